@@ -1,2 +1,4 @@
 #!/usr/bin/env -S bun run
-import './src/server.ts'
+import { main } from './src/cli.ts'
+
+await main(Bun.argv.slice(2))
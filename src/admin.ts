@@ -0,0 +1,261 @@
+/**
+ * Admin tools — reindex_path, clear_cache, and evict_file (ADMIN_TOOLS)
+ *
+ * WATCH keeps the index in step with the edits it sees, but a server
+ * without it, or one whose watcher missed a change (a network mount, a
+ * `git checkout` in a container), catches up only on restart, and
+ * restarting a server that took minutes to index is what an operator
+ * wants to avoid after a large external change. These tools force the
+ * refresh in place, for one subtree:
+ *
+ *   reindex_path  walk a directory or file of one or every collection,
+ *                 re-index what changed (by content hash, like a
+ *                 re-validation pass), add new files, and drop the
+ *                 documents whose files are gone
+ *   clear_cache   drop query-time caches (freshness checks, stores at
+ *                 git refs, license and CODEOWNERS lookups, cached graph
+ *                 answers) so the next call rebuilds them from disk
+ *   evict_file    remove documents from the index without reading
+ *                 anything; a file still on disk comes back with the
+ *                 next reindex_path, WATCH flush, or restart
+ *
+ * They change what every session sees, so they are registered only with
+ * ADMIN_TOOLS=1, and not with LAZY_INDEX or SHARD_DIR, whose stores the
+ * working tree is not the source of. Every change moves the store to a
+ * new generation, so sessions pinned with set_preferences snapshot keep
+ * their answers.
+ */
+
+import { existsSync } from "node:fs";
+import { resolve } from "node:path";
+import type { CollectionConfig, DocumentMeta, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { indexFile, listCollectionFiles, markdownDocId } from "./indexer";
+import { codeDocId, indexCodeFile, listCodeFiles } from "./code-indexer";
+import { readSource } from "./encoding";
+import { isUnder } from "./walk";
+import { isAbsolutePath, isWithin, relativePath, toolPath, toPosix } from "./paths";
+
+/** Bad paths, collections, and cache names. */
+export class AdminError extends Error {}
+
+/** A cache clear_cache can drop. */
+export interface CacheControl {
+  name: string;
+  /** What the cache holds */
+  description: string;
+  /** Drop every entry; returns how many there were */
+  clear(): number;
+}
+
+export interface ReindexReport {
+  collection: string;
+  /** Root-relative directory or file re-indexed ("" for the whole collection) */
+  path: string;
+  checked: number;
+  unchanged: number;
+  added: string[];
+  updated: string[];
+  removed: string[];
+  failed: string[];
+  elapsed_ms: number;
+}
+
+export interface EvictReport {
+  evicted: Array<{ doc_id: string; collection: string; file_path: string }>;
+  /** Targets that named no indexed document */
+  missing: string[];
+}
+
+/** Caches clear_cache drops, by the name it knows them by. */
+const QUERY_CACHES = [
+  { key: "staleness", name: "stale", description: "files found fresh by stale-result checks" },
+  { key: "refs", name: "refs", description: "stores built at git refs" },
+  { key: "licenses", name: "licenses", description: "license headers and files (LICENSE_TAGS)" },
+  { key: "codeowners", name: "codeowners", description: "parsed CODEOWNERS files" },
+  { key: "results", name: "results", description: "answers of the graph tools (RESULT_CACHE_SIZE)" },
+] as const;
+
+/** The CacheControls for the query-time caches a server has; both servers pass theirs. */
+export function queryCaches(
+  caches: Partial<Record<(typeof QUERY_CACHES)[number]["key"], { clear(): number }>>
+): CacheControl[] {
+  return QUERY_CACHES.flatMap(({ key, name, description }) => {
+    const cache = caches[key];
+    return cache ? [{ name, description, clear: () => cache.clear() }] : [];
+  });
+}
+
+interface Target {
+  collection: CollectionConfig;
+  kind: "markdown" | "code";
+  root: string;
+}
+
+/** Forced refreshes of the store from the working tree. */
+export class IndexAdmin {
+  private readonly caches: CacheControl[];
+
+  constructor(
+    private readonly store: DocumentStore,
+    private readonly config: IndexConfig,
+    options: { caches?: CacheControl[] } = {}
+  ) {
+    this.caches = options.caches ?? [];
+  }
+
+  /** The caches clear_cache knows, in registration order. */
+  listCaches(): CacheControl[] {
+    return this.caches;
+  }
+
+  /**
+   * Re-index `path` (a directory or file, relative to a collection root
+   * or absolute) in `collection`, or in every collection it lies in.
+   * Throws AdminError when the path is in none of them.
+   */
+  async reindexPath(path: string, collection?: string): Promise<ReindexReport[]> {
+    const targets = this.targets(collection);
+    const matches: Array<{ target: Target; under: string }> = [];
+    for (const target of targets) {
+      const under = this.relativeTo(target, path);
+      if (under === null) continue;
+      // A path that is gone still names the documents indexed under it
+      if (!existsSync(resolve(target.root, under)) && !this.indexedUnder(target, under).length) continue;
+      matches.push({ target, under });
+    }
+    if (matches.length === 0) {
+      const where = collection ? `collection "${collection}"` : "any collection";
+      throw new AdminError(`"${path}" is not a file or directory of ${where}`);
+    }
+
+    const reports: ReindexReport[] = [];
+    this.store.beginValidating();
+    try {
+      for (const { target, under } of matches) reports.push(await this.reindex(target, under));
+    } finally {
+      this.store.endValidating();
+    }
+    return reports;
+  }
+
+  /**
+   * Drop the caches named in `names`, or all of them. Returns the
+   * entries each held. Throws AdminError for an unknown name.
+   */
+  clearCaches(names?: string[]): Array<{ name: string; entries: number }> {
+    const unknown = (names ?? []).filter((name) => !this.caches.some((c) => c.name === name));
+    if (unknown.length > 0) {
+      const known = this.caches.map((c) => c.name).join(", ") || "none";
+      throw new AdminError(`Unknown cache ${unknown.map((n) => `"${n}"`).join(", ")} (known: ${known})`);
+    }
+    return this.caches
+      .filter((c) => !names?.length || names.includes(c.name))
+      .map((c) => ({ name: c.name, entries: c.clear() }));
+  }
+
+  /** Remove documents by doc_id or file path, optionally only in `collection`. */
+  evict(targets: string[], collection?: string): EvictReport {
+    const report: EvictReport = { evicted: [], missing: [] };
+    for (const target of targets) {
+      const exact = this.store.getDocMeta(target);
+      const docs = (exact ? [exact] : this.store.documentsAtPath(toPosix(target))).filter(
+        (d) => !collection || d.collection === collection
+      );
+      if (docs.length === 0) {
+        report.missing.push(target);
+        continue;
+      }
+      for (const doc of docs) {
+        this.store.removeDocument(doc.doc_id);
+        report.evicted.push({ doc_id: doc.doc_id, collection: doc.collection, file_path: doc.file_path });
+      }
+    }
+    return report;
+  }
+
+  // ── Internals ───────────────────────────────────────────────────────
+
+  private async reindex(target: Target, under: string): Promise<ReindexReport> {
+    const start = Date.now();
+    const report: ReindexReport = {
+      collection: target.collection.name,
+      path: under,
+      checked: 0,
+      unchanged: 0,
+      added: [],
+      updated: [],
+      removed: [],
+      failed: [],
+      elapsed_ms: 0,
+    };
+    const collection = { ...target.collection, root: target.root };
+    const files = target.kind === "markdown"
+      ? await listCollectionFiles(collection, under)
+      : await listCodeFiles(collection, under);
+
+    const seen = new Set<string>();
+    for (const file of files) {
+      report.checked++;
+      const relPath = relativePath(target.root, file);
+      const docId = target.kind === "markdown"
+        ? markdownDocId(collection.name, relPath)
+        : codeDocId(collection.name, relPath);
+      seen.add(docId);
+      try {
+        const raw = (await readSource(file)).text;
+        const existing = this.store.getDocMeta(docId);
+        if (existing?.content_hash === Bun.hash(raw).toString(16)) {
+          report.unchanged++;
+          continue;
+        }
+        this.store.addDocument(
+          target.kind === "markdown"
+            ? await indexFile(file, target.root, collection.name)
+            : await indexCodeFile(file, target.root, collection.name)
+        );
+        (existing ? report.updated : report.added).push(docId);
+      } catch {
+        report.failed.push(docId);
+      }
+    }
+
+    for (const doc of this.indexedUnder(target, under)) {
+      if (seen.has(doc.doc_id)) continue;
+      this.store.removeDocument(doc.doc_id);
+      report.removed.push(doc.doc_id);
+    }
+    report.elapsed_ms = Date.now() - start;
+    return report;
+  }
+
+  /** Documents of the target's collection at or below `under`. */
+  private indexedUnder(target: Target, under: string): DocumentMeta[] {
+    return this.store
+      .exportDocuments()
+      .map((d) => d.meta)
+      .filter((d) => d.collection === target.collection.name && isUnder(toPosix(d.file_path), under));
+  }
+
+  /** `path` relative to the target's root, "/"-separated; null when outside it. */
+  private relativeTo(target: Target, path: string): string | null {
+    const abs = isAbsolutePath(path) ? resolve(path) : resolve(target.root, toolPath(path));
+    return isWithin(target.root, abs) ? relativePath(target.root, abs) : null;
+  }
+
+  /** Every collection, or the one named; throws AdminError for an unknown name. */
+  private targets(name?: string): Target[] {
+    const all: Target[] = [
+      ...this.config.collections.map((collection) => ({ collection, kind: "markdown" as const, root: resolve(collection.root) })),
+      ...[...(this.config.code_collections ?? []), ...(this.config.dependency_collections ?? [])].map((collection) => ({
+        collection,
+        kind: "code" as const,
+        root: resolve(collection.root),
+      })),
+    ];
+    if (name === undefined) return all;
+    const named = all.filter((t) => t.collection.name === name);
+    if (named.length === 0) throw new AdminError(`Unknown collection "${name}"`);
+    return named;
+  }
+}
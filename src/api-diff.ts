@@ -0,0 +1,426 @@
+/**
+ * Exported Go API between two refs — the api_diff tool
+ *
+ * A release needs the right semver bump, and the bump follows from what
+ * changed in the exported surface, not from the commit messages. Each
+ * package whose Go files differ between the refs is read on both sides
+ * with packageApi() (go-api.ts), plus the exported fields of its
+ * structs, and the elements are compared by kind and name:
+ *
+ *   breaking   an element removed; a function, method, field, variable,
+ *              or constant whose type changed (parameter names aside); a
+ *              type whose kind or underlying type changed; a method
+ *              whose receiver became a pointer (values lose it); a
+ *              method added to an interface (outside implementations
+ *              stop satisfying it); a package removed
+ *   additive   anything added otherwise, including struct fields and
+ *              new packages
+ *
+ * Members of an added or removed type are not listed on their own.
+ * Packages under internal/, testdata/, and vendor/, and package main,
+ * are not importable from another module and are skipped unless asked
+ * for.
+ *
+ * The bump is major for a breaking change, minor for an additive one,
+ * and patch otherwise; from a v0 tag a breaking change is a minor bump,
+ * as v0 promises no compatibility. Versions come from `git show` and
+ * `git ls-tree` in each code collection root; the new side is a ref or
+ * the working tree.
+ */
+
+import { join, posix, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import { isPackageSource, packageApi, type GoApiEntry, type GoSourceFile } from "./go-api";
+import { parseGo } from "./parsers/go";
+import { moduleForPath, type GoModuleIndex } from "./go-modules";
+import { gitChangedFiles, gitLatestTag, gitListFiles, gitShowFile } from "./git-history";
+import { readSourceText } from "./encoding";
+import { currentDeadline } from "./deadline";
+
+export type ApiElementKind = "package" | "const" | "var" | "func" | "type" | "method" | "field";
+
+export type ApiChangeType = "added" | "removed" | "changed";
+
+export type Compat = "breaking" | "additive";
+
+export type SemverBump = "major" | "minor" | "patch";
+
+/** Bad refs and paths. */
+export class ApiDiffError extends Error {}
+
+/** One exported element: what is compared across the refs. */
+export interface ApiElement {
+  kind: ApiElementKind;
+  /** Qualified for members: Server.Start, Config.Addr */
+  name: string;
+  signature: string;
+  file: string;
+  line: number;
+  /** The element's type with names left out; equal shapes are compatible */
+  shape: string;
+  /** Methods: declared on *T */
+  pointer?: boolean;
+  /** Methods of interfaces */
+  interface?: boolean;
+}
+
+export interface ApiDifference {
+  /** Import path when a go.mod covers it, else the directory */
+  package: string;
+  dir: string;
+  kind: ApiElementKind;
+  name: string;
+  change: ApiChangeType;
+  compat: Compat;
+  /** Why, in a few words */
+  reason: string;
+  before?: string;
+  after?: string;
+  /** On the new side; the old side for removals */
+  file?: string;
+  line?: number;
+}
+
+export interface ApiDiffResult {
+  base: string;
+  /** A ref, or "working tree" */
+  head: string;
+  /** Packages whose files changed, and so were compared */
+  packages: number;
+  changes: ApiDifference[];
+  by_compat: Record<Compat, number>;
+  bump: SemverBump;
+  /** When base is a semver tag (v1.4.2) */
+  next_version?: string;
+  notes: string[];
+  /** The deadline stopped it before every package was compared */
+  truncated: boolean;
+}
+
+export interface ApiDiffOptions {
+  /** Old side; default: the latest tag */
+  base?: string;
+  /** New side; default: the working tree */
+  head?: string;
+  /** Only packages under this directory */
+  path?: string;
+  /** Compare internal/ packages and package main too */
+  include_internal?: boolean;
+}
+
+const NOT_IMPORTABLE = new Set(["internal", "testdata", "vendor"]);
+
+/** Split at top-level commas, outside (), [] and {}. */
+function splitTop(text: string): string[] {
+  const parts: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i < text.length; i++) {
+    const ch = text[i];
+    if ("([{".includes(ch)) depth++;
+    else if (")]}".includes(ch)) depth--;
+    else if (ch === "," && depth === 0) {
+      parts.push(text.slice(start, i).trim());
+      start = i + 1;
+    }
+  }
+  const last = text.slice(start).trim();
+  if (last || parts.length) parts.push(last);
+  return parts.filter(Boolean);
+}
+
+/** Index just past the bracket closing the one at `open`. */
+function closing(text: string, open: number): number {
+  const pairs: Record<string, string> = { "(": ")", "[": "]", "{": "}" };
+  const close = pairs[text[open]];
+  let depth = 0;
+  for (let i = open; i < text.length; i++) {
+    if (text[i] === text[open]) depth++;
+    else if (text[i] === close && --depth === 0) return i + 1;
+  }
+  return text.length;
+}
+
+const KEYWORD_TYPES = new Set(["chan", "func", "interface", "map", "struct"]);
+
+/** The types of a parameter list, names dropped: "a, b int, s ...string" is "int, int, ...string". */
+export function parameterTypes(list: string): string[] {
+  const parts = splitTop(list);
+  const named = parts.some((p) => {
+    const m = p.match(/^([\p{L}_][\p{L}\p{N}_]*)\s+\S/u);
+    return m !== null && !KEYWORD_TYPES.has(m[1]);
+  });
+  if (!named) return parts.map(compact);
+  const types: string[] = [];
+  let current = "";
+  for (let i = parts.length - 1; i >= 0; i--) {
+    const m = parts[i].match(/^[\p{L}_][\p{L}\p{N}_]*\s+(.+)$/u);
+    if (m) current = compact(m[1]);
+    types.unshift(current);
+  }
+  return types;
+}
+
+const compact = (text: string) => text.replace(/\s+/g, " ").replace(/\s*([()[\]{},*])\s*/g, "$1").replace(/,/g, ", ").trim();
+
+/**
+ * A func declaration's type without its receiver, names, or body:
+ * `func (s *S) Get(ctx context.Context, id string) (v *V, err error)`
+ * is `[](context.Context, string) (*V, error)`.
+ */
+export function funcShape(signature: string): string {
+  let rest = signature.replace(/\{\s*$/, "").trim().replace(/^func\s*/, "");
+  if (rest.startsWith("(")) rest = rest.slice(closing(rest, 0)).trim();
+  rest = rest.replace(/^[\p{L}_][\p{L}\p{N}_]*/u, "");
+  let typeParams = "";
+  if (rest.startsWith("[")) {
+    const end = closing(rest, 0);
+    typeParams = compact(rest.slice(0, end));
+    rest = rest.slice(end);
+  }
+  const end = rest.startsWith("(") ? closing(rest, 0) : 0;
+  const params = parameterTypes(rest.slice(1, end - 1)).join(", ");
+  let results = rest.slice(end).trim();
+  if (results.startsWith("(")) results = `(${parameterTypes(results.slice(1, closing(results, 0) - 1)).join(", ")})`;
+  else results = compact(results);
+  return `${typeParams}(${params})${results ? ` ${results}` : ""}`;
+}
+
+/** A method signature inside an interface body: `Get(id string) (*V, error)`. */
+const interfaceMethodShape = (text: string) => funcShape(`func ${text}`);
+
+/** The declared type of a const or var, "" when it is left to the value. */
+function valueType(signature: string): string {
+  const rest = signature
+    .replace(/^(?:const|var)\s+/, "")
+    .replace(/^[\p{L}_][\p{L}\p{N}_]*(?:\s*,\s*[\p{L}_][\p{L}\p{N}_]*)*/u, "")
+    .trim();
+  return compact(rest.split("=")[0]);
+}
+
+/** Code of a Go line: strings and struct tags emptied, the comment cut. */
+function codeOf(line: string): string {
+  return line.replace(/`[^`]*`|"(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'/g, '""').replace(/\/\/.*$/, "").trim();
+}
+
+/** Exported fields of the struct declared on 1-based `line`, with their types; embedded types by name. */
+function structFields(lines: string[], line: number): Array<{ name: string; type: string; line: number }> {
+  const fields: Array<{ name: string; type: string; line: number }> = [];
+  const read = (text: string, at: number) => {
+    const m = text.match(/^([\p{L}_][\p{L}\p{N}_]*(?:\s*,\s*[\p{L}_][\p{L}\p{N}_]*)*)\s+(.+)$/u);
+    if (m && !text.startsWith("*")) {
+      for (const name of m[1].split(",").map((n) => n.trim())) fields.push({ name, type: compact(m[2].replace(/\s*""$/, "")), line: at });
+      return;
+    }
+    const embedded = text.match(/^\*?(?:[\p{L}_][\p{L}\p{N}_]*\.)?([\p{L}_][\p{L}\p{N}_]*)(?:\[.*\])?(?:\s*"")?$/u);
+    if (embedded) fields.push({ name: embedded[1], type: compact(text.replace(/\s*""$/, "")), line: at });
+  };
+
+  const first = codeOf(lines[line - 1] ?? "");
+  const open = first.indexOf("{");
+  if (open >= 0 && first.lastIndexOf("}") > open) {
+    // type Point struct{ X, Y int }
+    for (const part of first.slice(open + 1, first.lastIndexOf("}")).split(";")) if (part.trim()) read(part.trim(), line);
+  } else {
+    let depth = open >= 0 ? 1 : 0;
+    for (let i = line; i < lines.length && depth > 0; i++) {
+      const text = codeOf(lines[i]);
+      if (depth === 1 && text && !text.startsWith("}")) read(text, i + 1);
+      for (const ch of text) {
+        if (ch === "{") depth++;
+        else if (ch === "}") depth--;
+      }
+    }
+  }
+  return fields.filter((f) => /^\p{Lu}/u.test(f.name));
+}
+
+/** The exported elements of a package, keyed by kind and name. */
+export function apiSurface(files: GoSourceFile[]): Map<string, ApiElement> {
+  const api = packageApi(files);
+  const surface = new Map<string, ApiElement>();
+  const add = (kind: ApiElementKind, name: string, entry: GoApiEntry, shape: string, extra: Partial<ApiElement> = {}) =>
+    surface.set(`${kind} ${name}`, { kind, name, signature: entry.signature, file: entry.file, line: entry.line, shape, ...extra });
+
+  for (const c of api.constants) add("const", c.name, c, valueType(c.signature));
+  for (const v of api.variables) add("var", v.name, v, valueType(v.signature));
+  for (const f of api.functions) add("func", f.name, f, funcShape(f.signature));
+  const sources = new Map(files.map((f) => [f.path, f.source.split("\n")]));
+  for (const t of api.types) {
+    const underlying = compact(t.signature.replace(/\{.*$/, "").replace(/^type\s+[\p{L}_][\p{L}\p{N}_]*/u, ""));
+    add("type", t.name, t, `${t.kind} ${underlying}`);
+    for (const c of t.constructors) add("func", c.name, c, funcShape(c.signature));
+    for (const m of t.methods) {
+      const name = `${t.name}.${m.name}`;
+      if (t.kind === "interface") add("method", name, m, interfaceMethodShape(m.signature), { interface: true });
+      else add("method", name, m, funcShape(m.signature), { pointer: /^func\s*\(\s*(?:[\p{L}_][\p{L}\p{N}_]*\s+)?\*/u.test(m.signature) });
+    }
+    if (t.kind === "struct") {
+      for (const field of structFields(sources.get(t.file) ?? [], t.line)) {
+        surface.set(`field ${t.name}.${field.name}`, {
+          kind: "field",
+          name: `${t.name}.${field.name}`,
+          signature: `${field.name} ${field.type}`,
+          file: t.file,
+          line: field.line,
+          shape: field.type,
+        });
+      }
+    }
+  }
+  return surface;
+}
+
+/** Differences between two surfaces of one package, in name order. */
+export function diffSurfaces(
+  before: Map<string, ApiElement>,
+  after: Map<string, ApiElement>
+): Array<Omit<ApiDifference, "package" | "dir">> {
+  const owner = (e: ApiElement) => (e.kind === "method" || e.kind === "field" ? e.name.slice(0, e.name.indexOf(".")) : null);
+  const changes: Array<Omit<ApiDifference, "package" | "dir">> = [];
+  const at = (e: ApiElement) => ({ file: e.file, line: e.line });
+
+  for (const [key, b] of before) {
+    const a = after.get(key);
+    if (!a) {
+      // A removed type stands for its members
+      if (owner(b) && !after.has(`type ${owner(b)}`)) continue;
+      changes.push({ kind: b.kind, name: b.name, change: "removed", compat: "breaking", reason: "removed", before: b.signature, ...at(b) });
+      continue;
+    }
+    let reason: string | null = null;
+    if (a.kind === "method" && !b.pointer && a.pointer && a.shape === b.shape) {
+      reason = `receiver is now a pointer: ${owner(a)} values no longer have ${a.name.slice(a.name.indexOf(".") + 1)}`;
+    } else if (a.shape !== b.shape && (a.kind !== "const" && a.kind !== "var" ? true : a.shape !== "" && b.shape !== "")) {
+      reason = a.kind === "type" ? "kind or underlying type changed" : a.kind === "field" ? "field type changed" : a.kind === "func" || a.kind === "method" ? "signature changed" : "type changed";
+    }
+    if (!reason) continue;
+    changes.push({ kind: a.kind, name: a.name, change: "changed", compat: "breaking", reason, before: b.signature, after: a.signature, ...at(a) });
+  }
+
+  for (const [key, a] of after) {
+    if (before.has(key)) continue;
+    if (owner(a) && !before.has(`type ${owner(a)}`)) continue;
+    const breaking = a.kind === "method" && a.interface === true;
+    changes.push({
+      kind: a.kind,
+      name: a.name,
+      change: "added",
+      compat: breaking ? "breaking" : "additive",
+      reason: breaking ? `method added to interface ${owner(a)}: types outside the package no longer implement it` : "added",
+      after: a.signature,
+      ...at(a),
+    });
+  }
+  return changes.sort((x, y) => x.name.localeCompare(y.name) || x.kind.localeCompare(y.kind));
+}
+
+/** The bump the changes call for, and the version after `base` when it is a semver tag. */
+export function semverBump(base: string, byCompat: Record<Compat, number>): { bump: SemverBump; next_version?: string; v0: boolean } {
+  const m = base.match(/^(v?)(\d+)\.(\d+)\.(\d+)$/);
+  const v0 = m !== null && m[2] === "0";
+  const bump: SemverBump = byCompat.breaking > 0 ? (v0 ? "minor" : "major") : byCompat.additive > 0 ? "minor" : "patch";
+  if (!m) return { bump, v0 };
+  const [major, minor, patch] = [Number(m[2]), Number(m[3]), Number(m[4])];
+  const next = bump === "major" ? [major + 1, 0, 0] : bump === "minor" ? [major, minor + 1, 0] : [major, minor, patch + 1];
+  return { bump, next_version: `${m[1]}${next.join(".")}`, v0 };
+}
+
+/** Compares the exported Go API of the code collections across refs. */
+export class ApiDiff {
+  private readonly roots: Map<string, string>;
+
+  constructor(config: IndexConfig, private readonly goModules?: GoModuleIndex) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  async diff(options: ApiDiffOptions = {}): Promise<ApiDiffResult> {
+    for (const ref of [options.base, options.head]) {
+      if (ref !== undefined && (!ref || ref.startsWith("-") || /\s/.test(ref))) throw new ApiDiffError(`Invalid ref "${ref}"`);
+    }
+    const prefix = (options.path ?? "").replace(/^\.?\/+|\/+$/g, "");
+    if (prefix.split("/").includes("..") || prefix.startsWith("/")) throw new ApiDiffError(`Invalid path "${options.path}"`);
+    const graph = this.goModules ? await this.goModules.graph() : null;
+    const deadline = currentDeadline();
+
+    let base = options.base;
+    const changes: ApiDifference[] = [];
+    let packages = 0;
+    let truncated = false;
+    for (const [collection, root] of this.roots) {
+      const from = base ?? gitLatestTag(root);
+      if (!from) continue;
+      base ??= from;
+      const changed = gitChangedFiles(root, from, options.head, prefix);
+      if (changed === null) throw new ApiDiffError(`Unknown ref "${from}"${options.head ? ` or "${options.head}"` : ""}, or ${root} is not a git repository`);
+      const dirs = [...new Set(changed.filter((f) => f.endsWith(".go")).map((f) => posix.dirname(f).replace(/^\.$/, "")))].filter(
+        (dir) => options.include_internal || !dir.split("/").some((seg) => NOT_IMPORTABLE.has(seg) || seg.startsWith(".") || seg.startsWith("_"))
+      );
+      if (dirs.length === 0) continue;
+      const sides = [from, options.head] as const;
+      const listed = sides.map((ref) => gitListFiles(root, ref, prefix) ?? []);
+
+      for (const dir of dirs.sort()) {
+        if (deadline?.expired()) {
+          truncated = true;
+          break;
+        }
+        const [before, after] = await Promise.all(
+          sides.map(async (ref, i) => {
+            const files: GoSourceFile[] = [];
+            for (const path of listed[i]) {
+              if (posix.dirname(path).replace(/^\.$/, "") !== dir || !isPackageSource(posix.basename(path))) continue;
+              const source = ref === undefined ? await readSourceText(join(root, path)).catch(() => null) : gitShowFile(root, ref, path);
+              if (source !== null) files.push({ path, source });
+            }
+            return files.sort((a, b) => (a.path < b.path ? -1 : 1));
+          })
+        );
+        // package main is a command: nothing imports it
+        const command = (files: GoSourceFile[]) => files.length > 0 && packageApi(files).name === "main";
+        if (!options.include_internal && (command(before) || command(after))) continue;
+        packages++;
+
+        const mod = graph && moduleForPath({ ...graph, modules: graph.modules.filter((m) => m.collection === collection) }, dir);
+        const rest = mod ? dir.slice(mod.dir.length).replace(/^\//, "") : "";
+        const pkg = mod ? (rest ? `${mod.module}/${rest}` : mod.module) : dir || ".";
+        if (before.length === 0 || after.length === 0) {
+          const side = before.length ? before : after;
+          changes.push({
+            package: pkg,
+            dir,
+            kind: "package",
+            name: pkg,
+            change: before.length ? "removed" : "added",
+            compat: before.length ? "breaking" : "additive",
+            reason: before.length ? "package removed" : "package added",
+            file: side[0].path,
+          });
+          continue;
+        }
+        for (const change of diffSurfaces(apiSurface(before), apiSurface(after))) changes.push({ package: pkg, dir, ...change });
+      }
+    }
+    if (base === undefined) throw new ApiDiffError("No tag to compare against; pass base, e.g. a release tag or commit");
+
+    const by_compat: Record<Compat, number> = { breaking: 0, additive: 0 };
+    for (const change of changes) by_compat[change.compat]++;
+    const { bump, next_version, v0 } = semverBump(base, by_compat);
+    const notes: string[] = [];
+    if (by_compat.breaking > 0 && v0) notes.push("v0 makes no compatibility promise, so breaking changes take a minor bump; consider whether the API is ready for v1.");
+    if (bump === "major" && next_version) {
+      notes.push(`Go modules at v2 and above need the major version in the module path (module .../v${next_version.replace(/^v/, "").split(".")[0]}).`);
+    }
+    return {
+      base,
+      head: options.head ?? "working tree",
+      packages,
+      changes: changes.sort((a, b) => a.package.localeCompare(b.package) || (a.compat === b.compat ? 0 : a.compat === "breaking" ? -1 : 1)),
+      by_compat,
+      bump,
+      ...(next_version ? { next_version } : {}),
+      notes,
+      truncated,
+    };
+  }
+}
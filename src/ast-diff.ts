@@ -0,0 +1,237 @@
+/**
+ * Structural diff of one file between two versions — the ast_diff tool
+ *
+ * Both versions go through the indexer's own parsers, and the symbol
+ * lists are compared instead of the lines:
+ *
+ *   added / removed      a symbol exists on one side only
+ *   renamed              a removed and an added symbol of the same kind
+ *                        whose bodies are equal once the name is ignored
+ *   signature_changed    the declaration line differs
+ *   body_changed         the body differs beyond whitespace
+ *
+ * Symbols are matched by kind-independent qualified name (Server.Start,
+ * or Start for a top-level function), so a method that moves within a
+ * file is not a change. A container's body excludes its members: a
+ * class whose only edit is inside one method reports just that method.
+ * Both flags can be set on one symbol; pure moves and re-indentation
+ * report nothing.
+ *
+ * Old versions come from `git show <ref>:<path>` in the collection
+ * root. The new side is another ref, content the caller supplies (an
+ * unsaved edit), or the working tree.
+ */
+
+import { join, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols, type CodeSymbol, type SymbolKind } from "./code-indexer";
+import { gitShowFile } from "./git-history";
+import { readSourceText } from "./encoding";
+
+/** Bodies shorter than this (`{}`, `pass`) are too common to pair as renames. */
+const MIN_RENAME_BODY = 12;
+
+/** Bad refs and paths. */
+export class AstDiffError extends Error {}
+
+export type ChangeType = "added" | "removed" | "renamed" | "signature_changed" | "body_changed";
+
+export interface SymbolChange {
+  change: ChangeType;
+  kind: SymbolKind;
+  /** Qualified name on the new side (old side for removals) */
+  name: string;
+  /** Old qualified name, for renames */
+  old_name?: string;
+  signature_before?: string;
+  signature_after?: string;
+  /** 1-based, on each side */
+  line_before?: number;
+  line_after?: number;
+  /** Set on signature_changed when the body changed too */
+  body_changed?: boolean;
+}
+
+interface Entry {
+  symbol: CodeSymbol;
+  name: string;
+  signature: string;
+  body: string;
+}
+
+/** Whitespace-insensitive declaration, without a body on the same line. */
+function normalizeSignature(signature: string): string {
+  const brace = signature.indexOf("{");
+  return (brace > 0 ? signature.slice(0, brace) : signature).replace(/\s+/g, " ").trim();
+}
+
+/** Symbols keyed by qualified name; repeats (overloads) get #2, #3. */
+function entries(symbols: CodeSymbol[]): Map<string, Entry> {
+  const byId = new Map(symbols.map((s) => [s.id, s]));
+  const qualified = (s: CodeSymbol): string => {
+    const parent = s.parent_id ? byId.get(s.parent_id) : undefined;
+    return parent ? `${qualified(parent)}.${s.name}` : s.name;
+  };
+  const result = new Map<string, Entry>();
+  for (const symbol of symbols) {
+    // A container's own body, with its members' lines cut out
+    const lines = symbol.content.split("\n");
+    for (const id of symbol.children_ids) {
+      const child = byId.get(id);
+      if (!child) continue;
+      for (let n = child.line_start; n <= child.line_end; n++) lines[n - symbol.line_start] = "";
+    }
+    const name = qualified(symbol);
+    let key = name;
+    for (let n = 2; result.has(key); n++) key = `${name}#${n}`;
+    const signature = normalizeSignature(symbol.signature);
+    const text = lines.join("\n").replace(/\s+/g, " ").trim();
+    result.set(key, { symbol, name, signature, body: text.startsWith(signature) ? text.slice(signature.length).trim() : text });
+  }
+  return result;
+}
+
+/** Structural changes from `before` to `after`, in new-file order. */
+export function diffSymbols(before: CodeSymbol[], after: CodeSymbol[]): SymbolChange[] {
+  const old = entries(before);
+  const next = entries(after);
+  const changes: SymbolChange[] = [];
+
+  for (const [key, b] of old) {
+    const a = next.get(key);
+    if (!a) continue;
+    const signatureChanged = a.signature !== b.signature;
+    const bodyChanged = a.body !== b.body;
+    if (!signatureChanged && !bodyChanged) continue;
+    changes.push({
+      change: signatureChanged ? "signature_changed" : "body_changed",
+      kind: a.symbol.kind,
+      name: a.name,
+      ...(signatureChanged ? { signature_before: b.signature, signature_after: a.signature } : {}),
+      line_before: b.symbol.line_start,
+      line_after: a.symbol.line_start,
+      ...(signatureChanged && bodyChanged ? { body_changed: true } : {}),
+    });
+  }
+
+  // Renames: same kind and same (non-trivial) body, with the name blanked out
+  const nameless = (e: Entry) =>
+    e.body.length < MIN_RENAME_BODY ? null : `${e.symbol.kind}\0${e.body.split(e.symbol.name).join("\0")}`;
+  const removed = [...old].filter(([key]) => !next.has(key));
+  const added = [...next].filter(([key]) => !old.has(key));
+  const unclaimed = new Map<string, Entry[]>();
+  for (const [, e] of removed) {
+    const key = nameless(e);
+    if (key === null) continue;
+    if (!unclaimed.has(key)) unclaimed.set(key, []);
+    unclaimed.get(key)!.push(e);
+  }
+  const renamed = new Set<Entry>();
+  for (const [, a] of added) {
+    const key = nameless(a);
+    const b = key === null ? undefined : unclaimed.get(key)?.shift();
+    if (b && b.symbol.kind !== "import") {
+      renamed.add(b);
+      changes.push({
+        change: "renamed",
+        kind: a.symbol.kind,
+        name: a.name,
+        old_name: b.name,
+        ...(a.signature !== b.signature ? { signature_before: b.signature, signature_after: a.signature } : {}),
+        line_before: b.symbol.line_start,
+        line_after: a.symbol.line_start,
+      });
+    } else {
+      changes.push({
+        change: "added",
+        kind: a.symbol.kind,
+        name: a.name,
+        signature_after: a.signature,
+        line_after: a.symbol.line_start,
+      });
+    }
+  }
+  for (const [, b] of removed) {
+    if (renamed.has(b)) continue;
+    changes.push({
+      change: "removed",
+      kind: b.symbol.kind,
+      name: b.name,
+      signature_before: b.signature,
+      line_before: b.symbol.line_start,
+    });
+  }
+
+  const position = (c: SymbolChange) => c.line_after ?? c.line_before ?? 0;
+  return changes.sort((x, y) => position(x) - position(y) || x.name.localeCompare(y.name));
+}
+
+export interface AstDiffResult {
+  file_path: string;
+  collection: string;
+  base: string;
+  /** A ref, "content", or "working tree" */
+  head: string;
+  changes: SymbolChange[];
+}
+
+/** Compares versions of files in the code collections. */
+export class AstDiff {
+  private readonly roots: Map<string, string>;
+
+  constructor(config: IndexConfig) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /**
+   * Diff `path` (a file path or doc_id) from `base` to `head`, to
+   * `content`, or to the working tree. Returns null when the file is
+   * missing on both sides.
+   */
+  async diff(
+    store: DocumentStore,
+    path: string,
+    options: { base?: string; head?: string; content?: string } = {}
+  ): Promise<AstDiffResult | null> {
+    const base = options.base ?? "HEAD";
+    for (const ref of [base, options.head]) {
+      if (ref !== undefined && (!ref || ref.startsWith("-") || /\s/.test(ref))) throw new AstDiffError(`Invalid ref "${ref}"`);
+    }
+
+    const doc = store.codeDocumentsAt(path).find((d) => d.file_path === path || d.doc_id === path);
+    const filePath = doc?.file_path ?? path;
+    if (filePath.split("/").includes("..") || filePath.startsWith("/")) throw new AstDiffError(`Invalid path "${path}"`);
+    const collections = doc ? [doc.collection] : [...this.roots.keys()];
+
+    for (const collection of collections) {
+      const root = this.roots.get(collection);
+      if (!root) continue;
+      const before = gitShowFile(root, base, filePath);
+      let after: string | null;
+      let head: string;
+      if (options.content !== undefined) {
+        after = options.content;
+        head = "content";
+      } else if (options.head !== undefined) {
+        after = gitShowFile(root, options.head, filePath);
+        head = options.head;
+      } else {
+        after = await readSourceText(join(root, filePath)).catch(() => null);
+        head = "working tree";
+      }
+      if (before === null && after === null) continue;
+      return {
+        file_path: filePath,
+        collection,
+        base,
+        head,
+        changes: diffSymbols(
+          before === null ? [] : parseCodeSymbols(before, filePath),
+          after === null ? [] : parseCodeSymbols(after, filePath)
+        ),
+      };
+    }
+    return null;
+  }
+}
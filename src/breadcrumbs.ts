@@ -0,0 +1,269 @@
+/**
+ * Enclosing scopes of a line — the breadcrumbs tool
+ *
+ * For a position in an indexed file, the chain of scopes it sits in,
+ * outermost first, so an agent can say exactly where a line lives:
+ *
+ *   example.com/app/cluster › class Manager › method Join › for _, n := range nodes
+ *
+ *   package     Go: the import path from the module graph, else the
+ *               package clause; Java, Kotlin, Scala: the package
+ *               declaration; C# and PHP: the namespace
+ *   module      Python: the dotted module path
+ *   directory   other languages: the file's directory
+ *   symbols     the indexed nodes around the line (class → method),
+ *               notebook cells, or markdown headings
+ *   blocks      control blocks inside the innermost symbol: if / for /
+ *               while / switch / try / with / ... and closures, by
+ *               braces or, for Python and Starlark, by indentation
+ *
+ * Blocks are read from the symbol's indexed source with strings and
+ * comments blanked. Brace literals (struct and object literals) are not
+ * scopes and are left out. Languages that close blocks with a keyword
+ * (Ruby, Lua, shell) get no blocks. Only the package clause is read
+ * from disk.
+ */
+
+import { basename, extname, join, posix, resolve } from "node:path";
+import type { DocumentMeta, IndexConfig, TreeNode } from "./types";
+import type { DocumentStore } from "./store";
+import { moduleForPath, type GoModuleIndex } from "./go-modules";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { readSourceText } from "./encoding";
+
+export type ScopeKind = "package" | "namespace" | "module" | "directory" | "symbol" | "cell" | "section" | "block";
+
+export interface Scope {
+  kind: ScopeKind;
+  /** The symbol kind (class, method, ...) for symbol scopes */
+  symbol_kind?: string;
+  name: string;
+  line_start?: number;
+  line_end?: number;
+  node_id?: string;
+}
+
+export interface Breadcrumb {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+  line: number;
+  column?: number;
+  cell?: number;
+  /** Outermost first */
+  scopes: Scope[];
+  /** The scopes' names joined with " › " */
+  trail: string;
+}
+
+/** Languages whose blocks are indented rather than braced */
+const INDENTED = new Set([".py", ".pyi", ".bzl"]);
+
+/** Languages whose blocks end with a keyword; no blocks are reported */
+const KEYWORD_BLOCKS = new Set([".rb", ".lua", ".sh", ".bash", ".zsh", ".mk"]);
+
+/** Headers that open a control block (after an optional "}" of the previous one) */
+const CONTROL = /^(?:\}\s*)?(?:else\s+if|if|else|elif|for|foreach|while|do|loop|switch|select|match|when|try|catch|except|finally|with|using|lock|synchronized|unsafe|defer|go|async\s+with|async\s+for)\b/;
+
+/** Headers that open a function literal */
+const CLOSURE = /\bfunc\s*\(|\bfunction\b|=>\s*\{?$|\blambda\b/;
+
+/** Python headers that open an indented block */
+const INDENT_HEADER = /^(?:if|elif|else|for|while|with|try|except|finally|match|case|def|class|async\s+(?:def|with|for))\b.*:$/;
+
+const PACKAGE_CLAUSES: Array<{ exts: string[]; kind: ScopeKind; pattern: RegExp }> = [
+  { exts: [".go"], kind: "package", pattern: /^package\s+(\w+)/m },
+  { exts: [".java", ".kt", ".kts", ".scala", ".groovy"], kind: "package", pattern: /^\s*package\s+([\w.]+)/m },
+  { exts: [".cs"], kind: "namespace", pattern: /^\s*namespace\s+([\w.]+)/m },
+  { exts: [".php"], kind: "namespace", pattern: /^\s*namespace\s+([\w\\]+)/m },
+];
+
+/** Longest block header shown */
+const MAX_HEADER = 80;
+
+/** Breadcrumbs over the documents of a store. */
+export class Breadcrumbs {
+  private readonly roots: Map<string, string>;
+
+  constructor(config: IndexConfig, private readonly goModules?: GoModuleIndex) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /**
+   * The scopes around `line` (and `column`, when given) of `doc`, in
+   * `cell` for notebooks; lines as results report them.
+   */
+  async at(store: DocumentStore, doc: DocumentMeta, line: number, column?: number, cell?: number): Promise<Breadcrumb> {
+    const scopes: Scope[] = [];
+    const code = doc.facets.content_type?.some((t) => t === "code" || t === "notebook");
+    if (code) {
+      const outer = await this.packageScope(doc);
+      if (outer) scopes.push(outer);
+    }
+
+    const innermost = store.nodeAt(doc.doc_id, line, line, cell);
+    const chain: TreeNode[] = [];
+    for (let node = innermost; node; node = node.parent_id ? store.getNodeContent(doc.doc_id, [node.parent_id])!.nodes[0] : null) {
+      chain.unshift(node);
+    }
+    for (const node of chain) scopes.push(nodeScope(node, doc, !!code));
+
+    if (code && innermost) {
+      const ext = extname(doc.file_path).toLowerCase();
+      const lines = blankLiterals(innermost.content, hashCommentsFor(doc.file_path)).split("\n");
+      const at = line - innermost.line_start + 1;
+      // A symbol's own body is its first block; the symbol scope stands for it
+      const own = scopes[scopes.length - 1];
+      const body = own.kind === "symbol" && own.symbol_kind !== "file" && own.symbol_kind !== "imports";
+      const blocks = INDENTED.has(ext) || doc.facets.language?.includes("python")
+        ? indentedBlocks(lines, at, body)
+        : KEYWORD_BLOCKS.has(ext)
+          ? []
+          : bracedBlocks(lines, at, column, body);
+      for (const b of blocks) {
+        scopes.push({
+          kind: "block",
+          name: b.header,
+          line_start: innermost.line_start + b.start - 1,
+          line_end: innermost.line_start + b.end - 1,
+        });
+      }
+    }
+
+    return {
+      doc_id: doc.doc_id,
+      collection: doc.collection,
+      file_path: doc.file_path,
+      line,
+      ...(column !== undefined ? { column } : {}),
+      ...(cell ? { cell } : {}),
+      scopes,
+      trail: scopes.map((s) => (s.kind === "symbol" ? `${s.symbol_kind} ${s.name}` : s.name)).join(" › "),
+    };
+  }
+
+  /** The package, namespace, module, or directory a code file belongs to. */
+  private async packageScope(doc: DocumentMeta): Promise<Scope | null> {
+    const ext = extname(doc.file_path).toLowerCase();
+    const dir = posix.dirname(doc.file_path).replace(/^\.$/, "");
+    if (ext === ".go" && this.goModules) {
+      const graph = await this.goModules.graph();
+      const mod = moduleForPath({ ...graph, modules: graph.modules.filter((m) => m.collection === doc.collection) }, dir);
+      if (mod) {
+        const rest = dir.slice(mod.dir.length).replace(/^\//, "");
+        return { kind: "package", name: rest ? `${mod.module}/${rest}` : mod.module };
+      }
+    }
+    const clause = PACKAGE_CLAUSES.find((c) => c.exts.includes(ext));
+    const root = this.roots.get(doc.collection);
+    if (clause && root) {
+      const source = await readSourceText(join(root, doc.file_path)).catch(() => "");
+      const m = source.match(clause.pattern);
+      if (m) return { kind: clause.kind, name: m[1] };
+    }
+    if (ext === ".py" || ext === ".pyi") {
+      const parts = doc.file_path.replace(/\.pyi?$/, "").split("/");
+      if (parts[parts.length - 1] === "__init__") parts.pop();
+      return parts.length ? { kind: "module", name: parts.join(".") } : null;
+    }
+    return { kind: "directory", name: dir || "." };
+  }
+}
+
+/** The scope a tree node stands for. */
+function nodeScope(node: TreeNode, doc: DocumentMeta, code: boolean): Scope {
+  const span = { line_start: node.line_start, line_end: node.line_end, node_id: node.node_id };
+  if (node.cell && node.level === 1) return { kind: "cell", name: node.title, ...span };
+  if (!code) return { kind: "section", name: node.title, ...span };
+  // Code node titles are "<kind> <name>"; see code-indexer.ts
+  const space = node.title.indexOf(" ");
+  if (node.title === "imports") return { kind: "symbol", symbol_kind: "imports", name: node.title, ...span };
+  if (space === -1 || node.title === basename(doc.file_path)) return { kind: "symbol", symbol_kind: "file", name: node.title, ...span };
+  return { kind: "symbol", symbol_kind: node.title.slice(0, space), name: node.title.slice(space + 1), ...span };
+}
+
+interface Block {
+  header: string;
+  /** 1-based lines within the scanned source */
+  start: number;
+  end: number;
+}
+
+function header(text: string): string {
+  const clean = text.replace(/\s+/g, " ").trim().replace(/^\}\s*/, "");
+  return clean.length > MAX_HEADER ? `${clean.slice(0, MAX_HEADER - 1)}…` : clean;
+}
+
+/**
+ * Braced blocks of `lines` (literals blanked) that enclose line `at`,
+ * before `column` when given; outermost first. Only control blocks and
+ * closures count; with `body`, the first block is the symbol's own and
+ * left out.
+ */
+function bracedBlocks(lines: string[], at: number, column: number | undefined, body: boolean): Block[] {
+  const open: Array<Block & { before: boolean; keep: boolean }> = [];
+  const enclosing: Block[] = [];
+  let first = body;
+  for (let l = 1; l <= lines.length; l++) {
+    const text = lines[l - 1];
+    for (let i = 0; i < text.length; i++) {
+      const ch = text[i];
+      if (ch !== "{" && ch !== "}") continue;
+      const before = l < at || (l === at && column !== undefined && i < column - 1);
+      if (ch === "{") {
+        let head = text.slice(0, i).trim();
+        // A brace on a line of its own belongs to the line above
+        if ((!head || head === ")") && l > 1) head = `${lines[l - 2].trim()} ${head}`.trim();
+        const keep = !first && (CONTROL.test(head) || CLOSURE.test(head));
+        first = false;
+        open.push({ header: header(`${head} {`), start: l, end: l, before, keep });
+        continue;
+      }
+      const block = open.pop();
+      if (!block) continue;
+      const after = l > at || (l === at && (column === undefined || i >= column - 1));
+      if (block.before && after && block.keep) enclosing.push({ header: block.header, start: block.start, end: l });
+    }
+  }
+  // Blocks left open (a symbol cut short) run to the end
+  for (const block of open) {
+    if (block.before && block.keep) enclosing.push({ header: block.header, start: block.start, end: lines.length });
+  }
+  return enclosing.sort((a, b) => a.start - b.start || b.end - a.end);
+}
+
+/**
+ * Indented blocks of `lines` that enclose line `at`; outermost first.
+ * With `body`, the first header is the symbol's own def or class and
+ * left out.
+ */
+function indentedBlocks(lines: string[], at: number, body: boolean): Block[] {
+  const indent = (text: string) => text.length - text.trimStart().length;
+  const stack: Array<Block & { indent: number }> = [];
+  const target = lines[at - 1]?.trim() ? indent(lines[at - 1]) : Infinity;
+  let first = body;
+  for (let l = 1; l < at; l++) {
+    const text = lines[l - 1];
+    if (!text.trim()) continue;
+    while (stack.length && stack[stack.length - 1].indent >= indent(text)) stack.pop();
+    if (!INDENT_HEADER.test(text.trim())) continue;
+    if (first) {
+      first = false;
+      continue;
+    }
+    stack.push({ header: header(text), start: l, end: l, indent: indent(text) });
+  }
+  while (stack.length && stack[stack.length - 1].indent >= target) stack.pop();
+
+  // A block ends at its last line indented deeper than its header
+  for (const block of stack) {
+    block.end = at;
+    for (let l = at + 1; l <= lines.length; l++) {
+      const text = lines[l - 1];
+      if (!text.trim()) continue;
+      if (indent(text) <= block.indent) break;
+      block.end = l;
+    }
+  }
+  return stack.map(({ header, start, end }) => ({ header, start, end }));
+}
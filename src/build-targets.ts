@@ -0,0 +1,462 @@
+/**
+ * Build targets from Makefiles and Bazel BUILD files — the build_targets tool
+ *
+ * In a polyglot monorepo the question before any build or test run is
+ * "which target builds this file?". The build files are indexed as code
+ * already (language-detect.ts names them); this reads their targets:
+ *
+ *   Bazel   BUILD, BUILD.bazel, BUCK: every top-level call with a
+ *           name = "...", e.g. go_library(name = "pool", srcs = [...]).
+ *           Sources are srcs / src / hdrs / textual_hdrs, as files or
+ *           glob([...], exclude = [...]) patterns relative to the
+ *           package; labels among them (":gen", "//proto:api") and in
+ *           deps / runtime_deps / exports / data are dependencies.
+ *           Top-level NAME = [...] assignments and select({...}) values
+ *           are followed.
+ *   Make    Makefile, GNUmakefile, *.mk: explicit rules, `targets:
+ *           prerequisites`. Prerequisites that are targets of the same
+ *           file are dependencies; the rest are sources, relative to
+ *           the Makefile's directory. $(VAR) and ${VAR} expand from the
+ *           file's own assignments and $(wildcard ...) to its patterns;
+ *           other functions, pattern rules (%.o: %.c), and included
+ *           makefiles are not followed.
+ *
+ * A glob owns a file only if no BUILD file sits between the two, as in
+ * Bazel, where a glob stops at package boundaries. Labels are Bazel's
+ * //pkg:name; Make targets are labelled <makefile>:<target>, e.g.
+ * tools/Makefile:lint.
+ */
+
+import { basename, posix } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { FileScanCache } from "./scan-cache";
+
+export type BuildSystem = "bazel" | "make";
+
+export const BUILD_SYSTEMS: BuildSystem[] = ["bazel", "make"];
+
+export interface BuildTarget {
+  system: BuildSystem;
+  /** //pkg:name, or <makefile>:<target> */
+  label: string;
+  name: string;
+  /** The Bazel rule (go_library, cc_test, ...); "phony" or "rule" for Make */
+  rule: string;
+  doc_id: string;
+  collection: string;
+  /** The build file */
+  file_path: string;
+  line: number;
+  /** Source files named outright, relative to the collection root */
+  sources: string[];
+  /** Glob patterns, relative to the collection root */
+  globs: string[];
+  /** Labels (Bazel) or targets of the same makefile (Make) this depends on */
+  deps: string[];
+  /** Exclusions of the globs, relative to the collection root */
+  excludes?: string[];
+}
+
+/** Attributes holding a target's own sources */
+const SOURCE_ATTRS = new Set(["srcs", "src", "hdrs", "textual_hdrs"]);
+
+/** Attributes holding only dependencies */
+const DEP_ATTRS = new Set(["deps", "runtime_deps", "exports", "implementation_deps", "data"]);
+
+/** The build system of a file name, or null for other files. */
+export function buildSystemOf(filePath: string): BuildSystem | null {
+  const name = basename(filePath);
+  if (name === "BUILD" || name === "BUILD.bazel" || name === "BUCK") return "bazel";
+  if (name === "Makefile" || name === "makefile" || name === "GNUmakefile" || name.endsWith(".mk")) return "make";
+  return null;
+}
+
+// ── Bazel ────────────────────────────────────────────────────────────
+
+type Token = { kind: "name" | "string" | "punct" | "other"; text: string; line: number };
+
+/** Starlark tokens: names, string literals (unquoted), and single-character punctuation. */
+function tokenize(source: string): Token[] {
+  const tokens: Token[] = [];
+  let line = 1;
+  for (let i = 0; i < source.length; ) {
+    const c = source[i];
+    if (c === "\n") {
+      line++;
+      i++;
+    } else if (c === " " || c === "\t" || c === "\r" || c === "\\") {
+      i++;
+    } else if (c === "#") {
+      while (i < source.length && source[i] !== "\n") i++;
+    } else if (c === '"' || c === "'" || (/[rRbB]/.test(c) && (source[i + 1] === '"' || source[i + 1] === "'"))) {
+      const raw = c !== '"' && c !== "'";
+      if (raw) i++;
+      const quote = source.startsWith(source[i].repeat(3), i) ? source[i].repeat(3) : source[i];
+      const start = line;
+      let text = "";
+      i += quote.length;
+      while (i < source.length && !source.startsWith(quote, i)) {
+        if (source[i] === "\\" && !raw) {
+          text += source[i + 1] ?? "";
+          i += 2;
+          continue;
+        }
+        if (source[i] === "\n") {
+          if (quote.length === 1) break;
+          line++;
+        }
+        text += source[i++];
+      }
+      i += quote.length;
+      tokens.push({ kind: "string", text, line: start });
+    } else if (/[A-Za-z_]/.test(c)) {
+      const m = /^[A-Za-z_]\w*/.exec(source.slice(i, i + 200))!;
+      tokens.push({ kind: "name", text: m[0], line });
+      i += m[0].length;
+    } else if ("()[]{},=:+.".includes(c)) {
+      tokens.push({ kind: "punct", text: c, line });
+      i++;
+    } else {
+      const m = /^[^\s#"'()[\]{},=:+.A-Za-z_]+/.exec(source.slice(i, i + 200));
+      tokens.push({ kind: "other", text: m?.[0] ?? c, line });
+      i += m?.[0].length || 1;
+    }
+  }
+  return tokens;
+}
+
+/** The strings an expression evaluates to, with glob() calls kept apart. */
+interface Value {
+  strings: string[];
+  globs: string[];
+  excludes: string[];
+}
+
+const empty = (): Value => ({ strings: [], globs: [], excludes: [] });
+
+function merge(into: Value, from: Value): Value {
+  into.strings.push(...from.strings);
+  into.globs.push(...from.globs);
+  into.excludes.push(...from.excludes);
+  return into;
+}
+
+/** A recursive-descent reader for the Starlark expressions BUILD files use. */
+class StarlarkReader {
+  i = 0;
+  constructor(
+    private readonly tokens: Token[],
+    private readonly env: Map<string, Value>
+  ) {}
+
+  peek(offset = 0): Token | undefined {
+    return this.tokens[this.i + offset];
+  }
+
+  is(text: string, offset = 0): boolean {
+    const t = this.peek(offset);
+    return t !== undefined && t.kind === "punct" && t.text === text;
+  }
+
+  /**
+   * An expression, up to a "," or closing bracket at this level, or at
+   * top level (outside brackets) the end of the line.
+   */
+  expression(topLevel = false): Value {
+    const value = empty();
+    for (let first = true; ; first = false) {
+      const t = this.peek();
+      if (!t || (t.kind === "punct" && ",)]}:".includes(t.text))) return value;
+      if (t.kind === "punct" && t.text === "=") return value;
+      if (topLevel && !first && this.tokens[this.i - 1].line < t.line) return value;
+      merge(value, this.primary());
+    }
+  }
+
+  private primary(): Value {
+    const t = this.tokens[this.i++];
+    if (t.kind === "string") return { strings: [t.text], globs: [], excludes: [] };
+    if (t.kind === "punct" && t.text === "[") return this.items("]", false);
+    if (t.kind === "punct" && t.text === "{") return this.items("}", true);
+    if (t.kind === "punct" && t.text === "(") return this.items(")", false);
+    if (t.kind === "name" && this.is("(")) {
+      this.i++;
+      if (t.text === "glob") return this.glob();
+      return this.arguments().reduce((all, [, v]) => merge(all, v), empty());
+    }
+    if (t.kind === "name") return this.env.get(t.text) ?? empty();
+    // + . and literals add nothing
+    return empty();
+  }
+
+  /** List or dict items up to `close`; for dicts only the values. */
+  private items(close: string, dict: boolean): Value {
+    const value = empty();
+    while (this.peek() && !this.is(close)) {
+      const item = this.expression();
+      if (dict && this.is(":")) {
+        this.i++;
+        merge(value, this.expression());
+      } else if (!dict) {
+        merge(value, item);
+      }
+      if (this.is(",")) this.i++;
+      else if (!this.is(close)) this.i++;
+    }
+    this.i++;
+    return value;
+  }
+
+  /** Call arguments after the "(": [keyword or "", value] pairs. */
+  arguments(): Array<[string, Value]> {
+    const args: Array<[string, Value]> = [];
+    while (this.peek() && !this.is(")")) {
+      let key = "";
+      const t = this.peek()!;
+      if (t.kind === "name" && this.is("=", 1)) {
+        key = t.text;
+        this.i += 2;
+      }
+      args.push([key, this.expression()]);
+      if (this.is(",")) this.i++;
+      else if (!this.is(")")) this.i++;
+    }
+    this.i++;
+    return args;
+  }
+
+  private glob(): Value {
+    const value = empty();
+    for (const [key, v] of this.arguments()) {
+      if (key === "exclude") value.excludes.push(...v.strings);
+      else if (key === "" || key === "include") value.globs.push(...v.strings);
+    }
+    return value;
+  }
+}
+
+/** A label as written in package `pkg`, made absolute: ":x" → //pkg:x, //a/b → //a/b:b. */
+export function normalizeLabel(label: string, pkg: string): string {
+  if (label.startsWith(":")) return `//${pkg}${label}`;
+  const m = /^(@[\w.~+-]*)?\/\/([^:]*)$/.exec(label);
+  if (m) return `${label}:${m[2].slice(m[2].lastIndexOf("/") + 1) || (m[1] ?? "").slice(1)}`;
+  return label;
+}
+
+const isLabel = (s: string) => s.startsWith(":") || s.startsWith("//") || s.startsWith("@");
+
+type TargetSource = Omit<BuildTarget, "doc_id" | "collection" | "file_path">;
+
+/** The named targets of a BUILD file at `filePath`, relative to the collection root. */
+export function parseBazelBuild(source: string, filePath: string): TargetSource[] {
+  const pkg = posix.dirname(filePath) === "." ? "" : posix.dirname(filePath);
+  const inPackage = (p: string) => (pkg ? `${pkg}/${p}` : p);
+  const tokens = tokenize(source);
+  const env = new Map<string, Value>();
+  const reader = new StarlarkReader(tokens, env);
+  const targets: TargetSource[] = [];
+
+  while (reader.peek()) {
+    const t = reader.peek()!;
+    // Only statements at the start of a line are top level
+    const first = reader.i === 0 || tokens[reader.i - 1].line < t.line;
+    if (first && t.kind === "name" && reader.is("=", 1)) {
+      reader.i += 2;
+      env.set(t.text, reader.expression(true));
+      continue;
+    }
+    if (!(first && t.kind === "name" && reader.is("(", 1))) {
+      reader.i++;
+      continue;
+    }
+    reader.i += 2;
+    const args = new Map(reader.arguments().filter(([key]) => key));
+    const name = args.get("name")?.strings[0];
+    if (!name) continue;
+
+    const sources: string[] = [];
+    const globs: string[] = [];
+    const excludes: string[] = [];
+    const deps: string[] = [];
+    for (const [key, value] of args) {
+      if (SOURCE_ATTRS.has(key)) {
+        for (const s of value.strings) (isLabel(s) ? deps : sources).push(isLabel(s) ? normalizeLabel(s, pkg) : inPackage(s));
+        globs.push(...value.globs.map(inPackage));
+        excludes.push(...value.excludes.map(inPackage));
+      } else if (DEP_ATTRS.has(key)) {
+        deps.push(...value.strings.filter(isLabel).map((s) => normalizeLabel(s, pkg)));
+      }
+    }
+    targets.push({
+      system: "bazel",
+      label: `//${pkg}:${name}`,
+      name,
+      rule: t.text,
+      line: t.line,
+      sources,
+      globs,
+      deps: [...new Set(deps)],
+      ...(excludes.length ? { excludes } : {}),
+    });
+  }
+  return targets;
+}
+
+// ── Make ─────────────────────────────────────────────────────────────
+
+/** Explicit rules of a makefile at `filePath`, relative to the collection root. */
+export function parseMakefile(source: string, filePath: string): TargetSource[] {
+  const dir = posix.dirname(filePath) === "." ? "" : posix.dirname(filePath);
+  const vars = new Map<string, string>();
+  const phony = new Set<string>();
+  const rules = new Map<string, { line: number; prereqs: string[] }>();
+
+  const expand = (text: string, depth = 0): string =>
+    depth > 10
+      ? ""
+      : text.replace(/\$(?:\(([^()]*)\)|\{([^{}]*)\}|([A-Za-z_]))/g, (_, paren, brace, single) => {
+          const inner: string = paren ?? brace ?? single;
+          const call = /^wildcard\s+(.*)$/.exec(inner);
+          if (call) return expand(call[1], depth + 1);
+          // Other functions are not evaluated
+          if (/\s/.test(inner)) return "";
+          return expand(vars.get(inner) ?? "", depth + 1);
+        });
+
+  const lines = source.split("\n");
+  let define = false;
+  for (let i = 0; i < lines.length; i++) {
+    const number = i + 1;
+    let text = lines[i];
+    // Recipes run commands and define bodies are text; neither declares anything
+    if (define) {
+      define = !/^\s*endef\b/.test(text);
+      continue;
+    }
+    if (/^\s*(?:(?:export|override)\s+)?define\b/.test(text)) {
+      define = true;
+      continue;
+    }
+    if (text.startsWith("\t")) continue;
+    while (text.endsWith("\\") && i + 1 < lines.length) text = `${text.slice(0, -1)} ${lines[++i]}`;
+    text = text.replace(/(?<!\\)#.*$/, "").trimEnd();
+    if (!text.trim()) continue;
+
+    const assign = /^\s*(?:(?:export|override)\s+)*([A-Za-z_][\w.-]*)\s*(::?=|:::=|\?=|\+=|!=|=)\s*(.*)$/.exec(text);
+    if (assign) {
+      const [, name, op, value] = assign;
+      if (op === "?=" && vars.has(name)) continue;
+      if (op === "!=") continue;
+      const resolved = op === ":=" || op === "::=" || op === ":::=" ? expand(value) : value;
+      vars.set(name, op === "+=" ? `${vars.get(name) ?? ""} ${resolved}` : resolved);
+      continue;
+    }
+    if (/^\s*(?:-?s?include|ifn?eq|ifn?def|else|endif|export|unexport|vpath)\b/.test(text)) continue;
+
+    const rule = /^([^:=]+?)\s*::?(?!=)(.*)$/.exec(text);
+    if (!rule) continue;
+    const deps = rule[2].split(";")[0];
+    // target: VAR = value sets a variable for the target
+    if (/(?:^|\s)[\w.-]+\s*(?:[:+?!]?=)/.test(deps)) continue;
+    const prereqs = expand(deps).split(/\s+/).filter((p) => p && p !== "|");
+    for (const target of expand(rule[1]).split(/\s+/).filter(Boolean)) {
+      if (target === ".PHONY") {
+        for (const p of prereqs) phony.add(p);
+        continue;
+      }
+      // Special targets (.SUFFIXES), suffix rules (.c.o), and pattern rules
+      if ((target.startsWith(".") && !target.includes("/")) || target.includes("%")) continue;
+      const existing = rules.get(target);
+      if (existing) existing.prereqs.push(...prereqs);
+      else rules.set(target, { line: number, prereqs: [...prereqs] });
+    }
+  }
+
+  const inDir = (p: string) => posix.normalize(dir ? `${dir}/${p}` : p);
+  return [...rules].map(([name, { line, prereqs }]): TargetSource => {
+    const deps = [...new Set(prereqs.filter((p) => rules.has(p)))];
+    const files = prereqs.filter((p) => !rules.has(p) && !phony.has(p));
+    return {
+      system: "make",
+      label: `${filePath}:${name}`,
+      name,
+      rule: phony.has(name) ? "phony" : "rule",
+      line,
+      sources: [...new Set(files.filter((p) => !/[*?[]/.test(p)).map(inDir))],
+      globs: [...new Set(files.filter((p) => /[*?[]/.test(p)).map(inDir))],
+      deps,
+    };
+  });
+}
+
+// ── Index ────────────────────────────────────────────────────────────
+
+/** Arguments build_targets cannot answer. */
+export class BuildTargetError extends Error {}
+
+export interface BuildMatch {
+  target: BuildTarget;
+  /** The source entry or glob pattern that names the file */
+  via: string;
+}
+
+export class BuildTargetIndex {
+  private readonly files: FileScanCache<BuildTarget[]>;
+
+  constructor(config: IndexConfig) {
+    this.files = new FileScanCache(config, (source, meta) => {
+      const parsed = buildSystemOf(meta.file_path) === "bazel" ? parseBazelBuild(source, meta.file_path) : parseMakefile(source, meta.file_path);
+      return parsed.map((t): BuildTarget => ({ ...t, doc_id: meta.doc_id, collection: meta.collection, file_path: meta.file_path }));
+    });
+  }
+
+  /** Every target of the indexed build files, optionally under a path prefix, in file and line order. */
+  async scan(store: DocumentStore, pathPrefix?: string): Promise<BuildTarget[]> {
+    const files = await this.files.scan(store, { pathPrefix, accept: (path) => buildSystemOf(path) !== null });
+    return files.flat().sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+  }
+
+  /** Targets whose sources name `filePath` (relative to a collection root), outright or by glob. */
+  async targetsFor(store: DocumentStore, filePath: string): Promise<BuildMatch[]> {
+    const path = posix.normalize(filePath.replace(/^\.\//, ""));
+    const all = await this.scan(store);
+    // A glob stops at the nearest BUILD package above the file
+    const packages = new Set(all.filter((t) => t.system === "bazel").map((t) => `${t.collection}\0${posix.dirname(t.file_path)}`));
+    const owningPackage = (collection: string) => {
+      for (let dir = posix.dirname(path); ; dir = posix.dirname(dir)) {
+        if (packages.has(`${collection}\0${dir}`)) return dir;
+        if (dir === "." || dir === "/") return null;
+      }
+    };
+
+    const matches: BuildMatch[] = [];
+    for (const target of all) {
+      if (target.sources.includes(path)) {
+        matches.push({ target, via: path });
+        continue;
+      }
+      if (target.system === "bazel" && owningPackage(target.collection) !== posix.dirname(target.file_path)) continue;
+      const pattern = target.globs.find((g) => new Bun.Glob(g).match(path));
+      if (!pattern || target.excludes?.some((g) => new Bun.Glob(g).match(path))) continue;
+      matches.push({ target, via: pattern });
+    }
+    return matches;
+  }
+
+  /** Targets a label names: //pkg:name, //pkg, <makefile>:<target>, or a bare name. */
+  async find(store: DocumentStore, label: string): Promise<BuildTarget[]> {
+    const all = await this.scan(store);
+    const absolute = label.startsWith("//") || label.startsWith("@") ? normalizeLabel(label, "") : label;
+    const exact = all.filter((t) => t.label === absolute);
+    return exact.length ? exact : all.filter((t) => t.name === label.replace(/^:/, ""));
+  }
+
+  /** Targets that depend on `target` directly. */
+  async dependents(store: DocumentStore, target: BuildTarget): Promise<BuildTarget[]> {
+    const ref = target.system === "bazel" ? target.label : target.name;
+    return (await this.scan(store)).filter(
+      (t) => t.system === target.system && t.deps.includes(ref) && (t.system === "bazel" || t.file_path === target.file_path)
+    );
+  }
+}
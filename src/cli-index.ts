@@ -7,12 +7,27 @@
  *   bun run src/cli-index.ts --root /path/to/docs     # Custom path
  *   bun run src/cli-index.ts --tree <doc_id>           # Show tree for a doc
  *   bun run src/cli-index.ts --search "query"          # Search indexed docs
+ *   bun run src/cli-index.ts --build-shards <dir>      # Build a sharded index
+ *   bun run src/cli-index.ts --build-shards <dir> --shard docs/api,docs/guides
+ *                                                     # Rebuild only those shards
+ *   bun run src/cli-index.ts --build-shards <dir> --workers 8
+ *                                                     # Build with 8 worker processes
+ *   bun run src/cli-index.ts --build-shards <dir> --worker 3/8
+ *                                                     # Build one worker's share only
+ *   bun run src/cli-index.ts --merge-shards <dir>      # Write the manifest after --worker runs
+ *
+ * --code <root> (or CODE_ROOT) adds the code collection, as the server
+ * does. Workers on other machines must see the same tree at the same
+ * absolute roots (shard files are fingerprinted with them) and write to
+ * a shared shard directory; --merge-shards runs once they are all done.
  */
 
 import { indexAllCollections } from "./indexer";
 import { DocumentStore } from "./store";
+import { buildShards, buildShardSlice, buildShardsDistributed, mergeShards } from "./shards";
 import { singleRootConfig } from "./types";
 import type { IndexConfig } from "./types";
+import type { IndexCompression } from "./index-blocks";
 
 const args = Bun.argv.slice(2);
 
@@ -26,8 +41,68 @@ const docs_root = getArg("root") || process.env.DOCS_ROOT || "./docs";
 const config: IndexConfig = singleRootConfig(docs_root);
 config.max_depth = 6;
 config.summary_length = 200;
+const code_root = getArg("code") || process.env.CODE_ROOT;
+if (code_root) config.code_collections = [{ name: "code", root: code_root, weight: 1.0 }];
+// Workers inherit the environment, so they write shards the same way
+const compression: IndexCompression = process.env.INDEX_COMPRESSION === "zstd" ? "zstd" : "none";
+
+/** Run one --worker slice as a child process of this script. */
+async function spawnWorker(shardDir: string, worker: number, count: number): Promise<number> {
+  const child = Bun.spawn(
+    [
+      process.execPath,
+      import.meta.path,
+      "--build-shards", shardDir,
+      "--worker", `${worker}/${count}`,
+      "--root", docs_root,
+      ...(code_root ? ["--code", code_root] : []),
+    ],
+    { stdout: "inherit", stderr: "inherit" }
+  );
+  return child.exited;
+}
 
 async function main() {
+  // Build (or partially rebuild) a sharded index and exit
+  const shardDir = getArg("build-shards");
+  const slice = getArg("worker")?.match(/^(\d+)\/(\d+)$/);
+  if (shardDir && slice) {
+    const [worker, count] = [parseInt(slice[1], 10), parseInt(slice[2], 10)];
+    const entries = await buildShardSlice(config, shardDir, worker, count, { compression });
+    console.log(`Worker ${worker}/${count}: built ${entries.length} shard(s)`);
+    return;
+  }
+  const workers = parseInt(getArg("workers") || "0", 10);
+  if (shardDir && workers > 0) {
+    console.log(`\n🧩 Building shards in ${shardDir} with ${workers} worker(s)\n`);
+    const result = await buildShardsDistributed(config, shardDir, {
+      workers,
+      compression,
+      run: (worker, count) => spawnWorker(shardDir, worker, count),
+    });
+    console.log(`\n   ${result.manifest.shards.length} shard(s) merged; ${result.rebuilt.length} rebuilt by the coordinator`);
+    return;
+  }
+  const mergeDir = getArg("merge-shards");
+  if (mergeDir) {
+    const { manifest, missing } = await mergeShards(config, mergeDir);
+    console.log(`Merged ${manifest.shards.length} shard(s) into ${mergeDir}/manifest.json`);
+    if (missing.length > 0) {
+      console.error(`Missing or stale: ${missing.join(", ")}. Run the workers that own them again.`);
+      process.exitCode = 1;
+    }
+    return;
+  }
+  if (shardDir) {
+    const only = (getArg("shard") || "").split(",").filter(Boolean);
+    console.log(`\n🧩 Building shards in ${shardDir}${only.length ? ` (${only.join(", ")})` : ""}\n`);
+    const manifest = await buildShards(config, shardDir, { only, compression });
+    for (const entry of manifest.shards) {
+      console.log(`   ${entry.id.padEnd(40)} ${entry.document_count} docs  (built ${entry.built_at})`);
+    }
+    return;
+  }
+
   console.log(`\n📁 Indexing: ${docs_root}\n`);
 
   const documents = await indexAllCollections(config);
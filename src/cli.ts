@@ -0,0 +1,535 @@
+/**
+ * `treenav-mcp` command-line entry point
+ *
+ * Subcommands:
+ *   treenav-mcp [serve]          Start the MCP server on stdio (default)
+ *   treenav-mcp index [path]     Build and persist the index, then exit
+ *   treenav-mcp import <file>    Install a snapshot exported by `index --export`
+ *   treenav-mcp search "query"   Query a persisted index from the shell
+ *   treenav-mcp report           Markdown overview of the indexed repository
+ *   treenav-mcp proxy <url>      Forward stdio MCP to a remote serve:http
+ *   treenav-mcp completion bash  Print a bash/zsh/fish completion script
+ *
+ * Commands and their flags are declared once in commands.ts; the yargs
+ * program that dispatches them, its --help, and shell completion are
+ * generated from that table.
+ *
+ * `index` writes the same artifact the server warm-starts from
+ * (INDEX_CACHE, default .treenav/index.json), so a CI job can pre-warm
+ * it and `serve` picks it up on the next launch. The run exits non-zero
+ * when the share of files that failed to parse exceeds
+ * --max-failure-rate, and in that case writes nothing. `index --verify`
+ * instead compares an existing artifact with the working tree (files it
+ * lacks, entries whose file is gone, content hash mismatches) and exits
+ * 1 on any difference; with --repair it patches the artifact in place,
+ * re-parsing only the files that differ.
+ *
+ * `index --export` also writes a portable snapshot (snapshot.ts), which
+ * `import` checks against the local config and git HEAD and installs
+ * as the local artifact, so an index built in CI serves a laptop.
+ *
+ * `proxy` indexes nothing: it serves the tools of a shared serve:http
+ * host on stdio (remote.ts), for clients on machines too small to index
+ * the whole repository.
+ *
+ * `search` loads that artifact into a DocumentStore (plus the glossary)
+ * and runs the same searchDocuments + formatSearchResults pipeline the
+ * search_documents tool uses, so shell results rank identically.
+ *
+ * `report` reads the same artifact and prints the overview of
+ * report.ts: languages, layout, Go modules, entry points, the largest
+ * and most referenced packages, and tests. With --out it writes a file
+ * an agent can be pointed at instead of exploring from scratch.
+ */
+
+import { existsSync } from "node:fs";
+import { writeFile } from "node:fs/promises";
+import { basename, join, resolve } from "node:path";
+import { StdioServerTransport } from "@modelcontextprotocol/sdk/server/stdio.js";
+import { indexAllCollections } from "./indexer";
+import {
+  ConfigError,
+  parsePathBoosts,
+  parseSynonymGroups,
+  readConfigFile,
+  resolveConfig,
+  toIndexConfig,
+  type ParsedArgs,
+  type ServeConfig,
+} from "./config";
+import {
+  DEFAULT_INDEX_CACHE_PATH,
+  loadIndexCache,
+  revalidateIndex,
+  saveIndexCache,
+  verifyIndexCache,
+  type VerificationReport,
+} from "./index-cache";
+import { DocumentStore } from "./store";
+import { exportSnapshot, importSnapshot, SnapshotError } from "./snapshot";
+import { connectRemote, createProxyServer, RemoteError } from "./remote";
+import { applyRecencyBoost } from "./git-history";
+import { formatSearchResults } from "./search-formatter";
+import { DEFAULT_MAX_FAILURE_RATE, SHELLS, program, type CommandArgs } from "./commands";
+import { completeWords, completionScript } from "./completion";
+import { vendorBoosts } from "./vendor";
+import { loadRankingWasm } from "./wasm-ranking";
+import { ParseCache, setParseCache } from "./parse-cache";
+import { EntrypointIndex } from "./entrypoints";
+import { GoModuleIndex } from "./go-modules";
+import { loadCoverProfile } from "./test-coverage";
+import { buildReport, DEFAULT_REPORT_TOP, formatReport } from "./report";
+import { CASE_MODES } from "./types";
+import type { CaseMode, IndexConfig, IndexRunStats } from "./types";
+import type { IndexCompression } from "./index-blocks";
+
+/**
+ * Resolve the shared configuration for a subcommand: its own flags
+ * are mapped onto config options (`aliases`), then the usual
+ * flag > env > file > default precedence applies.
+ */
+async function subcommandConfig(
+  args: Record<string, unknown>,
+  aliases: Record<string, string | undefined>
+): Promise<ServeConfig> {
+  const flags: ParsedArgs["flags"] = {};
+  for (const [flag, value] of Object.entries(args)) {
+    if (typeof value === "string" || typeof value === "boolean") flags[flag] = value;
+  }
+  const merged: ParsedArgs["flags"] = { ...flags };
+  for (const [flag, value] of Object.entries(aliases)) {
+    if (value) merged[flag] = value;
+  }
+  const file = await readConfigFile(flags, process.env);
+  return resolveConfig({ flags: merged, env: process.env, file: file.values }).config;
+}
+
+function flagString(args: Record<string, unknown>, name: string): string | undefined {
+  const value = args[name];
+  return typeof value === "string" ? value : undefined;
+}
+
+// ── index ────────────────────────────────────────────────────────────
+
+export async function runIndexCommand(
+  args: CommandArgs,
+  out: (text: string) => void = (text) => console.log(text)
+): Promise<number> {
+  if (args.repair && !args.verify) {
+    console.error("--repair requires --verify");
+    return 2;
+  }
+
+  const maxFailureRate = parseFloat(
+    flagString(args, "max-failure-rate") ?? String(DEFAULT_MAX_FAILURE_RATE)
+  );
+  if (Number.isNaN(maxFailureRate) || maxFailureRate < 0 || maxFailureRate > 1) {
+    console.error("--max-failure-rate must be a number between 0 and 1");
+    return 2;
+  }
+
+  const settings = await subcommandConfig(args, {
+    "docs-root": flagString(args, "docs_root"),
+    "code-root": flagString(args, "code"),
+  });
+  const config = toIndexConfig(settings);
+  const artifact = resolve(flagString(args, "out") || settings.index_cache || DEFAULT_INDEX_CACHE_PATH);
+
+  if (args.verify) {
+    const verified = await verifyIndex(artifact, config, Boolean(args.repair), settings.index_compression, out);
+    if (verified !== null) return verified;
+  }
+
+  const start = Date.now();
+  const stats: IndexRunStats = { files: 0, failed: [] };
+  const parseCache = settings.parse_cache ? new ParseCache(settings.parse_cache) : null;
+  setParseCache(parseCache);
+  const documents = await indexAllCollections(config, stats);
+
+  const rate = stats.files === 0 ? 0 : stats.failed.length / stats.files;
+  if (stats.failed.length > 0) {
+    console.error(`\n${stats.failed.length}/${stats.files} files failed to parse (${(rate * 100).toFixed(1)}%):`);
+    for (const f of stats.failed.slice(0, 20)) {
+      console.error(`  ${f.file}: ${f.error}`);
+    }
+    if (stats.failed.length > 20) console.error(`  … and ${stats.failed.length - 20} more`);
+  }
+
+  if (rate > maxFailureRate) {
+    console.error(
+      `\nParse failure rate ${(rate * 100).toFixed(1)}% exceeds --max-failure-rate ${(maxFailureRate * 100).toFixed(1)}% — index not written`
+    );
+    return 1;
+  }
+
+  await saveIndexCache(artifact, config, documents, { compression: settings.index_compression });
+  const elapsed = ((Date.now() - start) / 1000).toFixed(1);
+  out(`\nWrote ${documents.length} documents to ${artifact} in ${elapsed}s`);
+  if (parseCache) {
+    const { hits, misses } = parseCache.stats();
+    out(`Parse cache ${parseCache.dir}: ${hits} reused, ${misses} parsed`);
+  }
+
+  const exportPath = flagString(args, "export");
+  if (exportPath) {
+    const snapshot = await exportSnapshot(resolve(exportPath), config, documents);
+    const commits = [...new Set(snapshot.collections.map((c) => c.commit?.slice(0, 12) ?? "no commit"))];
+    out(`Exported snapshot to ${resolve(exportPath)} (${commits.join(", ")})`);
+  }
+  return 0;
+}
+
+/** Entries listed per category before "… and N more". */
+const VERIFY_LIST_LIMIT = 20;
+
+/**
+ * `index --verify`: report how the artifact differs from the working
+ * tree, and with `repair` patch it. Returns the exit code, or null when
+ * the artifact is unusable and `repair` asks for a full rebuild.
+ */
+async function verifyIndex(
+  artifact: string,
+  config: IndexConfig,
+  repair: boolean,
+  compression: IndexCompression,
+  out: (text: string) => void
+): Promise<number | null> {
+  const report = await verifyIndexCache(artifact, config);
+  if (report.unusable) {
+    console.error(`${artifact} is unusable: ${report.unusable}`);
+    if (!repair) return 1;
+    console.error("Rebuilding it from scratch");
+    return null;
+  }
+
+  out(formatVerification(artifact, report));
+  const differences = report.missing.length + report.orphaned.length + report.mismatched.length + report.failed.length;
+  if (differences === 0 || !repair) return differences === 0 ? 0 : 1;
+
+  const store = new DocumentStore();
+  store.load((await loadIndexCache(artifact, config))!);
+  const fixed = await revalidateIndex(store, config);
+  await saveIndexCache(artifact, config, store.exportDocuments(), { compression });
+  out(
+    `\nRepaired ${artifact}: ${fixed.updated.length} updated, ${fixed.added.length} added, ` +
+      `${fixed.removed.length} removed, ${fixed.failed.length} failed`
+  );
+  return fixed.failed.length > 0 ? 1 : 0;
+}
+
+function formatVerification(artifact: string, report: VerificationReport): string {
+  const lines = [
+    `Verified ${artifact} (${report.indexed} documents) against ${report.files} file(s) in ${report.elapsed_ms}ms`,
+    `  ${report.unchanged} unchanged`,
+  ];
+  const sections: Array<[string[], string]> = [
+    [report.missing, "missing (on disk, not in the index)"],
+    [report.orphaned, "orphaned (in the index, file gone or no longer matched)"],
+    [report.mismatched, "mismatched (content hash differs)"],
+    [report.failed, "unreadable"],
+  ];
+  for (const [ids, label] of sections) {
+    if (ids.length === 0) continue;
+    lines.push(`  ${ids.length} ${label}:`);
+    for (const id of ids.slice(0, VERIFY_LIST_LIMIT)) lines.push(`    ${id}`);
+    if (ids.length > VERIFY_LIST_LIMIT) lines.push(`    … and ${ids.length - VERIFY_LIST_LIMIT} more`);
+  }
+  return lines.join("\n");
+}
+
+// ── import ───────────────────────────────────────────────────────────
+
+export async function runImportCommand(
+  args: CommandArgs,
+  out: (text: string) => void = (text) => console.log(text)
+): Promise<number> {
+  const snapshotPath = flagString(args, "snapshot");
+  if (!snapshotPath) {
+    console.error("Missing snapshot path (see treenav-mcp import --help)");
+    return 2;
+  }
+
+  const settings = await subcommandConfig(args, {
+    "docs-root": flagString(args, "root"),
+    "code-root": flagString(args, "code"),
+  });
+  const artifact = resolve(flagString(args, "out") || settings.index_cache || DEFAULT_INDEX_CACHE_PATH);
+  try {
+    const { snapshot, drift } = await importSnapshot(resolve(snapshotPath), toIndexConfig(settings), artifact, {
+      force: Boolean(args.force),
+      compression: settings.index_compression,
+    });
+    for (const d of drift) console.error(`Warning: ${d}`);
+    out(`Imported ${snapshot.documents.length} documents (exported ${snapshot.created_at}) to ${artifact}`);
+    return 0;
+  } catch (err) {
+    if (!(err instanceof SnapshotError)) throw err;
+    console.error(err.message);
+    return 1;
+  }
+}
+
+// ── proxy ────────────────────────────────────────────────────────────
+
+/**
+ * Connect to the remote endpoint and serve it on stdio. Resolves with
+ * 0 once serving (the process then lives as long as stdin), or with
+ * the exit code when the remote cannot be reached.
+ */
+export async function runProxyCommand(args: CommandArgs): Promise<number> {
+  const url = flagString(args, "url");
+  if (!url) {
+    console.error("Missing remote URL (see treenav-mcp proxy --help)");
+    return 2;
+  }
+
+  const settings = await subcommandConfig(args, { "http-token": flagString(args, "token") });
+  try {
+    const remote = await connectRemote({ url, token: settings.http_token });
+    await createProxyServer(remote, url).connect(new StdioServerTransport());
+  } catch (err) {
+    if (!(err instanceof RemoteError)) throw err;
+    console.error(`[treenav-mcp] ${err.message}`);
+    return 1;
+  }
+  console.error(`[treenav-mcp] Proxying stdio to ${url}`);
+  return 0;
+}
+
+// ── search ───────────────────────────────────────────────────────────
+
+/** Parse `type=runbook,tags=auth,tags=jwt` into a facet filter map. */
+export function parseFilters(spec: string): Record<string, string[]> {
+  const filters: Record<string, string[]> = {};
+  for (const pair of spec.split(",")) {
+    const eq = pair.indexOf("=");
+    if (eq <= 0) continue;
+    const key = pair.slice(0, eq).trim();
+    const value = pair.slice(eq + 1).trim();
+    if (!value) continue;
+    (filters[key] ??= []).push(value);
+  }
+  return filters;
+}
+
+/** Config for the commands that read the artifact: `search`, `report`, and completion. */
+function searchConfig(args: Record<string, unknown>): Promise<ServeConfig> {
+  return subcommandConfig(args, {
+    "docs-root": flagString(args, "root"),
+    "code-root": flagString(args, "code"),
+    "index-cache": flagString(args, "index"),
+  });
+}
+
+export async function runSearchCommand(
+  args: CommandArgs,
+  out: (text: string) => void = (text) => console.log(text)
+): Promise<number> {
+  const query = [args.query ?? []].flat().join(" ").trim();
+  if (!query) {
+    console.error("Missing query (see treenav-mcp search --help)");
+    return 2;
+  }
+
+  const limit = parseInt(flagString(args, "limit") || "15");
+  if (Number.isNaN(limit) || limit < 1) {
+    console.error("--limit must be a positive integer");
+    return 2;
+  }
+
+  const caseMode = flagString(args, "case") || "insensitive";
+  if (!CASE_MODES.includes(caseMode as CaseMode)) {
+    console.error(`--case must be one of ${CASE_MODES.join(", ")}`);
+    return 2;
+  }
+
+  const settings = await searchConfig(args);
+  const docs_root = settings.docs_root;
+  const indexPath = resolve(settings.index_cache || DEFAULT_INDEX_CACHE_PATH);
+
+  const documents = await loadIndexCache(indexPath, toIndexConfig(settings));
+  if (!documents) {
+    console.error(
+      `No usable index at ${indexPath} for this configuration. Run \`treenav-mcp index\` first.`
+    );
+    return 1;
+  }
+
+  const store = new DocumentStore();
+  store.load(documents);
+
+  // Same glossary lookup as the server, so query expansion matches
+  const glossaryPath = settings.glossary_path || join(docs_root, "glossary.json");
+  if (existsSync(glossaryPath)) {
+    try {
+      store.loadGlossary(await Bun.file(glossaryPath).json());
+    } catch (err: any) {
+      console.error(`Warning: Failed to load glossary from ${glossaryPath}: ${err.message}`);
+    }
+  }
+  store.loadSynonyms(parseSynonymGroups(settings.synonyms));
+  store.setPathBoosts([...parsePathBoosts(settings.path_boosts), ...vendorBoosts(settings.vendor_policy)]);
+  store.setRanking({ reference_weight: settings.reference_weight });
+  store.setByteOffsets(settings.byte_offsets);
+  store.setGeneratedPolicy(settings.generated_policy);
+  store.setCommentMatching({ matching: settings.comment_matching, languages: settings.comment_languages });
+  if (settings.recency_weight > 0) {
+    applyRecencyBoost(store, toIndexConfig(settings), settings.recency_weight, settings.recency_half_life_days);
+  }
+  // The server's RANKING_WASM hook, so an experiment can be checked from the shell
+  if (settings.ranking_wasm) {
+    try {
+      store.setRescorer(await loadRankingWasm(settings.ranking_wasm, { log: (msg) => console.error(msg) }));
+    } catch (err: any) {
+      console.error(`Warning: RANKING_WASM not loaded, using built-in ranking: ${err.message}`);
+    }
+  }
+
+  const filterSpec = flagString(args, "filter");
+  const results = store.searchDocuments(query, {
+    limit,
+    doc_id: flagString(args, "doc-id"),
+    filters: filterSpec ? parseFilters(filterSpec) : undefined,
+    case: caseMode as CaseMode,
+    word_boundaries: Boolean(args["word-boundaries"]),
+  });
+
+  if (args.json) {
+    out(JSON.stringify({ query, count: results.length, results }, null, 2));
+  } else {
+    out(formatSearchResults(results, store, query));
+  }
+  return 0;
+}
+
+// ── report ───────────────────────────────────────────────────────────
+
+export async function runReportCommand(
+  args: CommandArgs,
+  out: (text: string) => void = (text) => process.stdout.write(text)
+): Promise<number> {
+  const top = parseInt(flagString(args, "top") || String(DEFAULT_REPORT_TOP));
+  if (Number.isNaN(top) || top < 1) {
+    console.error("--top must be a positive integer");
+    return 2;
+  }
+
+  const settings = await searchConfig(args);
+  const config = toIndexConfig(settings);
+  const indexPath = resolve(settings.index_cache || DEFAULT_INDEX_CACHE_PATH);
+  const documents = await loadIndexCache(indexPath, config);
+  if (!documents) {
+    console.error(`No usable index at ${indexPath} for this configuration. Run \`treenav-mcp index\` first.`);
+    return 1;
+  }
+
+  const store = new DocumentStore();
+  store.load(documents);
+  const goModules = config.code_collections?.length ? new GoModuleIndex(config) : undefined;
+  if (settings.coverage_profile) {
+    try {
+      await loadCoverProfile(store, config, settings.coverage_profile, goModules);
+    } catch (err: any) {
+      console.error(`Warning: Failed to load coverage profile ${settings.coverage_profile}: ${err.message}`);
+    }
+  }
+  const report = buildReport(store, {
+    top,
+    entrypoints: await new EntrypointIndex(config).scan(store),
+    modules: goModules ? (await goModules.graph()).modules : [],
+  });
+
+  const project = basename(resolve(config.code_collections?.[0]?.root ?? settings.docs_root));
+  const text = args.json ? JSON.stringify(report, null, 2) + "\n" : formatReport(report, `${project} overview`);
+  const outPath = flagString(args, "out");
+  if (!outPath) {
+    out(text);
+    return 0;
+  }
+  await writeFile(resolve(outPath), text);
+  console.error(`Wrote the report to ${resolve(outPath)}`);
+  return 0;
+}
+
+// ── completion ───────────────────────────────────────────────────────
+
+export function runCompletionCommand(
+  args: CommandArgs,
+  out: (text: string) => void = (text) => process.stdout.write(text)
+): number {
+  const script = completionScript(flagString(args, "shell") ?? "");
+  if (!script) {
+    console.error(`Usage: treenav-mcp completion <${SHELLS.join("|")}>`);
+    return 2;
+  }
+  out(script);
+  return 0;
+}
+
+/**
+ * Hidden `__complete` backend for the completion scripts. Never fails
+ * loudly: a broken config or missing index just yields no candidates.
+ */
+export async function runCompleteCommand(
+  words: string[],
+  out: (text: string) => void = (text) => console.log(text)
+): Promise<number> {
+  const candidates = await completeWords(words, async (command, flags) => {
+    if (command !== "search") return null;
+    try {
+      const settings = await searchConfig(flags);
+      return await loadIndexCache(resolve(settings.index_cache || DEFAULT_INDEX_CACHE_PATH), toIndexConfig(settings));
+    } catch {
+      return null;
+    }
+  });
+  if (candidates.length > 0) out(candidates.join("\n"));
+  return 0;
+}
+
+// ── Dispatch ─────────────────────────────────────────────────────────
+
+/** Run one command; the process exits with its code, except while serving. */
+async function runCommand(command: string, args: CommandArgs): Promise<void> {
+  switch (command) {
+    case "index":
+      process.exit(await runIndexCommand(args));
+    case "import":
+      process.exit(await runImportCommand(args));
+    case "search":
+      process.exit(await runSearchCommand(args));
+    case "report":
+      process.exit(await runReportCommand(args));
+    case "proxy": {
+      const code = await runProxyCommand(args);
+      if (code !== 0) process.exit(code);
+      return;
+    }
+    case "completion":
+      process.exit(runCompletionCommand(args));
+    case "serve":
+      // server.ts parses its own flags
+      await import("./server");
+  }
+}
+
+export async function main(argv: string[]): Promise<void> {
+  try {
+    // Hidden, and never validated: the words are whatever is typed so far
+    if (argv[0] === "__complete") process.exit(await runCompleteCommand(argv.slice(1)));
+
+    await program(argv, runCommand)
+      .fail((message, err, cli) => {
+        // yargs reports its own parse errors as a YError; anything else came from a command
+        if (err && err.name !== "YError") throw err;
+        console.error(`${message}\n`);
+        cli.showHelp();
+        process.exit(2);
+      })
+      .parseAsync();
+  } catch (err) {
+    if (!(err instanceof ConfigError)) throw err;
+    console.error(`Config error: ${err.message}`);
+    process.exit(2);
+  }
+}
@@ -23,12 +23,13 @@
  */
 
 import { stat } from "node:fs/promises";
-import { relative, basename, extname } from "node:path";
+import { basename, extname } from "node:path";
 import type {
   TreeNode,
   DocumentMeta,
   IndexedDocument,
   CollectionConfig,
+  IndexRunStats,
 } from "./types";
 import { parseTypeScript, TYPESCRIPT_EXTENSIONS } from "./parsers/typescript";
 import { parsePython, PYTHON_EXTENSIONS } from "./parsers/python";
@@ -36,6 +37,16 @@ import { parseJava, JAVA_EXTENSIONS } from "./parsers/java";
 import { parseGo, GO_EXTENSIONS } from "./parsers/go";
 import { parseRust, RUST_EXTENSIONS } from "./parsers/rust";
 import { parseGeneric, GENERIC_EXTENSIONS } from "./parsers/generic";
+import { readNotebook, NOTEBOOK_EXTENSIONS } from "./parsers/notebook";
+import { countWords } from "./parsers/lines";
+import { detectExtension, isSniffable, sniffExtension } from "./language-detect";
+import { detectGenerated, generatorOrigin } from "./generated";
+import { lineByteOffsets, normalizeLineEndings, readSource, type SourceText } from "./encoding";
+import { checkFileQuota, walkFiles } from "./walk";
+import { activeParseCache } from "./parse-cache";
+import { buildTrigramFilter } from "./trigram-filter";
+import { isIncluded, mayContainIncluded } from "./coverage";
+import { relativePath } from "./paths";
 
 // ── Code symbol intermediate representation ──────────────────────────
 
@@ -74,17 +85,50 @@ export const CODE_EXTENSIONS = new Set([
   ...GO_EXTENSIONS,
   ...RUST_EXTENSIONS,
   ...GENERIC_EXTENSIONS,
+  ...NOTEBOOK_EXTENSIONS,
 ]);
 
 /** Default glob pattern for code files */
-export const CODE_GLOB = "**/*.{ts,tsx,js,jsx,mts,cts,mjs,cjs,py,pyi,go,rs,java,kt,scala,c,cpp,cc,h,hpp,cs,rb,swift,php,lua,sh,bash,zsh}";
+export const CODE_GLOB = "**/*.{ts,tsx,js,jsx,mts,cts,mjs,cjs,py,pyi,bzl,go,rs,java,kt,scala,c,cpp,cc,h,hpp,cs,rb,swift,php,lua,sh,bash,zsh,mk,ipynb}";
 
 /**
  * Check if a file extension is supported for code indexing.
  */
 export function isCodeFile(filePath: string): boolean {
   const ext = extname(filePath).toLowerCase();
-  return CODE_EXTENSIONS.has(ext);
+  return CODE_EXTENSIONS.has(ext) || GRAMMAR_LANGUAGES.has(ext);
+}
+
+/**
+ * Extensions a tree-sitter grammar manifest adds (see grammars.ts), by
+ * language. They have no parser of their own and index through the
+ * generic one; extensions with a parser are never taken over.
+ */
+const GRAMMAR_LANGUAGES = new Map<string, string>();
+
+/** Replace the manifest extensions; later discovery and re-indexing pick them up. */
+export function setGrammarLanguages(languages: Map<string, string>): void {
+  GRAMMAR_LANGUAGES.clear();
+  for (const [ext, language] of languages) {
+    if (!CODE_EXTENSIONS.has(ext)) GRAMMAR_LANGUAGES.set(ext, language);
+  }
+}
+
+/**
+ * Whether discovery considers `relPath` for a code collection: a file
+ * with a supported extension the glob matches, or one with a grammar
+ * manifest extension or without an extension (or a known build file
+ * name) when the glob is the default or matches it. Files without an
+ * extension are kept only if sniffExtension names a language (see
+ * language-detect.ts).
+ */
+export function isCodeCandidate(collection: CollectionConfig, glob: Bun.Glob, relPath: string): boolean {
+  const defaultGlob = !collection.glob_pattern || collection.glob_pattern === CODE_GLOB;
+  if (CODE_EXTENSIONS.has(extname(relPath).toLowerCase())) return glob.match(relPath);
+  // CODE_GLOB cannot list manifest extensions, so the default admits them
+  if (isCodeFile(relPath)) return defaultGlob || glob.match(relPath);
+  if (!isSniffable(relPath)) return false;
+  return defaultGlob || glob.match(relPath);
 }
 
 // ── Language detection ───────────────────────────────────────────────
@@ -92,7 +136,7 @@ export function isCodeFile(filePath: string): boolean {
 const LANGUAGE_MAP: Record<string, string> = {
   ".ts": "typescript", ".tsx": "typescript", ".mts": "typescript", ".cts": "typescript",
   ".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
-  ".py": "python", ".pyi": "python",
+  ".py": "python", ".pyi": "python", ".bzl": "starlark",
   ".go": "go",
   ".rs": "rust",
   ".java": "java", ".kt": "kotlin", ".scala": "scala",
@@ -104,11 +148,17 @@ const LANGUAGE_MAP: Record<string, string> = {
   ".lua": "lua",
   ".r": "r", ".R": "r",
   ".sh": "shell", ".bash": "shell", ".zsh": "shell",
+  ".mk": "make",
 };
 
-function detectLanguage(filePath: string): string {
-  const ext = extname(filePath).toLowerCase();
-  return LANGUAGE_MAP[ext] || "unknown";
+/**
+ * The extension that picks parser and language: the file's own, or for
+ * scripts and build files one detected from the name or content.
+ */
+function sourceExtension(relPath: string, source: string): string {
+  const ext = extname(relPath).toLowerCase();
+  if (isCodeFile(relPath) || !isSniffable(relPath)) return ext;
+  return detectExtension(relPath, source) ?? ext;
 }
 
 // ── Symbol → TreeNode mapping ────────────────────────────────────────
@@ -146,7 +196,7 @@ function symbolToTreeNode(symbol: CodeSymbol): TreeNode {
     : `${symbol.kind} ${symbol.name}`;
 
   const content = symbol.content;
-  const wordCount = content.split(/\s+/).filter(Boolean).length;
+  const wordCount = countWords(content);
 
   return {
     node_id: symbol.id,
@@ -165,11 +215,21 @@ function symbolToTreeNode(symbol: CodeSymbol): TreeNode {
 // ── Parse source file ────────────────────────────────────────────────
 
 /**
- * Parse a source file into CodeSymbols using the appropriate language parser.
+ * Parse a source file into CodeSymbols using the appropriate language
+ * parser, or read them back from PARSE_CACHE when this content was
+ * parsed before.
  */
-function parseSourceFile(source: string, docId: string, filePath: string): CodeSymbol[] {
-  const ext = extname(filePath).toLowerCase();
+function parseSourceFile(source: string, docId: string, ext: string): CodeSymbol[] {
+  const cache = activeParseCache();
+  if (!cache) return runParser(source, docId, ext);
+  const cached = cache.get(ext, source, docId);
+  if (cached) return cached;
+  const symbols = runParser(source, docId, ext);
+  cache.set(ext, source, docId, symbols);
+  return symbols;
+}
 
+function runParser(source: string, docId: string, ext: string): CodeSymbol[] {
   if (TYPESCRIPT_EXTENSIONS.has(ext)) {
     return parseTypeScript(source, docId);
   }
@@ -193,8 +253,26 @@ function parseSourceFile(source: string, docId: string, filePath: string): CodeS
   return parseGeneric(source, docId, ext);
 }
 
+/**
+ * Symbols of source text that is not (or not yet) an indexed file, e.g.
+ * an older version from git. The extension of `filePath`, or for
+ * extensionless files its content, picks the parser.
+ */
+export function parseCodeSymbols(source: string, filePath: string): CodeSymbol[] {
+  return parseSourceFile(source, "source", sourceExtension(filePath, source));
+}
+
 // ── Index a single code file ─────────────────────────────────────────
 
+/**
+ * Build the doc_id for a code file: collection:path segments (replacing / with :,
+ * extension . with _). Extension is preserved (as _ext suffix) so .h and .cc
+ * files get distinct IDs.
+ */
+export function codeDocId(collectionName: string, relPath: string): string {
+  return `${collectionName}:${relPath.replace(/[/\\]/g, ":").replace(/\.(\w+)$/, "_$1")}`;
+}
+
 /**
  * Index a single source code file into an IndexedDocument.
  *
@@ -207,33 +285,54 @@ export async function indexCodeFile(
   docsRoot: string,
   collectionName: string = "code",
 ): Promise<IndexedDocument> {
-  const raw = await Bun.file(filePath).text();
-  const relPath = relative(docsRoot, filePath);
-  const ext = extname(filePath).toLowerCase();
-  const language = detectLanguage(filePath);
+  const source = await readSource(filePath);
+  const fstat = await stat(filePath);
+  return indexCodeContent(source.text, relativePath(docsRoot, filePath), collectionName, fstat.mtime.toISOString(), source);
+}
 
-  // Build doc_id: collection:path segments (replacing / with :, extension . with _)
-  // Extension is preserved (as _ext suffix) so .h and .cc files get distinct IDs.
-  const doc_id = `${collectionName}:${relPath.replace(/[/\\]/g, ":").replace(/\.(\w+)$/, "_$1")}`;
+/**
+ * Index source text that need not be on disk (e.g. a blob at a git
+ * ref). `relPath` is relative to the collection root and picks the
+ * language by extension, or by name and content when it has none.
+ * `decoded` says how a file was transcoded (readSource), for the offset
+ * map; without it the text is taken as UTF-8.
+ */
+export function indexCodeContent(
+  raw: string,
+  relPath: string,
+  collectionName: string,
+  lastModified: string,
+  decoded?: Pick<SourceText, "encoding" | "bom">
+): IndexedDocument {
+  // "\n" line breaks and NFC, so CRLF checkouts number lines the same and
+  // decomposed identifiers and comments match composed queries; the
+  // content hash stays on the text as read
+  const source = normalizeLineEndings(raw).normalize("NFC");
+  const doc_id = codeDocId(collectionName, relPath);
+  if (NOTEBOOK_EXTENSIONS.has(extname(relPath).toLowerCase())) {
+    return indexNotebookContent(raw, source, doc_id, relPath, collectionName, lastModified);
+  }
+  const ext = sourceExtension(relPath, source);
+  const language = LANGUAGE_MAP[ext] || GRAMMAR_LANGUAGES.get(ext) || "unknown";
 
   // Parse into symbols
-  const symbols = parseSourceFile(raw, doc_id, filePath);
+  const symbols = parseSourceFile(source, doc_id, ext);
 
   // Convert to TreeNodes
   const tree: TreeNode[] = symbols.map(symbolToTreeNode);
 
   // If no symbols found, create a root node with the full file content
   if (tree.length === 0) {
-    const lines = raw.split("\n");
+    const lines = source.split("\n");
     tree.push({
       node_id: `${doc_id}:n1`,
-      title: basename(filePath),
+      title: basename(relPath),
       level: 1,
       parent_id: null,
       children: [],
-      content: raw,
-      summary: raw.slice(0, 200),
-      word_count: raw.split(/\s+/).filter(Boolean).length,
+      content: source,
+      summary: source.slice(0, 200),
+      word_count: countWords(source),
       line_start: 1,
       line_end: lines.length,
     });
@@ -259,11 +358,9 @@ export async function indexCodeFile(
 
   const root_nodes = tree.filter((n) => n.parent_id === null).map((n) => n.node_id);
 
-  const title = basename(filePath);
+  const title = basename(relPath);
   const description = buildCodeDescription(symbols, language);
 
-  const fstat = await stat(filePath);
-
   const meta: DocumentMeta = {
     doc_id,
     file_path: relPath,
@@ -272,38 +369,166 @@ export async function indexCodeFile(
     word_count: tree.reduce((sum, n) => sum + n.word_count, 0),
     heading_count: tree.length,
     max_depth: Math.max(...tree.map((n) => n.level), 0),
-    last_modified: fstat.mtime.toISOString(),
+    last_modified: lastModified,
     tags: exportedSymbols.slice(0, 20), // Top exported symbols as tags for discovery
     content_hash,
     collection: collectionName,
     facets,
     references: [], // Code files don't have markdown links
   };
+  const { encoding, bom } = decoded ?? { encoding: "utf-8", bom: 0 };
+  if (encoding !== "utf-8") meta.encoding = encoding;
+  // Offsets of the text as read: NFC would shift them
+  meta.line_offsets = lineByteOffsets({ encoding, bom, text: raw });
+  // Of the text as read, which is what regex_search reads back
+  meta.trigram_filter = buildTrigramFilter(raw);
+  const generated = detectGenerated(relPath, source);
+  if (generated) meta.generated = generated;
+  const origin = generated === "generated" ? generatorOrigin(source) : null;
+  if (origin) meta.generated_from = origin;
 
   return { meta, tree, root_nodes };
 }
 
+/**
+ * Index a Jupyter notebook: one node per code cell, titled by its
+ * position and the markdown heading above it, with the symbols the
+ * kernel language's parser finds in the cell as children. Node lines
+ * count from the start of their cell, and every node records the cell.
+ * Notebooks get content_type "notebook": their files are JSON, so the
+ * code tools that re-read source from disk leave them alone.
+ */
+function indexNotebookContent(
+  raw: string,
+  source: string,
+  doc_id: string,
+  relPath: string,
+  collectionName: string,
+  lastModified: string
+): IndexedDocument {
+  const notebook = readNotebook(source);
+  const language = notebook?.language ?? "unknown";
+  const cellExt = Object.keys(LANGUAGE_MAP).find((ext) => LANGUAGE_MAP[ext] === language);
+
+  const tree: TreeNode[] = [];
+  const symbols: CodeSymbol[] = [];
+  for (const cell of notebook?.cells ?? []) {
+    const cellId = `${doc_id}:cell${cell.index}`;
+    const cellSymbols = cellExt ? parseSourceFile(cell.source, cellId, cellExt) : [];
+    symbols.push(...cellSymbols);
+    const children = cellSymbols.map((s) => ({
+      ...symbolToTreeNode(s),
+      level: symbolLevel(s) + 1,
+      parent_id: s.parent_id ?? cellId,
+      cell: cell.index,
+    }));
+    tree.push({
+      node_id: cellId,
+      title: cell.heading ? `cell ${cell.index}: ${cell.heading}` : `cell ${cell.index}`,
+      level: 1,
+      parent_id: null,
+      children: children.filter((n) => n.parent_id === cellId).map((n) => n.node_id),
+      content: cell.source,
+      summary: cell.source.trim().slice(0, 200),
+      word_count: countWords(cell.source),
+      line_start: 1,
+      line_end: cell.source.split("\n").length,
+      cell: cell.index,
+    }, ...children);
+  }
+  if (tree.length === 0) {
+    tree.push({
+      node_id: `${doc_id}:n1`,
+      title: basename(relPath),
+      level: 1,
+      parent_id: null,
+      children: [],
+      content: "",
+      summary: notebook ? "Notebook without code cells" : "Not a readable notebook",
+      word_count: 0,
+      line_start: 1,
+      line_end: 1,
+    });
+  }
+
+  const symbolKinds = [...new Set(symbols.map((s) => s.kind).filter((k) => k !== "import"))];
+  const facets: Record<string, string[]> = {
+    language: [language],
+    content_type: ["notebook"],
+  };
+  if (symbolKinds.length > 0) {
+    facets["symbol_kind"] = symbolKinds;
+  }
+
+  const cells = notebook?.cells.length ?? 0;
+  const defined = symbols.some((s) => s.parent_id === null && s.kind !== "import")
+    ? `; ${buildCodeDescription(symbols, language)}`
+    : "";
+
+  const meta: DocumentMeta = {
+    doc_id,
+    file_path: relPath,
+    title: basename(relPath),
+    description: `${language} notebook, ${cells} code cell${cells === 1 ? "" : "s"}${defined}`.slice(0, 200),
+    word_count: tree.reduce((sum, n) => sum + n.word_count, 0),
+    heading_count: tree.length,
+    max_depth: Math.max(...tree.map((n) => n.level), 0),
+    last_modified: lastModified,
+    tags: symbols.filter((s) => s.exported && s.parent_id === null && s.kind !== "import").map((s) => s.name).slice(0, 20),
+    content_hash: Bun.hash(raw).toString(16),
+    collection: collectionName,
+    facets,
+    references: [],
+  };
+
+  return { meta, tree, root_nodes: tree.filter((n) => n.parent_id === null).map((n) => n.node_id) };
+}
+
 // ── Scan directory for code files ────────────────────────────────────
 
+/**
+ * List the absolute paths of all indexable code files in a collection,
+ * or with `under`, in one root-relative directory or file of it.
+ */
+export async function listCodeFiles(
+  collection: CollectionConfig,
+  under?: string,
+  limit?: number,
+): Promise<string[]> {
+  const glob = new Bun.Glob(collection.glob_pattern || CODE_GLOB);
+  // Only include files the code indexer can handle
+  const files = await walkFiles(collection.root, {
+    symlinks: collection.symlinks,
+    under,
+    limit,
+    match: (relPath) => isCodeCandidate(collection, glob, relPath) && isIncluded(collection, relPath),
+    enter: (relDir) => mayContainIncluded(collection, relDir),
+  });
+  // Extensionless files stay only when their name or content names a
+  // language; one at a time, as a tree can hold many of them
+  const kept: string[] = [];
+  for (const file of files) {
+    if (isCodeFile(file) || (await sniffExtension(file))) kept.push(file);
+  }
+  // The walk stopped at the limit, but the sniff dropped some of what it
+  // found: the files past the cut may still fit
+  if (limit !== undefined && files.length > limit && kept.length <= limit) return listCodeFiles(collection, under);
+  return kept;
+}
+
 /**
  * Index all code files in a collection.
  * Parallel to indexCollection() in indexer.ts.
  */
 export async function indexCodeCollection(
   collection: CollectionConfig,
+  stats?: IndexRunStats,
+  maxFiles?: number,
 ): Promise<IndexedDocument[]> {
-  const { root, name, glob_pattern } = collection;
-  const pattern = glob_pattern || CODE_GLOB;
-
-  const glob = new Bun.Glob(pattern);
-  const files: string[] = [];
-
-  for await (const entry of glob.scan({ cwd: root, absolute: true })) {
-    // Only include files the code indexer can handle
-    if (isCodeFile(entry)) {
-      files.push(entry);
-    }
-  }
+  const { root, name } = collection;
+  const files = await listCodeFiles(collection, undefined, maxFiles);
+  checkFileQuota(name, files, maxFiles);
+  if (stats) stats.files += files.length;
 
   if (files.length === 0) return [];
 
@@ -318,6 +543,7 @@ export async function indexCodeCollection(
       batch.map((f) =>
         indexCodeFile(f, root, name).catch((err) => {
           console.warn(`Failed to index code file ${f}: ${err.message}`);
+          stats?.failed.push({ file: f, error: err.message });
           return null;
         }),
       ),
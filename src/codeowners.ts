@@ -0,0 +1,203 @@
+/**
+ * CODEOWNERS matching — the owners_of tool
+ *
+ * A review-routing agent needs "who owns services/payments/api.go?"
+ * answered with GitHub's rules, not an approximation of them:
+ *
+ *   - The file is the first of .github/CODEOWNERS, CODEOWNERS, and
+ *     docs/CODEOWNERS at the repository root: the nearest directory at
+ *     or above the collection root that holds .git, else the collection
+ *     root itself. A CODEOWNERS below the repository root, in a vendored
+ *     tree or a docs directory, is never read.
+ *   - Each line is a pattern followed by zero or more owners (@user,
+ *     @org/team, or an email). The LAST matching line wins; a match
+ *     with no owners leaves the file unowned.
+ *   - Patterns follow gitignore: a leading or middle "/" anchors to the
+ *     root, otherwise the pattern matches at any depth; a trailing "/"
+ *     or a directory match covers everything beneath, except that a
+ *     wildcard last segment stays at one level (`docs/*` owns
+ *     docs/a.md, not docs/guides/b.md); `*` stays within a segment and
+ *     `**` spans segments. `\#` and `\ ` escape.
+ *
+ * GitHub does not support `!` negation or `[...]` ranges in CODEOWNERS,
+ * so those lines never match, as on GitHub. GitLab section headers
+ * (`[Docs]`, `^[Docs]`) are skipped, and their rules are read as one
+ * list.
+ */
+
+import { readFile, stat } from "node:fs/promises";
+import { dirname, join, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import { relativePath } from "./paths";
+
+export const CODEOWNERS_LOCATIONS = [".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"];
+
+export interface CodeownersRule {
+  pattern: string;
+  owners: string[];
+  /** 1-based line in the CODEOWNERS file */
+  line: number;
+  regex: RegExp | null;
+}
+
+/** Regex for a CODEOWNERS pattern, or null for patterns GitHub rejects. */
+export function patternRegex(pattern: string): RegExp | null {
+  if (pattern.startsWith("!") || /(?<!\\)\[/.test(pattern)) return null;
+  const anchored = pattern.replace(/\/$/, "").includes("/");
+  const directoryOnly = pattern.endsWith("/");
+  const body = pattern.replace(/^\//, "").replace(/\/$/, "");
+  let source = "";
+  for (let i = 0; i < body.length; i++) {
+    const c = body[i];
+    if (c === "*" && body[i + 1] === "*") {
+      const slashBefore = i === 0 || body[i - 1] === "/";
+      const slashAfter = i + 2 === body.length || body[i + 2] === "/";
+      if (slashBefore && slashAfter) {
+        // "**/" is zero or more directories; a final "**" is everything
+        source += i + 2 === body.length ? ".*" : "(?:.*/)?";
+        i += 2;
+        continue;
+      }
+      source += "[^/]*";
+      i++;
+    } else if (c === "*") {
+      source += "[^/]*";
+    } else if (c === "?") {
+      source += "[^/]";
+    } else if (c === "\\" && i + 1 < body.length) {
+      source += body[++i].replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+    } else {
+      source += c.replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+    }
+  }
+  // A match of a whole directory covers its contents, but GitHub keeps
+  // a wildcard last segment (docs/*) to one level
+  const last = body.slice(body.lastIndexOf("/") + 1);
+  const within = directoryOnly ? "/.*" : last.includes("*") && last !== "**" ? "" : "(?:/.*)?";
+  return new RegExp(`^${anchored ? "" : "(?:.*/)?"}${source}${within}$`);
+}
+
+/** Rules of a CODEOWNERS file, in file order. */
+export function parseCodeowners(text: string): CodeownersRule[] {
+  const rules: CodeownersRule[] = [];
+  const lines = text.split("\n");
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i].trim();
+    if (!line || line.startsWith("#") || /^\^?\[[^\]]*\]/.test(line)) continue;
+    // Split on unescaped whitespace; a trailing # comment ends the owners
+    const fields = line.match(/(?:\\.|[^\s\\])+/g) ?? [];
+    const pattern = fields[0];
+    const owners: string[] = [];
+    for (const field of fields.slice(1)) {
+      if (field.startsWith("#")) break;
+      owners.push(field);
+    }
+    rules.push({ pattern, owners, line: i + 1, regex: patternRegex(pattern) });
+  }
+  return rules;
+}
+
+/** The rule that decides `path` (relative to the repository root), or null. */
+export function matchCodeowners(rules: CodeownersRule[], path: string): CodeownersRule | null {
+  for (let i = rules.length - 1; i >= 0; i--) {
+    if (rules[i].regex?.test(path)) return rules[i];
+  }
+  return null;
+}
+
+export interface Ownership {
+  /** Owners of the file; empty when unowned */
+  owners: string[];
+  /** The CODEOWNERS file, relative to the repository root */
+  codeowners: string;
+  /** The file, relative to the repository root */
+  repo_path: string;
+  rule?: { pattern: string; line: number };
+}
+
+/** One owners_of answer: a target resolved to a file, or not. */
+export interface OwnersEntry {
+  target: string;
+  status: "owned" | "unowned" | "no_codeowners" | "not_found";
+  collection?: string;
+  file_path?: string;
+  doc_id?: string;
+  /** Node title of the symbol the target named */
+  symbol?: string;
+  node_id?: string;
+  owners: string[];
+  codeowners?: string;
+  rule?: { pattern: string; line: number };
+}
+
+interface OwnersFile {
+  repo: string;
+  codeowners: string;
+  mtime: number;
+  rules: CodeownersRule[];
+}
+
+/** CODEOWNERS lookups for files in the collections of a config. */
+export class CodeownersIndex {
+  private readonly roots: Map<string, string>;
+  private files = new Map<string, OwnersFile>();
+
+  constructor(config: IndexConfig) {
+    this.roots = new Map([...config.collections, ...(config.code_collections ?? [])].map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /**
+   * Owners of `filePath` in `collection`. Returns null when no
+   * CODEOWNERS file governs the collection.
+   */
+  async ownersOf(collection: string, filePath: string): Promise<Ownership | null> {
+    const root = this.roots.get(collection);
+    if (!root) return null;
+    const file = await this.load(root);
+    if (!file) return null;
+    const repo_path = relativePath(file.repo, join(root, filePath));
+    const rule = matchCodeowners(file.rules, repo_path);
+    return {
+      owners: rule?.owners ?? [],
+      codeowners: file.codeowners,
+      repo_path,
+      ...(rule ? { rule: { pattern: rule.pattern, line: rule.line } } : {}),
+    };
+  }
+
+  /** Drop the parsed CODEOWNERS files; returns how many were kept. */
+  clear(): number {
+    const entries = this.files.size;
+    this.files.clear();
+    return entries;
+  }
+
+  /** The CODEOWNERS file for a collection root, re-read when it changes. */
+  private async load(root: string): Promise<OwnersFile | null> {
+    const cached = this.files.get(root);
+    if (cached) {
+      const mtime = await stat(join(cached.repo, cached.codeowners)).then((s) => s.mtimeMs, () => -1);
+      if (mtime === cached.mtime) return cached;
+      this.files.delete(root);
+    }
+
+    const repo = await repositoryRoot(root);
+    for (const location of CODEOWNERS_LOCATIONS) {
+      const path = join(repo, location);
+      const info = await stat(path).catch(() => null);
+      if (!info?.isFile()) continue;
+      const file = { repo, codeowners: location, mtime: info.mtimeMs, rules: parseCodeowners(await readFile(path, "utf-8")) };
+      this.files.set(root, file);
+      return file;
+    }
+    return null;
+  }
+}
+
+/** The nearest directory at or above `root` holding .git; `root` when there is none. */
+async function repositoryRoot(root: string): Promise<string> {
+  for (let dir = root; ; dir = dirname(dir)) {
+    if (await stat(join(dir, ".git")).then(() => true, () => false)) return dir;
+    if (dirname(dir) === dir) return root;
+  }
+}
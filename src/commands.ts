@@ -0,0 +1,206 @@
+/**
+ * Command table for the `treenav-mcp` CLI
+ *
+ * Each subcommand declares its positional argument and flags once,
+ * here. program() turns the table into a yargs program — one command
+ * per entry, its flags as options, and the generated --help — and
+ * shell completion (completion.ts) reads the same table, so adding a
+ * flag is a one-line change and the completions can never drift from
+ * what the command accepts.
+ *
+ * `serve` flags come straight from CONFIG_OPTIONS.
+ */
+
+import yargs, { type Arguments, type Argv, type Options } from "yargs";
+import { CONFIG_OPTIONS, PARSER_CONFIGURATION, envName, flagName } from "./config";
+import { DEFAULT_INDEX_CACHE_PATH } from "./index-cache";
+import { DEFAULT_SNAPSHOT_PATH } from "./snapshot";
+import { DEFAULT_REPORT_TOP } from "./report";
+
+/** What to offer when completing a value. */
+export type CompletionKind = "file" | "dir" | "doc_id" | "filter" | "shell" | "case";
+
+export interface FlagSpec {
+  name: string;
+  /** Value placeholder for usage text; omit for boolean switches */
+  value?: string;
+  description: string;
+  complete?: CompletionKind;
+}
+
+export interface CommandSpec {
+  name: string;
+  /** Positional arguments in yargs syntax, e.g. `[docs_root]` or `<query..>` */
+  args: string;
+  summary: string;
+  positional?: CompletionKind;
+  flags: FlagSpec[];
+}
+
+/** Default tolerated share of files that fail to parse during `index`. */
+export const DEFAULT_MAX_FAILURE_RATE = 0.05;
+
+export const SHELLS = ["bash", "zsh", "fish"];
+
+/** Every command that resolves the shared configuration takes the config file. */
+const CONFIG_FLAG: FlagSpec = {
+  name: "config",
+  value: "<path>",
+  description: "Config file (TREENAV_CONFIG, default ./treenav.config.json)",
+  complete: "file",
+};
+
+export const COMMANDS: CommandSpec[] = [
+  {
+    name: "serve",
+    args: "",
+    summary: "Start the MCP server on stdio (default)",
+    flags: [
+      CONFIG_FLAG,
+      { name: "print-config", description: "Print the effective configuration and exit" },
+      ...CONFIG_OPTIONS.map((o) => ({
+        name: flagName(o.key),
+        value: o.type === "boolean" ? undefined : "<value>",
+        description: `${o.description} (${envName(o.key)})`,
+        complete: o.complete,
+      })),
+    ],
+  },
+  {
+    name: "index",
+    args: "[docs_root]",
+    summary: "Build and persist the index, then exit",
+    positional: "dir",
+    flags: [
+      { name: "code", value: "<root>", description: "Also index source code under <root> (CODE_ROOT)", complete: "dir" },
+      { name: "out", value: "<path>", description: `Artifact path (INDEX_CACHE, default ${DEFAULT_INDEX_CACHE_PATH})`, complete: "file" },
+      {
+        name: "max-failure-rate",
+        value: "<n>",
+        description: `Exit 1 if more than this share of files fail to parse (0-1, default ${DEFAULT_MAX_FAILURE_RATE})`,
+      },
+      { name: "verify", description: "Check the artifact against the working tree instead of rebuilding; exit 1 on differences" },
+      { name: "repair", description: "With --verify, patch the artifact to match the working tree" },
+      { name: "export", value: "<path>", description: `Also write a portable snapshot (e.g. ${DEFAULT_SNAPSHOT_PATH})`, complete: "file" },
+      CONFIG_FLAG,
+    ],
+  },
+  {
+    name: "import",
+    args: "<snapshot>",
+    summary: "Install an exported snapshot as the local index",
+    positional: "file",
+    flags: [
+      { name: "root", value: "<path>", description: "Docs root to import for (DOCS_ROOT)", complete: "dir" },
+      { name: "code", value: "<root>", description: "Code root to import for (CODE_ROOT)", complete: "dir" },
+      { name: "out", value: "<path>", description: `Artifact path (INDEX_CACHE, default ${DEFAULT_INDEX_CACHE_PATH})`, complete: "file" },
+      { name: "force", description: "Import even if the snapshot was built at another commit" },
+      CONFIG_FLAG,
+    ],
+  },
+  {
+    name: "search",
+    args: "<query..>",
+    summary: "Search a persisted index",
+    flags: [
+      { name: "json", description: "Print ranked results as JSON" },
+      { name: "limit", value: "<n>", description: "Max results (default 15)" },
+      { name: "doc-id", value: "<id>", description: "Limit search to one document", complete: "doc_id" },
+      { name: "case", value: "<mode>", description: "Case matching: insensitive (default), sensitive, smart", complete: "case" },
+      { name: "word-boundaries", description: "Match query words only as whole words" },
+      { name: "filter", value: "<k=v[,k=v]>", description: "Facet filters, e.g. type=runbook,tags=auth", complete: "filter" },
+      { name: "index", value: "<path>", description: `Artifact to query (INDEX_CACHE, default ${DEFAULT_INDEX_CACHE_PATH})`, complete: "file" },
+      { name: "root", value: "<path>", description: "Docs root the artifact was built for (DOCS_ROOT)", complete: "dir" },
+      { name: "code", value: "<root>", description: "Code root the artifact was built for (CODE_ROOT)", complete: "dir" },
+      CONFIG_FLAG,
+    ],
+  },
+  {
+    name: "report",
+    args: "",
+    summary: "Print a Markdown overview of the indexed repository",
+    flags: [
+      { name: "out", value: "<path>", description: "Write the report to <path> instead of stdout", complete: "file" },
+      { name: "json", description: "Print the report's data as JSON" },
+      { name: "top", value: "<n>", description: `Rows per table and entry points per kind (default ${DEFAULT_REPORT_TOP})` },
+      { name: "index", value: "<path>", description: `Artifact to read (INDEX_CACHE, default ${DEFAULT_INDEX_CACHE_PATH})`, complete: "file" },
+      { name: "root", value: "<path>", description: "Docs root the artifact was built for (DOCS_ROOT)", complete: "dir" },
+      { name: "code", value: "<root>", description: "Code root the artifact was built for (CODE_ROOT)", complete: "dir" },
+      CONFIG_FLAG,
+    ],
+  },
+  {
+    name: "proxy",
+    args: "<url>",
+    summary: "Serve a remote serve:http index on stdio",
+    flags: [{ name: "token", value: "<token>", description: "Bearer token the remote requires (HTTP_TOKEN)" }, CONFIG_FLAG],
+  },
+  {
+    name: "completion",
+    args: "<shell>",
+    summary: "Print a shell completion script",
+    positional: "shell",
+    flags: [],
+  },
+  {
+    name: "help",
+    args: "",
+    summary: "Show this help",
+    flags: [],
+  },
+];
+
+export function findCommand(name: string): CommandSpec | undefined {
+  return COMMANDS.find((c) => c.name === name);
+}
+
+/** A command's flags as yargs options. */
+function optionsOf(command: CommandSpec): Record<string, Options> {
+  return Object.fromEntries(
+    command.flags.map((f) => [
+      f.name,
+      f.value
+        ? { type: "string", describe: f.description, requiresArg: true }
+        : { type: "boolean", describe: f.description },
+    ])
+  );
+}
+
+/** A parsed command line: positionals under their `args` names, flags under their kebab-case names. */
+export type CommandArgs = Arguments;
+
+/** Runs a command with its parsed arguments. */
+export type CommandRunner = (command: string, args: CommandArgs) => Promise<void>;
+
+/**
+ * The `treenav-mcp` program for `argv`. yargs checks each command's
+ * positionals and flag values, answers --help, and rejects unknown
+ * commands and flags; `run` then gets the parsed arguments. `serve`
+ * ignores them: server.ts reads its own flags.
+ */
+export function program(argv: string[], run: CommandRunner): Argv {
+  let cli = yargs(argv)
+    .scriptName("treenav-mcp")
+    .usage("Usage: $0 <command> [options]")
+    .epilog("Serve options are also env vars and treenav.config.json keys; precedence: flag > env > config file > default.")
+    .parserConfiguration(PARSER_CONFIGURATION)
+    .strict()
+    .help()
+    .alias("help", "h")
+    .version(false)
+    .wrap(null);
+  for (const command of COMMANDS) {
+    if (command.name === "help") {
+      cli = cli.command("help", command.summary, {}, () => cli.showHelp("log"));
+      continue;
+    }
+    const serve = command.name === "serve";
+    cli = cli.command(
+      (serve ? ["serve", "$0"] : [command.name]).map((name) => `${name} ${command.args}`.trim()),
+      command.summary,
+      optionsOf(command),
+      (args: CommandArgs) => run(command.name, args)
+    );
+  }
+  return cli;
+}
@@ -0,0 +1,194 @@
+/**
+ * Comment text in code, and the language it is written in
+ *
+ * Full-text search over code matches comments as readily as the code
+ * around them, so a query for "cache timeout" in a repository whose
+ * older packages are commented in German, or whose vendored library is
+ * documented in Chinese, can rank a page of prose above the code that
+ * implements the thing. The store indexes comment occurrences apart
+ * from code tokens and records each code node's comment language, and
+ * search can then leave comments out, search nothing else, or count
+ * only comments in the languages asked for (COMMENT_MATCHING,
+ * COMMENT_LANGUAGES, and the `comments` and `comment_languages`
+ * arguments of the search tools).
+ *
+ *   commentSegments  a source split into code and comment runs, by the
+ *                    comment syntax of its extension; string literals
+ *                    are skipped, so "//" inside a URL stays code
+ *   detectLanguage   an ISO 639-1 code by script (zh, ja, ko, ru, uk,
+ *                    el, ar, he, th, hi) or, for Latin text, by the
+ *                    common words of en, de, fr, es, pt, it, and nl;
+ *                    undefined when the text is too short or unclear
+ *
+ * Detection is deliberately small and offline: comments are short, and
+ * telling a German paragraph from an English one needs a handful of
+ * function words, not a model. Commented-out code has none of them and
+ * stays undetected.
+ */
+
+import { extname } from "node:path";
+
+/** How full-text search treats comment text in code. */
+export type CommentMatching = "include" | "exclude" | "only";
+
+/** One run of a source, in order; the runs join back to the source. */
+export interface Segment {
+  text: string;
+  comment: boolean;
+}
+
+interface CommentSyntax {
+  line: string[];
+  block: Array<[string, string]>;
+  /** Triple-quoted strings opening a line are docstrings */
+  docstrings: boolean;
+}
+
+const C_LIKE: CommentSyntax = { line: ["//"], block: [["/*", "*/"]], docstrings: false };
+const HASH: CommentSyntax = { line: ["#"], block: [], docstrings: false };
+const PYTHON: CommentSyntax = { line: ["#"], block: [], docstrings: true };
+
+const SYNTAX: Record<string, CommentSyntax> = {
+  ".py": PYTHON,
+  ".pyi": PYTHON,
+  ".bzl": PYTHON,
+  ".ipynb": PYTHON,
+  ".rb": HASH,
+  ".sh": HASH,
+  ".bash": HASH,
+  ".zsh": HASH,
+  ".mk": HASH,
+  ".r": HASH,
+  ".lua": { line: ["--"], block: [["--[[", "]]"]], docstrings: false },
+  ".php": { line: ["//", "#"], block: [["/*", "*/"]], docstrings: false },
+};
+
+/** The comment syntax of `filePath`; C-style for everything else. */
+function syntaxFor(filePath: string): CommentSyntax {
+  return SYNTAX[extname(filePath).toLowerCase()] ?? C_LIKE;
+}
+
+/** End of the string literal opening at `i`, or -1 when none does. */
+function stringEnd(source: string, i: number): number {
+  const c = source[i];
+  if (c !== '"' && c !== "'" && c !== "`") return -1;
+  for (let j = i + 1; j < source.length; j++) {
+    if (source[j] === "\\") j++;
+    else if (source[j] === c) return j + 1;
+    else if (source[j] === "\n" && c !== "`") return -1;
+  }
+  return c === "`" ? source.length : -1;
+}
+
+/** End of the comment opening at `i`, or -1 when none does. */
+function commentEnd(source: string, i: number, syntax: CommentSyntax): number {
+  // Block openers first: Lua's --[[ also starts a line comment
+  for (const [open, close] of syntax.block) {
+    if (!source.startsWith(open, i)) continue;
+    const end = source.indexOf(close, i + open.length);
+    return end === -1 ? source.length : end + close.length;
+  }
+  for (const open of syntax.line) {
+    if (!source.startsWith(open, i)) continue;
+    const nl = source.indexOf("\n", i);
+    return nl === -1 ? source.length : nl;
+  }
+  if (syntax.docstrings && (source.startsWith('"""', i) || source.startsWith("'''", i))) {
+    const lineStart = source.lastIndexOf("\n", i - 1) + 1;
+    if (source.slice(lineStart, i).trim() !== "") return -1;
+    const end = source.indexOf(source.slice(i, i + 3), i + 3);
+    return end === -1 ? source.length : end + 3;
+  }
+  return -1;
+}
+
+/** `source` split into code and comment runs by the comment syntax of `filePath`. */
+export function commentSegments(source: string, filePath: string): Segment[] {
+  const syntax = syntaxFor(filePath);
+  const segments: Segment[] = [];
+  let from = 0;
+  for (let i = 0; i < source.length; ) {
+    const end = commentEnd(source, i, syntax);
+    if (end > i) {
+      if (i > from) segments.push({ text: source.slice(from, i), comment: false });
+      segments.push({ text: source.slice(i, end), comment: true });
+      i = from = end;
+      continue;
+    }
+    const str = stringEnd(source, i);
+    i = str > i ? str : i + 1;
+  }
+  if (from < source.length) segments.push({ text: source.slice(from), comment: false });
+  return segments;
+}
+
+// ── Language detection ───────────────────────────────────────────────
+
+/** Letters needed before a language is named; CJK characters count three */
+const MIN_LETTERS = 12;
+
+/** Common words of a Latin-script language needed, and ahead of the runner-up */
+const MIN_WORDS = 2;
+
+const SCRIPTS: Array<[RegExp, string]> = [
+  [/\p{Script=Hangul}/u, "ko"],
+  [/[\p{Script=Hiragana}\p{Script=Katakana}]/u, "ja"],
+  [/\p{Script=Han}/u, "zh"],
+  [/\p{Script=Cyrillic}/u, "ru"],
+  [/\p{Script=Greek}/u, "el"],
+  [/\p{Script=Arabic}/u, "ar"],
+  [/\p{Script=Hebrew}/u, "he"],
+  [/\p{Script=Thai}/u, "th"],
+  [/\p{Script=Devanagari}/u, "hi"],
+  [/\p{Script=Latin}/u, "latin"],
+];
+
+const CJK = new Set(["ko", "ja", "zh"]);
+
+const COMMON_WORDS: Record<string, Set<string>> = {
+  en: new Set("the and is are of to in for this that with be it not when if or from an on by which returns should".split(" ")),
+  de: new Set("der die das und ist nicht mit für ein eine wenn auf den zu von wird sich auch dem des werden oder".split(" ")),
+  fr: new Set("le la les et est pas une des pour dans que qui du avec sur ce au sont ou être cette".split(" ")),
+  es: new Set("el la los las y es una para que con por del se en como no lo al está son este".split(" ")),
+  pt: new Set("o os as e é um uma para que com não do da em se por dos das ao está são este".split(" ")),
+  it: new Set("il lo gli e è un una per che con non del della di se nel sono questo alla".split(" ")),
+  nl: new Set("de het een en is niet van voor met dat als op te zijn wordt bij deze naar".split(" ")),
+};
+
+/**
+ * The language `text` is written in, as an ISO 639-1 code, or
+ * undefined when it has too few letters or no language stands out.
+ */
+export function detectLanguage(text: string): string | undefined {
+  const counts = new Map<string, number>();
+  let letters = 0;
+  for (const ch of text.normalize("NFC")) {
+    if (!/\p{L}/u.test(ch)) continue;
+    const script = SCRIPTS.find(([pattern]) => pattern.test(ch))?.[1] ?? "other";
+    const weight = CJK.has(script) ? 3 : 1;
+    counts.set(script, (counts.get(script) ?? 0) + weight);
+    letters += weight;
+  }
+  if (letters < MIN_LETTERS) return undefined;
+
+  // Kana anywhere makes Han text Japanese; Hangul is never mixed in
+  if (counts.has("ja") && counts.has("zh")) {
+    counts.set("ja", counts.get("ja")! + counts.get("zh")!);
+    counts.delete("zh");
+  }
+  const [script] = [...counts].sort((a, b) => b[1] - a[1])[0];
+  if (script === "other") return undefined;
+  if (script === "ru") return /[іїєґ]/iu.test(text) ? "uk" : "ru";
+  if (script !== "latin") return script;
+  return latinLanguage(text);
+}
+
+/** The Latin-script language whose common words `text` uses most. */
+function latinLanguage(text: string): string | undefined {
+  const words = text.toLowerCase().normalize("NFC").match(/[\p{L}\p{M}]+/gu) ?? [];
+  const scores = Object.entries(COMMON_WORDS)
+    .map(([lang, common]) => [lang, words.filter((w) => common.has(w)).length] as const)
+    .sort((a, b) => b[1] - a[1]);
+  const [best, runnerUp] = scores;
+  return best[1] >= MIN_WORDS && best[1] > runnerUp[1] ? best[0] : undefined;
+}
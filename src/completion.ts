@@ -0,0 +1,211 @@
+/**
+ * Shell completion for the `treenav-mcp` CLI
+ *
+ * The generated bash/zsh/fish scripts are thin: on every <Tab> they call
+ * the hidden `treenav-mcp __complete <words...>` subcommand with the
+ * words typed so far (the last one being the word under the cursor) and
+ * offer whatever it prints, one candidate per line. All the logic lives
+ * in completeWords(), driven by the command table in commands.ts.
+ *
+ * Values that are filesystem paths print a single FILES or DIRS
+ * sentinel instead, and the script falls back to the shell's own path
+ * completion. Values that name indexed content — `search --doc-id`
+ * and `search --filter` — are completed from the persisted index the
+ * command would query, resolved with the same flags, env, and config
+ * file, so completions match what the search will actually see.
+ */
+
+import { COMMANDS, SHELLS, findCommand } from "./commands";
+import { CASE_MODES } from "./types";
+import type { CompletionKind, CommandSpec } from "./commands";
+import { parseArgs, type ParsedArgs } from "./config";
+import type { IndexedDocument } from "./types";
+
+export const FILES_SENTINEL = "__files__";
+export const DIRS_SENTINEL = "__dirs__";
+
+/** Loads the documents a command would see, given the flags typed so far. */
+export type DocumentLoader = (
+  command: string,
+  flags: ParsedArgs["flags"]
+) => Promise<IndexedDocument[] | null>;
+
+/**
+ * Candidates for the last word in `words` (argv after the program
+ * name). Already filtered by the current prefix.
+ */
+export async function completeWords(words: string[], loadDocuments: DocumentLoader): Promise<string[]> {
+  const current = words.length > 0 ? words[words.length - 1] : "";
+  const before = words.slice(0, -1);
+
+  // First word: a subcommand, or a serve flag
+  if (before.length === 0 && !current.startsWith("-")) {
+    return matching(COMMANDS.map((c) => c.name), current);
+  }
+
+  const command = findCommand(before[0] ?? "") ?? findCommand("serve")!;
+  const rest = before[0] === command.name ? before.slice(1) : before;
+
+  // `--flag=value`
+  if (current.startsWith("--") && current.includes("=")) {
+    const eq = current.indexOf("=");
+    const flag = command.flags.find((f) => f.name === current.slice(2, eq));
+    if (!flag?.complete) return [];
+    const values = await completeValue(flag.complete, current.slice(eq + 1), command, rest, loadDocuments);
+    return isSentinel(values) ? [] : values.map((v) => `${current.slice(0, eq + 1)}${v}`);
+  }
+
+  // Value of the preceding flag
+  const prev = rest[rest.length - 1];
+  if (prev?.startsWith("--") && !prev.includes("=")) {
+    const flag = command.flags.find((f) => f.name === prev.slice(2));
+    if (flag?.value) {
+      return flag.complete ? completeValue(flag.complete, current, command, rest, loadDocuments) : [];
+    }
+  }
+
+  if (current.startsWith("-")) {
+    return matching(command.flags.map((f) => `--${f.name}`), current);
+  }
+
+  return command.positional ? completeValue(command.positional, current, command, rest, loadDocuments) : [];
+}
+
+async function completeValue(
+  kind: CompletionKind,
+  current: string,
+  command: CommandSpec,
+  rest: string[],
+  loadDocuments: DocumentLoader
+): Promise<string[]> {
+  switch (kind) {
+    case "file":
+      return [FILES_SENTINEL];
+    case "dir":
+      return [DIRS_SENTINEL];
+    case "shell":
+      return matching(SHELLS, current);
+    case "case":
+      return matching(CASE_MODES, current);
+    case "doc_id":
+    case "filter": {
+      const switches = command.flags.filter((f) => !f.value).map((f) => f.name);
+      const documents = await loadDocuments(command.name, parseArgs(rest, switches).flags);
+      if (!documents) return [];
+      return kind === "doc_id" ? docIdCandidates(documents, current) : filterCandidates(documents, current);
+    }
+  }
+}
+
+function docIdCandidates(documents: IndexedDocument[], current: string): string[] {
+  return matching(documents.map((d) => d.meta.doc_id), current);
+}
+
+/** `type=runbook,tags=au` → `type=runbook,tags=auth`, `type=runbook,tags=audit`, … */
+function filterCandidates(documents: IndexedDocument[], current: string): string[] {
+  const comma = current.lastIndexOf(",");
+  const head = current.slice(0, comma + 1);
+  const pairs = new Set<string>();
+  for (const doc of documents) {
+    for (const [key, values] of Object.entries(doc.meta.facets)) {
+      for (const value of values) pairs.add(`${key}=${value}`);
+    }
+    // The store filters on tags as a facet too
+    for (const tag of doc.meta.tags) pairs.add(`tags=${tag}`);
+  }
+  return matching([...pairs], current.slice(comma + 1)).map((p) => head + p);
+}
+
+function matching(candidates: string[], prefix: string): string[] {
+  return [...new Set(candidates.filter((c) => c.startsWith(prefix)))].sort();
+}
+
+function isSentinel(values: string[]): boolean {
+  return values[0] === FILES_SENTINEL || values[0] === DIRS_SENTINEL;
+}
+
+// ── Scripts ──────────────────────────────────────────────────────────
+
+const BASH = `# treenav-mcp bash completion
+# Install: treenav-mcp completion bash > /etc/bash_completion.d/treenav-mcp
+#      or: echo 'source <(treenav-mcp completion bash)' >> ~/.bashrc
+_treenav_mcp() {
+  local cur words cword
+  if declare -F _get_comp_words_by_ref >/dev/null; then
+    _get_comp_words_by_ref -n : cur words cword
+  else
+    cur="\${COMP_WORDS[COMP_CWORD]}"
+    words=("\${COMP_WORDS[@]}")
+    cword=$COMP_CWORD
+  fi
+
+  local IFS=$'\\n'
+  local -a out
+  out=($(treenav-mcp __complete "\${words[@]:1:cword}" 2>/dev/null))
+  case "\${out[0]}" in
+    ${FILES_SENTINEL}) COMPREPLY=($(compgen -f -- "$cur")) ;;
+    ${DIRS_SENTINEL}) COMPREPLY=($(compgen -d -- "$cur")) ;;
+    *) COMPREPLY=("\${out[@]}") ;;
+  esac
+
+  # doc_ids contain ':', which bash treats as a word break
+  if declare -F __ltrim_colon_completions >/dev/null; then
+    __ltrim_colon_completions "$cur"
+  fi
+}
+complete -o filenames -F _treenav_mcp treenav-mcp
+`;
+
+const ZSH = `#compdef treenav-mcp
+# treenav-mcp zsh completion
+# Install: treenav-mcp completion zsh > "\${fpath[1]}/_treenav-mcp"
+#      or: echo 'source <(treenav-mcp completion zsh)' >> ~/.zshrc
+_treenav_mcp() {
+  local -a out
+  out=("\${(@f)$(treenav-mcp __complete "\${(@)words[2,CURRENT]}" 2>/dev/null)}")
+  case "\${out[1]}" in
+    ${FILES_SENTINEL}) _files ;;
+    ${DIRS_SENTINEL}) _files -/ ;;
+    "") return 1 ;;
+    *) compadd -Q -- "\${out[@]}" ;;
+  esac
+}
+# Autoloaded from fpath: complete now. Sourced: register.
+if [[ "\${funcstack[1]}" == "_treenav-mcp" ]]; then
+  _treenav_mcp "$@"
+else
+  compdef _treenav_mcp treenav-mcp
+fi
+`;
+
+const FISH = `# treenav-mcp fish completion
+# Install: treenav-mcp completion fish > ~/.config/fish/completions/treenav-mcp.fish
+function __treenav_mcp_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    set -l out (treenav-mcp __complete $tokens[2..-1] "$current" 2>/dev/null)
+    switch "$out[1]"
+        case ${FILES_SENTINEL}
+            __fish_complete_path "$current"
+        case ${DIRS_SENTINEL}
+            __fish_complete_directories "$current"
+        case '*'
+            printf '%s\\n' $out
+    end
+end
+complete -c treenav-mcp -f -a '(__treenav_mcp_complete)'
+`;
+
+/** The completion script for `shell`, or null when unsupported. */
+export function completionScript(shell: string): string | null {
+  switch (shell) {
+    case "bash":
+      return BASH;
+    case "zsh":
+      return ZSH;
+    case "fish":
+      return FISH;
+    default:
+      return null;
+  }
+}
@@ -0,0 +1,417 @@
+/**
+ * Go synchronization landscape — the concurrency_map tool
+ *
+ * Per package (directory), the places goroutines start and the
+ * primitives they coordinate through:
+ *
+ *   goroutine   go f(...), go func() { ... }(), g.Go(func ...) (errgroup)
+ *   make_chan   make(chan T, n), with the name it is assigned to
+ *   send        ch <- v
+ *   receive     <-ch, v, ok := <-ch, for v := range ch (ch known as a channel)
+ *   close       close(ch)
+ *   select      select { ... }
+ *   lock        mu.Lock(), mu.RLock(), mu.TryLock()
+ *   unlock      mu.Unlock(), mu.RUnlock(), deferred or not
+ *   waitgroup   wg.Add / Done / Wait on a sync.WaitGroup
+ *   atomic      sync/atomic calls: atomic.AddInt64(&n, 1)
+ *
+ * Channels and mutexes are grouped by name: the expression as written,
+ * with a method's receiver dropped, so s.mu in one method and srv.mu in
+ * another are both "mu". Within each function, locks are paired with the
+ * next unlock of the same name and mode; a lock without one, or an
+ * unlock without a lock, is `unpaired` (often fine — a helper that
+ * expects its caller to hold the lock — but worth a look in a race
+ * review).
+ *
+ * Matching is lexical, by line over source with literals blanked, in Go
+ * files only.
+ */
+
+import { posix } from "node:path";
+import type { DocumentMeta, IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols, type CodeSymbol } from "./code-indexer";
+import { blankLiterals } from "./structural";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+
+export type ConcurrencyKind =
+  | "goroutine"
+  | "make_chan"
+  | "send"
+  | "receive"
+  | "close"
+  | "select"
+  | "lock"
+  | "unlock"
+  | "waitgroup"
+  | "atomic";
+
+export const CONCURRENCY_KINDS: ConcurrencyKind[] = [
+  "goroutine",
+  "make_chan",
+  "send",
+  "receive",
+  "close",
+  "select",
+  "lock",
+  "unlock",
+  "waitgroup",
+  "atomic",
+];
+
+/** One synchronization point on one line. */
+export interface ConcurrencySite {
+  kind: ConcurrencyKind;
+  /** The channel, mutex, or wait group, receiver dropped; what a goroutine runs; the atomic function */
+  name: string;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  text: string;
+  /** Qualified name of the function it is in; absent at top level */
+  function?: string;
+  /** lock / unlock: "read" for RLock / RUnlock */
+  mode?: "read" | "write";
+  /** unlock: under defer */
+  deferred?: boolean;
+}
+
+/** A lock and the unlock that releases it, or one of them alone. */
+export interface LockPair {
+  name: string;
+  mode: "read" | "write";
+  doc_id: string;
+  file_path: string;
+  function?: string;
+  lock?: number;
+  unlock?: number;
+  deferred: boolean;
+}
+
+export interface ChannelSummary {
+  name: string;
+  /** The element type from make or a declaration: chan Event */
+  type?: string;
+  /** The buffer size expression from make; absent when unbuffered */
+  buffer?: string;
+  make: number;
+  send: number;
+  receive: number;
+  close: number;
+}
+
+export interface MutexSummary {
+  name: string;
+  lock: number;
+  unlock: number;
+  deferred: number;
+  /** Locks or unlocks without a partner in their function */
+  unpaired: number;
+}
+
+export interface PackageConcurrency {
+  /** Directory of the files */
+  package: string;
+  files: number;
+  by_kind: Record<ConcurrencyKind, number>;
+  channels: ChannelSummary[];
+  mutexes: MutexSummary[];
+  /** Locks and unlocks without a partner in their function */
+  unpaired: LockPair[];
+}
+
+export interface ConcurrencyReport {
+  /** Go files scanned */
+  files: number;
+  /** Packages with at least one site, by directory */
+  packages: PackageConcurrency[];
+  /** Every site, in file and line order */
+  sites: ConcurrencySite[];
+}
+
+export interface ConcurrencyOptions {
+  /** Only files under this path prefix */
+  path?: string;
+  include_tests?: IncludeTests;
+}
+
+type FileSite = Omit<ConcurrencySite, "doc_id" | "file_path">;
+
+interface ScannedFile {
+  doc: DocumentMeta;
+  sites: FileSite[];
+  pairs: Array<Omit<LockPair, "doc_id" | "file_path">>;
+  /** Channel names declared with a chan type, and their types */
+  channels: Map<string, string>;
+  /** make(chan ...) assignments: name → [type, buffer] */
+  made: Map<string, { type: string; buffer?: string }>;
+  /** Names of range expressions, for receives once channels are known package-wide */
+  ranges: FileSite[];
+}
+
+const NAME = String.raw`[\p{L}_][\p{L}\p{N}_]*`;
+/** A selector or index expression: s.events, workers[i], ctx.Done() */
+const EXPR = String.raw`${NAME}(?:\s*\.\s*${NAME}|\[[^\]]*\]|\(\s*\))*`;
+
+/** A channel's element type: Event, *Job, []byte, struct{}, pkg.T */
+const ELEM = String.raw`(?:struct\s*\{\s*\}|interface\s*\{\s*\}|[*\[\]\p{L}\p{N}_.]+)`;
+const CHAN_TYPE = String.raw`(?:<-\s*)?chan\b(?:\s*<-)?\s*${ELEM}`;
+/** Keywords that can start a line before <- without it being a send */
+const NOT_SENDER = String.raw`(?!(?:case|return|go|defer|if|for|else|select|switch)\b)`;
+
+const GO_LAUNCH = new RegExp(String.raw`^\s*go\s+(func\b|${EXPR})`, "u");
+const GROUP_GO = new RegExp(String.raw`(${EXPR})\s*\.\s*Go\s*\(\s*func\b`, "u");
+const MAKE_CHAN = new RegExp(
+  String.raw`(?:(?:(${EXPR})\s*(?::=|=)|(${NAME})\s*:)\s*)?\bmake\s*\(\s*(${CHAN_TYPE})\s*(?:,\s*([^()]*?(?:\([^()]*\))?)\s*)?\)`,
+  "gu"
+);
+const SEND = new RegExp(String.raw`^\s*(?:case\s+)?${NOT_SENDER}(${EXPR})\s*<-`, "u");
+const RECEIVE = new RegExp(String.raw`<-\s*(?!chan\b)(${EXPR})`, "gu");
+const RANGE = new RegExp(String.raw`\brange\s+(${EXPR})\s*\{?\s*$`, "u");
+const CLOSE = new RegExp(String.raw`(?:^|[^\p{L}\p{N}_.])close\s*\(\s*(${EXPR})\s*\)`, "u");
+const SELECT = /^\s*select\s*\{/;
+const LOCK = new RegExp(String.raw`(${EXPR})\s*\.\s*(Lock|RLock|TryLock|TryRLock|Unlock|RUnlock)\s*\(\s*\)`, "gu");
+const WG_CALL = new RegExp(String.raw`(${EXPR})\s*\.\s*(Add|Done|Wait)\s*\(`, "gu");
+const ATOMIC = /\batomic\s*\.\s*([A-Z]\w*)\s*\(/g;
+const CHAN_DECL = new RegExp(String.raw`(${NAME})\s+(${CHAN_TYPE})`, "gu");
+const WG_DECL = new RegExp(String.raw`(${NAME})(?:\s+\*?|\s*:?=\s*(?:&|new\s*\(\s*))sync\.WaitGroup\b`, "gu");
+
+/**
+ * The name an expression groups under: whitespace removed and the
+ * method's receiver dropped.
+ */
+function groupName(expr: string, receiver?: string): string {
+  const name = expr.replace(/\s+/g, "");
+  return receiver && name.startsWith(`${receiver}.`) ? name.slice(receiver.length + 1) : name;
+}
+
+/** The sites and lock pairs of one Go file, with the channel names it declares. */
+export function scanConcurrency(
+  source: string,
+  filePath: string
+): Pick<ScannedFile, "sites" | "pairs" | "channels" | "made" | "ranges"> {
+  const functionAt = functions(source, filePath);
+  const blanked = blankLiterals(source, false).split("\n");
+  const raw = source.split("\n");
+  const sites: FileSite[] = [];
+  const ranges: FileSite[] = [];
+  const channels = new Map<string, string>();
+  const made = new Map<string, { type: string; buffer?: string }>();
+  const waitGroups = new Set<string>();
+
+  for (const line of blanked) {
+    for (const m of line.matchAll(CHAN_DECL)) channels.set(m[1], m[2].trim());
+    for (const m of line.matchAll(WG_DECL)) waitGroups.add(m[1]);
+  }
+
+  for (let i = 0; i < blanked.length; i++) {
+    const line = blanked[i];
+    if (!line.trim()) continue;
+    const fn = functionAt(i + 1);
+    const at = (kind: ConcurrencyKind, name: string, extra: Partial<FileSite> = {}): FileSite => ({
+      kind,
+      name,
+      line: i + 1,
+      text: raw[i].trim(),
+      ...(fn ? { function: fn.name } : {}),
+      ...extra,
+    });
+    const name = (expr: string) => groupName(expr, fn?.receiver);
+
+    const launch = line.match(GO_LAUNCH);
+    if (launch) sites.push(at("goroutine", launch[1] === "func" ? "func literal" : name(launch[1].replace(/\(\s*\)$/, ""))));
+    const group = line.match(GROUP_GO);
+    if (group) sites.push(at("goroutine", `${name(group[1])}.Go`));
+
+    for (const make of line.matchAll(MAKE_CHAN)) {
+      const target = make[1] || make[2] ? name(make[1] ?? make[2]) : "(unnamed)";
+      const type = make[3].replace(/\s+/g, " ").trim();
+      made.set(target, { type, ...(make[4] ? { buffer: make[4].trim() } : {}) });
+      sites.push(at("make_chan", target));
+    }
+
+    const send = line.match(SEND);
+    let sendEnd = -1;
+    if (send && !/^\s*(?:case\s+)?chan\b/.test(line)) {
+      sites.push(at("send", name(send[1])));
+      sendEnd = send[0].length;
+    }
+    for (const m of line.matchAll(RECEIVE)) {
+      if (m.index! < sendEnd) continue;
+      // chan<- T and <-chan T in types are not receives
+      if (/\bchan\s*$/.test(line.slice(0, m.index!))) continue;
+      // <-time.After(d): a receive from what a call returns
+      const call = /^\s*\(/.test(line.slice(m.index! + m[0].length)) && !m[1].endsWith(")");
+      sites.push(at("receive", `${name(m[1])}${call ? "()" : ""}`));
+    }
+    const ranged = line.match(RANGE);
+    if (ranged && /^\s*for\b/.test(line)) ranges.push(at("receive", name(ranged[1])));
+
+    const closed = line.match(CLOSE);
+    if (closed) sites.push(at("close", name(closed[1])));
+    if (SELECT.test(line)) sites.push(at("select", "select"));
+
+    const deferred = /^\s*defer\b/.test(line);
+    for (const m of line.matchAll(LOCK)) {
+      const unlock = m[2].endsWith("Unlock");
+      const mode = m[2].includes("RLock") || m[2] === "RUnlock" ? "read" : "write";
+      sites.push(at(unlock ? "unlock" : "lock", name(m[1]), { mode, ...(unlock ? { deferred } : {}) }));
+    }
+    for (const m of line.matchAll(WG_CALL)) {
+      if (waitGroups.has(m[1].replace(/\s+/g, "").split(".").pop()!)) sites.push(at("waitgroup", name(m[1])));
+    }
+    for (const m of line.matchAll(ATOMIC)) sites.push(at("atomic", `atomic.${m[1]}`));
+  }
+
+  // Pair locks with unlocks of the same name and mode, function by function
+  const pairs: ScannedFile["pairs"] = [];
+  const open = new Map<string, FileSite[]>();
+  let current: string | undefined;
+  const flush = () => {
+    for (const locks of open.values()) {
+      for (const lock of locks) {
+        pairs.push({ name: lock.name, mode: lock.mode!, ...(lock.function ? { function: lock.function } : {}), lock: lock.line, deferred: false });
+      }
+    }
+    open.clear();
+  };
+  for (const site of sites) {
+    if (site.kind !== "lock" && site.kind !== "unlock") continue;
+    const scope = `${site.function ?? ""}@${functionAt(site.line)?.start ?? 0}`;
+    if (scope !== current) {
+      flush();
+      current = scope;
+    }
+    const key = `${site.name}\0${site.mode}`;
+    if (site.kind === "lock") {
+      if (!open.has(key)) open.set(key, []);
+      open.get(key)!.push(site);
+      continue;
+    }
+    const lock = open.get(key)?.shift();
+    pairs.push({
+      name: site.name,
+      mode: site.mode!,
+      ...(site.function ? { function: site.function } : {}),
+      ...(lock ? { lock: lock.line } : {}),
+      unlock: site.line,
+      deferred: site.deferred ?? false,
+    });
+  }
+  flush();
+
+  return { sites, pairs, channels, made, ranges };
+}
+
+const emptyCounts = (): Record<ConcurrencyKind, number> =>
+  Object.fromEntries(CONCURRENCY_KINDS.map((k) => [k, 0])) as Record<ConcurrencyKind, number>;
+
+/** The synchronization map of the Go files in a store's code collections. */
+export class ConcurrencyMap {
+  private readonly cache: FileScanCache<ScannedFile>;
+
+  constructor(config: IndexConfig) {
+    this.cache = new FileScanCache(config, (source, meta) => ({ doc: meta, ...scanConcurrency(source, meta.file_path) }));
+  }
+
+  /** Sites grouped by package, with channel and mutex summaries. */
+  async map(store: DocumentStore, options: ConcurrencyOptions = {}): Promise<ConcurrencyReport> {
+    const files = (await this.files(store, options.path)).filter((f) => matchesTests(f.doc.file_path, options.include_tests));
+    const byPackage = new Map<string, ScannedFile[]>();
+    for (const file of files) {
+      const dir = posix.dirname(file.doc.file_path).replace(/^\.$/, "") || ".";
+      const key = `${file.doc.collection}\0${dir}`;
+      if (!byPackage.has(key)) byPackage.set(key, []);
+      byPackage.get(key)!.push(file);
+    }
+
+    const sites: ConcurrencySite[] = [];
+    const packages: PackageConcurrency[] = [];
+    for (const [key, group] of byPackage) {
+      // A channel is known package-wide: declared in one file, ranged over in another
+      const declared = new Map<string, string>();
+      const made = new Map<string, { type: string; buffer?: string }>();
+      for (const f of group) {
+        for (const [n, t] of f.channels) if (!declared.has(n)) declared.set(n, t);
+        for (const [n, m] of f.made) if (!made.has(n)) made.set(n, m);
+      }
+      const isChannel = (name: string) => made.has(name) || declared.has(name.split(".").pop()!);
+
+      const mine: ConcurrencySite[] = [];
+      const unpaired: LockPair[] = [];
+      for (const f of group) {
+        const where = { doc_id: f.doc.doc_id, file_path: f.doc.file_path };
+        const found = [...f.sites, ...f.ranges.filter((r) => isChannel(r.name))].sort((a, b) => a.line - b.line);
+        for (const s of found) mine.push({ ...s, ...where });
+        for (const p of f.pairs) if (p.lock === undefined || p.unlock === undefined) unpaired.push({ ...p, ...where });
+      }
+      if (mine.length === 0) continue;
+
+      const byKind = emptyCounts();
+      const channels = new Map<string, ChannelSummary>();
+      const mutexes = new Map<string, MutexSummary>();
+      for (const s of mine) {
+        byKind[s.kind]++;
+        // <-ctx.Done() and <-time.After(d) receive from a call, not a channel of the package
+        const channelUse = s.kind === "make_chan" || s.kind === "send" || s.kind === "close" || (s.kind === "receive" && !s.name.endsWith(")"));
+        if (channelUse && s.name !== "(unnamed)") {
+          let c = channels.get(s.name);
+          if (!c) {
+            const m = made.get(s.name);
+            const type = m?.type ?? declared.get(s.name.split(".").pop()!);
+            c = { name: s.name, ...(type ? { type } : {}), ...(m?.buffer ? { buffer: m.buffer } : {}), make: 0, send: 0, receive: 0, close: 0 };
+            channels.set(s.name, c);
+          }
+          c[s.kind === "make_chan" ? "make" : (s.kind as "send" | "receive" | "close")]++;
+        }
+        if (s.kind === "lock" || s.kind === "unlock") {
+          let m = mutexes.get(s.name);
+          if (!m) mutexes.set(s.name, (m = { name: s.name, lock: 0, unlock: 0, deferred: 0, unpaired: 0 }));
+          m[s.kind]++;
+          if (s.deferred) m.deferred++;
+        }
+      }
+      for (const p of unpaired) if (mutexes.has(p.name)) mutexes.get(p.name)!.unpaired++;
+
+      packages.push({
+        package: key.split("\0")[1],
+        files: group.length,
+        by_kind: byKind,
+        channels: [...channels.values()].sort((a, b) => a.name.localeCompare(b.name)),
+        mutexes: [...mutexes.values()].sort((a, b) => a.name.localeCompare(b.name)),
+        unpaired: unpaired.sort((a, b) => a.file_path.localeCompare(b.file_path) || (a.lock ?? a.unlock!) - (b.lock ?? b.unlock!)),
+      });
+      sites.push(...mine);
+    }
+
+    return {
+      files: files.length,
+      packages: packages.sort((a, b) => a.package.localeCompare(b.package)),
+      sites: sites.sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line),
+    };
+  }
+
+  /** Scanned Go files of the store. */
+  private async files(store: DocumentStore, pathPrefix?: string): Promise<ScannedFile[]> {
+    const files = await this.cache.scan(store, { pathPrefix, accept: (path) => path.endsWith(".go") });
+    return files.sort((a, b) => a.doc.file_path.localeCompare(b.doc.file_path));
+  }
+}
+
+/** A lookup from line to the innermost function around it, with a method's receiver name. */
+function functions(source: string, filePath: string): (line: number) => { name: string; receiver?: string; start: number } | undefined {
+  const symbols = parseCodeSymbols(source, filePath);
+  const lines = source.split("\n");
+  const byId = new Map(symbols.map((s): [string, CodeSymbol] => [s.id, s]));
+  const fns = symbols.filter((s) => s.kind === "function" || s.kind === "method");
+  return (line) => {
+    const fn = fns
+      .filter((f) => f.line_start <= line && line <= f.line_end)
+      .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start))[0];
+    if (!fn) return undefined;
+    const parent = fn.parent_id ? byId.get(fn.parent_id) : undefined;
+    const receiver = lines[fn.line_start - 1].match(/^\s*func\s*\(\s*(\w+)\s/)?.[1];
+    return { name: parent ? `${parent.name}.${fn.name}` : fn.name, ...(receiver ? { receiver } : {}), start: fn.line_start };
+  };
+}
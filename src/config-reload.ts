@@ -0,0 +1,254 @@
+/**
+ * Config hot reload — the config file, watched, and SIGHUP
+ *
+ * The servers watch the config file they started with and re-resolve
+ * it when it changes, or on SIGHUP. Flags and environment still win,
+ * so only options whose effective value moved count as changed. Each
+ * changed option is applied one of three ways:
+ *
+ *   live      in place, from the next call on: ranking (PATH_BOOSTS,
+ *             REFERENCE_WEIGHT, COVERAGE_WEIGHT, RECENCY_*,
+ *             RANKING_WASM), SYNONYMS,
+ *             the tool deadlines, and write mode (WIKI_*)
+ *   reindex   file discovery (INCLUDE, VENDOR_POLICY, DOCS_GLOB,
+ *             CODE_GLOB, SYMLINKS): the collections are updated in
+ *             place and one incremental pass adds and drops files;
+ *             files already indexed are not re-parsed
+ *   restart   everything else (roots, ports, index mode, ...); reported
+ *             and left alone
+ *
+ * The outcome is logged and, on stdio, sent to the client as an MCP
+ * log notification (notifications/message).
+ */
+
+import { watch, type FSWatcher } from "node:fs";
+import { basename, dirname, resolve } from "node:path";
+import { envName, parsePathBoosts, parseSynonymGroups, toToolTimeouts, toWikiOptions, type ServeConfig } from "./config";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import type { WikiOptions } from "./curator";
+import type { ToolTimeouts } from "./deadline";
+import { revalidateIndex, type RevalidationReport } from "./index-cache";
+import { applyRecencyBoost } from "./git-history";
+import { vendorBoosts } from "./vendor";
+import { loadRankingWasm } from "./wasm-ranking";
+
+/** Quiet period after a config file event; editors write in several steps */
+const RELOAD_DEBOUNCE_MS = 200;
+
+const LIVE_KEYS = new Set<keyof ServeConfig>([
+  "ranking_wasm",
+  "byte_offsets",
+  "generated_policy",
+  "comment_matching",
+  "comment_languages",
+  "synonyms",
+  "path_boosts",
+  "reference_weight",
+  "coverage_weight",
+  "max_per_file",
+  "max_per_package",
+  "recency_weight",
+  "recency_half_life_days",
+  "tool_timeout_ms",
+  "search_timeout_ms",
+  "graph_timeout_ms",
+  "git_timeout_ms",
+  "wiki_write",
+  "wiki_root",
+  "wiki_duplicate_threshold",
+]);
+
+const REINDEX_KEYS = new Set<keyof ServeConfig>(["include", "vendor_policy", "docs_glob", "code_glob", "symlinks"]);
+
+export interface ConfigChanges {
+  live: (keyof ServeConfig)[];
+  reindex: (keyof ServeConfig)[];
+  restart: (keyof ServeConfig)[];
+}
+
+/** The options whose value differs between `prev` and `next`, by how they apply. */
+export function diffConfig(prev: ServeConfig, next: ServeConfig): ConfigChanges {
+  const changes: ConfigChanges = { live: [], reindex: [], restart: [] };
+  for (const key of Object.keys(next) as (keyof ServeConfig)[]) {
+    if (JSON.stringify(prev[key]) === JSON.stringify(next[key])) continue;
+    if (LIVE_KEYS.has(key)) changes.live.push(key);
+    else if (REINDEX_KEYS.has(key)) changes.reindex.push(key);
+    else changes.restart.push(key);
+  }
+  return changes;
+}
+
+/** What the running server exposes to a reload. */
+export interface ReloadTarget {
+  store: DocumentStore;
+  /** The live IndexConfig; discovery options are updated in place */
+  config: IndexConfig;
+  /** False where the store is not a full index (LAZY_INDEX, SHARD_DIR, tenants) */
+  reindexable: boolean;
+  /** The deadlines object the tools read; updated in place */
+  timeouts: ToolTimeouts;
+  setWiki: (wiki: WikiOptions | undefined) => void;
+}
+
+export interface ReloadReport {
+  /** Env names of the options applied, live or by re-indexing */
+  applied: string[];
+  /** Env names of the changed options that need a restart */
+  restart: string[];
+  /** The incremental pass, when discovery options changed */
+  reindexed: RevalidationReport | null;
+}
+
+/** One-line summary of a reload, for the log and the notification. */
+export function formatReload(report: ReloadReport): string {
+  if (!report.applied.length && !report.restart.length) return "Config reloaded; nothing changed";
+  const parts: string[] = [];
+  if (report.applied.length) parts.push(`applied ${report.applied.join(", ")}`);
+  if (report.reindexed) {
+    const { added, removed, updated } = report.reindexed;
+    parts.push(`re-indexed (${added.length} added, ${removed.length} removed, ${updated.length} updated)`);
+  }
+  if (report.restart.length) parts.push(`needs a restart: ${report.restart.join(", ")}`);
+  return `Config reloaded; ${parts.join("; ")}`;
+}
+
+export class ConfigReloader {
+  private current: ServeConfig;
+  private watcher: FSWatcher | null = null;
+  private timer: ReturnType<typeof setTimeout> | null = null;
+  private running: Promise<ReloadReport | null> = Promise.resolve(null);
+  private readonly log: (msg: string) => void;
+  private readonly notify?: (message: string, report: ReloadReport) => void;
+
+  constructor(
+    settings: ServeConfig,
+    /** Re-resolve flags > env > file > defaults, e.g. loadConfig(argv) */
+    private readonly load: () => Promise<ServeConfig>,
+    private readonly target: ReloadTarget,
+    options: {
+      log?: (msg: string) => void;
+      notify?: (message: string, report: ReloadReport) => void;
+    } = {}
+  ) {
+    this.current = settings;
+    this.log = options.log ?? ((msg: string) => console.error(msg));
+    this.notify = options.notify;
+  }
+
+  /** Reload when `path` changes. Watches its directory, so editors that replace the file are seen too. */
+  watch(path: string): void {
+    const file = basename(path);
+    this.watcher = watch(dirname(resolve(path)), (_event, filename) => {
+      if (filename?.toString() !== file) return;
+      if (this.timer) clearTimeout(this.timer);
+      this.timer = setTimeout(() => void this.reload(), RELOAD_DEBOUNCE_MS);
+    });
+    this.watcher.on("error", (err) => this.log(`Config watcher error on ${path}: ${err.message}`));
+  }
+
+  stop(): void {
+    this.watcher?.close();
+    this.watcher = null;
+    if (this.timer) clearTimeout(this.timer);
+    this.timer = null;
+  }
+
+  /**
+   * Re-resolve the config and apply what changed. Reloads are
+   * serialized. Resolves null, keeping the running config, when the
+   * new one does not load.
+   */
+  reload(): Promise<ReloadReport | null> {
+    this.running = this.running.then(() =>
+      this.apply().catch((err) => {
+        this.log(`Config reload failed: ${err.message}`);
+        return null;
+      })
+    );
+    return this.running;
+  }
+
+  private async apply(): Promise<ReloadReport> {
+    const next = await this.load();
+    const changes = diffConfig(this.current, next);
+    const { store, config } = this.target;
+
+    const reindex = this.target.reindexable ? changes.reindex : [];
+    const restart = [...changes.restart, ...(this.target.reindexable ? [] : changes.reindex)];
+    const touched = new Set([...changes.live, ...reindex]);
+
+    // First, so a module that fails to load leaves everything as it was
+    if (touched.has("ranking_wasm")) {
+      store.setRescorer(next.ranking_wasm ? await loadRankingWasm(next.ranking_wasm, { log: this.log }) : null);
+    }
+
+    if (touched.has("byte_offsets")) store.setByteOffsets(next.byte_offsets);
+    if (touched.has("generated_policy")) store.setGeneratedPolicy(next.generated_policy);
+    if (touched.has("comment_matching") || touched.has("comment_languages")) {
+      store.setCommentMatching({ matching: next.comment_matching, languages: next.comment_languages });
+    }
+    if (touched.has("synonyms")) store.loadSynonyms(parseSynonymGroups(next.synonyms));
+    if (touched.has("path_boosts") || touched.has("vendor_policy")) {
+      store.setPathBoosts([...parsePathBoosts(next.path_boosts), ...vendorBoosts(next.vendor_policy)]);
+    }
+    if (
+      touched.has("reference_weight") ||
+      touched.has("coverage_weight") ||
+      touched.has("max_per_file") ||
+      touched.has("max_per_package")
+    ) {
+      store.setRanking({
+        reference_weight: next.reference_weight,
+        coverage_weight: next.coverage_weight,
+        max_per_file: next.max_per_file,
+        max_per_package: next.max_per_package,
+      });
+    }
+    if (touched.has("recency_weight") || touched.has("recency_half_life_days")) {
+      // Commit times are only read at startup when the boost was on
+      if (next.recency_weight > 0) applyRecencyBoost(store, config, next.recency_weight, next.recency_half_life_days);
+      else store.setRecencyBoost(0, next.recency_half_life_days);
+    }
+    if (changes.live.some((key) => key.endsWith("_timeout_ms"))) {
+      const timeouts = this.target.timeouts;
+      for (const key of Object.keys(timeouts) as (keyof ToolTimeouts)[]) delete timeouts[key];
+      Object.assign(timeouts, toToolTimeouts(next));
+    }
+    if (changes.live.some((key) => key.startsWith("wiki_"))) this.target.setWiki(toWikiOptions(next));
+
+    let reindexed: RevalidationReport | null = null;
+    if (reindex.length) {
+      applyDiscovery(config, next);
+      reindexed = await revalidateIndex(store, config);
+    }
+
+    // Options that need a restart keep their old value, so they are reported again next time
+    for (const key of [...changes.live, ...reindex]) (this.current as any)[key] = next[key];
+
+    const report: ReloadReport = {
+      applied: [...changes.live, ...reindex].map(envName),
+      restart: restart.map(envName),
+      reindexed,
+    };
+    const message = formatReload(report);
+    this.log(message);
+    this.notify?.(message, report);
+    return report;
+  }
+}
+
+/** Copy the discovery options onto the docs and code collections. */
+function applyDiscovery(config: IndexConfig, next: ServeConfig): void {
+  const [docs] = config.collections;
+  docs.glob_pattern = next.docs_glob;
+  docs.symlinks = next.symlinks;
+  docs.include = next.include;
+  for (const code of config.code_collections ?? []) {
+    if (code.name !== next.code_collection) continue;
+    code.glob_pattern = next.code_glob;
+    code.symlinks = next.symlinks;
+    code.include = next.include;
+    code.vendor = next.vendor_policy;
+  }
+}
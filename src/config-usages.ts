@@ -0,0 +1,326 @@
+/**
+ * Environment variables, flags, and config keys — the config_usages tool
+ *
+ * "What does DB_POOL_SIZE actually control?" is answered by every place
+ * the code reads it, and where it is declared with a default. This finds
+ * both, line by line, and groups them by key:
+ *
+ *   env      os.Getenv / LookupEnv, env:"X" and envconfig:"X" struct tags,
+ *            viper.BindEnv; os.environ / getenv; process.env, Bun.env,
+ *            import.meta.env, Deno.env.get; System.getenv;
+ *            Environment.GetEnvironmentVariable; env::var, env!,
+ *            clap's env = "X"; ENV["X"] / ENV.fetch
+ *   flag     flag / pflag definitions (flag.String, fs.IntVar, cmd.Flags()
+ *            .StringP, ...) and cobra's Flags().GetString reads; argparse
+ *            add_argument, click.option, commander .option
+ *   config   viper.Get* / SetDefault / BindPFlag; System.getProperty and
+ *            Spring @Value("${a.b}"); .NET Configuration["a:b"]
+ *
+ * Each site is a read or a definition (a flag declared, a default set,
+ * a variable exported or bound). Keys are only found when they are
+ * string literals, and process.env.X property names; a key built at
+ * run time is missed. Matches in comments and strings are not counted.
+ */
+
+import { extname } from "node:path";
+import type { IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+
+export type ConfigSource = "env" | "flag" | "config";
+
+export const CONFIG_SOURCES: ConfigSource[] = ["env", "flag", "config"];
+
+export type ConfigUse = "read" | "define";
+
+/** One key named at one place in a file. */
+export interface ConfigHit {
+  key: string;
+  source: ConfigSource;
+  use: ConfigUse;
+  /** How it is named: os.Getenv, viper.GetString, flag.Duration, process.env, ... */
+  api: string;
+  line: number;
+  text: string;
+}
+
+export interface ConfigSite extends ConfigHit {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+}
+
+export interface ConfigKey {
+  key: string;
+  source: ConfigSource;
+  definitions: ConfigSite[];
+  reads: ConfigSite[];
+}
+
+interface Rule {
+  /** Global: a line may name several keys */
+  pattern: RegExp;
+  read(m: RegExpExecArray, source: string): Omit<ConfigHit, "line" | "text"> | null;
+}
+
+/** A double- or single-quoted string captured as `key` */
+const KEY = String.raw`(?<q>["'])(?<key>[^"'\\\s]+)\k<q>`;
+
+/** --name or -n → name */
+const flagName = (arg: string) => arg.replace(/^--?/, "").replace(/[= <[].*$/, "");
+
+// ── Go ───────────────────────────────────────────────────────────────
+
+const VIPER_GETTERS =
+  "GetString|GetInt|GetInt32|GetInt64|GetUint|GetUint16|GetUint32|GetUint64|GetFloat64|GetBool|GetDuration|GetTime|GetStringSlice|GetIntSlice|GetStringMap|GetStringMapString|GetStringMapStringSlice|GetSizeInBytes";
+
+const FLAG_TYPES =
+  "String|Int|Int64|Int32|Int16|Int8|Uint|Uint64|Uint32|Uint16|Uint8|Bool|Duration|Float64|Float32|StringSlice|StringArray|IntSlice|StringToString|BytesHex|IP|IPNet|Count|Func|BoolFunc|TextVar|Var";
+
+const GO_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\bos\.(?<fn>Getenv|LookupEnv|Setenv|Unsetenv)\(\s*${KEY}`, "g"),
+    read: (m) => ({
+      key: m.groups!.key,
+      source: "env",
+      use: m.groups!.fn.startsWith("Set") || m.groups!.fn === "Unsetenv" ? "define" : "read",
+      api: `os.${m.groups!.fn}`,
+    }),
+  },
+  {
+    // `env:"DB_HOST"`, `envconfig:"DB_HOST"`, `env:"DB_HOST,required"`
+    pattern: /\b(?<tag>env|envconfig):"(?<key>[A-Za-z_][\w.]*)/g,
+    read: (m) => ({ key: m.groups!.key, source: "env", use: "define", api: `${m.groups!.tag} tag` }),
+  },
+  {
+    // viper.GetString("db.host"), or any receiver's typed getter in a file importing viper
+    pattern: new RegExp(String.raw`\b(?<recv>\w+)\.(?<fn>Get|IsSet|${VIPER_GETTERS})\(\s*${KEY}`, "g"),
+    read: (m, source) => {
+      const { recv, fn, key } = m.groups!;
+      if (recv !== "viper" && (fn === "Get" || fn === "IsSet" || !source.includes("github.com/spf13/viper"))) return null;
+      return { key, source: "config", use: "read", api: `${recv === "viper" ? "viper" : "(*viper.Viper)"}.${fn}` };
+    },
+  },
+  {
+    pattern: new RegExp(String.raw`\bviper\.(?<fn>SetDefault|Set|BindPFlag|RegisterAlias)\(\s*${KEY}`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "config", use: "define", api: `viper.${m.groups!.fn}` }),
+  },
+  {
+    // viper.BindEnv("db.host", "DB_HOST") binds both; with one argument the key is the variable
+    pattern: new RegExp(String.raw`\bviper\.BindEnv\(\s*${KEY}(?:\s*,\s*"(?<env>[^"]+)")?`, "g"),
+    read: (m) => ({ key: m.groups!.env ?? m.groups!.key, source: "env", use: "read", api: "viper.BindEnv" }),
+  },
+  {
+    // flag.String("addr", ...), fs.DurationVar(&d, "timeout", ...), cmd.Flags().StringP("name", "n", ...)
+    // A FlagSet may have any name (fs, set), so other receivers count in files importing flag or pflag
+    pattern: new RegExp(
+      String.raw`\b(?<recv>\w+|Flags\(\)|PersistentFlags\(\))\.(?<fn>(?:${FLAG_TYPES})(?:Var)?P?)\(\s*(?:&?[\w.[\]]+\s*,\s*)?${KEY}\s*,`,
+      "g"
+    ),
+    read: (m, source) => {
+      const { recv, fn, key } = m.groups!;
+      const known = recv === "flag" || recv === "pflag" || recv.endsWith("Flags()") || /[Ff]lags?(?:Set)?$/.test(recv);
+      if (!known && !/"flag"|"github\.com\/spf13\/pflag"/.test(source)) return null;
+      return { key, source: "flag", use: "define", api: `${recv}.${fn}` };
+    },
+  },
+  {
+    // cobra: cmd.Flags().GetString("name"), Lookup("name")
+    pattern: new RegExp(String.raw`\bFlags\(\)\.(?<fn>Get\w+|Lookup|Changed)\(\s*${KEY}`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "flag", use: "read", api: `Flags().${m.groups!.fn}` }),
+  },
+];
+
+// ── Python ───────────────────────────────────────────────────────────
+
+const PY_RULES: Rule[] = [
+  {
+    // os.environ["X"] reads, os.environ["X"] = v defines
+    pattern: new RegExp(String.raw`\bos\.environ\[\s*${KEY}\s*\](?<assign>\s*=(?!=))?`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "env", use: m.groups!.assign ? "define" : "read", api: "os.environ" }),
+  },
+  {
+    pattern: new RegExp(String.raw`\bos\.(?<fn>getenv|environ\.get|environ\.setdefault|putenv)\(\s*${KEY}`, "g"),
+    read: (m) => ({
+      key: m.groups!.key,
+      source: "env",
+      use: m.groups!.fn === "environ.setdefault" || m.groups!.fn === "putenv" ? "define" : "read",
+      api: `os.${m.groups!.fn}`,
+    }),
+  },
+  {
+    // parser.add_argument("-p", "--port", ...), @click.option("--port")
+    pattern: /\b(?<fn>add_argument|option)\(\s*(?:["']-\w["']\s*,\s*)?["'](?<key>--[\w-]+)["']/g,
+    read: (m) => ({ key: flagName(m.groups!.key), source: "flag", use: "define", api: m.groups!.fn === "option" ? "click.option" : "argparse" }),
+  },
+];
+
+// ── JavaScript / TypeScript ──────────────────────────────────────────
+
+const JS_RULES: Rule[] = [
+  {
+    // process.env.PORT, process.env["PORT"], Bun.env.PORT, import.meta.env.VITE_API
+    pattern: new RegExp(
+      String.raw`\b(?<api>process\.env|Bun\.env|import\.meta\.env)(?:\.(?<prop>[A-Za-z_$][\w$]*)|\[\s*${KEY}\s*\])(?<assign>\s*(?:\?\?|\|\|)?=(?!=))?`,
+      "g"
+    ),
+    read: (m) => ({
+      key: m.groups!.prop ?? m.groups!.key,
+      source: "env",
+      use: m.groups!.assign ? "define" : "read",
+      api: m.groups!.api,
+    }),
+  },
+  {
+    pattern: new RegExp(String.raw`\bDeno\.env\.(?<fn>get|set|has)\(\s*${KEY}`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "env", use: m.groups!.fn === "set" ? "define" : "read", api: `Deno.env.${m.groups!.fn}` }),
+  },
+  {
+    // commander / yargs: .option("-p, --port <n>")
+    pattern: /\.(?:option|requiredOption)\(\s*["'`](?:-\w,?\s*)?(?<key>--[\w-]+)/g,
+    read: (m) => ({ key: flagName(m.groups!.key), source: "flag", use: "define", api: ".option" }),
+  },
+];
+
+// ── JVM, .NET, Rust, Ruby ────────────────────────────────────────────
+
+const JAVA_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\bSystem\.(?<fn>getenv|getProperty|setProperty)\(\s*${KEY}`, "g"),
+    read: (m) => ({
+      key: m.groups!.key,
+      source: m.groups!.fn === "getenv" ? "env" : "config",
+      use: m.groups!.fn === "setProperty" ? "define" : "read",
+      api: `System.${m.groups!.fn}`,
+    }),
+  },
+  {
+    // Spring: @Value("${db.pool.size:10}")
+    pattern: /@Value\(\s*"\$\{(?<key>[\w.-]+)(?::[^}]*)?\}"/g,
+    read: (m) => ({ key: m.groups!.key, source: "config", use: "read", api: "@Value" }),
+  },
+];
+
+const CSHARP_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\bEnvironment\.(?<fn>GetEnvironmentVariable|SetEnvironmentVariable)\(\s*${KEY}`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "env", use: m.groups!.fn.startsWith("Set") ? "define" : "read", api: `Environment.${m.groups!.fn}` }),
+  },
+  {
+    pattern: new RegExp(String.raw`\b(?<recv>\w*[Cc]onfiguration)(?:\[\s*${KEY}\s*\]|\.GetValue<[^>]+>\(\s*"(?<typed>[^"]+)")`, "g"),
+    read: (m) => ({ key: m.groups!.key ?? m.groups!.typed, source: "config", use: "read", api: "IConfiguration" }),
+  },
+];
+
+const RUST_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\b(?:std::)?env::(?<fn>var|var_os|set_var|remove_var)\(\s*${KEY}`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "env", use: m.groups!.fn.endsWith("_var") ? "define" : "read", api: `env::${m.groups!.fn}` }),
+  },
+  {
+    pattern: new RegExp(String.raw`\b(?<fn>env|option_env)!\(\s*${KEY}`, "g"),
+    read: (m) => ({ key: m.groups!.key, source: "env", use: "read", api: `${m.groups!.fn}!` }),
+  },
+  {
+    // clap: #[arg(long, env = "PORT")]
+    pattern: /#\[(?:arg|clap)\([^\]]*\benv\s*=\s*"(?<key>[^"]+)"/g,
+    read: (m) => ({ key: m.groups!.key, source: "env", use: "define", api: "clap env" }),
+  },
+];
+
+const RUBY_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\bENV(?:\[\s*${KEY}\s*\](?<assign>\s*=(?!=))?|\.fetch\(\s*["'](?<fetched>[^"']+)["'])`, "g"),
+    read: (m) => ({
+      key: m.groups!.key ?? m.groups!.fetched,
+      source: "env",
+      use: m.groups!.assign ? "define" : "read",
+      api: m.groups!.fetched ? "ENV.fetch" : "ENV[]",
+    }),
+  },
+];
+
+const RULES: Record<string, Rule[]> = {
+  ".go": GO_RULES,
+  ".py": PY_RULES, ".pyi": PY_RULES,
+  ".ts": JS_RULES, ".tsx": JS_RULES, ".mts": JS_RULES, ".cts": JS_RULES,
+  ".js": JS_RULES, ".jsx": JS_RULES, ".mjs": JS_RULES, ".cjs": JS_RULES,
+  ".java": JAVA_RULES, ".kt": JAVA_RULES, ".kts": JAVA_RULES, ".scala": JAVA_RULES,
+  ".cs": CSHARP_RULES,
+  ".rs": RUST_RULES,
+  ".rb": RUBY_RULES,
+};
+
+/** Config keys named in a source file, in line order. */
+export function scanConfigUsages(source: string, filePath: string): ConfigHit[] {
+  const rules = RULES[extname(filePath).toLowerCase()];
+  if (!rules) return [];
+  const lines = source.split("\n");
+  const blanked = blankLiterals(source, hashCommentsFor(filePath)).split("\n");
+  const hits: ConfigHit[] = [];
+  for (let i = 0; i < lines.length; i++) {
+    if (!blanked[i].trim()) continue;
+    const seen = new Set<string>();
+    for (const rule of rules) {
+      rule.pattern.lastIndex = 0;
+      for (let m = rule.pattern.exec(lines[i]); m; m = rule.pattern.exec(lines[i])) {
+        // The match must start in code; struct tags and attributes live in literals and comments
+        const inCode = blanked[i][m.index] !== " " || /^(?:env|envconfig):|^#\[/.test(m[0]);
+        if (!inCode) continue;
+        const found = rule.read(m, source);
+        if (!found) continue;
+        const id = `${found.source}\0${found.key}\0${found.use}`;
+        if (seen.has(id)) continue;
+        seen.add(id);
+        hits.push({ ...found, line: i + 1, text: lines[i].trim() });
+      }
+    }
+  }
+  return hits;
+}
+
+/** Config keys across the code documents of a store. */
+export class ConfigUsageIndex {
+  private readonly files: FileScanCache<ConfigSite[]>;
+
+  constructor(config: IndexConfig) {
+    this.files = new FileScanCache(config, (source, meta) =>
+      scanConfigUsages(source, meta.file_path).map(
+        (hit): ConfigSite => ({ ...hit, doc_id: meta.doc_id, collection: meta.collection, file_path: meta.file_path })
+      )
+    );
+  }
+
+  /** Every site in the indexed code, optionally under a path prefix, in file and line order. */
+  async scan(store: DocumentStore, pathPrefix?: string): Promise<ConfigSite[]> {
+    const files = await this.files.scan(store, { pathPrefix, accept: (path) => !!RULES[extname(path).toLowerCase()] });
+    return files.flat().sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+  }
+
+  /**
+   * Sites grouped by key, keys with the most sites first. `key` is an
+   * exact name, or a prefix ending in "*"; flag names match with or
+   * without leading dashes.
+   */
+  async keys(
+    store: DocumentStore,
+    options: { key?: string; source?: ConfigSource; path?: string; include_tests?: IncludeTests } = {}
+  ): Promise<ConfigKey[]> {
+    const wanted = options.key?.replace(/^--?/, "");
+    const matches = (key: string) =>
+      wanted === undefined || (wanted.endsWith("*") ? key.startsWith(wanted.slice(0, -1)) : key === wanted);
+    const byKey = new Map<string, ConfigKey>();
+    for (const site of await this.scan(store, options.path)) {
+      if (!matches(site.key) || (options.source && site.source !== options.source)) continue;
+      if (!matchesTests(site.file_path, options.include_tests)) continue;
+      const id = `${site.source}\0${site.key}`;
+      let entry = byKey.get(id);
+      if (!entry) byKey.set(id, (entry = { key: site.key, source: site.source, definitions: [], reads: [] }));
+      (site.use === "define" ? entry.definitions : entry.reads).push(site);
+    }
+    const size = (k: ConfigKey) => k.definitions.length + k.reads.length;
+    return [...byKey.values()].sort((a, b) => size(b) - size(a) || a.key.localeCompare(b.key));
+  }
+}
@@ -0,0 +1,570 @@
+/**
+ * Runtime configuration — flags, environment, config file, defaults
+ *
+ * Every option can be set four ways. When more than one is set, the
+ * first in this list wins:
+ *
+ *   1. command-line flag   --docs-root ./docs
+ *   2. environment         DOCS_ROOT=./docs
+ *   3. config file         { "docs_root": "./docs" }  (treenav.config.json)
+ *   4. built-in default
+ *
+ * The option table below is the single source of truth: flag names are
+ * the kebab-case key, env names the upper-case key, config-file keys the
+ * snake_case key itself. The stdio server, the HTTP server, and the CLI
+ * subcommands all resolve through here, so an index built by
+ * `treenav-mcp index` has the same fingerprint the server computes.
+ *
+ * The config file is `--config <path>`, else $TREENAV_CONFIG, else
+ * ./treenav.config.json when it exists.
+ */
+
+import { existsSync } from "node:fs";
+import { join } from "node:path";
+import yargs from "yargs";
+import { DEFAULT_RANKING, singleRootConfig } from "./types";
+import { DEFAULT_LAZY_DEPTH } from "./lazy-index";
+import { DEFAULT_WATCH_BATCH_SIZE, DEFAULT_WATCH_DEBOUNCE_MS } from "./watcher";
+import { DEFAULT_SYMLINK_POLICY, SYMLINK_POLICIES } from "./walk";
+import { DEFAULT_VENDOR_POLICY, VENDOR_POLICIES } from "./vendor";
+import { DEFAULT_GENERATED_POLICY, GENERATED_POLICIES } from "./generated";
+import type { CommentMatching } from "./comments";
+import { EditorLinkError, editorTemplate } from "./editor-links";
+import { DEFAULT_RECENCY_HALF_LIFE_DAYS } from "./git-history";
+import { DEFAULT_MARKERS } from "./markers";
+import { DEFAULT_TOOL_TIMEOUT_MS, type ToolTimeouts } from "./deadline";
+import { DEFAULT_SHUTDOWN_GRACE_MS } from "./shutdown";
+import { DEFAULT_RESULT_CACHE_SIZE } from "./result-cache";
+import { rootPath } from "./paths";
+import { DEFAULT_RERANK_FORMAT, DEFAULT_RERANK_TIMEOUT_MS, DEFAULT_RERANK_TOP_K, RERANK_FORMATS } from "./reranker";
+import { INDEX_COMPRESSIONS, type IndexCompression } from "./index-blocks";
+import type { RerankerOptions, RerankFormat } from "./reranker";
+import type { PriorityOptions } from "./warmup";
+import type { GeneratedPolicy, IndexConfig, PathBoost, SymlinkPolicy, VendorPolicy } from "./types";
+import type { WikiOptions } from "./curator";
+
+export const DEFAULT_CONFIG_FILE = "treenav.config.json";
+
+type OptionType = "string" | "number" | "boolean" | "list";
+
+interface OptionSpec {
+  key: keyof ServeConfig;
+  type: OptionType;
+  default?: string | number | boolean | string[];
+  description: string;
+  /** Shell completion for the value: a path or a directory */
+  complete?: "file" | "dir";
+  /** Allowed values for a string option */
+  choices?: readonly string[];
+  /** Further checks on the coerced value; throws ConfigError */
+  validate?: (value: any, origin: string) => void;
+  /** Never printed by --print-config */
+  secret?: boolean;
+}
+
+/** Effective configuration after all sources are merged. */
+export interface ServeConfig {
+  docs_root: string;
+  docs_glob: string;
+  max_depth: number;
+  summary_length: number;
+  port: number;
+  glossary_path?: string;
+  synonyms: string[];
+  code_root?: string;
+  code_collection: string;
+  code_weight: number;
+  code_glob?: string;
+  code_roots: string[];
+  path_boosts: string[];
+  recency_weight: number;
+  recency_half_life_days: number;
+  reference_weight: number;
+  coverage_profile?: string;
+  coverage_weight: number;
+  max_per_file: number;
+  max_per_package: number;
+  byte_offsets: boolean;
+  markers: string[];
+  tree_sitter_grammars?: string;
+  goroot?: string;
+  index_dependencies: boolean;
+  gomodcache?: string;
+  structural_rewrite: boolean;
+  wiki_write: boolean;
+  wiki_root?: string;
+  wiki_duplicate_threshold: number;
+  admin_tools: boolean;
+  index_cache?: string;
+  index_compression: IndexCompression;
+  priority_files?: string;
+  priority_recent_commits: number;
+  parse_cache?: string;
+  lazy_index: boolean;
+  lazy_eager: string[];
+  lazy_depth: number;
+  shard_dir?: string;
+  shards: string[];
+  tenants_config?: string;
+  http_token?: string;
+  grpc_port?: number;
+  admin_port?: number;
+  profile_dir?: string;
+  rest_api: boolean;
+  web_ui: boolean;
+  watch: boolean;
+  watch_debounce_ms: number;
+  watch_batch_size: number;
+  stale_refresh: boolean;
+  result_cache_size: number;
+  license_tags: boolean;
+  tool_timeout_ms: number;
+  search_timeout_ms?: number;
+  graph_timeout_ms?: number;
+  git_timeout_ms?: number;
+  shutdown_grace_ms: number;
+  plugins: string[];
+  ranking_wasm?: string;
+  rerank_url?: string;
+  rerank_format: RerankFormat;
+  rerank_model?: string;
+  rerank_api_key?: string;
+  rerank_top_k: number;
+  rerank_timeout_ms: number;
+  query_log?: string;
+  editor_url?: string;
+  symlinks: SymlinkPolicy;
+  vendor_policy: VendorPolicy;
+  generated_policy: GeneratedPolicy;
+  comment_matching: Exclude<CommentMatching, "only">;
+  comment_languages: string[];
+  include: string[];
+}
+
+export type ConfigSource = "flag" | "env" | "file" | "default";
+
+export const CONFIG_OPTIONS: OptionSpec[] = [
+  { key: "docs_root", type: "string", default: "./docs", description: "Path to markdown repository root", complete: "dir" },
+  { key: "docs_glob", type: "string", default: "**/*.md", description: "File glob pattern for markdown" },
+  { key: "max_depth", type: "number", default: 6, description: "Max heading depth to index (1-6)" },
+  { key: "summary_length", type: "number", default: 200, description: "Characters in node summaries" },
+  { key: "port", type: "number", default: 3100, description: "HTTP server port (serve:http only)" },
+  { key: "glossary_path", type: "string", description: "Path to abbreviation glossary (default: $docs_root/glossary.json)", complete: "file" },
+  { key: "synonyms", type: "list", default: [], description: "Synonym groups for query expansion, terms joined by | (e.g. auth|authn,db|database)" },
+  { key: "code_root", type: "string", description: "Path to source code root; enables code indexing", complete: "dir" },
+  { key: "code_collection", type: "string", default: "code", description: "Name for the code collection" },
+  { key: "code_weight", type: "number", default: 1.0, description: "BM25 weight multiplier for code results" },
+  { key: "code_glob", type: "string", description: "Glob pattern for code files (default: all supported extensions)" },
+  { key: "code_roots", type: "list", default: [], description: "More code collections, name=path each (e.g. billing=../billing); references resolve across them", validate: (v, origin) => parseCodeRoots(v, origin) },
+  { key: "path_boosts", type: "list", default: [], description: "Ranking multipliers by path glob, pattern=weight (e.g. *.pb.go=0.5,internal/=1.5)", validate: (v, origin) => parsePathBoosts(v, origin) },
+  { key: "recency_weight", type: "number", default: 0, description: "Boost for recently committed files, from git history (0 = off; try 0.1-0.3)", validate: nonNegative },
+  { key: "recency_half_life_days", type: "number", default: DEFAULT_RECENCY_HALF_LIFE_DAYS, description: "Days after which a file's recency boost halves", validate: positive },
+  { key: "reference_weight", type: "number", default: DEFAULT_RANKING.reference_weight, description: "Boost for code symbols referenced from many files (0 = off)", validate: nonNegative },
+  { key: "coverage_profile", type: "string", description: "Go cover profile (go test -coverprofile) for coverage_for and \"needs tests\" ranking", complete: "file" },
+  { key: "coverage_weight", type: "number", default: DEFAULT_RANKING.coverage_weight, description: "Boost for uncovered functions in \"needs tests\" queries (0 = off)", validate: nonNegative },
+  { key: "max_per_file", type: "number", default: DEFAULT_RANKING.max_per_file, description: "Most search results from one file (0 = no cap; try 3 for exploration)", validate: count },
+  { key: "max_per_package", type: "number", default: DEFAULT_RANKING.max_per_package, description: "Most search results from one package directory (0 = no cap)", validate: count },
+  { key: "byte_offsets", type: "boolean", default: false, description: "Give search matches in every code file a byte range in the file, next to line and UTF-16 column" },
+  { key: "markers", type: "list", default: DEFAULT_MARKERS, description: "Comment markers list_markers looks for (e.g. TODO,FIXME,HACK)", validate: (v: string[], origin) => validateMarkers(v, origin) },
+  { key: "tree_sitter_grammars", type: "string", description: "Directory of tree-sitter-<language>.wasm grammars; enables ts_query (needs web-tree-sitter)", complete: "dir" },
+  { key: "goroot", type: "string", description: "Go installation whose standard library find_symbol and package_api resolve (default: go env GOROOT)", complete: "dir" },
+  { key: "index_dependencies", type: "boolean", default: false, description: "Also index direct Go dependencies from the module cache, read-only, as gomod/<module>@<version> collections" },
+  { key: "gomodcache", type: "string", description: "Go module cache for index_dependencies (default: go env GOMODCACHE)", complete: "dir" },
+  { key: "structural_rewrite", type: "boolean", default: false, description: "Enable structural_replace, which rewrites code files in place" },
+  { key: "include", type: "list", default: [], description: "Sparse indexing: only index files matching these globs (e.g. src/**,pkg/**)" },
+  { key: "symlinks", type: "string", default: DEFAULT_SYMLINK_POLICY, choices: SYMLINK_POLICIES, description: "Symlink policy for file discovery: skip, within-root, or all" },
+  { key: "vendor_policy", type: "string", default: DEFAULT_VENDOR_POLICY, choices: VENDOR_POLICIES, description: "vendor/ trees under the code root: index, downrank, or exclude" },
+  { key: "generated_policy", type: "string", default: DEFAULT_GENERATED_POLICY, choices: GENERATED_POLICIES, description: "Generated, minified, and lock files in search: index, downrank, or exclude" },
+  { key: "comment_matching", type: "string", default: "include", choices: ["include", "exclude"], description: "Comment text in code for full-text search: include or exclude (a search can still ask for it, or for comments only)" },
+  { key: "comment_languages", type: "list", default: [], description: "Count only code comments in these languages, ISO 639-1 (e.g. en,de); undetected ones always count", validate: (v: string[], origin) => validateLanguages(v, origin) },
+  { key: "wiki_write", type: "boolean", default: false, description: "Enable the wiki curation toolset" },
+  { key: "wiki_root", type: "string", description: "Root that curated entries must live under (default: docs_root)", complete: "dir" },
+  { key: "wiki_duplicate_threshold", type: "number", default: 0.35, description: "Overlap ratio above which writes require allow_duplicate" },
+  { key: "admin_tools", type: "boolean", default: false, description: "Enable reindex_path, clear_cache, and evict_file, which change the index for every session" },
+  { key: "index_cache", type: "string", description: "Persist the index here and warm-start from it", complete: "file" },
+  { key: "index_compression", type: "string", default: "none", choices: INDEX_COMPRESSIONS, description: "How index_cache and shard files are written: none (JSON) or zstd (compressed blocks); both are read" },
+  { key: "priority_files", type: "string", description: "On a cold start, index the paths listed here (one per line) first and serve while the rest is indexed", complete: "file" },
+  { key: "priority_recent_commits", type: "number", default: 0, description: "On a cold start, index files changed in this many recent commits first (0 = off)", validate: count },
+  { key: "parse_cache", type: "string", description: "Directory of parsed code symbols keyed by content hash, reused across branches and worktrees (see parse-cache.ts)", complete: "dir" },
+  { key: "lazy_index", type: "boolean", default: false, description: "Index lazily: skeleton plus lazy_eager regions at startup" },
+  { key: "lazy_eager", type: "list", default: [], description: "Path prefixes indexed at startup in lazy mode" },
+  { key: "lazy_depth", type: "number", default: DEFAULT_LAZY_DEPTH, description: "Leading directories that make up one lazy region" },
+  { key: "shard_dir", type: "string", description: "Load the index from per-top-level-directory shards here", complete: "dir" },
+  { key: "shards", type: "list", default: [], description: "Shard ids to load from shard_dir (default: all)" },
+  { key: "tenants_config", type: "string", description: "Tenants file for multi-tenant HTTP mode (serve:http only)", complete: "file" },
+  { key: "http_token", type: "string", secret: true, description: "Bearer token required on the MCP endpoints (serve:http, and sent by proxy)" },
+  { key: "grpc_port", type: "number", description: "Also serve the gRPC API (treenav.v1.Navigation) on this port (serve:http only)", validate: positive },
+  { key: "admin_port", type: "number", description: "Serve CPU and heap profiles at /debug/pprof/ on localhost:<port> (see profiler.ts)", validate: positive },
+  { key: "profile_dir", type: "string", description: "Directory capture_profile writes profiles to; enables the tool", complete: "dir" },
+  { key: "rest_api", type: "boolean", default: false, description: "Also answer GET /search, /symbol, /documents, /tree, /node as JSON (serve:http only)" },
+  { key: "web_ui", type: "boolean", default: false, description: "Serve a browser UI for the index at /ui; implies rest_api (serve:http only)" },
+  { key: "watch", type: "boolean", default: false, description: "Watch collection roots and re-index changed files" },
+  { key: "watch_debounce_ms", type: "number", default: DEFAULT_WATCH_DEBOUNCE_MS, description: "Quiet period before a batch of changes is applied" },
+  { key: "watch_batch_size", type: "number", default: DEFAULT_WATCH_BATCH_SIZE, description: "Changes above this per batch trigger one full re-validation" },
+  { key: "stale_refresh", type: "boolean", default: false, description: "Re-index files changed since indexing when they show up in search results" },
+  { key: "result_cache_size", type: "number", default: DEFAULT_RESULT_CACHE_SIZE, description: "Answers of the graph tools (callers, usage_stats, ...) kept until a code file changes (0 = off)", validate: count },
+  { key: "license_tags", type: "boolean", default: false, description: "Tag search hits, sections, and read_file excerpts with the license and provenance of their file" },
+  { key: "tool_timeout_ms", type: "number", default: DEFAULT_TOOL_TIMEOUT_MS, description: "Deadline for a tool call; past it, answers are flagged timed_out (0 = none)", validate: nonNegative },
+  { key: "search_timeout_ms", type: "number", description: "Deadline for search tools (default: tool_timeout_ms)", validate: nonNegative },
+  { key: "graph_timeout_ms", type: "number", description: "Deadline for module_info, package_api, usage_stats, find_references, find_cycles (default: tool_timeout_ms)", validate: nonNegative },
+  { key: "git_timeout_ms", type: "number", description: "Deadline for hotspots, ast_diff, list_markers, and ref reads (default: tool_timeout_ms)", validate: nonNegative },
+  { key: "shutdown_grace_ms", type: "number", default: DEFAULT_SHUTDOWN_GRACE_MS, description: "On SIGTERM, how long in-flight tool calls get to finish before they are cancelled", validate: nonNegative },
+  { key: "ranking_wasm", type: "string", description: "WebAssembly module that re-scores and filters search candidates (see wasm-ranking.ts)", complete: "file" },
+  { key: "rerank_url", type: "string", description: "Reranker endpoint that reorders search_documents' top candidates (see reranker.ts)" },
+  { key: "rerank_format", type: "string", default: DEFAULT_RERANK_FORMAT, choices: RERANK_FORMATS, description: "Reranker request format: cohere (/v2/rerank and compatibles) or tei (text-embeddings-inference)" },
+  { key: "rerank_model", type: "string", description: "Model name sent to a cohere-format reranker (e.g. rerank-v3.5)" },
+  { key: "rerank_api_key", type: "string", secret: true, description: "Bearer token sent to the reranker" },
+  { key: "rerank_top_k", type: "number", default: DEFAULT_RERANK_TOP_K, description: "Candidates sent to the reranker per search", validate: positive },
+  { key: "rerank_timeout_ms", type: "number", default: DEFAULT_RERANK_TIMEOUT_MS, description: "Latency budget for the reranker; past it, the keyword ranking is kept", validate: positive },
+  { key: "query_log", type: "string", description: "Append searches, the results read after them, and feedback to this JSON Lines file (see query-log.ts)", complete: "file" },
+  { key: "editor_url", type: "string", description: "Add an editor_url link next to every result uri: an editor (vscode, cursor, zed, sublime, textmate, jetbrains, idea) or a template such as myeditor://open?file={file}&line={line}", validate: (v: string, origin) => validateEditorUrl(v, origin) },
+  { key: "plugins", type: "list", default: [], description: "Modules that add tools answering from the index (e.g. ./plugins/flags.ts)" },
+];
+
+export class ConfigError extends Error {}
+
+function nonNegative(value: number, origin: string): void {
+  if (value < 0) throw new ConfigError(`${origin}: expected a number >= 0, got ${value}`);
+}
+
+function count(value: number, origin: string): void {
+  if (!Number.isInteger(value) || value < 0) throw new ConfigError(`${origin}: expected a whole number >= 0, got ${value}`);
+}
+
+function validateMarkers(markers: string[], origin: string): void {
+  const bad = markers.find((m) => !/^[\p{L}\p{N}_-]+$/u.test(m));
+  if (bad !== undefined) throw new ConfigError(`${origin}: marker "${bad}" must be a single word`);
+}
+
+function validateLanguages(languages: string[], origin: string): void {
+  const bad = languages.find((l) => !/^[a-z]{2}$/i.test(l));
+  if (bad !== undefined) throw new ConfigError(`${origin}: "${bad}" is not a two-letter language code`);
+}
+
+function validateEditorUrl(spec: string, origin: string): void {
+  try {
+    editorTemplate(spec);
+  } catch (err) {
+    if (err instanceof EditorLinkError) throw new ConfigError(`${origin}: ${err.message}`);
+    throw err;
+  }
+}
+
+function positive(value: number, origin: string): void {
+  if (value <= 0) throw new ConfigError(`${origin}: expected a number > 0, got ${value}`);
+}
+
+export function flagName(key: string): string {
+  return key.replace(/_/g, "-");
+}
+
+export function envName(key: string): string {
+  return key.toUpperCase();
+}
+
+/** Flags that never take a value (boolean options and CLI switches). */
+export const BOOLEAN_FLAGS = [
+  ...CONFIG_OPTIONS.filter((o) => o.type === "boolean").map((o) => flagName(o.key)),
+  "print-config",
+];
+
+// ── argv parsing ─────────────────────────────────────────────────────
+
+export interface ParsedArgs {
+  positionals: string[];
+  flags: Record<string, string | boolean>;
+}
+
+/**
+ * yargs settings for every treenav-mcp command: flags keep their
+ * kebab-case names and string values (coerce() types them against
+ * CONFIG_OPTIONS), and a repeated flag keeps its last value.
+ */
+export const PARSER_CONFIGURATION = {
+  "camel-case-expansion": false,
+  "dot-notation": false,
+  "parse-numbers": false,
+  "parse-positional-numbers": false,
+  "duplicate-arguments-array": false,
+  "boolean-negation": false,
+};
+
+/**
+ * Parse argv with yargs: `--name value` and `--name=value`, with the
+ * flags in `switches` as booleans; everything else is positional.
+ */
+export function parseArgs(argv: string[], switches: string[] = []): ParsedArgs {
+  const { _, $0, ...parsed } = yargs(argv)
+    .parserConfiguration(PARSER_CONFIGURATION)
+    .boolean(switches)
+    .help(false)
+    .version(false)
+    .parseSync();
+  const flags: ParsedArgs["flags"] = {};
+  for (const [name, value] of Object.entries(parsed)) {
+    if (value !== undefined) flags[name] = typeof value === "boolean" ? value : String(value);
+  }
+  return { positionals: _.map(String), flags };
+}
+
+// ── Resolution ───────────────────────────────────────────────────────
+
+function coerce(spec: OptionSpec, raw: unknown, origin: string): unknown {
+  const value = coerceType(spec, raw, origin);
+  spec.validate?.(value, origin);
+  return value;
+}
+
+function coerceType(spec: OptionSpec, raw: unknown, origin: string): unknown {
+  switch (spec.type) {
+    case "string":
+      if (typeof raw !== "string") throw new ConfigError(`${origin}: expected a string`);
+      if (spec.choices && !spec.choices.includes(raw)) {
+        throw new ConfigError(`${origin}: expected one of ${spec.choices.join(", ")}, got ${JSON.stringify(raw)}`);
+      }
+      return raw;
+    case "number": {
+      const n = typeof raw === "number" ? raw : parseFloat(String(raw));
+      if (typeof raw === "boolean" || Number.isNaN(n)) {
+        throw new ConfigError(`${origin}: expected a number, got ${JSON.stringify(raw)}`);
+      }
+      return n;
+    }
+    case "boolean":
+      if (typeof raw === "boolean") return raw;
+      if (/^(1|true|yes|on)$/i.test(String(raw))) return true;
+      if (/^(0|false|no|off|)$/i.test(String(raw))) return false;
+      throw new ConfigError(`${origin}: expected a boolean, got ${JSON.stringify(raw)}`);
+    case "list":
+      if (Array.isArray(raw)) return raw.map(String).filter(Boolean);
+      if (typeof raw === "string") return raw.split(",").map((s) => s.trim()).filter(Boolean);
+      throw new ConfigError(`${origin}: expected a list or comma-separated string`);
+  }
+}
+
+/**
+ * Merge flag > env > file > default. Returns the effective config and,
+ * for each key, which source it came from.
+ */
+export function resolveConfig(sources: {
+  flags?: ParsedArgs["flags"];
+  env?: Record<string, string | undefined>;
+  file?: Record<string, unknown>;
+}): { config: ServeConfig; sources: Record<keyof ServeConfig, ConfigSource> } {
+  const flags = sources.flags ?? {};
+  const env = sources.env ?? {};
+  const file = sources.file ?? {};
+
+  const known = new Set<string>(CONFIG_OPTIONS.map((o) => o.key));
+  for (const key of Object.keys(file)) {
+    if (!known.has(key)) throw new ConfigError(`config file: unknown option "${key}"`);
+  }
+
+  const config: Record<string, unknown> = {};
+  const origin: Record<string, ConfigSource> = {};
+
+  for (const spec of CONFIG_OPTIONS) {
+    const flag = flags[flagName(spec.key)];
+    const envValue = env[envName(spec.key)];
+
+    if (flag !== undefined) {
+      config[spec.key] = coerce(spec, typeof flag === "boolean" ? String(flag) : flag, `--${flagName(spec.key)}`);
+      origin[spec.key] = "flag";
+    } else if (envValue !== undefined && envValue !== "") {
+      config[spec.key] = coerce(spec, envValue, envName(spec.key));
+      origin[spec.key] = "env";
+    } else if (file[spec.key] !== undefined) {
+      config[spec.key] = coerce(spec, file[spec.key], `config file "${spec.key}"`);
+      origin[spec.key] = "file";
+    } else {
+      config[spec.key] = Array.isArray(spec.default) ? [...spec.default] : spec.default;
+      origin[spec.key] = "default";
+    }
+  }
+
+  // CODE_ROOTS names the collections beside docs and CODE_COLLECTION
+  const taken = new Set(["docs", ...(config.code_root ? [config.code_collection as string] : [])]);
+  for (const { name } of parseCodeRoots(config.code_roots as string[])) {
+    if (taken.has(name)) throw new ConfigError(`${envName("code_roots")}: collection "${name}" is already defined`);
+  }
+
+  return {
+    config: config as unknown as ServeConfig,
+    sources: origin as Record<keyof ServeConfig, ConfigSource>,
+  };
+}
+
+/**
+ * Read the config file named by --config / $TREENAV_CONFIG, or
+ * ./treenav.config.json when present. Returns {} when there is none.
+ */
+export async function readConfigFile(
+  flags: ParsedArgs["flags"],
+  env: Record<string, string | undefined>,
+  cwd: string = process.cwd()
+): Promise<{ path: string | null; values: Record<string, unknown> }> {
+  const explicit = typeof flags.config === "string" ? flags.config : env.TREENAV_CONFIG;
+  const path = explicit || join(cwd, DEFAULT_CONFIG_FILE);
+
+  if (!existsSync(path)) {
+    if (explicit) throw new ConfigError(`config file not found: ${path}`);
+    return { path: null, values: {} };
+  }
+
+  let values: unknown;
+  try {
+    values = await Bun.file(path).json();
+  } catch (err: any) {
+    throw new ConfigError(`config file ${path}: ${err.message}`);
+  }
+  if (!values || typeof values !== "object" || Array.isArray(values)) {
+    throw new ConfigError(`config file ${path}: expected a JSON object`);
+  }
+  return { path, values: values as Record<string, unknown> };
+}
+
+/** Parse argv, read the config file, and resolve — the servers' entry point. */
+export async function loadConfig(
+  argv: string[],
+  env: Record<string, string | undefined> = process.env
+): Promise<{ config: ServeConfig; sources: Record<keyof ServeConfig, ConfigSource>; file: string | null; args: ParsedArgs }> {
+  const args = parseArgs(argv, BOOLEAN_FLAGS);
+  const file = await readConfigFile(args.flags, env);
+  const resolved = resolveConfig({ flags: args.flags, env, file: file.values });
+  return { ...resolved, file: file.path, args };
+}
+
+/** JSON dump for --print-config: each option with its value and source. */
+export function formatConfig(
+  config: ServeConfig,
+  sources: Record<keyof ServeConfig, ConfigSource>,
+  file: string | null
+): string {
+  const options: Record<string, { value: unknown; source: ConfigSource }> = {};
+  for (const spec of CONFIG_OPTIONS) {
+    const value = config[spec.key] ?? null;
+    options[spec.key] = { value: spec.secret && value !== null ? "(set)" : value, source: sources[spec.key] };
+  }
+  return JSON.stringify({ config_file: file, options }, null, 2);
+}
+
+/**
+ * Split SYNONYMS entries into groups. An entry is "auth|authn" (as in
+ * the env var) or, in a config or tenants file, an array of terms;
+ * groups with fewer than two terms are dropped.
+ */
+export function parseSynonymGroups(entries: (string | string[])[]): string[][] {
+  return entries
+    .map((entry) => (Array.isArray(entry) ? entry : entry.split(/[|,]/)).map((t) => t.trim()).filter(Boolean))
+    .filter((group) => group.length >= 2);
+}
+
+/**
+ * Parse PATH_BOOSTS entries of the form "pattern=weight". Throws
+ * ConfigError on an entry without "=" or with a weight that is not a
+ * positive number.
+ */
+export function parsePathBoosts(entries: string[], origin: string = envName("path_boosts")): PathBoost[] {
+  return entries.map((entry) => {
+    const eq = entry.lastIndexOf("=");
+    const pattern = entry.slice(0, eq).trim();
+    const weight = Number(entry.slice(eq + 1).trim());
+    if (eq === -1 || !pattern || entry.slice(eq + 1).trim() === "" || !Number.isFinite(weight) || weight <= 0) {
+      throw new ConfigError(`${origin}: expected pattern=weight with a positive weight, got ${JSON.stringify(entry)}`);
+    }
+    return { pattern, weight };
+  });
+}
+
+/**
+ * Parse CODE_ROOTS entries of the form "name=path". Throws ConfigError
+ * on an entry without "=", a name that is not a plain word (letters,
+ * digits, ".", "_", "-"), or a name given twice.
+ */
+export function parseCodeRoots(entries: string[], origin: string = envName("code_roots")): { name: string; root: string }[] {
+  const seen = new Set<string>();
+  return entries.map((entry) => {
+    const eq = entry.indexOf("=");
+    const name = entry.slice(0, eq).trim();
+    const root = entry.slice(eq + 1).trim();
+    if (eq === -1 || !/^[\w.-]+$/.test(name) || !root) {
+      throw new ConfigError(`${origin}: expected name=path, got ${JSON.stringify(entry)}`);
+    }
+    if (seen.has(name)) throw new ConfigError(`${origin}: collection "${name}" is given twice`);
+    seen.add(name);
+    return { name, root };
+  });
+}
+
+// ── IndexConfig ──────────────────────────────────────────────────────
+
+/**
+ * Build the IndexConfig for the docs and the (optional) code
+ * collections: CODE_ROOT, then each of CODE_ROOTS with the same glob,
+ * weight, and policies. Roots are resolved once here, long-path
+ * prefixes dropped (paths.ts).
+ */
+export function toIndexConfig(config: ServeConfig): IndexConfig {
+  const index: IndexConfig = singleRootConfig(rootPath(config.docs_root));
+  index.collections[0].glob_pattern = config.docs_glob;
+  index.collections[0].symlinks = config.symlinks;
+  index.collections[0].include = config.include;
+  index.max_depth = config.max_depth;
+  index.summary_length = config.summary_length;
+
+  const code = [
+    ...(config.code_root ? [{ name: config.code_collection, root: config.code_root }] : []),
+    ...parseCodeRoots(config.code_roots),
+  ];
+  if (code.length > 0) {
+    index.code_collections = code.map(({ name, root }) => ({
+      name,
+      root: rootPath(root),
+      weight: config.code_weight,
+      glob_pattern: config.code_glob,
+      symlinks: config.symlinks,
+      include: config.include,
+      vendor: config.vendor_policy,
+    }));
+  }
+  return index;
+}
+
+/** Curation tool options when WIKI_WRITE is on, else undefined. */
+export function toWikiOptions(config: ServeConfig): WikiOptions | undefined {
+  if (!config.wiki_write) return undefined;
+  return {
+    root: rootPath(config.wiki_root || config.docs_root),
+    collectionName: "docs",
+    duplicateThreshold: config.wiki_duplicate_threshold,
+  };
+}
+
+/** Reranker options when RERANK_URL is set, else undefined. */
+export function toRerankerOptions(config: ServeConfig): RerankerOptions | undefined {
+  if (!config.rerank_url) return undefined;
+  return {
+    url: config.rerank_url,
+    format: config.rerank_format,
+    model: config.rerank_model,
+    api_key: config.rerank_api_key,
+    top_k: config.rerank_top_k,
+    timeout_ms: config.rerank_timeout_ms,
+  };
+}
+
+/** The cold-start priority list when PRIORITY_FILES or PRIORITY_RECENT_COMMITS is set, else undefined. */
+export function toPriorityOptions(config: ServeConfig): PriorityOptions | undefined {
+  if (!config.priority_files && !config.priority_recent_commits) return undefined;
+  return { manifest: config.priority_files, recent_commits: config.priority_recent_commits };
+}
+
+/** Tool deadlines from TOOL_TIMEOUT_MS and the per-category overrides. */
+export function toToolTimeouts(config: ServeConfig): ToolTimeouts {
+  return {
+    default: config.tool_timeout_ms,
+    ...(config.search_timeout_ms !== undefined ? { search: config.search_timeout_ms } : {}),
+    ...(config.graph_timeout_ms !== undefined ? { graph: config.graph_timeout_ms } : {}),
+    ...(config.git_timeout_ms !== undefined ? { git: config.git_timeout_ms } : {}),
+  };
+}
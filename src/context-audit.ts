@@ -0,0 +1,358 @@
+/**
+ * Context propagation audit — the context_audit tool
+ *
+ * Go functions that accept a context.Context are expected to hand it to
+ * whatever they call that takes one. For each such function (exported
+ * ones by default) the audit reports:
+ *
+ *   background           context.Background() or context.TODO() in a
+ *                        body that already has a context
+ *   dropped              a call to an indexed function whose first
+ *                        parameter is a context.Context, passed
+ *                        something other than the function's context
+ *                        or one derived from it
+ *   non_context_variant  a call with a context-taking sibling:
+ *                        http.NewRequest → NewRequestWithContext,
+ *                        exec.Command → CommandContext, db.Query →
+ *                        QueryContext, ...
+ *   unused               the context is never mentioned in a body that
+ *                        calls other functions (often an interface
+ *                        method that does not need it)
+ *
+ * A context is "derived" when it is assigned from an expression that
+ * mentions one already known: ctx, cancel := context.WithTimeout(ctx, d).
+ * r.Context() counts as a context too. Callees are matched by name:
+ * `pkg.Func(` and bare `Func(` against top-level functions, `x.Method(`
+ * against methods, and only when every indexed function of that name
+ * takes a context first, so an unrelated namesake cannot raise a false
+ * "dropped". Matching is lexical over blanked source; nothing is type
+ * checked.
+ */
+
+import type { DocumentMeta, IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols, type CodeSymbol } from "./code-indexer";
+import { blankLiterals } from "./structural";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+import { goImports } from "./usage";
+
+export type ContextFindingKind = "background" | "dropped" | "non_context_variant" | "unused";
+
+export const CONTEXT_FINDING_KINDS: ContextFindingKind[] = ["background", "dropped", "non_context_variant", "unused"];
+
+export interface ContextFinding {
+  kind: ContextFindingKind;
+  /** The function that has the context: Manager.Join */
+  function: string;
+  exported: boolean;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  text: string;
+  /** dropped / non_context_variant: the call, as written */
+  callee?: string;
+  /** dropped: the first argument passed instead */
+  passed?: string;
+  /** non_context_variant: what to call instead */
+  suggestion?: string;
+}
+
+export interface ContextAuditReport {
+  /** Go files scanned */
+  files: number;
+  /** Functions checked: those taking a context.Context, exported only unless asked */
+  functions: number;
+  /** Functions with at least one finding */
+  flagged: number;
+  by_kind: Record<ContextFindingKind, number>;
+  /** In file and line order */
+  findings: ContextFinding[];
+}
+
+export interface ContextAuditOptions {
+  /** Only functions in files under this path prefix */
+  path?: string;
+  /** Check unexported functions too (default false) */
+  include_unexported?: boolean;
+  include_tests?: IncludeTests;
+}
+
+/** A Go function or method, with what the audit needs of its signature. */
+interface GoFunction {
+  name: string;
+  /** Receiver type's name for methods */
+  owner?: string;
+  exported: boolean;
+  line_start: number;
+  line_end: number;
+  /** The context.Context parameter's name; "_" when unnamed; absent when none */
+  context?: string;
+  /** First line of the body, after the signature */
+  body_start: number;
+}
+
+interface ScannedFile {
+  doc: DocumentMeta;
+  lines: string[];
+  blanked: string[];
+  functions: GoFunction[];
+  imports: Map<string, string>;
+}
+
+/** Calls with a context-taking sibling. Keys are `pkg.Func` for imports, `.Method` for methods. */
+const CONTEXT_VARIANTS: Record<string, { suggestion: string; imports?: string[] }> = {
+  "http.NewRequest": { suggestion: "http.NewRequestWithContext" },
+  "http.Get": { suggestion: "http.NewRequestWithContext + Client.Do" },
+  "http.Post": { suggestion: "http.NewRequestWithContext + Client.Do" },
+  "exec.Command": { suggestion: "exec.CommandContext" },
+  "net.Dial": { suggestion: "(&net.Dialer{}).DialContext" },
+  "net.DialTimeout": { suggestion: "(&net.Dialer{Timeout: d}).DialContext" },
+  ".Query": { suggestion: "QueryContext", imports: ["database/sql", "github.com/jmoiron/sqlx"] },
+  ".QueryRow": { suggestion: "QueryRowContext", imports: ["database/sql", "github.com/jmoiron/sqlx"] },
+  ".Exec": { suggestion: "ExecContext", imports: ["database/sql", "github.com/jmoiron/sqlx"] },
+  ".Prepare": { suggestion: "PrepareContext", imports: ["database/sql", "github.com/jmoiron/sqlx"] },
+  ".Begin": { suggestion: "BeginTx", imports: ["database/sql", "github.com/jmoiron/sqlx"] },
+  ".Ping": { suggestion: "PingContext", imports: ["database/sql", "github.com/jmoiron/sqlx"] },
+};
+
+const NAME = String.raw`[\p{L}_][\p{L}\p{N}_]*`;
+const CALL = new RegExp(String.raw`(?:(${NAME})\s*\.\s*)?(${NAME})\s*\(`, "gu");
+const BACKGROUND = /\bcontext\s*\.\s*(?:Background|TODO)\s*\(\s*\)/;
+/** Keywords and builtins that look like calls */
+const KEYWORDS = new Set([
+  "func", "if", "for", "switch", "return", "go", "defer", "select", "range",
+  "make", "new", "len", "cap", "append", "panic", "recover", "copy", "delete", "close", "print", "println", "min", "max", "clear",
+]);
+
+/** The text inside the parentheses that open at `from` (an index of "("), across lines. */
+function parenthesized(text: string, from: number): { inner: string; end: number } | null {
+  let depth = 0;
+  for (let i = from; i < text.length; i++) {
+    const c = text[i];
+    if (c === "(" || c === "[" || c === "{") depth++;
+    else if (c === ")" || c === "]" || c === "}") {
+      depth--;
+      if (depth === 0) return { inner: text.slice(from + 1, i), end: i + 1 };
+    }
+  }
+  return null;
+}
+
+/** Split on commas outside brackets. */
+function topLevelCommas(text: string): string[] {
+  const parts: string[] = [];
+  let depth = 0;
+  let start = 0;
+  for (let i = 0; i < text.length; i++) {
+    const c = text[i];
+    if ("([{".includes(c)) depth++;
+    else if (")]}".includes(c)) depth--;
+    else if (c === "," && depth === 0) {
+      parts.push(text.slice(start, i));
+      start = i + 1;
+    }
+  }
+  parts.push(text.slice(start));
+  return parts.map((p) => p.trim()).filter(Boolean);
+}
+
+/**
+ * The parameter list of the func declared at `line` (1-based) of the
+ * blanked source, and the line its body opens on.
+ */
+export function goSignature(blanked: string[], line: number): { params: string[]; body: number } | null {
+  const text = blanked.slice(line - 1, line + 19).join("\n");
+  const func = text.match(/^\s*func\s*/);
+  if (!func) return null;
+  let at = func[0].length;
+  if (text[at] === "(") {
+    const receiver = parenthesized(text, at);
+    if (!receiver) return null;
+    at = receiver.end;
+  }
+  const open = text.indexOf("(", at);
+  if (open < 0) return null;
+  // Type parameters: func Map[T any](...)
+  const bracket = text.indexOf("[", at);
+  const start = bracket >= 0 && bracket < open ? text.indexOf("(", parenthesized(text, bracket)?.end ?? open) : open;
+  const params = parenthesized(text, start);
+  if (!params) return null;
+  const brace = text.indexOf("{", params.end);
+  const body = brace < 0 ? line : line + text.slice(0, brace).split("\n").length - 1;
+  return { params: topLevelCommas(params.inner), body };
+}
+
+/** The name of the context.Context parameter, "_" when unnamed, or undefined when there is none. */
+export function contextParam(params: string[]): string | undefined {
+  for (const p of params) {
+    const m = p.match(new RegExp(String.raw`^(?:(${NAME})\s+)?context\s*\.\s*Context$`, "u"));
+    if (m) return m[1] ?? "_";
+  }
+  return undefined;
+}
+
+/** Audits context propagation over the Go files of a store's code collections. */
+export class ContextAudit {
+  private readonly cache: FileScanCache<ScannedFile>;
+
+  constructor(config: IndexConfig) {
+    this.cache = new FileScanCache(config, (source, meta) => {
+      const blanked = blankLiterals(source, false).split("\n");
+      return {
+        doc: meta,
+        lines: source.split("\n"),
+        blanked,
+        functions: goFunctions(parseCodeSymbols(source, meta.file_path), blanked),
+        imports: goImports(source),
+      };
+    });
+  }
+
+  async audit(store: DocumentStore, options: ContextAuditOptions = {}): Promise<ContextAuditReport> {
+    const all = await this.files(store);
+    // Callees known to take a context first: every function by that name must
+    const takes = new Map<string, boolean>();
+    for (const f of all) {
+      for (const fn of f.functions) {
+        const key = fn.owner ? `.${fn.name}` : fn.name;
+        takes.set(key, (takes.get(key) ?? true) && firstIsContext(f.blanked, fn));
+      }
+    }
+
+    const prefix = options.path?.replace(/^\.?\/+/, "");
+    const findings: ContextFinding[] = [];
+    let functions = 0;
+    const flagged = new Set<string>();
+    const files = all.filter((f) => (!prefix || f.doc.file_path.startsWith(prefix)) && matchesTests(f.doc.file_path, options.include_tests));
+    for (const file of files) {
+      for (const fn of file.functions) {
+        if (!fn.context || (!fn.exported && !options.include_unexported)) continue;
+        functions++;
+        const qualified = fn.owner ? `${fn.owner}.${fn.name}` : fn.name;
+        const found = this.check(file, fn, takes).map(
+          (f): ContextFinding => ({
+            ...f,
+            function: qualified,
+            exported: fn.exported,
+            doc_id: file.doc.doc_id,
+            file_path: file.doc.file_path,
+          })
+        );
+        if (found.length) flagged.add(`${file.doc.doc_id}\0${fn.line_start}`);
+        findings.push(...found);
+      }
+    }
+
+    const by_kind = Object.fromEntries(CONTEXT_FINDING_KINDS.map((k) => [k, 0])) as Record<ContextFindingKind, number>;
+    for (const f of findings) by_kind[f.kind]++;
+    return {
+      files: files.length,
+      functions,
+      flagged: flagged.size,
+      by_kind,
+      findings: findings.sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line),
+    };
+  }
+
+  /** The findings in one function's body. */
+  private check(
+    file: ScannedFile,
+    fn: GoFunction,
+    takes: Map<string, boolean>
+  ): Array<Pick<ContextFinding, "kind" | "line" | "text" | "callee" | "passed" | "suggestion">> {
+    const out: Array<Pick<ContextFinding, "kind" | "line" | "text" | "callee" | "passed" | "suggestion">> = [];
+    const contexts = new Set(fn.context && fn.context !== "_" ? [fn.context] : []);
+    const mentions = (text: string) =>
+      /\.\s*Context\s*\(\s*\)/.test(text) || [...contexts].some((c) => new RegExp(String.raw`(?<![\p{L}\p{N}_.])${c}(?![\p{L}\p{N}_])`, "u").test(text));
+    const imported = [...file.imports.values()];
+    const body = file.blanked.slice(fn.body_start - 1, fn.line_end);
+    let calls = 0;
+    let used = false;
+
+    for (let k = 0; k < body.length; k++) {
+      const i = fn.body_start - 1 + k;
+      const line = k === 0 ? body[k].slice(body[k].indexOf("{") + 1) : body[k];
+      const text = file.lines[i].trim();
+      if (mentions(line)) used = true;
+
+      // tctx, cancel := context.WithTimeout(ctx, d) — a derived context; passing it counts
+      const assign = line.match(new RegExp(String.raw`^\s*(${NAME})(?:\s*,\s*${NAME})*\s*:?=\s*(.+)$`, "u"));
+      if (assign && mentions(assign[2]) && (/Context\s*\(|\bcontext\s*\.\s*With/.test(assign[2]) || /ctx|context/i.test(assign[1]))) {
+        contexts.add(assign[1]);
+      }
+
+      const background = BACKGROUND.test(line);
+      if (background) out.push({ kind: "background", line: i + 1, text });
+
+      for (const m of line.matchAll(CALL)) {
+        const [, qualifier, name] = m;
+        if (KEYWORDS.has(name) && !qualifier) continue;
+        calls++;
+
+        const pkg = qualifier ? file.imports.get(qualifier) : undefined;
+        const variant = !qualifier ? undefined : pkg ? CONTEXT_VARIANTS[`${pkg.split("/").pop()}.${name}`] : CONTEXT_VARIANTS[`.${name}`];
+        if (variant && (!variant.imports || variant.imports.some((p) => imported.includes(p)))) {
+          out.push({ kind: "non_context_variant", line: i + 1, text, callee: `${qualifier}.${name}`, suggestion: variant.suggestion });
+          continue;
+        }
+
+        // The callee: pkg.Func and bare Func against functions, x.Method against methods
+        const key = qualifier && !pkg ? `.${name}` : name;
+        if (!takes.get(key)) continue;
+        const rest = [line.slice(m.index!), ...file.blanked.slice(i + 1, i + 6)].join("\n");
+        const args = parenthesized(rest, rest.indexOf("("));
+        if (!args) continue;
+        const first = topLevelCommas(args.inner)[0] ?? "";
+        if (mentions(first) || BACKGROUND.test(first)) continue;
+        out.push({
+          kind: "dropped",
+          line: i + 1,
+          text,
+          callee: qualifier ? `${qualifier}.${name}` : name,
+          passed: first.replace(/\s+/g, " ") || "(nothing)",
+        });
+      }
+    }
+    if (!used && calls > 0 && !out.some((f) => f.kind === "background")) {
+      out.unshift({ kind: "unused", line: fn.line_start, text: file.lines[fn.line_start - 1].trim() });
+    }
+    return out;
+  }
+
+  /** Scanned Go files of the store. */
+  private async files(store: DocumentStore): Promise<ScannedFile[]> {
+    const files = await this.cache.scan(store, { accept: (path) => path.endsWith(".go") });
+    return files.sort((a, b) => a.doc.file_path.localeCompare(b.doc.file_path));
+  }
+}
+
+/** The functions and methods of a Go file, with their context parameter. */
+function goFunctions(symbols: CodeSymbol[], blanked: string[]): GoFunction[] {
+  const byId = new Map(symbols.map((s): [string, CodeSymbol] => [s.id, s]));
+  const out: GoFunction[] = [];
+  for (const s of symbols) {
+    if (s.kind !== "function" && s.kind !== "method") continue;
+    const signature = goSignature(blanked, s.line_start);
+    if (!signature) continue;
+    const receiver = blanked[s.line_start - 1].match(/^\s*func\s*\(\s*(?:\w+\s+)?\*?\s*(\w+)/)?.[1];
+    const owner = (s.parent_id ? byId.get(s.parent_id)?.name : undefined) ?? receiver;
+    const context = contextParam(signature.params);
+    out.push({
+      name: s.name,
+      ...(owner ? { owner } : {}),
+      exported: /^\p{Lu}/u.test(s.name),
+      line_start: s.line_start,
+      line_end: s.line_end,
+      ...(context ? { context } : {}),
+      body_start: signature.body,
+    });
+  }
+  return out;
+}
+
+/** Whether the function's first parameter is a context.Context. */
+function firstIsContext(blanked: string[], fn: GoFunction): boolean {
+  const first = goSignature(blanked, fn.line_start)?.params[0];
+  return !!first && /(?:^|\s)context\s*\.\s*Context$/.test(first);
+}
@@ -0,0 +1,127 @@
+/**
+ * Sparse indexing — INCLUDE patterns and "not indexed" answers
+ *
+ * On a huge repository it is often enough to index `src/**` and
+ * `pkg/**`. INCLUDE lists glob patterns, relative to each collection
+ * root, that a file must match, on top of the collection glob, to be
+ * indexed. File discovery never descends into directories that no
+ * pattern can match, so the excluded part of the tree costs nothing.
+ *
+ * A sparse index has a blind spot that an empty result hides: "no
+ * match" and "never looked" read the same to an agent. IndexCoverage
+ * lets the tools tell them apart, so a doc_id or focus outside the
+ * included set gets an explicit "not indexed" status naming the
+ * patterns instead of a bare "not found" or zero results.
+ */
+
+import type { CollectionConfig, IndexConfig } from "./types";
+import { isVendorPath } from "./vendor";
+
+const GLOB_CHARS = /[*?[{]/;
+
+/**
+ * Normalize user patterns: drop a leading "./", and let a bare
+ * directory (`src`, `src/`) mean everything beneath it.
+ */
+export function normalizeIncludes(patterns: string[]): string[] {
+  const out: string[] = [];
+  for (const raw of patterns) {
+    const p = raw.trim().replace(/\\/g, "/").replace(/^\.\//, "");
+    if (!p) continue;
+    if (p.endsWith("/")) out.push(`${p}**`);
+    else if (!GLOB_CHARS.test(p)) out.push(p, `${p}/**`);
+    else out.push(p);
+  }
+  return out;
+}
+
+/**
+ * True when `relPath` ("/"-separated) is in the include set; always
+ * true for an empty set. Files in vendor/ trees are out when the
+ * collection excludes them (VENDOR_POLICY=exclude).
+ */
+export function isIncluded(collection: CollectionConfig, relPath: string): boolean {
+  if (collection.vendor === "exclude" && isVendorPath(relPath)) return false;
+  const include = collection.include ?? [];
+  if (include.length === 0) return true;
+  return normalizeIncludes(include).some((p) => new Bun.Glob(p).match(relPath));
+}
+
+/**
+ * Could any include pattern match a file under `relDir`? Compares the
+ * directory with each pattern's literal leading segments; a pattern
+ * that starts with a wildcard can match anywhere.
+ */
+export function mayContainIncluded(collection: CollectionConfig, relDir: string): boolean {
+  if (collection.vendor === "exclude" && isVendorPath(relDir)) return false;
+  const include = collection.include ?? [];
+  if (include.length === 0 || relDir === "") return true;
+  const dir = relDir.split("/");
+  return normalizeIncludes(include).some((p) => {
+    const literal: string[] = [];
+    for (const segment of p.split("/")) {
+      if (GLOB_CHARS.test(segment)) break;
+      literal.push(segment);
+    }
+    const n = Math.min(literal.length, dir.length);
+    for (let i = 0; i < n; i++) {
+      if (literal[i] !== dir[i]) return false;
+    }
+    return true;
+  });
+}
+
+export class IndexCoverage {
+  private readonly collections: CollectionConfig[];
+  private readonly codeCollections: Set<CollectionConfig>;
+
+  constructor(config: IndexConfig) {
+    this.collections = [...config.collections, ...(config.code_collections ?? [])];
+    this.codeCollections = new Set(config.code_collections ?? []);
+  }
+
+  isSparse(): boolean {
+    return this.collections.some((c) => (c.include ?? []).length > 0);
+  }
+
+  /** One-line note for empty results on a sparse index, or "". */
+  describe(): string {
+    if (!this.isSparse()) return "";
+    const patterns = [...new Set(this.collections.flatMap((c) => c.include ?? []))];
+    return `Sparse index: only files matching ${patterns.join(", ")} are indexed.`;
+  }
+
+  /**
+   * Status text when `doc_id` names a file outside the include set,
+   * or null when it is (or could be) indexed. The file path is
+   * recovered from the doc_id shape, so this works for files that
+   * were never read.
+   */
+  excludedDocId(doc_id: string): string | null {
+    const sep = doc_id.indexOf(":");
+    if (sep === -1) return null;
+    const collection = this.collections.find((c) => c.name === doc_id.slice(0, sep));
+    const vendorExcluded = collection?.vendor === "exclude";
+    if (!collection || ((collection.include ?? []).length === 0 && !vendorExcluded)) return null;
+
+    const rest = doc_id.slice(sep + 1).replace(/:/g, "/");
+    const relPath = this.codeCollections.has(collection) ? rest.replace(/_(\w+)$/, ".$1") : `${rest}.md`;
+    if (isIncluded(collection, relPath)) return null;
+    if (vendorExcluded && isVendorPath(relPath)) {
+      return `Document "${doc_id}" is not indexed: ${relPath} is in a vendor/ tree and VENDOR_POLICY is exclude.`;
+    }
+    return `Document "${doc_id}" is not indexed: ${relPath} is outside the include patterns (${collection.include!.join(", ")}). Widen INCLUDE to index it.`;
+  }
+
+  /**
+   * Status text when no collection indexes anything under the
+   * directory `prefix` (a session focus), or null.
+   */
+  excludedPrefix(prefix: string): string | null {
+    if (!this.isSparse()) return null;
+    const dir = prefix.replace(/\/+$/, "");
+    if (this.collections.some((c) => mayContainIncluded(c, dir))) return null;
+    const patterns = [...new Set(this.collections.flatMap((c) => c.include ?? []))];
+    return `"${prefix}" is not indexed: it is outside the include patterns (${patterns.join(", ")}). Widen INCLUDE to index it.`;
+  }
+}
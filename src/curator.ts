@@ -15,10 +15,11 @@
  * See docs/adr/0001-llm-curated-wiki.md for the decision record.
  */
 
-import { mkdir, stat as fsStat } from "node:fs/promises";
-import { dirname, normalize, resolve, sep } from "node:path";
+import { mkdir, rename, stat as fsStat } from "node:fs/promises";
+import { dirname, normalize, resolve } from "node:path";
 import { DocumentStore } from "./store";
 import { indexFile, inferTypeFromPath } from "./indexer";
+import { isAbsolutePath, isWithin, relativePath, toPosix } from "./paths";
 
 // ── Options & types ─────────────────────────────────────────────────
 
@@ -179,9 +180,11 @@ export function findSimilar(
   const uniqueTokens = [...new Set(tokens)].slice(0, 200);
   const query = uniqueTokens.join(" ");
 
+  // Article text, not a query: a stray "-flag" token is not an operator
   const results = store.searchDocuments(query, {
     limit: Math.max(limit * 3, 15),
     collection: options?.collection,
+    operators: false,
   });
 
   let suggest_merge = false;
@@ -406,7 +409,10 @@ export async function writeWikiEntry(
   // 7. Write + re-index
   try {
     await mkdir(dirname(absolute), { recursive: true });
-    await Bun.write(absolute, serialized);
+    // Temp file + rename: a kill mid-write never leaves a truncated entry
+    const tmp = `${absolute}.tmp-${process.pid}`;
+    await Bun.write(tmp, serialized);
+    await rename(tmp, absolute);
   } catch (err: any) {
     throw new CuratorError("WRITE_FAILED", `write failed: ${err.message}`);
   }
@@ -443,16 +449,17 @@ function validateRelativePath(
   if (!input.endsWith(".md")) {
     return { ok: false, error: "path must end in .md" };
   }
-  // Reject absolute paths (POSIX and Windows drive letters)
-  if (input.startsWith("/") || /^[a-zA-Z]:[\\/]/.test(input)) {
+  // Reject absolute paths (POSIX, Windows drive letters, and UNC shares)
+  if (isAbsolutePath(input)) {
     return { ok: false, error: "path must be relative to the wiki root" };
   }
 
   const absRoot = resolve(wikiRoot);
-  const absPath = resolve(absRoot, normalize(input));
+  // Backslashes separate too, whichever platform the client writes from
+  const absPath = resolve(absRoot, normalize(toPosix(input)));
 
   // Containment: absPath must be exactly absRoot or nested under it
-  if (absPath !== absRoot && !absPath.startsWith(absRoot + sep)) {
+  if (!isWithin(absRoot, absPath)) {
     return { ok: false, error: "path escapes the wiki root" };
   }
   if (absPath === absRoot) {
@@ -460,7 +467,7 @@ function validateRelativePath(
   }
 
   // Normalized relative path (always POSIX-ish inside DOCS_ROOT)
-  const rel = absPath.slice(absRoot.length + 1).split(sep).join("/");
+  const rel = relativePath(absRoot, absPath);
   return { ok: true, absolute: absPath, relative: rel };
 }
 
@@ -628,8 +635,9 @@ function tokenizeForQuery(text: string): string[] {
   // Strip frontmatter if present so dedupe sees only the body
   const stripped = text.replace(/^---[\s\S]*?\n---\n/, "");
   return stripped
+    .normalize("NFC")
     .toLowerCase()
-    .replace(/[^a-z0-9_\-\.\/]/g, " ")
+    .replace(/[^\p{L}\p{N}\p{M}_\-\.\/]/gu, " ")
     .split(/\s+/)
     .filter((w) => w.length >= 3);
 }
@@ -0,0 +1,367 @@
+/**
+ * Import cycles in the package graph — the find_cycles tool
+ *
+ * Packages are directories of indexed code. An edge A → B means a file
+ * in A imports B, resolved the way each language does it:
+ *
+ *   Go          import paths under one of the repository's go.mod
+ *               modules (test files are left out; external test
+ *               packages may import back legally)
+ *   JS / TS     relative specifiers ("./x", "../y/index.js"), with
+ *               extensionless and index files resolved
+ *   Python      relative imports, and absolute ones that name a module
+ *               under the collection root
+ *
+ * Cycles are the strongly connected components of the graph. A
+ * component held together by runtime imports is a "cycle". One that
+ * only closes through type-only imports — `import type` and
+ * `export type` in TypeScript, imports under `if TYPE_CHECKING:` in
+ * Python, which is how interfaces usually cross packages — is a
+ * "near_cycle": harmless at runtime, but one value import away from a
+ * real cycle. Go has no type-only imports, and the compiler rejects
+ * its cycles, so a Go cycle means the tree does not build.
+ *
+ * For each component the report names a cut: edges whose removal breaks
+ * every cycle in it. The cut is built greedily, cheapest edge of the
+ * shortest remaining cycle first (type-only edges, then the fewest
+ * import sites), then pruned so no edge in it is redundant. It is
+ * minimal in that sense, not necessarily the smallest possible.
+ */
+
+import { extname, posix } from "node:path";
+import type { DocumentMeta, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { moduleForPath, type GoModuleIndex } from "./go-modules";
+import { currentDeadline } from "./deadline";
+import { FileScanCache } from "./scan-cache";
+
+const JS_EXTENSIONS = [".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"];
+
+export interface ImportSpec {
+  /** As written: an import path, a relative specifier, or a dotted module */
+  spec: string;
+  line: number;
+  type_only: boolean;
+}
+
+export interface ImportSite {
+  file_path: string;
+  line: number;
+  type_only: boolean;
+}
+
+export interface PackageEdge {
+  from: string;
+  to: string;
+  /** Every import site is type-only */
+  type_only: boolean;
+  sites: ImportSite[];
+}
+
+export interface ImportCycle {
+  kind: "cycle" | "near_cycle";
+  collection: string;
+  packages: string[];
+  /** Edges inside the component */
+  edges: PackageEdge[];
+  /** Edges whose removal breaks every cycle here */
+  cut: PackageEdge[];
+  /** A shortest cycle, starting and ending at the same package */
+  path: string[];
+}
+
+export interface CycleReport {
+  packages: number;
+  edges: number;
+  cycles: ImportCycle[];
+}
+
+const lineAt = (source: string, index: number) => source.slice(0, index).split("\n").length;
+
+/** Go import paths of a file, blank and dot imports included. */
+function goImportSpecs(source: string): ImportSpec[] {
+  const specs: ImportSpec[] = [];
+  for (const m of source.matchAll(/^import\s*\(([\s\S]*?)^\)/gm)) {
+    const first = lineAt(source, m.index!);
+    m[1].split("\n").forEach((line, i) => {
+      const s = line.match(/^\s*(?:[\w.]+\s+)?"([^"]+)"/);
+      if (s) specs.push({ spec: s[1], line: first + i, type_only: false });
+    });
+  }
+  for (const m of source.matchAll(/^import\s+(?:[\w.]+\s+)?"([^"]+)"/gm)) {
+    specs.push({ spec: m[1], line: lineAt(source, m.index!), type_only: false });
+  }
+  return specs;
+}
+
+/** Module specifiers of a JS/TS file: static imports, re-exports, require(), and import(). */
+function jsImportSpecs(source: string): ImportSpec[] {
+  const specs: ImportSpec[] = [];
+  const statement =
+    /^[ \t]*(?:import\s+(type\s+)?([\w$*{}\s,]*?)\s*from\s*|import\s*|export\s+(type\s+)?(?:\*(?:\s+as\s+[\w$]+)?|\{[^}]*\})\s*from\s*)["']([^"']+)["']/gm;
+  for (const m of source.matchAll(statement)) {
+    // import { type A, type B } from "x" is type-only too
+    const names = m[2]?.match(/^\{([^}]*)\}$/)?.[1].split(",").map((n) => n.trim()).filter(Boolean);
+    const inline = names !== undefined && names.length > 0 && names.every((n) => /^type\s/.test(n));
+    specs.push({ spec: m[4], line: lineAt(source, m.index!), type_only: Boolean(m[1] || m[3]) || inline });
+  }
+  for (const m of source.matchAll(/\b(?:require|import)\s*\(\s*["']([^"']+)["']\s*\)/g)) {
+    specs.push({ spec: m[1], line: lineAt(source, m.index!), type_only: false });
+  }
+  return specs;
+}
+
+/** Imported modules of a Python file, as dotted names with leading dots for relative imports. */
+function pyImportSpecs(source: string): ImportSpec[] {
+  const specs: ImportSpec[] = [];
+  let typeChecking: number | null = null;
+  source.split("\n").forEach((text, i) => {
+    const indent = text.match(/^\s*/)![0].length;
+    if (text.trim() && typeChecking !== null && indent <= typeChecking) typeChecking = null;
+    if (/^\s*if\s+(?:typing\.)?TYPE_CHECKING\s*:/.test(text)) {
+      typeChecking = indent;
+      return;
+    }
+    const type_only = typeChecking !== null;
+    const from = text.match(/^\s*from\s+(\.*[\w.]*)\s+import\b/);
+    if (from) {
+      specs.push({ spec: from[1], line: i + 1, type_only });
+      return;
+    }
+    const plain = text.match(/^\s*import\s+([\w.]+(?:\s+as\s+\w+)?(?:\s*,\s*[\w.]+(?:\s+as\s+\w+)?)*)/);
+    for (const name of plain?.[1].split(",") ?? []) {
+      specs.push({ spec: name.trim().split(/\s+/)[0], line: i + 1, type_only });
+    }
+  });
+  return specs;
+}
+
+/** The imports of a source file; empty for languages without package resolution here. */
+export function fileImports(source: string, filePath: string): ImportSpec[] {
+  const ext = extname(filePath).toLowerCase();
+  if (ext === ".go") return filePath.endsWith("_test.go") ? [] : goImportSpecs(source);
+  if (JS_EXTENSIONS.includes(ext)) return jsImportSpecs(source);
+  if (ext === ".py" || ext === ".pyi") return pyImportSpecs(source);
+  return [];
+}
+
+/** Strongly connected components with more than one package. */
+function components(nodes: string[], next: Map<string, string[]>): string[][] {
+  const index = new Map<string, number>();
+  const low = new Map<string, number>();
+  const stack: string[] = [];
+  const onStack = new Set<string>();
+  const found: string[][] = [];
+  const visit = (v: string) => {
+    index.set(v, index.size);
+    low.set(v, index.get(v)!);
+    stack.push(v);
+    onStack.add(v);
+    for (const w of next.get(v) ?? []) {
+      if (!index.has(w)) {
+        visit(w);
+        low.set(v, Math.min(low.get(v)!, low.get(w)!));
+      } else if (onStack.has(w)) {
+        low.set(v, Math.min(low.get(v)!, index.get(w)!));
+      }
+    }
+    if (low.get(v) !== index.get(v)) return;
+    const component: string[] = [];
+    let w: string;
+    do {
+      w = stack.pop()!;
+      onStack.delete(w);
+      component.push(w);
+    } while (w !== v);
+    if (component.length > 1) found.push(component.sort());
+  };
+  for (const v of nodes) if (!index.has(v)) visit(v);
+  return found;
+}
+
+/** The shortest cycle over `edges`, as a package path closing on its start; null when acyclic. */
+function shortestCycle(nodes: string[], edges: PackageEdge[]): string[] | null {
+  const next = new Map<string, string[]>();
+  for (const e of edges) next.set(e.from, [...(next.get(e.from) ?? []), e.to]);
+  let best: string[] | null = null;
+  for (const start of nodes) {
+    const parent = new Map<string, string>();
+    const queue = [start];
+    let closing: string | null = null;
+    while (queue.length && closing === null) {
+      const v = queue.shift()!;
+      for (const w of next.get(v) ?? []) {
+        if (w === start) {
+          closing = v;
+          break;
+        }
+        if (!parent.has(w)) {
+          parent.set(w, v);
+          queue.push(w);
+        }
+      }
+    }
+    if (closing === null) continue;
+    const path = [closing];
+    while (path[0] !== start) path.unshift(parent.get(path[0])!);
+    path.push(start);
+    if (!best || path.length < best.length) best = path;
+  }
+  return best;
+}
+
+/** A cut for the component: greedy on shortest cycles, then pruned of redundant edges. */
+function cutOf(nodes: string[], edges: PackageEdge[]): PackageEdge[] {
+  const cost = (e: PackageEdge) => (e.type_only ? 0 : 1e6) + e.sites.length;
+  const removed = new Set<PackageEdge>();
+  const rest = () => edges.filter((e) => !removed.has(e));
+  for (let cycle = shortestCycle(nodes, edges); cycle; cycle = shortestCycle(nodes, rest())) {
+    const onCycle = rest().filter((e) => cycle!.some((v, i) => i > 0 && cycle![i - 1] === e.from && v === e.to));
+    onCycle.sort((a, b) => cost(a) - cost(b) || a.from.localeCompare(b.from) || a.to.localeCompare(b.to));
+    removed.add(onCycle[0]);
+  }
+  for (const e of [...removed]) {
+    removed.delete(e);
+    if (shortestCycle(nodes, rest())) removed.add(e);
+  }
+  return edges.filter((e) => removed.has(e));
+}
+
+/** Cycle detection over the code collections of a store. */
+export class CycleFinder {
+  private readonly cache: FileScanCache<ImportSpec[]>;
+
+  constructor(config: IndexConfig, private readonly goModules?: GoModuleIndex) {
+    this.cache = new FileScanCache(config, (source, meta) => fileImports(source, meta.file_path));
+  }
+
+  /**
+   * Cycles among the packages at `path` (directory prefix or glob; all
+   * code when omitted), real cycles first, then by size. Returns null
+   * when no indexed code file matches the path.
+   */
+  async find(store: DocumentStore, options: { path?: string } = {}): Promise<CycleReport | null> {
+    const docs: DocumentMeta[] = options.path
+      ? store.codeDocumentsAt(options.path)
+      : store.listDocuments({ filters: { content_type: "code" }, limit: Infinity }).documents;
+    if (docs.length === 0) return null;
+
+    const graph = this.goModules ? await this.goModules.graph() : null;
+    const byCollection = new Map<string, DocumentMeta[]>();
+    for (const doc of docs) {
+      if (this.cache.root(doc.collection) === undefined) continue;
+      byCollection.set(doc.collection, [...(byCollection.get(doc.collection) ?? []), doc]);
+    }
+
+    let packages = 0;
+    let edgeCount = 0;
+    const cycles: ImportCycle[] = [];
+    for (const [collection, files] of byCollection) {
+      const modules = graph?.modules.filter((m) => m.collection === collection) ?? [];
+      const paths = new Set(files.map((f) => f.file_path));
+      const dirOf = (file: string) => posix.dirname(file).replace(/^\.$/, "");
+      const labels = new Map<string, string>();
+      const label = (dir: string, go: boolean) => {
+        if (!labels.has(dir)) {
+          const mod = go && graph ? moduleForPath({ ...graph, modules }, dir) : null;
+          const rest = mod ? dir.slice(mod.dir.length).replace(/^\//, "") : "";
+          labels.set(dir, mod ? (rest ? `${mod.module}/${rest}` : mod.module) : dir || ".");
+        }
+        return labels.get(dir)!;
+      };
+      for (const f of files) label(dirOf(f.file_path), f.file_path.endsWith(".go"));
+
+      const edges = new Map<string, PackageEdge>();
+      for (const doc of files) {
+        if (currentDeadline()?.expired()) break;
+        const from = dirOf(doc.file_path);
+        for (const imp of (await this.cache.get(doc)) ?? []) {
+          const to = this.resolve(imp.spec, doc.file_path, paths, modules);
+          if (to === null || to === from || !labels.has(to)) continue;
+          const key = `${from}\n${to}`;
+          let edge = edges.get(key);
+          if (!edge) {
+            edge = { from: labels.get(from)!, to: labels.get(to)!, type_only: true, sites: [] };
+            edges.set(key, edge);
+          }
+          edge.sites.push({ file_path: doc.file_path, line: imp.line, type_only: imp.type_only });
+          edge.type_only &&= imp.type_only;
+        }
+      }
+      packages += labels.size;
+      edgeCount += edges.size;
+
+      const all = [...edges.values()];
+      const nodes = [...new Set(labels.values())].sort();
+      const adjacency = (list: PackageEdge[]) => {
+        const next = new Map<string, string[]>();
+        for (const e of list) next.set(e.from, [...(next.get(e.from) ?? []), e.to]);
+        return next;
+      };
+      const runtime = all.filter((e) => !e.type_only);
+      const real = components(nodes, adjacency(runtime));
+      const seen = new Set(real.map((c) => c.join("\n")));
+      const near = components(nodes, adjacency(all)).filter((c) => !seen.has(c.join("\n")));
+      for (const [kind, list, group] of [
+        ["cycle", runtime, real],
+        ["near_cycle", all, near],
+      ] as const) {
+        for (const members of group) {
+          const inside = new Set(members);
+          const within = list.filter((e) => inside.has(e.from) && inside.has(e.to));
+          cycles.push({
+            kind,
+            collection,
+            packages: members,
+            edges: within,
+            cut: cutOf(members, within),
+            path: shortestCycle(members, within)!,
+          });
+        }
+      }
+    }
+    cycles.sort(
+      (a, b) =>
+        (a.kind === "cycle" ? 0 : 1) - (b.kind === "cycle" ? 0 : 1) ||
+        b.packages.length - a.packages.length ||
+        a.packages[0].localeCompare(b.packages[0])
+    );
+    return { packages, edges: edgeCount, cycles };
+  }
+
+  /** The package directory an import names, or null when it is outside the collection. */
+  private resolve(spec: string, filePath: string, paths: Set<string>, modules: Array<{ module: string; dir: string }>): string | null {
+    const normal = (p: string) => {
+      const n = posix.normalize(p).replace(/\/+$/, "");
+      return n === "." ? "" : n;
+    };
+    const ext = extname(filePath).toLowerCase();
+
+    if (ext === ".go") {
+      const owner = modules
+        .filter((m) => spec === m.module || spec.startsWith(`${m.module}/`))
+        .sort((a, b) => b.module.length - a.module.length)[0];
+      return owner ? normal(posix.join(owner.dir, spec.slice(owner.module.length + 1))) : null;
+    }
+
+    if (JS_EXTENSIONS.includes(ext)) {
+      if (!spec.startsWith(".")) return null;
+      const target = normal(posix.join(posix.dirname(filePath), spec));
+      const stem = target.replace(/\.(?:[mc]?js|jsx)$/, "");
+      const candidates = [target, ...JS_EXTENSIONS.map((e) => stem + e)];
+      const file = candidates.find((c) => paths.has(c));
+      if (file) return posix.dirname(file).replace(/^\.$/, "");
+      return JS_EXTENSIONS.some((e) => paths.has(`${target}/index${e}`)) ? target : null;
+    }
+
+    // Python: leading dots climb from the file's package
+    const dots = spec.match(/^\.*/)![0].length;
+    const base = dots ? normal(posix.join(posix.dirname(filePath), ...Array(dots - 1).fill(".."))) : "";
+    const module = spec.slice(dots).replace(/\./g, "/");
+    const target = normal(module ? posix.join(base, module) : base);
+    if (target.startsWith("..")) return null;
+    if (paths.has(`${target}.py`) || paths.has(`${target}.pyi`)) return posix.dirname(`${target}.py`).replace(/^\.$/, "");
+    // A package directory; the caller drops directories without indexed code
+    return dots > 0 || target !== "" ? target : null;
+  }
+}
@@ -0,0 +1,136 @@
+/**
+ * Tool deadlines — TOOL_TIMEOUT_MS and the per-category overrides
+ *
+ * A tool call on a large repository can take arbitrarily long: a glob
+ * over every file for structural_search, `git log` over years of
+ * history for hotspots, an import graph for find_cycles. Rather than
+ * leave the client waiting, every tool call runs under a deadline:
+ *
+ *   search  search_documents, find_symbol, multi_search, list_documents,
+ *           structural_search, regex_search, ts_query, find_duplicates
+ *   graph   module_info, package_api, usage_stats, find_references,
+ *           find_cycles
+ *   git     hotspots, ast_diff, list_markers, and any call with `ref`
+ *
+ * Each category takes SEARCH_TIMEOUT_MS, GRAPH_TIMEOUT_MS, or
+ * GIT_TIMEOUT_MS when set, else TOOL_TIMEOUT_MS (0 = no deadline).
+ *
+ * The deadline is carried through the call like a context: code deep
+ * in a loop asks currentDeadline() whether it has passed and stops with
+ * what it has, and git subprocesses are killed at it (spawnTimeout()).
+ * The answer then carries `timed_out: true`, so the client knows the
+ * results may be partial. A handler that still has not answered a
+ * second after the deadline is abandoned and the call fails with a
+ * timeout error. structural_replace, reindex_path, and the curation
+ * tools change files or the index and are never cut short by time. On shutdown (shutdown.ts), any call
+ * still running after the grace period has its deadline cancelled.
+ */
+
+import { AsyncLocalStorage } from "node:async_hooks";
+
+export type TimeoutCategory = "search" | "graph" | "git";
+
+export const TOOL_CATEGORIES: Record<string, TimeoutCategory> = {
+  search_documents: "search",
+  find_symbol: "search",
+  multi_search: "search",
+  list_documents: "search",
+  structural_search: "search",
+  regex_search: "search",
+  ts_query: "search",
+  find_duplicates: "search",
+  module_info: "graph",
+  package_api: "graph",
+  usage_stats: "graph",
+  find_references: "graph",
+  find_cycles: "graph",
+  hotspots: "git",
+  ast_diff: "git",
+  list_markers: "git",
+};
+
+/**
+ * Tools that write files; stopping them halfway would leave a mess.
+ * capture_profile takes as long as the profile it was asked for, and
+ * reindex_path as the subtree it was given.
+ */
+const UNTIMED = new Set(["structural_replace", "capture_profile", "reindex_path", "find_similar", "draft_wiki_entry", "write_wiki_entry"]);
+
+export const DEFAULT_TOOL_TIMEOUT_MS = 30_000;
+
+/** How long past the deadline a handler may take before it is abandoned */
+export const GRACE_MS = 1_000;
+
+/** Milliseconds per category; `default` for the rest (0 = none). */
+export interface ToolTimeouts {
+  default: number;
+  search?: number;
+  graph?: number;
+  git?: number;
+}
+
+/**
+ * The deadline for one call of `tool` with `args`, in ms (0 for none),
+ * and the setting it came from.
+ */
+export function timeoutFor(
+  tool: string,
+  args: Record<string, unknown> | undefined,
+  timeouts: ToolTimeouts
+): { ms: number; setting: string } {
+  if (UNTIMED.has(tool)) return { ms: 0, setting: "" };
+  // Reading at a git ref builds an index from the object store first
+  const category = args?.ref !== undefined ? "git" : TOOL_CATEGORIES[tool];
+  const own = category ? timeouts[category] : undefined;
+  return own !== undefined
+    ? { ms: own, setting: `${category!.toUpperCase()}_TIMEOUT_MS` }
+    : { ms: timeouts.default, setting: "TOOL_TIMEOUT_MS" };
+}
+
+/** A point in time a tool call must answer by. */
+export class Deadline {
+  readonly at: number;
+  private cancelled = false;
+
+  /** `ms` may be Infinity: no time limit, but still cancellable */
+  constructor(readonly ms: number) {
+    this.at = Date.now() + ms;
+  }
+
+  expired(): boolean {
+    return this.cancelled || Date.now() >= this.at;
+  }
+
+  /** Make the deadline pass now, e.g. when the server shuts down. */
+  cancel(): void {
+    this.cancelled = true;
+  }
+
+  get isCancelled(): boolean {
+    return this.cancelled;
+  }
+
+  /** Milliseconds left, at least 1 */
+  remaining(): number {
+    return this.cancelled ? 1 : Math.max(1, this.at - Date.now());
+  }
+}
+
+const current = new AsyncLocalStorage<Deadline>();
+
+/** The deadline of the tool call being answered, if any. */
+export function currentDeadline(): Deadline | undefined {
+  return current.getStore();
+}
+
+/** Run `fn` with `deadline` as the current deadline. */
+export function withDeadline<T>(deadline: Deadline, fn: () => T): T {
+  return current.run(deadline, fn);
+}
+
+/** Spawn options that kill a subprocess at the current deadline. */
+export function spawnTimeout(): { timeout?: number } {
+  const deadline = currentDeadline();
+  const remaining = deadline?.remaining();
+  return remaining !== undefined && Number.isFinite(remaining) ? { timeout: remaining } : {};
+}
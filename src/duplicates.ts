@@ -0,0 +1,230 @@
+/**
+ * Duplicate and near-duplicate code — the find_duplicates tool
+ *
+ * Every function and method node in the index is reduced to a token
+ * stream with comments dropped, literals collapsed to one placeholder,
+ * and (by default) identifiers collapsed to another. Keywords and
+ * punctuation stay, so the stream keeps the code's shape while two
+ * copies that differ only in names or constants become identical.
+ *
+ * Each stream is cut into overlapping shingles of SHINGLE_SIZE tokens.
+ * Two units are clones when the Jaccard similarity of their shingle
+ * sets reaches the threshold. Candidate pairs come from an inverted
+ * index over shingles; shingles shared by very many units (boilerplate
+ * such as `if err != nil { return err }`) do not nominate pairs, but
+ * still count in the similarity of the pairs that are compared. Clone
+ * pairs are merged into groups: A≈B and B≈C report A, B, C together.
+ *
+ * This is lexical fingerprinting, not an AST diff. Reordered statements
+ * lower the score, and a clone embedded in a larger function is found
+ * only when it dominates that function.
+ */
+
+import type { DocumentStore } from "./store";
+
+/** Tokens per shingle */
+const SHINGLE_SIZE = 8;
+
+/** A shingle found in more units than this does not nominate candidate pairs. */
+const MAX_SHINGLE_POSTINGS = 50;
+
+/** Words kept verbatim when identifiers are normalized, across the indexed languages */
+const KEYWORDS = new Set(
+  (
+    "if else elif for while do return func function def fn class struct interface trait impl enum type " +
+    "const let var val switch case default break continue goto try catch except finally throw throws raise " +
+    "new delete import from package go defer select range map chan match pub static async await yield " +
+    "in of is not and or with as lambda pass this self super nil null None true false True False void"
+  ).split(" ")
+);
+
+/** A function or method node. */
+export interface CodeUnit {
+  doc_id: string;
+  node_id: string;
+  collection: string;
+  file_path: string;
+  title: string;
+  line_start: number;
+  line_end: number;
+  /** Tokens after normalization */
+  tokens: number;
+}
+
+export interface CloneGroup {
+  /** Lowest similarity among the pairs that joined the group */
+  similarity: number;
+  members: CodeUnit[];
+}
+
+export interface DuplicateOptions {
+  path_prefix?: string;
+  /** Units shorter than this many tokens are skipped (default 50) */
+  min_tokens?: number;
+  /** Jaccard similarity of shingle sets, 0-1 (default 0.8) */
+  similarity?: number;
+  /** Treat identifiers as interchangeable (default true) */
+  normalize_identifiers?: boolean;
+}
+
+/**
+ * Normalized tokens of a code fragment: comments removed, string and
+ * number literals as "#", identifiers as "$" unless `keepIdentifiers`.
+ */
+export function codeTokens(source: string, keepIdentifiers = false): string[] {
+  const code = source
+    .replace(/\/\*[\s\S]*?\*\//g, " ")
+    .replace(/(^|[^:"'`])\/\/.*$/gm, "$1")
+    .replace(/^\s*#(?!\[|include|define|if|endif|else|pragma).*$/gm, "");
+  const tokens: string[] = [];
+  const TOKEN = /"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'|`[^`]*`|\d[\w.]*|[\p{L}_$][\p{L}\p{N}_$]*|[^\s\p{L}\p{N}_$]/gu;
+  for (const [token] of code.matchAll(TOKEN)) {
+    const first = token[0];
+    if (first === '"' || first === "'" || first === "`" || /\d/.test(first)) tokens.push("#");
+    else if (/[\p{L}_$]/u.test(first)) tokens.push(keepIdentifiers || KEYWORDS.has(token) ? token : "$");
+    else tokens.push(token);
+  }
+  return tokens;
+}
+
+/** Overlapping runs of `size` tokens; one shingle for a shorter stream. */
+export function shingles(tokens: string[], size: number = SHINGLE_SIZE): Set<string> {
+  const set = new Set<string>();
+  if (tokens.length <= size) {
+    set.add(tokens.join(" "));
+    return set;
+  }
+  for (let i = 0; i + size <= tokens.length; i++) set.add(tokens.slice(i, i + size).join(" "));
+  return set;
+}
+
+interface Fingerprint {
+  unit: CodeUnit;
+  shingles: Set<string>;
+}
+
+const isUnit = (title: string) => title.startsWith("function ") || title.startsWith("method ");
+
+/** Finds clone groups in the code of a store, caching fingerprints per file. */
+export class DuplicateFinder {
+  private cache = new Map<string, { hash: string; keep: boolean; prints: Fingerprint[] }>();
+
+  /** Clone groups, largest code first, and how many units were compared. */
+  find(store: DocumentStore, options: DuplicateOptions = {}): { groups: CloneGroup[]; units: number } {
+    const minTokens = options.min_tokens ?? 50;
+    const threshold = options.similarity ?? 0.8;
+    const prints = this.fingerprints(store, options.path_prefix, options.normalize_identifiers === false)
+      .filter((p) => p.unit.tokens >= minTokens);
+
+    // Candidate pairs: units sharing at least one distinctive shingle
+    const postings = new Map<string, number[]>();
+    prints.forEach((p, i) => {
+      for (const s of p.shingles) {
+        const list = postings.get(s);
+        if (list) list.push(i);
+        else postings.set(s, [i]);
+      }
+    });
+    const candidates = new Set<number>();
+    for (const list of postings.values()) {
+      if (list.length < 2 || list.length > MAX_SHINGLE_POSTINGS) continue;
+      for (let a = 0; a < list.length; a++) {
+        for (let b = a + 1; b < list.length; b++) candidates.add(list[a] * prints.length + list[b]);
+      }
+    }
+
+    // Score candidates and merge clone pairs (union-find)
+    const parent = prints.map((_, i) => i);
+    const root = (i: number): number => (parent[i] === i ? i : (parent[i] = root(parent[i])));
+    const groupSimilarity = new Map<number, number>();
+    const edges: Array<[number, number, number]> = [];
+    for (const key of candidates) {
+      const a = Math.floor(key / prints.length);
+      const b = key % prints.length;
+      if (nested(prints[a].unit, prints[b].unit)) continue;
+      const similarity = jaccard(prints[a].shingles, prints[b].shingles);
+      if (similarity < threshold) continue;
+      edges.push([a, b, similarity]);
+      parent[root(a)] = root(b);
+    }
+    for (const [a, , similarity] of edges) {
+      const r = root(a);
+      groupSimilarity.set(r, Math.min(groupSimilarity.get(r) ?? 1, similarity));
+    }
+
+    const members = new Map<number, CodeUnit[]>();
+    for (const [a, b] of edges) {
+      for (const i of [a, b]) {
+        const r = root(i);
+        const list = members.get(r) ?? [];
+        if (!list.includes(prints[i].unit)) list.push(prints[i].unit);
+        members.set(r, list);
+      }
+    }
+
+    const groups = [...members].map(([r, units]) => ({
+      similarity: Math.round(groupSimilarity.get(r)! * 1000) / 1000,
+      members: units.sort(
+        (x, y) => x.file_path.localeCompare(y.file_path) || x.line_start - y.line_start
+      ),
+    }));
+    const weight = (g: CloneGroup) => g.members.reduce((sum, m) => sum + m.tokens, 0);
+    groups.sort((x, y) => weight(y) - weight(x) || y.similarity - x.similarity);
+    return { groups, units: prints.length };
+  }
+
+  private fingerprints(store: DocumentStore, pathPrefix: string | undefined, keep: boolean): Fingerprint[] {
+    const prints: Fingerprint[] = [];
+    const live = new Set<string>();
+    for (const doc of store.exportDocuments()) {
+      if (!doc.meta.facets.content_type?.includes("code")) continue;
+      if (pathPrefix && !doc.meta.file_path.startsWith(pathPrefix)) continue;
+      live.add(doc.meta.doc_id);
+      const cached = this.cache.get(doc.meta.doc_id);
+      if (cached && cached.hash === doc.meta.content_hash && cached.keep === keep) {
+        prints.push(...cached.prints);
+        continue;
+      }
+      const docPrints = doc.tree
+        .filter((node) => isUnit(node.title))
+        .map((node): Fingerprint => {
+          const tokens = codeTokens(node.content, keep);
+          return {
+            unit: {
+              doc_id: doc.meta.doc_id,
+              node_id: node.node_id,
+              collection: doc.meta.collection,
+              file_path: doc.meta.file_path,
+              title: node.title,
+              line_start: node.line_start,
+              line_end: node.line_end,
+              tokens: tokens.length,
+            },
+            shingles: shingles(tokens),
+          };
+        });
+      this.cache.set(doc.meta.doc_id, { hash: doc.meta.content_hash, keep, prints: docPrints });
+      prints.push(...docPrints);
+    }
+    if (!pathPrefix) {
+      for (const id of this.cache.keys()) if (!live.has(id)) this.cache.delete(id);
+    }
+    return prints;
+  }
+}
+
+function jaccard(a: Set<string>, b: Set<string>): number {
+  const [small, large] = a.size <= b.size ? [a, b] : [b, a];
+  let shared = 0;
+  for (const s of small) if (large.has(s)) shared++;
+  return shared / (a.size + b.size - shared);
+}
+
+/** True when one unit lies inside the other, e.g. a nested function. */
+function nested(a: CodeUnit, b: CodeUnit): boolean {
+  if (a.doc_id !== b.doc_id) return false;
+  return (
+    (a.line_start <= b.line_start && b.line_end <= a.line_end) ||
+    (b.line_start <= a.line_start && a.line_end <= b.line_end)
+  );
+}
@@ -0,0 +1,162 @@
+/**
+ * "Open in editor" links next to every location URI (EDITOR_URL)
+ *
+ * A result's `uri` (treenav://file/<path>#L42-58) is for the next tool
+ * call; a person reading the answer in a chat client wants to click
+ * through to the line in their editor instead. With EDITOR_URL, or a
+ * session's set_preferences editor_url, every object in a tool's
+ * structured answer that carries a `uri` also gets an `editor_url`,
+ * filled in from a template:
+ *
+ *   vscode           vscode://file{file}:{line}:{column}
+ *   jetbrains        jetbrains://idea/navigate/reference?project={project}&path={path}:{line}
+ *   custom           https://review.internal/open?f={path}&l={line}-{end_line}
+ *
+ * Placeholders:
+ *
+ *   {file}        absolute path on this machine, "/"-separated and
+ *                 starting with "/" (/home/me/repo/x.go, /C:/repo/x.go)
+ *   {path}        path relative to the collection root, as indexed
+ *   {root}        the collection root, spelled like {file}
+ *   {project}     the root's directory name
+ *   {collection}  the collection's name
+ *   {line}        first line (1 for a whole file); {end_line} the last
+ *   {column}      always 1; results point at lines, not columns
+ *
+ * Path segments are percent-encoded; "/" and drive-letter colons are
+ * kept. Notebook cells get no link, since editors cannot address a line
+ * within a cell, and neither do answers read at a git ref, whose lines
+ * are not the working tree's.
+ */
+
+import { basename, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseUri, URI_PREFIX, UriError } from "./uris";
+import { toPosix } from "./paths";
+
+/** Templates by editor name, for EDITOR_URL and set_preferences editor_url. */
+export const EDITOR_PRESETS: Record<string, string> = {
+  vscode: "vscode://file{file}:{line}:{column}",
+  "vscode-insiders": "vscode-insiders://file{file}:{line}:{column}",
+  cursor: "cursor://file{file}:{line}:{column}",
+  zed: "zed://file{file}:{line}:{column}",
+  sublime: "subl://open?url=file://{file}&line={line}&column={column}",
+  textmate: "txmt://open?url=file://{file}&line={line}&column={column}",
+  jetbrains: "jetbrains://idea/navigate/reference?project={project}&path={path}:{line}",
+  idea: "idea://open?file={file}&line={line}",
+};
+
+const PLACEHOLDERS = new Set(["file", "path", "root", "project", "collection", "line", "end_line", "column"]);
+
+/** An unknown preset or a template naming no file or an unknown placeholder. */
+export class EditorLinkError extends Error {}
+
+/**
+ * The template `spec` names: a preset, or a template of its own. Throws
+ * EditorLinkError unless it has {file} or {path} and only known
+ * placeholders.
+ */
+export function editorTemplate(spec: string): string {
+  const template = EDITOR_PRESETS[spec.trim().toLowerCase()] ?? spec.trim();
+  if (!template.includes("{")) {
+    throw new EditorLinkError(`Unknown editor "${spec}" (known: ${Object.keys(EDITOR_PRESETS).join(", ")}), and not a template with {file} or {path}`);
+  }
+  const unknown = [...template.matchAll(/\{([^}]*)\}/g)].map((m) => m[1]).filter((name) => !PLACEHOLDERS.has(name));
+  if (unknown.length > 0) {
+    throw new EditorLinkError(`Unknown placeholder {${unknown[0]}} in "${template}"; use ${[...PLACEHOLDERS].map((p) => `{${p}}`).join(", ")}`);
+  }
+  if (!/\{(?:file|path)\}/.test(template)) throw new EditorLinkError(`"${template}" names no file; add {file} or {path}`);
+  return template;
+}
+
+/** What a template is filled in from. */
+export interface EditorLocation {
+  /** Collection root, absolute */
+  root: string;
+  collection: string;
+  /** Relative to the root, "/"-separated */
+  path: string;
+  line: number;
+  end_line: number;
+}
+
+/** `path` percent-encoded segment by segment, keeping "/" and drive-letter colons. */
+function encodePath(path: string): string {
+  return path.split("/").map((s) => encodeURIComponent(s).replace(/%3A/gi, ":")).join("/");
+}
+
+/** An absolute path as {file} spells it: "/"-separated, always starting with "/". */
+function fileSpelling(abs: string): string {
+  const posix = toPosix(abs);
+  return encodePath(posix.startsWith("/") ? posix : `/${posix}`);
+}
+
+/** `template` filled in for `location`. */
+export function formatEditorUrl(template: string, location: EditorLocation): string {
+  const values: Record<string, string> = {
+    file: fileSpelling(resolve(location.root, location.path)),
+    path: encodePath(location.path),
+    root: fileSpelling(location.root),
+    project: encodeURIComponent(basename(location.root)),
+    collection: encodeURIComponent(location.collection),
+    line: String(location.line),
+    end_line: String(location.end_line),
+    column: "1",
+  };
+  return template.replace(/\{([^}]*)\}/g, (whole, name: string) => values[name] ?? whole);
+}
+
+/** Editor links for the collections of one server. */
+export class EditorLinks {
+  private readonly roots: Map<string, string>;
+  /** EDITOR_URL, resolved; sessions without editor_url use it */
+  readonly template: string | undefined;
+
+  constructor(config: IndexConfig, spec?: string) {
+    this.roots = new Map(
+      [...config.collections, ...(config.code_collections ?? []), ...(config.dependency_collections ?? [])].map((c) => [c.name, resolve(c.root)])
+    );
+    this.template = spec ? editorTemplate(spec) : undefined;
+  }
+
+  /** The editor link for a location URI, or undefined when it names no indexed file on disk. */
+  linkFor(uri: string, template: string, store: DocumentStore): string | undefined {
+    let location;
+    try {
+      location = parseUri(uri);
+    } catch (err) {
+      if (err instanceof UriError) return undefined;
+      throw err;
+    }
+    if (location.cell) return undefined;
+    const collection = location.collection ?? store.documentsAtPath(location.path)[0]?.collection;
+    const root = collection === undefined ? undefined : this.roots.get(collection);
+    if (root === undefined) return undefined;
+    const line = location.line_start ?? 1;
+    return formatEditorUrl(template, {
+      root,
+      collection: collection!,
+      path: location.path,
+      line,
+      end_line: location.line_end ?? line,
+    });
+  }
+
+  /**
+   * `value` with an `editor_url` next to every location `uri` in it. A
+   * copy: the answer may be shared with the result cache.
+   */
+  addLinks<T>(value: T, template: string, store: DocumentStore): T {
+    if (Array.isArray(value)) return value.map((v) => this.addLinks(v, template, store)) as T;
+    if (value === null || typeof value !== "object") return value;
+    const out: Record<string, unknown> = {};
+    for (const [key, v] of Object.entries(value)) out[key] = this.addLinks(v, template, store);
+    const uri = out.uri;
+    if (typeof uri === "string" && uri.startsWith(URI_PREFIX) && out.editor_url === undefined) {
+      const link = this.linkFor(uri, template, store);
+      if (link) out.editor_url = link;
+    }
+    return out as T;
+  }
+}
@@ -0,0 +1,270 @@
+/**
+ * //go:embed directives and the files they embed — the list_embeds tool
+ *
+ * A directive sits on the lines above a var of type string, []byte, or
+ * embed.FS:
+ *
+ *   //go:embed static templates/*.html
+ *   var assets embed.FS
+ *
+ * Its patterns are resolved the way the go command does: relative to
+ * the package directory, path.Match globs per element, a directory
+ * embedding its whole subtree except names starting with "." or "_"
+ * (kept with the `all:` prefix), and a pattern that matches nothing
+ * failing the build, reported here as `missing`.
+ *
+ * A binary's assets are those of its main package and of every package
+ * it imports from the repository's own modules, transitively (test
+ * files left out), which needs the module graph from go-modules.ts.
+ *
+ * The files a directive matches are listed from disk on each call, since
+ * assets are rarely indexed.
+ */
+
+import { readdir } from "node:fs/promises";
+import { join, posix } from "node:path";
+import type { DocumentMeta, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import type { GoModuleIndex } from "./go-modules";
+import { currentDeadline } from "./deadline";
+import { FileScanCache } from "./scan-cache";
+import { isTestPath } from "./test-paths";
+import { fileImports } from "./cycles";
+
+/** Most files listed from disk per call, over all directives. */
+export const MAX_EMBED_FILES = 10_000;
+
+/** One directive and the variable it fills, as written in a file. */
+export interface EmbedDirective {
+  /** The variable: assets */
+  variable: string;
+  /** string, []byte, or embed.FS */
+  type: string;
+  /** Line of the variable */
+  line: number;
+  /** Line of the first //go:embed above it */
+  directive_line: number;
+  patterns: string[];
+}
+
+export interface Embed extends EmbedDirective {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+  /** Directory of the declaring file */
+  package: string;
+  /** Matched files, relative to the collection root, sorted */
+  files: string[];
+  /** Patterns that match no file; the package does not build */
+  missing: string[];
+}
+
+export interface EmbedReport {
+  embeds: Embed[];
+  /** Distinct files over all embeds */
+  files: number;
+  /** binary: the packages walked, the main package first */
+  packages?: string[];
+  /** The file listing stopped at MAX_EMBED_FILES */
+  truncated: boolean;
+}
+
+export interface EmbedOptions {
+  /** Only directives in files under this path prefix */
+  path?: string;
+  /** A main package's directory: only what that binary embeds */
+  binary?: string;
+  /** Only this variable: name, or pkg.name with pkg the directory's last element */
+  variable?: string;
+}
+
+interface ParsedFile {
+  doc: DocumentMeta;
+  directives: EmbedDirective[];
+  imports: string[];
+}
+
+const NAME = String.raw`[\p{L}_][\p{L}\p{N}_]*`;
+const DIRECTIVE = /^\s*\/\/go:embed\s+(.*)$/;
+const VAR = new RegExp(String.raw`^\s*(?:var\s+)?(${NAME})\s+([^=/]+?)\s*(?:=.*)?(?:\/\/.*)?$`, "u");
+
+/** The patterns of a directive's argument: space-separated, optionally "quoted" or `raw`. */
+export function embedPatterns(args: string): string[] {
+  const patterns: string[] = [];
+  for (const m of args.matchAll(/"((?:\\.|[^"\\])*)"|`([^`]*)`|(\S+)/g)) {
+    patterns.push(m[1] !== undefined ? m[1].replace(/\\(.)/g, "$1") : (m[2] ?? m[3]));
+  }
+  return patterns;
+}
+
+/** The //go:embed directives of a Go source file, each with the var below it. */
+export function parseEmbeds(source: string): EmbedDirective[] {
+  const lines = source.split("\n");
+  const out: EmbedDirective[] = [];
+  let patterns: string[] = [];
+  let first = 0;
+  for (let i = 0; i < lines.length; i++) {
+    const directive = lines[i].match(DIRECTIVE);
+    if (directive) {
+      if (patterns.length === 0) first = i + 1;
+      patterns.push(...embedPatterns(directive[1]));
+      continue;
+    }
+    if (patterns.length === 0) continue;
+    // Other comments and blank lines may sit between the directive and its var
+    if (!lines[i].trim() || /^\s*\/\//.test(lines[i])) continue;
+    const v = lines[i].match(VAR);
+    if (v && !/^(?:var|func|type|const|import|package)$/.test(v[1])) {
+      out.push({ variable: v[1], type: v[2].trim(), line: i + 1, directive_line: first, patterns });
+    }
+    patterns = [];
+  }
+  return out;
+}
+
+/** path.Match for one path element. */
+function elementPattern(glob: string): RegExp {
+  let re = "";
+  for (let i = 0; i < glob.length; i++) {
+    const c = glob[i];
+    if (c === "*") re += "[^/]*";
+    else if (c === "?") re += "[^/]";
+    else if (c === "\\" && i + 1 < glob.length) re += `\\${glob[++i]}`;
+    else if (c === "[") {
+      const end = glob.indexOf("]", i + 1);
+      if (end < 0) return /$^/;
+      const body = glob.slice(i + 1, end).replace(/^\^/, "!").replace(/\\/g, "\\\\");
+      re += body.startsWith("!") ? `[^${body.slice(1)}]` : `[${body}]`;
+      i = end;
+    } else re += c.replace(/[.+^${}()|]/g, "\\$&");
+  }
+  return new RegExp(`^${re}$`);
+}
+
+/** Lists embedded files under the code collections' roots. */
+export class EmbedIndex {
+  private readonly cache: FileScanCache<ParsedFile>;
+
+  constructor(config: IndexConfig, private readonly goModules?: GoModuleIndex) {
+    this.cache = new FileScanCache(config, (source, meta) => ({
+      doc: meta,
+      directives: parseEmbeds(source),
+      imports: fileImports(source, meta.file_path).map((i) => i.spec),
+    }));
+  }
+
+  /**
+   * The embeds matching the options, with their files. Returns null when
+   * `binary` names no directory of indexed Go code.
+   */
+  async list(store: DocumentStore, options: EmbedOptions = {}): Promise<EmbedReport | null> {
+    const files = await this.files(store);
+    const dirOf = (path: string) => posix.dirname(path).replace(/^\.$/, "");
+    let selected = files;
+    let packages: string[] | undefined;
+    if (options.binary !== undefined) {
+      const start = options.binary.replace(/^\.?\/+|\/+$/g, "").replace(/^\.$/, "");
+      const production = files.filter((f) => !isTestPath(f.doc.file_path));
+      const roots = production.filter((f) => dirOf(f.doc.file_path) === start);
+      if (roots.length === 0) return null;
+      packages = await this.closure(production, roots[0].doc.collection, start);
+      const walked = new Set(packages.map((p) => (p === "." ? "" : p)));
+      selected = production.filter((f) => f.doc.collection === roots[0].doc.collection && walked.has(dirOf(f.doc.file_path)));
+    }
+    const prefix = options.path?.replace(/^\.?\/+/, "");
+    if (prefix) selected = selected.filter((f) => f.doc.file_path.startsWith(prefix));
+
+    const embeds: Embed[] = [];
+    const budget = { left: MAX_EMBED_FILES };
+    for (const file of selected) {
+      const dir = dirOf(file.doc.file_path);
+      for (const d of file.directives) {
+        if (options.variable && options.variable !== d.variable && options.variable !== `${posix.basename(dir)}.${d.variable}`) continue;
+        const root = this.cache.root(file.doc.collection)!;
+        const matched = new Set<string>();
+        const missing: string[] = [];
+        for (const pattern of d.patterns) {
+          const found = await this.match(root, dir, pattern, budget);
+          if (found.length === 0 && budget.left > 0) missing.push(pattern);
+          for (const f of found) matched.add(f);
+        }
+        embeds.push({
+          ...d,
+          doc_id: file.doc.doc_id,
+          collection: file.doc.collection,
+          file_path: file.doc.file_path,
+          package: dir || ".",
+          files: [...matched].sort(),
+          missing,
+        });
+      }
+    }
+    const distinct = new Set(embeds.flatMap((e) => e.files.map((f) => `${e.collection}\0${f}`)));
+    return { embeds, files: distinct.size, ...(packages ? { packages } : {}), truncated: budget.left <= 0 };
+  }
+
+  /** The package directories a main package imports from the repository, itself first. */
+  private async closure(files: ParsedFile[], collection: string, start: string): Promise<string[]> {
+    const graph = this.goModules ? await this.goModules.graph() : null;
+    const modules = (graph?.modules ?? []).filter((m) => m.collection === collection);
+    const byDir = new Map<string, ParsedFile[]>();
+    for (const f of files) {
+      if (f.doc.collection !== collection) continue;
+      const dir = posix.dirname(f.doc.file_path).replace(/^\.$/, "");
+      byDir.set(dir, [...(byDir.get(dir) ?? []), f]);
+    }
+    const order = [start];
+    const seen = new Set(order);
+    for (let i = 0; i < order.length; i++) {
+      for (const f of byDir.get(order[i]) ?? []) {
+        for (const spec of f.imports) {
+          const owner = modules
+            .filter((m) => spec === m.module || spec.startsWith(`${m.module}/`))
+            .sort((a, b) => b.module.length - a.module.length)[0];
+          if (!owner) continue;
+          const dir = posix.normalize(posix.join(owner.dir, spec.slice(owner.module.length + 1))).replace(/^\.$|\/+$/g, "");
+          if (seen.has(dir) || !byDir.has(dir)) continue;
+          seen.add(dir);
+          order.push(dir);
+        }
+      }
+    }
+    return order.map((d) => d || ".");
+  }
+
+  /** Files one pattern embeds, relative to the collection root. */
+  private async match(root: string, dir: string, pattern: string, budget: { left: number }): Promise<string[]> {
+    const all = pattern.startsWith("all:");
+    const elements = (all ? pattern.slice(4) : pattern).split("/");
+    if (elements.some((e) => e === "" || e === "." || e === "..")) return [];
+    const out: string[] = [];
+    const walk = async (rel: string, depth: number): Promise<void> => {
+      if (budget.left <= 0 || currentDeadline()?.expired()) return;
+      const entries = await readdir(join(root, rel), { withFileTypes: true }).catch(() => null);
+      if (!entries) return;
+      const glob = depth < elements.length ? elementPattern(elements[depth]) : null;
+      for (const entry of entries.sort((a, b) => a.name.localeCompare(b.name))) {
+        if (entry.isSymbolicLink()) continue;
+        const child = rel ? `${rel}/${entry.name}` : entry.name;
+        if (glob) {
+          if (!glob.test(entry.name)) continue;
+          if (entry.isDirectory()) await walk(child, depth + 1);
+          else if (depth + 1 === elements.length && entry.isFile() && budget.left-- > 0) out.push(child);
+          continue;
+        }
+        // Inside a matched directory: everything but . and _ names, unless all:
+        if (!all && /^[._]/.test(entry.name)) continue;
+        if (entry.isDirectory()) await walk(child, depth + 1);
+        else if (entry.isFile() && budget.left-- > 0) out.push(child);
+      }
+    };
+    await walk(dir, 0);
+    return out;
+  }
+
+  /** Parsed Go files of the store. */
+  private async files(store: DocumentStore): Promise<ParsedFile[]> {
+    const files = await this.cache.scan(store, { accept: (path) => path.endsWith(".go") });
+    return files.sort((a, b) => a.doc.file_path.localeCompare(b.doc.file_path));
+  }
+}
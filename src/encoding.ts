@@ -0,0 +1,204 @@
+/**
+ * Source file encodings — UTF-16 and Latin-1 files, transcoded
+ *
+ * Older Windows-origin codebases hold UTF-16 (often with a BOM) and
+ * Latin-1 files. Read as UTF-8 they turn into noise: NULs between
+ * letters, replacement characters in comments. Every file the indexer
+ * and the code tools read goes through readSource, which detects:
+ *
+ *   utf-8      valid UTF-8, with or without a BOM (the default)
+ *   utf-16le   FF FE BOM, or NULs at odd offsets in the first 4 KB
+ *   utf-16be   FE FF BOM, or NULs at even offsets
+ *   latin1     anything that is not valid UTF-8, decoded as
+ *              windows-1252 (the superset Windows editors write)
+ *
+ * and returns UTF-8-compatible text.
+ *
+ * Line endings: CRLF and lone CR count as one line break, as in LSP.
+ * What is indexed and parsed has them normalized to "\n", so line and
+ * column numbers are the same for a file checked out on Windows and on
+ * Unix. Content hashes stay on the text as read.
+ *
+ * For code files the index keeps an offset map, the byte offset where
+ * each line starts, so a match reported by line and UTF-16 column can
+ * also be given as a byte range in the file as it is on disk
+ * (ContentMatch.byte_start / byte_end). Transcoded files always get
+ * byte ranges; others when BYTE_OFFSETS is on.
+ *
+ * Files written back (structural_replace) are encoded as they were read,
+ * line endings included.
+ */
+
+import { readFile } from "node:fs/promises";
+import type { SourceEncoding } from "./types";
+
+export type { SourceEncoding };
+
+/** A decoded file and how to map its text back to bytes. */
+export interface SourceText {
+  text: string;
+  encoding: SourceEncoding;
+  /** Length in bytes of the byte order mark, 0 when there is none */
+  bom: number;
+}
+
+export class EncodingError extends Error {}
+
+/** Bytes sampled to spot UTF-16 without a BOM */
+const SAMPLE_BYTES = 4096;
+
+/** Share of code units with a NUL half that marks UTF-16 */
+const UTF16_NUL_SHARE = 0.3;
+
+const UTF8 = new TextDecoder("utf-8", { fatal: true, ignoreBOM: true });
+
+/**
+ * windows-1252 for bytes 0x80–0x9F (“ ” € …); the rest is Latin-1. Kept
+ * here because not every runtime's TextDecoder tells the two apart.
+ */
+const CP1252_HIGH = [
+  0x20ac, 0x81, 0x201a, 0x192, 0x201e, 0x2026, 0x2020, 0x2021, 0x2c6, 0x2030, 0x160, 0x2039, 0x152, 0x8d, 0x17d, 0x8f,
+  0x90, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014, 0x2dc, 0x2122, 0x161, 0x203a, 0x153, 0x9d, 0x17e, 0x178,
+];
+const CP1252_BYTES = new Map(CP1252_HIGH.map((code, i) => [code, 0x80 + i]));
+
+function decodeWindows1252(bytes: Uint8Array): string {
+  const chunks: string[] = [];
+  const CHUNK = 8192;
+  for (let at = 0; at < bytes.length; at += CHUNK) {
+    const codes = Array.from(bytes.subarray(at, at + CHUNK), (b) => (b >= 0x80 && b < 0xa0 ? CP1252_HIGH[b - 0x80] : b));
+    chunks.push(String.fromCharCode(...codes));
+  }
+  return chunks.join("");
+}
+
+/** UTF-16 by BOM or by NUL halves in the first SAMPLE_BYTES; null otherwise */
+function utf16Encoding(bytes: Uint8Array): SourceText["encoding"] | null {
+  const sample = bytes.subarray(0, Math.min(bytes.length, SAMPLE_BYTES) & ~1);
+  let even = 0;
+  let odd = 0;
+  for (let i = 0; i < sample.length; i += 2) {
+    if (sample[i] === 0 && sample[i + 1] !== 0) even++;
+    if (sample[i + 1] === 0 && sample[i] !== 0) odd++;
+  }
+  const units = sample.length / 2;
+  if (units && odd / units >= UTF16_NUL_SHARE && odd > even) return "utf-16le";
+  if (units && even / units >= UTF16_NUL_SHARE && even > odd) return "utf-16be";
+  return null;
+}
+
+/** Decode file bytes in whatever encoding they are in. */
+export function decodeSource(bytes: Uint8Array): SourceText {
+  if (bytes[0] === 0xef && bytes[1] === 0xbb && bytes[2] === 0xbf) {
+    return { text: new TextDecoder("utf-8", { ignoreBOM: true }).decode(bytes.subarray(3)), encoding: "utf-8", bom: 3 };
+  }
+  if ((bytes[0] === 0xff && bytes[1] === 0xfe) || (bytes[0] === 0xfe && bytes[1] === 0xff)) {
+    const encoding = bytes[0] === 0xff ? "utf-16le" : "utf-16be";
+    return { text: new TextDecoder(encoding, { ignoreBOM: true }).decode(bytes.subarray(2)), encoding, bom: 2 };
+  }
+  const utf16 = utf16Encoding(bytes);
+  if (utf16) return { text: new TextDecoder(utf16).decode(bytes), encoding: utf16, bom: 0 };
+  try {
+    return { text: UTF8.decode(bytes), encoding: "utf-8", bom: 0 };
+  } catch {
+    return { text: decodeWindows1252(bytes), encoding: "latin1", bom: 0 };
+  }
+}
+
+/** `text` encoded like `like` was read: same encoding, same BOM. */
+export function encodeSource(text: string, like: Pick<SourceText, "encoding" | "bom">): Uint8Array {
+  if (like.encoding === "utf-8") {
+    const body = new TextEncoder().encode(text);
+    return like.bom ? new Uint8Array([0xef, 0xbb, 0xbf, ...body]) : body;
+  }
+  if (like.encoding === "latin1") {
+    const out = new Uint8Array(text.length);
+    for (let i = 0; i < text.length; i++) {
+      const code = text.charCodeAt(i);
+      const byte = code < 0x80 || (code >= 0xa0 && code <= 0xff) ? code : CP1252_BYTES.get(code);
+      if (byte === undefined) {
+        throw new EncodingError(`"${text[i]}" at offset ${i} cannot be written in the file's Latin-1 encoding`);
+      }
+      out[i] = byte;
+    }
+    return out;
+  }
+  const out = new Uint8Array(like.bom + text.length * 2);
+  const view = new DataView(out.buffer);
+  const little = like.encoding === "utf-16le";
+  if (like.bom) view.setUint16(0, 0xfeff, little);
+  for (let i = 0; i < text.length; i++) view.setUint16(like.bom + i * 2, text.charCodeAt(i), little);
+  return out;
+}
+
+/** Read and decode the file at `path`. */
+export async function readSource(path: string): Promise<SourceText> {
+  return decodeSource(await readFile(path));
+}
+
+/** `text` with CRLF and lone CR line breaks rewritten to "\n". */
+export function normalizeLineEndings(text: string): string {
+  return text.includes("\r") ? text.replace(/\r\n?/g, "\n") : text;
+}
+
+/**
+ * The decoded text of the file at `path` with normalized line endings,
+ * for tools that parse it (readSource keeps them, for hashing and
+ * rewriting).
+ */
+export async function readSourceText(path: string): Promise<string> {
+  return normalizeLineEndings((await readSource(path)).text);
+}
+
+// ── Offset map ───────────────────────────────────────────────────────
+
+/** Bytes `text` takes up in `encoding` */
+function encodedLength(encoding: SourceEncoding, text: string): number {
+  if (encoding === "latin1") return text.length;
+  if (encoding !== "utf-8") return text.length * 2;
+  let bytes = 0;
+  for (let i = 0; i < text.length; i++) {
+    const code = text.charCodeAt(i);
+    // A surrogate pair is 4 bytes, 2 per code unit
+    bytes += code < 0x80 ? 1 : code < 0x800 || (code >= 0xd800 && code < 0xe000) ? 2 : 3;
+  }
+  return bytes;
+}
+
+/**
+ * The byte offset where each line of `source` starts in the file, line
+ * breaks counted as in normalizeLineEndings.
+ */
+export function lineByteOffsets(source: SourceText): number[] {
+  const { text, encoding } = source;
+  const offsets = [source.bom];
+  let at = source.bom;
+  let from = 0;
+  for (let i = 0; i < text.length; i++) {
+    const code = text.charCodeAt(i);
+    if (code !== 10 && code !== 13) continue;
+    if (code === 13 && text.charCodeAt(i + 1) === 10) i++;
+    at += encodedLength(encoding, text.slice(from, i + 1));
+    offsets.push(at);
+    from = i + 1;
+  }
+  return offsets;
+}
+
+/**
+ * The byte range in the file of `length` code units at 1-based `line`
+ * and `column`, by the file's line offsets; `lineText` is that line.
+ */
+export function byteRange(
+  encoding: SourceEncoding,
+  offsets: number[],
+  line: number,
+  lineText: string,
+  column: number,
+  length: number
+): { byte_start: number; byte_end: number } | null {
+  const start = offsets[line - 1];
+  if (start === undefined) return null;
+  const byte_start = start + encodedLength(encoding, lineText.slice(0, column - 1));
+  return { byte_start, byte_end: byte_start + encodedLength(encoding, lineText.slice(column - 1, column - 1 + length)) };
+}
@@ -0,0 +1,366 @@
+/**
+ * Entry points and routes — the list_entrypoints tool
+ *
+ * The natural places to start tracing behavior: where a program starts,
+ * which handler answers an HTTP route, which type serves a gRPC service,
+ * and which function runs a CLI command.
+ *
+ *   main           func main() in package main, if __name__ == "__main__",
+ *                  static void main, fn main, int main, require.main
+ *   http_route     Go net/http (including 1.22 "GET /path" patterns),
+ *                  gorilla/mux, chi, gin, echo, fiber; Flask, FastAPI,
+ *                  Django urls.py; Express-style app.get / router.post;
+ *                  Spring @GetMapping; ASP.NET app.MapGet
+ *   grpc_service   RegisterFooServer(s, impl) in Go, Python's
+ *                  add_FooServicer_to_server, addService in Java and Node
+ *   cli_command    cobra.Command{Use: ...}, urfave/cli Command{Name: ...},
+ *                  click / typer @command, argparse add_parser,
+ *                  commander / yargs .command(...)
+ *
+ * Matching is by line over the source, with strings and comments blanked
+ * to tell code from prose; the framework is told apart by the file's
+ * imports. Route prefixes from groups (chi Route, gin Group, Flask
+ * blueprints) are not joined onto the paths.
+ */
+
+import { extname, posix } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { FileScanCache } from "./scan-cache";
+
+export type EntrypointKind = "main" | "http_route" | "grpc_service" | "cli_command";
+
+export const ENTRYPOINT_KINDS: EntrypointKind[] = ["main", "http_route", "grpc_service", "cli_command"];
+
+/** An entry point found in one line of a file. */
+export interface EntrypointHit {
+  kind: EntrypointKind;
+  /** go, net/http, chi, gin, flask, grpc, cobra, click, ... */
+  framework: string;
+  /** "main", "GET /users/{id}", the service, or the command */
+  name: string;
+  method?: string;
+  path?: string;
+  /** The function or type that answers, when the line names one */
+  handler?: string;
+  line: number;
+  text: string;
+}
+
+export interface Entrypoint extends EntrypointHit {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+}
+
+type Found = Omit<EntrypointHit, "line" | "text">;
+
+interface Context {
+  source: string;
+  lines: string[];
+  file_path: string;
+}
+
+interface Rule {
+  pattern: RegExp;
+  read(m: RegExpExecArray, ctx: Context, index: number): Found | null;
+}
+
+/** A quoted string captured as `name`; escapes and any quote style allowed. */
+const str = (name: string) => String.raw`(?<${name}Q>["'\x60])(?<${name}>(?:\\.|(?!\k<${name}Q>)[^\\])*)\k<${name}Q>`;
+
+/** The handler argument after a route's path, captured when it is a name */
+const HANDLER_NAME = String.raw`\s*(?:&\s*)?(?<handler>[\p{L}_$][\p{L}\p{N}_$.]*)`;
+const HANDLER = String.raw`(?:\s*,${HANDLER_NAME})?`;
+
+const HTTP_METHODS = "GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|CONNECT|TRACE";
+
+/** A route path: starts with / or, for Go 1.22 patterns, a method or host first. */
+const looksLikePath = (path: string) => /^(?:[A-Z]+\s+)?[\w.-]*\/\S*$/.test(path);
+
+/** Lines ahead of `index` (the line itself included), for literals spread over lines. */
+const ahead = (ctx: Context, index: number, n = 15) => ctx.lines.slice(index, index + n);
+
+/** The next def / function name at or after `index`, skipping decorators. */
+function nextDef(ctx: Context, index: number, pattern: RegExp): string | undefined {
+  for (const line of ahead(ctx, index + 1, 10)) {
+    const m = line.match(pattern);
+    if (m) return m[1];
+  }
+  return undefined;
+}
+
+const PY_DEF = /^\s*(?:async\s+)?def\s+(\w+)/;
+
+function route(framework: string, method: string | undefined, path: string, handler?: string): Found {
+  return {
+    kind: "http_route",
+    framework,
+    name: method ? `${method} ${path}` : path,
+    ...(method ? { method } : {}),
+    path,
+    ...(handler ? { handler } : {}),
+  };
+}
+
+// ── Go ───────────────────────────────────────────────────────────────
+
+function goRouter(ctx: Context, fallback: string): string {
+  if (ctx.source.includes("github.com/gin-gonic/gin")) return "gin";
+  if (ctx.source.includes("github.com/labstack/echo")) return "echo";
+  if (ctx.source.includes("github.com/gofiber/fiber")) return "fiber";
+  if (ctx.source.includes("github.com/go-chi/chi")) return "chi";
+  if (ctx.source.includes("github.com/gorilla/mux")) return "gorilla/mux";
+  return fallback;
+}
+
+const GO_RULES: Rule[] = [
+  {
+    pattern: /^func\s+main\s*\(\s*\)/,
+    read: (_m, ctx) => (/^package\s+main\b/m.test(ctx.source) ? { kind: "main", framework: "go", name: "main" } : null),
+  },
+  {
+    // r.Method("GET", "/p", h) in chi, r.Handle("GET", "/p", h) in gin
+    pattern: new RegExp(String.raw`\b\w+\.(?:Method|MethodFunc|Handle|Add)\(\s*${str("method")}\s*,\s*${str("path")}${HANDLER}`, "u"),
+    read: (m, ctx) =>
+      /^[A-Z]+$/.test(m.groups!.method) ? route(goRouter(ctx, "chi"), m.groups!.method, m.groups!.path, m.groups!.handler) : null,
+  },
+  {
+    // http.HandleFunc("GET /users/{id}", h), mux.Handle("/p", h), gorilla's .Methods("GET")
+    pattern: new RegExp(String.raw`\b(?<recv>\w+)\.(?:HandleFunc|Handle)\(\s*${str("path")}${HANDLER}`, "u"),
+    read: (m, ctx, i) => {
+      const { recv, path, handler } = m.groups!;
+      if (!looksLikePath(path)) return null;
+      const split = path.match(/^([A-Z]+)\s+(\S+)$/);
+      const methods = ctx.lines[i].match(/\.Methods\(\s*"([A-Z]+)"/);
+      const framework = recv === "http" ? "net/http" : goRouter(ctx, "net/http");
+      return route(framework, split?.[1] ?? methods?.[1], split?.[2] ?? path, handler);
+    },
+  },
+  {
+    // gin and echo: r.GET("/p", h)
+    pattern: new RegExp(String.raw`\b\w+\.(?<method>${HTTP_METHODS}|Any)\(\s*${str("path")}${HANDLER}`, "u"),
+    read: (m, ctx) =>
+      looksLikePath(m.groups!.path)
+        ? route(goRouter(ctx, "gin"), m.groups!.method === "Any" ? undefined : m.groups!.method, m.groups!.path, m.groups!.handler)
+        : null,
+  },
+  {
+    // chi and fiber: r.Get("/p", h); the path must look like one, so cache.Get("key") is left alone
+    pattern: new RegExp(String.raw`\b\w+\.(?<method>Get|Post|Put|Patch|Delete|Head|Options|Connect|Trace|All)\(\s*${str("path")}\s*,(?:${HANDLER_NAME})?`, "u"),
+    read: (m, ctx) =>
+      m.groups!.path.startsWith("/")
+        ? route(goRouter(ctx, "chi"), m.groups!.method === "All" ? undefined : m.groups!.method.toUpperCase(), m.groups!.path, m.groups!.handler)
+        : null,
+  },
+  {
+    // pb.RegisterUserServiceServer(s, &server{}); grpc-gateway's Register...HandlerServer is a proxy, not a service
+    pattern: /\bRegister(\w+)Server\(\s*[\w.]+\s*,\s*(?:&\s*)?([\w.]+)/,
+    read: (m) => (m[1].endsWith("Handler") ? null : { kind: "grpc_service", framework: "grpc", name: m[1], handler: m[2] }),
+  },
+  {
+    pattern: /\bcobra\.Command\s*\{/,
+    read: (_m, ctx, i) => {
+      const use = ahead(ctx, i).join("\n").match(/\bUse:\s*"([^"]*)"/);
+      return use ? { kind: "cli_command", framework: "cobra", name: use[1].split(/\s+/)[0] } : null;
+    },
+  },
+  {
+    pattern: /\bcli\.Command\s*\{/,
+    read: (_m, ctx, i) => {
+      const name = ahead(ctx, i).join("\n").match(/\bName:\s*"([^"]*)"/);
+      return name ? { kind: "cli_command", framework: "urfave/cli", name: name[1] } : null;
+    },
+  },
+];
+
+// ── Python ───────────────────────────────────────────────────────────
+
+const PY_RULES: Rule[] = [
+  {
+    pattern: /^if\s+__name__\s*==\s*["']__main__["']\s*:/,
+    read: () => ({ kind: "main", framework: "python", name: "__main__" }),
+  },
+  {
+    // @app.route("/p", methods=["POST"]), @router.get("/p")
+    pattern: new RegExp(String.raw`^\s*@\w+\.(?<verb>route|get|post|put|patch|delete|head|options|api_route|websocket)\(\s*${str("path")}`),
+    read: (m, ctx, i) => {
+      const { verb, path } = m.groups!;
+      const framework = /^\s*(?:from|import)\s+fastapi\b/m.test(ctx.source) ? "fastapi" : "flask";
+      const listed = ctx.lines[i].match(/methods\s*=\s*\[([^\]]*)\]/)?.[1].match(/[A-Za-z]+/g);
+      const method =
+        verb === "route" || verb === "api_route" ? listed?.map((v) => v.toUpperCase()).join(",") : verb === "websocket" ? "WS" : verb.toUpperCase();
+      return route(framework, method, path, nextDef(ctx, i, PY_DEF));
+    },
+  },
+  {
+    // Django: path("users/<int:id>/", views.user) in urls.py
+    pattern: new RegExp(String.raw`\b(?:re_)?path\(\s*${str("path")}${HANDLER}`, "u"),
+    read: (m, ctx) =>
+      posix.basename(ctx.file_path) === "urls.py" ? route("django", undefined, `/${m.groups!.path.replace(/^\^?\/?/, "")}`, m.groups!.handler) : null,
+  },
+  {
+    pattern: /\badd_(\w+)Servicer_to_server\(\s*([\w.]+)/,
+    read: (m) => ({ kind: "grpc_service", framework: "grpc", name: m[1], handler: m[2].replace(/\(.*$/, "") }),
+  },
+  {
+    // click, typer: @cli.command(), @click.command("name"), @app.command(name="x")
+    pattern: /^\s*@(?<recv>\w+)\.(?<verb>command|group)\(\s*(?:name\s*=\s*)?(?:["'](?<name>[^"']+)["'])?/,
+    read: (m, ctx, i) => {
+      const name = m.groups!.name ?? nextDef(ctx, i, PY_DEF)?.replace(/_/g, "-");
+      if (!name) return null;
+      const framework = /^\s*(?:from|import)\s+typer\b/m.test(ctx.source) ? "typer" : "click";
+      return { kind: "cli_command", framework, name, ...(m.groups!.name ? {} : { handler: nextDef(ctx, i, PY_DEF) }) };
+    },
+  },
+  {
+    pattern: /\.add_parser\(\s*["']([^"']+)["']/,
+    read: (m) => ({ kind: "cli_command", framework: "argparse", name: m[1] }),
+  },
+];
+
+// ── JavaScript / TypeScript ──────────────────────────────────────────
+
+const JS_RULES: Rule[] = [
+  {
+    pattern: /\brequire\.main\s*===\s*module\b/,
+    read: () => ({ kind: "main", framework: "node", name: "main" }),
+  },
+  {
+    // app.get("/p", handler), router.post('/p', ...)
+    pattern: new RegExp(String.raw`\b(?<recv>app|api|server|router|\w+Router|fastify)\.(?<method>get|post|put|patch|delete|head|options|all)\(\s*${str("path")}${HANDLER}`, "u"),
+    read: (m, ctx) => {
+      const { method, path, handler } = m.groups!;
+      if (!path.startsWith("/")) return null;
+      const framework = /from\s+["']fastify["']|require\(\s*["']fastify["']\s*\)/.test(ctx.source)
+        ? "fastify"
+        : /["']koa-router["']|["']@koa\/router["']/.test(ctx.source)
+          ? "koa"
+          : "express";
+      return route(framework, method === "all" ? undefined : method.toUpperCase(), path, handler);
+    },
+  },
+  {
+    pattern: /\.addService\(\s*([\w.]+)/,
+    read: (m) => ({ kind: "grpc_service", framework: "grpc", name: m[1].replace(/\.service$/, "").split(".").pop()! }),
+  },
+  {
+    pattern: /\.command\(\s*["'`]([^"'`]+)["'`]/,
+    read: (m, ctx) => ({
+      kind: "cli_command",
+      framework: /["']yargs(?:\/[\w/]+)?["']/.test(ctx.source) ? "yargs" : "commander",
+      name: m[1].split(/\s+/)[0],
+    }),
+  },
+];
+
+// ── JVM, .NET, Rust, C ───────────────────────────────────────────────
+
+const JAVA_RULES: Rule[] = [
+  {
+    pattern: /\bstatic\s+void\s+main\s*\(\s*(?:final\s+)?String/,
+    read: () => ({ kind: "main", framework: "java", name: "main" }),
+  },
+  {
+    pattern: /^\s*fun\s+main\s*\(/,
+    read: () => ({ kind: "main", framework: "kotlin", name: "main" }),
+  },
+  {
+    // Spring: @GetMapping("/p"), @RequestMapping(value = "/p", method = RequestMethod.POST)
+    pattern: new RegExp(String.raw`@(?<verb>Get|Post|Put|Patch|Delete|Request)Mapping\(\s*(?:(?:value|path)\s*=\s*)?${str("path")}`),
+    read: (m, ctx, i) => {
+      const { verb, path } = m.groups!;
+      const method = verb === "Request" ? ctx.lines[i].match(/RequestMethod\.([A-Z]+)/)?.[1] : verb.toUpperCase();
+      return route("spring", method, path, nextDef(ctx, i, /^\s*(?!@)(?:[\w<>[\],.?]+\s+)+(\w+)\s*\(/));
+    },
+  },
+  {
+    pattern: /\.addService\(\s*(?:new\s+)?([\w.]+)/,
+    read: (m) => ({ kind: "grpc_service", framework: "grpc", name: m[1].split(".").pop()!, handler: m[1] }),
+  },
+];
+
+const CSHARP_RULES: Rule[] = [
+  {
+    pattern: /\bstatic\s+(?:async\s+)?[\w<>]+\s+Main\s*\(/,
+    read: () => ({ kind: "main", framework: "dotnet", name: "Main" }),
+  },
+  {
+    pattern: new RegExp(String.raw`\.Map(?<verb>Get|Post|Put|Patch|Delete)\(\s*${str("path")}${HANDLER}`, "u"),
+    read: (m) => route("aspnet", m.groups!.verb.toUpperCase(), m.groups!.path, m.groups!.handler),
+  },
+  {
+    pattern: /\.MapGrpcService<([\w.]+)>/,
+    read: (m) => ({ kind: "grpc_service", framework: "grpc", name: m[1].split(".").pop()!, handler: m[1] }),
+  },
+];
+
+const RUST_RULES: Rule[] = [
+  {
+    pattern: /^\s*(?:pub\s+)?(?:async\s+)?fn\s+main\s*\(/,
+    read: () => ({ kind: "main", framework: "rust", name: "main" }),
+  },
+];
+
+const C_RULES: Rule[] = [
+  {
+    pattern: /^\s*(?:int|void)\s+main\s*\(/,
+    read: () => ({ kind: "main", framework: "c", name: "main" }),
+  },
+];
+
+const RULES: Record<string, Rule[]> = {
+  ".go": GO_RULES,
+  ".py": PY_RULES,
+  ".ts": JS_RULES, ".tsx": JS_RULES, ".mts": JS_RULES, ".cts": JS_RULES,
+  ".js": JS_RULES, ".jsx": JS_RULES, ".mjs": JS_RULES, ".cjs": JS_RULES,
+  ".java": JAVA_RULES, ".kt": JAVA_RULES, ".kts": JAVA_RULES, ".scala": JAVA_RULES,
+  ".cs": CSHARP_RULES,
+  ".rs": RUST_RULES,
+  ".c": C_RULES, ".cc": C_RULES, ".cpp": C_RULES, ".cxx": C_RULES,
+};
+
+/** Entry points in a source file, in line order; at most one per line. */
+export function scanEntrypoints(source: string, filePath: string): EntrypointHit[] {
+  const rules = RULES[extname(filePath).toLowerCase()];
+  if (!rules) return [];
+  const lines = source.split("\n");
+  const blanked = blankLiterals(source, hashCommentsFor(filePath)).split("\n");
+  const ctx: Context = { source, lines, file_path: filePath };
+  const hits: EntrypointHit[] = [];
+  for (let i = 0; i < lines.length; i++) {
+    if (!blanked[i].trim()) continue;
+    for (const rule of rules) {
+      const m = rule.pattern.exec(lines[i]);
+      if (!m) continue;
+      // The match must start in code, not in a comment or string
+      const start = m.index + m[0].length - m[0].trimStart().length;
+      if (blanked[i][start] === " ") continue;
+      const found = rule.read(m, ctx, i);
+      if (!found) continue;
+      hits.push({ ...found, line: i + 1, text: lines[i].trim() });
+      break;
+    }
+  }
+  return hits;
+}
+
+/** Entry points across the code documents of a store. */
+export class EntrypointIndex {
+  private readonly files: FileScanCache<Entrypoint[]>;
+
+  constructor(config: IndexConfig) {
+    this.files = new FileScanCache(config, (source, meta) =>
+      scanEntrypoints(source, meta.file_path).map(
+        (hit): Entrypoint => ({ ...hit, doc_id: meta.doc_id, collection: meta.collection, file_path: meta.file_path })
+      )
+    );
+  }
+
+  /** Every entry point in the indexed code, optionally under a path prefix, in file and line order. */
+  async scan(store: DocumentStore, pathPrefix?: string): Promise<Entrypoint[]> {
+    const files = await this.files.scan(store, { pathPrefix, accept: (path) => !!RULES[extname(path).toLowerCase()] });
+    return files.flat().sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+  }
+}
@@ -0,0 +1,441 @@
+/**
+ * Enum-like value sets and where their values go — the enum_usages tool
+ *
+ * A set is a closed list of named values of one type:
+ *
+ *   Go          typed constants: const ( StateAlive NodeState = iota; ... ),
+ *               the bare lines after a typed one included
+ *   TypeScript  enum NodeState { Alive, Dead }
+ *   Java        enum NodeState { ALIVE, DEAD; ... }
+ *   Python      class NodeState(Enum): ALIVE = 1
+ *
+ * Each reference to a value is `consumed` when it is tested (==, !=,
+ * is, a case label) and `produced` otherwise: assigned, returned,
+ * passed, or stored. A switch (or Python match) whose cases name values
+ * of a set is checked against the whole set; the values it leaves out
+ * are `missing`, and without a default it is `incomplete`.
+ *
+ * References are lexical over blanked source. In Go a bare name counts
+ * inside the defining package and `alias.Name` in files importing it;
+ * elsewhere only `NodeState.Alive`, and bare names on Java case labels.
+ */
+
+import { posix } from "node:path";
+import type { DocumentMeta, IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols, type CodeSymbol } from "./code-indexer";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+import { goImports, languageFamily } from "./usage";
+
+export type EnumRole = "produced" | "consumed";
+
+export interface EnumValue {
+  name: string;
+  line: number;
+  /** The expression after =, when the declaration has one */
+  value?: string;
+}
+
+export interface EnumSet {
+  /** The type: NodeState */
+  name: string;
+  language: string;
+  doc_id: string;
+  collection: string;
+  file_path: string;
+  /** The first value's line for Go, else the declaration's */
+  line: number;
+  /** Directory of the declaring file */
+  package: string;
+  values: EnumValue[];
+}
+
+export interface EnumSite {
+  set: string;
+  value: string;
+  role: EnumRole;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  text: string;
+  /** Qualified name of the function it is in; absent at top level */
+  function?: string;
+}
+
+export interface EnumSwitch {
+  set: string;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  function?: string;
+  /** Values named by its cases, in declaration order */
+  covered: string[];
+  /** Values of the set no case names */
+  missing: string[];
+  has_default: boolean;
+  /** Values are missing and no default catches them */
+  incomplete: boolean;
+}
+
+export interface EnumReport {
+  sets: Array<EnumSet & { values: Array<EnumValue & { produced: number; consumed: number }> }>;
+  /** Code files scanned */
+  files: number;
+  /** Every reference, in file and line order */
+  sites: EnumSite[];
+  /** Switches over the sets, in file and line order */
+  switches: EnumSwitch[];
+}
+
+export interface EnumOptions {
+  /** Only references and switches in files under this path prefix */
+  path?: string;
+  /** References in test files: counted (default), left out, or the only ones counted */
+  include_tests?: IncludeTests;
+}
+
+interface ParsedFile {
+  doc: DocumentMeta;
+  lines: string[];
+  blanked: string[];
+  symbols: CodeSymbol[];
+  imports: Map<string, string>;
+  sets: EnumSet[];
+}
+
+const WORD = String.raw`[\p{L}_$][\p{L}\p{N}_$]*`;
+
+/** Go's predeclared types: constants of these are plain values, not a set. */
+const GO_BASIC = new Set([
+  "bool", "byte", "complex64", "complex128", "float32", "float64", "int", "int8", "int16", "int32", "int64",
+  "rune", "string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+]);
+
+const PY_ENUM_BASE = /^class\s+\w+\s*\(\s*(?:[\w.]*\.)?(?:Enum|IntEnum|StrEnum|Flag|IntFlag|\w*Enum)\s*\)/;
+
+/**
+ * The sets declared in one file. `blanked` has literals blanked; `lines`
+ * is the source, for the values' expressions.
+ */
+export function parseEnumSets(
+  lines: string[],
+  blanked: string[],
+  symbols: CodeSymbol[],
+  meta: Pick<DocumentMeta, "doc_id" | "collection" | "file_path">
+): EnumSet[] {
+  const language = languageFamily(meta.file_path).replace(/^\./, "");
+  const dir = posix.dirname(meta.file_path).replace(/^\.$/, "");
+  const sets = new Map<string, EnumSet>();
+  const add = (type: string, value: EnumValue, line: number) => {
+    if (value.name === "_") return;
+    let set = sets.get(type);
+    if (!set) {
+      set = {
+        name: type,
+        language,
+        doc_id: meta.doc_id,
+        collection: meta.collection,
+        file_path: meta.file_path,
+        line,
+        package: dir || ".",
+        values: [],
+      };
+      sets.set(type, set);
+    }
+    set.values.push(value);
+  };
+  const expression = (i: number) => {
+    const eq = lines[i].indexOf("=");
+    const v = eq >= 0 ? lines[i].slice(eq + 1).replace(/\/\/.*$/, "").trim() : "";
+    return v ? { value: v } : {};
+  };
+
+  if (meta.file_path.endsWith(".go")) {
+    const member = new RegExp(String.raw`^\s*(${WORD}(?:\s*,\s*${WORD})*)(?:\s+([\p{L}_][\p{L}\p{N}_.]*))?\s*(=.*)?$`, "u");
+    let inBlock = false;
+    let type: string | undefined;
+    for (let i = 0; i < blanked.length; i++) {
+      const line = blanked[i];
+      const single = line.match(new RegExp(String.raw`^const\s+(${WORD})\s+([\p{L}_][\p{L}\p{N}_]*)\s*=`, "u"));
+      if (!inBlock && single) {
+        if (!GO_BASIC.has(single[2])) add(single[2], { name: single[1], line: i + 1, ...expression(i) }, i + 1);
+        continue;
+      }
+      if (!inBlock) {
+        if (/^const\s*\(\s*$/.test(line)) {
+          inBlock = true;
+          type = undefined;
+        }
+        continue;
+      }
+      if (/^\)/.test(line)) {
+        inBlock = false;
+        continue;
+      }
+      if (!line.trim()) continue;
+      const m = line.match(member);
+      if (!m) continue;
+      // A typed line starts a run; `X = expr` without a type ends it; a bare name repeats it
+      if (m[2]) type = m[2].includes(".") || GO_BASIC.has(m[2]) ? undefined : m[2];
+      else if (m[3]) type = undefined;
+      if (!type) continue;
+      for (const name of m[1].split(/\s*,\s*/)) add(type, { name, line: i + 1, ...(m[3] ? expression(i) : {}) }, i + 1);
+    }
+    return [...sets.values()];
+  }
+
+  const python = language === "py";
+  if (!python && language !== "js" && language !== "jvm") return [];
+  for (const s of symbols) {
+    if (python ? s.kind !== "class" || !PY_ENUM_BASE.test(blanked[s.line_start - 1].trim()) : s.kind !== "enum") continue;
+    if (python) {
+      const indent = blanked.slice(s.line_start, s.line_end).find((l) => l.trim())?.match(/^\s*/)![0] ?? "";
+      for (let i = s.line_start; i < s.line_end; i++) {
+        const m = blanked[i].match(new RegExp(String.raw`^${indent}(${WORD})\s*(?::\s*[^=]+)?=(?!=)`, "u"));
+        if (m && !m[1].startsWith("_")) add(s.name, { name: m[1], line: i + 1, ...expression(i) }, s.line_start);
+      }
+      continue;
+    }
+    // TypeScript and Java: the members between { and the first ; or }
+    let depth = 0;
+    let opened = false;
+    let done = false;
+    for (let i = s.line_start - 1; i < s.line_end && !done; i++) {
+      // Only what sits directly in the body: names, not arguments or bodies
+      let text = "";
+      for (const c of blanked[i]) {
+        if (c === "{" || c === "(" || c === "[") {
+          opened ||= depth === 0 && c === "{";
+          depth++;
+        } else if (c === "}" || c === ")" || c === "]") {
+          depth--;
+          if (opened && depth === 0) done = true;
+        } else if (opened && depth === 1 && c === ";") {
+          done = true;
+        }
+        if (done) break;
+        text += opened && depth === 1 && !"{([".includes(c) ? c : " ";
+      }
+      for (const m of text.matchAll(new RegExp(String.raw`(?:^|,)\s*(${WORD})\s*(?:=[^,]*)?(?=,|\s*$)`, "gu"))) {
+        // text keeps the source's columns, so the expression is read back from the line
+        const eq = m[0].indexOf("=");
+        const value = eq >= 0 ? lines[i].slice(m.index! + eq + 1, m.index! + m[0].length).trim() : "";
+        add(s.name, { name: m[1], line: i + 1, ...(value ? { value } : {}) }, s.line_start);
+      }
+    }
+  }
+  return [...sets.values()];
+}
+
+interface SwitchBlock {
+  line: number;
+  /** Case labels, each a list of alternatives, as written (blanked) */
+  cases: string[][];
+  has_default: boolean;
+}
+
+/** Switch statements (and Python match statements) of a file, from its blanked lines. */
+export function switchBlocks(blanked: string[], python: boolean): SwitchBlock[] {
+  const blocks: SwitchBlock[] = [];
+  for (let i = 0; i < blanked.length; i++) {
+    const line = blanked[i];
+    if (python) {
+      const m = line.match(/^(\s*)match\s+.+:\s*$/);
+      if (!m) continue;
+      const block: SwitchBlock = { line: i + 1, cases: [], has_default: false };
+      for (let j = i + 1; j < blanked.length; j++) {
+        if (!blanked[j].trim()) continue;
+        if (blanked[j].match(/^\s*/)![0].length <= m[1].length) break;
+        const c = blanked[j].match(/^\s*case\s+(.+?)\s*(?:\bif\b.*)?:\s*$/);
+        if (!c) continue;
+        if (c[1] === "_") block.has_default = true;
+        else block.cases.push(c[1].split("|").map((p) => p.trim()));
+      }
+      blocks.push(block);
+      continue;
+    }
+    if (!/(?:^|[\s;}])switch\b[^{]*\{\s*$/.test(line) || /\.\s*\(\s*type\s*\)/.test(line)) continue;
+    const block: SwitchBlock = { line: i + 1, cases: [], has_default: false };
+    let depth = 0;
+    for (let j = i; j < blanked.length; j++) {
+      const at = depth;
+      if (j > i && at === 1) {
+        const c = blanked[j].match(/^\s*case\s+(.+?)\s*(?::|->)/);
+        if (c) block.cases.push(c[1].split(",").map((p) => p.trim()));
+        else if (/^\s*default\s*(?::|->)/.test(blanked[j])) block.has_default = true;
+      }
+      for (const c of blanked[j]) {
+        if (c === "{") depth++;
+        else if (c === "}") depth--;
+      }
+      if (j > i && depth <= 0) break;
+    }
+    blocks.push(block);
+  }
+  return blocks;
+}
+
+/** Enum sets and their values' uses over the code collections of a store. */
+export class EnumIndex {
+  private readonly cache: FileScanCache<ParsedFile>;
+
+  constructor(config: IndexConfig) {
+    this.cache = new FileScanCache(config, (source, meta) => {
+      const lines = source.split("\n");
+      const blanked = blankLiterals(source, hashCommentsFor(meta.file_path)).split("\n");
+      const symbols = parseCodeSymbols(source, meta.file_path);
+      return {
+        doc: meta,
+        lines,
+        blanked,
+        symbols,
+        imports: meta.file_path.endsWith(".go") ? goImports(source) : new Map(),
+        sets: parseEnumSets(lines, blanked, symbols, meta),
+      };
+    });
+  }
+
+  /**
+   * The sets named `name` (a type, or pkg.Type to pick one by its
+   * directory), or every set when omitted, with each value's references
+   * and the switches over them. Returns null when no set has that name.
+   */
+  async usages(store: DocumentStore, name?: string, options: EnumOptions = {}): Promise<EnumReport | null> {
+    const files = await this.files(store);
+    const dot = name?.lastIndexOf(".") ?? -1;
+    const type = name !== undefined && dot > 0 ? name.slice(dot + 1) : name;
+    const pkg = name !== undefined && dot > 0 ? name.slice(0, dot) : undefined;
+    const sets = files
+      .flatMap((f) => f.sets)
+      .filter((s) => type === undefined || (s.name === type && (!pkg || s.package === pkg || posix.basename(s.package) === pkg)));
+    if (name !== undefined && sets.length === 0) return null;
+
+    const prefix = options.path?.replace(/^\.?\/+/, "");
+    const sites: EnumSite[] = [];
+    const switches: EnumSwitch[] = [];
+    const counts = new Map<EnumSet, Map<string, { produced: number; consumed: number }>>(
+      sets.map((s) => [s, new Map(s.values.map((v) => [v.name, { produced: 0, consumed: 0 }]))])
+    );
+    for (const file of files) {
+      if (prefix && !file.doc.file_path.startsWith(prefix)) continue;
+      if (!matchesTests(file.doc.file_path, options.include_tests)) continue;
+      const family = languageFamily(file.doc.file_path);
+      const mine = sets.filter((s) => languageFamily(s.file_path) === family);
+      if (mine.length === 0) continue;
+      const functionAt = this.functions(file);
+
+      for (const set of mine) {
+        const byName = new Map(set.values.map((v) => [v.name, v]));
+        const declared = new Set(set.file_path === file.doc.file_path ? set.values.map((v) => v.line) : []);
+        const qualifiers = this.qualifiers(file, set);
+        for (let i = 0; i < file.blanked.length; i++) {
+          if (declared.has(i + 1)) continue;
+          const line = file.blanked[i];
+          const caseLine = /^\s*case\b/.test(line);
+          for (const m of line.matchAll(new RegExp(WORD, "gu"))) {
+            if (!byName.has(m[0])) continue;
+            const index = m.index!;
+            if (line[index - 1] && /[\p{L}\p{N}_$]/u.test(line[index - 1])) continue;
+            const qualifier = line.slice(0, index).match(new RegExp(String.raw`(${WORD})\s*\.\s*$`, "u"));
+            if (qualifier ? !qualifiers.qualified.has(qualifier[1]) : !(qualifiers.bare || (caseLine && qualifiers.bareCases))) continue;
+            const start = qualifier ? index - qualifier[0].length : index;
+            const end = index + m[0].length;
+            const consumed =
+              caseLine ||
+              /(?:[!=]==?|\bis(?:\s+not)?|\bnot\s+in|\bin)\s*$/.test(line.slice(0, start)) ||
+              /^\s*(?:[!=]==?|\bis\b)/.test(line.slice(end));
+            const role: EnumRole = consumed ? "consumed" : "produced";
+            counts.get(set)!.get(m[0])![role]++;
+            const fn = functionAt(i + 1);
+            sites.push({
+              set: set.name,
+              value: m[0],
+              role,
+              doc_id: file.doc.doc_id,
+              file_path: file.doc.file_path,
+              line: i + 1,
+              text: file.lines[i].trim(),
+              ...(fn ? { function: fn } : {}),
+            });
+          }
+        }
+      }
+
+      for (const block of switchBlocks(file.blanked, family === "py")) {
+        // The set whose values the cases name most
+        let best: { set: EnumSet; covered: Set<string> } | undefined;
+        for (const set of mine) {
+          const names = new Set(set.values.map((v) => v.name));
+          const covered = new Set(
+            block.cases.flat().map((label) => label.replace(new RegExp(String.raw`^(?:${WORD}\s*\.\s*)*`, "u"), "")).filter((n) => names.has(n))
+          );
+          if (covered.size > (best?.covered.size ?? 0)) best = { set, covered };
+        }
+        if (!best) continue;
+        const missing = best.set.values.map((v) => v.name).filter((n) => !best!.covered.has(n));
+        const fn = functionAt(block.line);
+        switches.push({
+          set: best.set.name,
+          doc_id: file.doc.doc_id,
+          file_path: file.doc.file_path,
+          line: block.line,
+          ...(fn ? { function: fn } : {}),
+          covered: best.set.values.map((v) => v.name).filter((n) => best!.covered.has(n)),
+          missing,
+          has_default: block.has_default,
+          incomplete: missing.length > 0 && !block.has_default,
+        });
+      }
+    }
+
+    return {
+      sets: sets.map((s) => ({ ...s, values: s.values.map((v) => ({ ...v, ...counts.get(s)!.get(v.name)! })) })),
+      files: files.length,
+      sites: sites.sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line),
+      switches,
+    };
+  }
+
+  /**
+   * How `file` may name the values of `set`: bare (inside the defining
+   * Go package, or anywhere for a dot import), with one of `qualified`
+   * in front, or bare on case labels (Java).
+   */
+  private qualifiers(file: ParsedFile, set: EnumSet): { bare: boolean; bareCases: boolean; qualified: Set<string> } {
+    if (!set.file_path.endsWith(".go")) {
+      return { bare: false, bareCases: file.doc.file_path.endsWith(".java"), qualified: new Set([set.name]) };
+    }
+    const dir = posix.dirname(file.doc.file_path).replace(/^\.$/, "") || ".";
+    if (dir === set.package && file.doc.collection === set.collection) return { bare: true, bareCases: true, qualified: new Set() };
+    const qualified = new Set<string>();
+    let dotted = false;
+    for (const [alias, path] of file.imports) {
+      if (path !== set.package && !path.endsWith(`/${set.package}`)) continue;
+      if (alias === ".") dotted = true;
+      else qualified.add(alias);
+    }
+    return { bare: dotted, bareCases: dotted, qualified };
+  }
+
+  /** A lookup from line to the qualified name of the innermost function around it. */
+  private functions(file: ParsedFile): (line: number) => string | undefined {
+    const byId = new Map(file.symbols.map((s) => [s.id, s]));
+    const functions = file.symbols.filter((s) => s.kind === "function" || s.kind === "method");
+    return (line) => {
+      const fn = functions
+        .filter((f) => f.line_start <= line && line <= f.line_end)
+        .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start))[0];
+      if (!fn) return undefined;
+      const parent = fn.parent_id ? byId.get(fn.parent_id) : undefined;
+      return parent ? `${parent.name}.${fn.name}` : fn.name;
+    };
+  }
+
+  /** Parsed code files of the store. */
+  private async files(store: DocumentStore): Promise<ParsedFile[]> {
+    const files = await this.cache.scan(store);
+    return files.sort((a, b) => a.doc.file_path.localeCompare(b.doc.file_path));
+  }
+}
@@ -0,0 +1,318 @@
+/**
+ * Reads and writes of one field of a type — the field_references tool
+ *
+ * "Who sets NodeInfo.State?" is a question text search answers badly:
+ * State is a common name, and a match cannot tell `n.State = s` from
+ * `if n.State == s`. Each `.State` selector is sorted by where it sits
+ * in its statement:
+ *
+ *   write    left of an assignment (=, +=, ...), ++ / --, or del
+ *   init     a key in a literal of the type (NodeInfo{State: s}), or a
+ *            keyword argument to its constructor (NodeInfo(state=s))
+ *   address  &n.State, which may be written through
+ *   read     anything else, n.State.Reset() included
+ *
+ * A selector is `typed` when its receiver is known to hold the type in
+ * the enclosing function: a Go receiver, parameter, or var of the type,
+ * a literal or new(T) assigned to it, a TypeScript or Python
+ * annotation, a Java declaration, or this / self in the type's own
+ * methods; or a struct field of the type declared in the same file, as
+ * in m.Info.State. Other `.State` selectors in the same language are
+ * reported untyped: they may still be the field, reached through a
+ * call's result, an embedding, or a field declared elsewhere.
+ *
+ * Matching is on source with strings and comments blanked.
+ */
+
+import { posix } from "node:path";
+import type { DocumentMeta, IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols, type CodeSymbol, type SymbolKind } from "./code-indexer";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+import { languageFamily } from "./usage";
+
+export type FieldAccess = "read" | "write" | "init" | "address";
+
+export const FIELD_ACCESSES: FieldAccess[] = ["read", "write", "init", "address"];
+
+/** Bad targets: no type, or no field, in the name. */
+export class FieldError extends Error {}
+
+const TYPE_KINDS = new Set<SymbolKind>(["class", "interface", "type"]);
+const WORD = String.raw`[\p{L}_$][\p{L}\p{N}_$]*`;
+const QUALIFIER = String.raw`(?:[\p{L}_][\p{L}\p{N}_]*\.)?`;
+
+export interface FieldSite {
+  access: FieldAccess;
+  /** The receiver is known to hold the type */
+  typed: boolean;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  text: string;
+  /** Qualified name of the function it is in; absent at top level */
+  function?: string;
+}
+
+export interface FieldReport {
+  /** Type.Field as asked */
+  target: string;
+  types: Array<{ name: string; kind: SymbolKind; doc_id: string; file_path: string; line: number }>;
+  /** Where the types declare the field; empty when it is promoted or dynamic */
+  declarations: Array<{ doc_id: string; file_path: string; line: number; text: string }>;
+  /** Code files scanned */
+  files: number;
+  total: number;
+  by_access: Record<FieldAccess, number>;
+  /** Sites whose receiver is known to hold the type */
+  typed: number;
+  /** In file and line order */
+  sites: FieldSite[];
+}
+
+export interface FieldOptions {
+  /** Sites in test files: counted (default), left out, or the only ones counted */
+  include_tests?: IncludeTests;
+  /** Only sites whose receiver is known to hold the type */
+  typed_only?: boolean;
+}
+
+interface ParsedFile {
+  doc: DocumentMeta;
+  lines: string[];
+  blanked: string[];
+  symbols: CodeSymbol[];
+}
+
+/**
+ * The access a selector makes: `line` is blanked of literals, `start`
+ * is where the selector's receiver begins and `end` where the field
+ * name ends. Null for a method call, which is not the field.
+ */
+export function classifyFieldAccess(line: string, start: number, end: number): FieldAccess | null {
+  const before = line.slice(0, start);
+  const after = line.slice(end);
+  if (/^\s*\(/.test(after)) return null;
+  if (/(?:^|[^&])&\s*$/.test(before)) return "address";
+  if (/^\s*(?:\+\+|--)/.test(after) || /(?:\+\+|--)\s*$/.test(before) || /^\s*del\s+$/.test(before)) return "write";
+  // Only other assignment targets between the statement start and the operator
+  const target = String.raw`[\p{L}_$*][\p{L}\p{N}_$.\[\]*]*`;
+  const lhsBefore = new RegExp(String.raw`^\s*(?:${target}\s*,\s*)*$`, "u");
+  const lhsAfter = new RegExp(String.raw`^\s*(?:,\s*${target}\s*)*(?:[-+*/%&|^]|<<|>>|&\^|\*\*|//|\?\?)?=(?!=)`, "u");
+  return lhsBefore.test(before.replace(/^\s*(?:\}\s*)?/, "")) && lhsAfter.test(after) ? "write" : "read";
+}
+
+/** Names bound to `type` in `text`: parameters, declarations, literals, and annotations. */
+export function typedNames(text: string, type: string): Set<string> {
+  const t = `${QUALIFIER}${type.replace(/\$/g, "\\$")}(?![\\p{L}\\p{N}_$])`;
+  const names = new Set<string>();
+  const patterns = [
+    // Go receivers, parameters, and vars: (n *NodeInfo), a, b NodeInfo, var n NodeInfo
+    String.raw`((?:${WORD}\s*,\s*)*${WORD})\s+\*?${t}(?!\s*[.({])`,
+    // Literals and constructors: n := &NodeInfo{, n = new NodeInfo(, n = NodeInfo(
+    String.raw`(${WORD})\s*:?=\s*&?\s*(?:new\s+)?${t}\s*[{(]`,
+    String.raw`(${WORD})\s*:?=\s*new\(\s*${t}\s*\)`,
+    // Annotations: n: NodeInfo, n?: NodeInfo
+    String.raw`(${WORD})\s*\??:\s*${t}(?!\s*[.({])`,
+    // Java, C#, C++ declarations: NodeInfo n = ..., (NodeInfo n)
+    String.raw`${t}\s*[*&]?\s+(${WORD})\s*[=;,)]`,
+  ];
+  for (const pattern of patterns) {
+    for (const m of text.matchAll(new RegExp(pattern, "gu"))) {
+      for (const name of m[1].split(/\s*,\s*/)) if (!/^(?:var|const|let|func|return|type|new)$/.test(name)) names.add(name);
+    }
+  }
+  return names;
+}
+
+/**
+ * Whether `line`, blanked, inside the body of a type declares `field`:
+ * name first in Go, Rust, TypeScript, and Python, type first in Java
+ * and C-likes.
+ */
+export function declaresField(line: string, field: string, go: boolean): boolean {
+  const f = field.replace(/\$/g, "\\$");
+  if (go) return new RegExp(String.raw`^\s*(?:${WORD}\s*,\s*)*${f}(?:\s*,\s*${WORD})*\s+[^\s(=,]`, "u").test(line);
+  const modifiers = String.raw`(?:(?:public|private|protected|readonly|static|declare|final|override|abstract|internal|pub(?:\([^)]*\))?)\s+)*`;
+  return (
+    new RegExp(String.raw`^\s*${modifiers}${f}\s*[?!]?\s*[:=;]`, "u").test(line) ||
+    new RegExp(String.raw`^\s*${modifiers}[\p{L}_$][\p{L}\p{N}_$<>\[\]?,.]*\s+${f}\s*[=;,]`, "u").test(line)
+  );
+}
+
+/** Whether column `at` of line `i` sits directly inside a `{ ... }` opened right after `type`. */
+function inLiteralOf(blanked: string[], i: number, at: number, type: RegExp): boolean {
+  let depth = 0;
+  for (let j = i; j >= 0 && j > i - 50; j--) {
+    const line = j === i ? blanked[j].slice(0, at) : blanked[j];
+    for (let k = line.length - 1; k >= 0; k--) {
+      const c = line[k];
+      if (c === "}" || c === ")" || c === "]") depth++;
+      else if (c === "{" || c === "(" || c === "[") {
+        if (depth === 0) return c === "{" && type.test(line.slice(0, k));
+        depth--;
+      }
+    }
+  }
+  return false;
+}
+
+/** Field queries over the code collections of a store. */
+export class FieldReferences {
+  private readonly cache: FileScanCache<ParsedFile>;
+
+  constructor(config: IndexConfig) {
+    this.cache = new FileScanCache(config, (source, meta) => ({
+      doc: meta,
+      lines: source.split("\n"),
+      blanked: blankLiterals(source, hashCommentsFor(meta.file_path)).split("\n"),
+      symbols: parseCodeSymbols(source, meta.file_path),
+    }));
+  }
+
+  /**
+   * Reads and writes of `target`: Type.Field, or pkg.Type.Field to pick
+   * the type by its directory. Throws FieldError when the name has no
+   * type part; returns null when no type by that name is defined.
+   */
+  async find(store: DocumentStore, target: string, options: FieldOptions = {}): Promise<FieldReport | null> {
+    const parts = target.split(".");
+    if (parts.length < 2 || parts.some((p) => !p)) {
+      throw new FieldError(`"${target}" names no type; pass Type.Field, e.g. NodeInfo.State`);
+    }
+    const field = parts.at(-1)!;
+    const type = parts.at(-2)!;
+    const pkg = parts.length > 2 ? parts.slice(0, -2).join(".") : undefined;
+
+    const files = await this.files(store);
+    const types: FieldReport["types"] = [];
+    const declarations: FieldReport["declarations"] = [];
+    const families = new Set<string>();
+    const assigned = new RegExp(String.raw`(?:self|this)\.${field.replace(/\$/g, "\\$")}\s*(?::[^=]+)?=(?!=)`, "u");
+    for (const file of files) {
+      const dir = posix.dirname(file.doc.file_path);
+      if (pkg && dir !== pkg && posix.basename(dir) !== pkg && !dir.endsWith(`/${pkg}`)) continue;
+      for (const s of file.symbols) {
+        if (s.name !== type || !TYPE_KINDS.has(s.kind)) continue;
+        types.push({ name: s.name, kind: s.kind, doc_id: file.doc.doc_id, file_path: file.doc.file_path, line: s.line_start });
+        families.add(languageFamily(file.doc.file_path));
+        const go = file.doc.file_path.endsWith(".go");
+        const methods = file.symbols.filter((m) => m.parent_id === s.id && m.kind === "method");
+        for (let line = s.line_start + 1; line < s.line_end; line++) {
+          const method = methods.find((m) => m.line_start <= line && line <= m.line_end);
+          // Inside methods only constructors declare, by assigning: self.state = ...
+          const declares = method
+            ? /^(?:__init__|constructor)$/.test(method.name) && assigned.test(file.blanked[line - 1])
+            : declaresField(file.blanked[line - 1], field, go);
+          if (declares) {
+            declarations.push({ doc_id: file.doc.doc_id, file_path: file.doc.file_path, line, text: file.lines[line - 1].trim() });
+          }
+        }
+      }
+    }
+    if (types.length === 0) return null;
+
+    const escaped = field.replace(/\$/g, "\\$");
+    const selector = new RegExp(String.raw`(?<![\p{L}\p{N}_$])(${WORD})?\s*\.\s*${escaped}(?![\p{L}\p{N}_$])`, "gu");
+    const key = new RegExp(String.raw`(?<![\p{L}\p{N}_$.])${escaped}\s*:(?!=)`, "gu");
+    const literal = new RegExp(String.raw`(?<![\p{L}\p{N}_$])${QUALIFIER}${type}\s*$`, "u");
+    const keyword = new RegExp(String.raw`(?<![\p{L}\p{N}_$])${QUALIFIER}${type}\s*\([^()]*(?<![\p{L}\p{N}_$])${escaped}\s*=(?!=)`, "u");
+
+    const sites: FieldSite[] = [];
+    for (const file of files) {
+      if (!families.has(languageFamily(file.doc.file_path))) continue;
+      if (!matchesTests(file.doc.file_path, options.include_tests)) continue;
+      const scopes = this.scopes(file, type);
+      const literals = /\.(?:go|rs)$/.test(file.doc.file_path);
+      for (let i = 0; i < file.blanked.length; i++) {
+        const line = file.blanked[i];
+        if (!line.includes(field)) continue;
+        let at: ReturnType<typeof scopes> | undefined;
+        const scope = () => (at ??= scopes(i + 1));
+        const site = (access: FieldAccess, typed: boolean) => {
+          if (options.typed_only && !typed) return;
+          sites.push({
+            access,
+            typed,
+            doc_id: file.doc.doc_id,
+            file_path: file.doc.file_path,
+            line: i + 1,
+            text: file.lines[i].trim(),
+            ...(scope().function ? { function: scope().function } : {}),
+          });
+        };
+        for (const m of line.matchAll(selector)) {
+          const receiver = m[1];
+          const end = m.index! + m[0].length;
+          // The whole selector chain, a[i].b.State, for the statement around it
+          let start = m.index!;
+          while (start > 0 && /[\p{L}\p{N}_$.\]\[]/u.test(line[start - 1])) start--;
+          const access = classifyFieldAccess(line, start, end);
+          if (!access) continue;
+          // A chained receiver, m.Info.State, counts when Info is a field or var of the type
+          site(access, receiver !== undefined && scope().names.has(receiver));
+        }
+        if (literals) {
+          for (const m of line.matchAll(key)) {
+            if (inLiteralOf(file.blanked, i, m.index!, literal)) site("init", true);
+          }
+        } else if (keyword.test(line)) {
+          site("init", true);
+        }
+      }
+    }
+
+    const by_access = Object.fromEntries(FIELD_ACCESSES.map((a) => [a, 0])) as Record<FieldAccess, number>;
+    for (const s of sites) by_access[s.access]++;
+    return {
+      target,
+      types,
+      declarations,
+      files: files.length,
+      total: sites.length,
+      by_access,
+      typed: sites.filter((s) => s.typed).length,
+      sites,
+    };
+  }
+
+  /** For a line of `file`: the names bound to `type` there, and the function it is in. */
+  private scopes(file: ParsedFile, type: string): (line: number) => { names: Set<string>; function?: string } {
+    const byId = new Map(file.symbols.map((s) => [s.id, s]));
+    const functions = file.symbols.filter((s) => s.kind === "function" || s.kind === "method");
+    const bound = new Map<CodeSymbol, Set<string>>();
+    const namesIn = (fn: CodeSymbol) => {
+      let names = bound.get(fn);
+      if (!names) {
+        names = typedNames(file.blanked.slice(fn.line_start - 1, fn.line_end).join("\n"), type);
+        const owner = fn.parent_id ? byId.get(fn.parent_id) : undefined;
+        if (owner?.name === type && TYPE_KINDS.has(owner.kind)) names.add("this").add("self");
+        bound.set(fn, names);
+      }
+      return names;
+    };
+    let outside: Set<string> | undefined;
+    return (line) => {
+      outside ??= typedNames(
+        file.blanked.filter((_, i) => !functions.some((f) => f.line_start <= i + 1 && i + 1 <= f.line_end)).join("\n"),
+        type
+      );
+      const around = functions
+        .filter((f) => f.line_start <= line && line <= f.line_end)
+        .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start));
+      const names = new Set(outside);
+      for (const fn of around) for (const name of namesIn(fn)) names.add(name);
+      const inner = around[0];
+      const parent = inner?.parent_id ? byId.get(inner.parent_id) : undefined;
+      return { names, ...(inner ? { function: parent ? `${parent.name}.${inner.name}` : inner.name } : {}) };
+    };
+  }
+
+  /** Parsed code files of the store. */
+  private async files(store: DocumentStore): Promise<ParsedFile[]> {
+    const files = await this.cache.scan(store);
+    return files.sort((a, b) => a.doc.file_path.localeCompare(b.doc.file_path));
+  }
+}
@@ -0,0 +1,128 @@
+/**
+ * Generated and minified files — the GENERATED_POLICY setting
+ *
+ * Protobuf stubs, `go generate` output, bundled JavaScript and lockfiles
+ * are indexed like any source file, but nobody reads or edits them:
+ * their thousands of identifiers crowd the hand-written code out of
+ * search results. Every code file is classified when it is indexed, and
+ * the kind is kept in its DocumentMeta as `generated`:
+ *
+ *   lockfile   package-lock.json, yarn.lock, Cargo.lock, go.sum, ...
+ *   generated  a generator's name pattern (*.pb.go, *_pb2.py,
+ *              zz_generated.*.go, *.Designer.cs) or header ("Code
+ *              generated ... DO NOT EDIT", "@generated") in the first lines
+ *   minified   *.min.js / *.min.css, or JavaScript and CSS whose lines
+ *              average more than MINIFIED_LINE_LENGTH characters
+ *
+ * The policy decides what search does with them:
+ *
+ *   index     rank them like any other file
+ *   downrank  multiply their scores by GENERATED_WEIGHT (the default)
+ *   exclude   leave them out of search_documents and find_symbol
+ *
+ * Under every policy they stay in the index, so get_tree and
+ * get_node_content still open them and list_documents shows the kind.
+ *
+ * A Go generator's header also names what it was run on — protoc's
+ * "// source: api/user.proto", mockgen's "// Source: store.go
+ * (interfaces: Store)", stringer's "-type=Pill" — kept as
+ * `generated_from` so find_symbol can point from a generated symbol to
+ * its input (generator-links.ts).
+ */
+
+import { basename, extname } from "node:path";
+import type { GeneratedKind, GeneratedPolicy, GeneratorOrigin } from "./types";
+
+export const GENERATED_POLICIES: GeneratedPolicy[] = ["index", "downrank", "exclude"];
+export const DEFAULT_GENERATED_POLICY: GeneratedPolicy = "downrank";
+
+/** Score multiplier for generated files under the downrank policy */
+export const GENERATED_WEIGHT = 0.1;
+
+/** Average line length above which JavaScript or CSS counts as minified */
+export const MINIFIED_LINE_LENGTH = 300;
+
+/** Smaller files are never called minified: a one-line module is just short */
+const MINIFIED_MIN_CHARS = 2048;
+
+/** Leading lines searched for a generator's header */
+const HEADER_LINES = 20;
+
+const LOCKFILES = new Set([
+  "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml", "bun.lock", "bun.lockb",
+  "Cargo.lock", "Gemfile.lock", "Podfile.lock", "composer.lock", "poetry.lock", "Pipfile.lock", "uv.lock",
+  "go.sum", "flake.lock", "mix.lock", "packages.lock.json",
+]);
+
+const GENERATED_NAMES = [
+  /\.pb(?:\.gw|\.validate)?\.go$/, /_grpc\.pb\.go$/, /^zz_generated\..*\.go$/, /_generated\.go$/,
+  /_pb2(?:_grpc)?\.pyi?$/, /_pb\.(?:js|d\.ts)$/, /\.pb\.(?:h|cc)$/, /_grpc_pb\.js$/,
+  /\.(?:designer|g|g\.i)\.cs$/i, /\.generated\.\w+$/,
+];
+
+/**
+ * Go's convention (https://go.dev/s/generatedcode), plus the @generated
+ * tag of Phabricator-era tools and the phrases other generators write
+ */
+const GENERATED_HEADERS = [
+  /^\/\/ Code generated .* DO NOT EDIT\.$/m,
+  /@generated\b/,
+  /\bDO NOT EDIT\b/,
+  /\b(?:auto-?generated|automatically generated|generated by)\b.*\b(?:do not|don't) (?:edit|modify)\b/i,
+];
+
+const MINIFIABLE = new Set([".js", ".mjs", ".cjs", ".css"]);
+
+/**
+ * The kind of machine-written file `relPath` with contents `source` is,
+ * or null for a hand-written one.
+ */
+export function detectGenerated(relPath: string, source: string): GeneratedKind | null {
+  const name = basename(relPath);
+  if (LOCKFILES.has(name)) return "lockfile";
+  if (/\.min\.(?:js|css)$/.test(name)) return "minified";
+  if (GENERATED_NAMES.some((re) => re.test(name))) return "generated";
+
+  const head = source.split("\n", HEADER_LINES).join("\n");
+  if (GENERATED_HEADERS.some((re) => re.test(head))) return "generated";
+
+  if (MINIFIABLE.has(extname(name).toLowerCase()) && source.length >= MINIFIED_MIN_CHARS) {
+    const lines = source.split("\n").filter((l) => l.trim()).length;
+    if (source.length / Math.max(lines, 1) > MINIFIED_LINE_LENGTH) return "minified";
+  }
+  return null;
+}
+
+const GENERATED_BY = /^\/\/ Code generated by (?:"([^"]*)"|(\S+?))\.?;? DO NOT EDIT\.?$/m;
+const PROTO_SOURCE = /^\/\/ source: (\S+\.proto)$/m;
+const MOCKGEN_SOURCE = /^\/\/ Source: (\S+)(?: \(interfaces: ([^)]*)\))?$/m;
+
+/**
+ * The generator a Go file's "Code generated by" header names, with its
+ * input when the header gives one, or null without such a header.
+ */
+export function generatorOrigin(source: string): GeneratorOrigin | null {
+  const head = source.split("\n", HEADER_LINES).join("\n");
+  const m = head.match(GENERATED_BY);
+  if (!m) return null;
+  // stringer quotes its command line: "stringer -type=Pill,Color"
+  const command = m[1]?.trim().split(/\s+/) ?? [m[2]];
+  const generator = command[0] === "MockGen" ? "mockgen" : command[0];
+  if (!generator) return null;
+  const origin: GeneratorOrigin = { generator };
+  if (generator.startsWith("protoc-gen-")) {
+    const proto = head.match(PROTO_SOURCE);
+    if (proto) origin.source = proto[1];
+  } else if (generator === "mockgen") {
+    const mock = head.match(MOCKGEN_SOURCE);
+    if (mock) origin.source = mock[1];
+    const types = mock?.[2]?.split(",").map((t) => t.trim()).filter(Boolean);
+    if (types?.length) origin.types = types;
+  } else if (generator === "stringer") {
+    const flag = command.findIndex((a) => /^--?type(?:=|$)/.test(a));
+    const value = flag < 0 ? undefined : command[flag].includes("=") ? command[flag].split("=")[1] : command[flag + 1];
+    const types = value?.split(",").filter(Boolean);
+    if (types?.length) origin.types = types;
+  }
+  return origin;
+}
@@ -0,0 +1,220 @@
+/**
+ * Generated code back to its generator's input — find_symbol's
+ * `generator_input`
+ *
+ * A symbol found in a .pb.go file, a mock, or a stringer file is rarely
+ * the one to read or change: the source of truth is the .proto message,
+ * the mocked interface, or the constant's type. The generator header
+ * kept at index time (generated_from, see generated.ts) names the
+ * input; this maps the generated name onto the declaration in it:
+ *
+ *   protoc-gen-go       User, (*User).GetName, User_Status
+ *                       → message User, field name, enum Status
+ *   protoc-gen-go-grpc  UserServiceClient, RegisterUserServiceServer,
+ *                       (*userServiceClient).Get → service UserService, rpc Get
+ *   mockgen             MockStore, (*MockStore).Get, NewMockStore
+ *                       → interface Store, its method Get
+ *   stringer            (Pill).String, _Pill_name → type Pill
+ *
+ * A .proto path is relative to protoc's include path, which is not
+ * recorded, so it is tried under each directory from the generated
+ * file's up to the collection root. .proto files are not indexed; they
+ * are read from disk and have no uri. mockgen's source is a Go file
+ * (found the same way) or an import path, matched against the indexed
+ * package directories by suffix; stringer's is the type's declaration
+ * in the generated file's own package.
+ */
+
+import { existsSync, statSync } from "node:fs";
+import { join, posix, resolve } from "node:path";
+import type { DocumentMeta, GeneratorOrigin, IndexConfig, TreeNode } from "./types";
+import type { DocumentStore } from "./store";
+import { readSourceText } from "./encoding";
+
+/** Where a generated symbol comes from. */
+export interface GeneratorTarget {
+  generator: string;
+  /** The input, relative to the collection root; as the header names it when not found */
+  file_path: string;
+  /** The input exists under the collection root */
+  found: boolean;
+  /** The declaration in the input: message User, rpc Get, interface Store, method Get */
+  symbol?: string;
+  line?: number;
+  /** When the input is indexed */
+  doc_id?: string;
+}
+
+const GRPC_AFFIX = /^(?:Unimplemented|Unsafe|New|Register)(?=[A-Z])|(?:Client|Server)$/g;
+
+/** The declaring name and the receiver of a Go symbol node. */
+function goName(store: DocumentStore, doc_id: string, node: TreeNode): { name: string; receiver?: string } {
+  const name = node.title.slice(node.title.indexOf(" ") + 1);
+  if (node.parent_id) {
+    const parent = store.getNodeContent(doc_id, [node.parent_id])?.nodes[0];
+    if (parent) return { name, receiver: parent.title.slice(parent.title.indexOf(" ") + 1) };
+  }
+  const receiver = node.summary.match(/^func\s+\(\s*(?:\w+\s+)?\*?(\w+)/)?.[1];
+  return receiver ? { name, receiver } : { name };
+}
+
+/** lowerCamel or UpperCamel to the snake_case protoc names fields with. */
+function snakeCase(name: string): string {
+  return name.replace(/(?<=[a-z0-9])([A-Z])/g, "_$1").toLowerCase();
+}
+
+/** First line index of `re` at or after `from`, or -1. */
+function findLine(lines: string[], re: RegExp, from = 0): number {
+  for (let i = from; i < lines.length; i++) if (re.test(lines[i])) return i;
+  return -1;
+}
+
+/** Resolves generated symbols to their input in the code collections. */
+export class GeneratorLinks {
+  private readonly roots: Map<string, string>;
+  private protos = new Map<string, { mtime: number; lines: string[] }>();
+
+  constructor(config: IndexConfig) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /**
+   * The input a symbol of generated document `doc_id` comes from, or
+   * null when the document records no generator or one not known here.
+   */
+  async target(store: DocumentStore, doc_id: string, node_id: string): Promise<GeneratorTarget | null> {
+    const meta = store.getDocMeta(doc_id);
+    const origin = meta?.generated_from;
+    const node = store.getNodeContent(doc_id, [node_id])?.nodes[0];
+    if (!meta || !origin || !node || !meta.file_path.endsWith(".go")) return null;
+    const { name, receiver } = goName(store, doc_id, node);
+    if (origin.generator === "protoc-gen-go" || origin.generator === "protoc-gen-go-grpc") {
+      return origin.source ? this.proto(meta, origin, name, receiver) : null;
+    }
+    if (origin.generator === "mockgen") return this.mock(store, meta, origin, name, receiver);
+    if (origin.generator === "stringer") return this.stringer(store, meta, origin, name, receiver);
+    return null;
+  }
+
+  /** The message, enum, field, service, or rpc of a .proto file. */
+  private async proto(meta: DocumentMeta, origin: GeneratorOrigin, name: string, receiver?: string): Promise<GeneratorTarget> {
+    const source = origin.source!;
+    const root = this.roots.get(meta.collection);
+    const path = root ? this.candidates(meta.file_path, source).find((p) => existsSync(join(root, p))) : undefined;
+    const target: GeneratorTarget = { generator: origin.generator, file_path: path ?? source, found: path !== undefined };
+    const lines = path && root ? await this.protoLines(join(root, path)) : null;
+    if (!lines) return target;
+
+    const at = (re: RegExp, from = 0) => {
+      const i = findLine(lines, re, from);
+      return i < 0 ? null : i;
+    };
+    const decl = (keyword: string, id: string) => at(new RegExp(String.raw`^\s*${keyword}\s+${id}\b`));
+    const owner = (receiver ?? name).replace(/^_/, "");
+    if (origin.generator === "protoc-gen-go-grpc") {
+      const [head, member] = owner.replace(/^[a-z]/, (c) => c.toUpperCase()).split("_");
+      const service = head.replace(GRPC_AFFIX, "");
+      const start = decl("service", service);
+      if (start === null) return target;
+      const rpc = receiver ? name : member?.replace(GRPC_AFFIX, "");
+      const line = rpc && rpc !== "ServiceDesc" ? at(new RegExp(String.raw`^\s*rpc\s+${rpc}\s*\(`), start) : null;
+      return line !== null
+        ? { ...target, symbol: `rpc ${rpc}`, line: line + 1 }
+        : { ...target, symbol: `service ${service}`, line: start + 1 };
+    }
+    // Nested declarations are joined with _: User_Status is Status inside User
+    const parts = owner.split("_");
+    for (let i = parts.length - 1; i >= 0; i--) {
+      for (const keyword of ["message", "enum"]) {
+        const start = decl(keyword, parts[i]);
+        if (start === null) continue;
+        const field = receiver && keyword === "message" && name.match(/^Get([A-Z]\w*)$/)?.[1];
+        const line = field ? at(new RegExp(String.raw`\b${snakeCase(field)}\s*=\s*\d+`), start) : null;
+        return line !== null
+          ? { ...target, symbol: `field ${snakeCase(field as string)}`, line: line + 1 }
+          : { ...target, symbol: `${keyword} ${parts[i]}`, line: start + 1 };
+      }
+    }
+    return target;
+  }
+
+  /** The mocked interface, or its method. */
+  private mock(store: DocumentStore, meta: DocumentMeta, origin: GeneratorOrigin, name: string, receiver?: string): GeneratorTarget | null {
+    const iface = (receiver ?? name).replace(/^(?:New)?Mock/, "").replace(/MockRecorder$/, "");
+    if (!iface || (origin.types && !origin.types.includes(iface))) return null;
+    const docs = origin.source?.endsWith(".go")
+      ? this.candidates(meta.file_path, origin.source)
+          .flatMap((p) => store.documentsAtPath(p))
+          .filter((d) => d.collection === meta.collection)
+          .slice(0, 1)
+      : this.packageDocs(store, meta, origin.source);
+    const found = this.declaration(store, docs, `interface ${iface}`, receiver ? name : undefined);
+    if (found) return { generator: origin.generator, ...found };
+    const file_path = docs[0]?.file_path ?? origin.source;
+    return file_path ? { generator: origin.generator, file_path, found: docs.length > 0, ...(docs[0] ? { doc_id: docs[0].doc_id } : {}) } : null;
+  }
+
+  /** The type stringer was run for, in the generated file's package. */
+  private stringer(store: DocumentStore, meta: DocumentMeta, origin: GeneratorOrigin, name: string, receiver?: string): GeneratorTarget | null {
+    const type = receiver ?? name.match(/^_(\w+?)_(?:name|index|map|lower)\w*$/)?.[1] ?? (name === "_" ? origin.types?.[0] : undefined);
+    if (!type || (origin.types && !origin.types.includes(type))) return null;
+    const dir = posix.dirname(meta.file_path);
+    const docs = store
+      .listDocuments({ filters: { content_type: "code" }, limit: Infinity })
+      .documents.filter((d) => d.collection === meta.collection && !d.generated && posix.dirname(d.file_path) === dir);
+    const found = this.declaration(store, docs, `type ${type}`) ?? this.declaration(store, docs, `class ${type}`);
+    return found ? { generator: origin.generator, ...found } : null;
+  }
+
+  /** The node titled `title` in one of `docs`, or its `member` line. */
+  private declaration(store: DocumentStore, docs: DocumentMeta[], title: string, member?: string): Omit<GeneratorTarget, "generator"> | null {
+    for (const doc of docs) {
+      const node = store.getTree(doc.doc_id)?.nodes.find((n) => n.title === title);
+      const full = node && store.getNodeContent(doc.doc_id, [node.node_id])?.nodes[0];
+      if (!full) continue;
+      const target = { file_path: doc.file_path, found: true, doc_id: doc.doc_id, symbol: title, line: full.line_start };
+      const i = member ? findLine(full.content.split("\n"), new RegExp(String.raw`^\s*${member}\s*\(`)) : -1;
+      return i < 0 ? target : { ...target, symbol: `method ${member}`, line: full.line_start + i };
+    }
+    return null;
+  }
+
+  /** Indexed Go files of the package an import path names, matched by directory suffix. */
+  private packageDocs(store: DocumentStore, meta: DocumentMeta, importPath?: string): DocumentMeta[] {
+    if (!importPath) return [];
+    const go = store
+      .listDocuments({ filters: { content_type: "code" }, limit: Infinity })
+      .documents.filter((d) => d.collection === meta.collection && d.file_path.endsWith(".go") && !d.generated);
+    const dirs = new Set(go.map((d) => posix.dirname(d.file_path)).filter((d) => d !== "."));
+    const dir = [...dirs]
+      .filter((d) => importPath === d || importPath.endsWith(`/${d}`))
+      .sort((a, b) => b.length - a.length)[0];
+    return dir ? go.filter((d) => posix.dirname(d.file_path) === dir) : [];
+  }
+
+  /** `source` under each directory from the generated file's up to the root, then beside it. */
+  private candidates(generated: string, source: string): string[] {
+    const out: string[] = [];
+    let dir = posix.dirname(generated);
+    for (;;) {
+      out.push(posix.normalize(dir === "." ? source : posix.join(dir, source)));
+      if (dir === ".") break;
+      dir = posix.dirname(dir);
+    }
+    out.push(posix.join(posix.dirname(generated), posix.basename(source)));
+    return [...new Set(out)].filter((p) => !p.startsWith("../"));
+  }
+
+  /** A .proto file's lines, re-read when its mtime changes. */
+  private async protoLines(abs: string): Promise<string[] | null> {
+    const mtime = statSync(abs, { throwIfNoEntry: false })?.mtimeMs;
+    if (mtime === undefined) return null;
+    const cached = this.protos.get(abs);
+    if (cached?.mtime === mtime) return cached.lines;
+    const text = await readSourceText(abs).catch(() => null);
+    if (text === null) return null;
+    const lines = text.split("\n");
+    this.protos.set(abs, { mtime, lines });
+    return lines;
+  }
+}
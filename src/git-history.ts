@@ -0,0 +1,305 @@
+/**
+ * Commit times from git history — the recency ranking signal
+ *
+ * Two files that match a query equally well are not equally useful
+ * when one was touched last week and the other has not changed in five
+ * years. With RECENCY_WEIGHT set, each file's last commit time becomes
+ * a score multiplier that decays with age:
+ *
+ *   1 + RECENCY_WEIGHT × 0.5^(age / RECENCY_HALF_LIFE_DAYS)
+ *
+ * so a file committed today gets the full boost, one a half-life old
+ * half of it, and long-dead files fade to 1.0. The signal only breaks
+ * near-ties; it never outweighs a clearly better lexical match at the
+ * small weights it is meant for (0.1–0.3).
+ *
+ * File mtimes are no substitute: a fresh clone or `git checkout` sets
+ * every mtime to "now". Times come from one `git log` pass per
+ * collection root, newest commit first, so the first time a path is
+ * seen is its last change. Files git does not know about (untracked,
+ * or a root outside any repository) get no boost.
+ */
+
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { spawnTimeout } from "./deadline";
+import { decodeSource, normalizeLineEndings } from "./encoding";
+
+export const DEFAULT_RECENCY_HALF_LIFE_DAYS = 180;
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+/**
+ * Last commit time (epoch ms) of every file under `root`, keyed by its
+ * "/"-separated path relative to `root`. Empty when `root` is not in a
+ * git work tree or git is unavailable.
+ */
+export function gitCommitTimes(root: string): Map<string, number> {
+  const times = new Map<string, number>();
+  let result;
+  try {
+    result = Bun.spawnSync(
+      ["git", "-C", root, "-c", "core.quotePath=false", "log", "--format=%x00%ct", "--name-only", "--no-renames", "--relative", "--", "."],
+      { stdout: "pipe", stderr: "ignore" }
+    );
+  } catch {
+    return times;
+  }
+  if (!result.success) return times;
+
+  let commitTime = 0;
+  for (const line of result.stdout.toString().split("\n")) {
+    if (line.startsWith("\0")) {
+      commitTime = parseInt(line.slice(1), 10) * 1000;
+    } else if (line && !times.has(line)) {
+      times.set(line, commitTime);
+    }
+  }
+  return times;
+}
+
+/**
+ * Files under `root` changed by the last `commits` commits that touch
+ * it, most recently changed first, as "/"-separated paths relative to
+ * `root`. Files those commits deleted are included. Empty when `root`
+ * is not in a git work tree or git is unavailable.
+ */
+export function gitRecentFiles(root: string, commits: number): string[] {
+  let result;
+  try {
+    result = Bun.spawnSync(
+      ["git", "-C", root, "-c", "core.quotePath=false", "log", `-n${commits}`, "--format=", "--name-only", "--no-renames", "--relative", "--", "."],
+      { stdout: "pipe", stderr: "ignore" }
+    );
+  } catch {
+    return [];
+  }
+  if (!result.success) return [];
+  return [...new Set(result.stdout.toString().split("\n").filter(Boolean))];
+}
+
+/** Score multiplier for a file last committed at `committedAt`. */
+export function recencyMultiplier(
+  committedAt: number,
+  weight: number,
+  halfLifeDays: number = DEFAULT_RECENCY_HALF_LIFE_DAYS,
+  now: number = Date.now()
+): number {
+  const ageDays = Math.max(0, now - committedAt) / DAY_MS;
+  return 1 + weight * Math.pow(0.5, ageDays / halfLifeDays);
+}
+
+/**
+ * Turn on the recency signal for `store`, reading commit times for
+ * every collection in `config`. Returns the number of files with a
+ * known commit time; 0 means no collection root is under git.
+ */
+export function applyRecencyBoost(
+  store: DocumentStore,
+  config: IndexConfig,
+  weight: number,
+  halfLifeDays: number = DEFAULT_RECENCY_HALF_LIFE_DAYS
+): number {
+  let files = 0;
+  for (const collection of [...config.collections, ...(config.code_collections ?? [])]) {
+    const times = gitCommitTimes(collection.root);
+    store.setCommitTimes(collection.name, times);
+    files += times.size;
+  }
+  store.setRecencyBoost(weight, halfLifeDays);
+  return files;
+}
+
+/** Who last changed a line, from `git blame`. */
+export interface BlameLine {
+  author: string;
+  email: string;
+  /** Author time, epoch ms */
+  time: number;
+}
+
+/**
+ * Blame selected 1-based lines of `path` (relative to `root`). Lines
+ * that are not committed yet, and every line of a file git does not
+ * track, are missing from the result.
+ */
+export function gitBlameLines(root: string, path: string, lines: number[]): Map<number, BlameLine> {
+  const blamed = new Map<number, BlameLine>();
+  if (lines.length === 0) return blamed;
+  const ranges = [...new Set(lines)].flatMap((n) => ["-L", `${n},${n}`]);
+  let result;
+  try {
+    result = Bun.spawnSync(["git", "-C", root, "blame", "--line-porcelain", ...ranges, "--", path], {
+      stdout: "pipe",
+      stderr: "ignore",
+      ...spawnTimeout(),
+    });
+  } catch {
+    return blamed;
+  }
+  if (!result.success) return blamed;
+
+  // Each line: "<sha> <orig> <final>", key-value headers, then "\t<content>"
+  let line = 0;
+  let sha = "";
+  let current: BlameLine = { author: "", email: "", time: 0 };
+  for (const row of result.stdout.toString().split("\n")) {
+    const header = row.match(/^([0-9a-f]{40}) \d+ (\d+)/);
+    if (header) {
+      sha = header[1];
+      line = parseInt(header[2], 10);
+      current = { author: "", email: "", time: 0 };
+    } else if (row.startsWith("author ")) {
+      current.author = row.slice(7);
+    } else if (row.startsWith("author-mail ")) {
+      current.email = row.slice(12).replace(/^<|>$/g, "");
+    } else if (row.startsWith("author-time ")) {
+      current.time = parseInt(row.slice(12), 10) * 1000;
+    } else if (row.startsWith("\t") && !/^0+$/.test(sha)) {
+      blamed.set(line, current);
+    }
+  }
+  return blamed;
+}
+
+/**
+ * Contents of `path` (relative to `root`) at `ref`, line endings
+ * normalized as readSourceText does, or null when the file does not
+ * exist at that ref, the ref is unknown, or `root` is not in a git work
+ * tree.
+ */
+export function gitShowFile(root: string, ref: string, path: string): string | null {
+  let result;
+  try {
+    result = Bun.spawnSync(["git", "-C", root, "show", `${ref}:./${path}`], { stdout: "pipe", stderr: "ignore", ...spawnTimeout() });
+  } catch {
+    return null;
+  }
+  return result.success ? normalizeLineEndings(decodeSource(result.stdout).text) : null;
+}
+
+/** How much one file changed over a stretch of history. */
+export interface FileChurn {
+  commits: number;
+  lines_added: number;
+  lines_deleted: number;
+  /** Distinct author emails */
+  authors: number;
+  /** Last commit time, epoch ms */
+  last_changed: number;
+}
+
+/**
+ * Commit counts and changed lines of every file under `root`, keyed
+ * like gitCommitTimes, for commits after `since` (anything git's
+ * --since accepts, e.g. "6 months ago") or all of history. Binary
+ * files count commits but no lines. Empty outside a git work tree.
+ */
+export function gitChurn(root: string, since?: string): Map<string, FileChurn> {
+  const churn = new Map<string, FileChurn>();
+  let result;
+  try {
+    result = Bun.spawnSync(
+      [
+        "git", "-C", root, "-c", "core.quotePath=false", "log", "--format=%x00%ct %ae", "--numstat", "--no-renames", "--relative",
+        ...(since ? [`--since=${since}`] : []),
+        "--", ".",
+      ],
+      { stdout: "pipe", stderr: "ignore", ...spawnTimeout() }
+    );
+  } catch {
+    return churn;
+  }
+  if (!result.success) return churn;
+
+  const authors = new Map<string, Set<string>>();
+  let commitTime = 0;
+  let author = "";
+  for (const line of result.stdout.toString().split("\n")) {
+    if (line.startsWith("\0")) {
+      const space = line.indexOf(" ");
+      commitTime = parseInt(line.slice(1, space), 10) * 1000;
+      author = line.slice(space + 1);
+      continue;
+    }
+    const m = line.match(/^(\d+|-)\t(\d+|-)\t(.+)$/);
+    if (!m) continue;
+    const path = m[3];
+    let entry = churn.get(path);
+    if (!entry) {
+      // Newest commit first: the first sighting is the last change
+      entry = { commits: 0, lines_added: 0, lines_deleted: 0, authors: 0, last_changed: commitTime };
+      churn.set(path, entry);
+      authors.set(path, new Set());
+    }
+    entry.commits++;
+    if (m[1] !== "-") entry.lines_added += parseInt(m[1], 10);
+    if (m[2] !== "-") entry.lines_deleted += parseInt(m[2], 10);
+    authors.get(path)!.add(author);
+  }
+  for (const [path, entry] of churn) entry.authors = authors.get(path)!.size;
+  return churn;
+}
+
+/**
+ * Files under `path` (relative to `root`; everything when empty) at
+ * `ref`, or in the working tree when `ref` is omitted (tracked and
+ * untracked, ignored files aside), as "/"-separated paths relative to
+ * `root`. Null when the ref is unknown or `root` is not in a git work
+ * tree.
+ */
+export function gitListFiles(root: string, ref?: string, path = ""): string[] | null {
+  const args = ref === undefined ? ["ls-files", "--cached", "--others", "--exclude-standard"] : ["ls-tree", "-r", "--name-only", ref];
+  let result;
+  try {
+    result = Bun.spawnSync(["git", "-C", root, "-c", "core.quotePath=false", ...args, "--", path || "."], {
+      stdout: "pipe",
+      stderr: "ignore",
+      ...spawnTimeout(),
+    });
+  } catch {
+    return null;
+  }
+  if (!result.success) return null;
+  return [...new Set(result.stdout.toString().split("\n").filter(Boolean))];
+}
+
+/**
+ * Files under `path` that differ between `base` and `head`, or between
+ * `base` and the working tree when `head` is omitted (untracked files
+ * included), relative to `root`. Null when a ref is unknown or `root`
+ * is not in a git work tree.
+ */
+export function gitChangedFiles(root: string, base: string, head?: string, path = ""): string[] | null {
+  let result;
+  try {
+    result = Bun.spawnSync(
+      ["git", "-C", root, "-c", "core.quotePath=false", "diff", "--name-only", "--no-renames", "--relative", base, ...(head ? [head] : []), "--", path || "."],
+      { stdout: "pipe", stderr: "ignore", ...spawnTimeout() }
+    );
+  } catch {
+    return null;
+  }
+  if (!result.success) return null;
+  const changed = result.stdout.toString().split("\n").filter(Boolean);
+  if (head === undefined) {
+    const untracked = Bun.spawnSync(["git", "-C", root, "-c", "core.quotePath=false", "ls-files", "--others", "--exclude-standard", "--", path || "."], {
+      stdout: "pipe",
+      stderr: "ignore",
+      ...spawnTimeout(),
+    });
+    if (untracked.success) changed.push(...untracked.stdout.toString().split("\n").filter(Boolean));
+  }
+  return [...new Set(changed)];
+}
+
+/** The most recent tag reachable from HEAD in `root`'s repository, or null when there is none. */
+export function gitLatestTag(root: string): string | null {
+  let result;
+  try {
+    result = Bun.spawnSync(["git", "-C", root, "describe", "--tags", "--abbrev=0"], { stdout: "pipe", stderr: "ignore", ...spawnTimeout() });
+  } catch {
+    return null;
+  }
+  return result.success ? result.stdout.toString().trim() || null : null;
+}
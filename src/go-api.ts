@@ -0,0 +1,261 @@
+/**
+ * Go package API surface — the godoc view of one package
+ *
+ * find_symbol and get_tree show a package file by file, private
+ * helpers included. Before depending on a package an agent wants the
+ * opposite: only what it exports, grouped the way `go doc` groups it.
+ * packageApi() builds that view from the Go parser's symbols:
+ *
+ *   - constants and variables, one entry per exported name, including
+ *     names declared inside const ( ... ) / var ( ... ) blocks
+ *   - functions, except constructors
+ *   - types, each with its exported methods, its constructors (exported
+ *     functions whose first result is T or *T), and for interfaces the
+ *     method set
+ *
+ * Each entry carries its declaration line and the first sentence of its
+ * doc comment. Test files, files go ignores (_x.go, .x.go), and
+ * `//go:build ignore` files are left out.
+ */
+
+import { readdir, readFile } from "node:fs/promises";
+import { join, posix } from "node:path";
+import { parseGo } from "./parsers/go";
+import type { CodeSymbol } from "./code-indexer";
+
+export interface GoApiEntry {
+  name: string;
+  /** The declaration's first line, without the opening brace */
+  signature: string;
+  /** First sentence of the doc comment */
+  doc?: string;
+  /** Root-relative path of the declaring file */
+  file: string;
+  line: number;
+}
+
+export interface GoApiType extends GoApiEntry {
+  kind: "struct" | "interface" | "type";
+  constructors: GoApiEntry[];
+  /** Exported methods; for an interface, its method set */
+  methods: GoApiEntry[];
+}
+
+export interface GoPackageApi {
+  name: string;
+  /** First sentence of the package comment */
+  doc?: string;
+  files: string[];
+  constants: GoApiEntry[];
+  variables: GoApiEntry[];
+  functions: GoApiEntry[];
+  types: GoApiType[];
+}
+
+/** One source file of a package: root-relative path and its text. */
+export interface GoSourceFile {
+  path: string;
+  source: string;
+}
+
+const isExported = (name: string) => /^\p{Lu}/u.test(name);
+const byName = (a: GoApiEntry, b: GoApiEntry) => (a.name < b.name ? -1 : a.name > b.name ? 1 : 0);
+
+/** True for a file the go command builds as part of the package. */
+export function isPackageSource(name: string): boolean {
+  return name.endsWith(".go") && !name.endsWith("_test.go") && !name.startsWith("_") && !name.startsWith(".");
+}
+
+/** Build the exported API of one package from its source files. */
+export function packageApi(files: GoSourceFile[]): GoPackageApi {
+  const api: GoPackageApi = { name: "", files: [], constants: [], variables: [], functions: [], types: [] };
+  const types = new Map<string, GoApiType>();
+  const methods: { receiver: string; entry: GoApiEntry }[] = [];
+  const functions: GoApiEntry[] = [];
+
+  // doc.go conventionally holds the package comment; look there first
+  const ordered = [...files].sort((a, b) => Number(posix.basename(b.path) === "doc.go") - Number(posix.basename(a.path) === "doc.go"));
+
+  for (const { path, source } of ordered) {
+    const lines = source.split("\n");
+    if (lines.some((l) => /^\/\/go:build\s+ignore\b/.test(l.trim()))) continue;
+    api.files.push(path);
+
+    const pkgLine = lines.findIndex((l) => /^package\s+\p{ID_Continue}+/u.test(l.trim()));
+    if (pkgLine >= 0 && !api.name) api.name = lines[pkgLine].trim().split(/\s+/)[1];
+    if (pkgLine >= 0 && !api.doc) api.doc = docComment(lines, pkgLine);
+
+    const entry = (name: string, signature: string, line: number): GoApiEntry => {
+      const doc = docComment(lines, line - 1) ?? trailingComment(lines[line - 1]);
+      return { name, signature, ...(doc ? { doc } : {}), file: path, line };
+    };
+
+    for (const sym of parseGo(source, path)) {
+      switch (sym.kind) {
+        case "class":
+        case "interface":
+        case "type": {
+          if (!isExported(sym.name)) break;
+          const kind = sym.kind === "class" ? "struct" : sym.kind;
+          const type: GoApiType = { ...entry(sym.name, sym.signature, sym.line_start), kind, constructors: [], methods: [] };
+          if (kind === "interface") type.methods = interfaceMethods(sym, lines, entry);
+          types.set(sym.name, type);
+          break;
+        }
+        case "method": {
+          const receiver = sym.signature.match(/^func\s+\(\s*\p{ID_Continue}+\s+\*?(\p{ID_Continue}+)/u)?.[1];
+          if (receiver && isExported(sym.name)) {
+            methods.push({ receiver, entry: entry(sym.name, sym.signature, sym.line_start) });
+          }
+          break;
+        }
+        case "function":
+          if (isExported(sym.name)) functions.push(entry(sym.name, sym.signature, sym.line_start));
+          break;
+        case "variable":
+          for (const decl of valueDecls(sym, lines, entry)) {
+            (decl.signature.startsWith("const") ? api.constants : api.variables).push(decl);
+          }
+          break;
+      }
+    }
+  }
+
+  // Methods and constructors join their type once every file is read,
+  // since a type and its methods often live in different files
+  for (const { receiver, entry } of methods) types.get(receiver)?.methods.push(entry);
+  for (const fn of functions) {
+    const type = types.get(resultType(fn) ?? "");
+    if (type) type.constructors.push(fn);
+    else api.functions.push(fn);
+  }
+
+  api.constants.sort(byName);
+  api.variables.sort(byName);
+  api.functions.sort(byName);
+  api.types = [...types.values()].sort(byName);
+  for (const type of api.types) {
+    type.constructors.sort(byName);
+    if (type.kind !== "interface") type.methods.sort(byName);
+  }
+  return api;
+}
+
+/** Read a package directory and build its API; null when it holds no Go source. */
+export async function readPackageApi(root: string, dir: string): Promise<GoPackageApi | null> {
+  const entries = await readdir(join(root, dir), { withFileTypes: true }).catch(() => []);
+  const files: GoSourceFile[] = [];
+  for (const e of entries) {
+    if (!e.isFile() || !isPackageSource(e.name)) continue;
+    const path = dir ? `${dir}/${e.name}` : e.name;
+    files.push({ path, source: await readFile(join(root, path), "utf-8") });
+  }
+  if (files.length === 0) return null;
+  return packageApi(files.sort((a, b) => (a.path < b.path ? -1 : 1)));
+}
+
+// ── Declarations ─────────────────────────────────────────────────────
+
+type MakeEntry = (name: string, signature: string, line: number) => GoApiEntry;
+
+/**
+ * Exported names of a const/var symbol: a single declaration, or every
+ * line of a parenthesized block (`A, B = 1, 2` yields A and B).
+ */
+function valueDecls(sym: CodeSymbol, lines: string[], entry: MakeEntry): GoApiEntry[] {
+  const keyword = sym.signature.startsWith("const") ? "const" : "var";
+  if (sym.line_start === sym.line_end) {
+    return isExported(sym.name) ? [entry(sym.name, stripComment(sym.signature), sym.line_start)] : [];
+  }
+
+  // An entry without its own comment inherits the block's
+  const groupDoc = docComment(lines, sym.line_start - 1);
+  const decls: GoApiEntry[] = [];
+  for (let line = sym.line_start + 1; line < sym.line_end; line++) {
+    const text = stripComment(lines[line - 1].trim());
+    const names = text.match(/^(\p{ID_Continue}+(?:\s*,\s*\p{ID_Continue}+)*)/u)?.[1];
+    if (!names) continue;
+    for (const name of names.split(",").map((n) => n.trim())) {
+      if (!isExported(name)) continue;
+      const decl = entry(name, `${keyword} ${text}`, line);
+      decls.push(decl.doc || !groupDoc ? decl : { ...decl, doc: groupDoc });
+    }
+  }
+  return decls;
+}
+
+/** Exported methods declared inside an interface body. */
+function interfaceMethods(sym: CodeSymbol, lines: string[], entry: MakeEntry): GoApiEntry[] {
+  const found: GoApiEntry[] = [];
+  for (let line = sym.line_start + 1; line < sym.line_end; line++) {
+    const text = stripComment(lines[line - 1].trim());
+    const name = text.match(/^(\p{Lu}\p{ID_Continue}*)\s*\(/u)?.[1];
+    if (name) found.push(entry(name, text, line));
+  }
+  return found;
+}
+
+/** Name of a function's first result type, ignoring a leading "*". */
+function resultType(fn: GoApiEntry): string | null {
+  const open = fn.signature.indexOf("(");
+  if (open < 0) return null;
+  let depth = 0;
+  for (let i = open; i < fn.signature.length; i++) {
+    if (fn.signature[i] === "(") depth++;
+    else if (fn.signature[i] === ")" && --depth === 0) {
+      return fn.signature.slice(i + 1).trim().match(/^\(?\s*\*?(\p{ID_Continue}+)/u)?.[1] ?? null;
+    }
+  }
+  return null;
+}
+
+// ── Comments ─────────────────────────────────────────────────────────
+
+/**
+ * First sentence of the `//` comment directly above a 0-based line.
+ * Directives such as //go:generate are skipped.
+ */
+function docComment(lines: string[], index: number): string | undefined {
+  const doc: string[] = [];
+  for (let i = index - 1; i >= 0; i--) {
+    const text = lines[i].trim();
+    if (!text.startsWith("//")) break;
+    if (/^\/\/(?:go:|line |nolint)/.test(text)) continue;
+    doc.unshift(text.replace(/^\/\/ ?/, ""));
+  }
+  return firstSentence(doc);
+}
+
+function trailingComment(line: string | undefined): string | undefined {
+  const comment = line === undefined ? "" : splitComment(line).comment;
+  return comment ? firstSentence([comment]) : undefined;
+}
+
+/** The first sentence of a comment's first paragraph. */
+function firstSentence(doc: string[]): string | undefined {
+  const blank = doc.findIndex((l) => l.trim() === "");
+  const paragraph = (blank < 0 ? doc : doc.slice(0, blank)).join(" ").replace(/\s+/g, " ").trim();
+  if (!paragraph) return undefined;
+  return paragraph.match(/^(.*?[.!?])(?:\s|$)/)?.[1] ?? paragraph;
+}
+
+function stripComment(text: string): string {
+  return splitComment(text).code;
+}
+
+/** Split a line at its `//` comment, ignoring "//" inside string and rune literals. */
+function splitComment(line: string): { code: string; comment: string } {
+  let quote = "";
+  for (let i = 0; i < line.length; i++) {
+    const ch = line[i];
+    if (quote) {
+      if (ch === "\\" && quote !== "`") i++;
+      else if (ch === quote) quote = "";
+    } else if (ch === '"' || ch === "'" || ch === "`") {
+      quote = ch;
+    } else if (line.startsWith("//", i)) {
+      return { code: line.slice(0, i).trim(), comment: line.slice(i + 2).trim() };
+    }
+  }
+  return { code: line.trim(), comment: "" };
+}
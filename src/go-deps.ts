@@ -0,0 +1,108 @@
+/**
+ * Dependency sources from the Go module cache (INDEX_DEPENDENCIES)
+ *
+ * When debugging into third-party code, an agent otherwise has to stop
+ * at the import. With INDEX_DEPENDENCIES=1 the direct requirements of
+ * every go.mod under the code roots are indexed too, straight from the
+ * module cache where `go mod download` put them:
+ *
+ *   $GOMODCACHE/github.com/stretchr/testify@v1.9.0
+ *
+ * Each becomes a collection named "gomod/<module>@<version>", so its
+ * doc_ids say where they come from
+ * (gomod/github.com/stretchr/testify@v1.9.0:assert:assertions_go), and
+ * it ranks below workspace code. Dependency collections live in
+ * IndexConfig.dependency_collections, apart from code_collections, so
+ * they are searchable and navigable but no code tool reads or rewrites
+ * them. The module cache is read-only to begin with.
+ *
+ * Replace directives are followed when they point at another module
+ * version; ones pointing at a directory are skipped (that code sits in
+ * the repository or beside it). Requirements that are modules of the
+ * repository itself, ones already in a vendor/ tree (see vendor.ts),
+ * and ones missing from the cache are left out; the missing ones are
+ * reported so the caller can suggest `go mod download`.
+ */
+
+import { stat } from "node:fs/promises";
+import { homedir } from "node:os";
+import { join, resolve } from "node:path";
+import type { CollectionConfig } from "./types";
+import { isLocalPath, type GoModuleIndex } from "./go-modules";
+
+/** BM25 weight of a dependency collection: below the default code weight, so workspace code ranks first */
+export const DEPENDENCY_WEIGHT = 0.5;
+
+/** Prefix of every dependency collection name */
+export const DEPENDENCY_PREFIX = "gomod/";
+
+/**
+ * A module path or version as the module cache spells it: each
+ * uppercase letter becomes "!" and its lowercase form, so the cache
+ * works on case-insensitive file systems.
+ */
+export function escapeModulePath(path: string): string {
+  return path.replace(/[A-Z]/g, (c) => `!${c.toLowerCase()}`);
+}
+
+/** The module cache: `configured`, else `go env GOMODCACHE`, else $GOPATH/pkg/mod. */
+export function moduleCacheDir(configured?: string): string {
+  if (configured) return resolve(configured);
+  try {
+    const result = Bun.spawnSync(["go", "env", "GOMODCACHE"], { stdout: "pipe", stderr: "ignore" });
+    const found = result.success ? result.stdout.toString().trim() : "";
+    if (found) return found;
+  } catch {
+    // go is not installed; fall back to its default layout
+  }
+  const gopath = (process.env.GOPATH ?? "").split(":")[0] || join(homedir(), "go");
+  return join(gopath, "pkg", "mod");
+}
+
+export interface DependencyCollections {
+  collections: CollectionConfig[];
+  /** module@version of direct requirements not in the module cache */
+  missing: string[];
+  /** module@version left out because a vendor/ tree already holds them */
+  vendored: string[];
+}
+
+/**
+ * Collections for the direct dependencies of the repository's modules,
+ * found under `modcache`. Modules in `vendored` (module@version, from
+ * vendor/modules.txt) are skipped, since their code is indexed there.
+ */
+export async function dependencyCollections(
+  goModules: GoModuleIndex,
+  modcache: string,
+  options: { vendored?: Set<string> } = {}
+): Promise<DependencyCollections> {
+  const graph = await goModules.graph();
+  const local = new Set(graph.modules.map((m) => m.module));
+  const wanted = new Map<string, { path: string; version: string }>();
+  const vendored = new Set<string>();
+  for (const mod of graph.modules) {
+    for (const req of mod.require) {
+      if (req.indirect || local.has(req.path)) continue;
+      const rep =
+        mod.replace.find((r) => r.old === req.path && r.old_version === req.version) ??
+        mod.replace.find((r) => r.old === req.path && !r.old_version);
+      if (rep && (isLocalPath(rep.new) || !rep.new_version)) continue;
+      const target = rep ? { path: rep.new, version: rep.new_version! } : { path: req.path, version: req.version };
+      if (options.vendored?.has(`${req.path}@${req.version}`)) vendored.add(`${target.path}@${target.version}`);
+      else wanted.set(`${target.path}@${target.version}`, target);
+    }
+  }
+
+  const collections: CollectionConfig[] = [];
+  const missing: string[] = [];
+  for (const [key, { path, version }] of [...wanted].sort(([a], [b]) => a.localeCompare(b))) {
+    const root = join(modcache, `${escapeModulePath(path)}@${escapeModulePath(version)}`);
+    if (!(await stat(root).then((s) => s.isDirectory(), () => false))) {
+      missing.push(key);
+      continue;
+    }
+    collections.push({ name: `${DEPENDENCY_PREFIX}${key}`, root, weight: DEPENDENCY_WEIGHT, glob_pattern: "**/*.go" });
+  }
+  return { collections, missing, vendored: [...vendored].sort() };
+}
@@ -0,0 +1,408 @@
+/**
+ * Go module metadata — go.mod, go.sum, and go.work
+ *
+ * Symbol search answers "where is X defined"; module_info answers the
+ * questions one level up: which module owns a directory, what it
+ * depends on (directly or only transitively), which dependencies are
+ * swapped out by replace directives, and how the modules of a
+ * multi-module repository depend on each other.
+ *
+ * The parsers follow the go.mod grammar closely enough for real files:
+ * single-line and parenthesized block directives, quoted paths, `//`
+ * comments, and the `// indirect` marker. Directives treenav has no
+ * use for (retract, godebug, tool) are skipped.
+ *
+ * Discovery walks each code collection once, on first use, and skips
+ * vendor/, testdata/, and node_modules/ as the go command does. The
+ * files themselves are re-read on every call, so edits to go.mod show
+ * up immediately; a module added after the first call needs a restart.
+ */
+
+import { readFile, stat } from "node:fs/promises";
+import { join, posix, resolve } from "node:path";
+import { walkFiles } from "./walk";
+import type { IndexConfig } from "./types";
+import { relativePath } from "./paths";
+
+export interface GoRequirement {
+  path: string;
+  version: string;
+  /** Marked `// indirect`: needed only by other dependencies */
+  indirect: boolean;
+  /** The module's h1: hash from go.sum, when recorded */
+  checksum?: string;
+}
+
+export interface GoReplace {
+  old: string;
+  old_version?: string;
+  /** A module path, or a directory when it starts with ./, ../, or / */
+  new: string;
+  new_version?: string;
+}
+
+export interface GoModFile {
+  module: string;
+  go?: string;
+  toolchain?: string;
+  require: GoRequirement[];
+  replace: GoReplace[];
+  exclude: { path: string; version: string }[];
+}
+
+export interface GoWorkFile {
+  go?: string;
+  toolchain?: string;
+  /** Module directories, relative to the go.work file */
+  use: string[];
+  replace: GoReplace[];
+}
+
+export interface GoModule extends GoModFile {
+  collection: string;
+  /** Directory of go.mod, "/"-separated and relative to the collection root ("" at the root) */
+  dir: string;
+}
+
+export interface GoWorkspace extends GoWorkFile {
+  collection: string;
+  dir: string;
+  /** Module paths of the `use` directories that hold a go.mod */
+  modules: string[];
+}
+
+/** An in-repository dependency between two modules */
+export interface GoModuleEdge {
+  from: string;
+  to: string;
+  /** require: `from` requires `to`; replace: `from` points a dependency at `to`'s directory */
+  via: "require" | "replace";
+}
+
+export interface GoModuleGraph {
+  modules: GoModule[];
+  workspaces: GoWorkspace[];
+  edges: GoModuleEdge[];
+}
+
+/** Where a Go package lives, and its import path when a go.mod covers it */
+export interface GoPackageLocation {
+  collection: string;
+  /** Absolute collection root */
+  root: string;
+  /** Package directory relative to the root ("" at the root) */
+  dir: string;
+  import_path?: string;
+  /** Path of the owning module */
+  module?: string;
+}
+
+/** Directories the go command never treats as part of a module tree */
+const SKIPPED_DIRS = new Set(["vendor", "testdata", "node_modules"]);
+
+// ── Parsing ──────────────────────────────────────────────────────────
+
+interface Line {
+  verb: string;
+  args: string[];
+  comment: string;
+}
+
+/** Split go.mod/go.work text into directive lines, expanding blocks. */
+function directives(text: string): Line[] {
+  const lines: Line[] = [];
+  let block: string | null = null;
+  for (const raw of text.split("\n")) {
+    const { tokens, comment } = tokenize(raw);
+    if (tokens.length === 0) continue;
+    if (block !== null) {
+      if (tokens[0] === ")") {
+        block = null;
+        continue;
+      }
+      lines.push({ verb: block, args: tokens, comment });
+    } else if (tokens.length === 2 && tokens[1] === "(") {
+      block = tokens[0];
+    } else {
+      lines.push({ verb: tokens[0], args: tokens.slice(1), comment });
+    }
+  }
+  return lines;
+}
+
+/** Tokens of one line, honoring "..." and `...` strings, plus the trailing comment. */
+function tokenize(line: string): { tokens: string[]; comment: string } {
+  const tokens: string[] = [];
+  let i = 0;
+  while (i < line.length) {
+    const ch = line[i];
+    if (ch === " " || ch === "\t" || ch === "\r") {
+      i++;
+    } else if (line.startsWith("//", i)) {
+      return { tokens, comment: line.slice(i + 2).trim() };
+    } else if (ch === '"' || ch === "`") {
+      let end = i + 1;
+      while (end < line.length && line[end] !== ch) end += ch === '"' && line[end] === "\\" ? 2 : 1;
+      const body = line.slice(i + 1, end);
+      tokens.push(ch === '"' ? body.replace(/\\(.)/g, "$1") : body);
+      i = end + 1;
+    } else if (ch === "(" || ch === ")") {
+      tokens.push(ch);
+      i++;
+    } else {
+      let end = i;
+      while (end < line.length && !" \t\r()".includes(line[end]) && !line.startsWith("//", end)) end++;
+      tokens.push(line.slice(i, end));
+      i = end;
+    }
+  }
+  return { tokens, comment: "" };
+}
+
+function parseReplace(args: string[]): GoReplace | null {
+  const arrow = args.indexOf("=>");
+  if (arrow < 1 || arrow > 2 || args.length - arrow - 1 < 1) return null;
+  const [old, old_version] = args.slice(0, arrow);
+  const [next, new_version] = args.slice(arrow + 1);
+  return {
+    old,
+    ...(old_version ? { old_version } : {}),
+    new: next,
+    ...(new_version ? { new_version } : {}),
+  };
+}
+
+/** Parse a go.mod file. Returns null when it has no module directive. */
+export function parseGoMod(text: string): GoModFile | null {
+  const mod: GoModFile = { module: "", require: [], replace: [], exclude: [] };
+  for (const { verb, args, comment } of directives(text)) {
+    switch (verb) {
+      case "module":
+        mod.module = args[0] ?? "";
+        break;
+      case "go":
+        mod.go = args[0];
+        break;
+      case "toolchain":
+        mod.toolchain = args[0];
+        break;
+      case "require":
+        if (args.length >= 2) {
+          mod.require.push({ path: args[0], version: args[1], indirect: /^indirect\b/.test(comment) });
+        }
+        break;
+      case "replace": {
+        const replace = parseReplace(args);
+        if (replace) mod.replace.push(replace);
+        break;
+      }
+      case "exclude":
+        if (args.length >= 2) mod.exclude.push({ path: args[0], version: args[1] });
+        break;
+    }
+  }
+  return mod.module ? mod : null;
+}
+
+/** Parse a go.work file. */
+export function parseGoWork(text: string): GoWorkFile {
+  const work: GoWorkFile = { use: [], replace: [] };
+  for (const { verb, args } of directives(text)) {
+    if (verb === "go") work.go = args[0];
+    else if (verb === "toolchain") work.toolchain = args[0];
+    else if (verb === "use" && args[0]) work.use.push(args[0]);
+    else if (verb === "replace") {
+      const replace = parseReplace(args);
+      if (replace) work.replace.push(replace);
+    }
+  }
+  return work;
+}
+
+/**
+ * Module hashes from go.sum, keyed by "path@version". Only the h1: hash
+ * of the module itself is kept; the separate `/go.mod` hash lines are
+ * skipped.
+ */
+export function parseGoSum(text: string): Map<string, string> {
+  const sums = new Map<string, string>();
+  for (const line of text.split("\n")) {
+    const [path, version, hash] = line.trim().split(/\s+/);
+    if (!hash || version.endsWith("/go.mod")) continue;
+    sums.set(`${path}@${version}`, hash);
+  }
+  return sums;
+}
+
+// ── Graph ────────────────────────────────────────────────────────────
+
+/** True for a replacement that points at a directory rather than a module path. */
+export function isLocalPath(target: string): boolean {
+  return target.startsWith("./") || target.startsWith("../") || target.startsWith("/") || target === "." || target === "..";
+}
+
+/**
+ * Edges between the repository's own modules: a require of another
+ * module's path, or a replace that points at its directory.
+ */
+export function moduleEdges(modules: GoModule[]): GoModuleEdge[] {
+  const byPath = new Map(modules.map((m) => [m.module, m]));
+  const byDir = new Map(modules.map((m) => [`${m.collection}:${m.dir}`, m]));
+  const edges: GoModuleEdge[] = [];
+  const seen = new Set<string>();
+  const add = (edge: GoModuleEdge) => {
+    const key = `${edge.from}\0${edge.to}\0${edge.via}`;
+    if (edge.from !== edge.to && !seen.has(key)) {
+      seen.add(key);
+      edges.push(edge);
+    }
+  };
+
+  for (const m of modules) {
+    for (const req of m.require) {
+      if (byPath.has(req.path)) add({ from: m.module, to: req.path, via: "require" });
+    }
+    for (const rep of m.replace) {
+      if (!isLocalPath(rep.new)) continue;
+      const target = byDir.get(`${m.collection}:${normalizeDir(posix.join(m.dir, rep.new))}`);
+      if (target) add({ from: m.module, to: target.module, via: "replace" });
+    }
+  }
+  return edges;
+}
+
+/** A root-relative directory in canonical form: no "./", no trailing "/", "" for the root. */
+function normalizeDir(dir: string): string {
+  const normal = posix.normalize(dir).replace(/\/+$/, "");
+  return normal === "." ? "" : normal;
+}
+
+// ── Discovery ────────────────────────────────────────────────────────
+
+interface FoundFile {
+  collection: string;
+  root: string;
+  /** Root-relative, "/"-separated path of the go.mod or go.work */
+  path: string;
+}
+
+/**
+ * Go modules and workspaces under the code collections of an index.
+ * Discovery runs once, on the first graph() call.
+ */
+export class GoModuleIndex {
+  private found: Promise<FoundFile[]> | null = null;
+
+  constructor(private readonly config: IndexConfig) {}
+
+  /** Read every go.mod/go.work (with go.sum) and build the module graph. */
+  async graph(): Promise<GoModuleGraph> {
+    const modules: GoModule[] = [];
+    const workspaces: GoWorkspace[] = [];
+    for (const file of await this.discover()) {
+      const dir = posix.dirname(file.path) === "." ? "" : posix.dirname(file.path);
+      const text = await readFile(join(file.root, file.path), "utf-8").catch(() => null);
+      if (text === null) continue;
+
+      if (file.path.endsWith("go.work")) {
+        workspaces.push({ collection: file.collection, dir, modules: [], ...parseGoWork(text) });
+        continue;
+      }
+      const mod = parseGoMod(text);
+      if (!mod) continue;
+      const sums = parseGoSum(await readFile(join(file.root, dir, "go.sum"), "utf-8").catch(() => ""));
+      for (const req of mod.require) {
+        const checksum = sums.get(`${req.path}@${req.version}`);
+        if (checksum) req.checksum = checksum;
+      }
+      modules.push({ collection: file.collection, dir, ...mod });
+    }
+    for (const work of workspaces) {
+      const dirs = new Set(work.use.map((use) => normalizeDir(posix.join(work.dir, use))));
+      work.modules = modules.filter((m) => m.collection === work.collection && dirs.has(m.dir)).map((m) => m.module);
+    }
+    return { modules, workspaces, edges: moduleEdges(modules) };
+  }
+
+  /**
+   * Locate a package by import path (resolved against the repository's
+   * modules) or by directory, optionally prefixed "collection:". The
+   * directory is not checked for Go source.
+   */
+  async locatePackage(query: string): Promise<GoPackageLocation | null> {
+    const graph = await this.graph();
+    const collections = this.config.code_collections ?? [];
+    const rootOf = (name: string) => {
+      const collection = collections.find((c) => c.name === name);
+      return collection ? resolve(collection.root) : null;
+    };
+
+    const owner = graph.modules
+      .filter((m) => query === m.module || query.startsWith(`${m.module}/`))
+      .sort((a, b) => b.module.length - a.module.length)[0];
+    if (owner) {
+      const root = rootOf(owner.collection);
+      if (!root) return null;
+      const dir = normalizeDir(posix.join(owner.dir, query.slice(owner.module.length + 1)));
+      return { collection: owner.collection, root, dir, import_path: query, module: owner.module };
+    }
+
+    const colon = query.indexOf(":");
+    const named = colon > 0 ? collections.find((c) => c.name === query.slice(0, colon)) : undefined;
+    const dir = normalizeDir(named ? query.slice(colon + 1) : query);
+    if (dir.startsWith("..") || posix.isAbsolute(dir)) return null;
+    for (const collection of named ? [named] : collections) {
+      const root = resolve(collection.root);
+      const isDir = await stat(join(root, dir)).then((s) => s.isDirectory(), () => false);
+      if (!isDir) continue;
+      const mod = moduleForPath({ ...graph, modules: graph.modules.filter((m) => m.collection === collection.name) }, dir);
+      const rest = mod ? dir.slice(mod.dir.length).replace(/^\//, "") : "";
+      return {
+        collection: collection.name,
+        root,
+        dir,
+        ...(mod ? { import_path: rest ? `${mod.module}/${rest}` : mod.module, module: mod.module } : {}),
+      };
+    }
+    return null;
+  }
+
+  private discover(): Promise<FoundFile[]> {
+    this.found ??= (async () => {
+      const found: FoundFile[] = [];
+      for (const collection of this.config.code_collections ?? []) {
+        const root = resolve(collection.root);
+        const files = await walkFiles(root, {
+          symlinks: collection.symlinks,
+          match: (rel) => {
+            const name = posix.basename(rel);
+            return name === "go.mod" || name === "go.work";
+          },
+          enter: (rel) => !SKIPPED_DIRS.has(posix.basename(rel)),
+        });
+        for (const abs of files) {
+          found.push({ collection: collection.name, root, path: relativePath(root, abs) });
+        }
+      }
+      return found.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : 0));
+    })();
+    return this.found;
+  }
+}
+
+/** The module with this module path or go.mod directory. */
+export function findModule(graph: GoModuleGraph, query: string): GoModule | null {
+  const dir = normalizeDir(query);
+  return graph.modules.find((m) => m.module === query || m.dir === dir) ?? null;
+}
+
+/** The innermost module whose directory contains `relPath`, a file or directory. */
+export function moduleForPath(graph: GoModuleGraph, relPath: string): GoModule | null {
+  const path = normalizeDir(relPath);
+  let best: GoModule | null = null;
+  for (const m of graph.modules) {
+    const inside = m.dir === "" || path === m.dir || path.startsWith(`${m.dir}/`);
+    if (inside && (!best || m.dir.length > best.dir.length)) best = m;
+  }
+  return best;
+}
@@ -0,0 +1,153 @@
+/**
+ * Go standard library lookups against the local GOROOT
+ *
+ * Workspace code is full of `sync.RWMutex` and `context.Context`, but
+ * the standard library is not indexed, so find_symbol and package_api
+ * used to come up empty for them. GoStdlib resolves such names against
+ * the Go installation's sources instead:
+ *
+ *   sync.RWMutex          a type (or a function, constant, variable)
+ *   sync.RWMutex.RLock    one method
+ *   net/http.Request      a full import path as the qualifier
+ *   http.Request          the last path element, when it names a
+ *                         standard package (several may match)
+ *
+ * The API comes from the same reader as package_api (go-api.ts), so
+ * entries carry a signature, the first sentence of the doc comment, and
+ * a file and line under GOROOT/src. GOROOT comes from the `goroot`
+ * setting, else from `go env GOROOT`; without either, lookups find
+ * nothing. internal, vendor, testdata, and cmd packages are left out.
+ */
+
+import { readdir, stat } from "node:fs/promises";
+import { join, resolve } from "node:path";
+import { readPackageApi, type GoApiEntry, type GoPackageApi } from "./go-api";
+
+const SKIPPED = new Set(["internal", "vendor", "testdata", "cmd"]);
+
+export interface StdlibSymbol {
+  /** Import path of the declaring package */
+  package: string;
+  /** Qualified within the package: RWMutex, RWMutex.RLock */
+  name: string;
+  kind: "constant" | "variable" | "function" | "struct" | "interface" | "type" | "method";
+  signature: string;
+  doc?: string;
+  /** Absolute path of the declaring file */
+  file_path: string;
+  line: number;
+}
+
+/** A standard package by import path: `root` is GOROOT/src. */
+export interface StdlibPackage {
+  root: string;
+  dir: string;
+  import_path: string;
+}
+
+/** Standard library resolution for one GOROOT. */
+export class GoStdlib {
+  private goroot: Promise<string | null> | null = null;
+  private packages: Promise<string[]> | null = null;
+  private apis = new Map<string, Promise<GoPackageApi | null>>();
+
+  constructor(private readonly configured?: string) {}
+
+  /** The GOROOT in use; null when none is configured and `go` is not on PATH. */
+  root(): Promise<string | null> {
+    this.goroot ??= (async () => {
+      if (this.configured) return resolve(this.configured);
+      let result;
+      try {
+        result = Bun.spawnSync(["go", "env", "GOROOT"], { stdout: "pipe", stderr: "ignore" });
+      } catch {
+        return null;
+      }
+      const found = result.success ? result.stdout.toString().trim() : "";
+      return found || null;
+    })();
+    return this.goroot;
+  }
+
+  /**
+   * Standard packages named by `query`: its import path, or every
+   * package whose last path element it is. Empty for anything else.
+   */
+  async locate(query: string): Promise<StdlibPackage[]> {
+    const goroot = await this.root();
+    if (!goroot) return [];
+    const root = join(goroot, "src");
+    const paths = await this.importPaths(root);
+    const matches = paths.includes(query) ? [query] : paths.filter((p) => p.split("/").at(-1) === query);
+    return matches.map((path) => ({ root, dir: path, import_path: path }));
+  }
+
+  /** The package's API, read once per GOROOT. */
+  api(pkg: StdlibPackage): Promise<GoPackageApi | null> {
+    if (!this.apis.has(pkg.import_path)) this.apis.set(pkg.import_path, readPackageApi(pkg.root, pkg.dir));
+    return this.apis.get(pkg.import_path)!;
+  }
+
+  /**
+   * Definitions of a package-qualified name (sync.RWMutex,
+   * net/http.Request.Write). Empty when the qualifier is not a
+   * standard package or the package does not export the name.
+   */
+  async lookup(symbol: string): Promise<StdlibSymbol[]> {
+    const slash = symbol.lastIndexOf("/");
+    const dot = symbol.indexOf(".", slash + 1);
+    if (dot <= 0) return [];
+    const qualifier = symbol.slice(0, dot);
+    const [name, member] = symbol.slice(dot + 1).split(".", 2);
+    if (!name) return [];
+
+    const found: StdlibSymbol[] = [];
+    for (const pkg of await this.locate(qualifier)) {
+      const api = await this.api(pkg);
+      if (!api) continue;
+      const at = (kind: StdlibSymbol["kind"], entry: GoApiEntry, qualified = entry.name): StdlibSymbol => ({
+        package: pkg.import_path,
+        name: qualified,
+        kind,
+        signature: entry.signature,
+        ...(entry.doc ? { doc: entry.doc } : {}),
+        file_path: join(pkg.root, entry.file),
+        line: entry.line,
+      });
+      if (member === undefined) {
+        for (const c of api.constants) if (c.name === name) found.push(at("constant", c));
+        for (const v of api.variables) if (v.name === name) found.push(at("variable", v));
+        for (const f of api.functions) if (f.name === name) found.push(at("function", f));
+      }
+      for (const t of api.types) {
+        if (member === undefined) {
+          // Constructors are listed under their type, not as functions
+          if (t.name === name) found.push(at(t.kind, t));
+          for (const c of t.constructors) if (c.name === name) found.push(at("function", c));
+        } else if (t.name === name) {
+          for (const m of t.methods) if (m.name === member) found.push(at("method", m, `${t.name}.${m.name}`));
+        }
+      }
+    }
+    return found;
+  }
+
+  /** Every importable standard package under GOROOT/src, sorted. */
+  private importPaths(root: string): Promise<string[]> {
+    this.packages ??= (async () => {
+      const paths: string[] = [];
+      const walk = async (dir: string) => {
+        const entries = await readdir(join(root, dir), { withFileTypes: true }).catch(() => []);
+        if (dir && entries.some((e) => e.isFile() && e.name.endsWith(".go") && !e.name.endsWith("_test.go"))) paths.push(dir);
+        for (const e of entries) {
+          if (e.isDirectory() && !SKIPPED.has(e.name) && !e.name.startsWith(".") && !e.name.startsWith("_")) {
+            await walk(dir ? `${dir}/${e.name}` : e.name);
+          }
+        }
+      };
+      if (await stat(root).then((s) => s.isDirectory(), () => false)) await walk("");
+      return paths.sort();
+    })();
+    return this.packages;
+  }
+}
@@ -0,0 +1,179 @@
+/**
+ * Tree-sitter grammars in TREE_SITTER_GRAMMARS, found at query time
+ *
+ * A grammar is picked up by dropping it into the grammar directory; the
+ * server does not need a release or a restart for a language it has
+ * never heard of. Two kinds of file are loaded:
+ *
+ *   tree-sitter-<name>.wasm    web-tree-sitter (`tree-sitter build --wasm`)
+ *   tree-sitter-<name>.node    a Node-API grammar binding, run by the native
+ *   (or .so / .dylib)          tree-sitter package (`bun add tree-sitter`)
+ *
+ * A shared object must be the binding a grammar's bindings/node builds
+ * (what `npm install tree-sitter-<name>` leaves in build/Release), not the
+ * bare parser library: only the binding can be loaded into the process.
+ *
+ * Grammars named like that cover the extensions ts-query.ts already
+ * knows. A grammars.json manifest in the same directory names the rest,
+ * and may rename files:
+ *
+ *   {
+ *     "grammars": [
+ *       { "name": "zig", "file": "zig.wasm", "extensions": [".zig", ".zon"] },
+ *       { "name": "hcl", "file": "hcl.node", "extensions": [".tf", ".hcl"], "language": "terraform" }
+ *     ]
+ *   }
+ *
+ * Manifest extensions the indexer has no parser for are indexed as code
+ * with the generic parser (code-indexer.ts setGrammarLanguages), under
+ * `language` (default: the grammar name), so their files are searchable
+ * and ts_query can reach them.
+ *
+ * The directory and manifest are checked on every lookup and re-read
+ * when either changed. A grammar already loaded stays loaded: replacing
+ * its file takes a restart (a native binding cannot be unloaded).
+ */
+
+import { readdir, readFile, stat } from "node:fs/promises";
+import { basename, extname, join } from "node:path";
+import { setGrammarLanguages } from "./code-indexer";
+
+export const GRAMMAR_MANIFEST = "grammars.json";
+
+/** Extensions of Node-API grammar bindings */
+const NATIVE_EXTENSIONS = new Set([".node", ".so", ".dylib"]);
+
+export type GrammarRuntime = "wasm" | "native";
+
+export interface Grammar {
+  name: string;
+  /** Absolute path of the grammar file */
+  path: string;
+  runtime: GrammarRuntime;
+  /** Extensions from the manifest, lowercased; empty for grammars found by file name */
+  extensions: string[];
+  /** Language facet of files indexed through the manifest */
+  language: string;
+}
+
+/** A grammars.json that cannot be used. */
+export class GrammarManifestError extends Error {}
+
+/** The runtime that loads `file`, or null when it is not a grammar. */
+export function grammarRuntime(file: string): GrammarRuntime | null {
+  const ext = extname(file).toLowerCase();
+  if (ext === ".wasm") return "wasm";
+  return NATIVE_EXTENSIONS.has(ext) ? "native" : null;
+}
+
+/**
+ * The grammars a grammars.json names, with paths under `dir`.
+ * Throws GrammarManifestError.
+ */
+export function parseGrammarManifest(text: string, dir: string): Grammar[] {
+  let raw: any;
+  try {
+    raw = JSON.parse(text);
+  } catch (err) {
+    throw new GrammarManifestError(`${GRAMMAR_MANIFEST} is not valid JSON: ${err instanceof Error ? err.message : String(err)}`);
+  }
+  if (!raw || !Array.isArray(raw.grammars)) throw new GrammarManifestError(`${GRAMMAR_MANIFEST} needs a "grammars" array`);
+
+  const seen = new Set<string>();
+  return raw.grammars.map((entry: any, i: number): Grammar => {
+    const where = `${GRAMMAR_MANIFEST} grammars[${i}]`;
+    if (typeof entry?.name !== "string" || !/^[\w-]+$/.test(entry.name)) {
+      throw new GrammarManifestError(`${where}: "name" must be letters, digits, _ or -`);
+    }
+    if (seen.has(entry.name)) throw new GrammarManifestError(`${where}: grammar "${entry.name}" is listed twice`);
+    seen.add(entry.name);
+    const file = entry.file ?? `tree-sitter-${entry.name}.wasm`;
+    if (typeof file !== "string" || /[/\\]/.test(file) || file.startsWith(".")) {
+      throw new GrammarManifestError(`${where}: "file" must name a file in the grammar directory`);
+    }
+    const runtime = grammarRuntime(file);
+    if (!runtime) throw new GrammarManifestError(`${where}: "${file}" is neither .wasm nor a .node, .so or .dylib binding`);
+    const extensions = entry.extensions ?? [];
+    if (!Array.isArray(extensions) || extensions.some((e: unknown) => typeof e !== "string" || !/^\.[^./\\]+$/.test(e))) {
+      throw new GrammarManifestError(`${where}: "extensions" must be a list like [".zig"]`);
+    }
+    if (entry.language !== undefined && (typeof entry.language !== "string" || !entry.language)) {
+      throw new GrammarManifestError(`${where}: "language" must be a non-empty string`);
+    }
+    return {
+      name: entry.name,
+      path: join(dir, file),
+      runtime,
+      extensions: extensions.map((e: string) => e.toLowerCase()),
+      language: entry.language ?? entry.name,
+    };
+  });
+}
+
+/** The grammars of one directory, re-read whenever it or its manifest changes. */
+export class GrammarSet {
+  private signature: string | null = null;
+  private grammars = new Map<string, Grammar>();
+  /** The last manifest that parsed */
+  private manifest: Grammar[] = [];
+  private loading: Promise<Map<string, Grammar>> | null = null;
+
+  constructor(
+    readonly dir: string,
+    private readonly options: { log?: (msg: string) => void } = {}
+  ) {}
+
+  /** Installed grammars by name. */
+  async current(): Promise<Map<string, Grammar>> {
+    // One reload at a time; concurrent queries share it
+    if (this.loading) return this.loading;
+    const signature = await this.stamp();
+    if (signature === this.signature) return this.grammars;
+    this.loading = this.reload(signature).finally(() => (this.loading = null));
+    return this.loading;
+  }
+
+  /** Modification times of the directory and manifest; a dropped-in file changes the first. */
+  private async stamp(): Promise<string> {
+    const mtime = (path: string) => stat(path).then((s) => String(s.mtimeMs), () => "-");
+    return `${await mtime(this.dir)}:${await mtime(join(this.dir, GRAMMAR_MANIFEST))}`;
+  }
+
+  private async reload(signature: string): Promise<Map<string, Grammar>> {
+    const names = await readdir(this.dir).catch(() => [] as string[]);
+    const found = new Map<string, Grammar>();
+    for (const file of names) {
+      const name = file.match(/^tree-sitter-([\w-]+)\.\w+$/)?.[1];
+      const runtime = grammarRuntime(file);
+      // A .wasm wins over a binding of the same grammar: it needs no native package
+      if (!name || !runtime || (found.get(name)?.runtime === "wasm" && runtime === "native")) continue;
+      found.set(name, { name, path: join(this.dir, file), runtime, extensions: [], language: name });
+    }
+
+    if (!names.includes(GRAMMAR_MANIFEST)) {
+      this.manifest = [];
+    } else {
+      try {
+        this.manifest = parseGrammarManifest(await readFile(join(this.dir, GRAMMAR_MANIFEST), "utf8"), this.dir);
+      } catch (err) {
+        // Keep what the last good manifest said rather than drop languages being indexed
+        this.options.log?.(`ignoring ${join(this.dir, GRAMMAR_MANIFEST)}: ${err instanceof Error ? err.message : String(err)}`);
+      }
+    }
+    for (const grammar of this.manifest) {
+      if (!names.includes(basename(grammar.path))) {
+        this.options.log?.(`${GRAMMAR_MANIFEST}: grammar "${grammar.name}" names ${grammar.path}, which does not exist`);
+        continue;
+      }
+      found.set(grammar.name, grammar);
+    }
+
+    const languages = new Map<string, string>();
+    for (const grammar of found.values()) for (const ext of grammar.extensions) languages.set(ext, grammar.language);
+    setGrammarLanguages(languages);
+
+    this.grammars = found;
+    this.signature = signature;
+    return found;
+  }
+}
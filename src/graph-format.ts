@@ -0,0 +1,87 @@
+/**
+ * Diagram output for graph-shaped tool results
+ *
+ * A graph answer is easiest to share as a picture. Tools that return
+ * nodes and edges can also render them as Graphviz DOT or Mermaid
+ * flowchart text, fenced so that GitHub, GitLab, and most doc sites
+ * render a Mermaid block inline and a DOT block is ready for `dot -Tsvg`.
+ *
+ * Node ids in the output are generated (n0, n1, …) and labels quoted,
+ * so module paths, file paths, and symbol names need no escaping by the
+ * caller. Output is deterministic: nodes in first-seen order, edges in
+ * input order.
+ */
+
+export const GRAPH_FORMATS = ["text", "dot", "mermaid"] as const;
+
+export type GraphFormat = (typeof GRAPH_FORMATS)[number];
+
+export interface GraphEdge {
+  from: string;
+  to: string;
+  /** Drawn on the edge, and dashed when it is not the graph's main relation */
+  label?: string;
+}
+
+export interface DiagramOptions {
+  /** Nodes to draw even without edges */
+  nodes?: string[];
+  /** Edges whose label is in here are dashed */
+  dashed?: string[];
+}
+
+function ids(edges: GraphEdge[], nodes: string[] = []): Map<string, string> {
+  const out = new Map<string, string>();
+  for (const name of [...nodes, ...edges.flatMap((e) => [e.from, e.to])]) {
+    if (!out.has(name)) out.set(name, `n${out.size}`);
+  }
+  return out;
+}
+
+function dotString(s: string): string {
+  return `"${s.replace(/\\/g, "\\\\").replace(/"/g, '\\"')}"`;
+}
+
+function mermaidString(s: string): string {
+  // Mermaid has no string escapes; #quot; is its entity for a quote
+  return `"${s.replace(/"/g, "#quot;")}"`;
+}
+
+/** Graphviz DOT source for the graph, unfenced. */
+export function toDot(edges: GraphEdge[], options: DiagramOptions = {}): string {
+  const nodes = ids(edges, options.nodes);
+  const lines = ["digraph G {", "  rankdir=LR;", "  node [shape=box];"];
+  for (const [name, id] of nodes) lines.push(`  ${id} [label=${dotString(name)}];`);
+  for (const e of edges) {
+    const attrs = [
+      e.label ? `label=${dotString(e.label)}` : null,
+      e.label && options.dashed?.includes(e.label) ? "style=dashed" : null,
+    ].filter(Boolean);
+    lines.push(`  ${nodes.get(e.from)} -> ${nodes.get(e.to)}${attrs.length ? ` [${attrs.join(", ")}]` : ""};`);
+  }
+  lines.push("}");
+  return lines.join("\n");
+}
+
+/** Mermaid flowchart source for the graph, unfenced. */
+export function toMermaid(edges: GraphEdge[], options: DiagramOptions = {}): string {
+  const nodes = ids(edges, options.nodes);
+  const lines = ["flowchart LR"];
+  for (const [name, id] of nodes) lines.push(`  ${id}[${mermaidString(name)}]`);
+  for (const e of edges) {
+    const arrow = e.label && options.dashed?.includes(e.label) ? "-.->" : "-->";
+    lines.push(`  ${nodes.get(e.from)} ${arrow}${e.label ? `|${mermaidString(e.label)}|` : ""} ${nodes.get(e.to)}`);
+  }
+  return lines.join("\n");
+}
+
+/** The diagram source in `format`; null for "text". */
+export function renderDiagram(format: GraphFormat, edges: GraphEdge[], options: DiagramOptions = {}): string | null {
+  if (format === "text") return null;
+  return format === "dot" ? toDot(edges, options) : toMermaid(edges, options);
+}
+
+/** `source` as a fenced code block tagged with its format. */
+export function fenceDiagram(format: GraphFormat, source: string): string {
+  return `\`\`\`${format}\n${source}\n\`\`\``;
+}
@@ -0,0 +1,283 @@
+/**
+ * gRPC API — the navigation operations without an MCP client
+ *
+ * Internal bots and CI checks want to ask the index a question, not
+ * speak MCP. With GRPC_PORT set, serve:http also serves the versioned
+ * service treenav.v1.Navigation (proto/treenav/v1/navigation.proto)
+ * from the same store: ListDocuments, SearchDocuments, FindSymbol,
+ * GetTree, GetNodeContent, NavigateTree, and GetStats answer exactly
+ * like the MCP tools of the same name, with the same defaults and
+ * limits, but return only data, no agent-facing text.
+ *
+ * The RPC layer is two optional packages (`bun add @grpc/grpc-js
+ * @grpc/proto-loader`), loaded when the server starts; nothing else
+ * here depends on them, so the handlers are plain functions over the
+ * store. Calls need `authorization: Bearer <HTTP_TOKEN>` metadata when
+ * HTTP_TOKEN is set. The port is plaintext; terminate TLS in front of
+ * it where traffic leaves the host.
+ */
+
+import { join } from "node:path";
+import type { DocumentStore } from "./store";
+import type { LazyIndex } from "./lazy-index";
+import type { CaseMode, DocumentMeta, SearchResult, TreeNode } from "./types";
+import { bearerMatches } from "./remote";
+
+/** Packages loaded on first use; variables so the build does not require them. */
+const GRPC_JS = "@grpc/grpc-js";
+const PROTO_LOADER = "@grpc/proto-loader";
+
+export const PROTO_PATH = join(import.meta.dir, "..", "proto", "treenav", "v1", "navigation.proto");
+
+/** gRPC status codes the handlers answer with */
+export const GRPC_STATUS = {
+  INVALID_ARGUMENT: 3,
+  NOT_FOUND: 5,
+  INTERNAL: 13,
+  UNAVAILABLE: 14,
+  UNAUTHENTICATED: 16,
+} as const;
+
+/** A failed call, carrying its gRPC status code. */
+export class GrpcError extends Error {
+  constructor(
+    readonly code: number,
+    message: string
+  ) {
+    super(message);
+  }
+}
+
+type Values = Record<string, { values: string[] }>;
+
+/** Requests as decoded by proto-loader (keepCase, defaults, enums as strings). */
+type Request = Record<string, any>;
+
+export type NavigationHandlers = Record<string, (request: Request) => Promise<object>>;
+
+const CASE_MODE: Record<string, CaseMode | undefined> = {
+  CASE_MODE_UNSPECIFIED: undefined,
+  CASE_MODE_INSENSITIVE: "insensitive",
+  CASE_MODE_SENSITIVE: "sensitive",
+  CASE_MODE_SMART: "smart",
+};
+
+const SYMBOL_KINDS = ["class", "interface", "function", "method", "type", "enum", "variable"];
+
+function filtersOf(map: Values | undefined): Record<string, string[]> | undefined {
+  const entries = Object.entries(map ?? {}).filter(([, v]) => v.values?.length);
+  return entries.length ? Object.fromEntries(entries.map(([k, v]) => [k, v.values])) : undefined;
+}
+
+function facetsOf(facets: Record<string, string[]>): Values {
+  return Object.fromEntries(Object.entries(facets).map(([k, values]) => [k, { values }]));
+}
+
+/** 0 means unset; otherwise clamp to `max`, as the tool schemas do. */
+function limitOf(limit: number | undefined, fallback: number, max: number): number {
+  return limit ? Math.min(limit, max) : fallback;
+}
+
+function required(value: string | undefined, field: string): string {
+  if (!value) throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `${field} is required`);
+  return value;
+}
+
+function document(d: DocumentMeta) {
+  return {
+    doc_id: d.doc_id,
+    title: d.title,
+    description: d.description,
+    file_path: d.file_path,
+    collection: d.collection,
+    word_count: d.word_count,
+    heading_count: d.heading_count,
+    last_modified: d.last_modified,
+    tags: d.tags,
+    facets: facetsOf(d.facets),
+  };
+}
+
+function hit(r: SearchResult) {
+  return {
+    doc_id: r.doc_id,
+    doc_title: r.doc_title,
+    file_path: r.file_path,
+    node_id: r.node_id,
+    node_title: r.node_title,
+    level: r.level,
+    snippet: r.snippet,
+    score: r.score,
+    line_start: r.line_start,
+    collection: r.collection,
+    matched_terms: r.matched_terms,
+  };
+}
+
+function node(n: TreeNode) {
+  return {
+    node_id: n.node_id,
+    title: n.title,
+    level: n.level,
+    parent_id: n.parent_id ?? "",
+    children: n.children,
+    content: n.content,
+    word_count: n.word_count,
+    line_start: n.line_start,
+    line_end: n.line_end,
+  };
+}
+
+/** The Navigation RPCs as plain async functions over `store`. */
+export function navigationHandlers(store: DocumentStore, options: { lazy?: LazyIndex } = {}): NavigationHandlers {
+  const { lazy } = options;
+  const missingDoc = (doc_id: string) =>
+    new GrpcError(GRPC_STATUS.NOT_FOUND, `Document "${doc_id}" not found`);
+
+  return {
+    async ListDocuments(req) {
+      if (lazy && req.query) await lazy.expandForQuery(req.query);
+      const result = store.listDocuments({
+        query: req.query || undefined,
+        tag: req.tag || undefined,
+        collection: req.collection || undefined,
+        path_prefix: req.path_prefix || undefined,
+        filters: filtersOf(req.filters),
+        limit: limitOf(req.limit, 30, 100),
+        offset: req.offset || 0,
+      });
+      return { total: result.total, documents: result.documents.map(document) };
+    },
+
+    async SearchDocuments(req) {
+      const query = required(req.query, "query");
+      if (lazy) {
+        if (req.doc_id) await lazy.ensureDocument(req.doc_id);
+        else await lazy.expandForQuery(query);
+      }
+      const results = store.searchDocuments(query, {
+        doc_id: req.doc_id || undefined,
+        path_prefix: req.path_prefix || undefined,
+        filters: filtersOf(req.filters),
+        limit: limitOf(req.limit, 15, 50),
+        case: CASE_MODE[req.case_mode],
+        word_boundaries: Boolean(req.word_boundaries),
+      });
+      return { results: results.map(hit) };
+    },
+
+    async FindSymbol(req) {
+      const query = required(req.query, "query");
+      if (req.kind && !SYMBOL_KINDS.includes(req.kind)) {
+        throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `kind must be one of ${SYMBOL_KINDS.join(", ")}`);
+      }
+      const filters: Record<string, string | string[]> = { content_type: "code" };
+      if (req.kind) filters.symbol_kind = req.kind;
+      if (req.languages?.length) filters.language = req.languages;
+      if (lazy) await lazy.expandForQuery(query);
+      const results = store.searchDocuments(query, {
+        filters,
+        path_prefix: req.path_prefix || undefined,
+        limit: limitOf(req.limit, 15, 50),
+        case: CASE_MODE[req.case_mode],
+        word_boundaries: Boolean(req.word_boundaries),
+      });
+      return { results: results.map(hit) };
+    },
+
+    async GetTree(req) {
+      const doc_id = required(req.doc_id, "doc_id");
+      if (lazy) await lazy.ensureDocument(doc_id);
+      const tree = store.getTree(doc_id);
+      if (!tree) throw missingDoc(doc_id);
+      return tree;
+    },
+
+    async GetNodeContent(req) {
+      const doc_id = required(req.doc_id, "doc_id");
+      const node_ids: string[] = req.node_ids ?? [];
+      if (node_ids.length === 0 || node_ids.length > 10) {
+        throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, "node_ids must list 1 to 10 ids");
+      }
+      if (lazy) await lazy.ensureDocument(doc_id);
+      const result = store.getNodeContent(doc_id, node_ids);
+      if (!result) throw missingDoc(doc_id);
+      const found = new Set(result.nodes.map((n) => n.node_id));
+      return { doc_id, nodes: result.nodes.map(node), missing: node_ids.filter((id) => !found.has(id)) };
+    },
+
+    async NavigateTree(req) {
+      const doc_id = required(req.doc_id, "doc_id");
+      const node_id = required(req.node_id, "node_id");
+      if (lazy) await lazy.ensureDocument(doc_id);
+      const result = store.getSubtree(doc_id, node_id);
+      if (!result) {
+        throw new GrpcError(GRPC_STATUS.NOT_FOUND, `Document "${doc_id}" not found or node "${node_id}" doesn't exist`);
+      }
+      return { doc_id, nodes: result.nodes.map(node), missing: [] };
+    },
+
+    async GetStats() {
+      const { document_count, total_nodes, indexed_terms, collections } = store.getStats();
+      return { document_count, total_nodes, indexed_terms, collections };
+    },
+  };
+}
+
+export interface GrpcServerOptions {
+  port: number;
+  /** Required as `authorization: Bearer <token>` metadata when set */
+  token?: string;
+  lazy?: LazyIndex;
+}
+
+/**
+ * Serve treenav.v1.Navigation for `store`. Resolves with the bound port
+ * and a stop function. Throws GrpcError when the gRPC packages are not
+ * installed or the port cannot be bound.
+ */
+export async function startGrpcServer(
+  store: DocumentStore,
+  options: GrpcServerOptions
+): Promise<{ port: number; stop: () => void }> {
+  let grpc: any;
+  let loader: any;
+  try {
+    grpc = await import(GRPC_JS);
+    loader = await import(PROTO_LOADER);
+  } catch {
+    throw new GrpcError(
+      GRPC_STATUS.UNAVAILABLE,
+      `${GRPC_JS} and ${PROTO_LOADER} are not installed; run \`bun add ${GRPC_JS} ${PROTO_LOADER}\` to enable GRPC_PORT`
+    );
+  }
+  const definition = loader.loadSync(PROTO_PATH, { keepCase: true, longs: Number, enums: String, defaults: true });
+  const service = grpc.loadPackageDefinition(definition).treenav.v1.Navigation.service;
+
+  const handlers = navigationHandlers(store, { lazy: options.lazy });
+  const implementation: Record<string, Function> = {};
+  for (const [name, handle] of Object.entries(handlers)) {
+    implementation[name] = (call: any, callback: Function) => {
+      if (!bearerMatches(call.metadata.get("authorization")[0]?.toString(), options.token)) {
+        return callback({ code: GRPC_STATUS.UNAUTHENTICATED, message: "missing or invalid bearer token" });
+      }
+      handle(call.request).then(
+        (response) => callback(null, response),
+        (err) =>
+          callback({
+            code: err instanceof GrpcError ? err.code : GRPC_STATUS.INTERNAL,
+            message: err instanceof Error ? err.message : String(err),
+          })
+      );
+    };
+  }
+
+  const server = new grpc.Server();
+  server.addService(service, implementation);
+  const port = await new Promise<number>((resolve, reject) =>
+    server.bindAsync(`0.0.0.0:${options.port}`, grpc.ServerCredentials.createInsecure(), (err: Error | null, bound: number) =>
+      err ? reject(new GrpcError(GRPC_STATUS.UNAVAILABLE, `Cannot bind gRPC port ${options.port}: ${err.message}`)) : resolve(bound)
+    )
+  );
+  return { port, stop: () => server.forceShutdown() };
+}
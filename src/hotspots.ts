@@ -0,0 +1,122 @@
+/**
+ * Churn × complexity hotspots — the hotspots tool
+ *
+ * Code that is both complicated and changed often is where defects and
+ * refactoring effort concentrate; complicated code nobody touches, and
+ * simple code that changes every week, matter much less. Each indexed
+ * code file is scored
+ *
+ *   score = commits × complexity
+ *
+ * where commits count the file's commits in the window (`since`, or all
+ * of history) and complexity is the file's cyclomatic complexity: one
+ * per function plus one per decision point anywhere in the file. The
+ * decision points are branch and loop keywords (if, for, while, case,
+ * catch, except, …), short-circuit && / || / and / or, and the ternary
+ * ?, counted outside strings and comments. It is an approximation that
+ * needs no parser per language, which is enough to rank files against
+ * each other. The most complex function is reported alongside, as the
+ * place to start.
+ *
+ * Churn comes from one `git log --numstat` pass per collection root per
+ * call.
+ */
+
+import type { DocumentMeta, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols } from "./code-indexer";
+import { gitChurn, type FileChurn } from "./git-history";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { currentDeadline } from "./deadline";
+import { FileScanCache } from "./scan-cache";
+
+const DECISION =
+  /\b(?:if|for|foreach|while|case|catch|except|elif|elsif|unless|until|rescue|and|or)\b|&&|\|\||\?(?![.?:])/g;
+
+export interface FileComplexity {
+  complexity: number;
+  functions: number;
+  /** The function with the most decision points */
+  max_function?: { name: string; line: number; complexity: number };
+}
+
+/** Approximate cyclomatic complexity of a source file and its functions. */
+export function fileComplexity(source: string, filePath: string): FileComplexity {
+  const lines = blankLiterals(source, hashCommentsFor(filePath)).split("\n");
+  const decisions = lines.map((line) => line.match(DECISION)?.length ?? 0);
+  const symbols = parseCodeSymbols(source, filePath);
+  const byId = new Map(symbols.map((s) => [s.id, s]));
+
+  let functions = 0;
+  let max: FileComplexity["max_function"];
+  for (const s of symbols) {
+    if (s.kind !== "function" && s.kind !== "method") continue;
+    functions++;
+    let complexity = 1;
+    for (let n = s.line_start; n <= s.line_end; n++) complexity += decisions[n - 1] ?? 0;
+    if (!max || complexity > max.complexity) {
+      const parent = s.parent_id ? byId.get(s.parent_id) : undefined;
+      max = { name: parent ? `${parent.name}.${s.name}` : s.name, line: s.line_start, complexity };
+    }
+  }
+  return {
+    complexity: functions + decisions.reduce((a, b) => a + b, 0),
+    functions,
+    ...(max ? { max_function: max } : {}),
+  };
+}
+
+export interface Hotspot extends FileComplexity, FileChurn {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+  score: number;
+}
+
+/** Hotspot ranking over the code collections of a store. */
+export class Hotspots {
+  private readonly cache: FileScanCache<FileComplexity>;
+
+  constructor(config: IndexConfig) {
+    this.cache = new FileScanCache(config, (source, meta) => fileComplexity(source, meta.file_path));
+  }
+
+  /**
+   * Files at `path` (file, directory prefix, or glob; all code when
+   * omitted) that changed in the window, highest score first. Returns
+   * null when no indexed code file matches the path.
+   */
+  async rank(
+    store: DocumentStore,
+    options: { path?: string; since?: string } = {}
+  ): Promise<{ files: number; changed: number; hotspots: Hotspot[] } | null> {
+    const docs: DocumentMeta[] = options.path
+      ? store.codeDocumentsAt(options.path)
+      : store.listDocuments({ filters: { content_type: "code" }, limit: Infinity }).documents;
+    if (docs.length === 0) return null;
+
+    const churn = new Map<string, Map<string, FileChurn>>();
+    const hotspots: Hotspot[] = [];
+    const deadline = currentDeadline();
+    for (const doc of docs) {
+      if (deadline?.expired()) break;
+      const root = this.cache.root(doc.collection);
+      if (!root) continue;
+      if (!churn.has(doc.collection)) churn.set(doc.collection, gitChurn(root, options.since));
+      const changes = churn.get(doc.collection)!.get(doc.file_path);
+      if (!changes) continue;
+      const complexity = await this.cache.get(doc);
+      if (!complexity) continue;
+      hotspots.push({
+        doc_id: doc.doc_id,
+        collection: doc.collection,
+        file_path: doc.file_path,
+        score: changes.commits * complexity.complexity,
+        ...changes,
+        ...complexity,
+      });
+    }
+    hotspots.sort((a, b) => b.score - a.score || b.commits - a.commits || a.file_path.localeCompare(b.file_path));
+    return { files: docs.length, changed: hotspots.length, hotspots };
+  }
+}
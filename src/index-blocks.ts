@@ -0,0 +1,176 @@
+/**
+ * Block-compressed index files — INDEX_COMPRESSION=zstd
+ *
+ * An INDEX_CACHE written as plain JSON holds the full text of every
+ * section, so on a large repository it grows as big as the repository.
+ * With INDEX_COMPRESSION=zstd, saveIndexCache writes this layout
+ * instead:
+ *
+ *   "TNZB"                    magic
+ *   u32 LE                    byte length of the directory
+ *   directory                 zstd frame of JSON: the cache header
+ *                             (version, created_at, config_fingerprint),
+ *                             [doc_id, content_hash, block] per document,
+ *                             and [offset, length] per block
+ *   blocks                    one zstd frame per block: a JSON array of
+ *                             IndexedDocuments, about BLOCK_BYTES raw
+ *
+ * Each block is compressed on its own, so a reader can answer "which
+ * documents, at which content hash" from the directory alone, and
+ * decode one document by reading and decompressing just its block.
+ * `index --verify` reads only the directory; loading reads everything.
+ *
+ * Blocks are large enough to compress well without a dictionary. Bun's
+ * zstd binding takes no dictionary, so none is trained. Readers detect
+ * the layout by its magic, so plain and compressed caches load the same
+ * way whatever INDEX_COMPRESSION is set to.
+ */
+
+import { closeSync, openSync, readSync } from "node:fs";
+import type { IndexedDocument } from "./types";
+
+export const INDEX_COMPRESSIONS = ["none", "zstd"] as const;
+export type IndexCompression = (typeof INDEX_COMPRESSIONS)[number];
+
+/** Raw JSON bytes per block before a new block is started. */
+export const BLOCK_BYTES = 256 * 1024;
+
+/** zstd level: fast enough to rewrite the cache at shutdown. */
+const LEVEL = 3;
+
+const MAGIC = "TNZB";
+const PREAMBLE = 8;
+
+/** An index cache file without its documents. */
+export interface BlockFileHead {
+  version: number;
+  created_at: string;
+  config_fingerprint: string;
+}
+
+/** One document as listed in the directory. */
+export interface BlockEntry {
+  doc_id: string;
+  content_hash: string;
+  block: number;
+}
+
+interface Directory extends BlockFileHead {
+  documents: [string, string, number][];
+  blocks: [number, number][];
+}
+
+/** Encode `documents` in the block layout. */
+export function encodeBlockFile(head: BlockFileHead, documents: IndexedDocument[], blockBytes = BLOCK_BYTES): Uint8Array {
+  const frames: Uint8Array[] = [];
+  const directory: Directory = { ...head, documents: [], blocks: [] };
+  let offset = 0;
+  let pending: string[] = [];
+  let pendingBytes = 0;
+  const flush = () => {
+    if (pending.length === 0) return;
+    const frame = Bun.zstdCompressSync(Buffer.from(`[${pending.join(",")}]`), { level: LEVEL });
+    directory.blocks.push([offset, frame.length]);
+    frames.push(frame);
+    offset += frame.length;
+    pending = [];
+    pendingBytes = 0;
+  };
+  for (const doc of documents) {
+    const json = JSON.stringify(doc);
+    directory.documents.push([doc.meta.doc_id, doc.meta.content_hash, directory.blocks.length]);
+    pending.push(json);
+    pendingBytes += json.length;
+    if (pendingBytes >= blockBytes) flush();
+  }
+  flush();
+
+  const encoded = Bun.zstdCompressSync(Buffer.from(JSON.stringify(directory)), { level: LEVEL });
+  const out = new Uint8Array(PREAMBLE + encoded.length + offset);
+  out.set(Buffer.from(MAGIC, "latin1"), 0);
+  new DataView(out.buffer).setUint32(4, encoded.length, true);
+  out.set(encoded, PREAMBLE);
+  let at = PREAMBLE + encoded.length;
+  for (const frame of frames) {
+    out.set(frame, at);
+    at += frame.length;
+  }
+  return out;
+}
+
+/**
+ * Random access to a file written by encodeBlockFile. Blocks are read
+ * from disk as they are needed; close() releases the file.
+ */
+export class BlockFileReader {
+  readonly head: BlockFileHead;
+  private readonly directory: Directory;
+  private readonly fd: number;
+  private readonly base: number;
+  private byId: Map<string, number> | null = null;
+  private last: { block: number; documents: IndexedDocument[] } | null = null;
+
+  private constructor(fd: number, directory: Directory, base: number) {
+    this.fd = fd;
+    this.directory = directory;
+    this.base = base;
+    this.head = { version: directory.version, created_at: directory.created_at, config_fingerprint: directory.config_fingerprint };
+  }
+
+  /** Open `path`; null when it is not in the block layout. Throws on a damaged file. */
+  static open(path: string): BlockFileReader | null {
+    const fd = openSync(path, "r");
+    try {
+      const preamble = Buffer.alloc(PREAMBLE);
+      if (readSync(fd, preamble, 0, PREAMBLE, 0) < PREAMBLE || preamble.toString("latin1", 0, 4) !== MAGIC) {
+        closeSync(fd);
+        return null;
+      }
+      const length = preamble.readUInt32LE(4);
+      const directory = JSON.parse(Buffer.from(Bun.zstdDecompressSync(readAt(fd, PREAMBLE, length))).toString("utf-8")) as Directory;
+      if (!Array.isArray(directory.documents) || !Array.isArray(directory.blocks)) throw new Error("no document directory");
+      return new BlockFileReader(fd, directory, PREAMBLE + length);
+    } catch (err) {
+      closeSync(fd);
+      throw err;
+    }
+  }
+
+  /** Every document's id and content hash, without reading any block. */
+  entries(): BlockEntry[] {
+    return this.directory.documents.map(([doc_id, content_hash, block]) => ({ doc_id, content_hash, block }));
+  }
+
+  /** One document, decoding only its block; null when it is not in the file. */
+  document(docId: string): IndexedDocument | null {
+    this.byId ??= new Map(this.directory.documents.map(([id, , block]) => [id, block]));
+    const block = this.byId.get(docId);
+    if (block === undefined) return null;
+    return this.block(block).find((d) => d.meta.doc_id === docId) ?? null;
+  }
+
+  /** All documents, in the order they were written. */
+  documents(): IndexedDocument[] {
+    const all: IndexedDocument[] = [];
+    for (let i = 0; i < this.directory.blocks.length; i++) all.push(...this.block(i));
+    return all;
+  }
+
+  close(): void {
+    closeSync(this.fd);
+  }
+
+  private block(index: number): IndexedDocument[] {
+    if (this.last?.block === index) return this.last.documents;
+    const [offset, length] = this.directory.blocks[index];
+    const documents = JSON.parse(Buffer.from(Bun.zstdDecompressSync(readAt(this.fd, this.base + offset, length))).toString("utf-8"));
+    this.last = { block: index, documents };
+    return documents;
+  }
+}
+
+function readAt(fd: number, position: number, length: number): Buffer {
+  const buffer = Buffer.alloc(length);
+  if (readSync(fd, buffer, 0, length, position) < length) throw new Error("truncated file");
+  return buffer;
+}
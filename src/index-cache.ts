@@ -0,0 +1,481 @@
+/**
+ * Persistent index cache — warm start with background re-validation
+ *
+ * Indexing a large repository from scratch takes long enough that the
+ * first query after a restart can wait minutes. This module serializes
+ * the indexed documents to disk so the next startup can serve from the
+ * last known state immediately, then re-validate against the working
+ * tree in the background.
+ *
+ * Re-validation reuses the Pagefind-style content hash already stored
+ * on every DocumentMeta: files whose hash is unchanged are skipped,
+ * changed or new files are re-parsed and patched into the live store,
+ * and documents whose file disappeared are removed. While the pass is
+ * running the store reports `isValidating()` so tools can flag results
+ * as possibly stale.
+ *
+ * verifyIndexCache() is the offline counterpart for `index --verify`: it
+ * compares an artifact with the working tree without touching either,
+ * and reports files the artifact lacks, entries whose file is gone, and
+ * entries whose content hash no longer matches.
+ *
+ * Only the parsed documents are persisted. The positional index,
+ * facets, and glossary are rebuilt by DocumentStore.load(), which is
+ * a pure in-memory pass and fast compared to reading + parsing files.
+ * They are written as JSON, or with INDEX_COMPRESSION=zstd in the block
+ * layout of index-blocks.ts; either is read back.
+ */
+
+import { mkdir, rename, rm } from "node:fs/promises";
+import { existsSync } from "node:fs";
+import { dirname, resolve } from "node:path";
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import type { DocumentStore } from "./store";
+import { indexAllCollections, indexFile, listCollectionFiles, markdownDocId } from "./indexer";
+import { codeDocId, indexCodeFile, listCodeFiles } from "./code-indexer";
+import { DEFAULT_SYMLINK_POLICY } from "./walk";
+import { readSource } from "./encoding";
+import { relativePath } from "./paths";
+import { indexPriorityFiles, priorityFiles, type PriorityOptions } from "./warmup";
+import { BlockFileReader, encodeBlockFile, type IndexCompression } from "./index-blocks";
+
+/**
+ * Bump whenever the persisted shape of IndexedDocument changes. 5: file
+ * paths are "/"-separated on Windows too.
+ */
+export const INDEX_CACHE_VERSION = 5;
+
+/** Default cache location, relative to the working directory. */
+export const DEFAULT_INDEX_CACHE_PATH = ".treenav/index.json";
+
+/** On-disk cache file shape */
+export interface IndexCacheFile {
+  version: number;
+  created_at: string;
+  /** Fingerprint of the IndexConfig the cache was built with */
+  config_fingerprint: string;
+  documents: IndexedDocument[];
+}
+
+/** Outcome of a background re-validation pass */
+export interface RevalidationReport {
+  checked: number;
+  unchanged: number;
+  updated: string[];
+  added: string[];
+  removed: string[];
+  failed: string[];
+  elapsed_ms: number;
+}
+
+// ── Fingerprinting ──────────────────────────────────────────────────
+//
+// A cache built for a different set of roots, globs, include patterns,
+// symlink policies, or tree limits would silently serve the wrong corpus, so the config
+// is fingerprinted and a mismatch discards the cache. Roots are resolved first so a
+// relative and an absolute spelling of the same root match.
+
+export function configFingerprint(config: IndexConfig): string {
+  const shape = {
+    collections: config.collections.map(collectionShape),
+    code_collections: (config.code_collections ?? []).map(collectionShape),
+    ...(config.dependency_collections?.length ? { dependency_collections: config.dependency_collections.map(collectionShape) } : {}),
+    max_depth: config.max_depth,
+    summary_length: config.summary_length,
+  };
+  return Bun.hash(JSON.stringify(shape)).toString(16);
+}
+
+function collectionShape(c: CollectionConfig): string[] {
+  const shape = [c.name, resolve(c.root), c.glob_pattern ?? "", c.symlinks ?? DEFAULT_SYMLINK_POLICY, (c.include ?? []).join(",")];
+  // Only exclusion changes which files are indexed; appended so older caches stay valid
+  return c.vendor === "exclude" ? [...shape, "vendor=exclude"] : shape;
+}
+
+// ── Save / load ─────────────────────────────────────────────────────
+
+/**
+ * Write the documents to `path`, as JSON or with `compression` in the
+ * block layout. Writes to a temp file first and renames it into place
+ * so a crash mid-write never leaves a truncated cache.
+ */
+export async function saveIndexCache(
+  path: string,
+  config: IndexConfig,
+  documents: IndexedDocument[],
+  options: { compression?: IndexCompression } = {}
+): Promise<void> {
+  const head = {
+    version: INDEX_CACHE_VERSION,
+    created_at: new Date().toISOString(),
+    config_fingerprint: configFingerprint(config),
+  };
+  await mkdir(dirname(path), { recursive: true });
+  const tmp = `${path}.tmp-${process.pid}`;
+  try {
+    const file: IndexCacheFile = { ...head, documents };
+    await Bun.write(tmp, options.compression === "zstd" ? encodeBlockFile(head, documents) : JSON.stringify(file));
+    await rename(tmp, path);
+  } catch (err) {
+    await rm(tmp, { force: true });
+    throw err;
+  }
+}
+
+/**
+ * Read a cache written by saveIndexCache. Returns null when the file is
+ * missing, unreadable, from another cache version, or built for a
+ * different config.
+ */
+export async function loadIndexCache(
+  path: string,
+  config: IndexConfig
+): Promise<IndexedDocument[] | null> {
+  const read = await readIndexCache(path, config);
+  return "documents" in read ? read.documents : null;
+}
+
+/** loadIndexCache, with the reason when the cache is unusable. */
+async function readIndexCache(
+  path: string,
+  config: IndexConfig
+): Promise<{ documents: IndexedDocument[] } | { unusable: string }> {
+  const read = await withIndexCache(path, config, (file) => ("reader" in file ? file.reader.documents() : file.documents));
+  return "value" in read ? { documents: read.value } : read;
+}
+
+/**
+ * Open the cache at `path`, check it against `config`, and pass it to
+ * `use`: a plain cache as parsed, a compressed one as a reader that
+ * decodes blocks on demand.
+ */
+async function withIndexCache<T>(
+  path: string,
+  config: IndexConfig,
+  use: (file: IndexCacheFile | { reader: BlockFileReader }) => T
+): Promise<{ value: T } | { unusable: string }> {
+  if (!existsSync(path)) return { unusable: "no such file" };
+
+  let reader: BlockFileReader | null;
+  try {
+    reader = BlockFileReader.open(path);
+  } catch {
+    return { unusable: "not a readable compressed index" };
+  }
+  if (reader) {
+    try {
+      const unusable = checkHead(reader.head, config);
+      if (unusable) return { unusable };
+      return { value: use({ reader }) };
+    } catch {
+      return { unusable: "not a readable compressed index" };
+    } finally {
+      reader.close();
+    }
+  }
+
+  let file: IndexCacheFile;
+  try {
+    file = (await Bun.file(path).json()) as IndexCacheFile;
+  } catch {
+    return { unusable: "not valid JSON" };
+  }
+  const unusable = checkHead(file, config);
+  if (unusable) return { unusable };
+  if (!Array.isArray(file.documents)) return { unusable: "no documents list" };
+  return { value: use(file) };
+}
+
+function checkHead(head: { version: number; config_fingerprint: string }, config: IndexConfig): string | null {
+  if (head.version !== INDEX_CACHE_VERSION) {
+    return `cache version ${head.version}, expected ${INDEX_CACHE_VERSION}`;
+  }
+  if (head.config_fingerprint !== configFingerprint(config)) {
+    return "built for a different configuration (roots, globs, include, symlinks, vendor policy, or limits)";
+  }
+  return null;
+}
+
+// ── Verification ────────────────────────────────────────────────────
+
+/** Differences between an artifact and the working tree */
+export interface VerificationReport {
+  /** Why the artifact cannot be used at all; the lists are then empty */
+  unusable?: string;
+  /** Documents in the artifact */
+  indexed: number;
+  /** Files the config matches in the working tree */
+  files: number;
+  unchanged: number;
+  /** On disk and matched by the config, but not in the artifact */
+  missing: string[];
+  /** In the artifact, but the file is gone or no longer matched */
+  orphaned: string[];
+  /** In both, but the file's content hash differs from the artifact's */
+  mismatched: string[];
+  /** Files that could not be read */
+  failed: string[];
+  elapsed_ms: number;
+}
+
+/**
+ * Cross-check the artifact at `path` against the working tree. Files
+ * are hashed, not parsed, and nothing is written; the lists hold
+ * doc_ids.
+ */
+export async function verifyIndexCache(path: string, config: IndexConfig): Promise<VerificationReport> {
+  const start = Date.now();
+  const report: VerificationReport = {
+    indexed: 0,
+    files: 0,
+    unchanged: 0,
+    missing: [],
+    orphaned: [],
+    mismatched: [],
+    failed: [],
+    elapsed_ms: 0,
+  };
+  // A compressed cache answers this from its directory, without decoding a block
+  const read = await withIndexCache(path, config, (file) =>
+    "reader" in file
+      ? file.reader.entries().map((e) => [e.doc_id, e.content_hash] as const)
+      : file.documents.map((d) => [d.meta.doc_id, d.meta.content_hash] as const)
+  );
+  if (!("value" in read)) {
+    report.unusable = read.unusable;
+    report.elapsed_ms = Date.now() - start;
+    return report;
+  }
+
+  const hashes = new Map(read.value);
+  report.indexed = hashes.size;
+  const seen = new Set<string>();
+  for (const { collection, kind } of configuredCollections(config)) {
+    const files = kind === "markdown"
+      ? await listCollectionFiles(collection)
+      : await listCodeFiles(collection);
+
+    for (const file of files) {
+      report.files++;
+      const relPath = relativePath(collection.root, file);
+      const docId = kind === "markdown"
+        ? markdownDocId(collection.name, relPath)
+        : codeDocId(collection.name, relPath);
+      seen.add(docId);
+
+      const expected = hashes.get(docId);
+      if (expected === undefined) {
+        report.missing.push(docId);
+        continue;
+      }
+      try {
+        const hash = Bun.hash((await readSource(file)).text).toString(16);
+        if (hash === expected) report.unchanged++;
+        else report.mismatched.push(docId);
+      } catch {
+        report.failed.push(docId);
+      }
+    }
+  }
+  for (const docId of hashes.keys()) {
+    if (!seen.has(docId)) report.orphaned.push(docId);
+  }
+
+  report.elapsed_ms = Date.now() - start;
+  return report;
+}
+
+function configuredCollections(config: IndexConfig): { collection: CollectionConfig; kind: "markdown" | "code" }[] {
+  return [
+    ...config.collections.map((collection) => ({ collection, kind: "markdown" as const })),
+    ...(config.code_collections ?? []).map((collection) => ({ collection, kind: "code" as const })),
+    ...(config.dependency_collections ?? []).map((collection) => ({ collection, kind: "code" as const })),
+  ];
+}
+
+// ── Background re-validation ────────────────────────────────────────
+
+/**
+ * Re-hash every file in every configured collection and patch the store
+ * so it matches the working tree. Unchanged files are never re-parsed.
+ */
+export async function revalidateIndex(
+  store: DocumentStore,
+  config: IndexConfig
+): Promise<RevalidationReport> {
+  const start = Date.now();
+  const report: RevalidationReport = {
+    checked: 0,
+    unchanged: 0,
+    updated: [],
+    added: [],
+    removed: [],
+    failed: [],
+    elapsed_ms: 0,
+  };
+
+  const seen = new Set<string>();
+  const collections = configuredCollections(config);
+
+  for (const { collection, kind } of collections) {
+    const files = kind === "markdown"
+      ? await listCollectionFiles(collection)
+      : await listCodeFiles(collection);
+
+    for (const file of files) {
+      report.checked++;
+      const relPath = relativePath(collection.root, file);
+      const docId = kind === "markdown"
+        ? markdownDocId(collection.name, relPath)
+        : codeDocId(collection.name, relPath);
+      seen.add(docId);
+
+      try {
+        const raw = (await readSource(file)).text;
+        const hash = Bun.hash(raw).toString(16);
+        const existing = store.getDocMeta(docId);
+        if (existing && existing.content_hash === hash) {
+          report.unchanged++;
+          continue;
+        }
+
+        const doc = kind === "markdown"
+          ? await indexFile(file, collection.root, collection.name)
+          : await indexCodeFile(file, collection.root, collection.name);
+        store.addDocument(doc);
+        (existing ? report.updated : report.added).push(docId);
+      } catch {
+        report.failed.push(docId);
+      }
+    }
+  }
+
+  // Anything still loaded from the cache but no longer on disk is gone.
+  const collectionNames = new Set(collections.map((c) => c.collection.name));
+  for (const doc of store.exportDocuments()) {
+    if (!collectionNames.has(doc.meta.collection)) continue;
+    if (!seen.has(doc.meta.doc_id)) {
+      store.removeDocument(doc.meta.doc_id);
+      report.removed.push(doc.meta.doc_id);
+    }
+  }
+
+  report.elapsed_ms = Date.now() - start;
+  return report;
+}
+
+// ── Startup helper shared by the stdio and HTTP servers ─────────────
+
+export interface WarmStartResult {
+  /** True when documents came from the cache rather than a full index */
+  warm: boolean;
+  /**
+   * Resolves when background re-validation finishes: on a warm start,
+   * or on a cold start that indexed priority files first. Resolves to
+   * null if the pass itself failed; the state so far is kept.
+   */
+  validation: Promise<RevalidationReport | null> | null;
+  /** Priority files indexed ahead of the rest (0 when none were) */
+  priority: number;
+}
+
+/**
+ * Load the store from the cache at `cachePath` when one is usable,
+ * otherwise run a full index. On a warm start the store is flagged as
+ * validating and a background pass patches stale entries, then rewrites
+ * the cache. On a cold start the cache is written only when `persist`
+ * is set, so servers never drop files into a tree that didn't ask for it.
+ * A cold start with `priority` files indexes those, returns, and leaves
+ * the rest to the same background pass (see warmup.ts).
+ */
+export async function loadOrBuildIndex(
+  store: DocumentStore,
+  config: IndexConfig,
+  options: {
+    cachePath: string;
+    persist: boolean;
+    priority?: PriorityOptions;
+    compression?: IndexCompression;
+    log?: (msg: string) => void;
+  }
+): Promise<WarmStartResult> {
+  const log = options.log ?? ((msg: string) => console.error(msg));
+  const cached = await loadIndexCache(options.cachePath, config);
+  const save = () =>
+    saveIndexCache(options.cachePath, config, store.exportDocuments(), { compression: options.compression }).catch((err) =>
+      log(`Warning: failed to write index cache ${options.cachePath}: ${err.message}`)
+    );
+
+  if (!cached) {
+    const first = options.priority ? await priorityFiles(config, { ...options.priority, log }) : [];
+    if (first.length > 0) {
+      store.load(await indexPriorityFiles(first));
+      store.beginValidating();
+      log(`Warm-up: serving ${first.length} priority files while the rest are indexed`);
+      const validation = inBackground(store, config, log, "Indexing", async (report) => {
+        if (options.persist) await save();
+        return report;
+      });
+      return { warm: false, validation, priority: first.length };
+    }
+
+    const documents = await indexAllCollections(config);
+    store.load(documents);
+    if (options.persist) await save();
+    return { warm: false, validation: null, priority: 0 };
+  }
+
+  store.load(cached);
+  store.beginValidating();
+  log(`Warm start: serving ${cached.length} cached documents from ${options.cachePath} while re-validating`);
+
+  const validation = inBackground(store, config, log, "Re-validation", async (report) => {
+    const changed = report.updated.length + report.added.length + report.removed.length;
+    if (changed > 0) await save();
+    return report;
+  });
+
+  return { warm: true, validation, priority: 0 };
+}
+
+/** Run revalidateIndex with the store flagged as validating, then `done`. */
+function inBackground(
+  store: DocumentStore,
+  config: IndexConfig,
+  log: (msg: string) => void,
+  what: string,
+  done: (report: RevalidationReport) => Promise<RevalidationReport>
+): Promise<RevalidationReport | null> {
+  return revalidateIndex(store, config)
+    .then(async (report) => {
+      store.endValidating();
+      log(
+        `${what} complete in ${report.elapsed_ms}ms — ${report.unchanged} unchanged, ` +
+          `${report.updated.length} updated, ${report.added.length} added, ` +
+          `${report.removed.length} removed, ${report.failed.length} failed`
+      );
+      return done(report);
+    })
+    .catch((err) => {
+      store.endValidating();
+      log(`Warning: ${what.toLowerCase()} failed: ${err.message}`);
+      return null;
+    });
+}
+
+/**
+ * Write the store back to the cache, e.g. at shutdown after the watcher
+ * applied edits. Skipped (false) while a warm-start re-validation is
+ * still running: the cache on disk is consistent, and the next start
+ * re-validates it again.
+ */
+export async function flushIndexCache(
+  store: DocumentStore,
+  config: IndexConfig,
+  cachePath: string,
+  options: { compression?: IndexCompression } = {}
+): Promise<boolean> {
+  if (store.isValidating()) return false;
+  await saveIndexCache(cachePath, config, store.exportDocuments(), options);
+  return true;
+}
@@ -12,15 +12,20 @@
  */
 
 import { readdir, stat } from "node:fs/promises";
-import { join, relative, basename, extname } from "node:path";
+import { join, basename, extname } from "node:path";
 import type {
   TreeNode,
   DocumentMeta,
   IndexedDocument,
   IndexConfig,
   CollectionConfig,
+  IndexRunStats,
 } from "./types";
 import { indexCodeCollection, isCodeFile } from "./code-indexer";
+import { normalizeLineEndings, readSource } from "./encoding";
+import { checkFileQuota, walkFiles } from "./walk";
+import { isIncluded, mayContainIncluded } from "./coverage";
+import { relativePath } from "./paths";
 
 // ── State machine for tracking parse position ────────────────────────
 
@@ -580,16 +585,42 @@ function computeContentHash(content: string): string {
 
 // ── Index a single markdown file ────────────────────────────────────
 
+/**
+ * Build the doc_id for a markdown file: collection:path segments with the
+ * .md extension dropped. Shared with the index cache so re-validation can
+ * map files on disk back to already-loaded documents.
+ */
+export function markdownDocId(collectionName: string, relPath: string): string {
+  return `${collectionName}:${relPath.replace(/\.md$/i, "").replace(/[/\\]/g, ":")}`;
+}
+
 export async function indexFile(
   filePath: string,
   docsRoot: string,
   collectionName: string = "docs"
 ): Promise<IndexedDocument> {
-  const raw = await Bun.file(filePath).text();
-  const relPath = relative(docsRoot, filePath);
-  const doc_id = `${collectionName}:${relPath.replace(/\.md$/i, "").replace(/[/\\]/g, ":")}`;
+  const source = await readSource(filePath);
+  const fstat = await stat(filePath);
+  const doc = indexMarkdownContent(source.text, relativePath(docsRoot, filePath), collectionName, fstat.mtime.toISOString());
+  if (source.encoding !== "utf-8") doc.meta.encoding = source.encoding;
+  return doc;
+}
 
-  const { frontmatter, body } = extractFrontmatter(raw);
+/**
+ * Index markdown text that need not be on disk (e.g. a blob at a git
+ * ref). `relPath` is relative to the collection root.
+ */
+export function indexMarkdownContent(
+  raw: string,
+  relPath: string,
+  collectionName: string,
+  lastModified: string
+): IndexedDocument {
+  const doc_id = markdownDocId(collectionName, relPath);
+
+  // Parse the NFC form with "\n" line breaks; the hash below still covers
+  // the text as read
+  const { frontmatter, body } = extractFrontmatter(normalizeLineEndings(raw).normalize("NFC"));
   const tree = buildTree(body, doc_id);
 
   // Content hash for incremental re-indexing (Pagefind-inspired)
@@ -610,7 +641,7 @@ export async function indexFile(
   let title =
     (frontmatter.title as string) ||
     tree.find((n) => n.level <= 1)?.title ||
-    basename(filePath, extname(filePath));
+    basename(relPath, extname(relPath));
 
   // Improve generic titles like "Introduction" with parent directory context
   title = improveGenericTitle(title, relPath);
@@ -633,8 +664,6 @@ export async function indexFile(
     }
   }
 
-  const fstat = await stat(filePath);
-
   const meta: DocumentMeta = {
     doc_id,
     file_path: relPath,
@@ -643,7 +672,7 @@ export async function indexFile(
     word_count: tree.reduce((sum, n) => sum + n.word_count, 0),
     heading_count: tree.length,
     max_depth,
-    last_modified: fstat.mtime.toISOString(),
+    last_modified: lastModified,
     tags: (frontmatter.tags as string[]) || [],
     content_hash,
     collection: collectionName,
@@ -656,18 +685,36 @@ export async function indexFile(
 
 // ── Scan directory and index all markdown files ─────────────────────
 
+/**
+ * List the absolute paths of all markdown files in a collection,
+ * following symlinks per the collection's policy and restricted to
+ * its include patterns. `under` limits the walk to one root-relative
+ * directory or file.
+ */
+export async function listCollectionFiles(
+  collection: CollectionConfig,
+  under?: string,
+  limit?: number
+): Promise<string[]> {
+  const glob = new Bun.Glob(collection.glob_pattern || "**/*.md");
+  return walkFiles(collection.root, {
+    symlinks: collection.symlinks,
+    under,
+    limit,
+    match: (relPath) => glob.match(relPath) && isIncluded(collection, relPath),
+    enter: (relDir) => mayContainIncluded(collection, relDir),
+  });
+}
+
 export async function indexCollection(
-  collection: CollectionConfig
+  collection: CollectionConfig,
+  stats?: IndexRunStats,
+  maxFiles?: number
 ): Promise<IndexedDocument[]> {
-  const { root, name, glob_pattern } = collection;
-  const pattern = glob_pattern || "**/*.md";
-
-  const glob = new Bun.Glob(pattern);
-  const files: string[] = [];
-
-  for await (const entry of glob.scan({ cwd: root, absolute: true })) {
-    files.push(entry);
-  }
+  const { root, name } = collection;
+  const files = await listCollectionFiles(collection, undefined, maxFiles);
+  checkFileQuota(name, files, maxFiles);
+  if (stats) stats.files += files.length;
 
   console.log(`[${name}] Found ${files.length} markdown files in ${root}`);
 
@@ -680,6 +727,7 @@ export async function indexCollection(
       batch.map((f) =>
         indexFile(f, root, name).catch((err) => {
           console.warn(`Failed to index ${f}: ${err.message}`);
+          stats?.failed.push({ file: f, error: err.message });
           return null;
         })
       )
@@ -699,29 +747,44 @@ export async function indexCollection(
  * Index all collections defined in config.
  * Supports Pagefind-style multisite: multiple roots, each a named collection.
  * Also indexes code collections if configured.
+ *
+ * With `maxDocuments`, each collection's listing stops once the files
+ * found so far pass it, and DocumentQuotaError is thrown before those
+ * files are read (tenant quotas).
  */
 export async function indexAllCollections(
-  config: IndexConfig
+  config: IndexConfig,
+  stats?: IndexRunStats,
+  options: { maxDocuments?: number } = {}
 ): Promise<IndexedDocument[]> {
   const allDocs: IndexedDocument[] = [];
+  const left = () => (options.maxDocuments === undefined ? undefined : options.maxDocuments - allDocs.length);
 
   // Index markdown collections
   for (const collection of config.collections) {
-    const docs = await indexCollection(collection);
+    const docs = await indexCollection(collection, stats, left());
     allDocs.push(...docs);
   }
 
   // Index code collections (AST-based)
   if (config.code_collections && config.code_collections.length > 0) {
     for (const collection of config.code_collections) {
-      const codeDocs = await indexCodeCollection(collection);
+      const codeDocs = await indexCodeCollection(collection, stats, left());
       allDocs.push(...codeDocs);
     }
   }
 
+  // Dependency sources, parsed like code but kept out of the code tools
+  for (const collection of config.dependency_collections ?? []) {
+    allDocs.push(...(await indexCodeCollection(collection, stats, left())));
+  }
+
   const mdCount = config.collections.length;
   const codeCount = config.code_collections?.length || 0;
-  console.log(`Total: ${allDocs.length} documents across ${mdCount} doc + ${codeCount} code collection(s)`);
+  const depCount = config.dependency_collections?.length || 0;
+  console.log(
+    `Total: ${allDocs.length} documents across ${mdCount} doc + ${codeCount} code collection(s)${depCount ? ` + ${depCount} dependency collection(s)` : ""}`
+  );
   return allDocs;
 }
 
@@ -0,0 +1,157 @@
+/**
+ * Language detection for files without a code extension
+ *
+ * Scripts (`bin/deploy`), build files (`Makefile`, `BUILD`) and other
+ * extensionless files say what they are elsewhere. The language is
+ * taken from, in order:
+ *
+ *   1. the file name: Makefile, GNUmakefile, BUILD, WORKSPACE, Rakefile, ...
+ *   2. a shebang: #!/usr/bin/env python3, #!/bin/bash, #!/usr/bin/env -S deno run
+ *   3. a modeline in the first or last five lines: vim: ft=ruby, -*- mode: python -*-
+ *   4. content: <?php, make rules with tab-indented recipes, Python
+ *      imports next to def/class
+ *
+ * The result is a stand-in extension (".py", ".sh", ".mk", ...) that
+ * picks the parser and the language facet, as if the file had it.
+ * Only extensionless files and the names above are ever sniffed, so
+ * LICENSE.txt and friends stay out; an extensionless file with no
+ * signal (LICENSE, CODEOWNERS) is not a code file.
+ */
+
+import { open } from "node:fs/promises";
+import { basename, extname } from "node:path";
+
+/** Bytes read from the start of a file to detect its language */
+export const SNIFF_BYTES = 8192;
+
+/** Bytes read from the end, for trailing modelines */
+const TAIL_BYTES = 1024;
+
+const MODELINE_LINES = 5;
+
+/** Build and task files known by name */
+const FILE_NAMES: Record<string, string> = {
+  Makefile: ".mk", makefile: ".mk", GNUmakefile: ".mk",
+  BUILD: ".bzl", "BUILD.bazel": ".bzl", WORKSPACE: ".bzl", "WORKSPACE.bazel": ".bzl", "MODULE.bazel": ".bzl",
+  BUCK: ".bzl", Tiltfile: ".bzl",
+  Rakefile: ".rb", Gemfile: ".rb", Guardfile: ".rb", Podfile: ".rb", Vagrantfile: ".rb", Capfile: ".rb", Brewfile: ".rb",
+};
+
+/** Interpreter and editor mode names, version suffixes stripped */
+const LANGUAGE_NAMES: Record<string, string> = {
+  python: ".py",
+  node: ".js", nodejs: ".js", javascript: ".js", js: ".js",
+  deno: ".ts", bun: ".ts", "ts-node": ".ts", tsx: ".ts", typescript: ".ts", ts: ".ts",
+  sh: ".sh", bash: ".sh", zsh: ".sh", dash: ".sh", ksh: ".sh", shell: ".sh", "shell-script": ".sh",
+  ruby: ".rb",
+  php: ".php",
+  lua: ".lua", luajit: ".lua",
+  rscript: ".r", r: ".r",
+  make: ".mk", makefile: ".mk", "makefile-gmake": ".mk",
+  starlark: ".bzl", bzl: ".bzl",
+  go: ".go",
+  rust: ".rs",
+  java: ".java", kotlin: ".kt", scala: ".scala",
+  c: ".c", cpp: ".cpp", "c++": ".cpp",
+  cs: ".cs", csharp: ".cs",
+  swift: ".swift",
+};
+
+/** Whether `relPath` may be a code file whose language only its name or content tells. */
+export function isSniffable(relPath: string): boolean {
+  const name = basename(relPath);
+  if (name in FILE_NAMES) return true;
+  return !name.startsWith(".") && extname(name) === "";
+}
+
+/**
+ * The stand-in extension for `relPath` with contents `source`, or null
+ * when nothing names a language.
+ */
+export function detectExtension(relPath: string, source: string): string | null {
+  const named = FILE_NAMES[basename(relPath)];
+  if (named) return named;
+  if (source.slice(0, SNIFF_BYTES).includes("\0")) return null;
+
+  const lines = source.split("\n");
+  if (lines[0].startsWith("#!")) {
+    const ext = fromShebang(lines[0]);
+    if (ext) return ext;
+  }
+
+  const edges = lines.length > 2 * MODELINE_LINES
+    ? [...lines.slice(0, MODELINE_LINES), ...lines.slice(-MODELINE_LINES)]
+    : lines;
+  for (const line of edges) {
+    const ext = fromModeline(line);
+    if (ext) return ext;
+  }
+
+  return fromContent(source.slice(0, SNIFF_BYTES));
+}
+
+/**
+ * Detect the language of the file at `path` from its name, or from its
+ * first SNIFF_BYTES and last kilobyte. Null when unreadable or unknown.
+ */
+export async function sniffExtension(path: string): Promise<string | null> {
+  const named = FILE_NAMES[basename(path)];
+  if (named) return named;
+  let handle;
+  try {
+    handle = await open(path, "r");
+    const { size } = await handle.stat();
+    const head = Buffer.alloc(Math.min(size, SNIFF_BYTES));
+    await handle.read(head, 0, head.length, 0);
+    let text = head.toString("utf8");
+    if (size > SNIFF_BYTES) {
+      const tail = Buffer.alloc(Math.min(size - SNIFF_BYTES, TAIL_BYTES));
+      await handle.read(tail, 0, tail.length, size - tail.length);
+      text += "\n" + tail.toString("utf8");
+    }
+    return detectExtension(path, text);
+  } catch {
+    return null;
+  } finally {
+    await handle?.close();
+  }
+}
+
+// ── Signals ──────────────────────────────────────────────────────────
+
+function languageName(name: string): string | null {
+  const key = name.toLowerCase().replace(/[\d.]+$/, "");
+  return LANGUAGE_NAMES[key] ?? null;
+}
+
+/** #!/usr/bin/python3, #!/usr/bin/env node, #!/usr/bin/env -S deno run -A */
+function fromShebang(line: string): string | null {
+  const words = line.slice(2).trim().split(/\s+/);
+  let interpreter = basename(words[0] ?? "");
+  if (interpreter === "env") {
+    interpreter = words.slice(1).find((w) => !w.startsWith("-") && !w.includes("=")) ?? "";
+  }
+  return interpreter ? languageName(basename(interpreter)) : null;
+}
+
+/** vim: set ft=python:, vi: filetype=sh, -*- mode: ruby -*-, -*- python -*- */
+function fromModeline(line: string): string | null {
+  const vim = line.match(/(?:^|\s)(?:vi|vim|ex):.*?\b(?:ft|filetype|syntax)=([\w+-]+)/);
+  if (vim) return languageName(vim[1]);
+  const emacs = line.match(/-\*-(.*?)-\*-/);
+  if (emacs) {
+    const mode = emacs[1].match(/(?:^|;)\s*mode:\s*([\w+-]+)/i) ?? emacs[1].match(/^\s*([\w+-]+)\s*$/);
+    if (mode) return languageName(mode[1]);
+  }
+  return null;
+}
+
+function fromContent(head: string): string | null {
+  if (head.startsWith("<?php")) return ".php";
+  // A rule followed by a tab-indented recipe line
+  if (/^[\w./%$()-]+(?:[ \t]+[\w./%$()-]+)*[ \t]*::?(?!=).*\n\t\S/m.test(head)) return ".mk";
+  if (/^(?:from\s+[\w.]+\s+import\s|import\s+[\w.]+\s*$)/m.test(head) && /^(?:def|class)\s+\w+.*:\s*$/m.test(head)) {
+    return ".py";
+  }
+  return null;
+}
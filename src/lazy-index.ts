@@ -0,0 +1,257 @@
+/**
+ * Lazy on-demand indexing for very large trees
+ *
+ * A full index of a monorepo parses every file up front, so a team that
+ * only works in `services/payments` still pays for all of `third_party/`.
+ * In lazy mode only a skeleton is built at startup: the file list of
+ * every collection, grouped into regions by their leading directories.
+ * Files are parsed region by region, the first time something touches
+ * that region:
+ *
+ *   - a query term matches a directory name in the region's path
+ *     ("payments refund" expands `services/payments`)
+ *   - get_tree / get_node_content / navigate_tree asks for a doc_id
+ *     that lives in the region
+ *
+ * Regions listed in `eager` (and files sitting directly under a
+ * collection root) are indexed at startup, so the hot path never waits.
+ * Everything else shows up in list_documents as a pending region until
+ * it is expanded.
+ */
+
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import type { DocumentStore } from "./store";
+import { indexFile, listCollectionFiles, markdownDocId } from "./indexer";
+import { codeDocId, indexCodeFile, listCodeFiles } from "./code-indexer";
+import { relativePath } from "./paths";
+
+/** Default number of leading directories that define a region. */
+export const DEFAULT_LAZY_DEPTH = 2;
+
+export interface LazyIndexOptions {
+  /** Path prefixes (relative to a collection root) indexed at startup */
+  eager?: string[];
+  /** Leading directories per region, e.g. 2 → `services/payments` */
+  depth?: number;
+  log?: (msg: string) => void;
+}
+
+/** A group of not-yet-parsed files sharing a leading directory path */
+export interface PendingRegion {
+  collection: string;
+  /** Region path relative to the collection root, e.g. `third_party/grpc` */
+  path: string;
+  file_count: number;
+}
+
+interface Region {
+  key: string;
+  collection: CollectionConfig;
+  kind: "markdown" | "code";
+  path: string;
+  /** Lowercased directory-name tokens used to match query terms */
+  terms: Set<string>;
+  files: string[];
+  state: "pending" | "indexing" | "indexed";
+  inflight?: Promise<void>;
+}
+
+export class LazyIndex {
+  private regions = new Map<string, Region>();
+  /** doc_id → region key, for documents that have not been parsed yet */
+  private docRegion = new Map<string, string>();
+  private depth: number;
+  private eager: string[];
+  private log: (msg: string) => void;
+
+  constructor(
+    private store: DocumentStore,
+    private config: IndexConfig,
+    options: LazyIndexOptions = {}
+  ) {
+    this.depth = Math.max(1, options.depth ?? DEFAULT_LAZY_DEPTH);
+    this.eager = (options.eager ?? []).map(normalizePrefix).filter(Boolean);
+    this.log = options.log ?? ((msg: string) => console.error(msg));
+  }
+
+  /**
+   * Build the skeleton and index the eager regions. The store is loaded
+   * with whatever the eager pass produced; everything else stays pending.
+   */
+  async init(): Promise<void> {
+    const sources: { collection: CollectionConfig; kind: "markdown" | "code" }[] = [
+      ...this.config.collections.map((collection) => ({ collection, kind: "markdown" as const })),
+      ...(this.config.code_collections ?? []).map((collection) => ({ collection, kind: "code" as const })),
+    ];
+
+    for (const { collection, kind } of sources) {
+      const files = kind === "markdown"
+        ? await listCollectionFiles(collection)
+        : await listCodeFiles(collection);
+
+      for (const file of files) {
+        const relPath = relativePath(collection.root, file);
+        const regionPath = relPath.split("/").slice(0, -1).slice(0, this.depth).join("/");
+        const key = `${kind}:${collection.name}:${regionPath}`;
+
+        let region = this.regions.get(key);
+        if (!region) {
+          region = {
+            key,
+            collection,
+            kind,
+            path: regionPath,
+            terms: regionTerms(regionPath),
+            files: [],
+            state: "pending",
+          };
+          this.regions.set(key, region);
+        }
+        region.files.push(file);
+
+        const docId = kind === "markdown"
+          ? markdownDocId(collection.name, relPath)
+          : codeDocId(collection.name, relPath);
+        this.docRegion.set(docId, key);
+      }
+    }
+
+    const eagerRegions = [...this.regions.values()].filter((r) => this.isEager(r.path));
+    const docs: IndexedDocument[] = [];
+    for (const region of eagerRegions) {
+      docs.push(...(await this.parseRegion(region)));
+      region.state = "indexed";
+    }
+    this.store.load(docs);
+    this.forgetIndexed(eagerRegions);
+
+    const pending = this.pendingRegions();
+    this.log(
+      `Lazy index: ${docs.length} documents indexed eagerly, ` +
+        `${pending.length} regions (${pending.reduce((s, r) => s + r.file_count, 0)} files) deferred`
+    );
+  }
+
+  /**
+   * Make sure the region holding `doc_id` is indexed. Returns true if
+   * the document is (now) in the store.
+   */
+  async ensureDocument(doc_id: string): Promise<boolean> {
+    const key = this.docRegion.get(doc_id);
+    if (key) {
+      await this.expandRegion(this.regions.get(key)!);
+    }
+    return this.store.getDocMeta(doc_id) !== null;
+  }
+
+  /**
+   * Expand every pending region whose directory names match a term in
+   * `query`. Returns the paths of the regions that were expanded.
+   */
+  async expandForQuery(query: string): Promise<string[]> {
+    const terms = query
+      .normalize("NFC")
+      .toLowerCase()
+      .split(/[^\p{L}\p{N}\p{M}_-]+/u)
+      .filter((t) => t.length >= 3);
+    if (terms.length === 0) return [];
+
+    const matched = [...this.regions.values()].filter(
+      (r) => r.state !== "indexed" && terms.some((t) => r.terms.has(t))
+    );
+    await Promise.all(matched.map((r) => this.expandRegion(r)));
+    return matched.map((r) => `${r.collection.name}:${r.path}`);
+  }
+
+  /** Regions whose files have not been parsed yet. */
+  pendingRegions(): PendingRegion[] {
+    return [...this.regions.values()]
+      .filter((r) => r.state !== "indexed")
+      .map((r) => ({ collection: r.collection.name, path: r.path, file_count: r.files.length }))
+      .sort((a, b) => b.file_count - a.file_count);
+  }
+
+  // ── Internals ─────────────────────────────────────────────────────
+
+  private isEager(regionPath: string): boolean {
+    // Files directly under a collection root are always part of the skeleton
+    if (regionPath === "") return true;
+    return this.eager.some(
+      (prefix) =>
+        regionPath === prefix ||
+        regionPath.startsWith(`${prefix}/`) ||
+        prefix.startsWith(`${regionPath}/`)
+    );
+  }
+
+  private expandRegion(region: Region): Promise<void> {
+    if (region.state === "indexed") return Promise.resolve();
+    if (region.inflight) return region.inflight;
+
+    region.state = "indexing";
+    region.inflight = (async () => {
+      const start = Date.now();
+      const docs = await this.parseRegion(region);
+      this.store.addDocuments(docs);
+      region.state = "indexed";
+      region.inflight = undefined;
+      this.forgetIndexed([region]);
+      this.log(
+        `Lazy index: expanded ${region.collection.name}:${region.path || "."} ` +
+          `(${docs.length} documents in ${Date.now() - start}ms)`
+      );
+    })();
+    return region.inflight;
+  }
+
+  private async parseRegion(region: Region): Promise<IndexedDocument[]> {
+    const { root, name } = region.collection;
+    const BATCH_SIZE = 50;
+    const results: IndexedDocument[] = [];
+
+    for (let i = 0; i < region.files.length; i += BATCH_SIZE) {
+      const batch = region.files.slice(i, i + BATCH_SIZE);
+      const indexed = await Promise.all(
+        batch.map((f) =>
+          (region.kind === "markdown" ? indexFile(f, root, name) : indexCodeFile(f, root, name)).catch(
+            (err) => {
+              this.log(`Failed to index ${f}: ${err.message}`);
+              return null;
+            }
+          )
+        )
+      );
+      results.push(...(indexed.filter(Boolean) as IndexedDocument[]));
+    }
+    return results;
+  }
+
+  private forgetIndexed(regions: Region[]): void {
+    const keys = new Set(regions.map((r) => r.key));
+    for (const [docId, key] of this.docRegion) {
+      if (keys.has(key)) this.docRegion.delete(docId);
+    }
+  }
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────
+
+function normalizePrefix(prefix: string): string {
+  return prefix.trim().replace(/\\/g, "/").replace(/^\.?\/+/, "").replace(/\/+$/, "");
+}
+
+/**
+ * Directory names in a region path, plus their `-`/`_`-separated parts,
+ * so `third_party/grpc-java` matches "third_party", "party", and "grpc".
+ */
+function regionTerms(regionPath: string): Set<string> {
+  const terms = new Set<string>();
+  for (const segment of regionPath.normalize("NFC").toLowerCase().split("/")) {
+    if (!segment) continue;
+    terms.add(segment);
+    for (const part of segment.split(/[-_.]+/)) {
+      if (part.length >= 3) terms.add(part);
+    }
+  }
+  return terms;
+}
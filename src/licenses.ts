@@ -0,0 +1,301 @@
+/**
+ * License and provenance of indexed files — the `provenance` of results
+ *
+ * An agent that copies a snippet into generated code needs to know
+ * whether it came from MIT code, a GPL'd vendor tree, or a dependency,
+ * before the copy crosses a copyleft boundary. Every search hit, section,
+ * and file excerpt carries a provenance, decided per file:
+ *
+ *   1. A license header in the file's first HEADER_LINES lines: an
+ *      `SPDX-License-Identifier:` line, else license text in comments
+ *      recognized by LICENSE_RULES ("Licensed under the Apache License,
+ *      Version 2.0").
+ *   2. Else the nearest license file (LICENSE, LICENCE, COPYING,
+ *      UNLICENSE, with any extension or -suffix), from the file's
+ *      directory up to the collection root and on up to the repository
+ *      top, where CODEOWNERS lookups stop too. Several files in one
+ *      directory combine: LICENSE-MIT and LICENSE-APACHE are dual
+ *      licensing ("MIT OR Apache-2.0"), COPYING with COPYING.LESSER is
+ *      the LGPL.
+ *
+ * Recognized texts get an SPDX identifier; license text that no rule
+ * knows is "unknown". Texts name a license family and version, not
+ * whether "or later" applies, so the GPL family is reported as GPL-2.0
+ * and GPL-3.0. Copyleft is classified from the identifier: weak (LGPL,
+ * MPL, EPL, CDDL), strong (GPL, EUPL, OSL), or network (AGPL, SSPL). In
+ * an expression, OR takes the weakest alternative and AND the strongest.
+ *
+ * The origin says whose code it is: a dependency collection
+ * (INDEX_DEPENDENCIES, see go-deps.ts), a vendor/ tree, a third_party/
+ * tree, or the project itself.
+ *
+ * Headers are cached per file by content hash, license files by
+ * modification time, and directories by modification time, so a
+ * license file added or edited is picked up without re-indexing.
+ */
+
+import { readdir, readFile, stat } from "node:fs/promises";
+import { dirname, join, resolve } from "node:path";
+import type { DocumentMeta, IndexConfig } from "./types";
+import { readSourceText } from "./encoding";
+import { DEPENDENCY_PREFIX } from "./go-deps";
+import { isVendorPath } from "./vendor";
+import { relativePath } from "./paths";
+
+/** Lines at the top of a file searched for a license header */
+export const HEADER_LINES = 40;
+
+/** Names of license files; any extension or -suffix is allowed */
+const LICENSE_FILE = /^(?:LICEN[CS]E|COPYING|UNLICENSE)(?:[.-][\w.-]*)?$/i;
+
+export type Copyleft = "weak" | "strong" | "network";
+export type Origin = "project" | "dependency" | "vendored" | "third_party";
+
+export interface Provenance {
+  /** SPDX identifier or expression; "unknown" for license text no rule recognizes; absent when none was found */
+  license?: string;
+  /** Where the license was found */
+  source?: "header" | "file";
+  /** With source "file": the license files, relative to the collection root */
+  license_files?: string[];
+  copyleft?: Copyleft;
+  origin: Origin;
+  /** With origin "dependency": the module@version of the collection */
+  module?: string;
+}
+
+/**
+ * License texts by their distinctive phrases, most specific first. The
+ * version is read from the text for families that have several.
+ */
+const LICENSE_RULES: Array<{ pattern: RegExp; id: string | ((text: string) => string) }> = [
+  { pattern: /GNU\s+AFFERO\s+GENERAL\s+PUBLIC\s+LICENSE/i, id: "AGPL-3.0" },
+  { pattern: /GNU\s+LESSER\s+GENERAL\s+PUBLIC\s+LICENSE/i, id: (text) => (/version\s+2\.1/i.test(text) ? "LGPL-2.1" : "LGPL-3.0") },
+  { pattern: /GNU\s+LIBRARY\s+GENERAL\s+PUBLIC\s+LICENSE/i, id: "LGPL-2.0" },
+  { pattern: /GNU\s+GENERAL\s+PUBLIC\s+LICENSE/i, id: (text) => (/version\s+2\b/i.test(text) && !/version\s+3\b/i.test(text) ? "GPL-2.0" : "GPL-3.0") },
+  { pattern: /Server\s+Side\s+Public\s+License/i, id: "SSPL-1.0" },
+  { pattern: /European\s+Union\s+Public\s+Licen[cs]e/i, id: (text) => (/v\.?\s*1\.1\b/i.test(text) ? "EUPL-1.1" : "EUPL-1.2") },
+  { pattern: /Apache\s+License,?\s+Version\s+2\.0/i, id: "Apache-2.0" },
+  { pattern: /Mozilla\s+Public\s+License,?\s+(?:v\.\s*|version\s+)2\.0/i, id: "MPL-2.0" },
+  { pattern: /Eclipse\s+Public\s+License\s+-?\s*v(?:ersion)?\s*2\.0/i, id: "EPL-2.0" },
+  { pattern: /Eclipse\s+Public\s+License/i, id: "EPL-1.0" },
+  { pattern: /COMMON\s+DEVELOPMENT\s+AND\s+DISTRIBUTION\s+LICENSE/i, id: "CDDL-1.0" },
+  { pattern: /Boost\s+Software\s+License\s+-?\s*Version\s+1\.0/i, id: "BSL-1.0" },
+  { pattern: /This\s+is\s+free\s+and\s+unencumbered\s+software\s+released\s+into\s+the\s+public\s+domain/i, id: "Unlicense" },
+  { pattern: /CC0\s+1\.0\s+Universal/i, id: "CC0-1.0" },
+  {
+    pattern: /Permission\s+to\s+use,\s+copy,\s+modify,\s+and(?:\/or)?\s+distribute\s+this\s+software\s+for\s+any\s+purpose/i,
+    id: "ISC",
+  },
+  { pattern: /Permission\s+is\s+hereby\s+granted,\s+free\s+of\s+charge/i, id: "MIT" },
+  {
+    pattern: /Redistribution\s+and\s+use\s+in\s+source\s+and\s+binary\s+forms/i,
+    id: (text) => (/Neither\s+the\s+name|names\s+of\s+its\s+contributors/i.test(text) ? "BSD-3-Clause" : "BSD-2-Clause"),
+  },
+  // Headers that only name the license
+  { pattern: /\b(?:under|the)\s+(?:the\s+)?MIT\s+Licen[cs]e\b/i, id: "MIT" },
+];
+
+const COPYLEFT: Array<[RegExp, Copyleft]> = [
+  [/^(?:AGPL|SSPL)-/i, "network"],
+  [/^(?:GPL|EUPL|OSL)-/i, "strong"],
+  [/^(?:LGPL|MPL|EPL|CDDL|CPL)-/i, "weak"],
+];
+
+const STRENGTH: Record<Copyleft | "none", number> = { none: 0, weak: 1, strong: 2, network: 3 };
+
+/** The SPDX identifier of a license text, or null when no rule knows it. */
+export function identifyLicense(text: string): string | null {
+  for (const rule of LICENSE_RULES) {
+    if (rule.pattern.test(text)) return typeof rule.id === "string" ? rule.id : rule.id(text);
+  }
+  return null;
+}
+
+/** The SPDX-License-Identifier of a header, without comment closers, or null. */
+export function spdxIdentifier(text: string): string | null {
+  const m = text.match(/SPDX-License-Identifier:\s*([^\n]*)/);
+  const id = m?.[1].replace(/\s*(?:\*\/|-->|#\}|%>).*$/, "").trim();
+  return id || null;
+}
+
+/**
+ * A license header in the first HEADER_LINES lines of `source`, or null.
+ * License text counts only in comment lines, so prose that discusses a
+ * license is not taken for one.
+ */
+export function headerLicense(source: string): string | null {
+  const head = source.split("\n", HEADER_LINES);
+  const comments = head.filter((line) => /^\s*(?:\/\/|\/\*|\*|#|--|;|%|<!--|'|"""|REM\b)/.test(line));
+  return spdxIdentifier(head.join("\n")) ?? identifyLicense(comments.join("\n"));
+}
+
+/**
+ * The copyleft of an SPDX expression, or null for a permissive one. OR
+ * takes the weakest alternative, AND the strongest term.
+ */
+export function copyleftOf(expression: string): Copyleft | null {
+  const strength = (id: string): Copyleft | "none" => COPYLEFT.find(([pattern]) => pattern.test(id))?.[1] ?? "none";
+  const alternatives = expression.replace(/[()]/g, " ").split(/\s+OR\s+/i).map((alternative) =>
+    alternative
+      .split(/\s+AND\s+/i)
+      .map((term) => strength(term.split(/\s+WITH\s+/i)[0].trim()))
+      .reduce((a, b) => (STRENGTH[b] > STRENGTH[a] ? b : a), "none" as Copyleft | "none")
+  );
+  const weakest = alternatives.reduce((a, b) => (STRENGTH[b] < STRENGTH[a] ? b : a));
+  return weakest === "none" ? null : weakest;
+}
+
+/** The license of a directory from its license files and the identifier of each. */
+export function combineLicenses(files: Array<{ name: string; license: string }>): string {
+  let ids = [...new Set(files.map((f) => f.license))];
+  // COPYING holds the GPL text an LGPL'd project builds on
+  if (ids.some((id) => id.startsWith("LGPL-"))) ids = ids.filter((id) => !id.startsWith("GPL-"));
+  if (ids.length > 1) ids = ids.filter((id) => id !== "unknown");
+  const dual = files.length > 1 && files.every((f) => /^LICEN[CS]E-/i.test(f.name));
+  return ids.join(dual ? " OR " : " AND ");
+}
+
+/** Whose code a file in `collection` at `filePath` is. */
+export function originOf(collection: string, filePath: string): Pick<Provenance, "origin" | "module"> {
+  if (collection.startsWith(DEPENDENCY_PREFIX)) return { origin: "dependency", module: collection.slice(DEPENDENCY_PREFIX.length) };
+  if (isVendorPath(filePath)) return { origin: "vendored" };
+  if (/(?:^|\/)third[_-]?party\//i.test(filePath)) return { origin: "third_party" };
+  return { origin: "project" };
+}
+
+interface DirectoryLicense {
+  mtime: number;
+  /** License files in the directory, sorted */
+  names: string[];
+}
+
+/** License headers and files for the documents of a config's collections. */
+export class LicenseIndex {
+  private headers = new Map<string, { hash: string; license: string | null }>();
+  private dirs = new Map<string, DirectoryLicense>();
+  private texts = new Map<string, { mtime: number; license: string }>();
+  private tops = new Map<string, string>();
+
+  constructor(private readonly config: IndexConfig) {}
+
+  /** Provenance of each document, by doc_id. Documents of unknown collections are left out. */
+  async provenance(docs: DocumentMeta[]): Promise<Map<string, Provenance>> {
+    const roots = new Map(
+      [...this.config.collections, ...(this.config.code_collections ?? []), ...(this.config.dependency_collections ?? [])].map(
+        (c) => [c.name, resolve(c.root)]
+      )
+    );
+    // Directories already walked in this call: dir → its governing files
+    const walked = new Map<string, Promise<{ dir: string; files: Array<{ name: string; license: string }> } | null>>();
+    const result = new Map<string, Provenance>();
+    for (const doc of docs) {
+      if (result.has(doc.doc_id)) continue;
+      const root = roots.get(doc.collection);
+      if (!root) continue;
+      const origin = originOf(doc.collection, doc.file_path);
+      const header = await this.header(root, doc);
+      if (header) {
+        result.set(doc.doc_id, { license: header, source: "header", ...copyleftField(header), ...origin });
+        continue;
+      }
+      const dir = dirname(join(root, doc.file_path));
+      if (!walked.has(dir)) walked.set(dir, this.nearest(dir, root));
+      const found = await walked.get(dir)!;
+      if (!found) {
+        result.set(doc.doc_id, origin);
+        continue;
+      }
+      const license = combineLicenses(found.files);
+      result.set(doc.doc_id, {
+        license,
+        source: "file",
+        license_files: found.files.map((f) => relativePath(root, join(found.dir, f.name))),
+        ...copyleftField(license),
+        ...origin,
+      });
+    }
+    return result;
+  }
+
+  /** Drop the cached headers, directory listings, and license texts; returns how many entries there were. */
+  clear(): number {
+    const entries = this.headers.size + this.dirs.size + this.texts.size + this.tops.size;
+    for (const cache of [this.headers, this.dirs, this.texts, this.tops]) cache.clear();
+    return entries;
+  }
+
+  /** The license header of a document's file, cached by content hash. */
+  private async header(root: string, doc: DocumentMeta): Promise<string | null> {
+    const cached = this.headers.get(doc.doc_id);
+    if (cached?.hash === doc.content_hash) return cached.license;
+    const source = await readSourceText(join(root, doc.file_path)).catch(() => null);
+    if (source === null) return null;
+    const license = headerLicense(source);
+    this.headers.set(doc.doc_id, { hash: doc.content_hash, license });
+    return license;
+  }
+
+  /**
+   * The license files nearest `dir`: in it, or the first directory above
+   * it that has any, up to the repository top (or `root` outside git).
+   */
+  private async nearest(dir: string, root: string) {
+    const top = await this.top(root);
+    for (let at = dir; ; at = dirname(at)) {
+      const names = await this.licenseFiles(at);
+      if (names.length > 0) {
+        const files = [];
+        for (const name of names) files.push({ name, license: await this.licenseText(join(at, name)) });
+        return { dir: at, files };
+      }
+      if (at === top || dirname(at) === at) return null;
+    }
+  }
+
+  /** The repository top holding `root`, or `root` itself outside git. */
+  private async top(root: string): Promise<string> {
+    let top = this.tops.get(root);
+    if (top === undefined) {
+      top = root;
+      for (let dir = root; ; dir = dirname(dir)) {
+        if (await stat(join(dir, ".git")).then(() => true, () => false)) {
+          top = dir;
+          break;
+        }
+        if (dirname(dir) === dir) break;
+      }
+      this.tops.set(root, top);
+    }
+    return top;
+  }
+
+  /** License file names in `dir`, re-listed when the directory changes. */
+  private async licenseFiles(dir: string): Promise<string[]> {
+    const mtime = await stat(dir).then((s) => s.mtimeMs, () => -1);
+    const cached = this.dirs.get(dir);
+    if (cached?.mtime === mtime) return cached.names;
+    const names = (await readdir(dir, { withFileTypes: true }).catch(() => []))
+      .filter((e) => e.isFile() && LICENSE_FILE.test(e.name))
+      .map((e) => e.name)
+      .sort();
+    this.dirs.set(dir, { mtime, names });
+    return names;
+  }
+
+  /** The identifier of a license file, re-read when it changes. */
+  private async licenseText(path: string): Promise<string> {
+    const mtime = await stat(path).then((s) => s.mtimeMs, () => -1);
+    const cached = this.texts.get(path);
+    if (cached?.mtime === mtime) return cached.license;
+    const text = await readFile(path, "utf-8").catch(() => "");
+    const license = spdxIdentifier(text.slice(0, 2000)) ?? identifyLicense(text) ?? "unknown";
+    this.texts.set(path, { mtime, license });
+    return license;
+  }
+}
+
+function copyleftField(license: string): { copyleft?: Copyleft } {
+  const copyleft = copyleftOf(license);
+  return copyleft ? { copyleft } : {};
+}
@@ -0,0 +1,406 @@
+/**
+ * Log messages and metric names back to code — the find_log_source tool
+ *
+ * An on-call agent holds a line from production ("dial upstream
+ * 10.0.0.7:443: connection refused") or a metric from a dashboard
+ * (http_request_duration_seconds_bucket) and wants the code that emits
+ * it. This collects the string literals passed to known emitting calls:
+ *
+ *   log      Go log / slog / zap / logrus / klog / zerolog .Msg; Python
+ *            logging and logger; console and pino / winston / bunyan
+ *            loggers; SLF4J / Log4j; Rust log and tracing macros; Ruby
+ *            Logger; .NET ILogger
+ *   error    fmt.Errorf, errors.New / Wrap; raise / throw new ...Error;
+ *            anyhow! / bail! / panic! — their text ends up in log lines
+ *   metric   Prometheus (client_golang with Namespace and Subsystem
+ *            joined, prometheus_client, prom-client), OpenTelemetry
+ *            meters, statsd-style clients, Micrometer
+ *
+ * A log line matches a template when the template's literal parts occur
+ * in it in order, with its placeholders (%s, %v, %.2f, {}, {name},
+ * ${expr}, #{expr}) standing for anything; the line may carry more
+ * around it, such as a timestamp or structured fields. Templates that
+ * explain more of the line rank first. A fragment of a message matches
+ * templates containing it. A metric matches by name, histogram and
+ * counter suffixes (_bucket, _sum, _count, _total) and labels ignored,
+ * dots and underscores alike.
+ */
+
+import { extname } from "node:path";
+import type { IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+
+export type EmitKind = "log" | "error" | "metric";
+
+export const EMIT_KINDS: EmitKind[] = ["log", "error", "metric"];
+
+/** One literal passed to an emitting call. */
+export interface EmitHit {
+  kind: EmitKind;
+  /** The call: log.Printf, logger.error, prometheus.NewCounterVec, ... */
+  api: string;
+  /** debug, info, warn, error, ... when the call names one */
+  level?: string;
+  /** The message template, or the metric's full name */
+  template: string;
+  line: number;
+  text: string;
+}
+
+export interface EmitSite extends EmitHit {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+}
+
+export interface LogSourceMatch {
+  site: EmitSite;
+  /** "template": the text is an instance of it; "fragment": it contains the text; "metric": by name */
+  match: "template" | "fragment" | "metric";
+  /** Characters of the text the template's own literals account for */
+  score: number;
+  /** What each placeholder stood for, in order */
+  values?: string[];
+}
+
+/** Fewest literal characters a template needs to be matched against a line */
+const MIN_LITERAL = 4;
+
+/** Shortest text searched for as a fragment of a message */
+const MIN_FRAGMENT = 8;
+
+interface Rule {
+  /** Global; the literal is captured as `lit` */
+  pattern: RegExp;
+  read(m: RegExpExecArray, ctx: Context, index: number): Omit<EmitHit, "line" | "text"> | null;
+}
+
+interface Context {
+  source: string;
+  lines: string[];
+}
+
+/** A string literal, any quote style, with an optional Python prefix, captured as `lit` */
+const LIT = String.raw`(?:[rRfFbBuU]{1,2})?(?<q>["'\x60])(?<lit>(?:\\.|(?!\k<q>)[^\\])*)\k<q>`;
+
+/** Level names as written in calls, to one spelling */
+function level(name: string | undefined): string | undefined {
+  if (!name) return undefined;
+  const n = name.toLowerCase().replace(/^log/, "").replace(/(?:f|w|ln|context|ctx)$/, "");
+  if (n === "warning") return "warn";
+  if (n === "critical" || n === "fatal" || n === "panic" || n === "dpanic") return "fatal";
+  if (n === "exception") return "error";
+  if (n === "information") return "info";
+  return ["trace", "debug", "info", "warn", "error"].includes(n) ? n : undefined;
+}
+
+const log = (api: string, lit: string, lvl?: string): Omit<EmitHit, "line" | "text"> => ({
+  kind: "log",
+  api,
+  ...(level(lvl) ? { level: level(lvl) } : {}),
+  template: lit,
+});
+
+const error = (api: string, lit: string): Omit<EmitHit, "line" | "text"> => ({ kind: "error", api, template: lit });
+
+const metric = (api: string, name: string): Omit<EmitHit, "line" | "text"> => ({ kind: "metric", api, template: name });
+
+const GO_LEVELS =
+  "Print|Printf|Println|Fatal|Fatalf|Fatalln|Panic|Panicf|Trace|Tracef|Debug|Debugf|Debugw|Info|Infof|Infow|Infoln|Warn|Warnf|Warnw|Warning|Warningf|Error|Errorf|Errorw|DPanic|DebugContext|InfoContext|WarnContext|ErrorContext|Log|Logf";
+
+/** Statsd-style clients: receivers named for stats or metrics */
+const STATSD = String.raw`\b(?<recv>\w*(?:[Ss]tatsd?|[Mm]etrics|[Ss]tats)\w*)\.(?<fn>Incr|Increment|Decr|Decrement|Gauge|Timing|Timer|Histogram|Count|Distribution|incr|increment|decr|gauge|timing|timer|histogram|count|distribution)\(\s*${LIT}`;
+
+// ── Go ───────────────────────────────────────────────────────────────
+
+/** Namespace_Subsystem_Name from a prometheus Opts literal starting at `index`. */
+function promName(ctx: Context, index: number): string | null {
+  const opts = ctx.lines.slice(index, index + 12).join("\n");
+  const field = (name: string) => new RegExp(String.raw`\b${name}:\s*"([^"]+)"`).exec(opts)?.[1];
+  const name = field("Name");
+  if (!name) return null;
+  return [field("Namespace"), field("Subsystem"), name].filter(Boolean).join("_");
+}
+
+const GO_RULES: Rule[] = [
+  {
+    // log.Printf, logger.Infof, slog.Info(ctx?), zap's sugar, klog.V(2).Infof
+    pattern: new RegExp(String.raw`\b(?<recv>\w*(?:[Ll]og|[Ll]ogger|klog|glog|zap|slog|logrus|sugar|[Ll])\w*)(?:\.V\(\d+\))?\.(?<fn>${GO_LEVELS})\(\s*(?:ctx\s*,\s*)?(?:slog\.Level\w+\s*,\s*)?${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit, m.groups!.fn),
+  },
+  {
+    // zerolog: log.Error().Err(err).Msg("x")
+    pattern: new RegExp(String.raw`\.(?<fn>Msg|Msgf)\(\s*${LIT}`, "g"),
+    read: (m, ctx, i) => {
+      const lvl = /\.(Trace|Debug|Info|Warn|Error|Fatal|Panic)\(\)/.exec(ctx.lines.slice(Math.max(0, i - 3), i + 1).join("\n"))?.[1];
+      return log(`zerolog.${m.groups!.fn}`, m.groups!.lit, lvl);
+    },
+  },
+  {
+    pattern: new RegExp(String.raw`\b(?<api>fmt\.Errorf|errors\.New|errors\.Errorf|errors\.Wrapf?|errors\.WithMessagef?|status\.Errorf?)\(\s*(?:(?:err|\w+Err\w*|codes\.\w+)\s*,\s*)?${LIT}`, "g"),
+    read: (m) => error(m.groups!.api, m.groups!.lit),
+  },
+  {
+    pattern: /\b(?<api>(?:prometheus|promauto(?:\.With\([^)]*\))?|factory)\.New(?<type>Counter|Gauge|Histogram|Summary)(?:Vec|Func)?)\(/g,
+    read: (m, ctx, i) => {
+      const name = promName(ctx, i);
+      return name ? metric(m.groups!.api, name) : null;
+    },
+  },
+  {
+    // OpenTelemetry: meter.Int64Counter("x")
+    pattern: new RegExp(String.raw`\.(?<fn>(?:Int64|Float64)(?:Observable)?(?:Counter|UpDownCounter|Histogram|Gauge))\(\s*${LIT}`, "g"),
+    read: (m) => metric(`otel.${m.groups!.fn}`, m.groups!.lit),
+  },
+  {
+    pattern: new RegExp(STATSD, "g"),
+    read: (m) => metric(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit),
+  },
+];
+
+// ── Python ───────────────────────────────────────────────────────────
+
+const PY_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\b(?<recv>logging|\w*log(?:ger)?|\w*LOG(?:GER)?|self\.\w*log(?:ger)?)\.(?<fn>debug|info|warning|warn|error|exception|critical|fatal|log)\(\s*(?:logging\.[A-Z]+\s*,\s*)?${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit, m.groups!.fn),
+  },
+  {
+    pattern: new RegExp(String.raw`\braise\s+(?<type>[\w.]+)\(\s*${LIT}`, "g"),
+    read: (m) => error(`raise ${m.groups!.type}`, m.groups!.lit),
+  },
+  {
+    pattern: new RegExp(String.raw`\b(?<type>Counter|Gauge|Histogram|Summary|Info|Enum)\(\s*${LIT}`, "g"),
+    read: (m, ctx) => {
+      if (!/\bprometheus_client\b/.test(ctx.source)) return null;
+      // prometheus_client exposes counters with _total; the matcher ignores the suffix either way
+      return metric(`prometheus_client.${m.groups!.type}`, m.groups!.lit);
+    },
+  },
+  {
+    pattern: new RegExp(STATSD, "g"),
+    read: (m) => metric(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit),
+  },
+];
+
+// ── JavaScript / TypeScript ──────────────────────────────────────────
+
+const JS_RULES: Rule[] = [
+  {
+    // console.error("x"), logger.warn({ err }, "x"), this.log.info("x")
+    pattern: new RegExp(String.raw`\b(?<recv>console|\w*[Ll]og(?:ger)?|this\.\w*[Ll]og(?:ger)?)\.(?<fn>log|trace|debug|info|warn|error|fatal)\(\s*(?:\{[^{}]*\}\s*,\s*)?${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit, m.groups!.fn === "log" ? "info" : m.groups!.fn),
+  },
+  {
+    pattern: new RegExp(String.raw`\bthrow\s+new\s+(?<type>\w*Error)\(\s*${LIT}`, "g"),
+    read: (m) => error(`throw ${m.groups!.type}`, m.groups!.lit),
+  },
+  {
+    // prom-client: new client.Counter({ name: "x", ... })
+    pattern: /\bnew\s+(?:\w+\.)?(?<type>Counter|Gauge|Histogram|Summary)\(\s*\{/g,
+    read: (m, ctx, i) => {
+      const name = /\bname:\s*["'`]([^"'`]+)["'`]/.exec(ctx.lines.slice(i, i + 8).join("\n"))?.[1];
+      return name ? metric(`prom-client.${m.groups!.type}`, name) : null;
+    },
+  },
+  {
+    pattern: new RegExp(String.raw`\.(?<fn>create(?:Observable)?(?:Counter|UpDownCounter|Histogram|Gauge))\(\s*${LIT}`, "g"),
+    read: (m) => metric(`otel.${m.groups!.fn}`, m.groups!.lit),
+  },
+  {
+    pattern: new RegExp(STATSD, "g"),
+    read: (m) => metric(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit),
+  },
+];
+
+// ── JVM, .NET, Rust, Ruby ────────────────────────────────────────────
+
+const JAVA_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\b(?<recv>\w*(?:log|LOG|logger|LOGGER|Logger))\.(?<fn>trace|debug|info|warn|error|fatal)\(\s*${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit, m.groups!.fn),
+  },
+  {
+    pattern: new RegExp(String.raw`\bthrow\s+new\s+(?<type>\w+(?:Exception|Error))\(\s*${LIT}`, "g"),
+    read: (m) => error(`throw ${m.groups!.type}`, m.groups!.lit),
+  },
+  {
+    // Micrometer: Counter.builder("x"), registry.timer("x")
+    pattern: new RegExp(String.raw`\b(?<api>(?:Counter|Timer|Gauge|DistributionSummary|LongTaskTimer)\.builder|\w*[Rr]egistry\.(?:counter|timer|gauge|summary))\(\s*${LIT}`, "g"),
+    read: (m) => metric(m.groups!.api, m.groups!.lit),
+  },
+];
+
+const CSHARP_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\b(?<recv>_?\w*[Ll]ogger)\.(?<fn>Log(?:Trace|Debug|Information|Warning|Error|Critical))\(\s*(?:\w+\s*,\s*)?${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit, m.groups!.fn),
+  },
+  {
+    pattern: new RegExp(String.raw`\bthrow\s+new\s+(?<type>\w+Exception)\(\s*${LIT}`, "g"),
+    read: (m) => error(`throw ${m.groups!.type}`, m.groups!.lit),
+  },
+];
+
+const RUST_RULES: Rule[] = [
+  {
+    // info!("x"), log::warn!(target: "t", "x"), tracing::error!(%err, "x")
+    pattern: new RegExp(String.raw`\b(?<ns>(?:log|tracing)::)?(?<fn>trace|debug|info|warn|error)!\(\s*(?:target:\s*"[^"]*"\s*,\s*)?(?:[%?]?[\w.]+(?:\s*=\s*[^,]+)?\s*,\s*)*${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.ns ?? ""}${m.groups!.fn}!`, m.groups!.lit, m.groups!.fn),
+  },
+  {
+    pattern: new RegExp(String.raw`\b(?<fn>anyhow|bail|panic|ensure|unreachable|format_err)!\(\s*(?:[^,"]+,\s*)?${LIT}`, "g"),
+    read: (m) => error(`${m.groups!.fn}!`, m.groups!.lit),
+  },
+];
+
+const RUBY_RULES: Rule[] = [
+  {
+    pattern: new RegExp(String.raw`\b(?<recv>\w*logger|Rails\.logger)\.(?<fn>debug|info|warn|error|fatal)\s*\(?\s*${LIT}`, "g"),
+    read: (m) => log(`${m.groups!.recv}.${m.groups!.fn}`, m.groups!.lit, m.groups!.fn),
+  },
+  {
+    pattern: new RegExp(String.raw`\braise\s+(?<type>[\w:]+)(?:\.new\(|,\s*|\()\s*${LIT}`, "g"),
+    read: (m) => error(`raise ${m.groups!.type}`, m.groups!.lit),
+  },
+];
+
+const RULES: Record<string, Rule[]> = {
+  ".go": GO_RULES,
+  ".py": PY_RULES,
+  ".ts": JS_RULES, ".tsx": JS_RULES, ".mts": JS_RULES, ".cts": JS_RULES,
+  ".js": JS_RULES, ".jsx": JS_RULES, ".mjs": JS_RULES, ".cjs": JS_RULES,
+  ".java": JAVA_RULES, ".kt": JAVA_RULES, ".scala": JAVA_RULES,
+  ".cs": CSHARP_RULES,
+  ".rs": RUST_RULES,
+  ".rb": RUBY_RULES,
+};
+
+/** A literal as the program sees it: common escapes decoded, a final newline dropped. */
+function unescape(lit: string): string {
+  return lit
+    .replace(/\\(["'`\\nt])/g, (_, c) => (c === "n" ? "\n" : c === "t" ? "\t" : c))
+    .replace(/\n+$/, "");
+}
+
+/** Emitted literals in a source file, in line order. */
+export function scanLogSources(source: string, filePath: string): EmitHit[] {
+  const rules = RULES[extname(filePath).toLowerCase()];
+  if (!rules) return [];
+  const lines = source.split("\n");
+  const blanked = blankLiterals(source, hashCommentsFor(filePath)).split("\n");
+  const ctx: Context = { source, lines };
+  const hits: EmitHit[] = [];
+  for (let i = 0; i < lines.length; i++) {
+    if (!blanked[i].trim()) continue;
+    // A call broken after "(" or "," has its literal on the next line
+    const joined = /[(,]\s*$/.test(lines[i]) && i + 1 < lines.length ? `${lines[i]} ${lines[i + 1].trim()}` : lines[i];
+    const seen = new Set<string>();
+    for (const rule of rules) {
+      rule.pattern.lastIndex = 0;
+      for (let m = rule.pattern.exec(joined); m; m = rule.pattern.exec(joined)) {
+        // The call must start in code, not in a comment or string
+        if (m.index >= lines[i].length || blanked[i][m.index] === " ") continue;
+        const found = rule.read(m, ctx, i);
+        if (!found || !found.template.trim()) continue;
+        const template = found.kind === "metric" ? found.template : unescape(found.template);
+        if (seen.has(`${found.kind}\0${template}`)) continue;
+        seen.add(`${found.kind}\0${template}`);
+        hits.push({ ...found, template, line: i + 1, text: lines[i].trim() });
+      }
+    }
+  }
+  return hits;
+}
+
+// ── Matching ─────────────────────────────────────────────────────────
+
+/** printf verbs, {} / {0} / {name} / {name:>8}, ${expr}, #{expr}; %% is a literal */
+const PLACEHOLDER = /%%|%[-+# 0]*(?:\d+|\*)?(?:\.(?:\d+|\*))?[a-zA-Z]|\$\{[^}]*\}|#\{[^}]*\}|\{[^{}]*\}/g;
+
+/** A template's literal parts, between its placeholders. */
+export function templateParts(template: string): string[] {
+  const parts: string[] = [];
+  let current = "";
+  let last = 0;
+  for (const m of template.matchAll(PLACEHOLDER)) {
+    current += template.slice(last, m.index);
+    last = m.index! + m[0].length;
+    if (m[0] === "%%") {
+      current += "%";
+      continue;
+    }
+    parts.push(current);
+    current = "";
+  }
+  parts.push(current + template.slice(last));
+  return parts;
+}
+
+const escapeRe = (s: string) => s.replace(/[.*+?^${}()|[\]\\]/g, "\\$&").replace(/\s+/g, "\\s+");
+
+/** Whether `text` is an instance of `template`; the values its placeholders took. */
+export function matchTemplate(template: string, text: string): { score: number; values: string[] } | null {
+  const parts = templateParts(template);
+  const score = parts.reduce((n, p) => n + p.trim().length, 0);
+  if (score < MIN_LITERAL) return null;
+  const source = parts.map(escapeRe).join("(.*?)");
+  // The last placeholder takes the rest of the line, not the least it can
+  const m = new RegExp(parts[parts.length - 1] === "" && parts.length > 1 ? `${source.slice(0, -"(.*?)".length)}(.*)` : source).exec(text);
+  return m ? { score, values: m.slice(1) } : null;
+}
+
+/** A metric name without labels, value, and the suffixes exporters add, dots as underscores. */
+export function metricKey(name: string): string {
+  return name
+    .trim()
+    .replace(/[{\s].*$/s, "")
+    .replace(/\./g, "_")
+    .replace(/_(?:bucket|sum|count|total|created|info)$/, "");
+}
+
+export class LogSourceIndex {
+  private readonly files: FileScanCache<EmitSite[]>;
+
+  constructor(config: IndexConfig) {
+    this.files = new FileScanCache(config, (source, meta) =>
+      scanLogSources(source, meta.file_path).map(
+        (hit): EmitSite => ({ ...hit, doc_id: meta.doc_id, collection: meta.collection, file_path: meta.file_path })
+      )
+    );
+  }
+
+  /** Every emitting site in the indexed code, optionally under a path prefix, in file and line order. */
+  async scan(store: DocumentStore, pathPrefix?: string): Promise<EmitSite[]> {
+    const files = await this.files.scan(store, { pathPrefix, accept: (path) => !!RULES[extname(path).toLowerCase()] });
+    return files.flat().sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+  }
+
+  /**
+   * The sites that could have emitted `text`, best first: templates it
+   * is an instance of, then messages containing it, and metrics by name.
+   */
+  async find(store: DocumentStore, text: string, options: { kind?: EmitKind; path?: string; include_tests?: IncludeTests } = {}): Promise<LogSourceMatch[]> {
+    const line = text.trim();
+    const metricName = /^[A-Za-z_:][\w:.]*(?:\{.*\})?(?:\s+\S+)*$/s.test(line) ? metricKey(line) : null;
+    const matches: LogSourceMatch[] = [];
+    for (const site of await this.scan(store, options.path)) {
+      if ((options.kind && site.kind !== options.kind) || !matchesTests(site.file_path, options.include_tests)) continue;
+      if (site.kind === "metric") {
+        if (metricName && metricKey(site.template) === metricName) matches.push({ site, match: "metric", score: site.template.length });
+        continue;
+      }
+      const full = matchTemplate(site.template, line);
+      if (full) {
+        matches.push({ site, match: "template", score: full.score, values: full.values });
+      } else if (line.length >= MIN_FRAGMENT && templateParts(site.template).some((p) => p.includes(line))) {
+        matches.push({ site, match: "fragment", score: line.length });
+      }
+    }
+    const rank = { metric: 0, template: 1, fragment: 2 };
+    return matches.sort((a, b) => rank[a.match] - rank[b.match] || b.score - a.score || a.site.file_path.localeCompare(b.site.file_path));
+  }
+}
@@ -0,0 +1,101 @@
+/**
+ * TODO / FIXME / HACK markers in code comments — the list_markers tool
+ *
+ * A marker is one of the MARKERS words (default TODO, FIXME, HACK, XXX)
+ * at the start of a comment, in any of the comment styles the indexed
+ * languages use:
+ *
+ *   // TODO(alice): drop after the v2 migration
+ *   # FIXME retry on 503
+ *    * HACK: works around golang/go#12345
+ *
+ * Markers are matched case-sensitively, so prose such as "todo list"
+ * is left alone. An owner in parentheses is kept as `tag`. Every marker
+ * is also blamed, which yields the author and the date the line was
+ * last changed: the owner when no tag names one, and the marker's age.
+ */
+
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { gitBlameLines } from "./git-history";
+import { FileScanCache } from "./scan-cache";
+
+export const DEFAULT_MARKERS = ["TODO", "FIXME", "HACK", "XXX"];
+
+/** A marker found in one line of a comment. */
+export interface MarkerHit {
+  marker: string;
+  /** Owner named in the marker, as in TODO(alice) */
+  tag?: string;
+  text: string;
+  line: number;
+}
+
+export interface CodeMarker extends MarkerHit {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+  /** Last author of the line, from git blame */
+  author?: string;
+  email?: string;
+  /** When the line was last changed, epoch ms */
+  changed_at?: number;
+}
+
+/** Comment openers across the indexed languages; `*` only leads a line. */
+const COMMENT_OPENER = String.raw`(?:\/\/+|\/\*+|#+|--|<!--|;+|^\s*\*+)`;
+
+/** Matcher for comment lines that begin with one of `markers`. */
+export function markerPattern(markers: string[]): RegExp {
+  const words = markers.map((m) => m.replace(/[.*+?^${}()|[\]\\]/g, "\\$&")).join("|");
+  return new RegExp(
+    String.raw`${COMMENT_OPENER}\s*@?(${words})\b(?:\(([^)]*)\))?\s*[:\-]?\s*(.*?)\s*(?:\*\/|-->)?\s*$`
+  );
+}
+
+/** Markers in a source file, in line order. */
+export function scanMarkers(source: string, pattern: RegExp): MarkerHit[] {
+  const hits: MarkerHit[] = [];
+  const lines = source.split("\n");
+  for (let i = 0; i < lines.length; i++) {
+    const m = lines[i].match(pattern);
+    if (!m) continue;
+    const tag = m[2]?.trim();
+    hits.push({ marker: m[1], ...(tag ? { tag } : {}), text: m[3], line: i + 1 });
+  }
+  return hits;
+}
+
+/** Markers across the code documents of a store. */
+export class MarkerIndex {
+  private readonly files: FileScanCache<CodeMarker[]>;
+
+  constructor(config: IndexConfig, readonly markers: string[] = DEFAULT_MARKERS) {
+    const pattern = markerPattern(markers);
+    // Blamed once per content hash, with the scan
+    this.files = new FileScanCache(config, (source, meta, root) => {
+      const hits = scanMarkers(source, pattern);
+      const blame = gitBlameLines(root, meta.file_path, hits.map((h) => h.line));
+      return hits.map((hit): CodeMarker => {
+        const who = blame.get(hit.line);
+        return {
+          ...hit,
+          doc_id: meta.doc_id,
+          collection: meta.collection,
+          file_path: meta.file_path,
+          ...(who ? { author: who.author, email: who.email, changed_at: who.time } : {}),
+        };
+      });
+    });
+  }
+
+  /** Every marker in the indexed code, optionally under a path prefix. */
+  async scan(store: DocumentStore, pathPrefix?: string): Promise<CodeMarker[]> {
+    return (await this.files.scan(store, { pathPrefix })).flat();
+  }
+}
+
+/** The owner a marker is attributed to: its tag, else the last author. */
+export function markerOwner(marker: CodeMarker): string | undefined {
+  return marker.tag ?? marker.author;
+}
@@ -0,0 +1,124 @@
+/**
+ * Content-addressed parse cache — PARSE_CACHE
+ *
+ * The index cache and re-validation already skip files whose content
+ * hash is unchanged, but only against the last state of the same tree.
+ * After `git checkout other-branch` every file that differs from the
+ * previous branch is parsed again, even though the same blob was
+ * parsed an hour ago, and a second worktree of the same repository
+ * parses everything from scratch.
+ *
+ * With PARSE_CACHE=<dir> the symbols a code parser extracts are stored
+ * under the SHA-256 of the parser input: the source text, the parser it
+ * went to (by extension), and PARSE_CACHE_VERSION. A file with content
+ * that was parsed before, on any branch, in any worktree or server
+ * sharing the directory, is read back instead of parsed, so a branch
+ * switch costs parse time only for the blobs that are actually new.
+ *
+ * Entries hold symbol ids relative to the document ("n3"), so a blob
+ * that moved or is checked out under another path is still a hit.
+ * Each entry is one small JSON file under <dir>/<2 hex>/, written to a
+ * temp file and renamed like the index cache, so concurrent servers can
+ * share a directory. Nothing is ever pruned; the directory can be
+ * deleted at any time. Markdown is not cached: it parses faster than
+ * its entry would read back.
+ */
+
+import { createHash } from "node:crypto";
+import { mkdirSync, readFileSync, renameSync, writeFileSync } from "node:fs";
+import { join, resolve } from "node:path";
+import type { CodeSymbol } from "./code-indexer";
+
+/** Bump whenever a parser's output for the same input changes. */
+export const PARSE_CACHE_VERSION = 1;
+
+/** Hits and parses since the cache was created. */
+export interface ParseCacheStats {
+  hits: number;
+  misses: number;
+}
+
+/** Parsed symbols on disk, keyed by the content they were parsed from. */
+export class ParseCache {
+  readonly dir: string;
+  private readonly log: (msg: string) => void;
+  private counts: ParseCacheStats = { hits: 0, misses: 0 };
+  private failed = false;
+
+  constructor(dir: string, options: { log?: (msg: string) => void } = {}) {
+    this.dir = resolve(dir);
+    this.log = options.log ?? ((msg: string) => console.error(msg));
+  }
+
+  /** Symbols of `source` parsed as `ext`, with ids under `docId`; null on a miss. */
+  get(ext: string, source: string, docId: string): CodeSymbol[] | null {
+    let symbols: CodeSymbol[];
+    try {
+      const entry = JSON.parse(readFileSync(this.path(ext, source), "utf-8"));
+      if (entry.version !== PARSE_CACHE_VERSION || !Array.isArray(entry.symbols)) throw new Error("stale entry");
+      symbols = entry.symbols;
+    } catch {
+      this.counts.misses++;
+      return null;
+    }
+    this.counts.hits++;
+    const id = (relative: string) => `${docId}:${relative}`;
+    return symbols.map((s) => ({
+      ...s,
+      id: id(s.id),
+      parent_id: s.parent_id === null ? null : id(s.parent_id),
+      children_ids: s.children_ids.map(id),
+    }));
+  }
+
+  /**
+   * Store symbols parsed from `source` with ids under `docId`. Skipped
+   * when an id is not under `docId`. After a failed write, which is
+   * logged, the cache is only read.
+   */
+  set(ext: string, source: string, docId: string, symbols: CodeSymbol[]): void {
+    if (this.failed) return;
+    const prefix = `${docId}:`;
+    const relative = (id: string) => (id.startsWith(prefix) ? id.slice(prefix.length) : null);
+    const cached: CodeSymbol[] = [];
+    for (const s of symbols) {
+      const id = relative(s.id);
+      const parent_id = s.parent_id === null ? null : relative(s.parent_id);
+      const children_ids = s.children_ids.map(relative);
+      if (id === null || (s.parent_id !== null && parent_id === null) || children_ids.includes(null)) return;
+      cached.push({ ...s, id, parent_id, children_ids: children_ids as string[] });
+    }
+    const path = this.path(ext, source);
+    const tmp = `${path}.${process.pid}.tmp`;
+    try {
+      mkdirSync(join(path, ".."), { recursive: true });
+      writeFileSync(tmp, JSON.stringify({ version: PARSE_CACHE_VERSION, ext, symbols: cached }));
+      renameSync(tmp, path);
+    } catch (err: any) {
+      this.failed = true;
+      this.log(`Warning: PARSE_CACHE ${this.dir} could not be written; reading it only from now on: ${err.message}`);
+    }
+  }
+
+  stats(): ParseCacheStats {
+    return { ...this.counts };
+  }
+
+  private path(ext: string, source: string): string {
+    const key = createHash("sha256").update(`${PARSE_CACHE_VERSION}\0${ext}\0`).update(source).digest("hex");
+    return join(this.dir, key.slice(0, 2), `${key.slice(2)}.json`);
+  }
+}
+
+// The cache is content-addressed, so one per process serves every
+// collection and every caller of the code parsers
+let active: ParseCache | null = null;
+
+/** Use `cache` for code parsing from now on; null turns it off. */
+export function setParseCache(cache: ParseCache | null): void {
+  active = cache;
+}
+
+export function activeParseCache(): ParseCache | null {
+  return active;
+}
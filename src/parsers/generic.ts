@@ -3,13 +3,15 @@
  *
  * Extracts structural symbols from source files using language-agnostic
  * regex patterns. Works for Go, Rust, Java, C#, Ruby, and other languages
- * with common declaration syntax.
+ * with common declaration syntax. Makefiles get their rules and
+ * variables.
  *
  * Less precise than language-specific parsers, but provides reasonable
  * tree navigation for any brace-delimited or indentation-based language.
  */
 
 import type { CodeSymbol } from "../code-indexer";
+import { sourceLines, type SourceLines } from "./lines";
 
 /** Language detection from file extension */
 export const GENERIC_EXTENSIONS = new Set([
@@ -17,12 +19,13 @@ export const GENERIC_EXTENSIONS = new Set([
   ".c", ".cpp", ".cc", ".h", ".hpp",
   ".cs", ".rb", ".swift", ".php",
   ".lua", ".r", ".R", ".sh", ".bash", ".zsh",
+  ".mk",
 ]);
 
 /**
  * Detect language from file extension for tuned pattern matching.
  */
-type Lang = "go" | "java" | "c" | "ruby" | "shell" | "other";
+type Lang = "go" | "java" | "c" | "ruby" | "shell" | "make" | "other";
 
 function detectLang(ext: string): Lang {
   if (ext === ".go") return "go";
@@ -30,6 +33,7 @@ function detectLang(ext: string): Lang {
   if ([".c", ".cpp", ".cc", ".h", ".hpp"].includes(ext)) return "c";
   if (ext === ".rb") return "ruby";
   if ([".sh", ".bash", ".zsh"].includes(ext)) return "shell";
+  if (ext === ".mk") return "make";
   return "other";
 }
 
@@ -37,7 +41,7 @@ function detectLang(ext: string): Lang {
  * Parse a source file using generic patterns.
  */
 export function parseGeneric(source: string, docId: string, ext: string): CodeSymbol[] {
-  const lines = source.split("\n");
+  const lines = sourceLines(source);
   const symbols: CodeSymbol[] = [];
   let counter = 0;
   const lang = detectLang(ext);
@@ -50,6 +54,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
     c: /^#\s*include\s/,
     ruby: /^(?:require\s|require_relative\s|include\s)/,
     shell: /^(?:source\s|\.(?:\s|\/))/,
+    make: /^-?s?include\s/,
     other: /^(?:import\s|#\s*include|require\s|use\s)/,
   };
 
@@ -81,7 +86,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
       name: "imports",
       kind: "import",
       signature: `${importEnd - importStart + 1} import statements`,
-      content: lines.slice(importStart, importEnd + 1).join("\n"),
+      content: lines.block(importStart, importEnd),
       line_start: importStart + 1,
       line_end: importEnd + 1,
       exported: false,
@@ -102,11 +107,22 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
     const indent = line.length - line.trimStart().length;
     if (indent > 0 && lang !== "java") continue;
 
+    // --- Make rules and variables ---
+    if (lang === "make") {
+      const make = parseMakeDeclaration(lines, i, docId, counter + 1);
+      if (make) {
+        counter++;
+        symbols.push(make);
+        i = make.line_end - 1;
+      }
+      continue;
+    }
+
     // --- Struct/class ---
     const structMatch =
-      trimmed.match(/^(?:(?:pub(?:lic)?|private|protected|internal|sealed|final|static|export|abstract)\s+)*(?:struct|class|data\s+class|object)\s+(\w+)/) ||
-      (lang === "go" && trimmed.match(/^type\s+(\w+)\s+struct\b/)) ||
-      (lang === "ruby" && trimmed.match(/^class\s+(\w+)/));
+      trimmed.match(/^(?:(?:pub(?:lic)?|private|protected|internal|sealed|final|static|export|abstract)\s+)*(?:struct|class|data\s+class|object)\s+(\p{ID_Continue}+)/u) ||
+      (lang === "go" && trimmed.match(/^type\s+(\p{ID_Continue}+)\s+struct\b/u)) ||
+      (lang === "ruby" && trimmed.match(/^class\s+(\p{ID_Continue}+)/u));
 
     if (structMatch) {
       const name = structMatch[1];
@@ -125,7 +141,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
         name,
         kind: "class",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: isExported(trimmed, lang, name),
@@ -139,8 +155,8 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
 
     // --- Interface / trait ---
     const ifaceMatch =
-      trimmed.match(/^(?:(?:pub(?:lic)?|export)\s+)?(?:interface|trait|protocol)\s+(\w+)/) ||
-      (lang === "go" && trimmed.match(/^type\s+(\w+)\s+interface\b/));
+      trimmed.match(/^(?:(?:pub(?:lic)?|export)\s+)?(?:interface|trait|protocol)\s+(\p{ID_Continue}+)/u) ||
+      (lang === "go" && trimmed.match(/^type\s+(\p{ID_Continue}+)\s+interface\b/u));
 
     if (ifaceMatch) {
       const name = ifaceMatch[1];
@@ -151,7 +167,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
         name,
         kind: "interface",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: isExported(trimmed, lang, name),
@@ -163,7 +179,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
     }
 
     // --- Enum ---
-    const enumMatch = trimmed.match(/^(?:pub\s+)?(?:export\s+)?enum\s+(\w+)/);
+    const enumMatch = trimmed.match(/^(?:pub\s+)?(?:export\s+)?enum\s+(\p{ID_Continue}+)/u);
     if (enumMatch) {
       const name = enumMatch[1];
       const blockEnd = findBraceBlockEnd(lines, i);
@@ -173,7 +189,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
         name,
         kind: "enum",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: isExported(trimmed, lang, name),
@@ -186,10 +202,10 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
 
     // --- Function / method ---
     const funcMatch =
-      trimmed.match(/^(?:pub\s+)?(?:(?:async\s+)?fn|func|function|def|sub)\s+(\w+)\s*(?:<[^>]+>)?\s*\(/) ||
-      (lang === "go" && trimmed.match(/^func\s+(?:\([^)]+\)\s+)?(\w+)\s*\(/)) ||
-      (lang === "ruby" && trimmed.match(/^def\s+(\w+)/)) ||
-      (lang === "shell" && trimmed.match(/^(?:function\s+)?(\w+)\s*\(\s*\)/));
+      trimmed.match(/^(?:pub\s+)?(?:(?:async\s+)?fn|func|function|def|sub)\s+(\p{ID_Continue}+)\s*(?:<[^>]+>)?\s*\(/u) ||
+      (lang === "go" && trimmed.match(/^func\s+(?:\([^)]+\)\s+)?(\p{ID_Continue}+)\s*\(/u)) ||
+      (lang === "ruby" && trimmed.match(/^def\s+(\p{ID_Continue}+)/u)) ||
+      (lang === "shell" && trimmed.match(/^(?:function\s+)?(\p{ID_Continue}+)\s*\(\s*\)/u));
 
     if (funcMatch) {
       const name = funcMatch[1];
@@ -200,7 +216,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
         name,
         kind: "function",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: isExported(trimmed, lang, name),
@@ -215,7 +231,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
     // Handles .cc files where implementations use ClassName::method() syntax.
     // No keyword prefix — detected by the :: qualified name before the (.
     if (lang === "c") {
-      const cppMatch = trimmed.match(/(\w+)::(~?\w+)\s*(?:<[^>]*>)?\s*\(/);
+      const cppMatch = trimmed.match(/(\p{ID_Continue}+)::(~?\p{ID_Continue}+)\s*(?:<[^>]*>)?\s*\(/u);
       if (cppMatch && !trimmed.startsWith("#")) {
         const className = cppMatch[1];
         const methodName = cppMatch[2];
@@ -229,7 +245,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
             name,
             kind: "function",
             signature: trimmed.replace(/\{?\s*$/, "").trim(),
-            content: lines.slice(i, blockEnd + 1).join("\n"),
+            content: lines.block(i, blockEnd),
             line_start: i + 1,
             line_end: blockEnd + 1,
             exported: true,
@@ -246,12 +262,12 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
       const parenIdx = trimmed.indexOf("(");
       if (parenIdx > 0 && !trimmed.startsWith("#") && !trimmed.startsWith("//")) {
         const beforeParen = trimmed.slice(0, parenIdx).trim();
-        const nameMatch = beforeParen.match(/[*&\s](\w+)$/);
+        const nameMatch = beforeParen.match(/[*&\s](\p{ID_Continue}+)$/u);
         if (nameMatch) {
           const cFuncName = nameMatch[1];
           const excluded = ["if", "for", "while", "switch", "return", "sizeof", "typeof", "case", "catch", "throw"];
           const beforeName = beforeParen.slice(0, beforeParen.lastIndexOf(cFuncName)).trim();
-          if (!excluded.includes(cFuncName) && beforeName.length > 0 && /\w/.test(beforeName)) {
+          if (!excluded.includes(cFuncName) && beforeName.length > 0 && /\p{ID_Continue}/u.test(beforeName)) {
             const blockEnd = findBraceBlockEnd(lines, i);
             counter++;
             symbols.push({
@@ -259,7 +275,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
               name: cFuncName,
               kind: "function",
               signature: trimmed.replace(/[{;]\s*$/, "").trim(),
-              content: lines.slice(i, blockEnd > i ? blockEnd + 1 : i + 1).join("\n"),
+              content: lines.block(i, blockEnd > i ? blockEnd : i),
               line_start: i + 1,
               line_end: (blockEnd > i ? blockEnd : i) + 1,
               exported: !trimmed.startsWith("static"),
@@ -275,7 +291,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
 
     // --- Constant / type alias ---
     const constMatch =
-      (lang === "go" && trimmed.match(/^(?:var|const)\s+(\w+)/));
+      (lang === "go" && trimmed.match(/^(?:var|const)\s+(\p{ID_Continue}+)/u));
 
     if (constMatch) {
       const name = constMatch[1];
@@ -297,7 +313,7 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
         name,
         kind: "variable",
         signature: trimmed,
-        content: lines.slice(i, endLine + 1).join("\n"),
+        content: lines.block(i, endLine),
         line_start: i + 1,
         line_end: endLine + 1,
         exported: isExported(trimmed, lang, name),
@@ -312,10 +328,72 @@ export function parseGeneric(source: string, docId: string, ext: string): CodeSy
   return symbols;
 }
 
+// ── Makefiles ─────────────────────────────────────────────────────────
+
+/**
+ * A rule (`build test: deps` plus its tab-indented recipe) or a variable
+ * (`CFLAGS ?= -O2`, `define NAME ... endef`) starting at line `i`.
+ * Special targets such as .PHONY are skipped.
+ */
+function parseMakeDeclaration(lines: SourceLines, i: number, docId: string, n: number): CodeSymbol | null {
+  const trimmed = lines[i].trim();
+  const continued = (end: number) => {
+    while (end < lines.length - 1 && lines[end].trimEnd().endsWith("\\")) end++;
+    return end;
+  };
+
+  let name: string;
+  let kind: CodeSymbol["kind"];
+  let end: number;
+  let exported: boolean;
+
+  const define = trimmed.match(/^(?:(?:export|override)\s+)*define\s+([^\s=:]+)/);
+  const variable = trimmed.match(/^(?:(?:export|override)\s+)*([A-Za-z_][\w.-]*)\s*(?::{1,3}=|\?=|\+=|!=|=)/);
+  const rule = trimmed.match(/^([^\s:=#][^:=]*?)\s*::?(?!=)/);
+  if (define) {
+    name = define[1];
+    kind = "variable";
+    end = i;
+    while (end < lines.length - 1 && lines[end].trim() !== "endef") end++;
+    exported = trimmed.startsWith("export ");
+  } else if (variable) {
+    name = variable[1];
+    kind = "variable";
+    end = continued(i);
+    exported = trimmed.startsWith("export ");
+  } else if (rule) {
+    name = rule[1].split(/\s+/)[0];
+    if (name.startsWith(".")) return null;
+    kind = "function";
+    end = continued(i);
+    // The recipe: tab-indented lines, blank lines and comments between them
+    for (let j = end + 1; j < lines.length; j++) {
+      if (lines[j].startsWith("\t")) end = j;
+      else if (lines[j].trim() !== "" && !lines[j].startsWith("#")) break;
+    }
+    exported = true;
+  } else {
+    return null;
+  }
+
+  return {
+    id: `${docId}:n${n}`,
+    name,
+    kind,
+    signature: trimmed.replace(/\\$/, "").trim(),
+    content: lines.block(i, end),
+    line_start: i + 1,
+    line_end: end + 1,
+    exported,
+    children_ids: [],
+    parent_id: null,
+  };
+}
+
 // ── Member parsing ────────────────────────────────────────────────────
 
 function parseGenericMembers(
-  lines: string[],
+  lines: SourceLines,
   startLine: number,
   endLine: number,
   docId: string,
@@ -332,10 +410,10 @@ function parseGenericMembers(
 
     // Method / function inside struct/class
     const methodMatch =
-      trimmed.match(/^(?:pub\s+)?(?:(?:async\s+)?fn|func|function|def)\s+(\w+)\s*\(/) ||
-      (lang === "go" && trimmed.match(/^func\s+(\w+)\s*\(/)) ||
-      (lang === "ruby" && trimmed.match(/^def\s+(\w+)/)) ||
-      (lang === "java" && trimmed.match(/^(?:(?:public|private|protected|static|final|abstract|synchronized|native|override|async|await)\s+)*\w+(?:\s*<[^>]*>)?\s+(\w+)\s*\(/));
+      trimmed.match(/^(?:pub\s+)?(?:(?:async\s+)?fn|func|function|def)\s+(\p{ID_Continue}+)\s*\(/u) ||
+      (lang === "go" && trimmed.match(/^func\s+(\p{ID_Continue}+)\s*\(/u)) ||
+      (lang === "ruby" && trimmed.match(/^def\s+(\p{ID_Continue}+)/u)) ||
+      (lang === "java" && trimmed.match(/^(?:(?:public|private|protected|static|final|abstract|synchronized|native|override|async|await)\s+)*\p{ID_Continue}+(?:\s*<[^>]*>)?\s+(\p{ID_Continue}+)\s*\(/u));
 
     if (methodMatch) {
       const name = methodMatch[1];
@@ -349,7 +427,7 @@ function parseGenericMembers(
         name,
         kind: "method",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: isExported(trimmed, lang, name),
@@ -370,7 +448,9 @@ function findBraceBlockEnd(lines: string[], startLine: number): number {
   let foundOpen = false;
 
   for (let i = startLine; i < lines.length; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "{") { depth++; foundOpen = true; }
       if (ch === "}") depth--;
       if (foundOpen && depth === 0) return i;
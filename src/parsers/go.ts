@@ -10,6 +10,7 @@
  *   Pass 2: parse functions, methods, and const/var blocks.
  */
 import type { CodeSymbol } from "../code-indexer";
+import { sourceLines } from "./lines";
 
 /** Supported file extensions for this parser */
 export const GO_EXTENSIONS = new Set([".go"]);
@@ -27,7 +28,7 @@ export const GO_EXTENSIONS = new Set([".go"]);
  *  - Single const/var declarations (kind="variable")
  */
 export function parseGo(source: string, docId: string): CodeSymbol[] {
-  const lines = source.split("\n");
+  const lines = sourceLines(source);
   const symbols: CodeSymbol[] = [];
   let counter = 0;
 
@@ -41,7 +42,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
 
     // type X struct { ... }  or  type X interface { ... }
     // Also handles Go 1.18+ generics: type Set[T comparable] struct { ... }
-    const typeMatch = trimmed.match(/^type\s+(\w+)(?:\[[^\]]*\])?\s+(struct|interface)\b/);
+    const typeMatch = trimmed.match(/^type\s+(\p{ID_Continue}+)(?:\[[^\]]*\])?\s+(struct|interface)\b/u);
     if (typeMatch) {
       const name = typeMatch[1];
       const kind: CodeSymbol["kind"] = typeMatch[2] === "struct" ? "class" : "interface";
@@ -54,7 +55,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
         name,
         kind,
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: /^[A-Z]/.test(name),
@@ -66,7 +67,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
     }
 
     // type X SomeOtherType  (type alias — not struct or interface)
-    const aliasMatch = trimmed.match(/^type\s+(\w+)\s+(?!struct\b|interface\b)(\S+)/);
+    const aliasMatch = trimmed.match(/^type\s+(\p{ID_Continue}+)\s+(?!struct\b|interface\b)(\S+)/u);
     if (aliasMatch) {
       const name = aliasMatch[1];
       counter++;
@@ -95,9 +96,9 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
     if (!trimmed || trimmed.startsWith("//")) continue;
 
     // Skip type declarations (already processed in pass 1)
-    if (/^type\s+\w+/.test(trimmed)) {
+    if (/^type\s+\p{ID_Continue}+/u.test(trimmed)) {
       // If the type has a brace block body, skip past it
-      if (trimmed.match(/^type\s+\w+(?:\[[^\]]*\])?\s+(struct|interface)\b/)) {
+      if (trimmed.match(/^type\s+\p{ID_Continue}+(?:\[[^\]]*\])?\s+(struct|interface)\b/u)) {
         i = findBraceBlockEnd(lines, i);
       }
       continue;
@@ -116,7 +117,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
 
     // ── func (recv *Type) Method(...) — receiver method ────────────
     // Also handles generic receivers: func (s *Set[T]) Add(...)
-    const methodMatch = trimmed.match(/^func\s+\(\s*\w+\s+\*?(\w+)(?:\[[^\]]*\])?\s*\)\s+(\w+)\s*\(/);
+    const methodMatch = trimmed.match(/^func\s+\(\s*\p{ID_Continue}+\s+\*?(\p{ID_Continue}+)(?:\[[^\]]*\])?\s*\)\s+(\p{ID_Continue}+)\s*\(/u);
     if (methodMatch) {
       const receiverType = methodMatch[1];
       const name = methodMatch[2];
@@ -129,7 +130,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "method",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: /^[A-Z]/.test(name),
@@ -145,7 +146,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
     }
 
     // ── func Name(...) — top-level function (no receiver) ──────────
-    const funcMatch = trimmed.match(/^func\s+(\w+)\s*\(/);
+    const funcMatch = trimmed.match(/^func\s+(\p{ID_Continue}+)\s*\(/u);
     if (funcMatch) {
       const name = funcMatch[1];
       const blockEnd = findBraceBlockEnd(lines, i);
@@ -155,7 +156,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "function",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: /^[A-Z]/.test(name),
@@ -178,7 +179,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
         name: kwName,
         kind: "variable",
         signature: trimmed,
-        content: lines.slice(i, end + 1).join("\n"),
+        content: lines.block(i, end),
         line_start: i + 1,
         line_end: end + 1,
         exported: false,
@@ -190,7 +191,7 @@ export function parseGo(source: string, docId: string): CodeSymbol[] {
     }
 
     // ── var X / const X — single-line declaration ──────────────────
-    const singleMatch = trimmed.match(/^(?:const|var)\s+(\w+)/);
+    const singleMatch = trimmed.match(/^(?:const|var)\s+(\p{ID_Continue}+)/u);
     if (singleMatch) {
       const name = singleMatch[1];
       counter++;
@@ -220,7 +221,9 @@ function findBraceBlockEnd(lines: string[], startLine: number): number {
   let depth = 0;
   let found = false;
   for (let i = startLine; i < lines.length; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "{") { depth++; found = true; }
       if (ch === "}") depth--;
       if (found && depth === 0) return i;
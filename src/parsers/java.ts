@@ -14,6 +14,7 @@
  */
 
 import type { CodeSymbol } from "../code-indexer";
+import { sourceLines, type SourceLines } from "./lines";
 
 /** Supported file extensions for this parser */
 export const JAVA_EXTENSIONS = new Set([".java"]);
@@ -35,7 +36,7 @@ const JAVA_CONTROL_KEYWORDS = new Set([
  *  - Inner classes as children of their enclosing type
  */
 export function parseJava(source: string, docId: string): CodeSymbol[] {
-  const lines = source.split("\n");
+  const lines = sourceLines(source);
   const symbols: CodeSymbol[] = [];
   let counter = 0;
 
@@ -62,7 +63,7 @@ export function parseJava(source: string, docId: string): CodeSymbol[] {
       name: "imports",
       kind: "import",
       signature: `${importEnd - importStart + 1} import/package statements`,
-      content: lines.slice(importStart, importEnd + 1).join("\n"),
+      content: lines.block(importStart, importEnd),
       line_start: importStart + 1,
       line_end: importEnd + 1,
       exported: false,
@@ -117,7 +118,7 @@ export function parseJava(source: string, docId: string): CodeSymbol[] {
     declText = declText.replace(/\s+/g, " ").trim();
 
     // Detect type keyword: class / interface / enum / record / @interface
-    const typeKwMatch = declText.match(/\b(class|interface|enum|record|@interface)\s+(\w+)/);
+    const typeKwMatch = declText.match(/\b(class|interface|enum|record|@interface)\s+(\p{ID_Continue}+)/u);
     if (typeKwMatch) {
       const typeKw = typeKwMatch[1];
       const name = typeKwMatch[2];
@@ -145,7 +146,7 @@ export function parseJava(source: string, docId: string): CodeSymbol[] {
         name,
         kind,
         signature: sig.slice(0, 400),
-        content: lines.slice(annotLineStart, blockEnd + 1).join("\n"),
+        content: lines.block(annotLineStart, blockEnd),
         line_start: annotLineStart + 1,
         line_end: blockEnd + 1,
         exported: declText.includes("public"),
@@ -178,7 +179,7 @@ export function parseJava(source: string, docId: string): CodeSymbol[] {
  * as method declarations.
  */
 function parseJavaMembers(
-  lines: string[],
+  lines: SourceLines,
   startLine: number,
   endLine: number,
   docId: string,
@@ -209,7 +210,8 @@ function parseJavaMembers(
 
     // Inside a method body or anonymous class — just track braces, skip
     if (braceDepth > 0) {
-      for (const ch of trimmed) {
+      for (let k = 0; k < trimmed.length; k++) {
+        const ch = trimmed[k];
         if (ch === "{") braceDepth++;
         if (ch === "}") braceDepth--;
       }
@@ -235,7 +237,7 @@ function parseJavaMembers(
     }
 
     // Inner type declaration (class / interface / enum / record inside a class)
-    const innerTypeMatch = trimmed.match(/\b(class|interface|enum|record|@interface)\s+(\w+)/);
+    const innerTypeMatch = trimmed.match(/\b(class|interface|enum|record|@interface)\s+(\p{ID_Continue}+)/u);
     if (innerTypeMatch) {
       const typeKw = innerTypeMatch[1];
       const innerName = innerTypeMatch[2];
@@ -254,7 +256,7 @@ function parseJavaMembers(
         name: innerName,
         kind,
         signature: sig.slice(0, 400),
-        content: lines.slice(annotLineStart, blockEnd + 1).join("\n"),
+        content: lines.block(annotLineStart, blockEnd),
         line_start: annotLineStart + 1,
         line_end: blockEnd + 1,
         exported: trimmed.includes("public"),
@@ -281,7 +283,7 @@ function parseJavaMembers(
         name: methodName,
         kind: "method",
         signature: sig.slice(0, 400),
-        content: lines.slice(annotLineStart, methodEnd + 1).join("\n"),
+        content: lines.block(annotLineStart, methodEnd),
         line_start: annotLineStart + 1,
         line_end: methodEnd + 1,
         exported: trimmed.includes("public"),
@@ -296,7 +298,8 @@ function parseJavaMembers(
 
     // Unrecognized line (field, enum constant, static initializer, etc.)
     // Track braces so we can skip over any embedded blocks
-    for (const ch of trimmed) {
+    for (let k = 0; k < trimmed.length; k++) {
+      const ch = trimmed[k];
       if (ch === "{") braceDepth++;
       if (ch === "}") braceDepth--;
     }
@@ -333,7 +336,7 @@ function detectJavaMethod(line: string, className: string): string | null {
   if (beforeParen.includes("=")) return null;
 
   // Last identifier before ( = method/constructor name
-  const nameMatch = beforeParen.match(/(\w+)\s*$/);
+  const nameMatch = beforeParen.match(/(\p{ID_Continue}+)\s*$/u);
   if (!nameMatch) return null;
   const name = nameMatch[1];
 
@@ -379,7 +382,9 @@ function findJavaBlockEnd(lines: string[], startLine: number, maxLine = lines.le
   let depth = 0;
   let foundOpen = false;
   for (let i = startLine; i <= maxLine; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "{") { depth++; foundOpen = true; }
       if (ch === "}") depth--;
       if (foundOpen && depth === 0) return i;
@@ -397,7 +402,8 @@ function findJavaMethodEnd(lines: string[], startLine: number, maxLine: number):
   let foundOpen = false;
   for (let i = startLine; i <= maxLine; i++) {
     const t = stripLineComment(lines[i]);
-    for (const ch of t) {
+    for (let k = 0; k < t.length; k++) {
+      const ch = t[k];
       if (ch === "{") { depth++; foundOpen = true; }
       if (ch === "}") depth--;
       if (foundOpen && depth === 0) return i;
@@ -0,0 +1,67 @@
+/**
+ * Source lines for the language parsers
+ *
+ * Parsers walk a file line by line and cut each symbol's source back out
+ * of it. Cutting with lines.slice(a, b + 1).join("\n") allocated an array
+ * and built a fresh string per symbol, and since a class's content spans
+ * its methods, a large file was rebuilt once per nesting level.
+ * SourceLines.block cuts the same text as one slice of the source, which
+ * the engine shares with the source string instead of copying. For the
+ * same reason the parsers scan brackets by index, not with for...of,
+ * whose string iterator allocated as it went.
+ *
+ * Line start offsets live in one typed array per call, so a parse
+ * allocates nothing per line beyond the line strings themselves, and
+ * any number of SourceLines stay valid side by side.
+ */
+
+/** The lines of a source file, with its text cut by line range. */
+export interface SourceLines extends Array<string> {
+  /**
+   * Lines `from` through `to` (0-based, inclusive) joined by "\n", as
+   * lines.slice(from, to + 1).join("\n") would give them.
+   */
+  block(from: number, to: number): string;
+}
+
+/** Split `source` into lines (as source.split("\n")) for one parse. */
+export function sourceLines(source: string): SourceLines {
+  const lines = source.split("\n") as SourceLines;
+  const table = new Uint32Array(lines.length + 1);
+  let at = 0;
+  for (let i = 0; i < lines.length; i++) {
+    table[i] = at;
+    at += lines[i].length + 1;
+  }
+  table[lines.length] = at;
+  const count = lines.length;
+  lines.block = (from, to) => {
+    const start = Math.max(0, from);
+    const end = Math.min(to + 1, count);
+    // table[end] is one past the "\n" that ends line end - 1
+    return end <= start ? "" : source.slice(table[start], table[end] - 1);
+  };
+  return lines;
+}
+
+/**
+ * Words in `text`, as text.split(/\s+/).filter(Boolean).length counts
+ * them, without building the array of words.
+ */
+export function countWords(text: string): number {
+  let words = 0;
+  let inWord = false;
+  for (let i = 0; i < text.length; i++) {
+    const space = isSpace(text.charCodeAt(i));
+    if (!space && !inWord) words++;
+    inWord = !space;
+  }
+  return words;
+}
+
+/** The characters \s matches. */
+function isSpace(c: number): boolean {
+  if (c <= 32) return c === 32 || (c >= 9 && c <= 13);
+  if (c < 160) return false;
+  return c === 160 || c === 0x1680 || (c >= 0x2000 && c <= 0x200a) || c === 0x2028 || c === 0x2029 || c === 0x202f || c === 0x205f || c === 0x3000 || c === 0xfeff;
+}
@@ -0,0 +1,56 @@
+/**
+ * Jupyter notebook (.ipynb) reader
+ *
+ * A notebook is JSON: a list of cells, each with its source and, for
+ * code cells, the outputs of the last run. Only the source of code
+ * cells is indexed; outputs (images, tables, tracebacks) are skipped,
+ * and markdown cells only lend their heading to the code cells below
+ * them. The code indexer turns each code cell into a navigable node and
+ * parses its source with the parser of the kernel language.
+ */
+
+/** Supported file extensions for notebooks */
+export const NOTEBOOK_EXTENSIONS = new Set([".ipynb"]);
+
+export interface NotebookCell {
+  /** 1-based position among all cells, as Jupyter lists them */
+  index: number;
+  source: string;
+  /** The last markdown heading above the cell, if any */
+  heading?: string;
+}
+
+export interface Notebook {
+  /** Kernel language, lowercase ("python" when the notebook does not say) */
+  language: string;
+  cells: NotebookCell[];
+}
+
+/** The code cells of a notebook; null when `source` is not notebook JSON. */
+export function readNotebook(source: string): Notebook | null {
+  let json: any;
+  try {
+    json = JSON.parse(source);
+  } catch {
+    return null;
+  }
+  if (!json || !Array.isArray(json.cells)) return null;
+
+  const language = String(
+    json.metadata?.kernelspec?.language ?? json.metadata?.language_info?.name ?? "python"
+  ).toLowerCase();
+  const cells: NotebookCell[] = [];
+  let heading: string | undefined;
+  json.cells.forEach((cell: any, i: number) => {
+    // nbformat 4 stores source as a list of lines; older files as one string.
+    // Cells saved on Windows may hold "\r\n" inside the JSON strings.
+    const text = (Array.isArray(cell?.source) ? cell.source.join("") : String(cell?.source ?? "")).replace(/\r\n?/g, "\n");
+    if (cell?.cell_type === "markdown") {
+      const found = text.match(/^#{1,6}\s+(.+?)\s*#*\s*$/m);
+      if (found) heading = found[1];
+    } else if (cell?.cell_type === "code" && text.trim()) {
+      cells.push({ index: i + 1, source: text, ...(heading ? { heading } : {}) });
+    }
+  });
+  return { language, cells };
+}
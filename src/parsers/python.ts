@@ -9,9 +9,10 @@
  */
 
 import type { CodeSymbol } from "../code-indexer";
+import { sourceLines, type SourceLines } from "./lines";
 
-/** Supported file extensions for this parser */
-export const PYTHON_EXTENSIONS = new Set([".py", ".pyi"]);
+/** Supported file extensions for this parser (Starlark .bzl is Python syntax) */
+export const PYTHON_EXTENSIONS = new Set([".py", ".pyi", ".bzl"]);
 
 /**
  * Parse a Python source file into code symbols.
@@ -23,7 +24,7 @@ export const PYTHON_EXTENSIONS = new Set([".py", ".pyi"]);
  *  - Module-level constants (UPPER_CASE assignments)
  */
 export function parsePython(source: string, docId: string): CodeSymbol[] {
-  const lines = source.split("\n");
+  const lines = sourceLines(source);
   const symbols: CodeSymbol[] = [];
   let counter = 0;
 
@@ -55,7 +56,7 @@ export function parsePython(source: string, docId: string): CodeSymbol[] {
       name: "imports",
       kind: "import",
       signature: `${importEnd - importStart + 1} import statements`,
-      content: lines.slice(importStart, importEnd + 1).join("\n"),
+      content: lines.block(importStart, importEnd),
       line_start: importStart + 1,
       line_end: importEnd + 1,
       exported: false,
@@ -94,14 +95,14 @@ export function parsePython(source: string, docId: string): CodeSymbol[] {
     const currentTrimmed = currentLine.trim();
 
     // --- Class declaration ---
-    const classMatch = currentTrimmed.match(/^class\s+(\w+)(?:\s*\(([^)]*)\))?\s*:/);
+    const classMatch = currentTrimmed.match(/^class\s+(\p{ID_Continue}+)(?:\s*\(([^)]*)\))?\s*:/u);
     if (classMatch) {
       const name = classMatch[1];
       const bases = classMatch[2] || "";
       const blockEnd = findPythonBlockEnd(lines, i);
       counter++;
       const classId = `${docId}:n${counter}`;
-      const classBody = lines.slice(decoratorStart, blockEnd + 1).join("\n");
+      const classBody = lines.block(decoratorStart, blockEnd);
       const childIds: string[] = [];
 
       // Parse class methods
@@ -131,7 +132,7 @@ export function parsePython(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- Function declaration ---
-    const funcMatch = currentTrimmed.match(/^(?:async\s+)?def\s+(\w+)\s*\(/);
+    const funcMatch = currentTrimmed.match(/^(?:async\s+)?def\s+(\p{ID_Continue}+)\s*\(/u);
     if (funcMatch) {
       const name = funcMatch[1];
       const blockEnd = findPythonBlockEnd(lines, i);
@@ -146,7 +147,7 @@ export function parsePython(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "function",
         signature: sig,
-        content: lines.slice(decoratorStart, blockEnd + 1).join("\n"),
+        content: lines.block(decoratorStart, blockEnd),
         line_start: decoratorStart + 1,
         line_end: blockEnd + 1,
         exported: !name.startsWith("_"),
@@ -172,7 +173,7 @@ export function parsePython(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "variable",
         signature: currentTrimmed,
-        content: lines.slice(i, endLine + 1).join("\n"),
+        content: lines.block(i, endLine),
         line_start: i + 1,
         line_end: endLine + 1,
         exported: true,
@@ -190,7 +191,7 @@ export function parsePython(source: string, docId: string): CodeSymbol[] {
 // ── Class method parsing ──────────────────────────────────────────────
 
 function parseClassMethods(
-  lines: string[],
+  lines: SourceLines,
   startLine: number,
   endLine: number,
   docId: string,
@@ -228,7 +229,7 @@ function parseClassMethods(
     if (i > endLine) break;
 
     const methodLine = lines[i]?.trim() || "";
-    const methodMatch = methodLine.match(/^(?:async\s+)?def\s+(\w+)\s*\(/);
+    const methodMatch = methodLine.match(/^(?:async\s+)?def\s+(\p{ID_Continue}+)\s*\(/u);
     if (methodMatch) {
       const name = methodMatch[1];
       const blockEnd = Math.min(findPythonBlockEnd(lines, i), endLine);
@@ -243,7 +244,7 @@ function parseClassMethods(
         name,
         kind: "method",
         signature: sig,
-        content: lines.slice(decoratorStart, blockEnd + 1).join("\n"),
+        content: lines.block(decoratorStart, blockEnd),
         line_start: decoratorStart + 1,
         line_end: blockEnd + 1,
         exported: !name.startsWith("_"),
@@ -291,7 +292,9 @@ function findPythonBlockEnd(lines: string[], defLine: number): number {
 function findPythonExprEnd(lines: string[], startLine: number): number {
   let depth = 0;
   for (let i = startLine; i < lines.length; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "(" || ch === "[" || ch === "{") depth++;
       if (ch === ")" || ch === "]" || ch === "}") depth--;
     }
@@ -18,6 +18,7 @@
  *   Pass 2: process impl blocks and top-level fn/const/static/type aliases.
  */
 import type { CodeSymbol } from "../code-indexer";
+import { sourceLines, type SourceLines } from "./lines";
 
 /** Supported file extensions for this parser */
 export const RUST_EXTENSIONS = new Set([".rs"]);
@@ -35,7 +36,7 @@ export const RUST_EXTENSIONS = new Set([".rs"]);
  *  - pub type aliases (kind="type")
  */
 export function parseRust(source: string, docId: string): CodeSymbol[] {
-  const lines = source.split("\n");
+  const lines = sourceLines(source);
   const symbols: CodeSymbol[] = [];
   let counter = 0;
 
@@ -49,9 +50,9 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     if (!trimmed || trimmed.startsWith("//") || trimmed.startsWith("///") || trimmed.startsWith("/*") || trimmed.startsWith("*")) continue;
     if (trimmed.startsWith("#[") || trimmed.startsWith("#!")) continue;
 
-    const structMatch = trimmed.match(/^pub(?:\([^)]*\))?\s+struct\s+(\w+)/) || trimmed.match(/^struct\s+(\w+)/);
-    const enumMatch   = trimmed.match(/^pub(?:\([^)]*\))?\s+enum\s+(\w+)/)   || trimmed.match(/^enum\s+(\w+)/);
-    const traitMatch  = trimmed.match(/^pub(?:\([^)]*\))?\s+trait\s+(\w+)/)  || trimmed.match(/^trait\s+(\w+)/);
+    const structMatch = trimmed.match(/^pub(?:\([^)]*\))?\s+struct\s+(\p{ID_Continue}+)/u) || trimmed.match(/^struct\s+(\p{ID_Continue}+)/u);
+    const enumMatch   = trimmed.match(/^pub(?:\([^)]*\))?\s+enum\s+(\p{ID_Continue}+)/u)   || trimmed.match(/^enum\s+(\p{ID_Continue}+)/u);
+    const traitMatch  = trimmed.match(/^pub(?:\([^)]*\))?\s+trait\s+(\p{ID_Continue}+)/u)  || trimmed.match(/^trait\s+(\p{ID_Continue}+)/u);
 
     const typeMatch = structMatch || enumMatch || traitMatch;
     if (typeMatch) {
@@ -82,7 +83,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
         name,
         kind,
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: /^pub\b/.test(trimmed),
@@ -101,7 +102,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     if (trimmed.startsWith("#[") || trimmed.startsWith("#!")) continue;
 
     // Skip already-parsed struct/enum/trait declarations
-    if (trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait)\s+\w+/)) {
+    if (trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait)\s+\p{ID_Continue}+/u)) {
       if (trimmed.includes("{")) {
         i = findBraceBlockEnd(lines, i);
       } else {
@@ -117,7 +118,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- impl Trait for Type { ... } ---
-    const implForMatch = trimmed.match(/^impl(?:<[^>]*>)?\s+\w+(?:<[^>]*>)?\s+for\s+(\w+)/);
+    const implForMatch = trimmed.match(/^impl(?:<[^>]*>)?\s+\p{ID_Continue}+(?:<[^>]*>)?\s+for\s+(\p{ID_Continue}+)/u);
     if (implForMatch) {
       const typeName = implForMatch[1];
       const parentId = typeIds.get(typeName) ?? null;
@@ -128,7 +129,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- impl Name { ... } ---
-    const implMatch = trimmed.match(/^impl(?:<[^>]*>)?\s+(\w+)/);
+    const implMatch = trimmed.match(/^impl(?:<[^>]*>)?\s+(\p{ID_Continue}+)/u);
     if (implMatch) {
       const typeName = implMatch[1];
       const parentId = typeIds.get(typeName) ?? null;
@@ -139,7 +140,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- Top-level pub fn / fn ---
-    const fnMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(\w+)/);
+    const fnMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(\p{ID_Continue}+)/u);
     if (fnMatch) {
       const name = fnMatch[1];
       const blockEnd = findBraceBlockEnd(lines, i);
@@ -149,7 +150,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "function",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: /^pub\b/.test(trimmed),
@@ -161,7 +162,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- pub const / const / pub static / static ---
-    const constMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+(\w+)/);
+    const constMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+(\p{ID_Continue}+)/u);
     if (constMatch) {
       const name = constMatch[1];
       // Find end of statement (semicolon)
@@ -173,7 +174,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "variable",
         signature: trimmed.replace(/;?\s*$/, "").trim(),
-        content: lines.slice(i, end + 1).join("\n"),
+        content: lines.block(i, end),
         line_start: i + 1,
         line_end: end + 1,
         exported: /^pub\b/.test(trimmed),
@@ -185,7 +186,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- pub type Name = ... ---
-    const typeAliasMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?type\s+(\w+)/);
+    const typeAliasMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?type\s+(\p{ID_Continue}+)/u);
     if (typeAliasMatch) {
       const name = typeAliasMatch[1];
       let end = i;
@@ -196,7 +197,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "type",
         signature: trimmed.replace(/;?\s*$/, "").trim(),
-        content: lines.slice(i, end + 1).join("\n"),
+        content: lines.block(i, end),
         line_start: i + 1,
         line_end: end + 1,
         exported: /^pub\b/.test(trimmed),
@@ -215,7 +216,7 @@ export function parseRust(source: string, docId: string): CodeSymbol[] {
  * Links each method to parentId (if non-null) and pushes to symbols.
  */
 function parseFnsInBlock(
-  lines: string[],
+  lines: SourceLines,
   startLine: number,
   endLine: number,
   docId: string,
@@ -227,7 +228,7 @@ function parseFnsInBlock(
     const trimmed = lines[i].trim();
     if (!trimmed || trimmed.startsWith("//") || trimmed.startsWith("#[")) continue;
 
-    const fnMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(\w+)/);
+    const fnMatch = trimmed.match(/^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(\p{ID_Continue}+)/u);
     if (fnMatch) {
       const name = fnMatch[1];
       const blockEnd = Math.min(findBraceBlockEnd(lines, i), endLine);
@@ -238,7 +239,7 @@ function parseFnsInBlock(
         name,
         kind: "method",
         signature: trimmed.replace(/\{?\s*$/, "").trim(),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported: /^pub\b/.test(trimmed),
@@ -263,7 +264,9 @@ function findBraceBlockEnd(lines: string[], startLine: number): number {
   let depth = 0;
   let found = false;
   for (let i = startLine; i < lines.length; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "{") { depth++; found = true; }
       if (ch === "}") depth--;
       if (found && depth === 0) return i;
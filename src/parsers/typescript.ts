@@ -9,6 +9,7 @@
  */
 
 import type { CodeSymbol } from "../code-indexer";
+import { sourceLines, type SourceLines } from "./lines";
 
 /** Supported file extensions for this parser */
 export const TYPESCRIPT_EXTENSIONS = new Set([
@@ -27,7 +28,7 @@ export const TYPESCRIPT_EXTENSIONS = new Set([
  *  - Top-level const/let/var exports
  */
 export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
-  const lines = source.split("\n");
+  const lines = sourceLines(source);
   const symbols: CodeSymbol[] = [];
   let counter = 0;
 
@@ -36,7 +37,9 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
     let depth = 0;
     let foundOpen = false;
     for (let i = startLine; i < lines.length; i++) {
-      for (const ch of lines[i]) {
+      const line = lines[i];
+      for (let k = 0; k < line.length; k++) {
+        const ch = line[k];
         if (ch === "{") { depth++; foundOpen = true; }
         if (ch === "}") { depth--; }
         if (foundOpen && depth === 0) return i;
@@ -70,7 +73,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
         name: "imports",
         kind: "import",
         signature: `${lines.slice(importStart, i + 1).length} import statements`,
-        content: lines.slice(importStart, i + 1).join("\n"),
+        content: lines.block(importStart, i),
         line_start: importStart + 1,
         line_end: i + 1,
         exported: false,
@@ -82,7 +85,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
 
     // --- Class declaration ---
     const classMatch = trimmed.match(
-      /^(export\s+)?((?:abstract\s+)?class)\s+(\w+)(?:\s+extends\s+[\w.<>,\s]+)?(?:\s+implements\s+[\w.<>,\s]+)?\s*\{?/
+      /^(export\s+)?((?:abstract\s+)?class)\s+(\p{ID_Continue}+)(?:\s+extends\s+[\p{ID_Continue}.<>,\s]+)?(?:\s+implements\s+[\p{ID_Continue}.<>,\s]+)?\s*\{?/u
     );
     if (classMatch) {
       const exported = !!classMatch[1];
@@ -90,7 +93,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
       const blockEnd = findBlockEnd(i);
       counter++;
       const classId = `${docId}:n${counter}`;
-      const classBody = lines.slice(i, blockEnd + 1).join("\n");
+      const classBody = lines.block(i, blockEnd);
       const childIds: string[] = [];
 
       // Parse class members
@@ -117,7 +120,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
 
     // --- Interface declaration ---
     const ifaceMatch = trimmed.match(
-      /^(export\s+)?interface\s+(\w+)(?:<[^>]+>)?(?:\s+extends\s+[\w.<>,\s]+)?\s*\{?/
+      /^(export\s+)?interface\s+(\p{ID_Continue}+)(?:<[^>]+>)?(?:\s+extends\s+[\p{ID_Continue}.<>,\s]+)?\s*\{?/u
     );
     if (ifaceMatch) {
       const exported = !!ifaceMatch[1];
@@ -125,7 +128,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
       const blockEnd = findBlockEnd(i);
       counter++;
       const ifaceId = `${docId}:n${counter}`;
-      const ifaceBody = lines.slice(i, blockEnd + 1).join("\n");
+      const ifaceBody = lines.block(i, blockEnd);
       const childIds: string[] = [];
 
       const members = parseInterfaceMembers(lines, i + 1, blockEnd, docId, ifaceId, counter);
@@ -150,7 +153,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- Type alias ---
-    const typeMatch = trimmed.match(/^(export\s+)?type\s+(\w+)(?:<[^>]+>)?\s*=/);
+    const typeMatch = trimmed.match(/^(export\s+)?type\s+(\p{ID_Continue}+)(?:<[^>]+>)?\s*=/u);
     if (typeMatch) {
       const exported = !!typeMatch[1];
       const name = typeMatch[2];
@@ -167,7 +170,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "type",
         signature: extractSignature(trimmed),
-        content: lines.slice(i, endLine + 1).join("\n"),
+        content: lines.block(i, endLine),
         line_start: i + 1,
         line_end: endLine + 1,
         exported,
@@ -179,7 +182,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
     }
 
     // --- Enum declaration ---
-    const enumMatch = trimmed.match(/^(export\s+)?(const\s+)?enum\s+(\w+)\s*\{?/);
+    const enumMatch = trimmed.match(/^(export\s+)?(const\s+)?enum\s+(\p{ID_Continue}+)\s*\{?/u);
     if (enumMatch) {
       const exported = !!enumMatch[1];
       const name = enumMatch[3];
@@ -190,7 +193,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "enum",
         signature: extractSignature(trimmed),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported,
@@ -203,7 +206,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
 
     // --- Function declaration ---
     const funcMatch = trimmed.match(
-      /^(export\s+)?((?:async\s+)?function\*?)\s+(\w+)\s*(?:<[^>]+>)?\s*\(/
+      /^(export\s+)?((?:async\s+)?function\*?)\s+(\p{ID_Continue}+)\s*(?:<[^>]+>)?\s*\(/u
     );
     if (funcMatch) {
       const exported = !!funcMatch[1];
@@ -215,7 +218,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "function",
         signature: buildFunctionSignature(lines, i, blockEnd),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported,
@@ -228,7 +231,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
 
     // --- Arrow function const ---
     const arrowMatch = trimmed.match(
-      /^(export\s+)?(?:const|let|var)\s+(\w+)\s*(?::\s*[^=]+)?\s*=\s*(?:async\s+)?(?:\([^)]*\)|[a-zA-Z_]\w*)\s*(?::\s*[^=]+)?\s*=>/
+      /^(export\s+)?(?:const|let|var)\s+(\p{ID_Continue}+)\s*(?::\s*[^=]+)?\s*=\s*(?:async\s+)?(?:\([^)]*\)|[\p{ID_Start}_]\p{ID_Continue}*)\s*(?::\s*[^=]+)?\s*=>/u
     );
     if (arrowMatch) {
       const exported = !!arrowMatch[1];
@@ -241,7 +244,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
         name,
         kind: "function",
         signature: extractSignature(trimmed),
-        content: lines.slice(i, blockEnd + 1).join("\n"),
+        content: lines.block(i, blockEnd),
         line_start: i + 1,
         line_end: blockEnd + 1,
         exported,
@@ -254,7 +257,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
 
     // --- Exported const (non-arrow) ---
     const constMatch = trimmed.match(
-      /^export\s+(?:const|let|var)\s+(\w+)\s*(?::\s*[^=]+)?\s*=/
+      /^export\s+(?:const|let|var)\s+(\p{ID_Continue}+)\s*(?::\s*[^=]+)?\s*=/u
     );
     if (constMatch && !arrowMatch) {
       const name = constMatch[1];
@@ -265,7 +268,8 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
         // Array literal — find closing bracket
         let depth = 0;
         for (let j = i; j < lines.length; j++) {
-          for (const ch of lines[j]) {
+          for (let k = 0; k < lines[j].length; k++) {
+            const ch = lines[j][k];
             if (ch === "[") depth++;
             if (ch === "]") depth--;
           }
@@ -274,7 +278,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
       } else {
         while (endLine < lines.length - 1 && !lines[endLine].trimEnd().endsWith(";")) endLine++;
       }
-      const content = lines.slice(i, endLine + 1).join("\n");
+      const content = lines.block(i, endLine);
       const kind = isArrowFunctionContent(content) ? "function" : "variable";
       counter++;
       symbols.push({
@@ -300,7 +304,7 @@ export function parseTypeScript(source: string, docId: string): CodeSymbol[] {
 // ── Class member parsing ──────────────────────────────────────────────
 
 function parseClassMembers(
-  lines: string[],
+  lines: SourceLines,
   startLine: number,
   endLine: number,
   docId: string,
@@ -316,7 +320,7 @@ function parseClassMembers(
 
     // Method (including async, static, get/set, private/protected/public)
     const methodMatch = trimmed.match(
-      /^(?:(?:private|protected|public|static|abstract|async|override|readonly)\s+)*(?:get\s+|set\s+)?(\w+)\s*(?:<[^>]+>)?\s*\(/
+      /^(?:(?:private|protected|public|static|abstract|async|override|readonly)\s+)*(?:get\s+|set\s+)?(\p{ID_Continue}+)\s*(?:<[^>]+>)?\s*\(/u
     );
     if (methodMatch && !trimmed.match(/^(?:if|for|while|switch|catch)\s*\(/)) {
       const name = methodMatch[1];
@@ -329,7 +333,7 @@ function parseClassMembers(
         name: isConstructor ? "constructor" : name,
         kind: "method",
         signature: buildFunctionSignature(lines, i, methodEnd),
-        content: lines.slice(i, methodEnd + 1).join("\n"),
+        content: lines.block(i, methodEnd),
         line_start: i + 1,
         line_end: methodEnd + 1,
         exported: false,
@@ -342,7 +346,7 @@ function parseClassMembers(
 
     // Property declaration (field)
     const propMatch = trimmed.match(
-      /^(?:(?:private|protected|public|static|readonly|abstract|override)\s+)*(\w+)[\?!]?\s*(?::\s*[^=;]+)?(?:\s*=\s*[^;]+)?;?\s*$/
+      /^(?:(?:private|protected|public|static|readonly|abstract|override)\s+)*(\p{ID_Continue}+)[\?!]?\s*(?::\s*[^=;]+)?(?:\s*=\s*[^;]+)?;?\s*$/u
     );
     if (propMatch && !trimmed.startsWith("//") && !trimmed.startsWith("*")) {
       const name = propMatch[1];
@@ -385,7 +389,7 @@ function parseInterfaceMembers(
     if (trimmed === "" || trimmed === "{" || trimmed === "}" || trimmed.startsWith("//") || trimmed.startsWith("*")) continue;
 
     // Interface method signature
-    const methodMatch = trimmed.match(/^(?:readonly\s+)?(\w+)\s*(?:<[^>]+>)?\s*\(/);
+    const methodMatch = trimmed.match(/^(?:readonly\s+)?(\p{ID_Continue}+)\s*(?:<[^>]+>)?\s*\(/u);
     if (methodMatch) {
       const name = methodMatch[1];
       counter++;
@@ -405,7 +409,7 @@ function parseInterfaceMembers(
     }
 
     // Interface property
-    const propMatch = trimmed.match(/^(?:readonly\s+)?(\w+)[\?!]?\s*:/);
+    const propMatch = trimmed.match(/^(?:readonly\s+)?(\p{ID_Continue}+)[\?!]?\s*:/u);
     if (propMatch) {
       const name = propMatch[1];
       counter++;
@@ -434,7 +438,9 @@ function findMethodEnd(lines: string[], startLine: number): number {
   let foundOpen = false;
 
   for (let i = startLine; i < lines.length; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "{") { depth++; foundOpen = true; }
       if (ch === "}") depth--;
       if (foundOpen && depth === 0) return i;
@@ -456,7 +462,9 @@ function findArrowEnd(lines: string[], startLine: number): number {
     let depth = 0;
     let foundOpen = false;
     for (let i = startLine; i < lines.length; i++) {
-      for (const ch of lines[i]) {
+      const line = lines[i];
+      for (let k = 0; k < line.length; k++) {
+        const ch = line[k];
         if (ch === "{") { depth++; foundOpen = true; }
         if (ch === "}") depth--;
         if (foundOpen && depth === 0) return i;
@@ -467,7 +475,9 @@ function findArrowEnd(lines: string[], startLine: number): number {
   // Multi-line expression arrow
   let parenDepth = 0;
   for (let i = startLine; i < lines.length; i++) {
-    for (const ch of lines[i]) {
+    const line = lines[i];
+    for (let k = 0; k < line.length; k++) {
+      const ch = line[k];
       if (ch === "(") parenDepth++;
       if (ch === ")") parenDepth--;
     }
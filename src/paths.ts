@@ -0,0 +1,117 @@
+/**
+ * Path spellings — one form for every path the index holds
+ *
+ * On a Windows box the same file arrives as `src\db\conn.go` from
+ * path.relative, `C:\repo\src\db\conn.go` from an editor or a config
+ * root, `\\build01\repo\src\db\conn.go` or `\\?\C:\repo\...` from a
+ * share or a long-path API, and `SRC/DB/Conn.go` from an agent that
+ * guessed the case NTFS ignores. The index keeps one spelling: relative
+ * to the collection root, "/"-separated, in the case the directory
+ * listing gave. What crosses into it goes through here:
+ *
+ *   relativePath     root-relative and "/"-separated (path.relative,
+ *                    then the platform separator replaced)
+ *   isWithin         containment by path.relative rather than string
+ *                    prefixes, so drive roots, other drives, and the
+ *                    case-insensitive comparison of win32 paths hold
+ *   rootPath         a configured root resolved, with the `\\?\` and
+ *                    `\\?\UNC\` long-path prefixes dropped
+ *   isAbsolutePath   POSIX, drive-letter, and UNC paths, whatever the
+ *                    platform, so tool input is judged the same everywhere
+ *   toolPath         a path from tool input in the index's spelling:
+ *                    backslashes to "/", "./" and "//" dropped, absolute
+ *                    paths under a root made relative to it
+ *   caseInsensitive  whether a root's filesystem ignores case (NTFS, APFS
+ *                    by default), probed on disk, not guessed from the OS
+ *
+ * The DocumentStore matches tool paths with toolPath and, on a
+ * case-insensitive filesystem, case-folded (setPathMatching). The
+ * functions that depend on the platform take the `path` flavor to use,
+ * the platform's by default, which is how tests/paths.test.ts runs the
+ * Windows rules on every CI runner. Backslashes in tool input are always
+ * separators, never glob escapes.
+ */
+
+import * as path from "node:path";
+import { stat } from "node:fs/promises";
+import type { IndexConfig } from "./types";
+
+type PathFlavor = typeof path.posix;
+
+/** `relPath` with every backslash turned into "/". */
+export function toPosix(relPath: string): string {
+  return relPath.replace(/\\/g, "/");
+}
+
+/** `file` relative to `root`, "/"-separated ("" for the root itself). */
+export function relativePath(root: string, file: string, p: PathFlavor = path): string {
+  return p.relative(root, file).split(p.sep).join("/");
+}
+
+/** Whether `target` is `root` or inside it. */
+export function isWithin(root: string, target: string, p: PathFlavor = path): boolean {
+  const rel = p.relative(root, target);
+  return rel !== ".." && !rel.startsWith(`..${p.sep}`) && !p.isAbsolute(rel);
+}
+
+/** A configured root, resolved, without a `\\?\` or `\\?\UNC\` prefix. */
+export function rootPath(root: string, p: PathFlavor = path): string {
+  const plain = root.replace(/^[\\/]{2}\?[\\/]UNC[\\/]/i, "\\\\").replace(/^[\\/]{2}\?[\\/](?=[a-zA-Z]:)/, "");
+  return p.resolve(plain);
+}
+
+/** Whether `input` is absolute on any platform: `/x`, `\x`, `C:\x`, `C:/x`, or a UNC path. */
+export function isAbsolutePath(input: string): boolean {
+  return /^(?:[a-zA-Z]:[\\/]|[\\/])/.test(input);
+}
+
+/**
+ * A path from tool input in the index's spelling. An absolute path
+ * under one of `roots` (the most specific, when they nest) becomes
+ * relative to it, "." for the root itself; other absolute paths only get
+ * "/" separators, and are left for the caller to reject.
+ */
+export function toolPath(input: string, roots: string[] = [], p: PathFlavor = path): string {
+  if (isAbsolutePath(input)) {
+    const absolute = p.resolve(input);
+    const root = roots
+      .filter((r) => isWithin(r, absolute, p))
+      .sort((a, b) => b.length - a.length)[0];
+    return root === undefined ? toPosix(input) : relativePath(root, absolute, p) || ".";
+  }
+  let rel = toPosix(input).replace(/\/{2,}/g, "/").replace(/\/\.(?=\/|$)/g, "");
+  while (rel.startsWith("./")) rel = rel.slice(2);
+  return rel;
+}
+
+/** `s` with the case of every letter flipped. */
+function swapCase(s: string): string {
+  return s.replace(/\p{L}/gu, (c) => (c === c.toLowerCase() ? c.toUpperCase() : c.toLowerCase()));
+}
+
+/**
+ * Whether the filesystem holding `dir` ignores case: the directory is
+ * looked up again with the case of its path flipped. False when that
+ * names nothing, or when it cannot be told (no letters, `dir` missing).
+ */
+export async function caseInsensitive(dir: string): Promise<boolean> {
+  const abs = path.resolve(dir);
+  const flipped = swapCase(abs);
+  if (flipped === abs) return false;
+  try {
+    const [a, b] = await Promise.all([stat(abs), stat(flipped)]);
+    return a.ino === b.ino && a.dev === b.dev;
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * Collection roots and case rule for DocumentStore.setPathMatching:
+ * case is folded when any root's filesystem ignores it.
+ */
+export async function pathMatching(config: IndexConfig): Promise<{ roots: string[]; caseInsensitive: boolean }> {
+  const roots = [...new Set([...config.collections, ...(config.code_collections ?? [])].map((c) => rootPath(c.root)))];
+  const folds = await Promise.all(roots.map(caseInsensitive));
+  return { roots, caseInsensitive: folds.some(Boolean) };
+}
@@ -0,0 +1,210 @@
+/**
+ * Peeks at many definitions at once — the peek_definitions tool
+ *
+ * An agent reading a file pulls out a dozen identifiers it does not
+ * know (NodeInfo, dialBackoff, Pool.Put) and wants each one's shape
+ * before it reads further. find_symbol answers one name per call with
+ * whole bodies; peek_definitions answers all of them in one, each with
+ * just enough to judge it:
+ *
+ *   signature   func (p *Pool) Put(c *Conn)
+ *   doc         the comment block above it (or a Python docstring)
+ *   excerpt     the first body_lines lines of the definition
+ *
+ * Names resolve like find_symbol with exact names: the symbol's own
+ * name, or Parent.name to pin a method to its type. Doc comments are
+ * not part of the indexed symbol, so they are read from the file on
+ * disk, once per file per call.
+ */
+
+import { join } from "node:path";
+import type { CollectionConfig, IndexConfig, TreeNode } from "./types";
+import type { DocumentStore } from "./store";
+import { readSourceText } from "./encoding";
+
+/** Names one call resolves at most. */
+export const MAX_PEEK_NAMES = 50;
+
+export const DEFAULT_BODY_LINES = 8;
+
+/** Definitions kept per name; the rest are only counted. */
+export const DEFAULT_PER_NAME = 3;
+
+/** Longest doc comment returned, in lines. */
+const MAX_DOC_LINES = 30;
+
+export interface PeekedDefinition {
+  doc_id: string;
+  node_id: string;
+  file_path: string;
+  /** Node title: kind and name, e.g. "method Put" */
+  title: string;
+  line_start: number;
+  line_end: number;
+  signature: string;
+  /** The comment above the definition, markers stripped; absent when there is none */
+  doc?: string;
+  /** The first body_lines lines of the definition */
+  excerpt: string;
+  /** The definition is longer than the excerpt */
+  excerpt_truncated: boolean;
+}
+
+export interface PeekResult {
+  name: string;
+  definitions: PeekedDefinition[];
+  /** Definitions beyond per_name */
+  more: number;
+}
+
+export class DefinitionPeek {
+  constructor(private readonly config: IndexConfig) {}
+
+  /** The definitions of each of `names`, in the order given, duplicates dropped. */
+  async peek(
+    store: DocumentStore,
+    names: string[],
+    options: { body_lines?: number; per_name?: number; languages?: string | string[] } = {}
+  ): Promise<PeekResult[]> {
+    const bodyLines = options.body_lines ?? DEFAULT_BODY_LINES;
+    const perName = options.per_name ?? DEFAULT_PER_NAME;
+    const collections = this.collections();
+    // Read per call: files change, and a call names few of them
+    const files = new Map<string, Promise<string[] | null>>();
+    const linesOf = (collection: string, file_path: string) => {
+      const key = `${collection}\0${file_path}`;
+      let lines = files.get(key);
+      if (!lines) {
+        const root = collections.get(collection)?.root;
+        lines = root
+          ? readSourceText(join(root, file_path)).then((text) => text.split("\n"), () => null)
+          : Promise.resolve(null);
+        files.set(key, lines);
+      }
+      return lines;
+    };
+
+    const results: PeekResult[] = [];
+    for (const name of [...new Set(names.map((n) => n.trim()).filter(Boolean))].slice(0, MAX_PEEK_NAMES)) {
+      const found = definitionsOf(store, name, options.languages);
+      const definitions: PeekedDefinition[] = [];
+      for (const { result, node } of found.slice(0, perName)) {
+        const body = node.content.split("\n");
+        const lines = node.cell ? null : await linesOf(result.collection, result.file_path);
+        const doc = (lines && docCommentAbove(lines, node.line_start - 1)) ?? docstring(body);
+        definitions.push({
+          doc_id: result.doc_id,
+          node_id: node.node_id,
+          file_path: result.file_path,
+          title: node.title,
+          line_start: node.line_start,
+          line_end: node.line_end,
+          signature: node.summary,
+          ...(doc ? { doc } : {}),
+          excerpt: body.slice(0, bodyLines).join("\n"),
+          excerpt_truncated: body.length > bodyLines,
+        });
+      }
+      results.push({ name, definitions, more: Math.max(0, found.length - perName) });
+    }
+    return results;
+  }
+
+  private collections(): Map<string, CollectionConfig> {
+    return new Map(
+      [...this.config.collections, ...(this.config.code_collections ?? []), ...(this.config.dependency_collections ?? [])].map(
+        (c) => [c.name, c]
+      )
+    );
+  }
+}
+
+/**
+ * Code nodes defining exactly `name`, or with Parent.name, the ones
+ * whose enclosing symbol is Parent.
+ */
+function definitionsOf(
+  store: DocumentStore,
+  name: string,
+  languages: string | string[] | undefined
+): Array<{ result: { doc_id: string; collection: string; file_path: string }; node: TreeNode }> {
+  const dot = name.lastIndexOf(".");
+  const own = dot > 0 ? name.slice(dot + 1) : name;
+  const parent = dot > 0 ? name.slice(0, dot) : undefined;
+  const filters: Record<string, string | string[]> = { content_type: ["code", "notebook"] };
+  if (languages) filters["language"] = languages;
+  const nameOf = (title: string) => title.slice(title.indexOf(" ") + 1);
+
+  const found = [];
+  for (const r of store.searchDocuments(own, { limit: 50, filters, case: "sensitive", word_boundaries: true })) {
+    if (r.node_title === "imports" || nameOf(r.node_title) !== own) continue;
+    const node = store.getNodeContent(r.doc_id, [r.node_id])?.nodes[0];
+    if (!node) continue;
+    if (parent !== undefined) {
+      const up = node.parent_id ? store.getNodeContent(r.doc_id, [node.parent_id])?.nodes[0] : undefined;
+      if (!up || nameOf(up.title) !== parent) continue;
+    }
+    found.push({ result: r, node });
+  }
+  return found;
+}
+
+// ── Doc comments ─────────────────────────────────────────────────────
+
+/**
+ * The comment block ending just above 0-based line `index`: // and ///
+ * lines, # lines, or one /* ... *\/ block, with decorators and
+ * attributes (@Override, #[derive], @app.route) in between skipped.
+ */
+export function docCommentAbove(lines: string[], index: number): string | undefined {
+  let i = index - 1;
+  while (i >= 0 && /^\s*(?:@|#\[)/.test(lines[i])) i--;
+  if (i < 0) return undefined;
+
+  const doc: string[] = [];
+  const last = lines[i].trim();
+  if (last.endsWith("*/")) {
+    for (; i >= 0 && index - i <= MAX_DOC_LINES + 1; i--) {
+      const text = lines[i].trim();
+      doc.unshift(text.replace(/^\/\*+\s?/, "").replace(/\s*\*+\/$/, "").replace(/^\*\s?/, ""));
+      if (text.startsWith("/*")) break;
+    }
+  } else {
+    for (; i >= 0 && doc.length < MAX_DOC_LINES; i--) {
+      const text = lines[i].trim();
+      const marker = /^(?:\/\/[/!]?|#(?![[!]))\s?/.exec(text);
+      if (!marker) break;
+      // Compiler directives are not documentation
+      if (/^\/\/(?:go:|line |nolint)/.test(text) || /^#\s*(?:type:|noqa|pragma)/.test(text)) continue;
+      doc.unshift(text.slice(marker[0].length));
+    }
+  }
+  return trimBlank(doc);
+}
+
+/** A Python docstring opening the body after the signature. */
+function docstring(body: string[]): string | undefined {
+  const first = body.findIndex((line, i) => i > 0 && line.trim() !== "");
+  if (first < 0) return undefined;
+  const open = /^\s*[rRuU]?("""|''')/.exec(body[first]);
+  if (!open) return undefined;
+  const quote = open[1];
+  const doc: string[] = [];
+  for (let i = first; i < body.length && doc.length < MAX_DOC_LINES; i++) {
+    let text = body[i].trim();
+    if (i === first) text = text.slice(text.indexOf(quote) + 3);
+    const close = text.indexOf(quote);
+    if (close >= 0) {
+      doc.push(text.slice(0, close));
+      break;
+    }
+    doc.push(text);
+  }
+  return trimBlank(doc);
+}
+
+function trimBlank(doc: string[]): string | undefined {
+  while (doc.length && doc[0].trim() === "") doc.shift();
+  while (doc.length && doc[doc.length - 1].trim() === "") doc.pop();
+  return doc.length ? doc.join("\n") : undefined;
+}
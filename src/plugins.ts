@@ -0,0 +1,214 @@
+/**
+ * Plugin tools — PLUGINS
+ *
+ * Teams add organization-specific tools ("find feature flag usages",
+ * "which service owns this queue") that answer from the shared index,
+ * without forking the server. PLUGINS lists module paths; each module
+ * default-exports one tool or an array of them:
+ *
+ *   import { definePlugin } from "treenav-mcp/src/plugins";
+ *
+ *   export default definePlugin({
+ *     name: "find_feature_flags",
+ *     description: "Where a feature flag is read, by file",
+ *     params: { flag: { type: "string", description: "Flag key" } },
+ *     annotations: { readOnlyHint: true, idempotentHint: true },
+ *     run: ({ flag }, ctx) => {
+ *       const hits = ctx.index.searchDocuments(flag, { limit: 50 });
+ *       return { text: hits.map((h) => h.file_path).join("\n"), data: { files: hits.length } };
+ *     },
+ *   });
+ *
+ * Modules are loaded in-process once at startup, so a plugin is plain
+ * TypeScript with the same runtime as the server. It sees the index
+ * through a frozen facade of bound query methods (PluginIndex), never
+ * the store itself, so it cannot load, re-weight, or rescore the index
+ * under the other callers. It can read indexed files, and runs under the
+ * call's deadline like any other tool (TOOL_TIMEOUT_MS): long scans
+ * should check ctx.deadline. Parameters are declared with a small type
+ * vocabulary, so plugins do not depend on the server's zod. A plugin
+ * declares its own MCP behavior hints; the server cannot know what the
+ * code does, so undeclared hints are the cautious ones (not read-only,
+ * not idempotent).
+ *
+ * A module that fails to load, or a tool with a bad definition or a
+ * name already taken, is skipped with a warning; the rest still load.
+ */
+
+import { join, resolve } from "node:path";
+import { z } from "zod";
+import type { CollectionConfig, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { currentDeadline, type Deadline } from "./deadline";
+import { readSourceText } from "./encoding";
+
+export type PluginParamType = "string" | "number" | "boolean" | "string[]";
+
+export interface PluginParam {
+  type: PluginParamType;
+  description: string;
+  optional?: boolean;
+  default?: string | number | boolean | string[];
+}
+
+const PLUGIN_INDEX_METHODS = ["searchDocuments", "listDocuments", "getTree", "getNodeContent", "getDocMeta", "getStats"] as const;
+
+/** The read-only part of the store a plugin may use */
+export type PluginIndex = Pick<DocumentStore, (typeof PLUGIN_INDEX_METHODS)[number]>;
+
+/** A frozen object holding only the query methods of `store`, bound to it. */
+export function pluginIndex(store: DocumentStore): PluginIndex {
+  const index: Record<string, unknown> = {};
+  for (const method of PLUGIN_INDEX_METHODS) index[method] = store[method].bind(store);
+  return Object.freeze(index) as PluginIndex;
+}
+
+export interface PluginContext {
+  index: PluginIndex;
+  /** Contents of an indexed file by doc_id, or null if unknown or unreadable */
+  readFile(doc_id: string): Promise<string | null>;
+  /** The call's deadline; stop scanning once deadline.expired() */
+  deadline?: Deadline;
+}
+
+/** MCP behavior hints a plugin tool declares about itself */
+export interface PluginAnnotations {
+  readOnlyHint?: boolean;
+  destructiveHint?: boolean;
+  idempotentHint?: boolean;
+  openWorldHint?: boolean;
+}
+
+/** What run() returns: plain text, or text plus structured data */
+export type PluginResult =
+  | string
+  | { text: string; data?: Record<string, unknown>; status?: "ok" | "not_found" };
+
+export interface PluginTool {
+  /** Tool name, snake_case; must not clash with a built-in tool */
+  name: string;
+  description: string;
+  params?: Record<string, PluginParam>;
+  /** Behavior hints; see PLUGIN_DEFAULT_ANNOTATIONS for the undeclared ones */
+  annotations?: PluginAnnotations;
+  run(args: Record<string, any>, ctx: PluginContext): PluginResult | Promise<PluginResult>;
+}
+
+/** Identity, for type checking a plugin module's default export. */
+export function definePlugin<T extends PluginTool | PluginTool[]>(plugin: T): T {
+  return plugin;
+}
+
+/** Hints for what a plugin does not declare: it may write, and may reach outside the index. */
+export const PLUGIN_DEFAULT_ANNOTATIONS: Required<PluginAnnotations> = {
+  readOnlyHint: false,
+  destructiveHint: true,
+  idempotentHint: false,
+  openWorldHint: true,
+};
+
+const NAME = /^[a-z][a-z0-9_]{0,63}$/;
+const PARAM_TYPES = new Set<string>(["string", "number", "boolean", "string[]"]);
+const HINTS = new Set(Object.keys(PLUGIN_DEFAULT_ANNOTATIONS));
+
+/** The loaded plugin tools and what their calls can reach. */
+export class PluginHost {
+  constructor(
+    readonly tools: PluginTool[],
+    private readonly config: IndexConfig
+  ) {}
+
+  /** The context for one call against `store`. */
+  context(store: DocumentStore): PluginContext {
+    const collections = new Map<string, CollectionConfig>(
+      [...this.config.collections, ...(this.config.code_collections ?? []), ...(this.config.dependency_collections ?? [])].map(
+        (c) => [c.name, c]
+      )
+    );
+    return {
+      index: pluginIndex(store),
+      readFile: async (doc_id) => {
+        const meta = store.getDocMeta(doc_id);
+        const collection = meta && collections.get(meta.collection);
+        if (!meta || !collection) return null;
+        return readSourceText(join(collection.root, meta.file_path)).catch(() => null);
+      },
+      deadline: currentDeadline(),
+    };
+  }
+}
+
+/** The zod input shape for a plugin's params. */
+export function pluginInputSchema(params: Record<string, PluginParam> = {}): Record<string, z.ZodTypeAny> {
+  const shape: Record<string, z.ZodTypeAny> = {};
+  for (const [name, param] of Object.entries(params)) {
+    let schema: z.ZodTypeAny =
+      param.type === "number" ? z.number() : param.type === "boolean" ? z.boolean() : param.type === "string[]" ? z.array(z.string()) : z.string();
+    schema = schema.describe(param.description);
+    if (param.default !== undefined) schema = schema.default(param.default);
+    else if (param.optional) schema = schema.optional();
+    shape[name] = schema;
+  }
+  return shape;
+}
+
+/** Why `tool` cannot be registered, or null when it can. */
+export function pluginProblem(tool: any, taken: Set<string>): string | null {
+  if (!tool || typeof tool !== "object") return "expected a tool definition object";
+  if (typeof tool.name !== "string" || !NAME.test(tool.name)) {
+    return `invalid tool name ${JSON.stringify(tool.name)} (snake_case, starting with a letter)`;
+  }
+  if (taken.has(tool.name)) return `tool name "${tool.name}" is already taken`;
+  if (typeof tool.description !== "string" || !tool.description.trim()) return `${tool.name}: missing description`;
+  if (typeof tool.run !== "function") return `${tool.name}: run is not a function`;
+  for (const [param, spec] of Object.entries<any>(tool.params ?? {})) {
+    if (!spec || !PARAM_TYPES.has(spec.type)) return `${tool.name}: param "${param}" has an unknown type ${JSON.stringify(spec?.type)}`;
+    if (typeof spec.description !== "string") return `${tool.name}: param "${param}" needs a description`;
+  }
+  if (tool.annotations !== undefined && (typeof tool.annotations !== "object" || tool.annotations === null)) {
+    return `${tool.name}: annotations must be an object`;
+  }
+  for (const [hint, value] of Object.entries(tool.annotations ?? {})) {
+    if (!HINTS.has(hint)) return `${tool.name}: unknown annotation "${hint}"`;
+    if (typeof value !== "boolean") return `${tool.name}: annotation "${hint}" must be true or false`;
+  }
+  return null;
+}
+
+/**
+ * Import the PLUGINS modules and collect their tools. `reserved` holds
+ * the built-in tool names. Broken modules and tools are logged and
+ * skipped.
+ */
+export async function loadPlugins(
+  paths: string[],
+  config: IndexConfig,
+  options: { reserved: Iterable<string>; log?: (msg: string) => void }
+): Promise<PluginHost> {
+  const log = options.log ?? ((msg: string) => console.error(msg));
+  const taken = new Set(options.reserved);
+  const tools: PluginTool[] = [];
+
+  for (const path of paths) {
+    let exported: unknown;
+    try {
+      exported = (await import(resolve(path))).default;
+    } catch (err: any) {
+      log(`Warning: plugin ${path} failed to load: ${err.message}`);
+      continue;
+    }
+    const defined = Array.isArray(exported) ? exported : [exported];
+    for (const tool of defined) {
+      const problem = pluginProblem(tool, taken);
+      if (problem) {
+        log(`Warning: plugin ${path}: ${problem}; skipped`);
+        continue;
+      }
+      taken.add(tool.name);
+      tools.push(tool);
+    }
+  }
+
+  if (tools.length) log(`Plugins: ${tools.map((t) => t.name).join(", ")}`);
+  return new PluginHost(tools, config);
+}
@@ -0,0 +1,183 @@
+/**
+ * CPU and heap profiles of the running server
+ *
+ * A slow production server is diagnosed from a profile of the process
+ * that is slow, not from a reproduction. With ADMIN_PORT the server
+ * answers pprof-style endpoints on localhost:
+ *
+ *   GET /debug/pprof/                     what can be captured
+ *   GET /debug/pprof/profile?seconds=30   CPU profile (.cpuprofile)
+ *   GET /debug/pprof/heap?seconds=30      sampled allocations (.heapprofile)
+ *
+ * and with PROFILE_DIR the capture_profile tool records the same
+ * profiles to files there. Both are off unless configured.
+ *
+ * Profiles are recorded through the inspector protocol (node:inspector)
+ * and come out in the Chrome DevTools formats: open a .cpuprofile or
+ * .heapprofile in DevTools' Performance or Memory panel, or in
+ * speedscope. A heap profile samples the allocations made while it
+ * records, so a leak shows up as the call sites that kept allocating.
+ *
+ * Only one profile is recorded at a time, whichever way it was asked
+ * for; a second request fails instead of waiting.
+ */
+
+import { mkdir, writeFile } from "node:fs/promises";
+import { join, resolve } from "node:path";
+import { bearerAuthorized } from "./remote";
+
+export const PROFILE_KINDS = ["cpu", "heap"] as const;
+export type ProfileKind = (typeof PROFILE_KINDS)[number];
+
+export const DEFAULT_PROFILE_SECONDS = 30;
+
+/** Longest profile one request records. */
+export const MAX_PROFILE_SECONDS = 300;
+
+/** File extension DevTools expects for each kind. */
+const EXTENSIONS: Record<ProfileKind, string> = { cpu: ".cpuprofile", heap: ".heapprofile" };
+
+/** A profile already running, an unsupported runtime, or no PROFILE_DIR. */
+export class ProfilerError extends Error {}
+
+/** Records one profile; the default records through node:inspector. */
+export interface ProfileSource {
+  /** The profile of the next `seconds`, as JSON text. */
+  record(kind: ProfileKind, seconds: number): Promise<string>;
+}
+
+export interface CapturedProfile {
+  kind: ProfileKind;
+  seconds: number;
+  /** Absolute path of the written file */
+  path: string;
+  bytes: number;
+  started_at: string;
+}
+
+export class Profiler {
+  private running: ProfileKind | null = null;
+  readonly dir: string | null;
+
+  /** `dir` is PROFILE_DIR; without it, capture() fails and record() still works. */
+  constructor(dir?: string, private readonly source: ProfileSource = inspectorSource) {
+    this.dir = dir ? resolve(dir) : null;
+  }
+
+  /** The profile being recorded, if any. */
+  get busy(): ProfileKind | null {
+    return this.running;
+  }
+
+  /** Record a profile of the next `seconds` and return it. Throws ProfilerError. */
+  async record(kind: ProfileKind, seconds = DEFAULT_PROFILE_SECONDS): Promise<string> {
+    if (!Number.isFinite(seconds) || seconds <= 0 || seconds > MAX_PROFILE_SECONDS) {
+      throw new ProfilerError(`seconds must be between 1 and ${MAX_PROFILE_SECONDS}, got ${seconds}`);
+    }
+    if (this.running) throw new ProfilerError(`a ${this.running} profile is already being recorded; try again when it finishes`);
+    this.running = kind;
+    try {
+      return await this.source.record(kind, seconds);
+    } finally {
+      this.running = null;
+    }
+  }
+
+  /** Record a profile into PROFILE_DIR. Throws ProfilerError. */
+  async capture(kind: ProfileKind, seconds = DEFAULT_PROFILE_SECONDS): Promise<CapturedProfile> {
+    if (!this.dir) throw new ProfilerError("PROFILE_DIR is not set; profiles have nowhere to go");
+    const started = new Date();
+    const profile = await this.record(kind, seconds);
+    await mkdir(this.dir, { recursive: true });
+    // 2026-10-14T09-30-00-000Z: sortable, and valid on every filesystem
+    const stamp = started.toISOString().replace(/[:.]/g, "-");
+    const path = join(this.dir, `${kind}-${stamp}${EXTENSIONS[kind]}`);
+    await writeFile(path, profile);
+    return { kind, seconds, path, bytes: Buffer.byteLength(profile), started_at: started.toISOString() };
+  }
+}
+
+/** Records through an inspector session in this process. */
+const inspectorSource: ProfileSource = {
+  async record(kind, seconds) {
+    let Session: any;
+    try {
+      ({ Session } = await import("node:inspector"));
+    } catch {
+      throw new ProfilerError("profiling needs node:inspector, which this runtime does not provide");
+    }
+    const session = new Session();
+    const post = (method: string, params?: object) =>
+      new Promise<any>((resolve, reject) => session.post(method, params, (err: Error | null, result: any) => (err ? reject(err) : resolve(result))));
+    try {
+      session.connect();
+      const [domain, start, stop] =
+        kind === "cpu" ? ["Profiler", "start", "stop"] : ["HeapProfiler", "startSampling", "stopSampling"];
+      await post(`${domain}.enable`);
+      await post(`${domain}.${start}`);
+      await new Promise((done) => setTimeout(done, seconds * 1000));
+      const { profile } = await post(`${domain}.${stop}`);
+      return JSON.stringify(profile);
+    } catch (err: any) {
+      if (err instanceof ProfilerError) throw err;
+      throw new ProfilerError(`cannot record a ${kind} profile: ${err?.message ?? err}`);
+    } finally {
+      session.disconnect();
+    }
+  },
+};
+
+// ── Admin endpoints (ADMIN_PORT) ────────────────────────────────────
+
+/**
+ * Answer a request to the admin port. Requires `token` as a bearer
+ * token when set, like the MCP endpoint.
+ */
+export async function handleAdmin(req: Request, profiler: Profiler, options: { token?: string } = {}): Promise<Response> {
+  const url = new URL(req.url);
+  if (!url.pathname.startsWith("/debug/pprof")) return Response.json({ error: "not found" }, { status: 404 });
+  if (!bearerAuthorized(req, options.token)) {
+    return Response.json({ error: "missing or invalid bearer token" }, { status: 401, headers: { "WWW-Authenticate": "Bearer" } });
+  }
+  if (req.method !== "GET") return Response.json({ error: "method not allowed" }, { status: 405, headers: { Allow: "GET" } });
+
+  const endpoint = url.pathname.replace(/^\/debug\/pprof\/?/, "");
+  const kind: ProfileKind | null = endpoint === "profile" ? "cpu" : endpoint === "heap" ? "heap" : null;
+  if (endpoint === "") {
+    return Response.json({
+      profiles: {
+        "/debug/pprof/profile": `CPU profile (${EXTENSIONS.cpu}); ?seconds=N, default ${DEFAULT_PROFILE_SECONDS}`,
+        "/debug/pprof/heap": `Sampled allocations (${EXTENSIONS.heap}); ?seconds=N, default ${DEFAULT_PROFILE_SECONDS}`,
+      },
+      recording: profiler.busy,
+    });
+  }
+  if (!kind) return Response.json({ error: `unknown profile "${endpoint}"` }, { status: 404 });
+
+  const seconds = url.searchParams.has("seconds") ? Number(url.searchParams.get("seconds")) : DEFAULT_PROFILE_SECONDS;
+  try {
+    const profile = await profiler.record(kind, seconds);
+    return new Response(profile, {
+      headers: {
+        "Content-Type": "application/json",
+        "Content-Disposition": `attachment; filename="${kind}${EXTENSIONS[kind]}"`,
+      },
+    });
+  } catch (err) {
+    if (!(err instanceof ProfilerError)) throw err;
+    const status = /^seconds/.test(err.message) ? 400 : profiler.busy ? 409 : 503;
+    return Response.json({ error: err.message }, { status });
+  }
+}
+
+/** Serve the admin endpoints on localhost:`port`. */
+export function startAdminServer(profiler: Profiler, options: { port: number; token?: string }): { port: number; stop: () => void } {
+  const server = Bun.serve({
+    hostname: "127.0.0.1",
+    port: options.port,
+    // Profiles take as long as they were asked to
+    idleTimeout: 0,
+    fetch: (req) => handleAdmin(req, profiler, { token: options.token }),
+  });
+  return { port: server.port ?? options.port, stop: () => server.stop() };
+}
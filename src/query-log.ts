@@ -0,0 +1,217 @@
+/**
+ * Query log with relevance feedback — QUERY_LOG
+ *
+ * Ranking changes are guesses until they are checked against real
+ * use. With QUERY_LOG=<file> the server appends one JSON line per
+ * event to a local file, nothing else ever reads or sends it:
+ *
+ *   search    a search_documents, find_symbol, or multi_search query
+ *             and the results it returned, ranked
+ *   read      a result the client then opened: get_node_content,
+ *             navigate_tree, or an md-tree://doc/.../node/... resource
+ *             for a section, get_tree or md-tree://doc/... for a whole
+ *             document
+ *   feedback  the feedback tool: a result marked relevant or not, or
+ *             a section the search should have returned
+ *
+ * A read is credited to the latest search of the same session that
+ * returned the section (or, for a document, any section of it) within
+ * ATTRIBUTION_WINDOW_MS, and only once per search. Reads nothing led
+ * to are not logged. Sessions are the stdio connection, or an HTTP
+ * client's mcp-session-id; HTTP calls without one are each their own
+ * session, so only explicit feedback with a search_id links them.
+ *
+ * Searches are logged as the client saw them: after grouping,
+ * diversity caps, and reranking. A search at a git ref is not logged.
+ * Writes are appended in order in the background; a failed write is
+ * logged once and dropped, never failing the tool call.
+ */
+
+import { appendFile, mkdir } from "node:fs/promises";
+import { dirname, resolve } from "node:path";
+import type { SearchResult } from "./types";
+
+/** How long after a search a read still counts as following it */
+export const ATTRIBUTION_WINDOW_MS = 30 * 60 * 1000;
+
+/** Searches remembered per session for attribution, and across sessions for search_id */
+const RECENT_SEARCHES = 50;
+const RECENT_SEARCH_IDS = 1000;
+
+export interface LoggedHit {
+  doc_id: string;
+  node_id: string;
+  /** 1-based position in the results */
+  rank: number;
+  score: number;
+}
+
+export type QueryLogEntry =
+  | { type: "search"; id: string; at: string; tool: string; query: string; results: LoggedHit[] }
+  | { type: "read"; at: string; search_id: string; tool: string; doc_id: string; node_id: string | null; rank: number }
+  | {
+      type: "feedback";
+      at: string;
+      search_id: string;
+      doc_id: string;
+      node_id: string | null;
+      /** Position in that search's results; null when it did not return it */
+      rank: number | null;
+      relevant: boolean;
+      note?: string;
+    };
+
+export class QueryLogError extends Error {}
+
+interface RecentSearch {
+  id: string;
+  at: number;
+  /** doc_id::node_id → rank */
+  hits: Map<string, number>;
+  /** What has been credited to this search already */
+  read: Set<string>;
+}
+
+/** Appends searches, reads, and feedback to a JSON Lines file. */
+export class QueryLog {
+  readonly path: string;
+  private readonly now: () => number;
+  private readonly log: (msg: string) => void;
+  private recent = new WeakMap<object, RecentSearch[]>();
+  /** Every session's searches by id, for feedback that names one */
+  private byId = new Map<string, RecentSearch>();
+  private writing: Promise<void> = Promise.resolve();
+  private failed = false;
+  private seq = 0;
+
+  constructor(path: string, options: { now?: () => number; log?: (msg: string) => void } = {}) {
+    this.path = resolve(path);
+    this.now = options.now ?? Date.now;
+    this.log = options.log ?? ((msg: string) => console.error(msg));
+  }
+
+  /** Log a search `session` ran; returns its id. */
+  search(session: object, tool: string, query: string, results: SearchResult[]): string {
+    const at = this.now();
+    const id = `${at.toString(36)}-${(++this.seq).toString(36)}`;
+    const hits = results.map((r, i) => ({ doc_id: r.doc_id, node_id: r.node_id, rank: i + 1, score: round(r.score) }));
+    const entry: RecentSearch = { id, at, hits: new Map(hits.map((h) => [key(h.doc_id, h.node_id), h.rank])), read: new Set() };
+    const list = this.recent.get(session) ?? [];
+    list.push(entry);
+    if (list.length > RECENT_SEARCHES) this.byId.delete(list.shift()!.id);
+    this.recent.set(session, list);
+    this.byId.set(id, entry);
+    // HTTP calls without a session each leave one search behind
+    if (this.byId.size > RECENT_SEARCH_IDS) this.byId.delete(this.byId.keys().next().value!);
+    this.append({ type: "search", id, at: new Date(at).toISOString(), tool, query, results: hits });
+    return id;
+  }
+
+  /**
+   * Log that `session` opened sections of `doc_id` (the whole document
+   * when `node_ids` is empty), credited to the search that led there.
+   */
+  read(session: object, tool: string, doc_id: string, node_ids: string[] = []): void {
+    const at = this.now();
+    for (const node_id of node_ids.length ? node_ids : [null]) {
+      const found = this.leadingSearch(session, doc_id, node_id, at);
+      if (!found) continue;
+      const { search, rank } = found;
+      const credited = key(doc_id, node_id ?? "");
+      if (search.read.has(credited)) continue;
+      search.read.add(credited);
+      this.append({ type: "read", at: new Date(at).toISOString(), search_id: search.id, tool, doc_id, node_id, rank });
+    }
+  }
+
+  /**
+   * Log a relevance judgement on a section (or document) for the
+   * search `search_id`, by default the latest of `session` that
+   * returned it, else its latest search. Throws QueryLogError when
+   * there is no such search.
+   */
+  feedback(
+    session: object,
+    judgement: { search_id?: string; doc_id: string; node_id?: string; relevant: boolean; note?: string }
+  ): { search_id: string; rank: number | null } {
+    const { doc_id, relevant, note } = judgement;
+    const node_id = judgement.node_id ?? null;
+    let search: RecentSearch | undefined;
+    if (judgement.search_id) {
+      search = this.byId.get(judgement.search_id);
+      if (!search) throw new QueryLogError(`no recent search with id "${judgement.search_id}"`);
+    } else {
+      search = this.leadingSearch(session, doc_id, node_id, null)?.search ?? this.recent.get(session)?.at(-1);
+      if (!search) throw new QueryLogError("no search in this session to attach feedback to; pass search_id");
+    }
+    const rank = rankIn(search, doc_id, node_id);
+    this.append({
+      type: "feedback",
+      at: new Date(this.now()).toISOString(),
+      search_id: search.id,
+      doc_id,
+      node_id,
+      rank,
+      relevant,
+      ...(note ? { note } : {}),
+    });
+    return { search_id: search.id, rank };
+  }
+
+  /** Resolves once every entry logged so far is written. */
+  flush(): Promise<void> {
+    return this.writing;
+  }
+
+  /**
+   * The newest search of `session` that returned the section or
+   * document, within the window before `at` unless `at` is null.
+   */
+  private leadingSearch(
+    session: object,
+    doc_id: string,
+    node_id: string | null,
+    at: number | null
+  ): { search: RecentSearch; rank: number } | null {
+    const list = this.recent.get(session) ?? [];
+    for (let i = list.length - 1; i >= 0; i--) {
+      const search = list[i];
+      if (at !== null && at - search.at > ATTRIBUTION_WINDOW_MS) break;
+      const rank = rankIn(search, doc_id, node_id);
+      if (rank !== null) return { search, rank };
+    }
+    return null;
+  }
+
+  private append(entry: QueryLogEntry): void {
+    const line = JSON.stringify(entry) + "\n";
+    this.writing = this.writing
+      .then(async () => {
+        await mkdir(dirname(this.path), { recursive: true });
+        await appendFile(this.path, line);
+      })
+      .catch((err: any) => {
+        if (this.failed) return;
+        this.failed = true;
+        this.log(`Warning: QUERY_LOG ${this.path} could not be written, dropping entries: ${err.message}`);
+      });
+  }
+}
+
+function key(doc_id: string, node_id: string): string {
+  return `${doc_id}::${node_id}`;
+}
+
+/** Best rank of a section, or of any section of the document when node_id is null. */
+function rankIn(search: RecentSearch, doc_id: string, node_id: string | null): number | null {
+  if (node_id !== null) return search.hits.get(key(doc_id, node_id)) ?? null;
+  let best: number | null = null;
+  for (const [hit, rank] of search.hits) {
+    if (hit.startsWith(`${doc_id}::`) && (best === null || rank < best)) best = rank;
+  }
+  return best;
+}
+
+function round(score: number): number {
+  return Math.round(score * 1000) / 1000;
+}
@@ -0,0 +1,261 @@
+/**
+ * Query syntax for search — boolean operators and quoted phrases
+ *
+ * A plain query ("auth token refresh") is a bag of words ranked by
+ * BM25: any term may match. Once a query uses an operator it is parsed
+ * into a small AST instead, and only nodes that satisfy it are ranked:
+ *
+ *   connect AND NOT test      both operators spelled out
+ *   connect -test             the same, shorthand
+ *   +retry backoff            "+" marks a required term
+ *   (jwt OR oauth) refresh    parentheses group
+ *   "not connected" -retry    a phrase: its words adjacent and in order
+ *   Connect NEAR/5 ctx        both terms, at most 5 tokens apart
+ *
+ * Operators are the uppercase words AND, OR, NOT and NEAR/n, and a "+"
+ * or "-" directly before a term; lowercase "and"/"or"/"not" stay
+ * ordinary words. Adjacent terms are ANDed, as in GitHub code search.
+ * NOT binds tightest, then NEAR, then AND, then OR. A bare NEAR means
+ * NEAR/10. NEAR compares positions, so its operands must be terms or
+ * phrases; around a group it degrades to AND. A phrase in an otherwise
+ * plain query is required, while the loose words around it only rank.
+ *
+ * The AST is evaluated against the inverted index, phrases through its
+ * positional postings (see DocumentStore.searchDocuments); terms under
+ * a NOT filter results but never contribute to the score.
+ */
+
+export type QueryNode =
+  | { op: "term"; term: string }
+  | { op: "phrase"; phrase: string }
+  | { op: "and"; children: QueryNode[] }
+  | { op: "or"; children: QueryNode[] }
+  | { op: "not"; child: QueryNode }
+  | { op: "near"; distance: number; children: [Operand, Operand] };
+
+/** A query node with positions in the index: a term or a phrase. */
+export type Operand = Extract<QueryNode, { op: "term" | "phrase" }>;
+
+/** Looks up matches for the leaves of a query in an inverted index. */
+export interface QueryIndex {
+  /** Keys of nodes containing the term */
+  term(term: string): Set<string>;
+  /** Keys of nodes containing the phrase */
+  phrase(phrase: string): Set<string>;
+  /** Keys of nodes where the operands occur within `distance` tokens */
+  near(left: Operand, right: Operand, distance: number): Set<string>;
+}
+
+export const DEFAULT_NEAR_DISTANCE = 10;
+
+const NEAR = /^NEAR(?:\/(\d+))?$/;
+
+const OPERATOR_SYNTAX = /(^|\s)(AND|OR|NOT|NEAR(\/\d+)?)(?=\s|$)|(^|\s)[+-](?=[\p{L}\p{N}_(])/u;
+
+const QUOTED = /"[^"]*("|$)/g;
+
+/** A parsed query: the AST results must satisfy, and the text to rank by. */
+export interface ParsedQuery {
+  filter: QueryNode;
+  ranked: string;
+}
+
+/** True when `query` uses boolean operators rather than being a bag of words. */
+export function isBooleanQuery(query: string): boolean {
+  // A phrase stands in as one word, so `-"a b"` still reads as an operator
+  return OPERATOR_SYNTAX.test(query.replace(QUOTED, "phrase"));
+}
+
+interface Token {
+  text: string;
+  /** A quoted phrase; never an operator */
+  quoted: boolean;
+}
+
+function lex(query: string): Token[] {
+  const tokens: Token[] = [];
+  const push = (text: string, quoted = false) => tokens.push({ text, quoted });
+  let i = 0;
+  while (i < query.length) {
+    if (/\s/.test(query[i])) {
+      i++;
+      continue;
+    }
+    while (i < query.length && "(+-".includes(query[i])) push(query[i++]);
+    if (query[i] === '"') {
+      const close = query.indexOf('"', i + 1);
+      const end = close === -1 ? query.length : close;
+      const phrase = query.slice(i + 1, end).trim();
+      if (phrase) push(phrase, true);
+      i = end + 1;
+    } else {
+      let j = i;
+      while (j < query.length && !/\s/.test(query[j]) && query[j] !== '"') j++;
+      let word = query.slice(i, j);
+      i = j;
+      let closing = 0;
+      while (word.endsWith(")")) {
+        word = word.slice(0, -1);
+        closing++;
+      }
+      if (word) push(word);
+      for (let k = 0; k < closing; k++) push(")");
+    }
+    while (query[i] === ")") push(query[i++]);
+  }
+  return tokens;
+}
+
+/**
+ * Parse `query` into a filter AST and the text to rank by, or return
+ * null for a plain bag of words with no phrases.
+ */
+export function parseQuery(query: string): ParsedQuery | null {
+  const booleanQuery = parseBooleanQuery(query);
+  if (booleanQuery) return { filter: booleanQuery, ranked: positiveTerms(booleanQuery).join(" ") };
+
+  const phrases = lex(query).filter((t) => t.quoted).map((t): QueryNode => ({ op: "phrase", phrase: t.text }));
+  if (phrases.length === 0) return null;
+  return {
+    filter: phrases.length === 1 ? phrases[0] : { op: "and", children: phrases },
+    ranked: query.replace(/"/g, " "),
+  };
+}
+
+/**
+ * Parse a boolean query, or return null for a plain one. Malformed
+ * input degrades gracefully: a dangling operator or unbalanced
+ * parenthesis is dropped rather than rejected.
+ */
+export function parseBooleanQuery(query: string): QueryNode | null {
+  if (!isBooleanQuery(query)) return null;
+  const tokens = lex(query);
+  let pos = 0;
+
+  /** The operator at the cursor, or undefined for a term or phrase */
+  const peek = () => (tokens[pos] && !tokens[pos].quoted ? tokens[pos].text : undefined);
+
+  const parseOr = (): QueryNode | null => {
+    const children: QueryNode[] = [];
+    const first = parseAnd();
+    if (first) children.push(first);
+    while (peek() === "OR") {
+      pos++;
+      const next = parseAnd();
+      if (next) children.push(next);
+    }
+    if (children.length === 0) return null;
+    return children.length === 1 ? children[0] : { op: "or", children };
+  };
+
+  const parseAnd = (): QueryNode | null => {
+    const children: QueryNode[] = [];
+    while (pos < tokens.length && peek() !== "OR" && peek() !== ")") {
+      if (peek() === "AND") {
+        pos++;
+        continue;
+      }
+      const next = parseNear();
+      if (next) children.push(next);
+    }
+    if (children.length === 0) return null;
+    return children.length === 1 ? children[0] : { op: "and", children };
+  };
+
+  const parseNear = (): QueryNode | null => {
+    let left = parseUnary();
+    let match: RegExpMatchArray | null;
+    while ((match = (peek() ?? "").match(NEAR))) {
+      pos++;
+      const right = pos < tokens.length ? parseUnary() : null;
+      if (!right) break;
+      if (!left) {
+        left = right;
+        continue;
+      }
+      const distance = match[1] !== undefined ? parseInt(match[1]) : DEFAULT_NEAR_DISTANCE;
+      left = isOperand(left) && isOperand(right)
+        ? { op: "near", distance, children: [left, right] }
+        : { op: "and", children: [left, right] };
+    }
+    return left;
+  };
+
+  const parseUnary = (): QueryNode | null => {
+    const { text: token, quoted } = tokens[pos++];
+    if (quoted) return { op: "phrase", phrase: token };
+    if (token === "NOT" || token === "-") {
+      const child = pos < tokens.length ? parseUnary() : null;
+      return child ? { op: "not", child } : null;
+    }
+    if (token === "+") return pos < tokens.length ? parseUnary() : null;
+    if (token === "(") {
+      const inner = parseOr();
+      if (peek() === ")") pos++;
+      return inner;
+    }
+    return { op: "term", term: token };
+  };
+
+  let root = parseOr();
+  // Stray ")" at top level: skip it and keep parsing
+  while (pos < tokens.length) {
+    pos++;
+    const more = parseOr();
+    if (more) root = root ? { op: "and", children: [root, more] } : more;
+  }
+  return root;
+}
+
+function isOperand(node: QueryNode): node is Operand {
+  return node.op === "term" || node.op === "phrase";
+}
+
+/** Terms and phrases that can match a result, i.e. those not under a NOT. */
+export function positiveTerms(node: QueryNode): string[] {
+  switch (node.op) {
+    case "term":
+      return [node.term];
+    case "phrase":
+      return [node.phrase];
+    case "not":
+      return [];
+    default:
+      return (node.children as QueryNode[]).flatMap(positiveTerms);
+  }
+}
+
+/** The subset of `universe` that satisfies `node`. */
+export function evaluateQuery(node: QueryNode, universe: Set<string>, index: QueryIndex): Set<string> {
+  switch (node.op) {
+    case "term":
+    case "phrase":
+    case "near": {
+      const matches = node.op === "term"
+        ? index.term(node.term)
+        : node.op === "phrase"
+          ? index.phrase(node.phrase)
+          : index.near(node.children[0], node.children[1], node.distance);
+      return new Set([...matches].filter((key) => universe.has(key)));
+    }
+    case "not": {
+      const excluded = evaluateQuery(node.child, universe, index);
+      return new Set([...universe].filter((key) => !excluded.has(key)));
+    }
+    case "and": {
+      let result = universe;
+      for (const child of node.children) {
+        result = evaluateQuery(child, result, index);
+        if (result.size === 0) break;
+      }
+      return result;
+    }
+    case "or": {
+      const result = new Set<string>();
+      for (const child of node.children) {
+        for (const key of evaluateQuery(child, universe, index)) result.add(key);
+      }
+      return result;
+    }
+  }
+}
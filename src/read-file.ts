@@ -0,0 +1,150 @@
+/**
+ * Line ranges of indexed files — the read_file tool
+ *
+ * get_node_content returns whole sections, and an agent after twenty
+ * lines around a search hit would otherwise fetch the whole file and
+ * throw most of it away. read_file returns lines start_line..end_line
+ * of one indexed file as it is on disk now, capped at max_bytes. When
+ * the cap cuts the range short, the answer says so and gives the line
+ * to continue from, so a large file can be paged through. A single
+ * line longer than max_bytes (minified code, a generated blob) is
+ * paged through the same way, by byte: the answer gives the line again
+ * with next_start_byte, its UTF-8 bytes already returned. Each part
+ * holds at least one whole character, even one wider than max_bytes.
+ *
+ * Lines are counted as the index counts them (line breaks normalized,
+ * see encoding.ts), so the line_start / line_end of any result, or the
+ * #L fragment of its uri, can be passed straight in. Only indexed files
+ * are read; the path never leaves the collection roots.
+ */
+
+import { join } from "node:path";
+import type { CollectionConfig, DocumentMeta, IndexConfig } from "./types";
+import { readSourceText } from "./encoding";
+
+/** Bytes returned per call unless max_bytes says otherwise: 64 KiB. */
+export const DEFAULT_READ_BYTES = 64 * 1024;
+
+/** Largest max_bytes accepted: 1 MiB. */
+export const MAX_READ_BYTES = 1024 * 1024;
+
+/** Line ranges outside the file, and files that cannot be read. */
+export class ReadFileError extends Error {}
+
+export interface FileExcerpt {
+  doc_id: string;
+  file_path: string;
+  /** First and last line returned, 1-based and inclusive */
+  start_line: number;
+  end_line: number;
+  /** Lines in the file */
+  total_lines: number;
+  /** The lines, joined by "\n" */
+  content: string;
+  /** UTF-8 bytes of content */
+  bytes: number;
+  /** max_bytes cut the requested range short */
+  truncated: boolean;
+  /** With truncated: the start_line that continues where this stopped */
+  next_start_line?: number;
+  /** With cut_line: the start_byte to pass along with next_start_line */
+  next_start_byte?: number;
+  /** The first line alone was longer than max_bytes and is cut */
+  cut_line?: boolean;
+  /** The first line was read from this UTF-8 byte offset, not its start */
+  start_byte?: number;
+}
+
+export class FileReader {
+  constructor(private readonly config: IndexConfig) {}
+
+  /**
+   * Lines `start_line`..`end_line` of `doc`'s file (default: all of it),
+   * whole lines up to `max_bytes`, the first from its UTF-8 byte
+   * `start_byte` on. A first line longer than max_bytes is cut, and
+   * next_start_byte says where it goes on. Throws ReadFileError.
+   */
+  async read(
+    doc: DocumentMeta,
+    options: { start_line?: number; end_line?: number; max_bytes?: number; start_byte?: number } = {}
+  ): Promise<FileExcerpt> {
+    // Read per call: dependency collections are added after startup
+    const collection = this.collections().get(doc.collection);
+    if (!collection) throw new ReadFileError(`collection "${doc.collection}" of ${doc.file_path} is not configured`);
+    let text: string;
+    try {
+      text = await readSourceText(join(collection.root, doc.file_path));
+    } catch (err: any) {
+      throw new ReadFileError(`cannot read ${doc.file_path}: ${err.code === "ENOENT" ? "deleted since it was indexed" : err.message}`);
+    }
+
+    const lines = text.split("\n");
+    // A final line break ends the last line; it does not start another
+    if (lines.length > 1 && lines[lines.length - 1] === "") lines.pop();
+    const total = lines.length;
+    const start = options.start_line ?? 1;
+    if (start > total) throw new ReadFileError(`start_line ${start} is past the end of ${doc.file_path} (${total} lines)`);
+    const end = Math.min(options.end_line ?? total, total);
+    if (end < start) throw new ReadFileError(`end_line ${options.end_line} is before start_line ${start}`);
+
+    const startByte = options.start_byte ?? 0;
+    const first = Buffer.from(lines[start - 1]);
+    if (startByte > first.length) {
+      throw new ReadFileError(`start_byte ${startByte} is past the end of line ${start} (${first.length} bytes)`);
+    }
+    if (startByte < first.length && (first[startByte] & 0xc0) === 0x80) {
+      throw new ReadFileError(`start_byte ${startByte} falls inside a character of line ${start}`);
+    }
+    const head = first.subarray(startByte).toString("utf8");
+
+    const maxBytes = options.max_bytes ?? DEFAULT_READ_BYTES;
+    let bytes = 0;
+    let last = start - 1;
+    for (let i = start - 1; i < end; i++) {
+      // Each line after the first costs its "\n" too
+      const size = i === start - 1 ? first.length - startByte : Buffer.byteLength(lines[i]) + 1;
+      if (bytes + size > maxBytes) break;
+      bytes += size;
+      last = i + 1;
+    }
+
+    let content: string;
+    let cut = false;
+    if (last >= start) {
+      content = [head, ...lines.slice(start, last)].join("\n");
+    } else {
+      // Not even the rest of the first line fits: its next max_bytes, back to a character
+      // boundary, but always one whole character so paging moves on
+      let stop = startByte + maxBytes;
+      while ((first[stop] & 0xc0) === 0x80) stop--;
+      if (stop === startByte) do stop++; while ((first[stop] & 0xc0) === 0x80);
+      content = first.subarray(startByte, stop).toString("utf8");
+      bytes = stop - startByte;
+      last = start;
+      cut = true;
+    }
+    const truncated = cut || last < end;
+    return {
+      doc_id: doc.doc_id,
+      file_path: doc.file_path,
+      start_line: start,
+      end_line: last,
+      total_lines: total,
+      content,
+      bytes,
+      truncated,
+      // A cut line goes on within itself, from the byte after the last one returned
+      ...(cut ? { next_start_line: start, next_start_byte: startByte + bytes, cut_line: true } : {}),
+      ...(!cut && last < end ? { next_start_line: last + 1 } : {}),
+      ...(startByte > 0 ? { start_byte: startByte } : {}),
+    };
+  }
+
+  private collections(): Map<string, CollectionConfig> {
+    return new Map(
+      [...this.config.collections, ...(this.config.code_collections ?? []), ...(this.config.dependency_collections ?? [])].map(
+        (c) => [c.name, c]
+      )
+    );
+  }
+}
@@ -0,0 +1,172 @@
+/**
+ * Indexes of the collections as of a git ref — the `ref` parameter
+ *
+ * "How did this work in v1.4?" needs the old text, not today's. With
+ * `ref` set, the search and read tools answer from a separate store
+ * built from the git object store: `git ls-tree` lists the files at the
+ * commit, `git cat-file --batch` reads their blobs, and the same
+ * indexers as for the working tree turn them into documents. Nothing is
+ * checked out, and the working tree and the live index are untouched.
+ *
+ * doc_ids and node_ids are built the same way as for the working tree,
+ * so an ID from a live search reads the same document at the ref (when
+ * it existed there). A collection whose root is not in a repository, or
+ * where the ref does not resolve, is left out of the ref's store.
+ *
+ * Stores are keyed by the commits the ref resolves to, so "main" is
+ * re-indexed only after it moves. The last MAX_REF_STORES are kept.
+ */
+
+import { resolve } from "node:path";
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import { DocumentStore } from "./store";
+import { indexMarkdownContent } from "./indexer";
+import { CODE_GLOB, indexCodeContent, isCodeCandidate, isCodeFile } from "./code-indexer";
+import { detectExtension } from "./language-detect";
+import { decodeSource, type SourceText } from "./encoding";
+import { isIncluded } from "./coverage";
+import { spawnTimeout } from "./deadline";
+
+/** Ref stores kept in memory at once. */
+export const MAX_REF_STORES = 4;
+
+/** Bad or unknown refs. */
+export class RefIndexError extends Error {}
+
+export interface RefStore {
+  store: DocumentStore;
+  ref: string;
+  /** Commit per collection the ref resolved in */
+  commits: Record<string, string>;
+}
+
+function git(root: string, args: string[], stdin?: Buffer): Buffer | null {
+  let result;
+  try {
+    result = Bun.spawnSync(["git", "-C", root, "-c", "core.quotePath=false", ...args], {
+      stdout: "pipe",
+      stderr: "ignore",
+      ...(stdin ? { stdin } : {}),
+      ...spawnTimeout(),
+    });
+  } catch {
+    return null;
+  }
+  return result.success ? Buffer.from(result.stdout) : null;
+}
+
+/** Blobs under `root` at `commit`: path (relative to `root`) → object id. */
+function listBlobs(root: string, commit: string): Map<string, string> {
+  const blobs = new Map<string, string>();
+  const out = git(root, ["ls-tree", "-r", "-z", commit, "--", "."]);
+  if (!out) return blobs;
+  for (const entry of out.toString().split("\0")) {
+    const m = entry.match(/^\d+ blob ([0-9a-f]+)\t(.+)$/s);
+    if (m) blobs.set(m[2], m[1]);
+  }
+  return blobs;
+}
+
+/** Contents of `oids`, read in one `git cat-file --batch`. */
+function readBlobs(root: string, oids: string[]): Map<string, SourceText> {
+  const contents = new Map<string, SourceText>();
+  if (oids.length === 0) return contents;
+  const out = git(root, ["cat-file", "--batch"], Buffer.from(oids.join("\n") + "\n"));
+  if (!out) return contents;
+  // Each object: "<oid> blob <size>\n<size bytes>\n"
+  let pos = 0;
+  while (pos < out.length) {
+    const nl = out.indexOf(10, pos);
+    if (nl === -1) break;
+    const header = out.subarray(pos, nl).toString().split(" ");
+    pos = nl + 1;
+    if (header[1] === "missing") continue;
+    const size = parseInt(header[2], 10);
+    contents.set(header[0], decodeSource(out.subarray(pos, pos + size)));
+    pos += size + 1;
+  }
+  return contents;
+}
+
+/** Builds and caches stores of the configured collections at git refs. */
+export class RefIndex {
+  private stores = new Map<string, Promise<RefStore>>();
+
+  constructor(private readonly config: IndexConfig) {}
+
+  /**
+   * The store at `ref` (a commit, tag, or branch). Throws RefIndexError
+   * for malformed refs and refs no collection root knows.
+   */
+  async storeAt(ref: string): Promise<RefStore> {
+    if (!ref || ref.startsWith("-") || /[\s:]/.test(ref)) throw new RefIndexError(`Invalid ref "${ref}"`);
+
+    const collections: Array<[CollectionConfig, "docs" | "code"]> = [
+      ...this.config.collections.map((c): [CollectionConfig, "docs"] => [c, "docs"]),
+      ...(this.config.code_collections ?? []).map((c): [CollectionConfig, "code"] => [c, "code"]),
+    ];
+    const commits: Record<string, string> = {};
+    for (const [collection] of collections) {
+      const out = git(resolve(collection.root), ["rev-parse", "--verify", "--quiet", `${ref}^{commit}`]);
+      if (out) commits[collection.name] = out.toString().trim();
+    }
+    if (Object.keys(commits).length === 0) {
+      throw new RefIndexError(`Unknown ref "${ref}": it resolves in none of the collection roots`);
+    }
+
+    const key = Object.entries(commits).map(([name, sha]) => `${name}@${sha}`).sort().join(",");
+    let built = this.stores.get(key);
+    if (built) {
+      // Most recently used last
+      this.stores.delete(key);
+    } else {
+      built = this.build(ref, collections, commits);
+      built.catch(() => this.stores.delete(key));
+    }
+    this.stores.set(key, built);
+    while (this.stores.size > MAX_REF_STORES) this.stores.delete(this.stores.keys().next().value!);
+    return built;
+  }
+
+  /** Drop every ref store, so the next `ref` read rebuilds it; returns how many were kept. */
+  clear(): number {
+    const entries = this.stores.size;
+    this.stores.clear();
+    return entries;
+  }
+
+  private async build(
+    ref: string,
+    collections: Array<[CollectionConfig, "docs" | "code"]>,
+    commits: Record<string, string>
+  ): Promise<RefStore> {
+    const documents: IndexedDocument[] = [];
+    for (const [collection, type] of collections) {
+      const commit = commits[collection.name];
+      if (!commit) continue;
+      const root = resolve(collection.root);
+      const glob = new Bun.Glob(collection.glob_pattern || (type === "docs" ? "**/*.md" : CODE_GLOB));
+      const wanted = [...listBlobs(root, commit)].filter(
+        ([path]) =>
+          (type === "docs" ? glob.match(path) : isCodeCandidate(collection, glob, path)) && isIncluded(collection, path)
+      );
+      const contents = readBlobs(root, wanted.map(([, oid]) => oid));
+      const committed = git(root, ["show", "-s", "--format=%cI", commit])?.toString().trim() ?? "";
+      const lastModified = committed ? new Date(committed).toISOString() : new Date(0).toISOString();
+      for (const [path, oid] of wanted) {
+        const blob = contents.get(oid);
+        if (blob === undefined) continue;
+        if (type === "code" && !isCodeFile(path) && !detectExtension(path, blob.text)) continue;
+        documents.push(
+          type === "docs"
+            ? indexMarkdownContent(blob.text, path, collection.name, lastModified)
+            : indexCodeContent(blob.text, path, collection.name, lastModified, blob)
+        );
+      }
+    }
+    const store = new DocumentStore();
+    store.load(documents);
+    store.setCollectionWeights(Object.fromEntries(collections.map(([c]) => [c.name, c.weight])));
+    return { store, ref, commits };
+  }
+}
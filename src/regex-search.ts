@@ -0,0 +1,156 @@
+/**
+ * Regex and substring search over the indexed code — the regex_search tool
+ *
+ * Patterns are JavaScript regular expressions, matched line-anchored
+ * (^ and $ at line breaks, the m flag) against each file's text as read
+ * from disk, or with `literal` plain substrings. Before a file is read,
+ * its trigram Bloom filter (trigram-filter.ts) is asked whether it can
+ * contain a match at all, so a search for a rare identifier reads the
+ * handful of files that have it instead of the whole tree.
+ *
+ * Filters are built when a file is indexed. A file edited since then
+ * and not yet re-indexed (see WATCH and STALE_REFRESH) is judged by the
+ * filter of its indexed text, so a match the edit added can be missed
+ * until it is. Files indexed without a filter are always read.
+ *
+ * Regular expressions run without the u flag, so \p{...} is not
+ * available and the filters stay exact for case-insensitive matching.
+ * Matching is synchronous: a pathological pattern is only stopped
+ * between files, when the call's deadline has passed.
+ */
+
+import { join, resolve } from "node:path";
+import type { CaseMode, DocumentMeta, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { currentDeadline } from "./deadline";
+import { readSource } from "./encoding";
+import { decodeTrigramFilter, literalQuery, mayMatch, regexQuery } from "./trigram-filter";
+
+/** Files read per call at most; files the filters skip do not count. */
+export const MAX_REGEX_FILES = 5000;
+
+/** Longest matched text, and line text, returned per match. */
+const MAX_MATCH_TEXT = 500;
+
+/** Invalid patterns. */
+export class RegexSearchError extends Error {}
+
+export interface RegexMatch {
+  doc_id: string;
+  file_path: string;
+  /** 1-based line and column of the match start */
+  line: number;
+  column: number;
+  /** The line the match starts on; long lines are cut to a window around the match */
+  text: string;
+  /** The matched text, cut at MAX_MATCH_TEXT */
+  match: string;
+}
+
+export interface RegexSearchResult {
+  /** Files read and searched */
+  files: number;
+  /** Files the trigram filters ruled out without reading them */
+  skipped: number;
+  /** More files needed reading than one call reads */
+  truncated: boolean;
+  /** Matches found, including any beyond `limit` */
+  total: number;
+  matches: RegexMatch[];
+}
+
+export class RegexSearch {
+  private readonly roots: Map<string, string>;
+  // Decoded once per indexed version of a file; re-indexing replaces the meta
+  private readonly filters = new WeakMap<DocumentMeta, Uint8Array>();
+
+  constructor(config: IndexConfig) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /**
+   * Matches of `pattern` in the indexed code files at `path` (file,
+   * directory prefix, or glob; all code files when omitted). Returns
+   * null when no indexed code file matches the path.
+   */
+  async search(
+    store: DocumentStore,
+    pattern: string,
+    options: { path?: string; literal?: boolean; case?: CaseMode; limit?: number } = {}
+  ): Promise<RegexSearchResult | null> {
+    const mode = options.case ?? "sensitive";
+    const insensitive = mode === "insensitive" || (mode === "smart" && !/[A-Z]/.test(options.literal ? pattern : pattern.replace(/\\./g, "")));
+    const source = options.literal ? pattern.replace(/[.*+?^${}()|[\]\\]/g, "\\$&") : pattern;
+    let regex: RegExp;
+    try {
+      regex = new RegExp(source, insensitive ? "gim" : "gm");
+    } catch (err: any) {
+      throw new RegexSearchError(`invalid regular expression: ${err.message}`);
+    }
+    const query = options.literal ? literalQuery(pattern) : regexQuery(pattern);
+
+    const limit = options.limit ?? 50;
+    const docs = store.codeDocumentsAt(options.path ?? "**");
+    if (docs.length === 0) return null;
+
+    const result: RegexSearchResult = { files: 0, skipped: 0, truncated: false, total: 0, matches: [] };
+    const deadline = currentDeadline();
+    for (const doc of docs) {
+      if (deadline?.expired()) break;
+      const filter = this.filter(doc);
+      if (filter && !mayMatch(filter, query)) {
+        result.skipped++;
+        continue;
+      }
+      if (result.files >= MAX_REGEX_FILES) {
+        result.truncated = true;
+        break;
+      }
+      const root = this.roots.get(doc.collection);
+      const text = root ? ((await readSource(join(root, doc.file_path)).catch(() => null))?.text ?? null) : null;
+      if (text === null) continue;
+      result.files++;
+      this.scan(text, regex, doc, result, limit);
+    }
+    return result;
+  }
+
+  private filter(doc: DocumentMeta): Uint8Array | null {
+    if (!doc.trigram_filter) return null;
+    let filter = this.filters.get(doc);
+    if (!filter) {
+      filter = decodeTrigramFilter(doc.trigram_filter);
+      this.filters.set(doc, filter);
+    }
+    return filter;
+  }
+
+  private scan(text: string, regex: RegExp, doc: DocumentMeta, result: RegexSearchResult, limit: number): void {
+    regex.lastIndex = 0;
+    let line = 1;
+    let lineStart = 0;
+    for (let m = regex.exec(text); m !== null; m = regex.exec(text)) {
+      // An empty match would match again at the same place
+      if (m[0] === "") regex.lastIndex++;
+      result.total++;
+      if (result.matches.length >= limit) continue;
+      // Line breaks before lineStart are already counted
+      for (let i = text.indexOf("\n", lineStart); i !== -1 && i < m.index; i = text.indexOf("\n", i + 1)) {
+        line++;
+        lineStart = i + 1;
+      }
+      const end = text.indexOf("\n", m.index);
+      const lineText = text.slice(lineStart, end === -1 ? text.length : end).replace(/\r$/, "");
+      const column = m.index - lineStart + 1;
+      const from = lineText.length > MAX_MATCH_TEXT ? Math.max(0, column - 1 - 100) : 0;
+      result.matches.push({
+        doc_id: doc.doc_id,
+        file_path: doc.file_path,
+        line,
+        column,
+        text: lineText.slice(from, from + MAX_MATCH_TEXT),
+        match: m[0].slice(0, MAX_MATCH_TEXT),
+      });
+    }
+  }
+}
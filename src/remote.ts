@@ -0,0 +1,113 @@
+/**
+ * Remote index — one shared index host, thin local proxies
+ *
+ * Indexing a large monorepo on every laptop is slow and memory-hungry.
+ * Instead, a shared machine runs `serve:http` over the full tree, and
+ * each developer's MCP client launches `treenav-mcp proxy <url>`: a
+ * stdio server with no index of its own that forwards every request
+ * (tools, resources, completions) to the remote endpoint and relays the
+ * answer unchanged. The client sees exactly the remote tool list.
+ *
+ * The HTTP server is stateless, so the proxy needs no reconnect logic:
+ * every call is an independent POST, and a restarted host is picked up
+ * by the next call. Calls that fail in transit come back as tool errors
+ * rather than protocol errors, so the agent can report them. The proxy
+ * sends a fixed mcp-session-id, which keeps set_preferences defaults
+ * for the life of the proxy.
+ *
+ * With HTTP_TOKEN set, serve:http requires `Authorization: Bearer
+ * <token>` on its MCP endpoints; the proxy sends the same token.
+ */
+
+import { randomUUID, timingSafeEqual } from "node:crypto";
+import { Client } from "@modelcontextprotocol/sdk/client/index.js";
+import { StreamableHTTPClientTransport } from "@modelcontextprotocol/sdk/client/streamableHttp.js";
+import { Server } from "@modelcontextprotocol/sdk/server/index.js";
+import {
+  CallToolRequestSchema,
+  CompleteRequestSchema,
+  ListResourcesRequestSchema,
+  ListResourceTemplatesRequestSchema,
+  ListToolsRequestSchema,
+  ReadResourceRequestSchema,
+} from "@modelcontextprotocol/sdk/types.js";
+
+/** The remote endpoint could not be reached or rejected the proxy. */
+export class RemoteError extends Error {}
+
+export interface RemoteOptions {
+  /** MCP endpoint of serve:http, e.g. http://index-host:3100/mcp */
+  url: string;
+  /** Bearer token, when the server sets HTTP_TOKEN */
+  token?: string;
+}
+
+/** True when `req` carries the bearer `token`, or no token is required. */
+export function bearerAuthorized(req: Request, token: string | undefined): boolean {
+  return bearerMatches(req.headers.get("authorization") ?? undefined, token);
+}
+
+/** Compare an Authorization header value with `Bearer <token>` in constant time. */
+export function bearerMatches(header: string | undefined, token: string | undefined): boolean {
+  if (!token) return true;
+  const presented = Buffer.from(header ?? "");
+  const expected = Buffer.from(`Bearer ${token}`);
+  return presented.length === expected.length && timingSafeEqual(presented, expected);
+}
+
+/** Connect a client to the remote endpoint. Throws RemoteError. */
+export async function connectRemote(options: RemoteOptions): Promise<Client> {
+  let url: URL;
+  try {
+    url = new URL(options.url);
+  } catch {
+    throw new RemoteError(`Not a URL: "${options.url}"`);
+  }
+  const client = new Client({ name: "treenav-mcp-proxy", version: "1.0.0" });
+  const transport = new StreamableHTTPClientTransport(url, {
+    sessionId: randomUUID(),
+    requestInit: options.token ? { headers: { Authorization: `Bearer ${options.token}` } } : undefined,
+  });
+  try {
+    await client.connect(transport);
+  } catch (err: any) {
+    throw new RemoteError(`Cannot reach ${url.href}: ${err.message}`);
+  }
+  return client;
+}
+
+/**
+ * A server that answers every request by forwarding it to `remote`.
+ * It declares the remote's capabilities and instructions, so clients
+ * cannot tell it from the remote server.
+ */
+export function createProxyServer(remote: Client, url: string): Server {
+  const capabilities = remote.getServerCapabilities() ?? {};
+  const server = new Server(
+    { name: "treenav-mcp", version: "1.0.0" },
+    { capabilities, instructions: remote.getInstructions() }
+  );
+
+  if (capabilities.tools) {
+    server.setRequestHandler(ListToolsRequestSchema, (req) => remote.listTools(req.params));
+    server.setRequestHandler(CallToolRequestSchema, async (req) => {
+      try {
+        return await remote.callTool(req.params);
+      } catch (err: any) {
+        return {
+          content: [{ type: "text" as const, text: `Remote index at ${url} failed: ${err.message}` }],
+          isError: true,
+        };
+      }
+    });
+  }
+  if (capabilities.resources) {
+    server.setRequestHandler(ListResourcesRequestSchema, (req) => remote.listResources(req.params));
+    server.setRequestHandler(ListResourceTemplatesRequestSchema, (req) => remote.listResourceTemplates(req.params));
+    server.setRequestHandler(ReadResourceRequestSchema, (req) => remote.readResource(req.params));
+  }
+  if (capabilities.completions) {
+    server.setRequestHandler(CompleteRequestSchema, (req) => remote.complete(req.params));
+  }
+  return server;
+}
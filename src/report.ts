@@ -0,0 +1,352 @@
+/**
+ * Repository overview — the `treenav-mcp report` command
+ *
+ * The first questions about an unfamiliar repository are always the
+ * same: what is it written in, how is it laid out, where does it start,
+ * which packages matter, and how much of it is tested. The report
+ * answers them in one Markdown page, from the persisted index, so a
+ * person can read it and an agent can be handed it (or a file written
+ * with --out) as a primer instead of spending its first dozen calls on
+ * discovery.
+ *
+ *   Languages      code files and lines per language
+ *   Layout         top-level directories of each collection
+ *   Go modules     every go.mod, with its direct requirements
+ *   Entry points   mains, HTTP routes, gRPC services, CLI commands
+ *                  (entrypoints.ts); the one part read from disk
+ *   Packages       the largest by lines, and the most referenced: the
+ *                  sum of the lexical reference counts of their symbols
+ *                  (DocumentStore.referenceCount)
+ *   Tests          test files by path (test-paths.ts), the packages with
+ *                  and without them, and with COVERAGE_PROFILE the
+ *                  statements it covers
+ *
+ * A package is a directory. It has tests when a test file sits in it
+ * or a test file elsewhere is named for one of its files (pool.go and
+ * pool_test.go, store.ts and tests/store.test.ts, db.py and
+ * test_db.py). Generated files count toward languages and layout but
+ * not toward packages or tests.
+ */
+
+import { posix } from "node:path";
+import type { DocumentStore } from "./store";
+import type { DocumentMeta, IndexedDocument } from "./types";
+import type { Entrypoint, EntrypointKind } from "./entrypoints";
+import { ENTRYPOINT_KINDS } from "./entrypoints";
+import type { GoModule } from "./go-modules";
+import { isTestPath } from "./test-paths";
+
+/** Rows per table, and entry points per kind, by default. */
+export const DEFAULT_REPORT_TOP = 10;
+
+export interface LanguageStat {
+  language: string;
+  files: number;
+  lines: number;
+}
+
+export interface DirectoryStat {
+  collection: string;
+  /** "/"-separated, relative to the collection root; "" for its top level */
+  dir: string;
+  files: number;
+  lines: number;
+  /** Busiest first */
+  languages: string[];
+}
+
+export interface PackageStat {
+  collection: string;
+  dir: string;
+  files: number;
+  lines: number;
+  symbols: number;
+  /** Other files naming the package's symbols, summed over its symbols */
+  references: number;
+  /** Test files for the package */
+  tests: number;
+}
+
+export interface RepoReport {
+  generated_at: string;
+  collections: string[];
+  documents: number;
+  code_files: number;
+  code_lines: number;
+  languages: LanguageStat[];
+  layout: DirectoryStat[];
+  modules: Array<{ collection: string; dir: string; module: string; go?: string; requires: number }>;
+  entrypoints: Partial<Record<EntrypointKind, Entrypoint[]>>;
+  /** Entry points per kind before the cap */
+  entrypoint_counts: Partial<Record<EntrypointKind, number>>;
+  largest: PackageStat[];
+  most_referenced: PackageStat[];
+  tests: {
+    test_files: number;
+    packages: number;
+    tested_packages: number;
+    /** Largest packages without tests */
+    untested: PackageStat[];
+    /** From COVERAGE_PROFILE, when loaded */
+    statements?: { covered: number; total: number };
+  };
+}
+
+export interface ReportOptions {
+  /** Rows per table and entry points per kind (DEFAULT_REPORT_TOP) */
+  top?: number;
+  entrypoints?: Entrypoint[];
+  modules?: GoModule[];
+  now?: Date;
+}
+
+const isCode = (meta: DocumentMeta) => meta.facets.content_type?.includes("code") ?? false;
+
+/** Lines in the file, by its line offsets or its last node. */
+function lineCount(doc: IndexedDocument): number {
+  if (doc.meta.line_offsets) return doc.meta.line_offsets.length;
+  return doc.tree.reduce((max, n) => Math.max(max, n.line_end), 0);
+}
+
+/** A file name without its extension and test markers: store.test.ts and test_store.py are "store". */
+function testStem(filePath: string): string {
+  const name = posix.basename(filePath);
+  return name
+    .slice(0, name.indexOf(".") > 0 ? name.indexOf(".") : undefined)
+    .replace(/^(?:test_|mock_)/, "")
+    .replace(/(?:_test|_spec|_mock|Tests?|IT|Spec)$/, "")
+    .toLowerCase();
+}
+
+const byLines = <T extends { lines: number; files: number }>(a: T, b: T) => b.lines - a.lines || b.files - a.files;
+
+/** The overview of everything in `store`. */
+export function buildReport(store: DocumentStore, options: ReportOptions = {}): RepoReport {
+  const top = options.top ?? DEFAULT_REPORT_TOP;
+  const docs = store.exportDocuments();
+  const languages = new Map<string, LanguageStat>();
+  const layout = new Map<string, DirectoryStat & { counts: Map<string, number> }>();
+  const packages = new Map<string, PackageStat>();
+  const testFiles: DocumentMeta[] = [];
+  let codeFiles = 0;
+  let codeLines = 0;
+
+  for (const doc of docs) {
+    const meta = doc.meta;
+    const lines = lineCount(doc);
+    const language = isCode(meta) ? meta.facets.language?.[0] ?? "other" : "docs";
+    if (isCode(meta)) {
+      codeFiles++;
+      codeLines += lines;
+      const stat = languages.get(language) ?? { language, files: 0, lines: 0 };
+      stat.files++;
+      stat.lines += lines;
+      languages.set(language, stat);
+    }
+
+    const slash = meta.file_path.indexOf("/");
+    const topDir = slash === -1 ? "" : meta.file_path.slice(0, slash);
+    const dirKey = `${meta.collection}:${topDir}`;
+    const dir = layout.get(dirKey) ?? { collection: meta.collection, dir: topDir, files: 0, lines: 0, languages: [], counts: new Map() };
+    dir.files++;
+    dir.lines += lines;
+    dir.counts.set(language, (dir.counts.get(language) ?? 0) + 1);
+    layout.set(dirKey, dir);
+
+    if (!isCode(meta) || meta.generated) continue;
+    if (isTestPath(meta.file_path)) {
+      testFiles.push(meta);
+      continue;
+    }
+    const pkgDir = posix.dirname(meta.file_path).replace(/^\.$/, "");
+    const pkgKey = `${meta.collection}:${pkgDir}`;
+    const pkg = packages.get(pkgKey) ?? { collection: meta.collection, dir: pkgDir, files: 0, lines: 0, symbols: 0, references: 0, tests: 0 };
+    pkg.files++;
+    pkg.lines += lines;
+    for (const node of doc.tree) {
+      // Symbol nodes are titled "<kind> <name>"; see code-indexer.ts
+      if (!node.title.includes(" ")) continue;
+      pkg.symbols++;
+      pkg.references += store.referenceCount(meta.doc_id, node.node_id);
+    }
+    packages.set(pkgKey, pkg);
+  }
+
+  // Attach each test file to its own directory's package, else to the packages holding a file of its stem
+  const stems = new Map<string, PackageStat[]>();
+  for (const doc of docs) {
+    const meta = doc.meta;
+    if (!isCode(meta) || meta.generated || isTestPath(meta.file_path)) continue;
+    const pkg = packages.get(`${meta.collection}:${posix.dirname(meta.file_path).replace(/^\.$/, "")}`)!;
+    const key = `${meta.collection}:${testStem(meta.file_path)}`;
+    const list = stems.get(key) ?? [];
+    if (!list.includes(pkg)) list.push(pkg);
+    stems.set(key, list);
+  }
+  for (const meta of testFiles) {
+    const own = packages.get(`${meta.collection}:${posix.dirname(meta.file_path).replace(/^\.$/, "")}`);
+    for (const pkg of own ? [own] : stems.get(`${meta.collection}:${testStem(meta.file_path)}`) ?? []) pkg.tests++;
+  }
+
+  const entrypoints: RepoReport["entrypoints"] = {};
+  const entrypointCounts: RepoReport["entrypoint_counts"] = {};
+  for (const kind of ENTRYPOINT_KINDS) {
+    const found = (options.entrypoints ?? []).filter((e) => e.kind === kind);
+    if (found.length === 0) continue;
+    entrypoints[kind] = found.slice(0, top);
+    entrypointCounts[kind] = found.length;
+  }
+
+  const all = [...packages.values()];
+  const referenced = all.filter((p) => p.references > 0).sort((a, b) => b.references - a.references || byLines(a, b));
+  const statements = store.hasCoverage()
+    ? store.coveredNodes().reduce(
+        (sum, { coverage }) => ({ covered: sum.covered + coverage.covered, total: sum.total + coverage.statements }),
+        { covered: 0, total: 0 }
+      )
+    : undefined;
+
+  return {
+    generated_at: (options.now ?? new Date()).toISOString(),
+    collections: [...new Set(docs.map((d) => d.meta.collection))].sort(),
+    documents: docs.length,
+    code_files: codeFiles,
+    code_lines: codeLines,
+    languages: [...languages.values()].sort(byLines),
+    layout: [...layout.values()]
+      .sort((a, b) => a.collection.localeCompare(b.collection) || byLines(a, b))
+      .map(({ counts, ...dir }) => ({ ...dir, languages: [...counts].sort((a, b) => b[1] - a[1]).map(([l]) => l) })),
+    modules: (options.modules ?? [])
+      .map((m) => ({ collection: m.collection, dir: m.dir, module: m.module, go: m.go, requires: m.require.filter((r) => !r.indirect).length }))
+      .sort((a, b) => a.collection.localeCompare(b.collection) || a.dir.localeCompare(b.dir)),
+    entrypoints,
+    entrypoint_counts: entrypointCounts,
+    largest: [...all].sort(byLines).slice(0, top),
+    most_referenced: referenced.slice(0, top),
+    tests: {
+      test_files: testFiles.length,
+      packages: all.length,
+      tested_packages: all.filter((p) => p.tests > 0).length,
+      untested: all.filter((p) => p.tests === 0).sort(byLines).slice(0, top),
+      ...(statements ? { statements } : {}),
+    },
+  };
+}
+
+// ── Markdown ─────────────────────────────────────────────────────────
+
+const ENTRYPOINT_TITLES: Record<EntrypointKind, string> = {
+  main: "Programs",
+  http_route: "HTTP routes",
+  grpc_service: "gRPC services",
+  cli_command: "CLI commands",
+};
+
+const number = (n: number) => n.toLocaleString("en-US");
+const percent = (part: number, whole: number) => (whole === 0 ? "0%" : `${Math.round((part / whole) * 100)}%`);
+
+/** A table cell: pipes escaped, so paths and route patterns cannot break the row. */
+const cell = (text: string) => text.replace(/\|/g, "\\|");
+
+function table(header: string[], rows: string[][]): string[] {
+  return [`| ${header.join(" | ")} |`, `|${header.map(() => "---").join("|")}|`, ...rows.map((r) => `| ${r.map(cell).join(" | ")} |`)];
+}
+
+/** `report` as Markdown, headed by `title`. */
+export function formatReport(report: RepoReport, title: string = "Repository overview"): string {
+  const several = report.collections.length > 1;
+  const where = (collection: string, dir: string) => {
+    const path = dir === "" ? "(top level)" : `${dir}/`;
+    return several ? `${collection}: ${path}` : path;
+  };
+
+  const lines = [
+    `# ${title}`,
+    "",
+    `${number(report.code_files)} code files (${number(report.code_lines)} lines) and ` +
+      `${number(report.documents - report.code_files)} documents in ${report.collections.join(", ") || "no collections"}. ` +
+      `Generated ${report.generated_at} from the treenav index.`,
+  ];
+
+  if (report.languages.length > 0) {
+    lines.push("", "## Languages", "");
+    lines.push(
+      ...table(
+        ["Language", "Files", "Lines", "Share"],
+        report.languages.map((l) => [l.language, number(l.files), number(l.lines), percent(l.lines, report.code_lines)])
+      )
+    );
+  }
+
+  if (report.layout.length > 0) {
+    lines.push("", "## Layout", "");
+    lines.push(
+      ...table(
+        ["Directory", "Files", "Lines", "Languages"],
+        report.layout.map((d) => [where(d.collection, d.dir), number(d.files), number(d.lines), d.languages.slice(0, 3).join(", ")])
+      )
+    );
+  }
+
+  if (report.modules.length > 0) {
+    lines.push("", "## Go modules", "");
+    lines.push(
+      ...table(
+        ["Module", "Directory", "Go", "Direct requirements"],
+        report.modules.map((m) => [m.module, where(m.collection, m.dir), m.go ?? "", number(m.requires)])
+      )
+    );
+  }
+
+  const kinds = ENTRYPOINT_KINDS.filter((k) => report.entrypoints[k]);
+  if (kinds.length > 0) {
+    lines.push("", "## Entry points");
+    for (const kind of kinds) {
+      const found = report.entrypoints[kind]!;
+      lines.push("", `### ${ENTRYPOINT_TITLES[kind]}`, "");
+      for (const e of found) {
+        const handler = e.handler ? ` → \`${e.handler}\`` : "";
+        lines.push(`- \`${e.name}\`${handler} (${e.framework}) at \`${e.file_path}:${e.line}\``);
+      }
+      const more = report.entrypoint_counts[kind]! - found.length;
+      if (more > 0) lines.push(`- … and ${number(more)} more`);
+    }
+  }
+
+  if (report.largest.length > 0) {
+    lines.push("", "## Largest packages", "");
+    lines.push(
+      ...table(
+        ["Package", "Files", "Lines", "Symbols"],
+        report.largest.map((p) => [where(p.collection, p.dir), number(p.files), number(p.lines), number(p.symbols)])
+      )
+    );
+  }
+
+  if (report.most_referenced.length > 0) {
+    lines.push("", "## Most referenced packages", "");
+    lines.push(
+      ...table(
+        ["Package", "References", "Symbols", "Files"],
+        report.most_referenced.map((p) => [where(p.collection, p.dir), number(p.references), number(p.symbols), number(p.files)])
+      )
+    );
+  }
+
+  const t = report.tests;
+  if (t.packages > 0) {
+    lines.push("", "## Tests", "");
+    lines.push(
+      `${number(t.test_files)} test files. ${number(t.tested_packages)} of ${number(t.packages)} packages ` +
+        `(${percent(t.tested_packages, t.packages)}) have tests.` +
+        (t.statements
+          ? ` The coverage profile covers ${number(t.statements.covered)} of ${number(t.statements.total)} statements (${percent(t.statements.covered, t.statements.total)}).`
+          : "")
+    );
+    if (t.untested.length > 0) {
+      lines.push("", "Largest packages without tests:", "");
+      lines.push(...table(["Package", "Files", "Lines"], t.untested.map((p) => [where(p.collection, p.dir), number(p.files), number(p.lines)])));
+    }
+  }
+  return lines.join("\n") + "\n";
+}
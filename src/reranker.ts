@@ -0,0 +1,161 @@
+/**
+ * Language model reranking — RERANK_URL
+ *
+ * BM25 finds the sections that share words with the query; a
+ * cross-encoder reads the query and a candidate together and is much
+ * better at telling which of them actually answers it. With RERANK_URL
+ * set, search_documents sends its top RERANK_TOP_K candidates to a
+ * reranker endpoint and reorders them by the scores it returns. Only
+ * the query and each candidate's title and snippet leave the server,
+ * never whole sections or files.
+ *
+ * Two request formats, chosen by RERANK_FORMAT:
+ *
+ *   cohere  POST { model, query, documents: [text], top_n }
+ *           → { results: [{ index, relevance_score }] }
+ *           Cohere's /v2/rerank, and the many services that copy it
+ *           (Jina, Voyage, vLLM, LiteLLM)
+ *   tei     POST { query, texts: [text] } → [{ index, score }]
+ *           Hugging Face text-embeddings-inference /rerank, the usual
+ *           way to serve a local cross-encoder
+ *
+ * RERANK_API_KEY, when set, goes in an Authorization: Bearer header.
+ *
+ * Reranking is on the latency path of every search, so it is held to a
+ * budget: RERANK_TIMEOUT_MS, or what is left of the call's deadline if
+ * that is sooner. A slow, failing, or malformed answer keeps the
+ * keyword ranking, and the result says why (`rerank.status`), so a
+ * reranker outage degrades search instead of breaking it. Candidates
+ * the reranker gives no score keep their relative order after the
+ * scored ones, and results past the top k are left where they are.
+ * Scores are not rewritten; `score` stays the keyword score.
+ */
+
+import type { SearchResult } from "./types";
+import { currentDeadline } from "./deadline";
+
+export const RERANK_FORMATS = ["cohere", "tei"] as const;
+export type RerankFormat = (typeof RERANK_FORMATS)[number];
+
+export const DEFAULT_RERANK_FORMAT: RerankFormat = "cohere";
+export const DEFAULT_RERANK_TOP_K = 20;
+export const DEFAULT_RERANK_TIMEOUT_MS = 800;
+
+export interface RerankerOptions {
+  url: string;
+  format?: RerankFormat;
+  model?: string;
+  api_key?: string;
+  /** Candidates sent per search (default DEFAULT_RERANK_TOP_K) */
+  top_k?: number;
+  /** Latency budget per search, in ms (default DEFAULT_RERANK_TIMEOUT_MS) */
+  timeout_ms?: number;
+  log?: (msg: string) => void;
+  /** For tests; defaults to the global fetch */
+  fetch?: typeof fetch;
+}
+
+/** What the reranker did for one search. */
+export interface RerankStatus {
+  /** applied, or why the keyword ranking was kept */
+  status: "applied" | "timeout" | "error";
+  /** Candidates sent */
+  candidates: number;
+  ms: number;
+  /** For timeout and error */
+  reason?: string;
+}
+
+/** Reorders search candidates by a reranker endpoint's scores. */
+export class Reranker {
+  readonly topK: number;
+  private readonly timeoutMs: number;
+  private readonly format: RerankFormat;
+  private readonly fetch: typeof fetch;
+  private readonly log: (msg: string) => void;
+  private failures = 0;
+
+  constructor(private readonly options: RerankerOptions) {
+    this.topK = options.top_k ?? DEFAULT_RERANK_TOP_K;
+    this.timeoutMs = options.timeout_ms ?? DEFAULT_RERANK_TIMEOUT_MS;
+    this.format = options.format ?? DEFAULT_RERANK_FORMAT;
+    this.fetch = options.fetch ?? fetch;
+    this.log = options.log ?? ((msg: string) => console.error(msg));
+  }
+
+  /**
+   * `results` with the first topK reordered by the reranker, and what
+   * happened. On a timeout or error `results` come back unchanged.
+   */
+  async rerank(query: string, results: SearchResult[]): Promise<{ results: SearchResult[]; rerank: RerankStatus }> {
+    const head = results.slice(0, this.topK);
+    const started = Date.now();
+    const status = (s: RerankStatus["status"], reason?: string): RerankStatus => ({
+      status: s,
+      candidates: head.length,
+      ms: Date.now() - started,
+      ...(reason ? { reason } : {}),
+    });
+    if (head.length < 2) return { results, rerank: status("applied") };
+
+    const deadline = currentDeadline();
+    if (deadline?.expired()) return { results, rerank: status("timeout", "no time left in the call's deadline") };
+    const budget = Math.min(this.timeoutMs, deadline?.remaining() ?? Infinity);
+
+    let scores: Map<number, number>;
+    try {
+      const texts = head.map((r) => `${r.node_title}\n${r.snippet}`);
+      const res = await this.fetch(this.options.url, {
+        method: "POST",
+        headers: {
+          "content-type": "application/json",
+          ...(this.options.api_key ? { authorization: `Bearer ${this.options.api_key}` } : {}),
+        },
+        body: JSON.stringify(this.requestBody(query, texts)),
+        signal: AbortSignal.timeout(budget),
+      });
+      if (!res.ok) throw new Error(`HTTP ${res.status}`);
+      scores = parseScores(await res.json(), head.length);
+    } catch (err: any) {
+      const timedOut = err?.name === "TimeoutError" || err?.name === "AbortError";
+      const reason = timedOut ? `no answer within ${Math.round(budget)}ms` : err.message;
+      this.failures++;
+      this.log(`Warning: reranker ${timedOut ? "timed out" : "failed"} (${this.failures} so far), keeping keyword ranking: ${reason}`);
+      return { results, rerank: status(timedOut ? "timeout" : "error", reason) };
+    }
+
+    const order = head
+      .map((result, i) => ({ result, i, score: scores.get(i) }))
+      .sort((a, b) => {
+        if (a.score === undefined || b.score === undefined) {
+          return a.score === b.score ? a.i - b.i : a.score === undefined ? 1 : -1;
+        }
+        return b.score - a.score || a.i - b.i;
+      });
+    return { results: [...order.map((o) => o.result), ...results.slice(head.length)], rerank: status("applied") };
+  }
+
+  private requestBody(query: string, texts: string[]): Record<string, unknown> {
+    if (this.format === "tei") return { query, texts };
+    return { ...(this.options.model ? { model: this.options.model } : {}), query, documents: texts, top_n: texts.length };
+  }
+}
+
+/**
+ * Candidate index → score from either answer shape. Throws on anything
+ * else, so a misconfigured endpoint shows up as an error.
+ */
+export function parseScores(body: unknown, candidates: number): Map<number, number> {
+  const entries = Array.isArray(body) ? body : (body as { results?: unknown })?.results;
+  if (!Array.isArray(entries)) throw new Error("unexpected answer: no results array");
+  const scores = new Map<number, number>();
+  for (const entry of entries) {
+    const index = entry?.index;
+    const score = entry?.relevance_score ?? entry?.score;
+    if (!Number.isInteger(index) || index < 0 || index >= candidates || typeof score !== "number" || Number.isNaN(score)) {
+      throw new Error(`unexpected answer entry: ${JSON.stringify(entry)}`);
+    }
+    scores.set(index, score);
+  }
+  return scores;
+}
@@ -0,0 +1,142 @@
+/**
+ * REST/JSON facade — curl-friendly reads over serve:http
+ *
+ * With REST_API=1, serve:http also answers plain GET requests, for
+ * debugging from a shell and for integrations that will not speak MCP:
+ *
+ *   GET /search?q=<query>        search_documents; also limit, doc_id,
+ *                                path, case, word_boundaries, and
+ *                                filter=type=runbook,tags=auth
+ *   GET /symbol/<name>           find_symbol; also kind, language, path,
+ *                                limit, case, word_boundaries
+ *   GET /documents               list_documents; also q, tag, path,
+ *                                filter, limit, offset
+ *   GET /tree/<doc_id>           get_tree
+ *   GET /node/<doc_id>/<node_id> navigate_tree (the node and descendants)
+ *
+ * Responses are the JSON the gRPC API returns (grpc.ts runs the same
+ * handlers), and errors are `{ "error": "..." }` with 400, 401, 404,
+ * or 405. Path segments are URL-decoded, so ids with "/" or ":" work
+ * escaped or not. HTTP_TOKEN applies as for /mcp.
+ */
+
+import type { NavigationHandlers } from "./grpc";
+import { GRPC_STATUS, GrpcError } from "./grpc";
+import { bearerAuthorized } from "./remote";
+import { parseFilters } from "./cli";
+import { CASE_MODES } from "./types";
+
+const ROUTES = /^\/(search|symbol|documents|tree|node)(?:\/(.*))?$/;
+
+const HTTP_STATUS: Record<number, number> = {
+  [GRPC_STATUS.INVALID_ARGUMENT]: 400,
+  [GRPC_STATUS.UNAUTHENTICATED]: 401,
+  [GRPC_STATUS.NOT_FOUND]: 404,
+};
+
+function badRequest(message: string): never {
+  throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, message);
+}
+
+function count(params: URLSearchParams, name: string): number {
+  const raw = params.get(name);
+  if (raw === null || raw === "") return 0;
+  const n = Number(raw);
+  if (!Number.isInteger(n) || n < 0) badRequest(`${name} must be a non-negative integer`);
+  return n;
+}
+
+function caseMode(params: URLSearchParams): string {
+  const raw = params.get("case");
+  if (!raw) return "CASE_MODE_UNSPECIFIED";
+  if (!(CASE_MODES as string[]).includes(raw)) badRequest(`case must be one of ${CASE_MODES.join(", ")}`);
+  return `CASE_MODE_${raw.toUpperCase()}`;
+}
+
+function filters(params: URLSearchParams): Record<string, { values: string[] }> {
+  const parsed = parseFilters(params.getAll("filter").join(","));
+  return Object.fromEntries(Object.entries(parsed).map(([k, values]) => [k, { values }]));
+}
+
+function flag(params: URLSearchParams, name: string): boolean {
+  return ["1", "true", "yes", "on"].includes((params.get(name) ?? "").toLowerCase());
+}
+
+/**
+ * Answer `req` when it is a REST route, else return null so the caller
+ * can route it elsewhere.
+ */
+export async function handleRest(
+  req: Request,
+  handlers: NavigationHandlers,
+  options: { token?: string } = {}
+): Promise<Response | null> {
+  const url = new URL(req.url);
+  const match = url.pathname.match(ROUTES);
+  if (!match) return null;
+  const [, route, rest = ""] = match;
+
+  if (req.method !== "GET") {
+    return Response.json({ error: "only GET is supported" }, { status: 405, headers: { Allow: "GET" } });
+  }
+  if (!bearerAuthorized(req, options.token)) {
+    return Response.json({ error: "missing or invalid bearer token" }, { status: 401, headers: { "WWW-Authenticate": "Bearer" } });
+  }
+
+  const params = url.searchParams;
+  try {
+    const segments = rest ? rest.split("/").map(decodeURIComponent) : [];
+    switch (route) {
+      case "search":
+        return Response.json(
+          await handlers.SearchDocuments({
+            query: params.get("q") ?? "",
+            doc_id: params.get("doc_id") ?? "",
+            path_prefix: params.get("path") ?? "",
+            filters: filters(params),
+            limit: count(params, "limit"),
+            case_mode: caseMode(params),
+            word_boundaries: flag(params, "word_boundaries"),
+          })
+        );
+      case "symbol":
+        return Response.json(
+          await handlers.FindSymbol({
+            query: segments.join("/"),
+            kind: params.get("kind") ?? "",
+            languages: params.getAll("language").flatMap((l) => l.split(",")).filter(Boolean),
+            path_prefix: params.get("path") ?? "",
+            limit: count(params, "limit"),
+            case_mode: caseMode(params),
+            word_boundaries: flag(params, "word_boundaries"),
+          })
+        );
+      case "documents":
+        return Response.json(
+          await handlers.ListDocuments({
+            query: params.get("q") ?? "",
+            tag: params.get("tag") ?? "",
+            path_prefix: params.get("path") ?? "",
+            filters: filters(params),
+            limit: count(params, "limit"),
+            offset: count(params, "offset"),
+          })
+        );
+      case "tree":
+        return Response.json(await handlers.GetTree({ doc_id: segments.join("/") }));
+      case "node": {
+        // Both ids may contain "/"; a node id starts with "<doc_id>:"
+        const at = segments.findIndex((_, i) => i > 0 && segments.slice(i).join("/").startsWith(`${segments.slice(0, i).join("/")}:`));
+        if (at < 0) badRequest("expected /node/<doc_id>/<node_id>");
+        return Response.json(
+          await handlers.NavigateTree({ doc_id: segments.slice(0, at).join("/"), node_id: segments.slice(at).join("/") })
+        );
+      }
+    }
+  } catch (err) {
+    if (err instanceof URIError) return Response.json({ error: "malformed percent-encoding in path" }, { status: 400 });
+    if (!(err instanceof GrpcError)) throw err;
+    return Response.json({ error: err.message }, { status: HTTP_STATUS[err.code] ?? 500 });
+  }
+  return null;
+}
@@ -0,0 +1,102 @@
+/**
+ * Cached answers of the graph tools — RESULT_CACHE_SIZE
+ *
+ * usage_stats, find_references, callers, trace_errors, find_cycles, and
+ * panic_sites walk the references or imports of the whole code index, and an agent
+ * working through a change asks them the same question again and
+ * again: before an edit, after reading a file, when it re-plans. Their
+ * answers depend only on the arguments, the session focus, and the code
+ * files in the index, so ResultCache keeps them:
+ *
+ *   - The key is the tool, its arguments after defaults, and the focus
+ *     set with set_preferences.
+ *   - An entry remembers the index generation it was computed at. At
+ *     the same generation it is served as is.
+ *   - Once the index has moved on, the store's change journal
+ *     (changesSince) says which documents changed. If none is a code
+ *     file, the answer still holds and is carried forward to the
+ *     current generation; an edited README does not drop a call graph.
+ *     A changed code file, a load(), or changes older than the journal
+ *     drop the entry.
+ *
+ * Errors, answers cut short by the deadline, and answers computed while
+ * the store re-validates are not kept. Least recently used entries go
+ * first once there are RESULT_CACHE_SIZE; 0 turns caching off.
+ * Answers served from the cache carry `cached: true`.
+ */
+
+import type { DocumentMeta } from "./types";
+import type { DocumentStore } from "./store";
+
+export const DEFAULT_RESULT_CACHE_SIZE = 256;
+
+/** Tools whose answers are cached. */
+export const CACHED_TOOLS = new Set(["usage_stats", "find_references", "callers", "trace_errors", "find_cycles", "panic_sites"]);
+
+/** A tool result as the handlers return it. */
+export type CachedResult = { content: unknown[]; structuredContent?: Record<string, unknown>; isError?: boolean };
+
+interface Entry {
+  generation: number;
+  result: CachedResult;
+}
+
+/** The cache key of a call: tool, arguments in key order, and session focus. */
+export function resultKey(tool: string, args: Record<string, unknown> | undefined, focus?: string): string {
+  const sorted = Object.keys(args ?? {})
+    .sort()
+    .map((k) => [k, args![k]]);
+  return JSON.stringify([tool, sorted, focus ?? null]);
+}
+
+/** Whether a change to `doc` can change a cached answer. */
+function affects(doc: DocumentMeta): boolean {
+  return doc.facets.content_type?.includes("code") ?? false;
+}
+
+export class ResultCache {
+  private entries = new Map<string, Entry>();
+
+  constructor(
+    private readonly store: DocumentStore,
+    private readonly maxEntries: number = DEFAULT_RESULT_CACHE_SIZE
+  ) {}
+
+  /** The answer stored under `key`, if it still holds for the current index. */
+  get(key: string): CachedResult | undefined {
+    const entry = this.entries.get(key);
+    if (!entry) return undefined;
+    this.entries.delete(key);
+    const current = this.store.getGeneration();
+    if (entry.generation !== current) {
+      const changed = this.store.changesSince(entry.generation);
+      if (!changed || changed.some(affects)) return undefined;
+      entry.generation = current;
+    }
+    // Most recently used last
+    this.entries.set(key, entry);
+    return entry.result;
+  }
+
+  /**
+   * Keep `result` under `key`, as computed at `generation` (read before
+   * the call ran, so changes made meanwhile are checked on the next get).
+   */
+  set(key: string, result: CachedResult, generation: number): void {
+    if (this.maxEntries <= 0 || result.isError || this.store.isValidating()) return;
+    this.entries.delete(key);
+    this.entries.set(key, { generation, result });
+    while (this.entries.size > this.maxEntries) this.entries.delete(this.entries.keys().next().value!);
+  }
+
+  get size(): number {
+    return this.entries.size;
+  }
+
+  /** Drop every entry; returns how many there were. */
+  clear(): number {
+    const entries = this.entries.size;
+    this.entries.clear();
+    return entries;
+  }
+}
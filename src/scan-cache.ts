@@ -0,0 +1,83 @@
+/**
+ * Per-file scan results, cached by content hash
+ *
+ * The scanners behind list_entrypoints, config_usages, list_markers,
+ * find_log_source, the usage graph, and the rest read code files from the
+ * working tree rather than from the index, which keeps only chunks. A
+ * file is re-read and re-scanned only when the content hash the index
+ * holds for it changes; otherwise the last result is reused, so a
+ * repeated call costs one pass over the catalog.
+ *
+ * A scan that ends at the request deadline, or covers only a path
+ * prefix, keeps every entry; a full scan forgets the files that have
+ * left the index.
+ */
+
+import { join, resolve } from "node:path";
+import type { DocumentMeta, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { currentDeadline } from "./deadline";
+import { readSourceText } from "./encoding";
+
+/** Reads one file's source into the value cached for it. */
+export type FileScanner<T> = (source: string, meta: DocumentMeta, root: string) => T | Promise<T>;
+
+export interface FileScanOptions {
+  /** Only files under this path prefix */
+  pathPrefix?: string;
+  /** Only files whose path passes, e.g. by extension */
+  accept?: (filePath: string) => boolean;
+}
+
+export class FileScanCache<T> {
+  private readonly roots: Map<string, string>;
+  private entries = new Map<string, { hash: string; value: T }>();
+
+  constructor(config: IndexConfig, private readonly scanner: FileScanner<T>) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /** The absolute root of a code collection. */
+  root(collection: string): string | undefined {
+    return this.roots.get(collection);
+  }
+
+  /** One document's value; null when it is outside the code collections or cannot be read. */
+  async get(meta: DocumentMeta): Promise<T | null> {
+    const root = this.roots.get(meta.collection);
+    if (!root) return null;
+    const cached = this.entries.get(meta.doc_id);
+    if (cached && cached.hash === meta.content_hash) return cached.value;
+    const source = await readSourceText(join(root, meta.file_path)).catch(() => null);
+    if (source === null) return null;
+    const value = await this.scanner(source, meta, root);
+    this.entries.set(meta.doc_id, { hash: meta.content_hash, value });
+    return value;
+  }
+
+  /** The values of the store's code files, in catalog order, up to the request deadline. */
+  async scan(store: DocumentStore, options: FileScanOptions = {}): Promise<T[]> {
+    const docs = store.listDocuments({ filters: { content_type: "code" }, path_prefix: options.pathPrefix, limit: Infinity }).documents;
+    const values: T[] = [];
+    const live = new Set<string>();
+    const deadline = currentDeadline();
+    for (const meta of docs) {
+      if (deadline?.expired()) break;
+      if (!this.roots.has(meta.collection) || (options.accept && !options.accept(meta.file_path))) continue;
+      live.add(meta.doc_id);
+      const value = await this.get(meta);
+      if (value !== null) values.push(value);
+    }
+    if (!options.pathPrefix && !deadline?.expired()) {
+      for (const id of this.entries.keys()) if (!live.has(id)) this.entries.delete(id);
+    }
+    return values;
+  }
+
+  /** Drop every cached value; returns how many there were. */
+  clear(): number {
+    const entries = this.entries.size;
+    this.entries.clear();
+    return entries;
+  }
+}
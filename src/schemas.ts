@@ -0,0 +1,1472 @@
+/**
+ * Tool output schemas — the structured half of every tool result
+ *
+ * Each tool answers twice: a text block written for the model reading
+ * it (ranked snippets, inlined sections, hints about what to call
+ * next), and `structuredContent` that follows the schema declared here
+ * for programs. The schemas are advertised as each tool's outputSchema
+ * in tools/list, and the SDK validates every result against them, so
+ * automation never has to parse the prose.
+ *
+ * Every payload starts with the same envelope:
+ *
+ *   schema_version  SCHEMA_VERSION; bumped on any breaking change
+ *   status          "ok", or why there is nothing to return
+ *   message         human-readable note when status is not "ok"
+ *   timed_out       set when the deadline passed; results may be partial
+ *   cached          set when the answer came from the result cache
+ *
+ * Within a version, fields are only ever added, so clients should
+ * ignore keys they do not know. Removing, renaming, or retyping a field
+ * bumps SCHEMA_VERSION. docs/TOOL-SCHEMAS.md documents each tool.
+ */
+
+import { z } from "zod";
+
+export const SCHEMA_VERSION = 1;
+
+/** Why a tool had nothing to return; lookups that simply match nothing are "ok". */
+export type OutputStatus = "ok" | "not_found" | "not_indexed";
+
+const envelope = {
+  schema_version: z.literal(SCHEMA_VERSION).describe("Output schema version; bumped on breaking changes"),
+  status: z
+    .enum(["ok", "not_found", "not_indexed"])
+    .describe('"ok", "not_found" (unknown doc_id or node_id), or "not_indexed" (outside the INCLUDE patterns)'),
+  message: z.string().optional().describe("Explanation when status is not \"ok\""),
+  timed_out: z
+    .boolean()
+    .optional()
+    .describe("Set when the tool's deadline (TOOL_TIMEOUT_MS) passed while answering; results may be partial"),
+  cached: z
+    .boolean()
+    .optional()
+    .describe("Set when the answer came from the result cache: the same call was answered before, and no code file has changed since"),
+};
+
+const range = z.object({
+  start: z.number().describe("Start offset, UTF-16 code units"),
+  end: z.number().describe("End offset (exclusive), UTF-16 code units"),
+});
+
+const facets = z.record(z.array(z.string()));
+
+const preferences = z
+  .object({
+    languages: z.array(z.string()).optional(),
+    limit: z.number().optional(),
+    focus: z.string().optional(),
+    editor_url: z.string().optional(),
+  })
+  .describe("Session defaults that shaped this result (see set_preferences)");
+
+/** A position-anchored result, accepted back as `uri` input; see uris.ts. */
+const locationUri = z
+  .string()
+  .describe("treenav://file/<path>#L<start>-<end>; pass it as `uri` to get_node_content, navigate_tree, get_tree, usage_stats, callers, or breadcrumbs");
+
+/** With EDITOR_URL or set_preferences editor_url: the `uri` as an editor deep link; see editor-links.ts. */
+const editorUrl = z
+  .string()
+  .optional()
+  .describe('The location opened in an editor, e.g. vscode://file/home/me/repo/manager.go:42:1 (EDITOR_URL, set_preferences editor_url)');
+
+/** With LICENSE_TAGS: the license and origin of a result's file; see licenses.ts. */
+const provenance = z
+  .object({
+    license: z.string().optional().describe('SPDX identifier or expression; "unknown" for license text not recognized; absent when none was found'),
+    source: z.enum(["header", "file"]).optional().describe("A header in the file, or the nearest license file"),
+    license_files: z.array(z.string()).optional().describe("With source file: the license files, relative to the collection root"),
+    copyleft: z.enum(["weak", "strong", "network"]).optional(),
+    origin: z.enum(["project", "dependency", "vendored", "third_party"]),
+    module: z.string().optional().describe("With origin dependency: module@version"),
+  })
+  .optional()
+  .describe("License and provenance of the file");
+
+const searchHit = z.object({
+  doc_id: z.string(),
+  doc_title: z.string(),
+  file_path: z.string(),
+  node_id: z.string(),
+  node_title: z.string(),
+  level: z.number(),
+  score: z.number(),
+  snippet: z.string(),
+  snippet_highlights: z.array(range).describe("Matches within snippet"),
+  content_matches: z
+    .array(
+      range.extend({
+        line: z.number(),
+        column: z.number(),
+        byte_start: z.number().optional().describe("Start in the file's bytes on disk; set for code files transcoded from UTF-16 or Latin-1, or all code files with BYTE_OFFSETS"),
+        byte_end: z.number().optional().describe("End in the file's bytes on disk (exclusive)"),
+      })
+    )
+    .describe("Matches within the node's full content; 1-based line and column"),
+  line_start: z.number().describe("First line of the node in its file, or in its cell for notebooks"),
+  line_end: z.number().describe("Last line of the node"),
+  cell: z.number().optional().describe("Notebook cell (1-based) holding the node"),
+  uri: locationUri,
+  editor_url: editorUrl,
+  matched_terms: z.array(z.string()),
+  collection: z.string(),
+  facets,
+  stale: z
+    .enum(["modified", "deleted"])
+    .optional()
+    .describe("Set when the file changed since it was indexed; the hit may be out of date"),
+  provenance,
+  group: z
+    .object({
+      symbol: z.string().describe("The top-level function or type the matches sit in, or a Go receiver type"),
+      matches: z.number().describe("Matching nodes in it, this one included"),
+      node_ids: z.array(z.string()).describe("The other matching nodes, best first"),
+    })
+    .optional()
+    .describe("Present when other matches in the same symbol were folded into this result"),
+  comment_language: z
+    .string()
+    .optional()
+    .describe("Set when the hit matched in code comments whose language was detected: an ISO 639-1 code such as en, de, or zh"),
+  explain: z
+    .object({
+      terms: z.record(z.number()).describe("BM25 per matched indexed term; prefix expansions already discounted"),
+      bm25: z.number(),
+      prefix: z.number(),
+      proximity: z.number(),
+      all_terms: z.number(),
+      collection: z.number(),
+      path: z.number(),
+      recency: z.number(),
+      generated: z.number(),
+      popularity: z.number(),
+      coverage: z.number(),
+      before_rescore: z.number().optional(),
+    })
+    .optional()
+    .describe("With debug: (bm25 + prefix + proximity + all_terms) × the multipliers = score"),
+});
+
+/** Files re-indexed before answering because they were stale (STALE_REFRESH). */
+const refreshed = z.array(z.string()).optional().describe("Files changed since indexing that were re-indexed for this answer");
+
+const contentNode = z.object({
+  node_id: z.string(),
+  title: z.string(),
+  level: z.number(),
+  parent_id: z.string().nullable(),
+  children: z.array(z.string()),
+  content: z.string(),
+  word_count: z.number(),
+  line_start: z.number(),
+  line_end: z.number(),
+  cell: z.number().optional().describe("Notebook cell (1-based) holding the node"),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+});
+
+/** Set when the answer was read at a git ref, or a pinned generation, instead of the live index. */
+const atRef = {
+  ref: z.string().optional().describe("The git ref the answer was read at"),
+  generation: z.number().optional().describe("The index generation the session is pinned to (set_preferences snapshot)"),
+};
+
+const duplicateWarning = z.object({ doc_id: z.string(), overlap: z.number() }).optional();
+
+export const LIST_DOCUMENTS_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  total: z.number().describe("Documents matching the filters, across all pages"),
+  offset: z.number(),
+  documents: z.array(
+    z.object({
+      doc_id: z.string(),
+      title: z.string(),
+      description: z.string(),
+      file_path: z.string(),
+      collection: z.string(),
+      word_count: z.number(),
+      heading_count: z.number(),
+      last_modified: z.string(),
+      tags: z.array(z.string()),
+      references: z.array(z.string()),
+      facets,
+      generated: z
+        .enum(["generated", "minified", "lockfile"])
+        .optional()
+        .describe("Set for machine-written code files, which GENERATED_POLICY downranks or excludes from search"),
+    })
+  ),
+  pending_regions: z
+    .array(z.object({ collection: z.string(), path: z.string(), file_count: z.number() }))
+    .optional()
+    .describe("Lazy mode: regions not parsed yet, largest first"),
+  preferences,
+};
+
+export const SEARCH_DOCUMENTS_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  query: z.string(),
+  results: z.array(searchHit),
+  suggestions: z.array(z.string()).describe('"Did you mean" symbol names when nothing matched'),
+  rerank: z
+    .object({
+      status: z.enum(["applied", "timeout", "error"]).describe("applied, or why the keyword order was kept"),
+      candidates: z.number().describe("Top candidates sent to the reranker"),
+      ms: z.number(),
+      reason: z.string().optional(),
+    })
+    .optional()
+    .describe("With RERANK_URL: what the reranker did for this search"),
+  search_id: z.string().optional().describe("With QUERY_LOG: this search's id in the log, for feedback"),
+  refreshed,
+  validating: z.boolean().describe("True while a cached index is re-validated, or the rest of a priority warm-up is indexed; results may be stale or missing"),
+  preferences,
+};
+
+export const MULTI_SEARCH_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  groups: z
+    .array(
+      z.object({
+        query: z.string(),
+        results: z.array(searchHit),
+        suggestions: z.array(z.string()).describe('"Did you mean" symbol names when this query matched nothing'),
+        search_id: z.string().optional().describe("With QUERY_LOG: this query's id in the log, for feedback"),
+      })
+    )
+    .describe("One group per query, in request order"),
+  refreshed,
+  validating: z.boolean().describe("True while a cached index is re-validated, or the rest of a priority warm-up is indexed; results may be stale or missing"),
+  preferences,
+};
+
+export const GET_TREE_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  doc_id: z.string(),
+  title: z.string().optional(),
+  nodes: z.array(
+    z.object({
+      node_id: z.string(),
+      title: z.string(),
+      level: z.number(),
+      children: z.array(z.string()),
+      word_count: z.number(),
+      summary: z.string(),
+    })
+  ),
+};
+
+export const GET_NODE_CONTENT_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  doc_id: z.string(),
+  nodes: z.array(contentNode),
+  missing: z.array(z.string()).describe("Requested node IDs that do not exist in the document"),
+  provenance,
+};
+
+export const NAVIGATE_TREE_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  doc_id: z.string(),
+  node_id: z.string(),
+  nodes: z.array(contentNode).describe("The node first, then its descendants breadth-first"),
+  total_words: z.number(),
+  provenance,
+};
+
+export const FIND_SYMBOL_OUTPUT = {
+  ...SEARCH_DOCUMENTS_OUTPUT,
+  results: z.array(
+    searchHit.extend({
+      generator_input: z
+        .object({
+          generator: z.string().describe("protoc-gen-go, protoc-gen-go-grpc, mockgen, or stringer"),
+          file_path: z.string().describe("The input relative to the collection root, or as the generated header names it when not found"),
+          found: z.boolean(),
+          symbol: z.string().optional().describe("The declaration the symbol comes from: message User, rpc Get, interface Store, method Get, type Pill"),
+          line: z.number().optional(),
+          doc_id: z.string().optional().describe("When the input is indexed"),
+          uri: locationUri.optional(),
+          editor_url: editorUrl,
+        })
+        .optional()
+        .describe("For a symbol in generated code: the generator's input, an alternative definition to go to"),
+    })
+  ),
+  disambiguation: z
+    .object({
+      candidates: z.number().describe("Near-tied symbols offered to the user"),
+      action: z.enum(["accept", "decline", "cancel"]),
+      chosen: z.string().optional().describe("node_id the user picked; results holds only it"),
+    })
+    .optional()
+    .describe("Present when the user was asked to pick between equally plausible symbols"),
+  stdlib: z
+    .array(
+      z.object({
+        package: z.string().describe("Standard library import path"),
+        name: z.string(),
+        kind: z.enum(["constant", "variable", "function", "struct", "interface", "type", "method"]),
+        signature: z.string(),
+        doc: z.string().optional(),
+        file_path: z.string().describe("Absolute path under GOROOT/src"),
+        line: z.number(),
+      })
+    )
+    .optional()
+    .describe("Definitions in the Go standard library, for package-qualified names like sync.RWMutex"),
+};
+
+const goReplace = z.object({
+  old: z.string(),
+  old_version: z.string().optional(),
+  new: z.string().describe("Module path, or a directory when it starts with ./, ../, or /"),
+  new_version: z.string().optional(),
+});
+
+export const MODULE_INFO_OUTPUT = {
+  ...envelope,
+  modules: z.array(
+    z.object({
+      module: z.string(),
+      collection: z.string(),
+      dir: z.string().describe('Directory of go.mod relative to the collection root; "" at the root'),
+      go: z.string().optional(),
+      toolchain: z.string().optional(),
+      require: z.array(
+        z.object({
+          path: z.string(),
+          version: z.string(),
+          indirect: z.boolean(),
+          checksum: z.string().optional().describe("h1: hash from go.sum"),
+        })
+      ),
+      replace: z.array(goReplace),
+      exclude: z.array(z.object({ path: z.string(), version: z.string() })),
+    })
+  ),
+  workspaces: z.array(
+    z.object({
+      collection: z.string(),
+      dir: z.string(),
+      go: z.string().optional(),
+      toolchain: z.string().optional(),
+      use: z.array(z.string()),
+      modules: z.array(z.string()).describe("Module paths of the use directories"),
+      replace: z.array(goReplace),
+    })
+  ),
+  edges: z
+    .array(z.object({ from: z.string(), to: z.string(), via: z.enum(["require", "replace"]) }))
+    .describe("Dependencies between the repository's own modules"),
+  diagram: z.string().optional().describe('DOT or Mermaid source of the edges, when format is "dot" or "mermaid"'),
+};
+
+const goApiEntry = z.object({
+  name: z.string(),
+  signature: z.string(),
+  doc: z.string().optional().describe("First sentence of the doc comment"),
+  file: z.string(),
+  line: z.number(),
+});
+
+export const PACKAGE_API_OUTPUT = {
+  ...envelope,
+  package: z
+    .object({
+      name: z.string(),
+      import_path: z.string().optional(),
+      collection: z.string().describe('"GOROOT" for a standard library package'),
+      dir: z.string(),
+      doc: z.string().optional(),
+      files: z.array(z.string()),
+    })
+    .optional()
+    .describe("Absent when the package was not found"),
+  constants: z.array(goApiEntry),
+  variables: z.array(goApiEntry),
+  functions: z.array(goApiEntry).describe("Exported functions other than constructors"),
+  types: z.array(
+    goApiEntry.extend({
+      kind: z.enum(["struct", "interface", "type"]),
+      constructors: z.array(goApiEntry),
+      methods: z.array(goApiEntry),
+    })
+  ),
+};
+
+export const COVERAGE_FOR_OUTPUT = {
+  ...envelope,
+  profile: z.string().describe("Cover profile the numbers come from"),
+  totals: z.object({
+    functions: z.number(),
+    statements: z.number(),
+    covered: z.number(),
+    percent: z.number(),
+  }),
+  functions: z.array(
+    z.object({
+      doc_id: z.string(),
+      node_id: z.string(),
+      name: z.string(),
+      file_path: z.string(),
+      line_start: z.number(),
+      line_end: z.number(),
+      statements: z.number(),
+      covered: z.number(),
+      percent: z.number(),
+      status: z.enum(["covered", "partial", "uncovered"]),
+    })
+  ).describe("In file and line order; at most `limit`, while totals count every match"),
+};
+
+export const LIST_MARKERS_OUTPUT = {
+  ...envelope,
+  total: z.number().describe("Markers matching the filters, across all groups"),
+  groups: z
+    .array(z.object({ key: z.string(), count: z.number() }))
+    .describe("File paths or owners, with their marker counts"),
+  markers: z
+    .array(
+      z.object({
+        marker: z.string(),
+        tag: z.string().optional().describe("Owner named in the marker, as in TODO(alice)"),
+        text: z.string(),
+        doc_id: z.string(),
+        collection: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        owner: z.string().optional().describe("The tag, else the line's last author"),
+        author: z.string().optional(),
+        email: z.string().optional(),
+        changed_at: z.string().optional().describe("When the line last changed (ISO 8601), from git blame"),
+        age_days: z.number().optional(),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In group order; at most `limit`"),
+};
+
+export const FIND_DUPLICATES_OUTPUT = {
+  ...envelope,
+  units: z.number().describe("Functions and methods compared (at least min_tokens long)"),
+  total: z.number().describe("Clone groups found"),
+  groups: z
+    .array(
+      z.object({
+        similarity: z.number().describe("Lowest pairwise similarity in the group, 0-1"),
+        members: z.array(
+          z.object({
+            doc_id: z.string(),
+            node_id: z.string(),
+            collection: z.string(),
+            file_path: z.string(),
+            title: z.string(),
+            line_start: z.number(),
+            line_end: z.number(),
+            tokens: z.number(),
+            uri: locationUri.optional(),
+            editor_url: editorUrl,
+          })
+        ),
+      })
+    )
+    .describe("Largest duplicated code first; at most `limit`"),
+};
+
+export const TS_QUERY_OUTPUT = {
+  ...envelope,
+  files: z.number().describe("Files parsed"),
+  skipped: z.number().describe("Matching files without an installed grammar"),
+  truncated: z.boolean().describe("More files matched than one call parses; only the first were"),
+  total: z.number().describe("Captures found, including any beyond `limit`"),
+  captures: z
+    .array(
+      z.object({
+        capture: z.string().describe("Capture name without the @"),
+        node_type: z.string(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        start_line: z.number(),
+        start_column: z.number(),
+        end_line: z.number(),
+        end_column: z.number(),
+        text: z.string().describe("Source of the node, cut at 300 characters"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and match order; lines and columns are 1-based"),
+};
+
+export const STRUCTURAL_SEARCH_OUTPUT = {
+  ...envelope,
+  files: z.number().describe("Files searched"),
+  truncated: z.boolean().describe("More files matched the path than one call searches"),
+  total: z.number().describe("Matches found, including any beyond `limit`"),
+  matches: z
+    .array(
+      z.object({
+        doc_id: z.string(),
+        file_path: z.string(),
+        line_start: z.number(),
+        line_end: z.number(),
+        text: z.string(),
+        holes: z.record(z.string()).describe("Text bound to each named hole"),
+        replacement: z.string().optional().describe("The rewrite, when a template was given"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and position order"),
+};
+
+export const STRUCTURAL_REPLACE_OUTPUT = {
+  ...envelope,
+  written: z.boolean().describe("False for dry runs and when nothing matched"),
+  replacements: z.number(),
+  files: z
+    .array(z.object({ doc_id: z.string(), file_path: z.string(), replacements: z.number() }))
+    .describe("Files changed (or that would change), re-indexed after writing"),
+};
+
+export const REGEX_SEARCH_OUTPUT = {
+  ...envelope,
+  files: z.number().describe("Files read and searched"),
+  skipped: z.number().describe("Files the trigram filters ruled out without reading them"),
+  truncated: z.boolean().describe("More files needed reading than one call reads"),
+  total: z.number().describe("Matches found, including any beyond `limit`"),
+  matches: z
+    .array(
+      z.object({
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        column: z.number().describe("1-based, in UTF-16 code units"),
+        text: z.string().describe("The line the match starts on, cut to a window around the match when long"),
+        match: z.string().describe("The matched text, cut at 500 characters"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and position order"),
+};
+
+export const PEEK_DEFINITIONS_OUTPUT = {
+  ...envelope,
+  results: z
+    .array(
+      z.object({
+        name: z.string(),
+        definitions: z.array(
+          z.object({
+            doc_id: z.string(),
+            node_id: z.string(),
+            file_path: z.string(),
+            title: z.string().describe('Kind and name, e.g. "method Put"'),
+            line_start: z.number(),
+            line_end: z.number(),
+            signature: z.string(),
+            doc: z.string().optional().describe("The comment above the definition, or a Python docstring, markers stripped"),
+            excerpt: z.string().describe("The first body_lines lines of the definition"),
+            excerpt_truncated: z.boolean(),
+            uri: locationUri.optional(),
+            editor_url: editorUrl,
+          })
+        ),
+        more: z.number().describe("Definitions beyond per_name"),
+      })
+    )
+    .describe("One per distinct name, in the order asked"),
+  missing: z.array(z.string()).describe("Names with no definition"),
+};
+
+const buildTarget = z.object({
+  system: z.enum(["bazel", "make"]),
+  label: z.string().describe("//pkg:name, or <makefile>:<target>"),
+  name: z.string(),
+  rule: z.string().describe('The Bazel rule, e.g. "go_library"; "phony" or "rule" for Make'),
+  doc_id: z.string().describe("The build file's doc_id"),
+  collection: z.string(),
+  file_path: z.string().describe("The build file"),
+  line: z.number(),
+  sources: z.array(z.string()).describe("Source files named outright, relative to the collection root"),
+  globs: z.array(z.string()).describe("Source glob patterns, relative to the collection root"),
+  excludes: z.array(z.string()).optional().describe("Exclusions of the globs"),
+  deps: z.array(z.string()).describe("Labels (Bazel) or targets of the same makefile (Make)"),
+  via: z.string().optional().describe("With file: the source entry or glob that names it"),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+});
+
+export const BUILD_TARGETS_OUTPUT = {
+  ...envelope,
+  total: z.number().describe("Targets found, before the limit"),
+  targets: z.array(buildTarget).describe("In build file and line order"),
+  dependents: z
+    .array(z.object({ label: z.string(), rule: z.string(), file_path: z.string(), line: z.number() }))
+    .optional()
+    .describe("With target: targets that depend on it directly"),
+};
+
+const configSite = z.object({
+  use: z.enum(["read", "define"]),
+  api: z.string().describe("How the key is named: os.Getenv, viper.GetInt, flag.String, process.env, ..."),
+  doc_id: z.string(),
+  file_path: z.string(),
+  line: z.number(),
+  text: z.string(),
+  symbol: z.string().optional().describe('The indexed node around the line, e.g. "function main"'),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+});
+
+export const CONFIG_USAGES_OUTPUT = {
+  ...envelope,
+  total: z.number().describe("Keys found, before the limit"),
+  keys: z
+    .array(
+      z.object({
+        key: z.string(),
+        source: z.enum(["env", "flag", "config"]),
+        definition_count: z.number(),
+        read_count: z.number(),
+        definitions: z.array(configSite).describe("Flags declared, defaults set, variables exported or bound"),
+        reads: z.array(configSite),
+      })
+    )
+    .describe("Most definitions and reads first"),
+};
+
+export const FIND_LOG_SOURCE_OUTPUT = {
+  ...envelope,
+  total: z.number().describe("Matching sites, before the limit"),
+  matches: z
+    .array(
+      z.object({
+        kind: z.enum(["log", "error", "metric"]),
+        match: z
+          .enum(["template", "fragment", "metric"])
+          .describe("template: the text is an instance of it; fragment: the message contains the text; metric: by name"),
+        score: z.number().describe("Characters of the text the template's literal parts account for"),
+        api: z.string().describe("The emitting call: log.Printf, logger.error, prometheus.NewCounterVec, ..."),
+        level: z.string().optional().describe("trace, debug, info, warn, error, or fatal"),
+        template: z.string().describe("The message template, or the metric's full name"),
+        values: z.array(z.string()).optional().describe("What each placeholder stood for in the text, in order"),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        text: z.string(),
+        symbol: z.string().optional().describe('The indexed node around the line, e.g. "function dial"'),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("Best first: metrics, then templates explaining the most text, then fragments"),
+};
+
+export const READ_FILE_OUTPUT = {
+  ...envelope,
+  doc_id: z.string().optional(),
+  file_path: z.string(),
+  start_line: z.number().describe("First line returned, 1-based; 0 when nothing was read"),
+  end_line: z.number().describe("Last line returned, inclusive"),
+  total_lines: z.number().describe("Lines in the file"),
+  content: z.string().describe("The lines, joined by \"\\n\", without line numbers"),
+  bytes: z.number().describe("UTF-8 bytes of content"),
+  truncated: z.boolean().describe("max_bytes cut the requested lines short"),
+  next_start_line: z.number().optional().describe("With truncated: the start_line that continues where this stopped"),
+  next_start_byte: z.number().optional().describe("With cut_line: the start_byte to pass with next_start_line for the rest of the line"),
+  cut_line: z.boolean().optional().describe("The first line alone exceeded max_bytes and is cut"),
+  start_byte: z.number().optional().describe("The first line was read from this UTF-8 byte on, not its start"),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+  provenance,
+};
+
+export const CAPTURE_PROFILE_OUTPUT = {
+  ...envelope,
+  kind: z.enum(["cpu", "heap"]),
+  seconds: z.number().describe("How long the profile recorded"),
+  path: z.string().describe("Absolute path of the profile file in PROFILE_DIR"),
+  bytes: z.number(),
+  started_at: z.string().describe("ISO 8601 time recording started"),
+};
+
+export const AST_DIFF_OUTPUT = {
+  ...envelope,
+  file_path: z.string(),
+  collection: z.string().optional(),
+  base: z.string(),
+  head: z.string().describe('A ref, "content", or "working tree"'),
+  counts: z.object({
+    added: z.number(),
+    removed: z.number(),
+    renamed: z.number(),
+    signature_changed: z.number(),
+    body_changed: z.number(),
+  }),
+  changes: z
+    .array(
+      z.object({
+        change: z.enum(["added", "removed", "renamed", "signature_changed", "body_changed"]),
+        kind: z.string(),
+        name: z.string().describe("Qualified name, e.g. Server.Start"),
+        old_name: z.string().optional().describe("For renames"),
+        signature_before: z.string().optional(),
+        signature_after: z.string().optional(),
+        line_before: z.number().optional(),
+        line_after: z.number().optional(),
+        body_changed: z.boolean().optional().describe("On signature_changed, when the body changed too"),
+      })
+    )
+    .describe("In file order"),
+};
+
+const usageKinds = z.object({ call: z.number(), type_use: z.number(), embed: z.number(), value: z.number() });
+
+const usageDefinition = z.object({
+  name: z.string().describe("Qualified, e.g. Server.Start"),
+  kind: z.string(),
+  collection: z.string(),
+  doc_id: z.string(),
+  file_path: z.string(),
+  line: z.number(),
+  package: z.string(),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+});
+
+const usageSite = z.object({
+  name: z.string(),
+  kind: z.enum(["call", "type_use", "embed", "value"]),
+  collection: z.string(),
+  doc_id: z.string(),
+  file_path: z.string(),
+  line: z.number(),
+  package: z.string(),
+  text: z.string(),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+});
+
+export const USAGE_STATS_OUTPUT = {
+  ...envelope,
+  scope: z.enum(["symbol", "package"]),
+  target: z.string(),
+  definitions: z.array(usageDefinition),
+  files: z.number().describe("Code files scanned"),
+  total: z.number(),
+  by_kind: usageKinds,
+  consumers: z
+    .array(
+      z.object({
+        package: z.string().describe("Go import path, else the directory"),
+        collection: z.string(),
+        internal: z.boolean().describe("The consumer is a defining package"),
+        total: z.number(),
+        by_kind: usageKinds,
+        files: z.number(),
+      })
+    )
+    .describe("Busiest consumer first"),
+  symbols: z
+    .array(z.object({ name: z.string(), kind: z.string(), total: z.number(), by_kind: usageKinds, packages: z.number() }))
+    .optional()
+    .describe("Package scope: each symbol's references from other packages, busiest first"),
+  sites: z.array(usageSite).describe("The first `limit` references, in file and line order"),
+};
+
+export const FIND_REFERENCES_OUTPUT = {
+  ...envelope,
+  scope: z.enum(["symbol", "package"]),
+  target: z.string(),
+  definitions: z.array(usageDefinition),
+  files: z.number().describe("Code files scanned"),
+  total: z.number(),
+  cross_collection: z.number().describe("References from collections that define none of the targets"),
+  collections: z
+    .array(
+      z.object({
+        collection: z.string(),
+        external: z.boolean().describe("Defines none of the targets: its references cross in from another collection"),
+        total: z.number(),
+        by_kind: usageKinds,
+        files: z.number(),
+        sites: z.array(usageSite).describe("The first `limit` references here, in file and line order"),
+      })
+    )
+    .describe("Defining collections first, then the busiest"),
+};
+
+export const CALLERS_OUTPUT = {
+  ...envelope,
+  target: z.string(),
+  definitions: z.array(usageDefinition),
+  files: z.number().describe("Code files scanned"),
+  depth: z.number().describe("Deepest caller found; 1 is a direct caller"),
+  callers: z
+    .array(
+      z.object({
+        name: z.string().describe("Qualified, e.g. Server.Start"),
+        kind: z.string(),
+        collection: z.string(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        package: z.string(),
+        depth: z.number(),
+        calls: z.array(z.string()).describe("The target or callers one level nearer that this one calls"),
+        sites: z.array(z.object({ line: z.number(), text: z.string() })).describe("Its call sites of those"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("Nearest first, then by file and line"),
+  top_level: z
+    .array(z.object({ name: z.string(), file_path: z.string(), line: z.number(), text: z.string(), uri: locationUri.optional(), editor_url: editorUrl }))
+    .describe("Calls from outside any function; not followed further"),
+  truncated: z
+    .array(z.enum(["depth", "fan_out", "nodes", "deadline"]))
+    .describe("The limits that cut the closure short; empty when it is complete"),
+  diagram: z.string().optional().describe('The call graph as DOT or Mermaid source, for format "dot" or "mermaid"'),
+};
+
+export const TRACE_ERRORS_OUTPUT = {
+  ...envelope,
+  target: z.string(),
+  definitions: z.array(usageDefinition),
+  files: z.number().describe("Code files scanned"),
+  by_role: z.object({
+    created: z.number(),
+    wrapped: z.number(),
+    compared: z.number(),
+    returned: z.number(),
+    other: z.number(),
+  }),
+  sites: z
+    .array(
+      z.object({
+        role: z.enum(["created", "wrapped", "compared", "returned", "other"]),
+        name: z.string(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        package: z.string(),
+        text: z.string(),
+        function: z.string().optional().describe("The function it is in; absent at top level"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("Every use, in file and line order"),
+  origins: z.array(usageDefinition).describe("Functions that create, wrap, or return the error"),
+  chain: z
+    .array(
+      z.object({
+        name: z.string().describe("Qualified, e.g. Client.Send"),
+        kind: z.string(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        package: z.string(),
+        depth: z.number().describe("1 for a caller of an origin"),
+        calls: z.array(z.string()),
+        sites: z.array(z.object({ line: z.number(), text: z.string() })),
+        propagates: z.boolean().describe("Passes the error on to its own callers; only these are followed"),
+        wraps: z.boolean().describe("Wraps it on the way out"),
+        checks: z.boolean().describe("Tests for this error itself"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("Callers the error travels through, nearest first"),
+  truncated: z
+    .array(z.enum(["depth", "nodes", "deadline"]))
+    .describe("The limits that cut the chain short; empty when it is complete"),
+};
+
+export const PANIC_SITES_OUTPUT = {
+  ...envelope,
+  files: z.number().describe("Go files scanned"),
+  total: z.number().describe("Sites matching the filters, before the limit"),
+  by_kind: z.object({ panic: z.number(), fatal: z.number(), exit: z.number() }),
+  by_recover: z
+    .object({ local: z.number(), upstream: z.number(), partial: z.number(), none: z.number() })
+    .describe("Panics in a function by where they are recovered"),
+  sites: z
+    .array(
+      z.object({
+        kind: z.enum(["panic", "fatal", "exit"]),
+        call: z.string().describe("panic, log.Fatalf, os.Exit, ..."),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        package: z.string(),
+        text: z.string(),
+        function: z.string().optional().describe("The function it is in; absent at top level"),
+        recover: z
+          .enum(["local", "upstream", "partial", "none"])
+          .optional()
+          .describe("Panics only: recovered in the function, by every caller chain, by some, or nowhere found"),
+        recovered_by: z
+          .array(usageDefinition.extend({ depth: z.number().describe("1 for a direct caller") }))
+          .optional()
+          .describe("Callers that defer a recover, nearest first"),
+        escapes_via: z
+          .array(usageDefinition)
+          .optional()
+          .describe("Where the panic leaves unrecovered: callers nothing calls, or functions started with go"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and line order"),
+  truncated: z
+    .array(z.enum(["depth", "deadline"]))
+    .describe("The limits that cut the search for a recover short; empty when it is complete"),
+};
+
+export const API_DIFF_OUTPUT = {
+  ...envelope,
+  base: z.string().describe("The old side as resolved: the ref given, or the latest tag"),
+  head: z.string().describe('A ref, or "working tree"'),
+  packages: z.number().describe("Packages whose Go files changed, and so were compared"),
+  total: z.number().describe("Changes, before the limit"),
+  changes: z
+    .array(
+      z.object({
+        package: z.string().describe("Import path when a go.mod covers it, else the directory"),
+        dir: z.string(),
+        kind: z.enum(["package", "const", "var", "func", "type", "method", "field"]),
+        name: z.string().describe("Qualified for members: Server.Start, Config.Addr"),
+        change: z.enum(["added", "removed", "changed"]),
+        compat: z.enum(["breaking", "additive"]),
+        reason: z.string(),
+        before: z.string().optional().describe("Declaration on the old side"),
+        after: z.string().optional().describe("Declaration on the new side"),
+        file: z.string().optional().describe("On the new side; the old side for removals"),
+        line: z.number().optional(),
+      })
+    )
+    .describe("By package, breaking first, then by name"),
+  by_compat: z.object({ breaking: z.number(), additive: z.number() }),
+  bump: z.enum(["major", "minor", "patch"]).describe("major for breaking changes (minor from v0), minor for additive, else patch"),
+  next_version: z.string().optional().describe("When base is a semver tag"),
+  notes: z.array(z.string()),
+  truncated: z.boolean().describe("The deadline stopped it before every package was compared"),
+};
+
+export const REINDEX_PATH_OUTPUT = {
+  ...envelope,
+  reports: z
+    .array(
+      z.object({
+        collection: z.string(),
+        path: z.string().describe('Directory or file relative to the collection root; "" for all of it'),
+        checked: z.number().describe("Files found on disk"),
+        unchanged: z.number().describe("Files whose content hash matched the index"),
+        added: z.array(z.string()).describe("doc_ids"),
+        updated: z.array(z.string()).describe("doc_ids"),
+        removed: z.array(z.string()).describe("doc_ids whose files are gone"),
+        failed: z.array(z.string()).describe("doc_ids whose files could not be read or parsed"),
+        elapsed_ms: z.number(),
+      })
+    )
+    .describe("One per collection the path is in"),
+  generation: z.number().describe("Index generation after the re-index"),
+};
+
+export const CLEAR_CACHE_OUTPUT = {
+  ...envelope,
+  cleared: z.array(z.object({ name: z.string(), entries: z.number().describe("Entries dropped") })),
+};
+
+export const EVICT_FILE_OUTPUT = {
+  ...envelope,
+  evicted: z.array(z.object({ doc_id: z.string(), collection: z.string(), file_path: z.string() })),
+  missing: z.array(z.string()).describe("Targets that named no indexed document"),
+  generation: z.number().describe("Index generation after the eviction"),
+};
+
+export const FIELD_REFERENCES_OUTPUT = {
+  ...envelope,
+  target: z.string().describe("Type.Field as asked"),
+  types: z.array(
+    z.object({ name: z.string(), kind: z.string(), doc_id: z.string(), file_path: z.string(), line: z.number(), uri: locationUri.optional(), editor_url: editorUrl })
+  ),
+  declarations: z
+    .array(z.object({ doc_id: z.string(), file_path: z.string(), line: z.number(), text: z.string(), uri: locationUri.optional(), editor_url: editorUrl }))
+    .describe("Where the types declare the field; empty when it is promoted or set dynamically"),
+  files: z.number().describe("Code files scanned"),
+  total: z.number().describe("Sites matching the filters, before the limit"),
+  by_access: z
+    .object({ read: z.number(), write: z.number(), init: z.number(), address: z.number() })
+    .describe("Counts before the access filter"),
+  typed: z.number().describe("Sites whose receiver is known to hold the type"),
+  sites: z
+    .array(
+      z.object({
+        access: z.enum(["read", "write", "init", "address"]),
+        typed: z.boolean(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        text: z.string(),
+        function: z.string().optional().describe("The function it is in; absent at top level"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and line order"),
+};
+
+export const ENUM_USAGES_OUTPUT = {
+  ...envelope,
+  target: z.string().optional().describe("The type as asked; absent when listing every set"),
+  sets: z.array(
+    z.object({
+      name: z.string(),
+      language: z.string(),
+      doc_id: z.string(),
+      collection: z.string(),
+      file_path: z.string(),
+      line: z.number(),
+      package: z.string().describe("Directory of the declaring file"),
+      values: z.array(
+        z.object({
+          name: z.string(),
+          line: z.number(),
+          value: z.string().optional().describe("The expression after =, when declared with one"),
+          produced: z.number(),
+          consumed: z.number(),
+        })
+      ),
+      uri: locationUri.optional(),
+      editor_url: editorUrl,
+    })
+  ),
+  files: z.number().describe("Code files scanned"),
+  total: z.number().describe("References before the limit"),
+  sites: z
+    .array(
+      z.object({
+        set: z.string(),
+        value: z.string(),
+        role: z.enum(["produced", "consumed"]),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        text: z.string(),
+        function: z.string().optional().describe("The function it is in; absent at top level"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and line order"),
+  switches: z.array(
+    z.object({
+      set: z.string(),
+      doc_id: z.string(),
+      file_path: z.string(),
+      line: z.number(),
+      function: z.string().optional(),
+      covered: z.array(z.string()),
+      missing: z.array(z.string()),
+      has_default: z.boolean(),
+      incomplete: z.boolean().describe("Values are missing and no default catches them"),
+      uri: locationUri.optional(),
+      editor_url: editorUrl,
+    })
+  ),
+  incomplete: z.number().describe("Switches that are incomplete"),
+};
+
+export const LIST_EMBEDS_OUTPUT = {
+  ...envelope,
+  total: z.number().describe("Embeds matching the filters"),
+  files: z.number().describe("Distinct embedded files"),
+  packages: z.array(z.string()).optional().describe("binary: the package directories walked, the main package first"),
+  truncated: z.boolean().describe("The file listing stopped at its cap"),
+  embeds: z.array(
+    z.object({
+      variable: z.string(),
+      type: z.string().describe("string, []byte, or embed.FS"),
+      line: z.number().describe("Line of the variable"),
+      directive_line: z.number(),
+      patterns: z.array(z.string()),
+      doc_id: z.string(),
+      collection: z.string(),
+      file_path: z.string(),
+      package: z.string(),
+      file_count: z.number(),
+      files: z
+        .array(z.object({ path: z.string(), uri: locationUri.optional().describe("When the file is indexed"), editor_url: editorUrl }))
+        .describe("Matched files under the collection root, up to limit"),
+      missing: z.array(z.string()).describe("Patterns that match no file"),
+      uri: locationUri.optional(),
+      editor_url: editorUrl,
+    })
+  ),
+};
+
+export const FEEDBACK_OUTPUT = {
+  ...envelope,
+  recorded: z.boolean(),
+  search_id: z.string().describe("The search the judgement was attached to"),
+  doc_id: z.string(),
+  node_id: z.string().optional(),
+  rank: z.number().nullable().describe("Position in that search's results; null when it did not return the section"),
+  relevant: z.boolean(),
+};
+
+export const CONTEXT_AUDIT_OUTPUT = {
+  ...envelope,
+  files: z.number().describe("Go files scanned"),
+  functions: z.number().describe("Functions checked: those taking a context.Context"),
+  flagged: z.number().describe("Functions with at least one finding"),
+  by_kind: z
+    .object({ background: z.number(), dropped: z.number(), non_context_variant: z.number(), unused: z.number() })
+    .describe("Counts before the kind filter"),
+  total: z.number().describe("Findings matching kind, before the limit"),
+  findings: z
+    .array(
+      z.object({
+        kind: z.enum(["background", "dropped", "non_context_variant", "unused"]),
+        function: z.string(),
+        exported: z.boolean(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        text: z.string(),
+        callee: z.string().optional(),
+        passed: z.string().optional().describe("dropped: the first argument passed instead of the context"),
+        suggestion: z.string().optional().describe("non_context_variant: the call that takes a context"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and line order"),
+};
+
+const concurrencyCounts = z.object({
+  goroutine: z.number(),
+  make_chan: z.number(),
+  send: z.number(),
+  receive: z.number(),
+  close: z.number(),
+  select: z.number(),
+  lock: z.number(),
+  unlock: z.number(),
+  waitgroup: z.number(),
+  atomic: z.number(),
+});
+
+const lockPair = z.object({
+  name: z.string(),
+  mode: z.enum(["read", "write"]),
+  doc_id: z.string(),
+  file_path: z.string(),
+  function: z.string().optional(),
+  lock: z.number().optional().describe("Line of the lock; absent for an unlock without one"),
+  unlock: z.number().optional().describe("Line of the unlock; absent for a lock without one"),
+  deferred: z.boolean(),
+  uri: locationUri.optional(),
+  editor_url: editorUrl,
+});
+
+export const CONCURRENCY_MAP_OUTPUT = {
+  ...envelope,
+  files: z.number().describe("Go files scanned"),
+  packages: z
+    .array(
+      z.object({
+        package: z.string().describe("Directory of the files"),
+        files: z.number(),
+        by_kind: concurrencyCounts,
+        channels: z.array(
+          z.object({
+            name: z.string(),
+            type: z.string().optional(),
+            buffer: z.string().optional().describe("Buffer size from make; absent when unbuffered"),
+            make: z.number(),
+            send: z.number(),
+            receive: z.number(),
+            close: z.number(),
+          })
+        ),
+        mutexes: z.array(
+          z.object({ name: z.string(), lock: z.number(), unlock: z.number(), deferred: z.number(), unpaired: z.number() })
+        ),
+        unpaired: z.array(lockPair).describe("Locks and unlocks without a partner in their function"),
+      })
+    )
+    .describe("Packages with at least one site"),
+  total: z.number().describe("Sites matching kind, before the limit"),
+  sites: z
+    .array(
+      z.object({
+        kind: z.enum(["goroutine", "make_chan", "send", "receive", "close", "select", "lock", "unlock", "waitgroup", "atomic"]),
+        name: z.string(),
+        doc_id: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        text: z.string(),
+        function: z.string().optional(),
+        mode: z.enum(["read", "write"]).optional(),
+        deferred: z.boolean().optional(),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and line order"),
+};
+
+const entrypointCounts = z.object({
+  main: z.number(),
+  http_route: z.number(),
+  grpc_service: z.number(),
+  cli_command: z.number(),
+});
+
+export const LIST_ENTRYPOINTS_OUTPUT = {
+  ...envelope,
+  total: z.number().describe("Entry points matching the filters, before the limit"),
+  by_kind: entrypointCounts,
+  entrypoints: z
+    .array(
+      z.object({
+        kind: z.enum(["main", "http_route", "grpc_service", "cli_command"]),
+        framework: z.string().describe("go, net/http, chi, gin, flask, grpc, cobra, click, ..."),
+        name: z.string().describe('"main", "GET /users/{id}", the gRPC service, or the CLI command'),
+        method: z.string().optional(),
+        path: z.string().optional(),
+        handler: z.string().optional().describe("The function or type that answers, when the registration names one"),
+        doc_id: z.string(),
+        collection: z.string(),
+        file_path: z.string(),
+        line: z.number(),
+        text: z.string(),
+        symbol: z.string().optional().describe('The indexed node around the line, e.g. "function routes"'),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("In file and line order"),
+};
+
+export const HOTSPOTS_OUTPUT = {
+  ...envelope,
+  since: z.string().optional().describe("The history window; absent for all of history"),
+  files: z.number().describe("Code files considered"),
+  changed: z.number().describe("Of those, files with commits in the window"),
+  hotspots: z
+    .array(
+      z.object({
+        doc_id: z.string(),
+        collection: z.string(),
+        file_path: z.string(),
+        score: z.number().describe("commits × complexity"),
+        commits: z.number(),
+        lines_added: z.number(),
+        lines_deleted: z.number(),
+        authors: z.number(),
+        last_changed: z.number().describe("Epoch ms"),
+        complexity: z.number().describe("Approximate cyclomatic complexity of the file"),
+        functions: z.number(),
+        max_function: z.object({ name: z.string(), line: z.number(), complexity: z.number() }).optional(),
+      })
+    )
+    .describe("Highest score first"),
+};
+
+export const OWNERS_OF_OUTPUT = {
+  ...envelope,
+  entries: z
+    .array(
+      z.object({
+        target: z.string(),
+        status: z.enum(["owned", "unowned", "no_codeowners", "not_found"]),
+        collection: z.string().optional(),
+        file_path: z.string().optional(),
+        doc_id: z.string().optional(),
+        symbol: z.string().optional().describe("The symbol's node title, when the target was a symbol name"),
+        node_id: z.string().optional(),
+        owners: z.array(z.string()),
+        codeowners: z.string().optional().describe("The CODEOWNERS file, relative to the repository root"),
+        rule: z.object({ pattern: z.string(), line: z.number() }).optional().describe("The last matching line"),
+      })
+    )
+    .describe("One per resolved file, in request order; a symbol can resolve to several"),
+  by_owner: z
+    .array(z.object({ owner: z.string(), files: z.array(z.string()) }))
+    .describe("Files per owner, for review routing"),
+};
+
+const packageEdge = z.object({
+  from: z.string(),
+  to: z.string(),
+  type_only: z.boolean().describe("Every import site is type-only"),
+  sites: z.array(z.object({ file_path: z.string(), line: z.number(), type_only: z.boolean() })),
+});
+
+export const FIND_CYCLES_OUTPUT = {
+  ...envelope,
+  packages: z.number().describe("Packages (directories of code) in the graph"),
+  edges: z.number().describe("Import edges between them"),
+  cycles: z
+    .array(
+      z.object({
+        kind: z.enum(["cycle", "near_cycle"]).describe("near_cycle: closes only through type-only imports"),
+        collection: z.string(),
+        packages: z.array(z.string()).describe("Go import paths, else directories"),
+        edges: z.array(packageEdge).describe("Edges inside the cycle's component"),
+        cut: z.array(packageEdge).describe("Removing these breaks every cycle in the component; none is redundant"),
+        path: z.array(z.string()).describe("A shortest cycle, first package repeated at the end"),
+      })
+    )
+    .describe("Cycles first, then near-cycles; larger components first"),
+  diagram: z.string().optional().describe('The cycles as DOT or Mermaid source, for format "dot" or "mermaid"'),
+};
+
+export const BREADCRUMBS_OUTPUT = {
+  ...envelope,
+  doc_id: z.string(),
+  collection: z.string(),
+  file_path: z.string(),
+  line: z.number(),
+  column: z.number().optional(),
+  cell: z.number().optional().describe("Notebook cell the line is in"),
+  uri: locationUri,
+  editor_url: editorUrl,
+  trail: z.string().describe('The scopes joined with " › ", outermost first'),
+  scopes: z
+    .array(
+      z.object({
+        kind: z.enum(["package", "namespace", "module", "directory", "symbol", "cell", "section", "block"]),
+        symbol_kind: z.string().optional().describe("class, method, function, ... for symbol scopes"),
+        name: z.string().describe("Import path, module, symbol name, heading, or the block's header line"),
+        line_start: z.number().optional(),
+        line_end: z.number().optional(),
+        node_id: z.string().optional().describe("For symbol, cell, and section scopes"),
+        uri: locationUri.optional(),
+        editor_url: editorUrl,
+      })
+    )
+    .describe("Outermost first: package, then symbols or sections, then control blocks"),
+};
+
+export const STEP_SYMBOL_OUTPUT = {
+  ...envelope,
+  ...atRef,
+  doc_id: z.string(),
+  direction: z.enum(["next", "previous"]),
+  scope: z.enum(["siblings", "file"]),
+  from: z
+    .object({
+      node_id: z.string(),
+      title: z.string(),
+      line_start: z.number(),
+      line_end: z.number(),
+      cell: z.number().optional(),
+      uri: locationUri.optional(),
+      editor_url: editorUrl,
+    })
+    .nullable()
+    .describe("The symbol stepped from; null when starting from a line between symbols or from the whole file"),
+  symbols: z.array(contentNode).describe("Nearest first: in file order for next_symbol, reversed for previous_symbol"),
+  more: z.boolean().describe("More symbols lie beyond `count`; step again from the last one"),
+};
+
+export const SET_PREFERENCES_OUTPUT = {
+  ...envelope,
+  preferences,
+  snapshot: z
+    .object({
+      generation: z.number().describe("The generation reads are pinned to"),
+      current: z.number().describe("The live index's generation"),
+    })
+    .optional()
+    .describe("Set while the session is pinned"),
+  warning: z.string().optional().describe("Set when the focus lies outside the indexed set"),
+};
+
+/** Every plugin tool (PLUGINS); the plugin's own fields are under `data` */
+export const PLUGIN_TOOL_OUTPUT = {
+  ...envelope,
+  plugin: z.string().describe("Name of the plugin tool that answered"),
+  data: z.record(z.unknown()).optional().describe("Structured result from the plugin, shaped as it documents"),
+};
+
+export const FIND_SIMILAR_OUTPUT = {
+  ...envelope,
+  matches: z.array(
+    z.object({
+      node_id: z.string(),
+      doc_id: z.string(),
+      path: z.string(),
+      title: z.string(),
+      score: z.number(),
+      overlap: z.number().describe("Fraction of unique query terms found in the node, 0..1"),
+      snippet: z.string(),
+    })
+  ),
+  tokens_analyzed: z.number(),
+  suggest_merge: z.boolean(),
+};
+
+export const DRAFT_WIKI_ENTRY_OUTPUT = {
+  ...envelope,
+  suggested_path: z.string(),
+  frontmatter: z.object({
+    title: z.string(),
+    description: z.string().optional(),
+    type: z.string().optional(),
+    category: z.string().optional(),
+    tags: z.array(z.string()),
+    source_url: z.string().optional(),
+    captured_at: z.string(),
+  }),
+  backlinks: z.array(
+    z.object({
+      node_id: z.string(),
+      doc_id: z.string(),
+      title: z.string(),
+      score: z.number(),
+      reason: z.enum(["bm25", "shared_tag", "shared_category"]),
+    })
+  ),
+  glossary_hits: z.array(z.string()),
+  duplicate_warning: duplicateWarning,
+};
+
+export const WRITE_WIKI_ENTRY_OUTPUT = {
+  ...envelope,
+  written: z.boolean(),
+  path: z.string(),
+  absolute_path: z.string(),
+  doc_id: z.string().optional(),
+  root_node_id: z.string().optional(),
+  bytes: z.number(),
+  reindex_ms: z.number(),
+  duplicate_warning: duplicateWarning,
+  validation: z.object({
+    frontmatter_ok: z.boolean(),
+    reserved_keys_ok: z.boolean(),
+    path_ok: z.boolean(),
+  }),
+};
+
+/** The envelope fields for a payload with the given status. */
+export function envelopeFor(status: OutputStatus = "ok", message?: string) {
+  return message === undefined
+    ? { schema_version: SCHEMA_VERSION, status }
+    : { schema_version: SCHEMA_VERSION, status, message };
+}
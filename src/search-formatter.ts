@@ -8,11 +8,57 @@ export interface SubtreeProvider {
   ): { nodes: Pick<TreeNode, "node_id" | "title" | "level" | "content">[] } | null;
   resolveRef(path: string): { doc_id: string; node_id?: string } | null;
   getDocMeta(doc_id: string): DocumentMeta | null;
+  /** True while a warm-started index is still being re-validated. */
+  isValidating?(): boolean;
+  /** Symbol names close to a query that found nothing. */
+  suggest?(query: string): string[];
 }
 
 /** Number of top results for which full subtree content is inlined. */
 const INLINE_CONTENT_TOP_N = 3;
 
+/** Shown on results served while a cached index is re-validated, or a priority warm-up finishes. */
+export const VALIDATING_NOTICE =
+  "Note: index is validating — files are still being re-checked or indexed; some results may be stale or missing.";
+
+/**
+ * Shown above results whose files changed since indexing, or that were
+ * re-indexed for this answer (STALE_REFRESH); "" when neither.
+ */
+export function formatStaleNotice(
+  stale: Array<{ file_path: string; reason: string }>,
+  refreshed: Array<{ file_path: string }>
+): string {
+  const notes: string[] = [];
+  if (stale.length > 0) {
+    const files = stale.map((f) => `${f.file_path} (${f.reason})`).join(", ");
+    notes.push(`Note: ${stale.length} file(s) changed since indexing; their results may be out of date: ${files}.`);
+  }
+  if (refreshed.length > 0) {
+    notes.push(`Note: re-indexed ${refreshed.length} file(s) changed since indexing: ${refreshed.map((f) => f.file_path).join(", ")}.`);
+  }
+  return notes.map((n) => `${n}\n\n`).join("");
+}
+
+/** Max match locations listed per result */
+const MATCH_LOCATIONS_SHOWN = 5;
+
+/**
+ * "Matches: path:line:col" locations for a code result, whose content
+ * is verbatim source; "" for markdown, whose sections are re-rendered.
+ * Notebook locations name the cell: path#cell3:line:col.
+ */
+export function buildMatchLine(r: SearchResult): string {
+  const verbatim = r.facets.content_type?.some((t) => t === "code" || t === "notebook");
+  if (!verbatim || r.content_matches.length === 0) return "";
+  const file = r.cell ? `${r.file_path}#cell${r.cell}` : r.file_path;
+  const shown = r.content_matches
+    .slice(0, MATCH_LOCATIONS_SHOWN)
+    .map((m) => `${file}:${r.line_start + m.line - 1}:${m.column}`);
+  const more = r.content_matches.length - shown.length;
+  return `\n   Matches: ${shown.join(", ")}${more > 0 ? ` (+${more} more)` : ""}`;
+}
+
 /**
  * Format search results for agent consumption.
  *
@@ -31,16 +77,11 @@ export function formatSearchResults(
   query: string
 ): string {
   if (results.length === 0) {
-    return `No results found for "${query}". Try broader terms or use list_documents to browse the catalog.`;
+    return `No results found for "${query}".${didYouMean(store, query)} Try broader terms or use list_documents to browse the catalog.`;
   }
 
   // 1. Ranked snippet list
-  const summary = results
-    .map((r, i) => {
-      const badge = buildFacetBadge(r.facets);
-      return `${i + 1}. [${r.doc_id}] ${r.doc_title}\n   Section: ${r.node_title} (${r.node_id})\n   Score: ${r.score.toFixed(1)}${badge}\n   Snippet: ${r.snippet}`;
-    })
-    .join("\n\n");
+  const summary = results.map(formatRankedResult).join("\n\n");
 
   // 2. Full content blocks for top N
   const contentBlocks = results
@@ -75,6 +116,10 @@ export function formatSearchResults(
     `Search results for "${query}" (${results.length} matches):\n\n${summary}`,
   ];
 
+  if (store.isValidating?.()) {
+    parts.unshift(VALIDATING_NOTICE);
+  }
+
   if (contentBlocks.length > 0) {
     const n = Math.min(results.length, INLINE_CONTENT_TOP_N);
     parts.push(
@@ -85,6 +130,81 @@ export function formatSearchResults(
   return parts.join("\n");
 }
 
+/**
+ * Format multi_search results: one ranked snippet list per query, in
+ * request order. Unlike formatSearchResults no content is inlined — a
+ * batch answers "where is each of these", and inlining sections for
+ * every query would swamp the context. Follow up with get_node_content.
+ */
+export function formatBatchResults(
+  groups: { query: string; results: SearchResult[] }[],
+  store: SubtreeProvider
+): string {
+  const sections = groups.map(({ query, results }, i) => {
+    const heading = `━━━ ${i + 1}/${groups.length}: "${query}" (${results.length} matches) ━━━`;
+    const body =
+      results.length === 0
+        ? `No results.${didYouMean(store, query)}`
+        : results.map(formatRankedResult).join("\n\n");
+    return `${heading}\n\n${body}`;
+  });
+
+  if (store.isValidating?.()) sections.unshift(VALIDATING_NOTICE);
+  sections.push("Use get_node_content(doc_id, [node_id]) to read any of these sections.");
+  return sections.join("\n\n");
+}
+
+function formatRankedResult(r: SearchResult, i: number): string {
+  const badge = buildFacetBadge(r.facets);
+  return `${i + 1}. [${r.doc_id}] ${r.doc_title}\n   Section: ${r.node_title} (${r.node_id})\n   URI: ${r.uri}\n   Score: ${r.score.toFixed(1)}${badge}${buildExplainLine(r)}\n   Snippet: ${r.snippet}${buildMatchLine(r)}${buildGroupLine(r)}`;
+}
+
+/** Multipliers of a ScoreBreakdown, as labelled in the explain line */
+const EXPLAIN_FACTORS = [
+  ["collection", "collection"],
+  ["path", "path"],
+  ["recency", "recency"],
+  ["generated", "generated"],
+  ["popularity", "popularity"],
+  ["coverage", "coverage"],
+] as const;
+
+/**
+ * "Explain: (bm25 4.20 + proximity 2.00) × path 1.20 = 7.44" and the
+ * per-term scores, for a result searched with `explain`, or "".
+ * Multipliers of 1 are left out.
+ */
+export function buildExplainLine(r: SearchResult): string {
+  const e = r.explain;
+  if (!e) return "";
+  const sum = [
+    `bm25 ${e.bm25.toFixed(2)}`,
+    e.prefix ? `prefix ${e.prefix.toFixed(2)}` : "",
+    e.proximity ? `proximity ${e.proximity.toFixed(2)}` : "",
+    e.all_terms ? `all terms ${e.all_terms.toFixed(2)}` : "",
+  ].filter(Boolean);
+  const factors = EXPLAIN_FACTORS.filter(([key]) => e[key] !== 1).map(([key, label]) => ` × ${label} ${e[key].toFixed(2)}`);
+  const total = e.before_rescore ?? r.score;
+  const rescored = e.before_rescore !== undefined ? `, RANKING_WASM → ${r.score.toFixed(2)}` : "";
+  const terms = Object.entries(e.terms)
+    .sort((a, b) => b[1] - a[1])
+    .map(([term, score]) => `${term} ${score.toFixed(2)}`)
+    .join(", ");
+  return `\n   Explain: (${sum.join(" + ")})${factors.join("")} = ${total.toFixed(2)}${rescored}\n   Terms: ${terms || "(none)"}`;
+}
+
+/** Max folded node ids listed per grouped result */
+const GROUP_IDS_SHOWN = 5;
+
+/** "Also in <symbol>: N more matching sections" for a grouped result, or "". */
+export function buildGroupLine(r: SearchResult): string {
+  if (!r.group) return "";
+  const ids = r.group.node_ids;
+  const shown = ids.slice(0, GROUP_IDS_SHOWN).join(", ");
+  const more = ids.length > GROUP_IDS_SHOWN ? ` (+${ids.length - GROUP_IDS_SHOWN} more)` : "";
+  return `\n   Also in ${r.group.symbol}: ${ids.length} more matching section(s): ${shown}${more}`;
+}
+
 function buildFacetBadge(facets: Record<string, string[]>): string {
   const parts: string[] = [];
   const langs = facets["code_languages"];
@@ -108,3 +228,12 @@ function buildRefLine(references: string[], store: SubtreeProvider): string {
   if (!resolved.length) return "";
   return `\n\n→ References: ${resolved.join(", ")}`;
 }
+
+/**
+ * " Did you mean: A, B?" for a query that found nothing, or "" when
+ * the store has no close symbol names.
+ */
+export function didYouMean(store: Pick<SubtreeProvider, "suggest">, query: string): string {
+  const names = store.suggest?.(query) ?? [];
+  return names.length > 0 ? ` Did you mean: ${names.join(", ")}?` : "";
+}
@@ -5,6 +5,18 @@
  * instead of stdio — useful for remote agents, web apps, or multi-client setups.
  *
  * Usage: DOCS_ROOT=./docs bun run src/server-http.ts
+ *
+ * Multi-tenant mode: TENANTS_CONFIG=./tenants.json bun run src/server-http.ts
+ * serves each project at /projects/<id>/mcp with its own isolated index.
+ *
+ * As a shared index host for `treenav-mcp proxy` clients, set HTTP_TOKEN
+ * so the MCP endpoints require a bearer token (health checks stay open).
+ * Tenants take their own token from the tenants file, which guards
+ * every /projects/<id>/ route; the tenant list on /health is kept for
+ * HTTP_TOKEN.
+ * GRPC_PORT adds the gRPC API (grpc.ts) over the same index, and
+ * REST_API=1 a JSON facade over the same handlers (rest.ts); WEB_UI=1
+ * serves a browser UI on top of it at /ui (web-ui.ts).
  */
 
 import { existsSync } from "node:fs";
@@ -12,43 +24,433 @@ import { join, resolve } from "node:path";
 import { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
 import { WebStandardStreamableHTTPServerTransport } from "@modelcontextprotocol/sdk/server/webStandardStreamableHttp.js";
 import { DocumentStore } from "./store";
-import { indexAllCollections } from "./indexer";
-import { singleRootConfig } from "./types";
-import { registerTools } from "./tools";
-import type { WikiOptions } from "./curator";
+import { applyRecencyBoost } from "./git-history";
+import { DEFAULT_INDEX_CACHE_PATH, flushIndexCache, loadOrBuildIndex } from "./index-cache";
+import { LazyIndex } from "./lazy-index";
+import { loadOrBuildShards } from "./shards";
+import { IndexWatcher } from "./watcher";
+import { Shutdown } from "./shutdown";
+import { ConfigReloader } from "./config-reload";
+import {
+  ConfigError,
+  formatConfig,
+  loadConfig,
+  parsePathBoosts,
+  parseSynonymGroups,
+  toIndexConfig,
+  toRerankerOptions,
+  toPriorityOptions,
+  toToolTimeouts,
+  toWikiOptions,
+} from "./config";
+import { registerTools, TOOL_NAMES } from "./tools/registry";
+import { loadPlugins } from "./plugins";
+import { loadRankingWasm } from "./wasm-ranking";
+import { Reranker } from "./reranker";
+import { QueryLog } from "./query-log";
+import { ParseCache, setParseCache } from "./parse-cache";
+import { IndexCoverage } from "./coverage";
+import { GoModuleIndex } from "./go-modules";
+import { GoStdlib } from "./go-stdlib";
+import { GeneratorLinks } from "./generator-links";
+import { dependencyCollections, moduleCacheDir } from "./go-deps";
+import { findVendorTrees, vendorBoosts } from "./vendor";
+import { loadCoverProfile } from "./test-coverage";
+import { MarkerIndex } from "./markers";
+import { DuplicateFinder } from "./duplicates";
+import { TreeSitterQuery } from "./ts-query";
+import { StructuralSearch } from "./structural";
+import { RegexSearch } from "./regex-search";
+import { Profiler, startAdminServer } from "./profiler";
+import { FileReader } from "./read-file";
+import { DefinitionPeek } from "./peek";
+import { BuildTargetIndex } from "./build-targets";
+import { ConfigUsageIndex } from "./config-usages";
+import { LogSourceIndex } from "./log-sources";
+import { ApiDiff } from "./api-diff";
+import { IndexAdmin, queryCaches } from "./admin";
+import { ResultCache } from "./result-cache";
+import { EditorLinks } from "./editor-links";
+import { pathMatching } from "./paths";
+import { AstDiff } from "./ast-diff";
+import { UsageStats } from "./usage";
+import { Hotspots } from "./hotspots";
+import { CycleFinder } from "./cycles";
+import { EntrypointIndex } from "./entrypoints";
+import { FieldReferences } from "./fields";
+import { EnumIndex } from "./enums";
+import { ConcurrencyMap } from "./concurrency";
+import { ContextAudit } from "./context-audit";
+import { EmbedIndex } from "./embeds";
+import { Breadcrumbs } from "./breadcrumbs";
+import { CodeownersIndex } from "./codeowners";
+import { LicenseIndex } from "./licenses";
+import { RefIndex } from "./ref-index";
+import { StaleCheck } from "./staleness";
+import { parseTenantsConfig, TenantRegistry } from "./tenants";
+import { SessionState } from "./session";
+import { bearerAuthorized } from "./remote";
+import { navigationHandlers, startGrpcServer } from "./grpc";
+import { handleRest } from "./rest";
+import { handleWebUi } from "./web-ui";
 import type { IndexConfig } from "./types";
 
-const docs_root = process.env.DOCS_ROOT || "./docs";
-const config: IndexConfig = singleRootConfig(docs_root);
-config.max_depth = parseInt(process.env.MAX_DEPTH || "6");
-config.summary_length = parseInt(process.env.SUMMARY_LENGTH || "200");
+// Flags > environment > treenav.config.json > defaults — see config.ts
+const loaded = await loadConfig(Bun.argv.slice(2)).catch((err) => {
+  if (!(err instanceof ConfigError)) throw err;
+  console.error(`Config error: ${err.message}`);
+  process.exit(2);
+});
+const settings = loaded.config;
+
+if (loaded.args.flags["print-config"]) {
+  console.log(formatConfig(settings, loaded.sources, loaded.file));
+  process.exit(0);
+}
+
+const docs_root = settings.docs_root;
+const config: IndexConfig = toIndexConfig(settings);
+
+const PORT = settings.port;
 
-const PORT = parseInt(process.env.PORT || "3100");
+function unauthorized(): Response {
+  return Response.json({ error: "missing or invalid bearer token" }, { status: 401, headers: { "WWW-Authenticate": "Bearer" } });
+}
 
 // Wiki curation toolset — opt-in via WIKI_WRITE=1
-let wiki: WikiOptions | undefined;
-if (process.env.WIKI_WRITE === "1") {
-  const wikiRoot = resolve(process.env.WIKI_ROOT || docs_root);
-  wiki = {
-    root: wikiRoot,
-    collectionName: "docs",
-    duplicateThreshold: parseFloat(
-      process.env.WIKI_DUPLICATE_THRESHOLD || "0.35"
-    ),
-  };
-  console.log(`[wiki-write] write mode enabled; wiki root is ${wikiRoot}`);
+let wiki = toWikiOptions(settings);
+if (wiki) {
+  console.log(`[wiki-write] write mode enabled; wiki root is ${wiki.root}`);
 }
 
+// search_documents' reranking stage — opt-in via RERANK_URL, shared by tenants
+const rerankerOptions = toRerankerOptions(settings);
+const reranker = rerankerOptions ? new Reranker({ ...rerankerOptions, log: (msg) => console.log(msg) }) : undefined;
+if (reranker) console.log(`Reranker: ${settings.rerank_url} (top ${reranker.topK}, ${settings.rerank_timeout_ms}ms budget)`);
+
 const store = new DocumentStore();
 
+// Lazy mode — LAZY_INDEX=1 indexes only LAZY_EAGER prefixes at startup
+const lazy = settings.lazy_index
+  ? new LazyIndex(store, config, {
+      eager: settings.lazy_eager,
+      depth: settings.lazy_depth,
+      log: (msg) => console.log(msg),
+    })
+  : undefined;
+
+// Sparse index (INCLUDE) — "not indexed" answers outside the included set
+const coverage = new IndexCoverage(config);
+
+// module_info — go.mod / go.work metadata for the code collections
+const goModules = config.code_collections?.length ? new GoModuleIndex(config) : undefined;
+
+// Standard library names in find_symbol and package_api, from GOROOT
+const stdlib = config.code_collections?.length ? new GoStdlib(settings.goroot) : undefined;
+
+// Generator inputs (.proto, mocked interface) for find_symbol hits in generated code
+const generatorLinks = config.code_collections?.length ? new GeneratorLinks(config) : undefined;
+
+// list_markers — TODO/FIXME comments in the code collections
+const markers = config.code_collections?.length ? new MarkerIndex(config, settings.markers) : undefined;
+
+// find_duplicates — cloned functions in the code collections
+const duplicates = config.code_collections?.length ? new DuplicateFinder() : undefined;
+
+// ts_query — raw tree-sitter queries, with TREE_SITTER_GRAMMARS
+const treeSitter =
+  config.code_collections?.length && settings.tree_sitter_grammars
+    ? new TreeSitterQuery(config, settings.tree_sitter_grammars, { log: (msg) => console.log(msg) })
+    : undefined;
+// Extensions from its grammars.json are indexed as code, so read it first
+await treeSitter?.grammars();
+
+// structural_search, and structural_replace with STRUCTURAL_REWRITE=1
+const structural = config.code_collections?.length ? new StructuralSearch(config) : undefined;
+
+// regex_search — regular expressions, prefiltered by trigram Bloom filters
+const regex = config.code_collections?.length ? new RegexSearch(config) : undefined;
+
+// peek_definitions — signatures, doc comments, and excerpts for many names at once
+const peek = config.code_collections?.length ? new DefinitionPeek(config) : undefined;
+
+// build_targets — Makefile and Bazel targets that build a file
+const buildTargets = config.code_collections?.length ? new BuildTargetIndex(config) : undefined;
+
+// config_usages — env vars, flags, and config keys and where they are read
+const configUsages = config.code_collections?.length ? new ConfigUsageIndex(config) : undefined;
+
+// find_log_source — the code that emits a log line or metric
+const logSources = config.code_collections?.length ? new LogSourceIndex(config) : undefined;
+
+// api_diff — exported Go API changes between refs, and the semver bump
+const apiDiff = config.code_collections?.length ? new ApiDiff(config, goModules) : undefined;
+
+// ast_diff — symbol-level diffs against git refs
+const astDiff = config.code_collections?.length ? new AstDiff(config) : undefined;
+
+// usage_stats, callers, trace_errors, panic_sites — references by consuming package and kind
+const usage = config.code_collections?.length ? new UsageStats(config, goModules) : undefined;
+
+// hotspots — churn × complexity from git history
+const hotspots = config.code_collections?.length ? new Hotspots(config) : undefined;
+
+// find_cycles — import cycles in the package graph
+const cycles = config.code_collections?.length ? new CycleFinder(config, goModules) : undefined;
+
+// list_entrypoints — mains, routes, gRPC services, CLI commands
+const entrypoints = config.code_collections?.length ? new EntrypointIndex(config) : undefined;
+
+// field_references — reads and writes of a struct field
+const fields = config.code_collections?.length ? new FieldReferences(config) : undefined;
+
+// enum_usages — where enum values are produced and consumed
+const enums = config.code_collections?.length ? new EnumIndex(config) : undefined;
+
+// concurrency_map — goroutines, channels, and locks per Go package
+const concurrency = config.code_collections?.length ? new ConcurrencyMap(config) : undefined;
+
+// context_audit — Go functions that drop the context they were given
+const contextAudit = config.code_collections?.length ? new ContextAudit(config) : undefined;
+
+// list_embeds — //go:embed directives and the files they match
+const embeds = config.code_collections?.length ? new EmbedIndex(config, goModules) : undefined;
+
+// breadcrumbs — enclosing scopes of a line, for docs and code alike
+const breadcrumbs = new Breadcrumbs(config, goModules);
+const editorLinks = new EditorLinks(config, settings.editor_url);
+
+// owners_of — CODEOWNERS lookups, for docs and code alike
+const codeowners = new CodeownersIndex(config);
+
+// LICENSE_TAGS — license and provenance of result files
+const licenses = settings.license_tags ? new LicenseIndex(config) : undefined;
+
+// ref — the collections as of a git ref, read from the object store
+const refs = new RefIndex(config);
+
+// read_file — line ranges of indexed files, read from the collection roots
+const files = new FileReader(config);
+
+// Search results from files changed since indexing: flagged, or re-indexed
+const staleness = new StaleCheck(store, config, { refresh: settings.stale_refresh });
+
+// Answers of the graph tools on /mcp, kept until a code file changes (RESULT_CACHE_SIZE)
+const resultCache = settings.result_cache_size > 0 ? new ResultCache(store, settings.result_cache_size) : undefined;
+
+// reindex_path, clear_cache, and evict_file on /mcp — opt-in via
+// ADMIN_TOOLS=1; tenants keep their own stores and do not get them
+let admin: IndexAdmin | undefined;
+if (settings.admin_tools) {
+  if (lazy || settings.shard_dir) {
+    console.warn("Warning: ADMIN_TOOLS is not supported with LAZY_INDEX or SHARD_DIR; not registering the admin tools");
+  } else {
+    admin = new IndexAdmin(store, config, { caches: queryCaches({ staleness, refs, licenses, codeowners, results: resultCache }) });
+    console.log("Admin tools enabled on /mcp; reindex_path and evict_file change the index for every session");
+  }
+}
+
+// Tool deadlines (TOOL_TIMEOUT_MS and the per-category overrides)
+const timeouts = toToolTimeouts(settings);
+
+// CPU and heap profiles: capture_profile with PROFILE_DIR, /debug/pprof/ with ADMIN_PORT
+const profiler = settings.profile_dir || settings.admin_port ? new Profiler(settings.profile_dir) : undefined;
+
+// Organization-specific tools (PLUGINS) on /mcp; see plugins.ts
+const plugins = settings.plugins.length
+  ? await loadPlugins(settings.plugins, config, { reserved: TOOL_NAMES, log: (msg) => console.log(msg) })
+  : undefined;
+
+// SIGTERM/SIGINT close the listeners, drain in-flight tool calls, and
+// flush the index; see shutdown.ts
+const shutdown = new Shutdown({ graceMs: settings.shutdown_grace_ms, log: (msg) => console.log(msg) });
+shutdown.install();
+
+// Searches and the reads that follow them on /mcp — opt-in via QUERY_LOG.
+// Reads are credited within an mcp-session-id; see query-log.ts
+const queryLog = settings.query_log ? new QueryLog(settings.query_log, { log: (msg) => console.log(msg) }) : undefined;
+if (queryLog) {
+  console.log(`Query log: ${queryLog.path}`);
+  shutdown.onFlush("query log", () => queryLog.flush());
+}
+
+// Parsed code by content hash, reused across branches, worktrees, and
+// tenants — opt-in via PARSE_CACHE; see parse-cache.ts
+const parseCache = settings.parse_cache ? new ParseCache(settings.parse_cache, { log: (msg) => console.log(msg) }) : null;
+setParseCache(parseCache);
+
+// Edits to the config file, and SIGHUP, apply ranking, synonyms,
+// deadlines, write mode, and file discovery without a restart; see
+// config-reload.ts. Every request builds its tool list afresh, so a
+// write-mode change applies from the next request on.
+const reloader = new ConfigReloader(
+  settings,
+  async () => (await loadConfig(Bun.argv.slice(2))).config,
+  {
+    store,
+    config,
+    reindexable: !lazy && !settings.shard_dir && !settings.tenants_config,
+    timeouts,
+    setWiki: (next) => {
+      wiki = next;
+    },
+  },
+  { log: (msg) => console.log(msg) }
+);
+if (loaded.file) reloader.watch(loaded.file);
+process.on("SIGHUP", () => void reloader.reload());
+shutdown.onStop("config watcher", () => reloader.stop());
+
+// set_preferences state, keyed by the client's mcp-session-id header.
+// Requests without the header get a fresh, request-scoped session.
+// Oldest sessions are evicted past MAX_SESSIONS.
+const MAX_SESSIONS = 1000;
+const sessions = new Map<string, SessionState>();
+
+function sessionFor(req: Request, scope: string): SessionState {
+  const id = req.headers.get("mcp-session-id");
+  if (!id) return new SessionState();
+  const key = `${scope}:${id}`;
+  let session = sessions.get(key);
+  if (!session) {
+    session = new SessionState();
+    sessions.set(key, session);
+    if (sessions.size > MAX_SESSIONS) {
+      sessions.delete(sessions.keys().next().value!);
+    }
+  }
+  return session;
+}
+
+/** Handle one MCP request statelessly against the given store. */
+async function handleMcp(
+  req: Request,
+  target: DocumentStore,
+  options: Parameters<typeof registerTools>[2]
+): Promise<Response> {
+  // For each incoming request, create server + transport
+  // This is the stateless pattern from the MCP SDK docs
+  const server = new McpServer({
+    name: "treenav-mcp",
+    version: "1.0.0",
+  });
+  registerTools(server, target, options);
+
+  const transport = new WebStandardStreamableHTTPServerTransport({
+    sessionIdGenerator: undefined, // stateless
+  });
+
+  await server.connect(transport);
+
+  // Handle the request through the transport
+  return transport.handleRequest(req);
+}
+
+/**
+ * Multi-tenant mode: route /projects/<id>/mcp and /projects/<id>/health
+ * to per-tenant stores. Tenants are indexed on first request. Both
+ * need the tenant's token (registry.authorized); /health answers
+ * liveness to anyone and lists the tenants only to the operator.
+ */
+async function serveTenants(configPath: string) {
+  const tenants = parseTenantsConfig(await Bun.file(configPath).json());
+  const registry = new TenantRegistry(tenants);
+  console.log(`Multi-tenant mode: ${tenants.length} tenant(s) from ${configPath}`);
+
+  const http = Bun.serve({
+    port: PORT,
+    async fetch(req) {
+      const url = new URL(req.url);
+
+      const authorization = req.headers.get("authorization") ?? undefined;
+      if (url.pathname === "/health") {
+        if (!registry.authorized(null, authorization, settings.http_token)) return Response.json({ status: "ok" });
+        return Response.json({ status: "ok", tenants: await registry.summary() });
+      }
+
+      const match = url.pathname.match(/^\/projects\/([^/]+)\/(mcp|health)$/);
+      if (!match) return new Response("Not Found", { status: 404 });
+
+      const [, id, endpoint] = match;
+      if (!registry.authorized(id, authorization, settings.http_token)) return unauthorized();
+      if (!registry.has(id)) {
+        return Response.json({ error: `unknown project "${id}"` }, { status: 404 });
+      }
+      if (!registry.allowRequest(id)) {
+        return Response.json(
+          { error: "rate limit exceeded" },
+          { status: 429, headers: { "Retry-After": "60" } }
+        );
+      }
+
+      const tenant = await registry.load(id);
+      if (tenant.error) {
+        return Response.json({ error: tenant.error }, { status: 503 });
+      }
+
+      if (endpoint === "health") {
+        return Response.json({ status: "ok", project: id, ...tenant.store.getStats() });
+      }
+      return handleMcp(req, tenant.store, { wiki: tenant.wiki, session: sessionFor(req, id), reranker, timeouts, shutdown });
+    },
+  });
+  shutdown.onStop("http", () => http.stop());
+
+  console.log(`MCP HTTP server running on http://localhost:${PORT}/projects/<id>/mcp`);
+  console.log(`Health check: http://localhost:${PORT}/health`);
+}
+
 async function main() {
-  // Index documents
+  if (settings.tenants_config) {
+    return serveTenants(settings.tenants_config);
+  }
+
+  // Index documents — lazily (LAZY_INDEX), from shards (SHARD_DIR), or
+  // warm-started from a cached index (INDEX_CACHE); a cold start indexes
+  // priority files first (see warmup.ts)
   console.log(`Indexing from ${docs_root}...`);
-  const documents = await indexAllCollections(config);
-  store.load(documents);
+  if (settings.index_dependencies && goModules) {
+    if (lazy || settings.shard_dir) {
+      console.warn("Warning: INDEX_DEPENDENCIES is not supported with LAZY_INDEX or SHARD_DIR; not indexing dependencies");
+    } else {
+      const modcache = moduleCacheDir(settings.gomodcache);
+      // Modules vendored under an indexed vendor/ tree would be indexed twice
+      const vendored = new Set<string>();
+      if (settings.vendor_policy !== "exclude") {
+        for (const tree of await findVendorTrees(config, goModules)) for (const m of tree.modules) vendored.add(m);
+      }
+      const deps = await dependencyCollections(goModules, modcache, { vendored });
+      config.dependency_collections = deps.collections;
+      const missing = deps.missing.length ? `; not in the cache (run go mod download): ${deps.missing.join(", ")}` : "";
+      const skipped = deps.vendored.length ? `; ${deps.vendored.length} already vendored` : "";
+      console.log(`Dependencies: ${deps.collections.length} module(s) from ${modcache}${missing}${skipped}`);
+    }
+  }
+  const cachePath = resolve(settings.index_cache || DEFAULT_INDEX_CACHE_PATH);
+  let warm = false;
+  if ((lazy || settings.shard_dir) && toPriorityOptions(settings)) {
+    console.warn("Warning: PRIORITY_FILES and PRIORITY_RECENT_COMMITS are not supported with LAZY_INDEX or SHARD_DIR; ignoring them");
+  }
+  if (lazy) {
+    await lazy.init();
+  } else if (settings.shard_dir) {
+    await loadOrBuildShards(store, config, settings.shard_dir, {
+      only: settings.shards,
+      compression: settings.index_compression,
+      log: (msg) => console.log(msg),
+    });
+  } else {
+    ({ warm } = await loadOrBuildIndex(store, config, {
+      cachePath,
+      persist: !!settings.index_cache,
+      priority: toPriorityOptions(settings),
+      compression: settings.index_compression,
+      log: (msg) => console.log(msg),
+    }));
+  }
 
   // Load glossary if present
-  const glossaryPath = process.env.GLOSSARY_PATH || join(docs_root, "glossary.json");
+  const glossaryPath = settings.glossary_path || join(docs_root, "glossary.json");
   if (existsSync(glossaryPath)) {
     try {
       const glossaryData = await Bun.file(glossaryPath).json();
@@ -58,16 +460,77 @@ async function main() {
       console.warn(`Warning: Failed to load glossary: ${err.message}`);
     }
   }
+  store.loadSynonyms(parseSynonymGroups(settings.synonyms));
+  store.setPathBoosts([...parsePathBoosts(settings.path_boosts), ...vendorBoosts(settings.vendor_policy)]);
+  store.setRanking({
+    reference_weight: settings.reference_weight,
+    coverage_weight: settings.coverage_weight,
+    max_per_file: settings.max_per_file,
+    max_per_package: settings.max_per_package,
+  });
+  store.setByteOffsets(settings.byte_offsets);
+  store.setGeneratedPolicy(settings.generated_policy);
+  store.setCommentMatching({ matching: settings.comment_matching, languages: settings.comment_languages });
+  store.setPathMatching(await pathMatching(config));
+  if (settings.ranking_wasm) {
+    try {
+      store.setRescorer(await loadRankingWasm(settings.ranking_wasm, { log: (msg) => console.log(msg) }));
+      console.log(`Ranking hook: ${settings.ranking_wasm}`);
+    } catch (err: any) {
+      console.warn(`Warning: RANKING_WASM not loaded, using built-in ranking: ${err.message}`);
+    }
+  }
+  if (settings.recency_weight > 0) {
+    const files = applyRecencyBoost(store, config, settings.recency_weight, settings.recency_half_life_days);
+    console.log(`Recency boost: commit times for ${files} files`);
+  }
+  if (settings.coverage_profile) {
+    try {
+      const cover = await loadCoverProfile(store, config, settings.coverage_profile, goModules);
+      const outside = cover.unresolved ? `, ${cover.unresolved} files outside the code roots` : "";
+      console.log(`Coverage: ${cover.blocks} blocks in ${cover.files} files (mode ${cover.mode})${outside}`);
+    } catch (err: any) {
+      console.warn(`Warning: Failed to load coverage profile: ${err.message}`);
+    }
+  }
 
   const stats = store.getStats();
   console.log(
     `Indexed: ${stats.document_count} docs, ${stats.total_nodes} sections`
   );
+  if (parseCache) {
+    const { hits, misses } = parseCache.stats();
+    console.log(`Parse cache ${parseCache.dir}: ${hits} reused, ${misses} parsed`);
+  }
+
+  // Keep the index in step with the working tree (WATCH=1)
+  if (settings.watch) {
+    if (lazy || settings.shard_dir) {
+      console.warn("Warning: WATCH is not supported with LAZY_INDEX or SHARD_DIR; not watching");
+    } else {
+      const watcher = new IndexWatcher(store, config, {
+        debounceMs: settings.watch_debounce_ms,
+        batchSize: settings.watch_batch_size,
+        log: (msg) => console.log(msg),
+      });
+      watcher.start();
+      shutdown.onStop("watcher", () => watcher.stop());
+      shutdown.onFlush("pending changes", () => watcher.flush());
+      if (settings.index_cache || warm) {
+        shutdown.onFlush("index cache", async () => {
+          const written = await flushIndexCache(store, config, cachePath, { compression: settings.index_compression });
+          console.log(written ? `Index cache written to ${cachePath}` : "Re-validation still running; kept the existing index cache");
+        });
+      }
+    }
+  }
+
+  const rest = settings.rest_api || settings.web_ui ? navigationHandlers(store, { lazy }) : undefined;
 
   // Create a new MCP server per request for stateless operation
   // In production you'd want session tracking for stateful mode
 
-  Bun.serve({
+  const http = Bun.serve({
     port: PORT,
     async fetch(req) {
       const url = new URL(req.url);
@@ -82,30 +545,101 @@ async function main() {
 
       // MCP endpoint
       if (url.pathname === "/mcp") {
-        // For each incoming request, create server + transport
-        // This is the stateless pattern from the MCP SDK docs
-        const server = new McpServer({
-          name: "treenav-mcp",
-          version: "1.0.0",
-        });
-        registerTools(server, store, { wiki });
-
-        const transport = new WebStandardStreamableHTTPServerTransport({
-          sessionIdGenerator: undefined, // stateless
+        if (!bearerAuthorized(req, settings.http_token)) return unauthorized();
+        return handleMcp(req, store, {
+          wiki,
+          lazy,
+          coverage,
+          goModules,
+          stdlib,
+          generatorLinks,
+          reranker,
+          queryLog,
+          coverProfile: settings.coverage_profile,
+          markers,
+          duplicates,
+          treeSitter,
+          structural,
+          structuralRewrite: settings.structural_rewrite,
+          regex,
+          astDiff,
+          usage,
+          hotspots,
+          codeowners,
+          licenses,
+          cycles,
+          entrypoints,
+          fields,
+          enums,
+          concurrency,
+          contextAudit,
+          embeds,
+          breadcrumbs,
+          symbolNav: true,
+          refs,
+          staleness,
+          timeouts,
+          shutdown,
+          plugins,
+          profiler: settings.profile_dir ? profiler : undefined,
+          files,
+          peek,
+          buildTargets,
+          configUsages,
+          logSources,
+          apiDiff,
+          admin,
+          resultCache,
+          editorLinks,
+          session: sessionFor(req, ""),
         });
+      }
 
-        await server.connect(transport);
+      // Browser UI (WEB_UI=1); its data comes from the REST routes
+      if (settings.web_ui) {
+        const page = handleWebUi(req);
+        if (page) return page;
+      }
 
-        // Handle the request through the transport
-        return transport.handleRequest(req);
+      // REST/JSON facade (REST_API=1)
+      if (rest) {
+        const response = await handleRest(req, rest, { token: settings.http_token });
+        if (response) return response;
       }
 
       return new Response("Not Found", { status: 404 });
     },
   });
 
+  // In-flight requests finish; new connections are refused
+  shutdown.onStop("http", () => http.stop());
+
   console.log(`MCP HTTP server running on http://localhost:${PORT}/mcp`);
+  if (rest) console.log(`REST API: http://localhost:${PORT}/search?q=...`);
+  if (settings.web_ui) console.log(`Web UI: http://localhost:${PORT}/ui`);
   console.log(`Health check: http://localhost:${PORT}/health`);
+
+  // gRPC API for non-MCP consumers (GRPC_PORT)
+  if (settings.grpc_port) {
+    try {
+      const grpc = await startGrpcServer(store, { port: settings.grpc_port, token: settings.http_token, lazy });
+      shutdown.onStop("grpc", () => grpc.stop());
+      console.log(`gRPC server (treenav.v1.Navigation) running on port ${grpc.port}`);
+    } catch (err: any) {
+      console.warn(`Warning: gRPC API not started: ${err.message}`);
+    }
+  }
+
+  // Profiles for diagnosing a slow server (ADMIN_PORT), on localhost only
+  if (profiler && settings.admin_port) {
+    try {
+      const admin = startAdminServer(profiler, { port: settings.admin_port, token: settings.http_token });
+      shutdown.onStop("admin", () => admin.stop());
+      console.log(`Profiles: http://127.0.0.1:${admin.port}/debug/pprof/`);
+    } catch (err: any) {
+      console.warn(`Warning: admin port not started: ${err.message}`);
+    }
+  }
 }
 
 main().catch(console.error);
@@ -1,7 +1,7 @@
 /**
  * MCP Server for Markdown Tree Navigation
  *
- * Exposes 6 tools that let an agent perform PageIndex-style reasoning
+ * Exposes 8 tools that let an agent perform PageIndex-style reasoning
  * over your markdown repository:
  *
  *   1. list_documents   - Browse the document catalog
@@ -10,6 +10,30 @@
  *   4. get_node_content - Retrieve text from specific tree nodes
  *   5. navigate_tree    - Get a subtree (node + all descendants)
  *   6. find_symbol      - Search code symbols by name/kind/language
+ *   7. set_preferences  - Session defaults for languages/limit/focus
+ *   8. multi_search     - Several searches in one call, grouped by query
+ *
+ * With CODE_ROOT set, module_info adds go.mod / go.work metadata and
+ * package_api the exported API of a Go package; list_markers reports
+ * TODO/FIXME comments, find_duplicates cloned functions, and
+ * structural_search comby-style patterns (structural_replace with
+ * STRUCTURAL_REWRITE=1), regex_search regular expressions, skipping
+ * files by their trigram filters; ast_diff compares a file across git refs,
+ * and usage_stats counts a symbol's references by consuming package,
+ * callers follows its call sites, transitively if asked, trace_errors
+ * follows an error from where it is created up the callers that
+ * return it, field_references tells a field's reads from its writes,
+ * and enum_usages where each value of an enum is produced and
+ * consumed and which switches miss values; hotspots ranks files by
+ * commits × complexity, find_cycles reports import cycles between
+ * packages, list_entrypoints finds mains, routes, gRPC services, and
+ * CLI commands, concurrency_map the goroutines, channels, and locks
+ * of each Go package, context_audit the functions that drop the
+ * context.Context they were given, and list_embeds the files a
+ * package or binary embeds. owners_of answers from CODEOWNERS for any
+ * collection, breadcrumbs gives the scopes around any line, and
+ * next_symbol / previous_symbol step through a file's definitions.
+ * COVERAGE_PROFILE adds coverage_for; TREE_SITTER_GRAMMARS adds ts_query.
  *
  * The agent workflow:
  *   search/list → pick doc → get_tree → reason about structure →
@@ -22,75 +46,357 @@ import { StdioServerTransport } from "@modelcontextprotocol/sdk/server/stdio.js"
 import { existsSync } from "node:fs";
 import { join, resolve } from "node:path";
 import { DocumentStore } from "./store";
-import { indexAllCollections } from "./indexer";
-import { singleRootConfig } from "./types";
-import { registerTools } from "./tools";
-import type { WikiOptions } from "./curator";
+import { applyRecencyBoost } from "./git-history";
+import { DEFAULT_INDEX_CACHE_PATH, flushIndexCache, loadOrBuildIndex } from "./index-cache";
+import { LazyIndex } from "./lazy-index";
+import { loadOrBuildShards } from "./shards";
+import { IndexWatcher } from "./watcher";
+import { Shutdown } from "./shutdown";
+import { ConfigReloader } from "./config-reload";
+import {
+  ConfigError,
+  formatConfig,
+  loadConfig,
+  parsePathBoosts,
+  parseSynonymGroups,
+  toIndexConfig,
+  toRerankerOptions,
+  toPriorityOptions,
+  toToolTimeouts,
+  toWikiOptions,
+} from "./config";
+import { registerTools, TOOL_NAMES } from "./tools/registry";
+import { loadPlugins } from "./plugins";
+import { loadRankingWasm } from "./wasm-ranking";
+import { Reranker } from "./reranker";
+import { QueryLog } from "./query-log";
+import { ParseCache, setParseCache } from "./parse-cache";
+import { IndexCoverage } from "./coverage";
+import { GoModuleIndex } from "./go-modules";
+import { GoStdlib } from "./go-stdlib";
+import { GeneratorLinks } from "./generator-links";
+import { dependencyCollections, moduleCacheDir } from "./go-deps";
+import { findVendorTrees, vendorBoosts } from "./vendor";
+import { loadCoverProfile } from "./test-coverage";
+import { MarkerIndex } from "./markers";
+import { DuplicateFinder } from "./duplicates";
+import { TreeSitterQuery } from "./ts-query";
+import { StructuralSearch } from "./structural";
+import { RegexSearch } from "./regex-search";
+import { AstDiff } from "./ast-diff";
+import { UsageStats } from "./usage";
+import { Hotspots } from "./hotspots";
+import { CycleFinder } from "./cycles";
+import { EntrypointIndex } from "./entrypoints";
+import { FieldReferences } from "./fields";
+import { EnumIndex } from "./enums";
+import { ConcurrencyMap } from "./concurrency";
+import { ContextAudit } from "./context-audit";
+import { EmbedIndex } from "./embeds";
+import { Breadcrumbs } from "./breadcrumbs";
+import { CodeownersIndex } from "./codeowners";
+import { LicenseIndex } from "./licenses";
+import { RefIndex } from "./ref-index";
+import { StaleCheck } from "./staleness";
+import { Profiler, startAdminServer } from "./profiler";
+import { FileReader } from "./read-file";
+import { DefinitionPeek } from "./peek";
+import { BuildTargetIndex } from "./build-targets";
+import { ConfigUsageIndex } from "./config-usages";
+import { LogSourceIndex } from "./log-sources";
+import { ApiDiff } from "./api-diff";
+import { IndexAdmin, queryCaches } from "./admin";
+import { ResultCache } from "./result-cache";
+import { EditorLinks } from "./editor-links";
+import { pathMatching } from "./paths";
 import type { IndexConfig } from "./types";
 
 // ── Configuration ────────────────────────────────────────────────────
 
-const docs_root = process.env.DOCS_ROOT || "./docs";
-const config: IndexConfig = singleRootConfig(docs_root);
-config.max_depth = parseInt(process.env.MAX_DEPTH || "6");
-config.summary_length = parseInt(process.env.SUMMARY_LENGTH || "200");
-
-// Code collection: set CODE_ROOT to enable AST-based code indexing
-const code_root = process.env.CODE_ROOT;
-if (code_root) {
-  config.code_collections = [
-    {
-      name: process.env.CODE_COLLECTION || "code",
-      root: code_root,
-      weight: parseFloat(process.env.CODE_WEIGHT || "1.0"),
-      glob_pattern: process.env.CODE_GLOB,
-    },
-  ];
+// Flags > environment > treenav.config.json > defaults — see config.ts.
+// `treenav-mcp serve --print-config` dumps the effective values.
+const argv = Bun.argv.slice(2);
+if (argv[0] === "serve") argv.shift();
+
+const loaded = await loadConfig(argv).catch((err) => {
+  if (!(err instanceof ConfigError)) throw err;
+  console.error(`[treenav-mcp] Config error: ${err.message}`);
+  process.exit(2);
+});
+const settings = loaded.config;
+
+if (loaded.args.flags["print-config"]) {
+  console.log(formatConfig(settings, loaded.sources, loaded.file));
+  process.exit(0);
 }
 
+const docs_root = settings.docs_root;
+const config: IndexConfig = toIndexConfig(settings);
+
 // ── Initialize store ─────────────────────────────────────────────────
 
 const store = new DocumentStore();
 
+// Lazy mode — LAZY_INDEX=1 indexes only LAZY_EAGER prefixes at startup
+// and expands other regions when queries touch them
+const lazy = settings.lazy_index
+  ? new LazyIndex(store, config, {
+      eager: settings.lazy_eager,
+      depth: settings.lazy_depth,
+      log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+    })
+  : undefined;
+
 // ── Create MCP Server ────────────────────────────────────────────────
 
-const server = new McpServer({
-  name: "treenav-mcp",
-  version: "1.0.0",
-});
+const server = new McpServer(
+  {
+    name: "treenav-mcp",
+    version: "1.0.0",
+  },
+  // Config reloads are reported as log notifications
+  { capabilities: { logging: {} } }
+);
 
 // Wiki curation toolset — opt-in via WIKI_WRITE=1. When unset, treenav
 // stays read-only and the curation tools are NOT registered.
-let wiki: WikiOptions | undefined;
-if (process.env.WIKI_WRITE === "1") {
-  const wikiRoot = resolve(process.env.WIKI_ROOT || docs_root);
-  wiki = {
-    root: wikiRoot,
-    collectionName: "docs",
-    duplicateThreshold: parseFloat(
-      process.env.WIKI_DUPLICATE_THRESHOLD || "0.35"
-    ),
-  };
-  console.error(
-    `[treenav-mcp] [wiki-write] write mode enabled; wiki root is ${wikiRoot}`
-  );
+const wiki = toWikiOptions(settings);
+if (wiki) {
+  console.error(`[treenav-mcp] [wiki-write] write mode enabled; wiki root is ${wiki.root}`);
+}
+
+// search_documents' reranking stage — opt-in via RERANK_URL; see reranker.ts
+const rerankerOptions = toRerankerOptions(settings);
+const reranker = rerankerOptions
+  ? new Reranker({ ...rerankerOptions, log: (msg) => console.error(`[treenav-mcp] ${msg}`) })
+  : undefined;
+if (reranker) console.error(`[treenav-mcp] Reranker: ${settings.rerank_url} (top ${reranker.topK}, ${settings.rerank_timeout_ms}ms budget)`);
+
+// The code tools (module_info through find_cycles, except owners_of) need code collections
+const goModules = config.code_collections?.length ? new GoModuleIndex(config) : undefined;
+const stdlib = config.code_collections?.length ? new GoStdlib(settings.goroot) : undefined;
+const generatorLinks = config.code_collections?.length ? new GeneratorLinks(config) : undefined;
+const markers = config.code_collections?.length ? new MarkerIndex(config, settings.markers) : undefined;
+const duplicates = config.code_collections?.length ? new DuplicateFinder() : undefined;
+const treeSitter =
+  config.code_collections?.length && settings.tree_sitter_grammars
+    ? new TreeSitterQuery(config, settings.tree_sitter_grammars, { log: (msg) => console.error(`[treenav-mcp] ${msg}`) })
+    : undefined;
+// Extensions from its grammars.json are indexed as code, so read it first
+await treeSitter?.grammars();
+const structural = config.code_collections?.length ? new StructuralSearch(config) : undefined;
+const regex = config.code_collections?.length ? new RegexSearch(config) : undefined;
+const peek = config.code_collections?.length ? new DefinitionPeek(config) : undefined;
+const buildTargets = config.code_collections?.length ? new BuildTargetIndex(config) : undefined;
+const configUsages = config.code_collections?.length ? new ConfigUsageIndex(config) : undefined;
+const logSources = config.code_collections?.length ? new LogSourceIndex(config) : undefined;
+const apiDiff = config.code_collections?.length ? new ApiDiff(config, goModules) : undefined;
+const astDiff = config.code_collections?.length ? new AstDiff(config) : undefined;
+const usage = config.code_collections?.length ? new UsageStats(config, goModules) : undefined;
+const hotspots = config.code_collections?.length ? new Hotspots(config) : undefined;
+const cycles = config.code_collections?.length ? new CycleFinder(config, goModules) : undefined;
+const entrypoints = config.code_collections?.length ? new EntrypointIndex(config) : undefined;
+const fields = config.code_collections?.length ? new FieldReferences(config) : undefined;
+const enums = config.code_collections?.length ? new EnumIndex(config) : undefined;
+const concurrency = config.code_collections?.length ? new ConcurrencyMap(config) : undefined;
+const contextAudit = config.code_collections?.length ? new ContextAudit(config) : undefined;
+const embeds = config.code_collections?.length ? new EmbedIndex(config, goModules) : undefined;
+if (structural && settings.structural_rewrite) {
+  console.error("[treenav-mcp] structural_replace enabled; it rewrites files under the code roots");
+}
+
+// Query-time caches of files on disk, git, and earlier answers, which clear_cache drops
+const codeowners = new CodeownersIndex(config);
+const licenses = settings.license_tags ? new LicenseIndex(config) : undefined;
+const refs = new RefIndex(config);
+const staleness = new StaleCheck(store, config, { refresh: settings.stale_refresh });
+const resultCache = settings.result_cache_size > 0 ? new ResultCache(store, settings.result_cache_size) : undefined;
+
+// reindex_path, clear_cache, and evict_file — opt-in via ADMIN_TOOLS=1; see admin.ts
+let admin: IndexAdmin | undefined;
+if (settings.admin_tools) {
+  if (lazy || settings.shard_dir) {
+    console.error("[treenav-mcp] Warning: ADMIN_TOOLS is not supported with LAZY_INDEX or SHARD_DIR; not registering the admin tools");
+  } else {
+    admin = new IndexAdmin(store, config, { caches: queryCaches({ staleness, refs, licenses, codeowners, results: resultCache }) });
+    console.error("[treenav-mcp] Admin tools enabled; reindex_path and evict_file change the index for every session");
+  }
+}
+
+// SIGTERM/SIGINT drain in-flight tool calls and flush the index; see shutdown.ts
+const shutdown = new Shutdown({
+  graceMs: settings.shutdown_grace_ms,
+  log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+});
+shutdown.install();
+
+// Searches and the reads that follow them — opt-in via QUERY_LOG; see query-log.ts
+const queryLog = settings.query_log
+  ? new QueryLog(settings.query_log, { log: (msg) => console.error(`[treenav-mcp] ${msg}`) })
+  : undefined;
+if (queryLog) {
+  console.error(`[treenav-mcp] Query log: ${queryLog.path}`);
+  shutdown.onFlush("query log", () => queryLog.flush());
+}
+
+// Parsed code by content hash, reused across branches and worktrees — opt-in via PARSE_CACHE
+const parseCache = settings.parse_cache
+  ? new ParseCache(settings.parse_cache, { log: (msg) => console.error(`[treenav-mcp] ${msg}`) })
+  : null;
+setParseCache(parseCache);
+
+// CPU and heap profiles: capture_profile with PROFILE_DIR, /debug/pprof/ with ADMIN_PORT
+const profiler = settings.profile_dir || settings.admin_port ? new Profiler(settings.profile_dir) : undefined;
+if (profiler && settings.admin_port) {
+  try {
+    const admin = startAdminServer(profiler, { port: settings.admin_port, token: settings.http_token });
+    shutdown.onStop("admin", () => admin.stop());
+    console.error(`[treenav-mcp] Profiles: http://127.0.0.1:${admin.port}/debug/pprof/`);
+  } catch (err: any) {
+    console.error(`[treenav-mcp] Warning: admin port not started: ${err.message}`);
+  }
 }
 
+// Organization-specific tools (PLUGINS); see plugins.ts
+const plugins = settings.plugins.length
+  ? await loadPlugins(settings.plugins, config, {
+      reserved: TOOL_NAMES,
+      log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+    })
+  : undefined;
+
+// Read by every call, and updated in place on a config reload
+const timeouts = toToolTimeouts(settings);
+
 // Register all tools and resources from the shared module
-registerTools(server, store, { wiki });
+const tools = registerTools(server, store, {
+  wiki,
+  lazy,
+  coverage: new IndexCoverage(config),
+  goModules,
+  stdlib,
+  generatorLinks,
+  reranker,
+  queryLog,
+  coverProfile: settings.coverage_profile,
+  markers,
+  duplicates,
+  treeSitter,
+  structural,
+  structuralRewrite: settings.structural_rewrite,
+  regex,
+  astDiff,
+  usage,
+  hotspots,
+  codeowners,
+  licenses,
+  cycles,
+  entrypoints,
+  fields,
+  enums,
+  concurrency,
+  contextAudit,
+  embeds,
+  breadcrumbs: new Breadcrumbs(config, goModules),
+  symbolNav: true,
+  refs,
+  staleness,
+  timeouts,
+  shutdown,
+  plugins,
+  profiler: settings.profile_dir ? profiler : undefined,
+  files: new FileReader(config),
+  peek,
+  buildTargets,
+  configUsages,
+  logSources,
+  apiDiff,
+  admin,
+  resultCache,
+  editorLinks: new EditorLinks(config, settings.editor_url),
+});
+
+// Edits to the config file, and SIGHUP, apply ranking, synonyms,
+// deadlines, write mode, and file discovery without a restart; see
+// config-reload.ts. Connected clients get a log notification, and
+// notifications/tools/list_changed when write mode flips.
+const reloader = new ConfigReloader(
+  settings,
+  async () => (await loadConfig(argv)).config,
+  {
+    store,
+    config,
+    reindexable: !lazy && !settings.shard_dir,
+    timeouts,
+    setWiki: (next) => tools.setWiki(next),
+  },
+  {
+    log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+    notify: (message, report) => {
+      if (!server.isConnected()) return;
+      server.server.sendLoggingMessage({ level: "info", logger: "treenav-mcp", data: { message, ...report } }).catch(() => {});
+    },
+  }
+);
+if (loaded.file) reloader.watch(loaded.file);
+process.on("SIGHUP", () => void reloader.reload());
+shutdown.onStop("config watcher", () => reloader.stop());
 
 // ── Startup ──────────────────────────────────────────────────────────
 
 async function main() {
   console.error(`[treenav-mcp] Indexing documents from: ${docs_root}`);
 
-  // Index all documents at startup
+  // Direct Go dependencies from the module cache (INDEX_DEPENDENCIES)
+  if (settings.index_dependencies && goModules) {
+    if (lazy || settings.shard_dir) {
+      console.error("[treenav-mcp] Warning: INDEX_DEPENDENCIES is not supported with LAZY_INDEX or SHARD_DIR; not indexing dependencies");
+    } else {
+      const modcache = moduleCacheDir(settings.gomodcache);
+      // Modules vendored under an indexed vendor/ tree would be indexed twice
+      const vendored = new Set<string>();
+      if (settings.vendor_policy !== "exclude") {
+        for (const tree of await findVendorTrees(config, goModules)) for (const m of tree.modules) vendored.add(m);
+      }
+      const deps = await dependencyCollections(goModules, modcache, { vendored });
+      config.dependency_collections = deps.collections;
+      const missing = deps.missing.length ? `; not in the cache (run go mod download): ${deps.missing.join(", ")}` : "";
+      const skipped = deps.vendored.length ? `; ${deps.vendored.length} already vendored` : "";
+      console.error(`[treenav-mcp] Dependencies: ${deps.collections.length} module(s) from ${modcache}${missing}${skipped}`);
+    }
+  }
+
+  // Index all documents at startup — lazily (LAZY_INDEX), from shards
+  // (SHARD_DIR), or warm-started from a cached index (INDEX_CACHE) that
+  // is re-validated in the background. A cold start indexes priority
+  // files (PRIORITY_FILES, PRIORITY_RECENT_COMMITS) first; see warmup.ts
   const startTime = Date.now();
-  const documents = await indexAllCollections(config);
-  store.load(documents);
+  const cachePath = resolve(settings.index_cache || DEFAULT_INDEX_CACHE_PATH);
+  let warm = false;
+  let priority = 0;
+  if ((lazy || settings.shard_dir) && toPriorityOptions(settings)) {
+    console.error("[treenav-mcp] Warning: PRIORITY_FILES and PRIORITY_RECENT_COMMITS are not supported with LAZY_INDEX or SHARD_DIR; ignoring them");
+  }
+  if (lazy) {
+    await lazy.init();
+  } else if (settings.shard_dir) {
+    await loadOrBuildShards(store, config, settings.shard_dir, {
+      only: settings.shards,
+      compression: settings.index_compression,
+      log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+    });
+  } else {
+    ({ warm, priority } = await loadOrBuildIndex(store, config, {
+      cachePath,
+      persist: !!settings.index_cache,
+      priority: toPriorityOptions(settings),
+      compression: settings.index_compression,
+      log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+    }));
+  }
 
   // Load glossary if present (glossary.json in docs root)
-  const glossaryPath = process.env.GLOSSARY_PATH || join(docs_root, "glossary.json");
+  const glossaryPath = settings.glossary_path || join(docs_root, "glossary.json");
   if (existsSync(glossaryPath)) {
     try {
       const glossaryData = await Bun.file(glossaryPath).json();
@@ -100,12 +406,73 @@ async function main() {
       console.error(`[treenav-mcp] Warning: Failed to load glossary from ${glossaryPath}: ${err.message}`);
     }
   }
+  store.loadSynonyms(parseSynonymGroups(settings.synonyms));
+  store.setPathBoosts([...parsePathBoosts(settings.path_boosts), ...vendorBoosts(settings.vendor_policy)]);
+  store.setRanking({
+    reference_weight: settings.reference_weight,
+    coverage_weight: settings.coverage_weight,
+    max_per_file: settings.max_per_file,
+    max_per_package: settings.max_per_package,
+  });
+  store.setByteOffsets(settings.byte_offsets);
+  store.setGeneratedPolicy(settings.generated_policy);
+  store.setCommentMatching({ matching: settings.comment_matching, languages: settings.comment_languages });
+  store.setPathMatching(await pathMatching(config));
+  if (settings.ranking_wasm) {
+    try {
+      store.setRescorer(await loadRankingWasm(settings.ranking_wasm, { log: (msg) => console.error(`[treenav-mcp] ${msg}`) }));
+      console.error(`[treenav-mcp] Ranking hook: ${settings.ranking_wasm}`);
+    } catch (err: any) {
+      console.error(`[treenav-mcp] Warning: RANKING_WASM not loaded, using built-in ranking: ${err.message}`);
+    }
+  }
+  if (settings.recency_weight > 0) {
+    const files = applyRecencyBoost(store, config, settings.recency_weight, settings.recency_half_life_days);
+    console.error(`[treenav-mcp] Recency boost: commit times for ${files} files`);
+  }
+  if (settings.coverage_profile) {
+    try {
+      const cover = await loadCoverProfile(store, config, settings.coverage_profile, goModules);
+      const outside = cover.unresolved ? `, ${cover.unresolved} files outside the code roots` : "";
+      console.error(`[treenav-mcp] Coverage: ${cover.blocks} blocks in ${cover.files} files (mode ${cover.mode})${outside}`);
+    } catch (err: any) {
+      console.error(`[treenav-mcp] Warning: Failed to load coverage profile ${settings.coverage_profile}: ${err.message}`);
+    }
+  }
 
   const elapsed = ((Date.now() - startTime) / 1000).toFixed(1);
   const stats = store.getStats();
   console.error(
-    `[treenav-mcp] Ready in ${elapsed}s — ${stats.document_count} docs, ${stats.total_nodes} sections, ${stats.indexed_terms} terms`
+    `[treenav-mcp] Ready in ${elapsed}s${warm ? " (warm start)" : priority ? ` (${priority} priority files; indexing the rest)` : ""} — ${stats.document_count} docs, ${stats.total_nodes} sections, ${stats.indexed_terms} terms`
   );
+  if (parseCache) {
+    const { hits, misses } = parseCache.stats();
+    console.error(`[treenav-mcp] Parse cache ${parseCache.dir}: ${hits} reused, ${misses} parsed`);
+  }
+
+  // Keep the index in step with the working tree (WATCH=1)
+  if (settings.watch) {
+    if (lazy || settings.shard_dir) {
+      console.error("[treenav-mcp] Warning: WATCH is not supported with LAZY_INDEX or SHARD_DIR; not watching");
+    } else {
+      const watcher = new IndexWatcher(store, config, {
+        debounceMs: settings.watch_debounce_ms,
+        batchSize: settings.watch_batch_size,
+        log: (msg) => console.error(`[treenav-mcp] ${msg}`),
+      });
+      watcher.start();
+      // On shutdown, apply what is pending and persist it so the next
+      // start does not serve a cache from before these edits
+      shutdown.onStop("watcher", () => watcher.stop());
+      shutdown.onFlush("pending changes", () => watcher.flush());
+      if (settings.index_cache || warm) {
+        shutdown.onFlush("index cache", async () => {
+          const written = await flushIndexCache(store, config, cachePath, { compression: settings.index_compression });
+          console.error(`[treenav-mcp] ${written ? `Index cache written to ${cachePath}` : "Re-validation still running; kept the existing index cache"}`);
+        });
+      }
+    }
+  }
 
   // Connect via stdio transport
   const transport = new StdioServerTransport();
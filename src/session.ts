@@ -0,0 +1,102 @@
+/**
+ * Session-scoped preferences per MCP client
+ *
+ * Agents tend to repeat the same arguments on every call — the same
+ * language filter, the same result limit, the same corner of the repo.
+ * A SessionState holds those defaults for one client session; the
+ * set_preferences tool updates it and the read tools fall back to it
+ * whenever an argument is omitted. Explicit arguments always win.
+ *
+ * A session can also pin the index generation its reads answer from
+ * (set_preferences snapshot), so a plan spanning several calls does not
+ * see the watcher re-index files halfway through. The pin only moves
+ * when the client pins again.
+ *
+ * One SessionState lives per registerTools() call. Over stdio that is
+ * the whole client connection; the HTTP server keeps one per
+ * `mcp-session-id` header so stateless requests can share it.
+ */
+
+export interface SessionPreferences {
+  /** Default `language` filter for find_symbol (OR across the list) */
+  languages?: string[];
+  /** Default result limit for list/search tools */
+  limit?: number;
+  /** Directory prefix (relative file_path) that list/search tools stay inside */
+  focus?: string;
+  /** Editor preset or link template for `editor_url` (see editor-links.ts) */
+  editor_url?: string;
+}
+
+export class SessionState {
+  private prefs: SessionPreferences = {};
+  /** Pinned store generation (DocumentStore.pinGeneration), or null to read the live index */
+  private generation: number | null = null;
+
+  get(): SessionPreferences {
+    return { ...this.prefs };
+  }
+
+  /**
+   * Merge `update` into the current preferences. An empty string or
+   * empty array clears that preference; undefined leaves it alone.
+   */
+  set(update: SessionPreferences): SessionPreferences {
+    if (update.languages !== undefined) {
+      const languages = update.languages.map((l) => l.toLowerCase()).filter(Boolean);
+      if (languages.length > 0) this.prefs.languages = languages;
+      else delete this.prefs.languages;
+    }
+    if (update.limit !== undefined) {
+      this.prefs.limit = update.limit;
+    }
+    if (update.focus !== undefined) {
+      const focus = normalizeFocus(update.focus);
+      if (focus) this.prefs.focus = focus;
+      else delete this.prefs.focus;
+    }
+    if (update.editor_url !== undefined) {
+      const editor = update.editor_url.trim();
+      if (editor) this.prefs.editor_url = editor;
+      else delete this.prefs.editor_url;
+    }
+    return this.get();
+  }
+
+  clear(): void {
+    this.prefs = {};
+    this.generation = null;
+  }
+
+  /** The generation reads are pinned to, or null. */
+  pinned(): number | null {
+    return this.generation;
+  }
+
+  /** Pin reads to `generation`, or follow the live index again with null. */
+  pin(generation: number | null): void {
+    this.generation = generation;
+  }
+
+  /** Resolve a tool's limit: explicit arg, then session default, then fallback. */
+  limit(explicit: number | undefined, fallback: number, max: number): number {
+    return Math.min(explicit ?? this.prefs.limit ?? fallback, max);
+  }
+
+  /** One-line description for tool output, or "" when nothing is set. */
+  describe(): string {
+    const parts: string[] = [];
+    if (this.prefs.focus) parts.push(`focus: ${this.prefs.focus}`);
+    if (this.prefs.languages) parts.push(`languages: ${this.prefs.languages.join(", ")}`);
+    if (this.prefs.limit) parts.push(`limit: ${this.prefs.limit}`);
+    if (this.prefs.editor_url) parts.push(`editor: ${this.prefs.editor_url}`);
+    if (this.generation !== null) parts.push(`snapshot: generation ${this.generation}`);
+    return parts.join(" | ");
+  }
+}
+
+function normalizeFocus(focus: string): string {
+  const trimmed = focus.trim().replace(/\\/g, "/").replace(/^\.?\/+/, "");
+  if (!trimmed) return "";
+  return trimmed.endsWith("/") ? trimmed : `${trimmed}/`;
+}
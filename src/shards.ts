@@ -0,0 +1,428 @@
+/**
+ * Sharded index — per-top-level-directory slices of the corpus
+ *
+ * A monorepo index is one big document set, so touching one package
+ * means re-indexing (and re-persisting) everything. Shards split the
+ * documents of each collection by their top-level directory:
+ *
+ *   <shard dir>/manifest.json          — which shards exist, when built
+ *   <shard dir>/<collection>/<shard>.json — one IndexCacheFile per shard,
+ *                                       compressed with INDEX_COMPRESSION
+ *
+ * File and directory names are the names made filename-safe plus a hash
+ * of the raw name (shardFile), so `a b`, `a_b`, and names differing only
+ * in case or in non-ASCII letters never share a file.
+ *
+ * Each shard is built and persisted on its own, so a partial rebuild
+ * only re-parses the shards named. Loading reads all shard files in
+ * parallel and feeds them to one DocumentStore.load(), which rebuilds
+ * the positional index, facets, and glossary in memory as usual.
+ *
+ * Every document carries a `shard` facet (`<collection>/<shard>`), so
+ * queries can target a shard subset through the ordinary facet filters.
+ *
+ * Shards are also the unit of distributed builds. assignShards() splits
+ * the shards across N workers, largest first by source bytes onto the
+ * least-loaded worker, and is deterministic, so workers on different
+ * machines that see the same tree agree on the split without talking to
+ * each other. A worker (buildShardSlice) writes only its shard files,
+ * never the manifest, so workers sharing a shard directory cannot race.
+ * mergeShards() then checks every expected shard file against the
+ * config and writes the manifest. buildShardsDistributed() is the local
+ * coordinator: it runs the workers in parallel processes, merges, and
+ * builds any shard a failed worker left behind itself.
+ */
+
+import { existsSync, statSync } from "node:fs";
+import { mkdir, rename } from "node:fs/promises";
+import { join } from "node:path";
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import type { DocumentStore } from "./store";
+import { indexFile, listCollectionFiles } from "./indexer";
+import { indexCodeFile, listCodeFiles } from "./code-indexer";
+import { configFingerprint, loadIndexCache, saveIndexCache } from "./index-cache";
+import type { IndexCompression } from "./index-blocks";
+import { relativePath } from "./paths";
+
+export const SHARD_MANIFEST_VERSION = 2;
+
+/** Shard name for files sitting directly under a collection root; no directory can have it */
+export const ROOT_SHARD = ".";
+
+export interface ShardManifestEntry {
+  /** `<collection>/<shard>` — also the value of the `shard` facet */
+  id: string;
+  collection: string;
+  shard: string;
+  /** Shard file path, relative to the shard directory */
+  file: string;
+  document_count: number;
+  built_at: string;
+}
+
+export interface ShardManifest {
+  version: number;
+  config_fingerprint: string;
+  shards: ShardManifestEntry[];
+}
+
+/** Top-level directory of a collection-relative path, or ROOT_SHARD. */
+export function shardOf(relPath: string): string {
+  const parts = relPath.split(/[/\\]/);
+  return parts.length > 1 ? parts[0] : ROOT_SHARD;
+}
+
+export function shardId(collection: string, shard: string): string {
+  return `${collection}/${shard}`;
+}
+
+/** The shard's file, relative to the shard directory; distinct names never share one. */
+export function shardFile(collection: string, shard: string): string {
+  const safe = (s: string) => `${s.replace(/^\.+|[^\w.-]/g, "_")}-${Bun.hash(s).toString(16)}`;
+  return join(safe(collection), `${safe(shard)}.json`);
+}
+
+// ── Manifest ────────────────────────────────────────────────────────
+
+/** Read the manifest in `dir`, or null if missing or built for another config. */
+export async function readShardManifest(
+  dir: string,
+  config: IndexConfig
+): Promise<ShardManifest | null> {
+  const path = join(dir, "manifest.json");
+  if (!existsSync(path)) return null;
+
+  try {
+    const manifest = (await Bun.file(path).json()) as ShardManifest;
+    if (manifest.version !== SHARD_MANIFEST_VERSION) return null;
+    if (manifest.config_fingerprint !== configFingerprint(config)) return null;
+    return manifest;
+  } catch {
+    return null;
+  }
+}
+
+async function writeShardManifest(dir: string, manifest: ShardManifest): Promise<void> {
+  await mkdir(dir, { recursive: true });
+  const path = join(dir, "manifest.json");
+  const tmp = `${path}.tmp-${process.pid}`;
+  await Bun.write(tmp, JSON.stringify(manifest, null, 2));
+  await rename(tmp, path);
+}
+
+// ── Build ───────────────────────────────────────────────────────────
+
+interface ShardPlan {
+  id: string;
+  collection: CollectionConfig;
+  kind: "markdown" | "code";
+  shard: string;
+  files: string[];
+  /** Source size, the work estimate for assignShards */
+  bytes: number;
+}
+
+/** Every shard the configured collections have right now, by id. */
+async function planShards(config: IndexConfig): Promise<ShardPlan[]> {
+  const sources: { collection: CollectionConfig; kind: "markdown" | "code" }[] = [
+    ...config.collections.map((collection) => ({ collection, kind: "markdown" as const })),
+    ...(config.code_collections ?? []).map((collection) => ({ collection, kind: "code" as const })),
+  ];
+
+  const plans: ShardPlan[] = [];
+  for (const { collection, kind } of sources) {
+    const files = kind === "markdown"
+      ? await listCollectionFiles(collection)
+      : await listCodeFiles(collection);
+
+    const groups = new Map<string, ShardPlan>();
+    for (const file of files) {
+      const shard = shardOf(relativePath(collection.root, file));
+      let plan = groups.get(shard);
+      if (!plan) {
+        plan = { id: shardId(collection.name, shard), collection, kind, shard, files: [], bytes: 0 };
+        groups.set(shard, plan);
+        plans.push(plan);
+      }
+      plan.files.push(file);
+      plan.bytes += statSync(file, { throwIfNoEntry: false })?.size ?? 0;
+    }
+  }
+  return plans;
+}
+
+/** Index one shard and persist it to `dir`. */
+async function buildShard(
+  plan: ShardPlan,
+  config: IndexConfig,
+  dir: string,
+  log: (msg: string) => void,
+  compression?: IndexCompression
+): Promise<ShardManifestEntry> {
+  const docs = tagShard(await indexShardFiles(plan.files, plan.collection, plan.kind, log), plan.id);
+  const file = shardFile(plan.collection.name, plan.shard);
+  await saveIndexCache(join(dir, file), config, docs, { compression });
+  log(`[shard ${plan.id}] ${docs.length} documents`);
+  return {
+    id: plan.id,
+    collection: plan.collection.name,
+    shard: plan.shard,
+    file,
+    document_count: docs.length,
+    built_at: new Date().toISOString(),
+  };
+}
+
+/**
+ * Index the configured collections shard by shard and persist each
+ * shard to `dir`. With `only`, just those shard ids are rebuilt and the
+ * rest of an existing manifest is kept — a partial rebuild. Shards whose
+ * directory no longer has any files are dropped from the manifest.
+ */
+export async function buildShards(
+  config: IndexConfig,
+  dir: string,
+  options: { only?: string[]; compression?: IndexCompression; log?: (msg: string) => void } = {}
+): Promise<ShardManifest> {
+  const log = options.log ?? ((msg: string) => console.log(msg));
+  const only = options.only?.length ? new Set(options.only) : null;
+  const previous = only ? await readShardManifest(dir, config) : null;
+
+  const entries = new Map<string, ShardManifestEntry>();
+  for (const entry of previous?.shards ?? []) entries.set(entry.id, entry);
+
+  const plans = await planShards(config);
+  for (const plan of plans) {
+    if (only && !only.has(plan.id)) continue;
+    entries.set(plan.id, await buildShard(plan, config, dir, log, options.compression));
+  }
+
+  const seen = new Set(plans.map((p) => p.id));
+  for (const id of entries.keys()) {
+    if (!seen.has(id)) entries.delete(id);
+  }
+
+  const manifest: ShardManifest = {
+    version: SHARD_MANIFEST_VERSION,
+    config_fingerprint: configFingerprint(config),
+    shards: [...entries.values()].sort((a, b) => a.id.localeCompare(b.id)),
+  };
+  await writeShardManifest(dir, manifest);
+  return manifest;
+}
+
+// ── Distributed build ───────────────────────────────────────────────
+
+/**
+ * Split shards across `count` workers: largest first, each onto the
+ * worker with the least work so far (ties to the lowest worker). The
+ * same input always gives the same split. Returns shard ids per worker.
+ */
+export function assignShards(shards: { id: string; bytes: number }[], count: number): string[][] {
+  const workers = Array.from({ length: count }, () => ({ bytes: 0, ids: [] as string[] }));
+  const ordered = [...shards].sort((a, b) => b.bytes - a.bytes || a.id.localeCompare(b.id));
+  for (const shard of ordered) {
+    let target = workers[0];
+    for (const w of workers) if (w.bytes < target.bytes) target = w;
+    target.ids.push(shard.id);
+    target.bytes += shard.bytes;
+  }
+  return workers.map((w) => w.ids.sort());
+}
+
+/**
+ * Build worker `worker`'s share (1-based, of `count`) of the shards into
+ * `dir`. Writes shard files only; run mergeShards once every worker is
+ * done.
+ */
+export async function buildShardSlice(
+  config: IndexConfig,
+  dir: string,
+  worker: number,
+  count: number,
+  options: { compression?: IndexCompression; log?: (msg: string) => void } = {}
+): Promise<ShardManifestEntry[]> {
+  if (!Number.isInteger(count) || count < 1 || !Number.isInteger(worker) || worker < 1 || worker > count) {
+    throw new RangeError(`Worker ${worker} of ${count}: expected 1 <= worker <= count`);
+  }
+  const log = options.log ?? ((msg: string) => console.log(msg));
+  const plans = await planShards(config);
+  const mine = new Set(assignShards(plans, count)[worker - 1]);
+  const entries: ShardManifestEntry[] = [];
+  for (const plan of plans) {
+    if (mine.has(plan.id)) entries.push(await buildShard(plan, config, dir, log, options.compression));
+  }
+  return entries;
+}
+
+/**
+ * Write the manifest for the shard files in `dir`. Every shard the tree
+ * has now must have a file built for this config (and, with
+ * `builtAfter`, written since then); the ones that do not are left out
+ * of the manifest and returned as missing.
+ */
+export async function mergeShards(
+  config: IndexConfig,
+  dir: string,
+  options: { builtAfter?: number } = {}
+): Promise<{ manifest: ShardManifest; missing: string[] }> {
+  const shards: ShardManifestEntry[] = [];
+  const missing: string[] = [];
+  for (const plan of await planShards(config)) {
+    const file = shardFile(plan.collection.name, plan.shard);
+    const path = join(dir, file);
+    const built = statSync(path, { throwIfNoEntry: false });
+    const docs = built && built.mtimeMs >= (options.builtAfter ?? 0) ? await loadIndexCache(path, config) : null;
+    if (!docs) {
+      missing.push(plan.id);
+      continue;
+    }
+    shards.push({
+      id: plan.id,
+      collection: plan.collection.name,
+      shard: plan.shard,
+      file,
+      document_count: docs.length,
+      built_at: built!.mtime.toISOString(),
+    });
+  }
+
+  const manifest: ShardManifest = {
+    version: SHARD_MANIFEST_VERSION,
+    config_fingerprint: configFingerprint(config),
+    shards: shards.sort((a, b) => a.id.localeCompare(b.id)),
+  };
+  await writeShardManifest(dir, manifest);
+  return { manifest, missing };
+}
+
+/** Runs worker `worker` of `count` (1-based) and resolves to its exit code. */
+export type ShardWorkerRunner = (worker: number, count: number) => Promise<number>;
+
+/**
+ * Coordinator: run `workers` shard builders in parallel through `run`
+ * (one process each, typically), merge their shard files, and build
+ * whatever a failed worker did not deliver in this process.
+ */
+export async function buildShardsDistributed(
+  config: IndexConfig,
+  dir: string,
+  options: { workers: number; run: ShardWorkerRunner; compression?: IndexCompression; log?: (msg: string) => void }
+): Promise<{ manifest: ShardManifest; failed_workers: number[]; rebuilt: string[] }> {
+  const log = options.log ?? ((msg: string) => console.log(msg));
+  const started = Date.now();
+  const codes = await Promise.all(
+    Array.from({ length: options.workers }, (_, i) => options.run(i + 1, options.workers).catch(() => 1))
+  );
+  const failed_workers = codes.flatMap((code, i) => (code === 0 ? [] : [i + 1]));
+  for (const w of failed_workers) log(`Worker ${w}/${options.workers} failed`);
+
+  // Allow for filesystems that store mtimes at a coarser grain
+  let { manifest, missing } = await mergeShards(config, dir, { builtAfter: started - 2000 });
+  if (missing.length > 0) {
+    log(`Building ${missing.length} shard(s) no worker delivered: ${missing.join(", ")}`);
+    manifest = await buildShards(config, dir, { only: missing, compression: options.compression, log });
+  }
+  return { manifest, failed_workers, rebuilt: missing };
+}
+
+// ── Load ────────────────────────────────────────────────────────────
+
+/**
+ * Load the shards listed in the manifest (or just `only`) into `store`,
+ * reading all shard files in parallel. Returns the ids that loaded and
+ * those that were missing or stale, or null when there is no usable
+ * manifest.
+ */
+export async function loadShards(
+  store: DocumentStore,
+  config: IndexConfig,
+  dir: string,
+  options: { only?: string[] } = {}
+): Promise<{ loaded: string[]; missing: string[] } | null> {
+  const manifest = await readShardManifest(dir, config);
+  if (!manifest) return null;
+
+  const only = options.only?.length ? new Set(options.only) : null;
+  const wanted = manifest.shards.filter((s) => !only || only.has(s.id));
+  const missing = only ? [...only].filter((id) => !manifest.shards.some((s) => s.id === id)) : [];
+
+  const results = await Promise.all(
+    wanted.map(async (entry) => ({
+      entry,
+      docs: await loadIndexCache(join(dir, entry.file), config),
+    }))
+  );
+
+  const documents: IndexedDocument[] = [];
+  const loaded: string[] = [];
+  for (const { entry, docs } of results) {
+    if (!docs) {
+      missing.push(entry.id);
+      continue;
+    }
+    documents.push(...tagShard(docs, entry.id));
+    loaded.push(entry.id);
+  }
+
+  store.load(documents);
+  return { loaded, missing };
+}
+
+/**
+ * Startup helper shared by the stdio and HTTP servers: load shards from
+ * `dir`, building any that are missing or stale first.
+ */
+export async function loadOrBuildShards(
+  store: DocumentStore,
+  config: IndexConfig,
+  dir: string,
+  options: { only?: string[]; compression?: IndexCompression; log?: (msg: string) => void } = {}
+): Promise<string[]> {
+  const log = options.log ?? ((msg: string) => console.error(msg));
+  let result = await loadShards(store, config, dir, { only: options.only });
+
+  if (!result || result.missing.length > 0) {
+    log(`Building shards in ${dir}${result ? `: ${result.missing.join(", ")}` : ""}`);
+    await buildShards(config, dir, { only: result?.missing, compression: options.compression, log });
+    result = await loadShards(store, config, dir, { only: options.only });
+  }
+
+  const loaded = result?.loaded ?? [];
+  log(`Loaded ${loaded.length} shard(s) from ${dir}`);
+  return loaded;
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────
+
+function tagShard(docs: IndexedDocument[], id: string): IndexedDocument[] {
+  for (const doc of docs) {
+    doc.meta.facets = { ...doc.meta.facets, shard: [id] };
+  }
+  return docs;
+}
+
+async function indexShardFiles(
+  files: string[],
+  collection: CollectionConfig,
+  kind: "markdown" | "code",
+  log: (msg: string) => void
+): Promise<IndexedDocument[]> {
+  const { root, name } = collection;
+  const BATCH_SIZE = 50;
+  const results: IndexedDocument[] = [];
+
+  for (let i = 0; i < files.length; i += BATCH_SIZE) {
+    const batch = files.slice(i, i + BATCH_SIZE);
+    const indexed = await Promise.all(
+      batch.map((f) =>
+        (kind === "markdown" ? indexFile(f, root, name) : indexCodeFile(f, root, name)).catch((err) => {
+          log(`Failed to index ${f}: ${err.message}`);
+          return null;
+        })
+      )
+    );
+    results.push(...(indexed.filter(Boolean) as IndexedDocument[]));
+  }
+  return results;
+}
@@ -0,0 +1,130 @@
+/**
+ * Graceful shutdown — SIGTERM and SIGINT
+ *
+ * Killing the server mid-call used to cut a tool off wherever it was,
+ * including halfway through writing a file, and dropped whatever the
+ * watcher had not applied yet. On a signal the server now:
+ *
+ *   1. stops accepting work: new tool calls fail with a shutdown error,
+ *      and listeners and watchers registered with onStop() close
+ *   2. drains: in-flight calls get SHUTDOWN_GRACE_MS to finish; the
+ *      ones still running are cancelled through their Deadline, so
+ *      scans stop and answer with what they have
+ *   3. flushes: onFlush() steps run in order, e.g. apply pending watch
+ *      changes and persist the index cache
+ *   4. exits 0, or 1 when a step failed
+ *
+ * A second signal exits at once.
+ */
+
+export const DEFAULT_SHUTDOWN_GRACE_MS = 10_000;
+
+/** How long cancelled calls get to answer before the flush goes ahead anyway */
+const CANCEL_WAIT_MS = 1_000;
+
+type Step = { name: string; run: () => unknown };
+
+export class Shutdown {
+  private readonly calls = new Map<Promise<unknown>, () => void>();
+  private readonly stops: Step[] = [];
+  private readonly flushes: Step[] = [];
+  private closing: Promise<boolean> | null = null;
+  private readonly graceMs: number;
+  private readonly log: (msg: string) => void;
+
+  constructor(options: { graceMs?: number; log?: (msg: string) => void } = {}) {
+    this.graceMs = options.graceMs ?? DEFAULT_SHUTDOWN_GRACE_MS;
+    this.log = options.log ?? ((msg) => console.error(msg));
+  }
+
+  /** True once shutdown has begun; no new calls are accepted. */
+  get draining(): boolean {
+    return this.closing !== null;
+  }
+
+  /** Number of calls still running */
+  inFlight(): number {
+    return this.calls.size;
+  }
+
+  /** Track an in-flight call; `cancel` is called if it outlives the grace period. */
+  track<T>(call: Promise<T>, cancel: () => void): Promise<T> {
+    this.calls.set(call, cancel);
+    const forget = () => this.calls.delete(call);
+    call.then(forget, forget);
+    return call;
+  }
+
+  /** Run `run` as soon as shutdown begins: close listeners, stop watchers. */
+  onStop(name: string, run: () => unknown): void {
+    this.stops.push({ name, run });
+  }
+
+  /** Run `run` after in-flight calls drained, in registration order: persist state. */
+  onFlush(name: string, run: () => unknown): void {
+    this.flushes.push({ name, run });
+  }
+
+  /**
+   * Stop, drain, and flush. Resolves true when every step succeeded.
+   * Calling it again returns the same shutdown.
+   */
+  run(reason: string): Promise<boolean> {
+    this.closing ??= this.close(reason);
+    return this.closing;
+  }
+
+  /** Shut down on SIGTERM and SIGINT, then exit; a second signal exits at once. */
+  install(): void {
+    const onSignal = (signal: NodeJS.Signals) => {
+      if (this.draining) {
+        this.log(`${signal} again; exiting without waiting`);
+        process.exit(1);
+      }
+      void this.run(signal).then((ok) => process.exit(ok ? 0 : 1));
+    };
+    process.on("SIGTERM", onSignal);
+    process.on("SIGINT", onSignal);
+  }
+
+  private async close(reason: string): Promise<boolean> {
+    let ok = await this.steps(this.stops);
+
+    const running = this.calls.size;
+    this.log(`${reason}: shutting down${running ? `, waiting up to ${this.graceMs}ms for ${running} in-flight call(s)` : ""}`);
+    if (running && !(await this.settle(this.graceMs))) {
+      this.log(`Cancelling ${this.calls.size} call(s) still running after ${this.graceMs}ms`);
+      for (const cancel of this.calls.values()) cancel();
+      if (!(await this.settle(CANCEL_WAIT_MS))) this.log(`${this.calls.size} call(s) did not stop; flushing anyway`);
+    }
+
+    ok = (await this.steps(this.flushes)) && ok;
+    this.log(ok ? "Shutdown complete" : "Shutdown complete with errors");
+    return ok;
+  }
+
+  /** Wait up to `ms` for the tracked calls; true when they all settled. */
+  private async settle(ms: number): Promise<boolean> {
+    let timer: ReturnType<typeof setTimeout> | undefined;
+    const timeout = new Promise<false>((resolve) => {
+      timer = setTimeout(() => resolve(false), ms);
+    });
+    const settled = Promise.allSettled([...this.calls.keys()]).then(() => true);
+    const done = await Promise.race([settled, timeout]);
+    clearTimeout(timer);
+    return done;
+  }
+
+  private async steps(steps: Step[]): Promise<boolean> {
+    let ok = true;
+    for (const step of steps) {
+      try {
+        await step.run();
+      } catch (err: any) {
+        ok = false;
+        this.log(`Warning: shutdown step "${step.name}" failed: ${err?.message ?? err}`);
+      }
+    }
+    return ok;
+  }
+}
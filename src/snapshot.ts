@@ -0,0 +1,220 @@
+/**
+ * Portable index snapshots — build once in CI, import anywhere
+ *
+ * The INDEX_CACHE artifact is tied to the machine that wrote it: its
+ * config fingerprint includes absolute collection roots, so a copy on a
+ * laptop with the repository checked out elsewhere is discarded. A
+ * snapshot carries the same documents (all paths in them are relative
+ * to a collection root) plus what is needed to judge, on the importing
+ * machine, whether they describe the same corpus:
+ *
+ *   - snapshot and cache versions, checked exactly;
+ *   - per collection: name, kind, glob, include patterns, and symlink
+ *     policy, plus max_depth and summary_length. These must match the
+ *     local config, or the documents would not be what a local index
+ *     run produces.
+ *   - per collection: the root's path inside its git repository, the
+ *     HEAD commit, and whether the tree was dirty at export.
+ *
+ * A different commit (or a dirty export) is drift, not incompatibility:
+ * the documents are valid but possibly behind the working tree. Import
+ * refuses drift unless forced; forced or not, the imported artifact is
+ * re-validated by the server's warm start, which re-parses whatever
+ * differs. Import writes a regular INDEX_CACHE fingerprinted for the
+ * local roots, so `serve` and `search` use it as-is.
+ *
+ * The file is gzipped JSON.
+ */
+
+import { mkdir, rename } from "node:fs/promises";
+import { existsSync } from "node:fs";
+import { dirname, resolve } from "node:path";
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import { INDEX_CACHE_VERSION, saveIndexCache } from "./index-cache";
+import { DEFAULT_SYMLINK_POLICY } from "./walk";
+import type { IndexCompression } from "./index-blocks";
+
+/** Bump whenever the snapshot envelope changes. */
+export const SNAPSHOT_VERSION = 1;
+
+/** Default snapshot location, relative to the working directory. */
+export const DEFAULT_SNAPSHOT_PATH = ".treenav/snapshot.json.gz";
+
+/** Unreadable, incompatible, or (unless forced) drifted snapshots. */
+export class SnapshotError extends Error {}
+
+export interface SnapshotCollection {
+  name: string;
+  kind: "markdown" | "code";
+  glob: string;
+  include: string[];
+  symlinks: string;
+  /** Root relative to its repository's top level ("" at the top); null outside git */
+  repo_path: string | null;
+  /** HEAD of the repository at export; null outside git */
+  commit: string | null;
+  /** Uncommitted changes under the root at export */
+  dirty: boolean;
+}
+
+export interface SnapshotFile {
+  format: "treenav-snapshot";
+  snapshot_version: number;
+  index_cache_version: number;
+  created_at: string;
+  max_depth: number;
+  summary_length: number;
+  collections: SnapshotCollection[];
+  documents: IndexedDocument[];
+}
+
+/** Outcome of checking a snapshot against the local config and repository. */
+export interface SnapshotCheck {
+  /** Reasons the documents cannot be used with this config */
+  incompatible: string[];
+  /** Reasons they may be behind the working tree */
+  drift: string[];
+}
+
+function git(root: string, args: string[]): string | null {
+  let result;
+  try {
+    result = Bun.spawnSync(["git", "-C", root, ...args], { stdout: "pipe", stderr: "ignore" });
+  } catch {
+    return null;
+  }
+  return result.success ? result.stdout.toString().trim() : null;
+}
+
+function collectionsOf(config: IndexConfig): Array<{ collection: CollectionConfig; kind: "markdown" | "code" }> {
+  return [
+    ...config.collections.map((collection) => ({ collection, kind: "markdown" as const })),
+    ...(config.code_collections ?? []).map((collection) => ({ collection, kind: "code" as const })),
+  ];
+}
+
+/** Where each collection of `config` stands in git right now. */
+export function describeCollections(config: IndexConfig): SnapshotCollection[] {
+  return collectionsOf(config).map(({ collection, kind }) => {
+    const root = resolve(collection.root);
+    const commit = git(root, ["rev-parse", "--verify", "--quiet", "HEAD"]);
+    return {
+      name: collection.name,
+      kind,
+      glob: collection.glob_pattern ?? "",
+      include: collection.include ?? [],
+      symlinks: collection.symlinks ?? DEFAULT_SYMLINK_POLICY,
+      repo_path: commit ? (git(root, ["rev-parse", "--show-prefix"]) ?? "").replace(/\/$/, "") : null,
+      commit,
+      dirty: commit ? (git(root, ["status", "--porcelain", "--", "."]) ?? "") !== "" : false,
+    };
+  });
+}
+
+/** Write `documents` as a snapshot of `config`'s collections to `path`. */
+export async function exportSnapshot(
+  path: string,
+  config: IndexConfig,
+  documents: IndexedDocument[]
+): Promise<SnapshotFile> {
+  const snapshot: SnapshotFile = {
+    format: "treenav-snapshot",
+    snapshot_version: SNAPSHOT_VERSION,
+    index_cache_version: INDEX_CACHE_VERSION,
+    created_at: new Date().toISOString(),
+    max_depth: config.max_depth,
+    summary_length: config.summary_length,
+    collections: describeCollections(config),
+    documents,
+  };
+  await mkdir(dirname(path), { recursive: true });
+  const tmp = `${path}.tmp-${process.pid}`;
+  await Bun.write(tmp, Bun.gzipSync(Buffer.from(JSON.stringify(snapshot))));
+  await rename(tmp, path);
+  return snapshot;
+}
+
+/** Read a snapshot written by exportSnapshot. Throws SnapshotError. */
+export async function readSnapshot(path: string): Promise<SnapshotFile> {
+  if (!existsSync(path)) throw new SnapshotError(`No snapshot at ${path}`);
+  let snapshot: SnapshotFile;
+  try {
+    const bytes = await Bun.file(path).bytes();
+    const json = bytes[0] === 0x1f && bytes[1] === 0x8b ? Bun.gunzipSync(bytes) : bytes;
+    snapshot = JSON.parse(Buffer.from(json).toString("utf-8"));
+  } catch {
+    throw new SnapshotError(`${path} is not a readable snapshot`);
+  }
+  if (snapshot?.format !== "treenav-snapshot" || !Array.isArray(snapshot.documents)) {
+    throw new SnapshotError(`${path} is not a treenav snapshot`);
+  }
+  return snapshot;
+}
+
+/** Compare a snapshot with the local config and the repositories under it. */
+export function checkSnapshot(snapshot: SnapshotFile, config: IndexConfig): SnapshotCheck {
+  const incompatible: string[] = [];
+  const drift: string[] = [];
+  if (snapshot.snapshot_version !== SNAPSHOT_VERSION) {
+    incompatible.push(`snapshot version ${snapshot.snapshot_version}, expected ${SNAPSHOT_VERSION}`);
+  }
+  if (snapshot.index_cache_version !== INDEX_CACHE_VERSION) {
+    incompatible.push(`index schema version ${snapshot.index_cache_version}, expected ${INDEX_CACHE_VERSION}`);
+  }
+  if (snapshot.max_depth !== config.max_depth || snapshot.summary_length !== config.summary_length) {
+    incompatible.push(
+      `built with max_depth ${snapshot.max_depth} and summary_length ${snapshot.summary_length}, ` +
+        `configured ${config.max_depth} and ${config.summary_length}`
+    );
+  }
+
+  const local = describeCollections(config);
+  const names = (cs: SnapshotCollection[]) => cs.map((c) => `${c.name} (${c.kind})`).sort().join(", ");
+  if (names(local) !== names(snapshot.collections)) {
+    incompatible.push(`collections ${names(snapshot.collections) || "none"}, configured ${names(local) || "none"}`);
+    return { incompatible, drift };
+  }
+
+  for (const here of local) {
+    const there = snapshot.collections.find((c) => c.name === here.name)!;
+    for (const key of ["glob", "include", "symlinks"] as const) {
+      if (JSON.stringify(here[key]) !== JSON.stringify(there[key])) {
+        incompatible.push(`${here.name}: ${key} ${JSON.stringify(there[key])}, configured ${JSON.stringify(here[key])}`);
+      }
+    }
+    if (there.repo_path !== null && here.repo_path !== null && there.repo_path !== here.repo_path) {
+      incompatible.push(`${here.name}: root is ${there.repo_path || "the repository top"} in the snapshot, ${here.repo_path || "the repository top"} here`);
+    }
+    if (there.commit !== here.commit) {
+      drift.push(`${here.name}: exported at ${there.commit?.slice(0, 12) ?? "no commit"}, HEAD here is ${here.commit?.slice(0, 12) ?? "not in git"}`);
+    } else if (there.dirty) {
+      drift.push(`${here.name}: exported from a tree with uncommitted changes`);
+    }
+  }
+  return { incompatible, drift };
+}
+
+/**
+ * Import the snapshot at `path` as the INDEX_CACHE at `cachePath`.
+ * Throws SnapshotError when it is incompatible, or drifted and not
+ * `force`d. Returns the snapshot and the drift that was accepted.
+ */
+export async function importSnapshot(
+  path: string,
+  config: IndexConfig,
+  cachePath: string,
+  options: { force?: boolean; compression?: IndexCompression } = {}
+): Promise<{ snapshot: SnapshotFile; drift: string[] }> {
+  const snapshot = await readSnapshot(path);
+  const { incompatible, drift } = checkSnapshot(snapshot, config);
+  if (incompatible.length > 0) {
+    throw new SnapshotError(`Snapshot does not match this configuration:\n  ${incompatible.join("\n  ")}`);
+  }
+  if (drift.length > 0 && !options.force) {
+    throw new SnapshotError(
+      `Snapshot was built from other content:\n  ${drift.join("\n  ")}\nPass --force to import it anyway; the server re-validates it at startup.`
+    );
+  }
+  await saveIndexCache(cachePath, config, snapshot.documents, { compression: options.compression });
+  return { snapshot, drift };
+}
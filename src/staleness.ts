@@ -0,0 +1,128 @@
+/**
+ * Stale-result detection — files edited since they were indexed
+ *
+ * Without WATCH, the index is a snapshot: a search can rank a section
+ * that has since been rewritten, or a file that was deleted, and the
+ * agent has no way to tell. StaleCheck re-checks the files behind a
+ * result list at query time, cheaply:
+ *
+ *   1. stat the file. Gone means "deleted".
+ *   2. mtime and size equal to the last check that found the file
+ *      fresh (or, on the first check, mtime equal to the indexed
+ *      last_modified) means fresh, without reading it.
+ *   3. Otherwise the file is suspect: read it and compare its hash with
+ *      the indexed content_hash. Equal (a touch, a checkout of the same
+ *      content) is fresh and remembered; different is "modified".
+ *
+ * So a result list costs one stat per distinct file, and a read only
+ * for files whose metadata moved. Stale results are flagged in the
+ * search tools' output. With STALE_REFRESH=1 the stale files are
+ * re-indexed (deleted ones removed) and the search runs again, so the
+ * answer reflects the working tree.
+ */
+
+import { stat } from "node:fs/promises";
+import { join, resolve } from "node:path";
+import type { IndexConfig, IndexedDocument } from "./types";
+import type { DocumentStore } from "./store";
+import { indexFile } from "./indexer";
+import { indexCodeFile } from "./code-indexer";
+import { readSource } from "./encoding";
+
+export interface StaleFile {
+  doc_id: string;
+  collection: string;
+  file_path: string;
+  reason: "modified" | "deleted";
+}
+
+interface Checked {
+  hash: string;
+  mtimeMs: number;
+  size: number;
+}
+
+/** Query-time freshness checks against the files behind a store. */
+export class StaleCheck {
+  /** Re-index stale files before answering instead of only flagging them */
+  readonly refresh: boolean;
+  private readonly roots: Map<string, { root: string; kind: "markdown" | "code" }>;
+  private checked = new Map<string, Checked>();
+
+  constructor(
+    private readonly store: DocumentStore,
+    config: IndexConfig,
+    options: { refresh?: boolean } = {}
+  ) {
+    this.refresh = options.refresh ?? false;
+    this.roots = new Map([
+      ...config.collections.map((c) => [c.name, { root: resolve(c.root), kind: "markdown" as const }] as const),
+      ...(config.code_collections ?? []).map((c) => [c.name, { root: resolve(c.root), kind: "code" as const }] as const),
+    ]);
+  }
+
+  /** The documents among `docIds` whose file changed since indexing. */
+  async check(docIds: string[]): Promise<StaleFile[]> {
+    const stale: StaleFile[] = [];
+    for (const doc_id of new Set(docIds)) {
+      const meta = this.store.getDocMeta(doc_id);
+      const target = meta && this.roots.get(meta.collection);
+      if (!meta || !target) continue;
+      const path = join(target.root, meta.file_path);
+      const entry = { doc_id, collection: meta.collection, file_path: meta.file_path };
+
+      const info = await stat(path).catch(() => null);
+      if (!info?.isFile()) {
+        stale.push({ ...entry, reason: "deleted" });
+        continue;
+      }
+      const seen = this.checked.get(doc_id);
+      if (seen?.hash === meta.content_hash && seen.mtimeMs === info.mtimeMs && seen.size === info.size) continue;
+      const fresh = { hash: meta.content_hash, mtimeMs: info.mtimeMs, size: info.size };
+      if (!seen && info.mtime.toISOString() === meta.last_modified) {
+        this.checked.set(doc_id, fresh);
+        continue;
+      }
+
+      const raw = await readSource(path).then((s) => s.text, () => null);
+      if (raw !== null && Bun.hash(raw).toString(16) === meta.content_hash) {
+        this.checked.set(doc_id, fresh);
+      } else {
+        this.checked.delete(doc_id);
+        stale.push({ ...entry, reason: raw === null ? "deleted" : "modified" });
+      }
+    }
+    return stale;
+  }
+
+  /** Re-index modified files and drop deleted ones, in one store update. */
+  async reindex(files: StaleFile[]): Promise<void> {
+    const docs: IndexedDocument[] = [];
+    for (const file of files) {
+      const target = this.roots.get(file.collection);
+      if (!target) continue;
+      if (file.reason === "deleted") {
+        this.store.removeDocument(file.doc_id);
+        continue;
+      }
+      const path = join(target.root, file.file_path);
+      try {
+        docs.push(
+          target.kind === "markdown"
+            ? await indexFile(path, target.root, file.collection)
+            : await indexCodeFile(path, target.root, file.collection)
+        );
+      } catch {
+        // Vanished between the check and now; the next check reports it
+      }
+    }
+    this.store.addDocuments(docs);
+  }
+
+  /** Forget which files were found fresh, so the next checks read them; returns how many were known. */
+  clear(): number {
+    const entries = this.checked.size;
+    this.checked.clear();
+    return entries;
+  }
+}
@@ -22,9 +22,40 @@ import type {
   RankingParams,
   FilterIndex,
   FacetCounts,
+  CaseMode,
+  PathBoost,
+  MatchRange,
+  ContentMatch,
+  GeneratedPolicy,
+  IncludeTests,
+  ScoreBreakdown,
 } from "./types";
 import { DEFAULT_RANKING } from "./types";
 import { extractGlossaryEntries } from "./indexer";
+import { evaluateQuery, parseQuery, type Operand, type QueryIndex } from "./query";
+import { SymbolTrie } from "./suggest";
+import { DEFAULT_RECENCY_HALF_LIFE_DAYS, recencyMultiplier } from "./git-history";
+import { stripTestIntent, type CoverBlock, type NodeCoverage } from "./test-coverage";
+import { byteRange } from "./encoding";
+import { DEFAULT_GENERATED_POLICY, GENERATED_WEIGHT } from "./generated";
+import type { WatchQueueStatus } from "./watcher";
+import { matchesTests } from "./test-paths";
+import { locationUri } from "./uris";
+import { toolPath } from "./paths";
+import { commentSegments, detectLanguage, type CommentMatching } from "./comments";
+
+/**
+ * Re-scores the top search candidates (RANKING_WASM, see wasm-ranking.ts).
+ * Returns one new score per candidate, in order; a negative or NaN
+ * score drops the candidate.
+ */
+export type Rescorer = (candidates: { result: SearchResult; meta: DocumentMeta }[], query: string) => number[];
+
+/** Most candidates a Rescorer sees per search */
+export const MAX_RESCORE_CANDIDATES = 200;
+
+/** Changes changesSince() can look back over */
+export const MAX_JOURNAL_CHANGES = 256;
 
 export class DocumentStore {
   private docs: Map<string, IndexedDocument> = new Map();
@@ -51,6 +82,30 @@ export class DocumentStore {
   // ── Collection weights (Pagefind multisite/indexWeight inspired) ──
   private collectionWeights: Map<string, number> = new Map();
 
+  // ── Path boosts (per-extension / per-directory multipliers) ──────
+  private pathBoosts: { glob: InstanceType<typeof Bun.Glob>; kind: "name" | "path"; weight: number }[] = [];
+
+  // ── Recency (last commit time per file, see git-history.ts) ──────
+  // collection → relative path → epoch ms
+  private commitTimes: Map<string, Map<string, number>> = new Map();
+  private recency = { weight: 0, half_life_days: DEFAULT_RECENCY_HALF_LIFE_DAYS };
+
+  // doc_id → product of path boost, recency, and generated-file multipliers
+  private docWeights: Map<string, number> = new Map();
+
+  // What search does with generated files (GENERATED_POLICY)
+  private generatedPolicy: GeneratedPolicy = DEFAULT_GENERATED_POLICY;
+
+  // Comment text in full-text matching, when a search does not say
+  // (COMMENT_MATCHING, COMMENT_LANGUAGES; see comments.ts)
+  private commentMatching: { matching: Exclude<CommentMatching, "only">; languages: string[] } = { matching: "include", languages: [] };
+
+  // ── Custom ranking hook (RANKING_WASM) ────────────────────────────
+  private rescorer: Rescorer | null = null;
+
+  // Byte ranges for matches in every code file, not just transcoded ones
+  private byteOffsets: boolean = false;
+
   // ── Ranking parameters (Pagefind-style configurable knobs) ───────
   private ranking: RankingParams = { ...DEFAULT_RANKING };
 
@@ -59,18 +114,70 @@ export class DocumentStore {
   // like "CLI" also match "command line interface"
   private glossary: Map<string, string[]> = new Map();
 
+  // ── User-defined synonym groups (SYNONYMS) ────────────────────────
+  // stemmed term → the other members of its group(s)
+  private synonyms: Map<string, string[]> = new Map();
+
   // ── Ref map for cross-reference resolution ────────────────────────
   // basename(file_path) → { doc_id, tree }
   private refMap: Map<string, { doc_id: string; tree: TreeNode[] }> = new Map();
 
+  // ── Symbol names for "did you mean" (built on first use) ──────────
+  private symbolTrie: SymbolTrie | null = null;
+
+  // ── Documents by file path, for location URIs (built on first use) ─
+  // A path is indexed in more than one collection when roots overlap
+  private pathDocs: Map<string, string[]> | null = null;
+  // How tool paths are matched against them (setPathMatching, paths.ts)
+  private pathRoots: string[] = [];
+  private foldPathCase = false;
+
+  // ── Incoming references per code symbol (popularity signal) ───────
+  // "doc_id::node_id" → number of other code files naming the symbol
+  private referenceCounts: Map<string, number> | null = null;
+  private maxReferences = 0;
+
+  // ── Test coverage (Go cover profile, see test-coverage.ts) ────────
+  // collection → relative path → blocks
+  private coverBlocks: Map<string, Map<string, CoverBlock[]>> = new Map();
+  // "doc_id::node_id" → statements per function or method
+  private nodeCoverages: Map<string, NodeCoverage> | null = null;
+
+  // ── Warm-start validation state ───────────────────────────────────
+  // Passes re-checking the store against the working tree that are
+  // still running. Results served meanwhile may be stale.
+  private validating: number = 0;
+  // The WATCH queue, reported by getStats() while a watcher runs
+  private reindexStatus: (() => WatchQueueStatus) | null = null;
+
+  // ── Generations (sessions pinned with set_preferences snapshot) ───
+  // Bumped by every change to the documents
+  private generation: number = 0;
+  // Pinned generation → its frozen copy, made when the store first
+  // changes after the pin (null until then), kept while a holder pins it
+  private pinned: Map<number, DocumentStore | null> = new Map();
+  // Each holder (a session) pins one generation; holders per generation
+  private pins = new WeakMap<object, number>();
+  private pinCounts: Map<number, number> = new Map();
+  // Drops the pin of a holder collected without releasing it
+  private pinReaper = new FinalizationRegistry<number>((generation) => this.unpin(generation));
+  // Posting lists created since the last fork; the rest are shared with
+  // a frozen copy, and are copied before this store pushes onto them
+  private ownPostings = new WeakSet<Posting[]>();
+  // The last MAX_JOURNAL_CHANGES changes: the generation each made and
+  // the documents it touched (null: all of them, as load() does)
+  private journal: Array<{ generation: number; docs: DocumentMeta[] | null }> = [];
+
   // ── Load / Refresh ──────────────────────────────────────────────
 
   load(documents: IndexedDocument[]): void {
+    this.changing(null);
     this.docs.clear();
     this.index.clear();
     this.nodeStats.clear();
     this.filters.clear();
     this.contentHashes.clear();
+    this.docWeights.clear();
 
     for (const doc of documents) {
       this.docs.set(doc.meta.doc_id, doc);
@@ -82,7 +189,7 @@ export class DocumentStore {
     this.buildAutoGlossary(documents);
     this.buildRefMap();
 
-    console.log(
+    console.error(
       `Store loaded: ${this.docs.size} docs, ${this.totalNodes} nodes, ` +
         `${this.index.size} terms, ${this.filters.size} facet keys, ` +
         `${this.glossary.size} glossary mappings, ` +
@@ -99,6 +206,25 @@ export class DocumentStore {
    * not change." We use content hashes to skip unchanged files entirely.
    */
   addDocument(doc: IndexedDocument): void {
+    this.changing([doc.meta]);
+    this.insertDocument(doc);
+    this.recalcCorpusStats();
+    this.buildRefMap();
+  }
+
+  /**
+   * Add or update a batch of documents, recomputing corpus stats once
+   * at the end instead of per document. Used by lazy subtree expansion.
+   */
+  addDocuments(docs: IndexedDocument[]): void {
+    if (docs.length === 0) return;
+    this.changing(docs.map((d) => d.meta));
+    for (const doc of docs) this.insertDocument(doc);
+    this.recalcCorpusStats();
+    this.buildRefMap();
+  }
+
+  private insertDocument(doc: IndexedDocument): void {
     const existingDoc = this.docs.get(doc.meta.doc_id);
 
     // Remove old postings if this is an update
@@ -109,10 +235,10 @@ export class DocumentStore {
 
     this.docs.set(doc.meta.doc_id, doc);
     this.contentHashes.set(doc.meta.file_path, doc.meta.content_hash);
+    // An edit can make a file generated, or stop it being one
+    this.docWeights.delete(doc.meta.doc_id);
     this.indexDocument(doc);
     this.indexDocumentFilters(doc);
-    this.recalcCorpusStats();
-    this.buildRefMap();
   }
 
   /**
@@ -132,6 +258,7 @@ export class DocumentStore {
     const doc = this.docs.get(doc_id);
     if (!doc) return;
 
+    this.changing([doc.meta]);
     this.removeDocumentPostings(doc);
     this.removeDocumentFilters(doc);
     this.contentHashes.delete(doc.meta.file_path);
@@ -139,6 +266,151 @@ export class DocumentStore {
     this.recalcCorpusStats();
   }
 
+  /**
+   * All loaded documents, in load order. Used to persist the index.
+   */
+  exportDocuments(): IndexedDocument[] {
+    return [...this.docs.values()];
+  }
+
+  // ── Generations ─────────────────────────────────────────────────
+
+  /** Number of changes made to the documents so far. */
+  getGeneration(): number {
+    return this.generation;
+  }
+
+  /**
+   * Keep the current generation readable after later changes, for a
+   * session (`holder`) that wants one consistent view across several
+   * calls. Returns the generation. A holder pins one generation at a
+   * time, so pinning again moves its pin; a generation is kept while
+   * any holder pins it, whatever the others do, and dropped when the
+   * last one releases it or is garbage collected.
+   */
+  pinGeneration(holder: object): number {
+    if (this.pins.get(holder) === this.generation) return this.generation;
+    this.releaseGeneration(holder);
+    this.pins.set(holder, this.generation);
+    this.pinCounts.set(this.generation, (this.pinCounts.get(this.generation) ?? 0) + 1);
+    if (!this.pinned.has(this.generation)) this.pinned.set(this.generation, null);
+    this.pinReaper.register(holder, this.generation, holder);
+    return this.generation;
+  }
+
+  /** Drop `holder`'s pin, if it has one. */
+  releaseGeneration(holder: object): void {
+    const generation = this.pins.get(holder);
+    if (generation === undefined) return;
+    this.pins.delete(holder);
+    this.pinReaper.unregister(holder);
+    this.unpin(generation);
+  }
+
+  private unpin(generation: number): void {
+    const count = (this.pinCounts.get(generation) ?? 1) - 1;
+    if (count > 0) {
+      this.pinCounts.set(generation, count);
+      return;
+    }
+    this.pinCounts.delete(generation);
+    this.pinned.delete(generation);
+  }
+
+  /**
+   * The store as of `generation`: this store while it is current, else
+   * the copy frozen for its pin. null when it was never pinned or is no
+   * longer kept.
+   */
+  atGeneration(generation: number): DocumentStore | null {
+    if (generation === this.generation) return this;
+    return this.pinned.get(generation) ?? null;
+  }
+
+  /**
+   * The documents changed after `generation`, oldest change first. null
+   * when a load() replaced everything since, or the journal no longer
+   * reaches back that far.
+   */
+  changesSince(generation: number): DocumentMeta[] | null {
+    if (generation >= this.generation) return [];
+    if ((this.journal[0]?.generation ?? Infinity) > generation + 1) return null;
+    const changed: DocumentMeta[] = [];
+    for (const entry of this.journal) {
+      if (entry.generation <= generation) continue;
+      if (!entry.docs) return null;
+      changed.push(...entry.docs);
+    }
+    return changed;
+  }
+
+  // Runs before every change to the documents: a pinned current
+  // generation is copied first, so it reads the same afterwards
+  private changing(docs: DocumentMeta[] | null): void {
+    if (this.pinned.has(this.generation) && !this.pinned.get(this.generation)) {
+      this.pinned.set(this.generation, this.fork());
+    }
+    this.generation++;
+    this.journal.push({ generation: this.generation, docs });
+    if (this.journal.length > MAX_JOURNAL_CHANGES) this.journal.shift();
+  }
+
+  // A copy that shares documents and posting lists but none of the maps
+  // a later change edits in place. Posting lists stay shared until this
+  // store pushes onto one (ownPostings), so a fork costs one entry per
+  // term, not per posting. Derived lookups rebuild lazily.
+  private fork(): DocumentStore {
+    const copy = new DocumentStore();
+    copy.docs = new Map(this.docs);
+    copy.index = new Map(this.index);
+    this.ownPostings = new WeakSet();
+    copy.nodeStats = new Map(this.nodeStats);
+    copy.totalNodes = this.totalNodes;
+    copy.avgNodeLength = this.avgNodeLength;
+    copy.filters = new Map([...this.filters].map(([key, values]) => [key, new Map([...values].map(([value, ids]) => [value, new Set(ids)]))]));
+    copy.contentHashes = new Map(this.contentHashes);
+    copy.collectionWeights = new Map(this.collectionWeights);
+    copy.pathBoosts = this.pathBoosts;
+    copy.commitTimes = new Map(this.commitTimes);
+    copy.recency = this.recency;
+    copy.docWeights = new Map(this.docWeights);
+    copy.generatedPolicy = this.generatedPolicy;
+    copy.commentMatching = this.commentMatching;
+    copy.rescorer = this.rescorer;
+    copy.byteOffsets = this.byteOffsets;
+    copy.ranking = this.ranking;
+    copy.glossary = new Map([...this.glossary].map(([term, expansions]) => [term, [...expansions]]));
+    copy.synonyms = new Map(this.synonyms);
+    copy.refMap = new Map(this.refMap);
+    copy.coverBlocks = new Map(this.coverBlocks);
+    copy.generation = this.generation;
+    return copy;
+  }
+
+  /**
+   * Mark the store as being re-checked against the working tree: a
+   * warm-start re-validation, a watcher full pass, or a reindex_path.
+   * Passes may overlap, so the store validates until every pass that
+   * began has ended.
+   */
+  beginValidating(): void {
+    this.validating++;
+  }
+
+  /** End a pass started with beginValidating(). */
+  endValidating(): void {
+    this.validating = Math.max(0, this.validating - 1);
+  }
+
+  isValidating(): boolean {
+    return this.validating > 0;
+  }
+
+  /** Report a file watcher's queue in getStats(); null stops reporting it. */
+  setReindexStatus(status: (() => WatchQueueStatus) | null): void {
+    this.reindexStatus = status;
+  }
+
   setRanking(params: Partial<RankingParams>): void {
     this.ranking = { ...this.ranking, ...params };
   }
@@ -152,6 +424,214 @@ export class DocumentStore {
     }
   }
 
+  /**
+   * Set path boosts. Every pattern that matches a document's path
+   * multiplies its score, so "*.go=1.2" and "*.pb.go=0.5" together
+   * leave generated Go code at 0.6.
+   */
+  setPathBoosts(boosts: PathBoost[]): void {
+    this.pathBoosts = boosts.map(({ pattern, weight }) => {
+      const p = pattern.replace(/\\/g, "/").replace(/^\.\//, "");
+      if (p.endsWith("/")) return { glob: new Bun.Glob(`**/${p}**`), kind: "path" as const, weight };
+      return { glob: new Bun.Glob(p), kind: p.includes("/") ? "path" as const : "name" as const, weight };
+    });
+    this.docWeights.clear();
+  }
+
+  /**
+   * Enable the recency signal: documents committed recently score up to
+   * `1 + weight` times higher, decaying by half every `halfLifeDays`.
+   * A weight of 0 turns it off.
+   */
+  setRecencyBoost(weight: number, halfLifeDays: number = DEFAULT_RECENCY_HALF_LIFE_DAYS): void {
+    this.recency = { weight, half_life_days: halfLifeDays };
+    this.docWeights.clear();
+  }
+
+  /**
+   * Install a hook that re-scores and filters search results after all
+   * built-in ranking, or remove it with null.
+   */
+  setRescorer(rescorer: Rescorer | null): void {
+    this.rescorer = rescorer;
+  }
+
+  /**
+   * Give content matches in all code files byte ranges in the file
+   * alongside line and UTF-16 column (BYTE_OFFSETS).
+   */
+  setByteOffsets(enabled: boolean): void {
+    this.byteOffsets = enabled;
+  }
+
+  /**
+   * How paths from tool input are matched (see paths.ts): absolute
+   * paths under one of `roots` are taken relative to it, and with
+   * `caseInsensitive` letter case is ignored, as the filesystem does.
+   */
+  setPathMatching(options: { roots: string[]; caseInsensitive: boolean }): void {
+    this.pathRoots = options.roots;
+    this.foldPathCase = options.caseInsensitive;
+    this.pathDocs = null;
+  }
+
+  /**
+   * A file path, directory, or prefix from tool input in the spelling
+   * the index uses: "/"-separated, root-relative, and, on a
+   * case-insensitive filesystem, in the case of the indexed files it
+   * names. Doc_ids, globs, and paths that name nothing come back
+   * normalized but otherwise as given.
+   */
+  indexPath(input: string): string {
+    // An exact file path wins: on POSIX a backslash can be part of a name
+    if (this.documentsAtPath(input).some((d) => d.file_path === input)) return input;
+    const path = toolPath(input, this.pathRoots);
+    if (!this.foldPathCase || path === "" || this.docs.has(path)) return path;
+    const lower = path.toLowerCase();
+    for (const doc of this.docs.values()) {
+      const prefix = doc.meta.file_path.slice(0, path.length);
+      if (prefix.toLowerCase() === lower) return prefix;
+    }
+    return path;
+  }
+
+  /**
+   * How search treats files whose meta marks them generated, minified,
+   * or lockfiles: rank as usual, downrank, or exclude (see generated.ts).
+   */
+  setGeneratedPolicy(policy: GeneratedPolicy): void {
+    this.generatedPolicy = policy;
+    this.docWeights.clear();
+  }
+
+  /**
+   * How search treats comment text in code when a search does not say:
+   * `matching` includes or excludes it, and a non-empty `languages`
+   * counts only comments detected in one of them (or in no detectable
+   * language). Symbol lookups match titles, which neither affects.
+   */
+  setCommentMatching(options: { matching: Exclude<CommentMatching, "only">; languages: string[] }): void {
+    this.commentMatching = { matching: options.matching, languages: options.languages.map((l) => l.toLowerCase()) };
+  }
+
+  /** Last commit times for a collection's files, keyed by relative path. */
+  setCommitTimes(collection: string, times: Map<string, number>): void {
+    this.commitTimes.set(collection, times);
+    this.docWeights.clear();
+  }
+
+  /** Cover profile blocks for a collection's files, keyed by relative path. */
+  setCoverBlocks(collection: string, blocks: Map<string, CoverBlock[]>): void {
+    this.coverBlocks.set(collection, blocks);
+    this.nodeCoverages = null;
+  }
+
+  /** True once a cover profile has attributed statements to any function. */
+  hasCoverage(): boolean {
+    return this.buildNodeCoverage().size > 0;
+  }
+
+  /** Covered and total statements of a function or method node, if profiled. */
+  nodeCoverage(doc_id: string, node_id: string): NodeCoverage | null {
+    return this.buildNodeCoverage().get(`${doc_id}::${node_id}`) ?? null;
+  }
+
+  /**
+   * Every profiled function or method, optionally limited to one
+   * document, a path prefix, or symbols whose name contains `symbol`.
+   * In document and line order.
+   */
+  coveredNodes(options?: { doc_id?: string; path_prefix?: string; symbol?: string }): Array<{
+    doc: DocumentMeta;
+    node: TreeNode;
+    coverage: NodeCoverage;
+  }> {
+    const coverages = this.buildNodeCoverage();
+    const symbol = options?.symbol?.toLowerCase();
+    const found: Array<{ doc: DocumentMeta; node: TreeNode; coverage: NodeCoverage }> = [];
+    for (const [id, doc] of this.docs) {
+      if (options?.doc_id && id !== options.doc_id) continue;
+      if (options?.path_prefix && !doc.meta.file_path.startsWith(options.path_prefix)) continue;
+      for (const node of doc.tree) {
+        const coverage = coverages.get(`${id}::${node.node_id}`);
+        if (!coverage) continue;
+        if (symbol && !node.title.toLowerCase().includes(symbol)) continue;
+        found.push({ doc: doc.meta, node, coverage });
+      }
+    }
+    return found.sort(
+      (a, b) => a.doc.file_path.localeCompare(b.doc.file_path) || a.node.line_start - b.node.line_start
+    );
+  }
+
+  /**
+   * Attribute each block to the smallest function or method node whose
+   * lines contain the block's first line.
+   */
+  private buildNodeCoverage(): Map<string, NodeCoverage> {
+    if (this.nodeCoverages) return this.nodeCoverages;
+    const coverages = new Map<string, NodeCoverage>();
+    if (this.coverBlocks.size > 0) {
+      for (const [id, doc] of this.docs) {
+        const blocks = this.coverBlocks.get(doc.meta.collection)?.get(doc.meta.file_path.replace(/\\/g, "/"));
+        if (!blocks) continue;
+        const functions = doc.tree.filter((n) => n.title.startsWith("function ") || n.title.startsWith("method "));
+        for (const block of blocks) {
+          let owner: TreeNode | null = null;
+          for (const node of functions) {
+            if (block.start_line < node.line_start || block.start_line > node.line_end) continue;
+            if (!owner || node.line_end - node.line_start < owner.line_end - owner.line_start) owner = node;
+          }
+          if (!owner) continue;
+          const key = `${id}::${owner.node_id}`;
+          const entry = coverages.get(key) ?? { statements: 0, covered: 0 };
+          entry.statements += block.statements;
+          if (block.count > 0) entry.covered += block.statements;
+          coverages.set(key, entry);
+        }
+      }
+    }
+    this.nodeCoverages = coverages;
+    return coverages;
+  }
+
+  /** Score multiplier for a "needs tests" query: the uncovered share of a function. */
+  private coverageBoost(nodeKey: string): number {
+    const coverage = this.buildNodeCoverage().get(nodeKey);
+    if (!coverage || coverage.statements === 0) return 1.0;
+    return 1 + this.ranking.coverage_weight * (1 - coverage.covered / coverage.statements);
+  }
+
+  /** Path boost, recency, and generated-file multipliers for a document, cached per doc_id. */
+  private docWeight(doc: IndexedDocument): number {
+    const downrank = doc.meta.generated && this.generatedPolicy === "downrank";
+    if (this.pathBoosts.length === 0 && this.recency.weight === 0 && !downrank) return 1.0;
+    let weight = this.docWeights.get(doc.meta.doc_id);
+    if (weight === undefined) {
+      const parts = this.docWeightParts(doc);
+      weight = parts.path * parts.recency * parts.generated;
+      this.docWeights.set(doc.meta.doc_id, weight);
+    }
+    return weight;
+  }
+
+  /** docWeight's multipliers one by one, for explained searches. */
+  private docWeightParts(doc: IndexedDocument): { path: number; recency: number; generated: number } {
+    const path = doc.meta.file_path.replace(/\\/g, "/");
+    const name = path.slice(path.lastIndexOf("/") + 1);
+    let boost = 1.0;
+    for (const b of this.pathBoosts) {
+      if (b.glob.match(b.kind === "name" ? name : path)) boost *= b.weight;
+    }
+    const committedAt = this.commitTimes.get(doc.meta.collection)?.get(path);
+    const recency =
+      this.recency.weight > 0 && committedAt !== undefined
+        ? recencyMultiplier(committedAt, this.recency.weight, this.recency.half_life_days)
+        : 1.0;
+    const generated = doc.meta.generated && this.generatedPolicy === "downrank" ? GENERATED_WEIGHT : 1.0;
+    return { path: boost, recency, generated };
+  }
+
   /**
    * Load a glossary for query expansion.
    *
@@ -181,21 +661,144 @@ export class DocumentStore {
       }
     }
     if (this.glossary.size > 0) {
-      console.log(`Glossary loaded: ${Object.keys(entries).length} entries → ${this.glossary.size} expansion mappings`);
+      console.error(`Glossary loaded: ${Object.keys(entries).length} entries → ${this.glossary.size} expansion mappings`);
+    }
+  }
+
+  /**
+   * Load user-defined synonym groups (SYNONYMS). Every term of a group
+   * expands to all the others: ["auth", "authn", "authentication"] makes
+   * a query for any one of them match all three. Unlike the glossary,
+   * groups are symmetric, kept separately (loadGlossary leaves them
+   * alone), and keyed by stem, so "databases" expands like "database".
+   */
+  loadSynonyms(groups: string[][]): void {
+    this.synonyms.clear();
+    for (const group of groups) {
+      const members = group.map((term) => term.toLowerCase());
+      for (const member of members) {
+        const tokens = tokenize(member).map(stem);
+        // Queries are looked up a term at a time, so only one-word
+        // members can trigger expansion; longer ones are targets only
+        if (tokens.length !== 1) continue;
+        const existing = this.synonyms.get(tokens[0]) ?? [];
+        const others = members.filter((m) => m !== member && !existing.includes(m));
+        this.synonyms.set(tokens[0], [...existing, ...others]);
+      }
+    }
+    if (groups.length > 0) {
+      console.error(`Synonyms loaded: ${groups.length} groups → ${this.synonyms.size} expansion mappings`);
+    }
+  }
+
+  /**
+   * The QueryIndex a parsed query is evaluated against (see query.ts).
+   * Keys are "doc_id::node_id".
+   */
+  private queryIndex(wordBoundaries: boolean): QueryIndex {
+    const spansOf = (operand: Operand) =>
+      operand.op === "term" ? this.termSpans(operand.term, wordBoundaries) : this.phraseSpans(operand.phrase);
+    return {
+      term: (term) => new Set(this.termSpans(term, wordBoundaries).keys()),
+      phrase: (phrase) => new Set(this.phraseSpans(phrase).keys()),
+      near: (left, right, distance) => {
+        const keys = new Set<string>();
+        const rightSpans = spansOf(right);
+        for (const [key, spans] of spansOf(left)) {
+          const others = rightSpans.get(key);
+          if (!others) continue;
+          const close = spans.some(([start, end]) =>
+            others.some(([oStart, oEnd]) => Math.max(oStart - end, start - oEnd) <= distance)
+          );
+          if (close) keys.add(key);
+        }
+        return keys;
+      },
+    };
+  }
+
+  /**
+   * Where one query term occurs, per node, as [start, end] token spans,
+   * with the same stemming, glossary expansion and prefix matching as
+   * ranking.
+   */
+  private termSpans(term: string, wordBoundaries: boolean): Map<string, [number, number][]> {
+    const spans = new Map<string, [number, number][]>();
+    const add = (posting: Posting) => {
+      const key = `${posting.doc_id}::${posting.node_id}`;
+      const list = spans.get(key) ?? [];
+      for (const p of posting.positions) list.push([p, p]);
+      spans.set(key, list);
+    };
+    const stems = tokenize(term).map(stem).filter((t) => t.length >= 2);
+    for (const t of new Set(this.expandQueryTerms(stems))) {
+      for (const posting of this.index.get(t) ?? []) add(posting);
+      if (t.length < 3 || wordBoundaries) continue;
+      for (const [indexedTerm, postings] of this.index) {
+        if (indexedTerm === t || !indexedTerm.startsWith(t)) continue;
+        for (const posting of postings) add(posting);
+      }
     }
+    return spans;
   }
 
   /**
-   * Expand query terms using the glossary.
-   * Returns the original terms plus any glossary expansions.
+   * Where a phrase occurs, per node: its terms at consecutive
+   * positions. Phrase terms are stemmed, but never prefix-matched or
+   * glossary-expanded.
+   */
+  private phraseSpans(phrase: string): Map<string, [number, number][]> {
+    // A CJK run is matched by its bigrams, so the phrase may start or
+    // end inside a longer run of the text
+    const tokens = positionedTokens(phrase);
+    const split = new Set(tokens.filter((t) => t.bigram).map((t) => t.position));
+    const kept = tokens
+      .filter((t) => t.bigram || !split.has(t.position))
+      .map((t) => ({ term: stem(t.text), offset: t.position - tokens[0].position }))
+      .filter((t) => t.term.length >= 2);
+    const terms = kept.map((t) => t.term);
+    const spans = new Map<string, [number, number][]>();
+    if (terms.length === 0) return spans;
+
+    // Positions of each phrase term, per node. Tokens keep trailing
+    // punctuation ("connected." ends a sentence), so those count too.
+    const positions = terms.map((term) => {
+      const byNode = new Map<string, Set<number>>();
+      for (const [indexedTerm, postings] of this.index) {
+        if (indexedTerm !== term && !(indexedTerm.startsWith(term) && /^[.\-\/]+$/.test(indexedTerm.slice(term.length)))) continue;
+        for (const posting of postings) {
+          const key = `${posting.doc_id}::${posting.node_id}`;
+          const set = byNode.get(key) ?? new Set<number>();
+          for (const p of posting.positions) set.add(p);
+          byNode.set(key, set);
+        }
+      }
+      return byNode;
+    });
+
+    for (const [key, starts] of positions[0]) {
+      for (const start of starts) {
+        const origin = start - kept[0].offset;
+        if (!kept.every((t, i) => positions[i].get(key)?.has(origin + t.offset))) continue;
+        const list = spans.get(key) ?? [];
+        list.push([origin, origin + kept[kept.length - 1].offset]);
+        spans.set(key, list);
+      }
+    }
+    return spans;
+  }
+
+  /**
+   * Expand query terms using the glossary and synonym groups.
+   * Returns the original terms plus any expansions.
    */
   private expandQueryTerms(terms: string[]): string[] {
-    if (this.glossary.size === 0) return terms;
+    if (this.glossary.size === 0 && this.synonyms.size === 0) return terms;
 
     const expanded = new Set(terms);
     for (const term of terms) {
-      const expansions = this.glossary.get(term);
-      if (expansions) {
+      const expansions = [...(this.glossary.get(term) ?? []), ...(this.synonyms.get(term) ?? [])];
+      if (expansions.length > 0) {
         for (const expansion of expansions) {
           // Tokenize and stem each expansion (may be multi-word)
           const expandedTokens = tokenize(expansion).map(stem).filter((t) => t.length >= 2);
@@ -281,7 +884,7 @@ export class DocumentStore {
     }
 
     if (added > 0) {
-      console.log(`Auto-glossary: extracted ${added} entries from content`);
+      console.error(`Auto-glossary: extracted ${added} entries from content`);
     }
   }
 
@@ -297,6 +900,7 @@ export class DocumentStore {
         this.index.delete(term);
       } else {
         this.index.set(term, filtered);
+        this.ownPostings.add(filtered);
       }
     }
 
@@ -334,6 +938,7 @@ export class DocumentStore {
       ? new Set(tokenize(doc.meta.description).map(stem).filter((t) => t.length >= 2))
       : new Set<string>();
     const firstNodeId = doc.tree[0]?.node_id;
+    const isCode = doc.meta.facets.content_type?.includes("code") ?? false;
 
     for (const node of doc.tree) {
       const nodeKey = `${doc.meta.doc_id}::${node.node_id}`;
@@ -341,19 +946,39 @@ export class DocumentStore {
 
       // Tokenize title and body separately for weighting
       // (Pagefind also weights heading text differently from body text)
-      const titleTokens = tokenize(node.title);
-      const bodyTokens = tokenize(node.content);
+      const titleTokens = positionedTokens(node.title);
       const codeTokens = extractCodeTokens(node.content);
 
+      // Code bodies are tokenized run by run, so positions inside
+      // comments are known (see comments.ts)
+      const segments = isCode ? commentSegments(node.content, doc.meta.file_path) : [];
+      const commented = segments.some((s) => s.comment);
+      const titleEnd = wordCount(titleTokens);
+      const bodyTokens: Token[] = [];
+      const commentTokens = new Set<number>();
+      let words = titleEnd;
+      for (const segment of commented ? segments : [{ text: node.content, comment: false }]) {
+        const tokens = positionedTokens(segment.text);
+        for (const token of tokens) {
+          const position = words + token.position;
+          if (segment.comment) commentTokens.add(position);
+          bodyTokens.push({ ...token, position });
+        }
+        words += wordCount(tokens);
+      }
+      const commentLanguage = commented
+        ? detectLanguage(segments.filter((s) => s.comment).map((s) => s.text).join("\n"))
+        : undefined;
+
       // Combine into single token stream (title first, then body)
       const allTokens = [...titleTokens, ...bodyTokens];
-      const titleEnd = titleTokens.length;
 
       // Store node stats for BM25 length normalization
       this.nodeStats.set(nodeKey, {
         doc_id: doc.meta.doc_id,
         node_id: node.node_id,
-        total_tokens: allTokens.length,
+        total_tokens: words,
+        ...(commentLanguage ? { comment_language: commentLanguage } : {}),
       });
 
       // Build postings: for each unique term, record positions + weight
@@ -362,8 +987,9 @@ export class DocumentStore {
         { positions: number[]; maxWeight: number }
       > = new Map();
 
-      for (let pos = 0; pos < allTokens.length; pos++) {
-        const term = stem(allTokens[pos]);
+      for (const token of allTokens) {
+        const pos = token.position;
+        const term = stem(token.text);
         if (term.length < 2) continue;
 
         if (!termPositions.has(term)) {
@@ -381,7 +1007,7 @@ export class DocumentStore {
         } else if (isFirstNode && descriptionTerms.has(term)) {
           // Boost description terms in the first node
           weight = Math.max(weight, this.ranking.description_weight);
-        } else if (codeTokens.has(allTokens[pos])) {
+        } else if (codeTokens.has(token.text)) {
           weight = this.ranking.code_weight;
         }
         entry.maxWeight = Math.max(entry.maxWeight, weight);
@@ -389,18 +1015,23 @@ export class DocumentStore {
 
       // Insert postings into the inverted index
       for (const [term, { positions, maxWeight }] of termPositions) {
+        const inComments = commentTokens.size > 0 ? positions.filter((p) => commentTokens.has(p)) : [];
         const posting: Posting = {
           doc_id: doc.meta.doc_id,
           node_id: node.node_id,
           positions,
           term_frequency: positions.length,
           weight: maxWeight,
+          ...(inComments.length > 0 ? { comment_positions: inComments } : {}),
         };
 
-        if (!this.index.has(term)) {
-          this.index.set(term, []);
+        let postings = this.index.get(term);
+        if (!postings || !this.ownPostings.has(postings)) {
+          postings = postings ? [...postings] : [];
+          this.index.set(term, postings);
+          this.ownPostings.add(postings);
         }
-        this.index.get(term)!.push(posting);
+        postings.push(posting);
       }
     }
   }
@@ -458,6 +1089,11 @@ export class DocumentStore {
   }
 
   private recalcCorpusStats(): void {
+    // Runs after every change to the corpus; derived lookups rebuild lazily
+    this.symbolTrie = null;
+    this.pathDocs = null;
+    this.referenceCounts = null;
+    this.nodeCoverages = null;
     let totalTokens = 0;
     this.totalNodes = this.nodeStats.size;
 
@@ -546,10 +1182,44 @@ export class DocumentStore {
       doc_id?: string;
       collection?: string;
       filters?: Record<string, string | string[]>;
+      /** Only match documents whose file_path starts with this prefix */
+      path_prefix?: string;
+      /** Case matching (default "insensitive"); see textMatcher() */
+      case?: CaseMode;
+      /** Match query words only as whole words, with no prefix expansion */
+      word_boundaries?: boolean;
+      /** Test files: true counts them (default), false leaves them out, "only" keeps only them */
+      include_tests?: IncludeTests;
+      /** Parse AND/OR/NOT, +/- and "quoted phrases" (default true); see query.ts */
+      operators?: boolean;
+      /** Fold code matches within one top-level function or type into the best of them (default false) */
+      group?: boolean;
+      /**
+       * Cap results per file and per package (collection and directory);
+       * unset caps fall back to the ranking's max_per_file and
+       * max_per_package. Without this option results are not capped.
+       */
+      diversify?: { max_per_file?: number; max_per_package?: number };
+      /** Attach each result's score breakdown as `explain` (default false) */
+      explain?: boolean;
+      /** Comment text in code: matched like the rest, left out, or alone matched (default: setCommentMatching) */
+      comments?: CommentMatching;
+      /** Count only comments in these languages, or in none detected (default: setCommentMatching) */
+      comment_languages?: string[];
     }
   ): SearchResult[] {
-    const queryTerms = tokenize(query).map(stem).filter((t) => t.length >= 2);
-    if (queryTerms.length === 0) return [];
+    const wordBoundaries = options?.word_boundaries ?? false;
+    // "needs tests" queries rank by missing coverage; the intent words
+    // themselves are not searched for (see test-coverage.ts)
+    const testIntent =
+      this.ranking.coverage_weight > 0 && this.hasCoverage() ? stripTestIntent(query) : null;
+    const searched = testIntent ?? query;
+    // Operator and phrase queries filter by an AST and rank by their positive terms
+    const parsed = options?.operators === false ? null : parseQuery(searched);
+    const rankedQuery = parsed ? parsed.ranked : searched;
+    const matchesText = textMatcher(rankedQuery, options?.case ?? "insensitive", wordBoundaries);
+    const queryTerms = tokenize(rankedQuery).map(stem).filter((t) => t.length >= 2);
+    if (queryTerms.length === 0 && testIntent === null) return [];
 
     // Expand query using glossary (abbreviation ↔ expanded forms)
     const expandedTerms = this.expandQueryTerms(queryTerms);
@@ -575,29 +1245,96 @@ export class DocumentStore {
       }
     }
 
+    // Restrict to a directory (session focus)
+    if (options?.path_prefix) {
+      const prefix = options.path_prefix;
+      const inPrefix = new Set<string>();
+      for (const [id, doc] of this.docs) {
+        if (doc.meta.file_path.startsWith(prefix)) inPrefix.add(id);
+      }
+      if (filterWhitelist) {
+        for (const id of filterWhitelist) {
+          if (!inPrefix.has(id)) filterWhitelist.delete(id);
+        }
+      } else {
+        filterWhitelist = inPrefix;
+      }
+      if (filterWhitelist.size === 0) return [];
+    }
+
+    // With or without test code (see test-paths.ts)
+    if (options?.include_tests !== undefined && options.include_tests !== true) {
+      const kept = new Set<string>();
+      for (const [id, doc] of this.docs) {
+        if ((!filterWhitelist || filterWhitelist.has(id)) && matchesTests(doc.meta.file_path, options.include_tests)) kept.add(id);
+      }
+      filterWhitelist = kept;
+      if (filterWhitelist.size === 0) return [];
+    }
+
+    if (queryTerms.length === 0) {
+      return this.leastCovered(filterWhitelist, options?.doc_id, options?.limit || 20);
+    }
+
+    // Boolean queries and phrases: the nodes that satisfy the AST
+    let allowedNodes: Set<string> | null = null;
+    if (parsed) {
+      const universe = new Set<string>();
+      for (const [id, doc] of this.docs) {
+        if (options?.doc_id && id !== options.doc_id) continue;
+        if (filterWhitelist && !filterWhitelist.has(id)) continue;
+        for (const node of doc.tree) universe.add(`${id}::${node.node_id}`);
+      }
+      allowedNodes = evaluateQuery(parsed.filter, universe, this.queryIndex(wordBoundaries));
+      if (allowedNodes.size === 0) return [];
+    }
+
     // Accumulate BM25 scores per node
     const nodeScores: Map<
       string,
       {
         score: number;
         matchedTerms: Set<string>;
+        /** Indexed terms that matched, prefix expansions included */
+        hitTerms: Set<string>;
         positions: number[];
+        /** Whether any counted occurrence is in a comment */
+        inComments: boolean;
         doc_id: string;
         node_id: string;
+        explain?: ScoreBreakdown;
       }
     > = new Map();
+    const explain = options?.explain ?? false;
+    const comments = this.commentScope(options?.comments, options?.comment_languages);
+    const breakdown = (): ScoreBreakdown => ({
+      terms: {},
+      bm25: 0,
+      prefix: 0,
+      proximity: 0,
+      all_terms: 0,
+      collection: 1,
+      path: 1,
+      recency: 1,
+      generated: 1,
+      popularity: 1,
+      coverage: 1,
+    });
 
     for (const term of uniqueTerms) {
       // Exact term lookup
       const postings = this.index.get(term);
       if (postings) {
-        for (const posting of postings) {
-          if (options?.doc_id && posting.doc_id !== options.doc_id) continue;
-          if (filterWhitelist && !filterWhitelist.has(posting.doc_id)) continue;
+        for (const indexed of postings) {
+          if (options?.doc_id && indexed.doc_id !== options.doc_id) continue;
+          if (filterWhitelist && !filterWhitelist.has(indexed.doc_id)) continue;
 
-          const nodeKey = `${posting.doc_id}::${posting.node_id}`;
+          const nodeKey = `${indexed.doc_id}::${indexed.node_id}`;
+          if (allowedNodes && !allowedNodes.has(nodeKey)) continue;
           const stats = this.nodeStats.get(nodeKey);
           if (!stats) continue;
+          const posting = comments ? this.commentScoped(indexed, stats, comments) : indexed;
+          if (!posting) continue;
 
           const bm25Score = this.computeBM25(term, posting, stats.total_tokens);
 
@@ -605,34 +1342,46 @@ export class DocumentStore {
             nodeScores.set(nodeKey, {
               score: 0,
               matchedTerms: new Set(),
+              hitTerms: new Set(),
               positions: [],
+              inComments: false,
               doc_id: posting.doc_id,
               node_id: posting.node_id,
+              ...(explain ? { explain: breakdown() } : {}),
             });
           }
 
           const entry = nodeScores.get(nodeKey)!;
           entry.score += bm25Score;
+          if (entry.explain) {
+            entry.explain.bm25 += bm25Score;
+            entry.explain.terms[term] = (entry.explain.terms[term] ?? 0) + bm25Score;
+          }
           entry.matchedTerms.add(term);
+          entry.hitTerms.add(term);
           entry.positions.push(...posting.positions);
+          entry.inComments ||= posting.comment_positions !== undefined;
         }
       }
 
       // Prefix matching for partial terms
       // (Pagefind does this at the chunk level; we iterate the in-memory index)
-      if (term.length >= 3) {
+      if (term.length >= 3 && !wordBoundaries) {
         for (const [indexedTerm, pfxPostings] of this.index) {
           if (indexedTerm === term) continue;
           if (!indexedTerm.startsWith(term)) continue;
 
-          for (const posting of pfxPostings) {
-            if (options?.doc_id && posting.doc_id !== options.doc_id) continue;
-            if (filterWhitelist && !filterWhitelist.has(posting.doc_id))
+          for (const indexed of pfxPostings) {
+            if (options?.doc_id && indexed.doc_id !== options.doc_id) continue;
+            if (filterWhitelist && !filterWhitelist.has(indexed.doc_id))
               continue;
 
-            const nodeKey = `${posting.doc_id}::${posting.node_id}`;
+            const nodeKey = `${indexed.doc_id}::${indexed.node_id}`;
+            if (allowedNodes && !allowedNodes.has(nodeKey)) continue;
             const stats = this.nodeStats.get(nodeKey);
             if (!stats) continue;
+            const posting = comments ? this.commentScoped(indexed, stats, comments) : indexed;
+            if (!posting) continue;
 
             // Prefix matches score at prefix_penalty of exact matches
             const bm25Score =
@@ -643,16 +1392,25 @@ export class DocumentStore {
               nodeScores.set(nodeKey, {
                 score: 0,
                 matchedTerms: new Set(),
+                hitTerms: new Set(),
                 positions: [],
+                inComments: false,
                 doc_id: posting.doc_id,
                 node_id: posting.node_id,
+                ...(explain ? { explain: breakdown() } : {}),
               });
             }
 
             const entry = nodeScores.get(nodeKey)!;
             entry.score += bm25Score;
+            if (entry.explain) {
+              entry.explain.prefix += bm25Score;
+              entry.explain.terms[indexedTerm] = (entry.explain.terms[indexedTerm] ?? 0) + bm25Score;
+            }
             entry.matchedTerms.add(term);
+            entry.hitTerms.add(indexedTerm);
             entry.positions.push(...posting.positions);
+            entry.inComments ||= posting.comment_positions !== undefined;
           }
         }
       }
@@ -664,10 +1422,12 @@ export class DocumentStore {
 
       if (matchCount > 1) {
         entry.score += (matchCount - 1) * this.ranking.term_proximity_bonus;
+        if (entry.explain) entry.explain.proximity = (matchCount - 1) * this.ranking.term_proximity_bonus;
       }
 
       if (matchCount === uniqueTerms.length && uniqueTerms.length > 1) {
         entry.score += this.ranking.full_coverage_bonus;
+        if (entry.explain) entry.explain.all_terms = this.ranking.full_coverage_bonus;
       }
 
       // Apply collection weight (Pagefind indexWeight equivalent)
@@ -675,7 +1435,21 @@ export class DocumentStore {
       if (doc) {
         const colWeight =
           this.collectionWeights.get(doc.meta.collection) ?? 1.0;
-        entry.score *= colWeight;
+        entry.score *= colWeight * this.docWeight(doc);
+        if (entry.explain) Object.assign(entry.explain, { collection: colWeight }, this.docWeightParts(doc));
+      }
+
+      // Widely used symbols outrank one-off helpers
+      if (this.ranking.reference_weight > 0) {
+        const boost = this.referenceBoost(`${entry.doc_id}::${entry.node_id}`);
+        entry.score *= boost;
+        if (entry.explain) entry.explain.popularity = boost;
+      }
+
+      if (testIntent !== null) {
+        const boost = this.coverageBoost(`${entry.doc_id}::${entry.node_id}`);
+        entry.score *= boost;
+        if (entry.explain) entry.explain.coverage = boost;
       }
     }
 
@@ -685,9 +1459,11 @@ export class DocumentStore {
     for (const [, entry] of nodeScores) {
       const doc = this.docs.get(entry.doc_id);
       if (!doc) continue;
+      if (doc.meta.generated && this.generatedPolicy === "exclude") continue;
 
       const node = doc.tree.find((n) => n.node_id === entry.node_id);
       if (!node) continue;
+      if (matchesText && !matchesText(`${node.title}\n${node.content}`)) continue;
 
       // Density-based snippet (Pagefind excerpt algorithm)
       const snippet = buildDensitySnippet(
@@ -708,13 +1484,185 @@ export class DocumentStore {
         score: entry.score,
         match_positions: entry.positions.sort((a, b) => a - b),
         matched_terms: [...entry.matchedTerms],
+        line_start: node.line_start,
+        line_end: node.line_end,
+        ...(node.cell ? { cell: node.cell } : {}),
+        uri: locationUri(this, doc.meta.doc_id, node.line_start, node.line_end, node.cell)!,
+        snippet_highlights: findMatches(snippet, entry.hitTerms),
+        content_matches: withByteRanges(
+          doc.meta,
+          node,
+          this.byteOffsets,
+          locateMatches(node.content, findMatches(node.content, entry.hitTerms, MAX_CONTENT_MATCHES))
+        ),
         collection: doc.meta.collection,
         facets: doc.meta.facets,
+        ...(entry.explain ? { explain: entry.explain } : {}),
+        ...(entry.inComments ? commentLanguage(this.nodeStats.get(`${entry.doc_id}::${entry.node_id}`)) : {}),
       });
     }
 
     results.sort((a, b) => b.score - a.score);
-    return results.slice(0, options?.limit || 20);
+    const ranked = this.rescorer ? this.rescore(results, query) : results;
+    const grouped = options?.group ? this.groupBySymbol(ranked) : ranked;
+    const spread = options?.diversify ? this.diversify(grouped, options.diversify) : grouped;
+    return spread.slice(0, options?.limit || 20);
+  }
+
+  /**
+   * The comment rule of one search, from its options and the
+   * setCommentMatching defaults; null when every occurrence counts.
+   */
+  private commentScope(
+    matching: CommentMatching | undefined,
+    languages: string[] | undefined
+  ): { matching: CommentMatching; languages: Set<string> | null } | null {
+    const langs = (languages ?? this.commentMatching.languages).map((l) => l.toLowerCase());
+    const scope = { matching: matching ?? this.commentMatching.matching, languages: langs.length ? new Set(langs) : null };
+    return scope.matching === "include" && !scope.languages ? null : scope;
+  }
+
+  /**
+   * `posting` with the occurrences a comment scope does not count
+   * dropped, or null when none are left. Comments in a language outside
+   * `languages` count as if excluded; undetected ones always count.
+   */
+  private commentScoped(
+    posting: Posting,
+    stats: NodeStats,
+    scope: { matching: CommentMatching; languages: Set<string> | null }
+  ): Posting | null {
+    const inComments = posting.comment_positions ?? [];
+    const counted = !scope.languages || stats.comment_language === undefined || scope.languages.has(stats.comment_language);
+    let positions: number[];
+    if (scope.matching === "only") {
+      positions = counted ? inComments : [];
+    } else if (scope.matching === "exclude" || !counted) {
+      if (inComments.length === 0) return posting;
+      const dropped = new Set(inComments);
+      positions = posting.positions.filter((p) => !dropped.has(p));
+    } else {
+      return posting;
+    }
+    if (positions.length === 0) return null;
+    return {
+      doc_id: posting.doc_id,
+      node_id: posting.node_id,
+      weight: posting.weight,
+      positions,
+      term_frequency: positions.length,
+      ...(scope.matching === "only" ? { comment_positions: positions } : {}),
+    };
+  }
+
+  /**
+   * Ranked results with the code matches inside one top-level symbol
+   * folded into the first of them: a class and its methods, or a
+   * function and its closures. Go methods whose type is declared in
+   * another file fold by receiver type instead.
+   */
+  private groupBySymbol(results: SearchResult[]): SearchResult[] {
+    const out: SearchResult[] = [];
+    const leads = new Map<string, SearchResult>();
+    for (const r of results) {
+      const doc = this.docs.get(r.doc_id)!;
+      let node = doc.tree.find((n) => n.node_id === r.node_id);
+      if (!node || !doc.meta.facets.content_type?.includes("code")) {
+        out.push(r);
+        continue;
+      }
+      for (let up = node; up; up = doc.tree.find((n) => n.node_id === up!.parent_id)) node = up;
+      const receiver = node.title.startsWith("method ") ? node.summary.match(/^func\s+\(\s*(?:\w+\s+)?\*?(\w+)/)?.[1] : undefined;
+      const symbol = receiver ?? node.title;
+      const key = `${r.doc_id}::${receiver ? `recv:${receiver}` : node.node_id}`;
+      const lead = leads.get(key);
+      if (!lead) {
+        leads.set(key, r);
+        out.push(r);
+        continue;
+      }
+      lead.group ??= { symbol, matches: 1, node_ids: [] };
+      lead.group.matches++;
+      lead.group.node_ids.push(r.node_id);
+    }
+    return out;
+  }
+
+  /**
+   * Ranked results with those past a file's or package's cap dropped,
+   * so the next file or package gets the slots. 0 leaves a cap off.
+   */
+  private diversify(results: SearchResult[], caps: { max_per_file?: number; max_per_package?: number }): SearchResult[] {
+    const perFile = caps.max_per_file ?? this.ranking.max_per_file;
+    const perPackage = caps.max_per_package ?? this.ranking.max_per_package;
+    if (perFile <= 0 && perPackage <= 0) return results;
+    const files = new Map<string, number>();
+    const packages = new Map<string, number>();
+    return results.filter((r) => {
+      const pkg = `${r.collection}:${r.file_path.slice(0, Math.max(0, r.file_path.lastIndexOf("/")))}`;
+      const inFile = files.get(r.doc_id) ?? 0;
+      const inPackage = packages.get(pkg) ?? 0;
+      if ((perFile > 0 && inFile >= perFile) || (perPackage > 0 && inPackage >= perPackage)) return false;
+      files.set(r.doc_id, inFile + 1);
+      packages.set(pkg, inPackage + 1);
+      return true;
+    });
+  }
+
+  /** The top candidates under the rescorer's scores, re-sorted; dropped ones removed. */
+  private rescore(results: SearchResult[], query: string): SearchResult[] {
+    const candidates = results
+      .slice(0, MAX_RESCORE_CANDIDATES)
+      .map((result) => ({ result, meta: this.docs.get(result.doc_id)!.meta }));
+    const scores = this.rescorer!(candidates, query);
+    const kept = candidates
+      .map(({ result }, i) => ({
+        ...result,
+        score: scores[i],
+        ...(result.explain ? { explain: { ...result.explain, before_rescore: result.score } } : {}),
+      }))
+      .filter((r) => r.score >= 0);
+    return kept.sort((a, b) => b.score - a.score);
+  }
+
+  /**
+   * Results for a "needs tests" query with no other terms: profiled
+   * functions by uncovered statements, most first.
+   */
+  private leastCovered(whitelist: Set<string> | null, doc_id: string | undefined, limit: number): SearchResult[] {
+    const results: SearchResult[] = [];
+    for (const [key, coverage] of this.buildNodeCoverage()) {
+      const uncovered = coverage.statements - coverage.covered;
+      if (uncovered === 0) continue;
+      const [id, node_id] = key.split("::");
+      if ((doc_id && id !== doc_id) || (whitelist && !whitelist.has(id))) continue;
+      const doc = this.docs.get(id);
+      const node = doc?.tree.find((n) => n.node_id === node_id);
+      if (!doc || !node) continue;
+      const snippet = buildDensitySnippet(node.content, [], node.title, 180);
+      results.push({
+        doc_id: id,
+        doc_title: doc.meta.title,
+        file_path: doc.meta.file_path,
+        node_id,
+        node_title: node.title,
+        level: node.level,
+        snippet,
+        score: uncovered,
+        match_positions: [],
+        matched_terms: [],
+        line_start: node.line_start,
+        line_end: node.line_end,
+        ...(node.cell ? { cell: node.cell } : {}),
+        uri: locationUri(this, doc.meta.doc_id, node.line_start, node.line_end, node.cell)!,
+        snippet_highlights: [],
+        content_matches: [],
+        collection: doc.meta.collection,
+        facets: doc.meta.facets,
+      });
+    }
+    results.sort((a, b) => b.score - a.score);
+    return results.slice(0, limit);
   }
 
   // ── Catalog with facet counts (Pagefind filter UI equivalent) ───
@@ -724,6 +1672,7 @@ export class DocumentStore {
     query?: string;
     collection?: string;
     filters?: Record<string, string | string[]>;
+    path_prefix?: string;
     limit?: number;
     offset?: number;
   }): { total: number; documents: DocumentMeta[]; facet_counts: FacetCounts } {
@@ -741,6 +1690,11 @@ export class DocumentStore {
       docs = docs.filter((d) => d.collection === options.collection);
     }
 
+    if (options?.path_prefix) {
+      const prefix = options.path_prefix;
+      docs = docs.filter((d) => d.file_path.startsWith(prefix));
+    }
+
     if (options?.filters) {
       const whitelist = this.resolveFilters(options.filters);
       if (whitelist) {
@@ -749,12 +1703,12 @@ export class DocumentStore {
     }
 
     if (options?.query) {
-      const q = options.query.toLowerCase();
+      const q = options.query.normalize("NFC").toLowerCase();
       docs = docs.filter(
         (d) =>
-          d.title.toLowerCase().includes(q) ||
-          d.description.toLowerCase().includes(q) ||
-          d.file_path.toLowerCase().includes(q)
+          d.title.normalize("NFC").toLowerCase().includes(q) ||
+          d.description.normalize("NFC").toLowerCase().includes(q) ||
+          d.file_path.normalize("NFC").toLowerCase().includes(q)
       );
     }
 
@@ -792,6 +1746,41 @@ export class DocumentStore {
     };
   }
 
+  /**
+   * Code documents at `path`: an exact file path or doc_id, else a
+   * directory prefix, or a glob when `path` contains glob characters.
+   * Sorted by path.
+   */
+  codeDocumentsAt(path: string): DocumentMeta[] {
+    const docs = Array.from(this.docs.values())
+      .map((d) => d.meta)
+      .filter((d) => d.facets.content_type?.includes("code"))
+      .sort((a, b) => a.file_path.localeCompare(b.file_path));
+    if (/[*?[{]/.test(path)) {
+      const glob = new Bun.Glob(path);
+      return docs.filter((d) => glob.match(d.file_path));
+    }
+    const exact = docs.filter((d) => d.file_path === path || d.doc_id === path);
+    if (exact.length > 0) return exact;
+    const prefix = path.endsWith("/") ? path : `${path}/`;
+    return docs.filter((d) => d.file_path.startsWith(prefix));
+  }
+
+  /** Every document at exactly `path`, one per collection indexing it. */
+  documentsAtPath(path: string): DocumentMeta[] {
+    const key = (p: string) => (this.foldPathCase ? p.toLowerCase() : p);
+    if (!this.pathDocs) {
+      this.pathDocs = new Map();
+      for (const doc of this.docs.values()) {
+        const ids = this.pathDocs.get(key(doc.meta.file_path));
+        if (ids) ids.push(doc.meta.doc_id);
+        else this.pathDocs.set(key(doc.meta.file_path), [doc.meta.doc_id]);
+      }
+    }
+    const ids = this.pathDocs.get(key(path)) ?? this.pathDocs.get(key(toolPath(path, this.pathRoots))) ?? [];
+    return ids.map((id) => this.docs.get(id)!.meta);
+  }
+
   // ── Tree operations (PageIndex-inspired tools) ──────────────────
 
   getTree(doc_id: string): TreeOutline | null {
@@ -851,6 +1840,79 @@ export class DocumentStore {
     return { doc_id, nodes: result };
   }
 
+  /**
+   * The innermost node of `doc_id` spanning lines `start`..`end` (in
+   * `cell` for notebooks): code spans nest, a method inside its class.
+   * A range crossing node bounds falls back to the node where it starts.
+   */
+  nodeAt(doc_id: string, start: number, end: number, cell?: number): TreeNode | null {
+    const doc = this.docs.get(doc_id);
+    if (!doc) return null;
+    const nodes = doc.tree.filter((n) => !cell || n.cell === cell);
+    const spanning = nodes.filter((n) => n.line_start <= start && end <= n.line_end);
+    const candidates = spanning.length > 0 ? spanning : nodes.filter((n) => n.line_start <= start && start <= n.line_end);
+    const span = (n: TreeNode) => n.line_end - n.line_start;
+    return candidates.sort((a, b) => span(a) - span(b) || b.level - a.level)[0] ?? null;
+  }
+
+  /**
+   * Up to `count` nodes of `doc_id` after (or before) a node or a line,
+   * nearest first: its `siblings` (nodes with the same parent), or
+   * every node of the `file` in document order. A line between nodes,
+   * such as a blank line between two methods, steps through the nodes
+   * around it; `from` is then null. Import blocks are skipped in code.
+   * Null when the document or node does not exist.
+   */
+  stepNodes(
+    doc_id: string,
+    at: { node_id: string } | { line: number; cell?: number },
+    direction: "next" | "previous",
+    options: { scope?: "siblings" | "file"; count?: number } = {}
+  ): { from: TreeNode | null; nodes: TreeNode[]; more: boolean } | null {
+    const doc = this.docs.get(doc_id);
+    if (!doc) return null;
+    const code = doc.meta.facets.content_type?.some((t) => t === "code" || t === "notebook");
+    const order = (a: TreeNode, b: TreeNode) =>
+      (a.cell ?? 0) - (b.cell ?? 0) || a.line_start - b.line_start || b.line_end - a.line_end;
+    const steppable = doc.tree.filter((n) => !(code && n.title === "imports")).sort(order);
+
+    let from: TreeNode | null;
+    let parent: string | null;
+    if ("node_id" in at) {
+      from = doc.tree.find((n) => n.node_id === at.node_id) ?? null;
+      if (!from) return null;
+      parent = from.parent_id;
+    } else {
+      const around = this.nodeAt(doc_id, at.line, at.line, at.cell);
+      const inChild = around?.children.some((id) => {
+        const child = doc.tree.find((n) => n.node_id === id);
+        return child && child.line_start <= at.line && at.line <= child.line_end;
+      });
+      // Inside a node's body but outside its children: between them
+      const between = around && around.children.length > 0 && !inChild && at.line > around.line_start;
+      // An import block is not stepped through, so it is a position too
+      from = around && !between && !(code && around.title === "imports") ? around : null;
+      parent = from ? from.parent_id : between ? around!.node_id : around?.parent_id ?? null;
+    }
+
+    const scope = options.scope ?? "siblings";
+    const nodes = scope === "file" ? steppable : steppable.filter((n) => n.parent_id === parent);
+    // Nodes before and after the starting point; a skipped import block
+    // counts as a position
+    const index = from ? nodes.indexOf(from) : -1;
+    let before: number, after: number;
+    if (index !== -1) {
+      [before, after] = [index, index + 1];
+    } else {
+      const cell = from?.cell ?? ("line" in at ? at.cell ?? 0 : 0);
+      const line = from?.line_start ?? ("line" in at ? at.line : 0);
+      before = after = nodes.filter((n) => (n.cell ?? 0) < cell || ((n.cell ?? 0) === cell && n.line_start <= line)).length;
+    }
+    const count = options.count ?? 1;
+    const ahead = direction === "next" ? nodes.slice(after) : nodes.slice(0, before).reverse();
+    return { from, nodes: ahead.slice(0, count), more: ahead.length > count };
+  }
+
   // ── Stats ───────────────────────────────────────────────────────
 
   getStats(): {
@@ -861,6 +1923,9 @@ export class DocumentStore {
     avg_node_length: number;
     facet_keys: string[];
     collections: string[];
+    validating: boolean;
+    generation: number;
+    reindex?: WatchQueueStatus;
   } {
     let total_words = 0;
     for (const doc of this.docs.values()) {
@@ -875,6 +1940,9 @@ export class DocumentStore {
       avg_node_length: Math.round(this.avgNodeLength),
       facet_keys: [...this.filters.keys()],
       collections: [...(this.filters.get("collection")?.keys() ?? [])],
+      validating: this.isValidating(),
+      generation: this.generation,
+      ...(this.reindexStatus ? { reindex: this.reindexStatus() } : {}),
     };
   }
 
@@ -920,15 +1988,167 @@ export class DocumentStore {
     if (!fragment) return { doc_id: entry.doc_id };
 
     // Match fragment to node via title slug (GitHub-style: lowercase, non-alphanumeric → hyphen)
-    const slug = fragment.toLowerCase().replace(/[^a-z0-9]+/g, "-").replace(/^-|-$/g, "");
+    const slug = fragment.normalize("NFC").toLowerCase().replace(/[^\p{L}\p{N}]+/gu, "-").replace(/^-|-$/g, "");
     const node = entry.tree.find((n) => {
-      const nodeSlug = n.title.toLowerCase().replace(/[^a-z0-9]+/g, "-").replace(/^-|-$/g, "");
+      const nodeSlug = n.title.normalize("NFC").toLowerCase().replace(/[^\p{L}\p{N}]+/gu, "-").replace(/^-|-$/g, "");
       return nodeSlug === slug || n.node_id === fragment;
     });
 
     return { doc_id: entry.doc_id, node_id: node?.node_id };
   }
 
+  /**
+   * Number of other code files that name the symbol at `node_id`, or 0
+   * for a non-symbol node. A file that defines a name never counts as
+   * referencing it. Identifiers are matched by exact spelling, so this
+   * is a lexical estimate: two unrelated symbols with one name share
+   * their references.
+   */
+  referenceCount(doc_id: string, node_id: string): number {
+    return this.buildReferenceCounts().get(`${doc_id}::${node_id}`) ?? 0;
+  }
+
+  private referenceBoost(nodeKey: string): number {
+    const refs = this.buildReferenceCounts().get(nodeKey);
+    if (!refs) return 1.0;
+    return 1 + this.ranking.reference_weight * (Math.log1p(refs) / Math.log1p(this.maxReferences));
+  }
+
+  private buildReferenceCounts(): Map<string, number> {
+    if (this.referenceCounts) return this.referenceCounts;
+
+    // name → doc_ids defining it, and the nodes that carry it
+    const definedIn = new Map<string, Set<string>>();
+    const nodesByName = new Map<string, string[]>();
+    const codeDocs: IndexedDocument[] = [];
+    for (const doc of this.docs.values()) {
+      if (!doc.meta.facets.content_type?.includes("code")) continue;
+      codeDocs.push(doc);
+      for (const node of doc.tree) {
+        // Code node titles are "<kind> <name>"; see code-indexer.ts
+        if (!node.title.includes(" ")) continue;
+        const name = node.title.slice(node.title.indexOf(" ") + 1);
+        if (!definedIn.has(name)) {
+          definedIn.set(name, new Set());
+          nodesByName.set(name, []);
+        }
+        definedIn.get(name)!.add(doc.meta.doc_id);
+        nodesByName.get(name)!.push(`${doc.meta.doc_id}::${node.node_id}`);
+      }
+    }
+
+    const usedIn = new Map<string, number>();
+    for (const doc of codeDocs) {
+      const seen = new Set<string>();
+      for (const node of doc.tree) {
+        for (const [identifier] of node.content.matchAll(IDENTIFIER)) {
+          if (seen.has(identifier)) continue;
+          seen.add(identifier);
+          const definers = definedIn.get(identifier);
+          if (definers && !definers.has(doc.meta.doc_id)) {
+            usedIn.set(identifier, (usedIn.get(identifier) ?? 0) + 1);
+          }
+        }
+      }
+    }
+
+    this.referenceCounts = new Map();
+    this.maxReferences = 0;
+    for (const [name, count] of usedIn) {
+      for (const key of nodesByName.get(name)!) this.referenceCounts.set(key, count);
+      this.maxReferences = Math.max(this.maxReferences, count);
+    }
+    return this.referenceCounts;
+  }
+
+  /**
+   * Code symbol names close to the words of `query` — typos within a
+   * few edits, then longer names that start with a word — for a "did
+   * you mean" hint when the query found nothing.
+   */
+  suggest(query: string, limit: number = 5): string[] {
+    const symbols = this.symbols();
+    if (symbols.size === 0) return [];
+
+    const parsed = parseQuery(query);
+    const words = (parsed ? parsed.ranked : query)
+      .normalize("NFC")
+      .split(/[^\p{L}\p{N}\p{M}_]+/u)
+      .filter((w) => w.length >= 3);
+
+    const best = new Map<string, number>();
+    for (const word of words) {
+      for (const { name, distance } of symbols.suggest(word, limit)) {
+        best.set(name, Math.min(best.get(name) ?? Infinity, distance));
+      }
+    }
+    return [...best]
+      .sort((a, b) => a[1] - b[1] || a[0].length - b[0].length)
+      .slice(0, limit)
+      .map(([name]) => name);
+  }
+
+  /** Trie of every code symbol name, built on first use. */
+  private symbols(): SymbolTrie {
+    if (!this.symbolTrie) {
+      this.symbolTrie = new SymbolTrie();
+      for (const doc of this.docs.values()) {
+        if (!doc.meta.facets.content_type?.includes("code")) continue;
+        for (const node of doc.tree) {
+          // Code node titles are "<kind> <name>"; see code-indexer.ts
+          const name = node.title.slice(node.title.indexOf(" ") + 1);
+          if (node.title.includes(" ") && name) this.symbolTrie.insert(name);
+        }
+      }
+    }
+    return this.symbolTrie;
+  }
+
+  // ── Argument completion ────────────────────────────────────────────
+  //
+  // Candidates for a partially typed tool argument, served through the
+  // MCP completion capability (see tools/resources.ts). Each returns at most
+  // `limit` values, sorted.
+
+  /** Code symbol names starting with `prefix`, shortest first. */
+  completeSymbols(prefix: string, limit: number = 100): string[] {
+    return this.symbols().complete(prefix, limit);
+  }
+
+  /** Document IDs starting with `prefix`. */
+  completeDocIds(prefix: string, limit: number = 100): string[] {
+    const ids = [...this.docs.keys()].filter((id) => id.startsWith(prefix));
+    return ids.sort().slice(0, limit);
+  }
+
+  /** Node IDs of `doc_id` starting with `prefix`, in document order. */
+  completeNodeIds(doc_id: string, prefix: string, limit: number = 100): string[] {
+    const doc = this.docs.get(doc_id);
+    if (!doc) return [];
+    return doc.tree
+      .map((n) => n.node_id)
+      .filter((id) => id.startsWith(prefix))
+      .slice(0, limit);
+  }
+
+  /**
+   * Indexed file paths starting with `prefix`. Paths are completed one
+   * directory at a time, as a shell does: a file deeper than the next
+   * "/" is folded into its directory, returned with a trailing "/".
+   * Directories are also how packages and modules are named in most
+   * languages, so the same completion serves those.
+   */
+  completePaths(prefix: string, limit: number = 100): string[] {
+    const paths = new Set<string>();
+    for (const doc of this.docs.values()) {
+      const path = doc.meta.file_path;
+      if (!path.startsWith(prefix)) continue;
+      const slash = path.indexOf("/", prefix.length);
+      paths.add(slash === -1 ? path : path.slice(0, slash + 1));
+    }
+    return [...paths].sort().slice(0, limit);
+  }
+
   /**
    * Return the DocumentMeta for a doc_id, or null if not found.
    */
@@ -939,12 +2159,108 @@ export class DocumentStore {
 
 // ── Tokenization ─────────────────────────────────────────────────────
 
-function tokenize(text: string): string[] {
-  return text
+/** An identifier in source code, as the language parsers see one */
+const IDENTIFIER = /[\p{ID_Start}_$][\p{ID_Continue}$]*/gu;
+
+/** Scripts written without spaces between words */
+const CJK_RUN = /[\p{scx=Han}\p{scx=Hiragana}\p{scx=Katakana}\p{scx=Hangul}]{2,}/gu;
+
+/** An index term and the position of the word it came from. */
+interface Token {
+  text: string;
+  position: number;
+  /** A CJK bigram; it shares its word's position */
+  bigram: boolean;
+}
+
+/**
+ * Split text into index terms. Text is NFC-normalized first, so a
+ * composed "é" and "e" + combining accent index as the same term, and
+ * letters and digits of any script count as word characters. Runs of
+ * CJK characters carry no spaces to split on, so besides the whole run
+ * they also emit overlapping character bigrams — a query for 設定 then
+ * matches ユーザー設定を読み込む. Positions count words: the bigrams
+ * take their run's position, so phrases and NEAR/n measure distance
+ * the same across a CJK run as anywhere else.
+ */
+function positionedTokens(text: string): Token[] {
+  const words = text
+    .normalize("NFC")
     .toLowerCase()
-    .replace(/[^a-z0-9_\-\.\/]/g, " ")
+    .replace(/[^\p{L}\p{N}\p{M}_\-\.\/]/gu, " ")
+    .split(/\s+/)
+    .filter((w) => w.length >= 2);
+
+  const tokens: Token[] = [];
+  words.forEach((word, position) => {
+    tokens.push({ text: word, position, bigram: false });
+    for (const [run] of word.matchAll(CJK_RUN)) {
+      if (run.length === word.length && run.length === 2) continue;
+      const chars = [...run];
+      for (let i = 0; i + 1 < chars.length; i++) tokens.push({ text: chars[i] + chars[i + 1], position, bigram: true });
+    }
+  });
+  return tokens;
+}
+
+/** The index terms of `text`, in order, without positions. */
+function tokenize(text: string): string[] {
+  return positionedTokens(text).map((t) => t.text);
+}
+
+/** Words in `text`, as positionedTokens() counts positions. */
+function wordCount(tokens: Token[]): number {
+  return tokens.length === 0 ? 0 : tokens[tokens.length - 1].position + 1;
+}
+
+/** `{ comment_language }` of a node whose comments' language was detected, else `{}` */
+function commentLanguage(stats: NodeStats | undefined): { comment_language?: string } {
+  return stats?.comment_language ? { comment_language: stats.comment_language } : {};
+}
+
+/** Characters that continue a word; a whole-word match has none on either side */
+const WORD_CHAR = "[\\p{L}\\p{N}\\p{M}_]";
+
+/**
+ * The index is case-folded and prefix-matched, so case and word
+ * boundaries are checked against each candidate node's text afterwards.
+ * Returns null when neither applies. "smart" case is sensitive only
+ * when the query contains an uppercase letter, as in ripgrep.
+ *
+ * A node passes when at least one query word occurs in it (exactly
+ * cased when sensitive, as a whole word when `wordBoundaries`); when
+ * case-sensitive, no query word may occur only in a different case.
+ */
+function textMatcher(
+  query: string,
+  mode: CaseMode,
+  wordBoundaries: boolean
+): ((text: string) => boolean) | null {
+  const normalized = query.normalize("NFC");
+  const sensitive = mode === "sensitive" || (mode === "smart" && /\p{Lu}/u.test(normalized));
+  if (!sensitive && !wordBoundaries) return null;
+
+  const words = normalized
+    .replace(/[^\p{L}\p{N}\p{M}_\-\.\/]/gu, " ")
     .split(/\s+/)
     .filter((w) => w.length >= 2);
+  if (words.length === 0) return null;
+
+  const pattern = (word: string, flags: string) => {
+    const escaped = word.replace(/[.*+?^${}()|[\]\\/]/g, "\\$&");
+    return new RegExp(wordBoundaries ? `(?<!${WORD_CHAR})${escaped}(?!${WORD_CHAR})` : escaped, flags);
+  };
+  const exact = words.map((w) => pattern(w, sensitive ? "u" : "iu"));
+  const folded = sensitive ? words.map((w) => pattern(w, "iu")) : null;
+
+  return (text) => {
+    let found = false;
+    for (let i = 0; i < words.length; i++) {
+      if (exact[i].test(text)) found = true;
+      else if (folded?.[i].test(text)) return false;
+    }
+    return found;
+  };
 }
 
 function extractCodeTokens(content: string): Set<string> {
@@ -982,6 +2298,83 @@ function stem(word: string): string {
     .replace(/ly$/, "");
 }
 
+// ── Match ranges for highlighting ────────────────────────────────────
+//
+// Offsets are recovered by re-running tokenization over the returned
+// text and keeping the words whose index terms matched, so a highlight
+// covers exactly what the index matched: "tokens." in the text if the
+// query hit "token", a CJK bigram inside a longer run.
+
+/** A run of the characters tokenize() keeps inside a word */
+const WORD_RUN = /[\p{L}\p{N}\p{M}_\-\.\/]+/gu;
+
+/** Matches listed per result in content_matches, at most */
+const MAX_CONTENT_MATCHES = 50;
+
+function findMatches(text: string, terms: Set<string>, limit: number = Infinity): MatchRange[] {
+  const ranges: MatchRange[] = [];
+  if (terms.size === 0) return ranges;
+  for (const match of text.matchAll(WORD_RUN)) {
+    if (ranges.length >= limit) break;
+    const word = match[0].toLowerCase();
+    if (word.length < 2) continue;
+    const start = match.index!;
+    if (terms.has(stem(word))) {
+      ranges.push({ start, end: start + match[0].length });
+      continue;
+    }
+    for (const run of word.matchAll(CJK_RUN)) {
+      const chars = [...run[0]];
+      let offset = start + run.index!;
+      for (let i = 0; i + 1 < chars.length; i++) {
+        const pairLength = chars[i].length + chars[i + 1].length;
+        if (terms.has(stem(chars[i] + chars[i + 1]))) {
+          const last = ranges[ranges.length - 1];
+          // Overlapping bigrams merge into one range
+          if (last && last.end > offset) last.end = offset + pairLength;
+          else ranges.push({ start: offset, end: offset + pairLength });
+        }
+        offset += chars[i].length;
+      }
+    }
+  }
+  return ranges;
+}
+
+/** Add 1-based line and column numbers to ranges within `text`. */
+function locateMatches(text: string, ranges: MatchRange[]): ContentMatch[] {
+  const located: ContentMatch[] = [];
+  let line = 1;
+  let lineStart = 0;
+  let scanned = 0;
+  for (const range of ranges) {
+    for (; scanned < range.start; scanned++) {
+      if (text.charCodeAt(scanned) === 10) {
+        line++;
+        lineStart = scanned + 1;
+      }
+    }
+    located.push({ ...range, line, column: range.start - lineStart + 1 });
+  }
+  return located;
+}
+
+/**
+ * Add byte ranges in the file to matches in a code node that was
+ * transcoded from UTF-16 or Latin-1, or in any code node when `all`
+ * (see encoding.ts).
+ */
+function withByteRanges(meta: DocumentMeta, node: TreeNode, all: boolean, matches: ContentMatch[]): ContentMatch[] {
+  const { line_offsets } = meta;
+  if (!line_offsets || node.cell || (!meta.encoding && !all) || !matches.length) return matches;
+  const encoding = meta.encoding ?? "utf-8";
+  const lines = node.content.split("\n");
+  return matches.map((m) => ({
+    ...m,
+    ...byteRange(encoding, line_offsets, node.line_start + m.line - 1, lines[m.line - 1] ?? "", m.column, m.end - m.start),
+  }));
+}
+
 // ── Density-based snippet extraction ─────────────────────────────────
 //
 // Inspired by Pagefind's excerpt generation: find the region with the
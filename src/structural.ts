@@ -0,0 +1,434 @@
+/**
+ * Structural search and replace — the structural_search and
+ * structural_replace tools
+ *
+ * Patterns are source text with holes, in the style of comby:
+ *
+ *   Connect(:[ctx], :[addr], :[port])
+ *
+ *   :[name]    any text with balanced (), [], {} and whole string
+ *              literals and comments, matched as short as possible
+ *   :[[name]]  one identifier
+ *   :[_]       like :[name], but never bound
+ *
+ * Whitespace in a pattern matches any run of whitespace, including
+ * none, except where it separates two words. A name used twice must
+ * match the same text both times. A hole at the very end of a pattern
+ * runs to the end of the statement: the next newline or `;` outside
+ * brackets.
+ *
+ * Matching is syntactic in the sense that holes never split a bracket
+ * pair, string, or comment, so `foo(:[x])` against `foo(bar(1), 2)`
+ * binds x to `bar(1), 2`. Matches never start inside a string or
+ * comment. There is no grammar behind it: `a + b * c` is just text.
+ *
+ * A rewrite template uses the same holes, filled with what they bound.
+ * structural_search previews rewrites; structural_replace writes them
+ * and is registered only with STRUCTURAL_REWRITE=1.
+ */
+
+import { chmod, rename, stat } from "node:fs/promises";
+import { extname, join, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { indexCodeFile } from "./code-indexer";
+import { currentDeadline } from "./deadline";
+import { encodeSource, readSource, type SourceText } from "./encoding";
+
+/** Longest text one hole may cover */
+const MAX_HOLE = 20_000;
+
+/** Matching steps per start position before giving up on it */
+const MAX_STEPS = 100_000;
+
+/** Files searched per call at most */
+export const MAX_STRUCTURAL_FILES = 2000;
+
+/** Extensions whose line comments start with # rather than // */
+const HASH_COMMENTS = new Set([".py", ".pyi", ".rb", ".sh", ".bash", ".zsh", ".r"]);
+
+const HOLE = /:\[\[(\w+)\]\]|:\[(\w+)\]/g;
+const IDENT = /[\p{L}\p{N}_$]+/uy;
+const CLOSERS: Record<string, string> = { "(": ")", "[": "]", "{": "}" };
+
+/** Bad patterns and templates, and anything else the caller can fix. */
+export class StructuralError extends Error {}
+
+type Element =
+  | { kind: "text"; value: string }
+  | { kind: "space" }
+  | { kind: "hole"; name: string; ident: boolean };
+
+export interface StructuralMatch {
+  /** Offsets into the source */
+  start: number;
+  end: number;
+  /** 1-based */
+  line_start: number;
+  line_end: number;
+  text: string;
+  /** Text bound to each named hole */
+  holes: Record<string, string>;
+}
+
+/** Split a pattern into literal text, whitespace, and holes. */
+export function parsePattern(pattern: string): Element[] {
+  const elements: Element[] = [];
+  const pushText = (text: string) => {
+    for (const part of text.split(/(\s+)/)) {
+      if (!part) continue;
+      if (!/^\s+$/.test(part)) elements.push({ kind: "text", value: part });
+      else if (elements.at(-1)?.kind !== "space") elements.push({ kind: "space" });
+    }
+  };
+  let last = 0;
+  for (const m of pattern.matchAll(HOLE)) {
+    pushText(pattern.slice(last, m.index));
+    elements.push({ kind: "hole", name: m[1] ?? m[2], ident: m[1] !== undefined });
+    last = m.index! + m[0].length;
+  }
+  pushText(pattern.slice(last));
+  while (elements[0]?.kind === "space") elements.shift();
+  while (elements.at(-1)?.kind === "space") elements.pop();
+
+  if (!elements.some((e) => e.kind === "text")) {
+    throw new StructuralError("pattern needs some literal text besides holes");
+  }
+  const first = elements[0];
+  if (first.kind === "hole" && !first.ident) {
+    throw new StructuralError("pattern must start with literal text or an identifier hole :[[name]]");
+  }
+  for (let i = 1; i < elements.length; i++) {
+    if (elements[i].kind === "hole" && elements[i - 1].kind === "hole") {
+      throw new StructuralError("two holes in a row are ambiguous; separate them with text");
+    }
+  }
+  return elements;
+}
+
+/** Named holes a pattern binds, in order of first use. */
+export function patternHoles(pattern: string): string[] {
+  const names = parsePattern(pattern).flatMap((e) => (e.kind === "hole" && e.name !== "_" ? [e.name] : []));
+  return [...new Set(names)];
+}
+
+/** Fill a rewrite template with the text bound to each hole. */
+export function expandTemplate(template: string, holes: Record<string, string>): string {
+  return template.replace(HOLE, (_, ident, name) => holes[ident ?? name] ?? "");
+}
+
+/** Throw when `template` uses a hole the pattern does not bind. */
+export function checkTemplate(pattern: string, template: string): void {
+  const bound = new Set(patternHoles(pattern));
+  for (const m of template.matchAll(HOLE)) {
+    const name = m[1] ?? m[2];
+    if (!bound.has(name)) {
+      throw new StructuralError(
+        name === "_" ? "rewrite cannot use :[_], which is never bound" : `rewrite uses :[${name}], which the pattern does not bind`
+      );
+    }
+  }
+}
+
+/**
+ * End of the string literal or comment starting at `i`, or -1. Single-
+ * and double-quoted strings end at the line; an unterminated quote (a
+ * Rust lifetime, an apostrophe in shell) is an ordinary character.
+ */
+function literalEnd(source: string, i: number, hashComments: boolean): number {
+  const c = source[i];
+  if (hashComments ? c === "#" : c === "/" && source[i + 1] === "/") {
+    const nl = source.indexOf("\n", i);
+    return nl === -1 ? source.length : nl;
+  }
+  if (c === "/" && source[i + 1] === "*") {
+    const close = source.indexOf("*/", i + 2);
+    return close === -1 ? source.length : close + 2;
+  }
+  if (c === '"' || c === "'" || c === "`") {
+    for (let j = i + 1; j < source.length; j++) {
+      if (source[j] === "\\") j++;
+      else if (source[j] === c) return j + 1;
+      else if (source[j] === "\n" && c !== "`") return -1;
+    }
+    return c === "`" ? source.length : -1;
+  }
+  return -1;
+}
+
+/** Whether `filePath` is in a language whose line comments start with #. */
+export function hashCommentsFor(filePath: string): boolean {
+  return HASH_COMMENTS.has(extname(filePath).toLowerCase());
+}
+
+/** `source` with every string and comment blanked to spaces; newlines and offsets are kept. */
+export function blankLiterals(source: string, hashComments = false): string {
+  let out = "";
+  let from = 0;
+  for (let i = 0; i < source.length; ) {
+    const end = literalEnd(source, i, hashComments);
+    if (end > i) {
+      out += source.slice(from, i) + source.slice(i, end).replace(/[^\n]/g, " ");
+      i = from = end;
+    } else {
+      i++;
+    }
+  }
+  return out + source.slice(from);
+}
+
+const isWord = (c: string | undefined) => c !== undefined && /[\p{L}\p{N}_$]/u.test(c);
+
+/** Possible ends of a balanced hole starting at `i`, shortest first. */
+function holeEnds(source: string, i: number, hashComments: boolean, trailing: boolean): number[] {
+  const ends: number[] = [];
+  const stack: string[] = [];
+  let j = i;
+  while (j - i <= MAX_HOLE) {
+    if (stack.length === 0) ends.push(j);
+    if (j >= source.length) break;
+    const c = source[j];
+    if (trailing && stack.length === 0 && (c === "\n" || c === ";")) break;
+    const lit = literalEnd(source, j, hashComments);
+    if (lit > j) {
+      j = lit;
+      continue;
+    }
+    if (CLOSERS[c]) stack.push(CLOSERS[c]);
+    else if (c === ")" || c === "]" || c === "}") {
+      if (stack.at(-1) !== c) break;
+      stack.pop();
+    }
+    j++;
+  }
+  if (!trailing) return ends;
+  // A trailing hole takes the whole statement, less trailing whitespace
+  let end = ends.at(-1) ?? i;
+  while (end > i && /\s/.test(source[end - 1])) end--;
+  return [end];
+}
+
+/** Non-overlapping matches of `pattern` in `source`, left to right. */
+export function findStructural(source: string, pattern: string, hashComments = false): StructuralMatch[] {
+  const elements = parsePattern(pattern);
+  const first = elements[0];
+  const lastEl = elements.at(-1)!;
+
+  // Strings and comments, where matches may not start
+  const literals: Array<[number, number]> = [];
+  for (let i = 0; i < source.length; ) {
+    const end = literalEnd(source, i, hashComments);
+    if (end > i) {
+      literals.push([i, end]);
+      i = end;
+    } else i++;
+  }
+  let lit = 0;
+  const inLiteral = (i: number) => {
+    while (lit < literals.length && literals[lit][1] <= i) lit++;
+    return lit < literals.length && literals[lit][0] <= i;
+  };
+
+  const lineStarts = [0];
+  for (let i = 0; i < source.length; i++) if (source[i] === "\n") lineStarts.push(i + 1);
+  const lineOf = (offset: number) => {
+    let lo = 0;
+    let hi = lineStarts.length - 1;
+    while (lo < hi) {
+      const mid = (lo + hi + 1) >> 1;
+      if (lineStarts[mid] <= offset) lo = mid;
+      else hi = mid - 1;
+    }
+    return lo + 1;
+  };
+
+  let steps = 0;
+  const env = new Map<string, string>();
+  const step = (i: number, k: number): number => {
+    if (++steps > MAX_STEPS) return -1;
+    if (k === elements.length) {
+      return lastEl.kind === "text" && isWord(lastEl.value.at(-1)) && isWord(source[i]) ? -1 : i;
+    }
+    const el = elements[k];
+    if (el.kind === "text") return source.startsWith(el.value, i) ? step(i + el.value.length, k + 1) : -1;
+    if (el.kind === "space") {
+      let j = i;
+      while (j < source.length && /\s/.test(source[j])) j++;
+      if (j === i && isWord(source[i - 1]) && isWord(source[i])) return -1;
+      return step(j, k + 1);
+    }
+
+    const bound = el.name === "_" ? undefined : env.get(el.name);
+    if (bound !== undefined) return source.startsWith(bound, i) ? step(i + bound.length, k + 1) : -1;
+    const bind = (end: number) => {
+      if (el.name !== "_") env.set(el.name, source.slice(i, end));
+      const result = step(end, k + 1);
+      if (result < 0 && el.name !== "_") env.delete(el.name);
+      return result;
+    };
+    if (el.ident) {
+      IDENT.lastIndex = i;
+      const m = IDENT.exec(source);
+      return m ? bind(i + m[0].length) : -1;
+    }
+    for (const end of holeEnds(source, i, hashComments, k === elements.length - 1)) {
+      const result = bind(end);
+      if (result >= 0) return result;
+    }
+    return -1;
+  };
+
+  const matches: StructuralMatch[] = [];
+  let i = 0;
+  while (i < source.length) {
+    if (first.kind === "text") {
+      i = source.indexOf(first.value, i);
+      if (i === -1) break;
+    }
+    const wordStart = first.kind === "hole" || isWord(first.kind === "text" ? first.value[0] : undefined);
+    if (inLiteral(i) || (wordStart && isWord(source[i - 1])) || (first.kind === "hole" && !isWord(source[i]))) {
+      i++;
+      continue;
+    }
+    steps = 0;
+    env.clear();
+    const end = step(i, 0);
+    if (end > i) {
+      matches.push({
+        start: i,
+        end,
+        line_start: lineOf(i),
+        line_end: lineOf(Math.max(i, end - 1)),
+        text: source.slice(i, end),
+        holes: Object.fromEntries(env),
+      });
+      i = end;
+    } else i++;
+  }
+  return matches;
+}
+
+/** `source` with every match replaced by the expanded template. */
+export function applyRewrite(source: string, matches: StructuralMatch[], template: string): string {
+  let out = "";
+  let last = 0;
+  for (const m of matches) {
+    out += source.slice(last, m.start) + expandTemplate(template, m.holes);
+    last = m.end;
+  }
+  return out + source.slice(last);
+}
+
+export interface FileMatch extends StructuralMatch {
+  doc_id: string;
+  file_path: string;
+  /** The rewrite as it would read, when a template was given */
+  replacement?: string;
+}
+
+/** Structural search and rewrite over the code collections of a store. */
+export class StructuralSearch {
+  private readonly roots: Map<string, string>;
+
+  constructor(config: IndexConfig) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  }
+
+  /**
+   * Matches in the indexed code files at `path` (file, directory prefix,
+   * or glob). Returns null when no indexed code file matches the path.
+   */
+  async search(
+    store: DocumentStore,
+    path: string,
+    pattern: string,
+    options: { rewrite?: string; limit?: number } = {}
+  ): Promise<{ files: number; truncated: boolean; total: number; matches: FileMatch[] } | null> {
+    parsePattern(pattern);
+    if (options.rewrite !== undefined) checkTemplate(pattern, options.rewrite);
+    const limit = options.limit ?? 50;
+    const docs = store.codeDocumentsAt(path);
+    if (docs.length === 0) return null;
+
+    const matches: FileMatch[] = [];
+    let total = 0;
+    let files = 0;
+    const deadline = currentDeadline();
+    for (const doc of docs.slice(0, MAX_STRUCTURAL_FILES)) {
+      if (deadline?.expired()) break;
+      const source = (await this.read(doc.collection, doc.file_path))?.text ?? null;
+      if (source === null) continue;
+      files++;
+      for (const m of findStructural(source, pattern, hashCommentsFor(doc.file_path))) {
+        total++;
+        if (matches.length >= limit) continue;
+        matches.push({
+          ...m,
+          doc_id: doc.doc_id,
+          file_path: doc.file_path,
+          ...(options.rewrite !== undefined ? { replacement: expandTemplate(options.rewrite, m.holes) } : {}),
+        });
+      }
+    }
+    return { files, truncated: docs.length > MAX_STRUCTURAL_FILES, total, matches };
+  }
+
+  /**
+   * Rewrite every match in the files at `path` and re-index the files
+   * that changed. With `dry_run`, reports the same without writing.
+   */
+  async replace(
+    store: DocumentStore,
+    path: string,
+    pattern: string,
+    rewrite: string,
+    options: { dry_run?: boolean } = {}
+  ): Promise<{ written: boolean; replacements: number; files: Array<{ doc_id: string; file_path: string; replacements: number }> } | null> {
+    parsePattern(pattern);
+    checkTemplate(pattern, rewrite);
+    const docs = store.codeDocumentsAt(path);
+    if (docs.length === 0) return null;
+
+    const changed: Array<{ doc_id: string; file_path: string; replacements: number }> = [];
+    let replacements = 0;
+    for (const doc of docs.slice(0, MAX_STRUCTURAL_FILES)) {
+      const root = this.roots.get(doc.collection);
+      const read = await this.read(doc.collection, doc.file_path);
+      if (!root || read === null) continue;
+      const source = read.text;
+      const matches = findStructural(source, pattern, hashCommentsFor(doc.file_path));
+      const next = applyRewrite(source, matches, rewrite);
+      if (next === source) continue;
+      replacements += matches.length;
+
+      let doc_id = doc.doc_id;
+      if (!options.dry_run) {
+        const absolute = join(root, doc.file_path);
+        try {
+          // Temp file + rename: a kill mid-write never leaves half a file.
+          // Written back in the encoding it was read in (UTF-16, Latin-1),
+          // with the original's mode, so scripts stay executable
+          const bytes = encodeSource(next, read);
+          const { mode } = await stat(absolute);
+          const tmp = `${absolute}.tmp-${process.pid}`;
+          await Bun.write(tmp, bytes);
+          await chmod(tmp, mode);
+          await rename(tmp, absolute);
+        } catch (err: any) {
+          throw new StructuralError(`write failed for ${doc.file_path}: ${err.message}`);
+        }
+        const indexed = await indexCodeFile(absolute, root, doc.collection);
+        store.addDocument(indexed);
+        doc_id = indexed.meta.doc_id;
+      }
+      changed.push({ doc_id, file_path: doc.file_path, replacements: matches.length });
+    }
+    return { written: !options.dry_run && changed.length > 0, replacements, files: changed };
+  }
+
+  private async read(collection: string, filePath: string): Promise<SourceText | null> {
+    const root = this.roots.get(collection);
+    if (!root) return null;
+    return readSource(join(root, filePath)).catch(() => null);
+  }
+}
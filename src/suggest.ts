@@ -0,0 +1,116 @@
+/**
+ * "Did you mean" suggestions from a trie of symbol names
+ *
+ * A zero-result query is usually a typo (`ClusetManager`) or a name
+ * remembered only in part. Instead of leaving the agent to guess at a
+ * second search, the empty response lists nearby identifiers.
+ *
+ * Names are stored in a character trie keyed by their lowercase form,
+ * which keeps the lookup cheap on large repos: the Levenshtein DP row
+ * is computed once per trie edge and shared by every name below it,
+ * and a branch is abandoned as soon as its whole row exceeds the
+ * distance budget. Names that merely start with the query word are
+ * suggested too, ranked after true near-misses.
+ */
+
+interface TrieNode {
+  children: Map<string, TrieNode>;
+  /** Original spellings of the names ending here */
+  names: Set<string>;
+}
+
+export interface Suggestion {
+  name: string;
+  /** Edit distance to the query word; prefix completions count as budget + 1 */
+  distance: number;
+}
+
+/** Edit budget for a word: 1 for short words, 2 from 8 characters. */
+export function maxEditDistance(word: string): number {
+  return word.length >= 8 ? 2 : 1;
+}
+
+export class SymbolTrie {
+  private readonly root: TrieNode = { children: new Map(), names: new Set() };
+  private count = 0;
+
+  insert(name: string): void {
+    let node = this.root;
+    for (const ch of name.toLowerCase()) {
+      let next = node.children.get(ch);
+      if (!next) {
+        next = { children: new Map(), names: new Set() };
+        node.children.set(ch, next);
+      }
+      node = next;
+    }
+    if (!node.names.has(name)) this.count++;
+    node.names.add(name);
+  }
+
+  get size(): number {
+    return this.count;
+  }
+
+  /**
+   * Names within `maxDistance` edits of `word` (case-insensitive), then
+   * names that extend it, closest first. Exact matches are omitted.
+   */
+  suggest(word: string, limit: number = 5, maxDistance: number = maxEditDistance(word)): Suggestion[] {
+    const target = [...word.toLowerCase()];
+    const found = new Map<string, number>();
+    const firstRow = target.map((_, i) => i + 1);
+
+    const walk = (node: TrieNode, ch: string, previous: number[], depth: number) => {
+      const row = [depth];
+      for (let i = 0; i < target.length; i++) {
+        const cost = target[i] === ch ? 0 : 1;
+        row.push(Math.min(row[i] + 1, previous[i + 1] + 1, previous[i] + cost));
+      }
+      const distance = row[target.length];
+      if (distance > 0 && distance <= maxDistance) {
+        for (const name of node.names) found.set(name, Math.min(found.get(name) ?? Infinity, distance));
+      }
+      if (Math.min(...row) > maxDistance) return;
+      for (const [next, child] of node.children) walk(child, next, row, depth + 1);
+    };
+    for (const [ch, child] of this.root.children) walk(child, ch, [0, ...firstRow], 1);
+
+    // Prefix completions: everything below the node for `word` itself
+    let node: TrieNode | undefined = this.root;
+    for (const ch of target) node = node?.children.get(ch);
+    if (node) {
+      const stack: TrieNode[] = [...node.children.values()];
+      while (stack.length > 0 && found.size < limit * 4) {
+        const next = stack.pop()!;
+        for (const name of next.names) if (!found.has(name)) found.set(name, maxDistance + 1);
+        stack.push(...next.children.values());
+      }
+    }
+
+    return [...found]
+      .map(([name, distance]) => ({ name, distance }))
+      .sort((a, b) => a.distance - b.distance || a.name.length - b.name.length || (a.name < b.name ? -1 : 1))
+      .slice(0, limit);
+  }
+
+  /**
+   * Names starting with `prefix` (case-insensitive), shortest first,
+   * for argument completion. The trie is walked breadth-first and the
+   * walk stops after the first depth that fills `limit`.
+   */
+  complete(prefix: string, limit: number = 100): string[] {
+    let node: TrieNode | undefined = this.root;
+    for (const ch of prefix.toLowerCase()) node = node?.children.get(ch);
+    if (!node) return [];
+
+    const found: string[] = [];
+    let level: TrieNode[] = [node];
+    while (level.length > 0 && found.length < limit) {
+      const names = level.flatMap((n) => [...n.names]).sort();
+      found.push(...names);
+      level = level.flatMap((n) => [...n.children.values()]);
+    }
+    return found.slice(0, limit);
+  }
+}
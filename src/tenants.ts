@@ -0,0 +1,292 @@
+/**
+ * Multi-tenant server mode — many isolated project indexes, one process
+ *
+ * A platform team running treenav as a shared service would otherwise
+ * need one server per project. In tenant mode the HTTP server reads a
+ * tenants file (TENANTS_CONFIG) and routes `/projects/<id>/mcp` to that
+ * project's own DocumentStore and tool registration:
+ *
+ *   - isolation: every tenant has its own store, glossary, and (when
+ *     enabled) wiki root; a request can only ever see its tenant's index
+ *   - per-tenant config: roots, globs, tree limits, code root, wiki
+ *   - quotas: max indexed documents and requests per minute
+ *   - access: a tenant's `token` guards its endpoints, so its users hold
+ *     no key to any other tenant; HTTP_TOKEN is the operator's, for the
+ *     tenant list and tenants without a token of their own
+ *
+ * Tenants are indexed on first use, so a registry of hundreds of
+ * projects starts instantly and only pays for the ones being queried.
+ * The document quota is checked while the files are listed, so a tree
+ * over it is refused before any file is read. A tenant that failed to
+ * load is tried again on a later request, after a delay that doubles
+ * with each failure (RETRY_BASE_MS up to RETRY_MAX_MS).
+ *
+ * Tenants file format:
+ *
+ *   {
+ *     "tenants": [
+ *       {
+ *         "id": "payments",
+ *         "docs_root": "/srv/repos/payments/docs",
+ *         "code_root": "/srv/repos/payments/src",
+ *         "token": "payments-s3cret",
+ *         "quotas": { "max_documents": 5000, "requests_per_minute": 120 }
+ *       }
+ *     ]
+ *   }
+ */
+
+import { existsSync } from "node:fs";
+import { join, resolve } from "node:path";
+import { DocumentStore } from "./store";
+import { indexAllCollections } from "./indexer";
+import { DocumentQuotaError } from "./walk";
+import { applyRecencyBoost } from "./git-history";
+import { ConfigError, parsePathBoosts, parseSynonymGroups } from "./config";
+import { singleRootConfig } from "./types";
+import type { IndexConfig } from "./types";
+import type { WikiOptions } from "./curator";
+import { pathMatching } from "./paths";
+import { bearerMatches } from "./remote";
+
+/** Tenant ids appear in URLs and log lines, so keep them boring. */
+const TENANT_ID_RE = /^[a-z0-9][a-z0-9_-]{0,63}$/i;
+
+/** Delay before a failed tenant is loaded again, doubling per failure up to the max */
+export const RETRY_BASE_MS = 5_000;
+export const RETRY_MAX_MS = 5 * 60_000;
+
+export interface TenantQuotas {
+  /** Refuse to serve a tenant whose corpus lists more files to index */
+  max_documents?: number;
+  /** Requests allowed per rolling 60s window */
+  requests_per_minute?: number;
+}
+
+export interface TenantConfig {
+  id: string;
+  docs_root: string;
+  docs_glob?: string;
+  code_root?: string;
+  code_glob?: string;
+  max_depth?: number;
+  summary_length?: number;
+  glossary_path?: string;
+  /** Synonym groups, each "a|b" or ["a", "b"]; see parseSynonymGroups */
+  synonyms?: (string | string[])[];
+  /** Ranking multipliers, each "pattern=weight"; see parsePathBoosts */
+  path_boosts?: string[];
+  /** Boost for recently committed files (see RECENCY_WEIGHT); 0 or unset is off */
+  recency_weight?: number;
+  recency_half_life_days?: number;
+  quotas?: TenantQuotas;
+  /** Bearer token for this tenant's endpoints; HTTP_TOKEN when unset */
+  token?: string;
+  /** Enable the curation tools for this tenant, confined to wiki_root */
+  wiki_write?: boolean;
+  wiki_root?: string;
+}
+
+export interface Tenant {
+  config: TenantConfig;
+  store: DocumentStore;
+  wiki?: WikiOptions;
+  /** Error message when the tenant failed to load or exceeded its quota */
+  error?: string;
+}
+
+export class TenantConfigError extends Error {}
+
+/**
+ * Parse and validate a tenants file. Throws TenantConfigError on a bad
+ * id, a duplicate id, a tenant without docs_root, or malformed
+ * path_boosts.
+ */
+export function parseTenantsConfig(raw: unknown): TenantConfig[] {
+  const list = (raw as { tenants?: unknown })?.tenants;
+  if (!Array.isArray(list)) {
+    throw new TenantConfigError('tenants file must be an object with a "tenants" array');
+  }
+
+  const seen = new Set<string>();
+  return list.map((entry, i) => {
+    const t = entry as TenantConfig;
+    if (typeof t?.id !== "string" || !TENANT_ID_RE.test(t.id)) {
+      throw new TenantConfigError(`tenants[${i}]: id must match ${TENANT_ID_RE}`);
+    }
+    if (seen.has(t.id)) {
+      throw new TenantConfigError(`tenants[${i}]: duplicate id "${t.id}"`);
+    }
+    if (typeof t.docs_root !== "string" || t.docs_root === "") {
+      throw new TenantConfigError(`tenants[${i}] (${t.id}): docs_root is required`);
+    }
+    if (t.token !== undefined && (typeof t.token !== "string" || t.token === "")) {
+      throw new TenantConfigError(`tenants[${i}] (${t.id}): token must be a non-empty string`);
+    }
+    try {
+      parsePathBoosts(t.path_boosts ?? [], `tenants[${i}] (${t.id}): path_boosts`);
+    } catch (err) {
+      if (err instanceof ConfigError) throw new TenantConfigError(err.message);
+      throw err;
+    }
+    seen.add(t.id);
+    return t;
+  });
+}
+
+/** Build the IndexConfig for a tenant, mirroring the single-tenant env vars. */
+export function tenantIndexConfig(tenant: TenantConfig): IndexConfig {
+  const config = singleRootConfig(tenant.docs_root);
+  if (tenant.docs_glob) config.collections[0].glob_pattern = tenant.docs_glob;
+  config.max_depth = tenant.max_depth ?? 6;
+  config.summary_length = tenant.summary_length ?? 200;
+  if (tenant.code_root) {
+    config.code_collections = [
+      { name: "code", root: tenant.code_root, weight: 1.0, glob_pattern: tenant.code_glob },
+    ];
+  }
+  return config;
+}
+
+/**
+ * Holds every tenant's store and enforces per-tenant quotas. Stores are
+ * created and indexed on first `load()`; concurrent loads of the same
+ * tenant share one indexing pass.
+ */
+export class TenantRegistry {
+  private configs = new Map<string, TenantConfig>();
+  private tenants = new Map<string, Promise<Tenant>>();
+  /** Failed loads per tenant, and when the next attempt may start */
+  private failures = new Map<string, { attempts: number; retryAt: number }>();
+  /** Request timestamps per tenant, for the rolling rate window */
+  private requests = new Map<string, number[]>();
+
+  constructor(
+    tenants: TenantConfig[],
+    private log: (msg: string) => void = (msg) => console.log(msg)
+  ) {
+    for (const t of tenants) this.configs.set(t.id, t);
+  }
+
+  has(id: string): boolean {
+    return this.configs.has(id);
+  }
+
+  ids(): string[] {
+    return [...this.configs.keys()];
+  }
+
+  /**
+   * Whether an Authorization header may reach tenant `id`: its own
+   * token when it has one, else `operator` (HTTP_TOKEN). An unknown id
+   * needs `operator`, so ids cannot be probed without it. With id null,
+   * whether the header may see the tenant list: `operator` when set,
+   * and with no tokens at all, anyone.
+   */
+  authorized(id: string | null, header: string | undefined, operator: string | undefined): boolean {
+    if (id === null) {
+      if (operator) return bearerMatches(header, operator);
+      return ![...this.configs.values()].some((t) => t.token);
+    }
+    return bearerMatches(header, this.configs.get(id)?.token ?? operator);
+  }
+
+  /**
+   * Get the tenant, indexing it on first use. A failed load is answered
+   * from the map until its retry time, then indexed again.
+   */
+  load(id: string, now: number = Date.now()): Promise<Tenant> {
+    const existing = this.tenants.get(id);
+    const failure = this.failures.get(id);
+    if (existing && !(failure && now >= failure.retryAt)) return existing;
+
+    const config = this.configs.get(id);
+    if (!config) return Promise.reject(new TenantConfigError(`unknown tenant "${id}"`));
+
+    // Concurrent requests share this attempt until it settles
+    if (failure) failure.retryAt = Infinity;
+    const pending = this.index(config).then((tenant) => {
+      if (!tenant.error) {
+        this.failures.delete(id);
+        return tenant;
+      }
+      const attempts = (this.failures.get(id)?.attempts ?? 0) + 1;
+      const delay = Math.min(RETRY_MAX_MS, RETRY_BASE_MS * 2 ** (attempts - 1));
+      this.failures.set(id, { attempts, retryAt: Date.now() + delay });
+      return tenant;
+    });
+    this.tenants.set(id, pending);
+    return pending;
+  }
+
+  /**
+   * Record a request and report whether it fits the tenant's
+   * requests_per_minute quota. Rejected requests are not counted.
+   */
+  allowRequest(id: string, now: number = Date.now()): boolean {
+    const limit = this.configs.get(id)?.quotas?.requests_per_minute;
+    if (!limit) return true;
+
+    const window = (this.requests.get(id) ?? []).filter((t) => now - t < 60_000);
+    if (window.length >= limit) {
+      this.requests.set(id, window);
+      return false;
+    }
+    window.push(now);
+    this.requests.set(id, window);
+    return true;
+  }
+
+  /** Per-tenant summary for the top-level health endpoint. */
+  async summary(): Promise<Record<string, unknown>[]> {
+    return Promise.all(
+      this.ids().map(async (id) => {
+        const pending = this.tenants.get(id);
+        if (!pending) return { id, status: "not_loaded" };
+        const tenant = await pending;
+        if (tenant.error) return { id, status: "error", error: tenant.error };
+        const stats = tenant.store.getStats();
+        return { id, status: "ok", document_count: stats.document_count, total_nodes: stats.total_nodes };
+      })
+    );
+  }
+
+  private async index(config: TenantConfig): Promise<Tenant> {
+    const store = new DocumentStore();
+    const tenant: Tenant = { config, store };
+
+    if (config.wiki_write) {
+      tenant.wiki = {
+        root: resolve(config.wiki_root || config.docs_root),
+        collectionName: "docs",
+      };
+    }
+
+    const max = config.quotas?.max_documents;
+    try {
+      const documents = await indexAllCollections(tenantIndexConfig(config), undefined, { maxDocuments: max });
+      store.load(documents);
+
+      const glossaryPath = config.glossary_path || join(config.docs_root, "glossary.json");
+      if (existsSync(glossaryPath)) {
+        try {
+          store.loadGlossary(await Bun.file(glossaryPath).json());
+        } catch (err: any) {
+          this.log(`[tenant ${config.id}] Warning: Failed to load glossary: ${err.message}`);
+        }
+      }
+      store.loadSynonyms(parseSynonymGroups(config.synonyms ?? []));
+      store.setPathBoosts(parsePathBoosts(config.path_boosts ?? []));
+      store.setPathMatching(await pathMatching(tenantIndexConfig(config)));
+      if (config.recency_weight) {
+        applyRecencyBoost(store, tenantIndexConfig(config), config.recency_weight, config.recency_half_life_days);
+      }
+
+      this.log(`[tenant ${config.id}] Indexed ${documents.length} documents`);
+    } catch (err: any) {
+      tenant.error = err instanceof DocumentQuotaError ? `document quota exceeded: more than ${max} documents` : `indexing failed: ${err.message}`;
+      this.log(`[tenant ${config.id}] ${tenant.error}`);
+    }
+    return tenant;
+  }
+}
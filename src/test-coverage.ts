@@ -0,0 +1,183 @@
+/**
+ * Go test coverage — the coverage_for tool and the "needs tests" signal
+ *
+ * COVERAGE_PROFILE points at the output of `go test -coverprofile`:
+ *
+ *   mode: set
+ *   example.com/svc/auth/token.go:12.34,15.2 2 1
+ *
+ * Each line is one basic block: start line.column, end line.column,
+ * number of statements, and how often it ran. Blocks are attributed to
+ * the innermost function or method node whose lines contain the
+ * block's first line, which gives a covered/total statement count per
+ * function (DocumentStore.nodeCoverage).
+ *
+ * Profile paths are import paths. They resolve through the repository's
+ * go.mod files, so a block in example.com/svc/auth/token.go lands on
+ * auth/token.go in the collection holding module example.com/svc.
+ * Absolute paths (GOPATH-less `_/abs/path` too) resolve against the
+ * code collection roots. Anything else is counted as unresolved.
+ *
+ * The profile is read once at startup. Line numbers drift as the code
+ * changes, so regenerate it alongside the index.
+ *
+ * With coverage loaded, a query that asks for untested code ("auth
+ * needs tests", "uncovered handlers") drops those words and multiplies
+ * each function's score by 1 + COVERAGE_WEIGHT × its uncovered share.
+ * When nothing else is left of the query, functions are listed by
+ * uncovered statements.
+ */
+
+import { readFile } from "node:fs/promises";
+import { isAbsolute, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import type { GoModuleIndex } from "./go-modules";
+import { isWithin, relativePath } from "./paths";
+
+export interface CoverBlock {
+  start_line: number;
+  start_col: number;
+  end_line: number;
+  end_col: number;
+  statements: number;
+  /** Executions; only zero versus non-zero matters here */
+  count: number;
+}
+
+export interface CoverProfile {
+  /** set, count, or atomic */
+  mode: string;
+  /** Blocks keyed by the file name as written in the profile */
+  files: Map<string, CoverBlock[]>;
+}
+
+/** Statements of one function, and how many of them ran. */
+export interface NodeCoverage {
+  statements: number;
+  covered: number;
+}
+
+export type CoverageStatus = "covered" | "partial" | "uncovered";
+
+export function coverageStatus(c: NodeCoverage): CoverageStatus {
+  if (c.covered === 0) return "uncovered";
+  return c.covered >= c.statements ? "covered" : "partial";
+}
+
+const BLOCK_LINE = /^(.+):(\d+)\.(\d+),(\d+)\.(\d+) (\d+) (\d+)$/;
+
+/**
+ * Parse a cover profile. A block listed more than once (profiles merged
+ * from several test binaries) is kept once, with its counts combined.
+ */
+export function parseCoverProfile(text: string): CoverProfile {
+  const profile: CoverProfile = { mode: "set", files: new Map() };
+  const seen = new Map<string, CoverBlock>();
+  for (const raw of text.split("\n")) {
+    const line = raw.trim();
+    if (line.startsWith("mode:")) {
+      profile.mode = line.slice(5).trim();
+      continue;
+    }
+    const m = line.match(BLOCK_LINE);
+    if (!m) continue;
+    const [, file, sl, sc, el, ec, stmts, count] = m;
+    const key = `${file}:${sl}.${sc},${el}.${ec}`;
+    const existing = seen.get(key);
+    if (existing) {
+      existing.count = profile.mode === "set" ? Math.max(existing.count, +count) : existing.count + +count;
+      continue;
+    }
+    const block: CoverBlock = {
+      start_line: +sl,
+      start_col: +sc,
+      end_line: +el,
+      end_col: +ec,
+      statements: +stmts,
+      count: +count,
+    };
+    seen.set(key, block);
+    if (!profile.files.has(file)) profile.files.set(file, []);
+    profile.files.get(file)!.push(block);
+  }
+  return profile;
+}
+
+// ── "Needs tests" queries ────────────────────────────────────────────
+
+const NEEDS_TESTS =
+  /\b(?:(?:needs?|missing|without|lacking|no)\s+(?:unit\s+)?tests?|untested|uncovered|not\s+covered|(?:low|poor|no|missing)\s+(?:test\s+)?coverage)\b/gi;
+
+/**
+ * The rest of a query that asks for untested code, with the intent
+ * words removed, or null when the query does not ask for it.
+ */
+export function stripTestIntent(query: string): string | null {
+  const rest = query.replace(NEEDS_TESTS, " ");
+  return rest === query ? null : rest.replace(/\s+/g, " ").trim();
+}
+
+// ── Loading ──────────────────────────────────────────────────────────
+
+/**
+ * Collection and root-relative path of a profile file name, or null
+ * when it lies outside every code collection.
+ */
+export function resolveProfilePath(
+  file: string,
+  roots: { name: string; root: string }[],
+  modules: { module: string; collection: string; dir: string }[]
+): { collection: string; path: string } | null {
+  const abs = file.startsWith("_/") ? file.slice(1) : file;
+  if (isAbsolute(abs)) {
+    for (const { name, root } of roots) {
+      if (abs !== root && isWithin(root, abs)) return { collection: name, path: relativePath(root, abs) };
+    }
+    return null;
+  }
+
+  const owner = modules
+    .filter((m) => file.startsWith(`${m.module}/`))
+    .sort((a, b) => b.module.length - a.module.length)[0];
+  if (!owner) return null;
+  const rest = file.slice(owner.module.length + 1);
+  return { collection: owner.collection, path: owner.dir ? `${owner.dir}/${rest}` : rest };
+}
+
+/**
+ * Read a cover profile and hand its blocks to the store. Returns the
+ * number of files and blocks attached and of files that could not be
+ * placed in a code collection.
+ */
+export async function loadCoverProfile(
+  store: DocumentStore,
+  config: IndexConfig,
+  profilePath: string,
+  goModules?: GoModuleIndex
+): Promise<{ files: number; blocks: number; unresolved: number; mode: string }> {
+  const profile = parseCoverProfile(await readFile(profilePath, "utf-8"));
+  const roots = (config.code_collections ?? []).map((c) => ({ name: c.name, root: resolve(c.root) }));
+  const modules = goModules ? (await goModules.graph()).modules : [];
+
+  const byCollection = new Map<string, Map<string, CoverBlock[]>>(roots.map((r) => [r.name, new Map()]));
+  let blocks = 0;
+  let unresolved = 0;
+  for (const [file, fileBlocks] of profile.files) {
+    const target = resolveProfilePath(file, roots, modules);
+    const files = target ? byCollection.get(target.collection) : undefined;
+    if (!target || !files) {
+      unresolved++;
+      continue;
+    }
+    files.set(target.path, [...(files.get(target.path) ?? []), ...fileBlocks]);
+    blocks += fileBlocks.length;
+  }
+
+  let files = 0;
+  for (const [collection, collectionFiles] of byCollection) {
+    store.setCoverBlocks(collection, collectionFiles);
+    files += collectionFiles.size;
+  }
+  return { files, blocks, unresolved, mode: profile.mode };
+}
@@ -0,0 +1,47 @@
+/**
+ * Test code by path — the include_tests argument
+ *
+ * "Where is this API used in production code?" drowns in test fixtures
+ * and mocks when every _test.go and __mocks__ file answers too. Search
+ * and reference tools take `include_tests`:
+ *
+ *   true    tests count like any other file (the default)
+ *   false   leave test files out
+ *   "only"  nothing but test files
+ *
+ * A file is test code when its path says so, by the conventions of the
+ * supported languages:
+ *
+ *   names        *_test.go, *.test.ts, *.spec.js, test_*.py, *_test.py,
+ *                conftest.py, *_spec.rb, FooTest.java, FooTests.cs, FooSpec.scala,
+ *                mock_*.go, *_mock.go, *.mock.ts
+ *   directories  test/, tests/, __tests__/, spec/, testdata/, fixtures/,
+ *                __mocks__/, mocks/, testutil/, src/test/ (Maven, Gradle)
+ */
+
+import type { IncludeTests } from "./types";
+
+const TEST_NAMES = [
+  /_test\.go$/,
+  /\.(?:test|spec|mock)\.[cm]?[jt]sx?$/,
+  /^test_\w+\.pyi?$/, /_test\.pyi?$/, /^conftest\.py$/,
+  /_spec\.rb$/, /_test\.rb$/,
+  /\w(?:Test|Tests|IT|Spec)\.(?:java|kt|scala|cs|swift)$/,
+  /^mock_\w+\.go$/, /_mock\.go$/,
+  /_test\.(?:c|cc|cpp|h|hpp|rs|exs?)$/,
+];
+
+const TEST_DIRS = /(?:^|\/)(?:tests?|__tests__|spec|testdata|fixtures|__mocks__|mocks|testutils?|test-?helpers)\//;
+
+/** Whether the root-relative `relPath` is a test, fixture, or mock file. */
+export function isTestPath(relPath: string): boolean {
+  const path = relPath.replace(/\\/g, "/");
+  const name = path.slice(path.lastIndexOf("/") + 1);
+  return TEST_DIRS.test(path) || TEST_NAMES.some((re) => re.test(name));
+}
+
+/** Whether a file at `relPath` passes `include_tests` (undefined means true). */
+export function matchesTests(relPath: string, include: IncludeTests | undefined): boolean {
+  if (include === undefined || include === true) return true;
+  return isTestPath(relPath) === (include === "only");
+}
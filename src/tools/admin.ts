@@ -0,0 +1,194 @@
+/**
+ * Operator tools: capture_profile (PROFILE_DIR), and reindex_path,
+ * clear_cache, and evict_file (ADMIN_TOOLS); see admin.ts
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import { AdminError, type ReindexReport } from "../admin";
+import { DEFAULT_PROFILE_SECONDS, MAX_PROFILE_SECONDS, PROFILE_KINDS, ProfilerError, type CapturedProfile } from "../profiler";
+import { CAPTURE_PROFILE_OUTPUT, REINDEX_PATH_OUTPUT, CLEAR_CACHE_OUTPUT, EVICT_FILE_OUTPUT } from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+
+/**
+ * capture_profile: writes a new profile file to PROFILE_DIR on every
+ * call, and records the process while it runs. Replaces nothing.
+ */
+const RECORDS_PROFILE = {
+  readOnlyHint: false,
+  destructiveHint: false,
+  idempotentHint: false,
+  openWorldHint: false,
+};
+
+/**
+ * reindex_path and clear_cache: bring the index and caches back in line
+ * with the files on disk. Nothing is lost that the files do not hold,
+ * and repeating a call has no further effect.
+ */
+const REFRESHES_INDEX = {
+  readOnlyHint: false,
+  destructiveHint: false,
+  idempotentHint: true,
+  openWorldHint: false,
+};
+
+/** evict_file: removes documents from the index until they are re-indexed. */
+const EVICTS_DOCUMENTS = {
+  readOnlyHint: false,
+  destructiveHint: true,
+  idempotentHint: true,
+  openWorldHint: false,
+};
+
+/** Register capture_profile and the admin tools when they are enabled. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool } = ctx;
+
+  // ── Tool 35: capture_profile ───────────────────────────────────────
+
+  const profiler = options.profiler;
+  if (profiler) {
+    registerTool(
+      "capture_profile",
+      {
+        description:
+          "Record a CPU or heap profile of this server process for diagnosing slowness or memory growth, and write it to PROFILE_DIR. The call takes as long as the profile: 30 seconds by default, during which the server keeps answering. cpu writes a .cpuprofile (where time goes); heap writes a .heapprofile (which call sites allocate). Open either in Chrome DevTools or speedscope. Only one profile records at a time.",
+        inputSchema: {
+          kind: z.enum(PROFILE_KINDS).default("cpu").describe('"cpu" (default) or "heap"'),
+          seconds: z
+            .number()
+            .min(1)
+            .max(MAX_PROFILE_SECONDS)
+            .default(DEFAULT_PROFILE_SECONDS)
+            .describe(`How long to record (default ${DEFAULT_PROFILE_SECONDS})`),
+        },
+        outputSchema: CAPTURE_PROFILE_OUTPUT,
+        annotations: RECORDS_PROFILE,
+      },
+      async ({ kind, seconds }) => {
+        let captured: CapturedProfile;
+        try {
+          captured = await profiler.capture(kind, seconds);
+        } catch (err) {
+          if (err instanceof ProfilerError) return errorResult(err);
+          throw err;
+        }
+        return reply(
+          `Recorded a ${seconds}s ${kind} profile to ${captured.path} (${captured.bytes} bytes).`,
+          { ...captured }
+        );
+      }
+    );
+  }
+
+  // ── Admin tools (opt-in via ADMIN_TOOLS=1) ─────────────────────────
+
+  const admin = options.admin;
+  if (admin) {
+    // ── Tool 44: reindex_path ─────────────────────────────────────────
+
+    registerTool(
+      "reindex_path",
+      {
+        description:
+          "Re-index a directory or file from disk after changes the index missed (a checkout, a generator run, a sync that WATCH did not see), without restarting the server. Files whose content hash is unchanged are skipped, new files are added, and documents whose files are gone are removed. The path is relative to a collection root, or absolute; \".\" re-indexes whole collections. Admin tool: it changes what every session sees.",
+        inputSchema: {
+          path: z.string().describe('Directory or file relative to the collection root ("internal/billing"), absolute, or "." for everything'),
+          collection: z.string().optional().describe("Only this collection (default: every collection the path is in)"),
+        },
+        outputSchema: REINDEX_PATH_OUTPUT,
+        annotations: REFRESHES_INDEX,
+      },
+      async ({ path, collection }) => {
+        let reports: ReindexReport[];
+        try {
+          reports = await admin.reindexPath(path, collection);
+        } catch (err) {
+          if (err instanceof AdminError) return errorResult(err);
+          throw err;
+        }
+        return reply(formatReindex(reports), { reports, generation: store.getGeneration() });
+      }
+    );
+
+    // ── Tool 45: clear_cache ──────────────────────────────────────────
+
+    const caches = admin.listCaches();
+    registerTool(
+      "clear_cache",
+      {
+        description:
+          "Drop query-time caches so the next call rebuilds them from disk and git, e.g. after rewriting history or replacing LICENSE or CODEOWNERS files in bulk. The index itself is untouched; use reindex_path for that. Caches: " +
+          (caches.map((c) => `${c.name} (${c.description})`).join("; ") || "none") +
+          ". Admin tool.",
+        inputSchema: {
+          caches: z.array(z.string()).optional().describe("Names of the caches to drop (default: all)"),
+        },
+        outputSchema: CLEAR_CACHE_OUTPUT,
+        annotations: REFRESHES_INDEX,
+      },
+      async ({ caches: names }) => {
+        let cleared: Array<{ name: string; entries: number }>;
+        try {
+          cleared = admin.clearCaches(names);
+        } catch (err) {
+          if (err instanceof AdminError) return errorResult(err);
+          throw err;
+        }
+        const text = cleared.length
+          ? `Cleared ${cleared.length} cache(s): ${cleared.map((c) => `${c.name} (${c.entries} entries)`).join(", ")}`
+          : "No caches to clear.";
+        return reply(text, { cleared });
+      }
+    );
+
+    // ── Tool 46: evict_file ───────────────────────────────────────────
+
+    registerTool(
+      "evict_file",
+      {
+        description:
+          "Remove files from the index by file path or doc_id, without reading them: for a file that should not be served (a leaked secret, a broken generated file) until it is fixed on disk. A file still on disk comes back with the next reindex_path, WATCH flush, or restart. Admin tool: it changes what every session sees.",
+        inputSchema: {
+          targets: z.array(z.string()).min(1).describe('File paths ("internal/config/secrets.go") or doc_ids'),
+          collection: z.string().optional().describe("Only in this collection (default: every collection indexing the path)"),
+        },
+        outputSchema: EVICT_FILE_OUTPUT,
+        annotations: EVICTS_DOCUMENTS,
+      },
+      async ({ targets, collection }) => {
+        const report = admin.evict(targets, collection);
+        const lines = [
+          `Evicted ${report.evicted.length} document(s) from the index.`,
+          ...report.evicted.map((d) => `  ${d.doc_id}`),
+          ...(report.missing.length ? ["", `Not indexed: ${report.missing.join(", ")}`] : []),
+        ];
+        return reply(
+          lines.join("\n"),
+          { ...report, generation: store.getGeneration() },
+          report.evicted.length ? "ok" : "not_found",
+          report.evicted.length ? undefined : "No target names an indexed document"
+        );
+      }
+    );
+  }
+}
+
+/** reindex_path's text: per collection, what changed, with the doc_ids. */
+function formatReindex(reports: ReindexReport[]): string {
+  const lines: string[] = [];
+  for (const r of reports) {
+    if (lines.length) lines.push("");
+    lines.push(
+      `Re-indexed ${r.collection}:${r.path || "."} in ${r.elapsed_ms}ms — ${r.checked} file(s): ` +
+        `${r.added.length} added, ${r.updated.length} updated, ${r.removed.length} removed, ` +
+        `${r.unchanged} unchanged, ${r.failed.length} failed`
+    );
+    for (const [label, ids] of [["added", r.added], ["updated", r.updated], ["removed", r.removed], ["failed", r.failed]] as const) {
+      for (const id of ids) lines.push(`  ${label.padEnd(7)}  ${id}`);
+    }
+  }
+  return lines.join("\n");
+}
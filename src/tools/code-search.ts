@@ -0,0 +1,382 @@
+/**
+ * Code search tools: find_duplicates, ts_query, structural_search,
+ * structural_replace, and regex_search
+ *
+ * Pattern searches over code files that go past BM25 terms: clones,
+ * tree-sitter queries, hole-based structural patterns (and their
+ * rewrite), and regular expressions.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { CloneGroup } from "../duplicates";
+import { MAX_QUERY_FILES, TreeSitterError, type QueryResult } from "../ts-query";
+import { MAX_STRUCTURAL_FILES, StructuralError, type FileMatch, type StructuralSearch } from "../structural";
+import { MAX_REGEX_FILES, RegexSearchError, type RegexSearchResult } from "../regex-search";
+import { locationUri } from "../uris";
+import { FIND_DUPLICATES_OUTPUT, STRUCTURAL_REPLACE_OUTPUT, REGEX_SEARCH_OUTPUT, STRUCTURAL_SEARCH_OUTPUT, TS_QUERY_OUTPUT } from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { READ_ONLY, WRITES_FILES } from "./shared";
+
+/** Register the code search tools whose index is configured. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool, session } = ctx;
+
+  // ── Tool 13: find_duplicates ───────────────────────────────────────
+
+  const duplicates = options.duplicates;
+  if (duplicates) {
+    registerTool(
+      "find_duplicates",
+      {
+        description:
+          "Find cloned and near-duplicate functions and methods in the indexed code. Bodies are compared as token shingles with comments dropped and literals (and, by default, identifiers) normalized, so copies that differ only in names or constants still match. Each group lists the copies with file and line range — candidates to consolidate into one shared helper. Raise min_tokens to ignore small look-alikes; lower similarity to catch copies that drifted apart.",
+        inputSchema: {
+          path: z
+            .string()
+            .optional()
+            .describe("Only files under this path prefix (default: the session focus, else everything)"),
+          min_tokens: z
+            .number()
+            .min(10)
+            .default(50)
+            .describe("Skip functions shorter than this many tokens (default 50)"),
+          similarity: z
+            .number()
+            .min(0.5)
+            .max(1)
+            .default(0.8)
+            .describe("Minimum similarity of two copies, 0.5-1 (default 0.8; 1 = identical after normalization)"),
+          normalize_identifiers: z
+            .boolean()
+            .default(true)
+            .describe("Treat renamed variables and functions as the same code (default true)"),
+          limit: z
+            .number()
+            .min(1)
+            .max(100)
+            .default(20)
+            .describe("Max groups to return (default 20)"),
+        },
+        outputSchema: FIND_DUPLICATES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, min_tokens, similarity, normalize_identifiers, limit }) => {
+        const target = path ?? session.get().focus;
+        const found = duplicates.find(store, { path_prefix: target, min_tokens, similarity, normalize_identifiers });
+        const payload = {
+          units: found.units,
+          total: found.groups.length,
+          groups: found.groups.slice(0, limit).map((g) => ({
+            ...g,
+            members: g.members.map((m) => ({ ...m, uri: locationUri(store, m.doc_id, m.line_start, m.line_end) })),
+          })),
+        };
+        if (found.units === 0) {
+          return reply(
+            `No functions of ${min_tokens}+ tokens indexed${target ? ` under ${target}` : ""}.`,
+            payload,
+            "not_found"
+          );
+        }
+        if (payload.total === 0) {
+          return reply(
+            `No duplicates among ${found.units} functions${target ? ` under ${target}` : ""} at similarity ${similarity}.`,
+            payload
+          );
+        }
+        return reply(formatDuplicates(payload.groups, payload.total, found.units), payload);
+      }
+    );
+  }
+
+  // ── Tool 14: ts_query ──────────────────────────────────────────────
+
+  const treeSitter = options.treeSitter;
+  if (treeSitter) {
+    registerTool(
+      "ts_query",
+      {
+        description:
+          "Run a tree-sitter query (S-expression pattern with @captures and predicates such as #eq? / #match?) against indexed code files and return every captured node with its range and text. An escape hatch for structural patterns the other tools don't cover, e.g. all calls to a function, every struct with a given field, or error returns without wrapping. The grammar follows each file's extension; node type names are the grammar's own (see its node-types.json).",
+        inputSchema: {
+          path: z
+            .string()
+            .describe('A file path or doc_id, a directory prefix ("internal/"), or a glob ("**/*_handler.go")'),
+          query: z.string().min(1).describe('Tree-sitter query, e.g. (function_declaration name: (identifier) @name)'),
+          grammar: z
+            .string()
+            .optional()
+            .describe("Parse every file with this grammar instead of choosing by extension (e.g. tsx)"),
+          limit: z
+            .number()
+            .min(1)
+            .max(1000)
+            .default(100)
+            .describe("Max captures to return (default 100)"),
+        },
+        outputSchema: TS_QUERY_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, query, grammar, limit }) => {
+        const installed = await treeSitter.grammars();
+        if (grammar && !installed.has(grammar)) {
+          return errorResult(
+            new TreeSitterError(
+              `No ${grammar} grammar in ${treeSitter.grammarDir}` +
+                (installed.size ? `; installed: ${[...installed].sort().join(", ")}` : "")
+            )
+          );
+        }
+        let result: QueryResult | null;
+        try {
+          result = await treeSitter.run(store, path, query, { grammar, limit });
+        } catch (err) {
+          if (err instanceof TreeSitterError) return errorResult(err);
+          throw err;
+        }
+        if (!result) {
+          const empty = { files: 0, skipped: 0, truncated: false, total: 0, captures: [] };
+          return reply(`No indexed code files match "${path}".`, empty, "not_found");
+        }
+        const payload = {
+          ...result,
+          captures: result.captures.map((c) => ({ ...c, uri: locationUri(store, c.doc_id, c.start_line, c.end_line) })),
+        };
+        return reply(formatQueryCaptures(result, path), payload);
+      }
+    );
+  }
+
+  // ── Tools 15-16: structural_search, structural_replace ─────────────
+
+  const structural = options.structural;
+  const patternHelp =
+    "Source text with holes: :[name] matches any text with balanced brackets, strings, and comments (shortest first); :[[name]] one identifier; :[_] anything, unbound. Whitespace matches any whitespace. Reusing a name requires the same text.";
+  if (structural) {
+    registerTool(
+      "structural_search",
+      {
+        description:
+          'Search indexed code with comby-style patterns that respect brackets, strings, and comments instead of matching raw text: "Connect(:[ctx], :[addr], :[port])" finds every three-argument Connect call however its arguments nest or wrap, and reports what each hole bound. Pass rewrite to preview the replacement for each match, e.g. "Dial(:[ctx], net.JoinHostPort(:[addr], :[port]))". Nothing is written; structural_replace applies rewrites when enabled.',
+        inputSchema: {
+          path: z
+            .string()
+            .describe('A file path or doc_id, a directory prefix ("internal/"), or a glob ("**/*.go")'),
+          pattern: z.string().min(1).describe(patternHelp),
+          rewrite: z
+            .string()
+            .optional()
+            .describe("Template for a preview replacement, using the pattern's holes"),
+          limit: z
+            .number()
+            .min(1)
+            .max(500)
+            .default(50)
+            .describe("Max matches to return (default 50)"),
+        },
+        outputSchema: STRUCTURAL_SEARCH_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, pattern, rewrite, limit }) => {
+        let result: Awaited<ReturnType<StructuralSearch["search"]>>;
+        try {
+          result = await structural.search(store, path, pattern, { rewrite, limit });
+        } catch (err) {
+          if (err instanceof StructuralError) return errorResult(err);
+          throw err;
+        }
+        if (!result) {
+          const empty = { files: 0, truncated: false, total: 0, matches: [] };
+          return reply(`No indexed code files match "${path}".`, empty, "not_found");
+        }
+        const payload = {
+          ...result,
+          matches: result.matches.map(({ start, end, ...m }) => ({ ...m, uri: locationUri(store, m.doc_id, m.line_start, m.line_end) })),
+        };
+        if (result.total === 0) {
+          return reply(`No matches for \`${pattern}\` in ${result.files} file(s) under ${path}.`, payload);
+        }
+        return reply(formatStructuralMatches(result.matches, result, path), payload);
+      }
+    );
+
+    if (options.structuralRewrite) {
+      registerTool(
+        "structural_replace",
+        {
+          description:
+            "Rewrite every match of a structural pattern in the indexed code files at path, then re-index the changed files. Patterns and templates work as in structural_search; run that first with the same rewrite to review each replacement. dry_run=true reports the files and counts without writing. Requires STRUCTURAL_REWRITE=1.",
+          inputSchema: {
+            path: z
+              .string()
+              .describe('A file path or doc_id, a directory prefix ("internal/"), or a glob ("**/*.go")'),
+            pattern: z.string().min(1).describe(patternHelp),
+            rewrite: z.string().describe("Replacement template, using the pattern's holes"),
+            dry_run: z
+              .boolean()
+              .default(false)
+              .describe("Count replacements per file without touching disk"),
+          },
+          outputSchema: STRUCTURAL_REPLACE_OUTPUT,
+          annotations: WRITES_FILES,
+        },
+        async ({ path, pattern, rewrite, dry_run }) => {
+          let result: Awaited<ReturnType<StructuralSearch["replace"]>>;
+          try {
+            result = await structural.replace(store, path, pattern, rewrite, { dry_run });
+          } catch (err) {
+            if (err instanceof StructuralError) return errorResult(err);
+            throw err;
+          }
+          if (!result) {
+            const empty = { written: false, replacements: 0, files: [] };
+            return reply(`No indexed code files match "${path}".`, empty, "not_found");
+          }
+          if (result.replacements === 0) {
+            return reply(`No matches for \`${pattern}\` under ${path}; nothing to rewrite.`, result);
+          }
+          const verb = dry_run ? "Would rewrite" : "Rewrote";
+          const lines = [
+            `${verb} ${result.replacements} match(es) in ${result.files.length} file(s)${dry_run ? "" : "; re-indexed"}`,
+            ...result.files.map((f) => `  ${f.file_path}: ${f.replacements}`),
+          ];
+          return reply(lines.join("\n"), result);
+        }
+      );
+    }
+  }
+
+  // ── Tool 34: regex_search ──────────────────────────────────────────
+
+  const regex = options.regex;
+  if (regex) {
+    registerTool(
+      "regex_search",
+      {
+        description:
+          'Search the text of indexed code files like grep, with a regular expression ("func \\(\\w+ \\*Pool\\) (Get|Put)\\b") or a plain substring (literal=true: "TODO(alice)"). Returns each match with its line and column. Every file has a trigram filter, so files that cannot contain the pattern\'s literal parts are skipped without being read. Patterns are JavaScript regular expressions; ^ and $ match at line breaks. For symbol names, find_symbol is faster; for code shapes that span brackets, structural_search.',
+        inputSchema: {
+          pattern: z.string().min(1).describe("JavaScript regular expression, or the exact text with literal=true"),
+          path: z
+            .string()
+            .optional()
+            .describe('A file path or doc_id, a directory prefix ("internal/"), or a glob ("**/*.go"); default: all code files'),
+          literal: z.boolean().default(false).describe("Match pattern as plain text, not a regular expression"),
+          case: z
+            .enum(["sensitive", "insensitive", "smart"])
+            .default("sensitive")
+            .describe('Letter case matching: "sensitive" (default), "insensitive", or "smart" — sensitive only when the pattern has an uppercase letter'),
+          limit: z
+            .number()
+            .min(1)
+            .max(500)
+            .default(50)
+            .describe("Max matches to return (default 50)"),
+        },
+        outputSchema: REGEX_SEARCH_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ pattern, path, literal, case: caseMode, limit }) => {
+        let result: RegexSearchResult | null;
+        try {
+          result = await regex.search(store, pattern, { path, literal, case: caseMode, limit });
+        } catch (err) {
+          if (err instanceof RegexSearchError) return errorResult(err);
+          throw err;
+        }
+        if (!result) {
+          const empty = { files: 0, skipped: 0, truncated: false, total: 0, matches: [] };
+          return reply(`No indexed code files match "${path}".`, empty, "not_found");
+        }
+        const payload = {
+          ...result,
+          matches: result.matches.map((m) => ({ ...m, uri: locationUri(store, m.doc_id, m.line) })),
+        };
+        return reply(formatRegexMatches(result, pattern, path), payload);
+      }
+    );
+  }
+}
+
+function formatRegexMatches(result: RegexSearchResult, pattern: string, path: string | undefined): string {
+  const where = path ? ` under ${path}` : "";
+  const read = `${result.files} file(s) read${where}, ${result.skipped} skipped by trigram filters`;
+  if (result.total === 0) return `No matches for \`${pattern}\` (${read}).`;
+  const lines = [
+    `${result.total} match(es); ${read}` +
+      (result.matches.length < result.total ? `; showing ${result.matches.length}` : "") +
+      (result.truncated ? ` (stopped after ${MAX_REGEX_FILES} files)` : ""),
+  ];
+  let file = "";
+  for (const m of result.matches) {
+    if (m.file_path !== file) {
+      file = m.file_path;
+      lines.push("", file);
+    }
+    lines.push(`  L${m.line}:${m.column}: ${m.text.trim()}`);
+  }
+  return lines.join("\n");
+}
+
+function formatStructuralMatches(
+  matches: FileMatch[],
+  result: { files: number; truncated: boolean; total: number },
+  path: string
+): string {
+  const lines = [
+    `${result.total} match(es) in ${result.files} file(s) under ${path}` +
+      (matches.length < result.total ? `; showing ${matches.length}` : "") +
+      (result.truncated ? ` (only the first ${MAX_STRUCTURAL_FILES} files searched)` : ""),
+  ];
+  let file = "";
+  for (const m of matches) {
+    if (m.file_path !== file) {
+      file = m.file_path;
+      lines.push("", file);
+    }
+    const range = m.line_end > m.line_start ? `L${m.line_start}-${m.line_end}` : `L${m.line_start}`;
+    lines.push(`  ${range}: ${m.text.split("\n")[0]}${m.text.includes("\n") ? " …" : ""}`);
+    const holes = Object.entries(m.holes);
+    if (holes.length) lines.push(`    ${holes.map(([name, value]) => `${name}=${JSON.stringify(value)}`).join(" ")}`);
+    if (m.replacement !== undefined) lines.push(`    → ${m.replacement.split("\n")[0]}${m.replacement.includes("\n") ? " …" : ""}`);
+  }
+  return lines.join("\n");
+}
+
+function formatQueryCaptures(result: QueryResult, path: string): string {
+  const notes = [
+    result.skipped ? `${result.skipped} skipped without a grammar` : "",
+    result.truncated ? `only the first ${MAX_QUERY_FILES} files parsed` : "",
+  ].filter(Boolean);
+  const lines = [
+    `${result.total} capture(s) in ${result.files} file(s) under ${path}` +
+      (result.captures.length < result.total ? `; showing ${result.captures.length}` : "") +
+      (notes.length ? ` (${notes.join("; ")})` : ""),
+  ];
+  let file = "";
+  for (const c of result.captures) {
+    if (c.file_path !== file) {
+      file = c.file_path;
+      lines.push("", file);
+    }
+    const text = c.text.includes("\n") ? `${c.text.split("\n")[0]} …` : c.text;
+    lines.push(`  L${c.start_line}:${c.start_column}-L${c.end_line}:${c.end_column} @${c.capture} (${c.node_type}) ${text}`);
+  }
+  return lines.join("\n");
+}
+
+function formatDuplicates(groups: CloneGroup[], total: number, units: number): string {
+  const lines = [
+    `${total} clone group(s) among ${units} functions` + (groups.length < total ? `; showing ${groups.length}` : ""),
+  ];
+  groups.forEach((group, i) => {
+    const percent = Math.round(group.similarity * 100);
+    lines.push("", `${i + 1}. ${group.members.length} copies, ${percent}% similar`);
+    for (const m of group.members) {
+      lines.push(`  ${m.file_path}:${m.line_start}-${m.line_end} ${m.title} (${m.tokens} tokens) [${m.node_id}]`);
+    }
+  });
+  return lines.join("\n");
+}
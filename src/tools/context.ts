@@ -0,0 +1,293 @@
+/**
+ * What the tool modules register with
+ *
+ * The options registerTools was given, and the helpers that several
+ * modules share: registerTool behind the wrapper chain (see tools.ts),
+ * sparse-index misses, uri and ref resolution, the query log, stale
+ * results, and provenance.
+ */
+
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { DocumentStore } from "../store";
+import type { LazyIndex } from "../lazy-index";
+import type { IndexCoverage } from "../coverage";
+import type { DocumentMeta, SearchResult } from "../types";
+import type { GoModuleIndex } from "../go-modules";
+import type { GoStdlib } from "../go-stdlib";
+import type { GeneratorLinks } from "../generator-links";
+import type { Reranker } from "../reranker";
+import type { QueryLog } from "../query-log";
+import type { MarkerIndex } from "../markers";
+import type { DuplicateFinder } from "../duplicates";
+import type { TreeSitterQuery } from "../ts-query";
+import type { AstDiff } from "../ast-diff";
+import type { ApiDiff } from "../api-diff";
+import type { IndexAdmin } from "../admin";
+import type { ResultCache } from "../result-cache";
+import type { EditorLinks } from "../editor-links";
+import type { Hotspots } from "../hotspots";
+import type { CodeownersIndex } from "../codeowners";
+import type { LicenseIndex, Provenance } from "../licenses";
+import type { CycleFinder } from "../cycles";
+import type { FieldReferences } from "../fields";
+import type { EnumIndex } from "../enums";
+import type { EmbedIndex } from "../embeds";
+import type { ContextAudit } from "../context-audit";
+import type { ConcurrencyMap } from "../concurrency";
+import type { EntrypointIndex } from "../entrypoints";
+import { RefIndexError, type RefIndex, type RefStore } from "../ref-index";
+import type { StaleCheck, StaleFile } from "../staleness";
+import type { UsageStats } from "../usage";
+import type { StructuralSearch } from "../structural";
+import type { RegexSearch } from "../regex-search";
+import type { DefinitionPeek } from "../peek";
+import type { FileReader } from "../read-file";
+import type { BuildTargetIndex } from "../build-targets";
+import type { ConfigUsageIndex } from "../config-usages";
+import type { LogSourceIndex } from "../log-sources";
+import type { Profiler } from "../profiler";
+import { SessionState } from "../session";
+import type { ToolTimeouts } from "../deadline";
+import type { Shutdown } from "../shutdown";
+import type { PluginHost } from "../plugins";
+import { resolveUri, UriError, type ResolvedUri } from "../uris";
+import type { Breadcrumbs } from "../breadcrumbs";
+import type { WikiOptions } from "../curator.js";
+import { errorResult, toolRegistrar } from "../tools";
+
+/** What registerTools takes, each part optional; most parts enable the tools that need them. */
+export interface ToolOptions {
+  wiki?: WikiOptions;
+  lazy?: LazyIndex;
+  session?: SessionState;
+  coverage?: IndexCoverage;
+  goModules?: GoModuleIndex;
+  /** GOROOT lookups for standard library names in find_symbol and package_api */
+  stdlib?: GoStdlib;
+  /** Generator inputs for find_symbol results in generated files */
+  generatorLinks?: GeneratorLinks;
+  /** RERANK_URL: reorders search_documents' top candidates */
+  reranker?: Reranker;
+  /** QUERY_LOG: records searches and the reads that follow; enables feedback */
+  queryLog?: QueryLog;
+  /** COVERAGE_PROFILE loaded into the store; enables coverage_for */
+  coverProfile?: string;
+  markers?: MarkerIndex;
+  duplicates?: DuplicateFinder;
+  /** TREE_SITTER_GRAMMARS; enables ts_query */
+  treeSitter?: TreeSitterQuery;
+  structural?: StructuralSearch;
+  /** STRUCTURAL_REWRITE=1; enables structural_replace */
+  structuralRewrite?: boolean;
+  regex?: RegexSearch;
+  astDiff?: AstDiff;
+  usage?: UsageStats;
+  hotspots?: Hotspots;
+  codeowners?: CodeownersIndex;
+  /** LICENSE_TAGS: license and provenance on search hits, sections, and read_file */
+  licenses?: LicenseIndex;
+  cycles?: CycleFinder;
+  entrypoints?: EntrypointIndex;
+  fields?: FieldReferences;
+  enums?: EnumIndex;
+  concurrency?: ConcurrencyMap;
+  contextAudit?: ContextAudit;
+  embeds?: EmbedIndex;
+  breadcrumbs?: Breadcrumbs;
+  /** Registers next_symbol and previous_symbol */
+  symbolNav?: boolean;
+  /** Stores at git refs, for the `ref` argument */
+  refs?: RefIndex;
+  /** Query-time checks for results from files changed since indexing */
+  staleness?: StaleCheck;
+  /** Per-category deadlines; calls are not timed when omitted */
+  timeouts?: ToolTimeouts;
+  /** Tracks in-flight calls so SIGTERM can drain them; see shutdown.ts */
+  shutdown?: Shutdown;
+  /** Organization-specific tools loaded from PLUGINS */
+  plugins?: PluginHost;
+  /** PROFILE_DIR; enables capture_profile */
+  profiler?: Profiler;
+  /** Reads indexed files from the collection roots; enables read_file */
+  files?: FileReader;
+  /** Enables peek_definitions */
+  peek?: DefinitionPeek;
+  buildTargets?: BuildTargetIndex;
+  configUsages?: ConfigUsageIndex;
+  logSources?: LogSourceIndex;
+  apiDiff?: ApiDiff;
+  /** ADMIN_TOOLS=1; enables reindex_path, clear_cache, and evict_file */
+  admin?: IndexAdmin;
+  /** RESULT_CACHE_SIZE: answers of the graph tools, kept while no code file changes */
+  resultCache?: ResultCache;
+  /** EDITOR_URL and set_preferences editor_url: an editor link next to every uri */
+  editorLinks?: EditorLinks;
+}
+
+/** The store, options, and helpers every tool module registers with */
+export interface ToolContext {
+  store: DocumentStore;
+  options: ToolOptions;
+  /** server.registerTool behind the wrapper chain; see toolRegistrar */
+  registerTool: McpServer["registerTool"];
+  session: SessionState;
+  lazy?: LazyIndex;
+  coverage?: IndexCoverage;
+  /** Why a doc_id is outside a sparse index (INCLUDE), or null */
+  notIndexed(doc_id: string): string | null;
+  /** Why the session's focus is outside a sparse index, or null */
+  focusNotIndexed(): string | null;
+  /** The doc_id, and with `node` the section, that `doc_id` or `uri` names */
+  locate(
+    docs: DocumentStore,
+    doc_id: string | undefined,
+    uri: string | undefined,
+    node?: boolean
+  ): { doc_id: string; node: ResolvedUri["node"] } | UriError;
+  /** `run` against the store at `ref`, or the session's pinned generation, or the live store */
+  readAt<R extends { content: Array<{ type: "text"; text: string }>; structuredContent?: Record<string, unknown> }>(
+    ref: string | undefined,
+    run: (docs: DocumentStore) => Promise<R>
+  ): Promise<R | ReturnType<typeof errorResult>>;
+  /** Log a search to QUERY_LOG; returns its search_id */
+  logSearch(tool: string, query: string, results: SearchResult[], ref: string | undefined): string | undefined;
+  /** Log a read that may follow a logged search */
+  logRead(tool: string, doc_id: string, node_ids: string[], ref?: string): void;
+  /** `search`, with its hits from files changed since indexing flagged, or re-indexed and searched again */
+  freshSearch<T>(
+    docs: DocumentStore,
+    search: () => T,
+    hits: (found: T) => SearchResult[]
+  ): Promise<{ found: T; stale: StaleFile[]; refreshed: StaleFile[] }>;
+  /** LICENSE_TAGS license and origin by doc_id */
+  provenanceOf(docs: DocumentStore, doc_ids: string[], ref: string | undefined): Promise<Map<string, Provenance>>;
+}
+
+/** The context for `store` and `options`, with registerTool bound to `server`. */
+export function createToolContext(server: McpServer, store: DocumentStore, options: ToolOptions = {}): ToolContext {
+  const lazy = options.lazy;
+  const session = options.session ?? new SessionState();
+  const coverage = options.coverage;
+
+  const registerTool = toolRegistrar(server, store, session, options);
+
+  // Sparse index (INCLUDE): explain misses that are outside the indexed set
+  const notIndexed = (doc_id: string) => coverage?.excludedDocId(doc_id) ?? null;
+  const focusNotIndexed = () => {
+    const focus = session.get().focus;
+    return focus ? coverage?.excludedPrefix(focus) ?? null : null;
+  };
+
+  // `uri`: the read tools take a location URI from an earlier result in
+  // place of doc_id (and node ids, when `node` asks for its section)
+  const locate = (
+    docs: DocumentStore,
+    doc_id: string | undefined,
+    uri: string | undefined,
+    node = false
+  ): { doc_id: string; node: ResolvedUri["node"] } | UriError => {
+    if (uri === undefined) {
+      return doc_id === undefined || node ? new UriError(`pass doc_id${node ? " and node ids" : ""}, or uri`) : { doc_id, node: null };
+    }
+    if (doc_id !== undefined) return new UriError("pass doc_id or uri, not both");
+    try {
+      const at = resolveUri(docs, uri);
+      if (node && !at.node) return new UriError(`${uri} names a whole file; add #L<line> to pick a section`);
+      return { doc_id: at.doc.doc_id, node: at.node };
+    } catch (err) {
+      if (err instanceof UriError) return err;
+      throw err;
+    }
+  };
+
+  // `ref`: answer from the collections as of a git ref instead of the
+  // live store, and say which commit the answer came from. Without one,
+  // a session pinned with set_preferences snapshot answers from its
+  // generation, and is told when the index has moved on.
+  const refs = options.refs;
+  const readAt = async <R extends { content: Array<{ type: "text"; text: string }>; structuredContent?: Record<string, unknown> }>(
+    ref: string | undefined,
+    run: (docs: DocumentStore) => Promise<R>
+  ) => {
+    const generation = session.pinned();
+    if (ref === undefined && generation !== null) {
+      const docs = store.atGeneration(generation);
+      if (!docs) {
+        return errorResult(
+          new Error(`index generation ${generation} is no longer kept; pin again with set_preferences snapshot="pin" to read generation ${store.getGeneration()}`)
+        );
+      }
+      const result = await run(docs);
+      const current = store.getGeneration();
+      if (current !== generation) {
+        result.content[0].text = `At index generation ${generation} (pinned; the index is at ${current}, set_preferences snapshot="pin" to move up):\n\n${result.content[0].text}`;
+      }
+      if (result.structuredContent) result.structuredContent.generation = generation;
+      return result;
+    }
+    if (ref === undefined) return run(store);
+    if (!refs) return errorResult(new RefIndexError("ref is not available on this server"));
+    let at: RefStore;
+    try {
+      at = await refs.storeAt(ref);
+    } catch (err) {
+      if (err instanceof RefIndexError) return errorResult(err);
+      throw err;
+    }
+    const result = await run(at.store);
+    const commits = [...new Set(Object.values(at.commits))].map((c) => c.slice(0, 12)).join(", ");
+    result.content[0].text = `At ${ref} (${commits}):\n\n${result.content[0].text}`;
+    if (result.structuredContent) result.structuredContent.ref = ref;
+    return result;
+  };
+
+  // QUERY_LOG: searches, and the reads that follow them in this
+  // session. Nothing asked at a git ref is logged.
+  const queryLog = options.queryLog;
+  const logSearch = (tool: string, query: string, results: SearchResult[], ref: string | undefined) =>
+    queryLog && ref === undefined ? queryLog.search(session, tool, query, results) : undefined;
+  const logRead = (tool: string, doc_id: string, node_ids: string[], ref?: string) => {
+    if (queryLog && ref === undefined) queryLog.read(session, tool, doc_id, node_ids);
+  };
+
+  // Search results from files edited since indexing are flagged; with
+  // STALE_REFRESH the files are re-indexed and the search runs again,
+  // unless the session is pinned to a generation. Stores at a ref are
+  // never stale.
+  const staleness = options.staleness;
+  const freshSearch = async <T>(docs: DocumentStore, search: () => T, hits: (found: T) => SearchResult[]) => {
+    const found = search();
+    const none: StaleFile[] = [];
+    if (!staleness || docs !== store) return { found, stale: none, refreshed: none };
+    const stale = await staleness.check(hits(found).map((r) => r.doc_id));
+    if (stale.length === 0 || !staleness.refresh || session.pinned() !== null) return { found, stale, refreshed: none };
+    await staleness.reindex(stale);
+    return { found: search(), stale: none, refreshed: stale };
+  };
+
+  // LICENSE_TAGS: the license and origin of each result's file, as on
+  // disk; answers at a ref are not tagged
+  const licenses = options.licenses;
+  const provenanceOf = async (docs: DocumentStore, doc_ids: string[], ref: string | undefined) => {
+    if (!licenses || ref !== undefined) return new Map<string, Provenance>();
+    const metas = [...new Set(doc_ids)].map((id) => docs.getDocMeta(id)).filter((m): m is DocumentMeta => m !== null);
+    return licenses.provenance(metas);
+  };
+
+  return {
+    store,
+    options,
+    registerTool,
+    session,
+    lazy,
+    coverage,
+    notIndexed,
+    focusNotIndexed,
+    locate,
+    readAt,
+    logSearch,
+    logRead,
+    freshSearch,
+    provenanceOf,
+  };
+}
@@ -0,0 +1,220 @@
+/**
+ * Curation tools (WIKI_WRITE): find_similar, draft_wiki_entry, and
+ * write_wiki_entry; see curator.ts
+ */
+
+import { z } from "zod";
+import type { McpServer, RegisteredTool } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { DocumentStore } from "../store";
+import { DRAFT_WIKI_ENTRY_OUTPUT, FIND_SIMILAR_OUTPUT, WRITE_WIKI_ENTRY_OUTPUT } from "../schemas";
+import { draftWikiEntry, findSimilar, writeWikiEntry, type WikiOptions } from "../curator.js";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { READ_ONLY, WRITES_FILES } from "./shared";
+
+/** Register the curation tools when WIKI_WRITE is set; returns the switch that turns them on or off later. */
+export function register(server: McpServer, ctx: ToolContext): (wiki: WikiOptions | undefined) => boolean {
+  const { store, options, registerTool } = ctx;
+
+  // ── Curation tools (opt-in via WIKI_WRITE=1) ───────────────────────
+
+  // Write mode can be toggled at runtime (SIGHUP in server.ts). Adding
+  // or removing tools makes the SDK send notifications/tools/list_changed.
+  let wiki = options.wiki;
+  let curationTools = wiki ? registerCurationTools(registerTool, store, () => wiki!) : [];
+  const setWiki = (next: WikiOptions | undefined): boolean => {
+    const changed = !wiki !== !next;
+    wiki = next;
+    if (!changed) return false;
+    if (next) {
+      curationTools = registerCurationTools(registerTool, store, () => wiki!);
+    } else {
+      for (const tool of curationTools) tool.remove();
+      curationTools = [];
+    }
+    return true;
+  };
+  return setWiki;
+}
+
+// ── Curation tool implementations ────────────────────────────────────
+//
+// These are only registered when WIKI_WRITE=1 is set. They preserve the
+// project's core principle — zero LLM calls inside treenav — by exposing
+// deterministic BM25 / validation primitives that let a calling agent
+// curate a Karpathy-style wiki using its own LLM.
+//
+// See docs/adr/0001-llm-curated-wiki.md and docs/wiki-curation-spec.md.
+
+function jsonBlock(value: unknown): string {
+  return "```json\n" + JSON.stringify(value, null, 2) + "\n```";
+}
+
+/**
+ * Register the curation tools. `wiki` is read on every call, so the
+ * root and threshold can change without re-registering. Returns the
+ * tool handles so write mode can be switched off again.
+ */
+function registerCurationTools(
+  registerTool: McpServer["registerTool"],
+  store: DocumentStore,
+  wiki: () => WikiOptions
+): RegisteredTool[] {
+  // ── Tool 47: find_similar ─────────────────────────────────────────
+
+  const findSimilarTool = registerTool(
+    "find_similar",
+    {
+      description:
+        "Dedupe check for prospective wiki content. Runs arbitrary text through the BM25 engine and returns the top-N overlapping entries. Use this BEFORE drafting or writing a new entry to avoid creating a duplicate. Requires WIKI_WRITE=1.",
+      inputSchema: {
+        content: z
+          .string()
+          .min(1)
+          .describe(
+            "Text to check for duplicates — the full raw source you're about to curate, or a draft body"
+          ),
+        limit: z
+          .number()
+          .min(1)
+          .max(20)
+          .default(5)
+          .describe("Max matches to return"),
+        threshold: z
+          .number()
+          .min(0)
+          .max(10)
+          .default(0.1)
+          .describe("Minimum BM25 score for a match to be reported"),
+        collection: z
+          .string()
+          .optional()
+          .describe("Restrict to a single collection"),
+      },
+      outputSchema: FIND_SIMILAR_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ content, limit, threshold, collection }) => {
+      try {
+        const result = findSimilar(store, content, {
+          limit,
+          threshold,
+          collection,
+          duplicateThreshold: wiki().duplicateThreshold,
+        });
+        return reply(jsonBlock(result), { ...result });
+      } catch (err) {
+        return errorResult(err);
+      }
+    }
+  );
+
+  // ── Tool 48: draft_wiki_entry ────────────────────────────────────
+
+  const draftTool = registerTool(
+    "draft_wiki_entry",
+    {
+      description:
+        "Produce a structural scaffold for a new wiki entry: suggested path, frontmatter (type/category/tags inferred from related entries), backlink candidates, and a duplicate warning if relevant. Does NOT write anything. Use the returned scaffold to author the body with your own LLM, then call write_wiki_entry. Requires WIKI_WRITE=1.",
+      inputSchema: {
+        topic: z
+          .string()
+          .min(1)
+          .describe("Short topic handle — used for the path slug and title"),
+        raw_content: z
+          .string()
+          .min(1)
+          .describe("Source material to be distilled into the new entry"),
+        suggested_path: z
+          .string()
+          .optional()
+          .describe(
+            "Optional relative path under the wiki root. Must end in .md and stay inside the root."
+          ),
+        source_url: z
+          .string()
+          .optional()
+          .describe("Canonical URL of the raw source, echoed into frontmatter"),
+      },
+      outputSchema: DRAFT_WIKI_ENTRY_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ topic, raw_content, suggested_path, source_url }) => {
+      try {
+        const draft = draftWikiEntry(store, wiki(), {
+          topic,
+          raw_content,
+          suggested_path,
+          source_url,
+        });
+        return reply(jsonBlock(draft), { ...draft });
+      } catch (err) {
+        return errorResult(err);
+      }
+    }
+  );
+
+  // ── Tool 49: write_wiki_entry ────────────────────────────────────
+
+  const writeTool = registerTool(
+    "write_wiki_entry",
+    {
+      description:
+        "Write a curated entry to disk and trigger incremental re-index. Validates path containment, frontmatter schema, and duplicate overlap before touching disk. Use dry_run=true first to preview. On success returns the new doc_id so you can immediately call get_tree / get_node_content. Requires WIKI_WRITE=1.",
+      inputSchema: {
+        path: z
+          .string()
+          .min(1)
+          .describe("Relative path under the wiki root. Must end in .md."),
+        frontmatter: z
+          .record(z.unknown())
+          .describe(
+            "Frontmatter object. Values must be strings, numbers, booleans, or arrays of strings/numbers."
+          ),
+        content: z
+          .string()
+          .describe("Markdown body (without frontmatter fence)"),
+        dry_run: z
+          .boolean()
+          .default(false)
+          .describe("Validate and preview without touching disk"),
+        allow_duplicate: z
+          .boolean()
+          .default(false)
+          .describe(
+            "Override duplicate warning. Required when overlap exceeds WIKI_DUPLICATE_THRESHOLD."
+          ),
+        overwrite: z
+          .boolean()
+          .default(false)
+          .describe("Allow replacing an existing file at the same path"),
+      },
+      outputSchema: WRITE_WIKI_ENTRY_OUTPUT,
+      annotations: WRITES_FILES,
+    },
+    async ({
+      path,
+      frontmatter,
+      content,
+      dry_run,
+      allow_duplicate,
+      overwrite,
+    }) => {
+      try {
+        const result = await writeWikiEntry(store, wiki(), {
+          path,
+          frontmatter,
+          content,
+          dry_run,
+          allow_duplicate,
+          overwrite,
+        });
+        return reply(jsonBlock(result), { ...result });
+      } catch (err) {
+        return errorResult(err);
+      }
+    }
+  );
+
+  return [findSimilarTool, draftTool, writeTool];
+}
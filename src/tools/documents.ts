@@ -0,0 +1,302 @@
+/**
+ * Document tools: list_documents, get_tree, get_node_content, and
+ * navigate_tree
+ *
+ * The catalog and the section tree of each document, read from the live
+ * store, a pinned generation, or a git ref (readAt). get_tree and the
+ * content tools take a `uri` from an earlier result in place of doc_id.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { LazyIndex } from "../lazy-index";
+import { UriError } from "../uris";
+import { GET_NODE_CONTENT_OUTPUT, GET_TREE_OUTPUT, LIST_DOCUMENTS_OUTPUT, NAVIGATE_TREE_OUTPUT } from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { REF_INPUT, READ_ONLY, formatProvenanceLine, contentNode, sessionFooter } from "./shared";
+
+/** The `uri` argument of the read tools: a location URI from an earlier result; see uris.ts. */
+const URI_INPUT = z
+  .string()
+  .optional()
+  .describe("Instead of doc_id: a uri from an earlier result, e.g. treenav://file/internal/cluster/manager.go#L42-58");
+
+/** Register list_documents, get_tree, get_node_content, and navigate_tree. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { registerTool, session, lazy, notIndexed, focusNotIndexed, locate, readAt, logRead, provenanceOf } = ctx;
+
+  // ── Tool 1: list_documents ─────────────────────────────────────────
+
+  registerTool(
+    "list_documents",
+    {
+      description:
+        "List all indexed markdown documents. Filter by tag or keyword in title/path. Returns document metadata without content — use get_tree to explore a specific document's structure.",
+      inputSchema: {
+        query: z
+          .string()
+          .optional()
+          .describe("Filter documents by keyword in title, description, or path"),
+        tag: z
+          .string()
+          .optional()
+          .describe("Filter documents by frontmatter tag"),
+        limit: z
+          .number()
+          .min(1)
+          .max(100)
+          .optional()
+          .describe("Max results to return (default 30, or the session preference)"),
+        offset: z
+          .number()
+          .min(0)
+          .default(0)
+          .describe("Pagination offset"),
+        ref: REF_INPUT,
+      },
+      outputSchema: LIST_DOCUMENTS_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ query, tag, limit, offset, ref }) =>
+      readAt(ref, async (docs) => {
+        if (lazy && !ref && query) await lazy.expandForQuery(query);
+        const pageSize = session.limit(limit, 30, 100);
+        const result = docs.listDocuments({
+          query,
+          tag,
+          path_prefix: session.get().focus,
+          limit: pageSize,
+          offset,
+        });
+
+        const excluded = result.total === 0 ? focusNotIndexed() : null;
+        if (excluded) {
+          return reply(excluded + sessionFooter(session), { total: 0, offset, documents: [], preferences: session.get() }, "not_indexed", excluded);
+        }
+
+        const summary = result.documents
+          .map(
+            (d) =>
+              `• [${d.doc_id}] ${d.title} (${d.heading_count} sections, ${d.word_count} words)\n  path: ${d.file_path}${d.generated ? ` (${d.generated})` : ""}${d.tags.length ? `\n  tags: ${d.tags.join(", ")}` : ""}${d.references?.length ? `\n  links to: ${d.references.slice(0, 5).join(", ")}${d.references.length > 5 ? ` (+${d.references.length - 5} more)` : ""}` : ""}`
+          )
+          .join("\n\n");
+
+        return reply(
+          `Found ${result.total} documents (showing ${offset + 1}-${Math.min(offset + pageSize, result.total)}):\n\n${summary}\n\nUse get_tree with a doc_id to explore a document's section hierarchy.${lazy && !ref ? formatPendingRegions(lazy) : ""}${sessionFooter(session)}`,
+          {
+            total: result.total,
+            offset,
+            documents: result.documents.map((d) => ({
+              doc_id: d.doc_id,
+              title: d.title,
+              description: d.description,
+              file_path: d.file_path,
+              collection: d.collection,
+              word_count: d.word_count,
+              heading_count: d.heading_count,
+              last_modified: d.last_modified,
+              tags: d.tags,
+              references: d.references ?? [],
+              facets: d.facets,
+              ...(d.generated ? { generated: d.generated } : {}),
+            })),
+            ...(lazy && !ref ? { pending_regions: lazy.pendingRegions() } : {}),
+            preferences: session.get(),
+          }
+        );
+      })
+  );
+
+  // ── Tool 3: get_tree ───────────────────────────────────────────────
+
+  registerTool(
+    "get_tree",
+    {
+      description:
+        "Get the hierarchical section tree of a document. Returns an indented outline showing all headings, their node IDs, and word counts. This is the document's 'table of contents' — examine it to identify which sections contain the information you need, then use get_node_content to retrieve specific sections.",
+      inputSchema: {
+        doc_id: z
+          .string()
+          .optional()
+          .describe("Document ID (from list_documents or search_documents)"),
+        uri: URI_INPUT,
+        ref: REF_INPUT,
+      },
+      outputSchema: GET_TREE_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ doc_id: id, uri, ref }) =>
+      readAt(ref, async (docs) => {
+        const at = locate(docs, id, uri);
+        if (at instanceof UriError) return errorResult(at);
+        const doc_id = at.doc_id;
+        if (lazy && !ref) await lazy.ensureDocument(doc_id);
+        const tree = docs.getTree(doc_id);
+
+        if (!tree) {
+          const excluded = notIndexed(doc_id);
+          const text = excluded ?? `Document "${doc_id}" not found. Use list_documents to see available documents.`;
+          return reply(text, { doc_id, nodes: [] }, excluded ? "not_indexed" : "not_found", text);
+        }
+        logRead("get_tree", doc_id, [], ref);
+
+        // Format as indented tree for the agent to reason over
+        const outline = tree.nodes
+          .map((n) => {
+            const indent = "  ".repeat(n.level - 1);
+            return `${indent}[${n.node_id}] ${"#".repeat(n.level)} ${n.title} (${n.word_count} words)\n${indent}  ${n.summary ? `Summary: ${n.summary.slice(0, 120)}…` : ""}`;
+          })
+          .join("\n");
+
+        return reply(
+          `Document: ${tree.title}\nDoc ID: ${tree.doc_id}\nSections: ${tree.nodes.length}\n\n${outline}\n\nTo read a section's full content, call get_node_content("${doc_id}", ["node_id"]).\nTo get a section and all its subsections, call navigate_tree("${doc_id}", "node_id").`,
+          { doc_id: tree.doc_id, title: tree.title, nodes: tree.nodes }
+        );
+      })
+  );
+
+  // ── Tool 4: get_node_content ───────────────────────────────────────
+
+  registerTool(
+    "get_node_content",
+    {
+      description:
+        "Retrieve the full text content of one or more specific sections. Pass the node IDs obtained from get_tree or search_documents. This returns the actual content under those headings.",
+      inputSchema: {
+        doc_id: z.string().optional().describe("Document ID"),
+        node_ids: z
+          .array(z.string())
+          .min(1)
+          .max(10)
+          .optional()
+          .describe(
+            "Array of node IDs to retrieve content for (from get_tree output)"
+          ),
+        uri: z
+          .string()
+          .optional()
+          .describe("Instead of doc_id and node_ids: a uri from an earlier result; returns the innermost section spanning its lines"),
+        ref: REF_INPUT,
+      },
+      outputSchema: GET_NODE_CONTENT_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ doc_id: id, node_ids: ids, uri, ref }) =>
+      readAt(ref, async (docs) => {
+        const at = locate(docs, id, uri, ids === undefined);
+        if (at instanceof UriError) return errorResult(at);
+        const doc_id = at.doc_id;
+        const node_ids = ids ?? [at.node!.node_id];
+        if (lazy && !ref) await lazy.ensureDocument(doc_id);
+        const result = docs.getNodeContent(doc_id, node_ids);
+
+        if (!result) {
+          const excluded = notIndexed(doc_id);
+          const text = excluded ?? `Document "${doc_id}" not found.`;
+          return reply(text, { doc_id, nodes: [], missing: node_ids }, excluded ? "not_indexed" : "not_found", text);
+        }
+
+        const found = new Set(result.nodes.map((n) => n.node_id));
+        const missing = node_ids.filter((id) => !found.has(id));
+        if (result.nodes.length === 0) {
+          const text = `No matching nodes found for IDs: ${node_ids.join(", ")}. Use get_tree("${doc_id}") to see available node IDs.`;
+          return reply(text, { doc_id, nodes: [], missing }, "not_found", text);
+        }
+        logRead("get_node_content", doc_id, [...found], ref);
+        const provenance = (await provenanceOf(docs, [doc_id], ref)).get(doc_id);
+
+        const formatted = result.nodes
+          .map(
+            (n) =>
+              `━━━ ${n.title} [${n.node_id}] (H${n.level}) ━━━\n\n${n.content || "(empty section)"}`
+          )
+          .join("\n\n");
+
+        return reply(formatted + formatProvenanceLine(provenance), {
+          doc_id,
+          nodes: result.nodes.map((n) => contentNode(docs, doc_id, n)),
+          missing,
+          ...(provenance ? { provenance } : {}),
+        });
+      })
+  );
+
+  // ── Tool 5: navigate_tree ──────────────────────────────────────────
+
+  registerTool(
+    "navigate_tree",
+    {
+      description:
+        "Get a tree node and ALL its descendant sections with full content. Use this when you need to read an entire section including all its subsections. More efficient than calling get_node_content repeatedly for each child.",
+      inputSchema: {
+        doc_id: z.string().optional().describe("Document ID"),
+        node_id: z
+          .string()
+          .optional()
+          .describe("Root node ID — will return this node and all children"),
+        uri: z
+          .string()
+          .optional()
+          .describe("Instead of doc_id and node_id: a uri from an earlier result; the root is the innermost section spanning its lines"),
+        ref: REF_INPUT,
+      },
+      outputSchema: NAVIGATE_TREE_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ doc_id: id, node_id: root, uri, ref }) =>
+      readAt(ref, async (docs) => {
+        const at = locate(docs, id, uri, root === undefined);
+        if (at instanceof UriError) return errorResult(at);
+        const doc_id = at.doc_id;
+        const node_id = root ?? at.node!.node_id;
+        if (lazy && !ref) await lazy.ensureDocument(doc_id);
+        const result = docs.getSubtree(doc_id, node_id);
+
+        if (!result) {
+          const excluded = notIndexed(doc_id);
+          const text = excluded ?? `Document "${doc_id}" not found or node "${node_id}" doesn't exist.`;
+          return reply(text, { doc_id, node_id, nodes: [], total_words: 0 }, excluded ? "not_indexed" : "not_found", text);
+        }
+        logRead("navigate_tree", doc_id, [node_id], ref);
+
+        const formatted = result.nodes
+          .map((n) => {
+            const indent = "  ".repeat(Math.max(0, n.level - result.nodes[0].level));
+            return `${indent}${"#".repeat(n.level)} ${n.title} [${n.node_id}]\n${indent}${n.content || "(empty)"}`;
+          })
+          .join("\n\n");
+
+        const totalWords = result.nodes.reduce((s, n) => s + n.word_count, 0);
+        const provenance = (await provenanceOf(docs, [doc_id], ref)).get(doc_id);
+
+        return reply(
+          `Subtree: ${result.nodes[0].title} (${result.nodes.length} sections, ${totalWords} words)\n\n${formatted}${formatProvenanceLine(provenance)}`,
+          {
+            doc_id,
+            node_id,
+            nodes: result.nodes.map((n) => contentNode(docs, doc_id, n)),
+            total_words: totalWords,
+            ...(provenance ? { provenance } : {}),
+          }
+        );
+      })
+  );
+}
+
+/**
+ * Footer for list_documents in lazy mode: the largest regions that have
+ * not been parsed yet, so the agent knows the catalog is partial.
+ */
+function formatPendingRegions(lazy: LazyIndex): string {
+  const pending = lazy.pendingRegions();
+  if (pending.length === 0) return "";
+
+  const shown = pending
+    .slice(0, 10)
+    .map((r) => `• ${r.collection}:${r.path}/ (${r.file_count} files)`)
+    .join("\n");
+  const more = pending.length > 10 ? `\n(+${pending.length - 10} more regions)` : "";
+
+  return `\n\nNot yet indexed (lazy mode — expanded when a query or doc_id touches them):\n${shown}${more}`;
+}
@@ -0,0 +1,119 @@
+/** read_file: lines of an indexed file, read from disk */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { DocumentMeta } from "../types";
+import { DEFAULT_READ_BYTES, MAX_READ_BYTES, ReadFileError, type FileExcerpt } from "../read-file";
+import { documentAt, locationUri, parseUri, UriError } from "../uris";
+import { READ_FILE_OUTPUT } from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { READ_ONLY, formatProvenanceLine } from "./shared";
+
+/** Register read_file when a FileReader is configured. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool, lazy, notIndexed, logRead, provenanceOf } = ctx;
+
+  // ── Tool 36: read_file ────────────────────────────────────────────
+
+  const files = options.files;
+  if (files) {
+    registerTool(
+      "read_file",
+      {
+        description:
+          "Read lines of an indexed file (docs or code) as it is on disk now, with line numbers. Pass path with start_line and end_line, or the uri of an earlier result to read the lines it names. At most max_bytes are returned; when that cuts the range short, the answer says so and gives next_start_line to continue from, and for a line longer than max_bytes next_start_byte too. Use this instead of reading a whole file for the lines around a search hit or symbol; for whole sections, get_node_content.",
+        inputSchema: {
+          path: z.string().optional().describe('File path ("internal/pool/pool.go") or doc_id'),
+          uri: z
+            .string()
+            .optional()
+            .describe("Instead of path: a uri from an earlier result; its #L lines are the default range"),
+          start_line: z.number().int().min(1).optional().describe("First line to return, 1-based (default 1)"),
+          end_line: z.number().int().min(1).optional().describe("Last line to return, inclusive (default: the end of the file)"),
+          start_byte: z
+            .number()
+            .int()
+            .min(0)
+            .optional()
+            .describe("UTF-8 byte of start_line to begin at (default 0); pass next_start_byte to go on with a cut line"),
+          max_bytes: z
+            .number()
+            .int()
+            .min(1)
+            .max(MAX_READ_BYTES)
+            .default(DEFAULT_READ_BYTES)
+            .describe(`Most bytes of content to return (default ${DEFAULT_READ_BYTES}); whole lines are kept`),
+        },
+        outputSchema: READ_FILE_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, uri, start_line, end_line, start_byte, max_bytes }) => {
+        if ((path === undefined) === (uri === undefined)) return errorResult(new UriError("pass path or uri"));
+        let doc: DocumentMeta | null;
+        let range = { start_line, end_line };
+        if (uri !== undefined) {
+          try {
+            const location = parseUri(uri);
+            if (location.cell) throw new UriError(`${uri} names lines within a notebook cell; read_file takes file lines`);
+            doc = documentAt(store, location);
+            range = { start_line: start_line ?? location.line_start, end_line: end_line ?? location.line_end ?? location.line_start };
+          } catch (err) {
+            if (err instanceof UriError) return errorResult(err);
+            throw err;
+          }
+        } else {
+          if (lazy) await lazy.ensureDocument(path!);
+          doc = store.getDocMeta(path!);
+          if (!doc) {
+            const at = store.documentsAtPath(path!);
+            if (at.length > 1) {
+              return errorResult(new UriError(`"${path}" is indexed in ${at.map((d) => d.collection).join(", ")}; pass the doc_id`));
+            }
+            doc = at[0] ?? null;
+          }
+          if (!doc) {
+            const excluded = notIndexed(path!);
+            const text = excluded ?? `No indexed file at "${path}".`;
+            const empty = { file_path: path!, start_line: 0, end_line: 0, total_lines: 0, content: "", bytes: 0, truncated: false };
+            return reply(text, empty, excluded ? "not_indexed" : "not_found", text);
+          }
+        }
+
+        let excerpt: FileExcerpt;
+        try {
+          excerpt = await files.read(doc, { ...range, max_bytes, start_byte });
+        } catch (err) {
+          if (err instanceof ReadFileError) return errorResult(err);
+          throw err;
+        }
+        logRead("read_file", doc.doc_id, []);
+        const provenance = (await provenanceOf(store, [doc.doc_id], undefined)).get(doc.doc_id);
+        return reply(formatExcerpt(excerpt, max_bytes) + formatProvenanceLine(provenance), {
+          ...excerpt,
+          uri: locationUri(store, doc.doc_id, excerpt.start_line, excerpt.end_line),
+          ...(provenance ? { provenance } : {}),
+        });
+      }
+    );
+  }
+}
+
+/** read_file's text: the lines, numbered, and a notice when max_bytes cut them short. */
+function formatExcerpt(excerpt: FileExcerpt, maxBytes: number): string {
+  const { start_line: start, end_line: end } = excerpt;
+  const width = String(end).length;
+  const numbered = excerpt.content
+    .split("\n")
+    .map((line, i) => `${String(start + i).padStart(width)}  ${line}`)
+    .join("\n");
+  const from = excerpt.start_byte ? ` (line ${start} from byte ${excerpt.start_byte})` : "";
+  const header = `${excerpt.file_path}, lines ${start}-${end} of ${excerpt.total_lines}${from}:`;
+  if (!excerpt.truncated) return `${header}\n\n${numbered}`;
+  const notice = excerpt.cut_line
+    ? `[Truncated: line ${start} is longer than max_bytes=${maxBytes}; ${excerpt.bytes} bytes of it are shown. ` +
+      `Continue with start_line=${start} start_byte=${excerpt.next_start_byte}, or raise max_bytes.]`
+    : `[Truncated at max_bytes=${maxBytes}: ${excerpt.bytes} bytes returned.` +
+      `${excerpt.next_start_line === undefined ? "" : ` Continue with start_line=${excerpt.next_start_line}, or raise max_bytes.`}]`;
+  return `${header}\n\n${numbered}\n\n${notice}`;
+}
@@ -0,0 +1,644 @@
+/**
+ * Go tools: module_info, package_api, coverage_for, concurrency_map,
+ * context_audit, list_embeds, and api_diff
+ *
+ * Answers about Go modules and packages under the code roots, each
+ * registered only when its index or profile is configured.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import { findModule, moduleForPath, type GoModule, type GoModuleGraph } from "../go-modules";
+import { readPackageApi, type GoApiEntry, type GoPackageApi } from "../go-api";
+import { coverageStatus } from "../test-coverage";
+import { ApiDiffError, type ApiDiffResult } from "../api-diff";
+import type { EmbedReport } from "../embeds";
+import { CONTEXT_FINDING_KINDS, type ContextAuditReport, type ContextFinding, type ContextFindingKind } from "../context-audit";
+import { CONCURRENCY_KINDS, type ConcurrencyKind, type ConcurrencyReport, type ConcurrencySite } from "../concurrency";
+import { fenceDiagram, GRAPH_FORMATS, renderDiagram } from "../graph-format";
+import { locationUri } from "../uris";
+import {
+  CONCURRENCY_MAP_OUTPUT,
+  CONTEXT_AUDIT_OUTPUT,
+  COVERAGE_FOR_OUTPUT,
+  LIST_EMBEDS_OUTPUT,
+  MODULE_INFO_OUTPUT,
+  PACKAGE_API_OUTPUT,
+  API_DIFF_OUTPUT,
+} from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { INCLUDE_TESTS_INPUT, READ_ONLY } from "./shared";
+
+/** Register the Go tools whose index is configured. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool, session } = ctx;
+
+  // ── Tool 9: module_info ────────────────────────────────────────────
+
+  const goModules = options.goModules;
+  if (goModules) {
+    registerTool(
+      "module_info",
+      {
+        description:
+          "Go module metadata from go.mod, go.sum, and go.work. Without arguments, lists every module in the code collections with its Go version, dependency counts, and how the repository's own modules depend on each other (including go.work workspaces). With a module path, go.mod directory, or any file path, shows that module in detail: direct and indirect dependencies with versions, replace and exclude directives, and which in-repo modules it uses or is used by.",
+        inputSchema: {
+          module: z
+            .string()
+            .optional()
+            .describe('Module path (e.g. "github.com/acme/api"), a go.mod directory, or a file path inside a module'),
+          include_indirect: z
+            .boolean()
+            .default(true)
+            .describe("List // indirect dependencies too (default true)"),
+          format: z
+            .enum(GRAPH_FORMATS)
+            .default("text")
+            .describe('"dot" or "mermaid" to answer with the in-repo module graph as a diagram, ready to paste into docs (default "text")'),
+        },
+        outputSchema: MODULE_INFO_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ module: query, include_indirect, format }) => {
+        const graph = await goModules.graph();
+        const shown = (m: GoModule): GoModule =>
+          include_indirect ? m : { ...m, require: m.require.filter((r) => !r.indirect) };
+        const diagram = (modules: GoModule[], edges: GoModuleGraph["edges"]) =>
+          renderDiagram(
+            format,
+            edges.map((e) => ({ from: e.from, to: e.to, label: e.via === "replace" ? "replace" : undefined })),
+            { nodes: modules.map((m) => m.module), dashed: ["replace"] }
+          );
+
+        if (query === undefined) {
+          const drawn = diagram(graph.modules, graph.edges);
+          const text =
+            graph.modules.length === 0 && graph.workspaces.length === 0
+              ? "No go.mod or go.work files found in the code collections."
+              : drawn
+                ? fenceDiagram(format, drawn)
+                : formatModuleGraph(graph);
+          return reply(text, {
+            modules: graph.modules.map(shown),
+            workspaces: graph.workspaces,
+            edges: graph.edges,
+            ...(drawn ? { diagram: drawn } : {}),
+          });
+        }
+
+        // A dependency path is never resolved as a file path into the root module
+        const dependents = graph.modules.filter((m) => m.require.some((r) => r.path === query));
+        const mod = findModule(graph, query) ?? (dependents.length === 0 ? moduleForPath(graph, query) : null);
+        if (!mod) {
+          const message =
+            dependents.length > 0
+              ? `"${query}" is an external dependency of ${dependents.map((m) => m.module).join(", ")}, not a module in the code collections.`
+              : `No Go module matches "${query}".`;
+          return reply(
+            `${message} Call module_info without arguments to list the modules.`,
+            { modules: [], workspaces: [], edges: [] },
+            "not_found",
+            message
+          );
+        }
+        const edges = graph.edges.filter((e) => e.from === mod.module || e.to === mod.module);
+        const workspaces = graph.workspaces.filter((w) => w.modules.includes(mod.module));
+        const drawn = diagram([mod], edges);
+        return reply(drawn ? fenceDiagram(format, drawn) : formatModule(shown(mod), edges, workspaces), {
+          modules: [shown(mod)],
+          workspaces,
+          edges,
+          ...(drawn ? { diagram: drawn } : {}),
+        });
+      }
+    );
+
+    // ── Tool 10: package_api ─────────────────────────────────────────
+
+    registerTool(
+      "package_api",
+      {
+        description:
+          "The exported API of one Go package, like `go doc`: constants, variables, functions, and types with their constructors and methods, each with its signature, location, and the first sentence of its doc comment. Unexported identifiers and _test.go files are left out. Use this before depending on a package, instead of reading its files; then get_node_content or find_symbol for implementations.",
+        inputSchema: {
+          package: z
+            .string()
+            .min(1)
+            .describe('Import path (e.g. "github.com/acme/api/auth") or package directory (e.g. "internal/auth", optionally "collection:internal/auth")'),
+        },
+        outputSchema: PACKAGE_API_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ package: query }) => {
+        let location = await goModules.locatePackage(query);
+        let api = location ? await readPackageApi(location.root, location.dir) : null;
+        // Not in the repository: a standard library package, when unambiguous
+        const std = !api && options.stdlib ? await options.stdlib.locate(query) : [];
+        if (std.length === 1) {
+          location = { collection: "GOROOT", ...std[0] };
+          api = await options.stdlib!.api(std[0]);
+        }
+        if (!location || !api) {
+          const message = `No Go package found at "${query}".`;
+          return reply(
+            `${message} Pass an import path under one of the repository's modules (see module_info) or a directory holding .go files.`,
+            { constants: [], variables: [], functions: [], types: [] },
+            "not_found",
+            message
+          );
+        }
+        const { name, doc, files, ...members } = api;
+        return reply(formatPackageApi(api, location.import_path, location.collection), {
+          package: {
+            name,
+            ...(location.import_path ? { import_path: location.import_path } : {}),
+            collection: location.collection,
+            dir: location.dir,
+            ...(doc ? { doc } : {}),
+            files,
+          },
+          ...members,
+        });
+      }
+    );
+  }
+
+  // ── Tool 11: coverage_for ──────────────────────────────────────────
+
+  const coverProfile = options.coverProfile;
+  if (coverProfile) {
+    registerTool(
+      "coverage_for",
+      {
+        description:
+          "Test coverage per function and method, from the Go cover profile (go test -coverprofile) loaded at startup. Give a file, directory, or doc_id to see which functions are covered, partially covered, or not run by any test. Use status=\"uncovered\" to find code that needs tests. search_documents also ranks uncovered code first for queries like \"auth needs tests\".",
+        inputSchema: {
+          path: z
+            .string()
+            .optional()
+            .describe("File path, directory prefix, or doc_id (default: the whole code index)"),
+          symbol: z
+            .string()
+            .optional()
+            .describe("Only functions whose name contains this"),
+          status: z
+            .enum(["covered", "partial", "uncovered"])
+            .optional()
+            .describe("Only functions with this status"),
+          limit: z
+            .number()
+            .min(1)
+            .max(200)
+            .default(50)
+            .describe("Max functions to list (default 50)"),
+        },
+        outputSchema: COVERAGE_FOR_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, symbol, status, limit }) => {
+        const target = path?.replace(/^\.\//, "");
+        const scope = target
+          ? store.hasDocument(target)
+            ? { doc_id: target }
+            : { path_prefix: target }
+          : { path_prefix: session.get().focus };
+        const nodes = store
+          .coveredNodes({ ...scope, symbol })
+          .map(({ doc, node, coverage }) => ({
+            doc_id: doc.doc_id,
+            node_id: node.node_id,
+            name: node.title,
+            file_path: doc.file_path,
+            line_start: node.line_start,
+            line_end: node.line_end,
+            statements: coverage.statements,
+            covered: coverage.covered,
+            percent: percentOf(coverage.covered, coverage.statements),
+            status: coverageStatus(coverage),
+          }))
+          .filter((n) => !status || n.status === status);
+
+        const statements = nodes.reduce((sum, n) => sum + n.statements, 0);
+        const covered = nodes.reduce((sum, n) => sum + n.covered, 0);
+        const totals = { functions: nodes.length, statements, covered, percent: percentOf(covered, statements) };
+        const functions = nodes.slice(0, limit);
+        const data = { profile: coverProfile, totals, functions };
+
+        if (nodes.length === 0) {
+          if (target && !store.hasDocument(target) && store.completePaths(target, 1).length === 0) {
+            const message = `Nothing is indexed at "${target}".`;
+            return reply(message, data, "not_found", message);
+          }
+          return reply(
+            `No profiled functions${target ? ` under ${target}` : ""}${status ? ` with status ${status}` : ""} in ${coverProfile}.`,
+            data
+          );
+        }
+        return reply(formatCoverage(functions, totals, target, coverProfile), data);
+      }
+    );
+  }
+
+  // ── Tool 30: concurrency_map ───────────────────────────────────────
+
+  const concurrency = options.concurrency;
+  if (concurrency) {
+    registerTool(
+      "concurrency_map",
+      {
+        description:
+          "Map the synchronization in Go code, per package: goroutine launches (go f(), errgroup Go), channel makes, sends, receives, closes, and selects, mutex Lock/Unlock pairs, WaitGroup calls, and sync/atomic calls. Channels and mutexes are summarized by name, and locks with no matching unlock in the same function are listed as unpaired. Use it before a race-condition review to see where goroutines start and what they share.",
+        inputSchema: {
+          path: z.string().optional().describe("Only files under this path prefix, e.g. internal/cluster/"),
+          kind: z
+            .enum(CONCURRENCY_KINDS as [ConcurrencyKind, ...ConcurrencyKind[]])
+            .optional()
+            .describe("List the sites of this kind (the summaries always count every kind)"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(1000).default(100).describe("Max sites to list (default 100)"),
+        },
+        outputSchema: CONCURRENCY_MAP_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, kind, include_tests, limit }) => {
+        const report = await concurrency.map(store, { path, include_tests });
+        const matching = kind ? report.sites.filter((s) => s.kind === kind) : report.sites;
+        const withUri = <T extends { doc_id: string; line?: number; lock?: number; unlock?: number }>(at: T) => ({
+          ...at,
+          uri: locationUri(store, at.doc_id, (at.line ?? at.lock ?? at.unlock)!),
+        });
+        const payload = {
+          files: report.files,
+          packages: report.packages.map((p) => ({ ...p, unpaired: p.unpaired.map(withUri) })),
+          total: matching.length,
+          sites: matching.slice(0, limit).map(withUri),
+        };
+        return reply(formatConcurrencyMap(report, kind ? matching : [], limit), payload);
+      }
+    );
+  }
+
+  // ── Tool 31: context_audit ─────────────────────────────────────────
+
+  const contextAudit = options.contextAudit;
+  if (contextAudit) {
+    registerTool(
+      "context_audit",
+      {
+        description:
+          "Audit context propagation in Go: exported functions that accept a context.Context but call a context-taking function without passing it (or one derived from it), call context.Background() / context.TODO() mid-chain, call an API that has a context variant (http.NewRequest, exec.Command, db.Query, ...), or never use the context at all. Use it for \"does this code path honor cancellation?\" reviews.",
+        inputSchema: {
+          path: z.string().optional().describe("Only functions in files under this path prefix"),
+          kind: z
+            .enum(CONTEXT_FINDING_KINDS as [ContextFindingKind, ...ContextFindingKind[]])
+            .optional()
+            .describe("Only findings of this kind"),
+          include_unexported: z.boolean().default(false).describe("Check unexported functions too (default: exported only)"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(1000).default(100).describe("Max findings to list (default 100)"),
+        },
+        outputSchema: CONTEXT_AUDIT_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, kind, include_unexported, include_tests, limit }) => {
+        const report = await contextAudit.audit(store, { path, include_unexported, include_tests });
+        const matching = kind ? report.findings.filter((f) => f.kind === kind) : report.findings;
+        const shown = matching.slice(0, limit);
+        const payload = {
+          files: report.files,
+          functions: report.functions,
+          flagged: report.flagged,
+          by_kind: report.by_kind,
+          total: matching.length,
+          findings: shown.map((f) => ({ ...f, uri: locationUri(store, f.doc_id, f.line) })),
+        };
+        return reply(formatContextAudit(report, matching, limit), payload);
+      }
+    );
+  }
+
+  // ── Tool 32: list_embeds ───────────────────────────────────────────
+
+  const embeds = options.embeds;
+  if (embeds) {
+    registerTool(
+      "list_embeds",
+      {
+        description:
+          "List //go:embed directives with the variables they fill and the files they match, resolved like the go command does (globs, directories embedded recursively minus . and _ files unless all:). Give binary (a main package directory such as cmd/server) to answer \"which templates and assets does this binary embed?\" across every package it imports, or variable to jump from an embed variable to its files. Patterns that match nothing are reported as missing.",
+        inputSchema: {
+          binary: z.string().optional().describe("A main package's directory, e.g. cmd/server: its embeds and those of the packages it imports"),
+          variable: z.string().optional().describe("Only this embed variable: assets, or web.assets"),
+          path: z.string().optional().describe("Only directives in files under this path prefix"),
+          limit: z.number().int().min(1).max(1000).default(50).describe("Max files to list per embed (default 50)"),
+        },
+        outputSchema: LIST_EMBEDS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ binary, variable, path, limit }) => {
+        const report = await embeds.list(store, { binary, variable, path });
+        if (!report || (variable && report.embeds.length === 0)) {
+          return reply(
+            report
+              ? `No embed variable named "${variable}". Call list_embeds without variable to see them all.`
+              : `No indexed Go package at "${binary}". Pass the directory of a main package, e.g. cmd/server.`,
+            { total: 0, files: 0, truncated: false, embeds: [] },
+            "not_found"
+          );
+        }
+        const fileUri = (collection: string, path: string) => {
+          const doc = store.documentsAtPath(path).find((d) => d.collection === collection);
+          return doc ? locationUri(store, doc.doc_id) : undefined;
+        };
+        const payload = {
+          total: report.embeds.length,
+          files: report.files,
+          ...(report.packages ? { packages: report.packages } : {}),
+          truncated: report.truncated,
+          embeds: report.embeds.map((e) => ({
+            ...e,
+            file_count: e.files.length,
+            files: e.files.slice(0, limit).map((f) => {
+              const uri = fileUri(e.collection, f);
+              return uri ? { path: f, uri } : { path: f };
+            }),
+            uri: locationUri(store, e.doc_id, e.line),
+          })),
+        };
+        return reply(formatEmbeds(report, binary, limit), payload);
+      }
+    );
+  }
+
+  // ── Tool 42: api_diff ─────────────────────────────────────────────
+
+  const apiDiff = options.apiDiff;
+  if (apiDiff) {
+    registerTool(
+      "api_diff",
+      {
+        description:
+          "Compare the exported Go API between two git refs and classify every change as breaking (removed, type or signature changed, receiver made a pointer, method added to an interface) or additive (added), then name the semver bump they call for and the next version. Use it before a release to propose the bump with evidence, e.g. base=\"v1.4.0\" against the working tree. Only packages whose files changed are compared; internal/ and main packages are skipped by default.",
+        inputSchema: {
+          base: z.string().optional().describe("Old side: a tag, branch, or commit (default: the latest tag)"),
+          head: z.string().optional().describe("New side: a git ref (default: the working tree)"),
+          path: z.string().optional().describe("Only packages under this directory"),
+          include_internal: z.boolean().default(false).describe("Also compare internal/ packages and package main"),
+          limit: z.number().int().min(1).max(2000).default(200).describe("Max changes to list, breaking first per package (default 200)"),
+        },
+        outputSchema: API_DIFF_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ base, head, path, include_internal, limit }) => {
+        let result: ApiDiffResult;
+        try {
+          result = await apiDiff.diff({ base, head, path, include_internal });
+        } catch (err) {
+          if (err instanceof ApiDiffError) return errorResult(err);
+          throw err;
+        }
+        const payload = { ...result, total: result.changes.length, changes: result.changes.slice(0, limit) };
+        return reply(formatApiDiff(result, limit), payload);
+      }
+    );
+  }
+}
+
+/** Overview for module_info without arguments: modules, workspaces, in-repo edges. */
+function formatModuleGraph(graph: GoModuleGraph): string {
+  const lines = [`${graph.modules.length} Go module(s):`, ""];
+  for (const m of graph.modules) {
+    const direct = m.require.filter((r) => !r.indirect).length;
+    const details = [
+      m.go ? `go ${m.go}` : null,
+      `${direct} direct`,
+      `${m.require.length - direct} indirect`,
+      m.replace.length > 0 ? `${m.replace.length} replace` : null,
+    ].filter(Boolean);
+    lines.push(`• ${m.module} — ${m.collection}:${m.dir || "."} (${details.join(", ")})`);
+  }
+  for (const w of graph.workspaces) {
+    lines.push("", `Workspace ${w.collection}:${w.dir ? `${w.dir}/` : ""}go.work${w.go ? ` (go ${w.go})` : ""}`);
+    for (const use of w.use) lines.push(`  use ${use}`);
+  }
+  if (graph.edges.length > 0) {
+    lines.push("", "In-repo dependencies:");
+    for (const e of graph.edges) lines.push(`  ${e.from} → ${e.to}${e.via === "replace" ? " (replace)" : ""}`);
+  }
+  lines.push("", "Call module_info with a module path or directory for its dependencies.");
+  return lines.join("\n");
+}
+
+/** Detail view for module_info on one module. */
+function formatModule(
+  m: GoModule,
+  edges: GoModuleGraph["edges"],
+  workspaces: GoModuleGraph["workspaces"]
+): string {
+  const lines = [`# ${m.module}`, "", `Directory: ${m.collection}:${m.dir || "."}`];
+  if (m.go) lines.push(`Go: ${m.go}${m.toolchain ? ` (toolchain ${m.toolchain})` : ""}`);
+  for (const w of workspaces) lines.push(`Workspace: ${w.collection}:${w.dir ? `${w.dir}/` : ""}go.work`);
+
+  const section = (title: string, items: string[]) => {
+    if (items.length > 0) lines.push("", `## ${title} (${items.length})`, ...items.map((i) => `• ${i}`));
+  };
+  section(
+    "Direct dependencies",
+    m.require.filter((r) => !r.indirect).map((r) => `${r.path} ${r.version}`)
+  );
+  section(
+    "Indirect dependencies",
+    m.require.filter((r) => r.indirect).map((r) => `${r.path} ${r.version}`)
+  );
+  section(
+    "Replace",
+    m.replace.map(
+      (r) =>
+        `${r.old}${r.old_version ? ` ${r.old_version}` : ""} => ${r.new}${r.new_version ? ` ${r.new_version}` : ""}`
+    )
+  );
+  section("Exclude", m.exclude.map((e) => `${e.path} ${e.version}`));
+  section(
+    "Uses in-repo modules",
+    edges.filter((e) => e.from === m.module).map((e) => e.to + (e.via === "replace" ? " (replace)" : ""))
+  );
+  section(
+    "Used by in-repo modules",
+    edges.filter((e) => e.to === m.module).map((e) => e.from + (e.via === "replace" ? " (replace)" : ""))
+  );
+  return lines.join("\n");
+}
+
+function formatEmbeds(report: EmbedReport, binary: string | undefined, limit: number): string {
+  const scope = report.packages ? ` — binary ${binary}: ${report.packages.join(", ")}` : "";
+  const lines = [`${report.embeds.length} embed(s), ${report.files} file(s)${scope}`];
+  for (const e of report.embeds) {
+    lines.push("", `${e.file_path}:${e.line}  ${e.variable} ${e.type}  ← //go:embed ${e.patterns.join(" ")}`);
+    for (const f of e.files.slice(0, limit)) lines.push(`  ${f}`);
+    if (e.files.length > limit) lines.push(`  … ${e.files.length - limit} more; raise limit to see them`);
+    if (e.missing.length) lines.push(`  missing: ${e.missing.join(", ")} (matches no file; the package does not build)`);
+  }
+  if (report.truncated) lines.push("", "File listing stopped at its cap; narrow with path or variable.");
+  return lines.join("\n");
+}
+
+const CONTEXT_FINDING_TEXT: Record<ContextFindingKind, (f: ContextFinding) => string> = {
+  background: () => "context.Background() or TODO() with a context in scope",
+  dropped: (f) => `${f.callee} gets ${f.passed} instead of the context`,
+  non_context_variant: (f) => `${f.callee} has a context variant: ${f.suggestion}`,
+  unused: () => "context never used, yet the body makes calls",
+};
+
+function formatContextAudit(report: ContextAuditReport, matching: ContextFinding[], limit: number): string {
+  const counts = CONTEXT_FINDING_KINDS.filter((k) => report.by_kind[k] > 0)
+    .map((k) => `${report.by_kind[k]} ${k}`)
+    .join(", ");
+  const lines = [`${report.functions} function(s) take a context.Context; ${report.flagged} flagged${counts ? ` — ${counts}` : ""}`];
+  const shown = matching.slice(0, limit);
+  let file: string | undefined;
+  let fn: string | undefined;
+  for (const f of shown) {
+    if (f.file_path !== file) {
+      lines.push("", f.file_path);
+      file = f.file_path;
+      fn = undefined;
+    }
+    if (f.function !== fn) {
+      lines.push(`  ${f.function}`);
+      fn = f.function;
+    }
+    lines.push(`    :${f.line}  ${CONTEXT_FINDING_TEXT[f.kind](f)}${f.kind === "unused" ? "" : `  ${f.text}`}`);
+  }
+  if (matching.length > shown.length) lines.push("", `… ${matching.length - shown.length} more; raise limit to see them`);
+  return lines.join("\n");
+}
+
+function formatConcurrencyMap(report: ConcurrencyReport, listed: ConcurrencySite[], limit: number): string {
+  if (report.packages.length === 0) return `No goroutines, channels, or locks in ${report.files} Go file(s).`;
+  const at = (s: { file_path: string; function?: string }, line: number) => `${s.file_path}:${line}${s.function ? ` in ${s.function}` : ""}`;
+  const lines = [`${report.packages.length} package(s) with synchronization across ${report.files} Go file(s)`];
+  for (const p of report.packages) {
+    const counts = CONCURRENCY_KINDS.filter((k) => p.by_kind[k] > 0)
+      .map((k) => `${p.by_kind[k]} ${k}`)
+      .join(", ");
+    lines.push("", `${p.package} (${p.files} file(s)): ${counts}`);
+    if (p.channels.length) {
+      lines.push("  Channels:");
+      for (const c of p.channels) {
+        const type = c.type ? `  ${c.type}${c.buffer ? `, buffer ${c.buffer}` : ""}` : "";
+        const ops = (["make", "send", "receive", "close"] as const).filter((op) => c[op] > 0).map((op) => `${op} ${c[op]}`);
+        lines.push(`    ${c.name}${type}  ${ops.join(", ")}`);
+      }
+    }
+    if (p.mutexes.length) {
+      lines.push("  Mutexes:");
+      for (const m of p.mutexes) {
+        const extra = [m.deferred ? `${m.deferred} deferred` : "", m.unpaired ? `${m.unpaired} unpaired` : ""].filter(Boolean);
+        lines.push(`    ${m.name}  ${m.lock} lock / ${m.unlock} unlock${extra.length ? `, ${extra.join(", ")}` : ""}`);
+      }
+    }
+    const goroutines = report.sites.filter(
+      (s) => s.kind === "goroutine" && (s.file_path.includes("/") ? s.file_path.slice(0, s.file_path.lastIndexOf("/")) : ".") === p.package
+    );
+    if (goroutines.length) {
+      lines.push("  Goroutines:");
+      for (const g of goroutines) lines.push(`    ${at(g, g.line)}  ${g.text}`);
+    }
+    if (p.unpaired.length) {
+      lines.push("  Unpaired:");
+      for (const u of p.unpaired) {
+        const call = `${u.name}.${u.mode === "read" ? "R" : ""}${u.lock !== undefined ? "Lock" : "Unlock"}`;
+        lines.push(`    ${at(u, (u.lock ?? u.unlock)!)}  ${call} with no ${u.lock !== undefined ? "unlock" : "lock"} in the function`);
+      }
+    }
+  }
+  const shown = listed.slice(0, limit);
+  if (shown.length) {
+    lines.push("", `Sites (${listed.length}):`);
+    for (const s of shown) lines.push(`  ${at(s, s.line)}  ${s.text}`);
+    if (listed.length > shown.length) lines.push(`  … ${listed.length - shown.length} more; raise limit to see them`);
+  }
+  return lines.join("\n");
+}
+
+/** api_diff's text: the verdict, then each package's changes, breaking first, with both signatures. */
+function formatApiDiff(result: ApiDiffResult, limit: number): string {
+  const version = result.next_version ? ` (${result.base} → ${result.next_version})` : "";
+  const lines = [
+    `API diff ${result.base} → ${result.head}: ${result.packages} changed package(s) compared; ` +
+      `${result.by_compat.breaking} breaking, ${result.by_compat.additive} additive change(s). Bump: ${result.bump}${version}` +
+      (result.truncated ? " — stopped at the deadline; some packages were not compared" : ""),
+  ];
+  let pkg = "";
+  for (const c of result.changes.slice(0, limit)) {
+    if (c.package !== pkg) {
+      pkg = c.package;
+      lines.push("", pkg);
+    }
+    const where = c.file ? `  ${c.file}${c.line ? `:${c.line}` : ""}` : "";
+    const reason = c.reason === c.change ? "" : ` — ${c.reason}`;
+    lines.push(`  ${c.compat.padEnd(8)}  ${c.change.padEnd(7)}  ${c.kind} ${c.name}${reason}${where}`);
+    if (c.change === "changed") lines.push(`      - ${c.before}`, `      + ${c.after}`);
+  }
+  if (result.changes.length > limit) lines.push("", `… ${result.changes.length - limit} more; raise limit or narrow with path`);
+  if (result.notes.length) lines.push("", ...result.notes);
+  return lines.join("\n");
+}
+
+function percentOf(part: number, whole: number): number {
+  return whole === 0 ? 0 : Math.round((part / whole) * 1000) / 10;
+}
+
+/** coverage_for text: totals, then functions grouped by file. */
+function formatCoverage(
+  functions: Array<{ name: string; file_path: string; line_start: number; line_end: number; statements: number; covered: number; percent: number; status: string }>,
+  totals: { functions: number; statements: number; covered: number; percent: number },
+  target: string | undefined,
+  profile: string
+): string {
+  const lines = [
+    `Coverage${target ? ` for ${target}` : ""}: ${totals.percent}% of ${totals.statements} statements in ${totals.functions} functions (from ${profile})`,
+  ];
+  let file = "";
+  for (const f of functions) {
+    if (f.file_path !== file) {
+      file = f.file_path;
+      lines.push("", file);
+    }
+    lines.push(`  ${f.name} (L${f.line_start}-${f.line_end}): ${f.covered}/${f.statements} statements, ${f.percent}% — ${f.status}`);
+  }
+  if (functions.length < totals.functions) {
+    lines.push("", `(${totals.functions - functions.length} more; narrow with path, symbol, or status, or raise limit)`);
+  }
+  return lines.join("\n");
+}
+
+function formatPackageApi(api: GoPackageApi, importPath: string | undefined, collection: string): string {
+  const lines = [`package ${api.name}${importPath ? ` // import "${importPath}"` : ""}`];
+  if (api.doc) lines.push("", api.doc);
+
+  const entry = (e: GoApiEntry, indent = "") => {
+    lines.push(`${indent}${e.signature}  [${collection}:${e.file}:${e.line}]`);
+    if (e.doc) lines.push(`${indent}    ${e.doc}`);
+  };
+  const section = (title: string, entries: GoApiEntry[]) => {
+    if (entries.length === 0) return;
+    lines.push("", title);
+    for (const e of entries) entry(e);
+  };
+
+  section("CONSTANTS", api.constants);
+  section("VARIABLES", api.variables);
+  section("FUNCTIONS", api.functions);
+  if (api.types.length > 0) {
+    lines.push("", "TYPES");
+    for (const type of api.types) {
+      entry(type);
+      for (const c of type.constructors) entry(c, "  ");
+      for (const m of type.methods) entry(m, "  ");
+    }
+  }
+  if (lines.length === 1 || (lines.length === 3 && api.doc)) lines.push("", "(no exported identifiers)");
+  return lines.join("\n");
+}
@@ -0,0 +1,43 @@
+/** Plugin tools (PLUGINS): one per loaded definition; see plugins.ts */
+
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import { PLUGIN_DEFAULT_ANNOTATIONS, pluginInputSchema } from "../plugins";
+import { PLUGIN_TOOL_OUTPUT } from "../schemas";
+import { reply } from "../tools";
+import type { ToolContext } from "./context";
+
+/** Register a tool for every loaded plugin definition. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool } = ctx;
+
+  // ── Plugin tools (PLUGINS) ─────────────────────────────────────────
+
+  for (const plugin of options.plugins?.tools ?? []) {
+    const host = options.plugins!;
+    registerTool(
+      plugin.name,
+      {
+        description: plugin.description,
+        inputSchema: pluginInputSchema(plugin.params),
+        outputSchema: PLUGIN_TOOL_OUTPUT,
+        annotations: { ...PLUGIN_DEFAULT_ANNOTATIONS, ...plugin.annotations },
+      },
+      async (args: Record<string, any>) => {
+        try {
+          const result = await plugin.run(args, host.context(store));
+          if (typeof result === "string") return reply(result, { plugin: plugin.name });
+          return reply(
+            result.text,
+            { plugin: plugin.name, ...(result.data ? { data: result.data } : {}) },
+            result.status ?? "ok"
+          );
+        } catch (err: any) {
+          return {
+            content: [{ type: "text" as const, text: `Error: plugin tool ${plugin.name} failed: ${err?.message ?? err}` }],
+            isError: true,
+          };
+        }
+      }
+    );
+  }
+}
@@ -0,0 +1,101 @@
+/**
+ * set_preferences: the session's defaults for the read tools, and the
+ * snapshot pin
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import { EditorLinkError, editorTemplate } from "../editor-links";
+import { SET_PREFERENCES_OUTPUT } from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+
+/** set_preferences: changes this session's defaults, nothing else. */
+const SESSION_STATE = {
+  readOnlyHint: false,
+  destructiveHint: false,
+  idempotentHint: true,
+  openWorldHint: false,
+};
+
+/** Register set_preferences. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool, session, focusNotIndexed } = ctx;
+
+  // ── Tool 7: set_preferences ────────────────────────────────────────
+
+  registerTool(
+    "set_preferences",
+    {
+      description:
+        "Set session defaults so you don't have to repeat the same arguments on every call. languages applies to find_symbol; limit applies to list_documents, search_documents, and find_symbol; focus restricts those tools to files under a directory; editor_url adds a one-click link that opens the editor at the lines of every result uri. Explicit tool arguments always override these defaults. Pass an empty string or empty array to clear one preference, or reset=true to clear all. snapshot=\"pin\" keeps the index-backed read tools (those that take ref) on the index as it is now, so a multi-step plan does not see re-indexing halfway through; pin again to see newer changes, or release to follow the live index.",
+      inputSchema: {
+        languages: z
+          .array(z.string())
+          .optional()
+          .describe("Preferred programming languages for find_symbol (e.g. ['go', 'python'])"),
+        limit: z
+          .number()
+          .min(1)
+          .max(100)
+          .optional()
+          .describe("Default max results for list/search tools"),
+        focus: z
+          .string()
+          .optional()
+          .describe("Directory (relative to the collection root) to keep results inside, e.g. 'services/payments'"),
+        editor_url: z
+          .string()
+          .optional()
+          .describe('Give every result uri an editor_url link: an editor (vscode, vscode-insiders, cursor, zed, sublime, textmate, jetbrains, idea) or a template with {file} or {path}, plus {line}, {end_line}, {column}, {root}, {project}, {collection}, e.g. "myeditor://open?file={file}&line={line}" (default EDITOR_URL)'),
+        reset: z
+          .boolean()
+          .optional()
+          .describe("Clear all session preferences, and any snapshot pin, before applying the others"),
+        snapshot: z
+          .enum(["pin", "release"])
+          .optional()
+          .describe('"pin": answer from the index generation current now until pinned again; "release": follow the live index'),
+      },
+      outputSchema: SET_PREFERENCES_OUTPUT,
+      annotations: SESSION_STATE,
+    },
+    async ({ languages, limit, focus, editor_url, reset, snapshot }) => {
+      if (editor_url?.trim()) {
+        if (!options.editorLinks) return errorResult(new EditorLinkError("editor_url is not available on this server"));
+        try {
+          editorTemplate(editor_url);
+        } catch (err) {
+          if (err instanceof EditorLinkError) return errorResult(err);
+          throw err;
+        }
+      }
+      if (reset) {
+        store.releaseGeneration(session);
+        session.clear();
+      }
+      session.set({ languages, limit, focus, editor_url });
+      if (snapshot === "pin") session.pin(store.pinGeneration(session));
+      if (snapshot === "release") {
+        store.releaseGeneration(session);
+        session.pin(null);
+      }
+      const current = session.describe();
+      const excluded = focusNotIndexed();
+      const generation = session.pinned();
+
+      return reply(
+        (current
+          ? `Session preferences: ${current}`
+          : "Session preferences cleared — tools use their built-in defaults.") +
+          (generation !== null && generation !== store.getGeneration() ? `\n\nThe index has moved on to generation ${store.getGeneration()}.` : "") +
+          (excluded ? `\n\nWarning: ${excluded}` : ""),
+        {
+          preferences: session.get(),
+          ...(generation !== null ? { snapshot: { generation, current: store.getGeneration() } } : {}),
+          ...(excluded ? { warning: excluded } : {}),
+        }
+      );
+    }
+  );
+}
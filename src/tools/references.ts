@@ -0,0 +1,818 @@
+/**
+ * Reference tools: usage_stats, find_references, find_cycles, callers,
+ * trace_errors, field_references, enum_usages, and panic_sites
+ *
+ * Who uses a symbol, field, or enum value, and the paths calls and
+ * errors take through the code, from the usage graph and its siblings.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { IncludeTests } from "../types";
+import type { CycleReport, PackageEdge } from "../cycles";
+import { FIELD_ACCESSES, FieldError, type FieldAccess, type FieldReport } from "../fields";
+import type { EnumReport } from "../enums";
+import {
+  DEFAULT_CALLER_DEPTH,
+  DEFAULT_CALLER_FAN_OUT,
+  DEFAULT_ERROR_DEPTH,
+  DEFAULT_MAX_CALLERS,
+  ERROR_ROLES,
+  EXIT_KINDS,
+  RECOVER_STATUSES,
+  USAGE_KINDS,
+  UsageError,
+  referencesByCollection,
+  type CallTruncation,
+  type CallerReport,
+  type ErrorTrace,
+  type ExitKind,
+  type ExitSite,
+  type RecoverStatus,
+  type CollectionReferences,
+  type UsageReport,
+} from "../usage";
+import { fenceDiagram, GRAPH_FORMATS, renderDiagram } from "../graph-format";
+import { locationUri, parseUri, UriError } from "../uris";
+import {
+  CALLERS_OUTPUT,
+  ENUM_USAGES_OUTPUT,
+  FIELD_REFERENCES_OUTPUT,
+  FIND_CYCLES_OUTPUT,
+  FIND_REFERENCES_OUTPUT,
+  PANIC_SITES_OUTPUT,
+  TRACE_ERRORS_OUTPUT,
+  USAGE_STATS_OUTPUT,
+} from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { INCLUDE_TESTS_INPUT, READ_ONLY } from "./shared";
+
+/** Register the reference tools whose index is configured. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool, session, locate } = ctx;
+
+  // ── Tool 18: usage_stats ───────────────────────────────────────────
+
+  const usage = options.usage;
+  if (usage) {
+    // usage_stats and find_references take the same symbol, package, or uri
+    const usageReport = async (
+      symbol: string | undefined,
+      pkg: string | undefined,
+      uri: string | undefined,
+      include_tests: IncludeTests | undefined
+    ): Promise<UsageReport | null | UsageError | UriError> => {
+      if ([symbol, pkg, uri].filter((a) => a !== undefined).length !== 1) return new UsageError("pass symbol, package, or uri, exactly one");
+      if (uri === undefined) {
+        return symbol !== undefined ? usage.symbol(store, symbol, { include_tests }) : usage.package(store, pkg!, { include_tests });
+      }
+      const at = locate(store, undefined, uri, true);
+      if (at instanceof UriError) return at;
+      return usage.symbolAt(store, at.doc_id, at.node!.line_start, { include_tests });
+    };
+    const notDefined = (symbol: string | undefined, pkg: string | undefined, uri: string | undefined) => {
+      const what =
+        uri !== undefined
+          ? `No symbol is defined at ${uri}.`
+          : symbol !== undefined
+            ? `No indexed definition of "${symbol}".`
+            : `No indexed code in package "${pkg}".`;
+      return `${what} Try find_symbol to check the name.`;
+    };
+    const withUri = <T extends { doc_id: string; line: number }>(at: T) => ({ ...at, uri: locationUri(store, at.doc_id, at.line) });
+
+    registerTool(
+      "usage_stats",
+      {
+        description:
+          "Count the references to a symbol, or to everything a package defines, broken down by consuming package and by kind: call, type_use, embed (embedded field or base class), value. Use it to size the blast radius of a breaking change before making it. Go references are import-aware; methods count as .Name selectors, and other languages are matched lexically.",
+        inputSchema: {
+          symbol: z
+            .string()
+            .optional()
+            .describe("A name (Connect), a member (Server.Start), or package-qualified (db.Connect)"),
+          package: z
+            .string()
+            .optional()
+            .describe("Instead of symbol: a Go import path or directory; counts references from other packages"),
+          uri: z
+            .string()
+            .optional()
+            .describe("Instead of symbol: a uri from an earlier result; counts references to the symbol defined there"),
+          limit: z.number().int().min(0).max(500).default(20).describe("Reference sites to list"),
+          include_tests: INCLUDE_TESTS_INPUT,
+        },
+        outputSchema: USAGE_STATS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ symbol, package: pkg, uri, limit, include_tests }) => {
+        const report = await usageReport(symbol, pkg, uri, include_tests);
+        if (report instanceof Error) return errorResult(report);
+        const target = (symbol ?? pkg ?? uri)!;
+        if (!report) {
+          const empty = {
+            scope: pkg === undefined ? "symbol" : "package",
+            target,
+            definitions: [],
+            files: 0,
+            total: 0,
+            by_kind: { call: 0, type_use: 0, embed: 0, value: 0 },
+            consumers: [],
+            sites: [],
+          };
+          return reply(notDefined(symbol, pkg, uri), empty, "not_found");
+        }
+        const payload = {
+          ...report,
+          definitions: report.definitions.map(withUri),
+          sites: report.sites.slice(0, limit).map(withUri),
+        };
+        return reply(formatUsage(report, limit), payload);
+      }
+    );
+
+    // ── Tool 43: find_references ──────────────────────────────────────
+
+    registerTool(
+      "find_references",
+      {
+        description:
+          "List every reference to a symbol, or to what a package defines, grouped by code collection (repository), so uses from other repositories indexed beside it (CODE_ROOTS) show up next to its own. A reference from another collection counts only where its file imports the defining package: a Go import path from the go.mod of any collection, or for JS/TS the package.json name. Use it before changing an API that other repositories in the workspace consume; usage_stats gives the same references counted by package.",
+        inputSchema: {
+          symbol: z
+            .string()
+            .optional()
+            .describe("A name (Connect), a member (Server.Start), or package-qualified (db.Connect)"),
+          package: z
+            .string()
+            .optional()
+            .describe("Instead of symbol: a Go import path or directory; lists references from other packages"),
+          uri: z
+            .string()
+            .optional()
+            .describe("Instead of symbol: a uri from an earlier result; lists references to the symbol defined there"),
+          collection: z.string().optional().describe("Only references in this collection"),
+          limit: z.number().int().min(0).max(500).default(20).describe("Reference sites to list per collection"),
+          include_tests: INCLUDE_TESTS_INPUT,
+        },
+        outputSchema: FIND_REFERENCES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ symbol, package: pkg, uri, collection, limit, include_tests }) => {
+        const report = await usageReport(symbol, pkg, uri, include_tests);
+        if (report instanceof Error) return errorResult(report);
+        const target = (symbol ?? pkg ?? uri)!;
+        if (!report) {
+          const empty = {
+            scope: pkg === undefined ? "symbol" : "package",
+            target,
+            definitions: [],
+            files: 0,
+            total: 0,
+            cross_collection: 0,
+            collections: [],
+          };
+          return reply(notDefined(symbol, pkg, uri), empty, "not_found");
+        }
+        const groups = referencesByCollection(report).filter((g) => collection === undefined || g.collection === collection);
+        const payload = {
+          scope: report.scope,
+          target: report.target,
+          definitions: report.definitions.map(withUri),
+          files: report.files,
+          total: groups.reduce((n, g) => n + g.total, 0),
+          cross_collection: groups.filter((g) => g.external).reduce((n, g) => n + g.total, 0),
+          collections: groups.map((g) => ({ ...g, sites: g.sites.slice(0, limit).map(withUri) })),
+        };
+        return reply(formatReferences(report, groups, limit), payload);
+      }
+    );
+  }
+
+  // ── Tool 21: find_cycles ───────────────────────────────────────────
+
+  const cycles = options.cycles;
+  if (cycles) {
+    registerTool(
+      "find_cycles",
+      {
+        description:
+          "Find import cycles between packages (directories) of Go, JS/TS, and Python code, plus near-cycles that close only through type-only imports (import type, TYPE_CHECKING), which is how interfaces usually cross packages. Each cycle comes with a shortest example path and a minimal cut: the import edges to remove, cheapest first, with the import sites to edit.",
+        inputSchema: {
+          path: z
+            .string()
+            .optional()
+            .describe("Directory prefix or glob to look within (default: all code)"),
+          format: z
+            .enum(GRAPH_FORMATS)
+            .default("text")
+            .describe('"dot" or "mermaid" to answer with the cycles as a diagram, cut edges dashed (default "text")'),
+        },
+        outputSchema: FIND_CYCLES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, format }) => {
+        const report = await cycles.find(store, { path });
+        if (!report) {
+          return reply(`No indexed code files${path ? ` under "${path}"` : ""}.`, { packages: 0, edges: 0, cycles: [] }, "not_found");
+        }
+        const drawn = renderDiagram(
+          format,
+          report.cycles.flatMap((c) =>
+            c.edges.map((e) => ({ from: e.from, to: e.to, label: c.cut.includes(e) ? "cut" : e.type_only ? "type" : undefined }))
+          ),
+          { dashed: ["cut"] }
+        );
+        const payload = { ...report, ...(drawn ? { diagram: drawn } : {}) };
+        if (report.cycles.length === 0) {
+          return reply(`No import cycles among ${report.packages} package(s), ${report.edges} import edge(s).`, payload);
+        }
+        return reply(drawn ? fenceDiagram(format, drawn) : formatCycles(report), payload);
+      }
+    );
+  }
+
+  // ── Tool 25: callers ───────────────────────────────────────────────
+
+  if (usage) {
+    registerTool(
+      "callers",
+      {
+        description:
+          "List the functions that call a function or method. With transitive: true, follow callers of callers too (\"everything that eventually calls Disconnect\"), breadth first, bounded by depth, callers kept per function, and callers in all; the answer says which bounds cut it short. Use it for impact analysis on core functions in one call. Matching is usage_stats': import-aware for Go, lexical elsewhere.",
+        inputSchema: {
+          symbol: z
+            .string()
+            .optional()
+            .describe("A name (Connect), a member (Server.Start), or package-qualified (db.Connect)"),
+          uri: z
+            .string()
+            .optional()
+            .describe("Instead of symbol: a uri from an earlier result; the function defined there"),
+          transitive: z.boolean().default(false).describe("Follow callers of callers (default: direct callers only)"),
+          depth: z
+            .number()
+            .int()
+            .min(1)
+            .max(20)
+            .optional()
+            .describe(`With transitive: levels to follow (default ${DEFAULT_CALLER_DEPTH})`),
+          fan_out: z
+            .number()
+            .int()
+            .min(1)
+            .max(500)
+            .default(DEFAULT_CALLER_FAN_OUT)
+            .describe("New callers kept per function at each level"),
+          max_nodes: z.number().int().min(1).max(2000).default(DEFAULT_MAX_CALLERS).describe("Callers kept in all"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          format: z
+            .enum(GRAPH_FORMATS)
+            .default("text")
+            .describe('"dot" or "mermaid" to answer with the call graph as a diagram, callers pointing at what they call (default "text")'),
+        },
+        outputSchema: CALLERS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ symbol, uri, transitive, depth, fan_out, max_nodes, include_tests, format }) => {
+        if ((symbol === undefined) === (uri === undefined)) {
+          return errorResult(new UsageError("pass symbol or uri, exactly one"));
+        }
+        let target: string | { doc_id: string; line: number } = symbol!;
+        if (uri !== undefined) {
+          const at = locate(store, undefined, uri, true);
+          if (at instanceof UriError) return errorResult(at);
+          target = { doc_id: at.doc_id, line: at.node!.line_start };
+        }
+        const report = await usage.callers(store, target, {
+          depth: transitive ? depth ?? DEFAULT_CALLER_DEPTH : 1,
+          fan_out,
+          max_nodes,
+          include_tests,
+        });
+        if (!report) {
+          const what = uri !== undefined ? `No symbol is defined at ${uri}.` : `No indexed definition of "${symbol}".`;
+          return reply(
+            `${what} Try find_symbol to check the name.`,
+            { target: (symbol ?? uri)!, definitions: [], files: 0, depth: 0, callers: [], top_level: [], truncated: [] },
+            "not_found"
+          );
+        }
+        const withUri = <T extends { doc_id: string; line: number }>(at: T) => ({ ...at, uri: locationUri(store, at.doc_id, at.line) });
+        const drawn = renderDiagram(
+          format,
+          report.callers.flatMap((c) => c.calls.map((callee) => ({ from: c.name, to: callee }))),
+          { nodes: report.definitions.map((d) => d.name) }
+        );
+        const payload = {
+          ...report,
+          definitions: report.definitions.map(withUri),
+          callers: report.callers.map(withUri),
+          top_level: report.top_level.map(({ name, doc_id, file_path, line, text }) => ({
+            name,
+            file_path,
+            line,
+            text,
+            uri: locationUri(store, doc_id, line),
+          })),
+          ...(drawn ? { diagram: drawn } : {}),
+        };
+        return reply(drawn ? fenceDiagram(format, drawn) : formatCallers(report, transitive), payload);
+      }
+    );
+  }
+
+  // ── Tool 27: trace_errors ──────────────────────────────────────────
+
+  if (usage) {
+    registerTool(
+      "trace_errors",
+      {
+        description:
+          "Trace an error value or type, like ErrNotConnected or NotConnectedError: where it is created, wrapped (fmt.Errorf with %w, errors.Wrap, raise ... from), compared (errors.Is / As, ==, case, except, catch), and returned, then the callers it travels up through, level by level, with which of them pass it on, wrap it, or check for it. Use it to answer where an error comes from in one call. Matching is usage_stats': import-aware for Go, lexical elsewhere; passing on is read from the lines around each call.",
+        inputSchema: {
+          symbol: z
+            .string()
+            .optional()
+            .describe("The error: a name (ErrNotConnected), or package-qualified (client.ErrNotConnected)"),
+          uri: z
+            .string()
+            .optional()
+            .describe("Instead of symbol: a uri from an earlier result; the error defined there"),
+          depth: z
+            .number()
+            .int()
+            .min(1)
+            .max(20)
+            .default(DEFAULT_ERROR_DEPTH)
+            .describe(`Levels of callers to follow up from where it is returned (default ${DEFAULT_ERROR_DEPTH})`),
+          max_nodes: z.number().int().min(1).max(2000).default(DEFAULT_MAX_CALLERS).describe("Callers kept in the chain"),
+          include_tests: INCLUDE_TESTS_INPUT,
+        },
+        outputSchema: TRACE_ERRORS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ symbol, uri, depth, max_nodes, include_tests }) => {
+        if ((symbol === undefined) === (uri === undefined)) {
+          return errorResult(new UsageError("pass symbol or uri, exactly one"));
+        }
+        let target: string | { doc_id: string; line: number } = symbol!;
+        if (uri !== undefined) {
+          const at = locate(store, undefined, uri, true);
+          if (at instanceof UriError) return errorResult(at);
+          target = { doc_id: at.doc_id, line: parseUri(uri).line_start ?? at.node!.line_start };
+        }
+        const report = await usage.traceError(store, target, { depth, max_nodes, include_tests });
+        if (!report) {
+          const what = uri !== undefined ? `No symbol is defined at ${uri}.` : `No indexed definition of "${symbol}".`;
+          return reply(
+            `${what} Try find_symbol to check the name.`,
+            {
+              target: (symbol ?? uri)!,
+              definitions: [],
+              files: 0,
+              by_role: Object.fromEntries(ERROR_ROLES.map((r) => [r, 0])) as ErrorTrace["by_role"],
+              sites: [],
+              origins: [],
+              chain: [],
+              truncated: [],
+            },
+            "not_found"
+          );
+        }
+        const withUri = <T extends { doc_id: string; line: number }>(at: T) => ({ ...at, uri: locationUri(store, at.doc_id, at.line) });
+        const payload = {
+          ...report,
+          definitions: report.definitions.map(withUri),
+          sites: report.sites.map(withUri),
+          origins: report.origins.map(withUri),
+          chain: report.chain.map(withUri),
+        };
+        return reply(formatErrorTrace(report), payload);
+      }
+    );
+  }
+
+  // ── Tool 28: field_references ──────────────────────────────────────
+
+  const fields = options.fields;
+  if (fields) {
+    registerTool(
+      "field_references",
+      {
+        description:
+          "Find the reads and writes of one field of a type, e.g. NodeInfo.State, told apart by where each `.State` sits in its statement: writes (left of =, +=, ++), inits (NodeInfo{State: s}), address-taken (&n.State), and reads. Each site says whether its receiver is known to hold the type (a receiver, parameter, or local of it), which separates the field from namesakes on other types. Use it for \"who sets this?\" questions that text search answers badly for common field names.",
+        inputSchema: {
+          field: z.string().describe("Type.Field, e.g. NodeInfo.State; pkg.Type.Field picks the type by directory"),
+          access: z.enum(FIELD_ACCESSES as [FieldAccess, ...FieldAccess[]]).optional().describe("Only this kind of access"),
+          typed_only: z
+            .boolean()
+            .default(false)
+            .describe("Only sites whose receiver is known to hold the type (default: include unresolved namesakes)"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(1000).default(100).describe("Max sites to list (default 100)"),
+        },
+        outputSchema: FIELD_REFERENCES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ field, access, typed_only, include_tests, limit }) => {
+        let report: FieldReport | null;
+        try {
+          report = await fields.find(store, field, { typed_only, include_tests });
+        } catch (err) {
+          if (err instanceof FieldError) return errorResult(err);
+          throw err;
+        }
+        if (!report) {
+          return reply(
+            `No indexed type named in "${field}". Try find_symbol to check the type name.`,
+            {
+              target: field,
+              types: [],
+              declarations: [],
+              files: 0,
+              total: 0,
+              by_access: Object.fromEntries(FIELD_ACCESSES.map((a) => [a, 0])) as FieldReport["by_access"],
+              typed: 0,
+              sites: [],
+            },
+            "not_found"
+          );
+        }
+        const matching = access ? report.sites.filter((s) => s.access === access) : report.sites;
+        const withUri = <T extends { doc_id: string; line: number }>(at: T) => ({ ...at, uri: locationUri(store, at.doc_id, at.line) });
+        const payload = {
+          ...report,
+          types: report.types.map(withUri),
+          declarations: report.declarations.map(withUri),
+          total: matching.length,
+          sites: matching.slice(0, limit).map(withUri),
+        };
+        return reply(formatFieldReferences(report, matching, limit), payload);
+      }
+    );
+  }
+
+  // ── Tool 29: enum_usages ───────────────────────────────────────────
+
+  const enums = options.enums;
+  if (enums) {
+    registerTool(
+      "enum_usages",
+      {
+        description:
+          "Cross-reference an enum-like set of values — Go typed constants (const ( StateAlive NodeState = iota ... )), TypeScript and Java enums, Python Enum classes — with where each value is produced (assigned, returned, passed) and consumed (compared, a case label), and the switch or match statements over the set that leave values out. A switch missing values with no default is flagged incomplete. Omit enum to list every set with its counts.",
+        inputSchema: {
+          enum: z.string().optional().describe("The type, e.g. NodeState; pkg.Type picks it by directory. Omit to list every set"),
+          path: z.string().optional().describe("Only references and switches in files under this path prefix"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(1000).default(100).describe("Max references to list (default 100)"),
+        },
+        outputSchema: ENUM_USAGES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ enum: name, path, include_tests, limit }) => {
+        const report = await enums.usages(store, name, { path, include_tests });
+        if (!report) {
+          return reply(
+            `No enum-like set named "${name}". Call enum_usages without enum to list the sets, or find_symbol to check the name.`,
+            { target: name, sets: [], files: 0, total: 0, sites: [], switches: [], incomplete: 0 },
+            "not_found"
+          );
+        }
+        const withUri = <T extends { doc_id: string; line: number }>(at: T) => ({ ...at, uri: locationUri(store, at.doc_id, at.line) });
+        const payload = {
+          ...(name !== undefined ? { target: name } : {}),
+          sets: report.sets.map(withUri),
+          files: report.files,
+          total: report.sites.length,
+          sites: report.sites.slice(0, limit).map(withUri),
+          switches: report.switches.map(withUri),
+          incomplete: report.switches.filter((s) => s.incomplete).length,
+        };
+        return reply(formatEnumUsages(report, limit), payload);
+      }
+    );
+  }
+
+  // ── Tool 41: panic_sites ──────────────────────────────────────────
+
+  if (usage) {
+    registerTool(
+      "panic_sites",
+      {
+        description:
+          "List the places Go code gives up: panic() and logger Panic calls, log.Fatal and its kin (klog, logrus, zap), and os.Exit, each with its enclosing function. For each panic, say whether a recover stops it: deferred in the function itself, by every caller chain (upstream), by some (partial), or none found, naming the callers that recover and where it escapes — callers nothing calls, or a function started with go, which no caller's recover reaches. Fatal calls and os.Exit skip deferred calls, so a library should rarely have any. Use it for reliability reviews of library code.",
+        inputSchema: {
+          path: z.string().optional().describe("Only files under this path prefix (default: the session focus, else everything)"),
+          kind: z.enum(EXIT_KINDS as [ExitKind, ...ExitKind[]]).optional().describe("Only panics, fatal log calls, or exits"),
+          recover: z
+            .enum(RECOVER_STATUSES as [RecoverStatus, ...RecoverStatus[]])
+            .optional()
+            .describe('Only panics recovered this way; "none" lists the ones nothing recovers'),
+          depth: z
+            .number()
+            .int()
+            .min(1)
+            .max(20)
+            .default(DEFAULT_CALLER_DEPTH)
+            .describe(`Levels of callers to search for a recover (default ${DEFAULT_CALLER_DEPTH})`),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(1000).default(100).describe("Max sites to list (default 100)"),
+        },
+        outputSchema: PANIC_SITES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, kind, recover, depth, include_tests, limit }) => {
+        const report = await usage.exits(store, { path: path ?? session.get().focus, depth, include_tests });
+        const matching = report.sites.filter((s) => (!kind || s.kind === kind) && (!recover || s.recover === recover));
+        const by_recover = Object.fromEntries(RECOVER_STATUSES.map((r) => [r, 0])) as Record<RecoverStatus, number>;
+        for (const site of report.sites) if (site.recover) by_recover[site.recover]++;
+        const withUri = <T extends { doc_id: string; line: number }>(at: T) => ({ ...at, uri: locationUri(store, at.doc_id, at.line) });
+        const payload = {
+          files: report.files,
+          total: matching.length,
+          by_kind: report.by_kind,
+          by_recover,
+          sites: matching.slice(0, limit).map((s) => ({
+            ...withUri(s),
+            ...(s.recovered_by ? { recovered_by: s.recovered_by.map(withUri) } : {}),
+            ...(s.escapes_via ? { escapes_via: s.escapes_via.map(withUri) } : {}),
+          })),
+          truncated: report.truncated,
+        };
+        if (report.sites.length === 0) {
+          return reply(`No panic, log.Fatal, or os.Exit calls in ${report.files} Go file(s)${path ? ` under "${path}"` : ""}.`, payload);
+        }
+        return reply(formatPanicSites(matching, limit, report.by_kind, by_recover, report.truncated), payload);
+      }
+    );
+  }
+}
+
+function formatCycles(report: CycleReport): string {
+  const edge = (e: PackageEdge) => {
+    const sites = e.sites.slice(0, 3).map((s) => `${s.file_path}:${s.line}`);
+    const more = e.sites.length > 3 ? ` +${e.sites.length - 3} more` : "";
+    return `${e.from} → ${e.to}${e.type_only ? " (type-only)" : ""}  ${sites.join(", ")}${more}`;
+  };
+  const lines = [
+    `${report.cycles.length} import cycle(s) among ${report.packages} package(s), ${report.edges} import edge(s):`,
+    "",
+  ];
+  report.cycles.forEach((c, i) => {
+    const what = c.kind === "cycle" ? "cycle" : "near-cycle, closed only by type-only imports";
+    lines.push(
+      `${String(i + 1).padStart(2)}. ${what} [${c.collection}]: ${c.path.join(" → ")}`,
+      `    ${c.packages.length} package(s), ${c.edges.length} edge(s); cut ${c.cut.length}:`,
+      ...c.cut.map((e) => `      ${edge(e)}`)
+    );
+  });
+  return lines.join("\n");
+}
+
+const FIELD_ACCESS_HEADINGS: Array<[FieldAccess, string]> = [
+  ["write", "Writes"],
+  ["init", "Inits"],
+  ["address", "Address taken"],
+  ["read", "Reads"],
+];
+
+function formatFieldReferences(report: FieldReport, matching: FieldReport["sites"], limit: number): string {
+  const types = report.types;
+  const where = types.length === 1 ? `${types[0].kind}, ${types[0].file_path}:${types[0].line}` : `${types.length} types`;
+  const declared = report.declarations.length
+    ? `; declared at ${report.declarations.map((d) => `${d.file_path}:${d.line}`).join(", ")}`
+    : "; no declaration found";
+  const counts = FIELD_ACCESS_HEADINGS.filter(([a]) => report.by_access[a] > 0)
+    .map(([a]) => `${report.by_access[a]} ${a}`)
+    .join(", ");
+  const lines = [
+    `${report.target} (${where}${declared}): ${report.total} reference(s)${counts ? ` — ${counts}` : ""}; ${report.typed} typed`,
+  ];
+  const shown = matching.slice(0, limit);
+  for (const [access, heading] of FIELD_ACCESS_HEADINGS) {
+    const sites = shown.filter((s) => s.access === access);
+    if (sites.length === 0) continue;
+    lines.push("", `${heading} (${sites.length}):`);
+    for (const s of sites) {
+      lines.push(`  ${s.file_path}:${s.line}${s.function ? ` in ${s.function}` : ""}  ${s.text}${s.typed ? "" : "  (receiver type unknown)"}`);
+    }
+  }
+  if (matching.length > shown.length) lines.push("", `… ${matching.length - shown.length} more; raise limit to see them`);
+  return lines.join("\n");
+}
+
+function formatEnumUsages(report: EnumReport, limit: number): string {
+  const lines: string[] = [];
+  for (const set of report.sets) {
+    if (lines.length) lines.push("");
+    lines.push(`${set.name} (${set.language}, ${set.file_path}:${set.line}): ${set.values.length} value(s)`);
+    for (const v of set.values) {
+      const unused = v.produced === 0 && v.consumed === 0 ? "unused" : v.produced === 0 ? "never produced" : v.consumed === 0 ? "never consumed" : "";
+      lines.push(
+        `  ${v.name}${v.value ? ` = ${v.value}` : ""}  produced ${v.produced}, consumed ${v.consumed}${unused ? `  (${unused})` : ""}`
+      );
+    }
+  }
+  if (report.switches.length) {
+    const incomplete = report.switches.filter((s) => s.incomplete).length;
+    lines.push("", `Switches (${report.switches.length}, ${incomplete} incomplete):`);
+    for (const s of report.switches) {
+      const verdict =
+        s.missing.length === 0
+          ? "covers all"
+          : `missing ${s.missing.join(", ")}${s.has_default ? "; default catches them" : ", no default"}`;
+      const set = report.sets.length > 1 ? ` ${s.set}` : "";
+      lines.push(`  ${s.file_path}:${s.line}${s.function ? ` in ${s.function}` : ""}${set}  ${verdict}`);
+    }
+  }
+  const shown = report.sites.slice(0, limit);
+  if (shown.length) {
+    lines.push("", `References (${report.sites.length}):`);
+    for (const s of shown) {
+      lines.push(`  ${s.file_path}:${s.line}${s.function ? ` in ${s.function}` : ""}  ${s.role}  ${s.text}`);
+    }
+    if (report.sites.length > shown.length) lines.push(`  … ${report.sites.length - shown.length} more; raise limit to see them`);
+  }
+  return lines.length ? lines.join("\n") : `No enum-like sets in ${report.files} code file(s).`;
+}
+
+const ERROR_ROLE_HEADINGS: Record<ErrorTrace["sites"][number]["role"], string> = {
+  created: "Created",
+  wrapped: "Wrapped",
+  compared: "Compared",
+  returned: "Returned",
+  other: "Other uses",
+};
+
+function formatErrorTrace(report: ErrorTrace): string {
+  const defs = report.definitions;
+  const where = `${report.target} (${defs.length === 1 ? `${defs[0].kind}, ${defs[0].file_path}:${defs[0].line}` : `${defs.length} definitions`})`;
+  const roles = ERROR_ROLES.filter((r) => report.by_role[r] > 0)
+    .map((r) => `${report.by_role[r]} ${r}`)
+    .join(", ");
+  const lines = [`${where}: ${report.sites.length} site(s)${roles ? ` — ${roles}` : ""}`];
+  for (const role of ERROR_ROLES) {
+    const sites = report.sites.filter((s) => s.role === role);
+    if (sites.length === 0) continue;
+    lines.push("", `${ERROR_ROLE_HEADINGS[role]}:`);
+    for (const s of sites) lines.push(`  ${s.file_path}:${s.line}${s.function ? ` in ${s.function}` : ""}  ${s.text}`);
+  }
+  if (report.origins.length === 0) return lines.join("\n");
+
+  const cut = report.truncated.length ? `; stopped by ${report.truncated.join(", ")} limit` : "";
+  lines.push(
+    "",
+    `Up the call chain from ${report.origins.map((o) => o.name).join(", ")}: ${report.chain.length} caller(s)${cut}`
+  );
+  let level = 0;
+  for (const hop of report.chain) {
+    if (hop.depth !== level) {
+      level = hop.depth;
+      lines.push(`Depth ${level}:`);
+    }
+    const fate = [hop.propagates ? "passes it on" : "stops it", hop.wraps ? "wraps" : "", hop.checks ? "checks for it" : ""]
+      .filter(Boolean)
+      .join(", ");
+    lines.push(
+      `  ${hop.file_path}:${hop.line} ${hop.kind} ${hop.name} → ${hop.calls.join(", ")}  ` +
+        `(line${hop.sites.length > 1 ? "s" : ""} ${hop.sites.map((s) => s.line).join(", ")}; ${fate})`
+    );
+  }
+  return lines.join("\n");
+}
+
+function formatCallers(report: CallerReport, transitive: boolean): string {
+  const defs = report.definitions;
+  const where = `${report.target} (${defs.length === 1 ? `${defs[0].kind}, ${defs[0].file_path}:${defs[0].line}` : `${defs.length} definitions`})`;
+  const cut = report.truncated.length ? `; stopped by ${report.truncated.map((t) => t.replace("_", "-")).join(", ")} limit` : "";
+  const lines = [
+    transitive
+      ? `${report.callers.length} function(s) eventually call ${where}, up to ${report.depth} level(s) away${cut}`
+      : `${report.callers.length} function(s) call ${where}${cut}`,
+  ];
+  let level = 0;
+  for (const c of report.callers) {
+    if (transitive && c.depth !== level) {
+      level = c.depth;
+      lines.push("", `Depth ${level}:`);
+    } else if (level === 0) {
+      level = -1;
+      lines.push("");
+    }
+    lines.push(`  ${c.file_path}:${c.line} ${c.kind} ${c.name} → ${c.calls.join(", ")}  (line${c.sites.length > 1 ? "s" : ""} ${c.sites.map((s) => s.line).join(", ")})`);
+  }
+  if (report.top_level.length) {
+    lines.push("", "Calls outside any function:");
+    for (const s of report.top_level) lines.push(`  ${s.file_path}:${s.line}  ${s.text}`);
+  }
+  return lines.join("\n");
+}
+
+function formatUsage(report: UsageReport, limit: number): string {
+  const kinds = (counts: UsageReport["by_kind"]) =>
+    USAGE_KINDS.filter((k) => counts[k] > 0)
+      .map((k) => `${counts[k]} ${k.replace("_", " ")}`)
+      .join(", ");
+  const defs = report.definitions;
+  // Packages and paths say their collection when the report spans several
+  const several = new Set([...defs, ...report.sites].map((d) => d.collection)).size > 1;
+  const at = (collection: string, path: string) => (several ? `${collection}:${path}` : path);
+  const where =
+    report.scope === "symbol"
+      ? `${report.target} (${defs.length === 1 ? `${defs[0].kind}, ${at(defs[0].collection, defs[0].file_path)}:${defs[0].line}` : `${defs.length} definitions`})`
+      : `package ${report.target}`;
+  const lines = [
+    `Usage of ${where}: ${report.total} ${report.scope === "package" ? "external " : ""}reference(s) from ` +
+      `${report.consumers.length} package(s)${report.total ? ` — ${kinds(report.by_kind)}` : ""}`,
+  ];
+  if (report.consumers.length) {
+    lines.push("", "By package:");
+    for (const c of report.consumers) {
+      lines.push(`  ${at(c.collection, c.package)}  ${c.total} in ${c.files} file(s) (${kinds(c.by_kind)})${c.internal ? "  [defining package]" : ""}`);
+    }
+  }
+  if (report.symbols) {
+    const used = report.symbols.filter((s) => s.total > 0);
+    const unused = report.symbols.filter((s) => s.total === 0);
+    if (used.length) {
+      lines.push("", "By symbol:");
+      for (const s of used) lines.push(`  ${s.kind} ${s.name}  ${s.total} from ${s.packages} package(s) (${kinds(s.by_kind)})`);
+    }
+    if (unused.length) lines.push("", `Unused outside the package: ${unused.map((s) => s.name).join(", ")}`);
+  }
+  const sites = report.sites.slice(0, limit);
+  if (sites.length) {
+    lines.push("", `Sites${sites.length < report.sites.length ? ` (first ${sites.length} of ${report.sites.length})` : ""}:`);
+    for (const s of sites) lines.push(`  ${at(s.collection, s.file_path)}:${s.line} ${s.kind}  ${s.text}`);
+  }
+  return lines.join("\n");
+}
+
+function formatReferences(report: UsageReport, groups: CollectionReferences[], limit: number): string {
+  const defs = report.definitions;
+  const where =
+    report.scope === "symbol"
+      ? `${report.target} (${defs.length === 1 ? `${defs[0].kind}, ${defs[0].collection}:${defs[0].file_path}:${defs[0].line}` : `${defs.length} definitions`})`
+      : `package ${report.target}`;
+  const total = groups.reduce((n, g) => n + g.total, 0);
+  const cross = groups.filter((g) => g.external).reduce((n, g) => n + g.total, 0);
+  const lines = [
+    `References to ${where}: ${total} ${report.scope === "package" ? "external " : ""}reference(s) in ` +
+      `${groups.length} collection(s)${cross ? `, ${cross} from other collections` : ""}`,
+  ];
+  for (const g of groups) {
+    const kinds = USAGE_KINDS.filter((k) => g.by_kind[k] > 0)
+      .map((k) => `${g.by_kind[k]} ${k.replace("_", " ")}`)
+      .join(", ");
+    const sites = g.sites.slice(0, limit);
+    lines.push("", `${g.collection}${g.external ? "" : "  [defining]"}: ${g.total} in ${g.files} file(s) (${kinds})`);
+    for (const s of sites) lines.push(`  ${s.file_path}:${s.line} ${s.kind}  ${s.text}`);
+    if (sites.length < g.sites.length) lines.push(`  ... ${g.sites.length - sites.length} more`);
+  }
+  return lines.join("\n");
+}
+
+const RECOVER_NOTES: Record<RecoverStatus, string> = {
+  local: "recovered in the function",
+  upstream: "recovered by every caller chain",
+  partial: "recovered by some callers",
+  none: "no recover found",
+};
+
+/** panic_sites' text: counts, then the sites by file with what stops each. */
+function formatPanicSites(
+  sites: ExitSite[],
+  limit: number,
+  byKind: Record<ExitKind, number>,
+  byRecover: Record<RecoverStatus, number>,
+  truncated: CallTruncation[]
+): string {
+  const kinds = EXIT_KINDS.filter((k) => byKind[k] > 0).map((k) => `${byKind[k]} ${k}`);
+  const recovered = RECOVER_STATUSES.filter((r) => byRecover[r] > 0).map((r) => `${byRecover[r]} ${r}`);
+  const cut = truncated.length ? `; search for recovers stopped by ${truncated.join(", ")} limit` : "";
+  const lines = [`${kinds.join(", ")}${recovered.length ? `; panics recovered: ${recovered.join(", ")}` : ""}${cut}`];
+  if (sites.length === 0) return `${lines[0]}\nNone match the filters.`;
+  if (sites.length > limit) lines.push(`First ${limit} of ${sites.length} shown.`);
+  let file = "";
+  for (const s of sites.slice(0, limit)) {
+    if (s.file_path !== file) {
+      file = s.file_path;
+      lines.push("", file);
+    }
+    const fate =
+      s.kind === "panic"
+        ? s.recover
+          ? RECOVER_NOTES[s.recover] +
+            (s.recovered_by ? `: ${s.recovered_by.map((g) => g.name).join(", ")}` : "") +
+            (s.escapes_via ? `; escapes via ${s.escapes_via.map((e) => e.name).join(", ")}` : "")
+          : "at top level"
+        : s.kind === "fatal"
+          ? "logs, then exits the process"
+          : "exits the process";
+    lines.push(`  L${s.line}  ${s.call}${s.function ? ` in ${s.function}` : ""} — ${fate}`);
+  }
+  return lines.join("\n");
+}
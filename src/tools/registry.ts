@@ -0,0 +1,254 @@
+/**
+ * Shared MCP tool & resource registration
+ *
+ * Extracted from server.ts so both stdio and HTTP transports share
+ * identical tool implementations — and integration tests can wire up
+ * a McpServer + InMemoryTransport without any real I/O.
+ *
+ * Each feature module in this directory registers its own tools with
+ * register(server, ctx), in the order below; the wrapper chain and the
+ * reply envelope they share are in tools.ts.
+ */
+
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { DocumentStore } from "../store";
+import type { WikiOptions } from "../curator.js";
+import { createToolContext, type ToolOptions } from "./context";
+import * as documents from "./documents";
+import * as search from "./search";
+import * as symbols from "./symbols";
+import * as preferences from "./preferences";
+import * as go from "./go";
+import * as references from "./references";
+import * as codeSearch from "./code-search";
+import * as repository from "./repository";
+import * as files from "./files";
+import * as admin from "./admin";
+import * as plugins from "./plugins";
+import * as curation from "./curation";
+import * as resources from "./resources";
+
+/** Every built-in tool, registered or not; plugin tools may not reuse these names. */
+export const TOOL_NAMES = [
+  "list_documents",
+  "search_documents",
+  "get_tree",
+  "get_node_content",
+  "navigate_tree",
+  "find_symbol",
+  "set_preferences",
+  "multi_search",
+  "module_info",
+  "package_api",
+  "coverage_for",
+  "list_markers",
+  "find_duplicates",
+  "ts_query",
+  "structural_search",
+  "structural_replace",
+  "ast_diff",
+  "usage_stats",
+  "hotspots",
+  "owners_of",
+  "find_cycles",
+  "breadcrumbs",
+  "next_symbol",
+  "previous_symbol",
+  "callers",
+  "list_entrypoints",
+  "trace_errors",
+  "field_references",
+  "enum_usages",
+  "concurrency_map",
+  "context_audit",
+  "list_embeds",
+  "feedback",
+  "regex_search",
+  "capture_profile",
+  "read_file",
+  "peek_definitions",
+  "build_targets",
+  "config_usages",
+  "find_log_source",
+  "panic_sites",
+  "api_diff",
+  "find_references",
+  "reindex_path",
+  "clear_cache",
+  "evict_file",
+  "find_similar",
+  "draft_wiki_entry",
+  "write_wiki_entry",
+];
+
+/**
+ * Register all treenav-mcp tools and resources on the given MCP server.
+ *
+ * Read tools (always registered):
+ *   1. list_documents   — Browse the document catalog
+ *   2. search_documents — Keyword search across all docs
+ *   3. get_tree         — Hierarchical outline of a document
+ *   4. get_node_content — Retrieve text from specific tree nodes
+ *   5. navigate_tree    — Get a subtree (node + all descendants)
+ *   6. find_symbol      — Code-aware symbol search
+ *   7. set_preferences  — Session defaults (languages, limit, focus) and
+ *                         the snapshot pin
+ *   8. multi_search     — Several searches in one call, grouped by query
+ *
+ * Go tools (only when options.goModules is provided, i.e. CODE_ROOT):
+ *   9. module_info      — go.mod / go.work metadata and the module graph
+ *  10. package_api      — Exported API of one Go package, godoc-style
+ *  11. coverage_for     — Per-function test coverage from a cover profile
+ *                         (only when options.coverProfile is set)
+ *  12. list_markers     — TODO/FIXME/HACK comments by file or owner
+ *                         (only when options.markers is provided)
+ *  13. find_duplicates  — Cloned and near-duplicate functions
+ *                         (only when options.duplicates is provided)
+ *  14. ts_query         — Raw tree-sitter query over files or a glob
+ *                         (only when options.treeSitter is provided)
+ *  15. structural_search  — Hole-based patterns matched with balanced
+ *                           brackets, with rewrite preview
+ *  16. structural_replace — The same rewrite applied to the files
+ *                           (only with options.structuralRewrite)
+ *                         (both only when options.structural is provided)
+ *  17. ast_diff         — Symbols added/removed/changed between versions
+ *                         (only when options.astDiff is provided)
+ *  18. usage_stats      — References to a symbol or package, by
+ *                         consuming package and kind
+ *                         (only when options.usage is provided)
+ *  19. hotspots         — Files ranked by commits × complexity
+ *                         (only when options.hotspots is provided)
+ *  20. owners_of        — CODEOWNERS owners of files, doc_ids, or symbols
+ *                         (only when options.codeowners is provided)
+ *  21. find_cycles      — Import cycles in the package graph, with cuts
+ *                         (only when options.cycles is provided)
+ *  22. breadcrumbs      — Enclosing scopes of a line: package → type →
+ *                         method → block
+ *                         (only when options.breadcrumbs is provided)
+ *  23. next_symbol      — The symbols after a symbol or line, siblings
+ *  24. previous_symbol    or file order, with their content
+ *                         (both only when options.symbolNav is set)
+ *  25. callers          — Direct or transitive callers of a function,
+ *                         bounded by depth, fan-out, and count
+ *                         (only when options.usage is provided)
+ *  26. list_entrypoints — main functions, HTTP routes, gRPC services,
+ *                         and CLI commands
+ *                         (only when options.entrypoints is provided)
+ *  27. trace_errors     — Where an error is created, wrapped, compared,
+ *                         and returned, and the callers it travels up
+ *                         (only when options.usage is provided)
+ *  28. field_references — Reads and writes of one field of a type
+ *                         (only when options.fields is provided)
+ *  29. enum_usages      — Where enum values are produced and consumed,
+ *                         and switches that miss values
+ *                         (only when options.enums is provided)
+ *  30. concurrency_map  — Goroutines, channels, and locks per Go package,
+ *                         with unpaired locks
+ *                         (only when options.concurrency is provided)
+ *  31. context_audit    — Functions taking a context.Context that drop it,
+ *                         replace it, or call context-less variants
+ *                         (only when options.contextAudit is provided)
+ *  32. list_embeds      — //go:embed directives and the files they match,
+ *                         for a package or a whole binary
+ *                         (only when options.embeds is provided)
+ *  33. feedback         — Relevance judgements for the query log
+ *                         (only when options.queryLog is provided)
+ *  34. regex_search     — Regular expressions or substrings over code
+ *                         files, skipping files by trigram filters
+ *                         (only when options.regex is provided)
+ *
+ * Admin tools (only when options.profiler is provided, i.e. PROFILE_DIR):
+ *  35. capture_profile  — CPU or heap profile of the server, to a file
+ *
+ * File tools:
+ *  36. read_file        — Lines of an indexed file from disk, capped at
+ *                         max_bytes, with a notice when cut short
+ *                         (only when options.files is provided)
+ *  37. peek_definitions — Signature, doc comment, and body excerpt for
+ *                         each of many symbol names, in one call
+ *                         (only when options.peek is provided)
+ *  38. build_targets    — Makefile and Bazel targets that build a file,
+ *                         or one target's sources, deps, and dependents
+ *                         (only when options.buildTargets is provided)
+ *  39. config_usages    — Env vars, flags, and config keys, each with
+ *                         where it is defined and every place it is read
+ *                         (only when options.configUsages is provided)
+ *  40. find_log_source  — The log, error, or metric call that emits a
+ *                         production log line or metric name
+ *                         (only when options.logSources is provided)
+ *  41. panic_sites      — Go panic, log.Fatal, and os.Exit calls, with
+ *                         whether a recover upstream stops each panic
+ *                         (only when options.usage is provided)
+ *  42. api_diff         — Exported Go API changes between two refs,
+ *                         breaking or additive, and the semver bump
+ *                         (only when options.apiDiff is provided)
+ *  43. find_references  — References to a symbol or package grouped by
+ *                         collection, across every code root
+ *                         (only when options.usage is provided)
+ *
+ * Admin tools (only when options.admin is provided, i.e. ADMIN_TOOLS=1):
+ *  44. reindex_path     — Re-index a directory or file from disk
+ *  45. clear_cache      — Drop query-time caches so they are rebuilt
+ *  46. evict_file       — Remove documents from the index
+ *
+ * Curation tools (only when options.wiki is provided, i.e. WIKI_WRITE=1):
+ *  47. find_similar     — BM25 dedupe check for prospective content
+ *  48. draft_wiki_entry — Structural scaffold for a new entry (no write)
+ *  49. write_wiki_entry — Validated write + incremental re-index
+ *
+ * Plugin tools (options.plugins, i.e. PLUGINS): one per loaded
+ * definition, answering from the same store; see plugins.ts.
+ *
+ * When options.lazy is provided (LAZY_INDEX=1), read tools expand the
+ * regions a query or doc_id touches before answering. options.session
+ * carries set_preferences state across calls; a fresh one is created
+ * when omitted. options.coverage (INCLUDE) turns lookups outside the
+ * indexed set into an explicit "not indexed" status. options.refs
+ * answers the `ref` argument of the read tools (except set_preferences)
+ * from the collections as of a git ref. options.staleness flags search
+ * results whose files changed since indexing, and can re-index them.
+ * options.licenses tags the files of search hits, sections, and
+ * read_file excerpts with their license and provenance.
+ *
+ * Every tool declares an outputSchema (schemas.ts) and returns
+ * structuredContent alongside its text; see docs/TOOL-SCHEMAS.md.
+ * Annotations mark everything read-only except set_preferences
+ * (session state), feedback and capture_profile (new records),
+ * reindex_path and clear_cache (refreshes), and evict_file,
+ * write_wiki_entry, and structural_replace (destructive).
+ *
+ * The returned ToolSet turns the curation tools on or off after the
+ * server is connected, without a client restart.
+ *
+ * Resources:
+ *   - index-stats (md-tree://stats) — JSON index statistics
+ *   - document, section, file, symbol templates — completable doc_id,
+ *     node_id, path, and symbol name arguments (completion/complete)
+ */
+export function registerTools(server: McpServer, store: DocumentStore, options?: ToolOptions): ToolSet {
+  const ctx = createToolContext(server, store, options);
+  documents.register(server, ctx);
+  search.register(server, ctx);
+  symbols.register(server, ctx);
+  preferences.register(server, ctx);
+  go.register(server, ctx);
+  references.register(server, ctx);
+  codeSearch.register(server, ctx);
+  repository.register(server, ctx);
+  files.register(server, ctx);
+  admin.register(server, ctx);
+  plugins.register(server, ctx);
+  const setWiki = curation.register(server, ctx);
+  resources.register(server, ctx);
+  return { setWiki };
+}
+
+/** Changes to the advertised tool set after registerTools. */
+export interface ToolSet {
+  /**
+   * Enable the curation tools with these options, or disable them with
+   * undefined. Returns true when tools were added or removed; a new root
+   * or threshold alone applies in place and returns false.
+   */
+  setWiki(wiki: WikiOptions | undefined): boolean;
+}
@@ -0,0 +1,745 @@
+/**
+ * Repository tools: list_markers, ast_diff, hotspots, owners_of,
+ * list_entrypoints, build_targets, config_usages, and find_log_source
+ *
+ * Facts about the repository around the code: comment markers, change
+ * history, ownership, entry points, build targets, configuration, and
+ * where log lines come from.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { DocumentStore } from "../store";
+import { markerOwner, type CodeMarker } from "../markers";
+import { AstDiffError, type AstDiff, type SymbolChange } from "../ast-diff";
+import type { Hotspot } from "../hotspots";
+import type { OwnersEntry } from "../codeowners";
+import { ENTRYPOINT_KINDS, type Entrypoint, type EntrypointKind } from "../entrypoints";
+import { BUILD_SYSTEMS, BuildTargetError, type BuildMatch, type BuildSystem, type BuildTarget } from "../build-targets";
+import { CONFIG_SOURCES, type ConfigKey, type ConfigSite, type ConfigSource } from "../config-usages";
+import { EMIT_KINDS, type EmitKind, type LogSourceMatch } from "../log-sources";
+import { locationUri } from "../uris";
+import {
+  AST_DIFF_OUTPUT,
+  HOTSPOTS_OUTPUT,
+  LIST_ENTRYPOINTS_OUTPUT,
+  LIST_MARKERS_OUTPUT,
+  OWNERS_OF_OUTPUT,
+  BUILD_TARGETS_OUTPUT,
+  CONFIG_USAGES_OUTPUT,
+  FIND_LOG_SOURCE_OUTPUT,
+} from "../schemas";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import { INCLUDE_TESTS_INPUT, READ_ONLY } from "./shared";
+
+/** Register the repository tools whose index is configured. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, options, registerTool, session } = ctx;
+
+  // ── Tool 12: list_markers ──────────────────────────────────────────
+
+  const markers = options.markers;
+  if (markers) {
+    registerTool(
+      "list_markers",
+      {
+        description:
+          `List ${markers.markers.join("/")} comments in the indexed code, grouped by file or by owner. The owner is the name in TODO(name), else the line's last author from git blame; blame also dates each marker, so old ones can be singled out. Use this to turn scattered TODOs into a worklist, e.g. group_by="owner" with older_than_days=180 for stale items.`,
+        inputSchema: {
+          path: z
+            .string()
+            .optional()
+            .describe("Only files under this path prefix (default: the session focus, else everything)"),
+          marker: z
+            .string()
+            .optional()
+            .describe(`Only this marker (one of ${markers.markers.join(", ")})`),
+          owner: z
+            .string()
+            .optional()
+            .describe("Only markers whose owner, author, or author email contains this (case-insensitive)"),
+          older_than_days: z
+            .number()
+            .min(0)
+            .optional()
+            .describe("Only markers on lines last changed more than this many days ago"),
+          newer_than_days: z
+            .number()
+            .min(0)
+            .optional()
+            .describe("Only markers on lines changed within this many days, uncommitted ones included"),
+          group_by: z
+            .enum(["file", "owner"])
+            .default("file")
+            .describe('Group by "file" (default) or "owner"'),
+          limit: z
+            .number()
+            .min(1)
+            .max(500)
+            .default(100)
+            .describe("Max markers to list (default 100)"),
+        },
+        outputSchema: LIST_MARKERS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, marker, owner, older_than_days, newer_than_days, group_by, limit }) => {
+        const now = Date.now();
+        const ageDays = (m: CodeMarker) =>
+          m.changed_at === undefined ? undefined : Math.floor((now - m.changed_at) / (24 * 60 * 60 * 1000));
+        const who = owner?.toLowerCase();
+
+        const found = (await markers.scan(store, path ?? session.get().focus)).filter((m) => {
+          if (marker && m.marker !== marker) return false;
+          if (who && ![m.tag, m.author, m.email].some((v) => v?.toLowerCase().includes(who))) return false;
+          const age = ageDays(m);
+          if (older_than_days !== undefined && (age === undefined || age <= older_than_days)) return false;
+          if (newer_than_days !== undefined && age !== undefined && age > newer_than_days) return false;
+          return true;
+        });
+
+        const keyOf = (m: CodeMarker) => (group_by === "owner" ? markerOwner(m) ?? "(unknown)" : m.file_path);
+        const grouped = new Map<string, CodeMarker[]>();
+        for (const m of found) {
+          const key = keyOf(m);
+          if (!grouped.has(key)) grouped.set(key, []);
+          grouped.get(key)!.push(m);
+        }
+        const groups = [...grouped.entries()].sort(([a, x], [b, y]) =>
+          group_by === "owner" ? y.length - x.length || a.localeCompare(b) : a.localeCompare(b)
+        );
+        for (const [, list] of groups) {
+          list.sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+        }
+
+        const listed = groups.flatMap(([, list]) => list).slice(0, limit);
+        const payload = {
+          total: found.length,
+          groups: groups.map(([key, list]) => ({ key, count: list.length })),
+          markers: listed.map((m) => {
+            const age = ageDays(m);
+            const by = markerOwner(m);
+            return {
+              marker: m.marker,
+              ...(m.tag ? { tag: m.tag } : {}),
+              text: m.text,
+              doc_id: m.doc_id,
+              collection: m.collection,
+              file_path: m.file_path,
+              line: m.line,
+              ...(by ? { owner: by } : {}),
+              ...(m.author ? { author: m.author, email: m.email } : {}),
+              ...(m.changed_at !== undefined ? { changed_at: new Date(m.changed_at).toISOString() } : {}),
+              ...(age !== undefined ? { age_days: age } : {}),
+              uri: locationUri(store, m.doc_id, m.line),
+            };
+          }),
+        };
+        if (found.length === 0) {
+          return reply("No markers match.", payload);
+        }
+        return reply(formatMarkers(payload.markers, payload.groups, found, group_by), payload);
+      }
+    );
+  }
+
+  // ── Tool 17: ast_diff ──────────────────────────────────────────────
+
+  const astDiff = options.astDiff;
+  if (astDiff) {
+    registerTool(
+      "ast_diff",
+      {
+        description:
+          "Compare a code file between two git refs, or against content you provide, and report structural changes instead of line hunks: functions, methods, and types added, removed, renamed, with changed signatures, or with changed bodies. Formatting and moves are ignored. Use it to review a change or check an edit before saving it, e.g. base=\"main\" to see what a branch did to a file.",
+        inputSchema: {
+          path: z.string().describe("File path relative to its code root, or doc_id"),
+          base: z.string().default("HEAD").describe("Old side: any git ref (default HEAD)"),
+          head: z
+            .string()
+            .optional()
+            .describe("New side: a git ref (default: the working tree)"),
+          content: z
+            .string()
+            .optional()
+            .describe("New side: this source text instead of a ref, e.g. an unsaved edit"),
+        },
+        outputSchema: AST_DIFF_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, base, head, content }) => {
+        if (head !== undefined && content !== undefined) {
+          return errorResult(new AstDiffError("pass head or content, not both"));
+        }
+        let result: Awaited<ReturnType<AstDiff["diff"]>>;
+        try {
+          result = await astDiff.diff(store, path, { base, head, content });
+        } catch (err) {
+          if (err instanceof AstDiffError) return errorResult(err);
+          throw err;
+        }
+        const counts = { added: 0, removed: 0, renamed: 0, signature_changed: 0, body_changed: 0 };
+        if (!result) {
+          const empty = { file_path: path, base, head: head ?? (content !== undefined ? "content" : "working tree"), counts, changes: [] };
+          return reply(`"${path}" exists neither at ${base} nor on the new side.`, empty, "not_found");
+        }
+        for (const c of result.changes) counts[c.change]++;
+        const payload = { ...result, counts };
+        if (result.changes.length === 0) {
+          return reply(`No structural changes to ${result.file_path} between ${result.base} and ${result.head}.`, payload);
+        }
+        return reply(formatAstDiff(result.changes, payload), payload);
+      }
+    );
+  }
+
+  // ── Tool 19: hotspots ──────────────────────────────────────────────
+
+  const hotspots = options.hotspots;
+  if (hotspots) {
+    registerTool(
+      "hotspots",
+      {
+        description:
+          "Rank code files by change frequency × complexity: commits from git history (optionally since a date) times an approximate cyclomatic complexity. The top files are where refactoring pays off most. Each entry names its most complex function as the place to start.",
+        inputSchema: {
+          path: z
+            .string()
+            .optional()
+            .describe("File, directory prefix, or glob to rank within (default: all code)"),
+          since: z
+            .string()
+            .optional()
+            .describe('History window, anything git --since accepts: "6 months ago", "2025-01-01" (default: all history)'),
+          limit: z.number().int().min(1).max(200).default(20).describe("Files to return"),
+        },
+        outputSchema: HOTSPOTS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, since, limit }) => {
+        const result = await hotspots.rank(store, { path, since });
+        if (!result) {
+          return reply(`No indexed code files${path ? ` under "${path}"` : ""}.`, { files: 0, changed: 0, hotspots: [] }, "not_found");
+        }
+        const payload = { ...(since ? { since } : {}), ...result, hotspots: result.hotspots.slice(0, limit) };
+        if (result.changed === 0) {
+          return reply(
+            `None of ${result.files} code file(s) has commits${since ? ` since ${since}` : ""}; is the code root under git?`,
+            payload
+          );
+        }
+        return reply(formatHotspots(payload.hotspots, result, since), payload);
+      }
+    );
+  }
+
+  // ── Tool 20: owners_of ─────────────────────────────────────────────
+
+  const codeowners = options.codeowners;
+  if (codeowners) {
+    registerTool(
+      "owners_of",
+      {
+        description:
+          "Look up the CODEOWNERS owners of files, doc_ids, or symbol names (e.g. from search results), with GitHub's matching rules: the last matching pattern wins. Returns the deciding CODEOWNERS line for each file and groups the files by owner, for routing reviews.",
+        inputSchema: {
+          targets: z
+            .array(z.string())
+            .min(1)
+            .max(100)
+            .describe("File paths, doc_ids, or symbol names (AuthService, Server.Start)"),
+        },
+        outputSchema: OWNERS_OF_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ targets }) => {
+        const docs = store.listDocuments({ limit: Infinity }).documents;
+        const entries: OwnersEntry[] = [];
+        for (const target of targets) {
+          // A doc_id or an indexed path; else a symbol name
+          const files: Array<{ collection: string; file_path: string; doc_id?: string; symbol?: string; node_id?: string }> = [];
+          const doc = store.getDocMeta(target) ?? docs.find((d) => d.file_path === target);
+          if (doc) files.push({ collection: doc.collection, file_path: doc.file_path, doc_id: doc.doc_id });
+          else files.push(...symbolFiles(store, target));
+          if (files.length === 0) {
+            entries.push({ target, status: "not_found", owners: [] });
+            continue;
+          }
+          for (const file of files) {
+            const ownership = await codeowners.ownersOf(file.collection, file.file_path);
+            entries.push({
+              target,
+              status: !ownership ? "no_codeowners" : ownership.owners.length ? "owned" : "unowned",
+              ...file,
+              owners: ownership?.owners ?? [],
+              ...(ownership ? { codeowners: ownership.codeowners } : {}),
+              ...(ownership?.rule ? { rule: ownership.rule } : {}),
+            });
+          }
+        }
+
+        const byOwner = new Map<string, Set<string>>();
+        for (const e of entries) {
+          for (const owner of e.owners) {
+            if (!byOwner.has(owner)) byOwner.set(owner, new Set());
+            byOwner.get(owner)!.add(e.file_path!);
+          }
+        }
+        const by_owner = [...byOwner]
+          .map(([owner, files]) => ({ owner, files: [...files].sort() }))
+          .sort((a, b) => b.files.length - a.files.length || a.owner.localeCompare(b.owner));
+        const payload = { entries, by_owner };
+        if (entries.every((e) => e.status === "not_found")) {
+          return reply(`No indexed file or symbol matches ${targets.map((t) => `"${t}"`).join(", ")}.`, payload, "not_found");
+        }
+        return reply(formatOwners(entries, by_owner), payload);
+      }
+    );
+  }
+
+  // ── Tool 26: list_entrypoints ──────────────────────────────────────
+
+  const entrypoints = options.entrypoints;
+  if (entrypoints) {
+    registerTool(
+      "list_entrypoints",
+      {
+        description:
+          "List the natural starting points for tracing behavior: main functions, HTTP route registrations (net/http, gorilla/mux, chi, gin, echo, Flask, FastAPI, Express, Spring, ...), gRPC service registrations, and CLI command definitions (cobra, urfave/cli, click, argparse, commander). Each comes with its handler when the registration names one and the function it is registered in.",
+        inputSchema: {
+          path: z
+            .string()
+            .optional()
+            .describe("Only files under this path prefix (default: the session focus, else everything)"),
+          kind: z.enum(ENTRYPOINT_KINDS as [EntrypointKind, ...EntrypointKind[]]).optional().describe("Only this kind"),
+          framework: z.string().optional().describe('Only this framework, e.g. "gin" or "cobra"'),
+          limit: z.number().int().min(1).max(1000).default(200).describe("Max entry points to list (default 200)"),
+        },
+        outputSchema: LIST_ENTRYPOINTS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ path, kind, framework, limit }) => {
+        const found = (await entrypoints.scan(store, path ?? session.get().focus)).filter(
+          (e) => (!kind || e.kind === kind) && (!framework || e.framework === framework)
+        );
+        const by_kind = Object.fromEntries(ENTRYPOINT_KINDS.map((k) => [k, found.filter((e) => e.kind === k).length])) as Record<
+          EntrypointKind,
+          number
+        >;
+        const listed = found.slice(0, limit).map((e) => {
+          const node = store.nodeAt(e.doc_id, e.line, e.line);
+          return { ...e, ...(node ? { symbol: node.title } : {}), uri: locationUri(store, e.doc_id, e.line) };
+        });
+        const payload = { total: found.length, by_kind, entrypoints: listed };
+        if (found.length === 0) {
+          return reply(`No entry points found${path ? ` under "${path}"` : ""}.`, payload);
+        }
+        return reply(formatEntrypoints(listed, found.length), payload);
+      }
+    );
+  }
+
+  // ── Tool 38: build_targets ────────────────────────────────────────
+
+  const buildTargets = options.buildTargets;
+  if (buildTargets) {
+    registerTool(
+      "build_targets",
+      {
+        description:
+          'Answer "which target builds this file?" from the indexed Makefiles and Bazel BUILD files. Pass file to get the targets whose sources name it, outright or by glob; pass target (a Bazel label like //pkg/pool:pool or :pool, or a Makefile target like tools/Makefile:lint or lint) to get its sources, dependencies, and the targets that depend on it; pass neither to list the targets under path. Make variables and Bazel glob() are resolved; Make pattern rules and included makefiles are not followed.',
+        inputSchema: {
+          file: z.string().optional().describe('A file path relative to its collection root, e.g. "pkg/pool/pool.go"'),
+          target: z.string().optional().describe('Instead of file: a label, e.g. "//pkg/pool:pool" or "tools/Makefile:lint"'),
+          path: z
+            .string()
+            .optional()
+            .describe("With neither: list targets of build files under this path prefix (default: the session focus)"),
+          system: z.enum(BUILD_SYSTEMS as [BuildSystem, ...BuildSystem[]]).optional().describe("Only Bazel or only Make targets"),
+          limit: z.number().int().min(1).max(1000).default(100).describe("Max targets to list (default 100)"),
+        },
+        outputSchema: BUILD_TARGETS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ file, target, path, system, limit }) => {
+        if (file !== undefined && target !== undefined) return errorResult(new BuildTargetError("pass file or target, not both"));
+        const bySystem = (t: BuildTarget) => !system || t.system === system;
+        const listed = (t: BuildTarget, via?: string) => ({
+          ...t,
+          ...(via ? { via } : {}),
+          uri: locationUri(store, t.doc_id, t.line),
+        });
+
+        if (file !== undefined) {
+          const matches = (await buildTargets.targetsFor(store, file)).filter((m) => bySystem(m.target));
+          const payload = { total: matches.length, targets: matches.slice(0, limit).map((m) => listed(m.target, m.via)) };
+          if (matches.length === 0) {
+            const text = `No build target names ${file} in its sources. Try build_targets with path to see the targets nearby.`;
+            return reply(text, payload, "not_found", text);
+          }
+          return reply(formatBuildMatches(file, matches.slice(0, limit), matches.length), payload);
+        }
+
+        if (target !== undefined) {
+          const found = (await buildTargets.find(store, target)).filter(bySystem);
+          if (found.length === 0) {
+            const text = `No build target "${target}". Labels look like //pkg:name or tools/Makefile:name.`;
+            return reply(text, { total: 0, targets: [] }, "not_found", text);
+          }
+          const dependents = new Map<string, BuildTarget>();
+          for (const t of found) for (const d of await buildTargets.dependents(store, t)) dependents.set(d.label, d);
+          const payload = {
+            total: found.length,
+            targets: found.slice(0, limit).map((t) => listed(t)),
+            dependents: [...dependents.values()].map((d) => ({ label: d.label, rule: d.rule, file_path: d.file_path, line: d.line })),
+          };
+          return reply(formatBuildTarget(found.slice(0, limit), [...dependents.values()]), payload);
+        }
+
+        const all = (await buildTargets.scan(store, path ?? session.get().focus)).filter(bySystem);
+        const payload = { total: all.length, targets: all.slice(0, limit).map((t) => listed(t)) };
+        if (all.length === 0) {
+          return reply(`No Makefile or BUILD targets found${path ? ` under "${path}"` : ""}.`, payload);
+        }
+        return reply(formatBuildTargetList(all.slice(0, limit), all.length), payload);
+      }
+    );
+  }
+
+  // ── Tool 39: config_usages ────────────────────────────────────────
+
+  const configUsages = options.configUsages;
+  if (configUsages) {
+    registerTool(
+      "config_usages",
+      {
+        description:
+          'Map configuration keys to the code that reads them: environment variables (os.Getenv, process.env, os.environ, System.getenv, env::var, ENV[...], env:"X" struct tags), command-line flags (flag / pflag / cobra, argparse, click, commander), and config keys (viper, Spring @Value, System.getProperty, .NET IConfiguration). Each key comes with where it is defined (flag declared, default set, variable exported) and every read, with the enclosing function. Use it to answer "what does this env var actually control?"; pass no key to inventory every setting.',
+        inputSchema: {
+          key: z
+            .string()
+            .optional()
+            .describe('Only this key, e.g. "DB_HOST", "db.pool.size", or "--port"; end with * for a prefix ("DB_*")'),
+          source: z.enum(CONFIG_SOURCES as [ConfigSource, ...ConfigSource[]]).optional().describe("Only env vars, flags, or config keys"),
+          path: z.string().optional().describe("Only files under this path prefix (default: the session focus, else everything)"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(500).default(50).describe("Max keys to list, most-used first (default 50)"),
+          sites: z.number().int().min(1).max(200).default(20).describe("Max definitions and reads listed per key (default 20)"),
+        },
+        outputSchema: CONFIG_USAGES_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ key, source, path, include_tests, limit, sites }) => {
+        const keys = await configUsages.keys(store, { key, source, path: path ?? session.get().focus, include_tests });
+        const site = (s: ConfigSite) => {
+          const node = store.nodeAt(s.doc_id, s.line, s.line);
+          return {
+            use: s.use,
+            api: s.api,
+            doc_id: s.doc_id,
+            file_path: s.file_path,
+            line: s.line,
+            text: s.text,
+            ...(node ? { symbol: node.title } : {}),
+            uri: locationUri(store, s.doc_id, s.line),
+          };
+        };
+        const listed = keys.slice(0, limit).map((k) => ({
+          key: k.key,
+          source: k.source,
+          definition_count: k.definitions.length,
+          read_count: k.reads.length,
+          definitions: k.definitions.slice(0, sites).map(site),
+          reads: k.reads.slice(0, Math.max(0, sites - Math.min(k.definitions.length, sites))).map(site),
+        }));
+        const payload = { total: keys.length, keys: listed };
+        if (keys.length === 0) {
+          const text = key
+            ? `No code reads or defines "${key}". Keys are found when named as string literals; try a prefix like "${key.replace(/[^A-Za-z]+$/, "")}*".`
+            : `No environment variables, flags, or config keys found${path ? ` under "${path}"` : ""}.`;
+          return reply(text, payload, key ? "not_found" : "ok", key ? text : undefined);
+        }
+        return reply(formatConfigUsages(listed, keys), payload);
+      }
+    );
+  }
+
+  // ── Tool 40: find_log_source ──────────────────────────────────────
+
+  const logSources = options.logSources;
+  if (logSources) {
+    registerTool(
+      "find_log_source",
+      {
+        description:
+          'Find the code that emits a production log line, error message, or metric. Paste the line as it appears (timestamps, values, and structured fields included) or a metric name from a dashboard (http_requests_total, http_request_duration_seconds_bucket{le="0.5"}). Matches the string literals passed to logging calls (log / slog / zap / logrus / zerolog, Python logging, console / pino / winston, SLF4J, tracing, ILogger), error constructors (fmt.Errorf, errors.New, raise, throw new ...Error), and metric registrations (Prometheus, OpenTelemetry, statsd, Micrometer), with format placeholders (%s, %v, {}, ${x}) standing for the values. Best match first, with the values each placeholder took.',
+        inputSchema: {
+          text: z.string().min(1).describe("A log line, a fragment of one, or a metric name"),
+          kind: z.enum(EMIT_KINDS as [EmitKind, ...EmitKind[]]).optional().describe("Only log calls, error constructors, or metrics"),
+          path: z.string().optional().describe("Only files under this path prefix (default: the session focus, else everything)"),
+          include_tests: INCLUDE_TESTS_INPUT,
+          limit: z.number().int().min(1).max(100).default(10).describe("Max matches (default 10)"),
+        },
+        outputSchema: FIND_LOG_SOURCE_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ text, kind, path, include_tests, limit }) => {
+        const found = await logSources.find(store, text, { kind, path: path ?? session.get().focus, include_tests });
+        const matches = found.slice(0, limit).map(({ site, match, score, values }) => {
+          const node = store.nodeAt(site.doc_id, site.line, site.line);
+          return {
+            kind: site.kind,
+            match,
+            score,
+            api: site.api,
+            ...(site.level ? { level: site.level } : {}),
+            template: site.template,
+            ...(values ? { values } : {}),
+            doc_id: site.doc_id,
+            file_path: site.file_path,
+            line: site.line,
+            text: site.text,
+            ...(node ? { symbol: node.title } : {}),
+            uri: locationUri(store, site.doc_id, site.line),
+          };
+        });
+        const payload = { total: found.length, matches };
+        if (found.length === 0) {
+          const message = `No log, error, or metric call in the indexed code emits "${text.trim()}". Only string literals passed to known calls are matched; try a distinctive fragment of the message.`;
+          return reply(message, payload, "not_found", message);
+        }
+        return reply(formatLogSources(matches, found), payload);
+      }
+    );
+  }
+}
+
+/**
+ * Files defining a symbol: exact name matches among code nodes, with a
+ * Parent.name form matching the enclosing symbol too.
+ */
+function symbolFiles(
+  store: DocumentStore,
+  symbol: string
+): Array<{ collection: string; file_path: string; doc_id: string; symbol: string; node_id: string }> {
+  const dot = symbol.lastIndexOf(".");
+  const name = dot > 0 ? symbol.slice(dot + 1) : symbol;
+  const parent = dot > 0 ? symbol.slice(0, dot) : undefined;
+  const found = store.searchDocuments(name, { limit: 50, filters: { content_type: "code" }, word_boundaries: true });
+  const files = [];
+  for (const r of found) {
+    if (r.node_title.slice(r.node_title.indexOf(" ") + 1) !== name) continue;
+    if (parent !== undefined) {
+      const node = store.getNodeContent(r.doc_id, [r.node_id])?.nodes[0];
+      const up = node?.parent_id ? store.getNodeContent(r.doc_id, [node.parent_id])?.nodes[0] : undefined;
+      if (up?.title.slice(up.title.indexOf(" ") + 1) !== parent) continue;
+    }
+    files.push({ collection: r.collection, file_path: r.file_path, doc_id: r.doc_id, symbol: r.node_title, node_id: r.node_id });
+  }
+  return files;
+}
+
+function formatOwners(
+  entries: OwnersEntry[],
+  byOwner: Array<{ owner: string; files: string[] }>
+): string {
+  const lines = entries.map((e) => {
+    const what = e.file_path ? (e.file_path === e.target ? e.file_path : `${e.target} → ${e.file_path}`) : e.target;
+    switch (e.status) {
+      case "not_found":
+        return `${what}  not found`;
+      case "no_codeowners":
+        return `${what}  no CODEOWNERS file`;
+      default: {
+        const rule = e.rule ? `  (${e.codeowners}:${e.rule.line} ${e.rule.pattern})` : "";
+        return `${what}  ${e.owners.length ? e.owners.join(" ") : "unowned"}${rule}`;
+      }
+    }
+  });
+  if (byOwner.length) {
+    lines.push("", "By owner:");
+    for (const o of byOwner) lines.push(`  ${o.owner}: ${o.files.join(", ")}`);
+  }
+  return lines.join("\n");
+}
+
+function formatHotspots(
+  hotspots: Hotspot[],
+  result: { files: number; changed: number },
+  since: string | undefined
+): string {
+  const lines = [`Hotspots${since ? ` since ${since}` : ""} (${result.changed} of ${result.files} file(s) changed):`, ""];
+  hotspots.forEach((h, i) => {
+    lines.push(
+      `${String(i + 1).padStart(2)}. ${h.file_path}  score ${h.score} = ${h.commits} commit(s) × complexity ${h.complexity}`,
+      `    +${h.lines_added}/−${h.lines_deleted} lines, ${h.authors} author(s), last ${new Date(h.last_changed).toISOString().slice(0, 10)}` +
+        (h.max_function ? `; most complex: ${h.max_function.name} (L${h.max_function.line}, ${h.max_function.complexity})` : "")
+    );
+  });
+  return lines.join("\n");
+}
+
+const ENTRYPOINT_HEADINGS: Record<EntrypointKind, string> = {
+  main: "Programs",
+  http_route: "HTTP routes",
+  grpc_service: "gRPC services",
+  cli_command: "CLI commands",
+};
+
+function formatEntrypoints(listed: Array<Entrypoint & { symbol?: string }>, total: number): string {
+  const lines = [`${total} entry point(s)${listed.length < total ? `, first ${listed.length} shown` : ""}`];
+  for (const kind of ENTRYPOINT_KINDS) {
+    const group = listed.filter((e) => e.kind === kind);
+    if (group.length === 0) continue;
+    lines.push("", `${ENTRYPOINT_HEADINGS[kind]} (${group.length}):`);
+    for (const e of group) {
+      const handler = e.handler ? ` → ${e.handler}` : "";
+      const inside = e.symbol && kind !== "main" ? `  in ${e.symbol}` : "";
+      lines.push(`  ${e.name}${handler}  ${e.file_path}:${e.line} (${e.framework})${inside}`);
+    }
+  }
+  return lines.join("\n");
+}
+
+function formatAstDiff(
+  changes: SymbolChange[],
+  result: { file_path: string; base: string; head: string; counts: Record<string, number> }
+): string {
+  const summary = Object.entries(result.counts)
+    .filter(([, n]) => n > 0)
+    .map(([change, n]) => `${n} ${change.replace("_", " ")}`)
+    .join(", ");
+  const lines = [`${result.file_path}: ${result.base} → ${result.head} (${summary})`, ""];
+  const mark: Record<string, string> = { added: "+", removed: "-", renamed: "→", signature_changed: "~", body_changed: "·" };
+  for (const c of changes) {
+    const line = c.line_after ?? c.line_before;
+    const label =
+      c.change === "renamed"
+        ? `${c.kind} ${c.old_name} → ${c.name}`
+        : `${c.kind} ${c.name}${c.change === "body_changed" ? " (body)" : ""}`;
+    lines.push(`${mark[c.change]} L${line} ${label}`);
+    if (c.change === "signature_changed" || (c.change === "renamed" && c.signature_before)) {
+      lines.push(`    - ${c.signature_before}`, `    + ${c.signature_after}${c.body_changed ? "  (body changed too)" : ""}`);
+    } else if (c.change === "added" || c.change === "removed") {
+      lines.push(`    ${c.signature_after ?? c.signature_before}`);
+    }
+  }
+  return lines.join("\n");
+}
+
+/** One line per target: label, rule, and where it is declared. */
+function buildTargetLine(t: BuildTarget): string {
+  return `${t.label}  ${t.rule}  ${t.file_path}:${t.line}`;
+}
+
+/** build_targets with file: the targets naming it, and how. */
+function formatBuildMatches(file: string, matches: BuildMatch[], total: number): string {
+  const lines = [`${total} target(s) build ${file}${matches.length < total ? `, first ${matches.length} shown` : ""}:`, ""];
+  for (const { target, via } of matches) {
+    lines.push(`  ${buildTargetLine(target)}${target.sources.includes(via) ? "" : `  (glob ${via})`}`);
+    if (target.deps.length) lines.push(`    deps: ${target.deps.join(", ")}`);
+  }
+  return lines.join("\n");
+}
+
+/** build_targets with target: each target's sources and deps, then its dependents. */
+function formatBuildTarget(targets: BuildTarget[], dependents: BuildTarget[]): string {
+  const blocks = targets.map((t) => {
+    const lines = [buildTargetLine(t)];
+    if (t.sources.length) lines.push(`  sources (${t.sources.length}): ${t.sources.join(", ")}`);
+    if (t.globs.length) {
+      const excludes = t.excludes?.length ? ` excluding ${t.excludes.join(", ")}` : "";
+      lines.push(`  globs: ${t.globs.join(", ")}${excludes}`);
+    }
+    lines.push(t.deps.length ? `  deps (${t.deps.length}): ${t.deps.join(", ")}` : "  deps: none");
+    return lines.join("\n");
+  });
+  const rdeps = dependents.length
+    ? `Depended on by (${dependents.length}):\n${dependents.map((d) => `  ${buildTargetLine(d)}`).join("\n")}`
+    : "Nothing indexed depends on it.";
+  return `${blocks.join("\n\n")}\n\n${rdeps}`;
+}
+
+/** build_targets with neither: targets grouped by build file. */
+function formatBuildTargetList(targets: BuildTarget[], total: number): string {
+  const lines = [`${total} build target(s)${targets.length < total ? `, first ${targets.length} shown` : ""}`];
+  let file = "";
+  for (const t of targets) {
+    if (t.file_path !== file) {
+      file = t.file_path;
+      lines.push("", `${file}:`);
+    }
+    lines.push(`  ${t.name}  ${t.rule}  :${t.line}${t.deps.length ? `  → ${t.deps.join(", ")}` : ""}`);
+  }
+  return lines.join("\n");
+}
+
+type ConfigSiteOut = { use: string; api: string; file_path: string; line: number; symbol?: string };
+
+/** config_usages' text: per key, its definitions then its reads, each with the enclosing symbol. */
+function formatConfigUsages(
+  listed: Array<{ key: string; source: ConfigSource; definitions: Array<ConfigSiteOut>; reads: Array<ConfigSiteOut> }>,
+  keys: ConfigKey[]
+): string {
+  const total = keys.reduce((n, k) => n + k.definitions.length + k.reads.length, 0);
+  const lines = [`${keys.length} key(s), ${total} site(s)${listed.length < keys.length ? `; first ${listed.length} keys shown` : ""}`];
+  for (const [i, k] of listed.entries()) {
+    const { definitions, reads } = keys[i];
+    lines.push("", `${k.key} (${k.source}): ${definitions.length} definition(s), ${reads.length} read(s)`);
+    for (const s of [...k.definitions, ...k.reads]) {
+      lines.push(`  ${s.use === "define" ? "define" : "read  "}  ${s.file_path}:${s.line}  ${s.api}${s.symbol ? `  in ${s.symbol}` : ""}`);
+    }
+    const hidden = definitions.length + reads.length - k.definitions.length - k.reads.length;
+    if (hidden > 0) lines.push(`  … ${hidden} more; raise sites or narrow with path`);
+  }
+  return lines.join("\n");
+}
+
+/** find_log_source's text: each emitting call with its template, and what the placeholders held. */
+function formatLogSources(
+  matches: Array<{ kind: EmitKind; match: string; api: string; level?: string; template: string; values?: string[]; file_path: string; line: number; symbol?: string }>,
+  found: LogSourceMatch[]
+): string {
+  const lines = [`${found.length} possible source(s)${matches.length < found.length ? `; best ${matches.length} shown` : ""}`];
+  for (const m of matches) {
+    const what = m.kind === "log" && m.level ? `log ${m.level}` : m.kind;
+    lines.push("", `${m.file_path}:${m.line}  ${m.api}  (${what}, ${m.match} match)${m.symbol ? `  in ${m.symbol}` : ""}`);
+    lines.push(`  ${JSON.stringify(m.template)}`);
+    if (m.values?.length) lines.push(`  values: ${m.values.map((v) => JSON.stringify(v)).join(", ")}`);
+  }
+  return lines.join("\n");
+}
+
+/** list_markers text: a count per marker word, then markers by group. */
+function formatMarkers(
+  markers: Array<{ marker: string; tag?: string; text: string; file_path: string; line: number; owner?: string; age_days?: number }>,
+  groups: Array<{ key: string; count: number }>,
+  found: Array<{ marker: string }>,
+  groupBy: "file" | "owner"
+): string {
+  const total = found.length;
+  const counts = new Map<string, number>();
+  for (const m of found) counts.set(m.marker, (counts.get(m.marker) ?? 0) + 1);
+  const lines = [
+    `${total} marker(s), grouped by ${groupBy}` +
+      (markers.length < total ? `; showing ${markers.length}` : "") +
+      ` (${[...counts].map(([word, n]) => `${word} ${n}`).join(", ")})`,
+  ];
+
+  let shown = 0;
+  for (const group of groups) {
+    const members = markers.slice(shown, shown + group.count);
+    if (members.length === 0) break;
+    shown += members.length;
+    lines.push("", `${group.key} (${group.count})`);
+    for (const m of members) {
+      const where = groupBy === "owner" ? `${m.file_path}:${m.line}` : `L${m.line}`;
+      const label = `${m.marker}${m.tag ? `(${m.tag})` : ""}`;
+      const who = [groupBy === "file" ? m.owner : null, m.age_days !== undefined ? `${m.age_days}d old` : "not committed"]
+        .filter(Boolean)
+        .join(", ");
+      lines.push(`  ${where} ${label}: ${m.text} — ${who}`);
+    }
+  }
+  return lines.join("\n");
+}
@@ -0,0 +1,115 @@
+/**
+ * MCP resources: index statistics, and the document, section, file,
+ * and symbol templates that complete tool arguments
+ */
+
+import { ResourceTemplate, type McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { ToolContext } from "./context";
+
+/** Register the index-stats resource and the completion templates. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const { store, lazy, notIndexed, logRead } = ctx;
+
+  // ── Resources: expose index stats ──────────────────────────────────
+
+  server.resource("index-stats", "md-tree://stats", async (uri) => {
+    const stats = store.getStats();
+    return {
+      contents: [
+        {
+          uri: uri.href,
+          mimeType: "application/json",
+          text: JSON.stringify(stats, null, 2),
+        },
+      ],
+    };
+  });
+
+  // ── Resource templates: argument completion ────────────────────────
+  //
+  // MCP completion (completion/complete) covers prompt arguments and
+  // resource template variables, not tool arguments. Each template
+  // below mirrors a tool argument, so a client can autocomplete a
+  // doc_id, node_id, file path, or symbol name while the user types,
+  // then either read the resource or pass the value to the tool.
+
+  const json = (uri: URL, value: unknown) => ({
+    contents: [{ uri: uri.href, mimeType: "application/json", text: JSON.stringify(value, null, 2) }],
+  });
+  const variable = (v: string | string[]) => (Array.isArray(v) ? v[0] : v) ?? "";
+
+  // Code IDs contain "/" (code:src/auth.ts:n1), hence {+...}. The
+  // section template is registered first so it wins over {+doc_id}.
+
+  server.registerResource(
+    "section",
+    new ResourceTemplate("md-tree://doc/{+doc_id}/node/{+node_id}", {
+      list: undefined,
+      complete: {
+        doc_id: (value) => store.completeDocIds(value),
+        node_id: (value, context) => store.completeNodeIds(context?.arguments?.doc_id ?? "", value),
+      },
+    }),
+    { description: "Full text of one section (as get_node_content)", mimeType: "text/markdown" },
+    async (uri, vars) => {
+      const doc_id = variable(vars.doc_id);
+      const node_id = variable(vars.node_id);
+      if (lazy) await lazy.ensureDocument(doc_id);
+      const node = store.getNodeContent(doc_id, [node_id])?.nodes[0];
+      if (!node) throw new Error(`Node "${node_id}" not found in "${doc_id}"`);
+      logRead("resource", doc_id, [node_id]);
+      return { contents: [{ uri: uri.href, mimeType: "text/markdown", text: node.content }] };
+    }
+  );
+
+  server.registerResource(
+    "document",
+    new ResourceTemplate("md-tree://doc/{+doc_id}", {
+      list: undefined,
+      complete: { doc_id: (value) => store.completeDocIds(value) },
+    }),
+    { description: "Section outline of a document (as get_tree)", mimeType: "application/json" },
+    async (uri, vars) => {
+      const doc_id = variable(vars.doc_id);
+      if (lazy) await lazy.ensureDocument(doc_id);
+      const tree = store.getTree(doc_id);
+      if (!tree) throw new Error(notIndexed(doc_id) ?? `Document "${doc_id}" not found`);
+      logRead("resource", doc_id, []);
+      return json(uri, tree);
+    }
+  );
+
+  server.registerResource(
+    "file",
+    new ResourceTemplate("md-tree://file/{+path}", {
+      list: undefined,
+      complete: { path: (value) => store.completePaths(value) },
+    }),
+    { description: "Section outline of the document at a file path", mimeType: "application/json" },
+    async (uri, vars) => {
+      const path = variable(vars.path);
+      const doc = store.listDocuments({ path_prefix: path, limit: 100 }).documents.find((d) => d.file_path === path);
+      const tree = doc ? store.getTree(doc.doc_id) : null;
+      if (!tree) throw new Error(`No indexed document at "${path}"`);
+      logRead("resource", tree.doc_id, []);
+      return json(uri, tree);
+    }
+  );
+
+  server.registerResource(
+    "symbol",
+    new ResourceTemplate("md-tree://symbol/{name}", {
+      list: undefined,
+      complete: { name: (value) => store.completeSymbols(value) },
+    }),
+    { description: "Where a code symbol is defined (as find_symbol, exact name)", mimeType: "application/json" },
+    async (uri, vars) => {
+      const name = variable(vars.name);
+      const definitions = store
+        .searchDocuments(name, { filters: { content_type: "code" }, case: "sensitive", word_boundaries: true, limit: 50 })
+        .filter((r) => r.node_title.endsWith(` ${name}`))
+        .map((r) => ({ doc_id: r.doc_id, node_id: r.node_id, title: r.node_title, file_path: r.file_path, line_start: r.line_start, uri: r.uri }));
+      return json(uri, { name, definitions });
+    }
+  );
+}
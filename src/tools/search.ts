@@ -0,0 +1,335 @@
+/**
+ * Search tools: search_documents, multi_search, and feedback
+ *
+ * BM25 search over every collection, one query or several grouped per
+ * query, under the session's defaults. feedback records relevance
+ * judgements for the searches QUERY_LOG logged.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { RerankStatus } from "../reranker";
+import { QueryLogError } from "../query-log";
+import { currentDeadline } from "../deadline";
+import { UriError } from "../uris";
+import { FEEDBACK_OUTPUT, MULTI_SEARCH_OUTPUT, SEARCH_DOCUMENTS_OUTPUT } from "../schemas";
+import { formatBatchResults, formatSearchResults, formatStaleNotice } from "../search-formatter.js";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import {
+  REF_INPUT,
+  INCLUDE_TESTS_INPUT,
+  DEBUG_INPUT,
+  MAX_PER_FILE_INPUT,
+  MAX_PER_PACKAGE_INPUT,
+  READ_ONLY,
+  searchPayload,
+  withProvenance,
+  formatProvenance,
+  refreshedPayload,
+  sessionFooter,
+} from "./shared";
+
+/** Most queries one multi_search call accepts. */
+const MAX_BATCH_QUERIES = 10;
+
+/** The `group` argument of search_documents and multi_search; see DocumentStore.groupBySymbol. */
+const GROUP_INPUT = z
+  .boolean()
+  .optional()
+  .describe("Fold code matches inside one top-level function or type into its best match, with a count of the others, so one file cannot fill the results (default true)");
+
+/** The `comments` argument of search_documents and multi_search; see comments.ts. */
+const COMMENTS_INPUT = z
+  .enum(["include", "exclude", "only"])
+  .optional()
+  .describe('Comment text in code: "include" matches it like code (default COMMENT_MATCHING, include unless configured), "exclude" matches code tokens only, "only" matches nothing but comments');
+
+/** The `comment_languages` argument of search_documents and multi_search. */
+const COMMENT_LANGUAGES_INPUT = z
+  .array(z.string().regex(/^[a-zA-Z]{2}$/))
+  .optional()
+  .describe('Count only code comments in these languages, ISO 639-1 codes (e.g. ["en"]); comments whose language was not detected always count (default COMMENT_LANGUAGES, all unless configured)');
+
+/**
+ * feedback: appends a judgement to the QUERY_LOG file. Never changes
+ * or removes anything; repeating it records the judgement again.
+ */
+const RECORDS_FEEDBACK = {
+  readOnlyHint: false,
+  destructiveHint: false,
+  idempotentHint: false,
+  openWorldHint: false,
+};
+
+/** Register search_documents and multi_search, and feedback when QUERY_LOG is set. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const {
+    store,
+    options,
+    registerTool,
+    session,
+    lazy,
+    coverage,
+    notIndexed,
+    focusNotIndexed,
+    locate,
+    readAt,
+    logSearch,
+    freshSearch,
+    provenanceOf,
+  } = ctx;
+  const queryLog = options.queryLog;
+
+  // ── Tool 2: search_documents ───────────────────────────────────────
+
+  registerTool(
+    "search_documents",
+    {
+      description:
+        "Search across all indexed documents by keyword. Matches against section titles and content. Returns ranked results with snippets. Use filters to narrow by frontmatter facets (e.g., type, category, tags). Query terms are automatically expanded using the glossary if one is configured. Supports boolean operators: AND, OR, NOT, NEAR/n (terms within n tokens), parentheses, and +term / -term (e.g. \"connect AND NOT test\" or \"connect -test\"), and quoted phrases that must match word for word (e.g. \"not connected\").",
+      inputSchema: {
+        query: z
+          .string()
+          .describe("Search query — use specific terms for best results. Uppercase AND/OR/NOT/NEAR/n and +term/-term make it a boolean query; quote a phrase to match it exactly"),
+        doc_id: z
+          .string()
+          .optional()
+          .describe("Limit search to a specific document"),
+        filters: z
+          .record(z.union([z.string(), z.array(z.string())]))
+          .optional()
+          .describe(
+            'Facet filters to narrow results. Keys are frontmatter fields (e.g., "type", "tags", "category"). Values can be a string or array of strings. Example: { "type": "runbook", "tags": ["auth", "jwt"] }'
+          ),
+        shards: z
+          .array(z.string())
+          .optional()
+          .describe(
+            'Limit search to these index shards when the index is sharded (SHARD_DIR). Shard ids are "<collection>/<top-level directory>", e.g. "code/services"'
+          ),
+        limit: z
+          .number()
+          .min(1)
+          .max(50)
+          .optional()
+          .describe("Max results (default 15, or the session preference)"),
+        case: z
+          .enum(["sensitive", "insensitive", "smart"])
+          .optional()
+          .describe('Letter case matching: "insensitive" (default), "sensitive", or "smart" — sensitive only when the query has an uppercase letter'),
+        word_boundaries: z
+          .boolean()
+          .optional()
+          .describe("Match query words only as whole words, so Node does not match NodeInfo or activeNodes (default false)"),
+        group: GROUP_INPUT,
+        max_per_file: MAX_PER_FILE_INPUT,
+        max_per_package: MAX_PER_PACKAGE_INPUT,
+        include_tests: INCLUDE_TESTS_INPUT,
+        comments: COMMENTS_INPUT,
+        comment_languages: COMMENT_LANGUAGES_INPUT,
+        debug: DEBUG_INPUT,
+        rerank: z
+          .boolean()
+          .optional()
+          .describe("Reorder the top candidates with the configured reranker model (default true when RERANK_URL is set; false for keyword order)"),
+        ref: REF_INPUT,
+      },
+      outputSchema: SEARCH_DOCUMENTS_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ query, doc_id, filters, shards, limit, case: caseMode, word_boundaries, group, max_per_file, max_per_package, include_tests, comments, comment_languages, rerank, debug, ref }) =>
+      readAt(ref, async (docs) => {
+        if (lazy && !ref) {
+          if (doc_id) await lazy.ensureDocument(doc_id);
+          else await lazy.expandForQuery(query);
+        }
+        if (shards?.length) filters = { ...filters, shard: shards };
+        // The reranker sees its top k even when fewer are shown
+        const shown = session.limit(limit, 15, 50);
+        const reranker = rerank === false ? undefined : options.reranker;
+        const { found, ...freshness } = await freshSearch(
+          docs,
+          () =>
+            docs.searchDocuments(query, {
+              limit: reranker ? Math.max(shown, reranker.topK) : shown,
+              doc_id,
+              filters,
+              path_prefix: session.get().focus,
+              case: caseMode,
+              word_boundaries,
+              include_tests,
+              comments,
+              comment_languages,
+              group: group ?? true,
+              diversify: { max_per_file, max_per_package },
+              explain: debug,
+            }),
+          (found) => found
+        );
+        let results = found;
+        let reranked: RerankStatus | undefined;
+        if (reranker && found.length > 1) ({ results, rerank: reranked } = await reranker.rerank(query, found));
+        results = results.slice(0, shown);
+        const search_id = logSearch("search_documents", query, results, ref);
+        const provenance = await provenanceOf(docs, results.map((r) => r.doc_id), ref);
+        const payload = {
+          ...withProvenance(searchPayload(docs, query, results, session, freshness), provenance),
+          ...(reranked ? { rerank: reranked } : {}),
+          ...(search_id ? { search_id } : {}),
+        };
+        if (results.length === 0) {
+          const excluded = (doc_id ? notIndexed(doc_id) : null) ?? focusNotIndexed();
+          if (excluded) {
+            return reply(excluded + sessionFooter(session), payload, "not_indexed", excluded);
+          }
+        }
+        const sparse = results.length === 0 && coverage?.describe() ? `\n\n${coverage.describe()}` : "";
+        const text =
+          formatStaleNotice(freshness.stale, freshness.refreshed) +
+          formatSearchResults(results, docs, query) +
+          formatProvenance(provenance, results) +
+          sparse +
+          (reranked?.reason ? `\n\nReranker skipped (${reranked.reason}); results are in keyword order.` : "") +
+          sessionFooter(session);
+        return reply(text, payload);
+      })
+  );
+
+  // ── Tool 8: multi_search ───────────────────────────────────────────
+
+  registerTool(
+    "multi_search",
+    {
+      description:
+        `Run up to ${MAX_BATCH_QUERIES} searches in one call and get the results grouped by query. Use this when a task breaks down into several independent lookups (e.g. "where is rate limiting configured", "how are retries handled", "who calls the billing client"). Each query accepts the same syntax as search_documents, and filters, case, word_boundaries, include_tests, comments, and comment_languages apply to every query. Results are compact ranked lists without inlined content; follow up with get_node_content for the sections you need.`,
+      inputSchema: {
+        queries: z
+          .array(z.string().min(1))
+          .min(1)
+          .max(MAX_BATCH_QUERIES)
+          .describe(`Search queries, answered in order (at most ${MAX_BATCH_QUERIES})`),
+        filters: z
+          .record(z.union([z.string(), z.array(z.string())]))
+          .optional()
+          .describe('Facet filters applied to every query, e.g. { "content_type": "code" }'),
+        limit: z
+          .number()
+          .min(1)
+          .max(20)
+          .optional()
+          .describe("Max results per query (default 5)"),
+        case: z
+          .enum(["sensitive", "insensitive", "smart"])
+          .optional()
+          .describe('Letter case matching for every query: "insensitive" (default), "sensitive", or "smart"'),
+        word_boundaries: z
+          .boolean()
+          .optional()
+          .describe("Match query words only as whole words (default false)"),
+        group: GROUP_INPUT,
+        max_per_file: MAX_PER_FILE_INPUT,
+        max_per_package: MAX_PER_PACKAGE_INPUT,
+        include_tests: INCLUDE_TESTS_INPUT,
+        comments: COMMENTS_INPUT,
+        comment_languages: COMMENT_LANGUAGES_INPUT,
+        debug: DEBUG_INPUT,
+        ref: REF_INPUT,
+      },
+      outputSchema: MULTI_SEARCH_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ queries, filters, limit, case: caseMode, word_boundaries, group, max_per_file, max_per_package, include_tests, comments, comment_languages, debug, ref }) =>
+      readAt(ref, async (docs) => {
+        if (lazy && !ref) {
+          for (const query of queries) await lazy.expandForQuery(query);
+        }
+        const { found: groups, ...freshness } = await freshSearch(
+          docs,
+          () =>
+            queries.map((query) => ({
+              query,
+              // Past the deadline, the remaining queries are left unanswered
+              results: currentDeadline()?.expired() ? [] : docs.searchDocuments(query, {
+                limit: limit ?? 5,
+                filters,
+                path_prefix: session.get().focus,
+                case: caseMode,
+                word_boundaries,
+                include_tests,
+                comments,
+                comment_languages,
+                group: group ?? true,
+                diversify: { max_per_file, max_per_package },
+                explain: debug,
+              }),
+            })),
+          (found) => found.flatMap((g) => g.results)
+        );
+
+        const excluded = groups.every((g) => g.results.length === 0) ? focusNotIndexed() : null;
+        const all = groups.flatMap((g) => g.results);
+        const provenance = await provenanceOf(docs, all.map((r) => r.doc_id), ref);
+        const payload = {
+          groups: groups.map(({ query, results }) => {
+            const { results: hits, suggestions } = withProvenance(searchPayload(docs, query, results, session, freshness), provenance);
+            const search_id = logSearch("multi_search", query, results, ref);
+            return { query, results: hits, suggestions, ...(search_id ? { search_id } : {}) };
+          }),
+          ...refreshedPayload(freshness.refreshed),
+          validating: docs.isValidating(),
+          preferences: session.get(),
+        };
+        if (excluded) {
+          return reply(excluded + sessionFooter(session), payload, "not_indexed", excluded);
+        }
+        const notice = formatStaleNotice(freshness.stale, freshness.refreshed);
+        return reply(notice + formatBatchResults(groups, docs) + formatProvenance(provenance, all) + sessionFooter(session), payload);
+      })
+  );
+
+  // ── Tool 33: feedback ──────────────────────────────────────────────
+
+  if (queryLog) {
+    registerTool(
+      "feedback",
+      {
+        description:
+          "Tell the server whether a search result was what you needed, or name a section a search should have found. Judgements go to the local query log (QUERY_LOG) that ranking is tuned against; nothing is sent anywhere. By default the judgement applies to this session's latest search that returned the section, else its latest search; pass search_id (from a search result) to pick one.",
+        inputSchema: {
+          search_id: z.string().optional().describe("The search being judged, from its search_id (default: this session's latest search that returned the section)"),
+          doc_id: z.string().optional().describe("Document of the result"),
+          node_id: z.string().optional().describe("Section of the result; omit to judge the document as a whole"),
+          uri: z
+            .string()
+            .optional()
+            .describe("Instead of doc_id and node_id: a uri from an earlier result"),
+          relevant: z.boolean().describe("true: this answered the query; false: it did not"),
+          note: z.string().max(500).optional().describe("Why, in a few words; kept in the log"),
+        },
+        outputSchema: FEEDBACK_OUTPUT,
+        annotations: RECORDS_FEEDBACK,
+      },
+      async ({ search_id, doc_id: id, node_id: nodeId, uri, relevant, note }) => {
+        const at = locate(store, id, uri);
+        if (at instanceof UriError) return errorResult(at);
+        if (uri !== undefined && nodeId !== undefined) return errorResult(new UriError("pass node_id or uri, not both"));
+        const doc_id = at.doc_id;
+        const node_id = nodeId ?? at.node?.node_id;
+        let recorded: { search_id: string; rank: number | null };
+        try {
+          recorded = queryLog.feedback(session, { search_id, doc_id, node_id, relevant, note });
+        } catch (err) {
+          if (err instanceof QueryLogError) return errorResult(err);
+          throw err;
+        }
+        const what = node_id ? `${doc_id} [${node_id}]` : doc_id;
+        const position = recorded.rank === null ? "not among its results" : `result ${recorded.rank}`;
+        return reply(
+          `Recorded: ${what} ${relevant ? "relevant" : "not relevant"} for search ${recorded.search_id} (${position}).`,
+          { recorded: true, ...recorded, doc_id, ...(node_id ? { node_id } : {}), relevant }
+        );
+      }
+    );
+  }
+}
@@ -0,0 +1,187 @@
+/**
+ * Tool arguments, annotations, and answer helpers that several tool
+ * modules share
+ */
+
+import { z } from "zod";
+import type { DocumentStore } from "../store";
+import type { SearchResult, TreeNode } from "../types";
+import type { Provenance } from "../licenses";
+import type { StaleFile } from "../staleness";
+import { SessionState } from "../session";
+import { locationUri } from "../uris";
+
+/** The `ref` argument of the search and read tools. */
+export const REF_INPUT = z
+  .string()
+  .optional()
+  .describe('Git commit, tag, or branch to answer from instead of the working tree, e.g. "v1.4.0" (read from git; nothing is checked out)');
+
+/** The `include_tests` argument of the search and reference tools; see test-paths.ts. */
+export const INCLUDE_TESTS_INPUT = z
+  .union([z.boolean(), z.literal("only")])
+  .optional()
+  .describe('Test files, fixtures, and mocks (*_test.go, *.spec.ts, tests/, __mocks__/, ...): true counts them (default), false leaves them out, "only" keeps nothing else');
+
+/** The `debug` argument of the search tools: per-result score breakdowns (ScoreBreakdown). */
+export const DEBUG_INPUT = z
+  .boolean()
+  .optional()
+  .describe("Explain the ranking: give each result its score breakdown (BM25 per term, proximity and all-terms bonuses, collection, path, recency, generated, popularity, and coverage multipliers) as `explain` (default false)");
+
+/** The `max_per_file` argument of the search tools; see DocumentStore.diversify. */
+export const MAX_PER_FILE_INPUT = z
+  .number()
+  .int()
+  .min(0)
+  .optional()
+  .describe("At most this many results from one file, so the rest of the codebase gets the slots; 0 = no cap (default MAX_PER_FILE, off unless configured)");
+
+/** The `max_per_package` argument of the search tools. */
+export const MAX_PER_PACKAGE_INPUT = z
+  .number()
+  .int()
+  .min(0)
+  .optional()
+  .describe("At most this many results from one package (a directory of one collection); 0 = no cap (default MAX_PER_PACKAGE, off unless configured)");
+
+/**
+ * MCP behavior hints, so clients can auto-approve navigation and ask
+ * before anything touches disk. No tool reaches outside the indexed
+ * roots. Lazy expansion parses files on demand but changes nothing the
+ * caller can observe beyond what later lookups return.
+ */
+export const READ_ONLY = {
+  readOnlyHint: true,
+  destructiveHint: false,
+  idempotentHint: true,
+  openWorldHint: false,
+};
+
+/**
+ * write_wiki_entry: creates files, and with overwrite=true replaces
+ * them. Repeating a write is not a no-op — it fails unless overwrite
+ * is set. structural_replace rewrites code files in place.
+ */
+export const WRITES_FILES = {
+  readOnlyHint: false,
+  destructiveHint: true,
+  idempotentHint: false,
+  openWorldHint: false,
+};
+
+/** Structured payload shared by search_documents and find_symbol. */
+export function searchPayload(
+  store: DocumentStore,
+  query: string,
+  results: SearchResult[],
+  session: SessionState,
+  freshness: { stale: StaleFile[]; refreshed: StaleFile[] } = { stale: [], refreshed: [] }
+): Record<string, unknown> {
+  const stale = new Map(freshness.stale.map((f) => [f.doc_id, f.reason]));
+  return {
+    query,
+    results: results.map((r) => ({
+      doc_id: r.doc_id,
+      doc_title: r.doc_title,
+      file_path: r.file_path,
+      node_id: r.node_id,
+      node_title: r.node_title,
+      level: r.level,
+      score: r.score,
+      snippet: r.snippet,
+      snippet_highlights: r.snippet_highlights,
+      content_matches: r.content_matches,
+      line_start: r.line_start,
+      line_end: r.line_end,
+      ...(r.cell ? { cell: r.cell } : {}),
+      uri: r.uri,
+      matched_terms: r.matched_terms,
+      collection: r.collection,
+      facets: r.facets,
+      ...(stale.has(r.doc_id) ? { stale: stale.get(r.doc_id) } : {}),
+      ...(r.group ? { group: r.group } : {}),
+      ...(r.explain ? { explain: r.explain } : {}),
+      ...(r.comment_language ? { comment_language: r.comment_language } : {}),
+    })),
+    ...refreshedPayload(freshness.refreshed),
+    suggestions: results.length === 0 ? store.suggest(query) : [],
+    validating: store.isValidating(),
+    preferences: session.get(),
+  };
+}
+
+/** Adds `provenance` to the results of a search payload whose files have one. */
+export function withProvenance<P extends Record<string, unknown>>(payload: P, provenance: Map<string, Provenance>): P {
+  if (provenance.size === 0) return payload;
+  const results = payload.results as Array<{ doc_id: string }>;
+  return { ...payload, results: results.map((r) => (provenance.has(r.doc_id) ? { ...r, provenance: provenance.get(r.doc_id) } : r)) };
+}
+
+/** A provenance as one phrase: "GPL-3.0, strong copyleft, vendored (from vendor/x/LICENSE)". */
+export function describeProvenance(p: Provenance): string {
+  const parts = [p.license ?? "no license found"];
+  if (p.copyleft) parts.push(`${p.copyleft} copyleft`);
+  if (p.origin !== "project") parts.push(p.module ? `${p.origin} ${p.module}` : p.origin.replace("_", " "));
+  const from = p.source === "header" ? "file header" : p.license_files?.join(", ");
+  return parts.join(", ") + (from ? ` (from ${from})` : "");
+}
+
+/** The license line after a section or excerpt, or "" when nothing is known. */
+export function formatProvenanceLine(p: Provenance | undefined): string {
+  return p && (p.license || p.origin !== "project") ? `\n\nLicense: ${describeProvenance(p)}` : "";
+}
+
+/**
+ * Footer naming the license of the files of `results`, copyleft first;
+ * "" when none has a license or comes from outside the project.
+ */
+export function formatProvenance(provenance: Map<string, Provenance>, results: Array<{ doc_id: string; file_path: string }>): string {
+  const groups = new Map<string, { p: Provenance; paths: string[] }>();
+  for (const { doc_id, file_path } of results) {
+    const p = provenance.get(doc_id);
+    if (!p) continue;
+    const key = describeProvenance(p);
+    const group = groups.get(key) ?? { p, paths: [] };
+    if (!group.paths.includes(file_path)) group.paths.push(file_path);
+    groups.set(key, group);
+  }
+  const shown = [...groups.entries()].filter(([, g]) => g.p.license || g.p.origin !== "project");
+  if (shown.length === 0) return "";
+  const strength = (p: Provenance) => ["weak", "strong", "network"].indexOf(p.copyleft ?? "") + 1;
+  const lines = shown
+    .sort(([, a], [, b]) => strength(b.p) - strength(a.p))
+    .map(([key, g]) => {
+      const paths = g.paths.slice(0, 3).join(", ") + (g.paths.length > 3 ? ` (+${g.paths.length - 3} more)` : "");
+      return `  ${key}: ${paths}`;
+    });
+  return `\n\nLicenses:\n${lines.join("\n")}`;
+}
+
+/** The `refreshed` field: files re-indexed because they were stale. */
+export function refreshedPayload(refreshed: StaleFile[]): { refreshed?: string[] } {
+  return refreshed.length > 0 ? { refreshed: refreshed.map((f) => f.file_path) } : {};
+}
+
+/** A node's full content, and the location URI other tools take back. */
+export function contentNode(store: DocumentStore, doc_id: string, n: TreeNode) {
+  return {
+    node_id: n.node_id,
+    title: n.title,
+    level: n.level,
+    parent_id: n.parent_id,
+    children: n.children,
+    content: n.content,
+    word_count: n.word_count,
+    line_start: n.line_start,
+    line_end: n.line_end,
+    ...(n.cell ? { cell: n.cell } : {}),
+    uri: locationUri(store, doc_id, n.line_start, n.line_end, n.cell),
+  };
+}
+
+/** Trailer noting which session defaults shaped a result, if any. */
+export function sessionFooter(session: SessionState): string {
+  const current = session.describe();
+  return current ? `\n\n(Session preferences: ${current})` : "";
+}
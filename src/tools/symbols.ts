@@ -0,0 +1,532 @@
+/**
+ * Symbol tools: find_symbol, breadcrumbs, next_symbol, previous_symbol,
+ * and peek_definitions
+ *
+ * Code-aware lookups of definitions and the scopes around them.
+ * find_symbol asks the user to pick among near-tied matches when the
+ * client supports elicitation.
+ */
+
+import { z } from "zod";
+import type { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import type { DocumentStore } from "../store";
+import type { DocumentMeta, SearchResult, TreeNode } from "../types";
+import type { StdlibSymbol } from "../go-stdlib";
+import type { GeneratorTarget } from "../generator-links";
+import { DEFAULT_BODY_LINES, DEFAULT_PER_NAME, MAX_PEEK_NAMES, type PeekResult } from "../peek";
+import { documentAt, locationUri, parseUri, UriError } from "../uris";
+import type { Breadcrumb } from "../breadcrumbs";
+import { BREADCRUMBS_OUTPUT, FIND_SYMBOL_OUTPUT, PEEK_DEFINITIONS_OUTPUT, STEP_SYMBOL_OUTPUT } from "../schemas";
+import { buildExplainLine, buildMatchLine, didYouMean, VALIDATING_NOTICE, formatStaleNotice } from "../search-formatter.js";
+import { reply, errorResult } from "../tools";
+import type { ToolContext } from "./context";
+import {
+  REF_INPUT,
+  INCLUDE_TESTS_INPUT,
+  DEBUG_INPUT,
+  MAX_PER_FILE_INPUT,
+  MAX_PER_PACKAGE_INPUT,
+  READ_ONLY,
+  searchPayload,
+  withProvenance,
+  formatProvenance,
+  contentNode,
+  sessionFooter,
+} from "./shared";
+
+/** Register find_symbol, and the symbol navigation tools whose index is configured. */
+export function register(server: McpServer, ctx: ToolContext): void {
+  const {
+    store,
+    options,
+    registerTool,
+    session,
+    lazy,
+    notIndexed,
+    focusNotIndexed,
+    readAt,
+    logSearch,
+    logRead,
+    freshSearch,
+    provenanceOf,
+  } = ctx;
+
+  // ── Tool 6: find_symbol ────────────────────────────────────────────
+
+  registerTool(
+    "find_symbol",
+    {
+      description:
+        "Search for code symbols (classes, functions, interfaces, types, methods) across indexed source files. Filters by symbol kind and language. Returns matching symbols with their signatures and file locations. When several symbols match about equally well and the client supports elicitation, the user is asked which one was meant. Requires CODE_ROOT to be configured.",
+      inputSchema: {
+        query: z
+          .string()
+          .describe("Symbol name or keyword to search for. Uppercase AND/OR/NOT/NEAR/n and +term/-term make it a boolean query (e.g. \"connect -test\" or \"Connect NEAR/5 ctx\")"),
+        kind: z
+          .enum(["class", "interface", "function", "method", "type", "enum", "variable"])
+          .optional()
+          .describe("Filter by symbol kind"),
+        language: z
+          .string()
+          .optional()
+          .describe("Filter by programming language (e.g., 'typescript', 'python', 'go')"),
+        limit: z
+          .number()
+          .min(1)
+          .max(50)
+          .optional()
+          .describe("Max results (default 15, or the session preference)"),
+        case: z
+          .enum(["sensitive", "insensitive", "smart"])
+          .optional()
+          .describe('Letter case matching: "insensitive" (default), "sensitive", or "smart" — sensitive only when the query has an uppercase letter'),
+        word_boundaries: z
+          .boolean()
+          .optional()
+          .describe("Match query words only as whole words, so Node does not match NodeInfo or activeNodes (default false)"),
+        max_per_file: MAX_PER_FILE_INPUT,
+        max_per_package: MAX_PER_PACKAGE_INPUT,
+        include_tests: INCLUDE_TESTS_INPUT,
+        debug: DEBUG_INPUT,
+        ref: REF_INPUT,
+      },
+      outputSchema: FIND_SYMBOL_OUTPUT,
+      annotations: READ_ONLY,
+    },
+    async ({ query, kind, language, limit, case: caseMode, word_boundaries, max_per_file, max_per_package, include_tests, debug, ref }) =>
+      readAt(ref, async (docs) => {
+        // Build facet filters for code-specific search; notebook cells hold code too
+        const filters: Record<string, string | string[]> = {
+          content_type: ["code", "notebook"],
+        };
+        if (kind) filters["symbol_kind"] = kind;
+        const languages = language ?? session.get().languages;
+        if (languages) filters["language"] = languages;
+
+        if (lazy && !ref) await lazy.expandForQuery(query);
+        const { found: results, ...freshness } = await freshSearch(
+          docs,
+          () =>
+            docs.searchDocuments(query, {
+              limit: session.limit(limit, 15, 50),
+              filters,
+              path_prefix: session.get().focus,
+              case: caseMode,
+              word_boundaries,
+              include_tests,
+              diversify: { max_per_file, max_per_package },
+              explain: debug,
+            }),
+          (found) => found
+        );
+
+        // A miss is logged now; hits once disambiguation settles what is shown
+        const missed = results.length === 0 ? logSearch("find_symbol", query, results, ref) : undefined;
+        const missId = missed ? { search_id: missed } : {};
+
+        // Package-qualified Go names (sync.RWMutex) also resolve against GOROOT
+        const stdlib =
+          options.stdlib && !ref && /^[a-z][\w/.-]*\.[A-Z]\w*(?:\.\w+)?$/.test(query) ? await options.stdlib.lookup(query) : [];
+        if (results.length === 0 && stdlib.length > 0) {
+          return reply(`${formatStdlib(query, stdlib)}${sessionFooter(session)}`, {
+            ...searchPayload(docs, query, results, session, freshness),
+            stdlib,
+            ...missId,
+          });
+        }
+
+        if (results.length === 0) {
+          const payload = { ...searchPayload(docs, query, results, session), ...missId };
+          const excluded = focusNotIndexed();
+          return reply(
+            excluded ? excluded + sessionFooter(session) : `No symbols found for "${query}"${kind ? ` (kind: ${kind})` : ""}${languages ? ` (language: ${[languages].flat().join(", ")})` : ""}.${didYouMean(docs, query)} Make sure CODE_ROOT is configured and code files are indexed.${sessionFooter(session)}`,
+            payload,
+            excluded ? "not_indexed" : "ok",
+            excluded ?? undefined
+          );
+        }
+
+        // Several equally plausible symbols: ask the user which one they
+        // meant rather than confidently presenting the wrong one first
+        const { chosen, disambiguation } = await disambiguate(server, query, results);
+        const shown = chosen ? [chosen] : results;
+        const search_id = logSearch("find_symbol", query, shown, ref);
+
+        // A symbol in generated code also points at the generator's input
+        const inputs = new Map<string, GeneratorTarget>();
+        if (options.generatorLinks && !ref) {
+          for (const r of shown) {
+            if (!docs.getDocMeta(r.doc_id)?.generated_from) continue;
+            const target = await options.generatorLinks.target(docs, r.doc_id, r.node_id);
+            if (target) inputs.set(r.node_id, target);
+          }
+        }
+        const provenance = await provenanceOf(docs, shown.map((r) => r.doc_id), ref);
+        const payload = {
+          ...withProvenance(withGeneratorInputs(docs, searchPayload(docs, query, shown, session, freshness), inputs), provenance),
+          ...(disambiguation ? { disambiguation } : {}),
+          ...(stdlib.length ? { stdlib } : {}),
+          ...(search_id ? { search_id } : {}),
+        };
+
+        const formatted = shown
+          .map(
+            (r, i) =>
+              `${i + 1}. ${r.node_title} [${r.node_id}]\n   File: ${r.file_path}${r.cell ? ` (cell ${r.cell})` : ""}\n   URI: ${r.uri}\n   Score: ${r.score.toFixed(1)}${buildExplainLine(r)}\n   Signature: ${r.snippet}${buildMatchLine(r)}${formatGeneratorInput(docs, inputs.get(r.node_id))}`
+          )
+          .join("\n\n");
+
+        const notice =
+          formatStaleNotice(freshness.stale, freshness.refreshed) +
+          (docs.isValidating() ? `${VALIDATING_NOTICE}\n\n` : "");
+        const heading = chosen
+          ? `Symbol search for "${query}" (picked by the user from ${disambiguation!.candidates} candidates)`
+          : `Symbol search for "${query}" (${results.length} matches)`;
+
+        return reply(
+          `${notice}${heading}:\n\n${formatted}${formatProvenance(provenance, shown)}\n\nUse get_tree(doc_id) to see the full file structure, or get_node_content(doc_id, [node_id]) to read a symbol's source code.${stdlib.length ? `\n\n${formatStdlib(query, stdlib)}` : ""}${sessionFooter(session)}`,
+          payload
+        );
+      })
+  );
+
+  // ── Tool 22: breadcrumbs ───────────────────────────────────────────
+
+  const breadcrumbs = options.breadcrumbs;
+  if (breadcrumbs) {
+    registerTool(
+      "breadcrumbs",
+      {
+        description:
+          "Show where a line lives: the chain of scopes around it, outermost first — package or module, then type, method, or markdown heading, then the if / for / switch / try blocks and closures inside the method. Use it to explain a search hit or a stack-trace line without reading the whole file.",
+        inputSchema: {
+          uri: z
+            .string()
+            .optional()
+            .describe("A uri from an earlier result; the first line it names is used"),
+          path: z.string().optional().describe("Instead of uri: a file path as results report it"),
+          line: z.number().int().min(1).optional().describe("With path: the line, as results report it"),
+          column: z.number().int().min(1).optional().describe("1-based column on the line, to tell blocks that open or close there apart"),
+          collection: z.string().optional().describe("With path: the collection, when several index the path"),
+        },
+        outputSchema: BREADCRUMBS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ uri, path, line, column, collection }) => {
+        let doc: DocumentMeta, at: number, cell: number | undefined;
+        try {
+          if ((uri === undefined) === (path === undefined)) throw new UriError("pass uri, or path and line");
+          const location = uri !== undefined ? parseUri(uri) : { path: path!, collection, line_start: line };
+          if (location.line_start === undefined) {
+            throw new UriError(uri !== undefined ? `${uri} names a whole file; add #L<line>` : "pass line with path");
+          }
+          doc = documentAt(store, location);
+          at = location.line_start;
+          cell = location.cell;
+        } catch (err) {
+          if (err instanceof UriError) return errorResult(err);
+          throw err;
+        }
+        const crumb = await breadcrumbs.at(store, doc, at, column, cell);
+        const payload = {
+          ...crumb,
+          uri: locationUri(store, doc.doc_id, at, at, cell)!,
+          scopes: crumb.scopes.map((s) =>
+            s.line_start === undefined ? s : { ...s, uri: locationUri(store, doc.doc_id, s.line_start, s.line_end, cell) }
+          ),
+        };
+        return reply(formatBreadcrumb(crumb), payload);
+      }
+    );
+  }
+
+  // ── Tools 23–24: next_symbol, previous_symbol ──────────────────────
+
+  if (options.symbolNav) {
+    for (const direction of ["next", "previous"] as const) {
+      const name = `${direction}_symbol`;
+      const after = direction === "next" ? "after" : "before";
+      registerTool(
+        name,
+        {
+          description: `Step to the symbol(s) ${after} a given symbol or line in the same file, with their content: the ${direction} method of the same class, the ${direction} top-level function, or the ${direction} section of a document. Use it to walk a file's definitions in order without fetching the outline again at every step; pass the last result's node_id to keep going.`,
+          inputSchema: {
+            doc_id: z.string().optional().describe(`Document ID; with node_id or line, or alone to start at the ${direction === "next" ? "top" : "bottom"}`),
+            node_id: z.string().optional().describe("The symbol to step from"),
+            line: z.number().int().min(1).optional().describe("Instead of node_id: a line; between symbols, steps from there"),
+            uri: z
+              .string()
+              .optional()
+              .describe("Instead of doc_id: a uri from an earlier result; its first line is the starting point"),
+            scope: z
+              .enum(["siblings", "file"])
+              .default("siblings")
+              .describe('"siblings": symbols with the same parent, e.g. methods of one class (default); "file": every symbol in file order, descending into classes'),
+            count: z.number().int().min(1).max(50).default(1).describe("Symbols to return"),
+            ref: REF_INPUT,
+          },
+          outputSchema: STEP_SYMBOL_OUTPUT,
+          annotations: READ_ONLY,
+        },
+        async ({ doc_id: id, node_id, line, uri, scope, count, ref }) =>
+          readAt(ref, async (docs) => {
+            // Without a node or line, start from the top (or the bottom)
+            const edge = direction === "next" ? { line: 0 } : { line: Infinity, cell: Infinity };
+            let doc_id: string;
+            let at: { node_id: string } | { line: number; cell?: number };
+            try {
+              if ((uri === undefined) === (id === undefined)) throw new UriError("pass doc_id or uri");
+              if (node_id !== undefined && (line !== undefined || uri !== undefined)) {
+                throw new UriError("pass node_id, line, or uri, only one");
+              }
+              if (uri !== undefined) {
+                const location = parseUri(uri);
+                doc_id = documentAt(docs, location).doc_id;
+                at = location.line_start === undefined && !location.cell ? edge : { line: location.line_start ?? 1, cell: location.cell };
+              } else {
+                doc_id = id!;
+                at = node_id !== undefined ? { node_id } : line !== undefined ? { line } : edge;
+              }
+            } catch (err) {
+              if (err instanceof UriError) return errorResult(err);
+              throw err;
+            }
+            if (lazy && !ref) await lazy.ensureDocument(doc_id);
+            const step = docs.stepNodes(doc_id, at, direction, { scope, count });
+            if (!step) {
+              const excluded = notIndexed(doc_id);
+              const text = excluded ?? `Document "${doc_id}" not found${node_id ? ` or node "${node_id}" doesn't exist` : ""}.`;
+              return reply(
+                text,
+                { doc_id, direction, scope, from: null, symbols: [], more: false },
+                excluded ? "not_indexed" : "not_found",
+                text
+              );
+            }
+            const from = step.from && {
+              node_id: step.from.node_id,
+              title: step.from.title,
+              line_start: step.from.line_start,
+              line_end: step.from.line_end,
+              ...(step.from.cell ? { cell: step.from.cell } : {}),
+              uri: locationUri(docs, doc_id, step.from.line_start, step.from.line_end, step.from.cell),
+            };
+            return reply(formatSteps(direction, step, docs.getDocMeta(doc_id)!.file_path), {
+              doc_id,
+              direction,
+              scope,
+              from,
+              symbols: step.nodes.map((n) => contentNode(docs, doc_id, n)),
+              more: step.more,
+            });
+          })
+      );
+    }
+  }
+
+  // ── Tool 37: peek_definitions ─────────────────────────────────────
+
+  const peek = options.peek;
+  if (peek) {
+    registerTool(
+      "peek_definitions",
+      {
+        description:
+          'Resolve many code symbol names in one call. For each name returns its definitions with signature, doc comment, and the first lines of the body — enough to understand an identifier without reading its file. Use this when a file you are reading mentions several unfamiliar identifiers ("NodeInfo", "dialBackoff", "Pool.Put"); use find_symbol to search by keyword, and get_node_content for a whole body.',
+        inputSchema: {
+          names: z
+            .array(z.string().min(1))
+            .min(1)
+            .max(MAX_PEEK_NAMES)
+            .describe('Exact symbol names; "Type.method" pins a method to its type'),
+          body_lines: z
+            .number()
+            .int()
+            .min(1)
+            .max(100)
+            .default(DEFAULT_BODY_LINES)
+            .describe(`Lines of each definition to include, signature first (default ${DEFAULT_BODY_LINES})`),
+          per_name: z
+            .number()
+            .int()
+            .min(1)
+            .max(10)
+            .default(DEFAULT_PER_NAME)
+            .describe(`Definitions returned per name when several match (default ${DEFAULT_PER_NAME})`),
+          language: z
+            .string()
+            .optional()
+            .describe("Only definitions in this language (default: the session's languages)"),
+        },
+        outputSchema: PEEK_DEFINITIONS_OUTPUT,
+        annotations: READ_ONLY,
+      },
+      async ({ names, body_lines, per_name, language }) => {
+        if (lazy) await lazy.expandForQuery(names.join(" "));
+        const results = await peek.peek(store, names, { body_lines, per_name, languages: language ?? session.get().languages });
+        for (const r of results) for (const d of r.definitions) logRead("peek_definitions", d.doc_id, [d.node_id]);
+        const missing = results.filter((r) => r.definitions.length === 0).map((r) => r.name);
+        const payload = {
+          results: results.map((r) => ({
+            ...r,
+            definitions: r.definitions.map((d) => ({ ...d, uri: locationUri(store, d.doc_id, d.line_start, d.line_end) })),
+          })),
+          missing,
+        };
+        return reply(formatPeek(results), payload, missing.length === results.length ? "not_found" : "ok");
+      }
+    );
+  }
+}
+
+/** Candidates scoring within this fraction of the best are a near-tie. */
+const AMBIGUITY_RATIO = 0.9;
+
+/** Most candidates offered in one disambiguation question. */
+const MAX_CHOICES = 5;
+
+/**
+ * The near-tied leaders of a symbol search (score-sorted results), or
+ * an empty list when one result clearly wins.
+ */
+export function ambiguousCandidates(results: SearchResult[]): SearchResult[] {
+  if (results.length < 2) return [];
+  const cutoff = results[0].score * AMBIGUITY_RATIO;
+  const close = results.filter((r) => r.score >= cutoff);
+  return close.length >= 2 ? close.slice(0, MAX_CHOICES) : [];
+}
+
+/**
+ * Ask the user, via MCP elicitation, which of several near-tied symbols
+ * they meant. Returns nothing when the results are not ambiguous, the
+ * client cannot elicit, or the request fails; `chosen` is set only when
+ * the user accepted with a pick.
+ */
+async function disambiguate(
+  server: McpServer,
+  query: string,
+  results: SearchResult[]
+): Promise<{
+  chosen?: SearchResult;
+  disambiguation?: { candidates: number; action: "accept" | "decline" | "cancel"; chosen?: string };
+}> {
+  const candidates = ambiguousCandidates(results);
+  if (candidates.length === 0 || !server.server.getClientCapabilities()?.elicitation) return {};
+
+  try {
+    const answer = await server.server.elicitInput({
+      message: `"${query}" matches ${candidates.length} symbols about equally well. Which one did you mean?`,
+      requestedSchema: {
+        type: "object",
+        properties: {
+          symbol: {
+            type: "string",
+            title: "Symbol",
+            enum: candidates.map((c) => c.node_id),
+            enumNames: candidates.map((c) => `${c.node_title} — ${c.file_path}:${c.line_start}`),
+          },
+        },
+        required: ["symbol"],
+      },
+    });
+    const picked = answer.action === "accept" ? answer.content?.symbol : undefined;
+    const chosen = candidates.find((c) => c.node_id === picked);
+    return {
+      chosen,
+      disambiguation: {
+        candidates: candidates.length,
+        action: answer.action,
+        ...(chosen ? { chosen: chosen.node_id } : {}),
+      },
+    };
+  } catch {
+    // Elicitation failed (timeout, client error): answer with every match
+    return {};
+  }
+}
+
+/** Adds `generator_input` to the results of a search payload that have one. */
+function withGeneratorInputs(
+  store: DocumentStore,
+  payload: Record<string, unknown>,
+  inputs: Map<string, GeneratorTarget>
+): Record<string, unknown> {
+  if (inputs.size === 0) return payload;
+  const results = payload.results as Array<{ node_id: string }>;
+  return {
+    ...payload,
+    results: results.map((r) => {
+      const target = inputs.get(r.node_id);
+      if (!target) return r;
+      const uri = target.doc_id ? locationUri(store, target.doc_id, target.line) : undefined;
+      return { ...r, generator_input: { ...target, ...(uri ? { uri } : {}) } };
+    }),
+  };
+}
+
+/** The "Generated from" line of a find_symbol result, or "". */
+function formatGeneratorInput(store: DocumentStore, target: GeneratorTarget | undefined): string {
+  if (!target) return "";
+  const at = `${target.file_path}${target.line ? `:${target.line}` : ""}`;
+  const uri = target.doc_id ? locationUri(store, target.doc_id, target.line) : undefined;
+  return `\n   Generated by ${target.generator} from ${at}${target.symbol ? ` (${target.symbol})` : ""}${
+    target.found ? "" : ", not found under the collection root"
+  }${uri ? `\n   Generator input: ${uri}` : ""}`;
+}
+
+function formatSteps(
+  direction: "next" | "previous",
+  step: { from: TreeNode | null; nodes: TreeNode[]; more: boolean },
+  file_path: string
+): string {
+  const where = (n: TreeNode) => `${file_path}${n.cell ? ` cell ${n.cell}` : ""}:${n.line_start}-${n.line_end}`;
+  const origin = step.from ? `${step.from.title} (${where(step.from)})` : file_path;
+  const after = direction === "next" ? "after" : "before";
+  if (step.nodes.length === 0) return `No symbol ${after} ${origin}.`;
+  const lines = [`${step.nodes.length} symbol(s) ${after} ${origin}${step.more ? "; more follow" : ""}:`];
+  for (const n of step.nodes) {
+    lines.push("", `${"#".repeat(n.level)} ${n.title} [${n.node_id}] ${where(n)}`, n.content || "(empty)");
+  }
+  return lines.join("\n");
+}
+
+function formatBreadcrumb(crumb: Breadcrumb): string {
+  const where = `${crumb.file_path}${crumb.cell ? ` cell ${crumb.cell}` : ""}:${crumb.line}${crumb.column ? `:${crumb.column}` : ""}`;
+  if (crumb.scopes.length === 0) return `${where} is in no indexed scope.`;
+  const lines = [`${where}`, `  ${crumb.trail}`, ""];
+  for (const s of crumb.scopes) {
+    const span = s.line_start === undefined ? "" : `  (lines ${s.line_start}-${s.line_end})`;
+    lines.push(`  ${s.kind.padEnd(9)} ${s.kind === "symbol" ? `${s.symbol_kind} ${s.name}` : s.name}${span}`);
+  }
+  return lines.join("\n");
+}
+
+/** peek_definitions' text: per name, each definition's location, doc, and excerpt. */
+function formatPeek(results: PeekResult[]): string {
+  const blocks = results.map((r) => {
+    if (r.definitions.length === 0) return `━━━ ${r.name} ━━━
+No definition found.`;
+    const lines = [`━━━ ${r.name} ━━━`];
+    for (const d of r.definitions) {
+      lines.push(`${d.title} — ${d.file_path}:${d.line_start}-${d.line_end} [${d.node_id}]`);
+      if (d.doc) lines.push(...d.doc.split("\n").map((l) => `  │ ${l}`));
+      lines.push(...d.excerpt.split("\n").map((l) => `    ${l}`));
+      if (d.excerpt_truncated) lines.push(`    … (${d.line_end - d.line_start + 1} lines in all)`);
+    }
+    if (r.more) lines.push(`${r.more} more definition(s); raise per_name or narrow with language.`);
+    return lines.join("\n");
+  });
+  const found = results.filter((r) => r.definitions.length > 0).length;
+  return `${found} of ${results.length} name(s) resolved.\n\n${blocks.join("\n\n")}`;
+}
+
+/** godoc-style text for package_api. */
+function formatStdlib(query: string, symbols: StdlibSymbol[]): string {
+  const lines = [`Standard library definitions of "${query}":`, ""];
+  symbols.forEach((s, i) => {
+    lines.push(`${i + 1}. ${s.package}.${s.name} (${s.kind})`, `   File: ${s.file_path}:${s.line}`, `   Signature: ${s.signature}`);
+    if (s.doc) lines.push(`   ${s.doc}`);
+  });
+  lines.push("", "These files are outside the index; package_api lists the rest of the package.");
+  return lines.join("\n");
+}
@@ -0,0 +1,353 @@
+/**
+ * Trigram Bloom filters — the regex_search prefilter
+ *
+ * A regex or substring search over a large repository spends nearly all
+ * its time reading and scanning files that cannot match. Every indexed
+ * code file therefore carries a small Bloom filter of the trigrams in
+ * its text (DocumentMeta.trigram_filter), and a query is reduced to the
+ * trigrams any match must contain:
+ *
+ *   retryWithBackoff        ret, etr, try, ryw, ... (all of them)
+ *   conn(ect|ection)Pool    con, onn, poo, ool, and ect or ect, cti, tio, ion
+ *   Dial|Connect            dia, ial  OR  con, onn, nne, nec, ect
+ *   \w+Error\(              err, rro, ror, or(
+ *
+ * A file whose filter lacks a required trigram of every alternative is
+ * skipped without being read. Bloom filters have false positives, never
+ * false negatives, so skipping is always safe; the filters cost about a
+ * byte per distinct trigram of the file.
+ *
+ * Only trigrams of three ASCII characters are used, with letters
+ * lowercased, so one filter serves case-sensitive and case-insensitive
+ * queries alike. Anything in a pattern the extraction does not
+ * understand (classes, optional parts, lookarounds, backreferences)
+ * only weakens the query, and a pattern without a literal run of three
+ * characters in every alternative cannot be filtered: every file is
+ * scanned, as without filters.
+ */
+
+/** Filter bits per distinct trigram; with HASHES, about 2% false positives. */
+const BITS_PER_TRIGRAM = 8;
+const HASHES = 4;
+
+/** Largest filter, for minified and generated files: 128 KiB. */
+const MAX_FILTER_BITS = 1 << 20;
+
+/** Alternatives a query keeps before giving up on filtering. */
+const MAX_ALTERNATIVES = 16;
+
+/**
+ * Trigram codes a match must contain: any one inner list, in full.
+ * null when the pattern cannot be filtered.
+ */
+export type TrigramQuery = number[][] | null;
+
+// Scratch for buildTrigramFilter, reused from file to file: one bit per
+// possible trigram code to find the distinct ones, and the list of them
+const seen = new Uint8Array(1 << 18);
+let distinct = new Uint32Array(1 << 14);
+
+/** Base64 Bloom filter of the trigrams in `text`. */
+export function buildTrigramFilter(text: string): string {
+  // The trigrams of asciiRuns(text), read in one pass over the text
+  let count = 0;
+  let a = -1;
+  let b = -1;
+  for (let i = 0; i < text.length; i++) {
+    let c = text.charCodeAt(i);
+    if (c >= 128) {
+      a = b = -1;
+      continue;
+    }
+    if (c >= 65 && c <= 90) c += 32;
+    if (a >= 0) {
+      const code = (a << 14) | (b << 7) | c;
+      if ((seen[code >> 3] & (1 << (code & 7))) === 0) {
+        seen[code >> 3] |= 1 << (code & 7);
+        if (count === distinct.length) {
+          const grown = new Uint32Array(count * 2);
+          grown.set(distinct);
+          distinct = grown;
+        }
+        distinct[count++] = code;
+      }
+    }
+    a = b;
+    b = c;
+  }
+
+  const bits = Math.min(MAX_FILTER_BITS, Math.max(64, Math.ceil((count * BITS_PER_TRIGRAM) / 64) * 64));
+  const filter = new Uint8Array(bits / 8);
+  for (let i = 0; i < count; i++) {
+    const code = distinct[i];
+    seen[code >> 3] = 0;
+    const h1 = mix(code);
+    const h2 = mix(code ^ 0x9e3779b9) | 1;
+    for (let k = 0; k < HASHES; k++) {
+      const bit = ((h1 + Math.imul(k, h2)) >>> 0) % bits;
+      filter[bit >> 3] |= 1 << (bit & 7);
+    }
+  }
+  return Buffer.from(filter.buffer, filter.byteOffset, filter.byteLength).toString("base64");
+}
+
+/** Decode a filter made by buildTrigramFilter. */
+export function decodeTrigramFilter(filter: string): Uint8Array {
+  return new Uint8Array(Buffer.from(filter, "base64"));
+}
+
+/** Whether a file with `filter` can contain a match of `query`. */
+export function mayMatch(filter: Uint8Array, query: TrigramQuery): boolean {
+  if (query === null) return true;
+  const bits = filter.length * 8;
+  if (bits === 0) return true;
+  const has = (code: number) => bitsOf(code, bits).every((bit) => (filter[bit >> 3] & (1 << (bit & 7))) !== 0);
+  return query.some((required) => required.every(has));
+}
+
+/** The query for a plain substring search. */
+export function literalQuery(text: string): TrigramQuery {
+  return toQuery([asciiRuns(text)]);
+}
+
+/**
+ * The query for a JavaScript regular expression (without the u flag).
+ * Never throws: a pattern it cannot follow yields null.
+ */
+export function regexQuery(pattern: string): TrigramQuery {
+  try {
+    const parser = new Parser(pattern);
+    const result = parser.alternation();
+    return parser.done() ? toQuery(result) : null;
+  } catch {
+    return null;
+  }
+}
+
+// ── Regex → required literals ───────────────────────────────────────
+//
+// Each construct maps to a list of alternatives, each a list of literal
+// strings that must all appear. Concatenation multiplies alternatives
+// out, alternation joins them, and an optional construct contributes
+// nothing. [[]] means "no constraint".
+
+type Literals = string[][];
+const ANY: Literals = [[]];
+
+class Parser {
+  private at = 0;
+
+  constructor(private readonly source: string) {}
+
+  done(): boolean {
+    return this.at === this.source.length;
+  }
+
+  /** a|b|c, up to an unmatched ) or the end. */
+  alternation(): Literals {
+    const alternatives: Literals = [...this.sequence()];
+    while (this.source[this.at] === "|") {
+      this.at++;
+      alternatives.push(...this.sequence());
+      if (alternatives.length > MAX_ALTERNATIVES) return this.skipRest();
+    }
+    return alternatives;
+  }
+
+  private sequence(): Literals {
+    let result: Literals = ANY;
+    let run = "";
+    const flush = () => {
+      if (run) result = product(result, [[run]]);
+      run = "";
+    };
+    while (this.at < this.source.length && this.source[this.at] !== "|" && this.source[this.at] !== ")") {
+      const atom = this.atom();
+      const quantifier = this.quantifier();
+      if (quantifier === "optional") {
+        flush();
+        continue;
+      }
+      if (typeof atom === "string") {
+        run += atom;
+        if (quantifier === "repeated") flush();
+        continue;
+      }
+      flush();
+      if (atom !== null) result = product(result, atom);
+    }
+    flush();
+    return result;
+  }
+
+  /** One literal character, a group's literals, or null for anything else. */
+  private atom(): string | Literals | null {
+    const c = this.source[this.at++];
+    switch (c) {
+      case "\\":
+        return this.escape();
+      case ".":
+      case "^":
+      case "$":
+        return null;
+      case "[":
+        this.skipClass();
+        return null;
+      case "(":
+        return this.group();
+      default:
+        return literalChar(c);
+    }
+  }
+
+  private escape(): string | null {
+    const c = this.source[this.at++];
+    if (c === undefined) throw new Error("trailing backslash");
+    // Classes, boundaries, backreferences, and octal escapes
+    if ("dDwWsSbB0".includes(c) || /[1-9]/.test(c)) return null;
+    if (c === "k" && this.source[this.at] === "<") {
+      this.at = this.source.indexOf(">", this.at) + 1 || this.source.length;
+      return null;
+    }
+    const simple: Record<string, string> = { n: "\n", t: "\t", r: "\r", f: "\f", v: "\v" };
+    if (c in simple) return simple[c];
+    const hex = (length: number) => {
+      const digits = this.source.slice(this.at, this.at + length);
+      if (!new RegExp(`^[0-9a-fA-F]{${length}}$`).test(digits)) return null;
+      this.at += length;
+      return literalChar(String.fromCharCode(parseInt(digits, 16)));
+    };
+    if (c === "x") return hex(2) ?? "x";
+    if (c === "u") return hex(4) ?? "u";
+    if (c === "c") {
+      if (/[a-zA-Z]/.test(this.source[this.at] ?? "")) this.at++;
+      return null;
+    }
+    return literalChar(c);
+  }
+
+  private group(): Literals | null {
+    let lookaround = false;
+    if (this.source[this.at] === "?") {
+      const next = this.source.slice(this.at + 1, this.at + 3);
+      if (next.startsWith(":")) {
+        this.at += 2;
+      } else if (next.startsWith("=") || next.startsWith("!")) {
+        this.at += 2;
+        lookaround = true;
+      } else if (next === "<=" || next === "<!") {
+        this.at += 3;
+        lookaround = true;
+      } else if (next.startsWith("<")) {
+        this.at = this.source.indexOf(">", this.at) + 1 || this.source.length;
+      } else {
+        throw new Error("unsupported group");
+      }
+    }
+    const inner = this.alternation();
+    if (this.source[this.at] !== ")") throw new Error("unclosed group");
+    this.at++;
+    return lookaround ? null : inner;
+  }
+
+  private skipClass(): void {
+    while (this.at < this.source.length && this.source[this.at] !== "]") {
+      this.at += this.source[this.at] === "\\" ? 2 : 1;
+    }
+    if (this.at >= this.source.length) throw new Error("unclosed class");
+    this.at++;
+  }
+
+  /** How often the preceding atom occurs: once, possibly never, or repeated (one or more). */
+  private quantifier(): "once" | "optional" | "repeated" {
+    const c = this.source[this.at];
+    let kind: "once" | "optional" | "repeated" = "once";
+    if (c === "*" || c === "?") kind = "optional";
+    else if (c === "+") kind = "repeated";
+    else if (c === "{") {
+      const m = /^\{(\d+)(,\d*)?\}/.exec(this.source.slice(this.at));
+      if (!m) return "once";
+      this.at += m[0].length - 1;
+      kind = parseInt(m[1], 10) === 0 ? "optional" : "repeated";
+    } else return "once";
+    this.at++;
+    if (this.source[this.at] === "?") this.at++;
+    return kind;
+  }
+
+  private skipRest(): Literals {
+    let depth = 0;
+    while (this.at < this.source.length) {
+      const c = this.source[this.at];
+      if (c === "\\") this.at++;
+      else if (c === "[") {
+        this.at++;
+        this.skipClass();
+        continue;
+      } else if (c === "(") depth++;
+      else if (c === ")") {
+        if (depth === 0) break;
+        depth--;
+      }
+      this.at++;
+    }
+    return ANY;
+  }
+}
+
+/** ASCII characters stand for themselves; others only weaken the query. */
+function literalChar(c: string): string | null {
+  return c.charCodeAt(0) < 128 ? c : null;
+}
+
+function product(a: Literals, b: Literals): Literals {
+  if (a.length * b.length > MAX_ALTERNATIVES) {
+    // Keep the side that constrains more; dropping a factor is always safe
+    return weight(a) >= weight(b) ? a : b;
+  }
+  return a.flatMap((x) => b.map((y) => [...x, ...y]));
+}
+
+function weight(literals: Literals): number {
+  return Math.min(...literals.map((alt) => alt.reduce((sum, s) => sum + Math.max(0, s.length - 2), 0)));
+}
+
+function toQuery(literals: Literals): TrigramQuery {
+  const query = literals.map((alt) => [...new Set(alt.flatMap((s) => asciiRuns(s).flatMap(trigrams)))]);
+  return query.length === 0 || query.some((required) => required.length === 0) ? null : query;
+}
+
+// ── Trigrams and hashing ────────────────────────────────────────────
+
+/** Maximal runs of ASCII characters in `text`. */
+function asciiRuns(text: string): string[] {
+  return text.split(/[^\x00-\x7f]+/).filter((run) => run.length >= 3);
+}
+
+/** Codes of the trigrams of an ASCII string, letters lowercased. */
+function trigrams(text: string): number[] {
+  const codes: number[] = [];
+  const lower = text.toLowerCase();
+  for (let i = 0; i + 3 <= lower.length; i++) {
+    codes.push((lower.charCodeAt(i) << 14) | (lower.charCodeAt(i + 1) << 7) | lower.charCodeAt(i + 2));
+  }
+  return codes;
+}
+
+function bitsOf(code: number, bits: number): number[] {
+  const h1 = mix(code);
+  const h2 = mix(code ^ 0x9e3779b9) | 1;
+  const out: number[] = [];
+  for (let i = 0; i < HASHES; i++) out.push(((h1 + Math.imul(i, h2)) >>> 0) % bits);
+  return out;
+}
+
+/** 32-bit integer finalizer (murmur3 fmix32). */
+function mix(h: number): number {
+  h ^= h >>> 16;
+  h = Math.imul(h, 0x85ebca6b);
+  h ^= h >>> 13;
+  h = Math.imul(h, 0xc2b2ae35);
+  h ^= h >>> 16;
+  return h >>> 0;
+}
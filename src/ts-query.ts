@@ -0,0 +1,258 @@
+/**
+ * Raw tree-sitter queries — the ts_query tool
+ *
+ * The indexer's parsers are regex- and indentation-based by design (no
+ * native dependencies; see docs/DESIGN.md §4). For patterns they do not
+ * model, ts_query runs a tree-sitter S-expression query against indexed
+ * files and returns the captured nodes:
+ *
+ *   path:  "internal/"
+ *   query: (call_expression
+ *            function: (selector_expression field: (field_identifier) @f)
+ *            (#eq? @f "Fatal"))
+ *
+ * Nothing here is needed to index or search. Both pieces are opt-in:
+ *
+ *   - the web-tree-sitter package (`bun add web-tree-sitter`), loaded
+ *     the first time a query runs (or, for grammars that are Node-API
+ *     bindings, the native tree-sitter package);
+ *   - TREE_SITTER_GRAMMARS, a directory of compiled grammars named
+ *     tree-sitter-<language>.wasm (as shipped by tree-sitter-wasms, or
+ *     built with `tree-sitter build --wasm`), plus any a grammars.json
+ *     manifest adds (see grammars.ts). Grammars dropped in later are
+ *     picked up by the next query.
+ *
+ * The grammar is picked by file extension, the manifest's first. Files
+ * whose grammar is not in the directory are skipped and counted, so a
+ * glob may span languages.
+ */
+
+import { extname, join, resolve } from "node:path";
+import type { IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { currentDeadline } from "./deadline";
+import { readSourceText } from "./encoding";
+import { GrammarSet, type Grammar } from "./grammars";
+
+/** Packages loaded on first use; variables so the build does not require them. */
+const WEB_TREE_SITTER = "web-tree-sitter";
+const NATIVE_TREE_SITTER = "tree-sitter";
+
+/** Files parsed per call at most */
+export const MAX_QUERY_FILES = 500;
+
+/** Longest capture text returned; longer captures are cut with "…" */
+const MAX_CAPTURE_TEXT = 300;
+
+/** Grammar name (tree-sitter-<name>.wasm) by file extension, before the manifest's */
+const GRAMMAR_BY_EXTENSION: Record<string, string> = {
+  ".ts": "typescript", ".mts": "typescript", ".cts": "typescript", ".tsx": "tsx",
+  ".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+  ".py": "python", ".pyi": "python",
+  ".go": "go",
+  ".rs": "rust",
+  ".java": "java", ".kt": "kotlin", ".scala": "scala",
+  ".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".hpp": "cpp",
+  ".cs": "c_sharp",
+  ".rb": "ruby",
+  ".swift": "swift",
+  ".php": "php",
+  ".lua": "lua",
+  ".sh": "bash", ".bash": "bash", ".zsh": "bash",
+};
+
+/** The grammar a file is parsed with, or null for unknown extensions. */
+export function grammarFor(filePath: string): string | null {
+  return GRAMMAR_BY_EXTENSION[extname(filePath).toLowerCase()] ?? null;
+}
+
+/** Query failures the caller can fix: bad syntax, missing package or grammar. */
+export class TreeSitterError extends Error {}
+
+export interface QueryCapture {
+  /** Capture name without the @ */
+  capture: string;
+  node_type: string;
+  doc_id: string;
+  file_path: string;
+  /** 1-based */
+  start_line: number;
+  start_column: number;
+  end_line: number;
+  end_column: number;
+  text: string;
+}
+
+export interface QueryResult {
+  /** Files parsed */
+  files: number;
+  /** Matching files without an installed grammar */
+  skipped: number;
+  /** More than MAX_QUERY_FILES files matched; only the first were parsed */
+  truncated: boolean;
+  /** Captures found, including those beyond `limit` */
+  total: number;
+  captures: QueryCapture[];
+}
+
+/** Runs tree-sitter queries over the code collections of a store. */
+export class TreeSitterQuery {
+  private readonly roots: Map<string, string>;
+  private readonly installed: GrammarSet;
+  private runtime: Promise<any> | null = null;
+  private native: Promise<any> | null = null;
+  private languages = new Map<string, Promise<any>>();
+
+  constructor(config: IndexConfig, readonly grammarDir: string, options: { log?: (msg: string) => void } = {}) {
+    this.roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+    this.installed = new GrammarSet(grammarDir, options);
+  }
+
+  /** Grammar names present in the grammar directory now. */
+  async grammars(): Promise<Set<string>> {
+    return new Set((await this.installed.current()).keys());
+  }
+
+  /**
+   * Run `source` against the indexed code files at `path`: an exact file,
+   * a directory prefix, or a glob relative to the collection root.
+   * Returns null when no indexed code file matches.
+   */
+  async run(
+    store: DocumentStore,
+    path: string,
+    source: string,
+    options: { grammar?: string; limit?: number } = {}
+  ): Promise<QueryResult | null> {
+    const limit = options.limit ?? 100;
+    const docs = store.codeDocumentsAt(path);
+    if (docs.length === 0) return null;
+
+    const installed = await this.installed.current();
+    const byExtension = new Map<string, string>();
+    for (const grammar of installed.values()) for (const ext of grammar.extensions) byExtension.set(ext, grammar.name);
+    const result: QueryResult = { files: 0, skipped: 0, truncated: docs.length > MAX_QUERY_FILES, total: 0, captures: [] };
+    const queries = new Map<string, any>();
+    try {
+      const deadline = currentDeadline();
+      for (const doc of docs.slice(0, MAX_QUERY_FILES)) {
+        if (deadline?.expired()) break;
+        const name = options.grammar ?? byExtension.get(extname(doc.file_path).toLowerCase()) ?? grammarFor(doc.file_path);
+        const grammar = name ? installed.get(name) : undefined;
+        const root = this.roots.get(doc.collection);
+        if (!grammar || !root) {
+          result.skipped++;
+          continue;
+        }
+        const text = await readSourceText(join(root, doc.file_path)).catch(() => null);
+        if (text === null) {
+          result.skipped++;
+          continue;
+        }
+
+        const { Parser, Query } = await (grammar.runtime === "native" ? this.loadNative() : this.load());
+        const language = await this.language(grammar);
+        if (!queries.has(grammar.name)) queries.set(grammar.name, compile(Query, language, source, grammar.name));
+        const parser = new Parser();
+        parser.setLanguage(language);
+        const tree = parser.parse(text);
+        try {
+          for (const { name, node } of queries.get(grammar.name).captures(tree.rootNode)) {
+            result.total++;
+            if (result.captures.length >= limit) continue;
+            const body = text.slice(node.startIndex, node.endIndex);
+            result.captures.push({
+              capture: name,
+              node_type: node.type,
+              doc_id: doc.doc_id,
+              file_path: doc.file_path,
+              start_line: node.startPosition.row + 1,
+              start_column: node.startPosition.column + 1,
+              end_line: node.endPosition.row + 1,
+              end_column: node.endPosition.column + 1,
+              text: body.length > MAX_CAPTURE_TEXT ? `${body.slice(0, MAX_CAPTURE_TEXT)}…` : body,
+            });
+          }
+        } finally {
+          tree.delete?.();
+          parser.delete?.();
+        }
+        result.files++;
+      }
+    } finally {
+      for (const query of queries.values()) query.delete?.();
+    }
+    return result;
+  }
+
+  /** web-tree-sitter, normalized across the 0.2x (default export) and 0.25 (named exports) APIs. */
+  private load(): Promise<{ Parser: any; Language: any; Query: any }> {
+    this.runtime ??= (async () => {
+      let mod: any;
+      try {
+        mod = await import(WEB_TREE_SITTER);
+      } catch {
+        throw new TreeSitterError(`${WEB_TREE_SITTER} is not installed; run \`bun add ${WEB_TREE_SITTER}\` to enable ts_query`);
+      }
+      const Parser = mod.Parser ?? mod.default;
+      await Parser.init();
+      return { Parser, Language: mod.Language ?? Parser.Language, Query: mod.Query };
+    })();
+    this.runtime.catch(() => (this.runtime = null));
+    return this.runtime;
+  }
+
+  /** The native tree-sitter package, for grammars that are Node-API bindings. */
+  private loadNative(): Promise<{ Parser: any; Query: any }> {
+    this.native ??= (async () => {
+      let mod: any;
+      try {
+        mod = await import(NATIVE_TREE_SITTER);
+      } catch {
+        throw new TreeSitterError(
+          `${NATIVE_TREE_SITTER} is not installed; run \`bun add ${NATIVE_TREE_SITTER}\` to use .node/.so grammars`
+        );
+      }
+      const Parser = mod.default ?? mod;
+      return { Parser, Query: Parser.Query };
+    })();
+    this.native.catch(() => (this.native = null));
+    return this.native;
+  }
+
+  /** A grammar's language, loaded once per file. */
+  private language(grammar: Grammar): Promise<any> {
+    let language = this.languages.get(grammar.path);
+    if (!language) {
+      language =
+        grammar.runtime === "native"
+          ? this.loadNative().then(() => loadBinding(grammar))
+          : this.load().then(({ Language }) => Language.load(grammar.path));
+      language.catch(() => this.languages.delete(grammar.path));
+      this.languages.set(grammar.path, language);
+    }
+    return language;
+  }
+}
+
+/** The language a Node-API grammar binding exports. */
+function loadBinding(grammar: Grammar): any {
+  const module = { exports: {} as any };
+  try {
+    process.dlopen(module, grammar.path);
+  } catch (err) {
+    throw new TreeSitterError(
+      `Cannot load the ${grammar.name} grammar from ${grammar.path} (it must be a Node-API grammar binding): ` +
+        (err instanceof Error ? err.message : String(err))
+    );
+  }
+  return module.exports;
+}
+
+function compile(Query: any, language: any, source: string, grammar: string): any {
+  try {
+    return Query ? new Query(language, source) : language.query(source);
+  } catch (err) {
+    throw new TreeSitterError(`Invalid query for the ${grammar} grammar: ${err instanceof Error ? err.message : String(err)}`);
+  }
+}
@@ -29,6 +29,7 @@ export interface TreeNode {
   word_count: number;
   line_start: number;
   line_end: number;
+  cell?: number; // notebook cell (1-based) holding the node; lines then count from the cell's start
 }
 
 /** Compact tree representation for agent consumption (no content) */
@@ -76,8 +77,21 @@ export interface DocumentMeta {
   facets: Record<string, string[]>; // Pagefind-style filter facets from frontmatter
   /** Cross-references: doc-relative paths extracted from markdown links */
   references: string[];
+  /** Set when the file was transcoded from UTF-16 or Latin-1 (encoding.ts) */
+  encoding?: SourceEncoding;
+  /** Code files: the byte offset in the file where each line starts (encoding.ts) */
+  line_offsets?: number[];
+  /** Code files: Bloom filter of the file's trigrams, base64, for regex_search (trigram-filter.ts) */
+  trigram_filter?: string;
+  /** Set for code files that are generated, minified, or lockfiles (generated.ts) */
+  generated?: GeneratedKind;
+  /** For generated code: the generator and its input, from the file's header (generated.ts) */
+  generated_from?: GeneratorOrigin;
 }
 
+/** How a source file's bytes were decoded; see encoding.ts */
+export type SourceEncoding = "utf-8" | "utf-16le" | "utf-16be" | "latin1";
+
 /** Complete indexed document */
 export interface IndexedDocument {
   meta: DocumentMeta;
@@ -101,6 +115,7 @@ export interface Posting {
   positions: number[]; // word offsets within the node's token stream
   term_frequency: number; // |positions|
   weight: number; // base weight: title=3.0, body=1.0, code=1.5
+  comment_positions?: number[]; // the positions inside comments (code documents, see comments.ts)
 }
 
 /**
@@ -110,6 +125,8 @@ export interface NodeStats {
   doc_id: string;
   node_id: string;
   total_tokens: number;
+  /** Language of the node's comments, when detected (code documents, see comments.ts) */
+  comment_language?: string;
 }
 
 // ── Filter facets (Pagefind data-pagefind-filter inspired) ──────────
@@ -144,13 +161,102 @@ export interface SearchResult {
   node_title: string;
   level: number;
   snippet: string; // best region chosen by density (Pagefind excerpt algorithm)
-  score: number; // BM25 relevance score (× collection weight)
+  score: number; // BM25 relevance score (× collection and path weights)
   match_positions: number[]; // word positions of all matches in node
   matched_terms: string[]; // which query terms matched
+  line_start: number; // first line of the node in its file
+  line_end: number; // last line of the node
+  cell?: number; // notebook cell of the node; line_start is within the cell
+  uri: string; // location URI of the node's lines, see uris.ts
+  snippet_highlights: MatchRange[]; // matches within `snippet`
+  content_matches: ContentMatch[]; // matches within the node's full content
   collection: string; // Pagefind-style multisite collection
   facets: Record<string, string[]>; // document's facet values
+  group?: SymbolGroup; // other matches folded into this one (searchDocuments `group`)
+  explain?: ScoreBreakdown; // how `score` was reached (searchDocuments `explain`)
+  comment_language?: string; // language of the comments that matched, when detected (see comments.ts)
+}
+
+/**
+ * The parts of a result's score, in the order they are applied:
+ * (bm25 + prefix + proximity + all_terms) × every multiplier. A
+ * multiplier of 1 had no effect.
+ */
+export interface ScoreBreakdown {
+  /** BM25 of each matched indexed term; prefix expansions already × prefix_penalty */
+  terms: Record<string, number>;
+  /** Sum of exact-term BM25 */
+  bm25: number;
+  /** Sum of prefix-expansion BM25 */
+  prefix: number;
+  /** term_proximity_bonus per query term beyond the first that matched */
+  proximity: number;
+  /** full_coverage_bonus when every query term matched */
+  all_terms: number;
+  /** Collection weight */
+  collection: number;
+  /** PATH_BOOSTS and vendor downranking, multiplied */
+  path: number;
+  /** RECENCY_WEIGHT multiplier from the last commit */
+  recency: number;
+  /** GENERATED_POLICY=downrank multiplier */
+  generated: number;
+  /** REFERENCE_WEIGHT multiplier from files naming the symbol */
+  popularity: number;
+  /** COVERAGE_WEIGHT multiplier in "needs tests" queries */
+  coverage: number;
+  /** The score before RANKING_WASM replaced it, when it did */
+  before_rescore?: number;
+}
+
+/** Matches inside one top-level code symbol, folded into its best-scoring result. */
+export interface SymbolGroup {
+  /** The enclosing symbol: "class Manager", "function run", or the receiver type of Go methods declared apart from it */
+  symbol: string;
+  /** Matching nodes in the symbol, the result itself included */
+  matches: number;
+  /** The other matching nodes, best first */
+  node_ids: string[];
+}
+
+/**
+ * A matched span of text: [start, end) offsets in UTF-16 code units,
+ * i.e. JavaScript string indices (LSP's default position encoding).
+ */
+export interface MatchRange {
+  start: number;
+  end: number;
+}
+
+/**
+ * A match in a node's content, located by 1-based line and column
+ * within that content. Code node content is verbatim source starting
+ * at `line_start`, so the match sits on file line `line_start + line - 1`.
+ */
+export interface ContentMatch extends MatchRange {
+  line: number;
+  column: number;
+  /**
+   * [byte_start, byte_end) in the file as it is on disk: for code files
+   * that were transcoded, or all code files with BYTE_OFFSETS on
+   */
+  byte_start?: number;
+  byte_end?: number;
 }
 
+/**
+ * How search matches letter case:
+ *   insensitive  ignore case (default)
+ *   sensitive    match the query's case exactly
+ *   smart        insensitive unless the query contains an uppercase letter
+ */
+export type CaseMode = "sensitive" | "insensitive" | "smart";
+
+export const CASE_MODES: CaseMode[] = ["sensitive", "insensitive", "smart"];
+
+/** Whether test files count: true (default), false to leave them out, "only"; see test-paths.ts */
+export type IncludeTests = boolean | "only";
+
 // ── Ranking configuration (Pagefind-style configurable knobs) ───────
 
 /**
@@ -190,6 +296,25 @@ export interface RankingParams {
   /** Discount factor for prefix matches (0-1). Default 0.5.
    *  Pagefind handles this at the chunk-loading level; we apply as a score multiplier. */
   prefix_penalty: number;
+
+  /** Boost for code symbols referenced from many other files. A symbol
+   *  named in every file that mentions the most-used name scores up to
+   *  1 + reference_weight times higher; log-scaled below that. 0 = off. Default 0.5 */
+  reference_weight: number;
+
+  /** Boost for functions with uncovered statements, applied only to
+   *  "needs tests" queries while a cover profile is loaded: a function
+   *  with no statement covered scores 1 + coverage_weight times higher.
+   *  0 = off. Default 1.0 */
+  coverage_weight: number;
+
+  /** Most results from one file, for searches that ask for diversity
+   *  (search_documents, find_symbol, multi_search). 0 = no cap. Default 0 */
+  max_per_file: number;
+
+  /** Most results from one package: a directory of one collection.
+   *  0 = no cap. Default 0 */
+  max_per_package: number;
 }
 
 export const DEFAULT_RANKING: RankingParams = {
@@ -201,8 +326,22 @@ export const DEFAULT_RANKING: RankingParams = {
   term_proximity_bonus: 2.0,
   full_coverage_bonus: 5.0,
   prefix_penalty: 0.5,
+  reference_weight: 0.5,
+  coverage_weight: 1.0,
+  max_per_file: 0,
+  max_per_package: 0,
 };
 
+/**
+ * Score multiplier for documents whose path matches a glob (PATH_BOOSTS).
+ * A pattern without "/" matches the file name, one ending in "/" a
+ * directory at any depth, anything else the collection-relative path.
+ */
+export interface PathBoost {
+  pattern: string;
+  weight: number;
+}
+
 // ── Collection configuration (Pagefind multisite inspired) ──────────
 
 /**
@@ -217,17 +356,61 @@ export interface CollectionConfig {
   root: string;
   weight: number; // multiplied into BM25 scores. Pagefind's indexWeight equivalent.
   glob_pattern?: string;
+  /** How file discovery treats symlinks; see walk.ts (default "within-root") */
+  symlinks?: SymlinkPolicy;
+  /** Sparse indexing: only files matching one of these globs; see coverage.ts */
+  include?: string[];
+  /** How vendor/ trees are treated; see vendor.ts (default "index") */
+  vendor?: VendorPolicy;
+}
+
+/**
+ * Symlink handling during file discovery:
+ *   skip         never follow symlinked files or directories
+ *   within-root  follow links whose target resolves inside the collection root
+ *   all          follow every link, wherever it points
+ */
+export type SymlinkPolicy = "skip" | "within-root" | "all";
+
+/** Treatment of vendor/ trees: see vendor.ts */
+export type VendorPolicy = "index" | "downrank" | "exclude";
+
+/** Why a file counts as machine-written: see generated.ts */
+export type GeneratedKind = "generated" | "minified" | "lockfile";
+
+/** The generator of a generated file and what it was run on: see generated.ts */
+export interface GeneratorOrigin {
+  /** protoc-gen-go, protoc-gen-go-grpc, mockgen, stringer, or the name the header gives */
+  generator: string;
+  /** Input as the header names it: a .proto path, a Go file, or a Go import path */
+  source?: string;
+  /** Types the generator was run for: mockgen's interfaces, stringer's -type */
+  types?: string[];
 }
 
+/** Treatment of generated files in search: see generated.ts */
+export type GeneratedPolicy = "index" | "downrank" | "exclude";
+
 /** Main configuration */
 export interface IndexConfig {
   collections: CollectionConfig[];
   /** Optional code collections — source files indexed via AST parsing */
   code_collections?: CollectionConfig[];
+  /** Third-party code indexed read-only (INDEX_DEPENDENCIES); no code tool reads these roots */
+  dependency_collections?: CollectionConfig[];
   summary_length: number;
   max_depth: number;
 }
 
+/**
+ * Counters for one indexing run. Pass one to indexAllCollections to
+ * learn how many files were seen and which failed to parse.
+ */
+export interface IndexRunStats {
+  files: number;
+  failed: { file: string; error: string }[];
+}
+
 /** Convenience: single-root config (the common case) */
 export function singleRootConfig(
   docs_root: string,
@@ -0,0 +1,156 @@
+/**
+ * Location URIs — results that later calls take as input
+ *
+ * Every result that points into a file carries a `uri` naming the file
+ * and the lines it covers:
+ *
+ *   treenav://file/internal/cluster/manager.go#L42-58
+ *   treenav://file/analysis/eda.ipynb#cell3:L1-12
+ *   treenav://file/README.md?collection=docs#L10
+ *
+ * get_node_content, navigate_tree, get_tree, usage_stats, callers,
+ * breadcrumbs and read_file accept one in place of doc_id / node_id /
+ * symbol / path, so an agent passes a prior result on as is instead of
+ * rebuilding the arguments. A URI resolves to the innermost section
+ * spanning its lines; without a fragment it names the whole file.
+ *
+ * Lines are the ones results report (line_start / line_end): file lines
+ * for code, lines within the cell for notebooks. The collection is only
+ * named when more than one collection indexes the path. GitHub-style
+ * "#L42-L58" is accepted too.
+ */
+
+import type { DocumentStore } from "./store";
+import type { DocumentMeta, TreeNode } from "./types";
+
+export const URI_PREFIX = "treenav://file/";
+
+/** A file, and optionally the lines in it, that a URI names. */
+export interface UriLocation {
+  path: string;
+  collection?: string;
+  cell?: number;
+  line_start?: number;
+  line_end?: number;
+}
+
+export class UriError extends Error {}
+
+const FRAGMENT = /^(?:cell(\d+)(?::|$))?(?:L(\d+)(?:-L?(\d+))?)?$/;
+
+/** The URI for `location`; path segments are percent-encoded. */
+export function formatUri(location: UriLocation): string {
+  const path = location.path.split("/").map(encodeURIComponent).join("/");
+  const query = location.collection ? `?collection=${encodeURIComponent(location.collection)}` : "";
+  const lines =
+    location.line_start === undefined
+      ? ""
+      : location.line_end === undefined || location.line_end <= location.line_start
+        ? `L${location.line_start}`
+        : `L${location.line_start}-${location.line_end}`;
+  const cell = location.cell ? `cell${location.cell}${lines ? ":" : ""}` : "";
+  return `${URI_PREFIX}${path}${query}${cell || lines ? `#${cell}${lines}` : ""}`;
+}
+
+/** Parse a URI from formatUri; throws UriError on anything else. */
+export function parseUri(uri: string): UriLocation {
+  if (!uri.startsWith(URI_PREFIX)) {
+    throw new UriError(`"${uri}" is not a location URI; expected ${URI_PREFIX}<path>#L<start>-<end>`);
+  }
+  const hash = uri.indexOf("#");
+  const fragment = hash === -1 ? "" : uri.slice(hash + 1);
+  const rest = hash === -1 ? uri.slice(URI_PREFIX.length) : uri.slice(URI_PREFIX.length, hash);
+  const question = rest.indexOf("?");
+  const rawPath = question === -1 ? rest : rest.slice(0, question);
+  const params = new URLSearchParams(question === -1 ? "" : rest.slice(question + 1));
+
+  const m = fragment.match(FRAGMENT);
+  if (!m) throw new UriError(`Cannot read the fragment "#${fragment}" of "${uri}"; expected #L42, #L42-58, or #cell3:L1-12`);
+  let path: string;
+  try {
+    path = decodeURIComponent(rawPath);
+  } catch {
+    throw new UriError(`"${uri}" has a malformed path`);
+  }
+  if (!path) throw new UriError(`"${uri}" names no file`);
+
+  const location: UriLocation = { path };
+  const collection = params.get("collection");
+  if (collection) location.collection = collection;
+  if (m[1]) location.cell = Number(m[1]);
+  if (m[2]) {
+    location.line_start = Number(m[2]);
+    location.line_end = m[3] ? Number(m[3]) : location.line_start;
+    if (location.line_end < location.line_start) {
+      throw new UriError(`"${uri}" ends before it starts`);
+    }
+  }
+  return location;
+}
+
+/**
+ * The URI of lines `line_start`..`line_end` of document `doc_id`, naming
+ * the collection only when the path is indexed in several; undefined
+ * for a document the store does not hold.
+ */
+export function locationUri(
+  store: Pick<DocumentStore, "getDocMeta" | "documentsAtPath">,
+  doc_id: string,
+  line_start?: number,
+  line_end?: number,
+  cell?: number
+): string | undefined {
+  const meta = store.getDocMeta(doc_id);
+  if (!meta) return undefined;
+  const shared = store.documentsAtPath(meta.file_path).length > 1;
+  return formatUri({
+    path: meta.file_path,
+    ...(shared ? { collection: meta.collection } : {}),
+    ...(cell ? { cell } : {}),
+    line_start,
+    line_end,
+  });
+}
+
+/** The document a URI names and the innermost section spanning its lines. */
+export interface ResolvedUri {
+  doc: DocumentMeta;
+  /** null when the URI names no lines */
+  node: TreeNode | null;
+  location: UriLocation;
+}
+
+/**
+ * The one document at `location`'s path (in its collection, when named).
+ * Throws UriError when none is indexed there, or several are and the
+ * location does not say which.
+ */
+export function documentAt(store: Pick<DocumentStore, "documentsAtPath">, location: UriLocation): DocumentMeta {
+  const docs = store
+    .documentsAtPath(location.path)
+    .filter((d) => !location.collection || d.collection === location.collection);
+  if (docs.length === 0) {
+    throw new UriError(`No indexed file at "${location.path}"${location.collection ? ` in collection "${location.collection}"` : ""}`);
+  }
+  if (docs.length > 1) {
+    throw new UriError(
+      `"${location.path}" is indexed in ${docs.map((d) => d.collection).join(", ")}; add ?collection=<name> to the URI`
+    );
+  }
+  return docs[0];
+}
+
+/**
+ * Resolve `uri` against `store`. Throws UriError when it is malformed,
+ * names no indexed file, or is ambiguous between collections.
+ */
+export function resolveUri(store: DocumentStore, uri: string): ResolvedUri {
+  const location = parseUri(uri);
+  const doc = documentAt(store, location);
+  if (location.line_start === undefined && !location.cell) return { doc, node: null, location };
+
+  const start = location.line_start ?? 1;
+  const node = store.nodeAt(doc.doc_id, start, location.line_end ?? start, location.cell);
+  if (!node) throw new UriError(`${location.path} has no section at ${uri.slice(uri.indexOf("#") + 1)}`);
+  return { doc, node, location };
+}
@@ -0,0 +1,1273 @@
+/**
+ * Reference counts for a symbol or a package — the usage_stats tool
+ *
+ * Answers "who breaks if I change this?" before a breaking change. Each
+ * reference is attributed to the package (directory) it appears in and
+ * sorted into a kind:
+ *
+ *   call       Connect(ctx), db.Connect(ctx), s.Start()
+ *   type_use   var c *Conn, []Conn, Conn{}, conversions like Conn(x)
+ *   embed      a struct or interface field that is only the type, or a
+ *              base in extends / implements / class Foo(Base)
+ *   value      anything else: the symbol passed, assigned, or read
+ *
+ * Counting is lexical, like find_symbol's reference counts, but import
+ * aware for Go. Inside the defining package a bare name counts; from
+ * another package only `alias.Name`, and only in files that import the
+ * defining package under that alias (or dot-import it). Methods and
+ * fields count as `.Name` selectors anywhere in the same language, which
+ * overcounts when unrelated types share a method name. Other languages
+ * count every occurrence in files of the same language. Strings,
+ * comments, import lines, and the definition itself never count.
+ *
+ * With several code collections (CODE_ROOTS), a reference from another
+ * collection than the definition's also needs its file to import the
+ * defining package: for Go its import path, from the module graph across
+ * every collection; for JavaScript and TypeScript the name in the nearest
+ * package.json, or a subpath of it. Other languages stay lexical.
+ * referencesByCollection() groups the sites for find_references.
+ *
+ * callers() follows the "call" references the other way — the callers
+ * tool: the functions whose bodies call the symbol, then their callers,
+ * breadth first, until a depth, per-function fan-out, or total limit
+ * stops it. The report says which limits cut the closure short.
+ *
+ * traceError() follows an error value or type — the trace_errors tool:
+ * where it is created, wrapped (fmt.Errorf with %w, errors.Wrap, raise
+ * ... from), compared (errors.Is / As, ==, case, except, catch), and
+ * returned, then the callers that pass it on, level by level. Passing it
+ * on is read from the call and the lines just after it: in Go a return
+ * on the call's line or a return of err after it; elsewhere any call
+ * outside a try block, since exceptions unwind by themselves.
+ *
+ * exits() lists where Go code gives up — the panic_sites tool: panic
+ * and logger Panic calls, log.Fatal and its kin, and os.Exit, each with
+ * its function. For a panic it asks whether a recover stops it: one
+ * deferred in the function itself (a deferred closure calling recover(),
+ * or a deferred call to a function that does), else in the callers,
+ * followed level by level until one recovers. A call made with `go`
+ * starts a new goroutine, which no caller's recover reaches, so the
+ * panic escapes there. Fatal calls and os.Exit skip deferred calls
+ * altogether.
+ *
+ * Names declared in Go's grouped `var ( ... )` and `const ( ... )`
+ * blocks count as definitions of their own, which is where most
+ * sentinel errors live.
+ */
+
+import { readFile } from "node:fs/promises";
+import { extname, join, posix } from "node:path";
+import type { DocumentMeta, IncludeTests, IndexConfig } from "./types";
+import type { DocumentStore } from "./store";
+import { parseCodeSymbols, type CodeSymbol, type SymbolKind } from "./code-indexer";
+import { moduleForPath, type GoModuleIndex } from "./go-modules";
+import { blankLiterals, hashCommentsFor } from "./structural";
+import { currentDeadline } from "./deadline";
+import { FileScanCache } from "./scan-cache";
+import { matchesTests } from "./test-paths";
+
+export type UsageKind = "call" | "type_use" | "embed" | "value";
+
+export const USAGE_KINDS: UsageKind[] = ["call", "type_use", "embed", "value"];
+
+const TYPE_KINDS = new Set<SymbolKind>(["class", "interface", "type", "enum"]);
+
+/** Files in one family can reference each other's symbols. */
+const LANGUAGE_FAMILY: Record<string, string> = {
+  ".ts": "js", ".tsx": "js", ".mts": "js", ".cts": "js", ".js": "js", ".jsx": "js", ".mjs": "js", ".cjs": "js",
+  ".py": "py", ".pyi": "py",
+  ".java": "jvm", ".kt": "jvm", ".kts": "jvm", ".scala": "jvm",
+  ".c": "c", ".h": "c", ".cc": "c", ".cpp": "c", ".cxx": "c", ".hpp": "c", ".hh": "c",
+};
+
+export const languageFamily = (filePath: string) => {
+  const ext = extname(filePath).toLowerCase();
+  return LANGUAGE_FAMILY[ext] ?? ext;
+};
+
+const IDENTIFIER = /[\p{L}_$][\p{L}\p{N}_$]*/gu;
+const QUALIFIER = /([\p{L}_$][\p{L}\p{N}_$]*)\s*(?:\.|->|::)\s*$/u;
+const IMPORT_LINE = /^\s*(?:import\b|from\s+\S+\s+import\b|export\s+(?:type\s+)?(?:\{[^}]*\}|\*)\s*from\b|using\b|#include\b|(?:const|let|var)\s+(?:\{[^}]*\}|\w+)\s*=\s*require\s*\()/;
+
+/** Bad queries: neither or both of symbol and package. */
+export class UsageError extends Error {}
+
+/** The kind of a reference to a name: `line` is blanked of literals, `start` is where a qualifier begins. */
+export function classifyUsage(line: string, start: number, end: number, isType: boolean): UsageKind {
+  const before = line.slice(0, start);
+  const after = line.slice(end);
+  if (isType) {
+    // Embedded field: the type alone on its line, perhaps as a pointer
+    if (/^\s*\*?\s*$/.test(before) && /^\s*,?\s*$/.test(after)) return "embed";
+    if (/\b(?:extends|implements)\s+(?:[\w$.<>]+\s*,\s*)*$/.test(before)) return "embed";
+    if (/^\s*class\s+\w+\s*\((?:[^)]*,)?\s*$/.test(before)) return "embed";
+    return "type_use";
+  }
+  return /^\s*(?:\[[^\]]*\]\s*)?\(/.test(after) ? "call" : "value";
+}
+
+export type ErrorRole = "created" | "wrapped" | "compared" | "returned" | "other";
+
+export const ERROR_ROLES: ErrorRole[] = ["created", "wrapped", "compared", "returned", "other"];
+
+const WRAPS = /\bfmt\.Errorf\s*\(.*%w|\berrors\.(?:Wrapf?|WithMessagef?|WithStack|Join)\s*\(|\braise\b.+\bfrom\b|\bcause\s*:/;
+const RETURNS = /^\s*(?:return|raise|throw)\b/;
+const COMPARES =
+  /\berrors\.(?:Is|As)\s*\(|\b(?:assert|require)\.Error(?:Is|As)\s*\(|\bisinstance\s*\(|\b(?:assertRaises|raises)\s*\(|^\s*(?:case|except)\b|\bcatch\s*\(/;
+
+/**
+ * What a line does with the error `name`: `text` is the line as written,
+ * strings included, since %w lives in the format string. `isType` when
+ * the error is a type, which is created by constructing it rather than
+ * by its definition.
+ */
+export function classifyErrorUse(text: string, name: string, isType: boolean): ErrorRole {
+  const escaped = name.replace(/\$/g, "\\$");
+  const ref = String.raw`(?:[\p{L}_$][\p{L}\p{N}_$]*\.)*${escaped}(?![\p{L}\p{N}_$])`;
+  if (COMPARES.test(text) || new RegExp(String.raw`[!=]==?\s*&?${ref}|${ref}\s*[!=]=|\binstanceof\s+${ref}`, "u").test(text)) {
+    return "compared";
+  }
+  if (WRAPS.test(text)) return "wrapped";
+  if (isType && new RegExp(String.raw`\bnew\s+${ref}|&?${ref}\s*[{(]`, "u").test(text)) return "created";
+  if (RETURNS.test(text)) return "returned";
+  return "other";
+}
+
+/** Names declared in a Go file's `var ( ... )` and `const ( ... )` blocks, from its blanked lines. */
+export function groupedDeclarations(blanked: string[]): Array<{ name: string; line: number }> {
+  const names: Array<{ name: string; line: number }> = [];
+  // Bracket depth inside a block; -1 outside one
+  let depth = -1;
+  for (let i = 0; i < blanked.length; i++) {
+    const line = blanked[i];
+    if (depth < 0) {
+      if (/^(?:var|const)\s*\(\s*$/.test(line)) depth = 0;
+      continue;
+    }
+    if (depth === 0) {
+      if (/^\)/.test(line)) {
+        depth = -1;
+        continue;
+      }
+      const m = line.match(/^\s+([\p{L}_][\p{L}\p{N}_]*(?:\s*,\s*[\p{L}_][\p{L}\p{N}_]*)*)(?![\p{L}\p{N}_])/u);
+      if (m) for (const name of m[1].split(/\s*,\s*/)) if (name !== "_") names.push({ name, line: i + 1 });
+    }
+    depth += (line.match(/[({[]/g)?.length ?? 0) - (line.match(/[)}\]]/g)?.length ?? 0);
+    if (depth < 0) depth = -1;
+  }
+  return names;
+}
+
+/** Go import aliases of a file: alias → import path; "." for dot imports. Blank imports are left out. */
+export function goImports(source: string): Map<string, string> {
+  const imports = new Map<string, string>();
+  const add = (alias: string | undefined, path: string) => {
+    if (alias === "_") return;
+    const segments = path.split("/");
+    let name = segments.at(-1)!;
+    if (/^v\d+$/.test(name) && segments.length > 1) name = segments.at(-2)!;
+    imports.set(alias ?? name.replace(/^go-|[.-]go$/g, "").replace(/[^\w]/g, "_"), path);
+  };
+  const spec = /^\s*([\w.]+\s+)?"([^"]+)"/;
+  for (const m of source.matchAll(/^import\s*\(([\s\S]*?)^\)/gm)) {
+    for (const line of m[1].split("\n")) {
+      const s = line.match(spec);
+      if (s) add(s[1]?.trim(), s[2]);
+    }
+  }
+  for (const m of source.matchAll(/^import\s+([\w.]+\s+)?"([^"]+)"/gm)) add(m[1]?.trim(), m[2]);
+  return imports;
+}
+
+/**
+ * Module specifiers a JavaScript or TypeScript file imports: import and
+ * export ... from, side-effect imports, require(), and import(), in
+ * order, each once.
+ */
+export function jsImports(source: string): string[] {
+  const specs = new Set<string>();
+  const pattern = /\b(?:(?:import|export)\s[^'"`;]*?\bfrom\s*|import\s*|(?:require|import)\s*\(\s*)["']([^"'\n]+)["']/g;
+  for (const m of source.matchAll(pattern)) specs.add(m[1]);
+  return [...specs];
+}
+
+/** The `name` of a package.json; undefined when it is missing, unreadable, or has none. */
+async function packageJsonName(path: string): Promise<string | undefined> {
+  const text = await readFile(path, "utf-8").catch(() => null);
+  if (text === null) return undefined;
+  try {
+    const name = JSON.parse(text).name;
+    return typeof name === "string" && name ? name : undefined;
+  } catch {
+    return undefined;
+  }
+}
+
+export interface UsageDefinition {
+  /** Qualified, e.g. Server.Start */
+  name: string;
+  kind: SymbolKind;
+  collection: string;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  package: string;
+}
+
+export interface UsageSite {
+  /** The definition this references */
+  name: string;
+  kind: UsageKind;
+  collection: string;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  package: string;
+  text: string;
+}
+
+export interface PackageUsage {
+  package: string;
+  collection: string;
+  /** The consumer is a defining package */
+  internal: boolean;
+  total: number;
+  by_kind: Record<UsageKind, number>;
+  files: number;
+}
+
+export interface SymbolUsage {
+  name: string;
+  kind: SymbolKind;
+  total: number;
+  by_kind: Record<UsageKind, number>;
+  packages: number;
+}
+
+export interface UsageReport {
+  scope: "symbol" | "package";
+  target: string;
+  definitions: UsageDefinition[];
+  /** Code files scanned */
+  files: number;
+  total: number;
+  by_kind: Record<UsageKind, number>;
+  /** Busiest consumer first */
+  consumers: PackageUsage[];
+  /** Per defined symbol, busiest first (package scope only) */
+  symbols?: SymbolUsage[];
+  /** Every reference, in file and line order */
+  sites: UsageSite[];
+}
+
+/** The references of a report in one collection; see referencesByCollection */
+export interface CollectionReferences {
+  collection: string;
+  /** Defines none of the targets: every reference here crosses in from another collection */
+  external: boolean;
+  total: number;
+  by_kind: Record<UsageKind, number>;
+  files: number;
+  /** In file and line order */
+  sites: UsageSite[];
+}
+
+/** Why a caller closure stopped short; see UsageStats.callers */
+export type CallTruncation = "depth" | "fan_out" | "nodes" | "deadline";
+
+export interface CallerNode extends UsageDefinition {
+  /** 1 for a direct caller, 2 for a caller of one, ... */
+  depth: number;
+  /** Functions in the closure (or the target) this one calls */
+  calls: string[];
+  /** Its call sites of those, in line order */
+  sites: Array<{ line: number; text: string }>;
+}
+
+export interface CallerReport {
+  target: string;
+  definitions: UsageDefinition[];
+  /** Code files scanned */
+  files: number;
+  /** Deepest caller found */
+  depth: number;
+  /** Nearest first, then by file and line */
+  callers: CallerNode[];
+  /** Calls from outside any function: package initializers, scripts */
+  top_level: UsageSite[];
+  /** Empty when the closure is complete */
+  truncated: CallTruncation[];
+}
+
+export interface CallerOptions extends UsageOptions {
+  /** Levels of callers to follow; 1 is direct callers only */
+  depth?: number;
+  /** New callers kept per function at each level */
+  fan_out?: number;
+  /** Callers kept in all */
+  max_nodes?: number;
+}
+
+export interface ErrorSite {
+  role: ErrorRole;
+  /** The definition this references */
+  name: string;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  package: string;
+  text: string;
+  /** Qualified name of the function it is in; absent at top level */
+  function?: string;
+}
+
+export interface ErrorHop extends UsageDefinition {
+  /** 1 for a caller of a function the error leaves from, 2 for a caller of one, ... */
+  depth: number;
+  /** Functions on the path this one calls */
+  calls: string[];
+  /** Its call sites of those, in line order */
+  sites: Array<{ line: number; text: string }>;
+  /** Returns or lets through what those calls fail with */
+  propagates: boolean;
+  /** Wraps it on the way out (Go %w, errors.Wrap) */
+  wraps: boolean;
+  /** Tests for the error itself: errors.Is, except, catch */
+  checks: boolean;
+}
+
+export interface ErrorTrace {
+  target: string;
+  definitions: UsageDefinition[];
+  /** Code files scanned */
+  files: number;
+  by_role: Record<ErrorRole, number>;
+  /** Every use, definitions that create it included, in file and line order */
+  sites: ErrorSite[];
+  /** Functions that create, wrap, or return it, where the chain starts */
+  origins: UsageDefinition[];
+  /** Callers the error travels through, nearest first, then by file and line */
+  chain: ErrorHop[];
+  /** "depth", "nodes", "deadline"; empty when the chain is complete */
+  truncated: CallTruncation[];
+}
+
+export interface ErrorTraceOptions extends UsageOptions {
+  /** Levels of callers to follow up from the origins */
+  depth?: number;
+  /** Callers kept in the chain */
+  max_nodes?: number;
+}
+
+export const DEFAULT_ERROR_DEPTH = 4;
+
+/** panic: unwinds and can be recovered; fatal: log.Fatal and kin, exit after logging; exit: os.Exit */
+export type ExitKind = "panic" | "fatal" | "exit";
+
+export const EXIT_KINDS: ExitKind[] = ["panic", "fatal", "exit"];
+
+/**
+ * Where a panic is recovered: in its own function, by every caller chain
+ * followed, by some of them, or nowhere found.
+ */
+export type RecoverStatus = "local" | "upstream" | "partial" | "none";
+
+export const RECOVER_STATUSES: RecoverStatus[] = ["local", "upstream", "partial", "none"];
+
+export interface ExitSite {
+  kind: ExitKind;
+  /** panic, log.Fatalf, os.Exit, ... */
+  call: string;
+  doc_id: string;
+  file_path: string;
+  line: number;
+  package: string;
+  text: string;
+  /** Qualified name of the function it is in; absent at top level */
+  function?: string;
+  /** Panics in a function only */
+  recover?: RecoverStatus;
+  /** Callers that recover it, nearest first */
+  recovered_by?: Array<UsageDefinition & { depth: number }>;
+  /** Where it leaves the code unrecovered: callers with no callers of their own, or functions started with go */
+  escapes_via?: UsageDefinition[];
+}
+
+export interface ExitReport {
+  /** Go files scanned */
+  files: number;
+  by_kind: Record<ExitKind, number>;
+  /** In file and line order */
+  sites: ExitSite[];
+  /** "depth", "deadline"; empty when every caller chain was followed to its end */
+  truncated: CallTruncation[];
+}
+
+export interface ExitOptions extends UsageOptions {
+  /** Only sites in files under this path prefix */
+  path?: string;
+  /** Levels of callers to search for a recover */
+  depth?: number;
+}
+
+export const DEFAULT_CALLER_DEPTH = 5;
+export const DEFAULT_CALLER_FAN_OUT = 25;
+export const DEFAULT_MAX_CALLERS = 200;
+
+interface Target extends UsageDefinition {
+  dir: string;
+  base: string;
+  member: boolean;
+  isType: boolean;
+  go: boolean;
+  import_path?: string;
+  /** JavaScript and TypeScript: the name in the nearest package.json */
+  package_name?: string;
+  /** Declared in a Go var or const block */
+  grouped?: boolean;
+}
+
+interface ParsedFile {
+  doc: DocumentMeta;
+  lines: string[];
+  blanked: string[];
+  symbols: CodeSymbol[];
+  imports: Map<string, string>;
+  /** JavaScript and TypeScript: the module specifiers it imports */
+  modules: string[];
+}
+
+export interface UsageOptions {
+  /** Reference sites in test files: counted (default), left out, or the only ones counted */
+  include_tests?: IncludeTests;
+}
+
+const emptyKinds = (): Record<UsageKind, number> => ({ call: 0, type_use: 0, embed: 0, value: 0 });
+
+/**
+ * A report's sites by the collection they are in: the defining
+ * collections first, then the busiest.
+ */
+export function referencesByCollection(report: UsageReport): CollectionReferences[] {
+  const defining = new Set(report.definitions.map((d) => d.collection));
+  const groups = new Map<string, CollectionReferences & { paths: Set<string> }>();
+  for (const site of report.sites) {
+    let group = groups.get(site.collection);
+    if (!group) {
+      group = { collection: site.collection, external: !defining.has(site.collection), total: 0, by_kind: emptyKinds(), files: 0, sites: [], paths: new Set() };
+      groups.set(site.collection, group);
+    }
+    group.total++;
+    group.by_kind[site.kind]++;
+    group.paths.add(site.file_path);
+    group.sites.push(site);
+  }
+  return [...groups.values()]
+    .map(({ paths, ...group }) => ({ ...group, files: paths.size }))
+    .sort((a, b) => Number(a.external) - Number(b.external) || b.total - a.total || a.collection.localeCompare(b.collection));
+}
+
+/** Where a symbol of `file` is keyed in the definitions map. */
+const definitionKey = (doc_id: string, line: number, base: string) => `${doc_id}:${line}:${base}`;
+
+/**
+ * Whether the call on 0-based line `i` of `file`, in function `fn`,
+ * passes on the error it may fail with, and whether it wraps it.
+ */
+function passesOn(file: ParsedFile, i: number, fn: CodeSymbol): { propagates: boolean; wraps: boolean } {
+  if (file.doc.file_path.endsWith(".go")) {
+    for (let j = i; j < Math.min(i + 4, fn.line_end); j++) {
+      if (RETURNS.test(file.blanked[j]) && (j === i || /\berr\b/.test(file.blanked[j]))) {
+        return { propagates: true, wraps: WRAPS.test(file.lines[j]) };
+      }
+    }
+    return { propagates: false, wraps: false };
+  }
+  // Inside a try block when a line above, indented less, opens one
+  const indent = (line: string) => line.length - line.trimStart().length;
+  let depth = indent(file.blanked[i]);
+  for (let j = i - 1; j >= fn.line_start - 1; j--) {
+    const line = file.blanked[j];
+    if (!line.trim() || indent(line) >= depth) continue;
+    if (/^\s*(?:\}\s*)?try\b/.test(line)) return { propagates: false, wraps: false };
+    depth = indent(line);
+    if (depth === 0) break;
+  }
+  return { propagates: true, wraps: false };
+}
+
+/** Calls that end the process or unwind the goroutine, tried in order on a line with literals blanked. */
+const EXIT_CALLS: Array<{ kind: ExitKind; pattern: RegExp }> = [
+  { kind: "exit", pattern: /\b(?:os|syscall)\.Exit\(/g },
+  { kind: "panic", pattern: /(?<![\w.])panic\(/g },
+  { kind: "panic", pattern: /\b\w+\.(?:Panic|Panicf|Panicln|Panicw|DPanic|DPanicf|DPanicw)\(/g },
+  // testing's t.Fatal ends a test, not the process
+  { kind: "fatal", pattern: /\b(?!(?:t|b|tb|f)\.)\w+\.(?:Fatal|Fatalf|Fatalln|Fatalw)\(/g },
+  { kind: "fatal", pattern: /\b(?:klog|glog)\.(?:Exit|Exitf|Exitln)\(/g },
+];
+
+/** Whether lines of `body` (blanked) defer a recover: a deferred closure calling it, or a deferred call to `recovers`. */
+function defersRecover(body: string[], recovers: (name: string) => boolean): boolean {
+  const text = body.join("\n");
+  if (/\bdefer\s+func\b/.test(text) && /(?<![\w.])recover\(\s*\)/.test(text)) return true;
+  for (const m of text.matchAll(/\bdefer\s+(?:[\w]+\.)*(\w+)\(/g)) if (recovers(m[1])) return true;
+  return false;
+}
+
+/** The innermost symbol of `file` spanning `line`, imports aside. */
+function innermostSymbol(file: ParsedFile, line: number): CodeSymbol | undefined {
+  return file.symbols
+    .filter((s) => s.kind !== "import" && s.line_start <= line && line <= s.line_end)
+    .sort((a, b) => a.line_end - a.line_start - (b.line_end - b.line_start))[0];
+}
+
+/** Usage queries over the code collections of a store. */
+export class UsageStats {
+  private readonly cache: FileScanCache<ParsedFile>;
+
+  constructor(config: IndexConfig, private readonly goModules?: GoModuleIndex) {
+    this.cache = new FileScanCache(config, (source, meta) => ({
+      doc: meta,
+      lines: source.split("\n"),
+      blanked: blankLiterals(source, hashCommentsFor(meta.file_path)).split("\n"),
+      symbols: parseCodeSymbols(source, meta.file_path),
+      imports: meta.file_path.endsWith(".go") ? goImports(source) : new Map(),
+      modules: languageFamily(meta.file_path) === "js" ? jsImports(source) : [],
+    }));
+  }
+
+  /**
+   * References to every definition of `symbol`: a name (Connect), a
+   * member (Server.Start), or a package-qualified name (db.Connect, or
+   * with a full import path or directory). Returns null when nothing by
+   * that name is defined.
+   */
+  async symbol(store: DocumentStore, symbol: string, options: UsageOptions = {}): Promise<UsageReport | null> {
+    const files = await this.files(store);
+    const targets = await this.named(files, symbol);
+    if (targets.length === 0) return null;
+    return this.report("symbol", symbol, targets, this.scan(files, targets, options), files.length);
+  }
+
+  /** Definitions of `symbol` as symbol() reads it. */
+  private async named(files: ParsedFile[], symbol: string): Promise<Target[]> {
+    const dot = symbol.lastIndexOf(".");
+    const name = dot > 0 ? symbol.slice(dot + 1) : symbol;
+    const qualifier = dot > 0 ? symbol.slice(0, dot) : undefined;
+
+    const all = (await this.definitions(files)).filter((t) => t.base === name);
+    let targets = all;
+    if (qualifier !== undefined) {
+      const members = all.filter((t) => t.member && t.name === symbol);
+      targets = members.length
+        ? members
+        : all.filter(
+            (t) =>
+              !t.member &&
+              (t.import_path === qualifier || t.dir === qualifier || posix.basename(t.dir) === qualifier || t.package === qualifier)
+          );
+    }
+    return targets;
+  }
+
+  /**
+   * References to the symbol defined around `line` of the code document
+   * `doc_id`, the innermost one when they nest: what a location URI
+   * names. Returns null when no symbol spans that line.
+   */
+  async symbolAt(store: DocumentStore, doc_id: string, line: number, options: UsageOptions = {}): Promise<UsageReport | null> {
+    const files = await this.files(store);
+    const targets = await this.located(files, doc_id, line);
+    if (targets.length === 0) return null;
+    return this.report("symbol", targets[0].name, targets, this.scan(files, targets, options), files.length);
+  }
+
+  /** The definition around `line` of `doc_id`, as symbolAt() reads it. */
+  private async located(files: ParsedFile[], doc_id: string, line: number): Promise<Target[]> {
+    const file = files.find((f) => f.doc.doc_id === doc_id);
+    const at = file && innermostSymbol(file, line);
+    if (!file || !at) return [];
+    const defined = await this.definitions([file]);
+    const grouped = defined.filter((t) => t.grouped && t.line === line);
+    if (grouped.length) return grouped;
+    return defined.filter((t) => t.base === at.name && t.line === at.line_start);
+  }
+
+  /**
+   * References from other packages to the top-level symbols of a package
+   * (a Go import path or a directory), exported ones only for Go. Returns
+   * null when no indexed code file lives in that directory.
+   */
+  async package(store: DocumentStore, query: string, options: UsageOptions = {}): Promise<UsageReport | null> {
+    const files = await this.files(store);
+    const located = this.goModules ? await this.goModules.locatePackage(query) : null;
+    const colon = query.indexOf(":");
+    const named = !located && colon > 0 && this.cache.root(query.slice(0, colon)) !== undefined;
+    const collection = located?.collection ?? (named ? query.slice(0, colon) : undefined);
+    const dir = (located?.dir ?? (named ? query.slice(colon + 1) : query)).replace(/^\.?\/+|\/+$/g, "");
+
+    const inPackage = files.filter(
+      (f) => posix.dirname(f.doc.file_path).replace(/^\.$/, "") === dir && (!collection || f.doc.collection === collection)
+    );
+    if (inPackage.length === 0) return null;
+    const ids = new Set(inPackage.map((f) => f.doc.doc_id));
+    const targets = (await this.definitions(files)).filter(
+      (t) => ids.has(t.doc_id) && !t.member && (!t.go || /^\p{Lu}/u.test(t.base))
+    );
+    const sites = this.scan(files, targets, options).filter(
+      (s) => !targets.some((t) => t.collection === s.collection && t.package === s.package)
+    );
+    const report = this.report("package", query, targets, sites, files.length);
+
+    const symbols = new Map<string, SymbolUsage>();
+    for (const t of targets) {
+      if (!symbols.has(t.name)) symbols.set(t.name, { name: t.name, kind: t.kind, total: 0, by_kind: emptyKinds(), packages: 0 });
+    }
+    const consumers = new Map<string, Set<string>>();
+    for (const site of sites) {
+      const entry = symbols.get(site.name)!;
+      entry.total++;
+      entry.by_kind[site.kind]++;
+      if (!consumers.has(site.name)) consumers.set(site.name, new Set());
+      consumers.get(site.name)!.add(`${site.collection}\0${site.package}`);
+    }
+    for (const [name, packages] of consumers) symbols.get(name)!.packages = packages.size;
+    report.symbols = [...symbols.values()].sort((a, b) => b.total - a.total || a.name.localeCompare(b.name));
+    return report;
+  }
+
+  /**
+   * Everything that eventually calls `target` (a name as symbol() takes
+   * it, or the symbol around a line): the functions whose bodies hold a
+   * call to it, then their callers, breadth first. Stops at
+   * `options.depth` levels, keeps `fan_out` new callers per function per
+   * level and `max_nodes` in all, and says which limits cut it short.
+   * Only "call" references count; methods match any `.Name(` call, so
+   * closures through common method names overreach. Returns null when
+   * nothing by that name is defined.
+   */
+  async callers(
+    store: DocumentStore,
+    target: string | { doc_id: string; line: number },
+    options: CallerOptions = {}
+  ): Promise<CallerReport | null> {
+    const files = await this.files(store);
+    const roots = typeof target === "string" ? await this.named(files, target) : await this.located(files, target.doc_id, target.line);
+    if (roots.length === 0) return null;
+    const maxDepth = options.depth ?? DEFAULT_CALLER_DEPTH;
+    const fanOut = options.fan_out ?? DEFAULT_CALLER_FAN_OUT;
+    const maxNodes = options.max_nodes ?? DEFAULT_MAX_CALLERS;
+
+    const key = (doc_id: string, line: number, base: string) => `${doc_id}:${line}:${base}`;
+    const definitions = new Map((await this.definitions(files)).map((t) => [key(t.doc_id, t.line, t.base), t]));
+    const byDoc = new Map(files.map((f) => [f.doc.doc_id, f]));
+    const rootKeys = new Set(roots.map((t) => key(t.doc_id, t.line, t.base)));
+    const nodes = new Map<string, CallerNode>();
+    const top_level: UsageSite[] = [];
+    const truncated = new Set<CallTruncation>();
+    const deadline = currentDeadline();
+
+    let frontier = roots;
+    for (let level = 1; frontier.length > 0; level++) {
+      if (deadline?.expired()) {
+        truncated.add("deadline");
+        break;
+      }
+      // New callers per callee, in file and line order
+      const fresh = new Map<string, Map<string, { caller: Target; sites: UsageSite[] }>>();
+      for (const site of this.scan(files, frontier, options)) {
+        if (site.kind !== "call") continue;
+        const symbol = innermostSymbol(byDoc.get(site.doc_id)!, site.line);
+        const k = symbol && key(site.doc_id, symbol.line_start, symbol.name);
+        const caller = k ? definitions.get(k) : undefined;
+        if (!caller) {
+          top_level.push(site);
+          continue;
+        }
+        if (rootKeys.has(k!)) continue;
+        const known = nodes.get(k!);
+        if (known) {
+          if (!known.calls.includes(site.name)) known.calls.push(site.name);
+          known.sites.push({ line: site.line, text: site.text });
+          continue;
+        }
+        if (!fresh.has(site.name)) fresh.set(site.name, new Map());
+        const entry = fresh.get(site.name)!.get(k!) ?? { caller, sites: [] };
+        entry.sites.push(site);
+        fresh.get(site.name)!.set(k!, entry);
+      }
+      if (fresh.size === 0) break;
+      if (level > maxDepth) {
+        truncated.add("depth");
+        break;
+      }
+
+      const next: Target[] = [];
+      for (const [callee, callers] of fresh) {
+        let kept = 0;
+        for (const [k, { caller, sites }] of callers) {
+          const known = nodes.get(k);
+          if (known) {
+            // Found through another callee at this level
+            if (!known.calls.includes(callee)) known.calls.push(callee);
+            known.sites.push(...sites.map((s) => ({ line: s.line, text: s.text })));
+            continue;
+          }
+          if (kept === fanOut) {
+            truncated.add("fan_out");
+            break;
+          }
+          if (nodes.size === maxNodes) {
+            truncated.add("nodes");
+            break;
+          }
+          const { name, kind, collection, doc_id, file_path, line, package: pkg } = caller;
+          nodes.set(k, {
+            name,
+            kind,
+            collection,
+            doc_id,
+            file_path,
+            line,
+            package: pkg,
+            depth: level,
+            calls: [callee],
+            sites: sites.map((s) => ({ line: s.line, text: s.text })),
+          });
+          next.push(caller);
+          kept++;
+        }
+      }
+      if (truncated.has("nodes")) break;
+      frontier = next;
+    }
+
+    const callers = [...nodes.values()]
+      .map((node) => ({ ...node, sites: node.sites.sort((a, b) => a.line - b.line) }))
+      .sort((a, b) => a.depth - b.depth || a.file_path.localeCompare(b.file_path) || a.line - b.line);
+    return {
+      target: typeof target === "string" ? target : roots[0].name,
+      definitions: roots.map(({ name, kind, collection, doc_id, file_path, line, package: pkg }) => ({
+        name,
+        kind,
+        collection,
+        doc_id,
+        file_path,
+        line,
+        package: pkg,
+      })),
+      files: files.length,
+      depth: callers.reduce((d, c) => Math.max(d, c.depth), 0),
+      callers,
+      top_level,
+      truncated: (["depth", "fan_out", "nodes", "deadline"] as const).filter((t) => truncated.has(t)),
+    };
+  }
+
+  /**
+   * Where the error `target` (a name as symbol() takes it, or the symbol
+   * around a line) is created, wrapped, compared, and returned, and the
+   * callers it travels up through: the functions that create, wrap, or
+   * return it, then their callers whose calls pass the error on, breadth
+   * first, for `options.depth` levels and `max_nodes` callers in all.
+   * Callers that do not pass it on are listed and not followed. Returns
+   * null when nothing by that name is defined.
+   */
+  async traceError(
+    store: DocumentStore,
+    target: string | { doc_id: string; line: number },
+    options: ErrorTraceOptions = {}
+  ): Promise<ErrorTrace | null> {
+    const files = await this.files(store);
+    const roots = typeof target === "string" ? await this.named(files, target) : await this.located(files, target.doc_id, target.line);
+    if (roots.length === 0) return null;
+    const maxDepth = options.depth ?? DEFAULT_ERROR_DEPTH;
+    const maxNodes = options.max_nodes ?? DEFAULT_MAX_CALLERS;
+
+    const definitions = new Map((await this.definitions(files)).map((t) => [definitionKey(t.doc_id, t.line, t.base), t]));
+    const byDoc = new Map(files.map((f) => [f.doc.doc_id, f]));
+    const enclosing = (doc_id: string, line: number) => {
+      const symbol = innermostSymbol(byDoc.get(doc_id)!, line);
+      if (!symbol || (symbol.kind !== "function" && symbol.kind !== "method")) return undefined;
+      const key = definitionKey(doc_id, symbol.line_start, symbol.name);
+      const fn = definitions.get(key);
+      return fn && { key, fn, symbol };
+    };
+    const definition = ({ name, kind, collection, doc_id, file_path, line, package: pkg }: Target): UsageDefinition => ({
+      name,
+      kind,
+      collection,
+      doc_id,
+      file_path,
+      line,
+      package: pkg,
+    });
+
+    const sites: ErrorSite[] = [];
+    for (const root of roots) {
+      const text = byDoc.get(root.doc_id)!.lines[root.line - 1].trim();
+      if (!root.isType && /[^=!<>:]=[^=]|:=/.test(text)) {
+        sites.push({ role: "created", name: root.name, doc_id: root.doc_id, file_path: root.file_path, line: root.line, package: root.package, text });
+      }
+    }
+    const origins = new Map<string, Target>();
+    const checked = new Set<string>();
+    for (const site of this.scan(files, roots, options)) {
+      const root = roots.find((r) => r.name === site.name)!;
+      // A Go method's receiver is the type's own declaration, not a use
+      if (root.go && root.isType && new RegExp(String.raw`^func\s*\(\s*\w*\s*\*?\s*${root.base}\s*\)`).test(site.text)) continue;
+      const role = classifyErrorUse(site.text, root.base, root.isType);
+      const at = enclosing(site.doc_id, site.line);
+      const { doc_id, file_path, line, package: pkg, text } = site;
+      sites.push({ role, name: site.name, doc_id, file_path, line, package: pkg, text, ...(at ? { function: at.fn.name } : {}) });
+      if (!at) continue;
+      if (role === "compared") checked.add(at.key);
+      else if (role !== "other") origins.set(at.key, at.fn);
+    }
+    sites.sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+
+    const chain = new Map<string, ErrorHop>();
+    const truncated = new Set<CallTruncation>();
+    const deadline = currentDeadline();
+    let frontier = [...origins.values()];
+    for (let level = 1; frontier.length > 0; level++) {
+      if (deadline?.expired()) {
+        truncated.add("deadline");
+        break;
+      }
+      // New callers at this level, in file and line order
+      const fresh = new Map<string, ErrorHop & { target: Target }>();
+      for (const site of this.scan(files, frontier, options)) {
+        if (site.kind !== "call") continue;
+        const at = enclosing(site.doc_id, site.line);
+        if (!at || origins.has(at.key)) continue;
+        const pass = passesOn(byDoc.get(site.doc_id)!, site.line - 1, at.symbol);
+        const hop = chain.get(at.key) ?? fresh.get(at.key);
+        if (hop) {
+          if (!hop.calls.includes(site.name)) hop.calls.push(site.name);
+          hop.sites.push({ line: site.line, text: site.text });
+          hop.propagates ||= pass.propagates;
+          hop.wraps ||= pass.wraps;
+          continue;
+        }
+        fresh.set(at.key, {
+          ...definition(at.fn),
+          target: at.fn,
+          depth: level,
+          calls: [site.name],
+          sites: [{ line: site.line, text: site.text }],
+          ...pass,
+          checks: checked.has(at.key),
+        });
+      }
+      if (fresh.size === 0) break;
+      if (level > maxDepth) {
+        truncated.add("depth");
+        break;
+      }
+      const next: Target[] = [];
+      for (const [key, { target: fn, ...hop }] of fresh) {
+        if (chain.size === maxNodes) {
+          truncated.add("nodes");
+          break;
+        }
+        chain.set(key, hop);
+        if (hop.propagates) next.push(fn);
+      }
+      if (truncated.has("nodes")) break;
+      frontier = next;
+    }
+
+    const by_role = Object.fromEntries(ERROR_ROLES.map((r) => [r, 0])) as Record<ErrorRole, number>;
+    for (const site of sites) by_role[site.role]++;
+    return {
+      target: typeof target === "string" ? target : roots[0].name,
+      definitions: roots.map(definition),
+      files: files.length,
+      by_role,
+      sites,
+      origins: [...origins.values()].map(definition).sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line),
+      chain: [...chain.values()]
+        .map((hop) => ({ ...hop, sites: hop.sites.sort((a, b) => a.line - b.line) }))
+        .sort((a, b) => a.depth - b.depth || a.file_path.localeCompare(b.file_path) || a.line - b.line),
+      truncated: (["depth", "nodes", "deadline"] as const).filter((t) => truncated.has(t)),
+    };
+  }
+
+  /**
+   * Every panic, fatal log call, and os.Exit in the Go code, with its
+   * function and, for panics, where a recover stops it, searching
+   * `options.depth` levels of callers. Callers that recover are not
+   * followed further, nor calls made with `go`.
+   */
+  async exits(store: DocumentStore, options: ExitOptions = {}): Promise<ExitReport> {
+    const files = (await this.files(store)).filter((f) => f.doc.file_path.endsWith(".go"));
+    const maxDepth = options.depth ?? DEFAULT_CALLER_DEPTH;
+    const definitions = new Map((await this.definitions(files)).map((t) => [definitionKey(t.doc_id, t.line, t.base), t]));
+    const byDoc = new Map(files.map((f) => [f.doc.doc_id, f]));
+    const enclosing = (doc_id: string, line: number) => {
+      const symbol = innermostSymbol(byDoc.get(doc_id)!, line);
+      if (!symbol || (symbol.kind !== "function" && symbol.kind !== "method")) return undefined;
+      const key = definitionKey(doc_id, symbol.line_start, symbol.name);
+      const fn = definitions.get(key);
+      return fn && { key, fn, symbol };
+    };
+    const definition = ({ name, kind, collection, doc_id, file_path, line, package: pkg }: Target): UsageDefinition => ({
+      name,
+      kind,
+      collection,
+      doc_id,
+      file_path,
+      line,
+      package: pkg,
+    });
+
+    // Functions whose bodies defer a recover, by key; a deferred named function is looked up in its package
+    const recovering = new Map<string, boolean>();
+    const recovers = (key: string, fn: Target): boolean => {
+      const known = recovering.get(key);
+      if (known !== undefined) return known;
+      const result = defersRecover(this.body(byDoc.get(fn.doc_id)!, fn), (name) =>
+        [...definitions.values()].some(
+          (t) =>
+            t.base === name &&
+            t.collection === fn.collection &&
+            t.dir === fn.dir &&
+            (t.kind === "function" || t.kind === "method") &&
+            /(?<![\w.])recover\(\s*\)/.test(this.body(byDoc.get(t.doc_id)!, t).join("\n"))
+        )
+      );
+      recovering.set(key, result);
+      return result;
+    };
+
+    const sites: ExitSite[] = [];
+    const prefix = options.path?.replace(/^\.?\/+/, "");
+    for (const file of files) {
+      if (!matchesTests(file.doc.file_path, options.include_tests)) continue;
+      if (prefix && !file.doc.file_path.startsWith(prefix)) continue;
+      const dir = posix.dirname(file.doc.file_path).replace(/^\.$/, "");
+      const pkg = this.packageOf(file.doc.collection, dir, true);
+      for (let i = 0; i < file.blanked.length; i++) {
+        const line = file.blanked[i];
+        const taken = new Set<number>();
+        for (const { kind, pattern } of EXIT_CALLS) {
+          for (const m of line.matchAll(pattern)) {
+            if (taken.has(m.index!)) continue;
+            taken.add(m.index!);
+            const at = enclosing(file.doc.doc_id, i + 1);
+            sites.push({
+              kind,
+              call: m[0].slice(0, -1),
+              doc_id: file.doc.doc_id,
+              file_path: file.doc.file_path,
+              line: i + 1,
+              package: pkg,
+              text: file.lines[i].trim(),
+              ...(at ? { function: at.fn.name } : {}),
+            });
+          }
+        }
+      }
+    }
+    sites.sort((a, b) => a.file_path.localeCompare(b.file_path) || a.line - b.line);
+
+    // Callers of each function, found one level at a time for all panicking functions together
+    const callersOf = new Map<string, Array<{ key: string; fn: Target; spawned: boolean }>>();
+    const lookup = (targets: Array<{ key: string; fn: Target }>) => {
+      const missing = targets.filter((t) => !callersOf.has(t.key));
+      for (const t of missing) callersOf.set(t.key, []);
+      if (missing.length === 0) return;
+      const byName = new Map<string, string[]>();
+      for (const t of missing) byName.set(t.fn.name, [...(byName.get(t.fn.name) ?? []), t.key]);
+      for (const site of this.scan(files, missing.map((t) => t.fn), options)) {
+        if (site.kind !== "call") continue;
+        const at = enclosing(site.doc_id, site.line);
+        if (!at) continue;
+        const spawned = /^\s*go\b/.test(byDoc.get(site.doc_id)!.blanked[site.line - 1]);
+        for (const key of byName.get(site.name) ?? []) {
+          const list = callersOf.get(key)!;
+          if (at.key !== key && !list.some((c) => c.key === at.key && c.spawned === spawned)) list.push({ ...at, spawned });
+        }
+      }
+    };
+
+    const truncated = new Set<CallTruncation>();
+    const deadline = currentDeadline();
+    type Search = { guards: Map<string, UsageDefinition & { depth: number }>; escapes: Map<string, UsageDefinition>; seen: Set<string>; frontier: Array<{ key: string; fn: Target }> };
+    const searches = new Map<string, Search>();
+    for (const site of sites) {
+      if (site.kind !== "panic" || !site.function) continue;
+      const at = enclosing(site.doc_id, site.line)!;
+      if (searches.has(at.key) || recovers(at.key, at.fn)) continue;
+      searches.set(at.key, { guards: new Map(), escapes: new Map(), seen: new Set([at.key]), frontier: [at] });
+    }
+    for (let level = 1; [...searches.values()].some((s) => s.frontier.length > 0); level++) {
+      if (deadline?.expired()) {
+        truncated.add("deadline");
+        break;
+      }
+      if (level > maxDepth) {
+        truncated.add("depth");
+        break;
+      }
+      lookup([...searches.values()].flatMap((s) => s.frontier));
+      for (const search of searches.values()) {
+        const next: Array<{ key: string; fn: Target }> = [];
+        for (const callee of search.frontier) {
+          const callers = callersOf.get(callee.key)!;
+          if (callers.length === 0) search.escapes.set(callee.key, definition(callee.fn));
+          for (const caller of callers) {
+            if (caller.spawned) {
+              // A new goroutine: nothing above the go statement can recover it
+              search.escapes.set(callee.key, definition(callee.fn));
+              continue;
+            }
+            if (search.seen.has(caller.key)) continue;
+            search.seen.add(caller.key);
+            if (recovers(caller.key, caller.fn)) search.guards.set(caller.key, { ...definition(caller.fn), depth: level });
+            else next.push(caller);
+          }
+        }
+        search.frontier = next;
+      }
+    }
+
+    const byPath = (a: UsageDefinition, b: UsageDefinition) => a.file_path.localeCompare(b.file_path) || a.line - b.line;
+    for (const site of sites) {
+      if (site.kind !== "panic" || !site.function) continue;
+      const at = enclosing(site.doc_id, site.line)!;
+      const search = searches.get(at.key);
+      if (!search) {
+        site.recover = "local";
+        continue;
+      }
+      const guards = [...search.guards.values()].sort((a, b) => a.depth - b.depth || byPath(a, b));
+      const escapes = [...search.escapes.values()].sort(byPath);
+      site.recover = guards.length === 0 ? "none" : escapes.length === 0 && search.frontier.length === 0 ? "upstream" : "partial";
+      if (guards.length) site.recovered_by = guards;
+      if (escapes.length) site.escapes_via = escapes;
+    }
+
+    const by_kind = Object.fromEntries(EXIT_KINDS.map((k) => [k, 0])) as Record<ExitKind, number>;
+    for (const site of sites) by_kind[site.kind]++;
+    return { files: files.length, by_kind, sites, truncated: (["depth", "deadline"] as const).filter((t) => truncated.has(t)) };
+  }
+
+  /** Lines of `target`'s definition in `file`, literals blanked. */
+  private body(file: ParsedFile, target: Target): string[] {
+    const symbol = file.symbols.find((s) => s.line_start === target.line && s.name === target.base);
+    return symbol ? file.blanked.slice(symbol.line_start - 1, symbol.line_end) : [];
+  }
+
+  private report(scope: UsageReport["scope"], target: string, targets: Target[], sites: UsageSite[], files: number): UsageReport {
+    const key = (collection: string, pkg: string) => `${collection}\0${pkg}`;
+    const defining = new Set(targets.map((t) => key(t.collection, t.package)));
+    const consumers = new Map<string, PackageUsage & { paths: Set<string> }>();
+    const by_kind = emptyKinds();
+    for (const site of sites) {
+      by_kind[site.kind]++;
+      const at = key(site.collection, site.package);
+      let entry = consumers.get(at);
+      if (!entry) {
+        entry = {
+          package: site.package,
+          collection: site.collection,
+          internal: defining.has(at),
+          total: 0,
+          by_kind: emptyKinds(),
+          files: 0,
+          paths: new Set(),
+        };
+        consumers.set(at, entry);
+      }
+      entry.total++;
+      entry.by_kind[site.kind]++;
+      entry.paths.add(site.file_path);
+    }
+    return {
+      scope,
+      target,
+      definitions: targets.map(({ name, kind, collection, doc_id, file_path, line, package: pkg }) => ({
+        name,
+        kind,
+        collection,
+        doc_id,
+        file_path,
+        line,
+        package: pkg,
+      })),
+      files,
+      total: sites.length,
+      by_kind,
+      consumers: [...consumers.values()]
+        .map(({ paths, ...usage }) => ({ ...usage, files: paths.size }))
+        .sort((a, b) => b.total - a.total || a.package.localeCompare(b.package)),
+      sites,
+    };
+  }
+
+  /** References to `targets` across `files`, in file and line order. */
+  private scan(files: ParsedFile[], targets: Target[], options: UsageOptions): UsageSite[] {
+    const byName = new Map<string, Target[]>();
+    for (const t of targets) {
+      if (!byName.has(t.base)) byName.set(t.base, []);
+      byName.get(t.base)!.push(t);
+    }
+    const sites: UsageSite[] = [];
+    for (const file of files) {
+      if (!matchesTests(file.doc.file_path, options.include_tests)) continue;
+      const go = file.doc.file_path.endsWith(".go");
+      const lang = languageFamily(file.doc.file_path);
+      const dir = posix.dirname(file.doc.file_path).replace(/^\.$/, "");
+      const pkg = this.packageOf(file.doc.collection, dir, go);
+      const imported = new Set(file.imports.values());
+      // A reference from another collection comes through an import of the defining package
+      const importsPackage = (t: Target) => {
+        if (t.go) return t.import_path !== undefined && imported.has(t.import_path);
+        if (lang !== "js") return true;
+        const name = t.package_name;
+        return name !== undefined && file.modules.some((m) => m === name || m.startsWith(`${name}/`));
+      };
+      for (let i = 0; i < file.blanked.length; i++) {
+        const line = file.blanked[i];
+        if (!go && IMPORT_LINE.test(line)) continue;
+        for (const m of line.matchAll(IDENTIFIER)) {
+          const candidates = byName.get(m[0]);
+          if (!candidates) continue;
+          const index = m.index!;
+          if (line[index - 1] && /[\p{L}\p{N}_$]/u.test(line[index - 1])) continue;
+          const qualifier = line.slice(0, index).match(QUALIFIER);
+          const target = candidates.find((t) => {
+            if (languageFamily(t.file_path) !== lang) return false;
+            if (t.doc_id === file.doc.doc_id && t.line === i + 1) return false;
+            if (t.collection !== file.doc.collection && !importsPackage(t)) return false;
+            if (t.member) return qualifier !== null;
+            if (!t.go) return true;
+            const home = t.collection === file.doc.collection && t.dir === dir;
+            if (home) return qualifier === null;
+            const imported = (alias: string) => {
+              const path = file.imports.get(alias);
+              if (path === undefined) return false;
+              return t.import_path ? path === t.import_path : t.dir !== "" && (path === t.dir || path.endsWith(`/${t.dir}`));
+            };
+            return qualifier ? imported(qualifier[1]) : imported(".");
+          });
+          if (!target) continue;
+          const start = qualifier && !target.member ? index - qualifier[0].length : index;
+          sites.push({
+            name: target.name,
+            kind: classifyUsage(line, start, index + m[0].length, target.isType),
+            collection: file.doc.collection,
+            doc_id: file.doc.doc_id,
+            file_path: file.doc.file_path,
+            line: i + 1,
+            package: pkg,
+            text: file.lines[i].trim(),
+          });
+        }
+      }
+    }
+    return sites;
+  }
+
+  /** Every symbol defined in `files`, imports aside. */
+  private async definitions(files: ParsedFile[]): Promise<Target[]> {
+    const targets: Target[] = [];
+    for (const file of files) {
+      const go = file.doc.file_path.endsWith(".go");
+      const dir = posix.dirname(file.doc.file_path).replace(/^\.$/, "");
+      const byId = new Map(file.symbols.map((s) => [s.id, s]));
+      const import_path = go ? await this.importPath(file.doc.collection, dir) : undefined;
+      const package_name = languageFamily(file.doc.file_path) === "js" ? await this.packageName(file.doc.collection, dir) : undefined;
+      for (const s of file.symbols) {
+        if (s.kind === "import") continue;
+        const parent = s.parent_id ? byId.get(s.parent_id) : undefined;
+        targets.push({
+          name: parent ? `${parent.name}.${s.name}` : s.name,
+          base: s.name,
+          kind: s.kind,
+          doc_id: file.doc.doc_id,
+          file_path: file.doc.file_path,
+          line: s.line_start,
+          package: this.packageOf(file.doc.collection, dir, go),
+          collection: file.doc.collection,
+          dir,
+          member: parent !== undefined,
+          isType: TYPE_KINDS.has(s.kind),
+          go,
+          ...(import_path ? { import_path } : {}),
+          ...(package_name ? { package_name } : {}),
+        });
+      }
+      if (!go) continue;
+      for (const { name, line } of groupedDeclarations(file.blanked)) {
+        targets.push({
+          name,
+          base: name,
+          kind: "variable",
+          doc_id: file.doc.doc_id,
+          file_path: file.doc.file_path,
+          line,
+          package: this.packageOf(file.doc.collection, dir, go),
+          collection: file.doc.collection,
+          dir,
+          member: false,
+          isType: false,
+          go,
+          grouped: true,
+          ...(import_path ? { import_path } : {}),
+        });
+      }
+    }
+    return targets;
+  }
+
+  private importPaths = new Map<string, string | undefined>();
+
+  /** Go import path of a directory, from the module graph. */
+  private async importPath(collection: string, dir: string): Promise<string | undefined> {
+    const key = `${collection}:${dir}`;
+    if (!this.importPaths.has(key) && this.goModules) {
+      const graph = await this.goModules.graph();
+      const mod = moduleForPath({ ...graph, modules: graph.modules.filter((m) => m.collection === collection) }, dir);
+      const rest = mod ? dir.slice(mod.dir.length).replace(/^\//, "") : "";
+      this.importPaths.set(key, mod ? (rest ? `${mod.module}/${rest}` : mod.module) : undefined);
+    }
+    return this.importPaths.get(key);
+  }
+
+  private packageNames = new Map<string, Promise<string | undefined>>();
+
+  /** Name in the package.json nearest `dir`, looking up to the collection root. */
+  private packageName(collection: string, dir: string): Promise<string | undefined> {
+    const key = `${collection}:${dir}`;
+    let found = this.packageNames.get(key);
+    if (!found) {
+      found = (async () => {
+        const root = this.cache.root(collection);
+        if (root === undefined) return undefined;
+        const name = await packageJsonName(join(root, dir, "package.json"));
+        if (name !== undefined || dir === "") return name;
+        return this.packageName(collection, posix.dirname(dir).replace(/^\.$/, ""));
+      })();
+      this.packageNames.set(key, found);
+    }
+    return found;
+  }
+
+  /** Consumer label: the Go import path when known, else the directory. */
+  private packageOf(collection: string, dir: string, go: boolean): string {
+    const known = go ? this.importPaths.get(`${collection}:${dir}`) : undefined;
+    return known ?? (dir || ".");
+  }
+
+  /** Parsed code files of the store. */
+  private async files(store: DocumentStore): Promise<ParsedFile[]> {
+    const files = await this.cache.scan(store);
+    for (const file of files) {
+      if (file.doc.file_path.endsWith(".go")) await this.importPath(file.doc.collection, posix.dirname(file.doc.file_path).replace(/^\.$/, ""));
+    }
+    return files.sort((a, b) => a.doc.file_path.localeCompare(b.doc.file_path));
+  }
+}
@@ -0,0 +1,71 @@
+/**
+ * Vendored dependencies — the VENDOR_POLICY setting
+ *
+ * A `vendor/` tree is somebody else's code checked into the repository:
+ * Go's `go mod vendor` output, or Composer's and Bundler's. Indexed like
+ * first-party code it crowds search results. The policy decides, for
+ * every directory named vendor under a code root:
+ *
+ *   index     index it like any other code (the default)
+ *   downrank  index it, but multiply its scores by VENDOR_WEIGHT
+ *   exclude   leave it out of file discovery, like an INCLUDE miss
+ *
+ * Go vendor trees are detected by their vendor/modules.txt, which lists
+ * the vendored modules and versions. With INDEX_DEPENDENCIES those
+ * modules would exist twice, once under vendor/ and once from the
+ * module cache, with every symbol duplicated; the module cache copy is
+ * dropped unless vendor/ is excluded.
+ */
+
+import { readFile } from "node:fs/promises";
+import { join, posix, resolve } from "node:path";
+import type { IndexConfig, PathBoost, VendorPolicy } from "./types";
+import type { GoModuleIndex } from "./go-modules";
+
+export const VENDOR_POLICIES: VendorPolicy[] = ["index", "downrank", "exclude"];
+export const DEFAULT_VENDOR_POLICY: VendorPolicy = "index";
+
+/** Score multiplier for vendored files under the downrank policy */
+export const VENDOR_WEIGHT = 0.3;
+
+/** True when a root-relative file or directory path lies inside a vendor/ tree. */
+export function isVendorPath(relPath: string): boolean {
+  return /(?:^|\/)vendor(?:\/|$)/.test(relPath);
+}
+
+/** The path boost that implements the downrank policy; none for the others. */
+export function vendorBoosts(policy: VendorPolicy): PathBoost[] {
+  return policy === "downrank" ? [{ pattern: "vendor/", weight: VENDOR_WEIGHT }] : [];
+}
+
+export interface VendorTree {
+  collection: string;
+  /** The vendor directory, relative to the collection root */
+  dir: string;
+  /** module@version entries from modules.txt */
+  modules: string[];
+}
+
+/** Module entries of a Go vendor/modules.txt: "# path version [=> replacement [version]]" lines. */
+export function parseVendorModules(text: string): string[] {
+  const modules: string[] = [];
+  for (const line of text.split("\n")) {
+    const m = line.match(/^# (\S+) (v\S+)/);
+    if (m) modules.push(`${m[1]}@${m[2]}`);
+  }
+  return modules;
+}
+
+/** Go vendor trees beside the go.mod files of the code collections. */
+export async function findVendorTrees(config: IndexConfig, goModules: GoModuleIndex): Promise<VendorTree[]> {
+  const roots = new Map((config.code_collections ?? []).map((c) => [c.name, resolve(c.root)]));
+  const trees: VendorTree[] = [];
+  for (const mod of (await goModules.graph()).modules) {
+    const root = roots.get(mod.collection);
+    if (!root) continue;
+    const dir = posix.join(mod.dir, "vendor");
+    const text = await readFile(join(root, dir, "modules.txt"), "utf-8").catch(() => null);
+    if (text !== null) trees.push({ collection: mod.collection, dir, modules: parseVendorModules(text) });
+  }
+  return trees;
+}
@@ -0,0 +1,157 @@
+/**
+ * File discovery with an explicit symlink policy
+ *
+ * Collections used to be listed with Bun.Glob.scan(), whose symlink
+ * behavior was all-or-nothing: either symlinked packages (pnpm
+ * workspaces, docs vendored in via a link) were silently missed, or a
+ * cyclic link recursed without end. walkFiles() makes the choice
+ * explicit per collection (SYMLINKS):
+ *
+ *   skip         never follow symlinked files or directories
+ *   within-root  follow links whose target resolves inside the root (default)
+ *   all          follow every link, wherever it points
+ *
+ * The real tree is walked first and symlinks are queued until it is
+ * done, so a file reachable both directly and through a link is
+ * reported once, under its direct path. Every directory entered carries
+ * the real paths of its ancestors; a link that resolves to one of them
+ * is a loop and is skipped with a warning. Paths are reported as seen
+ * from the root (through the link), which keeps doc_ids stable when
+ * the link target moves.
+ *
+ * Like Bun.Glob.scan(), entries whose name starts with "." are skipped.
+ */
+
+import { readdir, realpath, stat } from "node:fs/promises";
+import { join, resolve } from "node:path";
+import type { SymlinkPolicy } from "./types";
+import { isWithin, relativePath } from "./paths";
+
+export const SYMLINK_POLICIES: SymlinkPolicy[] = ["skip", "within-root", "all"];
+export const DEFAULT_SYMLINK_POLICY: SymlinkPolicy = "within-root";
+
+interface PendingLink {
+  path: string;
+  /** Real paths of the directories enclosing the link */
+  ancestors: Set<string>;
+}
+
+/**
+ * List the absolute paths of files under `root` that pass `match`
+ * (called with the root-relative, "/"-separated path).
+ */
+export async function walkFiles(
+  root: string,
+  options: {
+    symlinks?: SymlinkPolicy;
+    match?: (relPath: string) => boolean;
+    /** Whether to descend into a directory (root-relative, "/"-separated) */
+    enter?: (relDir: string) => boolean;
+    /** Only this root-relative directory or file (reindex_path) */
+    under?: string;
+    /** Stop once more than this many files match; the result then holds limit + 1 */
+    limit?: number;
+    log?: (msg: string) => void;
+  } = {}
+): Promise<string[]> {
+  const policy = options.symlinks ?? DEFAULT_SYMLINK_POLICY;
+  const match = options.match ?? (() => true);
+  const wanted = options.enter ?? (() => true);
+  const under = options.under ?? "";
+  // Inside `under`, or on the way down to it
+  const enter = (relDir: string) => (isUnder(relDir, under) || isUnder(under, relDir)) && wanted(relDir);
+  const log = options.log ?? ((msg: string) => console.error(msg));
+  const limit = options.limit ?? Infinity;
+
+  const absRoot = resolve(root);
+  let realRoot: string;
+  try {
+    realRoot = await realpath(absRoot);
+  } catch {
+    return [];
+  }
+
+  const files: string[] = [];
+  const seenFiles = new Set<string>();
+  const links: PendingLink[] = [];
+
+  const emit = (path: string, real: string) => {
+    if (seenFiles.has(real)) return;
+    const relPath = relativePath(absRoot, path);
+    if (!isUnder(relPath, under) || !match(relPath)) return;
+    seenFiles.add(real);
+    files.push(path);
+  };
+
+  const visit = async (dir: string, ancestors: Set<string>, realDir: string) => {
+    if (dir !== absRoot && !enter(relativePath(absRoot, dir))) return;
+    let entries;
+    try {
+      entries = await readdir(dir, { withFileTypes: true });
+    } catch {
+      return;
+    }
+    entries.sort((a, b) => (a.name < b.name ? -1 : a.name > b.name ? 1 : 0));
+
+    for (const entry of entries) {
+      if (files.length > limit) return;
+      if (entry.name.startsWith(".")) continue;
+      const path = join(dir, entry.name);
+      if (entry.isSymbolicLink()) {
+        if (policy !== "skip") links.push({ path, ancestors });
+      } else if (entry.isDirectory()) {
+        const real = join(realDir, entry.name);
+        await visit(path, new Set(ancestors).add(real), real);
+      } else if (entry.isFile()) {
+        emit(path, join(realDir, entry.name));
+      }
+    }
+  };
+
+  await visit(absRoot, new Set([realRoot]), realRoot);
+
+  // Links last, so direct paths win; links found inside linked
+  // directories join the same queue
+  while (links.length > 0 && files.length <= limit) {
+    const { path, ancestors } = links.shift()!;
+
+    let real: string;
+    let isDirectory: boolean;
+    try {
+      real = await realpath(path);
+      isDirectory = (await stat(real)).isDirectory();
+    } catch {
+      continue; // dangling link
+    }
+
+    if (policy === "within-root" && !isWithin(realRoot, real)) continue;
+
+    if (!isDirectory) {
+      emit(path, real);
+    } else if (ancestors.has(real)) {
+      log(`Skipping symlink loop: ${path} -> ${real}`);
+    } else {
+      await visit(path, new Set(ancestors).add(real), real);
+    }
+  }
+
+  return files;
+}
+
+/**
+ * Thrown when a collection lists more files than a document quota
+ * allows, before any of them is read.
+ */
+export class DocumentQuotaError extends Error {}
+
+/** Throw DocumentQuotaError when a listing of `name` went past `maxFiles`. */
+export function checkFileQuota(name: string, files: string[], maxFiles: number | undefined): void {
+  if (maxFiles !== undefined && files.length > maxFiles) {
+    throw new DocumentQuotaError(`[${name}] more than ${maxFiles} files left to index`);
+  }
+}
+
+/** Whether root-relative `relPath` is `dir` or inside it ("" is the whole root). */
+export function isUnder(relPath: string, dir: string): boolean {
+  return dir === "" || relPath === dir || relPath.startsWith(`${dir}/`);
+}
@@ -0,0 +1,124 @@
+/**
+ * Priority warm-up — PRIORITY_FILES, PRIORITY_RECENT_COMMITS
+ *
+ * A cold start parses every file before the server answers its first
+ * query, which on a large repository takes minutes. Most questions at
+ * the start of a session are about the code someone is working on, so
+ * a priority list lets that part be indexed first:
+ *
+ *   PRIORITY_FILES            a manifest, one path per line; blank lines
+ *                             and # comments are skipped
+ *   PRIORITY_RECENT_COMMITS   files changed by the last N commits under
+ *                             each collection root (git log)
+ *
+ * Manifest paths are relative to a collection root, or absolute. Both
+ * lists are matched against the collections the way discovery would
+ * (globs, INCLUDE, dot-directories), so a priority list never indexes
+ * a file a full pass would not; paths that match no collection, or no
+ * longer exist, are skipped.
+ *
+ * The server starts serving once the priority files are indexed. The
+ * remaining files are indexed in the background by the pass that
+ * re-validates a warm start (revalidateIndex), and the store reports
+ * `isValidating()` until it finishes. This applies to cold starts only:
+ * a warm start from INDEX_CACHE already serves every file at once.
+ */
+
+import { existsSync, statSync } from "node:fs";
+import { readFile } from "node:fs/promises";
+import { isAbsolute, resolve } from "node:path";
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import { indexFile } from "./indexer";
+import { CODE_GLOB, indexCodeFile, isCodeCandidate, isCodeFile } from "./code-indexer";
+import { isIncluded } from "./coverage";
+import { sniffExtension } from "./language-detect";
+import { gitRecentFiles } from "./git-history";
+import { isWithin, relativePath } from "./paths";
+
+export interface PriorityOptions {
+  /** PRIORITY_FILES: manifest path */
+  manifest?: string;
+  /** PRIORITY_RECENT_COMMITS: commits of history per collection root (0 = none) */
+  recent_commits?: number;
+  log?: (msg: string) => void;
+}
+
+/** One file to index ahead of the rest. */
+export interface PriorityFile {
+  path: string;
+  collection: CollectionConfig;
+  kind: "markdown" | "code";
+}
+
+/** Manifest lines that name a path, in order. */
+export function parseManifest(text: string): string[] {
+  return text
+    .split("\n")
+    .map((line) => line.trim())
+    .filter((line) => line && !line.startsWith("#"));
+}
+
+/**
+ * The priority files of `config`, manifest entries first, then recently
+ * changed files newest first, each once. Empty when neither is set.
+ */
+export async function priorityFiles(config: IndexConfig, options: PriorityOptions): Promise<PriorityFile[]> {
+  const log = options.log ?? ((msg: string) => console.error(msg));
+  const targets = [
+    ...config.collections.map((collection) => ({ collection, kind: "markdown" as const, glob: new Bun.Glob(collection.glob_pattern || "**/*.md") })),
+    ...(config.code_collections ?? []).map((collection) => ({ collection, kind: "code" as const, glob: new Bun.Glob(collection.glob_pattern || CODE_GLOB) })),
+  ];
+  const files: PriorityFile[] = [];
+  const seen = new Set<string>();
+  const add = async (target: (typeof targets)[number], path: string) => {
+    if (!isWithin(target.collection.root, path)) return;
+    const posix = relativePath(target.collection.root, path);
+    if (posix === "") return;
+    // Discovery skips dot-directories (.git, .treenav); so do we
+    if (posix.split("/").some((segment) => segment.startsWith("."))) return;
+    const matches = target.kind === "code" ? isCodeCandidate(target.collection, target.glob, posix) : target.glob.match(posix);
+    if (!matches || !isIncluded(target.collection, posix)) return;
+    const key = `${target.collection.name}\0${posix}`;
+    if (seen.has(key) || !existsSync(path) || !statSync(path).isFile()) return;
+    if (target.kind === "code" && !isCodeFile(path) && !(await sniffExtension(path))) return;
+    seen.add(key);
+    files.push({ path, collection: target.collection, kind: target.kind });
+  };
+
+  if (options.manifest) {
+    let entries: string[] = [];
+    try {
+      entries = parseManifest(await readFile(options.manifest, "utf-8"));
+    } catch (err: any) {
+      log(`Warning: PRIORITY_FILES ${options.manifest} not read: ${err.message}`);
+    }
+    for (const entry of entries) {
+      for (const target of targets) await add(target, isAbsolute(entry) ? entry : resolve(target.collection.root, entry));
+    }
+  }
+  if (options.recent_commits) {
+    for (const target of targets) {
+      for (const relPath of gitRecentFiles(target.collection.root, options.recent_commits)) {
+        await add(target, resolve(target.collection.root, relPath));
+      }
+    }
+  }
+  return files;
+}
+
+/** Parse the priority files; ones that fail are left to the full pass. */
+export async function indexPriorityFiles(files: PriorityFile[]): Promise<IndexedDocument[]> {
+  const BATCH_SIZE = 50;
+  const results: IndexedDocument[] = [];
+  for (let i = 0; i < files.length; i += BATCH_SIZE) {
+    const indexed = await Promise.all(
+      files.slice(i, i + BATCH_SIZE).map(({ path, collection, kind }) =>
+        (kind === "markdown" ? indexFile(path, collection.root, collection.name) : indexCodeFile(path, collection.root, collection.name)).catch(
+          () => null
+        )
+      )
+    );
+    results.push(...(indexed.filter(Boolean) as IndexedDocument[]));
+  }
+  return results;
+}
@@ -0,0 +1,124 @@
+/**
+ * WASM ranking hooks — RANKING_WASM
+ *
+ * A relevance experiment should not need a new server build. With
+ * RANKING_WASM=<file.wasm> the server loads a small WebAssembly module
+ * and calls it on the top search candidates (MAX_RESCORE_CANDIDATES),
+ * after BM25 and every built-in boost. The scores it returns replace
+ * the old ones, negative scores drop the candidate, and the results
+ * are re-sorted. Every search goes through it: search_documents,
+ * find_symbol, multi_search, and the REST and gRPC APIs.
+ *
+ * The module's ABI is numeric, so it can be written in anything that
+ * compiles to WASM (Rust, AssemblyScript, TinyGo, hand-written WAT):
+ *
+ *   (func (export "rescore")
+ *     (param $score f64)     score after built-in ranking
+ *     (param $rank i32)      0-based position in that order
+ *     (param $code i32)      1 for code, 0 for markdown
+ *     (param $level i32)     heading level, or symbol nesting depth
+ *     (param $age_days f64)  days since the file last changed
+ *     (param $path i32) (param $path_len i32)   file path, UTF-8
+ *     (result f64))          new score; negative or NaN drops it
+ *
+ * A module that wants the path exports `memory` and
+ * `alloc(bytes: i32) -> i32`. alloc is called once per search for all
+ * candidate paths together, and the buffer is only valid until the
+ * next call, so a bump allocator that resets on alloc is enough.
+ * Without alloc, $path and $path_len are 0.
+ *
+ * The module gets no imports: no WASI, no files, no network, no clock.
+ * A trap (e.g. unreachable) keeps the built-in ranking for that search
+ * and is logged. WASM runs synchronously, so a module that loops
+ * forever blocks the server; keep it to arithmetic.
+ */
+
+import type { DocumentMeta, SearchResult } from "./types";
+import type { Rescorer } from "./store";
+
+export class RankingWasmError extends Error {}
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+type RescoreExport = (
+  score: number,
+  rank: number,
+  code: number,
+  level: number,
+  ageDays: number,
+  path: number,
+  pathLen: number
+) => number;
+
+/**
+ * A Rescorer backed by the compiled module `bytes`. Throws
+ * RankingWasmError when the module is invalid, needs imports, or lacks
+ * the exports above.
+ */
+export async function wasmRescorer(
+  bytes: BufferSource,
+  options: { log?: (msg: string) => void; now?: () => number } = {}
+): Promise<Rescorer> {
+  const log = options.log ?? ((msg: string) => console.error(msg));
+  const now = options.now ?? Date.now;
+
+  let instance: WebAssembly.Instance;
+  try {
+    ({ instance } = await WebAssembly.instantiate(bytes, {}));
+  } catch (err: any) {
+    throw new RankingWasmError(`invalid module: ${err.message}`);
+  }
+  const exports = instance.exports;
+  if (typeof exports.rescore !== "function") throw new RankingWasmError('missing export "rescore"');
+  const rescore = exports.rescore as RescoreExport;
+  const alloc = typeof exports.alloc === "function" ? (exports.alloc as (bytes: number) => number) : null;
+  const memory = exports.memory instanceof WebAssembly.Memory ? exports.memory : null;
+  if (alloc && !memory) throw new RankingWasmError('exports "alloc" but no "memory"');
+
+  const encoder = new TextEncoder();
+  let traps = 0;
+
+  return (candidates: { result: SearchResult; meta: DocumentMeta }[]) => {
+    try {
+      // All paths in one buffer, so alloc runs once per search
+      const paths = alloc ? candidates.map(({ result }) => encoder.encode(result.file_path)) : [];
+      let offset = 0;
+      if (alloc) {
+        const total = paths.reduce((sum, p) => sum + p.length, 0);
+        offset = alloc(total);
+        const view = new Uint8Array(memory!.buffer, offset, total);
+        let at = 0;
+        for (const p of paths) {
+          view.set(p, at);
+          at += p.length;
+        }
+      }
+
+      const time = now();
+      return candidates.map(({ result, meta }, rank) => {
+        const modified = Date.parse(meta.last_modified);
+        const ageDays = Number.isNaN(modified) ? 0 : Math.max(0, (time - modified) / DAY_MS);
+        const code = meta.facets.content_type?.includes("code") ? 1 : 0;
+        const len = alloc ? paths[rank].length : 0;
+        const score = rescore(result.score, rank, code, result.level, ageDays, alloc ? offset : 0, len);
+        offset += len;
+        return score;
+      });
+    } catch (err: any) {
+      traps++;
+      log(`Warning: RANKING_WASM trapped (${traps} so far), keeping built-in ranking: ${err.message}`);
+      return candidates.map(({ result }) => result.score);
+    }
+  };
+}
+
+/** Read and instantiate the module at `path` (RANKING_WASM). */
+export async function loadRankingWasm(path: string, options: { log?: (msg: string) => void } = {}): Promise<Rescorer> {
+  const file = Bun.file(path);
+  if (!(await file.exists())) throw new RankingWasmError(`${path}: file not found`);
+  try {
+    return await wasmRescorer(await file.arrayBuffer(), options);
+  } catch (err: any) {
+    throw err instanceof RankingWasmError ? new RankingWasmError(`${path}: ${err.message}`) : err;
+  }
+}
@@ -0,0 +1,362 @@
+/**
+ * File watcher — debounced, batched incremental re-indexing
+ *
+ * With WATCH=1 the server watches every collection root and keeps the
+ * store in step with the working tree, so edits show up in search
+ * without a restart.
+ *
+ * Change events are not applied one at a time. They collect in a
+ * pending set and are flushed together once the tree has been quiet
+ * for `debounceMs` (WATCH_DEBOUNCE_MS). A steady stream of events
+ * cannot postpone the flush forever: it also fires once the oldest
+ * event is MAX_WAIT_FACTOR × debounceMs old.
+ *
+ * A flush of up to `batchSize` files (WATCH_BATCH_SIZE) re-hashes and
+ * re-parses just those files and applies them with one addDocuments()
+ * call. A larger flush is a rename storm — `git checkout`, a rebase, a
+ * bulk format — and is coalesced into a single revalidateIndex() pass
+ * instead, which skips unchanged files by content hash and rebuilds
+ * corpus stats once. A renamed or deleted directory reports only the
+ * directory itself, so it forces the full pass too. While that pass
+ * runs the store reports isValidating() so tools flag results as
+ * possibly stale.
+ *
+ * The queue is bounded by the same rule. Once the pending flush is
+ * known to be a full pass, the paths themselves no longer matter, so
+ * they are dropped and only counted: a `git pull` touching a hundred
+ * thousand files holds at most `batchSize` paths. Flushes never overlap
+ * or pile up. Events that arrive while one runs wait for it, at most
+ * one follow-up is queued, and the follow-up waits a full debounce
+ * after the running flush ends. During a sustained write storm the
+ * server therefore alternates between one pass and a quiet interval
+ * that leaves room for queries, instead of re-validating back to back.
+ * queueStatus() (the `reindex` field of the store's stats, /health,
+ * and md-tree://stats) shows the queue depth.
+ */
+
+import { watch, existsSync, statSync, type FSWatcher } from "node:fs";
+import { extname, resolve } from "node:path";
+import type { CollectionConfig, IndexConfig, IndexedDocument } from "./types";
+import type { DocumentStore } from "./store";
+import { indexFile, markdownDocId } from "./indexer";
+import { CODE_GLOB, codeDocId, indexCodeFile, isCodeCandidate, isCodeFile } from "./code-indexer";
+import { detectExtension } from "./language-detect";
+import { revalidateIndex } from "./index-cache";
+import { isIncluded } from "./coverage";
+import { readSource } from "./encoding";
+import { isWithin, relativePath } from "./paths";
+
+export const DEFAULT_WATCH_DEBOUNCE_MS = 250;
+export const DEFAULT_WATCH_BATCH_SIZE = 200;
+
+/** A flush fires at the latest this many debounce intervals after the first pending event. */
+const MAX_WAIT_FACTOR = 20;
+
+export interface WatchFlushReport {
+  /** "incremental" for per-file updates, "full" for a coalesced revalidation */
+  mode: "incremental" | "full";
+  files: number;
+  updated: number;
+  removed: number;
+  failed: number;
+  elapsed_ms: number;
+}
+
+/** The watcher's queue, as reported in the store's stats. */
+export interface WatchQueueStatus {
+  /** Changes waiting; once the next flush is a full pass, events counted rather than distinct files */
+  pending: number;
+  /** The next flush re-validates everything instead of re-indexing the pending files */
+  full_pass: boolean;
+  /** A flush is running */
+  flushing: boolean;
+  /** How long the oldest pending change has waited */
+  oldest_ms: number;
+  /** The last finished flush */
+  last_flush?: WatchFlushReport;
+}
+
+interface WatchedCollection {
+  collection: CollectionConfig;
+  kind: "markdown" | "code";
+  root: string;
+  glob: InstanceType<typeof Bun.Glob>;
+}
+
+export class IndexWatcher {
+  private readonly collections: WatchedCollection[];
+  private readonly debounceMs: number;
+  private readonly batchSize: number;
+  private readonly log: (msg: string) => void;
+  private readonly onFlush?: (report: WatchFlushReport) => void;
+
+  private watchers: FSWatcher[] = [];
+  private pending = new Set<string>();
+  // Once the next flush is a full pass, changes are counted here instead
+  private dropped = 0;
+  private structural = false;
+  private firstPendingAt = 0;
+  private timer: ReturnType<typeof setTimeout> | null = null;
+  private active: Promise<void> | null = null;
+  private queued: Promise<void> | null = null;
+  private lastFlushEnd = 0;
+  private lastReport: WatchFlushReport | null = null;
+
+  constructor(
+    private readonly store: DocumentStore,
+    private readonly config: IndexConfig,
+    options: {
+      debounceMs?: number;
+      batchSize?: number;
+      log?: (msg: string) => void;
+      onFlush?: (report: WatchFlushReport) => void;
+    } = {}
+  ) {
+    this.debounceMs = options.debounceMs ?? DEFAULT_WATCH_DEBOUNCE_MS;
+    this.batchSize = options.batchSize ?? DEFAULT_WATCH_BATCH_SIZE;
+    this.log = options.log ?? ((msg: string) => console.error(msg));
+    this.onFlush = options.onFlush;
+
+    this.collections = [
+      ...config.collections.map((collection) => ({
+        collection,
+        kind: "markdown" as const,
+        root: resolve(collection.root),
+        glob: new Bun.Glob(collection.glob_pattern || "**/*.md"),
+      })),
+      ...(config.code_collections ?? []).map((collection) => ({
+        collection,
+        kind: "code" as const,
+        root: resolve(collection.root),
+        glob: new Bun.Glob(collection.glob_pattern || CODE_GLOB),
+      })),
+    ];
+  }
+
+  /** Start watching every collection root. */
+  start(): void {
+    for (const { root } of this.collections) {
+      if (!existsSync(root)) continue;
+      const watcher = watch(root, { recursive: true }, (_event, filename) => {
+        if (filename) this.notify(resolve(root, filename.toString()));
+      });
+      watcher.on("error", (err) => this.log(`Watcher error on ${root}: ${err.message}`));
+      this.watchers.push(watcher);
+    }
+    this.store.setReindexStatus(() => this.queueStatus());
+    this.log(
+      `Watching ${this.watchers.length} root(s) (debounce ${this.debounceMs}ms, batch size ${this.batchSize})`
+    );
+  }
+
+  stop(): void {
+    for (const watcher of this.watchers) watcher.close();
+    this.watchers = [];
+    if (this.timer) clearTimeout(this.timer);
+    this.timer = null;
+    this.store.setReindexStatus(null);
+  }
+
+  /** Record a changed path and (re)arm the debounce timer. */
+  notify(path: string, now: number = Date.now()): void {
+    if (!this.locate(path)) {
+      if (!this.isDirectoryChange(path)) return;
+      this.structural = true;
+    }
+    if (this.pendingCount() === 0) this.firstPendingAt = Math.max(now, this.lastFlushEnd);
+    if (this.structural || (this.pending.size >= this.batchSize && !this.pending.has(path))) {
+      // A full pass re-reads every file; which ones changed no longer matters
+      this.dropped += this.pending.size + (this.pending.has(path) ? 0 : 1);
+      this.pending.clear();
+      this.structural = true;
+    } else {
+      this.pending.add(path);
+    }
+    this.arm(now);
+  }
+
+  /** Changes waiting for the next flush. */
+  pendingCount(): number {
+    return this.pending.size + this.dropped;
+  }
+
+  queueStatus(now: number = Date.now()): WatchQueueStatus {
+    const pending = this.pendingCount();
+    return {
+      pending,
+      full_pass: this.structural,
+      flushing: this.active !== null,
+      oldest_ms: pending > 0 ? Math.max(0, now - this.firstPendingAt) : 0,
+      ...(this.lastReport ? { last_flush: this.lastReport } : {}),
+    };
+  }
+
+  /**
+   * Apply everything pending now. Flushes never overlap: called while
+   * one runs, this queues a single follow-up that takes everything
+   * pending once the running flush is done.
+   */
+  flush(): Promise<void> {
+    if (this.queued) return this.queued;
+    if (this.active) {
+      this.queued = this.active.then(() => {
+        this.queued = null;
+        return this.flush();
+      });
+      return this.queued;
+    }
+    this.active = this.run().finally(() => {
+      this.active = null;
+      this.lastFlushEnd = Date.now();
+      // Events that arrived meanwhile wait a quiet interval, not the overdue deadline
+      if (this.pendingCount() > 0) {
+        this.firstPendingAt = Math.max(this.firstPendingAt, this.lastFlushEnd);
+        this.arm(this.lastFlushEnd);
+      }
+    });
+    return this.active;
+  }
+
+  // ── Internals ───────────────────────────────────────────────────────
+
+  private arm(now: number): void {
+    if (this.timer) clearTimeout(this.timer);
+    this.timer = null;
+    // The flush that ends will re-arm
+    if (this.active) return;
+    const overdue = now - this.firstPendingAt >= this.debounceMs * MAX_WAIT_FACTOR;
+    this.timer = setTimeout(() => {
+      this.timer = null;
+      void this.flush();
+    }, overdue ? 0 : this.debounceMs);
+  }
+
+  private async run(): Promise<void> {
+    if (this.pendingCount() === 0) return;
+    const paths = [...this.pending];
+    const files = this.pendingCount();
+    const structural = this.structural;
+    this.pending.clear();
+    this.dropped = 0;
+    this.structural = false;
+
+    try {
+      const report = structural
+        ? await this.fullPass(files)
+        : await this.incremental(paths);
+      this.log(
+        `Re-indexed ${report.files} changed file(s) (${report.mode}) in ${report.elapsed_ms}ms — ` +
+          `${report.updated} updated, ${report.removed} removed, ${report.failed} failed`
+      );
+      this.lastReport = report;
+      this.onFlush?.(report);
+    } catch (err: any) {
+      this.log(`Warning: watch re-index failed: ${err.message}`);
+    }
+  }
+
+  private async incremental(paths: string[]): Promise<WatchFlushReport> {
+    const start = Date.now();
+    const docs: IndexedDocument[] = [];
+    let removed = 0;
+    let failed = 0;
+
+    for (const path of paths) {
+      const target = this.locate(path)!;
+      const relPath = relativePath(target.root, path);
+      const docId = target.kind === "markdown"
+        ? markdownDocId(target.collection.name, relPath)
+        : codeDocId(target.collection.name, relPath);
+
+      if (!existsSync(path)) {
+        if (this.store.hasDocument(docId)) {
+          this.store.removeDocument(docId);
+          removed++;
+        }
+        continue;
+      }
+
+      try {
+        const raw = (await readSource(path)).text;
+        // An extensionless file that no longer reads as a script leaves the index
+        if (target.kind === "code" && !isCodeFile(path) && !detectExtension(relPath, raw)) {
+          if (this.store.hasDocument(docId)) {
+            this.store.removeDocument(docId);
+            removed++;
+          }
+          continue;
+        }
+        if (this.store.getDocMeta(docId)?.content_hash === Bun.hash(raw).toString(16)) continue;
+        docs.push(
+          target.kind === "markdown"
+            ? await indexFile(path, target.collection.root, target.collection.name)
+            : await indexCodeFile(path, target.collection.root, target.collection.name)
+        );
+      } catch {
+        failed++;
+      }
+    }
+
+    this.store.addDocuments(docs);
+    return {
+      mode: "incremental",
+      files: paths.length,
+      updated: docs.length,
+      removed,
+      failed,
+      elapsed_ms: Date.now() - start,
+    };
+  }
+
+  private async fullPass(files: number): Promise<WatchFlushReport> {
+    this.store.beginValidating();
+    try {
+      const report = await revalidateIndex(this.store, this.config);
+      return {
+        mode: "full",
+        files,
+        updated: report.updated.length + report.added.length,
+        removed: report.removed.length,
+        failed: report.failed.length,
+        elapsed_ms: report.elapsed_ms,
+      };
+    } finally {
+      this.store.endValidating();
+    }
+  }
+
+  /** The collection a path belongs to, or null when no glob matches it. */
+  private locate(path: string): WatchedCollection | null {
+    for (const target of this.collections) {
+      const relPath = this.relativeTo(target, path);
+      if (relPath === null) continue;
+      const matches = target.kind === "code" ? isCodeCandidate(target.collection, target.glob, relPath) : target.glob.match(relPath);
+      if (!matches || !isIncluded(target.collection, relPath)) continue;
+      // Without an extension the path may be a directory; a script is a file, or was indexed as one
+      if (target.kind === "code" && !isCodeFile(path) && !this.isFileOrIndexed(path, codeDocId(target.collection.name, relPath))) continue;
+      return target;
+    }
+    return null;
+  }
+
+  private isFileOrIndexed(path: string, docId: string): boolean {
+    if (existsSync(path)) return statSync(path).isFile();
+    return this.store.hasDocument(docId);
+  }
+
+  /** A directory under a watched root, existing or (judging by its name) just removed. */
+  private isDirectoryChange(path: string): boolean {
+    if (!this.collections.some((target) => this.relativeTo(target, path) !== null)) return false;
+    if (existsSync(path)) return statSync(path).isDirectory();
+    return extname(path) === "";
+  }
+
+  private relativeTo(target: WatchedCollection, path: string): string | null {
+    if (!isWithin(target.root, path)) return null;
+    const relPath = relativePath(target.root, path);
+    if (relPath === "") return null;
+    // Glob scans skip dot-directories (.git, .treenav); so do we
+    if (relPath.split("/").some((segment) => segment.startsWith("."))) return null;
+    return relPath;
+  }
+}
@@ -0,0 +1,177 @@
+/**
+ * Embedded web UI — see what the agent sees
+ *
+ * With WEB_UI=1, serve:http serves one self-contained page at /ui: the
+ * indexed file tree, the section or symbol outline of the selected
+ * file, section content, and a search box. Every panel is filled from
+ * the REST routes (rest.ts, enabled along with the UI), so results rank
+ * exactly as search_documents ranks them. Hits show their BM25 score
+ * and matched terms, the raw material for debugging relevance.
+ *
+ * The page has no build step and no external assets. When HTTP_TOKEN
+ * is set it asks for the token once and keeps it in sessionStorage.
+ */
+
+/** Documents fetched per /documents page while building the file tree */
+const PAGE_SIZE = 100;
+
+const PAGE = `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>treenav index</title>
+<style>
+  body { margin: 0; font: 14px/1.4 system-ui, sans-serif; display: grid; grid-template: auto 1fr / 320px 320px 1fr; height: 100vh; }
+  header { grid-column: 1 / 4; display: flex; gap: 8px; padding: 8px; border-bottom: 1px solid #ddd; align-items: center; }
+  header input[type=search] { flex: 1; padding: 4px 8px; font: inherit; }
+  section { overflow: auto; padding: 8px; border-right: 1px solid #ddd; }
+  h2 { font-size: 12px; text-transform: uppercase; color: #666; margin: 0 0 8px; }
+  ul { list-style: none; margin: 0; padding-left: 14px; }
+  li > span, .hit { cursor: pointer; }
+  li > span:hover, .hit:hover { background: #eef; }
+  .dir { color: #555; }
+  .selected { background: #dde; }
+  .hit { border-bottom: 1px solid #eee; padding: 4px 0; }
+  .meta { color: #777; font-size: 12px; }
+  pre { white-space: pre-wrap; font: 13px/1.45 ui-monospace, monospace; }
+  #status { color: #a00; }
+</style>
+</head>
+<body>
+<header>
+  <strong>treenav</strong>
+  <input type="search" id="q" placeholder="Search (same engine and syntax as search_documents)">
+  <label><input type="checkbox" id="symbols"> symbols only</label>
+  <span id="status"></span>
+</header>
+<section><h2 id="left-title">Files</h2><div id="left"></div></section>
+<section><h2>Outline</h2><div id="outline"></div></section>
+<section><h2 id="content-title">Content</h2><div id="content"></div></section>
+<script>
+const PAGE_SIZE = ${PAGE_SIZE};
+const $ = (id) => document.getElementById(id);
+const el = (tag, text, cls) => { const e = document.createElement(tag); if (text != null) e.textContent = text; if (cls) e.className = cls; return e; };
+const enc = encodeURIComponent;
+
+async function api(path) {
+  const token = sessionStorage.getItem("treenav-token");
+  const res = await fetch(path, { headers: token ? { authorization: "Bearer " + token } : {} });
+  if (res.status === 401) {
+    const entered = prompt("HTTP_TOKEN for this server");
+    if (entered) { sessionStorage.setItem("treenav-token", entered); return api(path); }
+  }
+  const body = await res.json();
+  if (!res.ok) { $("status").textContent = body.error || res.statusText; throw new Error(body.error); }
+  $("status").textContent = "";
+  return body;
+}
+
+async function loadFiles() {
+  const docs = [];
+  for (let offset = 0; ; offset += PAGE_SIZE) {
+    const page = await api("/documents?limit=" + PAGE_SIZE + "&offset=" + offset);
+    docs.push(...page.documents);
+    if (docs.length >= page.total || page.documents.length === 0) break;
+  }
+  const root = {};
+  for (const d of docs) {
+    let dir = (root[d.collection] ??= {});
+    const parts = d.file_path.split("/");
+    for (const part of parts.slice(0, -1)) dir = (dir[part + "/"] ??= {});
+    dir[parts[parts.length - 1]] = d;
+  }
+  $("left-title").textContent = "Files (" + docs.length + ")";
+  $("left").replaceChildren(renderDir(root));
+}
+
+function renderDir(dir) {
+  const ul = el("ul");
+  for (const name of Object.keys(dir).sort()) {
+    const entry = dir[name];
+    const li = el("li");
+    if (entry.doc_id) {
+      const label = el("span", name);
+      label.title = entry.doc_id;
+      label.onclick = () => openDoc(entry.doc_id, label);
+      li.append(label);
+    } else {
+      const label = el("span", name, "dir");
+      const children = renderDir(entry);
+      children.hidden = true;
+      label.onclick = () => (children.hidden = !children.hidden);
+      li.append(label, children);
+    }
+    ul.append(li);
+  }
+  return ul;
+}
+
+function select(node) {
+  for (const e of document.querySelectorAll(".selected")) e.classList.remove("selected");
+  node?.classList.add("selected");
+}
+
+async function openDoc(docId, label, nodeId) {
+  select(label);
+  const tree = await api("/tree/" + enc(docId));
+  const byId = new Map(tree.nodes.map((n) => [n.node_id, n]));
+  const childIds = new Set(tree.nodes.flatMap((n) => n.children));
+  const render = (ids) => {
+    const ul = el("ul");
+    for (const id of ids) {
+      const n = byId.get(id);
+      if (!n) continue;
+      const li = el("li");
+      const span = el("span", n.title);
+      span.title = n.node_id + " (" + n.word_count + " words)";
+      span.onclick = () => openNode(docId, n.node_id, span);
+      li.append(span);
+      if (n.children.length) li.append(render(n.children));
+      ul.append(li);
+      if (n.node_id === nodeId) openNode(docId, n.node_id, span);
+    }
+    return ul;
+  };
+  $("outline").replaceChildren(el("div", tree.title + " — " + docId, "meta"), render(tree.nodes.filter((n) => !childIds.has(n.node_id)).map((n) => n.node_id)));
+}
+
+async function openNode(docId, nodeId, span) {
+  if (span) { for (const e of $("outline").querySelectorAll(".selected")) e.classList.remove("selected"); span.classList.add("selected"); }
+  const sub = await api("/node/" + enc(docId) + "/" + enc(nodeId));
+  $("content-title").textContent = sub.nodes[0]?.title ?? "Content";
+  $("content").replaceChildren(...sub.nodes.map((n) => {
+    const block = el("div");
+    block.append(el("div", "#".repeat(n.level) + " " + n.title + "  [" + n.node_id + ", lines " + n.line_start + "-" + n.line_end + "]", "meta"), el("pre", n.content || "(empty)"));
+    return block;
+  }));
+}
+
+async function search() {
+  const q = $("q").value.trim();
+  if (!q) return loadFiles();
+  const found = $("symbols").checked ? await api("/symbol/" + enc(q) + "?limit=50") : await api("/search?q=" + enc(q) + "&limit=50");
+  $("left-title").textContent = found.results.length + " results for " + q;
+  $("left").replaceChildren(...found.results.map((r, i) => {
+    const hit = el("div", null, "hit");
+    hit.append(el("div", (i + 1) + ". " + r.node_title), el("div", r.file_path + ":" + r.line_start, "meta"),
+      el("div", "score " + r.score.toFixed(2) + " · matched " + r.matched_terms.join(", "), "meta"), el("div", r.snippet, "meta"));
+    hit.onclick = () => openDoc(r.doc_id, hit, r.node_id);
+    return hit;
+  }));
+}
+
+let timer;
+$("q").oninput = () => { clearTimeout(timer); timer = setTimeout(search, 250); };
+$("symbols").onchange = search;
+loadFiles();
+</script>
+</body>
+</html>
+`;
+
+/** Answer /ui with the page, else return null. */
+export function handleWebUi(req: Request): Response | null {
+  const { pathname } = new URL(req.url);
+  if (pathname !== "/ui" && pathname !== "/ui/") return null;
+  return new Response(PAGE, { headers: { "content-type": "text/html; charset=utf-8" } });
+}
@@ -0,0 +1,187 @@
+/**
+ * Tests for the admin tools: re-indexing a subtree from disk, clearing
+ * query-time caches, and evicting documents.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { AdminError, IndexAdmin, queryCaches } from "../src/admin";
+import { indexAllCollections } from "../src/indexer";
+import { revalidateIndex } from "../src/index-cache";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+let dir: string;
+let config: IndexConfig;
+
+async function indexedStore(): Promise<DocumentStore> {
+  const store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+  return store;
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-admin-"));
+  await mkdir(join(dir, "guides"), { recursive: true });
+  await writeFile(join(dir, "guides", "auth.md"), "# Auth\n\nTokens are signed with HMAC.\n");
+  await writeFile(join(dir, "guides", "cache.md"), "# Cache\n\nTokens are cached for an hour.\n");
+  await writeFile(join(dir, "intro.md"), "# Intro\n\nStart here.\n");
+  config = {
+    collections: [{ name: "docs", root: dir, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("IndexAdmin.reindexPath", () => {
+  test("re-indexes changed files, adds new ones, and drops deleted ones under the path", async () => {
+    const store = await indexedStore();
+    await writeFile(join(dir, "guides", "auth.md"), "# Auth\n\nTokens are signed with Ed25519.\n");
+    await writeFile(join(dir, "guides", "quotas.md"), "# Quotas\n\nTen requests a second.\n");
+    await rm(join(dir, "guides", "cache.md"));
+    // Outside the path: left alone
+    await writeFile(join(dir, "intro.md"), "# Intro\n\nStart elsewhere.\n");
+
+    const [{ elapsed_ms, ...report }] = await new IndexAdmin(store, config).reindexPath("guides");
+    expect(elapsed_ms).toBeGreaterThanOrEqual(0);
+    expect(report).toEqual({
+      collection: "docs",
+      path: "guides",
+      checked: 2,
+      unchanged: 0,
+      added: ["docs:guides:quotas"],
+      updated: ["docs:guides:auth"],
+      removed: ["docs:guides:cache"],
+      failed: [],
+    });
+    expect(store.searchDocuments("Ed25519").map((r) => r.doc_id)).toEqual(["docs:guides:auth"]);
+    expect(store.searchDocuments("elsewhere")).toEqual([]);
+    expect(store.isValidating()).toBe(false);
+  });
+
+  test("leaves a validation pass it overlaps running", async () => {
+    const store = await indexedStore();
+    // A warm-start re-validation or watcher full pass still in flight
+    store.beginValidating();
+    await new IndexAdmin(store, config).reindexPath("guides");
+    expect(store.isValidating()).toBe(true);
+    expect(store.getStats().validating).toBe(true);
+    store.endValidating();
+    expect(store.isValidating()).toBe(false);
+  });
+
+  test("overlapping a real re-validation, validates until both have ended", async () => {
+    const store = await indexedStore();
+    let revalidated = false;
+    let reindexed = false;
+    store.beginValidating();
+    const revalidation = revalidateIndex(store, config).finally(() => {
+      store.endValidating();
+      revalidated = true;
+    });
+    const reindex = new IndexAdmin(store, config).reindexPath("guides").then(() => (reindexed = true));
+    await Promise.race([revalidation, reindex]);
+    expect(store.isValidating()).toBe(!(revalidated && reindexed));
+    await Promise.all([revalidation, reindex]);
+    expect(store.isValidating()).toBe(false);
+  });
+
+  test("takes a file, an absolute path, or the whole collection", async () => {
+    const store = await indexedStore();
+    const admin = new IndexAdmin(store, config);
+    const summary = async (path: string) => (await admin.reindexPath(path)).map((r) => [r.path, r.checked, r.unchanged]);
+    expect(await summary("guides/auth.md")).toEqual([["guides/auth.md", 1, 1]]);
+    expect(await summary(join(dir, "guides"))).toEqual([["guides", 2, 2]]);
+    expect(await summary(".")).toEqual([["", 3, 3]]);
+  });
+
+  test("a deleted directory still names its documents", async () => {
+    const store = await indexedStore();
+    await rm(join(dir, "guides"), { recursive: true });
+    const [report] = await new IndexAdmin(store, config).reindexPath("guides");
+    expect(report.removed.sort()).toEqual(["docs:guides:auth", "docs:guides:cache"]);
+    expect(store.hasDocument("docs:intro")).toBe(true);
+  });
+
+  test("rejects paths outside every collection and unknown collections", async () => {
+    const admin = new IndexAdmin(await indexedStore(), config);
+    await expect(admin.reindexPath("../elsewhere")).rejects.toThrow(AdminError);
+    await expect(admin.reindexPath("missing")).rejects.toThrow('"missing" is not a file or directory of any collection');
+    await expect(admin.reindexPath("guides", "wiki")).rejects.toThrow('Unknown collection "wiki"');
+  });
+});
+
+describe("IndexAdmin.clearCaches and evict", () => {
+  test("clears the named caches, or all, and rejects unknown names", () => {
+    const sizes = { staleness: 2, refs: 1 };
+    const caches = queryCaches({
+      staleness: { clear: () => sizes.staleness },
+      refs: { clear: () => sizes.refs },
+    });
+    expect(caches.map((c) => c.name)).toEqual(["stale", "refs"]);
+
+    const admin = new IndexAdmin(new DocumentStore(), config, { caches });
+    expect(admin.clearCaches(["refs"])).toEqual([{ name: "refs", entries: 1 }]);
+    expect(admin.clearCaches()).toEqual([
+      { name: "stale", entries: 2 },
+      { name: "refs", entries: 1 },
+    ]);
+    expect(() => admin.clearCaches(["licenses"])).toThrow('Unknown cache "licenses" (known: stale, refs)');
+  });
+
+  test("evicts by doc_id or file path and reports the rest as missing", async () => {
+    const store = await indexedStore();
+    const before = store.getGeneration();
+    const report = new IndexAdmin(store, config).evict(["docs:intro", "guides/auth.md", "guides/nope.md"]);
+    expect(report.evicted.map((d) => d.doc_id)).toEqual(["docs:intro", "docs:guides:auth"]);
+    expect(report.missing).toEqual(["guides/nope.md"]);
+    expect(store.hasDocument("docs:guides:cache")).toBe(true);
+    expect(store.getGeneration()).toBeGreaterThan(before);
+  });
+});
+
+describe("admin tools", () => {
+  test("are registered only with an IndexAdmin", async () => {
+    const docs = (await indexedStore()).exportDocuments();
+    const plain = await createMcpTestClient(docs);
+    const names = (await plain.client.listTools()).tools.map((t) => t.name);
+    expect(names).not.toContain("reindex_path");
+    expect(names).not.toContain("evict_file");
+    await plain.cleanup();
+
+    const harness = await createMcpTestClient(docs, { admin: (store) => new IndexAdmin(store, config) });
+    const tools = (await harness.client.listTools()).tools;
+    expect(tools.filter((t) => ["reindex_path", "clear_cache", "evict_file"].includes(t.name)).length).toBe(3);
+    expect(tools.find((t) => t.name === "evict_file")!.annotations?.destructiveHint).toBe(true);
+    await harness.cleanup();
+  });
+
+  test("reindex_path and evict_file report what changed", async () => {
+    const docs = (await indexedStore()).exportDocuments();
+    const harness = await createMcpTestClient(docs, { admin: (store) => new IndexAdmin(store, config) });
+    await writeFile(join(dir, "guides", "quotas.md"), "# Quotas\n\nTen requests a second.\n");
+
+    const reindexed = await harness.client.callTool({ name: "reindex_path", arguments: { path: "guides" } });
+    expect((reindexed.structuredContent as any).reports[0].added).toEqual(["docs:guides:quotas"]);
+    expect(getToolText(reindexed as any)).toContain("Re-indexed docs:guides in");
+    expect(getToolText(reindexed as any)).toContain("  added    docs:guides:quotas");
+
+    const evicted = await harness.client.callTool({ name: "evict_file", arguments: { targets: ["guides/quotas.md"] } });
+    expect((evicted.structuredContent as any).evicted.map((d: any) => d.doc_id)).toEqual(["docs:guides:quotas"]);
+    expect(harness.store.hasDocument("docs:guides:quotas")).toBe(false);
+
+    const none = await harness.client.callTool({ name: "evict_file", arguments: { targets: ["guides/quotas.md"] } });
+    expect((none.structuredContent as any).status).toBe("not_found");
+
+    const bad = await harness.client.callTool({ name: "reindex_path", arguments: { path: "../elsewhere" } });
+    expect(bad.isError).toBe(true);
+    await harness.cleanup();
+  });
+});
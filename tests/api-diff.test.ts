@@ -0,0 +1,188 @@
+/**
+ * Tests for api_diff: func shapes without names, struct fields, the
+ * breaking and additive rules, the semver bump, and the tool against a
+ * scratch repository.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ApiDiff, apiSurface, diffSurfaces, funcShape, parameterTypes, semverBump } from "../src/api-diff";
+import { GoModuleIndex } from "../src/go-modules";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { git, initRepo } from "./fixtures/git";
+
+const BEFORE = `package kv
+
+// Store holds values.
+type Store interface {
+	Get(key string) ([]byte, error)
+}
+
+type Options struct {
+	Path    string \`json:"path"\`
+	Timeout time.Duration
+}
+
+type Mem struct{}
+
+func (m Mem) Get(key string) ([]byte, error) { return nil, nil }
+
+func Open(path string, opts Options) (*Mem, error) { return nil, nil }
+
+func Legacy() {}
+
+const Version = "1"
+
+var ErrMissing = errors.New("missing")
+`;
+
+const AFTER = `package kv
+
+// Store holds values.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+type Options struct {
+	Path    string \`json:"path,omitempty"\`
+	Timeout int
+	Retries int
+}
+
+type Mem struct{}
+
+func (m *Mem) Get(k string) ([]byte, error) { return nil, nil }
+
+func Open(p string, o Options) (*Mem, error) { return nil, nil }
+
+func Dial(addr string) (*Mem, error) { return nil, nil }
+
+const Version = "2"
+
+var ErrMissing = errors.New("missing")
+`;
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-apidiff-"));
+  for (const sub of ["kv", "internal/x", "cmd/kv"]) await mkdir(join(dir, sub), { recursive: true });
+  await writeFile(join(dir, "go.mod"), "module example.com/kv\n\ngo 1.22\n");
+  await writeFile(join(dir, "kv", "store.go"), BEFORE);
+  await writeFile(join(dir, "internal", "x", "x.go"), "package x\n\nfunc Helper() {}\n");
+  await writeFile(join(dir, "cmd", "kv", "main.go"), "package main\n\nfunc main() {}\n");
+  initRepo(dir);
+  git(dir, ["tag", "v1.2.0"]);
+  await writeFile(join(dir, "kv", "store.go"), AFTER);
+  await writeFile(join(dir, "internal", "x", "x.go"), "package x\n\nfunc Helper(n int) {}\n");
+  await writeFile(join(dir, "cmd", "kv", "main.go"), "package main\n\nfunc main() {}\n\nfunc Run() {}\n");
+  await mkdir(join(dir, "kv", "cache"), { recursive: true });
+  await writeFile(join(dir, "kv", "cache", "cache.go"), "package cache\n\nfunc New() {}\n");
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: dir, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("shapes", () => {
+  test("drop receivers, parameter names, and bodies", () => {
+    expect(parameterTypes("a, b int, s ...string")).toEqual(["int", "int", "...string"]);
+    expect(parameterTypes("int, chan error")).toEqual(["int", "chan error"]);
+    expect(funcShape("func (s *S) Get(ctx context.Context, id string) (v *V, err error) {")).toBe("(context.Context, string) (*V, error)");
+    expect(funcShape("func Map[T any](xs []T, f func(T) T) []T")).toBe("[T any]([]T, func(T)T) []T");
+    expect(funcShape("func Open(p string, o Options) (*Mem, error)")).toBe(funcShape("func Open(path string, opts Options) (*Mem, error)"));
+  });
+
+  test("struct fields join the surface, tags aside", () => {
+    const surface = apiSurface([{ path: "kv/store.go", source: AFTER }]);
+    expect([...surface.keys()].filter((k) => k.startsWith("field"))).toEqual(["field Options.Path", "field Options.Timeout", "field Options.Retries"]);
+    expect(surface.get("field Options.Path")!.shape).toBe("string");
+  });
+});
+
+describe("diffSurfaces", () => {
+  test("sorts changes into breaking and additive", () => {
+    const changes = diffSurfaces(apiSurface([{ path: "kv/store.go", source: BEFORE }]), apiSurface([{ path: "kv/store.go", source: AFTER }]));
+    expect(changes.map((c) => [c.compat, c.change, c.kind, c.name])).toEqual([
+      ["additive", "added", "func", "Dial"],
+      ["breaking", "removed", "func", "Legacy"],
+      ["breaking", "changed", "method", "Mem.Get"],
+      ["additive", "added", "field", "Options.Retries"],
+      ["breaking", "changed", "field", "Options.Timeout"],
+      ["breaking", "added", "method", "Store.Delete"],
+    ]);
+    expect(changes.find((c) => c.name === "Mem.Get")!.reason).toBe("receiver is now a pointer: Mem values no longer have Get");
+  });
+
+  test("members of a removed type are not listed apart from it", () => {
+    const changes = diffSurfaces(apiSurface([{ path: "a.go", source: BEFORE }]), apiSurface([{ path: "a.go", source: "package kv\n" }]));
+    expect(changes.filter((c) => c.name.startsWith("Options")).map((c) => c.name)).toEqual(["Options"]);
+  });
+});
+
+describe("semverBump", () => {
+  test("major for breaking, minor from v0, patch when nothing changed", () => {
+    expect(semverBump("v1.2.0", { breaking: 1, additive: 0 })).toEqual({ bump: "major", next_version: "v2.0.0", v0: false });
+    expect(semverBump("v0.4.1", { breaking: 1, additive: 3 })).toEqual({ bump: "minor", next_version: "v0.5.0", v0: true });
+    expect(semverBump("1.2.3", { breaking: 0, additive: 0 })).toEqual({ bump: "patch", next_version: "1.2.4", v0: false });
+    expect(semverBump("main", { breaking: 0, additive: 2 })).toEqual({ bump: "minor", v0: false });
+  });
+});
+
+describe("ApiDiff", () => {
+  test("compares changed packages from the latest tag to the working tree", async () => {
+    const result = await new ApiDiff(config, new GoModuleIndex(config)).diff();
+    expect([result.base, result.head, result.packages, result.bump, result.next_version]).toEqual(["v1.2.0", "working tree", 2, "major", "v2.0.0"]);
+    expect(result.changes.map((c) => [c.package, c.compat, c.name])).toEqual([
+      ["example.com/kv/kv", "breaking", "Legacy"],
+      ["example.com/kv/kv", "breaking", "Mem.Get"],
+      ["example.com/kv/kv", "breaking", "Options.Timeout"],
+      ["example.com/kv/kv", "breaking", "Store.Delete"],
+      ["example.com/kv/kv", "additive", "Dial"],
+      ["example.com/kv/kv", "additive", "Options.Retries"],
+      ["example.com/kv/kv/cache", "additive", "example.com/kv/kv/cache"],
+    ]);
+    expect(result.notes[0]).toContain("module .../v2");
+  });
+
+  test("internal and main packages on request, and bad refs", async () => {
+    git(dir, ["add", "."]);
+    git(dir, ["commit", "-q", "-m", "next"]);
+    const diff = new ApiDiff(config);
+    const all = await diff.diff({ base: "v1.2.0", head: "HEAD", include_internal: true });
+    expect([...new Set(all.changes.map((c) => c.package))]).toEqual(["cmd/kv", "internal/x", "kv", "kv/cache"]);
+    expect((await diff.diff({ base: "HEAD" })).changes).toEqual([]);
+    await expect(diff.diff({ base: "nope" })).rejects.toThrow('Unknown ref "nope"');
+    await expect(diff.diff({ base: "--all" })).rejects.toThrow("Invalid ref");
+  });
+});
+
+describe("api_diff tool", () => {
+  test("reports the verdict and each change with both declarations", async () => {
+    const harness = await createMcpTestClient(new DocumentStore().exportDocuments(), { apiDiff: new ApiDiff(config, new GoModuleIndex(config)) });
+    const result = await harness.client.callTool({ name: "api_diff", arguments: { path: "kv", limit: 3 } });
+    const data = result.structuredContent as any;
+    expect([data.total, data.changes.length, data.by_compat]).toEqual([7, 3, { breaking: 4, additive: 3 }]);
+    const text = getToolText(result as any);
+    expect(text).toContain("API diff v1.2.0 → working tree: 2 changed package(s) compared; 4 breaking, 3 additive change(s). Bump: major (v1.2.0 → v2.0.0)");
+    expect(text).toContain("  breaking  changed  method Mem.Get — receiver is now a pointer: Mem values no longer have Get  kv/store.go:17");
+    expect(text).toContain("      - func (m Mem) Get(key string) ([]byte, error)");
+    expect(text).toContain("… 4 more; raise limit or narrow with path");
+
+    const bad = await harness.client.callTool({ name: "api_diff", arguments: { base: "nope" } });
+    expect(bad.isError).toBe(true);
+    await harness.cleanup();
+  });
+});
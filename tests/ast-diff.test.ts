@@ -0,0 +1,171 @@
+/**
+ * Tests for ast_diff: symbol matching, signature versus body changes,
+ * renames, container bodies, and the tool against a scratch repository.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { AstDiff, diffSymbols } from "../src/ast-diff";
+import { indexCodeFile, parseCodeSymbols } from "../src/code-indexer";
+import { gitShowFile } from "../src/git-history";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { initRepo } from "./fixtures/git";
+
+const BEFORE = `package svc
+
+type Server struct {
+	addr string
+}
+
+func (s *Server) Start() error {
+	return listen(s.addr)
+}
+
+func helper(x int) int {
+	return x * 2
+}
+
+func legacy() {}
+
+func Old(a int) int {
+	total := a + 1
+	return total
+}
+`;
+
+const AFTER = `package svc
+
+type Server struct {
+	addr string
+	port int
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	return listen(s.addr)
+}
+
+func helper(x int) int {
+	return x*2 + 1
+}
+
+func New(a int) int {
+	total := a + 1
+	return total
+}
+
+func Added() {}
+`;
+
+const diff = (a: string, b: string) => diffSymbols(parseCodeSymbols(a, "svc.go"), parseCodeSymbols(b, "svc.go"));
+
+describe("diffSymbols", () => {
+  test("classifies each structural change", () => {
+    expect(diff(BEFORE, AFTER).map((c) => [c.change, c.name])).toEqual([
+      ["body_changed", "Server"],
+      ["signature_changed", "Server.Start"],
+      ["body_changed", "helper"],
+      ["removed", "legacy"],
+      ["renamed", "New"],
+      ["added", "Added"],
+    ]);
+  });
+
+  test("keeps signature and body changes apart", () => {
+    const start = diff(BEFORE, AFTER).find((c) => c.name === "Server.Start")!;
+    expect(start.signature_before).toBe("func (s *Server) Start() error");
+    expect(start.signature_after).toBe("func (s *Server) Start(ctx context.Context) error");
+    expect(start.body_changed).toBeUndefined();
+  });
+
+  test("ignores reformatting and moves", () => {
+    const moved = "package svc\n\nfunc helper(x int) int {\n\treturn   x * 2\n}\n\nfunc legacy() {}\n";
+    const original = "package svc\n\nfunc legacy() {}\n\nfunc helper(x int) int {\n\treturn x * 2\n}\n";
+    expect(diff(original, moved)).toEqual([]);
+  });
+
+  test("does not pair trivial bodies as renames", () => {
+    expect(diff("package a\n\nfunc A() {}\n", "package a\n\nfunc B() {}\n").map((c) => c.change)).toEqual([
+      "removed",
+      "added",
+    ]);
+  });
+});
+
+// ── Git and the tool ─────────────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-astdiff-"));
+  await mkdir(join(dir, "svc"), { recursive: true });
+  await writeFile(join(dir, "svc", "server.go"), BEFORE);
+  initRepo(dir);
+  await writeFile(join(dir, "svc", "server.go"), AFTER);
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: dir, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("gitShowFile", () => {
+  test("reads a committed version, or null", () => {
+    expect(gitShowFile(dir, "HEAD", "svc/server.go")).toBe(BEFORE);
+    expect(gitShowFile(dir, "HEAD", "svc/missing.go")).toBeNull();
+    expect(gitShowFile(dir, "no-such-ref", "svc/server.go")).toBeNull();
+  });
+});
+
+describe("AstDiff", () => {
+  test("compares HEAD with the working tree or given content", async () => {
+    const store = new DocumentStore();
+    store.load([await indexCodeFile(join(dir, "svc", "server.go"), dir, "code")]);
+    const differ = new AstDiff(config);
+
+    const working = await differ.diff(store, "code:svc:server_go");
+    expect(working!.head).toBe("working tree");
+    expect(working!.changes).toHaveLength(6);
+
+    const same = await differ.diff(store, "svc/server.go", { content: BEFORE });
+    expect(same!.changes).toEqual([]);
+
+    const fresh = await differ.diff(store, "svc/new.go", { content: "package svc\n\nfunc Fresh() {}\n" });
+    expect(fresh!.changes.map((c) => c.change)).toEqual(["added"]);
+    expect(await differ.diff(store, "svc/none.go")).toBeNull();
+    await expect(differ.diff(store, "../etc/passwd")).rejects.toThrow("Invalid path");
+    await expect(differ.diff(store, "svc/server.go", { base: "--output=x" })).rejects.toThrow("Invalid ref");
+  });
+});
+
+describe("ast_diff tool", () => {
+  test("summarizes changes and counts them", async () => {
+    const store = new DocumentStore();
+    store.load([await indexCodeFile(join(dir, "svc", "server.go"), dir, "code")]);
+    const harness = await createMcpTestClient(store.exportDocuments(), { astDiff: new AstDiff(config) });
+
+    const result = await harness.client.callTool({ name: "ast_diff", arguments: { path: "svc/server.go" } });
+    const data = result.structuredContent as any;
+    expect(data.counts).toEqual({ added: 1, removed: 1, renamed: 1, signature_changed: 1, body_changed: 2 });
+    const text = getToolText(result as any);
+    expect(text).toContain("svc/server.go: HEAD → working tree");
+    expect(text).toContain("→ L16 function Old → New");
+    expect(text).toContain("    + func (s *Server) Start(ctx context.Context) error");
+
+    const both = await harness.client.callTool({
+      name: "ast_diff",
+      arguments: { path: "svc/server.go", head: "HEAD", content: "x" },
+    });
+    expect(both.isError).toBe(true);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,168 @@
+/**
+ * Tests for breadcrumbs: Go import paths and control blocks, Python
+ * indentation, markdown sections, columns, and the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { Breadcrumbs } from "../src/breadcrumbs";
+import { indexCodeContent } from "../src/code-indexer";
+import { indexMarkdownContent } from "../src/indexer";
+import { GoModuleIndex } from "../src/go-modules";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const TIME = "2026-01-01T00:00:00.000Z";
+
+const MANAGER = `package cluster
+
+type Manager struct {
+	nodes []string
+}
+
+func (m *Manager) Join(node string) error {
+	for _, n := range m.nodes {
+		if n == node {
+			return fmt.Errorf("already joined: {%s}", n)
+		}
+	}
+	cfg := Config{Retries: 3}
+	go func() {
+		m.notify(node)
+	}()
+	return nil
+}
+`;
+
+const JOBS = `class Scheduler:
+    def run(self, jobs):
+        for job in jobs:
+            try:
+                job.start()
+            except Error:
+                self.retry(job)
+        return len(jobs)
+`;
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-breadcrumbs-"));
+  await writeTree(dir, { "go.mod": "module example.com/app\n\ngo 1.22\n", "internal/cluster/manager.go": MANAGER });
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+async function indexedStore(): Promise<DocumentStore> {
+  const store = new DocumentStore();
+  store.load([
+    indexCodeContent(JOBS, "app/jobs/scheduler.py", "code", TIME),
+    indexMarkdownContent("# Operations\n\n## Restarts\n\nDrain the node first.\n", "runbook.md", "docs", TIME),
+  ]);
+  return indexTree(dir, ["internal/cluster/manager.go"], store);
+}
+
+const crumbs = (store: DocumentStore, path: string, line: number, column?: number) =>
+  new Breadcrumbs(config, new GoModuleIndex(config)).at(store, store.documentsAtPath(path)[0], line, column);
+
+describe("Breadcrumbs", () => {
+  test("Go: import path, type, method, and the control blocks around a line", async () => {
+    const store = await indexedStore();
+    const at = await crumbs(store, "internal/cluster/manager.go", 10);
+    expect(at.scopes.map((s) => [s.kind, s.name])).toEqual([
+      ["package", "example.com/app/internal/cluster"],
+      ["symbol", "Manager"],
+      ["symbol", "Join"],
+      ["block", "for _, n := range m.nodes {"],
+      ["block", "if n == node {"],
+    ]);
+    expect([at.scopes[4].line_start, at.scopes[4].line_end]).toEqual([9, 11]);
+    expect(at.trail).toBe("example.com/app/internal/cluster › class Manager › method Join › for _, n := range m.nodes { › if n == node {");
+  });
+
+  test("struct literals are not scopes; closures are", async () => {
+    const store = await indexedStore();
+    expect((await crumbs(store, "internal/cluster/manager.go", 13)).scopes.map((s) => s.kind)).toEqual(["package", "symbol", "symbol"]);
+    const inside = await crumbs(store, "internal/cluster/manager.go", 15);
+    expect(inside.scopes.map((s) => s.name).slice(-1)).toEqual(["go func() {"]);
+  });
+
+  test("a column before a block's brace is outside it", async () => {
+    const store = await indexedStore();
+    expect((await crumbs(store, "internal/cluster/manager.go", 9, 2)).scopes.map((s) => s.name).slice(3)).toEqual([
+      "for _, n := range m.nodes {",
+    ]);
+    expect((await crumbs(store, "internal/cluster/manager.go", 9, 20)).scopes.map((s) => s.name).slice(3)).toEqual([
+      "for _, n := range m.nodes {",
+      "if n == node {",
+    ]);
+  });
+
+  test("Python: dotted module, class, method, and indented blocks", async () => {
+    const store = await indexedStore();
+    const at = await crumbs(store, "app/jobs/scheduler.py", 7);
+    expect(at.scopes.map((s) => (s.kind === "symbol" ? `${s.symbol_kind} ${s.name}` : s.name))).toEqual([
+      "app.jobs.scheduler",
+      "class Scheduler",
+      "method run",
+      "for job in jobs:",
+      "except Error:",
+    ]);
+    expect((await crumbs(store, "app/jobs/scheduler.py", 8)).scopes.map((s) => s.kind)).toEqual(["module", "symbol", "symbol"]);
+  });
+
+  test("markdown: the headings a line sits under", async () => {
+    const store = await indexedStore();
+    const at = await crumbs(store, "runbook.md", 5);
+    expect(at.scopes.map((s) => [s.kind, s.name])).toEqual([
+      ["section", "Operations"],
+      ["section", "Restarts"],
+    ]);
+  });
+});
+
+describe("breadcrumbs tool", () => {
+  test("takes a uri or a path and line, and gives each scope a uri", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      breadcrumbs: new Breadcrumbs(config, new GoModuleIndex(config)),
+    });
+    const result = await harness.client.callTool({
+      name: "breadcrumbs",
+      arguments: { uri: "treenav://file/internal/cluster/manager.go#L10" },
+    });
+    const data = result.structuredContent as any;
+    expect(data.uri).toBe("treenav://file/internal/cluster/manager.go#L10");
+    expect(data.scopes.map((s: any) => s.uri)).toEqual([
+      undefined,
+      "treenav://file/internal/cluster/manager.go#L3-5",
+      "treenav://file/internal/cluster/manager.go#L7-18",
+      "treenav://file/internal/cluster/manager.go#L8-12",
+      "treenav://file/internal/cluster/manager.go#L9-11",
+    ]);
+    const text = getToolText(result as any);
+    expect(text).toContain("internal/cluster/manager.go:10");
+    expect(text).toContain("block     if n == node {  (lines 9-11)");
+
+    const byPath = await harness.client.callTool({ name: "breadcrumbs", arguments: { path: "app/jobs/scheduler.py", line: 5 } });
+    expect((byPath.structuredContent as any).trail).toBe(
+      "app.jobs.scheduler › class Scheduler › method run › for job in jobs: › try:"
+    );
+
+    const calls = [
+      { name: "breadcrumbs", arguments: { uri: "treenav://file/internal/cluster/manager.go" } },
+      { name: "breadcrumbs", arguments: { path: "nowhere.go", line: 1 } },
+      { name: "breadcrumbs", arguments: { path: "runbook.md" } },
+      { name: "breadcrumbs", arguments: {} },
+    ];
+    for (const call of calls) expect((await harness.client.callTool(call)).isError).toBe(true);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,176 @@
+/**
+ * Tests for build_targets: Bazel BUILD and Makefile parsing, which
+ * targets build a file (outright, by glob, across package boundaries),
+ * labels and dependents, and the tool's three ways of asking.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { BuildTargetIndex, normalizeLabel, parseBazelBuild, parseMakefile } from "../src/build-targets";
+import { indexAllCollections } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, writeTree } from "./fixtures/tree";
+
+const POOL_BUILD = `load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+COMMON = ["pool.go"]
+
+go_library(
+    name = "pool",
+    srcs = COMMON + select({
+        ":linux": ["pool_linux.go"],
+        "//conditions:default": [],
+    }),
+    deps = [
+        "//pkg/conn",
+        ":gen",
+    ],
+)
+
+go_test(
+    name = "pool_test",
+    srcs = glob(["**/*_test.go"], exclude = ["slow_test.go"]),
+    embed = [":pool"],
+)
+`;
+
+const MAKEFILE = `SRCS := cmd/main.go
+TOOLS = $(wildcard scripts/*.sh)
+
+.PHONY: all lint
+
+all: server lint
+
+server: $(SRCS) go.mod | bin
+\tgo build -o bin/server ./cmd
+
+bin:
+\tmkdir -p bin
+
+%.o: %.c
+\t$(CC) -c $<
+
+lint: $(TOOLS)
+\t./scripts/lint.sh
+`;
+
+let dir: string;
+let config: IndexConfig;
+let store: DocumentStore;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-build-"));
+  await writeTree(dir, {
+    "pkg/pool/BUILD.bazel": POOL_BUILD,
+    "pkg/pool/pool.go": "package pool\n",
+    "pkg/pool/pool_test.go": "package pool\n",
+    "pkg/pool/bench/BUILD": 'go_test(name = "bench", srcs = ["bench_test.go"], deps = ["//pkg/pool"])\n',
+    "pkg/pool/bench/bench_test.go": "package bench\n",
+    "Makefile": MAKEFILE,
+    "cmd/main.go": "package main\n\nfunc main() {}\n",
+  });
+  config = codeConfig(dir);
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("parseBazelBuild", () => {
+  test("reads named rules, variables, select values, and globs", () => {
+    const [lib, t] = parseBazelBuild(POOL_BUILD, "pkg/pool/BUILD.bazel");
+    expect([lib.label, lib.rule, lib.line]).toEqual(["//pkg/pool:pool", "go_library", 5]);
+    expect(lib.sources).toEqual(["pkg/pool/pool.go", "pkg/pool/pool_linux.go"]);
+    expect(lib.deps).toEqual(["//pkg/conn:conn", "//pkg/pool:gen"]);
+    expect([t.globs, t.excludes, t.deps]).toEqual([["pkg/pool/**/*_test.go"], ["pkg/pool/slow_test.go"], []]);
+  });
+
+  test("labels are made absolute", () => {
+    expect(normalizeLabel(":x", "a/b")).toBe("//a/b:x");
+    expect(normalizeLabel("//a/b", "")).toBe("//a/b:b");
+    expect(normalizeLabel("@repo//c:d", "")).toBe("@repo//c:d");
+  });
+});
+
+describe("parseMakefile", () => {
+  test("reads explicit rules with variables expanded", () => {
+    const targets = parseMakefile(MAKEFILE, "Makefile");
+    expect(targets.map((t) => [t.name, t.rule, t.deps])).toEqual([
+      ["all", "phony", ["server", "lint"]],
+      ["server", "rule", ["bin"]],
+      ["bin", "rule", []],
+      ["lint", "phony", []],
+    ]);
+    expect(targets[1].sources).toEqual(["cmd/main.go", "go.mod"]);
+    expect(targets[3].globs).toEqual(["scripts/*.sh"]);
+  });
+
+  test("paths are relative to the makefile, and define bodies are skipped", () => {
+    const [t] = parseMakefile("define HELP\nfake: rule\nendef\nbuild: ../lib/a.c # comment\n\tcc $<\n", "tools/Makefile");
+    expect([t.label, t.sources]).toEqual(["tools/Makefile:build", ["lib/a.c"]]);
+  });
+});
+
+describe("BuildTargetIndex", () => {
+  test("finds the targets that build a file", async () => {
+    const index = new BuildTargetIndex(config);
+    expect((await index.targetsFor(store, "pkg/pool/pool.go")).map((m) => [m.target.label, m.via])).toEqual([
+      ["//pkg/pool:pool", "pkg/pool/pool.go"],
+    ]);
+    expect((await index.targetsFor(store, "./cmd/main.go")).map((m) => m.target.label)).toEqual(["Makefile:server"]);
+  });
+
+  test("globs stop at the next BUILD package", async () => {
+    const index = new BuildTargetIndex(config);
+    expect((await index.targetsFor(store, "pkg/pool/pool_test.go")).map((m) => m.target.label)).toEqual(["//pkg/pool:pool_test"]);
+    expect((await index.targetsFor(store, "pkg/pool/bench/bench_test.go")).map((m) => m.target.label)).toEqual([
+      "//pkg/pool/bench:bench",
+    ]);
+    expect(await index.targetsFor(store, "pkg/pool/slow_test.go")).toEqual([]);
+  });
+
+  test("resolves labels and their dependents", async () => {
+    const index = new BuildTargetIndex(config);
+    const [pool] = await index.find(store, "//pkg/pool");
+    expect(pool.name).toBe("pool");
+    expect((await index.dependents(store, pool)).map((t) => t.label)).toEqual(["//pkg/pool/bench:bench"]);
+    expect((await index.find(store, "lint")).map((t) => t.label)).toEqual(["Makefile:lint"]);
+    const [server] = await index.find(store, "Makefile:server");
+    expect((await index.dependents(store, server)).map((t) => t.name)).toEqual(["all"]);
+  });
+});
+
+describe("build_targets tool", () => {
+  test("answers by file, by target, and as a list", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { buildTargets: new BuildTargetIndex(config) });
+    const byFile = await harness.client.callTool({ name: "build_targets", arguments: { file: "pkg/pool/pool_test.go" } });
+    expect(getToolText(byFile as any)).toContain("1 target(s) build pkg/pool/pool_test.go:");
+    expect(getToolText(byFile as any)).toContain("//pkg/pool:pool_test  go_test  pkg/pool/BUILD.bazel:17  (glob pkg/pool/**/*_test.go)");
+    expect((byFile.structuredContent as any).targets[0].uri).toContain("BUILD.bazel#L17");
+
+    const byTarget = await harness.client.callTool({ name: "build_targets", arguments: { target: ":pool" } });
+    const text = getToolText(byTarget as any);
+    expect(text).toContain("deps (2): //pkg/conn:conn, //pkg/pool:gen");
+    expect(text).toContain("Depended on by (1):\n  //pkg/pool/bench:bench  go_test  pkg/pool/bench/BUILD:1");
+
+    const list = await harness.client.callTool({ name: "build_targets", arguments: { system: "make" } });
+    expect((list.structuredContent as any).total).toBe(4);
+    expect(getToolText(list as any)).toContain("Makefile:\n  all  phony  :6  → server, lint");
+    await harness.cleanup();
+  });
+
+  test("not_found and conflicting arguments", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { buildTargets: new BuildTargetIndex(config) });
+    const none = await harness.client.callTool({ name: "build_targets", arguments: { file: "README.md" } });
+    expect((none.structuredContent as any).status).toBe("not_found");
+    const both = await harness.client.callTool({ name: "build_targets", arguments: { file: "a.go", target: "//a" } });
+    expect(both.isError).toBe(true);
+    await harness.cleanup();
+  });
+});
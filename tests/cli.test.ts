@@ -0,0 +1,227 @@
+/**
+ * Tests for the treenav-mcp CLI subcommands.
+ *
+ * Covers: argv parsing, `index` artifact output readable by the server's
+ * warm-start path, `search` against that artifact (text + JSON), and
+ * option validation.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { existsSync } from "node:fs";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { parseFilters, runIndexCommand, runSearchCommand } from "../src/cli";
+import { DocumentStore } from "../src/store";
+import { parseArgs, resolveConfig, toIndexConfig } from "../src/config";
+import { loadIndexCache } from "../src/index-cache";
+import { commandArgs } from "./fixtures/cli";
+
+describe("parseArgs", () => {
+  test("separates positionals from flags", () => {
+    const { positionals, flags } = parseArgs(["./docs", "--out", "x.json", "--code=./src"]);
+    expect(positionals).toEqual(["./docs"]);
+    expect(flags).toEqual({ out: "x.json", code: "./src" });
+  });
+
+  test("treats trailing and declared switches as booleans", () => {
+    const { positionals, flags } = parseArgs(["--json", "query", "--verbose"], ["json"]);
+    expect(positionals).toEqual(["query"]);
+    expect(flags).toEqual({ json: true, verbose: true });
+  });
+
+  test("keeps an explicit false switch, so it can override env and file", () => {
+    expect(parseArgs(["--lazy-index=false"], ["lazy-index"]).flags).toEqual({ "lazy-index": false });
+  });
+});
+
+describe("parseFilters", () => {
+  test("groups repeated keys", () => {
+    expect(parseFilters("type=runbook,tags=auth,tags=jwt")).toEqual({
+      type: ["runbook"],
+      tags: ["auth", "jwt"],
+    });
+  });
+
+  test("ignores malformed pairs", () => {
+    expect(parseFilters("type=,=x,tags")).toEqual({});
+  });
+});
+
+describe("runIndexCommand", () => {
+  let dir: string;
+  let docsRoot: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-cli-"));
+    docsRoot = join(dir, "docs");
+    await mkdir(docsRoot, { recursive: true });
+    await writeFile(join(docsRoot, "guide.md"), "# Guide\n\nSetup steps.\n");
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("writes an artifact the server's config accepts", async () => {
+    const out = join(dir, "index.json");
+    const code = await runIndexCommand(await commandArgs("index", [docsRoot, "--out", out]));
+
+    expect(code).toBe(0);
+    const docs = await loadIndexCache(out, toIndexConfig(resolveConfig({ env: { DOCS_ROOT: docsRoot } }).config));
+    expect(docs?.map((d) => d.meta.doc_id)).toEqual(["docs:guide"]);
+  });
+
+  test("rejects an out-of-range failure threshold", async () => {
+    const out = join(dir, "index.json");
+    const code = await runIndexCommand(await commandArgs("index", [docsRoot, "--out", out, "--max-failure-rate", "2"]));
+
+    expect(code).toBe(2);
+    expect(existsSync(out)).toBe(false);
+  });
+});
+
+describe("runIndexCommand --verify", () => {
+  let dir: string;
+  let docsRoot: string;
+  let out: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-cli-verify-"));
+    docsRoot = join(dir, "docs");
+    out = join(dir, "index.json");
+    await mkdir(docsRoot, { recursive: true });
+    await writeFile(join(docsRoot, "guide.md"), "# Guide\n\nSetup steps.\n");
+    await writeFile(join(docsRoot, "faq.md"), "# FAQ\n\nAnswers.\n");
+    await writeFile(join(docsRoot, "old.md"), "# Old\n\nRetired.\n");
+    await runIndexCommand(await commandArgs("index", [docsRoot, "--out", out]), () => {});
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  async function verify(...extra: string[]) {
+    const lines: string[] = [];
+    const args = await commandArgs("index", [docsRoot, "--out", out, "--verify", ...extra]);
+    const code = await runIndexCommand(args, (text) => lines.push(text));
+    return { code, output: lines.join("\n") };
+  }
+
+  test("passes an artifact that matches the working tree", async () => {
+    const { code, output } = await verify();
+    expect(code).toBe(0);
+    expect(output).toContain("(3 documents) against 3 file(s)");
+    expect(output).toContain("  3 unchanged");
+  });
+
+  test("reports missing, orphaned, and mismatched entries, then repairs them", async () => {
+    await writeFile(join(docsRoot, "guide.md"), "# Guide\n\nNew setup steps.\n");
+    await writeFile(join(docsRoot, "new.md"), "# New\n\nAdded later.\n");
+    await rm(join(docsRoot, "old.md"));
+
+    const before = await verify();
+    expect(before.code).toBe(1);
+    expect(before.output).toContain("  1 missing (on disk, not in the index):\n    docs:new");
+    expect(before.output).toContain("  1 orphaned (in the index, file gone or no longer matched):\n    docs:old");
+    expect(before.output).toContain("  1 mismatched (content hash differs):\n    docs:guide");
+
+    const repaired = await verify("--repair");
+    expect(repaired.code).toBe(0);
+    expect(repaired.output).toContain("1 updated, 1 added, 1 removed, 0 failed");
+    expect((await verify()).code).toBe(0);
+  });
+
+  test("fails on an unusable artifact and rebuilds it with --repair", async () => {
+    await writeFile(out, "{ not json");
+    expect((await verify()).code).toBe(1);
+    expect((await verify("--repair")).code).toBe(0);
+    expect((await verify()).code).toBe(0);
+  });
+
+  test("rejects --repair without --verify", async () => {
+    expect(await runIndexCommand(await commandArgs("index", [docsRoot, "--out", out, "--repair"]))).toBe(2);
+  });
+});
+
+describe("runSearchCommand", () => {
+  let dir: string;
+  let docsRoot: string;
+  let indexPath: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-cli-search-"));
+    docsRoot = join(dir, "docs");
+    indexPath = join(dir, "index.json");
+    await mkdir(docsRoot, { recursive: true });
+    await writeFile(
+      join(docsRoot, "auth.md"),
+      "---\ntype: guide\n---\n# Auth\n\n## Token Refresh\n\nRefresh tokens rotate hourly.\n"
+    );
+    await writeFile(
+      join(docsRoot, "restart.md"),
+      "---\ntype: runbook\n---\n# Restart\n\nRestart the token service.\n"
+    );
+    await runIndexCommand(await commandArgs("index", [docsRoot, "--out", indexPath]));
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  async function search(argv: string[]) {
+    const lines: string[] = [];
+    const code = await runSearchCommand(await commandArgs("search", argv), (text) => lines.push(text));
+    return { code, output: lines.join("\n") };
+  }
+
+  test("--json returns the same ranking as the store", async () => {
+    const { code, output } = await search(["token", "--json", "--index", indexPath, "--root", docsRoot]);
+    expect(code).toBe(0);
+
+    const parsed = JSON.parse(output);
+    const store = new DocumentStore();
+    store.load((await loadIndexCache(indexPath, toIndexConfig(resolveConfig({ env: { DOCS_ROOT: docsRoot } }).config)))!);
+    const expected = store.searchDocuments("token", { limit: 15 });
+
+    expect(parsed.query).toBe("token");
+    expect(parsed.results.map((r: any) => r.node_id)).toEqual(expected.map((r) => r.node_id));
+  });
+
+  test("text output matches the search_documents format", async () => {
+    const { output } = await search(["refresh", "--index", indexPath, "--root", docsRoot]);
+    expect(output).toContain('Search results for "refresh"');
+  });
+
+  test("--filter narrows by facet", async () => {
+    const { output } = await search([
+      "token", "--json", "--filter", "type=runbook", "--index", indexPath, "--root", docsRoot,
+    ]);
+    const parsed = JSON.parse(output);
+    expect(parsed.results.map((r: any) => r.doc_id)).toEqual(["docs:restart"]);
+  });
+
+  test("--case sensitive matches the query's case", async () => {
+    const { output } = await search(["Token", "--json", "--case", "sensitive", "--index", indexPath, "--root", docsRoot]);
+    expect(JSON.parse(output).results.map((r: any) => r.node_title)).toEqual(["Token Refresh"]);
+  });
+
+  test("--word-boundaries disables prefix matches", async () => {
+    const { output } = await search(["tok", "--json", "--word-boundaries", "--index", indexPath, "--root", docsRoot]);
+    expect(JSON.parse(output).count).toBe(0);
+  });
+
+  test("rejects an unknown --case mode", async () => {
+    const { code } = await search(["token", "--case", "upper", "--index", indexPath, "--root", docsRoot]);
+    expect(code).toBe(2);
+  });
+
+  test("fails when no index matches the configuration", async () => {
+    const { code } = await search(["token", "--index", join(dir, "missing.json"), "--root", docsRoot]);
+    expect(code).toBe(1);
+  });
+
+  test("requires a query", async () => {
+    await expect(commandArgs("search", ["--json"])).rejects.toThrow("Not enough non-option arguments");
+  });
+});
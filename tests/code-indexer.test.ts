@@ -143,6 +143,21 @@ describe("parseTypeScript", () => {
       expect(authService!.children_ids).toContain(method.id);
     }
   });
+  test("extracts non-ASCII identifiers", () => {
+    const source = [
+      "export class ユーザー設定 {",
+      "  読み込む(パス: string): void {}",
+      "}",
+      "export function calculerMontantTotal\u00e9(x: number) { return x; }",
+      "export const 最大値 = 100;",
+    ].join("\n");
+    const names = parseTypeScript(source, "test:jp").map((s) => s.name);
+
+    expect(names).toContain("ユーザー設定");
+    expect(names).toContain("読み込む");
+    expect(names).toContain("calculerMontantTotalé");
+    expect(names).toContain("最大値");
+  });
 });
 
 // ── Python parser tests ─────────────────────────────────────────────
@@ -208,6 +223,10 @@ describe("parsePython", () => {
     expect(imports).toBeDefined();
     expect(imports!.content).toContain("import");
   });
+  test("extracts accented identifiers", () => {
+    const symbols = parsePython("def créer_élément(nom):\n    return nom\n", "test:fr");
+    expect(symbols.find((s) => s.name === "créer_élément")?.kind).toBe("function");
+  });
 });
 
 // ── Java parser tests ────────────────────────────────────────────────
@@ -699,6 +718,23 @@ describe("code indexer + DocumentStore integration", () => {
     }
   });
 
+  test("non-ASCII symbols are searchable in composed or decomposed form", async () => {
+    const dir = await setupTempDir();
+    try {
+      const filePath = join(dir, "settings.ts");
+      // Source saved decomposed (NFD), as some macOS tools write it
+      await writeFile(filePath, "export function créerÉlément() {}\nexport class ユーザー設定 {}\n".normalize("NFD"));
+
+      store = new DocumentStore();
+      store.load([await indexCodeFile(filePath, dir, "code")]);
+
+      expect(store.searchDocuments("créerélément")[0]?.doc_id).toBe("code:settings_ts");
+      expect(store.searchDocuments("設定", { filters: { content_type: "code" } })[0]?.node_title).toContain("ユーザー設定");
+    } finally {
+      await cleanupTempDir();
+    }
+  });
+
   test("code files are filterable by language facet", async () => {
     const dir = await setupTempDir();
     try {
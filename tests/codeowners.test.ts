@@ -0,0 +1,142 @@
+/**
+ * Tests for CODEOWNERS: pattern semantics, last-match-wins, file
+ * discovery above a collection root, and the owners_of tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { CodeownersIndex, matchCodeowners, parseCodeowners, patternRegex } from "../src/codeowners";
+import { indexCodeFile } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const matches = (pattern: string, path: string) => patternRegex(pattern)!.test(path);
+
+describe("patternRegex", () => {
+  test("anchors patterns with a slash and floats the rest", () => {
+    expect(matches("*.go", "svc/api/main.go")).toBe(true);
+    expect(matches("/build/", "build/out.js")).toBe(true);
+    expect(matches("/build/", "web/build/out.js")).toBe(false);
+    expect(matches("apps/", "web/apps/x.ts")).toBe(true);
+    expect(matches("src/api", "src/api/v1/routes.ts")).toBe(true);
+    expect(matches("src/api", "lib/src/api/x.ts")).toBe(false);
+  });
+
+  test("keeps a wildcard last segment to one level and lets ** span", () => {
+    expect(matches("docs/*", "docs/intro.md")).toBe(true);
+    expect(matches("docs/*", "docs/guides/setup.md")).toBe(false);
+    expect(matches("docs/**/*.md", "docs/intro.md")).toBe(true);
+    expect(matches("docs/**/*.md", "docs/a/b/setup.md")).toBe(true);
+    expect(matches("**/logs", "deep/down/logs/app.log")).toBe(true);
+  });
+
+  test("rejects what GitHub rejects", () => {
+    expect(patternRegex("!vendor/")).toBeNull();
+    expect(patternRegex("*.[ch]")).toBeNull();
+  });
+});
+
+describe("matchCodeowners", () => {
+  const rules = parseCodeowners(`# Default owners
+*                @org/core
+
+[Docs]
+*.md             @org/docs  # writers
+/svc/payments/   @org/payments @alice
+/svc/payments/generated/
+path\\ with\\ space/ bob@example.com
+`);
+
+  test("lets the last matching line win", () => {
+    expect(matchCodeowners(rules, "svc/api.go")!.owners).toEqual(["@org/core"]);
+    expect(matchCodeowners(rules, "svc/payments/README.md")!.owners).toEqual(["@org/payments", "@alice"]);
+    expect(matchCodeowners(rules, "README.md")!.owners).toEqual(["@org/docs"]);
+  });
+
+  test("treats an ownerless match as unowned and reads escapes", () => {
+    const generated = matchCodeowners(rules, "svc/payments/generated/pb.go")!;
+    expect([generated.owners, generated.line]).toEqual([[], 7]);
+    expect(matchCodeowners(rules, "path with space/x")!.owners).toEqual(["bob@example.com"]);
+  });
+});
+
+// ── A scratch repository ─────────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-owners-"));
+  await mkdir(join(dir, ".git"));
+  await mkdir(join(dir, ".github"));
+  await mkdir(join(dir, "code", "auth"), { recursive: true });
+  await writeFile(join(dir, ".github", "CODEOWNERS"), "* @org/core\n/code/auth/ @org/identity\n");
+  await writeFile(join(dir, "CODEOWNERS"), "* @ignored\n");
+  await writeFile(join(dir, "code", "auth", "service.ts"), "export class AuthService {\n  login() {}\n}\n");
+  await writeFile(join(dir, "code", "main.ts"), "export function main() {}\n");
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: join(dir, "code"), weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("CodeownersIndex", () => {
+  test("finds .github/CODEOWNERS above the collection root", async () => {
+    const owners = await new CodeownersIndex(config).ownersOf("code", "auth/service.ts");
+    expect(owners).toEqual({
+      owners: ["@org/identity"],
+      codeowners: ".github/CODEOWNERS",
+      repo_path: "code/auth/service.ts",
+      rule: { pattern: "/code/auth/", line: 2 },
+    });
+  });
+
+  test("reads only the repository root, past a nested docs/CODEOWNERS", async () => {
+    await mkdir(join(dir, "code", "docs"));
+    await writeFile(join(dir, "code", "docs", "CODEOWNERS"), "* @vendored\n");
+    const owners = await new CodeownersIndex(config).ownersOf("code", "main.ts");
+    expect([owners?.owners, owners?.codeowners, owners?.repo_path]).toEqual([["@org/core"], ".github/CODEOWNERS", "code/main.ts"]);
+  });
+
+  test("answers null without a CODEOWNERS file", async () => {
+    await rm(join(dir, ".github"), { recursive: true });
+    await rm(join(dir, "CODEOWNERS"));
+    expect(await new CodeownersIndex(config).ownersOf("code", "main.ts")).toBeNull();
+  });
+});
+
+describe("owners_of tool", () => {
+  test("resolves paths, doc_ids, and symbols and groups by owner", async () => {
+    const root = join(dir, "code");
+    const docs = [await indexCodeFile(join(root, "auth", "service.ts"), root, "code"), await indexCodeFile(join(root, "main.ts"), root, "code")];
+    const harness = await createMcpTestClient(docs, { codeowners: new CodeownersIndex(config) });
+
+    const result = await harness.client.callTool({
+      name: "owners_of",
+      arguments: { targets: ["main.ts", "AuthService.login", "Nope"] },
+    });
+    const data = result.structuredContent as any;
+    expect(data.entries.map((e: any) => [e.target, e.status, e.owners])).toEqual([
+      ["main.ts", "owned", ["@org/core"]],
+      ["AuthService.login", "owned", ["@org/identity"]],
+      ["Nope", "not_found", []],
+    ]);
+    expect(data.by_owner).toEqual([
+      { owner: "@org/core", files: ["main.ts"] },
+      { owner: "@org/identity", files: ["auth/service.ts"] },
+    ]);
+    const text = getToolText(result as any);
+    expect(text).toContain("AuthService.login → auth/service.ts  @org/identity  (.github/CODEOWNERS:2 /code/auth/)");
+    expect(text).toContain("Nope  not found");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,123 @@
+/**
+ * Tests for comment text in code: splitting sources into code and
+ * comment runs, detecting the language comments are written in, and
+ * search including, excluding, or matching only comments.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { commentSegments, detectLanguage } from "../src/comments";
+import { indexCodeContent } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import { createMcpTestClient } from "./fixtures/helpers";
+
+const TIME = "2026-01-01T00:00:00.000Z";
+const comments = (source: string, file: string) =>
+  commentSegments(source, file).filter((s) => s.comment).map((s) => s.text);
+
+describe("commentSegments", () => {
+  test("splits C-style sources and joins back to them", () => {
+    const source = 'const url = "http://example.com"; // fetch it\n/* block\n   comment */ x = 1;\n';
+    expect(comments(source, "a.ts")).toEqual(["// fetch it", "/* block\n   comment */"]);
+    expect(commentSegments(source, "a.ts").map((s) => s.text).join("")).toBe(source);
+  });
+
+  test("knows hash comments, Python docstrings, and Lua blocks", () => {
+    expect(comments('def f():\n    """Return it."""\n    s = "# not a comment"  # a comment\n', "a.py")).toEqual([
+      '"""Return it."""',
+      "# a comment",
+    ]);
+    expect(comments("echo hi # greet\n", "run.sh")).toEqual(["# greet"]);
+    expect(comments("--[[ long\n]] local x = 1 -- short\n", "m.lua")).toEqual(["--[[ long\n]]", "-- short"]);
+  });
+});
+
+describe("detectLanguage", () => {
+  test("names languages by script", () => {
+    expect(detectLanguage("// 连接池的超时时间")).toBe("zh");
+    expect(detectLanguage("// 接続プールのタイムアウト")).toBe("ja");
+    expect(detectLanguage("// 연결 풀의 시간 초과")).toBe("ko");
+    expect(detectLanguage("// Время ожидания пула соединений")).toBe("ru");
+    expect(detectLanguage("// Час очікування пулу з'єднань")).toBe("uk");
+  });
+
+  test("tells Latin-script languages apart by their common words", () => {
+    expect(detectLanguage("// Returns the connection when the pool is not empty")).toBe("en");
+    expect(detectLanguage("// Gibt die Verbindung zurück, wenn der Pool nicht leer ist")).toBe("de");
+    expect(detectLanguage("// Renvoie la connexion quand le pool est plein")).toBe("fr");
+  });
+
+  test("names nothing for short text and commented-out code", () => {
+    expect(detectLanguage("// TODO")).toBeUndefined();
+    expect(detectLanguage("// conn.SetDeadline(time.Now().Add(timeout))")).toBeUndefined();
+  });
+});
+
+describe("search", () => {
+  const store = () => {
+    const s = new DocumentStore();
+    s.load([
+      indexCodeContent(
+        "package db\n\nfunc Dial() {\n\t// Verbindung zum Server aufbauen, wenn der Pool nicht leer ist\n\tconnect()\n}\n",
+        "dial.go",
+        "code",
+        TIME
+      ),
+      indexCodeContent("package db\n\nfunc Server() {\n\tlisten()\n}\n", "server.go", "code", TIME),
+    ]);
+    return s;
+  };
+  const files = (results: { file_path: string }[]) => results.map((r) => r.file_path).sort();
+
+  test("matches comments unless told otherwise", () => {
+    const s = store();
+    const hits = s.searchDocuments("server");
+    expect(files(hits)).toEqual(["dial.go", "server.go"]);
+    expect(hits.find((r) => r.file_path === "dial.go")!.comment_language).toBe("de");
+    expect(hits.find((r) => r.file_path === "server.go")!.comment_language).toBeUndefined();
+  });
+
+  test("leaves comments out, or matches nothing else", () => {
+    const s = store();
+    expect(files(s.searchDocuments("server", { comments: "exclude" }))).toEqual(["server.go"]);
+    expect(files(s.searchDocuments("server", { comments: "only" }))).toEqual(["dial.go"]);
+    expect(s.searchDocuments("verbindung", { comments: "exclude" })).toEqual([]);
+  });
+
+  test("counts only comments in the languages asked for", () => {
+    const s = store();
+    expect(files(s.searchDocuments("server", { comment_languages: ["en"] }))).toEqual(["server.go"]);
+    expect(files(s.searchDocuments("server", { comment_languages: ["de", "en"] }))).toEqual(["dial.go", "server.go"]);
+  });
+
+  test("takes defaults from setCommentMatching", () => {
+    const s = store();
+    s.setCommentMatching({ matching: "exclude", languages: [] });
+    expect(files(s.searchDocuments("server"))).toEqual(["server.go"]);
+    expect(files(s.searchDocuments("server", { comments: "include" }))).toEqual(["dial.go", "server.go"]);
+    s.setCommentMatching({ matching: "include", languages: ["EN"] });
+    expect(files(s.searchDocuments("server"))).toEqual(["server.go"]);
+  });
+});
+
+describe("search_documents", () => {
+  test("takes comments and comment_languages", async () => {
+    const harness = await createMcpTestClient([
+      indexCodeContent("package db\n\nfunc Dial() {\n\t// 连接到服务器并返回连接池\n\tconnect()\n}\n", "dial.go", "code", TIME),
+      indexCodeContent("package db\n\nfunc Open() {\n\t// Connect to the server and return the pool\n\tconnect()\n}\n", "open.go", "code", TIME),
+    ]);
+    const search = async (args: Record<string, unknown>) =>
+      (await harness.client.callTool({ name: "search_documents", arguments: { query: "服务器 server", ...args } }))
+        .structuredContent as any;
+
+    const all = await search({});
+    expect(all.results.map((r: any) => [r.file_path, r.comment_language]).sort()).toEqual([
+      ["dial.go", "zh"],
+      ["open.go", "en"],
+    ]);
+    const english = await search({ comment_languages: ["en"] });
+    expect(english.results.map((r: any) => r.file_path)).toEqual(["open.go"]);
+    const code = await search({ comments: "exclude" });
+    expect(code.results).toEqual([]);
+    await harness.cleanup();
+  });
+});
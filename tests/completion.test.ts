@@ -0,0 +1,154 @@
+/**
+ * Tests for shell completion.
+ *
+ * Covers: subcommand and flag completion from the command table,
+ * path sentinels, dynamic doc_id / facet completion from a persisted
+ * index, and the yargs program built from the same table.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { completeWords, completionScript, DIRS_SENTINEL, FILES_SENTINEL } from "../src/completion";
+import { runCompleteCommand, runIndexCommand } from "../src/cli";
+import { COMMANDS, program, type CommandArgs } from "../src/commands";
+import { commandArgs } from "./fixtures/cli";
+
+const noIndex = async () => null;
+
+describe("completeWords", () => {
+  test("completes subcommands", async () => {
+    expect(await completeWords(["se"], noIndex)).toEqual(["search", "serve"]);
+  });
+
+  test("completes flags for the current subcommand", async () => {
+    expect(await completeWords(["search", "--do"], noIndex)).toEqual(["--doc-id"]);
+    expect(await completeWords(["index", "--m"], noIndex)).toEqual(["--max-failure-rate"]);
+  });
+
+  test("treats a leading flag as serve", async () => {
+    expect(await completeWords(["--print"], noIndex)).toEqual(["--print-config"]);
+  });
+
+  test("defers paths to the shell", async () => {
+    expect(await completeWords(["index", ""], noIndex)).toEqual([DIRS_SENTINEL]);
+    expect(await completeWords(["search", "--index", ""], noIndex)).toEqual([FILES_SENTINEL]);
+    expect(await completeWords(["--docs-root", "./d"], noIndex)).toEqual([DIRS_SENTINEL]);
+  });
+
+  test("offers nothing for free-form values", async () => {
+    expect(await completeWords(["search", "--limit", ""], noIndex)).toEqual([]);
+  });
+
+  test("completes shells", async () => {
+    expect(await completeWords(["completion", "z"], noIndex)).toEqual(["zsh"]);
+  });
+
+  test("completes case modes", async () => {
+    expect(await completeWords(["search", "--case", "s"], noIndex)).toEqual(["sensitive", "smart"]);
+  });
+});
+
+describe("dynamic completion", () => {
+  let dir: string;
+  let docsRoot: string;
+  let indexPath: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-completion-"));
+    docsRoot = join(dir, "docs");
+    indexPath = join(dir, "index.json");
+    await mkdir(join(docsRoot, "auth"), { recursive: true });
+    await writeFile(join(docsRoot, "auth", "tokens.md"), "---\ntype: guide\ntags: [auth, jwt]\n---\n# Tokens\n");
+    await writeFile(join(docsRoot, "restart.md"), "---\ntype: runbook\n---\n# Restart\n");
+    await runIndexCommand(await commandArgs("index", [docsRoot, "--out", indexPath]));
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  async function complete(words: string[]): Promise<string[]> {
+    const lines: string[] = [];
+    await runCompleteCommand(words, (text) => lines.push(text));
+    return lines.join("\n").split("\n").filter(Boolean);
+  }
+
+  test("completes doc ids from the index the search would use", async () => {
+    const candidates = await complete(["search", "--index", indexPath, "--root", docsRoot, "--doc-id", "docs:a"]);
+    expect(candidates).toEqual(["docs:auth:tokens"]);
+  });
+
+  test("completes facet pairs after a comma", async () => {
+    const candidates = await complete([
+      "search", "--index", indexPath, "--root", docsRoot, "--filter", "type=guide,tags=",
+    ]);
+    expect(candidates).toEqual(["type=guide,tags=auth", "type=guide,tags=jwt"]);
+  });
+
+  test("completes --flag=value forms", async () => {
+    const candidates = await complete(["search", "--index", indexPath, "--root", docsRoot, "--doc-id=docs:r"]);
+    expect(candidates).toEqual(["--doc-id=docs:restart"]);
+  });
+
+  test("yields nothing when the index is missing", async () => {
+    const candidates = await complete(["search", "--index", join(dir, "missing.json"), "--doc-id", ""]);
+    expect(candidates).toEqual([]);
+  });
+});
+
+describe("completionScript", () => {
+  test("generates a script per shell that calls __complete", () => {
+    for (const shell of ["bash", "zsh", "fish"]) {
+      expect(completionScript(shell)).toContain("treenav-mcp __complete");
+    }
+    expect(completionScript("powershell")).toBeNull();
+  });
+});
+
+describe("program", () => {
+  async function run(argv: string[]) {
+    const calls: Array<[string, CommandArgs]> = [];
+    const failures: string[] = [];
+    try {
+      await program(argv, async (command, args) => {
+        calls.push([command, args]);
+      })
+        .fail((message) => {
+          throw new Error(message);
+        })
+        .parseAsync();
+    } catch (err: any) {
+      failures.push(err.message);
+    }
+    return { calls, failures };
+  }
+
+  test("lists every command in --help", async () => {
+    const help = await program([], async () => {}).getHelp();
+    for (const command of COMMANDS) expect(help).toContain(`treenav-mcp ${command.name}`);
+    expect(help).toContain("--docs-root");
+  });
+
+  test("hands a command its parsed arguments", async () => {
+    const [[command, args]] = (await run(["search", "--json", "token refresh", "--limit", "5"])).calls;
+    expect(command).toBe("search");
+    expect([args.query, args.json, args.limit]).toEqual([["token refresh"], true, "5"]);
+    const [[, index]] = (await run(["index", "./docs", "--out", "x.json"])).calls;
+    expect([index.docs_root, index.out]).toEqual(["./docs", "x.json"]);
+    for (const argv of [["--port", "3200"], ["serve", "--port", "3200"]]) {
+      const [[name, flags]] = (await run(argv)).calls;
+      expect(name).toBe("serve");
+      expect(flags.port).toBe("3200");
+    }
+  });
+
+  test("rejects unknown commands and flags, missing positionals, and flags without their value", async () => {
+    for (const argv of [["bogus"], ["index", "--bogus"], ["import"], ["index", "--out"]]) {
+      const { calls, failures } = await run(argv);
+      expect(calls).toEqual([]);
+      expect(failures).toHaveLength(1);
+    }
+  });
+});
@@ -0,0 +1,226 @@
+/**
+ * Tests for concurrency_map: goroutine launches, channel operations,
+ * lock pairing within functions, WaitGroups and atomics, per-package
+ * summaries, and the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ConcurrencyMap, scanConcurrency } from "../src/concurrency";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const FILES: Record<string, string> = {
+  "cluster/manager.go": `package cluster
+
+import "sync"
+
+type Manager struct {
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+	events chan Event
+	done   chan struct{}
+	nodes  map[string]int
+}
+
+func New() *Manager {
+	return &Manager{events: make(chan Event, 16), done: make(chan struct{})}
+}
+
+func (m *Manager) Start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+func (m *Manager) loop() {
+	defer m.wg.Done()
+	for {
+		select {
+		case ev := <-m.events:
+			m.apply(ev)
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *Manager) apply(ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes[ev.Name]++
+}
+
+func (m *Manager) Count() int {
+	m.mu.RLock()
+	n := len(m.nodes)
+	m.mu.RUnlock()
+	return n
+}
+
+func (m *Manager) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+`,
+  "cluster/worker.go": `package cluster
+
+func (m *Manager) Publish(ev Event) {
+	m.events <- ev
+}
+
+func (m *Manager) forget(name string) {
+	m.mu.Lock()
+	delete(m.nodes, name)
+}
+
+func drain(ch <-chan Event) {
+	for ev := range ch {
+		_ = ev
+	}
+	go func() {
+		atomic.AddInt64(&total, 1)
+	}()
+}
+`,
+  "api/server.go": `package api
+
+func serve() {
+	// go notReally()
+	msg := "<-ch"
+	_ = msg
+}
+`,
+};
+
+const kinds = (source: string) => scanConcurrency(source, "x.go").sites.map((s) => [s.kind, s.name]);
+
+describe("scanConcurrency", () => {
+  test("sends, receives, and channel types told apart", () => {
+    const source = `package x
+
+func f(in <-chan int, out chan<- int, ctx context.Context) {
+	out <- <-in
+	v, ok := <-in
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	case out <- v:
+	}
+	go worker(v)
+}
+`;
+    expect(kinds(source)).toEqual([
+      ["send", "out"],
+      ["receive", "in"],
+      ["receive", "in"],
+      ["select", "select"],
+      ["receive", "ctx.Done()"],
+      ["receive", "time.After()"],
+      ["send", "out"],
+      ["goroutine", "worker"],
+    ]);
+  });
+
+  test("pairs a lock with the next unlock of the same mode in its function", () => {
+    const source = `package x
+
+func (s *Store) a() {
+	s.mu.Lock()
+	s.mu.Unlock()
+	s.mu.RLock()
+}
+
+func (s *Store) b() {
+	s.mu.RUnlock()
+}
+`;
+    expect(scanConcurrency(source, "x.go").pairs.map((p) => [p.mode, p.lock ?? null, p.unlock ?? null])).toEqual([
+      ["write", 4, 5],
+      ["read", 6, null],
+      ["read", null, 10],
+    ]);
+  });
+});
+
+// ── The map and the tool ─────────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-concurrency-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES));
+
+describe("ConcurrencyMap", () => {
+  test("summarizes a package's channels, mutexes, and unpaired locks", async () => {
+    const report = await new ConcurrencyMap(config).map(await indexedStore());
+    expect(report.files).toBe(3);
+    expect(report.packages.map((p) => p.package)).toEqual(["cluster"]);
+    const [cluster] = report.packages;
+    expect(cluster.by_kind).toEqual({
+      goroutine: 2,
+      make_chan: 2,
+      send: 1,
+      receive: 3,
+      close: 1,
+      select: 1,
+      lock: 3,
+      unlock: 2,
+      waitgroup: 3,
+      atomic: 1,
+    });
+    expect(cluster.channels).toEqual([
+      { name: "ch", type: "<-chan Event", make: 0, send: 0, receive: 1, close: 0 },
+      { name: "done", type: "chan struct{}", make: 1, send: 0, receive: 1, close: 1 },
+      { name: "events", type: "chan Event", buffer: "16", make: 1, send: 1, receive: 1, close: 0 },
+    ]);
+    expect(cluster.mutexes).toEqual([{ name: "mu", lock: 3, unlock: 2, deferred: 1, unpaired: 1 }]);
+    expect(cluster.unpaired.map((u) => [u.file_path, u.lock, u.unlock ?? null])).toEqual([["cluster/worker.go", 8, null]]);
+  });
+
+  test("path prefix and test filtering", async () => {
+    const map = new ConcurrencyMap(config);
+    const store = await indexedStore();
+    expect((await map.map(store, { path: "api/" })).packages).toEqual([]);
+    expect((await map.map(store, { include_tests: "only" })).packages).toEqual([]);
+  });
+});
+
+describe("concurrency_map tool", () => {
+  test("lists the summary and the sites of one kind", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      concurrency: new ConcurrencyMap(config),
+    });
+    const result = await harness.client.callTool({ name: "concurrency_map", arguments: { kind: "goroutine" } });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(2);
+    expect(data.sites[0]).toEqual({
+      kind: "goroutine",
+      name: "loop",
+      doc_id: "code:cluster:manager_go",
+      file_path: "cluster/manager.go",
+      line: 19,
+      text: "go m.loop()",
+      function: "Manager.Start",
+      uri: "treenav://file/cluster/manager.go#L19",
+    });
+    expect(data.packages[0].unpaired[0].uri).toBe("treenav://file/cluster/worker.go#L8");
+    const text = getToolText(result as any);
+    expect(text).toContain("cluster (2 file(s)): 2 goroutine, 2 make_chan, 1 send, 3 receive, 1 close, 1 select, 3 lock, 2 unlock, 3 waitgroup, 1 atomic");
+    expect(text).toContain("    events  chan Event, buffer 16  make 1, send 1, receive 1");
+    expect(text).toContain("    mu  3 lock / 2 unlock, 1 deferred, 1 unpaired");
+    expect(text).toContain("Sites (2):");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,139 @@
+/**
+ * Tests for config hot reload: which options apply live, which re-index,
+ * which need a restart, and reloads triggered by editing the file.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DocumentStore } from "../src/store";
+import { indexAllCollections } from "../src/indexer";
+import { resolveConfig, toIndexConfig, toToolTimeouts, type ServeConfig } from "../src/config";
+import { ConfigReloader, diffConfig, formatReload, type ReloadReport } from "../src/config-reload";
+import type { WikiOptions } from "../src/curator";
+import type { ToolTimeouts } from "../src/deadline";
+import type { IndexConfig } from "../src/types";
+
+let dir: string;
+let settings: ServeConfig;
+let config: IndexConfig;
+let store: DocumentStore;
+let timeouts: ToolTimeouts;
+let wiki: WikiOptions | undefined;
+let file: Record<string, unknown>;
+
+function reloader(options: { reindexable?: boolean; notify?: (message: string, report: ReloadReport) => void } = {}) {
+  return new ConfigReloader(
+    settings,
+    async () => resolveConfig({ file }).config,
+    { store, config, reindexable: options.reindexable ?? true, timeouts, setWiki: (next) => (wiki = next) },
+    { log: () => {}, notify: options.notify }
+  );
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-reload-"));
+  await mkdir(join(dir, "guides"), { recursive: true });
+  await mkdir(join(dir, "notes"), { recursive: true });
+  await writeFile(join(dir, "guides", "auth.md"), "# Authentication\n\nLogin flow.\n");
+  await writeFile(join(dir, "notes", "scratch.md"), "# Scratch\n\nZeppelin notes.\n");
+
+  file = { docs_root: dir };
+  settings = resolveConfig({ file }).config;
+  config = toIndexConfig(settings);
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+  timeouts = toToolTimeouts(settings);
+  wiki = undefined;
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("diffConfig", () => {
+  test("sorts changed options by how they apply", () => {
+    const next = resolveConfig({ file: { ...file, synonyms: "auth|login", include: "guides/**", port: 4000 } }).config;
+    expect(diffConfig(settings, next)).toEqual({ live: ["synonyms"], reindex: ["include"], restart: ["port"] });
+    expect(diffConfig(settings, settings)).toEqual({ live: [], reindex: [], restart: [] });
+  });
+});
+
+describe("ConfigReloader", () => {
+  test("applies ranking, deadlines, and write mode in place", async () => {
+    file = { ...file, synonyms: "signin|login", tool_timeout_ms: 500, git_timeout_ms: 9000, wiki_write: true };
+    const report = (await reloader().reload())!;
+
+    expect(report.applied).toEqual(["SYNONYMS", "WIKI_WRITE", "TOOL_TIMEOUT_MS", "GIT_TIMEOUT_MS"]);
+    expect(report.restart).toEqual([]);
+    expect(report.reindexed).toBeNull();
+    expect(store.searchDocuments("signin").map((r) => r.doc_id)).toEqual(["docs:guides:auth"]);
+    expect(timeouts).toEqual({ default: 500, git: 9000 });
+    expect(wiki?.root).toBe(dir);
+  });
+
+  test("applies result diversity caps live", async () => {
+    await writeFile(join(dir, "guides", "login.md"), "# Login\n\nLogin flow.\n");
+    store.load(await indexAllCollections(config));
+    file = { ...file, max_per_package: 1 };
+    const report = (await reloader().reload())!;
+
+    expect(report.applied).toEqual(["MAX_PER_PACKAGE"]);
+    expect(store.searchDocuments("login").length).toBe(2);
+    expect(store.searchDocuments("login", { diversify: {} }).length).toBe(1);
+  });
+
+  test("re-indexes incrementally when file discovery changes", async () => {
+    file = { ...file, include: "guides/**" };
+    const report = (await reloader().reload())!;
+
+    expect(report.applied).toEqual(["INCLUDE"]);
+    expect(report.reindexed!.removed).toEqual(["docs:notes:scratch"]);
+    expect(report.reindexed!.unchanged).toBe(1);
+    expect(config.collections[0].include).toEqual(["guides/**"]);
+    expect(store.searchDocuments("zeppelin")).toEqual([]);
+  });
+
+  test("reports options that need a restart, and discovery where it cannot re-index", async () => {
+    file = { ...file, port: 4000, include: "guides/**" };
+    const messages: string[] = [];
+    const report = (await reloader({ reindexable: false, notify: (message) => messages.push(message) }).reload())!;
+
+    expect(report.applied).toEqual([]);
+    expect(report.restart).toEqual(["PORT", "INCLUDE"]);
+    expect(store.getStats().document_count).toBe(2);
+    expect(messages).toEqual(["Config reloaded; needs a restart: PORT, INCLUDE"]);
+  });
+
+  test("keeps the running config when the new one is invalid", async () => {
+    file = { ...file, tool_timeout_ms: -1 };
+    expect(await reloader().reload()).toBeNull();
+    expect(timeouts.default).toBe(settings.tool_timeout_ms);
+  });
+
+  test("reloads when the watched config file changes", async () => {
+    const path = join(dir, "treenav.config.json");
+    await writeFile(path, JSON.stringify(file));
+    const reports: ReloadReport[] = [];
+    const r = new ConfigReloader(
+      settings,
+      async () => resolveConfig({ file: await Bun.file(path).json() }).config,
+      { store, config, reindexable: true, timeouts, setWiki: () => {} },
+      { log: () => {}, notify: (_message, report) => reports.push(report) }
+    );
+    r.watch(path);
+    await Bun.sleep(20);
+    await writeFile(path, JSON.stringify({ ...file, reference_weight: 0 }));
+    await Bun.sleep(500);
+    r.stop();
+
+    expect(reports.map((report) => report.applied)).toEqual([["REFERENCE_WEIGHT"]]);
+  });
+});
+
+describe("formatReload", () => {
+  test("summarizes an empty reload", () => {
+    expect(formatReload({ applied: [], restart: [], reindexed: null })).toBe("Config reloaded; nothing changed");
+  });
+});
@@ -0,0 +1,171 @@
+/**
+ * Tests for config_usages: env vars, flags, and config keys found per
+ * language, definitions told from reads, matches in comments and
+ * strings left out, and the tool's per-key answer.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ConfigUsageIndex, scanConfigUsages } from "../src/config-usages";
+import { indexAllCollections } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, writeTree } from "./fixtures/tree";
+
+const CONFIG_GO = `package config
+
+import (
+	"flag"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+type DB struct {
+	Host string \`env:"DB_HOST,required"\`
+}
+
+var addr = flag.String("addr", ":8080", "listen address")
+
+func Load() {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.DurationVar(&timeout, "timeout", time.Second, "request timeout")
+	viper.SetDefault("db.pool", 10)
+	viper.BindEnv("db.host", "DB_HOST")
+}
+`;
+
+const MAIN_GO = `package main
+
+func main() {
+	// os.Getenv("COMMENTED_OUT")
+	url := os.Getenv("DB_HOST") + os.Getenv("DB_SUFFIX")
+	pool := viper.GetInt("db.pool")
+	log.Print("os.Getenv(\\"IN_A_STRING\\")")
+}
+`;
+
+const APP_TS = `export const port = Number(process.env.PORT ?? 3000);
+process.env.NODE_ENV = "test";
+const key = process.env["API_KEY"];
+program.option("-v, --verbose", "chatty");
+`;
+
+const SETTINGS_PY = `import os
+
+DEBUG = os.environ.get("DEBUG", "0")
+os.environ["TZ"] = "UTC"
+parser.add_argument("-p", "--port", type=int)
+`;
+
+let dir: string;
+let config: IndexConfig;
+let store: DocumentStore;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-config-"));
+  await writeTree(dir, {
+    "config/config.go": CONFIG_GO,
+    "main.go": MAIN_GO,
+    "app.ts": APP_TS,
+    "settings.py": SETTINGS_PY,
+  });
+  config = codeConfig(dir);
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const brief = (source: string, file: string) =>
+  scanConfigUsages(source, file).map((h) => [h.key, h.source, h.use, h.api]);
+
+describe("scanConfigUsages", () => {
+  test("Go: os, struct tags, viper, and flag sets", () => {
+    expect(brief(CONFIG_GO, "config.go")).toEqual([
+      ["DB_HOST", "env", "define", "env tag"],
+      ["addr", "flag", "define", "flag.String"],
+      ["timeout", "flag", "define", "fs.DurationVar"],
+      ["db.pool", "config", "define", "viper.SetDefault"],
+      ["DB_HOST", "env", "read", "viper.BindEnv"],
+    ]);
+  });
+
+  test("skips comments and strings, and finds several keys per line", () => {
+    expect(brief(MAIN_GO, "main.go")).toEqual([
+      ["DB_HOST", "env", "read", "os.Getenv"],
+      ["DB_SUFFIX", "env", "read", "os.Getenv"],
+      ["db.pool", "config", "read", "viper.GetInt"],
+    ]);
+  });
+
+  test("TypeScript and Python", () => {
+    expect(brief(APP_TS, "app.ts")).toEqual([
+      ["PORT", "env", "read", "process.env"],
+      ["NODE_ENV", "env", "define", "process.env"],
+      ["API_KEY", "env", "read", "process.env"],
+      ["verbose", "flag", "define", ".option"],
+    ]);
+    expect(brief(SETTINGS_PY, "settings.py")).toEqual([
+      ["DEBUG", "env", "read", "os.environ.get"],
+      ["TZ", "env", "define", "os.environ"],
+      ["port", "flag", "define", "argparse"],
+    ]);
+  });
+
+  test("JVM, Rust, and Ruby", () => {
+    expect(brief('String h = System.getenv("HOME");\n@Value("${db.pool.size:10}")\n', "A.java")).toEqual([
+      ["HOME", "env", "read", "System.getenv"],
+      ["db.pool.size", "config", "read", "@Value"],
+    ]);
+    expect(brief('let p = std::env::var("PORT")?;\n#[arg(long, env = "LOG_LEVEL")]\n', "main.rs")).toEqual([
+      ["PORT", "env", "read", "env::var"],
+      ["LOG_LEVEL", "env", "define", "clap env"],
+    ]);
+    expect(brief('ENV["RAILS_ENV"] = "test"\nsecret = ENV.fetch("SECRET")\n', "boot.rb")).toEqual([
+      ["RAILS_ENV", "env", "define", "ENV[]"],
+      ["SECRET", "env", "read", "ENV.fetch"],
+    ]);
+  });
+});
+
+describe("ConfigUsageIndex", () => {
+  test("groups sites by key, most used first", async () => {
+    const keys = await new ConfigUsageIndex(config).keys(store);
+    expect(keys[0].key).toBe("DB_HOST");
+    expect([keys[0].definitions.length, keys[0].reads.length]).toEqual([1, 2]);
+    expect(keys[0].reads.map((s) => s.file_path)).toEqual(["config/config.go", "main.go"]);
+  });
+
+  test("filters by key, prefix, source, and path", async () => {
+    const index = new ConfigUsageIndex(config);
+    expect((await index.keys(store, { key: "DB_*" })).map((k) => k.key)).toEqual(["DB_HOST", "DB_SUFFIX"]);
+    expect((await index.keys(store, { key: "--port" })).map((k) => k.key)).toEqual(["port"]);
+    expect((await index.keys(store, { source: "config" })).map((k) => k.key)).toEqual(["db.pool"]);
+    expect((await index.keys(store, { path: "config/", source: "flag" })).map((k) => k.key)).toEqual(["addr", "timeout"]);
+  });
+});
+
+describe("config_usages tool", () => {
+  test("lists definitions and reads with the enclosing symbol", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { configUsages: new ConfigUsageIndex(config) });
+    const result = await harness.client.callTool({ name: "config_usages", arguments: { key: "DB_HOST" } });
+    const text = getToolText(result as any);
+    expect(text).toContain("DB_HOST (env): 1 definition(s), 2 read(s)");
+    expect(text).toContain("  read    main.go:5  os.Getenv  in function main");
+    const data = result.structuredContent as any;
+    expect(data.keys[0].definitions[0].uri).toContain("config/config.go#L11");
+
+    const capped = await harness.client.callTool({ name: "config_usages", arguments: { key: "DB_HOST", sites: 2 } });
+    expect(getToolText(capped as any)).toContain("… 1 more; raise sites or narrow with path");
+
+    const none = await harness.client.callTool({ name: "config_usages", arguments: { key: "NOWHERE" } });
+    expect((none.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
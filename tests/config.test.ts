@@ -0,0 +1,225 @@
+/**
+ * Tests for runtime configuration resolution.
+ *
+ * Covers: flag > env > file > default precedence and reported sources,
+ * value coercion, unknown config-file keys, config file discovery,
+ * synonym groups, and the --print-config dump.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import {
+  ConfigError,
+  formatConfig,
+  parseCodeRoots,
+  parsePathBoosts,
+  parseSynonymGroups,
+  readConfigFile,
+  resolveConfig,
+  toIndexConfig,
+} from "../src/config";
+
+describe("resolveConfig", () => {
+  test("falls back to defaults", () => {
+    const { config, sources } = resolveConfig({});
+    expect(config.docs_root).toBe("./docs");
+    expect(config.max_depth).toBe(6);
+    expect(config.wiki_write).toBe(false);
+    expect(sources.docs_root).toBe("default");
+  });
+
+  test("flag beats env beats file", () => {
+    const { config, sources } = resolveConfig({
+      flags: { "docs-root": "/flag" },
+      env: { DOCS_ROOT: "/env", MAX_DEPTH: "3" },
+      file: { docs_root: "/file", max_depth: 4, summary_length: 80 },
+    });
+    expect(config.docs_root).toBe("/flag");
+    expect(sources.docs_root).toBe("flag");
+    expect(config.max_depth).toBe(3);
+    expect(sources.max_depth).toBe("env");
+    expect(config.summary_length).toBe(80);
+    expect(sources.summary_length).toBe("file");
+  });
+
+  test("ignores empty env values", () => {
+    const { config, sources } = resolveConfig({
+      env: { CODE_ROOT: "" },
+      file: { code_root: "/src" },
+    });
+    expect(config.code_root).toBe("/src");
+    expect(sources.code_root).toBe("file");
+  });
+
+  test("coerces booleans and lists", () => {
+    const { config } = resolveConfig({
+      flags: { "wiki-write": true },
+      env: { LAZY_EAGER: "docs/auth, docs/api" },
+      file: { shards: ["docs", "src"] },
+    });
+    expect(config.wiki_write).toBe(true);
+    expect(config.lazy_eager).toEqual(["docs/auth", "docs/api"]);
+    expect(config.shards).toEqual(["docs", "src"]);
+  });
+
+  test("rejects values of the wrong type", () => {
+    expect(() => resolveConfig({ flags: { port: "abc" } })).toThrow(ConfigError);
+    expect(() => resolveConfig({ env: { WIKI_WRITE: "maybe" } })).toThrow(/expected a boolean/);
+  });
+
+  test("rejects unknown config file keys", () => {
+    expect(() => resolveConfig({ file: { docs_rot: "./docs" } })).toThrow(/unknown option "docs_rot"/);
+  });
+});
+
+describe("readConfigFile", () => {
+  let dir: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-config-"));
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("finds treenav.config.json in the working directory", async () => {
+    await writeFile(join(dir, "treenav.config.json"), JSON.stringify({ docs_root: "/file" }));
+    const { path, values } = await readConfigFile({}, {}, dir);
+    expect(path).toBe(join(dir, "treenav.config.json"));
+    expect(values).toEqual({ docs_root: "/file" });
+  });
+
+  test("returns nothing when no file exists", async () => {
+    expect(await readConfigFile({}, {}, dir)).toEqual({ path: null, values: {} });
+  });
+
+  test("errors when an explicit path is missing", async () => {
+    await expect(readConfigFile({ config: join(dir, "nope.json") }, {}, dir)).rejects.toThrow(
+      /config file not found/
+    );
+  });
+
+  test("rejects a non-object file", async () => {
+    const path = join(dir, "list.json");
+    await writeFile(path, "[1, 2]");
+    await expect(readConfigFile({}, { TREENAV_CONFIG: path }, dir)).rejects.toThrow(/JSON object/);
+  });
+});
+
+describe("formatConfig", () => {
+  test("reports each option's value and source", () => {
+    const { config, sources } = resolveConfig({ flags: { port: "4000" } });
+    const dump = JSON.parse(formatConfig(config, sources, "/etc/treenav.config.json"));
+    expect(dump.config_file).toBe("/etc/treenav.config.json");
+    expect(dump.options.port).toEqual({ value: 4000, source: "flag" });
+    expect(dump.options.code_root).toEqual({ value: null, source: "default" });
+  });
+
+  test("masks secrets", () => {
+    const { config, sources } = resolveConfig({ env: { HTTP_TOKEN: "s3cret" } });
+    const dump = formatConfig(config, sources, null);
+    expect(JSON.parse(dump).options.http_token).toEqual({ value: "(set)", source: "env" });
+    expect(dump).not.toContain("s3cret");
+  });
+});
+
+describe("toIndexConfig", () => {
+  test("adds a code collection only when code_root is set", () => {
+    expect(toIndexConfig(resolveConfig({}).config).code_collections).toBeUndefined();
+
+    const index = toIndexConfig(resolveConfig({ env: { CODE_ROOT: "/src", CODE_WEIGHT: "0.5" } }).config);
+    expect(index.code_collections?.[0].root).toBe("/src");
+    expect(index.code_collections?.[0].weight).toBe(0.5);
+  });
+
+  test("adds the code_roots collections after code_root", () => {
+    const index = toIndexConfig(resolveConfig({ env: { CODE_ROOT: "/svc", CODE_ROOTS: "lib=/lib, billing=/billing" } }).config);
+    expect(index.code_collections?.map((c) => [c.name, c.root])).toEqual([
+      ["code", "/svc"],
+      ["lib", "/lib"],
+      ["billing", "/billing"],
+    ]);
+  });
+});
+
+describe("parseCodeRoots", () => {
+  test("reads name=path entries", () => {
+    expect(parseCodeRoots(["lib=/src/lib", "ui=../web=ui"])).toEqual([
+      { name: "lib", root: "/src/lib" },
+      { name: "ui", root: "../web=ui" },
+    ]);
+  });
+
+  test("rejects bad entries, repeated names, and names already taken", () => {
+    for (const bad of ["/src/lib", "=/src/lib", "lib=", "a b=/src"]) {
+      expect(() => parseCodeRoots([bad])).toThrow(ConfigError);
+    }
+    expect(() => parseCodeRoots(["lib=/a", "lib=/b"])).toThrow('collection "lib" is given twice');
+    expect(() => resolveConfig({ env: { CODE_ROOT: "/svc", CODE_ROOTS: "code=/lib" } })).toThrow('collection "code" is already defined');
+    expect(() => resolveConfig({ env: { CODE_ROOTS: "docs=/lib" } })).toThrow("CODE_ROOTS");
+  });
+});
+
+describe("parseSynonymGroups", () => {
+  test("reads env-style and config-file groups alike", () => {
+    const fromEnv = resolveConfig({ env: { SYNONYMS: "auth|authn, db|database" } }).config.synonyms;
+    const fromFile = resolveConfig({ file: { synonyms: [["auth", "authn"], ["db", "database"]] } }).config.synonyms;
+    expect(parseSynonymGroups(fromEnv)).toEqual([["auth", "authn"], ["db", "database"]]);
+    expect(parseSynonymGroups(fromFile)).toEqual([["auth", "authn"], ["db", "database"]]);
+  });
+
+  test("drops groups with a single term", () => {
+    expect(parseSynonymGroups(["auth", "db|", ["x"], ["kv", "key value"]])).toEqual([["kv", "key value"]]);
+  });
+});
+
+describe("parsePathBoosts", () => {
+  test("reads pattern=weight entries", () => {
+    const { config } = resolveConfig({ env: { PATH_BOOSTS: "*.pb.go=0.5, internal/=1.5" } });
+    expect(parsePathBoosts(config.path_boosts)).toEqual([
+      { pattern: "*.pb.go", weight: 0.5 },
+      { pattern: "internal/", weight: 1.5 },
+    ]);
+  });
+
+  test("rejects entries without a positive weight", () => {
+    for (const bad of ["*.go", "*.go=", "=2", "*.go=fast", "*.go=0", "*.go=-1"]) {
+      expect(() => parsePathBoosts([bad])).toThrow(ConfigError);
+    }
+  });
+
+  test("resolveConfig names the source of a bad entry", () => {
+    expect(() => resolveConfig({ flags: { "path-boosts": "examples/" } })).toThrow("--path-boosts");
+    expect(() => resolveConfig({ file: { path_boosts: ["*.go=x"] } })).toThrow('config file "path_boosts"');
+  });
+});
+
+describe("recency options", () => {
+  test("default to off", () => {
+    const { config } = resolveConfig({});
+    expect(config.recency_weight).toBe(0);
+    expect(config.recency_half_life_days).toBe(180);
+  });
+
+  test("reject a negative weight or a non-positive half-life", () => {
+    expect(() => resolveConfig({ env: { RECENCY_WEIGHT: "-0.1" } })).toThrow(ConfigError);
+    expect(() => resolveConfig({ env: { RECENCY_HALF_LIFE_DAYS: "0" } })).toThrow("RECENCY_HALF_LIFE_DAYS");
+  });
+});
+
+describe("result diversity options", () => {
+  test("default to no cap", () => {
+    const { config } = resolveConfig({});
+    expect(config.max_per_file).toBe(0);
+    expect(config.max_per_package).toBe(0);
+  });
+
+  test("take whole numbers only", () => {
+    expect(resolveConfig({ env: { MAX_PER_FILE: "3" } }).config.max_per_file).toBe(3);
+    expect(() => resolveConfig({ env: { MAX_PER_FILE: "1.5" } })).toThrow("MAX_PER_FILE");
+    expect(() => resolveConfig({ flags: { "max-per-package": "-1" } })).toThrow(ConfigError);
+  });
+});
@@ -0,0 +1,160 @@
+/**
+ * Tests for context_audit: Go signatures, context parameters, dropped
+ * and background contexts, context-less API variants, unused contexts,
+ * and the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ContextAudit, contextParam, goSignature } from "../src/context-audit";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+describe("goSignature and contextParam", () => {
+  test("reads parameters past receivers, type parameters, and line breaks", () => {
+    expect(goSignature(["func (s *S) Map[T any](ctx context.Context, a, b int) (T, error) {"], 1)).toEqual({
+      params: ["ctx context.Context", "a", "b int"],
+      body: 1,
+    });
+    expect(goSignature(["func F(", "\tctx context.Context,", "\tname string,", ") error {"], 1)).toEqual({
+      params: ["ctx context.Context", "name string"],
+      body: 4,
+    });
+  });
+
+  test("names the context parameter, or _ when it has none", () => {
+    expect(contextParam(["ctx context.Context", "name string"])).toBe("ctx");
+    expect(contextParam(["context.Context", "string"])).toBe("_");
+    expect(contextParam(["_ context.Context"])).toBe("_");
+    expect(contextParam(["a", "b int"])).toBeUndefined();
+  });
+});
+
+// ── A scratch tree ───────────────────────────────────────────────────
+
+const FILES: Record<string, string> = {
+  "svc/service.go": `package svc
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+)
+
+type Service struct {
+	db    *sql.DB
+	store *Store
+}
+
+func (s *Service) Join(ctx context.Context, name string) error {
+	tctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := s.store.Save(tctx, name); err != nil {
+		return err
+	}
+	go s.store.Save(context.Background(), name)
+	return s.store.Save(nil, name)
+}
+
+func (s *Service) Lookup(ctx context.Context, id string) (*sql.Rows, error) {
+	req, _ := http.NewRequest("GET", "/x", nil)
+	_ = req
+	return s.db.Query("SELECT 1")
+}
+
+func (s *Service) Ping(_ context.Context) error {
+	return notify("ping")
+}
+
+func (s *Service) Quiet(ctx context.Context) {}
+
+func helper(ctx context.Context) {
+	_ = context.TODO()
+}
+`,
+  "svc/store.go": `package svc
+
+import "context"
+
+type Store struct{}
+
+func (st *Store) Save(ctx context.Context, name string) error {
+	return nil
+}
+
+func notify(msg string) error { return nil }
+`,
+};
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-context-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES));
+
+describe("ContextAudit", () => {
+  test("flags exported functions that drop or replace their context", async () => {
+    const report = await new ContextAudit(config).audit(await indexedStore());
+    expect([report.files, report.functions, report.flagged]).toEqual([2, 5, 3]);
+    expect(report.by_kind).toEqual({ background: 1, dropped: 1, non_context_variant: 2, unused: 2 });
+    expect(report.findings.map((f) => [f.line, f.kind, f.function, f.callee ?? null, f.passed ?? f.suggestion ?? null])).toEqual([
+      [20, "background", "Service.Join", null, null],
+      [21, "dropped", "Service.Join", "store.Save", "nil"],
+      [24, "unused", "Service.Lookup", null, null],
+      [25, "non_context_variant", "Service.Lookup", "http.NewRequest", "http.NewRequestWithContext"],
+      [27, "non_context_variant", "Service.Lookup", "db.Query", "QueryContext"],
+      [30, "unused", "Service.Ping", null, null],
+    ]);
+  });
+
+  test("unexported functions on request, and a path prefix", async () => {
+    const audit = new ContextAudit(config);
+    const store = await indexedStore();
+    const all = await audit.audit(store, { include_unexported: true });
+    expect(all.findings.filter((f) => f.function === "helper").map((f) => [f.line, f.kind])).toEqual([[37, "background"]]);
+    expect((await audit.audit(store, { path: "svc/store" })).findings).toEqual([]);
+  });
+});
+
+describe("context_audit tool", () => {
+  test("groups findings by file and function and filters by kind", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      contextAudit: new ContextAudit(config),
+    });
+    const result = await harness.client.callTool({ name: "context_audit", arguments: {} });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(6);
+    expect(data.findings[1]).toEqual({
+      kind: "dropped",
+      function: "Service.Join",
+      exported: true,
+      doc_id: "code:svc:service_go",
+      file_path: "svc/service.go",
+      line: 21,
+      text: "return s.store.Save(nil, name)",
+      callee: "store.Save",
+      passed: "nil",
+      uri: "treenav://file/svc/service.go#L21",
+    });
+    const text = getToolText(result as any);
+    expect(text).toContain("5 function(s) take a context.Context; 3 flagged — 1 background, 1 dropped, 2 non_context_variant, 2 unused");
+    expect(text).toContain("  Service.Join");
+    expect(text).toContain("    :21  store.Save gets nil instead of the context  return s.store.Save(nil, name)");
+
+    const dropped = await harness.client.callTool({ name: "context_audit", arguments: { kind: "dropped" } });
+    expect((dropped.structuredContent as any).findings.map((f: any) => f.line)).toEqual([21]);
+    await harness.cleanup();
+  });
+});
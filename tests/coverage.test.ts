@@ -0,0 +1,102 @@
+/**
+ * Tests for sparse indexing (INCLUDE).
+ *
+ * Covers: pattern normalization, directory pruning, include-restricted
+ * file listing for docs and code, and "not indexed" status text.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join, relative } from "node:path";
+import { tmpdir } from "node:os";
+import {
+  IndexCoverage,
+  isIncluded,
+  mayContainIncluded,
+  normalizeIncludes,
+} from "../src/coverage";
+import { listCollectionFiles } from "../src/indexer";
+import { listCodeFiles } from "../src/code-indexer";
+import type { CollectionConfig } from "../src/types";
+
+function collection(include: string[]): CollectionConfig {
+  return { name: "code", root: "/repo", weight: 1, include };
+}
+
+describe("include patterns", () => {
+  test("a bare directory means everything beneath it", () => {
+    expect(normalizeIncludes(["./src", "pkg/", "lib/**/*.go"])).toEqual([
+      "src",
+      "src/**",
+      "pkg/**",
+      "lib/**/*.go",
+    ]);
+  });
+
+  test("matches relative paths against the set", () => {
+    const c = collection(["src/**", "pkg"]);
+    expect(isIncluded(c, "src/a/b.ts")).toBe(true);
+    expect(isIncluded(c, "pkg/x.go")).toBe(true);
+    expect(isIncluded(c, "vendor/x.go")).toBe(false);
+    expect(isIncluded(collection([]), "vendor/x.go")).toBe(true);
+  });
+
+  test("prunes directories no pattern can reach", () => {
+    const c = collection(["src/server/**", "**/*.proto"]);
+    expect(mayContainIncluded(collection(["src/server/**"]), "vendor")).toBe(false);
+    expect(mayContainIncluded(collection(["src/server/**"]), "src")).toBe(true);
+    expect(mayContainIncluded(collection(["src/server/**"]), "src/client")).toBe(false);
+    expect(mayContainIncluded(c, "vendor")).toBe(true); // **/*.proto can match anywhere
+  });
+});
+
+describe("sparse listing", () => {
+  let dir: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-sparse-"));
+    for (const sub of ["src", "pkg", "vendor"]) {
+      await mkdir(join(dir, sub), { recursive: true });
+      await writeFile(join(dir, sub, "notes.md"), `# ${sub}\n`);
+      await writeFile(join(dir, sub, "main.go"), "package main\n");
+    }
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("markdown and code collections only list included files", async () => {
+    const include = ["src/**", "pkg/**"];
+    const docs = await listCollectionFiles({ name: "docs", root: dir, weight: 1, include });
+    const code = await listCodeFiles({ name: "code", root: dir, weight: 1, include });
+
+    expect(docs.map((f) => relative(dir, f)).sort()).toEqual([join("pkg", "notes.md"), join("src", "notes.md")]);
+    expect(code.map((f) => relative(dir, f)).sort()).toEqual([join("pkg", "main.go"), join("src", "main.go")]);
+  });
+});
+
+describe("IndexCoverage", () => {
+  const coverage = new IndexCoverage({
+    collections: [{ name: "docs", root: "/repo/docs", weight: 1 }],
+    code_collections: [collection(["src/**", "pkg/**"])],
+    max_depth: 6,
+    summary_length: 200,
+  });
+
+  test("recovers the file path from a code doc_id", () => {
+    expect(coverage.excludedDocId("code:vendor:lib_go")).toContain("vendor/lib.go is outside");
+    expect(coverage.excludedDocId("code:src:main_go")).toBeNull();
+  });
+
+  test("collections without include patterns are never excluded", () => {
+    expect(coverage.excludedDocId("docs:anything:here")).toBeNull();
+    expect(coverage.excludedPrefix("vendor")).toBeNull();
+  });
+
+  test("a non-sparse index reports nothing", () => {
+    const full = new IndexCoverage({ collections: [collection([])], max_depth: 6, summary_length: 200 });
+    expect(full.isSparse()).toBe(false);
+    expect(full.describe()).toBe("");
+  });
+});
@@ -466,6 +466,30 @@ describe("MCP curation round trip", () => {
     expect(names).toContain("find_similar");
     expect(names).toContain("draft_wiki_entry");
     expect(names).toContain("write_wiki_entry");
+    for (const tool of tools) {
+      expect(tool.outputSchema?.required).toContain("schema_version");
+    }
+    const write = tools.find((t) => t.name === "write_wiki_entry")!;
+    expect(write.annotations?.readOnlyHint).toBe(false);
+    expect(write.annotations?.destructiveHint).toBe(true);
+    expect(write.annotations?.idempotentHint).toBe(false);
+    const draft = tools.find((t) => t.name === "draft_wiki_entry")!;
+    expect(draft.annotations?.readOnlyHint).toBe(true);
+  });
+
+  test("write mode can be switched on and off after connecting", async () => {
+    harness = await createMcpTestClient([]);
+    const names = async () => (await harness.client.listTools()).tools.map((t) => t.name);
+
+    expect(harness.tools.setWiki({ root, duplicateThreshold: 0.35 })).toBe(true);
+    expect(await names()).toContain("write_wiki_entry");
+
+    // A new threshold applies in place; the tool list is unchanged
+    expect(harness.tools.setWiki({ root, duplicateThreshold: 0.5 })).toBe(false);
+
+    expect(harness.tools.setWiki(undefined)).toBe(true);
+    expect(await names()).not.toContain("find_similar");
+    expect(harness.tools.setWiki(undefined)).toBe(false);
   });
 
   test("full workflow: draft → write → search via MCP client", async () => {
@@ -493,6 +517,10 @@ describe("MCP curation round trip", () => {
     const draftText = getToolText(draftRes as any);
     expect(draftText).toContain("service-mesh.md");
     expect(draftText).toContain("guide"); // inferred type
+    const draft = (draftRes as any).structuredContent;
+    expect(draft.status).toBe("ok");
+    expect(draft.suggested_path).toBe("guides/service-mesh.md");
+    expect(draft.frontmatter.type).toBe("guide");
 
     // 2. Write the entry
     const writeRes = await harness.client.callTool({
@@ -511,6 +539,10 @@ describe("MCP curation round trip", () => {
     const writeText = getToolText(writeRes as any);
     expect(writeText).toContain('"written": true');
     expect(writeText).toContain("service-mesh");
+    const written = (writeRes as any).structuredContent;
+    expect(written.schema_version).toBe(1);
+    expect(written.written).toBe(true);
+    expect(written.doc_id).toContain("service-mesh");
 
     // 3. The new entry should be searchable via the regular search tool
     const searchRes = await harness.client.callTool({
@@ -542,5 +574,9 @@ describe("MCP curation round trip", () => {
     const text = getToolText(res as any);
     expect(text).toContain("matches");
     expect(text).toContain("auth");
+
+    const out = (res as any).structuredContent;
+    expect(out.status).toBe("ok");
+    expect(out.matches[0].doc_id).toContain("auth");
   });
 });
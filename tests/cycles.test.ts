@@ -0,0 +1,125 @@
+/**
+ * Tests for find_cycles: import parsing per language, Go and TS package
+ * resolution, cycles versus type-only near-cycles, cuts, and the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { CycleFinder, fileImports } from "../src/cycles";
+import { GoModuleIndex } from "../src/go-modules";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+describe("fileImports", () => {
+  test("marks type-only TypeScript imports", () => {
+    const source = [
+      'import { a } from "./a";',
+      'import type { B } from "../b";',
+      "import {",
+      "  type C,",
+      "  type D,",
+      '} from "./c";',
+      'export * from "./e";',
+      'const f = require("./f");',
+    ].join("\n");
+    expect(fileImports(source, "x.ts").map((i) => [i.spec, i.line, i.type_only])).toEqual([
+      ["./a", 1, false],
+      ["../b", 2, true],
+      ["./c", 3, true],
+      ["./e", 7, false],
+      ["./f", 8, false],
+    ]);
+  });
+
+  test("reads Python imports, under TYPE_CHECKING as type-only", () => {
+    const source = ["import os, app.db as db", "from ..core import x", "if TYPE_CHECKING:", "    from .models import M", "from . import y"].join(
+      "\n"
+    );
+    expect(fileImports(source, "pkg/mod.py").map((i) => [i.spec, i.type_only])).toEqual([
+      ["os", false],
+      ["app.db", false],
+      ["..core", false],
+      [".models", true],
+      [".", false],
+    ]);
+  });
+
+  test("includes blank Go imports and skips test files", () => {
+    const source = 'package x\n\nimport (\n\t_ "example.com/app/db"\n\tlog "log"\n)\n';
+    expect(fileImports(source, "x/x.go").map((i) => [i.spec, i.line])).toEqual([
+      ["example.com/app/db", 4],
+      ["log", 5],
+    ]);
+    expect(fileImports(source, "x/x_test.go")).toEqual([]);
+  });
+});
+
+// ── A scratch repository ─────────────────────────────────────────────
+
+const FILES: Record<string, string> = {
+  "go.mod": "module example.com/app\n\ngo 1.22\n",
+  "api/api.go": 'package api\n\nimport "example.com/app/db"\n\nvar _ = db.X\n',
+  "db/db.go": 'package db\n\nimport "example.com/app/cache"\n\nvar X = cache.Y\n',
+  "cache/cache.go": 'package cache\n\nimport (\n\t"fmt"\n\t"example.com/app/api"\n)\n\nvar Y = fmt.Sprint(api.Z)\n',
+  "web/ui/view.ts": 'import { store } from "../state";\nexport const view = store;\n',
+  "web/state/index.ts": 'import type { View } from "../ui/view";\nexport const store = {} as View;\n',
+  "web/util/log.ts": 'import { view } from "../ui/view";\nexport const log = view;\n',
+};
+
+let dir: string;
+let config: IndexConfig;
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES));
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-cycles-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("CycleFinder", () => {
+  test("finds a Go cycle by import path and a type-only near-cycle", async () => {
+    const report = (await new CycleFinder(config, new GoModuleIndex(config)).find(await indexedStore()))!;
+    expect([report.packages, report.edges]).toEqual([6, 6]);
+    expect(report.cycles.map((c) => [c.kind, c.packages])).toEqual([
+      ["cycle", ["example.com/app/api", "example.com/app/cache", "example.com/app/db"]],
+      ["near_cycle", ["web/state", "web/ui"]],
+    ]);
+    const [go, web] = report.cycles;
+    expect(go.path).toEqual(["example.com/app/api", "example.com/app/db", "example.com/app/cache", "example.com/app/api"]);
+    expect(go.cut).toHaveLength(1);
+    expect(web.cut.map((e) => [e.from, e.to, e.type_only, e.sites[0].line])).toEqual([["web/state", "web/ui", true, 1]]);
+  });
+
+  test("scopes to a path and returns null without code there", async () => {
+    const finder = new CycleFinder(config, new GoModuleIndex(config));
+    const report = (await finder.find(await indexedStore(), { path: "web" }))!;
+    expect(report.cycles.map((c) => c.kind)).toEqual(["near_cycle"]);
+    expect(await finder.find(await indexedStore(), { path: "nowhere" })).toBeNull();
+  });
+});
+
+describe("find_cycles tool", () => {
+  test("lists cycles with their cuts, or draws them", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      cycles: new CycleFinder(config, new GoModuleIndex(config)),
+    });
+    const result = await harness.client.callTool({ name: "find_cycles", arguments: {} });
+    const text = getToolText(result as any);
+    expect(text).toContain("2 import cycle(s) among 6 package(s), 6 import edge(s):");
+    expect(text).toContain("near-cycle, closed only by type-only imports [code]: web/state → web/ui → web/state");
+    expect(text).toContain("web/state → web/ui (type-only)  web/state/index.ts:1");
+
+    const drawn = await harness.client.callTool({ name: "find_cycles", arguments: { path: "web", format: "mermaid" } });
+    expect((drawn.structuredContent as any).diagram).toContain('-.->|"cut"|');
+    expect(getToolText(drawn as any).startsWith("```mermaid\n")).toBe(true);
+    await harness.cleanup();
+  });
+});
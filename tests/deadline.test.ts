@@ -0,0 +1,72 @@
+/**
+ * Tests for tool deadlines: which timeout applies to a call, the
+ * current deadline across awaits, and how late answers are reported.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { currentDeadline, Deadline, spawnTimeout, timeoutFor, withDeadline } from "../src/deadline";
+import type { Hotspots } from "../src/hotspots";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+describe("timeoutFor", () => {
+  const timeouts = { default: 30_000, git: 120_000 };
+
+  test("picks the category override, else the global timeout", () => {
+    expect(timeoutFor("hotspots", {}, timeouts)).toEqual({ ms: 120_000, setting: "GIT_TIMEOUT_MS" });
+    expect(timeoutFor("search_documents", {}, timeouts)).toEqual({ ms: 30_000, setting: "TOOL_TIMEOUT_MS" });
+    expect(timeoutFor("get_tree", {}, timeouts)).toEqual({ ms: 30_000, setting: "TOOL_TIMEOUT_MS" });
+  });
+
+  test("treats reads at a git ref as git operations and never times writes", () => {
+    expect(timeoutFor("search_documents", { ref: "v1.0.0" }, timeouts).ms).toBe(120_000);
+    expect(timeoutFor("structural_replace", {}, timeouts).ms).toBe(0);
+  });
+});
+
+describe("Deadline", () => {
+  test("is current across awaits inside withDeadline only", async () => {
+    const deadline = new Deadline(1_000);
+    const seen = await withDeadline(deadline, async () => {
+      await Bun.sleep(1);
+      return currentDeadline();
+    });
+    expect(seen).toBe(deadline);
+    expect(currentDeadline()).toBeUndefined();
+    expect(spawnTimeout()).toEqual({});
+    expect(withDeadline(deadline, () => spawnTimeout().timeout! > 0)).toBe(true);
+  });
+});
+
+describe("timed tool calls", () => {
+  const slowHotspots = (ms: number) =>
+    ({
+      rank: async () => {
+        await Bun.sleep(ms);
+        return { files: 1, changed: 0, hotspots: [] };
+      },
+    }) as unknown as Hotspots;
+
+  test("an answer past the deadline is flagged timed_out", async () => {
+    const harness = await createMcpTestClient([], { hotspots: slowHotspots(30), timeouts: { default: 5 } });
+    const result = await harness.client.callTool({ name: "hotspots", arguments: {} });
+    expect((result.structuredContent as any).timed_out).toBe(true);
+    expect(getToolText(result as any)).toStartWith("Note: the 5 ms deadline (TOOL_TIMEOUT_MS) passed while answering");
+    await harness.cleanup();
+  });
+
+  test("an answer in time is not flagged", async () => {
+    const harness = await createMcpTestClient([], { hotspots: slowHotspots(0), timeouts: { default: 5_000 } });
+    const result = await harness.client.callTool({ name: "hotspots", arguments: {} });
+    expect((result.structuredContent as any).timed_out).toBeUndefined();
+    await harness.cleanup();
+  });
+
+  test("a handler that never answers fails with a timeout error", async () => {
+    const stuck = { rank: () => new Promise(() => {}) } as unknown as Hotspots;
+    const harness = await createMcpTestClient([], { hotspots: stuck, timeouts: { default: 10, git: 20 } });
+    const result = await harness.client.callTool({ name: "hotspots", arguments: {} });
+    expect(result.isError).toBe(true);
+    expect(getToolText(result as any)).toContain("hotspots timed out after 20 ms (GIT_TIMEOUT_MS)");
+    await harness.cleanup();
+  });
+});
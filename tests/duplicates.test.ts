@@ -0,0 +1,143 @@
+/**
+ * Tests for duplicate detection: token normalization, shingling,
+ * clone grouping and thresholds, and the find_duplicates tool.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { codeTokens, DuplicateFinder, shingles } from "../src/duplicates";
+import { DocumentStore } from "../src/store";
+import { createMcpTestClient, getToolText, makeDoc, makeNode } from "./fixtures/helpers";
+
+const RETRY = `function retryFetch(url, attempts) {
+  // keep trying until the server answers
+  for (let i = 0; i < attempts; i++) {
+    const res = fetch(url, { timeout: 5000 });
+    if (res.ok) return res.json();
+    sleep(i * 200);
+  }
+  throw new Error("gave up after " + attempts);
+}`;
+
+/** RETRY with every name and constant changed */
+const RENAMED = `function pollStatus(endpoint, tries) {
+  for (let n = 0; n < tries; n++) {
+    const reply = fetch(endpoint, { timeout: 1000 });
+    if (reply.ok) return reply.json();
+    wait(n * 50);
+  }
+  throw new Error("no status after " + tries);
+}`;
+
+const UNRELATED = `function sumPrices(items) {
+  let total = 0;
+  items.forEach((item) => { total += item.price * item.quantity; });
+  return Math.round(total * 100) / 100;
+}`;
+
+function codeDoc(file: string, bodies: Record<string, string>) {
+  const id = `code:src:${file.replace(".", "_")}`;
+  let line = 1;
+  return makeDoc({
+    meta: {
+      doc_id: id,
+      file_path: `src/${file}`,
+      title: file,
+      collection: "code",
+      content_hash: file,
+      facets: { language: ["typescript"], content_type: ["code"] },
+    },
+    tree: Object.entries(bodies).map(([name, content], i) => {
+      const start = line;
+      line += content.split("\n").length + 1;
+      return makeNode({
+        node_id: `${id}:n${i + 1}`,
+        title: `function ${name}`,
+        content,
+        line_start: start,
+        line_end: line - 2,
+      });
+    }),
+  });
+}
+
+const DOCS = [
+  codeDoc("http.ts", { retryFetch: RETRY, sumPrices: UNRELATED }),
+  codeDoc("status.ts", { pollStatus: RENAMED }),
+];
+
+function store(): DocumentStore {
+  const s = new DocumentStore();
+  s.load(DOCS);
+  return s;
+}
+
+describe("codeTokens", () => {
+  test("drops comments and normalizes names and literals", () => {
+    expect(codeTokens('// note\nif (count > 10) return "x";')).toEqual(["if", "(", "$", ">", "#", ")", "return", "#", ";"]);
+  });
+
+  test("keeps identifiers when asked", () => {
+    expect(codeTokens("return total;", true)).toEqual(["return", "total", ";"]);
+  });
+});
+
+describe("shingles", () => {
+  test("makes overlapping windows, or one shingle for short input", () => {
+    expect(shingles(["a", "b", "c", "d"], 3)).toEqual(new Set(["a b c", "b c d"]));
+    expect(shingles(["a", "b"], 3)).toEqual(new Set(["a b"]));
+  });
+});
+
+describe("DuplicateFinder", () => {
+  test("groups copies that differ only in names and constants", () => {
+    const { groups, units } = new DuplicateFinder().find(store(), { min_tokens: 20 });
+    expect(units).toBe(3);
+    expect(groups).toHaveLength(1);
+    expect(groups[0].similarity).toBe(1);
+    expect(groups[0].members.map((m) => m.title)).toEqual(["function retryFetch", "function pollStatus"]);
+  });
+
+  test("misses renamed copies when identifiers are kept", () => {
+    const { groups } = new DuplicateFinder().find(store(), { min_tokens: 20, normalize_identifiers: false });
+    expect(groups).toEqual([]);
+  });
+
+  test("skips functions below the size threshold", () => {
+    const { groups, units } = new DuplicateFinder().find(store(), { min_tokens: 500 });
+    expect(units).toBe(0);
+    expect(groups).toEqual([]);
+  });
+
+  test("limits the comparison to a path prefix", () => {
+    const { units } = new DuplicateFinder().find(store(), { min_tokens: 20, path_prefix: "src/status" });
+    expect(units).toBe(1);
+  });
+});
+
+describe("find_duplicates tool", () => {
+  test("is registered only with a finder", async () => {
+    const without = await createMcpTestClient(DOCS);
+    const names = (await without.client.listTools()).tools.map((t) => t.name);
+    expect(names.includes("find_duplicates")).toBe(false);
+    await without.cleanup();
+  });
+
+  test("lists clone groups with their locations", async () => {
+    const harness = await createMcpTestClient(DOCS, { duplicates: new DuplicateFinder() });
+    const result = await harness.client.callTool({ name: "find_duplicates", arguments: { min_tokens: 20 } });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(1);
+    expect(data.groups[0].members.map((m: any) => m.file_path)).toEqual(["src/http.ts", "src/status.ts"]);
+    const text = getToolText(result as any);
+    expect(text).toContain("1 clone group(s) among 3 functions");
+    expect(text).toContain("2 copies, 100% similar");
+    await harness.cleanup();
+  });
+
+  test("reports not_found when nothing is large enough", async () => {
+    const harness = await createMcpTestClient(DOCS, { duplicates: new DuplicateFinder() });
+    const result = await harness.client.callTool({ name: "find_duplicates", arguments: { path: "docs/" } });
+    expect((result.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
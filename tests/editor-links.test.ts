@@ -0,0 +1,97 @@
+/**
+ * Tests for editor links: presets and templates, filling them in, and
+ * the tools adding `editor_url` next to every uri, per session.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { EditorLinkError, EditorLinks, editorTemplate, formatEditorUrl } from "../src/editor-links";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, makeDoc } from "./fixtures/helpers";
+
+const config: IndexConfig = {
+  collections: [{ name: "docs", root: "/work/docs", weight: 1.0 }],
+  code_collections: [{ name: "code", root: "/work/repo", weight: 1.0 }],
+  summary_length: 200,
+  max_depth: 6,
+};
+
+const docs = () => [
+  makeDoc({ meta: { doc_id: "code:internal:pool_go", collection: "code", file_path: "internal/pool.go" } }),
+  makeDoc({ meta: { doc_id: "docs:guides:auth", collection: "docs", file_path: "guides/auth.md" } }),
+];
+
+describe("editorTemplate", () => {
+  test("knows presets and takes templates of its own", () => {
+    expect(editorTemplate("vscode")).toBe("vscode://file{file}:{line}:{column}");
+    expect(editorTemplate(" JetBrains ")).toContain("jetbrains://idea/navigate/reference");
+    expect(editorTemplate("myeditor://open?file={file}&line={line}")).toBe("myeditor://open?file={file}&line={line}");
+  });
+
+  test("rejects unknown editors and placeholders, and templates without a file", () => {
+    expect(() => editorTemplate("notepad")).toThrow(EditorLinkError);
+    expect(() => editorTemplate("x://{file}:{row}")).toThrow("{row}");
+    expect(() => editorTemplate("x://open?line={line}")).toThrow("names no file");
+  });
+});
+
+describe("formatEditorUrl", () => {
+  test("fills in every placeholder, encoding path segments", () => {
+    const location = { root: "/work/my repo", collection: "code", path: "src/a b.go", line: 42, end_line: 58 };
+    expect(formatEditorUrl(editorTemplate("vscode"), location)).toBe("vscode://file/work/my%20repo/src/a%20b.go:42:1");
+    expect(formatEditorUrl("x://{project}/{path}?c={collection}&r={root}#{line}-{end_line}", location)).toBe(
+      "x://my%20repo/src/a%20b.go?c=code&r=/work/my%20repo#42-58"
+    );
+  });
+});
+
+describe("EditorLinks", () => {
+  test("adds editor_url next to every uri, in a copy", () => {
+    const store = new DocumentStore();
+    store.load(docs());
+    const links = new EditorLinks(config);
+    const answer = {
+      results: [
+        { uri: "treenav://file/internal/pool.go#L10-20" },
+        { uri: "treenav://file/guides/auth.md" },
+        { uri: "treenav://file/analysis/eda.ipynb#cell2:L1-4" },
+        { uri: "treenav://file/unindexed.go#L1" },
+      ],
+    };
+    const linked = links.addLinks(answer, editorTemplate("zed"), store);
+    expect(linked.results.map((r: any) => r.editor_url)).toEqual([
+      "zed://file/work/repo/internal/pool.go:10:1",
+      "zed://file/work/docs/guides/auth.md:1:1",
+      undefined,
+      undefined,
+    ]);
+    expect((answer.results[0] as any).editor_url).toBeUndefined();
+  });
+});
+
+describe("tools", () => {
+  test("link every result uri by EDITOR_URL, or the session's editor_url", async () => {
+    const harness = await createMcpTestClient(docs(), { editorLinks: new EditorLinks(config, "vscode") });
+    const search = async () =>
+      (await harness.client.callTool({ name: "search_documents", arguments: { query: "content" } })).structuredContent as any;
+
+    const linked = (await search()).results.find((r: any) => r.doc_id === "code:internal:pool_go");
+    expect(linked.editor_url).toBe(`vscode://file/work/repo/internal/pool.go:${linked.line_start}:1`);
+
+    await harness.client.callTool({ name: "set_preferences", arguments: { editor_url: "idea" } });
+    const idea = (await search()).results.find((r: any) => r.doc_id === "code:internal:pool_go");
+    expect(idea.editor_url).toBe(`idea://open?file=/work/repo/internal/pool.go&line=${idea.line_start}`);
+
+    const bad = await harness.client.callTool({ name: "set_preferences", arguments: { editor_url: "x://{row}" } });
+    expect(bad.isError).toBe(true);
+    expect((await search()).preferences.editor_url).toBe("idea");
+    await harness.cleanup();
+  });
+
+  test("add nothing without a template", async () => {
+    const harness = await createMcpTestClient(docs(), { editorLinks: new EditorLinks(config) });
+    const found = await harness.client.callTool({ name: "search_documents", arguments: { query: "content" } });
+    expect((found.structuredContent as any).results.every((r: any) => r.editor_url === undefined)).toBe(true);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,165 @@
+/**
+ * Tests for list_embeds: directive parsing, pattern resolution against
+ * the files on disk, missing patterns, a binary's import closure, and
+ * the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { EmbedIndex, embedPatterns, parseEmbeds } from "../src/embeds";
+import { GoModuleIndex } from "../src/go-modules";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const FILES: Record<string, string> = {
+  "go.mod": "module example.com/app\n\ngo 1.22\n",
+  "cmd/server/main.go": `package main
+
+import (
+	"example.com/app/web"
+	_ "example.com/app/migrations"
+)
+
+func main() { web.Serve() }
+`,
+  "web/assets.go": `package web
+
+import "embed"
+
+//go:embed static templates/*.html
+var assets embed.FS
+
+// version is stamped at release.
+//
+//go:embed VERSION
+var version string
+
+//go:embed all:themes "missing dir"
+var themes embed.FS
+
+func Serve() {}
+`,
+  "web/static/app.js": "console.log(1)\n",
+  "web/static/.hidden": "x\n",
+  "web/static/_draft.css": "x\n",
+  "web/static/img/logo.svg": "<svg/>\n",
+  "web/templates/index.html": "<html></html>\n",
+  "web/templates/index.txt": "text\n",
+  "web/VERSION": "1.0.0\n",
+  "web/themes/_base/theme.css": "x\n",
+  "migrations/sql.go": `package migrations
+
+import "embed"
+
+//go:embed *.sql
+var files embed.FS
+`,
+  "migrations/001_init.sql": "create table t ();\n",
+  "tools/gen/main.go": `package main
+
+import "embed"
+
+//go:embed tmpl
+var tmpl embed.FS
+`,
+  "tools/gen/tmpl/a.tmpl": "{{.}}\n",
+};
+
+describe("embedPatterns", () => {
+  test("bare, quoted, and backquoted patterns", () => {
+    expect(embedPatterns('static "with space/*.txt" `raw\\name` all:x')).toEqual([
+      "static",
+      "with space/*.txt",
+      "raw\\name",
+      "all:x",
+    ]);
+  });
+});
+
+describe("parseEmbeds", () => {
+  test("directives collect onto the var below them, comments between allowed", () => {
+    const source = `package x
+
+//go:embed a.txt
+//go:embed b/*.txt
+// a note
+var data []byte
+
+//go:embed c.txt
+func notAVar() {}
+`;
+    expect(parseEmbeds(source)).toEqual([
+      { variable: "data", type: "[]byte", line: 6, directive_line: 3, patterns: ["a.txt", "b/*.txt"] },
+    ]);
+  });
+});
+
+// ── The index and the tool ───────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-embeds-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES).filter((p) => p.endsWith(".go")));
+
+describe("EmbedIndex", () => {
+  test("directories skip . and _ names, globs match per element, all: keeps them", async () => {
+    const report = (await new EmbedIndex(config).list(await indexedStore(), { path: "web/" }))!;
+    expect(report.embeds.map((e) => [e.variable, e.type, e.files, e.missing])).toEqual([
+      ["assets", "embed.FS", ["web/static/app.js", "web/static/img/logo.svg", "web/templates/index.html"], []],
+      ["version", "string", ["web/VERSION"], []],
+      ["themes", "embed.FS", ["web/themes/_base/theme.css"], ["missing dir"]],
+    ]);
+    expect(report.embeds[1].directive_line).toBe(10);
+    expect(report.files).toBe(5);
+  });
+
+  test("a binary embeds what it and its imports embed, blank imports included", async () => {
+    const index = new EmbedIndex(config, new GoModuleIndex(config));
+    const store = await indexedStore();
+    const report = (await index.list(store, { binary: "cmd/server" }))!;
+    expect(report.packages).toEqual(["cmd/server", "web", "migrations"]);
+    expect(report.embeds.map((e) => e.variable)).toEqual(["files", "assets", "version", "themes"]);
+    expect(report.embeds.some((e) => e.variable === "tmpl")).toBe(false);
+    expect(await index.list(store, { binary: "nowhere" })).toBeNull();
+  });
+
+  test("variable filter by name or package-qualified name", async () => {
+    const index = new EmbedIndex(config);
+    const store = await indexedStore();
+    expect((await index.list(store, { variable: "web.version" }))!.embeds.map((e) => e.file_path)).toEqual(["web/assets.go"]);
+    expect((await index.list(store, { variable: "api.version" }))!.embeds).toEqual([]);
+  });
+});
+
+describe("list_embeds tool", () => {
+  test("lists a variable's files and flags missing patterns", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), { embeds: new EmbedIndex(config) });
+    const result = await harness.client.callTool({ name: "list_embeds", arguments: { path: "web/", limit: 2 } });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(3);
+    expect(data.embeds[0].uri).toBe("treenav://file/web/assets.go#L6");
+    expect(data.embeds[0].file_count).toBe(3);
+    expect(data.embeds[0].files).toEqual([{ path: "web/static/app.js" }, { path: "web/static/img/logo.svg" }]);
+    const text = getToolText(result as any);
+    expect(text).toContain("web/assets.go:6  assets embed.FS  ← //go:embed static templates/*.html");
+    expect(text).toContain("  … 1 more; raise limit to see them");
+    expect(text).toContain("  missing: missing dir (matches no file; the package does not build)");
+
+    const missing = await harness.client.callTool({ name: "list_embeds", arguments: { variable: "nope" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,160 @@
+/**
+ * Tests for source encodings: detecting UTF-16 and Latin-1, round trips,
+ * line ending normalization, byte ranges of matches, and rewriting
+ * files in their original encoding.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, readFile, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { decodeSource, encodeSource, lineByteOffsets, normalizeLineEndings, EncodingError } from "../src/encoding";
+import { indexCodeContent, indexCodeFile } from "../src/code-indexer";
+import { indexFile, indexMarkdownContent } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import { StructuralSearch } from "../src/structural";
+import type { IndexConfig } from "../src/types";
+
+const CSHARP = "// Préférences\r\nclass Settings {\r\n  void Load() { Retry(3); }\r\n}\r\n";
+
+function utf16le(text: string, bom = true): Uint8Array {
+  return encodeSource(text, { encoding: "utf-16le", bom: bom ? 2 : 0 });
+}
+
+describe("decodeSource", () => {
+  test("reads UTF-16 by BOM or by NUL halves", () => {
+    expect(decodeSource(utf16le(CSHARP))).toEqual({ text: CSHARP, encoding: "utf-16le", bom: 2 });
+    expect(decodeSource(utf16le(CSHARP, false))).toEqual({ text: CSHARP, encoding: "utf-16le", bom: 0 });
+    const be = encodeSource(CSHARP, { encoding: "utf-16be", bom: 2 });
+    expect([be[0], be[1]]).toEqual([0xfe, 0xff]);
+    expect(decodeSource(be).text).toBe(CSHARP);
+  });
+
+  test("falls back to windows-1252 for bytes that are not UTF-8", () => {
+    const bytes = new Uint8Array([0x63, 0x61, 0x66, 0xe9, 0x20, 0x93, 0x6f, 0x6b, 0x94]);
+    expect(decodeSource(bytes)).toEqual({ text: "café “ok”", encoding: "latin1", bom: 0 });
+    expect([...encodeSource("café “ok”", { encoding: "latin1", bom: 0 })]).toEqual([...bytes]);
+  });
+
+  test("leaves UTF-8 alone and remembers its BOM", () => {
+    const plain = new TextEncoder().encode("naïve");
+    expect(decodeSource(plain)).toEqual({ text: "naïve", encoding: "utf-8", bom: 0 });
+    const marked = new Uint8Array([0xef, 0xbb, 0xbf, ...plain]);
+    expect(decodeSource(marked)).toEqual({ text: "naïve", encoding: "utf-8", bom: 3 });
+    expect([...encodeSource("naïve", { encoding: "utf-8", bom: 3 })]).toEqual([...marked]);
+  });
+
+  test("refuses characters Latin-1 cannot hold", () => {
+    expect(() => encodeSource("日本", { encoding: "latin1", bom: 0 })).toThrow(EncodingError);
+  });
+});
+
+describe("lineByteOffsets", () => {
+  test("counts two bytes per code unit after the BOM", () => {
+    expect(lineByteOffsets({ text: "ab\ncd\n", encoding: "utf-16le", bom: 2 })).toEqual([2, 8, 14]);
+    expect(lineByteOffsets({ text: "ab\ncd", encoding: "latin1", bom: 0 })).toEqual([0, 3]);
+  });
+
+  test("counts UTF-8 bytes and every kind of line break", () => {
+    expect(lineByteOffsets({ text: "é\r\nab\rc\n", encoding: "utf-8", bom: 0 })).toEqual([0, 4, 7, 9]);
+    expect(lineByteOffsets({ text: "\u{1F600}\n", encoding: "utf-8", bom: 3 })).toEqual([3, 8]);
+  });
+});
+
+describe("normalizeLineEndings", () => {
+  test("CRLF and lone CR become LF", () => {
+    expect(normalizeLineEndings("a\r\nb\rc\n\r\n")).toBe("a\nb\nc\n\n");
+    expect(normalizeLineEndings("plain\n")).toBe("plain\n");
+  });
+});
+
+describe("CRLF files in the index", () => {
+  const TIME = "2026-01-01T00:00:00.000Z";
+  const LF = "// Préférences\nclass Settings {\n  void Load() { Retry(3); }\n}\n";
+
+  function matchOf(store: DocumentStore) {
+    const [hit] = store.searchDocuments("retry");
+    return { hit, match: hit.content_matches[0] };
+  }
+
+  test("lines and columns match the LF checkout", () => {
+    const crlf = new DocumentStore();
+    crlf.load([indexCodeContent(CSHARP, "Settings.cs", "code", TIME)]);
+    const lf = new DocumentStore();
+    lf.load([indexCodeContent(LF, "Settings.cs", "code", TIME)]);
+    const a = matchOf(crlf);
+    const b = matchOf(lf);
+    expect(a.hit.node_id).toBe(b.hit.node_id);
+    expect(a.hit.line_start + a.match.line - 1).toBe(3);
+    expect([a.match.line, a.match.column]).toEqual([b.match.line, b.match.column]);
+    expect(a.match.byte_start).toBeUndefined();
+  });
+
+  test("BYTE_OFFSETS gives UTF-8 files byte ranges that count CRs and accents", () => {
+    const store = new DocumentStore();
+    store.load([indexCodeContent(CSHARP, "Settings.cs", "code", TIME)]);
+    store.setByteOffsets(true);
+    const { match } = matchOf(store);
+    const bytes = new TextEncoder().encode(CSHARP);
+    expect(new TextDecoder().decode(bytes.subarray(match.byte_start!, match.byte_end!))).toBe("Retry");
+  });
+
+  test("markdown headings are found through CRLF", () => {
+    const doc = indexMarkdownContent("# Setup\r\n\r\nInstall it.\r\n\r\n## Usage\r\n\r\nRun it.\r\n", "guide.md", "docs", TIME);
+    expect(doc.tree.map((n) => n.title)).toEqual(["Setup", "Usage"]);
+    expect(doc.tree.every((n) => !n.content.includes("\r"))).toBe(true);
+  });
+});
+
+describe("transcoded files in the index", () => {
+  let dir: string;
+  let config: IndexConfig;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-encoding-"));
+    await writeFile(join(dir, "Settings.cs"), utf16le(CSHARP));
+    config = {
+      collections: [],
+      code_collections: [{ name: "code", root: dir, weight: 1.0 }],
+      summary_length: 200,
+      max_depth: 6,
+    };
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("searches UTF-16 code and reports byte ranges of the original file", async () => {
+    const doc = await indexCodeFile(join(dir, "Settings.cs"), dir, "code");
+    expect(doc.meta.encoding).toBe("utf-16le");
+
+    const store = new DocumentStore();
+    store.load([doc]);
+    const [hit] = store.searchDocuments("retry");
+    const match = hit.content_matches.find((m) => hit.line_start + m.line - 1 === 3)!;
+    const bytes = await readFile(join(dir, "Settings.cs"));
+    const range = bytes.subarray(match.byte_start!, match.byte_end!);
+    expect(new TextDecoder("utf-16le").decode(range)).toBe("Retry");
+  });
+
+  test("indexes Latin-1 markdown as text", async () => {
+    const bytes = new Uint8Array([0x23, 0x20, 0x52, 0xe9, 0x73, 0x75, 0x6d, 0xe9, 0x0a]); // "# Résumé\n"
+    await writeFile(join(dir, "notes.md"), bytes);
+    const doc = await indexFile(join(dir, "notes.md"), dir, "docs");
+    expect(doc.meta.encoding).toBe("latin1");
+    expect(doc.meta.title).toBe("Résumé");
+  });
+
+  test("structural_replace writes in the encoding the file had", async () => {
+    const store = new DocumentStore();
+    store.load([await indexCodeFile(join(dir, "Settings.cs"), dir, "code")]);
+    const result = await new StructuralSearch(config).replace(store, "**/*.cs", "Retry(:[n])", "Backoff(:[n])");
+    expect(result!.replacements).toBe(1);
+
+    const written = decodeSource(await readFile(join(dir, "Settings.cs")));
+    expect(written.encoding).toBe("utf-16le");
+    expect(written.bom).toBe(2);
+    expect(written.text).toBe(CSHARP.replace("Retry(3)", "Backoff(3)"));
+  });
+});
@@ -0,0 +1,173 @@
+/**
+ * Tests for entry-point discovery: Go mains, routes, gRPC services, and
+ * CLI commands; Python, JavaScript, and Spring patterns; comments and
+ * look-alikes left alone; and the list_entrypoints tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { EntrypointIndex, scanEntrypoints } from "../src/entrypoints";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const summary = (source: string, path: string) =>
+  scanEntrypoints(source, path).map((e) => [e.kind, e.framework, e.name, e.handler ?? null]);
+
+describe("scanEntrypoints: Go", () => {
+  test("main, net/http patterns, gRPC, and cobra", () => {
+    const source = `package main
+
+import (
+	"net/http"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", getUser)
+	http.Handle("/metrics", promhttp.Handler())
+	pb.RegisterUserServiceServer(grpcServer, &userServer{})
+	gw.RegisterUserServiceHandlerServer(ctx, gwmux, srv)
+	// http.HandleFunc("/old", legacy)
+	_ = cache.Get("/not-a-route")
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [flags]",
+	Short: "Run the server",
+}
+`;
+    expect(summary(source, "cmd/api/main.go")).toEqual([
+      ["main", "go", "main", null],
+      ["http_route", "net/http", "GET /users/{id}", "getUser"],
+      ["http_route", "net/http", "/metrics", "promhttp.Handler"],
+      ["grpc_service", "grpc", "UserService", "userServer"],
+      ["cli_command", "cobra", "serve", null],
+    ]);
+  });
+
+  test("chi, gin, and gorilla routes by import", () => {
+    const chi = 'import "github.com/go-chi/chi/v5"\n\nfunc routes(r chi.Router) {\n\tr.Get("/health", health)\n\tr.Method("DELETE", "/users/{id}", deleteUser)\n}\n';
+    expect(summary(chi, "api/routes.go")).toEqual([
+      ["http_route", "chi", "GET /health", "health"],
+      ["http_route", "chi", "DELETE /users/{id}", "deleteUser"],
+    ]);
+    const gin = 'import "github.com/gin-gonic/gin"\n\nfunc routes(r *gin.Engine) {\n\tr.POST("/login", h.Login)\n\tr.Any("/proxy/*path", proxy)\n}\n';
+    expect(summary(gin, "api/routes.go")).toEqual([
+      ["http_route", "gin", "POST /login", "h.Login"],
+      ["http_route", "gin", "/proxy/*path", "proxy"],
+    ]);
+    const gorilla = 'import "github.com/gorilla/mux"\n\nfunc routes(r *mux.Router) {\n\tr.HandleFunc("/orders", list).Methods("GET")\n}\n';
+    expect(summary(gorilla, "api/routes.go")).toEqual([["http_route", "gorilla/mux", "GET /orders", "list"]]);
+  });
+
+  test("func main outside package main is not an entry point", () => {
+    expect(scanEntrypoints("package tools\n\nfunc main() {}\n", "tools/gen.go")).toEqual([]);
+  });
+});
+
+describe("scanEntrypoints: other languages", () => {
+  test("Flask, FastAPI, click, and __main__", () => {
+    const flask = `from flask import Flask
+import click
+
+@app.route("/users", methods=["GET", "POST"])
+def users():
+    pass
+
+@cli.command()
+def sync_all():
+    pass
+
+if __name__ == "__main__":
+    app.run()
+`;
+    expect(summary(flask, "app/server.py")).toEqual([
+      ["http_route", "flask", "GET,POST /users", "users"],
+      ["cli_command", "click", "sync-all", "sync_all"],
+      ["main", "python", "__main__", null],
+    ]);
+    const fastapi = 'from fastapi import APIRouter\n\n@router.get("/items/{item_id}")\nasync def read_item(item_id: int):\n    pass\n';
+    expect(summary(fastapi, "app/items.py")).toEqual([["http_route", "fastapi", "GET /items/{item_id}", "read_item"]]);
+  });
+
+  test("Express routes, commander commands, and Spring mappings", () => {
+    const express = "const app = express();\napp.get('/health', health);\nrouter.post(\"/users\", (req, res) => {});\nprogram.command('deploy <env>');\n";
+    expect(summary(express, "src/server.ts")).toEqual([
+      ["http_route", "express", "GET /health", "health"],
+      ["http_route", "express", "POST /users", null],
+      ["cli_command", "commander", "deploy", null],
+    ]);
+    const spring = '@RestController\nclass Users {\n  @GetMapping("/users/{id}")\n  public User get(@PathVariable long id) {\n    return null;\n  }\n}\n';
+    expect(summary(spring, "src/main/java/Users.java")).toEqual([["http_route", "spring", "GET /users/{id}", "get"]]);
+  });
+});
+
+// ── The index and the tool ───────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-entrypoints-"));
+  await writeTree(dir, {
+    "cmd/api/main.go": 'package main\n\nimport "net/http"\n\nfunc main() {\n\thttp.HandleFunc("POST /login", login)\n\thttp.ListenAndServe(":8080", nil)\n}\n',
+    "internal/auth/token.go": "package auth\n\nfunc Sign() string { return \"\" }\n",
+  });
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, ["cmd/api/main.go", "internal/auth/token.go"]);
+
+describe("EntrypointIndex", () => {
+  test("scans the indexed code files, under a prefix when given", async () => {
+    const index = new EntrypointIndex(config);
+    const store = await indexedStore();
+    expect((await index.scan(store)).map((e) => [e.file_path, e.line, e.name])).toEqual([
+      ["cmd/api/main.go", 5, "main"],
+      ["cmd/api/main.go", 6, "POST /login"],
+    ]);
+    expect(await index.scan(store, "internal/")).toEqual([]);
+  });
+});
+
+describe("list_entrypoints tool", () => {
+  test("lists entry points by kind with their enclosing symbol", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      entrypoints: new EntrypointIndex(config),
+    });
+    const result = await harness.client.callTool({ name: "list_entrypoints", arguments: {} });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(2);
+    expect(data.by_kind).toEqual({ main: 1, http_route: 1, grpc_service: 0, cli_command: 0 });
+    expect(data.entrypoints[1]).toEqual({
+      kind: "http_route",
+      framework: "net/http",
+      name: "POST /login",
+      method: "POST",
+      path: "/login",
+      handler: "login",
+      doc_id: "code:cmd:api:main_go",
+      collection: "code",
+      file_path: "cmd/api/main.go",
+      line: 6,
+      text: 'http.HandleFunc("POST /login", login)',
+      symbol: "function main",
+      uri: "treenav://file/cmd/api/main.go#L6",
+    });
+    const text = getToolText(result as any);
+    expect(text).toContain("HTTP routes (1):");
+    expect(text).toContain("POST /login → login  cmd/api/main.go:6 (net/http)");
+
+    const routes = await harness.client.callTool({ name: "list_entrypoints", arguments: { kind: "main" } });
+    expect((routes.structuredContent as any).entrypoints.map((e: any) => e.kind)).toEqual(["main"]);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,200 @@
+/**
+ * Tests for enum_usages: Go typed constant blocks, TypeScript enums,
+ * Python Enum classes, produced vs consumed references, switch
+ * coverage, and the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { EnumIndex, parseEnumSets, switchBlocks } from "../src/enums";
+import { parseCodeSymbols } from "../src/code-indexer";
+import { blankLiterals, hashCommentsFor } from "../src/structural";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const FILES: Record<string, string> = {
+  "cluster/state.go": `package cluster
+
+type NodeState int
+
+const (
+	StateAlive NodeState = iota
+	StateSuspect
+	StateDead
+)
+
+const MaxNodes = 64
+
+func (s NodeState) String() string {
+	switch s {
+	case StateAlive:
+		return "alive"
+	case StateSuspect, StateDead:
+		return "down"
+	}
+	return ""
+}
+`,
+  "api/health.go": `package api
+
+import "example.com/app/cluster"
+
+func healthy(s cluster.NodeState) bool {
+	switch s {
+	case cluster.StateAlive:
+		return true
+	case cluster.StateSuspect:
+		return false
+	}
+	return s != cluster.StateDead
+}
+
+func mark(n *node) {
+	n.state = cluster.StateSuspect
+}
+`,
+  "jobs/phase.py": `from enum import Enum
+
+class Phase(Enum):
+    NEW = 1
+    DONE = 2
+
+    def label(self):
+        return self.name
+
+def advance(p):
+    match p:
+        case Phase.NEW:
+            return Phase.DONE
+        case _:
+            return p
+`,
+  "web/color.ts": `export enum Color {
+  Red,
+  Green = "green",
+  Blue,
+}
+
+export function paint(c: Color): string {
+  switch (c) {
+    case Color.Red:
+      return "r";
+    default:
+      return Color.Green;
+  }
+}
+`,
+};
+
+const sets = (path: string) => {
+  const source = FILES[path];
+  const blanked = blankLiterals(source, hashCommentsFor(path)).split("\n");
+  return parseEnumSets(source.split("\n"), blanked, parseCodeSymbols(source, path), { doc_id: "d", collection: "code", file_path: path });
+};
+
+describe("parseEnumSets", () => {
+  test("Go: a typed iota run, untyped constants left out", () => {
+    expect(sets("cluster/state.go").map((s) => [s.name, s.line, s.values.map((v) => [v.name, v.value ?? null])])).toEqual([
+      ["NodeState", 6, [["StateAlive", "iota"], ["StateSuspect", null], ["StateDead", null]]],
+    ]);
+  });
+
+  test("TypeScript enums and Python Enum classes", () => {
+    expect(sets("web/color.ts").map((s) => s.values.map((v) => [v.name, v.value ?? null]))).toEqual([
+      [["Red", null], ["Green", '"green"'], ["Blue", null]],
+    ]);
+    expect(sets("jobs/phase.py").map((s) => [s.name, s.values.map((v) => v.name)])).toEqual([["Phase", ["NEW", "DONE"]]]);
+  });
+});
+
+describe("switchBlocks", () => {
+  test("collects the case labels directly inside each switch and its default", () => {
+    const go = "switch s {\ncase A, B:\n\tswitch t {\n\tcase C:\n\t}\ndefault:\n}\n".split("\n");
+    expect(switchBlocks(go, false)).toEqual([
+      { line: 1, cases: [["A", "B"]], has_default: true },
+      { line: 3, cases: [["C"]], has_default: false },
+    ]);
+    const py = "match p:\n    case A.X | A.Y:\n        pass\n    case _:\n        pass\n".split("\n");
+    expect(switchBlocks(py, true)).toEqual([{ line: 1, cases: [["A.X", "A.Y"]], has_default: true }]);
+  });
+});
+
+// ── The index and the tool ───────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-enums-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES));
+
+describe("EnumIndex", () => {
+  test("Go: produced and consumed per value, across an import", async () => {
+    const report = (await new EnumIndex(config).usages(await indexedStore(), "NodeState"))!;
+    expect(report.sets[0].values.map((v) => [v.name, v.produced, v.consumed])).toEqual([
+      ["StateAlive", 0, 2],
+      ["StateSuspect", 1, 2],
+      ["StateDead", 0, 2],
+    ]);
+    expect(report.sites.filter((s) => s.role === "produced").map((s) => [s.file_path, s.line, s.function])).toEqual([
+      ["api/health.go", 16, "mark"],
+    ]);
+    expect(report.switches.map((s) => [s.file_path, s.line, s.missing, s.incomplete])).toEqual([
+      ["api/health.go", 6, ["StateDead"], true],
+      ["cluster/state.go", 14, [], false],
+    ]);
+  });
+
+  test("Python match and TypeScript switch, with defaults", async () => {
+    const index = new EnumIndex(config);
+    const store = await indexedStore();
+    const phase = (await index.usages(store, "Phase"))!;
+    expect(phase.sites.map((s) => [s.value, s.role, s.line])).toEqual([
+      ["NEW", "consumed", 12],
+      ["DONE", "produced", 13],
+    ]);
+    expect(phase.switches.map((s) => [s.missing, s.has_default, s.incomplete])).toEqual([[["DONE"], true, false]]);
+    const color = (await index.usages(store, "Color"))!;
+    expect(color.switches.map((s) => [s.covered, s.missing, s.incomplete])).toEqual([[["Red"], ["Green", "Blue"], false]]);
+  });
+
+  test("every set without a name; null for an unknown one", async () => {
+    const index = new EnumIndex(config);
+    const store = await indexedStore();
+    expect((await index.usages(store))!.sets.map((s) => s.name).sort()).toEqual(["Color", "NodeState", "Phase"]);
+    expect(await index.usages(store, "cluster.NodeState")).not.toBeNull();
+    expect(await index.usages(store, "api.NodeState")).toBeNull();
+    expect((await index.usages(store, "NodeState", { path: "cluster/" }))!.switches.length).toBe(1);
+  });
+});
+
+describe("enum_usages tool", () => {
+  test("summarizes values and flags incomplete switches", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), { enums: new EnumIndex(config) });
+    const result = await harness.client.callTool({ name: "enum_usages", arguments: { enum: "NodeState" } });
+    const data = result.structuredContent as any;
+    expect(data.incomplete).toBe(1);
+    expect(data.sets[0].uri).toBe("treenav://file/cluster/state.go#L6");
+    expect(data.switches[0].uri).toBe("treenav://file/api/health.go#L6");
+    expect(data.total).toBe(7);
+    const text = getToolText(result as any);
+    expect(text).toContain("NodeState (go, cluster/state.go:6): 3 value(s)");
+    expect(text).toContain("  StateAlive = iota  produced 0, consumed 2  (never produced)");
+    expect(text).toContain("  api/health.go:6 in healthy  missing StateDead, no default");
+
+    const missing = await harness.client.callTool({ name: "enum_usages", arguments: { enum: "Nope" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,198 @@
+/**
+ * Tests for field_references: statement positions, receivers known to
+ * hold the type, Go literals, Python self and keyword arguments, and
+ * the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { classifyFieldAccess, declaresField, FieldError, FieldReferences, typedNames } from "../src/fields";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const access = (line: string, selector: string) => {
+  const start = line.indexOf(selector);
+  return classifyFieldAccess(line, start, start + selector.length);
+};
+
+describe("classifyFieldAccess", () => {
+  test("tells writes, reads, and addresses apart by position", () => {
+    expect(access("\tn.State = 2", "n.State")).toBe("write");
+    expect(access("\tn.State += 1", "n.State")).toBe("write");
+    expect(access("\tn.State, other.Name = 1, 2", "n.State")).toBe("write");
+    expect(access("\ta, n.State = f()", "n.State")).toBe("write");
+    expect(access("\tn.State++", "n.State")).toBe("write");
+    expect(access("    del job.state", "job.state")).toBe("write");
+    expect(access("\tif n.State == 2 {", "n.State")).toBe("read");
+    expect(access("\tx := n.State", "n.State")).toBe("read");
+    expect(access("\tn.State.Reset()", "n.State")).toBe("read");
+    expect(access("\tmark(&n.State)", "n.State")).toBe("address");
+    expect(access("\tok := a && n.State", "n.State")).toBe("read");
+    expect(access("\tn.State()", "n.State")).toBeNull();
+  });
+
+  test("typedNames finds receivers, parameters, literals, and annotations", () => {
+    const go = "func (n *NodeInfo) f(a, b NodeInfo, c *cluster.NodeInfo) {\n\tvar d NodeInfo\n\te := &NodeInfo{}\n\tf := new(NodeInfo)\n\tg := NodeInfoList{}\n}";
+    expect([...typedNames(go, "NodeInfo")].sort()).toEqual(["a", "b", "c", "d", "e", "f", "n"]);
+    expect([...typedNames("def f(job: Job, other):\n    j = Job()\n", "Job")].sort()).toEqual(["j", "job"]);
+    expect([...typedNames("void f(Job job) { Job other = new Job(); }", "Job")].sort()).toEqual(["job", "other"]);
+  });
+
+  test("declaresField reads Go and other declaration orders", () => {
+    expect(declaresField("\tState NodeState", "State", true)).toBe(true);
+    expect(declaresField("\tName, State string", "State", true)).toBe(true);
+    expect(declaresField("\tOther State", "State", true)).toBe(false);
+    expect(declaresField("  private readonly state: string;", "state", false)).toBe(true);
+    expect(declaresField("  private int state = 0;", "state", false)).toBe(true);
+    expect(declaresField("  state(): string {", "state", false)).toBe(false);
+  });
+});
+
+// ── A scratch tree ───────────────────────────────────────────────────
+
+const FILES: Record<string, string> = {
+  "cluster/node.go": `package cluster
+
+type NodeState int
+
+type NodeInfo struct {
+	Name  string
+	State NodeState \`json:"state"\`
+}
+
+type Manager struct {
+	local NodeInfo
+}
+
+func NewNode(name string) *NodeInfo {
+	return &NodeInfo{Name: name, State: 0}
+}
+
+func (n *NodeInfo) Fail() {
+	n.State = 2
+}
+
+func (m *Manager) Report() NodeState {
+	return m.local.State
+}
+
+func touch(info *NodeInfo, other *Job) {
+	info.State++
+	if info.State == 1 {
+		mark(&info.State)
+	}
+	other.State = 3
+	n := lookup()
+	n.State, other.Name = 1, "x"
+}
+`,
+  "jobs/job.py": `class Job:
+    def __init__(self):
+        self.state = "new"
+
+    def run(self):
+        self.state = "running"
+        return self.state
+
+def check(job: Job, other):
+    if job.state == "done":
+        other.state = "x"
+    Job(state="y")
+`,
+};
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-fields-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES));
+
+describe("FieldReferences", () => {
+  test("Go: writes, reads, literals, and whether the receiver is the type", async () => {
+    const report = (await new FieldReferences(config).find(await indexedStore(), "NodeInfo.State"))!;
+    expect(report.types.map((t) => [t.name, t.file_path, t.line])).toEqual([["NodeInfo", "cluster/node.go", 5]]);
+    expect(report.declarations.map((d) => d.line)).toEqual([7]);
+    expect(report.sites.map((s) => [s.line, s.access, s.typed, s.function ?? null])).toEqual([
+      [15, "init", true, "NewNode"],
+      [19, "write", true, "NodeInfo.Fail"],
+      [23, "read", true, "Manager.Report"],
+      [27, "write", true, "touch"],
+      [28, "read", true, "touch"],
+      [29, "address", true, "touch"],
+      [31, "write", false, "touch"],
+      [33, "write", false, "touch"],
+    ]);
+    expect([report.total, report.typed, report.by_access]).toEqual([8, 6, { read: 2, write: 4, init: 1, address: 1 }]);
+  });
+
+  test("Python: self in the class's methods, annotations, and keyword arguments", async () => {
+    const report = (await new FieldReferences(config).find(await indexedStore(), "Job.state"))!;
+    expect(report.declarations.map((d) => d.text)).toEqual(['self.state = "new"']);
+    expect(report.sites.map((s) => [s.line, s.access, s.typed])).toEqual([
+      [3, "write", true],
+      [6, "write", true],
+      [7, "read", true],
+      [10, "read", true],
+      [11, "write", false],
+      [12, "init", true],
+    ]);
+  });
+
+  test("typed_only, a package qualifier, and bad targets", async () => {
+    const fields = new FieldReferences(config);
+    const store = await indexedStore();
+    expect((await fields.find(store, "NodeInfo.State", { typed_only: true }))!.sites.every((s) => s.typed)).toBe(true);
+    expect((await fields.find(store, "cluster.NodeInfo.State"))!.types.length).toBe(1);
+    expect(await fields.find(store, "jobs.NodeInfo.State")).toBeNull();
+    expect(await fields.find(store, "Nope.State")).toBeNull();
+    await expect(fields.find(store, "State")).rejects.toThrow(FieldError);
+  });
+});
+
+describe("field_references tool", () => {
+  test("groups sites by access and filters by it", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      fields: new FieldReferences(config),
+    });
+    const result = await harness.client.callTool({ name: "field_references", arguments: { field: "NodeInfo.State" } });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(8);
+    expect(data.declarations[0].uri).toBe("treenav://file/cluster/node.go#L7");
+    expect(data.sites[1]).toEqual({
+      access: "write",
+      typed: true,
+      doc_id: "code:cluster:node_go",
+      file_path: "cluster/node.go",
+      line: 19,
+      text: "n.State = 2",
+      function: "NodeInfo.Fail",
+      uri: "treenav://file/cluster/node.go#L19",
+    });
+    const text = getToolText(result as any);
+    expect(text).toContain("NodeInfo.State (class, cluster/node.go:5; declared at cluster/node.go:7): 8 reference(s) — 4 write, 1 init, 1 address, 2 read; 6 typed");
+    expect(text).toContain("Writes (4):");
+    expect(text).toContain("  cluster/node.go:31 in touch  other.State = 3  (receiver type unknown)");
+
+    const writes = await harness.client.callTool({ name: "field_references", arguments: { field: "NodeInfo.State", access: "write", typed_only: true } });
+    expect((writes.structuredContent as any).sites.map((s: any) => s.line)).toEqual([19, 27]);
+    expect((writes.structuredContent as any).by_access.write).toBe(2);
+
+    const missing = await harness.client.callTool({ name: "field_references", arguments: { field: "Nope.State" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    const bare = await harness.client.callTool({ name: "field_references", arguments: { field: "State" } });
+    expect(bare.isError).toBe(true);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,20 @@
+/**
+ * Command lines for the `run*Command` handlers, parsed by the real
+ * yargs program so the tests see exactly what main() dispatches.
+ */
+
+import { program, type CommandArgs } from "../../src/commands";
+
+/** Parse `treenav-mcp <command> ...words` and return the arguments its handler gets. */
+export async function commandArgs(command: string, words: string[]): Promise<CommandArgs> {
+  let parsed: CommandArgs | undefined;
+  await program([command, ...words], async (_name, args) => {
+    parsed = args;
+  })
+    .exitProcess(false)
+    .fail((message: string) => {
+      throw new Error(message);
+    })
+    .parseAsync();
+  return parsed!;
+}
@@ -0,0 +1,30 @@
+/**
+ * Scratch git repositories for tests: every command runs as the same
+ * author, optionally at a pinned date, and throws when git fails.
+ */
+
+/** Author and committer of every fixture commit */
+export const GIT_AUTHOR = { name: "Bob", email: "bob@example.com" };
+
+/** Run `git -C cwd ...args`; `date` (epoch ms or ISO) pins the author and committer dates. */
+export function git(cwd: string, args: string[], date?: number | string): void {
+  const iso = date !== undefined ? new Date(date).toISOString() : undefined;
+  const result = Bun.spawnSync(["git", "-C", cwd, ...args], {
+    env: {
+      ...process.env,
+      GIT_AUTHOR_NAME: GIT_AUTHOR.name,
+      GIT_AUTHOR_EMAIL: GIT_AUTHOR.email,
+      GIT_COMMITTER_NAME: GIT_AUTHOR.name,
+      GIT_COMMITTER_EMAIL: GIT_AUTHOR.email,
+      ...(iso ? { GIT_AUTHOR_DATE: iso, GIT_COMMITTER_DATE: iso } : {}),
+    },
+  });
+  if (!result.success) throw new Error(`git ${args.join(" ")} failed: ${result.stderr.toString()}`);
+}
+
+/** Make `cwd` a repository whose first commit, `message`, holds everything in it. */
+export function initRepo(cwd: string, message = "initial", date?: number | string): void {
+  git(cwd, ["init", "-q"]);
+  git(cwd, ["add", "."]);
+  git(cwd, ["commit", "-q", "-m", message], date);
+}
@@ -8,9 +8,49 @@
 import { Client } from "@modelcontextprotocol/sdk/client/index.js";
 import { InMemoryTransport } from "@modelcontextprotocol/sdk/inMemory.js";
 import { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import { ElicitRequestSchema, type ElicitRequest, type ElicitResult } from "@modelcontextprotocol/sdk/types.js";
 import { DocumentStore } from "../../src/store";
-import { registerTools } from "../../src/tools";
+import { registerTools, type ToolSet } from "../../src/tools/registry";
+import type { GoModuleIndex } from "../../src/go-modules";
+import type { MarkerIndex } from "../../src/markers";
+import type { DuplicateFinder } from "../../src/duplicates";
+import type { TreeSitterQuery } from "../../src/ts-query";
+import type { StructuralSearch } from "../../src/structural";
+import type { RegexSearch } from "../../src/regex-search";
+import type { AstDiff } from "../../src/ast-diff";
+import type { UsageStats } from "../../src/usage";
+import type { Hotspots } from "../../src/hotspots";
+import type { CycleFinder } from "../../src/cycles";
+import type { Breadcrumbs } from "../../src/breadcrumbs";
+import type { EntrypointIndex } from "../../src/entrypoints";
+import type { FieldReferences } from "../../src/fields";
+import type { EnumIndex } from "../../src/enums";
+import type { ConcurrencyMap } from "../../src/concurrency";
+import type { ContextAudit } from "../../src/context-audit";
+import type { EmbedIndex } from "../../src/embeds";
+import type { GoStdlib } from "../../src/go-stdlib";
+import type { Reranker } from "../../src/reranker";
+import type { QueryLog } from "../../src/query-log";
+import type { GeneratorLinks } from "../../src/generator-links";
+import type { CodeownersIndex } from "../../src/codeowners";
+import type { LicenseIndex } from "../../src/licenses";
+import type { RefIndex } from "../../src/ref-index";
+import type { StaleCheck } from "../../src/staleness";
+import type { ToolTimeouts } from "../../src/deadline";
+import type { Shutdown } from "../../src/shutdown";
+import type { PluginHost } from "../../src/plugins";
+import type { Profiler } from "../../src/profiler";
+import type { FileReader } from "../../src/read-file";
+import type { DefinitionPeek } from "../../src/peek";
+import type { BuildTargetIndex } from "../../src/build-targets";
+import type { ConfigUsageIndex } from "../../src/config-usages";
+import type { LogSourceIndex } from "../../src/log-sources";
+import type { ApiDiff } from "../../src/api-diff";
+import type { IndexAdmin } from "../../src/admin";
+import type { ResultCache } from "../../src/result-cache";
+import type { EditorLinks } from "../../src/editor-links";
 import type { WikiOptions } from "../../src/curator";
+import type { IndexCoverage } from "../../src/coverage";
 import type { IndexedDocument, TreeNode, DocumentMeta } from "../../src/types";
 
 // ── Node / Meta / Doc factories ──────────────────────────────────────
@@ -87,6 +127,7 @@ export interface McpTestHarness {
   client: Client;
   store: DocumentStore;
   mcpServer: McpServer;
+  tools: ToolSet;
   cleanup: () => Promise<void>;
 }
 
@@ -103,6 +144,51 @@ export async function createMcpTestClient(
     glossary?: Record<string, string[]>;
     collectionWeights?: Record<string, number>;
     wiki?: WikiOptions;
+    coverage?: IndexCoverage;
+    goModules?: GoModuleIndex;
+    stdlib?: GoStdlib;
+    generatorLinks?: GeneratorLinks;
+    reranker?: Reranker;
+    queryLog?: QueryLog;
+    coverProfile?: string;
+    markers?: MarkerIndex;
+    duplicates?: DuplicateFinder;
+    treeSitter?: TreeSitterQuery;
+    structural?: StructuralSearch;
+    structuralRewrite?: boolean;
+    regex?: RegexSearch;
+    astDiff?: AstDiff;
+    usage?: UsageStats;
+    hotspots?: Hotspots;
+    codeowners?: CodeownersIndex;
+    licenses?: LicenseIndex;
+    cycles?: CycleFinder;
+    entrypoints?: EntrypointIndex;
+    fields?: FieldReferences;
+    enums?: EnumIndex;
+    concurrency?: ConcurrencyMap;
+    contextAudit?: ContextAudit;
+    embeds?: EmbedIndex;
+    breadcrumbs?: Breadcrumbs;
+    symbolNav?: boolean;
+    refs?: RefIndex;
+    /** Builds the StaleCheck, which needs the harness's own store */
+    staleness?: (store: DocumentStore) => StaleCheck;
+    timeouts?: ToolTimeouts;
+    shutdown?: Shutdown;
+    plugins?: PluginHost;
+    profiler?: Profiler;
+    files?: FileReader;
+    peek?: DefinitionPeek;
+    buildTargets?: BuildTargetIndex;
+    configUsages?: ConfigUsageIndex;
+    logSources?: LogSourceIndex;
+    apiDiff?: ApiDiff;
+    admin?: (store: DocumentStore) => IndexAdmin;
+    resultCache?: (store: DocumentStore) => ResultCache;
+    editorLinks?: EditorLinks;
+    /** Answer elicitation requests; the client declares the capability only when set */
+    elicit?: (params: ElicitRequest["params"]) => ElicitResult | Promise<ElicitResult>;
   },
 ): Promise<McpTestHarness> {
   // Build and populate the store
@@ -121,7 +207,51 @@ export async function createMcpTestClient(
     name: "treenav-test",
     version: "0.0.1",
   });
-  registerTools(mcpServer, store, { wiki: options?.wiki });
+  const tools = registerTools(mcpServer, store, {
+    wiki: options?.wiki,
+    coverage: options?.coverage,
+    goModules: options?.goModules,
+    stdlib: options?.stdlib,
+    generatorLinks: options?.generatorLinks,
+    reranker: options?.reranker,
+    queryLog: options?.queryLog,
+    coverProfile: options?.coverProfile,
+    markers: options?.markers,
+    duplicates: options?.duplicates,
+    treeSitter: options?.treeSitter,
+    structural: options?.structural,
+    structuralRewrite: options?.structuralRewrite,
+    regex: options?.regex,
+    astDiff: options?.astDiff,
+    usage: options?.usage,
+    hotspots: options?.hotspots,
+    codeowners: options?.codeowners,
+    licenses: options?.licenses,
+    cycles: options?.cycles,
+    entrypoints: options?.entrypoints,
+    fields: options?.fields,
+    enums: options?.enums,
+    concurrency: options?.concurrency,
+    contextAudit: options?.contextAudit,
+    embeds: options?.embeds,
+    breadcrumbs: options?.breadcrumbs,
+    symbolNav: options?.symbolNav,
+    refs: options?.refs,
+    staleness: options?.staleness?.(store),
+    timeouts: options?.timeouts,
+    shutdown: options?.shutdown,
+    plugins: options?.plugins,
+    profiler: options?.profiler,
+    files: options?.files,
+    peek: options?.peek,
+    buildTargets: options?.buildTargets,
+    configUsages: options?.configUsages,
+    logSources: options?.logSources,
+    apiDiff: options?.apiDiff,
+    admin: options?.admin?.(store),
+    resultCache: options?.resultCache?.(store),
+    editorLinks: options?.editorLinks,
+  });
 
   // Wire up InMemoryTransport
   const [clientTransport, serverTransport] = InMemoryTransport.createLinkedPair();
@@ -131,13 +261,19 @@ export async function createMcpTestClient(
 
   const client = new Client(
     { name: "test-client", version: "0.0.1" },
+    options?.elicit ? { capabilities: { elicitation: {} } } : undefined,
   );
+  if (options?.elicit) {
+    const elicit = options.elicit;
+    client.setRequestHandler(ElicitRequestSchema, (request) => elicit(request.params));
+  }
   await client.connect(clientTransport);
 
   return {
     client,
     store,
     mcpServer,
+    tools,
     cleanup: async () => {
       await client.close();
       await mcpServer.server.close();
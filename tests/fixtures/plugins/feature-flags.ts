@@ -0,0 +1,33 @@
+/**
+ * Example plugin for tests/plugins.test.ts: where a feature flag is
+ * read, found through the shared index and confirmed in the file.
+ */
+
+import { definePlugin } from "../../../src/plugins";
+
+export default definePlugin([
+  {
+    name: "find_feature_flags",
+    description: "Files that read a feature flag through isEnabled()",
+    params: {
+      flag: { type: "string", description: "Flag key, e.g. new-checkout" },
+      limit: { type: "number", description: "Most files to return", default: 20 },
+    },
+    annotations: { readOnlyHint: true, destructiveHint: false, idempotentHint: true },
+    run: async ({ flag, limit }, ctx) => {
+      const files: string[] = [];
+      for (const hit of ctx.index.searchDocuments(flag, { limit: 50 })) {
+        if (files.length >= limit || ctx.deadline?.expired()) break;
+        const source = await ctx.readFile(hit.doc_id);
+        if (source?.includes(`isEnabled("${flag}")`) && !files.includes(hit.file_path)) files.push(hit.file_path);
+      }
+      if (!files.length) return { text: `No reads of "${flag}".`, data: { files }, status: "not_found" as const };
+      return { text: files.join("\n"), data: { files } };
+    },
+  },
+  {
+    name: "search_documents",
+    description: "Clashes with a built-in tool",
+    run: () => "never registered",
+  },
+]);
@@ -0,0 +1,49 @@
+/**
+ * Scratch source trees for tests: a path → content map written under a
+ * temporary directory, its code files indexed into a store, and the
+ * config with that directory as the one code collection.
+ */
+
+import { mkdir, writeFile } from "node:fs/promises";
+import { dirname, join } from "node:path";
+import { indexCodeFile, isCodeFile } from "../../src/code-indexer";
+import { DocumentStore } from "../../src/store";
+import type { IndexConfig } from "../../src/types";
+
+/** Files by path relative to the tree's root */
+export type Tree = Record<string, string>;
+
+/** Write every file of `tree` under `root`, creating directories as needed. */
+export async function writeTree(root: string, tree: Tree): Promise<void> {
+  for (const [path, content] of Object.entries(tree)) {
+    await mkdir(dirname(join(root, path)), { recursive: true });
+    await writeFile(join(root, path), content);
+  }
+}
+
+/**
+ * A store of the code files among `paths` under `root`, in collection
+ * "code" unless named; go.mod and other non-code paths are left out.
+ * Pass `store` to add them to one already loaded.
+ */
+export async function indexTree(
+  root: string,
+  paths: Iterable<string>,
+  store = new DocumentStore(),
+  collection = "code"
+): Promise<DocumentStore> {
+  const docs = [];
+  for (const path of paths) if (isCodeFile(path)) docs.push(await indexCodeFile(join(root, path), root, collection));
+  store.addDocuments(docs);
+  return store;
+}
+
+/** A config with `root` as its only collection, of code. */
+export function codeConfig(root: string): IndexConfig {
+  return {
+    collections: [],
+    code_collections: [{ name: "code", root, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+}
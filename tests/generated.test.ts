@@ -0,0 +1,89 @@
+/**
+ * Tests for generated file detection: lockfiles, generator names and
+ * headers, minified bundles, generator inputs from Go headers, and
+ * the GENERATED_POLICY in search.
+ */
+
+import { describe, test, expect } from "bun:test";
+import { detectGenerated, generatorOrigin } from "../src/generated";
+import { indexCodeContent } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+
+const TIME = "2026-01-01T00:00:00.000Z";
+
+const HANDWRITTEN = "package api\n\n// Dial opens a connection to the server.\nfunc Dial(addr string) error {\n\treturn nil\n}\n";
+const GENERATED = "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: api.proto\n\n" + HANDWRITTEN;
+
+describe("detectGenerated", () => {
+  test("knows lockfiles and generator file names", () => {
+    expect(detectGenerated("web/package-lock.json", "{}")).toBe("lockfile");
+    expect(detectGenerated("go.sum", "")).toBe("lockfile");
+    expect(detectGenerated("pkg/api/api.pb.go", HANDWRITTEN)).toBe("generated");
+    expect(detectGenerated("gen/service_pb2.py", "")).toBe("generated");
+    expect(detectGenerated("apis/v1/zz_generated.deepcopy.go", "")).toBe("generated");
+    expect(detectGenerated("UI/Form1.Designer.cs", "")).toBe("generated");
+    expect(detectGenerated("assets/app.min.js", "")).toBe("minified");
+  });
+
+  test("reads generator headers in the first lines only", () => {
+    expect(detectGenerated("pkg/api/client.go", GENERATED)).toBe("generated");
+    expect(detectGenerated("src/schema.ts", "/**\n * @generated by graphql-codegen\n */\nexport type Q = {};\n")).toBe("generated");
+    expect(detectGenerated("pkg/api/client.go", HANDWRITTEN)).toBeNull();
+    const late = HANDWRITTEN + "\n".repeat(30) + "// DO NOT EDIT below this line by hand\n";
+    expect(detectGenerated("pkg/api/client.go", late)).toBeNull();
+  });
+
+  test("calls JavaScript with very long lines minified", () => {
+    const bundle = "!function(e){" + "var a=e.b||{};a.c=function(d){return d*2};".repeat(80) + "}(window);\n";
+    expect(detectGenerated("dist/bundle.js", bundle)).toBe("minified");
+    expect(detectGenerated("dist/bundle.py", bundle)).toBeNull();
+    expect(detectGenerated("src/short.js", "export const a=1;")).toBeNull();
+  });
+});
+
+describe("generatorOrigin", () => {
+  test("protoc, mockgen, and stringer headers name their input", () => {
+    expect(generatorOrigin(GENERATED)).toEqual({ generator: "protoc-gen-go", source: "api.proto" });
+    const mock = "// Code generated by MockGen. DO NOT EDIT.\n// Source: example.com/app/store (interfaces: Store, Cache)\n";
+    expect(generatorOrigin(mock)).toEqual({ generator: "mockgen", source: "example.com/app/store", types: ["Store", "Cache"] });
+    const stringer = '// Code generated by "stringer -type=Pill,Color -output=x.go"; DO NOT EDIT.\n\npackage painkiller\n';
+    expect(generatorOrigin(stringer)).toEqual({ generator: "stringer", types: ["Pill", "Color"] });
+    expect(generatorOrigin("// Code generated by controller-gen. DO NOT EDIT.\n")).toEqual({ generator: "controller-gen" });
+    expect(generatorOrigin(HANDWRITTEN)).toBeNull();
+  });
+});
+
+describe("GENERATED_POLICY", () => {
+  function store(): DocumentStore {
+    const s = new DocumentStore();
+    s.load([
+      indexCodeContent(HANDWRITTEN, "pkg/api/dial.go", "code", TIME),
+      indexCodeContent(GENERATED, "pkg/api/client.go", "code", TIME),
+    ]);
+    return s;
+  }
+
+  function score(s: DocumentStore, path: string): number | undefined {
+    return s.searchDocuments("dial").find((r) => r.file_path === path)?.score;
+  }
+
+  test("the kind is recorded in the document's meta", () => {
+    const s = store();
+    expect(s.getDocMeta("code:pkg:api:client_go")!.generated).toBe("generated");
+    expect(s.getDocMeta("code:pkg:api:dial_go")!.generated).toBeUndefined();
+  });
+
+  test("downrank is the default; index ranks as usual; exclude drops them", () => {
+    const s = store();
+    const downranked = score(s, "pkg/api/client.go")!;
+    expect(downranked).toBeLessThan(score(s, "pkg/api/dial.go")!);
+
+    s.setGeneratedPolicy("index");
+    expect(score(s, "pkg/api/client.go")!).toBeCloseTo(downranked * 10, 5);
+
+    s.setGeneratedPolicy("exclude");
+    expect(score(s, "pkg/api/client.go")).toBeUndefined();
+    expect(score(s, "pkg/api/dial.go")).toBeDefined();
+    expect(s.getTree("code:pkg:api:client_go")).not.toBeNull();
+  });
+});
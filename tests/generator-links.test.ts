@@ -0,0 +1,213 @@
+/**
+ * Tests for generator inputs: protoc-gen-go and protoc-gen-go-grpc
+ * symbols back to the .proto, mocks back to their interface, stringer
+ * output back to its type, and find_symbol's generator_input.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { GeneratorLinks } from "../src/generator-links";
+import type { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const FILES: Record<string, string> = {
+  "api/v1/user.proto": `syntax = "proto3";
+
+package api.v1;
+
+message User {
+  string display_name = 1;
+  Status status = 2;
+
+  enum Status {
+    ACTIVE = 0;
+  }
+}
+
+service UserService {
+  rpc Get(GetRequest) returns (User);
+  rpc Watch(GetRequest) returns (stream User);
+}
+`,
+  "gen/api/v1/user.pb.go": `// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// source: api/v1/user.proto
+
+package apiv1
+
+type User struct {
+	DisplayName string
+}
+
+func (x *User) GetDisplayName() string {
+	return x.DisplayName
+}
+
+type User_Status int32
+`,
+  "gen/api/v1/user_grpc.pb.go": `// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// source: api/v1/user.proto
+
+package apiv1
+
+type userServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func (c *userServiceClient) Get(ctx context.Context, in *GetRequest) (*User, error) {
+	return nil, nil
+}
+
+func RegisterUserServiceServer(s grpc.ServiceRegistrar, srv UserServiceServer) {
+}
+`,
+  "gen/other/other.pb.go": `// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: other/other.proto
+
+package other
+
+type Other struct{}
+`,
+  "store/store.go": `package store
+
+type Store interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+}
+`,
+  "store/mocks/store_mock.go": `// Code generated by MockGen. DO NOT EDIT.
+// Source: store.go
+
+package mocks
+
+type MockStore struct {
+	ctrl *gomock.Controller
+}
+
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	return &MockStore{ctrl: ctrl}
+}
+
+func (m *MockStore) Put(key, value string) error {
+	return nil
+}
+`,
+  "painkiller/pill.go": `package painkiller
+
+type Pill int
+
+const (
+	Placebo Pill = iota
+	Aspirin
+)
+`,
+  "painkiller/pill_string.go": `// Code generated by "stringer -type=Pill"; DO NOT EDIT.
+
+package painkiller
+
+const _Pill_name = "PlaceboAspirin"
+
+func (i Pill) String() string {
+	return _Pill_name
+}
+`,
+};
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-generator-links-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES).filter((p) => p.endsWith(".go")));
+
+/** The input of the node titled `title` in the file at `path`, as [file, line, symbol, found]. */
+async function targetOf(store: DocumentStore, path: string, title: string) {
+  const doc = store.documentsAtPath(path)[0];
+  const node = store.getTree(doc.doc_id)!.nodes.find((n) => n.title === title)!;
+  const target = await new GeneratorLinks(config).target(store, doc.doc_id, node.node_id);
+  return target && [target.file_path, target.line ?? null, target.symbol ?? null, target.found];
+}
+
+describe("GeneratorLinks", () => {
+  test("protoc-gen-go: messages, nested enums, and Get accessors", async () => {
+    const store = await indexedStore();
+    expect(await targetOf(store, "gen/api/v1/user.pb.go", "class User")).toEqual(["api/v1/user.proto", 5, "message User", true]);
+    expect(await targetOf(store, "gen/api/v1/user.pb.go", "method GetDisplayName")).toEqual([
+      "api/v1/user.proto",
+      6,
+      "field display_name",
+      true,
+    ]);
+    expect(await targetOf(store, "gen/api/v1/user.pb.go", "type User_Status")).toEqual(["api/v1/user.proto", 9, "enum Status", true]);
+  });
+
+  test("protoc-gen-go-grpc: services and rpcs", async () => {
+    const store = await indexedStore();
+    expect(await targetOf(store, "gen/api/v1/user_grpc.pb.go", "method Get")).toEqual(["api/v1/user.proto", 15, "rpc Get", true]);
+    expect(await targetOf(store, "gen/api/v1/user_grpc.pb.go", "function RegisterUserServiceServer")).toEqual([
+      "api/v1/user.proto",
+      14,
+      "service UserService",
+      true,
+    ]);
+  });
+
+  test("a .proto under none of the directories is reported as not found", async () => {
+    const store = await indexedStore();
+    expect(await targetOf(store, "gen/other/other.pb.go", "class Other")).toEqual(["other/other.proto", null, null, false]);
+  });
+
+  test("mockgen and stringer point into indexed Go code", async () => {
+    const store = await indexedStore();
+    expect(await targetOf(store, "store/mocks/store_mock.go", "method Put")).toEqual(["store/store.go", 5, "method Put", true]);
+    expect(await targetOf(store, "store/mocks/store_mock.go", "function NewMockStore")).toEqual([
+      "store/store.go",
+      3,
+      "interface Store",
+      true,
+    ]);
+    expect(await targetOf(store, "painkiller/pill_string.go", "method String")).toEqual(["painkiller/pill.go", 3, "type Pill", true]);
+    expect(await targetOf(store, "painkiller/pill_string.go", "variable _Pill_name")).toEqual(["painkiller/pill.go", 3, "type Pill", true]);
+    expect(await targetOf(store, "store/store.go", "interface Store")).toBeNull();
+  });
+});
+
+describe("find_symbol generator_input", () => {
+  test("a generated hit carries its input as an alternative target", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      generatorLinks: new GeneratorLinks(config),
+    });
+    const result = await harness.client.callTool({ name: "find_symbol", arguments: { query: "NewMockStore" } });
+    const hit = (result.structuredContent as any).results.find((r: any) => r.node_title === "function NewMockStore");
+    expect(hit.generator_input).toEqual({
+      generator: "mockgen",
+      file_path: "store/store.go",
+      found: true,
+      doc_id: "code:store:store_go",
+      symbol: "interface Store",
+      line: 3,
+      uri: "treenav://file/store/store.go#L3",
+    });
+    const text = getToolText(result as any);
+    expect(text).toContain("Generated by mockgen from store/store.go:3 (interface Store)");
+    expect(text).toContain("Generator input: treenav://file/store/store.go#L3");
+
+    const plain = await harness.client.callTool({ name: "find_symbol", arguments: { query: "Pill" } });
+    const pill = (plain.structuredContent as any).results.find((r: any) => r.file_path === "painkiller/pill.go");
+    expect(pill.generator_input).toBeUndefined();
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,134 @@
+/**
+ * Tests for the git recency signal.
+ *
+ * Covers: reading last commit times from a scratch repository, paths
+ * relative to a subdirectory root, non-repository roots, the decay
+ * curve, and the boost breaking a tie in DocumentStore.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { gitCommitTimes, recencyMultiplier, applyRecencyBoost } from "../src/git-history";
+import { DocumentStore } from "../src/store";
+import { singleRootConfig } from "../src/types";
+import type { IndexedDocument } from "../src/types";
+import { git, initRepo } from "./fixtures/git";
+
+const DAY = 24 * 60 * 60 * 1000;
+const OLD = Date.parse("2020-01-01T00:00:00Z");
+const NEW = Date.parse("2026-01-01T00:00:00Z");
+
+let dir: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-git-"));
+  await mkdir(join(dir, "src"), { recursive: true });
+  await writeFile(join(dir, "src", "legacy.ts"), "export function dial() {}\n");
+  await writeFile(join(dir, "src", "active.ts"), "export function dial() {}\n");
+  initRepo(dir, "initial", OLD);
+  await writeFile(join(dir, "src", "active.ts"), "export function dial(retries = 3) {}\n");
+  git(dir, ["commit", "-q", "-am", "retries"], NEW);
+  await writeFile(join(dir, "src", "untracked.ts"), "export function dial() {}\n");
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("gitCommitTimes", () => {
+  test("reports each file's last commit", () => {
+    const times = gitCommitTimes(dir);
+    expect(times.get("src/active.ts")).toBe(NEW);
+    expect(times.get("src/legacy.ts")).toBe(OLD);
+    expect(times.has("src/untracked.ts")).toBe(false);
+  });
+
+  test("paths are relative to a subdirectory root", () => {
+    const times = gitCommitTimes(join(dir, "src"));
+    expect(times.get("active.ts")).toBe(NEW);
+    expect(times.has("src/active.ts")).toBe(false);
+  });
+
+  test("is empty outside a repository", async () => {
+    const plain = await mkdtemp(join(tmpdir(), "treenav-nogit-"));
+    try {
+      expect(gitCommitTimes(plain).size).toBe(0);
+    } finally {
+      await rm(plain, { recursive: true, force: true });
+    }
+  });
+});
+
+describe("recencyMultiplier", () => {
+  test("halves the boost every half-life", () => {
+    const now = NEW;
+    expect(recencyMultiplier(now, 0.2, 90, now)).toBeCloseTo(1.2, 10);
+    expect(recencyMultiplier(now - 90 * DAY, 0.2, 90, now)).toBeCloseTo(1.1, 10);
+    expect(recencyMultiplier(now - 3650 * DAY, 0.2, 90, now)).toBeCloseTo(1.0, 5);
+  });
+
+  test("treats future commit times as new", () => {
+    expect(recencyMultiplier(NEW + DAY, 0.2, 90, NEW)).toBeCloseTo(1.2, 10);
+  });
+});
+
+describe("recency boost in search", () => {
+  const doc = (path: string): IndexedDocument => ({
+    meta: {
+      doc_id: `code:${path.replace(".", "_")}`,
+      file_path: path,
+      title: path,
+      description: "",
+      word_count: 4,
+      heading_count: 1,
+      max_depth: 1,
+      last_modified: new Date().toISOString(),
+      tags: [],
+      content_hash: path,
+      collection: "code",
+      facets: {},
+      references: [],
+    },
+    tree: [
+      {
+        node_id: `code:${path}:n1`,
+        title: "function dial",
+        level: 1,
+        parent_id: null,
+        children: [],
+        content: "export function dial() {}",
+        summary: "",
+        word_count: 4,
+        line_start: 1,
+        line_end: 1,
+      },
+    ],
+    root_nodes: [`code:${path}:n1`],
+  });
+
+  test("recently committed files win ties", () => {
+    const store = new DocumentStore();
+    store.load([doc("src/legacy.ts"), doc("src/active.ts"), doc("src/untracked.ts")]);
+
+    const config = singleRootConfig(join(dir, "docs"));
+    config.code_collections = [{ name: "code", root: dir, weight: 1.0 }];
+    expect(applyRecencyBoost(store, config, 0.2)).toBe(2);
+
+    const results = store.searchDocuments("dial");
+    expect(results.map((r) => r.file_path)[0]).toBe("src/active.ts");
+    const score = (path: string) => results.find((r) => r.file_path === path)!.score;
+    expect(score("src/active.ts")).toBeGreaterThan(score("src/legacy.ts"));
+    expect(score("src/legacy.ts")).toBeCloseTo(score("src/untracked.ts"), 3);
+  });
+
+  test("a weight of 0 leaves scores alone", () => {
+    const store = new DocumentStore();
+    store.load([doc("src/legacy.ts"), doc("src/active.ts")]);
+    store.setCommitTimes("code", gitCommitTimes(dir));
+    store.setRecencyBoost(0);
+    const [a, b] = store.searchDocuments("dial");
+    expect(a.score).toBe(b.score);
+  });
+});
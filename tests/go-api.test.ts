@@ -0,0 +1,204 @@
+/**
+ * Tests for the Go package API view: exported-only filtering, grouping
+ * of methods and constructors under their type, const blocks, doc
+ * comments, package lookup, and the package_api tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { isPackageSource, packageApi } from "../src/go-api";
+import { GoModuleIndex } from "../src/go-modules";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const DOC_GO = `// Package auth validates bearer tokens. It is safe for concurrent use.
+package auth
+`;
+
+const TOKEN_GO = `package auth
+
+import "time"
+
+// Token lifetimes.
+const (
+	// DefaultTTL is used when no TTL is configured.
+	DefaultTTL = time.Hour
+	MaxTTL     = 24 * time.Hour
+	minTTL     = time.Minute
+)
+
+// Issuer is the default issuer URL.
+var Issuer = "https://auth.example.com" // not a comment: https://
+
+// Validator checks tokens.
+type Validator interface {
+	// Validate reports whether the token is valid.
+	Validate(token string) error
+	reset()
+}
+
+// Server serves tokens.
+type Server struct {
+	ttl time.Duration
+}
+
+// NewServer returns a Server with the default TTL.
+func NewServer() *Server {
+	return &Server{ttl: DefaultTTL}
+}
+
+// Issue mints a token.
+//
+// The token expires after the server's TTL.
+func (s *Server) Issue(subject string) (string, error) {
+	return "", nil
+}
+
+func (s *Server) sign(b []byte) []byte {
+	return b
+}
+
+// Parse splits a raw header value.
+func Parse(header string) (string, error) {
+	return header, nil
+}
+
+func helper() {}
+`;
+
+const EXTRA_GO = `package auth
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return nil
+}
+`;
+
+describe("packageApi", () => {
+  const api = packageApi([
+    { path: "auth/token.go", source: TOKEN_GO },
+    { path: "auth/doc.go", source: DOC_GO },
+    { path: "auth/extra.go", source: EXTRA_GO },
+  ]);
+
+  test("reads the package name and the first sentence of the package comment", () => {
+    expect(api.name).toBe("auth");
+    expect(api.doc).toBe("Package auth validates bearer tokens.");
+  });
+
+  test("lists exported names from const blocks with their docs", () => {
+    expect(api.constants.map((c) => c.name)).toEqual(["DefaultTTL", "MaxTTL"]);
+    expect(api.constants[0].doc).toBe("DefaultTTL is used when no TTL is configured.");
+    expect(api.constants[1].doc).toBe("Token lifetimes.");
+    expect(api.constants[0].signature).toBe("const DefaultTTL = time.Hour");
+  });
+
+  test("keeps // inside string literals out of comments", () => {
+    expect(api.variables).toHaveLength(1);
+    expect(api.variables[0].signature).toBe('var Issuer = "https://auth.example.com"');
+    expect(api.variables[0].doc).toBe("Issuer is the default issuer URL.");
+  });
+
+  test("leaves out unexported functions and constructors", () => {
+    expect(api.functions.map((f) => f.name)).toEqual(["Parse"]);
+  });
+
+  test("groups constructors and methods from every file under their type", () => {
+    const server = api.types.find((t) => t.name === "Server")!;
+    expect(server.kind).toBe("struct");
+    expect(server.constructors.map((c) => c.name)).toEqual(["NewServer"]);
+    expect(server.methods.map((m) => m.name)).toEqual(["Close", "Issue"]);
+    expect(server.methods[1].doc).toBe("Issue mints a token.");
+    expect(server.methods[0].file).toBe("auth/extra.go");
+  });
+
+  test("lists the exported method set of an interface", () => {
+    const validator = api.types.find((t) => t.name === "Validator")!;
+    expect(validator.kind).toBe("interface");
+    expect(validator.methods.map((m) => m.signature)).toEqual(["Validate(token string) error"]);
+  });
+
+  test("skips files excluded with //go:build ignore", () => {
+    const ignored = packageApi([{ path: "gen.go", source: "//go:build ignore\n\npackage main\n\nfunc Run() {}\n" }]);
+    expect(ignored.files).toEqual([]);
+    expect(ignored.functions).toEqual([]);
+  });
+});
+
+describe("isPackageSource", () => {
+  test("excludes tests and files go ignores", () => {
+    expect(isPackageSource("token.go")).toBe(true);
+    expect(isPackageSource("token_test.go")).toBe(false);
+    expect(isPackageSource("_scratch.go")).toBe(false);
+    expect(isPackageSource("README.md")).toBe(false);
+  });
+});
+
+// ── Lookup and the package_api tool ──────────────────────────────────
+
+let root: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  root = await mkdtemp(join(tmpdir(), "treenav-goapi-"));
+  await mkdir(join(root, "internal", "auth"), { recursive: true });
+  await writeFile(join(root, "go.mod"), "module example.com/svc\n\ngo 1.22\n");
+  await writeFile(join(root, "internal", "auth", "doc.go"), DOC_GO);
+  await writeFile(join(root, "internal", "auth", "token.go"), TOKEN_GO);
+  await writeFile(join(root, "internal", "auth", "token_test.go"), "package auth\n\nfunc TestHidden() {}\n");
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(root, { recursive: true, force: true });
+});
+
+describe("GoModuleIndex.locatePackage", () => {
+  test("resolves an import path through the owning module", async () => {
+    const location = await new GoModuleIndex(config).locatePackage("example.com/svc/internal/auth");
+    expect(location?.dir).toBe("internal/auth");
+    expect(location?.module).toBe("example.com/svc");
+  });
+
+  test("resolves a directory and derives its import path", async () => {
+    const location = await new GoModuleIndex(config).locatePackage("code:internal/auth/");
+    expect(location?.import_path).toBe("example.com/svc/internal/auth");
+  });
+
+  test("rejects directories outside the collection", async () => {
+    expect(await new GoModuleIndex(config).locatePackage("../etc")).toBeNull();
+    expect(await new GoModuleIndex(config).locatePackage("internal/missing")).toBeNull();
+  });
+});
+
+describe("package_api tool", () => {
+  test("renders the exported API godoc-style", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({ name: "package_api", arguments: { package: "internal/auth" } });
+    const text = getToolText(result as any);
+    expect(text).toContain('package auth // import "example.com/svc/internal/auth"');
+    expect(text).toContain("func NewServer() *Server  [code:internal/auth/token.go:");
+    expect(text).not.toContain("helper");
+    expect(text).not.toContain("TestHidden");
+
+    const data = result.structuredContent as any;
+    expect(data.status).toBe("ok");
+    expect(data.package.files).toEqual(["internal/auth/doc.go", "internal/auth/token.go"]);
+    expect(data.types.map((t: any) => t.name)).toEqual(["Server", "Validator"]);
+    await harness.cleanup();
+  });
+
+  test("reports not_found for an unknown package", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({ name: "package_api", arguments: { package: "example.com/other" } });
+    expect((result.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
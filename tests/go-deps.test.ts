@@ -0,0 +1,92 @@
+/**
+ * Tests for dependency indexing from the module cache: path escaping,
+ * which requirements become collections, and indexing them read-only.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { dependencyCollections, escapeModulePath } from "../src/go-deps";
+import { GoModuleIndex } from "../src/go-modules";
+import { indexAllCollections } from "../src/indexer";
+import type { IndexConfig } from "../src/types";
+
+const GO_MOD = `module example.com/app
+
+go 1.22
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/sync v0.7.0 // indirect
+	example.com/app/lib v0.0.0
+	github.com/missing/dep v1.0.0
+	github.com/forked/dep v1.0.0
+	github.com/local/dep v1.0.0
+)
+
+replace github.com/forked/dep => github.com/me/dep v1.1.0
+
+replace github.com/local/dep => ../dep
+`;
+
+let dir: string;
+let modcache: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-deps-"));
+  modcache = join(dir, "modcache");
+  const files: Record<string, string> = {
+    "repo/go.mod": GO_MOD,
+    "repo/lib/go.mod": "module example.com/app/lib\n",
+    "modcache/github.com/!burnt!sushi/toml@v1.3.2/decode.go": "package toml\n\nfunc Decode(data string, v any) error { return nil }\n",
+    "modcache/github.com/stretchr/testify@v1.9.0/assert/assertions.go": "package assert\n\nfunc Equal(t any, expected, actual any) bool { return true }\n",
+    "modcache/github.com/stretchr/testify@v1.9.0/README.md": "# Testify\n",
+    "modcache/github.com/me/dep@v1.1.0/dep.go": "package dep\n",
+    "modcache/golang.org/x/sync@v0.7.0/errgroup/errgroup.go": "package errgroup\n",
+  };
+  for (const [path, content] of Object.entries(files)) {
+    await mkdir(join(dir, path, ".."), { recursive: true });
+    await writeFile(join(dir, path), content);
+  }
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: join(dir, "repo"), weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("escapeModulePath", () => {
+  test("escapes uppercase letters the way the module cache does", () => {
+    expect(escapeModulePath("github.com/BurntSushi/toml")).toBe("github.com/!burnt!sushi/toml");
+    expect(escapeModulePath("v1.0.0-RC1")).toBe("v1.0.0-!r!c1");
+  });
+});
+
+describe("dependencyCollections", () => {
+  test("collects direct, cached, non-local requirements, following replaces", async () => {
+    const deps = await dependencyCollections(new GoModuleIndex(config), modcache);
+    expect(deps.collections.map((c) => [c.name, c.weight])).toEqual([
+      ["gomod/github.com/BurntSushi/toml@v1.3.2", 0.5],
+      ["gomod/github.com/me/dep@v1.1.0", 0.5],
+      ["gomod/github.com/stretchr/testify@v1.9.0", 0.5],
+    ]);
+    expect(deps.collections[0].root).toBe(join(modcache, "github.com/!burnt!sushi/toml@v1.3.2"));
+    expect(deps.missing).toEqual(["github.com/missing/dep@v1.0.0"]);
+  });
+
+  test("indexes dependency sources as namespaced code", async () => {
+    config.dependency_collections = (await dependencyCollections(new GoModuleIndex(config), modcache)).collections;
+    const docs = await indexAllCollections(config);
+    const ids = docs.map((d) => d.meta.doc_id).sort();
+    expect(ids).toContain("gomod/github.com/stretchr/testify@v1.9.0:assert:assertions_go");
+    expect(ids.some((id) => id.endsWith("README_md"))).toBe(false);
+  });
+});
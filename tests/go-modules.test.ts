@@ -0,0 +1,271 @@
+/**
+ * Tests for Go module metadata: the go.mod, go.work, and go.sum
+ * parsers, the in-repo module graph, discovery under code collections,
+ * and the module_info tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import {
+  findModule,
+  GoModuleIndex,
+  moduleEdges,
+  moduleForPath,
+  parseGoMod,
+  parseGoSum,
+  parseGoWork,
+  type GoModule,
+} from "../src/go-modules";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const API_GO_MOD = `// API service
+module github.com/acme/platform/api
+
+go 1.22.1
+
+toolchain go1.22.4
+
+require (
+	github.com/acme/platform/lib v0.0.0
+	github.com/go-chi/chi/v5 v5.0.12
+	golang.org/x/sync v0.7.0 // indirect
+)
+
+require "github.com/google/uuid" v1.6.0
+
+replace github.com/acme/platform/lib => ../lib
+
+replace (
+	golang.org/x/net v0.20.0 => golang.org/x/net v0.21.0
+)
+
+exclude golang.org/x/crypto v0.1.0
+
+retract v0.9.0
+`;
+
+describe("parseGoMod", () => {
+  test("reads module, go, toolchain, require, replace, and exclude", () => {
+    const mod = parseGoMod(API_GO_MOD)!;
+    expect(mod.module).toBe("github.com/acme/platform/api");
+    expect(mod.go).toBe("1.22.1");
+    expect(mod.toolchain).toBe("go1.22.4");
+    expect(mod.require).toEqual([
+      { path: "github.com/acme/platform/lib", version: "v0.0.0", indirect: false },
+      { path: "github.com/go-chi/chi/v5", version: "v5.0.12", indirect: false },
+      { path: "golang.org/x/sync", version: "v0.7.0", indirect: true },
+      { path: "github.com/google/uuid", version: "v1.6.0", indirect: false },
+    ]);
+    expect(mod.replace).toEqual([
+      { old: "github.com/acme/platform/lib", new: "../lib" },
+      { old: "golang.org/x/net", old_version: "v0.20.0", new: "golang.org/x/net", new_version: "v0.21.0" },
+    ]);
+    expect(mod.exclude).toEqual([{ path: "golang.org/x/crypto", version: "v0.1.0" }]);
+  });
+
+  test("returns null without a module directive", () => {
+    expect(parseGoMod("go 1.22\n")).toBeNull();
+  });
+
+  test("keeps comment markers inside quoted paths", () => {
+    const mod = parseGoMod('module "example.com/a//b"\n')!;
+    expect(mod.module).toBe("example.com/a//b");
+  });
+});
+
+describe("parseGoWork", () => {
+  test("reads use directives in blocks and single lines", () => {
+    const work = parseGoWork("go 1.22\n\nuse (\n\t./api\n\t./lib // shared code\n)\nuse ./tools\n");
+    expect(work.go).toBe("1.22");
+    expect(work.use).toEqual(["./api", "./lib", "./tools"]);
+    expect(work.replace).toEqual([]);
+  });
+});
+
+describe("parseGoSum", () => {
+  test("keeps module hashes and skips go.mod hashes", () => {
+    const sums = parseGoSum(
+      "github.com/go-chi/chi/v5 v5.0.12 h1:abc=\ngithub.com/go-chi/chi/v5 v5.0.12/go.mod h1:def=\n"
+    );
+    expect(sums.get("github.com/go-chi/chi/v5@v5.0.12")).toBe("h1:abc=");
+    expect(sums.size).toBe(1);
+  });
+});
+
+describe("module graph", () => {
+  const mod = (module: string, dir: string, extra: Partial<GoModule> = {}): GoModule => ({
+    module,
+    collection: "code",
+    dir,
+    require: [],
+    replace: [],
+    exclude: [],
+    ...extra,
+  });
+
+  const modules = [
+    mod("example.com/root", ""),
+    mod("example.com/api", "services/api", {
+      require: [{ path: "example.com/lib", version: "v0.0.0", indirect: false }],
+      replace: [{ old: "example.com/lib", new: "../../lib" }],
+    }),
+    mod("example.com/lib", "lib"),
+  ];
+
+  test("links requires and local replaces between repo modules", () => {
+    expect(moduleEdges(modules)).toEqual([
+      { from: "example.com/api", to: "example.com/lib", via: "require" },
+      { from: "example.com/api", to: "example.com/lib", via: "replace" },
+    ]);
+  });
+
+  test("finds a module by path or directory, and the innermost one for a file", () => {
+    const graph = { modules, workspaces: [], edges: [] };
+    expect(findModule(graph, "example.com/lib")?.dir).toBe("lib");
+    expect(findModule(graph, "./services/api/")?.module).toBe("example.com/api");
+    expect(moduleForPath(graph, "services/api/handlers/user.go")?.module).toBe("example.com/api");
+    expect(moduleForPath(graph, "cmd/main.go")?.module).toBe("example.com/root");
+  });
+});
+
+// ── Discovery and module_info ────────────────────────────────────────
+
+let root: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  root = await mkdtemp(join(tmpdir(), "treenav-gomod-"));
+  await mkdir(join(root, "api"), { recursive: true });
+  await mkdir(join(root, "lib"), { recursive: true });
+  await mkdir(join(root, "vendor", "x"), { recursive: true });
+  await writeFile(join(root, "go.work"), "go 1.22\n\nuse (\n\t./api\n\t./lib\n)\n");
+  await writeFile(
+    join(root, "api", "go.mod"),
+    "module example.com/api\n\ngo 1.22\n\nrequire (\n\texample.com/lib v0.0.0\n\tgithub.com/google/uuid v1.6.0\n\tgolang.org/x/sync v0.7.0 // indirect\n)\n"
+  );
+  await writeFile(join(root, "api", "go.sum"), "github.com/google/uuid v1.6.0 h1:uuid=\n");
+  await writeFile(join(root, "lib", "go.mod"), "module example.com/lib\n\ngo 1.21\n");
+  await writeFile(join(root, "vendor", "x", "go.mod"), "module example.com/vendored\n");
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(root, { recursive: true, force: true });
+});
+
+describe("GoModuleIndex", () => {
+  test("discovers modules and workspaces, skipping vendor/", async () => {
+    const graph = await new GoModuleIndex(config).graph();
+    expect(graph.modules.map((m) => m.module)).toEqual(["example.com/api", "example.com/lib"]);
+    expect(graph.workspaces).toHaveLength(1);
+    expect(graph.workspaces[0].modules).toEqual(["example.com/api", "example.com/lib"]);
+    expect(graph.edges).toEqual([{ from: "example.com/api", to: "example.com/lib", via: "require" }]);
+  });
+
+  test("attaches go.sum checksums to requirements", async () => {
+    const graph = await new GoModuleIndex(config).graph();
+    const uuid = graph.modules[0].require.find((r) => r.path === "github.com/google/uuid");
+    expect(uuid?.checksum).toBe("h1:uuid=");
+  });
+
+  test("re-reads go.mod on every call", async () => {
+    const index = new GoModuleIndex(config);
+    await index.graph();
+    await writeFile(join(root, "lib", "go.mod"), "module example.com/lib\n\ngo 1.23\n");
+    const graph = await index.graph();
+    expect(graph.modules[1].go).toBe("1.23");
+  });
+});
+
+describe("module_info tool", () => {
+  test("is registered only with a module index", async () => {
+    const without = await createMcpTestClient();
+    const names = (await without.client.listTools()).tools.map((t) => t.name);
+    expect(names.includes("module_info")).toBe(false);
+    await without.cleanup();
+
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const tool = (await harness.client.listTools()).tools.find((t) => t.name === "module_info");
+    expect(tool?.annotations?.readOnlyHint).toBe(true);
+    await harness.cleanup();
+  });
+
+  test("lists modules and the workspace graph without arguments", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({ name: "module_info", arguments: {} });
+    const text = getToolText(result as any);
+    expect(text).toContain("example.com/api");
+    expect(text).toContain("use ./lib");
+    expect(text).toContain("example.com/api → example.com/lib");
+    const data = result.structuredContent as any;
+    expect(data.modules).toHaveLength(2);
+    expect(data.edges).toHaveLength(1);
+    await harness.cleanup();
+  });
+
+  test("draws the module graph as DOT or Mermaid on request", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const mermaid = await harness.client.callTool({ name: "module_info", arguments: { format: "mermaid" } });
+    expect(getToolText(mermaid as any)).toBe(
+      '```mermaid\nflowchart LR\n  n0["example.com/api"]\n  n1["example.com/lib"]\n  n0 --> n1\n```'
+    );
+    const dot = await harness.client.callTool({ name: "module_info", arguments: { module: "example.com/lib", format: "dot" } });
+    const data = dot.structuredContent as any;
+    expect(data.diagram).toContain('n1 [label="example.com/api"];');
+    expect(data.diagram).toContain("n1 -> n0;");
+    expect(data.modules[0].module).toBe("example.com/lib");
+    await harness.cleanup();
+  });
+
+  test("shows one module for a file path, optionally without indirect deps", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({
+      name: "module_info",
+      arguments: { module: "api/server/main.go", include_indirect: false },
+    });
+    const text = getToolText(result as any);
+    expect(text).toContain("# example.com/api");
+    expect(text).toContain("github.com/google/uuid v1.6.0");
+    expect(text).not.toContain("golang.org/x/sync");
+    const data = result.structuredContent as any;
+    expect(data.modules[0].require.map((r: any) => r.path)).toEqual([
+      "example.com/lib",
+      "github.com/google/uuid",
+    ]);
+    expect(data.workspaces).toHaveLength(1);
+    await harness.cleanup();
+  });
+
+  test("lists in-repo dependents of a module", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({ name: "module_info", arguments: { module: "example.com/lib" } });
+    expect(getToolText(result as any)).toContain("Used by in-repo modules (1)");
+    await harness.cleanup();
+  });
+
+  test("reports not_found for an unknown module", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({ name: "module_info", arguments: { module: "example.com/none" } });
+    expect((result.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+
+  test("explains that a dependency path is not a repo module", async () => {
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config) });
+    const result = await harness.client.callTool({
+      name: "module_info",
+      arguments: { module: "github.com/google/uuid" },
+    });
+    expect((result.structuredContent as any).status).toBe("not_found");
+    expect(getToolText(result as any)).toContain("external dependency of example.com/api");
+    await harness.cleanup();
+  });
+});
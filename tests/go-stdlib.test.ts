@@ -0,0 +1,116 @@
+/**
+ * Tests for standard library resolution: locating packages under a
+ * GOROOT, qualified lookups, and the find_symbol / package_api fallbacks.
+ */
+
+import { afterAll, beforeAll, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { GoStdlib } from "../src/go-stdlib";
+import { GoModuleIndex } from "../src/go-modules";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const GOROOT_FILES: Record<string, string> = {
+  "src/sync/rwmutex.go": `package sync
+
+// A RWMutex is a reader/writer mutual exclusion lock.
+type RWMutex struct {
+	w Mutex
+}
+
+// RLock locks rw for reading.
+func (rw *RWMutex) RLock() {}
+
+func (rw *RWMutex) rUnlockSlow(r int32) {}
+`,
+  "src/sync/cond.go": `package sync
+
+// Cond implements a condition variable.
+type Cond struct{}
+
+// NewCond returns a new Cond with Locker l.
+func NewCond(l Locker) *Cond { return &Cond{} }
+`,
+  "src/net/http/request.go": `package http
+
+// A Request represents an HTTP request.
+type Request struct {
+	Method string
+}
+`,
+  "src/html/template/template.go": "package template\n\ntype Template struct{}\n",
+  "src/text/template/template.go": "package template\n\ntype Template struct{}\n",
+  "src/internal/race/race.go": "package race\n\nfunc Enabled() bool { return false }\n",
+};
+
+let goroot: string;
+let code: string;
+
+beforeAll(async () => {
+  goroot = await mkdtemp(join(tmpdir(), "treenav-goroot-"));
+  for (const [path, content] of Object.entries(GOROOT_FILES)) {
+    await mkdir(join(goroot, path, ".."), { recursive: true });
+    await writeFile(join(goroot, path), content);
+  }
+  code = await mkdtemp(join(tmpdir(), "treenav-stdlib-code-"));
+  await writeFile(join(code, "go.mod"), "module example.com/app\n\ngo 1.22\n");
+});
+
+afterAll(async () => {
+  await rm(goroot, { recursive: true, force: true });
+  await rm(code, { recursive: true, force: true });
+});
+
+describe("GoStdlib", () => {
+  test("locates packages by import path or last element, skipping internal", async () => {
+    const stdlib = new GoStdlib(goroot);
+    expect((await stdlib.locate("net/http")).map((p) => p.import_path)).toEqual(["net/http"]);
+    expect((await stdlib.locate("template")).map((p) => p.import_path)).toEqual(["html/template", "text/template"]);
+    expect(await stdlib.locate("race")).toEqual([]);
+  });
+
+  test("resolves types, methods, and constructors", async () => {
+    const stdlib = new GoStdlib(goroot);
+    const [mutex] = await stdlib.lookup("sync.RWMutex");
+    expect([mutex.package, mutex.kind, mutex.signature, mutex.doc, mutex.line]).toEqual([
+      "sync",
+      "struct",
+      "type RWMutex struct",
+      "A RWMutex is a reader/writer mutual exclusion lock.",
+      4,
+    ]);
+    expect(mutex.file_path).toBe(join(goroot, "src", "sync", "rwmutex.go"));
+    expect((await stdlib.lookup("sync.RWMutex.RLock")).map((s) => [s.name, s.kind])).toEqual([["RWMutex.RLock", "method"]]);
+    expect((await stdlib.lookup("sync.NewCond")).map((s) => s.kind)).toEqual(["function"]);
+    expect((await stdlib.lookup("http.Request")).map((s) => s.package)).toEqual(["net/http"]);
+    expect(await stdlib.lookup("sync.rUnlockSlow")).toEqual([]);
+    expect(await stdlib.lookup("RWMutex")).toEqual([]);
+  });
+});
+
+describe("standard library fallbacks", () => {
+  test("find_symbol answers qualified stdlib names and package_api stdlib packages", async () => {
+    const config: IndexConfig = {
+      collections: [],
+      code_collections: [{ name: "code", root: code, weight: 1.0 }],
+      summary_length: 200,
+      max_depth: 6,
+    };
+    const harness = await createMcpTestClient([], { goModules: new GoModuleIndex(config), stdlib: new GoStdlib(goroot) });
+
+    const found = await harness.client.callTool({ name: "find_symbol", arguments: { query: "sync.RWMutex" } });
+    expect((found.structuredContent as any).stdlib.map((s: any) => s.name)).toEqual(["RWMutex"]);
+    expect(getToolText(found as any)).toContain("1. sync.RWMutex (struct)");
+
+    const api = await harness.client.callTool({ name: "package_api", arguments: { package: "sync" } });
+    const data = api.structuredContent as any;
+    expect([data.package.collection, data.package.import_path]).toEqual(["GOROOT", "sync"]);
+    expect(data.types.map((t: any) => t.name)).toEqual(["Cond", "RWMutex"]);
+
+    const ambiguous = await harness.client.callTool({ name: "package_api", arguments: { package: "template" } });
+    expect((ambiguous.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
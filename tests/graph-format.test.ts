@@ -0,0 +1,41 @@
+/**
+ * Tests for DOT and Mermaid rendering: generated ids, label escaping,
+ * dashed edges, and standalone nodes.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { fenceDiagram, renderDiagram, toDot, toMermaid } from "../src/graph-format";
+
+const edges = [
+  { from: 'say "hi"', to: "b\\c" },
+  { from: "b\\c", to: 'say "hi"', label: "replace" },
+];
+
+describe("graph formats", () => {
+  test("DOT quotes labels and dashes the chosen relations", () => {
+    expect(toDot(edges, { nodes: ["lonely"], dashed: ["replace"] })).toBe(
+      [
+        "digraph G {",
+        "  rankdir=LR;",
+        "  node [shape=box];",
+        '  n0 [label="lonely"];',
+        '  n1 [label="say \\"hi\\""];',
+        '  n2 [label="b\\\\c"];',
+        "  n1 -> n2;",
+        '  n2 -> n1 [label="replace", style=dashed];',
+        "}",
+      ].join("\n")
+    );
+  });
+
+  test("Mermaid uses entities for quotes and dotted arrows for dashed edges", () => {
+    expect(toMermaid(edges, { dashed: ["replace"] })).toBe(
+      ["flowchart LR", '  n0["say #quot;hi#quot;"]', '  n1["b\\c"]', "  n0 --> n1", '  n1 -.->|"replace"| n0'].join("\n")
+    );
+  });
+
+  test("text means no diagram", () => {
+    expect(renderDiagram("text", edges)).toBeNull();
+    expect(fenceDiagram("dot", "digraph G {}")).toBe("```dot\ndigraph G {}\n```");
+  });
+});
@@ -0,0 +1,68 @@
+/**
+ * Tests for the gRPC API handlers: defaults and limits shared with the
+ * MCP tools, status codes, and the proto declaring every handler.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { GRPC_STATUS, GrpcError, navigationHandlers, PROTO_PATH } from "../src/grpc";
+import { DocumentStore } from "../src/store";
+import { makeDoc, makeNode } from "./fixtures/helpers";
+
+function handlers() {
+  const store = new DocumentStore();
+  store.load([
+    makeDoc({
+      meta: { doc_id: "docs:auth", title: "Auth", collection: "docs", tags: ["auth"], facets: { type: ["guide"] } },
+      tree: [
+        makeNode({ node_id: "docs:auth:n1", title: "Auth", children: ["docs:auth:n2"], content: "Tokens are signed." }),
+        makeNode({ node_id: "docs:auth:n2", title: "Rotation", level: 2, parent_id: "docs:auth:n1", content: "Rotate keys monthly." }),
+      ],
+      root_nodes: ["docs:auth:n1"],
+    }),
+  ]);
+  return navigationHandlers(store);
+}
+
+async function status(call: Promise<object>): Promise<number> {
+  try {
+    await call;
+  } catch (err) {
+    if (err instanceof GrpcError) return err.code;
+    throw err;
+  }
+  return 0;
+}
+
+describe("navigationHandlers", () => {
+  test("answers with plain data in the proto's shape", async () => {
+    const rpc = handlers();
+    const listed: any = await rpc.ListDocuments({ filters: { type: { values: ["guide"] } }, limit: 0 });
+    expect([listed.total, listed.documents[0].facets]).toEqual([1, { type: { values: ["guide"] } }]);
+
+    const found: any = await rpc.SearchDocuments({ query: "rotate", limit: 0, case_mode: "CASE_MODE_UNSPECIFIED" });
+    expect(found.results.map((r: any) => r.node_id)).toEqual(["docs:auth:n2"]);
+
+    const sections: any = await rpc.GetNodeContent({ doc_id: "docs:auth", node_ids: ["docs:auth:n1", "docs:auth:n9"] });
+    expect([sections.nodes[0].parent_id, sections.missing]).toEqual(["", ["docs:auth:n9"]]);
+
+    const subtree: any = await rpc.NavigateTree({ doc_id: "docs:auth", node_id: "docs:auth:n1" });
+    expect(subtree.nodes.map((n: any) => n.title)).toEqual(["Auth", "Rotation"]);
+    const stats: any = await rpc.GetStats({});
+    expect([stats.document_count, stats.total_nodes, stats.collections]).toEqual([1, 2, ["docs"]]);
+  });
+
+  test("maps bad input and unknown ids to status codes", async () => {
+    const rpc = handlers();
+    expect(await status(rpc.SearchDocuments({ query: "" }))).toBe(GRPC_STATUS.INVALID_ARGUMENT);
+    expect(await status(rpc.FindSymbol({ query: "sign", kind: "macro" }))).toBe(GRPC_STATUS.INVALID_ARGUMENT);
+    expect(await status(rpc.GetNodeContent({ doc_id: "docs:auth", node_ids: [] }))).toBe(GRPC_STATUS.INVALID_ARGUMENT);
+    expect(await status(rpc.GetTree({ doc_id: "docs:nope" }))).toBe(GRPC_STATUS.NOT_FOUND);
+    expect(await status(rpc.NavigateTree({ doc_id: "docs:auth", node_id: "docs:auth:n9" }))).toBe(GRPC_STATUS.NOT_FOUND);
+  });
+
+  test("implements every RPC the proto declares", async () => {
+    const proto = await Bun.file(PROTO_PATH).text();
+    const rpcs = [...proto.matchAll(/^\s*rpc (\w+)\(/gm)].map((m) => m[1]).sort();
+    expect(Object.keys(handlers()).sort()).toEqual(rpcs);
+  });
+});
@@ -0,0 +1,133 @@
+/**
+ * Tests for hotspots: complexity counting, churn from git log, the
+ * commits × complexity ranking, and the tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { fileComplexity, Hotspots } from "../src/hotspots";
+import { indexCodeFile } from "../src/code-indexer";
+import { gitChurn } from "../src/git-history";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { git } from "./fixtures/git";
+
+const ROUTER = `package svc
+
+func route(path string) int {
+	// if this were simpler, for example
+	if path == "" || path == "/" {
+		return 0
+	}
+	for _, r := range path {
+		switch r {
+		case 'a':
+			return 1
+		case 'b':
+			return 2
+		}
+	}
+	return -1
+}
+
+func name() string { return "if for while" }
+`;
+
+describe("fileComplexity", () => {
+  test("counts decision points outside strings and comments", () => {
+    expect(fileComplexity(ROUTER, "svc/router.go")).toEqual({
+      complexity: 7,
+      functions: 2,
+      max_function: { name: "route", line: 3, complexity: 6 },
+    });
+  });
+
+  test("knows word operators and # comments", () => {
+    const py = "def ok(a, b):\n    # if a or b\n    return a and not b\n";
+    expect(fileComplexity(py, "ok.py").complexity).toBe(2);
+  });
+});
+
+// ── A scratch repository ─────────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+async function commit(path: string, content: string, message: string) {
+  await writeFile(join(dir, path), content);
+  git(dir, ["add", "."]);
+  git(dir, ["commit", "-q", "-m", message], "2020-06-01T12:00:00Z");
+}
+
+async function indexedStore(): Promise<DocumentStore> {
+  const store = new DocumentStore();
+  store.load([
+    await indexCodeFile(join(dir, "svc", "router.go"), dir, "code"),
+    await indexCodeFile(join(dir, "svc", "util.go"), dir, "code"),
+    await indexCodeFile(join(dir, "svc", "new.go"), dir, "code"),
+  ]);
+  return store;
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-hotspots-"));
+  await mkdir(join(dir, "svc"), { recursive: true });
+  git(dir, ["init", "-q"]);
+  await commit("svc/util.go", "package svc\n\nfunc id(x int) int { return x }\n", "util");
+  await commit("svc/router.go", ROUTER.replace("case 'b':\n\t\t\treturn 2\n", ""), "router");
+  await commit("svc/router.go", ROUTER, "router: b");
+  await commit("svc/util.go", "package svc\n\nfunc id(x int) int {\n\treturn x\n}\n", "util: format");
+  await commit("svc/router.go", ROUTER + "\n", "router: newline");
+  await writeFile(join(dir, "svc", "new.go"), "package svc\n");
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: dir, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("gitChurn", () => {
+  test("counts commits, lines, and authors per file", () => {
+    const churn = gitChurn(dir);
+    expect([churn.get("svc/router.go")!.commits, churn.get("svc/router.go")!.authors]).toEqual([3, 1]);
+    expect(churn.get("svc/util.go")!.lines_deleted).toBe(1);
+    expect(churn.has("svc/new.go")).toBe(false);
+    expect(gitChurn(dir, "2021-01-01").size).toBe(0);
+  });
+});
+
+describe("Hotspots", () => {
+  test("ranks changed files by commits × complexity", async () => {
+    const result = (await new Hotspots(config).rank(await indexedStore()))!;
+    expect(result.files).toBe(3);
+    expect(result.hotspots.map((h) => [h.file_path, h.score])).toEqual([
+      ["svc/router.go", 21],
+      ["svc/util.go", 2],
+    ]);
+    expect(await new Hotspots(config).rank(await indexedStore(), { path: "lib/" })).toBeNull();
+  });
+});
+
+describe("hotspots tool", () => {
+  test("lists the ranking with the most complex function", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), { hotspots: new Hotspots(config) });
+    const result = await harness.client.callTool({ name: "hotspots", arguments: { path: "svc/", limit: 1 } });
+    expect((result.structuredContent as any).hotspots).toHaveLength(1);
+    const text = getToolText(result as any);
+    expect(text).toContain("Hotspots (2 of 3 file(s) changed):");
+    expect(text).toContain(" 1. svc/router.go  score 21 = 3 commit(s) × complexity 7");
+    expect(text).toContain("last 2020-06-01; most complex: route (L3, 6)");
+
+    const later = await harness.client.callTool({ name: "hotspots", arguments: { since: "2021-01-01" } });
+    expect(getToolText(later as any)).toContain("None of 3 code file(s) has commits since 2021-01-01");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,117 @@
+/**
+ * Tests for block-compressed index files.
+ *
+ * Covers: the block layout (directory, random access to one document,
+ * several blocks), and compressed caches through saveIndexCache,
+ * loadIndexCache, verifyIndexCache, and a warm start.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir, readFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { BlockFileReader, encodeBlockFile } from "../src/index-blocks";
+import { loadIndexCache, loadOrBuildIndex, saveIndexCache, verifyIndexCache } from "../src/index-cache";
+import { indexAllCollections } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import { singleRootConfig } from "../src/types";
+import type { IndexConfig, IndexedDocument } from "../src/types";
+import { makeDoc, makeNode } from "./fixtures/helpers";
+
+let dir: string;
+let docsRoot: string;
+let cachePath: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-blocks-"));
+  docsRoot = join(dir, "docs");
+  cachePath = join(dir, ".treenav", "index.json");
+  await mkdir(docsRoot, { recursive: true });
+  await writeFile(join(docsRoot, "alpha.md"), "# Alpha\n\nAlpha content about tokens.\n");
+  await writeFile(join(docsRoot, "beta.md"), "# Beta\n\nBeta content about sessions.\n");
+  config = singleRootConfig(docsRoot);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const HEAD = { version: 1, created_at: "2026-01-01T00:00:00.000Z", config_fingerprint: "f" };
+
+function docs(count: number): IndexedDocument[] {
+  return Array.from({ length: count }, (_, i) =>
+    makeDoc({
+      meta: { doc_id: `docs:d${i}`, file_path: `d${i}.md`, title: `Doc ${i}`, content_hash: `h${i}` },
+      tree: [makeNode({ node_id: `docs:d${i}:n1`, title: `Doc ${i}`, content: `section ${i} `.repeat(50) })],
+    })
+  );
+}
+
+describe("block layout", () => {
+  test("lists documents from the directory and decodes one block on demand", async () => {
+    const path = join(dir, "blocks.bin");
+    // Each document is 500-1000 bytes of JSON, so two fill a block
+    await writeFile(path, encodeBlockFile(HEAD, docs(5), 1000));
+    const reader = BlockFileReader.open(path)!;
+    expect(reader.head).toEqual(HEAD);
+    expect(reader.entries().map((e) => [e.doc_id, e.content_hash, e.block])).toEqual([
+      ["docs:d0", "h0", 0],
+      ["docs:d1", "h1", 0],
+      ["docs:d2", "h2", 1],
+      ["docs:d3", "h3", 1],
+      ["docs:d4", "h4", 2],
+    ]);
+    expect(reader.document("docs:d3")!.tree[0].content).toBe("section 3 ".repeat(50));
+    expect(reader.document("docs:missing")).toBeNull();
+    expect(reader.documents()).toEqual(docs(5));
+    reader.close();
+  });
+
+  test("other files are not opened as block files", async () => {
+    const path = join(dir, "plain.json");
+    await writeFile(path, JSON.stringify({ documents: [] }));
+    expect(BlockFileReader.open(path)).toBeNull();
+  });
+});
+
+describe("compressed index cache", () => {
+  test("round-trips, and is smaller than the JSON cache", async () => {
+    await writeFile(join(docsRoot, "long.md"), `# Long\n\n${"Connection pools hand out sessions. ".repeat(400)}\n`);
+    const indexed = await indexAllCollections(config);
+    await saveIndexCache(cachePath, config, indexed, { compression: "zstd" });
+    const plainPath = join(dir, "plain.json");
+    await saveIndexCache(plainPath, config, indexed);
+
+    const bytes = await readFile(cachePath);
+    expect(bytes.subarray(0, 4).toString("latin1")).toBe("TNZB");
+    expect(bytes.length).toBeLessThan((await readFile(plainPath)).length / 2);
+    expect(await loadIndexCache(cachePath, config)).toEqual(await loadIndexCache(plainPath, config));
+  });
+
+  test("verify reads the directory, and a damaged cache is unusable", async () => {
+    await saveIndexCache(cachePath, config, await indexAllCollections(config), { compression: "zstd" });
+    await writeFile(join(docsRoot, "beta.md"), "# Beta\n\nChanged.\n");
+    const report = await verifyIndexCache(cachePath, config);
+    expect([report.indexed, report.unchanged, report.mismatched]).toEqual([2, 1, ["docs:beta"]]);
+
+    const bytes = await readFile(cachePath);
+    await writeFile(cachePath, bytes.subarray(0, bytes.length - 10));
+    expect((await verifyIndexCache(cachePath, config)).unusable).toBeUndefined();
+    expect(await loadIndexCache(cachePath, config)).toBeNull();
+    await writeFile(cachePath, bytes.subarray(0, 12));
+    expect((await verifyIndexCache(cachePath, config)).unusable).toBe("not a readable compressed index");
+  });
+
+  test("a warm start loads a compressed cache and writes it back compressed", async () => {
+    await saveIndexCache(cachePath, config, await indexAllCollections(config), { compression: "zstd" });
+    await writeFile(join(docsRoot, "gamma.md"), "# Gamma\n\nNew.\n");
+    const store = new DocumentStore();
+    const result = await loadOrBuildIndex(store, config, { cachePath, persist: true, compression: "zstd", log: () => {} });
+    expect(result.warm).toBe(true);
+    await result.validation;
+    expect(store.getStats().document_count).toBe(3);
+    expect((await readFile(cachePath)).subarray(0, 4).toString("latin1")).toBe("TNZB");
+    expect((await loadIndexCache(cachePath, config))!.length).toBe(3);
+  });
+});
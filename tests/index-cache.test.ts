@@ -0,0 +1,160 @@
+/**
+ * Tests for the persistent index cache.
+ *
+ * Covers: save/load round-trip, config fingerprint mismatch, warm start
+ * with background re-validation (changed, added, removed files), and
+ * the validating flag surfaced through search results.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir, unlink } from "node:fs/promises";
+import { existsSync } from "node:fs";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DocumentStore } from "../src/store";
+import { singleRootConfig } from "../src/types";
+import { indexAllCollections } from "../src/indexer";
+import { formatSearchResults, VALIDATING_NOTICE } from "../src/search-formatter";
+import {
+  loadIndexCache,
+  saveIndexCache,
+  loadOrBuildIndex,
+  configFingerprint,
+} from "../src/index-cache";
+import type { IndexConfig } from "../src/types";
+
+let dir: string;
+let docsRoot: string;
+let cachePath: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-cache-"));
+  docsRoot = join(dir, "docs");
+  cachePath = join(dir, ".treenav", "index.json");
+  await mkdir(docsRoot, { recursive: true });
+  await writeFile(join(docsRoot, "alpha.md"), "# Alpha\n\nAlpha content about tokens.\n");
+  await writeFile(join(docsRoot, "beta.md"), "# Beta\n\nBeta content about sessions.\n");
+  config = singleRootConfig(docsRoot);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+// ── Save / load ─────────────────────────────────────────────────────
+
+describe("saveIndexCache / loadIndexCache", () => {
+  test("round-trips documents", async () => {
+    const docs = await indexAllCollections(config);
+    await saveIndexCache(cachePath, config, docs);
+
+    const loaded = await loadIndexCache(cachePath, config);
+    expect(loaded).not.toBeNull();
+    expect(loaded!.map((d) => d.meta.doc_id).sort()).toEqual(["docs:alpha", "docs:beta"]);
+  });
+
+  test("returns null when the cache is missing", async () => {
+    expect(await loadIndexCache(cachePath, config)).toBeNull();
+  });
+
+  test("returns null when the config changed", async () => {
+    const docs = await indexAllCollections(config);
+    await saveIndexCache(cachePath, config, docs);
+
+    const other = singleRootConfig(docsRoot);
+    other.max_depth = 2;
+    expect(configFingerprint(other)).not.toBe(configFingerprint(config));
+    expect(await loadIndexCache(cachePath, other)).toBeNull();
+  });
+
+  test("returns null for a corrupt cache file", async () => {
+    await mkdir(join(dir, ".treenav"), { recursive: true });
+    await writeFile(cachePath, "{not json");
+    expect(await loadIndexCache(cachePath, config)).toBeNull();
+  });
+});
+
+// ── Warm start ──────────────────────────────────────────────────────
+
+describe("loadOrBuildIndex", () => {
+  const quiet = () => {};
+
+  test("cold start indexes and persists when asked", async () => {
+    const store = new DocumentStore();
+    const result = await loadOrBuildIndex(store, config, { cachePath, persist: true, log: quiet });
+
+    expect(result.warm).toBe(false);
+    expect(result.validation).toBeNull();
+    expect(store.getStats().document_count).toBe(2);
+    expect(existsSync(cachePath)).toBe(true);
+  });
+
+  test("cold start does not write a cache unless persist is set", async () => {
+    const store = new DocumentStore();
+    await loadOrBuildIndex(store, config, { cachePath, persist: false, log: quiet });
+    expect(existsSync(cachePath)).toBe(false);
+  });
+
+  test("warm start serves the cache then patches changes", async () => {
+    await loadOrBuildIndex(new DocumentStore(), config, { cachePath, persist: true, log: quiet });
+
+    // Working tree drifts while the server is down
+    await writeFile(join(docsRoot, "alpha.md"), "# Alpha\n\nAlpha now covers rotation.\n");
+    await writeFile(join(docsRoot, "gamma.md"), "# Gamma\n\nGamma content.\n");
+    await unlink(join(docsRoot, "beta.md"));
+
+    const store = new DocumentStore();
+    const result = await loadOrBuildIndex(store, config, { cachePath, persist: true, log: quiet });
+
+    expect(result.warm).toBe(true);
+    expect(store.isValidating()).toBe(true);
+    expect(store.getDocMeta("docs:beta")).not.toBeNull();
+
+    const report = await result.validation!;
+    expect(report).not.toBeNull();
+    expect(report!.updated).toEqual(["docs:alpha"]);
+    expect(report!.added).toEqual(["docs:gamma"]);
+    expect(report!.removed).toEqual(["docs:beta"]);
+    expect(store.isValidating()).toBe(false);
+
+    expect(store.getDocMeta("docs:beta")).toBeNull();
+    expect(store.searchDocuments("rotation").length).toBeGreaterThan(0);
+
+    // Cache was rewritten with the patched state
+    const reloaded = await loadIndexCache(cachePath, config);
+    expect(reloaded!.map((d) => d.meta.doc_id).sort()).toEqual(["docs:alpha", "docs:gamma"]);
+  });
+
+  test("unchanged files are not re-parsed", async () => {
+    await loadOrBuildIndex(new DocumentStore(), config, { cachePath, persist: true, log: quiet });
+
+    const store = new DocumentStore();
+    const result = await loadOrBuildIndex(store, config, { cachePath, persist: true, log: quiet });
+    const report = await result.validation!;
+
+    expect(report!.unchanged).toBe(2);
+    expect(report!.updated).toEqual([]);
+    expect(report!.added).toEqual([]);
+    expect(report!.removed).toEqual([]);
+  });
+});
+
+// ── Validating notice ───────────────────────────────────────────────
+
+describe("validating notice", () => {
+  test("search results are flagged while validating", async () => {
+    const store = new DocumentStore();
+    store.load(await indexAllCollections(config));
+    const results = store.searchDocuments("tokens");
+
+    expect(formatSearchResults(results, store, "tokens")).not.toContain(VALIDATING_NOTICE);
+
+    store.beginValidating();
+    expect(formatSearchResults(results, store, "tokens")).toContain(VALIDATING_NOTICE);
+    expect(store.getStats().validating).toBe(true);
+
+    store.endValidating();
+    expect(formatSearchResults(results, store, "tokens")).not.toContain(VALIDATING_NOTICE);
+  });
+});
@@ -0,0 +1,134 @@
+/**
+ * Tests for content-based language detection: file names, shebangs,
+ * modelines, content heuristics, Makefile parsing, and discovery of
+ * extensionless files in a code collection.
+ */
+
+import { describe, test, expect, beforeAll, afterAll } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join, relative } from "node:path";
+import { tmpdir } from "node:os";
+import { detectExtension, isSniffable, sniffExtension } from "../src/language-detect";
+import { indexCodeContent, listCodeFiles } from "../src/code-indexer";
+import { parseGeneric } from "../src/parsers/generic";
+
+const TIME = "2026-01-01T00:00:00.000Z";
+
+const MAKEFILE = [
+  "include common.mk",
+  "",
+  "CFLAGS ?= -O2",
+  "export GOFLAGS := -mod=vendor",
+  "",
+  ".PHONY: build test",
+  "",
+  "build: deps",
+  "\tgo build ./...",
+  "",
+  "\tgo vet ./...",
+  "",
+  "test:",
+  "\tgo test ./...",
+].join("\n");
+
+describe("detectExtension", () => {
+  test("knows build files by name", () => {
+    expect(detectExtension("Makefile", "")).toBe(".mk");
+    expect(detectExtension("tools/BUILD.bazel", "")).toBe(".bzl");
+    expect(detectExtension("Rakefile", "")).toBe(".rb");
+  });
+
+  test("reads shebangs, through env and version suffixes", () => {
+    expect(detectExtension("bin/deploy", "#!/usr/bin/env python3\nprint(1)\n")).toBe(".py");
+    expect(detectExtension("bin/run", "#!/bin/bash\nset -e\n")).toBe(".sh");
+    expect(detectExtension("bin/serve", "#!/usr/bin/env -S deno run -A\n")).toBe(".ts");
+    expect(detectExtension("bin/tool", "#!/usr/local/bin/node\n")).toBe(".js");
+    expect(detectExtension("bin/x", "#!/usr/bin/perl\n")).toBeNull();
+  });
+
+  test("reads vim and emacs modelines at either end", () => {
+    expect(detectExtension("hooks/pre-commit", "# vim: set ft=ruby :\nputs 1\n")).toBe(".rb");
+    expect(detectExtension("conf/setup", "# -*- mode: python; coding: utf-8 -*-\n")).toBe(".py");
+    expect(detectExtension("conf/env", "# -*- sh -*-\n")).toBe(".sh");
+    const trailing = [...Array(20).fill("echo hi"), "# vi: filetype=sh"].join("\n");
+    expect(detectExtension("conf/profile", trailing)).toBe(".sh");
+    expect(detectExtension("conf/notes", "# -*- coding: utf-8 -*-\n")).toBeNull();
+  });
+
+  test("falls back to content heuristics", () => {
+    expect(detectExtension("web/index", "<?php\necho 'hi';\n")).toBe(".php");
+    expect(detectExtension("build/rules", "all: main.o\n\tcc -o all main.o\n")).toBe(".mk");
+    expect(detectExtension("scripts/fetch", "import sys\n\ndef main():\n    pass\n")).toBe(".py");
+    expect(detectExtension("LICENSE", "MIT License\n\nPermission is hereby granted: free of charge.\n")).toBeNull();
+    expect(detectExtension("bin/tool", "\0ELF binary")).toBeNull();
+  });
+});
+
+describe("isSniffable", () => {
+  test("only extensionless files and known names", () => {
+    expect(isSniffable("bin/deploy")).toBe(true);
+    expect(isSniffable("BUILD.bazel")).toBe(true);
+    expect(isSniffable("notes.txt")).toBe(false);
+    expect(isSniffable(".envrc")).toBe(false);
+  });
+});
+
+describe("Makefile parsing", () => {
+  test("rules are functions and variables are variables", () => {
+    const symbols = parseGeneric(MAKEFILE, "code:Makefile", ".mk");
+    expect(symbols.map((s) => [s.kind, s.name])).toEqual([
+      ["import", "imports"],
+      ["variable", "CFLAGS"],
+      ["variable", "GOFLAGS"],
+      ["function", "build"],
+      ["function", "test"],
+    ]);
+    const build = symbols.find((s) => s.name === "build")!;
+    expect([build.line_start, build.line_end]).toEqual([8, 11]);
+    expect(symbols.find((s) => s.name === "GOFLAGS")!.exported).toBe(true);
+  });
+
+  test("an extensionless script is indexed under its detected language", () => {
+    const doc = indexCodeContent("#!/usr/bin/env python3\n\ndef deploy(env):\n    pass\n", "bin/deploy", "code", TIME);
+    expect(doc.meta.facets.language).toEqual(["python"]);
+    expect(doc.tree.map((n) => n.title)).toEqual(["function deploy"]);
+
+    const make = indexCodeContent(MAKEFILE, "Makefile", "code", TIME);
+    expect(make.meta.facets.language).toEqual(["make"]);
+  });
+});
+
+describe("listCodeFiles", () => {
+  let dir: string;
+
+  beforeAll(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-detect-"));
+    await mkdir(join(dir, "bin"), { recursive: true });
+    await writeFile(join(dir, "bin", "deploy"), "#!/bin/sh\necho deploy\n");
+    await writeFile(join(dir, "bin", "README"), "Scripts for deploying.\n");
+    await writeFile(join(dir, "Makefile"), MAKEFILE);
+    await writeFile(join(dir, "BUILD.bazel"), 'cc_library(name = "core")\n');
+    await writeFile(join(dir, "LICENSE"), "MIT License\n");
+    await writeFile(join(dir, "main.go"), "package main\n");
+    await writeFile(join(dir, "notes.txt"), "#!/bin/sh\n");
+  });
+
+  afterAll(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("keeps extensionless files whose name or content names a language", async () => {
+    const files = await listCodeFiles({ name: "code", root: dir, weight: 1 });
+    expect(files.map((f) => relative(dir, f)).sort()).toEqual(["BUILD.bazel", "Makefile", "bin/deploy", "main.go"]);
+  });
+
+  test("a custom glob must match extensionless files too", async () => {
+    const files = await listCodeFiles({ name: "code", root: dir, weight: 1, glob_pattern: "**/*.go" });
+    expect(files.map((f) => relative(dir, f))).toEqual(["main.go"]);
+  });
+
+  test("sniffExtension reads the file", async () => {
+    expect(await sniffExtension(join(dir, "bin", "deploy"))).toBe(".sh");
+    expect(await sniffExtension(join(dir, "missing"))).toBeNull();
+  });
+});
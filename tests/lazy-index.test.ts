@@ -0,0 +1,102 @@
+/**
+ * Tests for lazy on-demand indexing.
+ *
+ * Covers: skeleton construction, eager prefixes, expansion by query term
+ * (non-ASCII too) and by doc_id, and pending-region reporting.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DocumentStore } from "../src/store";
+import { singleRootConfig } from "../src/types";
+import { LazyIndex } from "../src/lazy-index";
+
+let dir: string;
+let store: DocumentStore;
+
+async function put(relPath: string, body: string) {
+  const full = join(dir, relPath);
+  await mkdir(join(full, ".."), { recursive: true });
+  await writeFile(full, body);
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-lazy-"));
+  await put("README.md", "# Overview\n\nTop-level overview.\n");
+  await put("services/payments/refunds.md", "# Refunds\n\nHow refunds settle.\n");
+  await put("services/payments/ledger.md", "# Ledger\n\nDouble-entry ledger.\n");
+  await put("third_party/grpc/notes.md", "# gRPC\n\nVendored transport notes.\n");
+  await put("third_party/openssl/notes.md", "# OpenSSL\n\nVendored crypto notes.\n");
+  store = new DocumentStore();
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+function lazyIndex(eager: string[] = []) {
+  return new LazyIndex(store, singleRootConfig(dir), { eager, log: () => {} });
+}
+
+describe("LazyIndex", () => {
+  test("indexes root files and eager regions only", async () => {
+    const lazy = lazyIndex(["services/payments"]);
+    await lazy.init();
+
+    expect(store.getDocMeta("docs:README")).not.toBeNull();
+    expect(store.getDocMeta("docs:services:payments:refunds")).not.toBeNull();
+    expect(store.getDocMeta("docs:third_party:grpc:notes")).toBeNull();
+
+    const pending = lazy.pendingRegions().map((r) => r.path).sort();
+    expect(pending).toEqual(["third_party/grpc", "third_party/openssl"]);
+  });
+
+  test("query terms matching a directory name expand that region", async () => {
+    const lazy = lazyIndex();
+    await lazy.init();
+    expect(store.searchDocuments("refunds").length).toBe(0);
+
+    const expanded = await lazy.expandForQuery("payments refunds");
+    expect(expanded).toEqual(["docs:services/payments"]);
+    expect(store.searchDocuments("refunds").length).toBeGreaterThan(0);
+    expect(store.getDocMeta("docs:third_party:grpc:notes")).toBeNull();
+  });
+
+  test("non-ASCII query terms match directory names", async () => {
+    await put("services/überweisungen/notes.md", "# Überweisungen\n\nSEPA transfers.\n");
+    const lazy = lazyIndex();
+    await lazy.init();
+
+    expect(await lazy.expandForQuery("Überweisungen SEPA")).toEqual(["docs:services/überweisungen"]);
+    expect(await lazy.expandForQuery("платежи")).toEqual([]);
+  });
+
+  test("ensureDocument expands the region holding a doc_id", async () => {
+    const lazy = lazyIndex();
+    await lazy.init();
+
+    expect(await lazy.ensureDocument("docs:third_party:openssl:notes")).toBe(true);
+    expect(store.getTree("docs:third_party:openssl:notes")).not.toBeNull();
+    expect(store.getDocMeta("docs:third_party:grpc:notes")).toBeNull();
+    expect(lazy.pendingRegions().map((r) => r.path)).not.toContain("third_party/openssl");
+  });
+
+  test("ensureDocument returns false for unknown doc_ids", async () => {
+    const lazy = lazyIndex();
+    await lazy.init();
+    expect(await lazy.ensureDocument("docs:nope")).toBe(false);
+  });
+
+  test("concurrent expansions of one region parse it once", async () => {
+    const lazy = lazyIndex();
+    await lazy.init();
+
+    await Promise.all([
+      lazy.expandForQuery("grpc"),
+      lazy.ensureDocument("docs:third_party:grpc:notes"),
+    ]);
+    expect(store.getStats().document_count).toBe(2);
+  });
+});
@@ -0,0 +1,140 @@
+/**
+ * Tests for license and provenance tagging: license texts and SPDX
+ * headers, copyleft of expressions, license files found up the tree,
+ * and the tags on search and read results.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { combineLicenses, copyleftOf, headerLicense, identifyLicense, LicenseIndex, originOf, spdxIdentifier } from "../src/licenses";
+import { indexCodeFile } from "../src/code-indexer";
+import type { IndexConfig, IndexedDocument } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const MIT = "MIT License\n\nCopyright (c) 2024 Acme\n\nPermission is hereby granted, free of charge, to any person obtaining a copy\n";
+const GPL3 = "                    GNU GENERAL PUBLIC LICENSE\n                       Version 3, 29 June 2007\n";
+
+describe("license texts", () => {
+  test("are recognized by their phrases, versions included", () => {
+    expect(identifyLicense(MIT)).toBe("MIT");
+    expect(identifyLicense(GPL3)).toBe("GPL-3.0");
+    expect(identifyLicense("GNU GENERAL PUBLIC LICENSE\nVersion 2, June 1991")).toBe("GPL-2.0");
+    expect(identifyLicense("GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999")).toBe("LGPL-2.1");
+    expect(identifyLicense("Apache License\n                           Version 2.0, January 2004")).toBe("Apache-2.0");
+    expect(identifyLicense("Redistribution and use in source and binary forms, with or without\n... Neither the name of Google Inc.")).toBe("BSD-3-Clause");
+    expect(identifyLicense("All rights reserved.")).toBeNull();
+  });
+
+  test("SPDX lines win, and only comment lines count as a header", () => {
+    expect(spdxIdentifier("/* SPDX-License-Identifier: GPL-2.0-only WITH Linux-syscall-note */")).toBe("GPL-2.0-only WITH Linux-syscall-note");
+    expect(headerLicense("// Copyright 2024 Acme\n// SPDX-License-Identifier: Apache-2.0\npackage x\n")).toBe("Apache-2.0");
+    expect(headerLicense("# Licensed under the Apache License, Version 2.0 (the \"License\");\nimport os\n")).toBe("Apache-2.0");
+    expect(headerLicense("# Licensing\n\nWe considered the GNU General Public License, version 3.\n")).toBeNull();
+    expect(headerLicense(`${"\n".repeat(45)}// SPDX-License-Identifier: MIT\n`)).toBeNull();
+  });
+
+  test("copyleft takes the weakest alternative and the strongest term", () => {
+    expect(copyleftOf("MIT")).toBeNull();
+    expect(copyleftOf("GPL-3.0-or-later")).toBe("strong");
+    expect(copyleftOf("AGPL-3.0")).toBe("network");
+    expect(copyleftOf("MIT OR GPL-2.0")).toBeNull();
+    expect(copyleftOf("(MPL-2.0 OR GPL-2.0) AND Apache-2.0")).toBe("weak");
+    expect(copyleftOf("LGPL-2.1 AND GPL-2.0 WITH Classpath-exception-2.0")).toBe("strong");
+  });
+
+  test("license files of one directory combine", () => {
+    expect(combineLicenses([{ name: "LICENSE-APACHE", license: "Apache-2.0" }, { name: "LICENSE-MIT", license: "MIT" }])).toBe("Apache-2.0 OR MIT");
+    expect(combineLicenses([{ name: "COPYING", license: "GPL-3.0" }, { name: "COPYING.LESSER", license: "LGPL-3.0" }])).toBe("LGPL-3.0");
+    expect(combineLicenses([{ name: "LICENSE", license: "MIT" }, { name: "LICENSE.third-party", license: "unknown" }])).toBe("MIT");
+  });
+
+  test("origin comes from the collection and path", () => {
+    expect(originOf("gomod/github.com/pkg/errors@v0.9.1", "errors.go")).toEqual({ origin: "dependency", module: "github.com/pkg/errors@v0.9.1" });
+    expect(originOf("code", "vendor/golang.org/x/net/http2/frame.go")).toEqual({ origin: "vendored" });
+    expect(originOf("code", "third_party/zlib/inflate.c")).toEqual({ origin: "third_party" });
+    expect(originOf("code", "src/main.go")).toEqual({ origin: "project" });
+  });
+});
+
+let dir: string;
+let root: string;
+let config: IndexConfig;
+let docs: IndexedDocument[];
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-licenses-"));
+  root = join(dir, "code");
+  for (const sub of ["src", "third_party/gpl", "vendor/acme/util"]) await mkdir(join(root, sub), { recursive: true });
+  await mkdir(join(dir, ".git"));
+  await writeFile(join(dir, "LICENSE"), MIT);
+  await writeFile(join(root, "third_party", "gpl", "COPYING"), GPL3);
+  await writeFile(join(root, "src", "retry.ts"), "export function retryRequest(times: number) {\n  return times;\n}\n");
+  await writeFile(join(root, "src", "apache.ts"), "// SPDX-License-Identifier: Apache-2.0\nexport function retryApache() {}\n");
+  await writeFile(join(root, "third_party", "gpl", "retry.ts"), "export function retryForever() {}\n");
+  await writeFile(join(root, "vendor", "acme", "util", "retry.ts"), "export function retryVendored() {}\n");
+  config = { collections: [], code_collections: [{ name: "code", root, weight: 1.0 }], summary_length: 200, max_depth: 6 };
+  docs = [];
+  for (const path of ["src/retry.ts", "src/apache.ts", "third_party/gpl/retry.ts", "vendor/acme/util/retry.ts"]) {
+    docs.push(await indexCodeFile(join(root, path), root, "code"));
+  }
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("LicenseIndex", () => {
+  test("tags each file from its header or the nearest license file", async () => {
+    const provenance = await new LicenseIndex(config).provenance(docs.map((d) => d.meta));
+    expect(Object.fromEntries([...provenance].map(([id, p]) => [docs.find((d) => d.meta.doc_id === id)!.meta.file_path, p]))).toEqual({
+      "src/retry.ts": { license: "MIT", source: "file", license_files: ["../LICENSE"], origin: "project" },
+      "src/apache.ts": { license: "Apache-2.0", source: "header", origin: "project" },
+      "third_party/gpl/retry.ts": { license: "GPL-3.0", source: "file", license_files: ["third_party/gpl/COPYING"], copyleft: "strong", origin: "third_party" },
+      "vendor/acme/util/retry.ts": { license: "MIT", source: "file", license_files: ["../LICENSE"], origin: "vendored" },
+    });
+  });
+
+  test("picks up a license file added later, and stops at the repository top", async () => {
+    const index = new LicenseIndex(config);
+    const vendored = docs.filter((d) => d.meta.file_path.startsWith("vendor/")).map((d) => d.meta);
+    expect((await index.provenance(vendored)).get(vendored[0].doc_id)!.license).toBe("MIT");
+    await writeFile(join(root, "vendor", "acme", "LICENSE"), GPL3.replace("Version 3", "Version 2"));
+    expect((await index.provenance(vendored)).get(vendored[0].doc_id)!.license_files).toEqual(["vendor/acme/LICENSE"]);
+
+    await rm(join(dir, "LICENSE"));
+    const own = docs.filter((d) => d.meta.file_path === "src/retry.ts").map((d) => d.meta);
+    expect(await new LicenseIndex(config).provenance(own)).toEqual(new Map([[own[0].doc_id, { origin: "project" }]]));
+  });
+});
+
+describe("license tags on results", () => {
+  test("search hits carry provenance, and the text lists copyleft first", async () => {
+    const harness = await createMcpTestClient(docs, { licenses: new LicenseIndex(config) });
+    const result = await harness.client.callTool({ name: "search_documents", arguments: { query: "retry" } });
+    const data = result.structuredContent as any;
+    const gpl = data.results.find((r: any) => r.file_path === "third_party/gpl/retry.ts");
+    expect(gpl.provenance).toEqual({ license: "GPL-3.0", source: "file", license_files: ["third_party/gpl/COPYING"], copyleft: "strong", origin: "third_party" });
+    const text = getToolText(result as any);
+    const footer = text.slice(text.indexOf("Licenses:"));
+    expect(footer.split("\n")[1]).toBe("  GPL-3.0, strong copyleft, third party (from third_party/gpl/COPYING): third_party/gpl/retry.ts");
+    expect(footer).toContain("  Apache-2.0 (from file header): src/apache.ts");
+
+    const section = await harness.client.callTool({
+      name: "get_node_content",
+      arguments: { doc_id: gpl.doc_id, node_ids: [gpl.node_id] },
+    });
+    expect((section.structuredContent as any).provenance.copyleft).toBe("strong");
+    expect(getToolText(section as any)).toEndWith("License: GPL-3.0, strong copyleft, third party (from third_party/gpl/COPYING)");
+    await harness.cleanup();
+  });
+
+  test("results are untagged without LICENSE_TAGS", async () => {
+    const harness = await createMcpTestClient(docs);
+    const result = await harness.client.callTool({ name: "search_documents", arguments: { query: "retry" } });
+    expect((result.structuredContent as any).results.some((r: any) => r.provenance)).toBe(false);
+    expect(getToolText(result as any)).not.toContain("Licenses:");
+    await harness.cleanup();
+  });
+});
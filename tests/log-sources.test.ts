@@ -0,0 +1,179 @@
+/**
+ * Tests for find_log_source: emitting calls found per language, format
+ * placeholders matched against log lines, metric names with exporter
+ * suffixes, and the tool's ranked answer.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { LogSourceIndex, matchTemplate, metricKey, scanLogSources, templateParts } from "../src/log-sources";
+import { indexAllCollections } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, writeTree } from "./fixtures/tree";
+
+const DIAL_GO = `package upstream
+
+var requests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "proxy",
+	Subsystem: "upstream",
+	Name:      "requests_total",
+	Help:      "Requests sent upstream.",
+}, []string{"code"})
+
+func Dial(addr string, attempt int) error {
+	// log.Printf("commented out %s", addr)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		log.Printf("dial upstream %s failed (attempt %d): %v", addr, attempt, err)
+		return fmt.Errorf("dial upstream: %w", err)
+	}
+	logger.Infow("upstream connected", "addr", addr)
+	log.Error().Err(err).Msg("handshake timed out")
+	fmt.Println("log.Printf(\\"in a string\\")")
+	return nil
+}
+`;
+
+const WORKER_PY = `import logging
+from prometheus_client import Histogram
+
+LATENCY = Histogram("job_latency_seconds", "Job latency.")
+logger = logging.getLogger(__name__)
+
+def run(job):
+    logger.warning("job %s retried %d times", job.id, job.retries)
+    raise RuntimeError(f"job {job.id} exhausted retries")
+`;
+
+const SERVER_TS = `export function listen(port: number) {
+  console.log(\`listening on :\${port}\`);
+  logger.error({ err }, "request failed");
+  statsd.increment("http.requests");
+  throw new TypeError("port must be a number");
+}
+`;
+
+let dir: string;
+let config: IndexConfig;
+let store: DocumentStore;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-logs-"));
+  await writeTree(dir, {
+    "upstream/dial.go": DIAL_GO,
+    "worker.py": WORKER_PY,
+    "server.ts": SERVER_TS,
+  });
+  config = codeConfig(dir);
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("scanLogSources", () => {
+  test("finds log, error, and metric calls in Go, leaving comments and strings alone", () => {
+    expect(scanLogSources(DIAL_GO, "upstream/dial.go").map((h) => [h.kind, h.api, h.level, h.template, h.line])).toEqual([
+      ["metric", "promauto.NewCounterVec", undefined, "proxy_upstream_requests_total", 3],
+      ["log", "log.Printf", undefined, "dial upstream %s failed (attempt %d): %v", 14],
+      ["error", "fmt.Errorf", undefined, "dial upstream: %w", 15],
+      ["log", "logger.Infow", "info", "upstream connected", 17],
+      ["log", "zerolog.Msg", "error", "handshake timed out", 18],
+    ]);
+  });
+
+  test("Python and TypeScript", () => {
+    expect(scanLogSources(WORKER_PY, "worker.py").map((h) => [h.kind, h.api, h.template])).toEqual([
+      ["metric", "prometheus_client.Histogram", "job_latency_seconds"],
+      ["log", "logger.warning", "job %s retried %d times"],
+      ["error", "raise RuntimeError", "job {job.id} exhausted retries"],
+    ]);
+    expect(scanLogSources(SERVER_TS, "server.ts").map((h) => [h.kind, h.api, h.level, h.template])).toEqual([
+      ["log", "console.log", "info", "listening on :${port}"],
+      ["log", "logger.error", "error", "request failed"],
+      ["metric", "statsd.increment", undefined, "http.requests"],
+      ["error", "throw TypeError", undefined, "port must be a number"],
+    ]);
+  });
+
+  test("Rust macros, SLF4J, and calls broken across lines", () => {
+    const rust = `fn main() {\n    tracing::warn!(%peer, "handshake failed: {}", err);\n    bail!("no route to {host}");\n}\n`;
+    expect(scanLogSources(rust, "main.rs").map((h) => [h.api, h.template])).toEqual([
+      ["tracing::warn!", "handshake failed: {}"],
+      ["bail!", "no route to {host}"],
+    ]);
+    const java = `class A {\n  void f() {\n    LOG.info(\n        "cache warmed in {} ms", ms);\n  }\n}\n`;
+    expect(scanLogSources(java, "A.java").map((h) => [h.api, h.template, h.line])).toEqual([["LOG.info", "cache warmed in {} ms", 3]]);
+  });
+});
+
+describe("matching", () => {
+  test("placeholders stand for values; the line may carry more around the message", () => {
+    expect(templateParts("%d%% done in %.2fs")).toEqual(["", "% done in ", "s"]);
+    expect(matchTemplate("dial upstream %s failed (attempt %d): %v", "2026/01/02 15:04:05 dial upstream 10.0.0.7:443 failed (attempt 3): connection refused")).toEqual({
+      score: 30,
+      values: ["10.0.0.7:443", "3", "connection refused"],
+    });
+    expect(matchTemplate("job {job.id} exhausted retries", "job 42 exhausted retries")?.values).toEqual(["42"]);
+    expect(matchTemplate("dial upstream %s failed", "dial upstream timed out")).toBeNull();
+    // Too little literal text to tell one call from another
+    expect(matchTemplate("%s: %v", "anything: at all")).toBeNull();
+  });
+
+  test("metric names lose labels, values, and exporter suffixes", () => {
+    expect(metricKey('proxy_upstream_requests_total{code="502"} 17')).toBe("proxy_upstream_requests");
+    expect(metricKey("job_latency_seconds_bucket")).toBe("job_latency_seconds");
+    expect(metricKey("http.requests")).toBe("http_requests");
+  });
+});
+
+describe("LogSourceIndex", () => {
+  test("ranks the template explaining the most of the line first", async () => {
+    const line = "dial upstream 10.0.0.7:443 failed (attempt 3): dial upstream: connection refused";
+    const [best, next] = await new LogSourceIndex(config).find(store, line);
+    expect([best.site.file_path, best.site.line, best.match, best.values]).toEqual([
+      "upstream/dial.go",
+      14,
+      "template",
+      ["10.0.0.7:443", "3", "dial upstream: connection refused"],
+    ]);
+    expect([next.site.api, next.values]).toEqual(["fmt.Errorf", ["connection refused"]]);
+  });
+
+  test("fragments, metrics, and the kind filter", async () => {
+    const index = new LogSourceIndex(config);
+    expect((await index.find(store, "exhausted retries")).map((m) => [m.site.file_path, m.match])).toEqual([["worker.py", "fragment"]]);
+    expect((await index.find(store, "job_latency_seconds_count")).map((m) => [m.site.api, m.match])).toEqual([
+      ["prometheus_client.Histogram", "metric"],
+    ]);
+    expect(await index.find(store, "request failed", { kind: "metric" })).toEqual([]);
+  });
+});
+
+describe("find_log_source tool", () => {
+  test("answers with the emitting call, its symbol, and the values", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { logSources: new LogSourceIndex(config) });
+    const result = await harness.client.callTool({
+      name: "find_log_source",
+      arguments: { text: 'level=warning msg="job 7 retried 3 times"' },
+    });
+    const data = result.structuredContent as any;
+    const { kind, level, api, values, file_path, line } = data.matches[0];
+    expect({ kind, level, api, values, file_path, line }).toEqual({ kind: "log", level: "warn", api: "logger.warning", values: ["7", "3"], file_path: "worker.py", line: 8 });
+    expect(data.matches[0].uri).toContain("worker.py#L8");
+    const text = getToolText(result as any);
+    expect(text).toContain("1 possible source(s)");
+    expect(text).toContain('worker.py:8  logger.warning  (log warn, template match)');
+    expect(text).toContain('  values: "7", "3"');
+
+    const none = await harness.client.callTool({ name: "find_log_source", arguments: { text: "nothing like this is ever logged" } });
+    expect((none.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
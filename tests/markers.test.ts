@@ -0,0 +1,148 @@
+/**
+ * Tests for TODO/FIXME markers: comment-style matching, owner tags,
+ * blame attribution and age from a scratch repository, caching by
+ * content hash, and the list_markers tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DEFAULT_MARKERS, MarkerIndex, markerPattern, scanMarkers } from "../src/markers";
+import { gitBlameLines } from "../src/git-history";
+import { indexCodeFile } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { initRepo } from "./fixtures/git";
+
+const pattern = markerPattern(DEFAULT_MARKERS);
+
+describe("scanMarkers", () => {
+  test("finds markers in each comment style", () => {
+    const source = [
+      "// TODO(alice): drop after the v2 migration",
+      "x = 1  # FIXME retry on 503",
+      "/* HACK: works around a driver bug */",
+      " * XXX - revisit",
+      "-- TODO tune this query",
+    ].join("\n");
+    expect(scanMarkers(source, pattern)).toEqual([
+      { marker: "TODO", tag: "alice", text: "drop after the v2 migration", line: 1 },
+      { marker: "FIXME", text: "retry on 503", line: 2 },
+      { marker: "HACK", text: "works around a driver bug", line: 3 },
+      { marker: "XXX", text: "revisit", line: 4 },
+      { marker: "TODO", text: "tune this query", line: 5 },
+    ]);
+  });
+
+  test("ignores lowercase words and markers outside comments", () => {
+    const source = "// add it to the todo list\nconst TODO = 1;\n// TODOS are tracked elsewhere\n";
+    expect(scanMarkers(source, pattern)).toEqual([]);
+  });
+
+  test("uses the configured marker words", () => {
+    const custom = markerPattern(["NOTE"]);
+    expect(scanMarkers("// NOTE: keep sorted\n// TODO: ignored\n", custom).map((m) => m.marker)).toEqual(["NOTE"]);
+  });
+});
+
+// ── Blame and the list_markers tool ──────────────────────────────────
+
+const OLD = Date.parse("2020-01-01T00:00:00Z");
+
+let dir: string;
+let config: IndexConfig;
+
+async function indexedStore(): Promise<DocumentStore> {
+  const store = new DocumentStore();
+  store.load([
+    await indexCodeFile(join(dir, "src", "retry.ts"), dir, "code"),
+    await indexCodeFile(join(dir, "src", "auth.ts"), dir, "code"),
+  ]);
+  return store;
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-markers-"));
+  await mkdir(join(dir, "src"), { recursive: true });
+  await writeFile(
+    join(dir, "src", "retry.ts"),
+    "export function retry() {\n  // FIXME: no backoff\n  return 1;\n}\n"
+  );
+  initRepo(dir, "initial", OLD);
+  await writeFile(join(dir, "src", "auth.ts"), "// TODO(carol): rotate keys\nexport function auth() {}\n");
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: dir, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("gitBlameLines", () => {
+  test("reports the author and time of committed lines only", () => {
+    const blame = gitBlameLines(dir, "src/retry.ts", [2]);
+    expect(blame.get(2)).toEqual({ author: "Bob", email: "bob@example.com", time: OLD });
+    expect(gitBlameLines(dir, "src/auth.ts", [1]).size).toBe(0);
+  });
+});
+
+describe("MarkerIndex", () => {
+  test("attaches blame to markers in indexed code", async () => {
+    const found = await new MarkerIndex(config).scan(await indexedStore());
+    const fixme = found.find((m) => m.marker === "FIXME")!;
+    expect(fixme).toEqual({
+      marker: "FIXME",
+      text: "no backoff",
+      line: 2,
+      doc_id: "code:src:retry_ts",
+      collection: "code",
+      file_path: "src/retry.ts",
+      author: "Bob",
+      email: "bob@example.com",
+      changed_at: OLD,
+    });
+    const todo = found.find((m) => m.marker === "TODO")!;
+    expect(todo.tag).toBe("carol");
+    expect(todo.author).toBeUndefined();
+  });
+
+  test("limits the scan to a path prefix", async () => {
+    const found = await new MarkerIndex(config).scan(await indexedStore(), "src/auth");
+    expect(found.map((m) => m.file_path)).toEqual(["src/auth.ts"]);
+  });
+});
+
+describe("list_markers tool", () => {
+  test("groups by owner, using the tag before the blame author", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      markers: new MarkerIndex(config),
+    });
+    const result = await harness.client.callTool({ name: "list_markers", arguments: { group_by: "owner" } });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(2);
+    expect(data.groups.map((g: any) => g.key).sort()).toEqual(["Bob", "carol"]);
+    expect(getToolText(result as any)).toContain("src/auth.ts:1 TODO(carol): rotate keys — not committed");
+    await harness.cleanup();
+  });
+
+  test("filters by age and marker", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      markers: new MarkerIndex(config),
+    });
+    const old = await harness.client.callTool({ name: "list_markers", arguments: { older_than_days: 365 } });
+    expect((old.structuredContent as any).markers.map((m: any) => m.marker)).toEqual(["FIXME"]);
+
+    const recent = await harness.client.callTool({ name: "list_markers", arguments: { newer_than_days: 30 } });
+    expect((recent.structuredContent as any).markers.map((m: any) => m.marker)).toEqual(["TODO"]);
+
+    const todos = await harness.client.callTool({ name: "list_markers", arguments: { marker: "TODO", owner: "CAROL" } });
+    expect((todos.structuredContent as any).total).toBe(1);
+    await harness.cleanup();
+  });
+});
@@ -17,6 +17,7 @@ import {
   makeNode,
   type McpTestHarness,
 } from "./fixtures/helpers";
+import { IndexCoverage } from "../src/coverage";
 
 // ── Shared fixture data ──────────────────────────────────────────────
 
@@ -202,7 +203,7 @@ describe("MCP tool discovery", () => {
     if (harness) await harness.cleanup();
   });
 
-  test("listTools returns all 6 tools", async () => {
+  test("listTools returns all 8 tools", async () => {
     harness = await createMcpTestClient(allDocs());
     const { tools } = await harness.client.listTools();
 
@@ -212,8 +213,10 @@ describe("MCP tool discovery", () => {
       "get_node_content",
       "get_tree",
       "list_documents",
+      "multi_search",
       "navigate_tree",
       "search_documents",
+      "set_preferences",
     ]);
   });
 
@@ -227,6 +230,35 @@ describe("MCP tool discovery", () => {
     }
   });
 
+  test("each tool declares a versioned output schema", async () => {
+    harness = await createMcpTestClient([]);
+    const { tools } = await harness.client.listTools();
+
+    for (const tool of tools) {
+      expect(tool.outputSchema).toBeDefined();
+      expect(tool.outputSchema!.required).toContain("schema_version");
+      expect(tool.outputSchema!.required).toContain("status");
+    }
+  });
+
+  test("read tools are annotated read-only and idempotent", async () => {
+    harness = await createMcpTestClient([]);
+    const { tools } = await harness.client.listTools();
+
+    for (const tool of tools.filter((t) => t.name !== "set_preferences")) {
+      expect(tool.annotations).toEqual({
+        readOnlyHint: true,
+        destructiveHint: false,
+        idempotentHint: true,
+        openWorldHint: false,
+      });
+    }
+    const prefs = tools.find((t) => t.name === "set_preferences")!;
+    expect(prefs.annotations?.readOnlyHint).toBe(false);
+    expect(prefs.annotations?.destructiveHint).toBe(false);
+    expect(prefs.annotations?.idempotentHint).toBe(true);
+  });
+
   test("listResources includes index-stats", async () => {
     harness = await createMcpTestClient([]);
     const { resources } = await harness.client.listResources();
@@ -401,6 +433,50 @@ describe("MCP search_documents", () => {
     const text = getToolText(result);
     expect(text).toContain("Snippet:");
   });
+
+  test("folds matches inside one class unless group is false", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const search = (args: Record<string, unknown>) =>
+      harness.client.callTool({ name: "search_documents", arguments: { query: "string", doc_id: "code:src/auth.ts", ...args } });
+
+    const grouped = await search({});
+    const results = (grouped.structuredContent as any).results;
+    expect(results.length).toBe(3);
+    const lead = results.find((r: any) => r.group);
+    expect(lead.group).toEqual({
+      symbol: "class AuthService",
+      matches: 2,
+      node_ids: [lead.node_id === "code:src/auth.ts:n1" ? "code:src/auth.ts:n2" : "code:src/auth.ts:n1"],
+    });
+    expect(getToolText(grouped)).toContain("Also in class AuthService: 1 more matching section(s)");
+
+    const flat = await search({ group: false });
+    expect((flat.structuredContent as any).results.length).toBe(4);
+  });
+
+  test("debug explains each result's score", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const result = await harness.client.callTool({
+      name: "search_documents",
+      arguments: { query: "token refresh", debug: true },
+    });
+    const [top] = (result.structuredContent as any).results;
+    expect(top.explain.bm25).toBeGreaterThan(0);
+    expect(top.explain.all_terms).toBeGreaterThan(0);
+    expect(getToolText(result)).toContain("Explain: (bm25 ");
+
+    const plain = await harness.client.callTool({ name: "search_documents", arguments: { query: "token refresh" } });
+    expect((plain.structuredContent as any).results[0].explain).toBeUndefined();
+  });
+
+  test("max_per_file caps the results from one file", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const result = await harness.client.callTool({
+      name: "search_documents",
+      arguments: { query: "string", doc_id: "code:src/auth.ts", group: false, max_per_file: 2 },
+    });
+    expect((result.structuredContent as any).results.length).toBe(2);
+  });
 });
 
 // ── get_tree ─────────────────────────────────────────────────────────
@@ -630,6 +706,55 @@ describe("MCP navigate_tree", () => {
   });
 });
 
+// ── next_symbol / previous_symbol ────────────────────────────────────
+
+describe("MCP next_symbol / previous_symbol", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  test("steps to the next sibling with its content", async () => {
+    harness = await createMcpTestClient(allDocs(), { symbolNav: true });
+    const result = await harness.client.callTool({
+      name: "next_symbol",
+      arguments: { doc_id: "docs:auth", node_id: "docs:auth:n2" },
+    });
+    const data = result.structuredContent as any;
+    expect(data.from.node_id).toBe("docs:auth:n2");
+    expect(data.symbols.map((n: any) => n.node_id)).toEqual(["docs:auth:n3"]);
+    expect(data.more).toBe(false);
+    expect(getToolText(result)).toContain("Error Handling [docs:auth:n3]");
+  });
+
+  test("a whole-file uri starts from the bottom for previous_symbol", async () => {
+    harness = await createMcpTestClient(allDocs(), { symbolNav: true });
+    const result = await harness.client.callTool({
+      name: "previous_symbol",
+      arguments: { uri: "treenav://file/guides/auth.md", scope: "file", count: 2 },
+    });
+    const data = result.structuredContent as any;
+    expect(data.from).toBeNull();
+    expect(data.symbols.map((n: any) => n.node_id)).toEqual(["docs:auth:n3", "docs:auth:n2"]);
+    expect(data.more).toBe(true);
+  });
+
+  test("unknown nodes are not_found and conflicting arguments are errors", async () => {
+    harness = await createMcpTestClient(allDocs(), { symbolNav: true });
+    const missing = await harness.client.callTool({
+      name: "next_symbol",
+      arguments: { doc_id: "docs:auth", node_id: "nonexistent" },
+    });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    const both = await harness.client.callTool({
+      name: "next_symbol",
+      arguments: { doc_id: "docs:auth", node_id: "docs:auth:n2", line: 3 },
+    });
+    expect(both.isError).toBe(true);
+  });
+});
+
 // ── find_symbol ──────────────────────────────────────────────────────
 
 describe("MCP find_symbol", () => {
@@ -698,6 +823,495 @@ describe("MCP find_symbol", () => {
   });
 });
 
+// ── set_preferences ──────────────────────────────────────────────────
+
+describe("MCP set_preferences", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  test("focus restricts list and search results to a directory", async () => {
+    harness = await createMcpTestClient(allDocs());
+    await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { focus: "guides" },
+    });
+
+    const list = getToolText(
+      await harness.client.callTool({ name: "list_documents", arguments: {} })
+    );
+    expect(list).toContain("Found 2 documents");
+    expect(list).not.toContain("docs:runbook");
+    expect(list).toContain("Session preferences: focus: guides/");
+
+    const search = getToolText(
+      await harness.client.callTool({
+        name: "search_documents",
+        arguments: { query: "authentication" },
+      })
+    );
+    expect(search).toContain("docs:auth");
+    expect(search).not.toContain("code:src/auth.ts");
+  });
+
+  test("limit preference applies when the argument is omitted", async () => {
+    harness = await createMcpTestClient(allDocs());
+    await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { limit: 1 },
+    });
+
+    const defaulted = getToolText(
+      await harness.client.callTool({ name: "list_documents", arguments: {} })
+    );
+    expect(defaulted).toContain("showing 1-1");
+
+    const explicit = getToolText(
+      await harness.client.callTool({ name: "list_documents", arguments: { limit: 3 } })
+    );
+    expect(explicit).toContain("showing 1-3");
+  });
+
+  test("languages preference filters find_symbol", async () => {
+    harness = await createMcpTestClient(allDocs());
+    await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { languages: ["python"] },
+    });
+
+    const miss = getToolText(
+      await harness.client.callTool({ name: "find_symbol", arguments: { query: "AuthService" } })
+    );
+    expect(miss).toContain("No symbols found");
+
+    const override = getToolText(
+      await harness.client.callTool({
+        name: "find_symbol",
+        arguments: { query: "AuthService", language: "typescript" },
+      })
+    );
+    expect(override).toContain("AuthService");
+  });
+
+  test("reset clears all preferences", async () => {
+    harness = await createMcpTestClient(allDocs());
+    await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { focus: "guides", limit: 1 },
+    });
+    const result = await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { reset: true },
+    });
+    expect(getToolText(result)).toContain("cleared");
+
+    const list = getToolText(
+      await harness.client.callTool({ name: "list_documents", arguments: {} })
+    );
+    expect(list).toContain("Found 4 documents");
+    expect(list).not.toContain("Session preferences");
+  });
+
+  test("empty focus clears only that preference", async () => {
+    harness = await createMcpTestClient(allDocs());
+    await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { focus: "guides", limit: 2 },
+    });
+    const result = await harness.client.callTool({
+      name: "set_preferences",
+      arguments: { focus: "" },
+    });
+    expect(getToolText(result)).toBe("Session preferences: limit: 2");
+  });
+
+  test("snapshot pins reads to a generation until pinned again", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const pinned = await harness.client.callTool({ name: "set_preferences", arguments: { snapshot: "pin" } });
+    const generation = harness.store.getGeneration();
+    expect(getToolText(pinned)).toBe(`Session preferences: snapshot: generation ${generation}`);
+    expect((pinned.structuredContent as any).snapshot).toEqual({ generation, current: generation });
+
+    harness.store.removeDocument("docs:runbook");
+    const list = await harness.client.callTool({ name: "list_documents", arguments: {} });
+    expect(getToolText(list)).toStartWith(`At index generation ${generation} (pinned; the index is at ${generation + 1}`);
+    expect(getToolText(list)).toContain("Found 4 documents");
+    expect((list.structuredContent as any).generation).toBe(generation);
+
+    await harness.client.callTool({ name: "set_preferences", arguments: { snapshot: "pin" } });
+    const moved = getToolText(await harness.client.callTool({ name: "list_documents", arguments: {} }));
+    expect(moved).toContain("Found 3 documents");
+    expect(moved).not.toContain("At index generation");
+
+    await harness.client.callTool({ name: "set_preferences", arguments: { snapshot: "release" } });
+    harness.store.removeDocument("docs:auth");
+    const live = getToolText(await harness.client.callTool({ name: "list_documents", arguments: {} }));
+    expect(live).toContain("Found 2 documents");
+    expect(live).not.toContain("Session preferences");
+  });
+});
+
+// ── Sparse index (INCLUDE) ───────────────────────────────────────────
+
+describe("MCP sparse index", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  function sparse() {
+    return new IndexCoverage({
+      collections: [{ name: "docs", root: "/repo/docs", weight: 1, include: ["guides/**"] }],
+      code_collections: [{ name: "code", root: "/repo", weight: 1, include: ["src/**"] }],
+      max_depth: 6,
+      summary_length: 200,
+    });
+  }
+
+  test("get_tree reports a doc_id outside the include set as not indexed", async () => {
+    harness = await createMcpTestClient(allDocs(), { coverage: sparse() });
+    const text = getToolText(
+      await harness.client.callTool({ name: "get_tree", arguments: { doc_id: "docs:runbooks:restart" } })
+    );
+    expect(text).toContain("is not indexed");
+    expect(text).toContain("runbooks/restart.md");
+    expect(text).toContain("guides/**");
+  });
+
+  test("an unknown doc_id inside the include set is still just not found", async () => {
+    harness = await createMcpTestClient(allDocs(), { coverage: sparse() });
+    const text = getToolText(
+      await harness.client.callTool({ name: "get_tree", arguments: { doc_id: "docs:guides:missing" } })
+    );
+    expect(text).toContain("not found");
+  });
+
+  test("a focus outside the include set yields a status instead of empty results", async () => {
+    harness = await createMcpTestClient(allDocs(), { coverage: sparse() });
+    const prefs = getToolText(
+      await harness.client.callTool({ name: "set_preferences", arguments: { focus: "vendor" } })
+    );
+    expect(prefs).toContain("Warning:");
+
+    const search = getToolText(
+      await harness.client.callTool({ name: "search_documents", arguments: { query: "token" } })
+    );
+    expect(search).toContain('"vendor/" is not indexed');
+  });
+
+  test("empty search results mention the include patterns", async () => {
+    harness = await createMcpTestClient(allDocs(), { coverage: sparse() });
+    const search = getToolText(
+      await harness.client.callTool({ name: "search_documents", arguments: { query: "xylophone" } })
+    );
+    expect(search).toContain("Sparse index: only files matching guides/**, src/** are indexed.");
+  });
+});
+
+// ── Structured output ────────────────────────────────────────────────
+
+describe("MCP structured output", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  const call = async (name: string, args: Record<string, unknown>) =>
+    (await harness.client.callTool({ name, arguments: args })).structuredContent as Record<string, any>;
+
+  test("search_documents returns results with match ranges", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = await call("search_documents", { query: "token refresh" });
+
+    expect(out.schema_version).toBe(1);
+    expect(out.status).toBe("ok");
+    expect(out.query).toBe("token refresh");
+    expect(out.results[0].doc_id).toBe("docs:auth");
+    expect(out.results[0].content_matches.length).toBeGreaterThan(0);
+    expect(out.validating).toBe(false);
+  });
+
+  test("list_documents pages the catalog", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = await call("list_documents", { limit: 2 });
+
+    expect(out.total).toBe(4);
+    expect(out.documents).toHaveLength(2);
+    expect(out.documents[0].file_path).toBeTruthy();
+  });
+
+  test("get_tree reports an unknown doc_id as not_found", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = await call("get_tree", { doc_id: "docs:nope" });
+
+    expect(out.status).toBe("not_found");
+    expect(out.message).toContain("not found");
+    expect(out.nodes).toEqual([]);
+  });
+
+  test("get_node_content lists missing node IDs", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = await call("get_node_content", { doc_id: "docs:auth", node_ids: ["docs:auth:n2", "docs:auth:n9"] });
+
+    expect(out.status).toBe("ok");
+    expect(out.nodes.map((n: any) => n.node_id)).toEqual(["docs:auth:n2"]);
+    expect(out.missing).toEqual(["docs:auth:n9"]);
+  });
+
+  test("navigate_tree returns the subtree with a word total", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = await call("navigate_tree", { doc_id: "docs:auth", node_id: "docs:auth:n1" });
+
+    expect(out.nodes).toHaveLength(3);
+    expect(out.total_words).toBe(44);
+  });
+
+  test("set_preferences echoes the session state", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = await call("set_preferences", { languages: ["Go"], limit: 5 });
+
+    expect(out.preferences).toEqual({ languages: ["go"], limit: 5 });
+  });
+
+  test("a doc_id outside the include set is not_indexed", async () => {
+    harness = await createMcpTestClient(allDocs(), {
+      coverage: new IndexCoverage({
+        collections: [{ name: "docs", root: "/repo/docs", weight: 1, include: ["guides/**"] }],
+        max_depth: 6,
+        summary_length: 200,
+      }),
+    });
+    const out = await call("get_tree", { doc_id: "docs:runbooks:restart" });
+
+    expect(out.status).toBe("not_indexed");
+  });
+});
+
+// ── multi_search ─────────────────────────────────────────────────────
+
+describe("MCP multi_search", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  const multiSearch = async (args: Record<string, unknown>) =>
+    (await harness.client.callTool({ name: "multi_search", arguments: args })) as any;
+
+  test("groups results by query, in request order", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const res = await multiSearch({ queries: ["JWT refresh", "database restart", "xylophone"] });
+    const out = res.structuredContent;
+
+    expect(out.groups.map((g: any) => g.query)).toEqual(["JWT refresh", "database restart", "xylophone"]);
+    expect(out.groups[0].results[0].doc_id).toBe("docs:auth");
+    expect(out.groups[1].results[0].doc_id).toBe("docs:runbook");
+    expect(out.groups[2].results).toEqual([]);
+
+    const text = getToolText(res);
+    expect(text).toContain('1/3: "JWT refresh"');
+    expect(text).toContain('3/3: "xylophone" (0 matches)');
+    expect(text).not.toContain("Full content");
+  });
+
+  test("applies limit and filters to every query", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const out = (await multiSearch({ queries: ["token", "secret"], limit: 1, filters: { content_type: "code" } }))
+      .structuredContent;
+
+    for (const group of out.groups) {
+      expect(group.results).toHaveLength(1);
+      expect(group.results[0].doc_id).toBe("code:src/auth.ts");
+    }
+  });
+
+  test("rejects more queries than the batch limit", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const res = await multiSearch({ queries: Array.from({ length: 11 }, (_, i) => `q${i}`) });
+
+    expect(res.isError).toBe(true);
+  });
+});
+
+// ── Symbol disambiguation ────────────────────────────────────────────
+
+describe("MCP find_symbol disambiguation", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  // A second AuthService, identical to the one in src/auth.ts
+  function legacyAuthDoc() {
+    const original = codeDoc().tree[0];
+    return makeDoc({
+      meta: {
+        doc_id: "code:legacy/auth.ts",
+        file_path: "legacy/auth.ts",
+        title: "legacy/auth.ts",
+        description: "",
+        tags: [],
+        collection: "code",
+        facets: { content_type: ["code"], language: ["typescript"], symbol_kind: ["class"] },
+      },
+      tree: [makeNode({ ...original, node_id: "code:legacy/auth.ts:n1", children: [] })],
+    });
+  }
+
+  const findSymbol = async (query: string) =>
+    (await harness.client.callTool({ name: "find_symbol", arguments: { query } })) as any;
+
+  test("asks the user to pick between equally plausible symbols", async () => {
+    const asked: any[] = [];
+    harness = await createMcpTestClient([codeDoc(), legacyAuthDoc()], {
+      elicit: (params) => {
+        asked.push(params);
+        return { action: "accept", content: { symbol: "code:legacy/auth.ts:n1" } };
+      },
+    });
+    const res = await findSymbol("AuthService");
+
+    expect(asked).toHaveLength(1);
+    expect(asked[0].requestedSchema.properties.symbol.enum.sort()).toEqual([
+      "code:legacy/auth.ts:n1",
+      "code:src/auth.ts:n1",
+    ]);
+    expect(res.structuredContent.results.map((r: any) => r.node_id)).toEqual(["code:legacy/auth.ts:n1"]);
+    expect(res.structuredContent.disambiguation).toEqual({
+      candidates: 2,
+      action: "accept",
+      chosen: "code:legacy/auth.ts:n1",
+    });
+    expect(getToolText(res)).toContain("picked by the user from 2 candidates");
+  });
+
+  test("keeps every match when the user declines", async () => {
+    harness = await createMcpTestClient([codeDoc(), legacyAuthDoc()], {
+      elicit: () => ({ action: "decline" }),
+    });
+    const res = await findSymbol("AuthService");
+
+    expect(res.structuredContent.results.length).toBeGreaterThanOrEqual(2);
+    expect(res.structuredContent.disambiguation).toEqual({ candidates: 2, action: "decline" });
+  });
+
+  test("does not ask clients without elicitation support", async () => {
+    harness = await createMcpTestClient([codeDoc(), legacyAuthDoc()]);
+    const res = await findSymbol("AuthService");
+
+    expect(res.structuredContent.results.length).toBeGreaterThanOrEqual(2);
+    expect(res.structuredContent.disambiguation).toBeUndefined();
+  });
+
+  test("does not ask when one symbol clearly wins", async () => {
+    let asked = 0;
+    harness = await createMcpTestClient([codeDoc(), legacyAuthDoc()], {
+      elicit: () => {
+        asked++;
+        return { action: "cancel" };
+      },
+    });
+    const res = await findSymbol("validateToken");
+
+    expect(asked).toBe(0);
+    expect(res.structuredContent.results[0].node_id).toBe("code:src/auth.ts:n3");
+  });
+});
+
+// ── Argument completion ──────────────────────────────────────────────
+
+describe("MCP argument completion", () => {
+  let harness: McpTestHarness;
+
+  afterEach(async () => {
+    if (harness) await harness.cleanup();
+  });
+
+  const complete = async (uri: string, name: string, value: string, context?: Record<string, string>) =>
+    (
+      await harness.client.complete({
+        ref: { type: "ref/resource", uri },
+        argument: { name, value },
+        ...(context ? { context: { arguments: context } } : {}),
+      })
+    ).completion.values;
+
+  test("lists a template per completable argument", async () => {
+    harness = await createMcpTestClient(allDocs());
+    const { resourceTemplates } = await harness.client.listResourceTemplates();
+
+    expect(resourceTemplates.map((t) => t.uriTemplate).sort()).toEqual([
+      "md-tree://doc/{+doc_id}",
+      "md-tree://doc/{+doc_id}/node/{+node_id}",
+      "md-tree://file/{+path}",
+      "md-tree://symbol/{name}",
+    ]);
+  });
+
+  test("completes doc IDs and node IDs within a document", async () => {
+    harness = await createMcpTestClient(allDocs());
+
+    expect(await complete("md-tree://doc/{+doc_id}", "doc_id", "docs:")).toEqual([
+      "docs:auth",
+      "docs:deploy",
+      "docs:runbook",
+    ]);
+    expect(
+      await complete("md-tree://doc/{+doc_id}/node/{+node_id}", "node_id", "docs:auth:n", { doc_id: "docs:auth" })
+    ).toEqual(["docs:auth:n1", "docs:auth:n2", "docs:auth:n3"]);
+  });
+
+  test("completes file paths a directory at a time", async () => {
+    harness = await createMcpTestClient(allDocs());
+
+    expect(await complete("md-tree://file/{+path}", "path", "")).toEqual(["guides/", "runbooks/", "src/"]);
+    expect(await complete("md-tree://file/{+path}", "path", "guides/")).toEqual(["guides/auth.md", "guides/deploy.md"]);
+  });
+
+  test("completes symbol names from code", async () => {
+    harness = await createMcpTestClient(allDocs());
+
+    expect(await complete("md-tree://symbol/{name}", "name", "auth")).toEqual([
+      "AuthConfig",
+      "AuthService",
+      "authenticate",
+    ]);
+  });
+
+  test("completed values resolve when read", async () => {
+    harness = await createMcpTestClient(allDocs());
+
+    const file = await harness.client.readResource({ uri: "md-tree://file/guides/auth.md" });
+    expect(JSON.parse(file.contents[0].text as string).doc_id).toBe("docs:auth");
+
+    const section = await harness.client.readResource({ uri: "md-tree://doc/docs:auth/node/docs:auth:n1" });
+    expect(section.contents[0].text).toContain("JWT tokens");
+
+    const code = await harness.client.readResource({ uri: "md-tree://doc/code:src/auth.ts/node/code:src/auth.ts:n3" });
+    expect(code.contents[0].text).toContain("validateToken");
+    const outline = await harness.client.readResource({ uri: "md-tree://doc/code:src/auth.ts" });
+    expect(JSON.parse(outline.contents[0].text as string).nodes).toHaveLength(4);
+
+    const symbol = await harness.client.readResource({ uri: "md-tree://symbol/AuthService" });
+    expect(JSON.parse(symbol.contents[0].text as string).definitions).toEqual([
+      {
+        doc_id: "code:src/auth.ts",
+        node_id: "code:src/auth.ts:n1",
+        title: "class AuthService",
+        file_path: "src/auth.ts",
+        line_start: 1,
+        uri: "treenav://file/src/auth.ts#L1-10",
+      },
+    ]);
+  });
+});
+
 // ── index-stats resource ─────────────────────────────────────────────
 
 describe("MCP index-stats resource", () => {
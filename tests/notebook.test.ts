@@ -0,0 +1,88 @@
+/**
+ * Tests for Jupyter notebook indexing: reading cells, cell nodes and
+ * their symbols, skipped outputs, and cell locations in results.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { readNotebook } from "../src/parsers/notebook";
+import { indexCodeContent, isCodeFile } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import { buildMatchLine } from "../src/search-formatter";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const NOTEBOOK = JSON.stringify({
+  nbformat: 4,
+  nbformat_minor: 5,
+  metadata: { kernelspec: { name: "python3", language: "python", display_name: "Python 3" } },
+  cells: [
+    { cell_type: "markdown", metadata: {}, source: ["# Churn analysis\n", "\n", "Loads the events table."] },
+    { cell_type: "code", metadata: {}, execution_count: 1, source: ["import pandas as pd\n", "events = pd.read_csv('events.csv')"], outputs: [] },
+    { cell_type: "markdown", metadata: {}, source: "## Features" },
+    {
+      cell_type: "code",
+      metadata: {},
+      execution_count: 2,
+      source: ["def churn_features(events):\n", "    \"\"\"Per-user churn features.\"\"\"\n", "    return events.groupby('user').agg(last_seen=('ts', 'max'))\n"],
+      outputs: [{ output_type: "stream", name: "stdout", text: ["retention_blob_marker 0.93\n"] }],
+    },
+    { cell_type: "code", metadata: {}, execution_count: null, source: [], outputs: [] },
+    { cell_type: "raw", metadata: {}, source: "raw text" },
+  ],
+});
+
+const TIME = "2026-01-01T00:00:00.000Z";
+
+describe("readNotebook", () => {
+  test("keeps non-empty code cells with their index and heading", () => {
+    const nb = readNotebook(NOTEBOOK)!;
+    expect(nb.language).toBe("python");
+    expect(nb.cells.map((c) => [c.index, c.heading])).toEqual([
+      [2, "Churn analysis"],
+      [4, "Features"],
+    ]);
+    expect(nb.cells[0].source).toBe("import pandas as pd\nevents = pd.read_csv('events.csv')");
+  });
+
+  test("rejects files that are not notebook JSON", () => {
+    expect(readNotebook("not json")).toBeNull();
+    expect(readNotebook('{"cells": 3}')).toBeNull();
+  });
+});
+
+describe("notebook indexing", () => {
+  test("indexes code cells as nodes with their symbols, without outputs", () => {
+    expect(isCodeFile("analysis/churn.ipynb")).toBe(true);
+    const doc = indexCodeContent(NOTEBOOK, "analysis/churn.ipynb", "code", TIME);
+    expect(doc.meta.doc_id).toBe("code:analysis:churn_ipynb");
+    expect(doc.meta.facets.content_type).toEqual(["notebook"]);
+    expect(doc.meta.facets.language).toEqual(["python"]);
+    expect(doc.root_nodes).toEqual(["code:analysis:churn_ipynb:cell2", "code:analysis:churn_ipynb:cell4"]);
+
+    const cell = doc.tree.find((n) => n.node_id === "code:analysis:churn_ipynb:cell4")!;
+    expect([cell.title, cell.level, cell.cell, cell.line_start, cell.line_end]).toEqual(["cell 4: Features", 1, 4, 1, 4]);
+    const fn = doc.tree.find((n) => n.title === "function churn_features")!;
+    expect([fn.parent_id, fn.level, fn.cell, fn.line_start]).toEqual([cell.node_id, 2, 4, 1]);
+    expect(cell.children).toContain(fn.node_id);
+
+    expect(doc.tree.some((n) => n.content.includes("retention_blob_marker"))).toBe(false);
+  });
+
+  test("search results locate matches by cell", () => {
+    const store = new DocumentStore();
+    store.load([indexCodeContent(NOTEBOOK, "churn.ipynb", "code", TIME)]);
+    const [hit] = store.searchDocuments("last_seen");
+    expect(hit.cell).toBe(4);
+    expect(buildMatchLine(hit)).toContain("churn.ipynb#cell4:3:");
+    expect(store.searchDocuments("retention_blob_marker")).toEqual([]);
+  });
+
+  test("find_symbol finds definitions in notebook cells", async () => {
+    const harness = await createMcpTestClient([indexCodeContent(NOTEBOOK, "churn.ipynb", "code", TIME)]);
+    const result = await harness.client.callTool({ name: "find_symbol", arguments: { query: "churn_features" } });
+    const data = result.structuredContent as any;
+    expect(data.results[0].node_title).toBe("function churn_features");
+    expect(data.results[0].cell).toBe(4);
+    expect(getToolText(result as any)).toContain("File: churn.ipynb (cell 4)");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,88 @@
+/**
+ * Tests for the content-addressed parse cache: hits across paths and
+ * collections, ids rebased onto the new document, keys that include
+ * the parser, damaged entries, and write failures.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, readdir, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ParseCache, setParseCache } from "../src/parse-cache";
+import { indexCodeContent, parseCodeSymbols } from "../src/code-indexer";
+
+const SOURCE = `package pool
+
+type Pool struct {
+	size int
+}
+
+func (p *Pool) Acquire() error {
+	return nil
+}
+`;
+
+let dir: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-parse-cache-"));
+});
+
+afterEach(async () => {
+  setParseCache(null);
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("ParseCache", () => {
+  test("the same content under another path reuses the parse, with its own ids", () => {
+    const cache = new ParseCache(join(dir, "cache"));
+    setParseCache(cache);
+    const first = indexCodeContent(SOURCE, "pool/pool.go", "code", "2026-01-01T00:00:00.000Z");
+    const moved = indexCodeContent(SOURCE, "internal/pool/pool.go", "other", "2026-01-01T00:00:00.000Z");
+    expect(cache.stats()).toEqual({ hits: 1, misses: 1 });
+
+    setParseCache(null);
+    const fresh = indexCodeContent(SOURCE, "internal/pool/pool.go", "other", "2026-01-01T00:00:00.000Z");
+    expect(moved.tree).toEqual(fresh.tree);
+    expect(moved.root_nodes).toEqual(fresh.root_nodes);
+    expect(moved.tree[0].node_id.startsWith("other:internal:pool:pool_go:")).toBe(true);
+    expect(first.tree.length).toBe(fresh.tree.length);
+  });
+
+  test("keys include the parser, and changed content misses", () => {
+    const cache = new ParseCache(dir);
+    cache.set(".go", SOURCE, "source", parseCodeSymbols(SOURCE, "x.go"));
+    expect(cache.get(".go", SOURCE, "e")?.[0].id.startsWith("e:")).toBe(true);
+    expect(cache.get(".py", SOURCE, "e")).toBeNull();
+    expect(cache.get(".go", SOURCE + "\n", "e")).toBeNull();
+  });
+
+  test("symbols outside the document are not cached", () => {
+    const cache = new ParseCache(dir);
+    cache.set(".go", SOURCE, "d", parseCodeSymbols(SOURCE, "x.go"));
+    expect(cache.get(".go", SOURCE, "d")).toBeNull();
+  });
+
+  test("a damaged entry is a miss and is replaced", async () => {
+    const cache = new ParseCache(dir);
+    setParseCache(cache);
+    indexCodeContent(SOURCE, "pool.go", "code", "2026-01-01T00:00:00.000Z");
+    const [shard] = await readdir(dir);
+    const [entry] = await readdir(join(dir, shard));
+    await writeFile(join(dir, shard, entry), "{ not json");
+    indexCodeContent(SOURCE, "pool.go", "code", "2026-01-01T00:00:00.000Z");
+    indexCodeContent(SOURCE, "pool.go", "code", "2026-01-01T00:00:00.000Z");
+    expect(cache.stats()).toEqual({ hits: 1, misses: 2 });
+  });
+
+  test("a failed write is logged once, and parsing goes on", async () => {
+    await writeFile(join(dir, "file"), "not a directory");
+    const warnings: string[] = [];
+    setParseCache(new ParseCache(join(dir, "file"), { log: (msg) => warnings.push(msg) }));
+    const a = indexCodeContent(SOURCE, "a.go", "code", "2026-01-01T00:00:00.000Z");
+    indexCodeContent(SOURCE.replace("Pool", "Queue"), "b.go", "code", "2026-01-01T00:00:00.000Z");
+    expect(a.tree.length).toBeGreaterThan(1);
+    expect(warnings.length).toBe(1);
+    expect(warnings[0]).toContain("could not be written");
+  });
+});
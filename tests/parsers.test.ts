@@ -14,6 +14,7 @@ import { parseGeneric } from "../src/parsers/generic";
 import { parseJava } from "../src/parsers/java";
 import { parseGo } from "../src/parsers/go";
 import { parseRust } from "../src/parsers/rust";
+import { countWords, sourceLines } from "../src/parsers/lines";
 import type { CodeSymbol } from "../src/code-indexer";
 
 import {
@@ -1391,3 +1392,38 @@ impl Config {
     expect(config.children_ids).toContain(newFn.id);
   });
 });
+
+// ════════════════════════════════════════════════════════════════════
+// Source lines (lines.ts)
+// ════════════════════════════════════════════════════════════════════
+
+describe("sourceLines", () => {
+  const SOURCE = "package main\n\nfunc main() {\n\tprintln(1)\n}\n";
+
+  test("block cuts the same text as slice and join", () => {
+    const lines = sourceLines(SOURCE);
+    const split = SOURCE.split("\n");
+    expect([...lines]).toEqual(split);
+    for (const [from, to] of [[0, 0], [2, 4], [0, 5], [3, 99], [4, 3], [5, 5]]) {
+      expect(lines.block(from, to)).toBe(split.slice(from, to + 1).join("\n"));
+    }
+  });
+
+  test("stays valid while other sources are split", () => {
+    const first = sourceLines(SOURCE);
+    const second = sourceLines("a\nb");
+    // Longer than the first, so a shared table would have been regrown and overwritten
+    const third = sourceLines("x\n".repeat(10_000));
+    expect(second.block(0, 1)).toBe("a\nb");
+    expect(first.block(2, 4)).toBe("func main() {\n\tprintln(1)\n}");
+    expect(third.block(9_999, 10_000)).toBe("x\n");
+  });
+});
+
+describe("countWords", () => {
+  test("counts as splitting on whitespace does", () => {
+    for (const text of ["", "   ", "one", " two  words ", "tabs\tand\nnewlines\r\n", "nbsp\u00a0ideographic\u3000space", "é ü"]) {
+      expect(countWords(text)).toBe(text.split(/\s+/).filter(Boolean).length);
+    }
+  });
+});
@@ -0,0 +1,134 @@
+/**
+ * Tests for path spellings: the Windows rules run through path.win32 on
+ * every platform, the case probe on the real filesystem, and the store
+ * and tools matching paths from input the way an agent on Windows
+ * writes them.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { existsSync } from "node:fs";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join, posix, win32 } from "node:path";
+import { tmpdir } from "node:os";
+import { caseInsensitive, isAbsolutePath, isWithin, relativePath, rootPath, toolPath } from "../src/paths";
+import { DocumentStore } from "../src/store";
+import { createMcpTestClient, makeDoc } from "./fixtures/helpers";
+
+describe("Windows paths", () => {
+  test("relativePath gives \"/\"-separated paths under drive and UNC roots", () => {
+    expect(relativePath("C:\\repo", "C:\\repo\\src\\db\\conn.go", win32)).toBe("src/db/conn.go");
+    expect(relativePath("\\\\build01\\share\\repo", "\\\\build01\\share\\repo\\docs\\a.md", win32)).toBe("docs/a.md");
+    expect(relativePath("C:\\repo", "C:\\repo", win32)).toBe("");
+    expect(relativePath("/repo", "/repo/src/conn.go", posix)).toBe("src/conn.go");
+  });
+
+  test("isWithin holds for drive roots and ignores case, but not across drives or shares", () => {
+    expect(isWithin("C:\\", "C:\\repo\\a.md", win32)).toBe(true);
+    expect(isWithin("C:\\Repo", "c:\\repo\\SRC\\a.go", win32)).toBe(true);
+    expect(isWithin("C:\\repo", "D:\\repo\\a.go", win32)).toBe(false);
+    expect(isWithin("C:\\repo", "C:\\repo-old\\a.go", win32)).toBe(false);
+    expect(isWithin("\\\\build01\\share", "\\\\build02\\share\\a.go", win32)).toBe(false);
+    expect(isWithin("/", "/repo/a.md", posix)).toBe(true);
+    expect(isWithin("/repo", "/repo/..x/a.md", posix)).toBe(true);
+    expect(isWithin("/Repo", "/repo/a.md", posix)).toBe(false);
+  });
+
+  test("rootPath drops long-path prefixes", () => {
+    expect(rootPath("\\\\?\\C:\\repo\\docs", win32)).toBe("C:\\repo\\docs");
+    expect(rootPath("\\\\?\\UNC\\build01\\share\\docs", win32)).toBe("\\\\build01\\share\\docs");
+    expect(rootPath("C:/repo/docs/", win32)).toBe("C:\\repo\\docs");
+  });
+
+  test("isAbsolutePath knows every platform's absolute paths", () => {
+    for (const abs of ["/etc/passwd", "\\Windows", "C:\\repo", "c:/repo", "\\\\build01\\share", "//build01/share"]) {
+      expect(isAbsolutePath(abs)).toBe(true);
+    }
+    for (const rel of ["src/db", "src\\db", "C:relative", "docs:guides:auth", "./x"]) {
+      expect(isAbsolutePath(rel)).toBe(false);
+    }
+  });
+
+  test("toolPath spells tool input as the index does", () => {
+    expect(toolPath("src\\db\\conn.go")).toBe("src/db/conn.go");
+    expect(toolPath(".\\src\\\\db\\.\\conn.go")).toBe("src/db/conn.go");
+    expect(toolPath("internal\\cluster\\")).toBe("internal/cluster/");
+    expect(toolPath(".")).toBe(".");
+    expect(toolPath("docs:guides:auth")).toBe("docs:guides:auth");
+    expect(toolPath("**/*_test.go")).toBe("**/*_test.go");
+
+    const roots = ["C:\\repo", "C:\\repo\\docs"];
+    expect(toolPath("C:\\repo\\docs\\guides\\auth.md", roots, win32)).toBe("guides/auth.md");
+    expect(toolPath("c:/REPO/src/db/conn.go", roots, win32)).toBe("src/db/conn.go");
+    expect(toolPath("C:\\repo", roots, win32)).toBe(".");
+    expect(toolPath("D:\\elsewhere\\a.go", roots, win32)).toBe("D:/elsewhere/a.go");
+  });
+});
+
+describe("caseInsensitive", () => {
+  let dir: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-paths-"));
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("probes the filesystem of the directory", async () => {
+    // Whatever the platform: true exactly when the flipped spelling finds the directory
+    const flipped = dir.replace(/\p{L}/gu, (c) => (c === c.toLowerCase() ? c.toUpperCase() : c.toLowerCase()));
+    expect(await caseInsensitive(dir)).toBe(existsSync(flipped));
+    expect(await caseInsensitive(join(dir, "missing"))).toBe(false);
+  });
+});
+
+describe("DocumentStore path matching", () => {
+  const docs = () => [
+    makeDoc({ meta: { doc_id: "code:src:db:Conn_go", collection: "code", file_path: "src/db/Conn.go" } }),
+    makeDoc({ meta: { doc_id: "code:README", collection: "code", file_path: "README.md" } }),
+  ];
+
+  test("takes backslashes and absolute paths under a root", () => {
+    const store = new DocumentStore();
+    store.load(docs());
+    store.setPathMatching({ roots: [process.platform === "win32" ? "C:\\repo" : "/repo"], caseInsensitive: false });
+    const abs = process.platform === "win32" ? "C:\\repo\\src\\db\\Conn.go" : "/repo/src/db/Conn.go";
+    expect(store.documentsAtPath("src\\db\\Conn.go").map((d) => d.doc_id)).toEqual(["code:src:db:Conn_go"]);
+    expect(store.documentsAtPath(abs).map((d) => d.doc_id)).toEqual(["code:src:db:Conn_go"]);
+    expect(store.indexPath(".\\src\\db\\")).toBe("src/db/");
+    // Case matters unless the filesystem ignores it
+    expect(store.documentsAtPath("src/DB/conn.go")).toEqual([]);
+    expect(store.indexPath("SRC/")).toBe("SRC/");
+  });
+
+  test("folds case on a case-insensitive filesystem, answering in the indexed case", () => {
+    const store = new DocumentStore();
+    store.load(docs());
+    store.setPathMatching({ roots: [], caseInsensitive: true });
+    expect(store.documentsAtPath("SRC\\DB\\conn.GO").map((d) => d.doc_id)).toEqual(["code:src:db:Conn_go"]);
+    expect(store.indexPath("SRC/db/")).toBe("src/db/");
+    expect(store.indexPath("readme.MD")).toBe("README.md");
+    expect(store.indexPath("code:README")).toBe("code:README");
+    expect(store.indexPath("docs/missing.md")).toBe("docs/missing.md");
+  });
+});
+
+describe("tool path arguments", () => {
+  test("reach the tools in the index's spelling", async () => {
+    const harness = await createMcpTestClient([
+      makeDoc({ meta: { doc_id: "docs:guides:auth", file_path: "guides/auth.md" } }),
+      makeDoc({ meta: { doc_id: "docs:intro", file_path: "intro.md" } }),
+    ]);
+    harness.store.setPathMatching({ roots: [], caseInsensitive: true });
+
+    const tree = await harness.client.callTool({ name: "get_tree", arguments: { uri: "treenav://file/Guides\\Auth.md" } });
+    expect(tree.isError).toBeFalsy();
+    expect((tree.structuredContent as any).doc_id).toBe("docs:guides:auth");
+
+    await harness.client.callTool({ name: "set_preferences", arguments: { focus: ".\\GUIDES\\" } });
+    const listed = await harness.client.callTool({ name: "list_documents", arguments: {} });
+    expect((listed.structuredContent as any).documents.map((d: any) => d.doc_id)).toEqual(["docs:guides:auth"]);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,140 @@
+/**
+ * Tests for peek_definitions: names resolved exactly and with
+ * Parent.name, doc comments in each comment style, body excerpts,
+ * and the tool's batch answer.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DefinitionPeek, docCommentAbove } from "../src/peek";
+import { indexAllCollections } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, writeTree } from "./fixtures/tree";
+
+const POOL = `package pool
+
+// Pool hands out connections.
+//
+// The zero value is ready to use.
+type Pool struct {
+	idle []*Conn
+}
+
+// Put returns c to the pool.
+//go:noinline
+func (p *Pool) Put(c *Conn) {
+	p.idle = append(p.idle, c)
+}
+
+func dialBackoff(attempt int) time.Duration {
+	d := time.Duration(attempt) * time.Second
+	if d > time.Minute {
+		d = time.Minute
+	}
+	return d
+}
+`;
+
+const CACHE = `class Cache:
+    def put(self, key, value):
+        """Store value under key.
+
+        Replaces any earlier value.
+        """
+        self.items[key] = value
+`;
+
+const RETRY = `/**
+ * Retries fn with exponential backoff.
+ * @param attempts how often to try
+ */
+export function retry(fn: () => void, attempts: number): void {
+  for (let i = 0; i < attempts; i++) fn();
+}
+`;
+
+let dir: string;
+let config: IndexConfig;
+let store: DocumentStore;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-peek-"));
+  await writeTree(dir, {
+    "pool/pool.go": POOL,
+    "cache.py": CACHE,
+    "retry.ts": RETRY,
+  });
+  config = codeConfig(dir);
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("docCommentAbove", () => {
+  test("reads line comments, blocks, and skips decorators and directives", () => {
+    expect(docCommentAbove(["// One.", "// Two.", "func f() {}"], 2)).toBe("One.\nTwo.");
+    expect(docCommentAbove(["/**", " * Block.", " */", "@Override", "void f() {}"], 4)).toBe("Block.");
+    expect(docCommentAbove(["/// Rust doc.", "#[inline]", "fn f() {}"], 2)).toBe("Rust doc.");
+    expect(docCommentAbove(["# Python comment", "@cache", "def f():"], 2)).toBe("Python comment");
+    expect(docCommentAbove(["x := 1", "func f() {}"], 1)).toBeUndefined();
+    expect(docCommentAbove(["func f() {}"], 0)).toBeUndefined();
+  });
+});
+
+describe("DefinitionPeek", () => {
+  test("resolves each name with its signature, doc, and excerpt", async () => {
+    const [type, method, fn, missing] = await new DefinitionPeek(config).peek(store, ["Pool", "Pool.Put", "dialBackoff", "Nowhere"], {
+      body_lines: 2,
+    });
+    expect(type.definitions[0].doc).toBe("Pool hands out connections.\n\nThe zero value is ready to use.");
+    expect(method.definitions.map((d) => [d.title, d.signature, d.doc])).toEqual([
+      ["method Put", "func (p *Pool) Put(c *Conn)", "Put returns c to the pool."],
+    ]);
+    expect(fn.definitions[0].excerpt).toBe("func dialBackoff(attempt int) time.Duration {\n\td := time.Duration(attempt) * time.Second");
+    expect([fn.definitions[0].excerpt_truncated, fn.definitions[0].doc]).toEqual([true, undefined]);
+    expect(missing.definitions).toEqual([]);
+  });
+
+  test("Python docstrings and JSDoc blocks", async () => {
+    const [put, retry] = await new DefinitionPeek(config).peek(store, ["put", "retry"]);
+    expect(put.definitions[0].doc).toBe("Store value under key.\n\nReplaces any earlier value.");
+    expect(retry.definitions[0].doc).toBe("Retries fn with exponential backoff.\n@param attempts how often to try");
+    expect(retry.definitions[0].excerpt_truncated).toBe(false);
+  });
+
+  test("drops duplicates, requires the exact name, and honors per_name and language", async () => {
+    const peek = new DefinitionPeek(config);
+    expect((await peek.peek(store, ["Pool", " Pool", "pool"])).map((r) => [r.name, r.definitions.length])).toEqual([
+      ["Pool", 1],
+      ["pool", 0],
+    ]);
+    expect((await peek.peek(store, ["Cache.Put", "Pool.put"]))[0].definitions).toEqual([]);
+    expect((await peek.peek(store, ["retry"], { languages: "go" }))[0].definitions).toEqual([]);
+  });
+});
+
+describe("peek_definitions tool", () => {
+  test("answers every name in one call", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { peek: new DefinitionPeek(config) });
+    const result = await harness.client.callTool({ name: "peek_definitions", arguments: { names: ["Pool.Put", "Missing"], body_lines: 1 } });
+    const data = result.structuredContent as any;
+    expect(data.missing).toEqual(["Missing"]);
+    expect(data.results[0].definitions[0].uri).toContain("pool/pool.go#L12-14");
+    const text = getToolText(result as any);
+    expect(text).toContain("1 of 2 name(s) resolved.");
+    expect(text).toContain("method Put — pool/pool.go:12-14");
+    expect(text).toContain("  │ Put returns c to the pool.\n    func (p *Pool) Put(c *Conn) {\n    … (3 lines in all)");
+    expect(text).toContain("━━━ Missing ━━━\nNo definition found.");
+
+    const none = await harness.client.callTool({ name: "peek_definitions", arguments: { names: ["Missing"] } });
+    expect((none.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
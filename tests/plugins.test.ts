@@ -0,0 +1,128 @@
+/**
+ * Tests for plugin tools: loading and validating PLUGINS modules,
+ * parameter schemas, and plugin calls through MCP against the index.
+ */
+
+import { describe, test, expect, beforeAll, afterAll } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { indexAllCollections } from "../src/indexer";
+import { resolveConfig, toIndexConfig } from "../src/config";
+import { loadPlugins, pluginInputSchema, pluginProblem, type PluginHost } from "../src/plugins";
+import { DocumentStore } from "../src/store";
+import { TOOL_NAMES } from "../src/tools/registry";
+import type { IndexConfig, IndexedDocument } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const EXAMPLE = join(import.meta.dir, "fixtures", "plugins", "feature-flags.ts");
+
+let dir: string;
+let config: IndexConfig;
+let docs: IndexedDocument[];
+let logs: string[];
+let host: PluginHost;
+
+beforeAll(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-plugins-"));
+  await mkdir(join(dir, "docs"), { recursive: true });
+  await mkdir(join(dir, "src"), { recursive: true });
+  await writeFile(join(dir, "docs", "flags.md"), "# Flags\n\nThe new checkout flow is behind a flag.\n");
+  await writeFile(
+    join(dir, "src", "checkout.ts"),
+    'export function checkout(cart: Cart) {\n  if (isEnabled("new-checkout")) return newCheckout(cart);\n  return legacyCheckout(cart);\n}\n'
+  );
+  await writeFile(join(dir, "broken.ts"), "export default {\n");
+  await writeFile(join(dir, "nameless.ts"), "export default { description: 'no name', run: () => '' };\n");
+
+  config = toIndexConfig(resolveConfig({ file: { docs_root: join(dir, "docs"), code_root: join(dir, "src") } }).config);
+  docs = await indexAllCollections(config);
+  logs = [];
+  host = await loadPlugins([EXAMPLE, join(dir, "broken.ts"), join(dir, "nameless.ts")], config, {
+    reserved: TOOL_NAMES,
+    log: (msg) => logs.push(msg),
+  });
+});
+
+afterAll(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("loadPlugins", () => {
+  test("loads valid tools and skips broken modules, bad definitions, and taken names", () => {
+    expect(host.tools.map((t) => t.name)).toEqual(["find_feature_flags"]);
+    expect(logs.some((m) => m.startsWith(`Warning: plugin ${join(dir, "broken.ts")} failed to load`))).toBe(true);
+    expect(logs).toContain(`Warning: plugin ${EXAMPLE}: tool name "search_documents" is already taken; skipped`);
+    expect(logs).toContain(`Warning: plugin ${join(dir, "nameless.ts")}: invalid tool name undefined (snake_case, starting with a letter); skipped`);
+    expect(logs).toContain("Plugins: find_feature_flags");
+  });
+
+  test("checks parameter types", () => {
+    const tool = { name: "x", description: "d", run: () => "", params: { n: { type: "int", description: "n" } } };
+    expect(pluginProblem(tool, new Set())).toBe('x: param "n" has an unknown type "int"');
+  });
+
+  test("checks annotations", () => {
+    const tool = { name: "x", description: "d", run: () => "" };
+    expect(pluginProblem({ ...tool, annotations: { readOnlyHint: true } }, new Set())).toBeNull();
+    expect(pluginProblem({ ...tool, annotations: { readOnlyHint: "yes" } }, new Set())).toBe('x: annotation "readOnlyHint" must be true or false');
+    expect(pluginProblem({ ...tool, annotations: { safe: true } }, new Set())).toBe('x: unknown annotation "safe"');
+  });
+
+  test("gives a plugin the index's query methods only, not the store", () => {
+    const store = new DocumentStore();
+    store.load(docs);
+    const { index } = host.context(store);
+    expect(Object.keys(index).sort()).toEqual(["getDocMeta", "getNodeContent", "getStats", "getTree", "listDocuments", "searchDocuments"]);
+    expect([(index as any).load, (index as any).setPathBoosts, (index as any).setRescorer]).toEqual([undefined, undefined, undefined]);
+    expect(Object.isFrozen(index)).toBe(true);
+    expect(index.getStats().document_count).toBe(docs.length);
+  });
+
+  test("builds the input schema from params", () => {
+    const shape = pluginInputSchema({
+      flag: { type: "string", description: "Flag key" },
+      limit: { type: "number", description: "Max", default: 20 },
+      tags: { type: "string[]", description: "Tags", optional: true },
+    });
+    expect(shape.flag.parse("a")).toBe("a");
+    expect(shape.limit.parse(undefined)).toBe(20);
+    expect(shape.tags.parse(undefined)).toBeUndefined();
+    expect(shape.flag.safeParse(3).success).toBe(false);
+  });
+});
+
+describe("plugin tools over MCP", () => {
+  test("answer from the shared index with structured data", async () => {
+    const harness = await createMcpTestClient(docs, { plugins: host });
+    const result = await harness.client.callTool({ name: "find_feature_flags", arguments: { flag: "new-checkout" } });
+    expect(getToolText(result as any)).toBe("checkout.ts");
+    const data = result.structuredContent as any;
+    expect([data.status, data.plugin, data.data]).toEqual(["ok", "find_feature_flags", { files: ["checkout.ts"] }]);
+
+    const missing = await harness.client.callTool({ name: "find_feature_flags", arguments: { flag: "does-not-exist" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+
+  test("advertise the hints a plugin declares, and cautious ones for the rest", async () => {
+    const undeclared = await loadPlugins([], config, { reserved: TOOL_NAMES, log: () => {} });
+    undeclared.tools.push(...host.tools, { name: "sync_flags", description: "Pushes flags", run: () => "" });
+    const harness = await createMcpTestClient(docs, { plugins: undeclared });
+    const { tools } = await harness.client.listTools();
+    const hints = (name: string) => tools.find((t) => t.name === name)!.annotations;
+    expect(hints("find_feature_flags")).toEqual({ readOnlyHint: true, destructiveHint: false, idempotentHint: true, openWorldHint: true });
+    expect(hints("sync_flags")).toEqual({ readOnlyHint: false, destructiveHint: true, idempotentHint: false, openWorldHint: true });
+    await harness.cleanup();
+  });
+
+  test("a plugin that throws fails the call only", async () => {
+    const failing = await loadPlugins([], config, { reserved: TOOL_NAMES, log: () => {} });
+    failing.tools.push({ name: "always_fails", description: "Throws", run: () => { throw new Error("boom"); } });
+    const harness = await createMcpTestClient(docs, { plugins: failing });
+    const result = await harness.client.callTool({ name: "always_fails", arguments: {} });
+    expect(result.isError).toBe(true);
+    expect(getToolText(result as any)).toBe("Error: plugin tool always_fails failed: boom");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,119 @@
+/**
+ * Tests for profiles of the running server: captures to PROFILE_DIR,
+ * one recording at a time, the /debug/pprof/ admin endpoints, and the
+ * capture_profile tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, readdir, readFile, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { handleAdmin, Profiler, ProfilerError, type ProfileKind, type ProfileSource } from "../src/profiler";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+/** Returns a fake profile at once, or when release() is called. */
+function fakeSource(options: { hold?: boolean } = {}) {
+  const calls: [ProfileKind, number][] = [];
+  let release = () => {};
+  const source: ProfileSource = {
+    async record(kind, seconds) {
+      calls.push([kind, seconds]);
+      if (options.hold) await new Promise<void>((done) => (release = done));
+      return JSON.stringify({ kind, seconds, nodes: [] });
+    },
+  };
+  return { source, calls, release: () => release() };
+}
+
+let dir: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-profiles-"));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("Profiler", () => {
+  test("captures write DevTools files into the profile directory", async () => {
+    const fake = fakeSource();
+    const profiler = new Profiler(join(dir, "profiles"), fake.source);
+    const cpu = await profiler.capture("cpu", 5);
+    const heap = await profiler.capture("heap");
+    expect(fake.calls).toEqual([["cpu", 5], ["heap", 30]]);
+    expect(cpu.path).toMatch(/profiles\/cpu-\d{4}-\d\d-\d\dT[\d-]+Z\.cpuprofile$/);
+    expect(heap.path.endsWith(".heapprofile")).toBe(true);
+    expect(JSON.parse(await readFile(cpu.path, "utf8"))).toEqual({ kind: "cpu", seconds: 5, nodes: [] });
+    expect(cpu.bytes).toBe((await readFile(cpu.path)).length);
+    expect((await readdir(join(dir, "profiles"))).length).toBe(2);
+  });
+
+  test("records one profile at a time, and checks its length", async () => {
+    const fake = fakeSource({ hold: true });
+    const profiler = new Profiler(dir, fake.source);
+    const first = profiler.record("cpu", 1);
+    expect(profiler.busy).toBe("cpu");
+    await expect(profiler.record("heap", 1)).rejects.toThrow("a cpu profile is already being recorded");
+    fake.release();
+    await first;
+    expect(profiler.busy).toBeNull();
+    await expect(profiler.record("cpu", 0)).rejects.toThrow(ProfilerError);
+    await expect(profiler.record("cpu", 301)).rejects.toThrow("seconds must be between 1 and 300");
+    await expect(new Profiler(undefined, fake.source).capture("cpu")).rejects.toThrow("PROFILE_DIR is not set");
+  });
+});
+
+describe("admin endpoints", () => {
+  const get = (path: string, init?: RequestInit) => new Request(`http://127.0.0.1:6060${path}`, init);
+
+  test("serve profiles as downloads", async () => {
+    const fake = fakeSource();
+    const profiler = new Profiler(undefined, fake.source);
+    const index = await handleAdmin(get("/debug/pprof/"), profiler);
+    expect(Object.keys((await index.json()).profiles)).toEqual(["/debug/pprof/profile", "/debug/pprof/heap"]);
+
+    const cpu = await handleAdmin(get("/debug/pprof/profile?seconds=2"), profiler);
+    expect(cpu.status).toBe(200);
+    expect(cpu.headers.get("content-disposition")).toBe('attachment; filename="cpu.cpuprofile"');
+    expect((await cpu.json()).seconds).toBe(2);
+    await handleAdmin(get("/debug/pprof/heap"), profiler);
+    expect(fake.calls).toEqual([["cpu", 2], ["heap", 30]]);
+  });
+
+  test("refuse bad requests, a missing token, and a second recording", async () => {
+    const fake = fakeSource({ hold: true });
+    const profiler = new Profiler(undefined, fake.source);
+    const token = { token: "s3cret" };
+    expect((await handleAdmin(get("/debug/pprof/heap"), profiler, token)).status).toBe(401);
+    const auth = { headers: { Authorization: "Bearer s3cret" } };
+    expect((await handleAdmin(get("/debug/pprof/goroutine", auth), profiler, token)).status).toBe(404);
+    expect((await handleAdmin(get("/metrics", auth), profiler, token)).status).toBe(404);
+    expect((await handleAdmin(get("/debug/pprof/profile?seconds=abc", auth), profiler, token)).status).toBe(400);
+
+    const first = handleAdmin(get("/debug/pprof/profile", auth), profiler, token);
+    const second = await handleAdmin(get("/debug/pprof/heap", auth), profiler, token);
+    expect(second.status).toBe(409);
+    fake.release();
+    expect((await first).status).toBe(200);
+  });
+});
+
+describe("capture_profile tool", () => {
+  test("writes a profile and reports where", async () => {
+    const harness = await createMcpTestClient([], { profiler: new Profiler(dir, fakeSource().source) });
+    const result = await harness.client.callTool({ name: "capture_profile", arguments: { kind: "heap", seconds: 3 } });
+    const data = result.structuredContent as any;
+    expect([data.kind, data.seconds]).toEqual(["heap", 3]);
+    expect(data.path.startsWith(dir)).toBe(true);
+    expect(getToolText(result as any)).toContain(`Recorded a 3s heap profile to ${data.path}`);
+    await harness.cleanup();
+  });
+
+  test("is only registered with a profiler", async () => {
+    const harness = await createMcpTestClient([]);
+    const { tools } = await harness.client.listTools();
+    expect(tools.map((t) => t.name)).not.toContain("capture_profile");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,165 @@
+/**
+ * Tests for the query log: search entries, read attribution (per
+ * session, once per search, within the window), feedback, write
+ * failures, and the search → read → feedback flow over MCP.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, readFile, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ATTRIBUTION_WINDOW_MS, QueryLog, QueryLogError, type QueryLogEntry } from "../src/query-log";
+import type { SearchResult } from "../src/types";
+import { createMcpTestClient, getToolText, makeDoc, makeNode } from "./fixtures/helpers";
+
+let dir: string;
+let path: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-querylog-"));
+  path = join(dir, "logs", "queries.jsonl");
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+async function entries(log: QueryLog): Promise<QueryLogEntry[]> {
+  await log.flush();
+  return (await readFile(path, "utf-8"))
+    .trim()
+    .split("\n")
+    .map((line) => JSON.parse(line));
+}
+
+function hit(doc_id: string, node_id: string, score = 1): SearchResult {
+  return { doc_id, node_id, score } as SearchResult;
+}
+
+describe("QueryLog", () => {
+  test("logs searches with ranked hits and credits reads to them", async () => {
+    let now = 1_000_000;
+    const log = new QueryLog(path, { now: () => now });
+    const session = {};
+    const id = log.search(session, "search_documents", "connection pool", [hit("d:a", "d:a:n1", 2.5), hit("d:b", "d:b:n2")]);
+    now += 1000;
+    log.read(session, "get_node_content", "d:b", ["d:b:n2", "d:b:n9"]);
+    log.read(session, "get_tree", "d:a");
+
+    const [search, first, second] = await entries(log);
+    expect(search).toEqual({
+      type: "search",
+      id,
+      at: new Date(1_000_000).toISOString(),
+      tool: "search_documents",
+      query: "connection pool",
+      results: [
+        { doc_id: "d:a", node_id: "d:a:n1", rank: 1, score: 2.5 },
+        { doc_id: "d:b", node_id: "d:b:n2", rank: 2, score: 1 },
+      ],
+    });
+    expect(first).toEqual({ type: "read", at: new Date(1_001_000).toISOString(), search_id: id, tool: "get_node_content", doc_id: "d:b", node_id: "d:b:n2", rank: 2 });
+    expect(second).toEqual({ type: "read", at: new Date(1_001_000).toISOString(), search_id: id, tool: "get_tree", doc_id: "d:a", node_id: null, rank: 1 });
+  });
+
+  test("a read is credited once, to the latest search of its own session, within the window", async () => {
+    let now = 0;
+    const log = new QueryLog(path, { now: () => now });
+    const mine = {};
+    const other = {};
+    log.search(mine, "find_symbol", "Pool", [hit("d:a", "d:a:n1")]);
+    const latest = log.search(mine, "search_documents", "pool", [hit("d:b", "d:b:n1"), hit("d:a", "d:a:n1")]);
+    log.read(other, "navigate_tree", "d:a", ["d:a:n1"]);
+    log.read(mine, "navigate_tree", "d:a", ["d:a:n1"]);
+    log.read(mine, "resource", "d:a", ["d:a:n1"]);
+    now = ATTRIBUTION_WINDOW_MS + 1;
+    log.read(mine, "get_node_content", "d:b", ["d:b:n1"]);
+
+    const reads = (await entries(log)).filter((e) => e.type === "read");
+    expect(reads.length).toBe(1);
+    expect((reads[0] as any).search_id).toBe(latest);
+    expect((reads[0] as any).rank).toBe(2);
+  });
+
+  test("feedback attaches to the search that returned the section, or the one named", async () => {
+    const log = new QueryLog(path);
+    const session = {};
+    const first = log.search(session, "search_documents", "retry", [hit("d:a", "d:a:n1")]);
+    const second = log.search(session, "search_documents", "backoff", [hit("d:b", "d:b:n1")]);
+
+    expect(log.feedback(session, { doc_id: "d:a", node_id: "d:a:n1", relevant: true })).toEqual({ search_id: first, rank: 1 });
+    // Not in any result: a section the latest search missed
+    expect(log.feedback(session, { doc_id: "d:c", relevant: true, note: "should be first" })).toEqual({ search_id: second, rank: null });
+    // By id, from another session
+    expect(log.feedback({}, { search_id: first, doc_id: "d:b", node_id: "d:b:n1", relevant: false })).toEqual({
+      search_id: first,
+      rank: null,
+    });
+
+    const feedback = (await entries(log)).filter((e) => e.type === "feedback");
+    expect(feedback.map((e) => (e as any).relevant)).toEqual([true, true, false]);
+    expect((feedback[1] as any).note).toBe("should be first");
+    expect("note" in feedback[0]).toBe(false);
+  });
+
+  test("feedback without a search to attach to throws", () => {
+    const log = new QueryLog(path);
+    expect(() => log.feedback({}, { doc_id: "d:a", relevant: true })).toThrow(QueryLogError);
+    expect(() => log.feedback({}, { search_id: "nope", doc_id: "d:a", relevant: true })).toThrow('no recent search with id "nope"');
+  });
+
+  test("a failed write is logged once and never throws", async () => {
+    await writeFile(join(dir, "logs"), "a file, not a directory");
+    const warnings: string[] = [];
+    const log = new QueryLog(path, { log: (msg) => warnings.push(msg) });
+    log.search({}, "search_documents", "a", []);
+    log.search({}, "search_documents", "b", []);
+    await log.flush();
+    expect(warnings.length).toBe(1);
+    expect(warnings[0]).toContain("could not be written");
+  });
+});
+
+describe("query log over MCP", () => {
+  const docs = ["alpha", "beta"].map((name) =>
+    makeDoc({
+      meta: { doc_id: `docs:${name}`, file_path: `${name}.md`, title: name },
+      tree: [makeNode({ node_id: `docs:${name}:n1`, title: name, content: `connection pool ${name}` })],
+    })
+  );
+
+  test("search, read, and feedback land in the log", async () => {
+    const queryLog = new QueryLog(path);
+    const harness = await createMcpTestClient(docs, { queryLog });
+    const result = await harness.client.callTool({ name: "search_documents", arguments: { query: "connection pool" } });
+    const search_id = (result.structuredContent as any).search_id;
+    expect(typeof search_id).toBe("string");
+
+    await harness.client.callTool({ name: "get_node_content", arguments: { doc_id: "docs:beta", node_ids: ["docs:beta:n1"] } });
+    const feedback = await harness.client.callTool({
+      name: "feedback",
+      arguments: { doc_id: "docs:beta", node_id: "docs:beta:n1", relevant: true },
+    });
+    const data = feedback.structuredContent as any;
+    expect([data.recorded, data.search_id, data.rank, data.relevant]).toEqual([true, search_id, 2, true]);
+    expect(getToolText(feedback as any)).toContain(`docs:beta [docs:beta:n1] relevant for search ${search_id}`);
+
+    const logged = await entries(queryLog);
+    expect(logged.map((e) => e.type)).toEqual(["search", "read", "feedback"]);
+    expect([(logged[1] as any).tool, (logged[1] as any).search_id]).toEqual(["get_node_content", search_id]);
+    await harness.cleanup();
+  });
+
+  test("feedback is only registered with a query log, and reports a missing search", async () => {
+    const plain = await createMcpTestClient(docs);
+    const { tools } = await plain.client.listTools();
+    expect(tools.some((t) => t.name === "feedback")).toBe(false);
+    await plain.cleanup();
+
+    const harness = await createMcpTestClient(docs, { queryLog: new QueryLog(path) });
+    const result = await harness.client.callTool({ name: "feedback", arguments: { doc_id: "docs:alpha", relevant: false } });
+    expect(result.isError).toBe(true);
+    expect(getToolText(result as any)).toContain("no search in this session");
+    await harness.cleanup();
+  });
+});
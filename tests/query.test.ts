@@ -0,0 +1,159 @@
+/**
+ * Tests for query syntax parsing and evaluation.
+ *
+ * Covers: operator detection, precedence, +/- shorthand, grouping,
+ * quoted phrases, malformed input, and evaluation against a term lookup.
+ */
+
+import { describe, test, expect } from "bun:test";
+import {
+  evaluateQuery,
+  isBooleanQuery,
+  parseBooleanQuery,
+  parseQuery,
+  positiveTerms,
+  type QueryIndex,
+} from "../src/query";
+
+describe("isBooleanQuery", () => {
+  test("detects uppercase operators and +/- prefixes", () => {
+    expect(isBooleanQuery("connect AND NOT test")).toBe(true);
+    expect(isBooleanQuery("connect -test")).toBe(true);
+    expect(isBooleanQuery("+retry backoff")).toBe(true);
+  });
+
+  test("leaves plain queries alone", () => {
+    expect(isBooleanQuery("auth token refresh")).toBe(false);
+    expect(isBooleanQuery("rock and roll")).toBe(false);
+    expect(isBooleanQuery("co-op run-time")).toBe(false);
+    expect(parseBooleanQuery("auth token")).toBeNull();
+  });
+});
+
+describe("parseBooleanQuery", () => {
+  test("NOT binds tighter than AND, AND tighter than OR", () => {
+    expect(parseBooleanQuery("a OR b AND NOT c")).toEqual({
+      op: "or",
+      children: [
+        { op: "term", term: "a" },
+        { op: "and", children: [{ op: "term", term: "b" }, { op: "not", child: { op: "term", term: "c" } }] },
+      ],
+    });
+  });
+
+  test("adjacent terms are ANDed and shorthand maps to operators", () => {
+    expect(parseBooleanQuery("+retry backoff -test")).toEqual({
+      op: "and",
+      children: [
+        { op: "term", term: "retry" },
+        { op: "term", term: "backoff" },
+        { op: "not", child: { op: "term", term: "test" } },
+      ],
+    });
+  });
+
+  test("parentheses group", () => {
+    expect(parseBooleanQuery("(jwt OR oauth) AND refresh")).toEqual({
+      op: "and",
+      children: [
+        { op: "or", children: [{ op: "term", term: "jwt" }, { op: "term", term: "oauth" }] },
+        { op: "term", term: "refresh" },
+      ],
+    });
+  });
+
+  test("drops dangling operators and unbalanced parentheses", () => {
+    expect(parseBooleanQuery("connect AND")).toEqual({ op: "term", term: "connect" });
+    expect(parseBooleanQuery("(connect OR dial -test")).toEqual({
+      op: "or",
+      children: [
+        { op: "term", term: "connect" },
+        { op: "and", children: [{ op: "term", term: "dial" }, { op: "not", child: { op: "term", term: "test" } }] },
+      ],
+    });
+  });
+});
+
+describe("NEAR", () => {
+  test("parses NEAR/n between terms and phrases", () => {
+    expect(isBooleanQuery("Connect NEAR/5 ctx")).toBe(true);
+    expect(parseBooleanQuery('Connect NEAR/5 "ctx context"')).toEqual({
+      op: "near",
+      distance: 5,
+      children: [{ op: "term", term: "Connect" }, { op: "phrase", phrase: "ctx context" }],
+    });
+  });
+
+  test("binds tighter than AND and defaults to 10", () => {
+    expect(parseBooleanQuery("dial a NEAR b")).toEqual({
+      op: "and",
+      children: [
+        { op: "term", term: "dial" },
+        { op: "near", distance: 10, children: [{ op: "term", term: "a" }, { op: "term", term: "b" }] },
+      ],
+    });
+  });
+
+  test("degrades to AND around a group", () => {
+    expect(parseBooleanQuery("(a OR b) NEAR/2 c")).toEqual({
+      op: "and",
+      children: [
+        { op: "or", children: [{ op: "term", term: "a" }, { op: "term", term: "b" }] },
+        { op: "term", term: "c" },
+      ],
+    });
+  });
+});
+
+describe("quoted phrases", () => {
+  test("a phrase is one node, and operators inside it are words", () => {
+    expect(parseBooleanQuery('"NOT connected" -retry')).toEqual({
+      op: "and",
+      children: [{ op: "phrase", phrase: "NOT connected" }, { op: "not", child: { op: "term", term: "retry" } }],
+    });
+  });
+
+  test("a phrase in a plain query is required and its words are ranked", () => {
+    expect(parseQuery('"not connected" error')).toEqual({
+      filter: { op: "phrase", phrase: "not connected" },
+      ranked: " not connected  error",
+    });
+    expect(parseQuery("not connected")).toBeNull();
+  });
+
+  test("an unterminated quote runs to the end", () => {
+    expect(parseQuery('"not connected')?.filter).toEqual({ op: "phrase", phrase: "not connected" });
+  });
+});
+
+describe("evaluateQuery", () => {
+  const postings: Record<string, string[]> = {
+    connect: ["a", "b", "c"],
+    test: ["b"],
+    retry: ["c", "d"],
+  };
+  const index: QueryIndex = {
+    term: (term) => new Set(postings[term] ?? []),
+    phrase: () => new Set(),
+    // Pretend only connect/retry in "c" are close together
+    near: (left, right) => new Set(left.op === "term" && right.op === "term" && left.term === "connect" && right.term === "retry" ? ["c"] : []),
+  };
+  const universe = new Set(["a", "b", "c", "d", "e"]);
+  const run = (query: string) => [...evaluateQuery(parseBooleanQuery(query)!, universe, index)].sort();
+
+  test("evaluates AND, OR and NOT", () => {
+    expect(run("connect AND NOT test")).toEqual(["a", "c"]);
+    expect(run("connect -test retry")).toEqual(["c"]);
+    expect(run("test OR retry")).toEqual(["b", "c", "d"]);
+    expect(run("NOT connect")).toEqual(["d", "e"]);
+  });
+
+  test("evaluates NEAR through the index", () => {
+    expect(run("connect NEAR/3 retry")).toEqual(["c"]);
+    expect(run("connect NEAR/3 retry -retry")).toEqual([]);
+  });
+
+  test("positive terms exclude negated ones", () => {
+    expect(positiveTerms(parseBooleanQuery("connect -test (retry OR NOT x)")!)).toEqual(["connect", "retry"]);
+  });
+});
@@ -0,0 +1,183 @@
+/**
+ * Tests for read_file: line ranges, the max_bytes cap and where to
+ * continue after it, line counting as the index counts, and the tool's
+ * path, doc_id, and uri arguments.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { FileReader, ReadFileError } from "../src/read-file";
+import { indexAllCollections } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const POOL = `package pool
+
+// Pool hands out connections
+type Pool struct {
+	idle []*Conn
+}
+
+func (p *Pool) Put(c *Conn) {
+	p.idle = append(p.idle, c)
+}
+`;
+
+let dir: string;
+let config: IndexConfig;
+let store: DocumentStore;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-read-"));
+  await mkdir(join(dir, "docs"), { recursive: true });
+  await mkdir(join(dir, "src", "pool"), { recursive: true });
+  await writeFile(join(dir, "docs", "guide.md"), "# Guide\r\n\r\nLine three.\r\nLine four.\r\n");
+  await writeFile(join(dir, "src", "pool", "pool.go"), POOL);
+  config = {
+    collections: [{ name: "docs", root: join(dir, "docs"), weight: 1.0 }],
+    code_collections: [{ name: "code", root: join(dir, "src"), weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const pool = () => store.documentsAtPath("pool/pool.go")[0];
+
+describe("FileReader", () => {
+  test("returns the requested lines", async () => {
+    const reader = new FileReader(config);
+    const all = await reader.read(pool());
+    expect([all.start_line, all.end_line, all.total_lines, all.truncated]).toEqual([1, 10, 10, false]);
+    expect(all.content).toBe(POOL.slice(0, -1));
+
+    const put = await reader.read(pool(), { start_line: 8, end_line: 99 });
+    expect(put.content).toBe("func (p *Pool) Put(c *Conn) {\n\tp.idle = append(p.idle, c)\n}");
+    expect(put.end_line).toBe(10);
+    expect(put.bytes).toBe(Buffer.byteLength(put.content));
+  });
+
+  test("counts lines as the index does", async () => {
+    const guide = await new FileReader(config).read(store.documentsAtPath("guide.md")[0], { start_line: 3, end_line: 3 });
+    expect(guide.content).toBe("Line three.");
+    expect(guide.total_lines).toBe(4);
+  });
+
+  test("stops at max_bytes on a line boundary and says where to continue", async () => {
+    const reader = new FileReader(config);
+    // "package pool" (12) + "\n" + "" (0) + "\n" + "// Pool hands out connections" (29)
+    const first = await reader.read(pool(), { max_bytes: 43 });
+    expect([first.end_line, first.truncated, first.next_start_line, first.bytes]).toEqual([3, true, 4, 43]);
+    const next = await reader.read(pool(), { start_line: first.next_start_line, max_bytes: 43 });
+    expect(next.content.startsWith("type Pool struct {")).toBe(true);
+
+    const exact = await reader.read(pool(), { end_line: 3, max_bytes: 43 });
+    expect(exact.truncated).toBe(false);
+  });
+
+  test("cuts a first line longer than max_bytes on a character boundary", async () => {
+    await writeFile(join(dir, "src", "pool", "pool.go"), `// ${"é".repeat(20)}\nnext\n`);
+    const cut = await new FileReader(config).read(pool(), { max_bytes: 8 });
+    expect(cut.content).toBe("// éé");
+    expect([cut.bytes, cut.truncated, cut.cut_line, cut.next_start_line, cut.next_start_byte]).toEqual([7, true, true, 1, 7]);
+  });
+
+  test("pages through a long line by next_start_byte", async () => {
+    const line = `// ${"é".repeat(20)}`;
+    await writeFile(join(dir, "src", "pool", "pool.go"), `${line}\nnext\n`);
+    const reader = new FileReader(config);
+    let text = "";
+    let at: { start_line?: number; start_byte?: number } = {};
+    for (let calls = 0; calls < 20; calls++) {
+      const part = await reader.read(pool(), { ...at, max_bytes: 8 });
+      text += part.content;
+      if (!part.cut_line) break;
+      expect(part.start_line).toBe(1);
+      at = { start_line: part.next_start_line, start_byte: part.next_start_byte };
+    }
+    expect(text.split("\n")[0]).toBe(line);
+
+    const rest = await reader.read(pool(), { start_byte: 41 });
+    expect([rest.content, rest.start_byte]).toEqual(["é\nnext", 41]);
+    await expect(reader.read(pool(), { start_byte: 4 })).rejects.toThrow("inside a character");
+    await expect(reader.read(pool(), { start_byte: 44 })).rejects.toThrow("past the end of line 1");
+  });
+
+  test("returns one whole character when max_bytes is narrower than it", async () => {
+    await writeFile(join(dir, "src", "pool", "pool.go"), "€𝄞a\n");
+    const reader = new FileReader(config);
+    const parts: Array<[string, number, number | undefined]> = [];
+    let at: { start_line?: number; start_byte?: number } = {};
+    for (let calls = 0; calls < 10; calls++) {
+      const part = await reader.read(pool(), { ...at, max_bytes: 1 });
+      parts.push([part.content, part.bytes, part.next_start_byte]);
+      if (!part.cut_line) break;
+      at = { start_line: part.next_start_line, start_byte: part.next_start_byte };
+    }
+    expect(parts).toEqual([
+      ["€", 3, 3],
+      ["𝄞", 4, 7],
+      ["a", 1, undefined],
+    ]);
+  });
+
+  test("rejects ranges outside the file and files gone from disk", async () => {
+    const reader = new FileReader(config);
+    await expect(reader.read(pool(), { start_line: 11 })).rejects.toThrow("start_line 11 is past the end of pool/pool.go (10 lines)");
+    await expect(reader.read(pool(), { start_line: 5, end_line: 4 })).rejects.toThrow("end_line 4 is before start_line 5");
+    await rm(join(dir, "src", "pool", "pool.go"));
+    await expect(reader.read(pool())).rejects.toThrow(ReadFileError);
+  });
+});
+
+describe("read_file tool", () => {
+  test("reads by path, doc_id, or uri, with numbered lines", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { files: new FileReader(config) });
+    const byPath = await harness.client.callTool({ name: "read_file", arguments: { path: "pool/pool.go", start_line: 8, end_line: 10 } });
+    expect(getToolText(byPath as any)).toBe(
+      "pool/pool.go, lines 8-10 of 10:\n\n 8  func (p *Pool) Put(c *Conn) {\n 9  \tp.idle = append(p.idle, c)\n10  }"
+    );
+    const data = byPath.structuredContent as any;
+    expect(data.uri).toContain("pool.go#L8-10");
+
+    const byUri = await harness.client.callTool({ name: "read_file", arguments: { uri: data.uri } });
+    expect((byUri.structuredContent as any).content).toBe(data.content);
+    const byId = await harness.client.callTool({ name: "read_file", arguments: { path: pool().doc_id, start_line: 4, end_line: 4 } });
+    expect((byId.structuredContent as any).content).toBe("type Pool struct {");
+    await harness.cleanup();
+  });
+
+  test("says when max_bytes cut the answer short", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { files: new FileReader(config) });
+    const result = await harness.client.callTool({ name: "read_file", arguments: { path: "pool/pool.go", max_bytes: 43 } });
+    expect(getToolText(result as any)).toContain("[Truncated at max_bytes=43: 43 bytes returned. Continue with start_line=4, or raise max_bytes.]");
+    expect((result.structuredContent as any).truncated).toBe(true);
+
+    await writeFile(join(dir, "src", "pool", "pool.go"), `// ${"x".repeat(30)}\n`);
+    const cut = await harness.client.callTool({ name: "read_file", arguments: { path: "pool/pool.go", max_bytes: 10 } });
+    expect(getToolText(cut as any)).toContain("Continue with start_line=1 start_byte=10, or raise max_bytes.]");
+    const rest = await harness.client.callTool({ name: "read_file", arguments: { path: "pool/pool.go", start_byte: 10 } });
+    expect(getToolText(rest as any)).toContain("lines 1-1 of 1 (line 1 from byte 10):");
+    expect((rest.structuredContent as any).content).toBe("x".repeat(23));
+    await harness.cleanup();
+  });
+
+  test("unknown files and bad arguments", async () => {
+    const harness = await createMcpTestClient(store.exportDocuments(), { files: new FileReader(config) });
+    const missing = await harness.client.callTool({ name: "read_file", arguments: { path: "nowhere.go" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    const both = await harness.client.callTool({ name: "read_file", arguments: { path: "pool/pool.go", uri: "treenav://file/pool/pool.go" } });
+    expect(both.isError).toBe(true);
+    const past = await harness.client.callTool({ name: "read_file", arguments: { path: "pool/pool.go", start_line: 50 } });
+    expect(getToolText(past as any)).toContain("past the end");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,99 @@
+/**
+ * Tests for the ref parameter: stores built from the git object store,
+ * ref validation, collection roots below the repository root, and the
+ * read tools answering at a ref.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { RefIndex, RefIndexError } from "../src/ref-index";
+import { indexFile } from "../src/indexer";
+import { indexCodeFile } from "../src/code-indexer";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { git, initRepo } from "./fixtures/git";
+
+// ── A scratch repository ─────────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-ref-"));
+  await mkdir(join(dir, "docs"), { recursive: true });
+  await mkdir(join(dir, "src"), { recursive: true });
+  await writeFile(join(dir, "docs", "auth.md"), "# Auth\n\n## Tokens\n\nTokens are signed with HMAC.\n");
+  await writeFile(join(dir, "src", "auth.ts"), "export function signToken() {\n  return sign();\n}\n");
+  initRepo(dir, "auth", "2020-06-01T12:00:00Z");
+  git(dir, ["tag", "v1"]);
+  // The working tree moves on
+  await writeFile(join(dir, "docs", "auth.md"), "# Auth\n\n## Tokens\n\nTokens are signed with Ed25519.\n");
+  await writeFile(join(dir, "src", "auth.ts"), "export function signTokenV2() {\n  return signV2();\n}\n");
+  await writeFile(join(dir, "docs", "new.md"), "# New\n\nUncommitted.\n");
+  config = {
+    collections: [{ name: "docs", root: join(dir, "docs"), weight: 1.0 }],
+    code_collections: [{ name: "code", root: join(dir, "src"), weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("RefIndex", () => {
+  test("indexes the committed content of every collection", async () => {
+    const at = await new RefIndex(config).storeAt("v1");
+    expect(Object.keys(at.commits).sort()).toEqual(["code", "docs"]);
+    expect(at.commits.docs).toMatch(/^[0-9a-f]{40}$/);
+    expect(at.store.getStats().document_count).toBe(2);
+
+    const hits = at.store.searchDocuments("HMAC");
+    expect(hits.map((h) => h.doc_id)).toEqual(["docs:auth"]);
+    expect(at.store.searchDocuments("Ed25519")).toHaveLength(0);
+    expect(at.store.getDocMeta("docs:auth")!.last_modified).toBe("2020-06-01T12:00:00.000Z");
+  });
+
+  test("reuses the store while the ref stays put", async () => {
+    const refs = new RefIndex(config);
+    const first = await refs.storeAt("v1");
+    expect((await refs.storeAt("HEAD")).store).toBe(first.store);
+  });
+
+  test("rejects malformed and unknown refs", async () => {
+    const refs = new RefIndex(config);
+    for (const ref of ["", "--output=x", "main branch", "HEAD:docs"]) {
+      await expect(refs.storeAt(ref)).rejects.toThrow(RefIndexError);
+    }
+    await expect(refs.storeAt("no-such-tag")).rejects.toThrow('Unknown ref "no-such-tag"');
+  });
+});
+
+describe("ref argument", () => {
+  test("answers search_documents and find_symbol at the ref, and reports bad refs", async () => {
+    const docs = [
+      await indexFile(join(dir, "docs", "auth.md"), join(dir, "docs"), "docs"),
+      await indexCodeFile(join(dir, "src", "auth.ts"), join(dir, "src"), "code"),
+    ];
+    const harness = await createMcpTestClient(docs, { refs: new RefIndex(config) });
+
+    const live = await harness.client.callTool({ name: "search_documents", arguments: { query: "HMAC" } });
+    expect((live.structuredContent as any).results).toHaveLength(0);
+
+    const old = await harness.client.callTool({ name: "search_documents", arguments: { query: "HMAC", ref: "v1" } });
+    const data = old.structuredContent as any;
+    expect([data.ref, data.results[0].doc_id]).toEqual(["v1", "docs:auth"]);
+    expect(getToolText(old as any)).toMatch(/^At v1 \([0-9a-f]{12}\):/);
+
+    const symbol = await harness.client.callTool({ name: "find_symbol", arguments: { query: "signToken", ref: "v1" } });
+    expect((symbol.structuredContent as any).results[0].node_title).toContain("signToken");
+
+    const bad = await harness.client.callTool({ name: "get_tree", arguments: { doc_id: "docs:auth", ref: "nope" } });
+    expect(bad.isError).toBe(true);
+    expect(getToolText(bad as any)).toContain('Unknown ref "nope"');
+    await harness.cleanup();
+  });
+});
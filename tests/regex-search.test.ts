@@ -0,0 +1,153 @@
+/**
+ * Tests for regex search: trigram queries extracted from patterns,
+ * Bloom filter lookups, files skipped without being read, matching
+ * options, and the regex_search tool.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { buildTrigramFilter, decodeTrigramFilter, literalQuery, mayMatch, regexQuery } from "../src/trigram-filter";
+import { RegexSearch, RegexSearchError } from "../src/regex-search";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const POOL = `package pool
+
+// Get returns a connection from the pool
+func (p *Pool) Get(ctx context.Context) (*Conn, error) {
+	return p.dial(ctx)
+}
+
+func (p *Pool) Put(c *Conn) { p.idle = append(p.idle, c) }
+`;
+
+const RETRY = `package retry
+
+func retryWithBackoff(attempts int) error {
+	return ErrExhausted
+}
+`;
+
+const may = (text: string, query: ReturnType<typeof regexQuery>) => mayMatch(decodeTrigramFilter(buildTrigramFilter(text)), query);
+
+describe("regexQuery", () => {
+  test("requires the literal runs of a pattern", () => {
+    expect(regexQuery("abc")).toEqual(literalQuery("abc"));
+    expect(regexQuery("retryWithBackoff")![0].length).toBe(14);
+    // \w+ and the optional s break the runs; "Pool" and "Get" remain
+    const expected = [...literalQuery("Pool")![0], ...literalQuery(") Get")![0]].sort();
+    expect(regexQuery("\\w+Pools?\\) Get")![0].sort()).toEqual(expected);
+  });
+
+  test("alternation and groups give alternatives", () => {
+    const query = regexQuery("Dial|Connect")!;
+    expect(query.length).toBe(2);
+    expect(regexQuery("conn(ect|ection)Pool")!.length).toBe(2);
+    expect(may(POOL, regexQuery("Dial|Get\\("))).toBe(true);
+    expect(may(POOL, regexQuery("Redial|Hangup"))).toBe(false);
+  });
+
+  test("patterns without a three-character literal everywhere cannot filter", () => {
+    expect(regexQuery("\\w+")).toBeNull();
+    expect(regexQuery("ab|retry")).toBeNull();
+    expect(regexQuery("(?:retry)?x")).toBeNull();
+    expect(regexQuery("[abc]+def")).not.toBeNull();
+    expect(regexQuery("(unclosed")).toBeNull();
+    expect(regexQuery("éé+")).toBeNull();
+    expect(regexQuery("café")).toEqual(literalQuery("caf"));
+  });
+
+  test("optional and repeated parts", () => {
+    expect(may(RETRY, regexQuery("retr(y)?WithBackoff"))).toBe(true);
+    expect(may(RETRY, regexQuery("retryy*With"))).toBe(true);
+    expect(may(RETRY, regexQuery("ret(?=ry)"))).toBe(true);
+    expect(may(RETRY, regexQuery("Exhausted{2,}"))).toBe(true);
+  });
+});
+
+describe("trigram filters", () => {
+  test("never rule out text a pattern matches, in either case", () => {
+    for (const pattern of ["retryWithBackoff", "RETRYWITHBACKOFF", "func retry\\w+\\(attempts", "Err[A-Z]\\w+", "^\\treturn Err"]) {
+      expect(new RegExp(pattern, "im").test(RETRY)).toBe(true);
+      expect(may(RETRY, regexQuery(pattern))).toBe(true);
+    }
+    expect(may(RETRY, literalQuery("(attempts int)"))).toBe(true);
+  });
+
+  test("rule out text that lacks a required literal", () => {
+    expect(may(RETRY, regexQuery("circuitBreaker"))).toBe(false);
+    expect(may(POOL, literalQuery("retryWithBackoff"))).toBe(false);
+    expect(may("", regexQuery("anything"))).toBe(false);
+  });
+});
+
+let dir: string;
+let config: IndexConfig;
+
+const indexedStore = () => indexTree(dir, ["pool/pool.go", "retry/retry.go"]);
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-regex-"));
+  await writeTree(dir, {
+    "pool/pool.go": POOL,
+    "retry/retry.go": RETRY,
+  });
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("RegexSearch", () => {
+  test("reads only the files the filters let through", async () => {
+    const result = (await new RegexSearch(config).search(await indexedStore(), "func \\(p \\*Pool\\) (Get|Put)"))!;
+    expect([result.files, result.skipped, result.total]).toEqual([1, 1, 2]);
+    expect(result.matches.map((m) => [m.file_path, m.line, m.column, m.match])).toEqual([
+      ["pool/pool.go", 4, 1, "func (p *Pool) Get"],
+      ["pool/pool.go", 8, 1, "func (p *Pool) Put"],
+    ]);
+    expect(result.matches[1].text).toBe("func (p *Pool) Put(c *Conn) { p.idle = append(p.idle, c) }");
+  });
+
+  test("literal, case, path, and limit", async () => {
+    const store = await indexedStore();
+    const search = new RegexSearch(config);
+    expect((await search.search(store, "p.idle", { literal: true }))!.total).toBe(2);
+    expect((await search.search(store, "P.IDLE", { literal: true }))!.total).toBe(0);
+    expect((await search.search(store, "P.IDLE", { literal: true, case: "insensitive" }))!.total).toBe(2);
+    expect((await search.search(store, "errexhausted", { case: "smart" }))!.total).toBe(1);
+    expect((await search.search(store, "errExhausted", { case: "smart" }))!.total).toBe(0);
+    expect((await search.search(store, "ctx", { path: "retry/" }))!.files).toBe(0);
+    const limited = (await search.search(store, "p\\.", { limit: 1 }))!;
+    expect([limited.files, limited.total, limited.matches.length]).toEqual([2, 3, 1]);
+    expect(await search.search(store, "x", { path: "nowhere/" })).toBeNull();
+    await expect(search.search(store, "(")).rejects.toThrow(RegexSearchError);
+  });
+
+  test("empty matches do not loop", async () => {
+    const result = (await new RegexSearch(config).search(await indexedStore(), "^", { path: "retry/retry.go" }))!;
+    expect(result.total).toBe(RETRY.split("\n").length);
+  });
+});
+
+describe("regex_search tool", () => {
+  test("lists matches by file, with how many files were skipped", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), { regex: new RegexSearch(config) });
+    const result = await harness.client.callTool({ name: "regex_search", arguments: { pattern: "retryWith\\w+" } });
+    const data = result.structuredContent as any;
+    expect([data.files, data.skipped, data.total]).toEqual([1, 1, 1]);
+    expect(data.matches[0].uri).toContain("retry.go");
+    const text = getToolText(result as any);
+    expect(text).toContain("1 match(es); 1 file(s) read, 1 skipped by trigram filters");
+    expect(text).toContain("retry/retry.go\n  L3:6: func retryWithBackoff(attempts int) error {");
+
+    const bad = await harness.client.callTool({ name: "regex_search", arguments: { pattern: "[" } });
+    expect(bad.isError).toBe(true);
+    expect(getToolText(bad as any)).toContain("invalid regular expression");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,77 @@
+/**
+ * Tests for the remote index proxy: bearer checks, forwarding tools and
+ * resources unchanged, and failures surfacing as tool errors.
+ */
+
+import { afterEach, describe, expect, test } from "bun:test";
+import { Client } from "@modelcontextprotocol/sdk/client/index.js";
+import { InMemoryTransport } from "@modelcontextprotocol/sdk/inMemory.js";
+import { bearerAuthorized, connectRemote, createProxyServer, RemoteError } from "../src/remote";
+import { createMcpTestClient, getToolText, makeDoc, type McpTestHarness } from "./fixtures/helpers";
+
+let harness: McpTestHarness | undefined;
+
+afterEach(async () => {
+  await harness?.cleanup();
+  harness = undefined;
+});
+
+/** A client talking to a proxy in front of `remote`. */
+async function throughProxy(remote: Client): Promise<Client> {
+  const [clientTransport, serverTransport] = InMemoryTransport.createLinkedPair();
+  await createProxyServer(remote, "http://index-host:3100/mcp").connect(serverTransport);
+  const local = new Client({ name: "test-client", version: "0.0.1" });
+  await local.connect(clientTransport);
+  return local;
+}
+
+describe("bearerAuthorized", () => {
+  const request = (authorization?: string) =>
+    new Request("http://localhost/mcp", { headers: authorization ? { authorization } : {} });
+
+  test("accepts the configured token only", () => {
+    expect(bearerAuthorized(request(), undefined)).toBe(true);
+    expect(bearerAuthorized(request("Bearer s3cret"), "s3cret")).toBe(true);
+    expect(bearerAuthorized(request("Bearer s3cre"), "s3cret")).toBe(false);
+    expect(bearerAuthorized(request(), "s3cret")).toBe(false);
+  });
+});
+
+describe("proxy", () => {
+  test("serves the remote tools and resources unchanged", async () => {
+    harness = await createMcpTestClient([
+      makeDoc({ meta: { doc_id: "docs:auth", title: "Auth", collection: "docs" } }),
+    ]);
+    const local = await throughProxy(harness.client);
+
+    const names = (r: { tools: { name: string }[] }) => r.tools.map((t) => t.name);
+    expect(names(await local.listTools())).toEqual(names(await harness.client.listTools()));
+
+    const args = { name: "search_documents", arguments: { query: "authentication" } };
+    const proxied = await local.callTool(args);
+    expect(proxied.structuredContent).toEqual((await harness.client.callTool(args)).structuredContent);
+    expect(getToolText(proxied as any)).toContain("docs:auth");
+
+    expect((await local.listResources()).resources).toEqual((await harness.client.listResources()).resources);
+  });
+
+  test("reports a failed remote call as a tool error", async () => {
+    const remote = {
+      getServerCapabilities: () => ({ tools: {} }),
+      getInstructions: () => undefined,
+      callTool: async () => {
+        throw new Error("fetch failed");
+      },
+    } as unknown as Client;
+    const result = await (await throughProxy(remote)).callTool({ name: "search_documents", arguments: { query: "x" } });
+    expect(result.isError).toBe(true);
+    expect(getToolText(result as any)).toBe("Remote index at http://index-host:3100/mcp failed: fetch failed");
+  });
+});
+
+describe("connectRemote", () => {
+  test("rejects bad and unreachable URLs", async () => {
+    await expect(connectRemote({ url: "index host" })).rejects.toThrow(RemoteError);
+    await expect(connectRemote({ url: "http://127.0.0.1:9/mcp" })).rejects.toThrow("Cannot reach http://127.0.0.1:9/mcp");
+  });
+});
@@ -0,0 +1,138 @@
+/**
+ * Tests for the repository overview: languages, layout, packages, and
+ * tests from the index, its Markdown, and `treenav-mcp report` over a
+ * persisted artifact.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, readFile, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { buildReport, formatReport } from "../src/report";
+import { runIndexCommand, runReportCommand } from "../src/cli";
+import { indexCodeContent } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import type { Entrypoint } from "../src/entrypoints";
+import { commandArgs } from "./fixtures/cli";
+
+const TIME = "2026-01-01T00:00:00.000Z";
+
+function store() {
+  const s = new DocumentStore();
+  s.load([
+    indexCodeContent("package db\n\nfunc Connect() *Conn {\n\treturn nil\n}\n\ntype Conn struct{}\n", "internal/db/conn.go", "code", TIME),
+    indexCodeContent("package db\n\nfunc TestConnect(t *testing.T) {\n\tConnect()\n}\n", "internal/db/conn_test.go", "code", TIME),
+    indexCodeContent("package api\n\nfunc Serve() {\n\tc := db.Connect()\n\t_ = c\n}\n", "internal/api/serve.go", "code", TIME),
+    indexCodeContent("package main\n\nfunc main() {\n\tapi.Serve()\n}\n", "cmd/server/main.go", "code", TIME),
+    indexCodeContent("export function render(): string {\n  return \"\";\n}\n", "web/render.ts", "code", TIME),
+    indexCodeContent("import { render } from \"../web/render\";\n\ntest(\"render\", () => render());\n", "tests/render.test.ts", "code", TIME),
+  ]);
+  return s;
+}
+
+const main: Entrypoint = {
+  kind: "main",
+  framework: "go",
+  name: "main",
+  line: 3,
+  text: "func main() {",
+  doc_id: "code:cmd:server:main_go",
+  collection: "code",
+  file_path: "cmd/server/main.go",
+};
+
+describe("buildReport", () => {
+  test("counts languages and top-level directories", () => {
+    const report = buildReport(store());
+    expect(report.code_files).toBe(6);
+    expect(report.languages.map((l) => [l.language, l.files])).toEqual([
+      ["go", 4],
+      ["typescript", 2],
+    ]);
+    expect(report.layout.map((d) => d.dir)).toContain("internal");
+    expect(report.layout.find((d) => d.dir === "internal")!.files).toBe(3);
+  });
+
+  test("ranks packages by size and by references to their symbols", () => {
+    const report = buildReport(store());
+    expect(report.largest.map((p) => p.dir)).toContain("internal/db");
+    expect(report.most_referenced[0].dir).toBe("internal/db");
+    expect(report.most_referenced[0].references).toBeGreaterThan(0);
+  });
+
+  test("finds tests beside a package or named for one of its files", () => {
+    const { tests } = buildReport(store());
+    expect(tests.test_files).toBe(2);
+    expect(tests.packages).toBe(4);
+    expect(tests.tested_packages).toBe(2);
+    expect(tests.untested.map((p) => p.dir).sort()).toEqual(["cmd/server", "internal/api"]);
+  });
+
+  test("caps entry points per kind", () => {
+    const report = buildReport(store(), { top: 1, entrypoints: [main, { ...main, file_path: "cmd/tool/main.go" }] });
+    expect(report.entrypoints.main!.map((e) => e.file_path)).toEqual(["cmd/server/main.go"]);
+    expect(report.entrypoint_counts.main).toBe(2);
+  });
+});
+
+describe("formatReport", () => {
+  test("renders every section as Markdown", () => {
+    const text = formatReport(buildReport(store(), { entrypoints: [main], now: new Date(TIME) }), "svc overview");
+    expect(text.startsWith("# svc overview\n")).toBe(true);
+    expect(text).toContain("Generated 2026-01-01T00:00:00.000Z");
+    expect(text).toContain("| go | 4 |");
+    expect(text).toContain("- `main` (go) at `cmd/server/main.go:3`");
+    expect(text).toContain("## Most referenced packages");
+    expect(text).toContain("2 of 4 packages (50%) have tests.");
+    expect(text).toContain("| internal/api/ |");
+  });
+});
+
+describe("runReportCommand", () => {
+  let dir: string;
+  let codeRoot: string;
+  let indexPath: string;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-report-"));
+    codeRoot = join(dir, "svc");
+    indexPath = join(dir, "index.json");
+    await mkdir(join(codeRoot, "cmd/server"), { recursive: true });
+    await writeFile(join(codeRoot, "go.mod"), "module example.com/svc\n\ngo 1.22\n\nrequire github.com/go-chi/chi/v5 v5.0.12\n");
+    await writeFile(join(codeRoot, "cmd/server/main.go"), "package main\n\nfunc main() {\n\trun()\n}\n");
+    await mkdir(join(dir, "docs"));
+    const words = [join(dir, "docs"), "--code", codeRoot, "--out", indexPath];
+    await runIndexCommand(await commandArgs("index", words), () => {});
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  const args = () => ["--index", indexPath, "--root", join(dir, "docs"), "--code", codeRoot];
+  const report = async (words: string[], out: (text: string) => void) =>
+    runReportCommand(await commandArgs("report", words), out);
+
+  test("prints modules and entry points read from the working tree", async () => {
+    let text = "";
+    expect(await report(args(), (t) => (text += t))).toBe(0);
+    expect(text).toContain("# svc overview");
+    expect(text).toContain("| example.com/svc | (top level) | 1.22 | 1 |");
+    expect(text).toContain("`cmd/server/main.go:3`");
+  });
+
+  test("writes --out, or JSON with --json", async () => {
+    const out = join(dir, "OVERVIEW.md");
+    expect(await report([...args(), "--out", out], () => {})).toBe(0);
+    expect(await readFile(out, "utf-8")).toContain("## Languages");
+
+    let json = "";
+    await report([...args(), "--json"], (t) => (json += t));
+    expect(JSON.parse(json).modules[0].module).toBe("example.com/svc");
+  });
+
+  test("fails without a usable index, and on a bad --top", async () => {
+    expect(await report(["--index", join(dir, "missing.json"), "--root", join(dir, "docs")], () => {})).toBe(1);
+    expect(await report([...args(), "--top", "0"], () => {})).toBe(2);
+  });
+});
@@ -0,0 +1,137 @@
+/**
+ * Tests for the reranking stage: both request formats, reordering of
+ * the top k only, the latency budget, bad answers, and search_documents.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { parseScores, Reranker, type RerankerOptions } from "../src/reranker";
+import { Deadline, withDeadline } from "../src/deadline";
+import type { SearchResult } from "../src/types";
+import { createMcpTestClient, getToolText, makeDoc, makeNode } from "./fixtures/helpers";
+
+function hit(title: string): SearchResult {
+  return { node_title: title, snippet: `about ${title}` } as SearchResult;
+}
+
+/** A fetch that records requests and answers with `answer(body)`. */
+function fakeFetch(answer: (body: any) => unknown, options: { delayMs?: number; status?: number } = {}) {
+  const requests: { url: string; headers: Record<string, string>; body: any }[] = [];
+  const impl = (async (url: string, init: RequestInit) => {
+    const body = JSON.parse(init.body as string);
+    requests.push({ url, headers: init.headers as Record<string, string>, body });
+    if (options.delayMs) {
+      await new Promise((resolve, reject) => {
+        const timer = setTimeout(resolve, options.delayMs);
+        init.signal?.addEventListener("abort", () => {
+          clearTimeout(timer);
+          reject(init.signal!.reason);
+        });
+      });
+    }
+    return Response.json(answer(body), { status: options.status ?? 200 });
+  }) as unknown as typeof fetch;
+  return { impl, requests };
+}
+
+function reranker(fetch: typeof globalThis.fetch, options: Partial<RerankerOptions> = {}): Reranker {
+  return new Reranker({ url: "http://rerank.test/v2/rerank", fetch, log: () => {}, ...options });
+}
+
+describe("parseScores", () => {
+  test("reads Cohere and TEI answers", () => {
+    expect([...parseScores({ results: [{ index: 1, relevance_score: 0.9 }] }, 2)]).toEqual([[1, 0.9]]);
+    expect([...parseScores([{ index: 0, score: 3.5 }], 2)]).toEqual([[0, 3.5]]);
+  });
+
+  test("rejects answers that do not fit the candidates", () => {
+    expect(() => parseScores({ data: [] }, 2)).toThrow("no results array");
+    expect(() => parseScores([{ index: 2, score: 1 }], 2)).toThrow("unexpected answer entry");
+  });
+});
+
+describe("Reranker", () => {
+  test("cohere format: sends title and snippet of the top k, reorders only those", async () => {
+    const { impl, requests } = fakeFetch(() => ({
+      results: [
+        { index: 1, relevance_score: 0.9 },
+        { index: 0, relevance_score: 0.2 },
+      ],
+    }));
+    const out = await reranker(impl, { top_k: 2, model: "rerank-v3.5", api_key: "k" }).rerank("pool", [hit("a"), hit("b"), hit("c")]);
+    expect(out.results.map((r) => r.node_title)).toEqual(["b", "a", "c"]);
+    expect(out.rerank.status).toBe("applied");
+    expect(out.rerank.candidates).toBe(2);
+    expect(requests[0].body).toEqual({ model: "rerank-v3.5", query: "pool", documents: ["a\nabout a", "b\nabout b"], top_n: 2 });
+    expect(requests[0].headers.authorization).toBe("Bearer k");
+  });
+
+  test("tei format; unscored candidates keep their order after the scored ones", async () => {
+    const { impl, requests } = fakeFetch(() => [{ index: 2, score: 1.5 }]);
+    const out = await reranker(impl, { format: "tei" }).rerank("pool", [hit("a"), hit("b"), hit("c")]);
+    expect(out.results.map((r) => r.node_title)).toEqual(["c", "a", "b"]);
+    expect(requests[0].body).toEqual({ query: "pool", texts: ["a\nabout a", "b\nabout b", "c\nabout c"] });
+  });
+
+  test("past the budget, or on an error, the keyword order is kept", async () => {
+    const results = [hit("a"), hit("b")];
+    const slow = fakeFetch(() => [{ index: 1, score: 1 }], { delayMs: 200 });
+    const late = await reranker(slow.impl, { timeout_ms: 20 }).rerank("pool", results);
+    expect(late.results).toBe(results);
+    expect(late.rerank.status).toBe("timeout");
+    expect(late.rerank.reason).toBe("no answer within 20ms");
+
+    const down = fakeFetch(() => ({ message: "overloaded" }), { status: 503 });
+    const failed = await reranker(down.impl).rerank("pool", results);
+    expect(failed.results).toBe(results);
+    expect(failed.rerank).toEqual({ status: "error", candidates: 2, ms: failed.rerank.ms, reason: "HTTP 503" });
+  });
+
+  test("an expired call deadline skips the request", async () => {
+    const { impl, requests } = fakeFetch(() => []);
+    const deadline = new Deadline(1000);
+    deadline.cancel();
+    const out = await withDeadline(deadline, () => reranker(impl).rerank("pool", [hit("a"), hit("b")]));
+    expect(out.rerank.status).toBe("timeout");
+    expect(requests).toEqual([]);
+  });
+});
+
+describe("search_documents reranking", () => {
+  const docs = ["alpha", "beta", "gamma"].map((name, i) =>
+    makeDoc({
+      meta: { doc_id: `docs:${name}`, file_path: `${name}.md`, title: name },
+      tree: [makeNode({ node_id: `docs:${name}:n1`, title: name, content: "connection pool ".repeat(3 - i) })],
+    })
+  );
+
+  test("reorders results and reports the stage; rerank: false opts out", async () => {
+    // Ranks the candidates in reverse of what it was sent
+    const { impl, requests } = fakeFetch((body) => ({
+      results: body.documents.map((_: string, index: number) => ({ index, relevance_score: index })),
+    }));
+    const harness = await createMcpTestClient(docs, { reranker: reranker(impl, { top_k: 3 }) });
+    const search = (args: Record<string, unknown>) =>
+      harness.client.callTool({ name: "search_documents", arguments: { query: "connection pool", ...args } });
+
+    const result = await search({ limit: 1 });
+    const data = result.structuredContent as any;
+    expect(requests[0].body.documents.length).toBe(3);
+    expect(data.results.map((r: any) => r.doc_id)).toEqual(["docs:gamma"]);
+    expect(data.rerank.status).toBe("applied");
+
+    const plain = await search({ limit: 1, rerank: false });
+    expect((plain.structuredContent as any).results.map((r: any) => r.doc_id)).toEqual(["docs:alpha"]);
+    expect((plain.structuredContent as any).rerank).toBeUndefined();
+    expect(requests.length).toBe(1);
+    await harness.cleanup();
+  });
+
+  test("a failing reranker is noted in the text", async () => {
+    const { impl } = fakeFetch(() => ({}), { status: 500 });
+    const harness = await createMcpTestClient(docs, { reranker: reranker(impl) });
+    const result = await harness.client.callTool({ name: "search_documents", arguments: { query: "connection pool" } });
+    expect((result.structuredContent as any).results[0].doc_id).toBe("docs:alpha");
+    expect(getToolText(result as any)).toContain("Reranker skipped (HTTP 500); results are in keyword order.");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,59 @@
+/**
+ * Tests for the REST/JSON facade: routes, query parameters, ids with
+ * slashes, status codes, and the bearer token.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { handleRest } from "../src/rest";
+import { navigationHandlers } from "../src/grpc";
+import { DocumentStore } from "../src/store";
+import { makeDoc, makeNode } from "./fixtures/helpers";
+
+const store = new DocumentStore();
+store.load([
+  makeDoc({
+    meta: { doc_id: "docs:guides/auth", file_path: "guides/auth.md", title: "Auth", collection: "docs", facets: { type: ["guide"] } },
+    tree: [
+      makeNode({ node_id: "docs:guides/auth:n1", title: "Auth", children: ["docs:guides/auth:n2"], content: "Tokens are signed." }),
+      makeNode({ node_id: "docs:guides/auth:n2", title: "Rotation", level: 2, parent_id: "docs:guides/auth:n1", content: "Rotate keys monthly." }),
+    ],
+    root_nodes: ["docs:guides/auth:n1"],
+  }),
+]);
+const handlers = navigationHandlers(store);
+
+async function get(path: string, init?: RequestInit): Promise<{ status: number; body: any }> {
+  const response = await handleRest(new Request(`http://localhost${path}`, init), handlers, { token: init ? "s3cret" : undefined });
+  if (!response) return { status: 0, body: null };
+  return { status: response.status, body: await response.json() };
+}
+
+describe("handleRest", () => {
+  test("serves search, documents, trees, and subtrees as JSON", async () => {
+    const search = await get("/search?q=rotate&filter=type=guide&limit=5");
+    expect([search.status, search.body.results.map((r: any) => r.node_id)]).toEqual([200, ["docs:guides/auth:n2"]]);
+
+    expect((await get("/documents?tag=&limit=1")).body.total).toBe(1);
+    expect((await get("/tree/docs:guides/auth")).body.nodes).toHaveLength(2);
+
+    const subtree = await get(`/node/docs:guides/auth/${encodeURIComponent("docs:guides/auth:n1")}`);
+    expect(subtree.body.nodes.map((n: any) => n.title)).toEqual(["Auth", "Rotation"]);
+    expect((await get("/node/docs:guides/auth/docs:guides/auth:n2")).body.nodes).toHaveLength(1);
+  });
+
+  test("maps errors to HTTP statuses and leaves other paths alone", async () => {
+    expect((await get("/search")).status).toBe(400);
+    expect((await get("/search?q=x&limit=-1")).status).toBe(400);
+    expect((await get("/search?q=x&case=loud")).status).toBe(400);
+    expect((await get("/tree/docs:missing")).status).toBe(404);
+    expect((await get("/node/docs:guides/auth")).status).toBe(400);
+    expect((await get("/symbol/sign", { method: "POST" })).status).toBe(405);
+    expect((await get("/mcp")).status).toBe(0);
+  });
+
+  test("requires the bearer token when one is set", async () => {
+    const request = (headers: Record<string, string>) => get("/search?q=rotate", { headers });
+    expect((await request({})).status).toBe(401);
+    expect((await request({ authorization: "Bearer s3cret" })).status).toBe(200);
+  });
+});
@@ -0,0 +1,122 @@
+/**
+ * Tests for the result cache: keys, carrying entries forward across
+ * changes that touch no code file, dropping them on ones that do, and
+ * the graph tools answering repeated calls from it.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { ResultCache, resultKey } from "../src/result-cache";
+import { indexCodeFile } from "../src/code-indexer";
+import { UsageStats } from "../src/usage";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText, makeDoc } from "./fixtures/helpers";
+
+const answer = (text: string) => ({ content: [{ type: "text", text }], structuredContent: { text } });
+const code = (doc_id: string) => makeDoc({ meta: { doc_id, file_path: `${doc_id}.go`, facets: { content_type: ["code"] } } });
+const markdown = (doc_id: string) => makeDoc({ meta: { doc_id, file_path: `${doc_id}.md`, facets: { content_type: ["docs"] } } });
+
+describe("resultKey", () => {
+  test("ignores argument order and includes the focus", () => {
+    expect(resultKey("callers", { symbol: "Connect", depth: 2 })).toBe(resultKey("callers", { depth: 2, symbol: "Connect" }));
+    expect(resultKey("callers", { symbol: "Connect" })).not.toBe(resultKey("usage_stats", { symbol: "Connect" }));
+    expect(resultKey("panic_sites", {}, "db/")).not.toBe(resultKey("panic_sites", {}));
+  });
+});
+
+describe("ResultCache", () => {
+  test("carries entries over changes to non-code files and drops them on code changes", () => {
+    const store = new DocumentStore();
+    store.load([code("a"), markdown("readme")]);
+    const cache = new ResultCache(store);
+    cache.set("k", answer("one"), store.getGeneration());
+    expect(cache.get("k")).toEqual(answer("one"));
+
+    store.addDocument(markdown("guide"));
+    store.removeDocument("readme");
+    expect(cache.get("k")).toEqual(answer("one"));
+
+    store.addDocument(code("b"));
+    expect(cache.get("k")).toBeUndefined();
+    expect(cache.size).toBe(0);
+  });
+
+  test("drops entries when the store is reloaded", () => {
+    const store = new DocumentStore();
+    store.load([code("a")]);
+    const cache = new ResultCache(store);
+    cache.set("k", answer("one"), store.getGeneration());
+    store.load([code("a")]);
+    expect(cache.get("k")).toBeUndefined();
+  });
+
+  test("keeps no errors and no answers computed while validating", () => {
+    const store = new DocumentStore();
+    const cache = new ResultCache(store);
+    cache.set("error", { ...answer("bad"), isError: true }, store.getGeneration());
+    store.beginValidating();
+    cache.set("validating", answer("maybe stale"), store.getGeneration());
+    expect(cache.size).toBe(0);
+  });
+
+  test("evicts the least recently used entry beyond its size", () => {
+    const store = new DocumentStore();
+    const cache = new ResultCache(store, 2);
+    cache.set("a", answer("a"), 0);
+    cache.set("b", answer("b"), 0);
+    cache.get("a");
+    cache.set("c", answer("c"), 0);
+    expect(cache.get("b")).toBeUndefined();
+    expect(cache.get("a")).toEqual(answer("a"));
+    expect(cache.clear()).toBe(2);
+  });
+});
+
+describe("graph tools", () => {
+  let dir: string;
+  let config: IndexConfig;
+
+  beforeEach(async () => {
+    dir = await mkdtemp(join(tmpdir(), "treenav-result-cache-"));
+    await writeFile(join(dir, "conn.go"), "package db\n\nfunc Connect() {}\n\nfunc pool() { Connect() }\n");
+    await writeFile(join(dir, "serve.go"), "package db\n\nfunc serve() {\n\tConnect()\n}\n");
+    config = {
+      collections: [],
+      code_collections: [{ name: "code", root: dir, weight: 1.0 }],
+      summary_length: 200,
+      max_depth: 6,
+    };
+  });
+
+  afterEach(async () => {
+    await rm(dir, { recursive: true, force: true });
+  });
+
+  test("answer a repeated call from the cache until a code file changes", async () => {
+    const docs = [await indexCodeFile(join(dir, "conn.go"), dir, "code"), await indexCodeFile(join(dir, "serve.go"), dir, "code")];
+    const harness = await createMcpTestClient(docs, {
+      usage: new UsageStats(config),
+      resultCache: (store) => new ResultCache(store),
+    });
+    const call = () => harness.client.callTool({ name: "callers", arguments: { symbol: "Connect" } });
+
+    const first = await call();
+    expect((first.structuredContent as any).cached).toBeUndefined();
+    const second = await call();
+    expect((second.structuredContent as any).cached).toBe(true);
+    expect(getToolText(second as any)).toBe(getToolText(first as any));
+    expect((second.structuredContent as any).callers.map((c: any) => c.name)).toEqual(
+      (first.structuredContent as any).callers.map((c: any) => c.name)
+    );
+
+    await writeFile(join(dir, "serve.go"), "package db\n\nfunc serve() {}\n");
+    harness.store.addDocument(await indexCodeFile(join(dir, "serve.go"), dir, "code"));
+    const third = await call();
+    expect((third.structuredContent as any).cached).toBeUndefined();
+    expect((third.structuredContent as any).callers.map((c: any) => c.name)).toEqual(["pool"]);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,100 @@
+/**
+ * Tests for the per-file scan cache: reuse while the content hash holds,
+ * re-reading after a change, the path filter, unreadable files, and
+ * forgetting files that left the index.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { FileScanCache } from "../src/scan-cache";
+import { Deadline, withDeadline } from "../src/deadline";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-scan-cache-"));
+  await writeTree(dir, {
+    "main.go": "package main\n\nfunc main() {}\n",
+    "pkg/util.go": "package pkg\n\nfunc Util() {}\n",
+    "web.ts": "export const x = 1;\n",
+  });
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+const indexed = (...paths: string[]) => indexTree(dir, paths);
+
+/** A cache whose scanner records each file it reads. */
+function counting() {
+  const reads: string[] = [];
+  const cache = new FileScanCache(config, (source, meta) => {
+    reads.push(meta.file_path);
+    return `${meta.file_path}:${source.split("\n")[0]}`;
+  });
+  return { cache, reads };
+}
+
+describe("FileScanCache", () => {
+  test("reads each file once while its content hash holds", async () => {
+    const store = await indexed("main.go", "pkg/util.go");
+    const { cache, reads } = counting();
+    expect(await cache.scan(store)).toEqual(["main.go:package main", "pkg/util.go:package pkg"]);
+    await cache.scan(store);
+    expect(reads.sort()).toEqual(["main.go", "pkg/util.go"]);
+  });
+
+  test("re-reads a file whose content hash changed", async () => {
+    const { cache, reads } = counting();
+    await cache.scan(await indexed("main.go"));
+    await writeFile(join(dir, "main.go"), "package app\n");
+    expect(await cache.scan(await indexed("main.go"))).toEqual(["main.go:package app"]);
+    expect(reads).toEqual(["main.go", "main.go"]);
+  });
+
+  test("scans only accepted files under the path prefix", async () => {
+    const store = await indexed("main.go", "pkg/util.go", "web.ts");
+    const { cache } = counting();
+    expect(await cache.scan(store, { accept: (path) => path.endsWith(".go") })).toHaveLength(2);
+    expect(await cache.scan(store, { pathPrefix: "pkg" })).toEqual(["pkg/util.go:package pkg"]);
+  });
+
+  test("skips files it cannot read, and documents outside the code collections", async () => {
+    const store = await indexed("main.go", "pkg/util.go");
+    await rm(join(dir, "pkg/util.go"));
+    const { cache } = counting();
+    expect(await cache.scan(store)).toEqual(["main.go:package main"]);
+    const other = new FileScanCache({ ...config, code_collections: [] }, () => 1);
+    expect(await other.scan(store)).toEqual([]);
+    expect(other.root("code")).toBeUndefined();
+  });
+
+  test("forgets files that left the index after a full scan only", async () => {
+    const { cache } = counting();
+    await cache.scan(await indexed("main.go", "pkg/util.go"));
+    const smaller = await indexed("pkg/util.go");
+    await cache.scan(smaller, { pathPrefix: "pkg" });
+    expect(cache.clear()).toBe(2);
+
+    await cache.scan(await indexed("main.go", "pkg/util.go"));
+    await cache.scan(smaller);
+    expect(cache.clear()).toBe(1);
+  });
+
+  test("stops at the request deadline and keeps what it had", async () => {
+    const store = await indexed("main.go", "pkg/util.go");
+    const { cache } = counting();
+    await cache.scan(store);
+    const passed = new Deadline(0);
+    expect(await withDeadline(passed, () => cache.scan(new DocumentStore()))).toEqual([]);
+    expect(cache.clear()).toBe(2);
+  });
+});
@@ -1,5 +1,5 @@
 import { describe, test, expect } from "bun:test";
-import { formatSearchResults } from "../src/search-formatter";
+import { buildExplainLine, formatBatchResults, formatSearchResults } from "../src/search-formatter";
 import type { SubtreeProvider } from "../src/search-formatter";
 import type { SearchResult } from "../src/types";
 
@@ -17,6 +17,11 @@ function makeResult(overrides: Partial<SearchResult> = {}): SearchResult {
     score: 9.2,
     match_positions: [0],
     matched_terms: ["provision"],
+    line_start: 1,
+    line_end: 4,
+    uri: "treenav://file/webex-calling.md#L1-4",
+    snippet_highlights: [{ start: 3, end: 12 }],
+    content_matches: [{ start: 3, end: 12, line: 1, column: 4 }],
     collection: "docs",
     facets: {},
     ...overrides,
@@ -65,6 +70,25 @@ describe("formatSearchResults", () => {
     expect(out).not.toContain("Full content");
   });
 
+  test("lists file:line:col match locations for code results", () => {
+    const result = makeResult({
+      file_path: "src/retry.ts",
+      line_start: 10,
+      facets: { content_type: ["code"] },
+      content_matches: [
+        { start: 9, end: 21, line: 1, column: 10 },
+        { start: 34, end: 41, line: 2, column: 6 },
+      ],
+    });
+    const out = formatSearchResults([result], makeStore(), "retry");
+    expect(out).toContain("Matches: src/retry.ts:10:10, src/retry.ts:11:6");
+  });
+
+  test("omits match locations for markdown results", () => {
+    const out = formatSearchResults([makeResult()], makeStore(), "provision");
+    expect(out).not.toContain("Matches:");
+  });
+
   test("shows facet badge for code_languages", () => {
     const result = makeResult({ facets: { code_languages: ["javascript", "python"] } });
     const out = formatSearchResults([result], makeStore(), "provision");
@@ -134,3 +158,51 @@ describe("formatSearchResults", () => {
     expect(out).toContain("5. [webex-calling]");
   });
 });
+
+describe("formatBatchResults", () => {
+  test("lists each query's results under its own heading", () => {
+    const out = formatBatchResults(
+      [
+        { query: "provision", results: [makeResult()] },
+        { query: "webex", results: [] },
+      ],
+      makeStore({ suggest: () => ["WebexClient"] })
+    );
+    expect(out).toContain('━━━ 1/2: "provision" (1 matches) ━━━');
+    expect(out).toContain("1. [webex-calling] Webex Calling Guide");
+    expect(out).toContain('━━━ 2/2: "webex" (0 matches) ━━━\n\nNo results. Did you mean: WebexClient?');
+  });
+
+  test("never inlines content", () => {
+    const out = formatBatchResults([{ query: "provision", results: [makeResult()] }], makeStore());
+    expect(out).not.toContain("Full content here.");
+  });
+});
+
+describe("buildExplainLine", () => {
+  const explain = {
+    terms: { provision: 3, provisioning: 1 },
+    bm25: 3,
+    prefix: 1,
+    proximity: 0,
+    all_terms: 0,
+    collection: 1,
+    path: 1.5,
+    recency: 1,
+    generated: 1,
+    popularity: 1.2,
+    coverage: 1,
+  };
+
+  test("shows the sum, the multipliers that changed it, and the terms", () => {
+    expect(buildExplainLine(makeResult({ score: 7.2, explain }))).toBe(
+      "\n   Explain: (bm25 3.00 + prefix 1.00) × path 1.50 × popularity 1.20 = 7.20\n   Terms: provision 3.00, provisioning 1.00"
+    );
+    expect(buildExplainLine(makeResult())).toBe("");
+  });
+
+  test("notes a RANKING_WASM rescore", () => {
+    const line = buildExplainLine(makeResult({ score: 2, explain: { ...explain, before_rescore: 7.2 } }));
+    expect(line).toContain("= 7.20, RANKING_WASM → 2.00");
+  });
+});
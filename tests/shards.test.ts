@@ -0,0 +1,204 @@
+/**
+ * Tests for the sharded index.
+ *
+ * Covers: shard assignment, build + manifest, parallel load, subset
+ * load, partial rebuild, shard-targeted search via the facet filter,
+ * and distributed builds (worker split, slices, merge, coordinator).
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir, unlink } from "node:fs/promises";
+import { existsSync } from "node:fs";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DocumentStore } from "../src/store";
+import { singleRootConfig } from "../src/types";
+import {
+  assignShards,
+  buildShards,
+  buildShardSlice,
+  buildShardsDistributed,
+  loadShards,
+  loadOrBuildShards,
+  mergeShards,
+  shardFile,
+  shardOf,
+  ROOT_SHARD,
+} from "../src/shards";
+import type { IndexConfig } from "../src/types";
+
+let dir: string;
+let docsRoot: string;
+let shardDir: string;
+let config: IndexConfig;
+
+async function put(relPath: string, body: string) {
+  const full = join(docsRoot, relPath);
+  await mkdir(join(full, ".."), { recursive: true });
+  await writeFile(full, body);
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-shards-"));
+  docsRoot = join(dir, "docs");
+  shardDir = join(dir, "shards");
+  await put("index.md", "# Home\n\nLanding page.\n");
+  await put("payments/refunds.md", "# Refunds\n\nRefund settlement.\n");
+  await put("platform/deploy.md", "# Deploy\n\nDeployment settlement window.\n");
+  config = singleRootConfig(docsRoot);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("shardOf", () => {
+  test("uses the top-level directory", () => {
+    expect(shardOf("payments/api/refunds.md")).toBe("payments");
+    expect(shardOf("index.md")).toBe(ROOT_SHARD);
+    expect(shardOf("_root/index.md")).not.toBe(ROOT_SHARD);
+  });
+});
+
+describe("shardFile", () => {
+  test("gives distinct names distinct files", () => {
+    const names = ["a b", "a_b", "a-b", "A_b", "é", "ü", ROOT_SHARD, "_root", "..", "_"];
+    const files = names.map((n) => shardFile("docs", n));
+    expect(new Set(files.map((f) => f.toLowerCase())).size).toBe(names.length);
+    expect(shardFile("a b", "x")).not.toBe(shardFile("a_b", "x"));
+    expect(files.every((f) => !f.split(/[/\\]/).some((part) => part.startsWith(".")))).toBe(true);
+  });
+
+  test("keeps colliding directory names in separate shards", async () => {
+    await put("a b/one.md", "# One\n\nFirst space note.\n");
+    await put("a_b/two.md", "# Two\n\nSecond underscore note.\n");
+    await put("_root/three.md", "# Three\n\nThird note.\n");
+    await buildShards(config, shardDir, { log: () => {} });
+    const store = new DocumentStore();
+    await loadShards(store, config, shardDir);
+    expect(store.getStats().document_count).toBe(6);
+  });
+});
+
+describe("buildShards / loadShards", () => {
+  test("builds one shard per top-level directory", async () => {
+    const manifest = await buildShards(config, shardDir, { log: () => {} });
+    expect(manifest.shards.map((s) => s.id)).toEqual(["docs/.", "docs/payments", "docs/platform"]);
+  });
+
+  test("loads every shard into the store", async () => {
+    await buildShards(config, shardDir, { log: () => {} });
+    const store = new DocumentStore();
+    const result = await loadShards(store, config, shardDir);
+
+    expect(result!.loaded.length).toBe(3);
+    expect(store.getStats().document_count).toBe(3);
+  });
+
+  test("loads only the requested subset", async () => {
+    await buildShards(config, shardDir, { log: () => {} });
+    const store = new DocumentStore();
+    await loadShards(store, config, shardDir, { only: ["docs/payments"] });
+
+    expect(store.getDocMeta("docs:payments:refunds")).not.toBeNull();
+    expect(store.getDocMeta("docs:platform:deploy")).toBeNull();
+  });
+
+  test("returns null without a manifest", async () => {
+    expect(await loadShards(new DocumentStore(), config, shardDir)).toBeNull();
+  });
+
+  test("partial rebuild only touches the named shard", async () => {
+    const first = await buildShards(config, shardDir, { log: () => {} });
+    const platformBuilt = first.shards.find((s) => s.id === "docs/platform")!.built_at;
+
+    await put("payments/chargebacks.md", "# Chargebacks\n\nDisputes.\n");
+    await new Promise((r) => setTimeout(r, 5));
+    const second = await buildShards(config, shardDir, { only: ["docs/payments"], log: () => {} });
+
+    expect(second.shards.find((s) => s.id === "docs/payments")!.document_count).toBe(2);
+    expect(second.shards.find((s) => s.id === "docs/platform")!.built_at).toBe(platformBuilt);
+  });
+
+  test("drops shards whose directory is gone", async () => {
+    await buildShards(config, shardDir, { log: () => {} });
+    await unlink(join(docsRoot, "platform", "deploy.md"));
+    const manifest = await buildShards(config, shardDir, { log: () => {} });
+    expect(manifest.shards.map((s) => s.id)).not.toContain("docs/platform");
+  });
+});
+
+describe("loadOrBuildShards", () => {
+  test("builds shards on first start, then targets them by facet", async () => {
+    const store = new DocumentStore();
+    const loaded = await loadOrBuildShards(store, config, shardDir, { log: () => {} });
+    expect(loaded.length).toBe(3);
+
+    const all = store.searchDocuments("settlement");
+    expect(all.length).toBe(2);
+
+    const scoped = store.searchDocuments("settlement", { filters: { shard: ["docs/payments"] } });
+    expect(scoped.map((r) => r.doc_id)).toEqual(["docs:payments:refunds"]);
+  });
+});
+
+describe("distributed build", () => {
+  test("assignShards balances by size and is deterministic", () => {
+    const shards = [
+      { id: "c", bytes: 5 },
+      { id: "a", bytes: 10 },
+      { id: "d", bytes: 3 },
+      { id: "b", bytes: 7 },
+    ];
+    expect(assignShards(shards, 2)).toEqual([["a", "d"], ["b", "c"]]);
+    expect(assignShards([...shards].reverse(), 2)).toEqual([["a", "d"], ["b", "c"]]);
+    expect(assignShards(shards, 6).filter((ids) => ids.length === 0)).toHaveLength(2);
+  });
+
+  test("worker slices cover every shard once, and merge writes the manifest", async () => {
+    const first = await buildShardSlice(config, shardDir, 1, 2, { log: () => {} });
+    const second = await buildShardSlice(config, shardDir, 2, 2, { log: () => {} });
+    expect([...first, ...second].map((e) => e.id).sort()).toEqual(["docs/.", "docs/payments", "docs/platform"]);
+    expect(existsSync(join(shardDir, "manifest.json"))).toBe(false);
+
+    const { manifest, missing } = await mergeShards(config, shardDir);
+    expect(missing).toEqual([]);
+    expect(manifest.shards.map((s) => [s.id, s.document_count])).toEqual([
+      ["docs/.", 1],
+      ["docs/payments", 1],
+      ["docs/platform", 1],
+    ]);
+    const store = new DocumentStore();
+    expect((await loadShards(store, config, shardDir))!.loaded).toHaveLength(3);
+  });
+
+  test("merge reports the shards of a worker that never ran", async () => {
+    const built = await buildShardSlice(config, shardDir, 1, 2, { log: () => {} });
+    const { manifest, missing } = await mergeShards(config, shardDir);
+    expect(manifest.shards.map((s) => s.id)).toEqual(built.map((e) => e.id).sort());
+    expect(missing).toHaveLength(3 - built.length);
+  });
+
+  test("rejects a worker outside 1..count", async () => {
+    await expect(buildShardSlice(config, shardDir, 3, 2)).rejects.toThrow(RangeError);
+  });
+
+  test("the coordinator rebuilds what a failed worker left out", async () => {
+    const result = await buildShardsDistributed(config, shardDir, {
+      workers: 2,
+      run: async (worker, count) => {
+        if (worker === 2) return 1;
+        await buildShardSlice(config, shardDir, worker, count, { log: () => {} });
+        return 0;
+      },
+      log: () => {},
+    });
+    expect(result.failed_workers).toEqual([2]);
+    expect(result.rebuilt.length).toBeGreaterThan(0);
+    expect(result.manifest.shards).toHaveLength(3);
+
+    const store = new DocumentStore();
+    await loadShards(store, config, shardDir);
+    expect(store.getStats().document_count).toBe(3);
+  });
+});
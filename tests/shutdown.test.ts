@@ -0,0 +1,106 @@
+/**
+ * Tests for graceful shutdown: draining in-flight calls, cancelling
+ * the ones that outlive the grace period, flush order, and how tool
+ * calls behave while the server shuts down.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { mkdtemp, readdir, rm } from "node:fs/promises";
+import { tmpdir } from "node:os";
+import { basename, dirname, join } from "node:path";
+import { Shutdown } from "../src/shutdown";
+import { currentDeadline } from "../src/deadline";
+import { saveIndexCache } from "../src/index-cache";
+import type { Hotspots } from "../src/hotspots";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const quiet = () => {};
+
+describe("Shutdown", () => {
+  test("waits for in-flight calls, then flushes in order", async () => {
+    const shutdown = new Shutdown({ graceMs: 1_000, log: quiet });
+    const steps: string[] = [];
+    shutdown.onStop("listener", () => steps.push("stop"));
+    shutdown.onFlush("watcher", async () => {
+      await Bun.sleep(1);
+      steps.push("watcher");
+    });
+    shutdown.onFlush("cache", () => steps.push("cache"));
+    shutdown.track(
+      Bun.sleep(20).then(() => steps.push("call")),
+      () => steps.push("cancelled")
+    );
+
+    const done = shutdown.run("SIGTERM");
+    expect(shutdown.draining).toBe(true);
+    expect(shutdown.run("SIGTERM")).toBe(done);
+    expect(await done).toBe(true);
+    expect(steps).toEqual(["stop", "call", "watcher", "cache"]);
+    expect(shutdown.inFlight()).toBe(0);
+  });
+
+  test("cancels calls still running after the grace period", async () => {
+    const shutdown = new Shutdown({ graceMs: 5, log: quiet });
+    let release: () => void = () => {};
+    shutdown.track(new Promise<void>((resolve) => (release = resolve)), () => release());
+    let flushed = false;
+    shutdown.onFlush("cache", () => (flushed = true));
+    expect(await shutdown.run("SIGTERM")).toBe(true);
+    expect(flushed).toBe(true);
+  });
+
+  test("a failing step does not stop the others", async () => {
+    const logs: string[] = [];
+    const shutdown = new Shutdown({ log: (msg) => logs.push(msg) });
+    shutdown.onFlush("watcher", () => {
+      throw new Error("disk full");
+    });
+    let saved = false;
+    shutdown.onFlush("cache", () => (saved = true));
+    expect(await shutdown.run("SIGINT")).toBe(false);
+    expect(saved).toBe(true);
+    expect(logs).toContain('Warning: shutdown step "watcher" failed: disk full');
+  });
+});
+
+describe("saveIndexCache", () => {
+  test("leaves no temp file behind when the write fails", async () => {
+    const dir = await mkdtemp(join(tmpdir(), "treenav-shutdown-"));
+    // The target is a directory, so the rename fails after the temp write
+    await expect(saveIndexCache(dir, { collections: [] } as any, [])).rejects.toThrow();
+    expect((await readdir(dirname(dir))).some((f) => f.startsWith(`${basename(dir)}.tmp-`))).toBe(false);
+    await rm(dir, { recursive: true, force: true });
+  });
+});
+
+describe("tool calls during shutdown", () => {
+  test("new calls are refused once draining starts", async () => {
+    const shutdown = new Shutdown({ log: quiet });
+    const harness = await createMcpTestClient([], { shutdown });
+    await shutdown.run("SIGTERM");
+    const result = await harness.client.callTool({ name: "list_documents", arguments: {} });
+    expect(result.isError).toBe(true);
+    expect(getToolText(result as any)).toContain("server is shutting down; list_documents was not run");
+    await harness.cleanup();
+  });
+
+  test("a call cut short by shutdown answers with partial results", async () => {
+    // Stops at its deadline like the real scans do
+    const hotspots = {
+      rank: async () => {
+        while (!currentDeadline()?.expired()) await Bun.sleep(1);
+        return { files: 1, changed: 0, hotspots: [] };
+      },
+    } as unknown as Hotspots;
+    const shutdown = new Shutdown({ graceMs: 5, log: quiet });
+    const harness = await createMcpTestClient([], { hotspots, shutdown });
+    const call = harness.client.callTool({ name: "hotspots", arguments: {} });
+    await Bun.sleep(5);
+    expect(shutdown.inFlight()).toBe(1);
+    await shutdown.run("SIGTERM");
+    const result = await call;
+    expect((result.structuredContent as any).timed_out).toBe(true);
+    expect(getToolText(result as any)).toStartWith("Note: the server began shutting down while answering");
+    await harness.cleanup();
+  });
+});
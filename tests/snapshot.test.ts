@@ -0,0 +1,110 @@
+/**
+ * Tests for portable snapshots: export, import into a clone at another
+ * path, and the version, config, and commit checks.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { checkSnapshot, exportSnapshot, importSnapshot, readSnapshot, SnapshotError } from "../src/snapshot";
+import { indexAllCollections } from "../src/indexer";
+import { loadIndexCache } from "../src/index-cache";
+import { runImportCommand, runIndexCommand } from "../src/cli";
+import type { IndexConfig } from "../src/types";
+import { commandArgs } from "./fixtures/cli";
+import { git, initRepo } from "./fixtures/git";
+
+let dir: string;
+
+function docsConfig(repo: string, glob?: string): IndexConfig {
+  return {
+    collections: [{ name: "docs", root: join(repo, "docs"), weight: 1.0, ...(glob ? { glob_pattern: glob } : {}) }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-snapshot-"));
+  const ci = join(dir, "ci");
+  await mkdir(join(ci, "docs"), { recursive: true });
+  await writeFile(join(ci, "docs", "guide.md"), "# Guide\n\nSetup steps.\n");
+  initRepo(ci, "guide");
+  git(dir, ["clone", "-q", ci, join(dir, "laptop")]);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+async function exported(): Promise<string> {
+  const config = docsConfig(join(dir, "ci"));
+  const path = join(dir, "out", "snapshot.json.gz");
+  await exportSnapshot(path, config, await indexAllCollections(config));
+  return path;
+}
+
+describe("exportSnapshot", () => {
+  test("records the commit and where the root sits in the repository", async () => {
+    const snapshot = await readSnapshot(await exported());
+    expect(snapshot.documents.map((d) => d.meta.doc_id)).toEqual(["docs:guide"]);
+    const [docs] = snapshot.collections;
+    expect([docs.name, docs.kind, docs.repo_path, docs.dirty]).toEqual(["docs", "markdown", "docs", false]);
+    expect(docs.commit).toMatch(/^[0-9a-f]{40}$/);
+  });
+});
+
+describe("importSnapshot", () => {
+  test("installs the documents for a clone at another path", async () => {
+    const config = docsConfig(join(dir, "laptop"));
+    const cache = join(dir, "laptop", ".treenav", "index.json");
+    const { drift } = await importSnapshot(await exported(), config, cache);
+    expect(drift).toEqual([]);
+    expect((await loadIndexCache(cache, config))!.map((d) => d.meta.doc_id)).toEqual(["docs:guide"]);
+  });
+
+  test("refuses another commit unless forced", async () => {
+    const laptop = join(dir, "laptop");
+    await writeFile(join(laptop, "docs", "faq.md"), "# FAQ\n");
+    git(laptop, ["add", "."]);
+    git(laptop, ["commit", "-q", "-m", "faq"]);
+    const path = await exported();
+    const cache = join(laptop, ".treenav", "index.json");
+
+    await expect(importSnapshot(path, docsConfig(laptop), cache)).rejects.toThrow("Pass --force");
+    const { drift } = await importSnapshot(path, docsConfig(laptop), cache, { force: true });
+    expect(drift).toHaveLength(1);
+    expect(drift[0]).toMatch(/^docs: exported at [0-9a-f]{12}, HEAD here is [0-9a-f]{12}$/);
+  });
+
+  test("rejects snapshots built with another config", async () => {
+    const snapshot = await readSnapshot(await exported());
+    expect(checkSnapshot(snapshot, docsConfig(join(dir, "laptop"), "**/*.markdown")).incompatible).toEqual([
+      'docs: glob "", configured "**/*.markdown"',
+    ]);
+    expect(checkSnapshot({ ...snapshot, index_cache_version: 99 }, docsConfig(join(dir, "laptop"))).incompatible).toEqual([
+      "index schema version 99, expected 5",
+    ]);
+    await writeFile(join(dir, "junk.gz"), "not a snapshot");
+    await expect(readSnapshot(join(dir, "junk.gz"))).rejects.toThrow(SnapshotError);
+  });
+});
+
+describe("index --export and import", () => {
+  test("round-trip through the CLI", async () => {
+    const path = join(dir, "snapshot.json.gz");
+    const quiet = () => {};
+    const ci = await commandArgs("index", [join(dir, "ci", "docs"), "--out", join(dir, "ci.json"), "--export", path]);
+    expect(await runIndexCommand(ci, quiet)).toBe(0);
+
+    const lines: string[] = [];
+    const cache = join(dir, "laptop.json");
+    const laptop = await commandArgs("import", [path, "--root", join(dir, "laptop", "docs"), "--out", cache]);
+    const code = await runImportCommand(laptop, (t) => lines.push(t));
+    expect(code).toBe(0);
+    expect(lines.join("\n")).toContain("Imported 1 documents");
+    const missing = await commandArgs("import", [join(dir, "missing.gz"), "--out", cache]);
+    expect(await runImportCommand(missing, quiet)).toBe(1);
+  });
+});
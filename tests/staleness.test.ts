@@ -0,0 +1,107 @@
+/**
+ * Tests for stale-result detection: the stat-then-hash check, re-index
+ * of stale files, and the search tools flagging or refreshing results.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm, utimes, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { StaleCheck } from "../src/staleness";
+import { indexFile } from "../src/indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+let dir: string;
+let config: IndexConfig;
+
+async function indexedStore(): Promise<DocumentStore> {
+  const store = new DocumentStore();
+  store.load([await indexFile(join(dir, "auth.md"), dir, "docs"), await indexFile(join(dir, "cache.md"), dir, "docs")]);
+  return store;
+}
+
+/** Rewrite a file with an mtime no index run shares. */
+async function edit(name: string, content: string) {
+  await writeFile(join(dir, name), content);
+  await utimes(join(dir, name), new Date(), new Date("2031-01-01"));
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-stale-"));
+  await writeFile(join(dir, "auth.md"), "# Auth\n\nTokens are signed with HMAC.\n");
+  await writeFile(join(dir, "cache.md"), "# Cache\n\nTokens are cached for an hour.\n");
+  config = {
+    collections: [{ name: "docs", root: dir, weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("StaleCheck", () => {
+  test("flags modified and deleted files and passes touched ones", async () => {
+    const store = await indexedStore();
+    const check = new StaleCheck(store, config);
+    expect(await check.check(["docs:auth", "docs:cache", "docs:missing"])).toEqual([]);
+
+    // Same content, new mtime: hashed once, then fresh
+    await utimes(join(dir, "cache.md"), new Date(), new Date("2030-01-01"));
+    expect(await check.check(["docs:cache"])).toEqual([]);
+
+    await edit("auth.md", "# Auth\n\nTokens are signed with Ed25519.\n");
+    await rm(join(dir, "cache.md"));
+    expect(await check.check(["docs:auth", "docs:cache", "docs:auth"])).toEqual([
+      { doc_id: "docs:auth", collection: "docs", file_path: "auth.md", reason: "modified" },
+      { doc_id: "docs:cache", collection: "docs", file_path: "cache.md", reason: "deleted" },
+    ]);
+  });
+
+  test("re-indexes modified files and drops deleted ones", async () => {
+    const store = await indexedStore();
+    const check = new StaleCheck(store, config);
+    await edit("auth.md", "# Auth\n\nTokens are signed with Ed25519.\n");
+    await rm(join(dir, "cache.md"));
+    await check.reindex(await check.check(["docs:auth", "docs:cache"]));
+
+    expect(store.hasDocument("docs:cache")).toBe(false);
+    expect(store.searchDocuments("Ed25519").map((r) => r.doc_id)).toEqual(["docs:auth"]);
+    expect(await check.check(["docs:auth"])).toEqual([]);
+  });
+});
+
+describe("search tools", () => {
+  test("flag hits from files changed since indexing", async () => {
+    const docs = (await indexedStore()).exportDocuments();
+    const harness = await createMcpTestClient(docs, { staleness: (store) => new StaleCheck(store, config) });
+    await edit("auth.md", "# Auth\n\nTokens are signed with Ed25519.\n");
+
+    const result = await harness.client.callTool({ name: "search_documents", arguments: { query: "tokens" } });
+    const hits = (result.structuredContent as any).results;
+    expect(Object.fromEntries(hits.map((h: any) => [h.doc_id, h.stale ?? null]))).toEqual({
+      "docs:auth": "modified",
+      "docs:cache": null,
+    });
+    expect(getToolText(result as any)).toContain("Note: 1 file(s) changed since indexing; their results may be out of date: auth.md (modified).");
+    await harness.cleanup();
+  });
+
+  test("re-index stale files and search again with refresh", async () => {
+    const docs = (await indexedStore()).exportDocuments();
+    const harness = await createMcpTestClient(docs, {
+      staleness: (store) => new StaleCheck(store, config, { refresh: true }),
+    });
+    await edit("auth.md", "# Auth\n\nSessions are signed with Ed25519.\n");
+
+    const result = await harness.client.callTool({ name: "multi_search", arguments: { queries: ["HMAC", "Ed25519"] } });
+    const data = result.structuredContent as any;
+    expect(data.refreshed).toEqual(["auth.md"]);
+    expect(data.groups.map((g: any) => g.results.length)).toEqual([0, 1]);
+    expect(getToolText(result as any)).toContain("Note: re-indexed 1 file(s) changed since indexing: auth.md.");
+    await harness.cleanup();
+  });
+});
@@ -1,11 +1,15 @@
 /**
  * Tests for the DocumentStore — BM25 search, facet filtering,
- * glossary expansion, description weight, tree navigation.
+ * glossary expansion, description weight, tree navigation, case
+ * matching, word boundaries, boolean queries, phrases, proximity, Unicode
+ * normalization, and stepping between symbols.
  */
 
 import { describe, test, expect, beforeEach } from "bun:test";
-import { DocumentStore } from "../src/store";
-import type { IndexedDocument, TreeNode, DocumentMeta } from "../src/types";
+import { DocumentStore, MAX_JOURNAL_CHANGES } from "../src/store";
+import { indexCodeContent } from "../src/code-indexer";
+import type { IndexedDocument, TreeNode, DocumentMeta, SearchResult } from "../src/types";
+import { DEFAULT_RANKING } from "../src/types";
 
 // ── Test helpers ────────────────────────────────────────────────────
 
@@ -542,6 +546,48 @@ describe("glossary query expansion", () => {
 
 // ── Description weight ──────────────────────────────────────────────
 
+describe("synonym groups", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "docs:authn", file_path: "authn.md", title: "Authn" },
+        tree: [makeNode({ node_id: "docs:authn:n1", title: "Authn", content: "The authn service issues sessions." })],
+      }),
+      makeDoc({
+        meta: { doc_id: "docs:db", file_path: "db.md", title: "Storage" },
+        tree: [makeNode({ node_id: "docs:db:n1", title: "Storage", content: "Every database runs on Falcon hosts." })],
+      }),
+    ]);
+    // Silence the load log line
+    const log = console.error;
+    console.error = () => {};
+    store.loadSynonyms([["auth", "authn", "authentication"], ["db", "database"], ["falcon", "payments platform"]]);
+    console.error = log;
+  });
+
+  test("expands every member to the others", () => {
+    expect(store.searchDocuments("authentication")[0]?.doc_id).toBe("docs:authn");
+    expect(store.searchDocuments("db")[0]?.doc_id).toBe("docs:db");
+  });
+
+  test("matches stemmed forms of a member", () => {
+    expect(store.searchDocuments("authentications")[0]?.doc_id).toBe("docs:authn");
+  });
+
+  test("multi-word members are expansion targets only", () => {
+    expect(store.searchDocuments("falcon")[0]?.doc_id).toBe("docs:db");
+    expect(store.searchDocuments("payments")).toEqual([]);
+  });
+
+  test("survives a glossary reload", () => {
+    store.loadGlossary({});
+    expect(store.searchDocuments("authentication")[0]?.doc_id).toBe("docs:authn");
+  });
+});
+
 describe("description weight", () => {
   test("description terms in first node get boosted weight", () => {
     const store = new DocumentStore();
@@ -841,6 +887,255 @@ describe("resolveRef", () => {
   });
 });
 
+describe("case matching", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "code:client", file_path: "client.ts", title: "client.ts" },
+        tree: [
+          makeNode({ node_id: "code:client:n1", title: "class HttpClient", content: "class HttpClient { send() {} }" }),
+          makeNode({ node_id: "code:client:n2", title: "httpclient config", content: "The httpclient key in settings." }),
+        ],
+      }),
+    ]);
+  });
+
+  const titles = (query: string, mode?: "sensitive" | "insensitive" | "smart") =>
+    store.searchDocuments(query, { case: mode }).map((r) => r.node_title).sort();
+
+  test("is insensitive by default", () => {
+    expect(titles("HttpClient")).toEqual(["class HttpClient", "httpclient config"]);
+  });
+
+  test("sensitive keeps only exact-case occurrences", () => {
+    expect(titles("HttpClient", "sensitive")).toEqual(["class HttpClient"]);
+    expect(titles("httpclient", "sensitive")).toEqual(["httpclient config"]);
+  });
+
+  test("sensitive drops nodes where a query word appears only in another case", () => {
+    expect(titles("HttpClient settings", "sensitive")).toEqual(["class HttpClient"]);
+  });
+
+  test("smart is sensitive only for queries with uppercase", () => {
+    expect(titles("httpclient", "smart")).toEqual(["class HttpClient", "httpclient config"]);
+    expect(titles("HttpClient", "smart")).toEqual(["class HttpClient"]);
+  });
+});
+
+describe("word boundaries", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "code:tree", file_path: "tree.ts", title: "tree.ts" },
+        tree: [
+          makeNode({ node_id: "code:tree:n1", title: "class Node", content: "class Node { parent: Node | null }" }),
+          makeNode({ node_id: "code:tree:n2", title: "interface NodeInfo", content: "interface NodeInfo { depth: number }" }),
+          makeNode({ node_id: "code:tree:n3", title: "const nodesMap", content: "const nodesMap = new Map(activeNodes)" }),
+        ],
+      }),
+    ]);
+  });
+
+  const titles = (query: string, options: { word_boundaries?: boolean; case?: "sensitive" } = {}) =>
+    store.searchDocuments(query, options).map((r) => r.node_title).sort();
+
+  test("prefix matching is on by default", () => {
+    expect(titles("node")).toContain("interface NodeInfo");
+  });
+
+  test("matches whole words only", () => {
+    expect(titles("node", { word_boundaries: true })).toEqual(["class Node"]);
+  });
+
+  test("combines with case-sensitive matching", () => {
+    expect(titles("node", { word_boundaries: true, case: "sensitive" })).toEqual([]);
+    expect(titles("Node", { word_boundaries: true, case: "sensitive" })).toEqual(["class Node"]);
+  });
+});
+
+describe("boolean queries", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "code:db", file_path: "db.ts", title: "db.ts" },
+        tree: [
+          makeNode({ node_id: "code:db:n1", title: "function connect", content: "Open a pool connection with retry." }),
+          makeNode({ node_id: "code:db:n2", title: "test connect", content: "A test that mocks connect." }),
+          makeNode({ node_id: "code:db:n3", title: "function close", content: "Close the pool." }),
+        ],
+      }),
+    ]);
+  });
+
+  const titles = (query: string) => store.searchDocuments(query).map((r) => r.node_title).sort();
+
+  test("NOT and -term exclude matching nodes", () => {
+    expect(titles("connect AND NOT test")).toEqual(["function connect"]);
+    expect(titles("connect -test")).toEqual(["function connect"]);
+  });
+
+  test("AND requires every term, unlike a plain query", () => {
+    expect(titles("connect pool")).toEqual(["function close", "function connect", "test connect"]);
+    expect(titles("connect AND pool")).toEqual(["function connect"]);
+  });
+
+  test("OR and grouping", () => {
+    expect(titles("(close OR retry) pool")).toEqual(["function close", "function connect"]);
+  });
+
+  test("a query with only negated terms matches nothing", () => {
+    expect(titles("NOT test")).toEqual([]);
+  });
+});
+
+describe("phrase search", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "docs:errors", file_path: "errors.md", title: "Errors" },
+        tree: [
+          makeNode({ node_id: "docs:errors:n1", title: "Offline", content: "The client is not connected." }),
+          makeNode({ node_id: "docs:errors:n2", title: "Online", content: "Connected clients are not throttled." }),
+        ],
+      }),
+    ]);
+  });
+
+  const titles = (query: string) => store.searchDocuments(query).map((r) => r.node_title).sort();
+
+  test("requires the words adjacent and in order", () => {
+    expect(titles("not connected")).toEqual(["Offline", "Online"]);
+    expect(titles('"not connected"')).toEqual(["Offline"]);
+    expect(titles('"connected not"')).toEqual([]);
+  });
+
+  test("combines with loose words and operators", () => {
+    expect(titles('"not connected" client')).toEqual(["Offline"]);
+    expect(titles('throttled OR "not connected"')).toEqual(["Offline", "Online"]);
+    expect(titles('clients -"not connected"')).toEqual(["Online"]);
+  });
+});
+
+describe("proximity search", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "code:net", file_path: "net.go", title: "net.go" },
+        tree: [
+          makeNode({ node_id: "code:net:n1", title: "func Dial", content: "conn := Connect(ctx, addr)" }),
+          makeNode({
+            node_id: "code:net:n2",
+            title: "func Serve",
+            content: "ctx is created here and passed through many layers of setup before we finally Connect",
+          }),
+        ],
+      }),
+      makeDoc({
+        meta: { doc_id: "docs:load", file_path: "load.md", title: "load.md" },
+        tree: [makeNode({ node_id: "docs:load:n1", title: "Loading", content: "load 設定ファイルを読み込む関数です then parse" })],
+      }),
+    ]);
+  });
+
+  const titles = (query: string) => store.searchDocuments(query).map((r) => r.node_title).sort();
+
+  test("matches only where the terms are within n tokens", () => {
+    expect(titles("Connect ctx")).toEqual(["func Dial", "func Serve"]);
+    expect(titles("Connect NEAR/5 ctx")).toEqual(["func Dial"]);
+    expect(titles("Connect NEAR/20 ctx")).toEqual(["func Dial", "func Serve"]);
+  });
+
+  test("is order-independent and works with phrases", () => {
+    expect(titles("ctx NEAR/2 connect")).toEqual(["func Dial"]);
+    expect(titles('"finally connect" NEAR/3 before')).toEqual(["func Serve"]);
+  });
+
+  test("counts a CJK run as one word", () => {
+    expect(titles("load NEAR/3 parse")).toEqual(["Loading"]);
+    expect(titles("load NEAR/1 parse")).toEqual([]);
+    expect(titles("設定 NEAR/2 parse")).toEqual(["Loading"]);
+  });
+
+  test("matches phrases that span a CJK run", () => {
+    expect(titles('"load 設定ファイルを読み込む関数です then"')).toEqual(["Loading"]);
+    expect(titles('"関数です then parse"')).toEqual(["Loading"]);
+    expect(titles('"load then"')).toEqual([]);
+  });
+});
+
+describe("Unicode text", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "docs:cafe", file_path: "cafe.md", title: "Caf\u0065\u0301 Menu" },
+        tree: [
+          makeNode({
+            node_id: "docs:cafe:n1",
+            title: "Caf\u0065\u0301 Menu",
+            // Decomposed: "e" followed by a combining acute accent
+            content: "The cre\u0300me bru\u0302le\u0301e recipe lives in re\u0301sume\u0301 notes.",
+          }),
+        ],
+      }),
+      makeDoc({
+        meta: { doc_id: "docs:settings", file_path: "settings.md", title: "ユーザー設定" },
+        tree: [
+          makeNode({
+            node_id: "docs:settings:n1",
+            title: "ユーザー設定",
+            content: "設定ファイルを読み込む関数です。",
+          }),
+        ],
+      }),
+    ]);
+  });
+
+  test("NFC and NFD spellings match each other", () => {
+    expect(store.searchDocuments("crème")[0]?.doc_id).toBe("docs:cafe");
+    expect(store.searchDocuments("cre\u0300me")[0]?.doc_id).toBe("docs:cafe");
+  });
+
+  test("accented words are not split at the accent", () => {
+    expect(store.searchDocuments("brûlée")[0]?.doc_id).toBe("docs:cafe");
+    expect(store.searchDocuments("bru")).toEqual([]);
+  });
+
+  test("matches CJK words inside unspaced text", () => {
+    expect(store.searchDocuments("設定")[0]?.doc_id).toBe("docs:settings");
+    expect(store.searchDocuments("読み込む")[0]?.doc_id).toBe("docs:settings");
+    expect(store.searchDocuments("ファイル")[0]?.doc_id).toBe("docs:settings");
+  });
+
+  test("list_documents query compares normalized titles", () => {
+    expect(store.listDocuments({ query: "café" }).documents.map((d) => d.doc_id)).toEqual(["docs:cafe"]);
+  });
+
+  test("resolveRef slugs keep non-ASCII letters", () => {
+    expect(store.resolveRef("settings.md#ユーザー設定")).toEqual({
+      doc_id: "docs:settings",
+      node_id: "docs:settings:n1",
+    });
+  });
+});
+
 describe("getDocMeta", () => {
   test("returns meta for known doc_id", () => {
     const store = new DocumentStore();
@@ -887,3 +1182,464 @@ describe("stats", () => {
     expect(stats.collections).toContain("col2");
   });
 });
+
+describe("path boosts", () => {
+  let store: DocumentStore;
+
+  const codeDoc = (path: string) =>
+    makeDoc({
+      meta: { doc_id: `code:${path}`, file_path: path, title: path },
+      tree: [makeNode({ node_id: `code:${path}:n1`, title: "function Dial", content: "Dial opens a connection." })],
+    });
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      codeDoc("examples/client/main.go"),
+      codeDoc("pkg/api/client.pb.go"),
+      codeDoc("pkg/internal/dial/dial.go"),
+    ]);
+  });
+
+  const ranked = () => store.searchDocuments("dial").map((r) => r.doc_id);
+
+  test("file-name, directory and path patterns reorder results", () => {
+    store.setPathBoosts([
+      { pattern: "*.pb.go", weight: 0.5 },
+      { pattern: "internal/", weight: 2 },
+      { pattern: "examples/**", weight: 0.8 },
+    ]);
+    expect(ranked()).toEqual([
+      "code:pkg/internal/dial/dial.go",
+      "code:examples/client/main.go",
+      "code:pkg/api/client.pb.go",
+    ]);
+  });
+
+  test("weights of every matching pattern multiply", () => {
+    const before = store.searchDocuments("dial").find((r) => r.doc_id === "code:pkg/api/client.pb.go")!.score;
+    store.setPathBoosts([
+      { pattern: "*.go", weight: 1.2 },
+      { pattern: "*.pb.go", weight: 0.5 },
+    ]);
+    const after = store.searchDocuments("dial").find((r) => r.doc_id === "code:pkg/api/client.pb.go")!.score;
+    expect(after).toBeCloseTo(before * 0.6, 5);
+  });
+
+  test("boosts never filter results out", () => {
+    store.setPathBoosts([{ pattern: "*.go", weight: 0.01 }]);
+    expect(ranked()).toHaveLength(3);
+  });
+});
+
+describe("reference popularity", () => {
+  let store: DocumentStore;
+
+  const codeFile = (path: string, nodes: { name: string; content: string }[]) =>
+    makeDoc({
+      meta: { doc_id: `code:${path}`, file_path: path, title: path, facets: { content_type: ["code"] } },
+      tree: nodes.map((n, i) => makeNode({ node_id: `code:${path}:n${i}`, title: `function ${n.name}`, content: n.content })),
+    });
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      codeFile("retry.ts", [
+        { name: "retryRequest", content: "function retryRequest(req) { return send(req); }" },
+        { name: "retryOnce", content: "function retryOnce(req) { return retryRequest(req); }" },
+      ]),
+      codeFile("a.ts", [{ name: "fetchUser", content: "function fetchUser() { return retryRequest(get); }" }]),
+      codeFile("b.ts", [{ name: "fetchOrder", content: "function fetchOrder() { return retryRequest(get) ?? retryRequest(get); }" }]),
+    ]);
+  });
+
+  test("counts other files that name a symbol", () => {
+    expect(store.referenceCount("code:retry.ts", "code:retry.ts:n0")).toBe(2);
+    // Used only in its own file
+    expect(store.referenceCount("code:retry.ts", "code:retry.ts:n1")).toBe(0);
+  });
+
+  test("boosts the most-referenced symbol by 1 + reference_weight", () => {
+    const scores = () => new Map(store.searchDocuments("retryrequest").map((r) => [r.node_id, r.score]));
+    const boosted = scores();
+    store.setRanking({ reference_weight: 0 });
+    const plain = scores();
+
+    expect(boosted.get("code:retry.ts:n0")).toBeCloseTo(plain.get("code:retry.ts:n0")! * 1.5, 5);
+    expect(boosted.get("code:a.ts:n0")).toBeCloseTo(plain.get("code:a.ts:n0")!, 5);
+    expect([...boosted.keys()][0]).toBe("code:retry.ts:n0");
+  });
+
+  test("recounts after the corpus changes", () => {
+    store.removeDocument("code:b.ts");
+    expect(store.referenceCount("code:retry.ts", "code:retry.ts:n0")).toBe(1);
+  });
+});
+
+describe("match ranges", () => {
+  let store: DocumentStore;
+  const source = "function retryRequest(req) {\n  // Retries back off\n  return send(req);\n}";
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "code:retry_ts", file_path: "src/retry.ts", title: "retry.ts", facets: { content_type: ["code"] } },
+        tree: [
+          makeNode({
+            node_id: "code:retry_ts:n1",
+            title: "function retryRequest",
+            line_start: 10,
+            content: source,
+          }),
+        ],
+      }),
+      makeDoc({
+        meta: { doc_id: "docs:ja", file_path: "ja.md", title: "ガイド" },
+        tree: [makeNode({ node_id: "docs:ja:n1", title: "概要", content: "東京都の設定です。" })],
+      }),
+    ]);
+  });
+
+  test("content matches carry offsets, lines and columns", () => {
+    const [result] = store.searchDocuments("back");
+    expect(result.line_start).toBe(10);
+    expect(result.content_matches).toEqual([{ start: 42, end: 46, line: 2, column: 14 }]);
+    expect(source.slice(42, 46)).toBe("back");
+  });
+
+  test("prefix matches highlight the whole word", () => {
+    const [result] = store.searchDocuments("retryre");
+    expect(result.content_matches.map((m) => source.slice(m.start, m.end))).toEqual(["retryRequest"]);
+  });
+
+  test("snippet highlights index into the snippet", () => {
+    const [result] = store.searchDocuments("send");
+    expect(result.snippet_highlights.length).toBe(1);
+    const { start, end } = result.snippet_highlights[0];
+    expect(result.snippet.slice(start, end)).toBe("send");
+  });
+
+  test("CJK bigram matches cover just the matched characters", () => {
+    const [result] = store.searchDocuments("東京");
+    const { start, end } = result.content_matches[0];
+    expect("東京都の設定です。".slice(start, end)).toBe("東京");
+  });
+});
+
+describe("stepNodes", () => {
+  const SOURCE = [
+    'import { db } from "./db";',
+    "",
+    "export class Pool {",
+    "  open() {}",
+    "",
+    "  close() {}",
+    "",
+    "  drain() {}",
+    "}",
+    "",
+    "export function connect() {}",
+    "",
+    "export function disconnect() {}",
+    "",
+  ].join("\n");
+  const doc_id = "code:src:pool_ts";
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([indexCodeContent(SOURCE, "src/pool.ts", "code", "2026-01-01T00:00:00.000Z")]);
+  });
+
+  const titles = (step: ReturnType<DocumentStore["stepNodes"]>) => step!.nodes.map((n) => n.title);
+  const id = (title: string) => store.getTree(doc_id)!.nodes.find((n) => n.title === title)!.node_id;
+
+  test("steps between siblings, nearest first", () => {
+    expect(titles(store.stepNodes(doc_id, { node_id: id("method open") }, "next", { count: 5 }))).toEqual([
+      "method close",
+      "method drain",
+    ]);
+    expect(titles(store.stepNodes(doc_id, { node_id: id("method drain") }, "previous", { count: 2 }))).toEqual([
+      "method close",
+      "method open",
+    ]);
+    const last = store.stepNodes(doc_id, { node_id: id("function disconnect") }, "next")!;
+    expect([last.nodes, last.more]).toEqual([[], false]);
+  });
+
+  test("file scope descends into classes and skips imports", () => {
+    const step = store.stepNodes(doc_id, { line: 1 }, "next", { scope: "file", count: 3 })!;
+    expect(step.from).toBeNull();
+    expect([titles(step), step.more]).toEqual([["class Pool", "method open", "method close"], true]);
+    expect(titles(store.stepNodes(doc_id, { node_id: id("function connect") }, "previous", { scope: "file" }))).toEqual([
+      "method drain",
+    ]);
+  });
+
+  test("a line between methods steps through the methods around it", () => {
+    const between = store.stepNodes(doc_id, { line: 5 }, "next")!;
+    expect([between.from, titles(between)]).toEqual([null, ["method close"]]);
+    expect(titles(store.stepNodes(doc_id, { line: 5 }, "previous"))).toEqual(["method open"]);
+    expect(titles(store.stepNodes(doc_id, { line: 12 }, "next"))).toEqual(["function disconnect"]);
+    expect(store.stepNodes(doc_id, { line: 6 }, "next")!.from!.title).toBe("method close");
+  });
+
+  test("unknown documents and nodes are null", () => {
+    expect(store.stepNodes("code:nope", { line: 1 }, "next")).toBeNull();
+    expect(store.stepNodes(doc_id, { node_id: "nope" }, "next")).toBeNull();
+  });
+});
+
+describe("grouping by symbol", () => {
+  const POOL = `package pool
+
+type Pool struct {
+	conns []Conn
+}
+
+func (p *Pool) Acquire() Conn {
+	return p.conns[0] // pool conns
+}
+
+func (p *Pool) Release(c Conn) {
+	p.conns = append(p.conns, c) // pool conns
+}
+
+func Dial() *Pool {
+	return &Pool{} // pool
+}
+`;
+  const WORKER = `package pool
+
+func (p *Pool) drain() {
+	p.conns = nil // pool conns
+}
+
+func (p *Pool) reset() {
+	p.conns = p.conns[:0] // pool conns
+}
+`;
+  const TIME = "2026-01-01T00:00:00.000Z";
+
+  function store(): DocumentStore {
+    const s = new DocumentStore();
+    s.load([
+      indexCodeContent(POOL, "pool/pool.go", "code", TIME),
+      indexCodeContent(WORKER, "pool/worker.go", "code", TIME),
+      makeDoc({ meta: { doc_id: "docs:pool", file_path: "pool.md", title: "Pools" }, tree: [makeNode({ node_id: "docs:pool:n1", title: "Pool conns", content: "pool conns" }), makeNode({ node_id: "docs:pool:n2", title: "More", content: "pool conns again" })] }),
+    ]);
+    return s;
+  }
+
+  test("off by default: every matching node is a result", () => {
+    expect(store().searchDocuments("conns").length).toBe(7);
+  });
+
+  test("folds a type's methods into its best match and Go methods by receiver", () => {
+    const results = store().searchDocuments("conns", { group: true });
+    const code = results.filter((r) => r.file_path.endsWith(".go"));
+    expect(code.map((r) => [r.file_path, r.group?.symbol ?? null, r.group?.matches ?? 1]).sort()).toEqual([
+      ["pool/pool.go", "class Pool", 3],
+      ["pool/worker.go", "Pool", 2],
+    ]);
+    const [lead] = code.filter((r) => r.file_path === "pool/pool.go");
+    expect(lead.group!.node_ids).not.toContain(lead.node_id);
+    // Markdown sections are never folded
+    expect(results.filter((r) => r.file_path === "pool.md").length).toBe(2);
+  });
+
+  test("grouping happens before the limit", () => {
+    const results = store().searchDocuments("conns", { group: true, limit: 2, filters: { content_type: "code" } });
+    expect(results.map((r) => r.file_path).sort()).toEqual(["pool/pool.go", "pool/worker.go"]);
+  });
+});
+
+describe("result diversity", () => {
+  const TIME = "2026-01-01T00:00:00.000Z";
+  const file = (name: string) => `package cache
+
+func ${name}A() {} // evict
+func ${name}B() {} // evict
+func ${name}C() {} // evict
+`;
+
+  function store(): DocumentStore {
+    const s = new DocumentStore();
+    s.load([
+      indexCodeContent(file("Lru"), "cache/lru.go", "code", TIME),
+      indexCodeContent(file("Ttl"), "cache/ttl.go", "code", TIME),
+      indexCodeContent(file("Ring"), "ring/ring.go", "code", TIME),
+    ]);
+    return s;
+  }
+  const count = (results: { file_path: string }[], path: string) => results.filter((r) => r.file_path.startsWith(path)).length;
+
+  test("uncapped without the option, even when the ranking sets caps", () => {
+    const s = store();
+    s.setRanking({ max_per_file: 1 });
+    expect(s.searchDocuments("evict").length).toBe(9);
+  });
+
+  test("max_per_file keeps the best hits of each file", () => {
+    const s = store();
+    const results = s.searchDocuments("evict", { diversify: { max_per_file: 2 } });
+    expect([count(results, "cache/lru.go"), count(results, "cache/ttl.go"), count(results, "ring/")]).toEqual([2, 2, 2]);
+    const capped = s.searchDocuments("evict", { diversify: { max_per_file: 1 }, limit: 2 });
+    expect(capped.length).toBe(2);
+    expect(new Set(capped.map((r) => r.file_path)).size).toBe(2);
+  });
+
+  test("max_per_package counts every file in a directory", () => {
+    const results = store().searchDocuments("evict", { diversify: { max_per_package: 2 } });
+    expect([count(results, "cache/"), count(results, "ring/")]).toEqual([2, 2]);
+  });
+
+  test("unset caps fall back to the ranking; 0 turns one off", () => {
+    const s = store();
+    s.setRanking({ max_per_file: 1 });
+    expect(s.searchDocuments("evict", { diversify: {} }).length).toBe(3);
+    expect(s.searchDocuments("evict", { diversify: { max_per_file: 0 } }).length).toBe(9);
+  });
+});
+
+describe("explain", () => {
+  let store: DocumentStore;
+
+  beforeEach(() => {
+    store = new DocumentStore();
+    store.load([
+      makeDoc({
+        meta: { doc_id: "code:internal/pool.go", file_path: "internal/pool.go", title: "pool.go", facets: { content_type: ["code"] } },
+        tree: [makeNode({ node_id: "code:internal/pool.go:n1", title: "function Acquire", content: "Acquire a pooled connection from the pool" })],
+      }),
+      makeDoc({
+        meta: { doc_id: "docs:pool", file_path: "pool.md", title: "Pools" },
+        tree: [makeNode({ node_id: "docs:pool:n1", title: "Pools", content: "Each pool keeps connections" })],
+      }),
+    ]);
+    store.setPathBoosts([{ pattern: "internal/", weight: 1.5 }]);
+  });
+
+  const total = (e: NonNullable<SearchResult["explain"]>) =>
+    (e.bm25 + e.prefix + e.proximity + e.all_terms) * e.collection * e.path * e.recency * e.generated * e.popularity * e.coverage;
+
+  test("off by default", () => {
+    expect(store.searchDocuments("pool").every((r) => r.explain === undefined)).toBe(true);
+  });
+
+  test("the parts multiply out to the score", () => {
+    const results = store.searchDocuments("pool conn", { explain: true });
+    expect(results.length).toBe(2);
+    for (const r of results) expect(total(r.explain!)).toBeCloseTo(r.score, 6);
+
+    const code = results.find((r) => r.doc_id === "code:internal/pool.go")!.explain!;
+    expect(code.path).toBe(1.5);
+    expect(code.proximity).toBe(DEFAULT_RANKING.term_proximity_bonus);
+    expect(code.all_terms).toBe(DEFAULT_RANKING.full_coverage_bonus);
+    // "conn" matched only by prefix, at prefix_penalty
+    expect(code.prefix).toBeGreaterThan(0);
+    expect(code.terms.pool).toBeGreaterThan(0);
+    expect(code.terms.connect).toBeGreaterThan(0);
+    const termSum = Object.values(code.terms).reduce((sum, score) => sum + score, 0);
+    expect(termSum).toBeCloseTo(code.bm25 + code.prefix, 6);
+  });
+});
+
+describe("generations", () => {
+  test("every change to the documents counts, settings do not", () => {
+    const store = new DocumentStore();
+    store.load([makeDoc({ meta: { doc_id: "a", file_path: "a.md" } })]);
+    expect(store.getGeneration()).toBe(1);
+    store.setRanking({ k1: 1.5 });
+    store.removeDocument("missing");
+    store.addDocuments([]);
+    expect(store.getGeneration()).toBe(1);
+    store.addDocument(makeDoc({ meta: { doc_id: "b", file_path: "b.md" } }));
+    store.removeDocument("a");
+    expect(store.getStats().generation).toBe(3);
+  });
+
+  test("a pinned generation reads the same after later changes", () => {
+    const store = new DocumentStore();
+    store.load([makeDoc({ meta: { doc_id: "a", file_path: "a.md" } })]);
+    const pinned = store.pinGeneration({});
+    expect(store.atGeneration(pinned)).toBe(store);
+
+    store.addDocument(
+      makeDoc({
+        meta: { doc_id: "b", file_path: "b.md", collection: "other" },
+        tree: [makeNode({ node_id: "b:n1", content: "Kerberos tickets and authentication realms." })],
+      })
+    );
+    store.removeDocument("a");
+    const frozen = store.atGeneration(pinned)!;
+    expect(frozen).not.toBe(store);
+    expect(frozen.listDocuments().documents.map((d) => d.doc_id)).toEqual(["a"]);
+    expect(frozen.searchDocuments("authentication").map((r) => r.doc_id)).toEqual(["a"]);
+    expect(frozen.getFacets().collection).toEqual({ test: 1 });
+    expect(store.searchDocuments("authentication").map((r) => r.doc_id)).toEqual(["b"]);
+    expect(store.atGeneration(pinned + 1)).toBeNull();
+  });
+
+  test("a session's pin is kept however many other sessions pin", () => {
+    const store = new DocumentStore();
+    const session = {};
+    const mine = store.pinGeneration(session);
+    for (let i = 0; i < 20; i++) {
+      store.addDocument(makeDoc({ meta: { doc_id: `d${i}`, file_path: `d${i}.md` } }));
+      store.pinGeneration({});
+    }
+    expect(store.atGeneration(mine)!.listDocuments().total).toBe(0);
+  });
+
+  test("a generation is dropped when the last session pinning it moves or releases", () => {
+    const store = new DocumentStore();
+    const [first, second] = [{}, {}];
+    const pinned = store.pinGeneration(first);
+    expect(store.pinGeneration(second)).toBe(pinned);
+    store.addDocument(makeDoc({ meta: { doc_id: "a", file_path: "a.md" } }));
+
+    store.releaseGeneration(first);
+    expect(store.atGeneration(pinned)).not.toBeNull();
+    store.pinGeneration(second);
+    expect(store.atGeneration(pinned)).toBeNull();
+    expect(store.atGeneration(store.getGeneration())).toBe(store);
+  });
+
+  test("a frozen generation shares the posting lists later changes leave alone", () => {
+    const store = new DocumentStore();
+    store.load([
+      makeDoc({ meta: { doc_id: "a", file_path: "a.md" }, tree: [makeNode({ node_id: "a:n1", content: "Kerberos realms." })] }),
+    ]);
+    const pinned = store.pinGeneration({});
+    store.addDocument(
+      makeDoc({ meta: { doc_id: "b", file_path: "b.md" }, tree: [makeNode({ node_id: "b:n1", content: "Kerberos tickets." })] })
+    );
+    const frozen = store.atGeneration(pinned)! as any;
+    const live = store as any;
+    const term = (word: string) => [...live.index.keys()].find((t: string) => t.startsWith(word))!;
+    expect(frozen.index.get(term("realm"))).toBeDefined();
+    expect(frozen.index.get(term("realm"))).toBe(live.index.get(term("realm")));
+    expect(frozen.index.get(term("kerber"))).not.toBe(live.index.get(term("kerber")));
+    expect(frozen.searchDocuments("kerberos").map((r: SearchResult) => r.doc_id)).toEqual(["a"]);
+    expect(store.searchDocuments("kerberos").map((r) => r.doc_id).sort()).toEqual(["a", "b"]);
+  });
+
+  test("changesSince lists the documents changed after a generation", () => {
+    const store = new DocumentStore();
+    store.load([makeDoc({ meta: { doc_id: "a", file_path: "a.md" } })]);
+    const loaded = store.getGeneration();
+    store.addDocuments([makeDoc({ meta: { doc_id: "b", file_path: "b.md" } }), makeDoc({ meta: { doc_id: "c", file_path: "c.md" } })]);
+    store.removeDocument("a");
+    expect(store.changesSince(loaded)!.map((d) => d.doc_id)).toEqual(["b", "c", "a"]);
+    expect(store.changesSince(store.getGeneration())).toEqual([]);
+    // load() replaced everything
+    expect(store.changesSince(loaded - 1)).toBeNull();
+
+    const before = store.getGeneration();
+    for (let i = 0; i < MAX_JOURNAL_CHANGES; i++) store.addDocument(makeDoc({ meta: { doc_id: `d${i}`, file_path: `d${i}.md` } }));
+    expect(store.changesSince(before)).toHaveLength(MAX_JOURNAL_CHANGES);
+    expect(store.changesSince(before - 1)).toBeNull();
+  });
+});
@@ -0,0 +1,162 @@
+/**
+ * Tests for structural search: pattern parsing, balanced holes,
+ * strings and comments, repeated names, rewrite templates, and the
+ * structural_search / structural_replace tools.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { chmod, mkdtemp, readFile, rm, stat } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { applyRewrite, checkTemplate, findStructural, parsePattern, StructuralSearch } from "../src/structural";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const GO = `package db
+
+func open() {
+	conn := Connect(ctx, cfg.Addr(), 8080) // Connect(a, b, c)
+	msg := "Connect(x, y, z)"
+	Connect(withTimeout(ctx, 5*time.Second),
+		"db", port)
+	Reconnect(a, b, c)
+	if err != nil { return err }
+}
+`;
+
+const texts = (pattern: string, source = GO) => findStructural(source, pattern).map((m) => m.text);
+
+describe("parsePattern", () => {
+  test("rejects patterns that cannot be anchored", () => {
+    expect(() => parsePattern(":[a]")).toThrow("literal text");
+    expect(() => parsePattern(":[a](x)")).toThrow("must start with");
+    expect(() => parsePattern("f(:[a]:[b])")).toThrow("two holes in a row");
+  });
+});
+
+describe("findStructural", () => {
+  test("binds holes across nested brackets and line breaks", () => {
+    const matches = findStructural(GO, "Connect(:[ctx], :[addr], :[port])");
+    expect(matches.map((m) => m.holes)).toEqual([
+      { ctx: "ctx", addr: "cfg.Addr()", port: "8080" },
+      { ctx: "withTimeout(ctx, 5*time.Second)", addr: '"db"', port: "port" },
+    ]);
+    expect([matches[1].line_start, matches[1].line_end]).toEqual([6, 7]);
+  });
+
+  test("skips strings, comments, and partial words", () => {
+    expect(texts("Connect(:[_])")).toHaveLength(2);
+    expect(texts("Reconnect(:[_])")).toEqual(["Reconnect(a, b, c)"]);
+  });
+
+  test("runs a trailing hole to the end of the statement", () => {
+    expect(findStructural(GO, "if :[cond] { return :[e] }")[0].holes).toEqual({ cond: "err != nil", e: "err" });
+    expect(findStructural("x := 1; return a + b; y()", "return :[v]")[0].holes.v).toBe("a + b");
+  });
+
+  test("requires a repeated name to bind the same text", () => {
+    const source = "assertEqual(got, got)\nassertEqual(got, want)\n";
+    expect(texts(":[[fn]](:[x], :[x])", source)).toEqual(["assertEqual(got, got)"]);
+  });
+
+  test("honors # comments where the language uses them", () => {
+    const source = "# print(x)\nprint(y)\n";
+    expect(findStructural(source, "print(:[v])", true).map((m) => m.holes.v)).toEqual(["y"]);
+  });
+});
+
+describe("rewrite templates", () => {
+  test("fill holes and check their names", () => {
+    const pattern = "Connect(:[ctx], :[addr], :[port])";
+    const out = applyRewrite(GO, findStructural(GO, pattern), "Dial(:[ctx], net.JoinHostPort(:[addr], :[port]))");
+    expect(out).toContain("conn := Dial(ctx, net.JoinHostPort(cfg.Addr(), 8080)) // Connect(a, b, c)");
+    expect(out).toContain('msg := "Connect(x, y, z)"');
+    expect(() => checkTemplate(pattern, "Dial(:[host])")).toThrow("does not bind");
+  });
+});
+
+// ── Tools ────────────────────────────────────────────────────────────
+
+let dir: string;
+let config: IndexConfig;
+
+const indexedStore = () => indexTree(dir, ["db/open.go"]);
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-structural-"));
+  await writeTree(dir, {
+    "db/open.go": GO,
+  });
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("structural_search tool", () => {
+  test("lists matches with holes and rewrite previews", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      structural: new StructuralSearch(config),
+    });
+    const names = (await harness.client.listTools()).tools.map((t) => t.name);
+    expect(names.includes("structural_replace")).toBe(false);
+
+    const result = await harness.client.callTool({
+      name: "structural_search",
+      arguments: { path: "db/", pattern: "Connect(:[ctx], :[addr], :[port])", rewrite: "Dial(:[addr])" },
+    });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(2);
+    expect(data.matches[0].replacement).toBe("Dial(cfg.Addr())");
+    const text = getToolText(result as any);
+    expect(text).toContain("2 match(es) in 1 file(s) under db/");
+    expect(text).toContain('ctx="ctx" addr="cfg.Addr()" port="8080"');
+
+    const bad = await harness.client.callTool({ name: "structural_search", arguments: { path: "db/", pattern: ":[x]" } });
+    expect(bad.isError).toBe(true);
+    await harness.cleanup();
+  });
+});
+
+describe("structural_replace tool", () => {
+  test("rewrites files and re-indexes them, or only counts on dry runs", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      structural: new StructuralSearch(config),
+      structuralRewrite: true,
+    });
+    const args = { path: "**/*.go", pattern: "Reconnect(:[a], :[b], :[c])", rewrite: "Redial(:[c], :[b], :[a])" };
+
+    const dry = await harness.client.callTool({ name: "structural_replace", arguments: { ...args, dry_run: true } });
+    expect((dry.structuredContent as any).written).toBe(false);
+    expect(await readFile(join(dir, "db", "open.go"), "utf-8")).toBe(GO);
+
+    const wet = await harness.client.callTool({ name: "structural_replace", arguments: args });
+    expect(wet.structuredContent as any).toEqual({
+      schema_version: (wet.structuredContent as any).schema_version,
+      status: "ok",
+      written: true,
+      replacements: 1,
+      files: [{ doc_id: "code:db:open_go", file_path: "db/open.go", replacements: 1 }],
+    });
+    expect(await readFile(join(dir, "db", "open.go"), "utf-8")).toContain("Redial(c, b, a)");
+    expect(harness.store.searchDocuments("Redial").length).toBeGreaterThan(0);
+    await harness.cleanup();
+  });
+
+  test("keeps the file's mode", async () => {
+    await chmod(join(dir, "db", "open.go"), 0o755);
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      structural: new StructuralSearch(config),
+      structuralRewrite: true,
+    });
+    await harness.client.callTool({
+      name: "structural_replace",
+      arguments: { path: "**/*.go", pattern: "Reconnect(:[a], :[b], :[c])", rewrite: "Redial(:[c], :[b], :[a])" },
+    });
+    expect(await readFile(join(dir, "db", "open.go"), "utf-8")).toContain("Redial(c, b, a)");
+    expect((await stat(join(dir, "db", "open.go"))).mode & 0o777).toBe(0o755);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,98 @@
+/**
+ * Tests for "did you mean" suggestions.
+ *
+ * Covers: edit-distance matches from the symbol trie, prefix
+ * completions, ranking, the store/formatter integration, and
+ * argument completion.
+ */
+
+import { describe, test, expect } from "bun:test";
+import { SymbolTrie, maxEditDistance } from "../src/suggest";
+import { DocumentStore } from "../src/store";
+import { formatSearchResults } from "../src/search-formatter";
+import type { IndexedDocument } from "../src/types";
+
+function trie(names: string[]): SymbolTrie {
+  const t = new SymbolTrie();
+  for (const name of names) t.insert(name);
+  return t;
+}
+
+describe("SymbolTrie", () => {
+  const names = ["ClusterManager", "ClusterManagerConfig", "clusterMode", "NodeManager", "Queue"];
+
+  test("finds typos within the edit budget, case-insensitively", () => {
+    expect(trie(names).suggest("ClusetManager").map((s) => s.name)[0]).toBe("ClusterManager");
+    expect(trie(names).suggest("queu").map((s) => s.name)).toEqual(["Queue"]);
+  });
+
+  test("ranks near-misses before prefix completions", () => {
+    expect(trie([...names, "ClusterMap"]).suggest("ClusterMan").map((s) => s.name)).toEqual([
+      "ClusterMap",
+      "ClusterManager",
+      "ClusterManagerConfig",
+    ]);
+  });
+
+  test("omits exact matches and names beyond the budget", () => {
+    expect(trie(names).suggest("Queue")).toEqual([]);
+    expect(trie(names).suggest("Qxxue")).toEqual([]);
+  });
+
+  test("scales the budget with word length", () => {
+    expect(maxEditDistance("queu")).toBe(1);
+    expect(maxEditDistance("clustermgr")).toBe(2);
+  });
+
+  test("counts distinct names", () => {
+    expect(trie(["Queue", "Queue", "queue"]).size).toBe(2);
+  });
+
+  test("completes a prefix, shortest first", () => {
+    expect(trie(names).complete("cluster")).toEqual(["clusterMode", "ClusterManager", "ClusterManagerConfig"]);
+    expect(trie(names).complete("cluster", 2)).toEqual(["clusterMode", "ClusterManager"]);
+    expect(trie(names).complete("Queue")).toEqual(["Queue"]);
+    expect(trie(names).complete("zzz")).toEqual([]);
+  });
+});
+
+describe("DocumentStore.suggest", () => {
+  const codeDoc: IndexedDocument = {
+    meta: {
+      doc_id: "code:cluster_ts",
+      file_path: "cluster.ts",
+      title: "cluster.ts",
+      description: "",
+      word_count: 10,
+      heading_count: 2,
+      max_depth: 2,
+      last_modified: "2025-01-01T00:00:00.000Z",
+      tags: [],
+      content_hash: "abc",
+      collection: "code",
+      facets: { content_type: ["code"], language: ["typescript"] },
+      references: [],
+    },
+    tree: [
+      { node_id: "code:cluster_ts:n1", title: "class ClusterManager", level: 1, parent_id: null, children: [], content: "class ClusterManager {}", summary: "", word_count: 2, line_start: 1, line_end: 1 },
+      { node_id: "code:cluster_ts:n2", title: "imports", level: 1, parent_id: null, children: [], content: "import x", summary: "", word_count: 2, line_start: 1, line_end: 1 },
+    ],
+    root_nodes: ["code:cluster_ts:n1", "code:cluster_ts:n2"],
+  };
+
+  test("suggests symbol names for a query that finds nothing", () => {
+    const store = new DocumentStore();
+    store.load([codeDoc]);
+    expect(store.searchDocuments("ClusetManager")).toEqual([]);
+    expect(store.suggest("ClusetManager")).toEqual(["ClusterManager"]);
+    expect(formatSearchResults([], store, "ClusetManager")).toContain("Did you mean: ClusterManager?");
+  });
+
+  test("rebuilds after the corpus changes", () => {
+    const store = new DocumentStore();
+    store.load([]);
+    expect(store.suggest("ClusetManager")).toEqual([]);
+    store.addDocuments([codeDoc]);
+    expect(store.suggest("ClusetManager")).toEqual(["ClusterManager"]);
+  });
+});
@@ -0,0 +1,189 @@
+/**
+ * Tests for multi-tenant mode.
+ *
+ * Covers: tenants file validation, per-tenant IndexConfig, store
+ * isolation, per-tenant tokens, document quota, and the rolling
+ * request-rate quota.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import {
+  parseTenantsConfig,
+  RETRY_BASE_MS,
+  tenantIndexConfig,
+  TenantConfigError,
+  TenantRegistry,
+} from "../src/tenants";
+
+let dir: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-tenants-"));
+  await mkdir(join(dir, "a"), { recursive: true });
+  await mkdir(join(dir, "b"), { recursive: true });
+  await writeFile(join(dir, "a", "alpha.md"), "# Alpha\n\nAlpha-only ledger notes.\n");
+  await writeFile(join(dir, "b", "beta.md"), "# Beta\n\nBeta-only deploy notes.\n");
+  await writeFile(join(dir, "b", "gamma.md"), "# Gamma\n\nMore beta notes.\n");
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("parseTenantsConfig", () => {
+  test("accepts a valid tenants file", () => {
+    const tenants = parseTenantsConfig({ tenants: [{ id: "payments", docs_root: "/x" }] });
+    expect(tenants[0].id).toBe("payments");
+  });
+
+  test("rejects ids that are unsafe in a URL", () => {
+    expect(() => parseTenantsConfig({ tenants: [{ id: "../etc", docs_root: "/x" }] })).toThrow(
+      TenantConfigError
+    );
+  });
+
+  test("rejects duplicate ids", () => {
+    expect(() =>
+      parseTenantsConfig({
+        tenants: [
+          { id: "a", docs_root: "/x" },
+          { id: "a", docs_root: "/y" },
+        ],
+      })
+    ).toThrow(/duplicate/);
+  });
+
+  test("requires docs_root", () => {
+    expect(() => parseTenantsConfig({ tenants: [{ id: "a" }] })).toThrow(/docs_root/);
+  });
+
+  test("rejects an empty token", () => {
+    expect(() => parseTenantsConfig({ tenants: [{ id: "a", docs_root: "/x", token: "" }] })).toThrow(/token/);
+  });
+
+  test("requires a tenants array", () => {
+    expect(() => parseTenantsConfig({})).toThrow(TenantConfigError);
+  });
+});
+
+describe("tenantIndexConfig", () => {
+  test("maps tenant fields onto IndexConfig", () => {
+    const config = tenantIndexConfig({
+      id: "p",
+      docs_root: "/docs",
+      docs_glob: "guides/**/*.md",
+      code_root: "/src",
+      max_depth: 3,
+    });
+    expect(config.collections[0].glob_pattern).toBe("guides/**/*.md");
+    expect(config.code_collections?.[0].root).toBe("/src");
+    expect(config.max_depth).toBe(3);
+  });
+});
+
+describe("TenantRegistry", () => {
+  test("keeps tenant stores isolated", async () => {
+    const registry = new TenantRegistry(
+      [
+        { id: "a", docs_root: join(dir, "a") },
+        { id: "b", docs_root: join(dir, "b") },
+      ],
+      () => {}
+    );
+
+    const a = await registry.load("a");
+    const b = await registry.load("b");
+    expect(a.store.searchDocuments("ledger").length).toBeGreaterThan(0);
+    expect(b.store.searchDocuments("ledger").length).toBe(0);
+    expect(b.store.getStats().document_count).toBe(2);
+  });
+
+  test("concurrent loads share one indexing pass", async () => {
+    const registry = new TenantRegistry([{ id: "a", docs_root: join(dir, "a") }], () => {});
+    const [first, second] = await Promise.all([registry.load("a"), registry.load("a")]);
+    expect(first).toBe(second);
+  });
+
+  test("refuses a tenant over its document quota", async () => {
+    const registry = new TenantRegistry(
+      [{ id: "b", docs_root: join(dir, "b"), quotas: { max_documents: 1 } }],
+      () => {}
+    );
+    const tenant = await registry.load("b");
+    expect(tenant.error).toBe("document quota exceeded: more than 1 documents");
+    expect(tenant.store.getStats().document_count).toBe(0);
+  });
+
+  test("loads a failed tenant again after a delay that doubles", async () => {
+    const registry = new TenantRegistry(
+      [{ id: "b", docs_root: join(dir, "b"), quotas: { max_documents: 1 } }],
+      () => {}
+    );
+    const failed = await registry.load("b");
+    await rm(join(dir, "b", "gamma.md"));
+    expect(await registry.load("b")).toBe(failed);
+
+    const later = Date.now() + RETRY_BASE_MS;
+    const [retry, shared] = await Promise.all([registry.load("b", later), registry.load("b", later)]);
+    expect(retry).toBe(shared);
+    expect(retry.error).toBeUndefined();
+    expect(retry.store.getStats().document_count).toBe(1);
+    expect(await registry.load("b", later + RETRY_BASE_MS * 100)).toBe(retry);
+  });
+
+  test("enforces requests_per_minute over a rolling window", () => {
+    const registry = new TenantRegistry(
+      [{ id: "a", docs_root: join(dir, "a"), quotas: { requests_per_minute: 2 } }],
+      () => {}
+    );
+    expect(registry.allowRequest("a", 0)).toBe(true);
+    expect(registry.allowRequest("a", 1_000)).toBe(true);
+    expect(registry.allowRequest("a", 2_000)).toBe(false);
+    expect(registry.allowRequest("a", 61_000)).toBe(true);
+  });
+
+  test("authorizes each tenant by its own token, and the list by the operator's", () => {
+    const registry = new TenantRegistry(
+      [
+        { id: "a", docs_root: join(dir, "a"), token: "a-key" },
+        { id: "b", docs_root: join(dir, "b") },
+      ],
+      () => {}
+    );
+    expect(registry.authorized("a", "Bearer a-key", "ops")).toBe(true);
+    expect(registry.authorized("a", "Bearer ops", "ops")).toBe(false);
+    expect(registry.authorized("b", "Bearer a-key", "ops")).toBe(false);
+    expect(registry.authorized("b", "Bearer ops", "ops")).toBe(true);
+    expect(registry.authorized("nope", "Bearer a-key", "ops")).toBe(false);
+    expect(registry.authorized(null, "Bearer a-key", "ops")).toBe(false);
+    expect(registry.authorized(null, "Bearer ops", "ops")).toBe(true);
+    // Without HTTP_TOKEN, a tenant with a token keeps the list closed
+    expect(registry.authorized(null, undefined, undefined)).toBe(false);
+    expect(registry.authorized("b", undefined, undefined)).toBe(true);
+    expect(new TenantRegistry([{ id: "b", docs_root: join(dir, "b") }], () => {}).authorized(null, undefined, undefined)).toBe(true);
+  });
+
+  test("rejects unknown tenants", async () => {
+    const registry = new TenantRegistry([], () => {});
+    await expect(registry.load("nope")).rejects.toThrow(/unknown tenant/);
+  });
+
+  test("summary reports load status per tenant", async () => {
+    const registry = new TenantRegistry(
+      [
+        { id: "a", docs_root: join(dir, "a") },
+        { id: "b", docs_root: join(dir, "b") },
+      ],
+      () => {}
+    );
+    await registry.load("a");
+    const summary = await registry.summary();
+    expect(summary).toEqual([
+      { id: "a", status: "ok", document_count: 1, total_nodes: 1 },
+      { id: "b", status: "not_loaded" },
+    ]);
+  });
+});
@@ -0,0 +1,171 @@
+/**
+ * Tests for the Go coverage overlay: cover profile parsing, path
+ * resolution through go.mod, per-function attribution, "needs tests"
+ * ranking, and the coverage_for tool.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { DocumentStore } from "../src/store";
+import {
+  coverageStatus,
+  parseCoverProfile,
+  resolveProfilePath,
+  stripTestIntent,
+  type CoverBlock,
+} from "../src/test-coverage";
+import { createMcpTestClient, getToolText, makeDoc, makeNode } from "./fixtures/helpers";
+
+const PROFILE = `mode: set
+example.com/svc/auth/token.go:5.30,8.2 2 1
+example.com/svc/auth/token.go:12.40,14.16 2 0
+example.com/svc/auth/token.go:14.16,16.3 1 1
+example.com/svc/auth/token.go:20.35,24.2 3 0
+example.com/svc/auth/token.go:20.35,24.2 3 0
+`;
+
+describe("parseCoverProfile", () => {
+  test("reads the mode and blocks per file", () => {
+    const profile = parseCoverProfile(PROFILE);
+    expect(profile.mode).toBe("set");
+    const blocks = profile.files.get("example.com/svc/auth/token.go")!;
+    expect(blocks).toHaveLength(4);
+    expect(blocks[0]).toEqual({ start_line: 5, start_col: 30, end_line: 8, end_col: 2, statements: 2, count: 1 });
+  });
+
+  test("merges a block listed twice", () => {
+    const profile = parseCoverProfile("mode: count\na.go:1.1,2.2 1 3\na.go:1.1,2.2 1 4\n");
+    expect(profile.files.get("a.go")![0].count).toBe(7);
+  });
+});
+
+describe("resolveProfilePath", () => {
+  const roots = [{ name: "code", root: "/repo" }];
+  const modules = [
+    { module: "example.com/svc", collection: "code", dir: "" },
+    { module: "example.com/svc/tools", collection: "code", dir: "tools" },
+  ];
+
+  test("maps import paths through the innermost module", () => {
+    expect(resolveProfilePath("example.com/svc/auth/token.go", roots, modules)).toEqual({
+      collection: "code",
+      path: "auth/token.go",
+    });
+    expect(resolveProfilePath("example.com/svc/tools/gen/main.go", roots, modules)?.path).toBe("tools/gen/main.go");
+  });
+
+  test("maps absolute and _/ paths against the code roots", () => {
+    expect(resolveProfilePath("/repo/auth/token.go", roots, [])?.path).toBe("auth/token.go");
+    expect(resolveProfilePath("_/repo/auth/token.go", roots, [])?.path).toBe("auth/token.go");
+    expect(resolveProfilePath("/elsewhere/x.go", roots, [])).toBeNull();
+    expect(resolveProfilePath("github.com/other/x.go", roots, modules)).toBeNull();
+  });
+});
+
+describe("stripTestIntent", () => {
+  test("removes the intent words and keeps the rest", () => {
+    expect(stripTestIntent("auth needs tests")).toBe("auth");
+    expect(stripTestIntent("untested handlers")).toBe("handlers");
+    expect(stripTestIntent("low test coverage")).toBe("");
+  });
+
+  test("returns null for ordinary queries", () => {
+    expect(stripTestIntent("test runner setup")).toBeNull();
+  });
+});
+
+describe("coverageStatus", () => {
+  test("classifies by covered statements", () => {
+    expect(coverageStatus({ statements: 4, covered: 4 })).toBe("covered");
+    expect(coverageStatus({ statements: 4, covered: 1 })).toBe("partial");
+    expect(coverageStatus({ statements: 4, covered: 0 })).toBe("uncovered");
+  });
+});
+
+// ── Store attribution and ranking ────────────────────────────────────
+
+function tokenDoc() {
+  const id = "code:auth:token_go";
+  return makeDoc({
+    meta: { doc_id: id, file_path: "auth/token.go", title: "token.go", collection: "code", facets: { language: ["go"], content_type: ["code"] } },
+    tree: [
+      makeNode({ node_id: `${id}:n1`, title: "function NewToken", content: "func NewToken() *Token { token }", line_start: 5, line_end: 8 }),
+      makeNode({ node_id: `${id}:n2`, title: "function Parse", content: "func Parse(raw string) token parse", line_start: 12, line_end: 17 }),
+      makeNode({ node_id: `${id}:n3`, title: "function Refresh", content: "func Refresh(t *Token) token refresh", line_start: 20, line_end: 24 }),
+    ],
+  });
+}
+
+function coveredStore(): DocumentStore {
+  const store = new DocumentStore();
+  store.load([tokenDoc()]);
+  const blocks = parseCoverProfile(PROFILE).files.get("example.com/svc/auth/token.go")!;
+  store.setCoverBlocks("code", new Map<string, CoverBlock[]>([["auth/token.go", blocks]]));
+  return store;
+}
+
+describe("DocumentStore coverage", () => {
+  test("attributes blocks to the function containing them", () => {
+    const store = coveredStore();
+    expect(store.nodeCoverage("code:auth:token_go", "code:auth:token_go:n1")).toEqual({ statements: 2, covered: 2 });
+    expect(store.nodeCoverage("code:auth:token_go", "code:auth:token_go:n2")).toEqual({ statements: 3, covered: 1 });
+    expect(store.nodeCoverage("code:auth:token_go", "code:auth:token_go:n3")).toEqual({ statements: 3, covered: 0 });
+  });
+
+  test("ranks uncovered functions first for a needs-tests query", () => {
+    const store = coveredStore();
+    const plain = store.searchDocuments("token");
+    const needsTests = store.searchDocuments("token needs tests");
+    expect(needsTests[0].node_title).toBe("function Refresh");
+    expect(needsTests[0].score).toBeGreaterThan(plain.find((r) => r.node_title === "function Refresh")!.score);
+  });
+
+  test("lists functions by uncovered statements when only the intent is given", () => {
+    const results = coveredStore().searchDocuments("untested");
+    expect(results.map((r) => r.node_title)).toEqual(["function Refresh", "function Parse"]);
+  });
+
+  test("leaves ordinary queries alone without a profile", () => {
+    const store = new DocumentStore();
+    store.load([tokenDoc()]);
+    expect(store.hasCoverage()).toBe(false);
+    expect(store.searchDocuments("untested")).toEqual([]);
+  });
+});
+
+describe("coverage_for tool", () => {
+  test("is registered only with a cover profile", async () => {
+    const without = await createMcpTestClient([tokenDoc()]);
+    const names = (await without.client.listTools()).tools.map((t) => t.name);
+    expect(names.includes("coverage_for")).toBe(false);
+    await without.cleanup();
+  });
+
+  test("reports per-function status and totals", async () => {
+    const harness = await createMcpTestClient([tokenDoc()], { coverProfile: "cover.out" });
+    const blocks = parseCoverProfile(PROFILE).files.get("example.com/svc/auth/token.go")!;
+    harness.store.setCoverBlocks("code", new Map([["auth/token.go", blocks]]));
+
+    const result = await harness.client.callTool({ name: "coverage_for", arguments: { path: "auth/" } });
+    const text = getToolText(result as any);
+    expect(text).toContain("Coverage for auth/: 37.5% of 8 statements in 3 functions");
+    expect(text).toContain("function Refresh (L20-24): 0/3 statements, 0% — uncovered");
+
+    const data = result.structuredContent as any;
+    expect(data.totals).toEqual({ functions: 3, statements: 8, covered: 3, percent: 37.5 });
+    expect(data.functions.map((f: any) => f.status)).toEqual(["covered", "partial", "uncovered"]);
+
+    const uncovered = await harness.client.callTool({
+      name: "coverage_for",
+      arguments: { path: "code:auth:token_go", status: "uncovered" },
+    });
+    expect((uncovered.structuredContent as any).functions.map((f: any) => f.name)).toEqual(["function Refresh"]);
+    await harness.cleanup();
+  });
+
+  test("reports not_found for an unindexed path", async () => {
+    const harness = await createMcpTestClient([tokenDoc()], { coverProfile: "cover.out" });
+    const result = await harness.client.callTool({ name: "coverage_for", arguments: { path: "billing/" } });
+    expect((result.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
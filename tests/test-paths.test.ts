@@ -0,0 +1,80 @@
+/**
+ * Tests for test code detection by path and the include_tests filter
+ * in search and the search tools.
+ */
+
+import { describe, test, expect } from "bun:test";
+import { isTestPath, matchesTests } from "../src/test-paths";
+import { DocumentStore } from "../src/store";
+import { createMcpTestClient, makeDoc } from "./fixtures/helpers";
+
+describe("isTestPath", () => {
+  test("recognizes test files by name", () => {
+    for (const path of [
+      "pkg/auth/token_test.go",
+      "src/auth.test.ts",
+      "web/Button.spec.jsx",
+      "tests/test_auth.py",
+      "auth/token_test.py",
+      "conftest.py",
+      "spec/models/user_spec.rb",
+      "src/main/java/com/acme/AuthServiceTest.java",
+      "Acme.Tests/AuthTests.cs",
+      "internal/mock_client.go",
+      "src/api.mock.ts",
+    ]) {
+      expect(isTestPath(path)).toBe(true);
+    }
+  });
+
+  test("recognizes test, fixture, and mock directories", () => {
+    expect(isTestPath("src/__tests__/auth.ts")).toBe(true);
+    expect(isTestPath("src/__mocks__/fs.ts")).toBe(true);
+    expect(isTestPath("pkg/parser/testdata/input.go")).toBe(true);
+    expect(isTestPath("test/fixtures/helpers.ts")).toBe(true);
+    expect(isTestPath("src/test/java/com/acme/Helper.java")).toBe(true);
+  });
+
+  test("leaves production code alone", () => {
+    for (const path of ["src/auth.ts", "pkg/testing/assert.go", "src/latest.ts", "src/contest.py", "cmd/attest/main.go", "src/Testimonial.java"]) {
+      expect(isTestPath(path)).toBe(false);
+    }
+  });
+
+  test("matchesTests applies true, false, and only", () => {
+    expect(matchesTests("src/a.test.ts", undefined)).toBe(true);
+    expect(matchesTests("src/a.test.ts", false)).toBe(false);
+    expect(matchesTests("src/a.ts", false)).toBe(true);
+    expect(matchesTests("src/a.ts", "only")).toBe(false);
+    expect(matchesTests("src/a.test.ts", "only")).toBe(true);
+  });
+});
+
+const DOCS = [
+  makeDoc({ meta: { doc_id: "code:src:auth_ts", file_path: "src/auth.ts", title: "auth.ts" } }),
+  makeDoc({ meta: { doc_id: "code:src:auth_test_ts", file_path: "src/auth.test.ts", title: "auth.test.ts" } }),
+];
+
+describe("include_tests in search", () => {
+  test("the store filters documents by test path", () => {
+    const store = new DocumentStore();
+    store.load(DOCS);
+    const paths = (include_tests?: boolean | "only") =>
+      [...new Set(store.searchDocuments("authentication", { include_tests }).map((r) => r.file_path))].sort();
+    expect(paths()).toEqual(["src/auth.test.ts", "src/auth.ts"]);
+    expect(paths(false)).toEqual(["src/auth.ts"]);
+    expect(paths("only")).toEqual(["src/auth.test.ts"]);
+  });
+
+  test("search_documents takes include_tests", async () => {
+    const harness = await createMcpTestClient(DOCS);
+    const result = await harness.client.callTool({
+      name: "search_documents",
+      arguments: { query: "authentication", include_tests: false },
+    });
+    const results = (result.structuredContent as any).results;
+    expect(results.length).toBeGreaterThan(0);
+    expect(results.every((r: any) => r.file_path === "src/auth.ts")).toBe(true);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,168 @@
+/**
+ * Tests for ts_query plumbing that does not need web-tree-sitter:
+ * grammar choice, grammar discovery and the grammars.json manifest,
+ * path matching, and the tool's answers when nothing can be parsed.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { grammarFor, TreeSitterQuery } from "../src/ts-query";
+import { GrammarManifestError, parseGrammarManifest } from "../src/grammars";
+import { indexCodeFile, isCodeFile, setGrammarLanguages } from "../src/code-indexer";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+describe("grammarFor", () => {
+  test("maps extensions to grammar names", () => {
+    expect(grammarFor("svc/main.go")).toBe("go");
+    expect(grammarFor("ui/App.tsx")).toBe("tsx");
+    expect(grammarFor("lib/util.mts")).toBe("typescript");
+    expect(grammarFor("Program.cs")).toBe("c_sharp");
+    expect(grammarFor("README.md")).toBeNull();
+  });
+});
+
+let dir: string;
+let grammars: string;
+let config: IndexConfig;
+
+const indexedStore = () => indexTree(dir, ["src/auth.ts", "src/main.go"]);
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-tsq-"));
+  grammars = join(dir, "grammars");
+  await mkdir(grammars);
+  await writeTree(dir, {
+    "src/auth.ts": "export function login() {}\n",
+    "src/main.go": "package main\n\nfunc main() {}\n",
+  });
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  setGrammarLanguages(new Map());
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("TreeSitterQuery", () => {
+  test("discovers grammars by file name", async () => {
+    await writeFile(join(grammars, "tree-sitter-go.wasm"), "");
+    await writeFile(join(grammars, "tree-sitter-tsx.wasm"), "");
+    await writeFile(join(grammars, "notes.txt"), "");
+    expect(await new TreeSitterQuery(config, grammars).grammars()).toEqual(new Set(["go", "tsx"]));
+  });
+
+  test("discovers native bindings, preferring a .wasm of the same grammar", async () => {
+    await writeFile(join(grammars, "tree-sitter-hcl.node"), "");
+    await writeFile(join(grammars, "tree-sitter-go.so"), "");
+    await writeFile(join(grammars, "tree-sitter-go.wasm"), "");
+    expect(await new TreeSitterQuery(config, grammars).grammars()).toEqual(new Set(["go", "hcl"]));
+  });
+
+  test("picks up grammars dropped in after the first query", async () => {
+    const ts = new TreeSitterQuery(config, grammars);
+    expect((await ts.grammars()).size).toBe(0);
+    await writeFile(join(grammars, "tree-sitter-go.wasm"), "");
+    expect(await ts.grammars()).toEqual(new Set(["go"]));
+  });
+
+  test("treats a missing grammar directory as empty", async () => {
+    expect((await new TreeSitterQuery(config, join(dir, "nope")).grammars()).size).toBe(0);
+  });
+
+  test("skips matching files without a grammar and returns null for no match", async () => {
+    const ts = new TreeSitterQuery(config, grammars);
+    const store = await indexedStore();
+    const result = await ts.run(store, "src/", "(identifier) @id");
+    expect(result).toEqual({ files: 0, skipped: 2, truncated: false, total: 0, captures: [] });
+    expect((await ts.run(store, "**/*.go", "(identifier) @id"))!.skipped).toBe(1);
+    expect(await ts.run(store, "lib/", "(identifier) @id")).toBeNull();
+  });
+});
+
+describe("grammars.json", () => {
+  test("parses entries and defaults file and language", () => {
+    const [zig, hcl] = parseGrammarManifest(
+      JSON.stringify({
+        grammars: [
+          { name: "zig", extensions: [".zig", ".ZON"] },
+          { name: "hcl", file: "hcl.node", extensions: [".tf"], language: "terraform" },
+        ],
+      }),
+      "/g"
+    );
+    expect(zig).toEqual({ name: "zig", path: "/g/tree-sitter-zig.wasm", runtime: "wasm", extensions: [".zig", ".zon"], language: "zig" });
+    expect([hcl.path, hcl.runtime, hcl.language]).toEqual(["/g/hcl.node", "native", "terraform"]);
+  });
+
+  test("rejects malformed manifests", () => {
+    const bad = (grammars: unknown) => () => parseGrammarManifest(JSON.stringify({ grammars }), "/g");
+    expect(() => parseGrammarManifest("{", "/g")).toThrow(GrammarManifestError);
+    expect(bad([{ name: "zig", file: "../zig.wasm" }])).toThrow('"file" must name a file in the grammar directory');
+    expect(bad([{ name: "zig", file: "zig.dll" }])).toThrow("neither .wasm nor");
+    expect(bad([{ name: "zig", extensions: ["zig"] }])).toThrow('"extensions" must be a list');
+    expect(bad([{ name: "zig" }, { name: "zig" }])).toThrow("listed twice");
+  });
+
+  test("adds grammars, and indexes their extensions as code", async () => {
+    await writeFile(join(grammars, "zig.wasm"), "");
+    await writeFile(
+      join(grammars, "grammars.json"),
+      JSON.stringify({ grammars: [{ name: "zig", file: "zig.wasm", extensions: [".zig"] }, { name: "gone", extensions: [".gn"] }] })
+    );
+    const logged: string[] = [];
+    const ts = new TreeSitterQuery(config, grammars, { log: (msg) => logged.push(msg) });
+    expect(await ts.grammars()).toEqual(new Set(["zig"]));
+    expect(logged[0]).toContain('grammar "gone" names');
+    expect(isCodeFile("src/build.zig")).toBe(true);
+    expect(isCodeFile("BUILD.gn")).toBe(false);
+
+    await writeFile(join(dir, "src", "build.zig"), "pub fn main() void {}\n");
+    const doc = await indexCodeFile(join(dir, "src", "build.zig"), dir, "code");
+    expect(doc.meta.facets.language).toEqual(["zig"]);
+  });
+
+  test("keeps the last good manifest when an edit breaks it", async () => {
+    await writeFile(join(grammars, "zig.wasm"), "");
+    await writeFile(join(grammars, "grammars.json"), JSON.stringify({ grammars: [{ name: "zig", file: "zig.wasm", extensions: [".zig"] }] }));
+    const logged: string[] = [];
+    const ts = new TreeSitterQuery(config, grammars, { log: (msg) => logged.push(msg) });
+    await ts.grammars();
+    await writeFile(join(grammars, "grammars.json"), "{ not json");
+    expect(await ts.grammars()).toEqual(new Set(["zig"]));
+    expect(logged[0]).toContain("is not valid JSON");
+    expect(isCodeFile("main.zig")).toBe(true);
+  });
+});
+
+describe("ts_query tool", () => {
+  test("is registered only with a grammar directory", async () => {
+    const without = await createMcpTestClient((await indexedStore()).exportDocuments());
+    const names = (await without.client.listTools()).tools.map((t) => t.name);
+    expect(names.includes("ts_query")).toBe(false);
+    await without.cleanup();
+  });
+
+  test("reports not_found, skipped files, and unknown grammars", async () => {
+    await writeFile(join(grammars, "tree-sitter-python.wasm"), "");
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      treeSitter: new TreeSitterQuery(config, grammars),
+    });
+    const missing = await harness.client.callTool({ name: "ts_query", arguments: { path: "lib/", query: "(identifier) @id" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+
+    const skipped = await harness.client.callTool({ name: "ts_query", arguments: { path: "src/", query: "(identifier) @id" } });
+    expect(getToolText(skipped as any)).toContain("0 capture(s) in 0 file(s) under src/ (2 skipped without a grammar)");
+
+    const unknown = await harness.client.callTool({
+      name: "ts_query",
+      arguments: { path: "src/", query: "(identifier) @id", grammar: "go" },
+    });
+    expect(unknown.isError).toBe(true);
+    expect(getToolText(unknown as any)).toContain("installed: python");
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,145 @@
+/**
+ * Tests for location URIs: formatting and parsing, resolving to the
+ * innermost section, collections sharing a path, and passing a result's
+ * uri back into the read tools.
+ */
+
+import { describe, test, expect } from "bun:test";
+import { formatUri, parseUri, resolveUri, UriError } from "../src/uris";
+import { indexCodeContent } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+
+const TIME = "2026-01-01T00:00:00.000Z";
+
+const MANAGER = [
+  "package cluster",
+  "",
+  "type Manager struct {",
+  "\tnodes []string",
+  "}",
+  "",
+  "func (m *Manager) Join(node string) error {",
+  "\tm.nodes = append(m.nodes, node)",
+  "\treturn nil",
+  "}",
+  "",
+  "func Leave(node string) {}",
+  "",
+].join("\n");
+
+const NOTEBOOK = JSON.stringify({
+  nbformat: 4,
+  nbformat_minor: 5,
+  metadata: { kernelspec: { name: "python3", language: "python", display_name: "Python 3" } },
+  cells: [
+    { cell_type: "code", metadata: {}, execution_count: 1, source: ["import pandas as pd\n", "events = pd.read_csv('events.csv')"], outputs: [] },
+    { cell_type: "code", metadata: {}, execution_count: 2, source: ["def churn(events):\n", "    return events.tail()\n"], outputs: [] },
+  ],
+});
+
+function store(): DocumentStore {
+  const s = new DocumentStore();
+  s.load([
+    indexCodeContent(MANAGER, "internal/cluster/manager.go", "code", TIME),
+    indexCodeContent(NOTEBOOK, "analysis/churn.ipynb", "code", TIME),
+  ]);
+  return s;
+}
+
+describe("formatUri / parseUri", () => {
+  test("round-trip paths, lines, cells, and collections", () => {
+    const uris = [
+      "treenav://file/internal/cluster/manager.go#L42-58",
+      "treenav://file/internal/cluster/manager.go#L7",
+      "treenav://file/analysis/churn.ipynb#cell3:L1-2",
+      "treenav://file/docs/My%20Notes.md?collection=wiki#L10-24",
+      "treenav://file/go.mod",
+    ];
+    for (const uri of uris) expect(formatUri(parseUri(uri))).toBe(uri);
+    expect(parseUri("treenav://file/docs/My%20Notes.md?collection=wiki#L10-24")).toEqual({
+      path: "docs/My Notes.md",
+      collection: "wiki",
+      line_start: 10,
+      line_end: 24,
+    });
+  });
+
+  test("accepts GitHub-style ranges and a single line", () => {
+    expect(parseUri("treenav://file/a.go#L42-L58")).toEqual({ path: "a.go", line_start: 42, line_end: 58 });
+    expect(formatUri({ path: "a.go", line_start: 5, line_end: 5 })).toBe("treenav://file/a.go#L5");
+  });
+
+  test("rejects other schemes, bad fragments, and backwards ranges", () => {
+    expect(() => parseUri("md-tree://file/a.go")).toThrow(UriError);
+    expect(() => parseUri("treenav://file/a.go#line42")).toThrow(UriError);
+    expect(() => parseUri("treenav://file/a.go#L9-3")).toThrow(UriError);
+    expect(() => parseUri("treenav://file/#L1")).toThrow(UriError);
+  });
+});
+
+describe("resolveUri", () => {
+  test("picks the innermost section spanning the lines", () => {
+    const s = store();
+    expect(resolveUri(s, "treenav://file/internal/cluster/manager.go#L8-9").node!.title).toBe("method Join");
+    expect(resolveUri(s, "treenav://file/internal/cluster/manager.go#L12").node!.title).toBe("function Leave");
+    const whole = resolveUri(s, "treenav://file/internal/cluster/manager.go");
+    expect([whole.doc.doc_id, whole.node]).toEqual(["code:internal:cluster:manager_go", null]);
+  });
+
+  test("search results carry URIs that resolve back to them", () => {
+    const s = store();
+    const hits = [...s.searchDocuments("nodes"), ...s.searchDocuments("churn")];
+    expect(hits.length).toBeGreaterThan(2);
+    for (const hit of hits) {
+      const { doc, node } = resolveUri(s, hit.uri);
+      expect([doc.doc_id, node!.node_id]).toEqual([hit.doc_id, hit.node_id]);
+    }
+    const fn = s.searchDocuments("churn").find((r) => r.node_title === "function churn")!;
+    expect(fn.uri).toBe("treenav://file/analysis/churn.ipynb#cell2:L1-2");
+  });
+
+  test("a path in two collections needs the collection", () => {
+    const s = store();
+    s.addDocument(indexCodeContent(MANAGER, "internal/cluster/manager.go", "vendor", TIME));
+    const [hit] = s.searchDocuments("Leave");
+    expect(hit.uri).toContain(`?collection=${hit.collection}#`);
+    expect(resolveUri(s, hit.uri).doc.collection).toBe(hit.collection);
+    expect(() => resolveUri(s, "treenav://file/internal/cluster/manager.go#L12")).toThrow(/add \?collection=/);
+    expect(() => resolveUri(s, "treenav://file/missing.go#L1")).toThrow(UriError);
+  });
+});
+
+describe("uri input on the read tools", () => {
+  test("a find_symbol result opens in get_node_content and navigate_tree", async () => {
+    const harness = await createMcpTestClient(store().exportDocuments());
+    const found = await harness.client.callTool({ name: "find_symbol", arguments: { query: "Join" } });
+    const [hit] = (found.structuredContent as any).results;
+    expect(hit.uri).toBe("treenav://file/internal/cluster/manager.go#L7-10");
+    expect(getToolText(found)).toContain(`URI: ${hit.uri}`);
+
+    const content = await harness.client.callTool({ name: "get_node_content", arguments: { uri: hit.uri } });
+    expect((content.structuredContent as any).nodes.map((n: any) => [n.node_id, n.uri])).toEqual([[hit.node_id, hit.uri]]);
+    expect(getToolText(content)).toContain("m.nodes = append(m.nodes, node)");
+
+    const subtree = await harness.client.callTool({ name: "navigate_tree", arguments: { uri: hit.uri } });
+    expect((subtree.structuredContent as any).node_id).toBe(hit.node_id);
+
+    const tree = await harness.client.callTool({ name: "get_tree", arguments: { uri: "treenav://file/internal/cluster/manager.go" } });
+    expect((tree.structuredContent as any).doc_id).toBe("code:internal:cluster:manager_go");
+    await harness.cleanup();
+  });
+
+  test("bad or incomplete arguments are errors", async () => {
+    const harness = await createMcpTestClient(store().exportDocuments());
+    const calls = [
+      { name: "get_node_content", arguments: { uri: "treenav://file/internal/cluster/manager.go" } },
+      { name: "get_node_content", arguments: { doc_id: "code:internal:cluster:manager_go" } },
+      { name: "navigate_tree", arguments: { uri: "treenav://file/nowhere.go#L1" } },
+      { name: "get_tree", arguments: { doc_id: "code:internal:cluster:manager_go", uri: "treenav://file/internal/cluster/manager.go" } },
+      { name: "get_tree", arguments: {} },
+    ];
+    for (const call of calls) expect((await harness.client.callTool(call)).isError).toBe(true);
+    await harness.cleanup();
+  });
+});
@@ -0,0 +1,656 @@
+/**
+ * Tests for usage_stats: reference kinds, Go import aliases, package
+ * attribution, package scope, test files, and the tool; references
+ * across collections and find_references; callers, direct and
+ * transitive; error tracing; and panic and exit sites.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import {
+  classifyErrorUse,
+  classifyUsage,
+  goImports,
+  groupedDeclarations,
+  jsImports,
+  referencesByCollection,
+  UsageStats,
+} from "../src/usage";
+import { indexCodeFile } from "../src/code-indexer";
+import { GoModuleIndex } from "../src/go-modules";
+import type { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { createMcpTestClient, getToolText } from "./fixtures/helpers";
+import { codeConfig, indexTree, writeTree } from "./fixtures/tree";
+
+const kind = (line: string, name: string, isType: boolean, qualifier = "") => {
+  const index = line.indexOf(qualifier + name);
+  return classifyUsage(line, index, index + qualifier.length + name.length, isType);
+};
+
+describe("classifyUsage", () => {
+  test("tells calls, type uses, embeds, and values apart", () => {
+    expect(kind("\tc, err := db.Connect(ctx)", "Connect", false, "db.")).toBe("call");
+    expect(kind("\tretry(Connect, 3)", "Connect", false)).toBe("value");
+    expect(kind("\tvar c *db.Conn", "Conn", true, "db.")).toBe("type_use");
+    expect(kind("\treturn Conn{addr: a}", "Conn", true)).toBe("type_use");
+    expect(kind("\t*db.Conn", "Conn", true, "db.")).toBe("embed");
+    expect(kind("class Admin extends User {", "User", true)).toBe("embed");
+    expect(kind("class Admin(Base, User):", "User", true)).toBe("embed");
+  });
+});
+
+describe("goImports", () => {
+  test("maps aliases to import paths", () => {
+    const source = `package api
+
+import "fmt"
+
+import (
+	"example.com/app/db"
+	pg "example.com/app/store/v2"
+	_ "example.com/app/driver"
+	. "example.com/app/util"
+)
+`;
+    expect([...goImports(source)]).toEqual([
+      ["db", "example.com/app/db"],
+      ["pg", "example.com/app/store/v2"],
+      [".", "example.com/app/util"],
+      ["fmt", "fmt"],
+    ]);
+  });
+});
+
+describe("jsImports", () => {
+  test("lists the module specifiers of imports, re-exports, and requires", () => {
+    const source = `import { render } from "@acme/ui";
+import type {
+  Props,
+} from "@acme/ui/props";
+import "./polyfill";
+export * from "./local";
+const fs = require("node:fs");
+const lazy = await import("@acme/charts");
+import { render as again } from "@acme/ui";
+`;
+    expect(jsImports(source)).toEqual(["@acme/ui", "@acme/ui/props", "./polyfill", "./local", "node:fs", "@acme/charts"]);
+  });
+});
+
+// ── A scratch module ─────────────────────────────────────────────────
+
+const FILES: Record<string, string> = {
+  "go.mod": "module example.com/app\n\ngo 1.22\n",
+  "db/conn.go": `package db
+
+type Conn struct {
+	addr string
+}
+
+func Connect(addr string) (*Conn, error) {
+	return &Conn{addr: addr}, nil
+}
+
+func (c *Conn) Close() error { return nil }
+
+func Version() string { return "1" }
+`,
+  "db/pool.go": `package db
+
+func pool() *Conn {
+	c, _ := Connect("x") // Connect retries
+	return c
+}
+`,
+  "api/handler.go": `package api
+
+import (
+	"example.com/app/db"
+)
+
+type Handler struct {
+	*db.Conn
+	backup db.Conn
+}
+
+func serve() {
+	c, _ := db.Connect("localhost")
+	defer c.Close()
+	log("db.Connect failed")
+}
+`,
+  "other/conn.go": `package other
+
+func Connect() {}
+
+func use() { Connect() }
+`,
+};
+
+let dir: string;
+let config: IndexConfig;
+
+const indexedStore = () => indexTree(dir, Object.keys(FILES));
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-usage-"));
+  await writeTree(dir, FILES);
+  config = codeConfig(dir);
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("UsageStats", () => {
+  test("counts a function by consuming package, following imports", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const report = (await usage.symbol(await indexedStore(), "db.Connect"))!;
+    expect(report.definitions.map((d) => [d.file_path, d.package])).toEqual([["db/conn.go", "example.com/app/db"]]);
+    expect(report.consumers.map((c) => [c.package, c.total, c.internal])).toEqual([
+      ["example.com/app/api", 1, false],
+      ["example.com/app/db", 1, true],
+    ]);
+    expect(report.by_kind.call).toBe(2);
+  });
+
+  test("sorts type references into embeds and type uses", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const report = (await usage.symbol(await indexedStore(), "Conn"))!;
+    const api = report.consumers.find((c) => c.package === "example.com/app/api")!;
+    expect(api.by_kind).toEqual({ call: 0, type_use: 1, embed: 1, value: 0 });
+  });
+
+  test("reports external use of a package's exported symbols", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const report = (await usage.package(await indexedStore(), "example.com/app/db"))!;
+    expect(report.consumers.map((c) => c.package)).toEqual(["example.com/app/api"]);
+    expect(report.symbols!.map((s) => [s.name, s.total])).toEqual([
+      ["Conn", 2],
+      ["Connect", 1],
+      ["Version", 0],
+    ]);
+    expect(await usage.package(await indexedStore(), "nowhere")).toBeNull();
+  });
+
+  test("include_tests leaves test files out, or counts nothing else", async () => {
+    await writeFile(join(dir, "db/conn_test.go"), 'package db\n\nfunc TestConnect(t *testing.T) {\n\tConnect("a")\n\tConnect("b")\n}\n');
+    const store = await indexedStore();
+    store.addDocument(await indexCodeFile(join(dir, "db/conn_test.go"), dir, "code"));
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+
+    expect((await usage.symbol(store, "db.Connect"))!.total).toBe(4);
+    const production = (await usage.symbol(store, "db.Connect", { include_tests: false }))!;
+    expect(production.sites.map((s) => s.file_path)).toEqual(["api/handler.go", "db/pool.go"]);
+    const tests = (await usage.symbol(store, "db.Connect", { include_tests: "only" }))!;
+    expect(tests.sites.map((s) => [s.file_path, s.line])).toEqual([["db/conn_test.go", 4], ["db/conn_test.go", 5]]);
+  });
+});
+
+// ── Two repositories side by side ────────────────────────────────────
+
+const LIB: Record<string, string> = {
+  "go.mod": "module example.com/lib\n\ngo 1.22\n",
+  "db/db.go": "package db\n\nfunc Open() *Handle { return nil }\n\ntype Handle struct{}\n\nfunc (h *Handle) Close() {}\n",
+  "db/pool.go": "package db\n\nfunc reopen() *Handle { return Open() }\n",
+  "ui/package.json": '{ "name": "@acme/ui" }\n',
+  "ui/src/render.ts": 'export function render(): string {\n  return "";\n}\n',
+};
+
+const SVC: Record<string, string> = {
+  "go.mod": "module example.com/svc\n\ngo 1.22\n\nrequire example.com/lib v0.1.0\n",
+  "main.go": 'package main\n\nimport "example.com/lib/db"\n\nfunc main() {\n\th := db.Open()\n\th.Close()\n}\n',
+  "other/file.go": "package other\n\ntype file struct{}\n\nfunc (f *file) Close() {}\n\nfunc use(f *file) { f.Close() }\n",
+  "web/app.ts": 'import { render } from "@acme/ui/src/render";\n\nexport const page = () => render();\n',
+  "web/local.ts": "function render() {}\n\nrender();\n",
+};
+
+describe("references across collections", () => {
+  let workspace: IndexConfig;
+
+  beforeEach(async () => {
+    await writeTree(join(dir, "lib"), LIB);
+    await writeTree(join(dir, "svc"), SVC);
+    workspace = {
+      ...codeConfig(join(dir, "svc")),
+      code_collections: [
+        { name: "svc", root: join(dir, "svc"), weight: 1.0 },
+        { name: "lib", root: join(dir, "lib"), weight: 1.0 },
+      ],
+    };
+  });
+
+  const workspaceStore = async () =>
+    indexTree(join(dir, "lib"), Object.keys(LIB), await indexTree(join(dir, "svc"), Object.keys(SVC), undefined, "svc"), "lib");
+  const sites = (report: { sites: Array<{ collection: string; file_path: string; line: number }> }) =>
+    report.sites.map((s) => [s.collection, s.file_path, s.line]);
+
+  test("follow Go imports into another collection's module", async () => {
+    const usage = new UsageStats(workspace, new GoModuleIndex(workspace));
+    const store = await workspaceStore();
+    const open = (await usage.symbol(store, "db.Open"))!;
+    expect(sites(open)).toEqual([["lib", "db/pool.go", 3], ["svc", "main.go", 6]]);
+    expect(open.consumers.map((c) => [c.collection, c.package, c.internal])).toEqual([
+      ["lib", "example.com/lib/db", true],
+      ["svc", "example.com/svc", false],
+    ]);
+    // A method of the same name in a file that does not import the package is not a reference
+    expect(sites((await usage.symbol(store, "Handle.Close"))!)).toEqual([["svc", "main.go", 7]]);
+  });
+
+  test("count JS and TS uses from another collection only where its package.json name is imported", async () => {
+    const usage = new UsageStats(workspace);
+    const store = await workspaceStore();
+    const render = store.listDocuments({ limit: Infinity }).documents.find((d) => d.collection === "lib" && d.file_path === "ui/src/render.ts")!;
+    expect(sites((await usage.symbolAt(store, render.doc_id, 1))!)).toEqual([["svc", "web/app.ts", 3]]);
+  });
+
+  test("referencesByCollection puts the defining collections first and marks the rest external", async () => {
+    const usage = new UsageStats(workspace, new GoModuleIndex(workspace));
+    const groups = referencesByCollection((await usage.symbol(await workspaceStore(), "db.Open"))!);
+    expect(groups.map((g) => [g.collection, g.external, g.total, g.files])).toEqual([
+      ["lib", false, 1, 1],
+      ["svc", true, 1, 1],
+    ]);
+  });
+
+  test("find_references groups the sites by collection", async () => {
+    const store = await workspaceStore();
+    const harness = await createMcpTestClient(store.exportDocuments(), { usage: new UsageStats(workspace, new GoModuleIndex(workspace)) });
+    const result = await harness.client.callTool({ name: "find_references", arguments: { symbol: "db.Open" } });
+    const data = result.structuredContent as any;
+    expect([data.total, data.cross_collection]).toEqual([2, 1]);
+    expect(data.collections.map((g: any) => [g.collection, g.external, g.sites[0].file_path])).toEqual([
+      ["lib", false, "db/pool.go"],
+      ["svc", true, "main.go"],
+    ]);
+    const text = getToolText(result as any);
+    expect(text).toContain("References to db.Open (function, lib:db/db.go:3): 2 reference(s) in 2 collection(s), 1 from other collections");
+    expect(text).toContain("svc: 1 in 1 file(s) (1 call)\n  main.go:6 call  h := db.Open()");
+
+    const svc = await harness.client.callTool({ name: "find_references", arguments: { symbol: "db.Open", collection: "svc" } });
+    expect((svc.structuredContent as any).collections.map((g: any) => g.collection)).toEqual(["svc"]);
+    const stats = await harness.client.callTool({ name: "usage_stats", arguments: { symbol: "db.Open" } });
+    expect(getToolText(stats as any)).toContain("svc:example.com/svc  1 in 1 file(s) (1 call)");
+    const missing = await harness.client.callTool({ name: "find_references", arguments: { symbol: "Nope" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    await harness.cleanup();
+  });
+});
+
+describe("UsageStats.callers", () => {
+  async function chainedStore(): Promise<DocumentStore> {
+    await writeFile(
+      join(dir, "api/router.go"),
+      `package api
+
+func route() {
+	serve()
+}
+
+func mount() {
+	route()
+	route()
+}
+
+var _ = route
+`
+    );
+    const store = await indexedStore();
+    store.addDocument(await indexCodeFile(join(dir, "api/router.go"), dir, "code"));
+    return store;
+  }
+
+  test("direct callers only at depth 1", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const report = (await usage.callers(await chainedStore(), "db.Connect", { depth: 1 }))!;
+    expect(report.callers.map((c) => [c.name, c.depth, c.calls])).toEqual([
+      ["serve", 1, ["Connect"]],
+      ["pool", 1, ["Connect"]],
+    ]);
+    expect(report.truncated).toEqual(["depth"]);
+  });
+
+  test("follows callers of callers until nothing calls them", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const report = (await usage.callers(await chainedStore(), "db.Connect"))!;
+    expect(report.callers.map((c) => [c.name, c.depth])).toEqual([
+      ["serve", 1],
+      ["pool", 1],
+      ["route", 2],
+      ["mount", 3],
+    ]);
+    expect(report.callers.find((c) => c.name === "mount")!.sites.map((s) => s.line)).toEqual([8, 9]);
+    expect([report.depth, report.truncated]).toEqual([3, []]);
+  });
+
+  test("fan-out and node limits say they cut the closure short", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const store = await chainedStore();
+    const fanned = (await usage.callers(store, "db.Connect", { fan_out: 1 }))!;
+    expect(fanned.callers.filter((c) => c.depth === 1).length).toBe(1);
+    expect(fanned.truncated).toContain("fan_out");
+    const capped = (await usage.callers(store, "db.Connect", { max_nodes: 3 }))!;
+    expect([capped.callers.length, capped.truncated]).toEqual([3, ["nodes"]]);
+  });
+});
+
+describe("error tracing", () => {
+  test("classifyErrorUse tells creation, wrapping, comparison, and return apart", () => {
+    const role = (text: string, isType = false) => classifyErrorUse(text, isType ? "NotConnectedError" : "ErrNotConnected", isType);
+    expect(role('return fmt.Errorf("send: %w", ErrNotConnected)')).toBe("wrapped");
+    expect(role('return fmt.Errorf("send: %v", ErrNotConnected)')).toBe("returned");
+    expect(role("if errors.Is(err, client.ErrNotConnected) {")).toBe("compared");
+    expect(role("if err == ErrNotConnected {")).toBe("compared");
+    expect(role("case ErrNotConnected:")).toBe("compared");
+    expect(role("return nil, ErrNotConnected")).toBe("returned");
+    expect(role("err = ErrNotConnected")).toBe("other");
+    expect(role("return &NotConnectedError{addr: addr}", true)).toBe("created");
+    expect(role('raise NotConnectedError("down") from exc', true)).toBe("wrapped");
+    expect(role("except NotConnectedError:", true)).toBe("compared");
+  });
+
+  test("groupedDeclarations reads the names in var and const blocks", () => {
+    const source = 'package client\n\nvar (\n\tErrNotConnected = errors.New("x")\n\tErrA, ErrB = f(\n\t\tnil,\n\t)\n)\n\nconst (\n\tA = iota\n\tB\n)\n';
+    expect(groupedDeclarations(source.replace(/"[^"]*"/g, (m) => " ".repeat(m.length)).split("\n"))).toEqual([
+      { name: "ErrNotConnected", line: 4 },
+      { name: "ErrA", line: 5 },
+      { name: "ErrB", line: 5 },
+      { name: "A", line: 11 },
+      { name: "B", line: 12 },
+    ]);
+  });
+
+  async function errorStore(): Promise<DocumentStore> {
+    const files: Record<string, string> = {
+      "client/errors.go": 'package client\n\nimport "errors"\n\nvar (\n\tErrNotConnected = errors.New("not connected")\n\tErrClosed       = errors.New("closed")\n)\n',
+      "client/send.go": `package client
+
+import "fmt"
+
+type Client struct {
+	conn any
+}
+
+func (c *Client) Send(b []byte) error {
+	if c.conn == nil {
+		return fmt.Errorf("send %d bytes: %w", len(b), ErrNotConnected)
+	}
+	return nil
+}
+`,
+      "api/handler.go": `package api
+
+import (
+	"errors"
+	"fmt"
+
+	"example.com/app/client"
+)
+
+func publish(c *client.Client) error {
+	if err := c.Send(nil); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	return nil
+}
+
+func handle(c *client.Client) {
+	err := publish(c)
+	if errors.Is(err, client.ErrNotConnected) {
+		retry()
+	}
+}
+`,
+    };
+    await writeTree(dir, files);
+    return indexTree(dir, Object.keys(files));
+  }
+
+  test("finds a sentinel in a var block, its uses, and the callers it travels through", async () => {
+    const usage = new UsageStats(config, new GoModuleIndex(config));
+    const trace = (await usage.traceError(await errorStore(), "client.ErrNotConnected"))!;
+    expect(trace.definitions.map((d) => [d.name, d.file_path, d.line])).toEqual([["ErrNotConnected", "client/errors.go", 6]]);
+    expect(trace.sites.map((s) => [s.role, s.file_path, s.line, s.function ?? null])).toEqual([
+      ["compared", "api/handler.go", 19, "handle"],
+      ["created", "client/errors.go", 6, null],
+      ["wrapped", "client/send.go", 11, "Client.Send"],
+    ]);
+    expect(trace.origins.map((o) => o.name)).toEqual(["Client.Send"]);
+    expect(trace.chain.map((h) => [h.name, h.depth, h.propagates, h.wraps, h.checks])).toEqual([
+      ["publish", 1, true, true, false],
+      ["handle", 2, false, false, true],
+    ]);
+    expect(trace.truncated).toEqual([]);
+
+    const shallow = (await usage.traceError(await errorStore(), "ErrNotConnected", { depth: 1 }))!;
+    expect([shallow.chain.map((h) => h.name), shallow.truncated]).toEqual([["publish"], ["depth"]]);
+  });
+});
+
+const PANICS: Record<string, string> = {
+  "lib/parse.go": `package lib
+
+func mustParse(s string) int {
+	if s == "" {
+		panic("empty input")
+	}
+	return len(s)
+}
+
+func Parse(s string) int { return mustParse(s) }
+
+func Safe(s string) (n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			n = -1
+		}
+	}()
+	return mustParse(s)
+}
+
+func guard() {
+	if r := recover(); r != nil {
+		log.Printf("recovered: %v", r)
+	}
+}
+
+func Guarded() int {
+	defer guard()
+	panic("always")
+}
+
+func Background(s string) {
+	go mustParse(s)
+}
+`,
+  "lib/parse_test.go": `package lib
+
+func TestParse(t *testing.T) {
+	if Parse("x") != 1 {
+		t.Fatalf("Parse: got %d", Parse("x"))
+	}
+}
+`,
+  "cmd/main.go": `package main
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalf("run: %v", err)
+	}
+	// os.Exit(3)
+	fmt.Println("panic(\"in a string\")")
+	os.Exit(0)
+}
+`,
+};
+
+async function panicStore(): Promise<DocumentStore> {
+  await writeTree(dir, PANICS);
+  return indexTree(dir, Object.keys(PANICS));
+}
+
+describe("UsageStats.exits", () => {
+  test("lists panics, fatal calls, and exits, leaving t.Fatal, comments, and strings out", async () => {
+    const report = await new UsageStats(config).exits(await panicStore());
+    expect(report.sites.map((s) => [s.kind, s.call, s.file_path, s.line, s.function])).toEqual([
+      ["fatal", "log.Fatalf", "cmd/main.go", 5, "main"],
+      ["exit", "os.Exit", "cmd/main.go", 9, "main"],
+      ["panic", "panic", "lib/parse.go", 5, "mustParse"],
+      ["panic", "panic", "lib/parse.go", 29, "Guarded"],
+    ]);
+    expect(report.by_kind).toEqual({ panic: 2, fatal: 1, exit: 1 });
+    expect(report.truncated).toEqual([]);
+  });
+
+  test("finds the recover: deferred in place, in a caller, or escaping through go and exported callers", async () => {
+    const report = await new UsageStats(config).exits(await panicStore(), { include_tests: false });
+    const [, , parse, guarded] = report.sites;
+    expect(guarded.recover).toBe("local");
+    expect(parse.recover).toBe("partial");
+    expect(parse.recovered_by!.map((g) => [g.name, g.depth])).toEqual([["Safe", 1]]);
+    expect(parse.escapes_via!.map((e) => e.name)).toEqual(["mustParse", "Parse"]);
+    expect(report.sites[0].recover).toBeUndefined();
+  });
+
+  test("depth bounds the search and path narrows the sites", async () => {
+    const store = await panicStore();
+    const usage = new UsageStats(config);
+    expect((await usage.exits(store, { path: "cmd" })).sites.map((s) => s.kind)).toEqual(["fatal", "exit"]);
+    await writeFile(join(dir, "lib/chain.go"), "package lib\n\nfunc a() { b() }\n\nfunc b() { panic(\"deep\") }\n");
+    store.addDocument(await indexCodeFile(join(dir, "lib/chain.go"), dir, "code"));
+    const shallow = await usage.exits(store, { path: "lib/chain.go", depth: 1 });
+    expect([shallow.sites[0].recover, shallow.truncated]).toEqual(["none", ["depth"]]);
+  });
+});
+
+describe("usage_stats tool", () => {
+  test("summarizes references and rejects ambiguous input", async () => {
+    const harness = await createMcpTestClient((await indexedStore()).exportDocuments(), {
+      usage: new UsageStats(config),
+    });
+    const result = await harness.client.callTool({ name: "usage_stats", arguments: { symbol: "Conn.Close" } });
+    const data = result.structuredContent as any;
+    expect(data.total).toBe(1);
+    expect(data.sites[0].text).toBe("defer c.Close()");
+    const text = getToolText(result as any);
+    expect(text).toContain("Usage of Conn.Close (method, db/conn.go:11): 1 reference(s) from 1 package(s) — 1 call");
+    expect(text).toContain("api/handler.go:14 call  defer c.Close()");
+
+    const missing = await harness.client.callTool({ name: "usage_stats", arguments: { symbol: "Nope" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    const both = await harness.client.callTool({ name: "usage_stats", arguments: { symbol: "Conn", package: "db" } });
+    expect(both.isError).toBe(true);
+    await harness.cleanup();
+  });
+
+  test("a uri counts the symbol defined there, not its namesakes", async () => {
+    const store = await indexedStore();
+    const harness = await createMcpTestClient(store.exportDocuments(), { usage: new UsageStats(config) });
+    const result = await harness.client.callTool({ name: "usage_stats", arguments: { uri: "treenav://file/db/conn.go#L7-9" } });
+    const data = result.structuredContent as any;
+    const qualified = (await new UsageStats(config).symbol(store, "db.Connect"))!;
+    expect(data.target).toBe("Connect");
+    expect(data.definitions.map((d: any) => d.file_path)).toEqual(["db/conn.go"]);
+    expect(data.total).toBe(qualified.total);
+    expect(data.sites[0].uri).toBe(`treenav://file/${data.sites[0].file_path}#L${data.sites[0].line}`);
+
+    const between = await harness.client.callTool({ name: "usage_stats", arguments: { uri: "treenav://file/db/conn.go#L2" } });
+    expect(between.isError).toBe(true);
+    await harness.cleanup();
+  });
+
+  test("callers answers direct or transitive, from a symbol or a uri", async () => {
+    await writeFile(join(dir, "api/router.go"), "package api\n\nfunc route() {\n\tserve()\n}\n");
+    const store = await indexedStore();
+    store.addDocument(await indexCodeFile(join(dir, "api/router.go"), dir, "code"));
+    const harness = await createMcpTestClient(store.exportDocuments(), { usage: new UsageStats(config) });
+
+    const direct = await harness.client.callTool({ name: "callers", arguments: { symbol: "db.Connect" } });
+    expect((direct.structuredContent as any).callers.map((c: any) => c.name)).toEqual(["serve", "pool"]);
+    expect((direct.structuredContent as any).truncated).toEqual(["depth"]);
+
+    const closure = await harness.client.callTool({
+      name: "callers",
+      arguments: { uri: "treenav://file/db/conn.go#L7-9", transitive: true },
+    });
+    const data = closure.structuredContent as any;
+    expect(data.callers.map((c: any) => [c.name, c.depth, c.uri])).toEqual([
+      ["serve", 1, "treenav://file/api/handler.go#L12"],
+      ["pool", 1, "treenav://file/db/pool.go#L3"],
+      ["route", 2, "treenav://file/api/router.go#L3"],
+    ]);
+    expect(data.truncated).toEqual([]);
+    const text = getToolText(closure as any);
+    expect(text).toContain("3 function(s) eventually call Connect (function, db/conn.go:7), up to 2 level(s) away");
+    expect(text).toContain("api/router.go:3 function route → serve  (line 4)");
+
+    const drawn = await harness.client.callTool({
+      name: "callers",
+      arguments: { symbol: "db.Connect", transitive: true, format: "mermaid" },
+    });
+    const diagram = (drawn.structuredContent as any).diagram;
+    expect(diagram).toContain('n0["Connect"]');
+    expect(diagram).toContain('["route"]');
+    expect(diagram.split("\n").filter((l: string) => l.includes("-->"))).toHaveLength(3);
+    expect(getToolText(drawn as any).startsWith("```mermaid\n")).toBe(true);
+
+    const neither = await harness.client.callTool({ name: "callers", arguments: {} });
+    expect(neither.isError).toBe(true);
+    await harness.cleanup();
+  });
+
+  test("trace_errors lists the sites by role and the chain, from a symbol or a uri", async () => {
+    await writeFile(join(dir, "db/errors.go"), 'package db\n\nimport "errors"\n\nvar ErrNoConn = errors.New("no connection")\n');
+    await writeFile(
+      join(dir, "db/dial.go"),
+      "package db\n\nfunc dial(addr string) (*Conn, error) {\n\tif addr == \"\" {\n\t\treturn nil, ErrNoConn\n\t}\n\treturn nil, nil\n}\n\nfunc open() *Conn {\n\tc, err := dial(\"\")\n\tif err != nil {\n\t\treturn nil\n\t}\n\treturn c\n}\n"
+    );
+    const store = await indexTree(dir, ["db/errors.go", "db/dial.go"], await indexedStore());
+    const harness = await createMcpTestClient(store.exportDocuments(), { usage: new UsageStats(config) });
+
+    const result = await harness.client.callTool({ name: "trace_errors", arguments: { symbol: "ErrNoConn" } });
+    const data = result.structuredContent as any;
+    expect(data.by_role).toEqual({ created: 1, wrapped: 0, compared: 0, returned: 1, other: 0 });
+    expect(data.sites.map((s: any) => s.uri)).toEqual(["treenav://file/db/dial.go#L5", "treenav://file/db/errors.go#L5"]);
+    expect(data.chain.map((h: any) => [h.name, h.propagates])).toEqual([["open", false]]);
+    const text = getToolText(result as any);
+    expect(text).toContain("ErrNoConn (variable, db/errors.go:5): 2 site(s) — 1 created, 1 returned");
+    expect(text).toContain("  db/dial.go:5 in dial  return nil, ErrNoConn");
+    expect(text).toContain("Up the call chain from dial: 1 caller(s)");
+    expect(text).toContain("  db/dial.go:10 function open → dial  (line 11; stops it)");
+
+    const byUri = await harness.client.callTool({ name: "trace_errors", arguments: { uri: "treenav://file/db/errors.go#L5" } });
+    expect((byUri.structuredContent as any).target).toBe("ErrNoConn");
+    const missing = await harness.client.callTool({ name: "trace_errors", arguments: { symbol: "ErrNope" } });
+    expect((missing.structuredContent as any).status).toBe("not_found");
+    expect((await harness.client.callTool({ name: "trace_errors", arguments: {} })).isError).toBe(true);
+    await harness.cleanup();
+  });
+
+  test("panic_sites lists the sites by file with what stops each", async () => {
+    const store = await panicStore();
+    const harness = await createMcpTestClient(store.exportDocuments(), { usage: new UsageStats(config) });
+    const result = await harness.client.callTool({ name: "panic_sites", arguments: { kind: "panic", include_tests: false } });
+    const data = result.structuredContent as any;
+    expect([data.total, data.by_kind, data.by_recover]).toEqual([
+      2,
+      { panic: 2, fatal: 1, exit: 1 },
+      { local: 1, upstream: 0, partial: 1, none: 0 },
+    ]);
+    expect(data.sites[0].recovered_by[0].uri).toBe("treenav://file/lib/parse.go#L12");
+    const text = getToolText(result as any);
+    expect(text).toContain("2 panic, 1 fatal, 1 exit; panics recovered: 1 local, 1 partial");
+    expect(text).toContain("  L5  panic in mustParse — recovered by some callers: Safe; escapes via mustParse, Parse");
+    expect(text).toContain("  L29  panic in Guarded — recovered in the function");
+
+    const exits = await harness.client.callTool({ name: "panic_sites", arguments: { path: "cmd" } });
+    expect(getToolText(exits as any)).toContain("  L5  log.Fatalf in main — logs, then exits the process");
+    const none = await harness.client.callTool({ name: "panic_sites", arguments: { recover: "none" } });
+    expect(getToolText(none as any)).toContain("None match the filters.");
+    await harness.cleanup();
+  });
+});
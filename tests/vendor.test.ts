@@ -0,0 +1,113 @@
+/**
+ * Tests for the vendor policy: vendor/ detection, exclusion from file
+ * discovery, downranking, and deduplication against the module cache.
+ */
+
+import { afterEach, beforeEach, describe, expect, test } from "bun:test";
+import { mkdir, mkdtemp, rm, writeFile } from "node:fs/promises";
+import { join, relative } from "node:path";
+import { tmpdir } from "node:os";
+import { findVendorTrees, isVendorPath, parseVendorModules, vendorBoosts } from "../src/vendor";
+import { dependencyCollections } from "../src/go-deps";
+import { GoModuleIndex } from "../src/go-modules";
+import { listCodeFiles } from "../src/code-indexer";
+import { IndexCoverage } from "../src/coverage";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { makeDoc, makeNode } from "./fixtures/helpers";
+
+const MODULES_TXT = `# github.com/stretchr/testify v1.9.0
+## explicit; go 1.17
+github.com/stretchr/testify/assert
+# github.com/forked/dep v1.0.0 => github.com/me/dep v1.1.0
+## explicit
+github.com/forked/dep
+`;
+
+let dir: string;
+let config: IndexConfig;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-vendor-"));
+  const files: Record<string, string> = {
+    "repo/go.mod": `module example.com/app\n\nrequire (\n\tgithub.com/stretchr/testify v1.9.0\n\tgithub.com/forked/dep v1.0.0\n\tgithub.com/BurntSushi/toml v1.3.2\n)\n\nreplace github.com/forked/dep => github.com/me/dep v1.1.0\n`,
+    "repo/main.go": "package main\n",
+    "repo/vendor/modules.txt": MODULES_TXT,
+    "repo/vendor/github.com/stretchr/testify/assert/assertions.go": "package assert\n",
+    "modcache/github.com/stretchr/testify@v1.9.0/assert/assertions.go": "package assert\n",
+    "modcache/github.com/me/dep@v1.1.0/dep.go": "package dep\n",
+    "modcache/github.com/!burnt!sushi/toml@v1.3.2/decode.go": "package toml\n",
+  };
+  for (const [path, content] of Object.entries(files)) {
+    await mkdir(join(dir, path, ".."), { recursive: true });
+    await writeFile(join(dir, path), content);
+  }
+  config = {
+    collections: [],
+    code_collections: [{ name: "code", root: join(dir, "repo"), weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("vendor detection", () => {
+  test("recognizes vendor/ at any depth, not as a name prefix", () => {
+    expect(isVendorPath("vendor/github.com/x/y.go")).toBe(true);
+    expect(isVendorPath("services/api/vendor/lib.php")).toBe(true);
+    expect(isVendorPath("vendor")).toBe(true);
+    expect(isVendorPath("pkg/vendored/x.go")).toBe(false);
+    expect(isVendorPath("vendor.go")).toBe(false);
+  });
+
+  test("reads vendored modules from modules.txt", async () => {
+    expect(parseVendorModules(MODULES_TXT)).toEqual(["github.com/stretchr/testify@v1.9.0", "github.com/forked/dep@v1.0.0"]);
+    const trees = await findVendorTrees(config, new GoModuleIndex(config));
+    expect(trees.map((t) => [t.collection, t.dir, t.modules.length])).toEqual([["code", "vendor", 2]]);
+  });
+});
+
+describe("vendor policy", () => {
+  const listed = async () => (await listCodeFiles(config.code_collections![0])).map((p) => relative(join(dir, "repo"), p)).sort();
+
+  test("index and downrank keep vendored files; exclude drops them", async () => {
+    expect(await listed()).toContain("vendor/github.com/stretchr/testify/assert/assertions.go");
+    config.code_collections![0].vendor = "downrank";
+    expect(await listed()).toContain("vendor/github.com/stretchr/testify/assert/assertions.go");
+    config.code_collections![0].vendor = "exclude";
+    expect(await listed()).toEqual(["main.go"]);
+  });
+
+  test("explains excluded vendored doc_ids", () => {
+    config.code_collections![0].vendor = "exclude";
+    const coverage = new IndexCoverage(config);
+    expect(coverage.excludedDocId("code:vendor:github.com:x:y_go")).toContain("VENDOR_POLICY is exclude");
+    expect(coverage.excludedDocId("code:main_go")).toBeNull();
+  });
+
+  test("downrank ranks vendored code below first-party code", () => {
+    expect(vendorBoosts("index")).toEqual([]);
+    const store = new DocumentStore();
+    const doc = (path: string) =>
+      makeDoc({
+        meta: { doc_id: `code:${path}`, file_path: path, title: path },
+        tree: [makeNode({ node_id: `code:${path}:n1`, title: "function Dial", content: "Dial opens a connection. Dial dials." })],
+      });
+    store.load([doc("vendor/github.com/x/dial.go"), doc("pkg/dial.go")]);
+    store.setPathBoosts(vendorBoosts("downrank"));
+    expect(store.searchDocuments("dial").map((r) => r.doc_id)).toEqual(["code:pkg/dial.go", "code:vendor/github.com/x/dial.go"]);
+  });
+});
+
+describe("module cache deduplication", () => {
+  test("skips dependencies already vendored, by required version", async () => {
+    const goModules = new GoModuleIndex(config);
+    const vendored = new Set((await findVendorTrees(config, goModules)).flatMap((t) => t.modules));
+    const deps = await dependencyCollections(goModules, join(dir, "modcache"), { vendored });
+    expect(deps.collections.map((c) => c.name)).toEqual(["gomod/github.com/BurntSushi/toml@v1.3.2"]);
+    expect(deps.vendored).toEqual(["github.com/me/dep@v1.1.0", "github.com/stretchr/testify@v1.9.0"]);
+  });
+});
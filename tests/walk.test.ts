@@ -0,0 +1,106 @@
+/**
+ * Tests for symlink-aware file discovery.
+ *
+ * Covers: each symlink policy, loop detection, de-duplication of
+ * files reachable through several paths, dangling links, and stopping
+ * at a file limit.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir, symlink } from "node:fs/promises";
+import { join, relative } from "node:path";
+import { tmpdir } from "node:os";
+import { DocumentQuotaError, walkFiles } from "../src/walk";
+import { indexAllCollections, listCollectionFiles } from "../src/indexer";
+import { singleRootConfig } from "../src/types";
+import { ConfigError, resolveConfig } from "../src/config";
+
+let dir: string;
+let root: string;
+let outside: string;
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-walk-"));
+  root = join(dir, "repo");
+  outside = join(dir, "vendor");
+  await mkdir(join(root, "guides"), { recursive: true });
+  await mkdir(join(root, "shared"), { recursive: true });
+  await mkdir(outside, { recursive: true });
+  await writeFile(join(root, "guides", "intro.md"), "# Intro\n");
+  await writeFile(join(root, "shared", "setup.md"), "# Setup\n");
+  await writeFile(join(outside, "pkg.md"), "# Vendored\n");
+
+  await symlink(join(root, "shared"), join(root, "guides", "shared"));
+  await symlink(outside, join(root, "guides", "vendor"));
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+async function list(symlinks: "skip" | "within-root" | "all", match?: (p: string) => boolean) {
+  const files = await walkFiles(root, { symlinks, match, log: () => {} });
+  return files.map((f) => relative(root, f).split("\\").join("/")).sort();
+}
+
+describe("walkFiles", () => {
+  test("skip ignores every link", async () => {
+    expect(await list("skip")).toEqual(["guides/intro.md", "shared/setup.md"]);
+  });
+
+  test("within-root follows links that stay inside the root", async () => {
+    const guidesOnly = (p: string) => p.startsWith("guides/");
+    expect(await list("within-root", guidesOnly)).toEqual(["guides/intro.md", "guides/shared/setup.md"]);
+  });
+
+  test("all follows links out of the root", async () => {
+    expect(await list("all")).toContain("guides/vendor/pkg.md");
+  });
+
+  test("reports a file reachable twice once, under its direct path", async () => {
+    expect(await list("within-root")).toEqual(["guides/intro.md", "shared/setup.md"]);
+  });
+
+  test("skips cyclic links instead of recursing", async () => {
+    await symlink(root, join(root, "shared", "loop"));
+    const warnings: string[] = [];
+    const files = await walkFiles(root, { symlinks: "all", log: (m) => warnings.push(m) });
+
+    expect(files.length).toBe(3);
+    expect(warnings.some((w) => w.includes("symlink loop"))).toBe(true);
+  });
+
+  test("ignores dangling links", async () => {
+    await symlink(join(dir, "missing"), join(root, "guides", "gone.md"));
+    expect(await list("all")).not.toContain("guides/gone.md");
+  });
+
+  test("under limits the walk to one directory or file", async () => {
+    const under = async (path: string) =>
+      (await walkFiles(root, { symlinks: "skip", under: path })).map((f) => relative(root, f).split("\\").join("/"));
+    expect(await under("shared")).toEqual(["shared/setup.md"]);
+    expect(await under("guides/intro.md")).toEqual(["guides/intro.md"]);
+    expect(await under("guide")).toEqual([]);
+  });
+
+  test("limit stops the walk one file past it", async () => {
+    expect(await walkFiles(root, { symlinks: "all", limit: 1, log: () => {} })).toHaveLength(2);
+    expect(await walkFiles(root, { symlinks: "all", limit: 0, log: () => {} })).toHaveLength(1);
+  });
+
+  test("a listing past maxDocuments fails before any file is indexed", async () => {
+    await expect(indexAllCollections(singleRootConfig(root), undefined, { maxDocuments: 1 })).rejects.toThrow(DocumentQuotaError);
+    expect(await indexAllCollections(singleRootConfig(root), undefined, { maxDocuments: 2 })).toHaveLength(2);
+  });
+});
+
+describe("SYMLINKS", () => {
+  test("threads the policy through to collection listing", async () => {
+    const files = await listCollectionFiles({ name: "docs", root, weight: 1, symlinks: "all" });
+    expect(files.some((f) => f.endsWith("pkg.md"))).toBe(true);
+  });
+
+  test("rejects unknown policies", () => {
+    expect(() => resolveConfig({ env: { SYMLINKS: "sometimes" } })).toThrow(ConfigError);
+  });
+});
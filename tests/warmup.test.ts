@@ -0,0 +1,124 @@
+/**
+ * Tests for the priority warm-up.
+ *
+ * Covers: manifest parsing, resolving manifest entries and recently
+ * committed files against the collections, and a cold start that
+ * serves the priority files while the rest is indexed.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { existsSync } from "node:fs";
+import { join, relative } from "node:path";
+import { tmpdir } from "node:os";
+import { parseManifest, priorityFiles } from "../src/warmup";
+import { gitRecentFiles } from "../src/git-history";
+import { loadOrBuildIndex } from "../src/index-cache";
+import { codeDocId } from "../src/code-indexer";
+import { DocumentStore } from "../src/store";
+import type { IndexConfig } from "../src/types";
+import { git, initRepo } from "./fixtures/git";
+
+let dir: string;
+let config: IndexConfig;
+
+const quiet = () => {};
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-warmup-"));
+  await mkdir(join(dir, "docs"), { recursive: true });
+  await mkdir(join(dir, "src", "pool"), { recursive: true });
+  await mkdir(join(dir, "src", ".cache"), { recursive: true });
+  await writeFile(join(dir, "docs", "guide.md"), "# Guide\n\nConnection pools explained\n");
+  await writeFile(join(dir, "docs", "notes.txt"), "not indexed\n");
+  await writeFile(join(dir, "src", "pool", "pool.go"), "package pool\n\nfunc Acquire() {}\n");
+  await writeFile(join(dir, "src", "pool", "retry.go"), "package pool\n\nfunc Retry() {}\n");
+  await writeFile(join(dir, "src", "main.go"), "package main\n\nfunc main() {}\n");
+  await writeFile(join(dir, "src", ".cache", "gen.go"), "package cache\n");
+  config = {
+    collections: [{ name: "docs", root: join(dir, "docs"), weight: 1.0 }],
+    code_collections: [{ name: "code", root: join(dir, "src"), weight: 1.0 }],
+    summary_length: 200,
+    max_depth: 6,
+  };
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+function paths(files: { path: string }[]): string[] {
+  return files.map((f) => relative(dir, f.path));
+}
+
+describe("parseManifest", () => {
+  test("skips blank lines and comments", () => {
+    expect(parseManifest("# hot paths\npool/pool.go\n\n  guide.md  \n#old.go\n")).toEqual(["pool/pool.go", "guide.md"]);
+  });
+});
+
+describe("priorityFiles", () => {
+  test("resolves manifest entries against each collection once, as discovery would", async () => {
+    const manifest = join(dir, "priority.txt");
+    await writeFile(
+      manifest,
+      ["pool/pool.go", "guide.md", join(dir, "src", "main.go"), "pool/pool.go", "notes.txt", ".cache/gen.go", "gone.go"].join("\n")
+    );
+    const files = await priorityFiles(config, { manifest, log: quiet });
+    expect(paths(files)).toEqual(["src/pool/pool.go", "docs/guide.md", "src/main.go"]);
+    expect(files.map((f) => f.kind)).toEqual(["code", "markdown", "code"]);
+  });
+
+  test("an unreadable manifest is reported and skipped", async () => {
+    const warnings: string[] = [];
+    expect(await priorityFiles(config, { manifest: join(dir, "missing.txt"), log: (msg) => warnings.push(msg) })).toEqual([]);
+    expect(warnings[0]).toContain("PRIORITY_FILES");
+  });
+
+  test("recent commits add the files they changed, newest first", async () => {
+    initRepo(dir);
+    await writeFile(join(dir, "src", "pool", "retry.go"), "package pool\n\nfunc Retry(n int) {}\n");
+    git(dir, ["commit", "-q", "-am", "retries"]);
+
+    expect(gitRecentFiles(join(dir, "src"), 1)).toEqual(["pool/retry.go"]);
+    // Per root: the docs' last commit is the initial one
+    const files = await priorityFiles(config, { recent_commits: 1, log: quiet });
+    expect(paths(files)).toEqual(["docs/guide.md", "src/pool/retry.go"]);
+    expect(gitRecentFiles(join(dir, "not-a-dir"), 5)).toEqual([]);
+  });
+});
+
+describe("loadOrBuildIndex with priority files", () => {
+  test("serves the priority files first, then indexes the rest in the background", async () => {
+    const manifest = join(dir, "priority.txt");
+    await writeFile(manifest, "pool/pool.go\n");
+    const cachePath = join(dir, ".treenav", "index.json");
+    const store = new DocumentStore();
+    const result = await loadOrBuildIndex(store, config, { cachePath, persist: true, priority: { manifest }, log: quiet });
+
+    expect(result.warm).toBe(false);
+    expect(result.priority).toBe(1);
+    expect(store.getStats().document_count).toBe(1);
+    expect(store.hasDocument(codeDocId("code", "pool/pool.go"))).toBe(true);
+    expect(store.isValidating()).toBe(true);
+
+    const report = await result.validation!;
+    expect(report!.added.length).toBe(3);
+    expect(store.getStats().document_count).toBe(4);
+    expect(store.isValidating()).toBe(false);
+    expect(existsSync(cachePath)).toBe(true);
+  });
+
+  test("without a match, a cold start indexes everything up front", async () => {
+    const store = new DocumentStore();
+    const result = await loadOrBuildIndex(store, config, {
+      cachePath: join(dir, "index.json"),
+      persist: false,
+      priority: { manifest: join(dir, "missing.txt") },
+      log: quiet,
+    });
+    expect(result.priority).toBe(0);
+    expect(result.validation).toBeNull();
+    expect(store.getStats().document_count).toBe(4);
+  });
+});
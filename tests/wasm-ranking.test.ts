@@ -0,0 +1,141 @@
+/**
+ * Tests for RANKING_WASM: the rescore ABI, path access through alloc,
+ * trap fallback, and filtering search results in the store.
+ *
+ * The modules are assembled by hand, so the tests need no WASM toolchain.
+ */
+
+import { describe, test, expect } from "bun:test";
+import { DocumentStore } from "../src/store";
+import { RankingWasmError, loadRankingWasm, wasmRescorer } from "../src/wasm-ranking";
+import { makeDoc, makeMeta } from "./fixtures/helpers";
+import type { SearchResult } from "../src/types";
+
+// ── A minimal WASM binary builder ───────────────────────────────────
+
+const F64 = 0x7c;
+const I32 = 0x7f;
+const RESCORE_TYPE = [0x60, 7, F64, I32, I32, I32, F64, I32, I32, 1, F64];
+const ALLOC_TYPE = [0x60, 1, I32, 1, I32];
+
+function f64(value: number): number[] {
+  return [...new Uint8Array(new Float64Array([value]).buffer)];
+}
+
+function name(text: string): number[] {
+  return [text.length, ...new TextEncoder().encode(text)];
+}
+
+function section(id: number, items: number[][]): number[] {
+  const body = [items.length, ...items.flat()];
+  return [id, body.length, ...body];
+}
+
+/** A module of functions (type index, body) plus exports (name, kind, index). */
+function wasmModule(options: {
+  types: number[][];
+  funcs: { type: number; body: number[] }[];
+  memory?: boolean;
+  exports: [string, number, number][];
+}): Uint8Array {
+  return new Uint8Array([
+    0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+    ...section(1, options.types),
+    ...section(3, options.funcs.map((f) => [f.type])),
+    ...(options.memory ? section(5, [[0x00, 1]]) : []),
+    ...section(7, options.exports.map(([n, kind, index]) => [...name(n), kind, index])),
+    ...section(10, options.funcs.map((f) => [f.body.length + 2, 0x00, ...f.body, 0x0b])),
+  ]);
+}
+
+/** rescore = score * (code + 1): code ranks above docs */
+const BOOST_CODE = wasmModule({
+  types: [RESCORE_TYPE],
+  funcs: [{ type: 0, body: [0x20, 0, 0x20, 2, 0xb7, 0x44, ...f64(1), 0xa0, 0xa2] }],
+  exports: [["rescore", 0, 0]],
+});
+
+/** rescore = -1 when the path starts with "t", else score; alloc always returns 16 */
+const DROP_T_PATHS = wasmModule({
+  types: [RESCORE_TYPE, ALLOC_TYPE],
+  funcs: [
+    {
+      type: 0,
+      body: [0x20, 5, 0x2d, 0, 0, 0x41, 0xf4, 0x00, 0x46, 0x04, F64, 0x44, ...f64(-1), 0x05, 0x20, 0, 0x0b],
+    },
+    { type: 1, body: [0x41, 16] },
+  ],
+  memory: true,
+  exports: [["rescore", 0, 0], ["alloc", 0, 1], ["memory", 2, 0]],
+});
+
+/** rescore = unreachable */
+const TRAPS = wasmModule({ types: [RESCORE_TYPE], funcs: [{ type: 0, body: [0x00] }], exports: [["rescore", 0, 0]] });
+
+function candidate(file_path: string, score: number, code = false) {
+  const result = { doc_id: file_path, file_path, score, level: 1 } as SearchResult;
+  return { result, meta: makeMeta({ file_path, facets: code ? { content_type: ["code"] } : {} }) };
+}
+
+// ── Tests ───────────────────────────────────────────────────────────
+
+describe("wasmRescorer", () => {
+  test("passes each candidate's features to rescore", async () => {
+    const rescore = await wasmRescorer(BOOST_CODE, { log: () => {} });
+    expect(rescore([candidate("a.md", 3), candidate("b.ts", 2, true)], "q")).toEqual([3, 4]);
+  });
+
+  test("copies candidate paths into module memory through alloc", async () => {
+    const rescore = await wasmRescorer(DROP_T_PATHS, { log: () => {} });
+    expect(rescore([candidate("docs/a.md", 5), candidate("tests/a.md", 4), candidate("t", 3)], "q")).toEqual([5, -1, -1]);
+  });
+
+  test("keeps the built-in scores when the module traps", async () => {
+    const logs: string[] = [];
+    const rescore = await wasmRescorer(TRAPS, { log: (msg) => logs.push(msg) });
+    expect(rescore([candidate("a.md", 3), candidate("b.md", 2)], "q")).toEqual([3, 2]);
+    expect(logs.length).toBe(1);
+    expect(logs[0].startsWith("Warning: RANKING_WASM trapped (1 so far)")).toBe(true);
+  });
+
+  test("rejects invalid modules and missing exports", async () => {
+    const unnamed = wasmModule({ types: [RESCORE_TYPE], funcs: [{ type: 0, body: [0x20, 0] }], exports: [["score", 0, 0]] });
+    await expect(wasmRescorer(unnamed)).rejects.toThrow('missing export "rescore"');
+    await expect(wasmRescorer(new Uint8Array([1, 2, 3]))).rejects.toBeInstanceOf(RankingWasmError);
+  });
+
+  test("loadRankingWasm names the missing file", async () => {
+    await expect(loadRankingWasm("/nonexistent/rank.wasm")).rejects.toThrow("/nonexistent/rank.wasm: file not found");
+  });
+});
+
+describe("DocumentStore.setRescorer", () => {
+  function store(): DocumentStore {
+    const s = new DocumentStore();
+    s.load([
+      makeDoc({ meta: { doc_id: "docs:guide", file_path: "guide/auth.md", title: "Auth Guide" } }),
+      makeDoc({ meta: { doc_id: "docs:tests", file_path: "tests/auth.md", title: "Auth Tests" } }),
+    ]);
+    return s;
+  }
+
+  test("drops candidates the module scores negative", async () => {
+    const s = store();
+    const before = new Set(s.searchDocuments("authentication").map((r) => r.file_path));
+    expect(before.has("tests/auth.md")).toBe(true);
+
+    s.setRescorer(await wasmRescorer(DROP_T_PATHS, { log: () => {} }));
+    const after = s.searchDocuments("authentication");
+    expect(after.length).toBeGreaterThan(0);
+    expect(after.every((r) => r.file_path === "guide/auth.md")).toBe(true);
+
+    s.setRescorer(null);
+    expect(new Set(s.searchDocuments("authentication").map((r) => r.file_path))).toEqual(before);
+  });
+
+  test("re-sorts by the new scores", () => {
+    const s = store();
+    s.setRescorer((candidates) => candidates.map(({ result }) => (result.file_path.startsWith("tests/") ? 100 : 1)));
+    expect(s.searchDocuments("authentication")[0].file_path).toBe("tests/auth.md");
+  });
+});
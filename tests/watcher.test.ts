@@ -0,0 +1,187 @@
+/**
+ * Tests for the debounced file watcher.
+ *
+ * Covers: incremental updates and removals, debounce coalescing,
+ * rename storms falling back to one full pass, directory changes,
+ * extensionless scripts in code collections, paths outside the
+ * collection globs, the bounded queue, and flushes that never overlap.
+ */
+
+import { describe, test, expect, beforeEach, afterEach } from "bun:test";
+import { mkdtemp, writeFile, rm, mkdir } from "node:fs/promises";
+import { join } from "node:path";
+import { tmpdir } from "node:os";
+import { DocumentStore } from "../src/store";
+import { indexAllCollections } from "../src/indexer";
+import { IndexWatcher, type WatchFlushReport } from "../src/watcher";
+import { singleRootConfig } from "../src/types";
+import type { IndexConfig } from "../src/types";
+
+let dir: string;
+let config: IndexConfig;
+let store: DocumentStore;
+let reports: WatchFlushReport[];
+
+function watcher(options: { debounceMs?: number; batchSize?: number } = {}) {
+  return new IndexWatcher(store, config, {
+    ...options,
+    log: () => {},
+    onFlush: (report) => reports.push(report),
+  });
+}
+
+beforeEach(async () => {
+  dir = await mkdtemp(join(tmpdir(), "treenav-watch-"));
+  await mkdir(join(dir, "guides"), { recursive: true });
+  await writeFile(join(dir, "a.md"), "# Alpha\n\nOriginal text.\n");
+  await writeFile(join(dir, "guides", "b.md"), "# Beta\n\nMore text.\n");
+  await writeFile(join(dir, "guides", "c.md"), "# Gamma\n\nEven more.\n");
+
+  config = singleRootConfig(dir);
+  store = new DocumentStore();
+  store.load(await indexAllCollections(config));
+  reports = [];
+});
+
+afterEach(async () => {
+  await rm(dir, { recursive: true, force: true });
+});
+
+describe("IndexWatcher", () => {
+  test("re-indexes changed files incrementally", async () => {
+    const w = watcher();
+    await writeFile(join(dir, "a.md"), "# Alpha\n\nRewritten with zeppelin.\n");
+    w.notify(join(dir, "a.md"));
+    await w.flush();
+
+    expect(store.searchDocuments("zeppelin").map((r) => r.doc_id)).toEqual(["docs:a"]);
+    expect(reports.length).toBe(1);
+    expect(reports[0].mode).toBe("incremental");
+    expect(reports[0].updated).toBe(1);
+  });
+
+  test("removes deleted files", async () => {
+    const w = watcher();
+    await rm(join(dir, "a.md"));
+    w.notify(join(dir, "a.md"));
+    await w.flush();
+
+    expect(store.hasDocument("docs:a")).toBe(false);
+    expect(reports[0].removed).toBe(1);
+  });
+
+  test("skips files whose content is unchanged", async () => {
+    const w = watcher();
+    w.notify(join(dir, "a.md"));
+    await w.flush();
+    expect(reports[0].updated).toBe(0);
+  });
+
+  test("debounces a burst into one flush", async () => {
+    const w = watcher({ debounceMs: 20 });
+    w.notify(join(dir, "a.md"));
+    w.notify(join(dir, "guides", "b.md"));
+    w.notify(join(dir, "a.md"));
+    expect(w.pendingCount()).toBe(2);
+
+    await new Promise((r) => setTimeout(r, 80));
+    await w.flush();
+    expect(reports.length).toBe(1);
+    expect(reports[0].files).toBe(2);
+  });
+
+  test("coalesces a storm above the batch size into one full pass", async () => {
+    const w = watcher({ batchSize: 2 });
+    await writeFile(join(dir, "guides", "d.md"), "# Delta\n\nNew file.\n");
+    await rm(join(dir, "guides", "c.md"));
+    for (const file of ["a.md", "guides/b.md", "guides/c.md", "guides/d.md"]) {
+      w.notify(join(dir, file));
+    }
+    await w.flush();
+
+    expect(reports.length).toBe(1);
+    expect(reports[0].mode).toBe("full");
+    expect(store.hasDocument("docs:guides:d")).toBe(true);
+    expect(store.hasDocument("docs:guides:c")).toBe(false);
+    expect(store.isValidating()).toBe(false);
+  });
+
+  test("treats a removed directory as a full pass", async () => {
+    const w = watcher();
+    await rm(join(dir, "guides"), { recursive: true });
+    w.notify(join(dir, "guides"));
+    await w.flush();
+
+    expect(reports[0].mode).toBe("full");
+    expect(store.getStats().document_count).toBe(1);
+  });
+
+  test("indexes extensionless scripts and still sees removed directories", async () => {
+    await mkdir(join(dir, "bin"), { recursive: true });
+    config = { ...config, code_collections: [{ name: "code", root: dir, weight: 1 }] };
+    const w = watcher();
+    await writeFile(join(dir, "bin", "deploy"), "#!/bin/bash\nrelease() {\n  echo shipped\n}\n");
+    await writeFile(join(dir, "bin", "NOTES"), "Nothing to run here.\n");
+    w.notify(join(dir, "bin", "deploy"));
+    w.notify(join(dir, "bin", "NOTES"));
+    await w.flush();
+
+    expect(store.getDocMeta("code:bin:deploy")?.facets.language).toEqual(["shell"]);
+    expect(store.hasDocument("code:bin:NOTES")).toBe(false);
+    expect(reports[0].mode).toBe("incremental");
+
+    await rm(join(dir, "guides"), { recursive: true });
+    w.notify(join(dir, "guides"));
+    await w.flush();
+    expect(reports[1].mode).toBe("full");
+  });
+
+  test("keeps at most a batch of paths during a storm", async () => {
+    const w = watcher({ batchSize: 2 });
+    w.notify(join(dir, "a.md"));
+    w.notify(join(dir, "a.md"));
+    expect([w.pendingCount(), w.queueStatus().full_pass]).toEqual([1, false]);
+    for (let i = 0; i < 50; i++) w.notify(join(dir, `storm-${i}.md`));
+    expect([w.pendingCount(), w.queueStatus().full_pass]).toEqual([51, true]);
+    await w.flush();
+
+    expect(reports.map((r) => [r.mode, r.files])).toEqual([["full", 51]]);
+    expect(w.queueStatus()).toEqual({ pending: 0, full_pass: false, flushing: false, oldest_ms: 0, last_flush: reports[0] });
+    w.stop();
+  });
+
+  test("changes during a flush wait for one follow-up flush", async () => {
+    const w = watcher({ debounceMs: 10_000 });
+    w.notify(join(dir, "guides"));
+    const first = w.flush();
+    await writeFile(join(dir, "a.md"), "# Alpha\n\nRewritten with zeppelin.\n");
+    w.notify(join(dir, "a.md"));
+    expect(w.queueStatus().flushing).toBe(true);
+    const second = w.flush();
+    expect(w.flush()).toBe(second);
+    await first;
+    await second;
+
+    expect(reports.map((r) => r.mode)).toEqual(["full", "incremental"]);
+    expect(store.searchDocuments("zeppelin").map((r) => r.doc_id)).toEqual(["docs:a"]);
+    w.stop();
+  });
+
+  test("reports its queue in the store stats while running", () => {
+    const w = watcher();
+    w.start();
+    w.notify(join(dir, "a.md"));
+    expect(store.getStats().reindex?.pending).toBe(1);
+    w.stop();
+    expect(store.getStats().reindex).toBeUndefined();
+  });
+
+  test("ignores paths outside the collection glob", () => {
+    const w = watcher();
+    w.notify(join(dir, "notes.txt"));
+    w.notify(join(dir, ".git", "HEAD.md"));
+    w.notify(join(tmpdir(), "elsewhere.md"));
+    expect(w.pendingCount()).toBe(0);
+    w.stop();
+  });
+});
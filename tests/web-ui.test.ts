@@ -0,0 +1,29 @@
+/**
+ * Tests for the embedded web UI: the page route, a script that parses,
+ * and only REST routes that exist being called.
+ */
+
+import { describe, expect, test } from "bun:test";
+import { handleWebUi } from "../src/web-ui";
+
+async function page(): Promise<string> {
+  const response = handleWebUi(new Request("http://localhost/ui"))!;
+  expect(response.headers.get("content-type")).toContain("text/html");
+  return response.text();
+}
+
+describe("handleWebUi", () => {
+  test("serves the page at /ui only", async () => {
+    expect(handleWebUi(new Request("http://localhost/ui/"))).not.toBeNull();
+    expect(handleWebUi(new Request("http://localhost/ui/x"))).toBeNull();
+    expect(handleWebUi(new Request("http://localhost/search?q=x"))).toBeNull();
+    expect(await page()).toContain("<title>treenav index</title>");
+  });
+
+  test("ships a script that parses and calls existing REST routes", async () => {
+    const script = (await page()).match(/<script>([\s\S]*)<\/script>/)![1];
+    expect(() => new Function(script)).not.toThrow();
+    const routes = [...script.matchAll(/api\("\/(\w+)/g)].map((m) => m[1]);
+    expect([...new Set(routes)].sort()).toEqual(["documents", "node", "search", "symbol", "tree"]);
+  });
+});